@@ -0,0 +1,74 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package authz
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/ant0ine/go-json-rest/rest"
+	"github.com/mendersoftware/go-lib-micro/identity"
+	"github.com/mendersoftware/go-lib-micro/log"
+	"github.com/mendersoftware/go-lib-micro/rest_utils"
+)
+
+// Errors
+var (
+	ErrForbidden = errors.New("Forbidden")
+)
+
+// AuthzMiddleware checks every request against the Authorizer.
+// Requests are described by tenant and subject of the caller identity,
+// HTTP method (action) and URL path (resource).
+// Should be placed after identity.IdentityMiddleware.
+type AuthzMiddleware struct {
+	Authorizer Authorizer
+
+	// Allow requests if the authorization decision could not be obtained,
+	// deny otherwise
+	FailOpen bool
+}
+
+// MiddlewareFunc makes AuthzMiddleware implement the Middleware interface.
+func (mw *AuthzMiddleware) MiddlewareFunc(h rest.HandlerFunc) rest.HandlerFunc {
+	return func(w rest.ResponseWriter, r *rest.Request) {
+		ctx := r.Context()
+		l := log.FromContext(ctx)
+
+		input := &Input{
+			Action:   r.Method,
+			Resource: r.URL.Path,
+		}
+		if id := identity.FromContext(ctx); id != nil {
+			input.Tenant = id.Tenant
+			input.Subject = id.Subject
+			input.IsDevice = id.IsDevice
+			input.IsUser = id.IsUser
+		}
+
+		allow, err := mw.Authorizer.Authorize(ctx, input)
+		if err != nil {
+			l.Errorf("authorization check failed: %v", err)
+			allow = mw.FailOpen
+		}
+
+		if !allow {
+			rest_utils.RestErrWithLog(w, r, l, ErrForbidden, http.StatusForbidden)
+			return
+		}
+
+		h(w, r)
+	}
+}
@@ -0,0 +1,89 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package authz
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/ant0ine/go-json-rest/rest"
+	"github.com/ant0ine/go-json-rest/rest/test"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeAuthorizer struct {
+	allow bool
+	err   error
+	input *Input
+}
+
+func (f *fakeAuthorizer) Authorize(ctx context.Context, input *Input) (bool, error) {
+	f.input = input
+	return f.allow, f.err
+}
+
+func TestAuthzMiddleware(t *testing.T) {
+
+	t.Parallel()
+
+	testCases := map[string]struct {
+		Allow    bool
+		Err      error
+		FailOpen bool
+
+		Code int
+	}{
+		"allowed": {
+			Allow: true,
+			Code:  http.StatusOK,
+		},
+		"denied": {
+			Code: http.StatusForbidden,
+		},
+		"failure, fail closed": {
+			Err:  errors.New("connection refused"),
+			Code: http.StatusForbidden,
+		},
+		"failure, fail open": {
+			Err:      errors.New("connection refused"),
+			FailOpen: true,
+			Code:     http.StatusOK,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			authorizer := &fakeAuthorizer{allow: tc.Allow, err: tc.Err}
+
+			api := rest.NewApi()
+			api.Use(&AuthzMiddleware{
+				Authorizer: authorizer,
+				FailOpen:   tc.FailOpen,
+			})
+			api.SetApp(rest.AppSimple(func(w rest.ResponseWriter, r *rest.Request) {
+				w.WriteJson(map[string]string{})
+			}))
+
+			req := test.MakeSimpleRequest("DELETE", "http://localhost/api/artifacts/1", nil)
+			recorded := test.RunRequest(t, api.MakeHandler(), req)
+			recorded.CodeIs(tc.Code)
+
+			assert.Equal(t, http.MethodDelete, authorizer.input.Action)
+			assert.Equal(t, "/api/artifacts/1", authorizer.input.Resource)
+		})
+	}
+}
@@ -0,0 +1,245 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package authz
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/asaskevich/govalidator"
+	"github.com/mendersoftware/go-lib-micro/requestid"
+	"github.com/pkg/errors"
+)
+
+const (
+	DefaultTimeout  = 5 * time.Second
+	DefaultCacheTTL = time.Minute
+	// Maximum number of cached decisions, least recently used ones are
+	// evicted first
+	DefaultCacheSize = 10000
+)
+
+// Input is the authorization request context evaluated by the policy.
+type Input struct {
+	Tenant   string `json:"tenant"`
+	Subject  string `json:"subject"`
+	IsDevice bool   `json:"is_device"`
+	IsUser   bool   `json:"is_user"`
+	Action   string `json:"action"`
+	Resource string `json:"resource"`
+}
+
+func (i *Input) key() string {
+	b, _ := json.Marshal(i)
+	return string(b)
+}
+
+// Authorizer decides whether the request described by input is allowed.
+type Authorizer interface {
+	Authorize(ctx context.Context, input *Input) (bool, error)
+}
+
+// OPAClient queries Open Policy Agent data API for authorization decisions.
+// Decision document is expected to be either a boolean, or an object with
+// boolean "allow" field; undefined decision denies the request.
+// Decisions are cached per input for the configured time, up to the
+// configured number of inputs.
+type OPAClient struct {
+	client *http.Client
+	uri    string
+	ttl    time.Duration
+	size   int
+
+	lock  sync.Mutex
+	cache map[string]*list.Element
+	// most recently used decisions first
+	lru *list.List
+}
+
+type decision struct {
+	key     string
+	allow   bool
+	expires time.Time
+}
+
+type OPAClientOption func(*OPAClient)
+
+// WithHTTPClient overrides default http client.
+func WithHTTPClient(client *http.Client) OPAClientOption {
+	return func(c *OPAClient) {
+		if client != nil {
+			c.client = client
+		}
+	}
+}
+
+// WithCacheTTL sets decision cache time to live, 0 disables caching.
+func WithCacheTTL(ttl time.Duration) OPAClientOption {
+	return func(c *OPAClient) {
+		c.ttl = ttl
+	}
+}
+
+// WithCacheSize sets maximum number of cached decisions, 0 disables caching.
+func WithCacheSize(size int) OPAClientOption {
+	return func(c *OPAClient) {
+		c.size = size
+	}
+}
+
+// NewOPAClient creates client of the OPA decision endpoint, e.g.
+// http://opa:8181/v1/data/mender/deployments/allow
+func NewOPAClient(uri string, options ...OPAClientOption) (*OPAClient, error) {
+	if !govalidator.IsURL(uri) {
+		return nil, errors.New("invalid OPA uri")
+	}
+
+	c := &OPAClient{
+		client: &http.Client{Timeout: DefaultTimeout},
+		uri:    uri,
+		ttl:    DefaultCacheTTL,
+		size:   DefaultCacheSize,
+		cache:  make(map[string]*list.Element),
+		lru:    list.New(),
+	}
+
+	for _, option := range options {
+		option(c)
+	}
+
+	return c, nil
+}
+
+func (c *OPAClient) Authorize(ctx context.Context, input *Input) (bool, error) {
+	key := input.key()
+
+	if allow, ok := c.cached(key); ok {
+		return allow, nil
+	}
+
+	allow, err := c.query(ctx, input)
+	if err != nil {
+		return false, err
+	}
+
+	c.store(key, allow)
+
+	return allow, nil
+}
+
+func (c *OPAClient) cached(key string) (bool, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	e, ok := c.cache[key]
+	if !ok {
+		return false, false
+	}
+	d := e.Value.(*decision)
+	if time.Now().After(d.expires) {
+		c.remove(e)
+		return false, false
+	}
+	c.lru.MoveToFront(e)
+	return d.allow, true
+}
+
+func (c *OPAClient) store(key string, allow bool) {
+	if c.ttl <= 0 || c.size <= 0 {
+		return
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	d := &decision{key: key, allow: allow, expires: time.Now().Add(c.ttl)}
+	if e, ok := c.cache[key]; ok {
+		e.Value = d
+		c.lru.MoveToFront(e)
+		return
+	}
+	c.cache[key] = c.lru.PushFront(d)
+
+	for c.lru.Len() > c.size {
+		c.remove(c.lru.Back())
+	}
+}
+
+func (c *OPAClient) remove(e *list.Element) {
+	c.lru.Remove(e)
+	delete(c.cache, e.Value.(*decision).key)
+}
+
+func (c *OPAClient) query(ctx context.Context, input *Input) (bool, error) {
+	body, err := json.Marshal(struct {
+		Input *Input `json:"input"`
+	}{Input: input})
+	if err != nil {
+		return false, errors.Wrap(err, "serializing OPA request")
+	}
+
+	req, err := http.NewRequest(http.MethodPost, c.uri, bytes.NewReader(body))
+	if err != nil {
+		return false, errors.Wrap(err, "creating OPA request")
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	//propagate request id
+	reqId := ctx.Value(requestid.RequestIdHeader)
+	if reqId != nil {
+		req.Header.Set(requestid.RequestIdHeader, reqId.(string))
+	}
+
+	resp, err := c.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return false, errors.Wrap(err, "sending OPA request")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return false, errors.Errorf("unexpected OPA response status: %d", resp.StatusCode)
+	}
+
+	result := struct {
+		Result json.RawMessage `json:"result"`
+	}{}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return false, errors.Wrap(err, "parsing OPA response")
+	}
+
+	// undefined decision
+	if len(result.Result) == 0 {
+		return false, nil
+	}
+
+	var allow bool
+	if err := json.Unmarshal(result.Result, &allow); err == nil {
+		return allow, nil
+	}
+
+	document := struct {
+		Allow bool `json:"allow"`
+	}{}
+	if err := json.Unmarshal(result.Result, &document); err != nil {
+		return false, errors.Wrap(err, "parsing OPA decision")
+	}
+
+	return document.Allow, nil
+}
@@ -0,0 +1,185 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package authz
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOPAClientAuthorize(t *testing.T) {
+
+	t.Parallel()
+
+	testCases := map[string]struct {
+		Code int
+		Body string
+
+		Allow bool
+		Err   error
+	}{
+		"boolean allow": {
+			Code:  http.StatusOK,
+			Body:  `{"result": true}`,
+			Allow: true,
+		},
+		"boolean deny": {
+			Code: http.StatusOK,
+			Body: `{"result": false}`,
+		},
+		"document allow": {
+			Code:  http.StatusOK,
+			Body:  `{"result": {"allow": true}}`,
+			Allow: true,
+		},
+		"undefined decision": {
+			Code: http.StatusOK,
+			Body: `{}`,
+		},
+		"server error": {
+			Code: http.StatusInternalServerError,
+			Err:  errors.New("unexpected OPA response status: 500"),
+		},
+	}
+
+	for name, test := range testCases {
+		t.Run(name, func(t *testing.T) {
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				req := struct {
+					Input Input `json:"input"`
+				}{}
+				assert.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+				assert.Equal(t, "tenant1", req.Input.Tenant)
+				assert.Equal(t, http.MethodGet, req.Input.Action)
+
+				w.WriteHeader(test.Code)
+				w.Write([]byte(test.Body))
+			}))
+			defer ts.Close()
+
+			c, err := NewOPAClient(ts.URL)
+			assert.NoError(t, err)
+
+			allow, err := c.Authorize(context.Background(), &Input{
+				Tenant:   "tenant1",
+				Subject:  "user1",
+				Action:   http.MethodGet,
+				Resource: "/api/management/v1/deployments/deployments",
+			})
+			if test.Err != nil {
+				assert.EqualError(t, err, test.Err.Error())
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, test.Allow, allow)
+		})
+	}
+}
+
+func TestOPAClientCache(t *testing.T) {
+
+	t.Parallel()
+
+	calls := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Write([]byte(`{"result": true}`))
+	}))
+	defer ts.Close()
+
+	input := &Input{Tenant: "tenant1", Action: http.MethodGet, Resource: "/r"}
+
+	c, err := NewOPAClient(ts.URL, WithCacheTTL(time.Hour))
+	assert.NoError(t, err)
+
+	for i := 0; i < 3; i++ {
+		allow, err := c.Authorize(context.Background(), input)
+		assert.NoError(t, err)
+		assert.True(t, allow)
+	}
+	assert.Equal(t, 1, calls)
+
+	// different input is not served from cache
+	_, err = c.Authorize(context.Background(), &Input{Tenant: "tenant2"})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, calls)
+
+	c, err = NewOPAClient(ts.URL, WithCacheTTL(0))
+	assert.NoError(t, err)
+
+	for i := 0; i < 2; i++ {
+		_, err := c.Authorize(context.Background(), input)
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, 4, calls)
+
+	_, err = NewOPAClient("ht/localhost")
+	assert.Error(t, err)
+}
+
+func TestOPAClientCacheSize(t *testing.T) {
+
+	t.Parallel()
+
+	calls := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Write([]byte(`{"result": true}`))
+	}))
+	defer ts.Close()
+
+	c, err := NewOPAClient(ts.URL, WithCacheTTL(time.Hour), WithCacheSize(2))
+	assert.NoError(t, err)
+
+	authorize := func(tenant string) {
+		_, err := c.Authorize(context.Background(), &Input{Tenant: tenant})
+		assert.NoError(t, err)
+	}
+
+	authorize("tenant1")
+	authorize("tenant2")
+	// tenant1 is the most recently used now
+	authorize("tenant1")
+	assert.Equal(t, 2, calls)
+
+	// evicts tenant2
+	authorize("tenant3")
+	assert.Equal(t, 3, calls)
+	assert.Len(t, c.cache, 2)
+	assert.Equal(t, 2, c.lru.Len())
+
+	authorize("tenant1")
+	authorize("tenant3")
+	assert.Equal(t, 3, calls)
+
+	authorize("tenant2")
+	assert.Equal(t, 4, calls)
+	assert.Len(t, c.cache, 2)
+
+	// expired decisions are not served
+	c.cache["x"] = c.lru.PushFront(&decision{
+		key: "x", allow: true, expires: time.Now().Add(-time.Second)})
+	allow, ok := c.cached("x")
+	assert.False(t, allow)
+	assert.False(t, ok)
+	assert.NotContains(t, c.cache, "x")
+}
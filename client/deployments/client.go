@@ -0,0 +1,229 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+// Package deployments is a client of the deployments service management
+// and internal APIs, meant to be used by other services instead of
+// issuing the HTTP requests on their own.
+package deployments
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/asaskevich/govalidator"
+	"github.com/mendersoftware/go-lib-micro/requestid"
+	"github.com/pkg/errors"
+)
+
+// API prefixes
+const (
+	URIManagement = "/api/management/v1/deployments"
+	URIInternal   = "/api/internal/v1/deployments"
+)
+
+// Retry defaults: idempotent requests failing with network error or
+// server side error are retried with exponentially growing backoff.
+const (
+	DefaultRetries = 3
+	DefaultBackoff = 500 * time.Millisecond
+)
+
+// ClientOption is the type of constructor options for NewClient
+type ClientOption func(*Client) error
+
+// Client of the deployments service.
+type Client struct {
+	client  *http.Client
+	uri     string
+	token   string
+	retries int
+	backoff time.Duration
+}
+
+// NewClient creates client of the deployments service reachable at uri,
+// either directly or through the API gateway.
+func NewClient(uri string, options ...ClientOption) (*Client, error) {
+
+	if !govalidator.IsURL(uri) {
+		return nil, errors.New("invalid server uri")
+	}
+
+	client := &Client{
+		client:  &http.Client{},
+		uri:     strings.TrimRight(uri, "/"),
+		retries: DefaultRetries,
+		backoff: DefaultBackoff,
+	}
+
+	for _, option := range options {
+		if err := option(client); err != nil {
+			return nil, err
+		}
+	}
+
+	return client, nil
+}
+
+// WithHTTPClient sets HTTP client used for requests.
+func WithHTTPClient(client *http.Client) ClientOption {
+	return func(c *Client) error {
+		if client != nil {
+			c.client = client
+		}
+		return nil
+	}
+}
+
+// WithToken sets JWT sent with every request, required by the
+// management API.
+func WithToken(token string) ClientOption {
+	return func(c *Client) error {
+		c.token = token
+		return nil
+	}
+}
+
+// WithRetries sets number of retries of failed idempotent requests
+// and backoff before the first retry, doubled on every next one.
+func WithRetries(retries int, backoff time.Duration) ClientOption {
+	return func(c *Client) error {
+		if retries < 0 || backoff < 0 {
+			return errors.New("retries and backoff must not be negative")
+		}
+		c.retries = retries
+		c.backoff = backoff
+		return nil
+	}
+}
+
+// do sends request, retrying it if allowed, and decodes successful response
+// into out if provided. Response headers are returned on success.
+func (c *Client) do(ctx context.Context, method, path string, query url.Values,
+	body interface{}, out interface{}) (http.Header, error) {
+
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			return nil, errors.Wrap(err, "encoding request body")
+		}
+	}
+
+	uri := c.uri + path
+	if len(query) > 0 {
+		uri += "?" + query.Encode()
+	}
+
+	retries := 0
+	if isIdempotent(method) {
+		retries = c.retries
+	}
+
+	var (
+		rsp *http.Response
+		err error
+	)
+	for attempt := 0; ; attempt++ {
+		var reqBody io.Reader
+		if payload != nil {
+			reqBody = bytes.NewReader(payload)
+		}
+		rsp, err = c.send(ctx, method, uri, "application/json", reqBody)
+		if attempt >= retries || ctx.Err() != nil || !isRetriable(rsp, err) {
+			break
+		}
+		if rsp != nil {
+			rsp.Body.Close()
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(c.backoff << uint(attempt)):
+		}
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "sending %s %s request", method, path)
+	}
+
+	return handleResponse(rsp, out)
+}
+
+// handleResponse closes the response, returns service error or decodes
+// successful response into out if provided.
+func handleResponse(rsp *http.Response, out interface{}) (http.Header, error) {
+	defer rsp.Body.Close()
+
+	if rsp.StatusCode >= http.StatusMultipleChoices {
+		return nil, parseErrorResponse(rsp)
+	}
+
+	if out != nil {
+		if err := json.NewDecoder(rsp.Body).Decode(out); err != nil {
+			return nil, errors.Wrap(err, "parsing server response")
+		}
+	}
+
+	return rsp.Header, nil
+}
+
+func (c *Client) send(ctx context.Context, method, uri string,
+	contentType string, body io.Reader) (*http.Response, error) {
+
+	req, err := http.NewRequest(method, uri, body)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	//propagate request id
+	if reqId := requestid.FromContext(ctx); reqId != "" {
+		req.Header.Set(requestid.RequestIdHeader, reqId)
+	}
+
+	return c.client.Do(req)
+}
+
+func isIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete:
+		return true
+	default:
+		return false
+	}
+}
+
+func isRetriable(rsp *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+
+	return rsp.StatusCode == http.StatusTooManyRequests ||
+		(rsp.StatusCode >= http.StatusInternalServerError &&
+			rsp.StatusCode != http.StatusNotImplemented)
+}
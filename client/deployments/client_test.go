@@ -0,0 +1,202 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package deployments
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mendersoftware/go-lib-micro/requestid"
+	"github.com/stretchr/testify/assert"
+
+	deps "github.com/mendersoftware/deployments/resources/deployments"
+)
+
+func TestNewClient(t *testing.T) {
+	t.Parallel()
+
+	client, err := NewClient("http://localhost/",
+		WithToken("token"), WithRetries(1, time.Second))
+	assert.NoError(t, err)
+	assert.Equal(t, "http://localhost", client.uri)
+	assert.Equal(t, "token", client.token)
+	assert.Equal(t, 1, client.retries)
+
+	_, err = NewClient("ht/localhost")
+	assert.EqualError(t, err, "invalid server uri")
+
+	_, err = NewClient("http://localhost", WithRetries(-1, 0))
+	assert.EqualError(t, err, "retries and backoff must not be negative")
+}
+
+func TestClientRetries(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		method  string
+		codes   []int
+		retries int
+
+		requests int
+		err      string
+	}{
+		"get retried until success": {
+			method:  http.MethodGet,
+			codes:   []int{http.StatusServiceUnavailable, http.StatusBadGateway, http.StatusOK},
+			retries: 3,
+
+			requests: 3,
+		},
+		"get retries exhausted": {
+			method:  http.MethodGet,
+			codes:   []int{http.StatusServiceUnavailable, http.StatusServiceUnavailable},
+			retries: 1,
+
+			requests: 2,
+			err:      "deployments: 503 Service Unavailable: unavailable",
+		},
+		"client error not retried": {
+			method:  http.MethodDelete,
+			codes:   []int{http.StatusNotFound},
+			retries: 3,
+
+			requests: 1,
+			err:      "deployments: 404 Not Found: unavailable",
+		},
+		"post not retried": {
+			method:  http.MethodPost,
+			codes:   []int{http.StatusServiceUnavailable, http.StatusCreated},
+			retries: 3,
+
+			requests: 1,
+			err:      "deployments: 503 Service Unavailable: unavailable",
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			requests := 0
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				code := tc.codes[requests]
+				requests++
+				w.WriteHeader(code)
+				if code >= http.StatusBadRequest {
+					json.NewEncoder(w).Encode(map[string]string{"error": "unavailable"})
+				}
+			}))
+			defer srv.Close()
+
+			client, err := NewClient(srv.URL, WithRetries(tc.retries, time.Millisecond))
+			assert.NoError(t, err)
+
+			_, err = client.do(context.Background(), tc.method, "/", nil, nil, nil)
+			if tc.err != "" {
+				assert.EqualError(t, err, tc.err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tc.requests, requests)
+		})
+	}
+}
+
+func TestClientRequest(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+		assert.Equal(t, "req-1", r.Header.Get(requestid.RequestIdHeader))
+		assert.Equal(t, URIManagement+"/deployments", r.URL.Path)
+
+		var constructor deps.DeploymentConstructor
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&constructor))
+		assert.Equal(t, "release", *constructor.Name)
+
+		w.Header().Set("Location",
+			"./deployments/"+"0f8a4d8e-a4c2-4a33-a1a1-1d4e6a0b3a1c")
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer srv.Close()
+
+	client, err := NewClient(srv.URL, WithToken("token"))
+	assert.NoError(t, err)
+
+	ctx := requestid.WithContext(context.Background(), "req-1")
+	name := "release"
+	id, err := client.CreateDeployment(ctx, &deps.DeploymentConstructor{Name: &name})
+	assert.NoError(t, err)
+	assert.Equal(t, "0f8a4d8e-a4c2-4a33-a1a1-1d4e6a0b3a1c", id)
+}
+
+func TestClientErrorResponse(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"error": "Resource not found", "request_id": "req-2"}`))
+	}))
+	defer srv.Close()
+
+	client, err := NewClient(srv.URL)
+	assert.NoError(t, err)
+
+	deployment, err := client.GetDeployment(context.Background(), "id")
+	assert.Nil(t, deployment)
+	assert.True(t, IsNotFound(err))
+	if rspErr, ok := err.(*Error); assert.True(t, ok) {
+		assert.Equal(t, "Resource not found", rspErr.Message)
+		assert.Equal(t, "req-2", rspErr.RequestID)
+	}
+}
+
+func TestClientUploadArtifact(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, URIManagement+"/artifacts", r.URL.Path)
+
+		assert.NoError(t, r.ParseMultipartForm(1024))
+		assert.Equal(t, "4", r.FormValue("size"))
+		assert.Equal(t, "first release", r.FormValue("description"))
+
+		file, header, err := r.FormFile("artifact")
+		if assert.NoError(t, err) {
+			data, _ := ioutil.ReadAll(file)
+			assert.Equal(t, "data", string(data))
+			assert.Equal(t, "application/octet-stream", header.Header.Get("Content-Type"))
+		}
+
+		w.Header().Set("Location", "./artifacts/artifact-1")
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer srv.Close()
+
+	client, err := NewClient(srv.URL)
+	assert.NoError(t, err)
+
+	id, err := client.UploadArtifact(context.Background(), "first release", 4,
+		strings.NewReader("data"))
+	assert.NoError(t, err)
+	assert.Equal(t, "artifact-1", id)
+}
@@ -0,0 +1,56 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package deployments
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/pkg/errors"
+
+	deps "github.com/mendersoftware/deployments/resources/deployments"
+)
+
+// Error is an error response of the deployments service.
+type Error struct {
+	StatusCode int               `json:"-"`
+	Message    string            `json:"error"`
+	RequestID  string            `json:"request_id"`
+	Fields     []deps.FieldError `json:"fields,omitempty"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("deployments: %d %s: %s",
+		e.StatusCode, http.StatusText(e.StatusCode), e.Message)
+}
+
+// IsNotFound tells if err is the not found response of the service.
+func IsNotFound(err error) bool {
+	if rspErr, ok := errors.Cause(err).(*Error); ok {
+		return rspErr.StatusCode == http.StatusNotFound
+	}
+	return false
+}
+
+func parseErrorResponse(rsp *http.Response) error {
+	rspErr := &Error{}
+	if err := json.NewDecoder(rsp.Body).Decode(rspErr); err != nil {
+		rspErr.Message = errors.Wrap(err, "parsing server error response").Error()
+	}
+	rspErr.StatusCode = rsp.StatusCode
+
+	return rspErr
+}
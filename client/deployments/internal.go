@@ -0,0 +1,128 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package deployments
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+
+	deps "github.com/mendersoftware/deployments/resources/deployments"
+)
+
+// DeviceDeployment with the id of the deployment it belongs to.
+type DeviceDeployment struct {
+	deps.DeviceDeployment
+	DeploymentID string `json:"deployment_id"`
+}
+
+// JobsQuery filters background jobs listing.
+type JobsQuery struct {
+	Type   string
+	Status string
+	// Page size, DefaultPerPage if not set.
+	PerPage int
+}
+
+// ProvisionTenant sets up the service for a new tenant.
+func (c *Client) ProvisionTenant(ctx context.Context, tenantID string) error {
+	tenant := struct {
+		TenantID string `json:"tenant_id"`
+	}{
+		TenantID: tenantID,
+	}
+
+	_, err := c.do(ctx, http.MethodPost, URIInternal+"/tenants", nil, tenant, nil)
+	return err
+}
+
+// ListTenantDeployments returns all deployments of the tenant matching the query.
+func (c *Client) ListTenantDeployments(ctx context.Context, tenantID string,
+	query DeploymentsQuery) ([]*Deployment, error) {
+
+	var deployments []*Deployment
+	_, err := c.do(ctx, http.MethodGet,
+		URIInternal+"/tenants/"+url.PathEscape(tenantID)+"/deployments",
+		query.values(), nil, &deployments)
+	if err != nil {
+		return nil, err
+	}
+
+	return deployments, nil
+}
+
+// GetLatestDeviceDeployment returns the most recent deployment of the device,
+// optionally limited to the given deployments.
+func (c *Client) GetLatestDeviceDeployment(ctx context.Context, tenantID, deviceID string,
+	deploymentIDs ...string) (*DeviceDeployment, error) {
+
+	query := url.Values{}
+	if tenantID != "" {
+		query.Set("tenant_id", tenantID)
+	}
+	for _, id := range deploymentIDs {
+		query.Add("deployment_id", id)
+	}
+
+	var deviceDeployment DeviceDeployment
+	_, err := c.do(ctx, http.MethodGet,
+		URIInternal+"/devices/"+url.PathEscape(deviceID)+"/deployments/last",
+		query, nil, &deviceDeployment)
+	if err != nil {
+		return nil, err
+	}
+
+	return &deviceDeployment, nil
+}
+
+// GetActiveDeviceDeployments returns active deployments of each of
+// the devices, by device ID.
+func (c *Client) GetActiveDeviceDeployments(ctx context.Context, tenantID string,
+	deviceIDs ...string) (map[string][]DeviceDeployment, error) {
+
+	query := url.Values{}
+	if tenantID != "" {
+		query.Set("tenant_id", tenantID)
+	}
+
+	lookup := deps.DevicesLookup{
+		DeviceIDs: deviceIDs,
+	}
+
+	var active map[string][]DeviceDeployment
+	_, err := c.do(ctx, http.MethodPost, URIInternal+"/devices/deployments/active",
+		query, lookup, &active)
+	if err != nil {
+		return nil, err
+	}
+
+	return active, nil
+}
+
+// ListJobs returns iterator over background jobs matching the query.
+func (c *Client) ListJobs(ctx context.Context, query JobsQuery) *JobsIterator {
+	values := url.Values{}
+	if query.Type != "" {
+		values.Set("type", query.Type)
+	}
+	if query.Status != "" {
+		values.Set("status", query.Status)
+	}
+
+	return &JobsIterator{
+		ctx:   ctx,
+		pager: newPager(c, URIInternal+"/jobs", values, query.PerPage),
+	}
+}
@@ -0,0 +1,165 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package deployments
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/mendersoftware/deployments/resources/jobs"
+)
+
+// DefaultPerPage is the page size used by iterators unless specified.
+const DefaultPerPage = 100
+
+// pager fetches consecutive pages of a paginated collection, as long as
+// the service announces the next page in the Link header.
+type pager struct {
+	client  *Client
+	path    string
+	query   url.Values
+	perPage int
+	page    int
+	done    bool
+	err     error
+}
+
+func newPager(client *Client, path string, query url.Values, perPage int) *pager {
+	if perPage <= 0 {
+		perPage = DefaultPerPage
+	}
+	if query == nil {
+		query = url.Values{}
+	}
+
+	return &pager{
+		client:  client,
+		path:    path,
+		query:   query,
+		perPage: perPage,
+		page:    1,
+	}
+}
+
+// next decodes the next page into out, returns false if there are
+// no more pages or fetching the page failed.
+func (p *pager) next(ctx context.Context, out interface{}) bool {
+	if p.done || p.err != nil {
+		return false
+	}
+
+	p.query.Set("page", strconv.Itoa(p.page))
+	p.query.Set("per_page", strconv.Itoa(p.perPage))
+
+	header, err := p.client.do(ctx, http.MethodGet, p.path, p.query, nil, out)
+	if err != nil {
+		p.err = err
+		return false
+	}
+
+	p.page++
+	p.done = !hasNextPage(header)
+
+	return true
+}
+
+func hasNextPage(header http.Header) bool {
+	for _, link := range header["Link"] {
+		for _, part := range strings.Split(link, ",") {
+			if strings.Contains(part, `rel="next"`) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// DeploymentsIterator walks over deployments page by page.
+//
+//	it := client.ListDeployments(ctx, query)
+//	for it.Next() {
+//		deployment := it.Deployment()
+//		...
+//	}
+//	if err := it.Err(); err != nil {
+//		...
+//	}
+type DeploymentsIterator struct {
+	ctx     context.Context
+	pager   *pager
+	items   []*Deployment
+	current *Deployment
+}
+
+// Next advances to the next deployment, fetching next page if needed.
+// Returns false when there are no more deployments or on error.
+func (it *DeploymentsIterator) Next() bool {
+	for len(it.items) == 0 {
+		it.items = nil
+		if !it.pager.next(it.ctx, &it.items) {
+			it.current = nil
+			return false
+		}
+	}
+
+	it.current, it.items = it.items[0], it.items[1:]
+	return true
+}
+
+// Deployment returns the current deployment.
+func (it *DeploymentsIterator) Deployment() *Deployment {
+	return it.current
+}
+
+// Err returns error which stopped the iteration, if any.
+func (it *DeploymentsIterator) Err() error {
+	return it.pager.err
+}
+
+// JobsIterator walks over background jobs page by page.
+type JobsIterator struct {
+	ctx     context.Context
+	pager   *pager
+	items   []*jobs.Job
+	current *jobs.Job
+}
+
+// Next advances to the next job, fetching next page if needed.
+// Returns false when there are no more jobs or on error.
+func (it *JobsIterator) Next() bool {
+	for len(it.items) == 0 {
+		it.items = nil
+		if !it.pager.next(it.ctx, &it.items) {
+			it.current = nil
+			return false
+		}
+	}
+
+	it.current, it.items = it.items[0], it.items[1:]
+	return true
+}
+
+// Job returns the current job.
+func (it *JobsIterator) Job() *jobs.Job {
+	return it.current
+}
+
+// Err returns error which stopped the iteration, if any.
+func (it *JobsIterator) Err() error {
+	return it.pager.err
+}
@@ -0,0 +1,99 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package deployments
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mendersoftware/deployments/resources/jobs"
+)
+
+func TestDeploymentsIterator(t *testing.T) {
+	t.Parallel()
+
+	pages := [][]string{
+		{"a", "b"},
+		{"c", "d"},
+		{"e"},
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, URIManagement+"/deployments", r.URL.Path)
+		assert.Equal(t, "finished", r.URL.Query().Get("status"))
+		assert.Equal(t, "2", r.URL.Query().Get("per_page"))
+
+		var page int
+		fmt.Sscanf(r.URL.Query().Get("page"), "%d", &page)
+		if page < len(pages) {
+			w.Header().Add("Link", fmt.Sprintf(`<http://localhost/?page=%d>; rel="next"`, page+1))
+		}
+		w.Header().Add("Link", `<http://localhost/?page=1>; rel="first"`)
+
+		list := []map[string]string{}
+		for _, id := range pages[page-1] {
+			list = append(list, map[string]string{"id": id, "status": "finished"})
+		}
+		json.NewEncoder(w).Encode(list)
+	}))
+	defer srv.Close()
+
+	client, err := NewClient(srv.URL)
+	assert.NoError(t, err)
+
+	ids := []string{}
+	it := client.ListDeployments(context.Background(),
+		DeploymentsQuery{Status: "finished", PerPage: 2})
+	for it.Next() {
+		assert.Equal(t, "finished", it.Deployment().Status)
+		ids = append(ids, *it.Deployment().Id)
+	}
+	assert.NoError(t, it.Err())
+	assert.Equal(t, []string{"a", "b", "c", "d", "e"}, ids)
+}
+
+func TestJobsIteratorError(t *testing.T) {
+	t.Parallel()
+
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests > 1 {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error": "invalid page"}`))
+			return
+		}
+		w.Header().Add("Link", `<http://localhost/?page=2>; rel="next"`)
+		json.NewEncoder(w).Encode([]*jobs.Job{{Id: "job-1"}})
+	}))
+	defer srv.Close()
+
+	client, err := NewClient(srv.URL)
+	assert.NoError(t, err)
+
+	it := client.ListJobs(context.Background(), JobsQuery{})
+	assert.True(t, it.Next())
+	assert.Equal(t, "job-1", it.Job().Id)
+	assert.False(t, it.Next())
+	assert.Nil(t, it.Job())
+	assert.EqualError(t, it.Err(), "deployments: 400 Bad Request: invalid page")
+	assert.Equal(t, 2, requests)
+}
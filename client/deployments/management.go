@@ -0,0 +1,265 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package deployments
+
+import (
+	"context"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"path"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+
+	deps "github.com/mendersoftware/deployments/resources/deployments"
+	"github.com/mendersoftware/deployments/resources/images"
+)
+
+// Deployment as served by the service, with the status computed
+// from the device deployments.
+type Deployment struct {
+	deps.Deployment
+	Status string `json:"status"`
+}
+
+// DeploymentsQuery filters deployments lookup.
+type DeploymentsQuery struct {
+	// Match deployment or artifact name.
+	Search string
+	// One of "pending", "inprogress", "finished" or "aborted".
+	Status        string
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
+	// Page size, DefaultPerPage if not set.
+	PerPage int
+}
+
+func (q DeploymentsQuery) values() url.Values {
+	values := url.Values{}
+	if q.Search != "" {
+		values.Set("search", q.Search)
+	}
+	if q.Status != "" {
+		values.Set("status", q.Status)
+	}
+	if q.CreatedAfter != nil {
+		values.Set("created_after", strconv.FormatInt(q.CreatedAfter.Unix(), 10))
+	}
+	if q.CreatedBefore != nil {
+		values.Set("created_before", strconv.FormatInt(q.CreatedBefore.Unix(), 10))
+	}
+	return values
+}
+
+// CreateDeployment creates deployment and returns its id.
+func (c *Client) CreateDeployment(ctx context.Context,
+	constructor *deps.DeploymentConstructor) (string, error) {
+
+	header, err := c.do(ctx, http.MethodPost, URIManagement+"/deployments",
+		nil, constructor, nil)
+	if err != nil {
+		return "", err
+	}
+
+	location := header.Get("Location")
+	if location == "" {
+		return "", errors.New("missing location of created deployment")
+	}
+
+	return path.Base(location), nil
+}
+
+// GetDeployment returns deployment by id.
+func (c *Client) GetDeployment(ctx context.Context, id string) (*Deployment, error) {
+	var deployment Deployment
+	_, err := c.do(ctx, http.MethodGet, URIManagement+"/deployments/"+url.PathEscape(id),
+		nil, nil, &deployment)
+	if err != nil {
+		return nil, err
+	}
+
+	return &deployment, nil
+}
+
+// ListDeployments returns iterator over deployments matching the query.
+func (c *Client) ListDeployments(ctx context.Context, query DeploymentsQuery) *DeploymentsIterator {
+	return &DeploymentsIterator{
+		ctx:   ctx,
+		pager: newPager(c, URIManagement+"/deployments", query.values(), query.PerPage),
+	}
+}
+
+// GetDeploymentStats returns number of devices of the deployment per status.
+func (c *Client) GetDeploymentStats(ctx context.Context, id string) (deps.Stats, error) {
+	stats := deps.Stats{}
+	_, err := c.do(ctx, http.MethodGet,
+		URIManagement+"/deployments/"+url.PathEscape(id)+"/statistics",
+		nil, nil, &stats)
+	if err != nil {
+		return nil, err
+	}
+
+	return stats, nil
+}
+
+// ListDeploymentDevices returns device deployments of the deployment.
+func (c *Client) ListDeploymentDevices(ctx context.Context,
+	id string) ([]*deps.DeviceDeployment, error) {
+
+	var devices []*deps.DeviceDeployment
+	_, err := c.do(ctx, http.MethodGet,
+		URIManagement+"/deployments/"+url.PathEscape(id)+"/devices",
+		nil, nil, &devices)
+	if err != nil {
+		return nil, err
+	}
+
+	return devices, nil
+}
+
+// AbortDeployment aborts the deployment on all devices which did not finish it yet.
+func (c *Client) AbortDeployment(ctx context.Context, id, reason string) error {
+	status := struct {
+		Status string `json:"status"`
+		Reason string `json:"reason,omitempty"`
+	}{
+		Status: deps.DeviceDeploymentStatusAborted,
+		Reason: reason,
+	}
+
+	_, err := c.do(ctx, http.MethodPut,
+		URIManagement+"/deployments/"+url.PathEscape(id)+"/status",
+		nil, status, nil)
+	return err
+}
+
+// ForceFinishDeployment finishes the deployment, setting the status, aborted
+// or timed_out, on all devices which did not finish it yet.
+func (c *Client) ForceFinishDeployment(ctx context.Context, id, status string) error {
+	req := struct {
+		Status string `json:"status"`
+	}{
+		Status: status,
+	}
+
+	_, err := c.do(ctx, http.MethodPost,
+		URIManagement+"/deployments/"+url.PathEscape(id)+"/finalize",
+		nil, req, nil)
+	return err
+}
+
+// DecommissionDevice marks all deployments of the device as decommissioned.
+func (c *Client) DecommissionDevice(ctx context.Context, deviceID string) error {
+	_, err := c.do(ctx, http.MethodDelete,
+		URIManagement+"/deployments/devices/"+url.PathEscape(deviceID),
+		nil, nil, nil)
+	return err
+}
+
+// ListArtifacts returns all artifacts.
+func (c *Client) ListArtifacts(ctx context.Context) ([]*images.SoftwareImage, error) {
+	var artifacts []*images.SoftwareImage
+	_, err := c.do(ctx, http.MethodGet, URIManagement+"/artifacts", nil, nil, &artifacts)
+	if err != nil {
+		return nil, err
+	}
+
+	return artifacts, nil
+}
+
+// UploadArtifact uploads artifact of the given size and returns its id.
+// The artifact is streamed, the request is not retried.
+func (c *Client) UploadArtifact(ctx context.Context, description string,
+	size int64, artifact io.Reader) (string, error) {
+
+	body, writer := io.Pipe()
+	form := multipart.NewWriter(writer)
+
+	go func() {
+		err := form.WriteField("size", strconv.FormatInt(size, 10))
+		if err == nil {
+			err = form.WriteField("description", description)
+		}
+		if err == nil {
+			var part io.Writer
+			part, err = form.CreatePart(textproto.MIMEHeader{
+				"Content-Disposition": {`form-data; name="artifact"; filename="artifact.mender"`},
+				"Content-Type":        {"application/octet-stream"},
+			})
+			if err == nil {
+				_, err = io.Copy(part, artifact)
+			}
+		}
+		if err == nil {
+			err = form.Close()
+		}
+		writer.CloseWithError(err)
+	}()
+
+	rsp, err := c.send(ctx, http.MethodPost, c.uri+URIManagement+"/artifacts",
+		form.FormDataContentType(), body)
+	body.Close()
+	if err != nil {
+		return "", errors.Wrap(err, "sending artifact upload request")
+	}
+
+	header, err := handleResponse(rsp, nil)
+	if err != nil {
+		return "", err
+	}
+
+	location := header.Get("Location")
+	if location == "" {
+		return "", errors.New("missing location of uploaded artifact")
+	}
+
+	return path.Base(location), nil
+}
+
+// GetArtifact returns artifact by id.
+func (c *Client) GetArtifact(ctx context.Context, id string) (*images.SoftwareImage, error) {
+	var artifact images.SoftwareImage
+	_, err := c.do(ctx, http.MethodGet, URIManagement+"/artifacts/"+url.PathEscape(id),
+		nil, nil, &artifact)
+	if err != nil {
+		return nil, err
+	}
+
+	return &artifact, nil
+}
+
+// DeleteArtifact removes artifact by id.
+func (c *Client) DeleteArtifact(ctx context.Context, id string) error {
+	_, err := c.do(ctx, http.MethodDelete, URIManagement+"/artifacts/"+url.PathEscape(id),
+		nil, nil, nil)
+	return err
+}
+
+// GetArtifactDownloadLink returns time limited link for downloading the artifact.
+func (c *Client) GetArtifactDownloadLink(ctx context.Context, id string) (*images.Link, error) {
+	var link images.Link
+	_, err := c.do(ctx, http.MethodGet,
+		URIManagement+"/artifacts/"+url.PathEscape(id)+"/download",
+		nil, nil, &link)
+	if err != nil {
+		return nil, err
+	}
+
+	return &link, nil
+}
@@ -0,0 +1,108 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/pkg/errors"
+	"github.com/urfave/cli"
+	"gopkg.in/yaml.v2"
+
+	deps "github.com/mendersoftware/deployments/resources/deployments"
+)
+
+// loadSpec reads the deployment spec, the deployment creation request of
+// the management API in YAML. Unknown fields are rejected, so that
+// misspelled ones are not silently ignored.
+func loadSpec(path string) (*deps.DeploymentConstructor, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var spec interface{}
+	if err := yaml.Unmarshal(data, &spec); err != nil {
+		return nil, errors.Wrap(err, "parsing spec")
+	}
+	raw, err := json.Marshal(jsonValue(spec))
+	if err != nil {
+		return nil, errors.Wrap(err, "parsing spec")
+	}
+
+	var constructor deps.DeploymentConstructor
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&constructor); err != nil {
+		return nil, errors.Wrap(err, "parsing spec")
+	}
+
+	if err := constructor.Validate(); err != nil {
+		return nil, errors.Wrap(err, "invalid spec")
+	}
+
+	return &constructor, nil
+}
+
+// jsonValue converts value decoded from YAML, with maps keyed by any
+// value, to value encodable as JSON.
+func jsonValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			m[fmt.Sprint(key)] = jsonValue(value)
+		}
+		return m
+	case []interface{}:
+		for i, value := range v {
+			v[i] = jsonValue(value)
+		}
+	}
+	return v
+}
+
+func cmdDeploy(args *cli.Context) error {
+	if args.NArg() != 1 {
+		return cli.NewExitError("expected single spec file", 1)
+	}
+
+	constructor, err := loadSpec(args.Args().First())
+	if err != nil {
+		return cli.NewExitError(err.Error(), 1)
+	}
+
+	client, err := newClient(args, args.GlobalDuration("timeout"))
+	if err != nil {
+		return cli.NewExitError(err.Error(), 1)
+	}
+
+	ctx := context.Background()
+	id, err := client.CreateDeployment(ctx, constructor)
+	if err != nil {
+		return cli.NewExitError(fmt.Sprintf("creating deployment: %v", err), 1)
+	}
+	fmt.Println(id)
+
+	if !args.Bool("watch") {
+		return nil
+	}
+	return watch(ctx, client, id, args.Duration("interval"), os.Stdout)
+}
@@ -0,0 +1,233 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mendersoftware/deployments/client/deployments"
+	deps "github.com/mendersoftware/deployments/resources/deployments"
+)
+
+const testDeploymentID = "5c5fb5ec-5a35-4a6c-9d6f-a0d4bf1d1f56"
+
+func TestLoadSpec(t *testing.T) {
+	dir, err := ioutil.TempDir("", "deployctl")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	testCases := map[string]struct {
+		spec string
+
+		name    string
+		devices []string
+		phases  int
+		err     string
+	}{
+		"ok": {
+			spec: `
+name: release 2
+artifact_name: release-2
+devices:
+  - device-1
+  - device-2
+phases:
+  - batch_size: 10
+  - start_ts: 2030-01-01T00:00:00Z
+`,
+			name:    "release 2",
+			devices: []string{"device-1", "device-2"},
+			phases:  2,
+		},
+		"unknown field": {
+			spec: `
+name: release 2
+artifact: release-2
+devices: [device-1]
+`,
+			err: `parsing spec: json: unknown field "artifact"`,
+		},
+		"invalid": {
+			spec: `
+name: release 2
+devices: [device-1]
+`,
+			err: "invalid spec: artifact_name:",
+		},
+		"not YAML": {
+			spec: "name: [",
+			err:  "parsing spec: yaml:",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(dir, "spec.yaml")
+			assert.NoError(t, ioutil.WriteFile(path, []byte(tc.spec), 0644))
+
+			constructor, err := loadSpec(path)
+			if tc.err != "" {
+				if assert.Error(t, err) {
+					assert.Contains(t, err.Error(), tc.err)
+				}
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.name, *constructor.Name)
+			assert.Equal(t, tc.devices, constructor.Devices)
+			assert.Len(t, constructor.Phases, tc.phases)
+		})
+	}
+}
+
+// fakeServer accepts artifact uploads after failing the configured number
+// of them, and serves a deployment finishing after a few polls.
+type fakeServer struct {
+	sync.Mutex
+
+	failUploads int
+	uploads     int
+	polls       int
+}
+
+func (s *fakeServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.Lock()
+	defer s.Unlock()
+
+	switch {
+	case r.URL.Path == deployments.URIManagement+"/artifacts":
+		ioutil.ReadAll(r.Body)
+		s.uploads++
+		if s.uploads <= s.failUploads {
+			w.WriteHeader(http.StatusBadGateway)
+			w.Write([]byte(`{"error":"bad gateway"}`))
+			return
+		}
+		w.Header().Set("Location", "./artifacts/artifact-1")
+		w.WriteHeader(http.StatusCreated)
+	case strings.HasSuffix(r.URL.Path, "/statistics"):
+		stats := deps.NewDeviceDeploymentStats()
+		switch s.polls {
+		case 1:
+			stats[deps.DeviceDeploymentStatusPending] = 2
+		case 2:
+			stats[deps.DeviceDeploymentStatusPending] = 1
+			stats[deps.DeviceDeploymentStatusSuccess] = 1
+		default:
+			stats[deps.DeviceDeploymentStatusFailure] = 1
+			stats[deps.DeviceDeploymentStatusSuccess] = 1
+		}
+		json.NewEncoder(w).Encode(stats)
+	case r.URL.Path == deployments.URIManagement+"/deployments/"+testDeploymentID:
+		s.polls++
+		deployment := map[string]interface{}{
+			"id":     testDeploymentID,
+			"status": deps.DeploymentStatusInProgress,
+		}
+		if s.polls >= 3 {
+			deployment["status"] = deps.DeploymentStatusFinished
+			deployment["finished"] = time.Now()
+		}
+		json.NewEncoder(w).Encode(deployment)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestUpload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "deployctl")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "release-2.mender")
+	assert.NoError(t, ioutil.WriteFile(file, []byte("0123456789"), 0644))
+
+	server := &fakeServer{failUploads: 1}
+	srv := httptest.NewServer(server)
+	defer srv.Close()
+
+	client, err := deployments.NewClient(srv.URL)
+	assert.NoError(t, err)
+	ctx := context.Background()
+
+	// failed upload is retried as a whole
+	id, err := uploadFile(ctx, client, file, "", 1)
+	assert.NoError(t, err)
+	assert.Equal(t, "artifact-1", id)
+	assert.Equal(t, 2, server.uploads)
+
+	server.failUploads = 10
+	_, err = uploadFile(ctx, client, file, "", 0)
+	assert.EqualError(t, err, "deployments: 502 Bad Gateway: bad gateway")
+
+	// uploaded files are skipped by later runs, unless changed
+	statePath := filepath.Join(dir, "state.json")
+	state, err := loadUploadState(statePath)
+	assert.NoError(t, err)
+	info, err := os.Stat(file)
+	assert.NoError(t, err)
+	_, ok := state.Uploaded(file, info)
+	assert.False(t, ok)
+	assert.NoError(t, state.Record(file, info, "artifact-1"))
+
+	state, err = loadUploadState(statePath)
+	assert.NoError(t, err)
+	id, ok = state.Uploaded(file, info)
+	assert.True(t, ok)
+	assert.Equal(t, "artifact-1", id)
+
+	assert.NoError(t, ioutil.WriteFile(file, []byte("0123456789abc"), 0644))
+	info, err = os.Stat(file)
+	assert.NoError(t, err)
+	_, ok = state.Uploaded(file, info)
+	assert.False(t, ok)
+}
+
+func TestWatchDeployment(t *testing.T) {
+	srv := httptest.NewServer(&fakeServer{})
+	defer srv.Close()
+
+	client, err := deployments.NewClient(srv.URL)
+	assert.NoError(t, err)
+
+	var out bytes.Buffer
+	stats, err := watchDeployment(context.Background(), client, testDeploymentID,
+		time.Millisecond, &out)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, stats[deps.DeviceDeploymentStatusFailure])
+	assert.Equal(t, "inprogress: pending=2\n"+
+		"inprogress: pending=1 success=1\n"+
+		"finished: failure=1 success=1\n", out.String())
+
+	err = watch(context.Background(), client, testDeploymentID, time.Millisecond, &out)
+	assert.EqualError(t, err, "deployment failed on 1 devices")
+
+	_, err = watchDeployment(context.Background(), client, "other",
+		time.Millisecond, &out)
+	assert.True(t, deployments.IsNotFound(err))
+}
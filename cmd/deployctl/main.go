@@ -0,0 +1,140 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+// Command line tool for CI systems and operators: uploads artifacts,
+// creates deployments from YAML specs and watches deployment progress
+// through the management API of the Deployments Service.
+package main
+
+import (
+	"crypto/tls"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/urfave/cli"
+
+	"github.com/mendersoftware/deployments/client/deployments"
+)
+
+func main() {
+	doMain(os.Args)
+}
+
+func doMain(args []string) {
+	app := cli.NewApp()
+	app.Usage = "Manage artifacts and deployments of the Deployments Service"
+	app.Description = "Authenticates with a user JWT or an API token of the " +
+		"artifacts:upload and deployments:create scopes."
+
+	app.Flags = []cli.Flag{
+		cli.StringFlag{
+			Name:  "server",
+			Usage: "Deployments Service or API gateway `URL`.",
+			Value: "http://localhost:8080",
+		},
+		cli.StringFlag{
+			Name:   "token",
+			Usage:  "JWT or API `TOKEN` sent as bearer token.",
+			EnvVar: "DEPLOYCTL_TOKEN",
+		},
+		cli.BoolFlag{
+			Name:  "insecure",
+			Usage: "Skip TLS certificate verification.",
+		},
+		cli.DurationFlag{
+			Name:  "timeout",
+			Usage: "Timeout of single requests, artifact uploads excluded.",
+			Value: time.Minute,
+		},
+	}
+
+	app.Commands = []cli.Command{
+		{
+			Name:      "upload",
+			Usage:     "Upload artifacts",
+			ArgsUsage: "FILE...",
+			Description: "Files are uploaded one by one, each as a whole. With --state, " +
+				"uploaded files are recorded and skipped when the command is run " +
+				"again, resuming an interrupted upload of many files.",
+			Action: cmdUpload,
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "description",
+					Usage: "Description of the artifacts.",
+				},
+				cli.StringFlag{
+					Name:  "state",
+					Usage: "`FILE` recording uploaded files, to resume from.",
+				},
+				cli.IntFlag{
+					Name:  "retries",
+					Usage: "Number of retries of a failed upload.",
+					Value: deployments.DefaultRetries,
+				},
+			},
+		},
+		{
+			Name:      "deploy",
+			Usage:     "Create deployment from YAML spec",
+			ArgsUsage: "SPEC",
+			Description: "The spec has the fields of the deployment creation request " +
+				"of the management API, e.g. name, artifact_name and devices. " +
+				"The ID of the created deployment is printed.",
+			Action: cmdDeploy,
+			Flags: []cli.Flag{
+				cli.BoolFlag{
+					Name:  "watch",
+					Usage: "Watch the created deployment until it finishes.",
+				},
+				watchIntervalFlag,
+			},
+		},
+		{
+			Name:      "watch",
+			Usage:     "Watch deployment progress until it finishes",
+			ArgsUsage: "DEPLOYMENT_ID",
+			Description: "Prints device statistics whenever they change. Exits with " +
+				"status 1 if the deployment failed on any device.",
+			Action: cmdWatch,
+			Flags: []cli.Flag{
+				watchIntervalFlag,
+			},
+		},
+	}
+
+	app.Run(args)
+}
+
+var watchIntervalFlag = cli.DurationFlag{
+	Name:  "interval",
+	Usage: "Interval of polling the deployment statistics.",
+	Value: 10 * time.Second,
+}
+
+// newClient creates client of the server from the global flags, requests
+// are not limited in time if timeout is 0.
+func newClient(args *cli.Context, timeout time.Duration) (*deployments.Client, error) {
+	return deployments.NewClient(args.GlobalString("server"),
+		deployments.WithToken(args.GlobalString("token")),
+		deployments.WithHTTPClient(&http.Client{
+			Timeout: timeout,
+			Transport: &http.Transport{
+				Proxy: http.ProxyFromEnvironment,
+				TLSClientConfig: &tls.Config{
+					InsecureSkipVerify: args.GlobalBool("insecure"),
+				},
+			},
+		}))
+}
@@ -0,0 +1,197 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/urfave/cli"
+
+	"github.com/mendersoftware/deployments/client/deployments"
+)
+
+// uploadState records files uploaded by earlier runs, so that they are not
+// uploaded again. Files changed since are uploaded again.
+type uploadState struct {
+	path  string
+	Files map[string]uploadedFile `json:"files"`
+}
+
+type uploadedFile struct {
+	Size       int64     `json:"size"`
+	Modified   time.Time `json:"modified"`
+	ArtifactID string    `json:"artifact_id"`
+}
+
+// loadUploadState reads the state file, empty state if it does not exist.
+func loadUploadState(path string) (*uploadState, error) {
+	state := &uploadState{
+		path:  path,
+		Files: map[string]uploadedFile{},
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, errors.Wrapf(err, "parsing state file %s", path)
+	}
+	if state.Files == nil {
+		state.Files = map[string]uploadedFile{}
+	}
+	return state, nil
+}
+
+// Uploaded returns ID of the artifact the file was uploaded as, false if
+// not uploaded yet or changed since.
+func (s *uploadState) Uploaded(file string, info os.FileInfo) (string, bool) {
+	uploaded, ok := s.Files[stateKey(file)]
+	if !ok || uploaded.Size != info.Size() || !uploaded.Modified.Equal(info.ModTime()) {
+		return "", false
+	}
+	return uploaded.ArtifactID, true
+}
+
+// Record saves the file uploaded as the artifact; the state file is
+// replaced at once, so that it is not left half written.
+func (s *uploadState) Record(file string, info os.FileInfo, artifactID string) error {
+	s.Files[stateKey(file)] = uploadedFile{
+		Size:       info.Size(),
+		Modified:   info.ModTime(),
+		ArtifactID: artifactID,
+	}
+
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
+
+func stateKey(file string) string {
+	if abs, err := filepath.Abs(file); err == nil {
+		return abs
+	}
+	return file
+}
+
+// uploadFile uploads the file, retrying the whole upload if it failed with
+// network or server side error.
+func uploadFile(ctx context.Context, client *deployments.Client, file, description string,
+	retries int) (string, error) {
+
+	for attempt := 0; ; attempt++ {
+		f, err := os.Open(file)
+		if err != nil {
+			return "", err
+		}
+		info, err := f.Stat()
+		if err != nil {
+			f.Close()
+			return "", err
+		}
+
+		id, err := client.UploadArtifact(ctx, description, info.Size(), f)
+		f.Close()
+		if err == nil {
+			return id, nil
+		}
+		if attempt >= retries || !isRetriableUpload(err) {
+			return "", err
+		}
+
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case <-time.After(deployments.DefaultBackoff << uint(attempt)):
+		}
+	}
+}
+
+// isRetriableUpload tells if the upload failed for reasons other than the
+// artifact or the request, e.g. a duplicate artifact.
+func isRetriableUpload(err error) bool {
+	rspErr, ok := errors.Cause(err).(*deployments.Error)
+	if !ok {
+		return true
+	}
+	return rspErr.StatusCode == http.StatusTooManyRequests ||
+		(rspErr.StatusCode >= http.StatusInternalServerError &&
+			rspErr.StatusCode != http.StatusNotImplemented)
+}
+
+func cmdUpload(args *cli.Context) error {
+	if args.NArg() == 0 {
+		return cli.NewExitError("no files to upload", 1)
+	}
+
+	client, err := newClient(args, 0)
+	if err != nil {
+		return cli.NewExitError(err.Error(), 1)
+	}
+
+	var state *uploadState
+	if path := args.String("state"); path != "" {
+		if state, err = loadUploadState(path); err != nil {
+			return cli.NewExitError(err.Error(), 1)
+		}
+	}
+
+	ctx := context.Background()
+	for _, file := range args.Args() {
+		info, err := os.Stat(file)
+		if err != nil {
+			return cli.NewExitError(err.Error(), 1)
+		}
+		if state != nil {
+			if id, ok := state.Uploaded(file, info); ok {
+				fmt.Printf("%s: already uploaded as %s, skipped\n", file, id)
+				continue
+			}
+		}
+
+		id, err := uploadFile(ctx, client, file, args.String("description"),
+			args.Int("retries"))
+		if err != nil {
+			return cli.NewExitError(fmt.Sprintf("uploading %s: %v", file, err), 1)
+		}
+		fmt.Printf("%s: %s\n", file, id)
+
+		if state != nil {
+			if err := state.Record(file, info, id); err != nil {
+				return cli.NewExitError(
+					fmt.Sprintf("saving state file %s: %v", state.path, err), 1)
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,110 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/urfave/cli"
+
+	"github.com/mendersoftware/deployments/client/deployments"
+	deps "github.com/mendersoftware/deployments/resources/deployments"
+)
+
+// watchDeployment polls the deployment until it finishes, printing its
+// status and statistics whenever they change. Returns the final statistics.
+func watchDeployment(ctx context.Context, client *deployments.Client, id string,
+	interval time.Duration, out io.Writer) (deps.Stats, error) {
+
+	var last string
+	for {
+		deployment, err := client.GetDeployment(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		stats, err := client.GetDeploymentStats(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+
+		line := deployment.Status + ": " + formatStats(stats)
+		if line != last {
+			fmt.Fprintln(out, line)
+			last = line
+		}
+		if deployment.Finished != nil {
+			return stats, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(interval):
+		}
+	}
+}
+
+// formatStats lists non-zero device counts by status name.
+func formatStats(stats deps.Stats) string {
+	var counts []string
+	for status, count := range stats {
+		if count > 0 {
+			counts = append(counts, fmt.Sprintf("%s=%d", status, count))
+		}
+	}
+	if len(counts) == 0 {
+		return "no devices"
+	}
+	sort.Strings(counts)
+	return strings.Join(counts, " ")
+}
+
+// watch watches the deployment, failing if it failed on any device.
+func watch(ctx context.Context, client *deployments.Client, id string,
+	interval time.Duration, out io.Writer) error {
+
+	if interval <= 0 {
+		return cli.NewExitError("interval must be positive", 1)
+	}
+
+	stats, err := watchDeployment(ctx, client, id, interval, out)
+	if err != nil {
+		return cli.NewExitError(fmt.Sprintf("watching deployment: %v", err), 1)
+	}
+	if failed := stats[deps.DeviceDeploymentStatusFailure]; failed > 0 {
+		return cli.NewExitError(fmt.Sprintf("deployment failed on %d devices", failed), 1)
+	}
+	return nil
+}
+
+func cmdWatch(args *cli.Context) error {
+	if args.NArg() != 1 {
+		return cli.NewExitError("expected single deployment ID", 1)
+	}
+
+	client, err := newClient(args, args.GlobalDuration("timeout"))
+	if err != nil {
+		return cli.NewExitError(err.Error(), 1)
+	}
+
+	return watch(context.Background(), client, args.Args().First(),
+		args.Duration("interval"), os.Stdout)
+}
@@ -0,0 +1,349 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/deployments/resources/deployments"
+)
+
+// Device API routes
+const (
+	URINextDeployment   = "/api/devices/v1/deployments/device/deployments/next"
+	URIDeploymentStatus = "/api/devices/v1/deployments/device/deployments/%s/status"
+	URIDeploymentLog    = "/api/devices/v1/deployments/device/deployments/%s/log"
+)
+
+var errDeploymentAborted = errors.New("deployment aborted")
+
+// Config of the simulation shared by all devices.
+type Config struct {
+	// Server URL, e.g. https://localhost:443
+	Server string
+	Tenant string
+
+	DeviceType   string
+	ArtifactName string
+
+	PollInterval time.Duration
+	// Delay between reported status transitions.
+	StepDelay time.Duration
+	// Fraction of updates reported as failed, 0 to 1.
+	FailureRate float64
+	// Do not download artifacts, only report the transitions.
+	SkipDownload bool
+}
+
+// Stats counts simulation events across all devices.
+type Stats struct {
+	Polls      int64
+	Updates    int64
+	Successes  int64
+	Failures   int64
+	Aborted    int64
+	Throttled  int64
+	Errors     int64
+	Downloaded int64
+}
+
+func (s *Stats) String() string {
+	return fmt.Sprintf("polls: %d, updates: %d, success: %d, failure: %d, "+
+		"aborted: %d, throttled: %d, errors: %d, downloaded bytes: %d",
+		atomic.LoadInt64(&s.Polls), atomic.LoadInt64(&s.Updates),
+		atomic.LoadInt64(&s.Successes), atomic.LoadInt64(&s.Failures),
+		atomic.LoadInt64(&s.Aborted), atomic.LoadInt64(&s.Throttled),
+		atomic.LoadInt64(&s.Errors), atomic.LoadInt64(&s.Downloaded))
+}
+
+// Device is a single simulated device polling for deployments and
+// going through the update states.
+type Device struct {
+	ID           string
+	ArtifactName string
+
+	config *Config
+	client *http.Client
+	stats  *Stats
+	token  string
+	rand   *rand.Rand
+}
+
+func NewDevice(id string, config *Config, client *http.Client, stats *Stats) *Device {
+	return &Device{
+		ID:           id,
+		ArtifactName: config.ArtifactName,
+		config:       config,
+		client:       client,
+		stats:        stats,
+		token:        deviceToken(id, config.Tenant),
+		rand:         rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// deviceToken creates unsigned device token carrying the identity,
+// accepted by the service unless token verification is configured.
+func deviceToken(id, tenant string) string {
+	claims := map[string]interface{}{
+		"sub":           id,
+		"mender.device": true,
+	}
+	if tenant != "" {
+		claims["mender.tenant"] = tenant
+	}
+
+	header, _ := json.Marshal(map[string]string{"alg": "none", "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+
+	return base64.RawStdEncoding.EncodeToString(header) + "." +
+		base64.RawStdEncoding.EncodeToString(payload) + ".sim"
+}
+
+// Run polls for deployments until ctx is done. First poll is delayed
+// by random part of the poll interval to spread devices over time.
+func (d *Device) Run(ctx context.Context) {
+	wait := time.Duration(d.rand.Int63n(int64(d.config.PollInterval) + 1))
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+
+		wait = d.config.PollInterval
+		retryAfter, err := d.Poll(ctx)
+		if err != nil && ctx.Err() == nil {
+			atomic.AddInt64(&d.stats.Errors, 1)
+		}
+		if retryAfter > 0 {
+			wait = retryAfter
+		}
+	}
+}
+
+// Poll checks for pending deployment once and performs the update if
+// there is one. Returns delay requested by the server if throttled.
+func (d *Device) Poll(ctx context.Context) (time.Duration, error) {
+	atomic.AddInt64(&d.stats.Polls, 1)
+
+	query := url.Values{}
+	query.Set("artifact_name", d.ArtifactName)
+	query.Set("device_type", d.config.DeviceType)
+
+	rsp, err := d.request(ctx, http.MethodGet, URINextDeployment+"?"+query.Encode(), nil)
+	if err != nil {
+		return 0, err
+	}
+	defer rsp.Body.Close()
+
+	switch rsp.StatusCode {
+	case http.StatusNoContent:
+		return 0, nil
+	case http.StatusTooManyRequests:
+		atomic.AddInt64(&d.stats.Throttled, 1)
+		return retryAfter(rsp), nil
+	case http.StatusOK:
+	default:
+		return 0, errors.Errorf("unexpected next deployment response: %s", rsp.Status)
+	}
+
+	var instructions deployments.DeploymentInstructions
+	if err := json.NewDecoder(rsp.Body).Decode(&instructions); err != nil {
+		return 0, errors.Wrap(err, "parsing deployment instructions")
+	}
+
+	return 0, d.Update(ctx, &instructions)
+}
+
+// Update goes through the update states reporting each of them,
+// fails randomly according to the configured failure rate.
+func (d *Device) Update(ctx context.Context, instructions *deployments.DeploymentInstructions) error {
+	atomic.AddInt64(&d.stats.Updates, 1)
+
+	err := d.report(ctx, instructions.ID, deployments.DeviceDeploymentStatusDownloading)
+	if err == nil && !d.config.SkipDownload {
+		if err = d.download(ctx, instructions.Artifact.Source.Uri); err != nil {
+			return d.fail(ctx, instructions.ID, err.Error())
+		}
+	}
+
+	for _, status := range []string{
+		deployments.DeviceDeploymentStatusInstalling,
+		deployments.DeviceDeploymentStatusRebooting,
+	} {
+		if err != nil {
+			break
+		}
+		if err = d.step(ctx); err == nil {
+			err = d.report(ctx, instructions.ID, status)
+		}
+	}
+
+	if err == errDeploymentAborted {
+		atomic.AddInt64(&d.stats.Aborted, 1)
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	if d.rand.Float64() < d.config.FailureRate {
+		return d.fail(ctx, instructions.ID, "simulated installation failure")
+	}
+
+	if err := d.report(ctx, instructions.ID, deployments.DeviceDeploymentStatusSuccess); err != nil {
+		return err
+	}
+	atomic.AddInt64(&d.stats.Successes, 1)
+	d.ArtifactName = instructions.Artifact.ArtifactName
+
+	return nil
+}
+
+func (d *Device) step(ctx context.Context) error {
+	if d.config.StepDelay <= 0 {
+		return nil
+	}
+
+	// up to 50% jitter to avoid devices reporting in lockstep
+	jitter := time.Duration(d.rand.Int63n(int64(d.config.StepDelay)/2 + 1))
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(d.config.StepDelay + jitter):
+		return nil
+	}
+}
+
+// download reads the artifact discarding the content.
+func (d *Device) download(ctx context.Context, uri string) error {
+	req, err := http.NewRequest(http.MethodGet, uri, nil)
+	if err != nil {
+		return errors.Wrap(err, "creating artifact download request")
+	}
+
+	rsp, err := d.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return errors.Wrap(err, "downloading artifact")
+	}
+	defer rsp.Body.Close()
+
+	if rsp.StatusCode != http.StatusOK {
+		return errors.Errorf("downloading artifact: %s", rsp.Status)
+	}
+
+	n, err := io.Copy(ioutil.Discard, rsp.Body)
+	atomic.AddInt64(&d.stats.Downloaded, n)
+
+	return errors.Wrap(err, "downloading artifact")
+}
+
+// fail reports failure together with the deployment log.
+func (d *Device) fail(ctx context.Context, id, message string) error {
+	now := time.Now()
+	log := deployments.DeploymentLog{
+		Messages: []deployments.LogMessage{{
+			Timestamp: &now,
+			Level:     "error",
+			Message:   message,
+		}},
+	}
+
+	rsp, err := d.request(ctx, http.MethodPut, fmt.Sprintf(URIDeploymentLog, id), log)
+	if err != nil {
+		return err
+	}
+	rsp.Body.Close()
+
+	err = d.report(ctx, id, deployments.DeviceDeploymentStatusFailure)
+	if err == errDeploymentAborted {
+		atomic.AddInt64(&d.stats.Aborted, 1)
+		return nil
+	} else if err != nil {
+		return err
+	}
+	atomic.AddInt64(&d.stats.Failures, 1)
+
+	return nil
+}
+
+func (d *Device) report(ctx context.Context, id, status string) error {
+	report := struct {
+		Status string `json:"status"`
+	}{
+		Status: status,
+	}
+
+	rsp, err := d.request(ctx, http.MethodPut, fmt.Sprintf(URIDeploymentStatus, id), report)
+	if err != nil {
+		return err
+	}
+	defer rsp.Body.Close()
+
+	switch rsp.StatusCode {
+	case http.StatusNoContent, http.StatusOK:
+		return nil
+	case http.StatusConflict:
+		return errDeploymentAborted
+	default:
+		return errors.Errorf("unexpected status report response: %s", rsp.Status)
+	}
+}
+
+func (d *Device) request(ctx context.Context, method, path string,
+	body interface{}) (*http.Response, error) {
+
+	var payload io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		payload = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequest(method, d.config.Server+path, payload)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+d.token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	return d.client.Do(req.WithContext(ctx))
+}
+
+func retryAfter(rsp *http.Response) time.Duration {
+	seconds, err := strconv.Atoi(rsp.Header.Get("Retry-After"))
+	if err != nil || seconds < 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
@@ -0,0 +1,187 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mendersoftware/go-lib-micro/identity"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mendersoftware/deployments/resources/deployments"
+	"github.com/mendersoftware/deployments/resources/images"
+)
+
+const testDeploymentID = "5c5fb5ec-5a35-4a6c-9d6f-a0d4bf1d1f56"
+
+// fakeServer serves single deployment and records reported statuses.
+type fakeServer struct {
+	sync.Mutex
+
+	statusCode int
+	conflict   string
+	statuses   []string
+	logs       int
+	served     bool
+}
+
+func (s *fakeServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.Lock()
+	defer s.Unlock()
+
+	switch {
+	case r.URL.Path == URINextDeployment:
+		if s.statusCode != 0 {
+			w.Header().Set("Retry-After", "7")
+			w.WriteHeader(s.statusCode)
+			return
+		}
+		if s.served {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		s.served = true
+		json.NewEncoder(w).Encode(deployments.DeploymentInstructions{
+			ID: testDeploymentID,
+			Artifact: deployments.ArtifactDeploymentInstructions{
+				ArtifactName: "release-2",
+				Source:       images.Link{Uri: "http://" + r.Host + "/artifact"},
+			},
+		})
+	case r.URL.Path == "/artifact":
+		w.Write([]byte("0123456789"))
+	case strings.HasSuffix(r.URL.Path, "/status"):
+		var report struct {
+			Status string
+		}
+		json.NewDecoder(r.Body).Decode(&report)
+		s.statuses = append(s.statuses, report.Status)
+		if report.Status == s.conflict {
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	case strings.HasSuffix(r.URL.Path, "/log"):
+		s.logs++
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestDevicePoll(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		server       *fakeServer
+		failureRate  float64
+		skipDownload bool
+
+		statuses     []string
+		logs         int
+		artifactName string
+		retryAfter   time.Duration
+		stats        Stats
+	}{
+		"success": {
+			server: &fakeServer{},
+
+			statuses:     []string{"downloading", "installing", "rebooting", "success"},
+			artifactName: "release-2",
+			stats:        Stats{Polls: 1, Updates: 1, Successes: 1, Downloaded: 10},
+		},
+		"success, skip download": {
+			server:       &fakeServer{},
+			skipDownload: true,
+
+			statuses:     []string{"downloading", "installing", "rebooting", "success"},
+			artifactName: "release-2",
+			stats:        Stats{Polls: 1, Updates: 1, Successes: 1},
+		},
+		"failure": {
+			server:      &fakeServer{},
+			failureRate: 1,
+
+			statuses:     []string{"downloading", "installing", "rebooting", "failure"},
+			logs:         1,
+			artifactName: "release-1",
+			stats:        Stats{Polls: 1, Updates: 1, Failures: 1, Downloaded: 10},
+		},
+		"aborted": {
+			server: &fakeServer{conflict: "installing"},
+
+			statuses:     []string{"downloading", "installing"},
+			artifactName: "release-1",
+			stats:        Stats{Polls: 1, Updates: 1, Aborted: 1, Downloaded: 10},
+		},
+		"throttled": {
+			server: &fakeServer{statusCode: http.StatusTooManyRequests},
+
+			artifactName: "release-1",
+			retryAfter:   7 * time.Second,
+			stats:        Stats{Polls: 1, Throttled: 1},
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			srv := httptest.NewServer(tc.server)
+			defer srv.Close()
+
+			stats := &Stats{}
+			device := NewDevice("device-1", &Config{
+				Server:       srv.URL,
+				DeviceType:   "qemu",
+				ArtifactName: "release-1",
+				PollInterval: time.Second,
+				FailureRate:  tc.failureRate,
+				SkipDownload: tc.skipDownload,
+			}, srv.Client(), stats)
+
+			retryAfter, err := device.Poll(context.Background())
+			assert.NoError(t, err)
+			assert.Equal(t, tc.retryAfter, retryAfter)
+			assert.Equal(t, tc.statuses, tc.server.statuses)
+			assert.Equal(t, tc.logs, tc.server.logs)
+			assert.Equal(t, tc.artifactName, device.ArtifactName)
+			assert.Equal(t, tc.stats, *stats)
+		})
+	}
+}
+
+func TestDeviceToken(t *testing.T) {
+	t.Parallel()
+
+	for _, tenant := range []string{"", "tenant-1"} {
+		id, err := identity.ExtractIdentity(deviceToken("device-1", tenant))
+		assert.NoError(t, err, fmt.Sprintf("tenant: %q", tenant))
+		assert.Equal(t, identity.Identity{
+			Subject:  "device-1",
+			Tenant:   tenant,
+			IsDevice: true,
+		}, id)
+	}
+}
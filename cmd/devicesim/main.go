@@ -0,0 +1,201 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+// Device simulator generating load on the deployments service: simulated
+// devices poll for deployments, download artifacts and report status
+// transitions through the devices API.
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/asaskevich/govalidator"
+	"github.com/mendersoftware/go-lib-micro/log"
+	"github.com/urfave/cli"
+)
+
+func main() {
+	doMain(os.Args)
+}
+
+func doMain(args []string) {
+	app := cli.NewApp()
+	app.Usage = "Simulate devices updating through the Deployments Service"
+	app.Description = "Devices identify with unsigned tokens, the service must not " +
+		"verify device tokens (no jwt settings for device routes)."
+
+	app.Flags = []cli.Flag{
+		cli.StringFlag{
+			Name:  "server",
+			Usage: "Deployments Service or API gateway `URL`.",
+			Value: "http://localhost:8080",
+		},
+		cli.IntFlag{
+			Name:  "devices",
+			Usage: "Number of simulated devices.",
+			Value: 10,
+		},
+		cli.StringFlag{
+			Name:  "id-prefix",
+			Usage: "Prefix of simulated device IDs.",
+			Value: "devicesim-",
+		},
+		cli.StringFlag{
+			Name:  "tenant",
+			Usage: "Tenant ID of the devices (optional).",
+		},
+		cli.StringFlag{
+			Name:  "device-type",
+			Usage: "Device type reported by the devices.",
+			Value: "devicesim",
+		},
+		cli.StringFlag{
+			Name:  "artifact-name",
+			Usage: "Name of the artifact initially installed on the devices.",
+			Value: "devicesim-initial",
+		},
+		cli.DurationFlag{
+			Name:  "poll-interval",
+			Usage: "Interval of polling for deployments.",
+			Value: 30 * time.Second,
+		},
+		cli.DurationFlag{
+			Name:  "step-delay",
+			Usage: "Delay between reported status transitions.",
+			Value: 5 * time.Second,
+		},
+		cli.Float64Flag{
+			Name:  "failure-rate",
+			Usage: "Fraction of updates reported as failed, 0 to 1.",
+		},
+		cli.BoolFlag{
+			Name:  "skip-download",
+			Usage: "Do not download artifacts, only report status transitions.",
+		},
+		cli.BoolFlag{
+			Name:  "insecure",
+			Usage: "Skip TLS certificate verification.",
+		},
+		cli.DurationFlag{
+			Name:  "duration",
+			Usage: "Stop after the given time, run until interrupted if not set.",
+		},
+	}
+
+	app.Action = cmdSimulate
+
+	app.Run(args)
+}
+
+func cmdSimulate(args *cli.Context) error {
+	config := &Config{
+		Server:       strings.TrimRight(args.String("server"), "/"),
+		Tenant:       args.String("tenant"),
+		DeviceType:   args.String("device-type"),
+		ArtifactName: args.String("artifact-name"),
+		PollInterval: args.Duration("poll-interval"),
+		StepDelay:    args.Duration("step-delay"),
+		FailureRate:  args.Float64("failure-rate"),
+		SkipDownload: args.Bool("skip-download"),
+	}
+
+	if !govalidator.IsURL(config.Server) {
+		return cli.NewExitError("invalid server url", 1)
+	}
+	if config.PollInterval <= 0 {
+		return cli.NewExitError("poll interval must be positive", 1)
+	}
+	if config.FailureRate < 0 || config.FailureRate > 1 {
+		return cli.NewExitError("failure rate must be between 0 and 1", 1)
+	}
+
+	count := args.Int("devices")
+	if count <= 0 {
+		return cli.NewExitError("number of devices must be positive", 1)
+	}
+
+	client := &http.Client{
+		Timeout: time.Minute,
+		Transport: &http.Transport{
+			Proxy:               http.ProxyFromEnvironment,
+			MaxIdleConnsPerHost: count,
+			TLSClientConfig: &tls.Config{
+				InsecureSkipVerify: args.Bool("insecure"),
+			},
+		},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if duration := args.Duration("duration"); duration > 0 {
+		ctx, cancel = context.WithTimeout(ctx, duration)
+		defer cancel()
+	}
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	go func() {
+		select {
+		case <-interrupt:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	l := log.New(log.Ctx{})
+	l.Infof("simulating %d devices against %s", count, config.Server)
+
+	stats := &Stats{}
+	started := time.Now()
+
+	var wg sync.WaitGroup
+	for i := 0; i < count; i++ {
+		device := NewDevice(fmt.Sprintf("%s%05d", args.String("id-prefix"), i),
+			config, client, stats)
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			device.Run(ctx)
+		}()
+	}
+
+	ticker := time.NewTicker(10 * time.Second)
+	defer ticker.Stop()
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	for {
+		select {
+		case <-ticker.C:
+			l.Infof("%s", stats)
+		case <-done:
+			fmt.Printf("simulation finished after %s\n%s\n",
+				time.Since(started).Round(time.Second), stats)
+			return nil
+		}
+	}
+}
@@ -16,8 +16,10 @@ package main
 import (
 	"fmt"
 	"os"
+	"strings"
 
 	"github.com/mendersoftware/deployments/config"
+	"github.com/mendersoftware/deployments/resources/images/mirror"
 )
 
 const (
@@ -37,11 +39,30 @@ const (
 	SettingsAwsTagArtifact        = SettingsAws + ".tag_artifact"
 	SettingsAwsTagArtifactDefault = false
 
+	SettingAwsForcePathStyle = SettingsAws + ".force_path_style"
+
 	SettingsAwsAuth      = SettingsAws + ".auth"
 	SettingAwsAuthKeyId  = SettingsAwsAuth + ".key"
 	SettingAwsAuthSecret = SettingsAwsAuth + ".secret"
 	SettingAwsAuthToken  = SettingsAwsAuth + ".token"
 
+	SettingsAwsRole                    = SettingsAws + ".role"
+	SettingAwsRoleARN                  = SettingsAwsRole + ".arn"
+	SettingAwsRoleSessionName          = SettingsAwsRole + ".session_name"
+	SettingAwsRoleWebIdentityTokenFile = SettingsAwsRole + ".web_identity_token_file"
+
+	// Environment variables of the role and its web identity token,
+	// set by EKS for IAM roles of service accounts.
+	EnvAwsRoleARN              = "AWS_ROLE_ARN"
+	EnvAwsWebIdentityTokenFile = "AWS_WEB_IDENTITY_TOKEN_FILE"
+
+	SettingsAwsArchive                  = SettingsAws + ".archive"
+	SettingAwsArchiveStorageClass       = SettingsAwsArchive + ".storage_class"
+	SettingAwsArchiveUnusedDays         = SettingsAwsArchive + ".unused_days"
+	SettingAwsArchiveUnusedDaysDefault  = 180
+	SettingAwsArchiveRestoreDays        = SettingsAwsArchive + ".restore_days"
+	SettingAwsArchiveRestoreDaysDefault = 7
+
 	SettingMongo        = "mongo-url"
 	SettingMongoDefault = "mongo-deployments"
 
@@ -51,14 +72,173 @@ const (
 	SettingDbSSLSkipVerify        = "mongo_ssl_skipverify"
 	SettingDbSSLSkipVerifyDefault = false
 
+	SettingDbSSLCAFile   = "mongo_ssl_ca_file"
+	SettingDbSSLCertFile = "mongo_ssl_cert_file"
+	SettingDbSSLKeyFile  = "mongo_ssl_key_file"
+
 	SettingDbUsername = "mongo_username"
 	SettingDbPassword = "mongo_password"
 
+	SettingDbAuthMechanism = "mongo_auth_mechanism"
+	SettingDbAuthSource    = "mongo_auth_source"
+	SettingDbReplicaSet    = "mongo_replica_set"
+
 	SettingGateway        = "mender-gateway"
 	SettingGatewayDefault = "localhost:9080"
 
 	SettingMiddleware        = "middleware"
 	SettingMiddlewareDefault = EnvProd
+
+	SettingMirrors           = "mirrors"
+	SettingMirrorsNetworks   = SettingMirrors + ".networks"
+	SettingMirrorsAttributes = SettingMirrors + ".attributes"
+
+	SettingPolicy               = "policy"
+	SettingPolicyURL            = SettingPolicy + ".url"
+	SettingPolicyTimeout        = SettingPolicy + ".timeout"
+	SettingPolicyTimeoutDefault = 5
+
+	SettingAuthz                 = "authz"
+	SettingAuthzOPAURL           = SettingAuthz + ".opa_url"
+	SettingAuthzCacheTTL         = SettingAuthz + ".cache_ttl"
+	SettingAuthzCacheTTLDefault  = 60
+	SettingAuthzCacheSize        = SettingAuthz + ".cache_size"
+	SettingAuthzCacheSizeDefault = 10000
+	SettingAuthzFailOpen         = SettingAuthz + ".fail_open"
+	SettingAuthzFailOpenDefault  = false
+
+	SettingJWT          = "jwt"
+	SettingJWTKeyFiles  = SettingJWT + ".key_files"
+	SettingJWTJWKSURL   = SettingJWT + ".jwks_url"
+	SettingJWTAudience  = SettingJWT + ".audience"
+	SettingJWTRoutes    = SettingJWT + ".routes"
+	JWTRoutesManagement = "management"
+	JWTRoutesDevices    = "devices"
+
+	SettingEvents               = "events"
+	SettingEventsWebhookURL     = SettingEvents + ".webhook_url"
+	SettingEventsTimeout        = SettingEvents + ".timeout"
+	SettingEventsTimeoutDefault = 5
+	SettingEventsBaseURL        = SettingEvents + ".base_url"
+
+	SettingDeviceNotifyURL = "device_notify_url"
+
+	SettingDownloadProxy       = "download_proxy"
+	SettingDownloadProxyURL    = SettingDownloadProxy + ".url"
+	SettingDownloadProxySecret = SettingDownloadProxy + ".secret"
+
+	SettingDeviceLogsSearch        = "device_logs_search"
+	SettingDeviceLogsSearchDefault = false
+
+	SettingArtifactLinkCacheTTL        = "artifact_link_cache_ttl"
+	SettingArtifactLinkCacheTTLDefault = 3600
+
+	SettingLazyDevicesThreshold        = "lazy_devices_threshold"
+	SettingLazyDevicesThresholdDefault = 10000
+
+	SettingScriptsAckTenants = "scripts_ack_tenants"
+
+	SettingDeviceExternalID = "device_external_id"
+
+	SettingDeviceInventorySnapshot = "device_inventory_snapshot"
+
+	SettingStatusNames = "status_names"
+
+	SettingStrictJSON        = "strict_json"
+	SettingStrictJSONDefault = false
+
+	SettingDualWriteMongo = "dual_write_mongo_url"
+
+	SettingMongoReplica                 = "mongo_replica"
+	SettingMongoReplicaURL              = SettingMongoReplica + ".url"
+	SettingMongoReplicaPoolLimit        = SettingMongoReplica + ".pool_limit"
+	SettingMongoReplicaPoolLimitDefault = 0
+
+	SettingStatusBatchWindow        = "status_batch_window"
+	SettingStatusBatchWindowDefault = 0
+
+	SettingDashboardStreamInterval        = "dashboard_stream_interval"
+	SettingDashboardStreamIntervalDefault = 5
+
+	SettingPartitionDeviceDeploymentsDays        = "partition_device_deployments_days"
+	SettingPartitionDeviceDeploymentsDaysDefault = 90
+
+	SettingSlowQueries                         = "slow_queries"
+	SettingSlowQueriesThreshold                = SettingSlowQueries + ".threshold"
+	SettingSlowQueriesThresholdDefault         = 0
+	SettingSlowQueriesExplainSampleRate        = SettingSlowQueries + ".explain_sample_rate"
+	SettingSlowQueriesExplainSampleRateDefault = 0.1
+
+	SettingArtifactTrashDays        = "artifact_trash_days"
+	SettingArtifactTrashDaysDefault = 7
+
+	SettingArtifactUnlockRole        = "artifact_unlock_role"
+	SettingArtifactUnlockRoleDefault = "RBAC_ROLE_PERMIT_ALL"
+
+	SettingFreezeOverrideRole        = "freeze_override_role"
+	SettingFreezeOverrideRoleDefault = "RBAC_ROLE_PERMIT_ALL"
+
+	SettingArtifactVersionPattern        = "artifact_version_pattern"
+	SettingArtifactVersionPatternDefault = ""
+
+	SettingTelemetrySampleRate        = "telemetry_sample_rate"
+	SettingTelemetrySampleRateDefault = 0.1
+
+	SettingMetricsTenants                  = "metrics_tenants"
+	SettingMetricsTenantsTop               = SettingMetricsTenants + ".top"
+	SettingMetricsTenantsTopDefault        = 10
+	SettingMetricsTenantsMaxTracked        = SettingMetricsTenants + ".max_tracked"
+	SettingMetricsTenantsMaxTrackedDefault = 1000
+
+	SettingUsageFlushInterval        = "usage_flush_interval"
+	SettingUsageFlushIntervalDefault = 60
+
+	SettingDeviceDeploymentMaxRetries        = "device_deployment_max_retries"
+	SettingDeviceDeploymentMaxRetriesDefault = 3
+
+	SettingMetricsDeploymentsMax        = "metrics_deployments_max"
+	SettingMetricsDeploymentsMaxDefault = 1000
+
+	SettingMetricsDownloads        = "metrics_downloads"
+	SettingMetricsDownloadsDefault = true
+
+	SettingAlertsInterval        = "alerts_interval"
+	SettingAlertsIntervalDefault = 60
+
+	SettingPhasesInterval        = "phases_interval"
+	SettingPhasesIntervalDefault = 60
+
+	SettingFinalize            = "finalize"
+	SettingFinalizeHooks       = SettingFinalize + ".hooks"
+	SettingFinalizeWorkflowURL = SettingFinalize + ".workflow_url"
+	FinalizeHookWebhook        = "webhook"
+	FinalizeHookWorkflow       = "workflow"
+	FinalizeHookLockArtifacts  = "lock_artifacts"
+	FinalizeHookStatsArchive   = "stats_archive"
+
+	SettingArtifactParser                 = "artifact_parser"
+	SettingArtifactParserWorkers          = SettingArtifactParser + ".workers"
+	SettingArtifactParserQueueSize        = SettingArtifactParser + ".queue_size"
+	SettingArtifactParserQueueSizeDefault = 16
+	SettingArtifactParserTimeout          = SettingArtifactParser + ".timeout"
+	SettingArtifactParserRate             = SettingArtifactParser + ".rate"
+
+	SettingJobs                   = "jobs"
+	SettingJobsWorkers            = SettingJobs + ".workers"
+	SettingJobsWorkersDefault     = 2
+	SettingJobsMaxAttempts        = SettingJobs + ".max_attempts"
+	SettingJobsMaxAttemptsDefault = 5
+	SettingJobsBackoff            = SettingJobs + ".backoff"
+	SettingJobsBackoffDefault     = 10
+
+	SettingDeviceEvents                = "device_events"
+	SettingDeviceEventsDatabase        = SettingDeviceEvents + ".database"
+	SettingDeviceEventsConsumer        = SettingDeviceEvents + ".consumer"
+	SettingDeviceEventsConsumerDefault = "deployments"
+
+	SettingTimeouts        = "timeouts"
+	SettingTimeoutsDefault = SettingTimeouts + ".default"
+	SettingTimeoutsRoutes  = SettingTimeouts + ".routes"
 )
 
 // ValidateAwsAuth validates configuration of SettingsAwsAuth section if provided.
@@ -80,6 +260,29 @@ func ValidateAwsAuth(c config.ConfigReader) error {
 	return nil
 }
 
+// ValidateAwsRole validates configuration of SettingsAwsRole section
+// if provided.
+func ValidateAwsRole(c config.ConfigReader) error {
+
+	tokenFile := c.GetString(SettingAwsRoleWebIdentityTokenFile)
+	if tokenFile == "" {
+		return nil
+	}
+
+	if c.IsSet(SettingsAwsAuth) {
+		return fmt.Errorf("%s: not allowed together with %s",
+			SettingAwsRoleWebIdentityTokenFile, SettingsAwsAuth)
+	}
+	if c.GetString(SettingAwsRoleARN) == "" {
+		return MissingOptionError(SettingAwsRoleARN)
+	}
+	if _, err := os.Stat(tokenFile); err != nil {
+		return err
+	}
+
+	return nil
+}
+
 // ValidateHttps validates configuration of SettingHttps section if provided.
 func ValidateHttps(c config.ConfigReader) error {
 
@@ -104,14 +307,72 @@ func ValidateHttps(c config.ConfigReader) error {
 	return nil
 }
 
+// ValidateMirrors validates configuration of SettingMirrors section if provided.
+func ValidateMirrors(c config.ConfigReader) error {
+
+	for _, rule := range c.GetStringSlice(SettingMirrorsNetworks) {
+		if _, err := mirror.ParseNetworkRule(rule); err != nil {
+			return err
+		}
+	}
+
+	for _, rule := range c.GetStringSlice(SettingMirrorsAttributes) {
+		if _, err := mirror.ParseAttributeRule(rule); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ValidateMongo validates connection strings, authentication mechanism
+// and TLS files of mongo connections.
+func ValidateMongo(c config.ConfigReader) error {
+
+	for _, key := range []string{SettingMongo, SettingMongoReplicaURL, SettingDualWriteMongo} {
+		if url := c.GetString(key); strings.HasPrefix(url, mongoSRVScheme) {
+			if _, err := parseMongoSRVURL(url); err != nil {
+				return fmt.Errorf("%s: %s", key, err)
+			}
+		}
+	}
+
+	mechanism := c.GetString(SettingDbAuthMechanism)
+	switch mechanism {
+	case "", MongoAuthSCRAMSHA1, MongoAuthMongoCR, MongoAuthX509:
+	case "SCRAM-SHA-256":
+		return fmt.Errorf("%s: %s is not supported by the mongo driver, use %s",
+			SettingDbAuthMechanism, mechanism, MongoAuthSCRAMSHA1)
+	default:
+		return fmt.Errorf("%s: unsupported mechanism '%s'",
+			SettingDbAuthMechanism, mechanism)
+	}
+
+	certFile := c.GetString(SettingDbSSLCertFile)
+	keyFile := c.GetString(SettingDbSSLKeyFile)
+	if certFile != "" && keyFile == "" {
+		return MissingOptionError(SettingDbSSLKeyFile)
+	}
+	if keyFile != "" && certFile == "" {
+		return MissingOptionError(SettingDbSSLCertFile)
+	}
+	if mechanism == MongoAuthX509 && certFile == "" {
+		return MissingOptionError(SettingDbSSLCertFile)
+	}
+
+	_, err := SetupMongoTLS(c)
+	return err
+}
+
 // Generate error with missing reuired option message.
 func MissingOptionError(option string) error {
 	return fmt.Errorf("Required option: '%s'", option)
 }
 
 var (
-	configValidators = []config.Validator{ValidateAwsAuth, ValidateHttps}
-	configDefaults   = []config.Default{
+	configValidators = []config.Validator{
+		ValidateAwsAuth, ValidateAwsRole, ValidateHttps, ValidateMirrors, ValidateMongo}
+	configDefaults = []config.Default{
 		{Key: SettingListen, Value: SettingListenDefault},
 		{Key: SettingAwsS3Region, Value: SettingAwsS3RegionDefault},
 		{Key: SettingAwsS3Bucket, Value: SettingAwsS3BucketDefault},
@@ -120,5 +381,41 @@ var (
 		{Key: SettingDbSSLSkipVerify, Value: SettingDbSSLSkipVerifyDefault},
 		{Key: SettingGateway, Value: SettingGatewayDefault},
 		{Key: SettingsAwsTagArtifact, Value: SettingsAwsTagArtifactDefault},
+		{Key: SettingPolicyTimeout, Value: SettingPolicyTimeoutDefault},
+		{Key: SettingAuthzCacheTTL, Value: SettingAuthzCacheTTLDefault},
+		{Key: SettingAuthzCacheSize, Value: SettingAuthzCacheSizeDefault},
+		{Key: SettingAuthzFailOpen, Value: SettingAuthzFailOpenDefault},
+		{Key: SettingJWTRoutes, Value: []string{JWTRoutesManagement, JWTRoutesDevices}},
+		{Key: SettingEventsTimeout, Value: SettingEventsTimeoutDefault},
+		{Key: SettingAwsArchiveUnusedDays, Value: SettingAwsArchiveUnusedDaysDefault},
+		{Key: SettingAwsArchiveRestoreDays, Value: SettingAwsArchiveRestoreDaysDefault},
+		{Key: SettingDeviceLogsSearch, Value: SettingDeviceLogsSearchDefault},
+		{Key: SettingArtifactLinkCacheTTL, Value: SettingArtifactLinkCacheTTLDefault},
+		{Key: SettingLazyDevicesThreshold, Value: SettingLazyDevicesThresholdDefault},
+		{Key: SettingArtifactParserQueueSize, Value: SettingArtifactParserQueueSizeDefault},
+		{Key: SettingJobsWorkers, Value: SettingJobsWorkersDefault},
+		{Key: SettingJobsMaxAttempts, Value: SettingJobsMaxAttemptsDefault},
+		{Key: SettingJobsBackoff, Value: SettingJobsBackoffDefault},
+		{Key: SettingDeviceEventsConsumer, Value: SettingDeviceEventsConsumerDefault},
+		{Key: SettingStrictJSON, Value: SettingStrictJSONDefault},
+		{Key: SettingMongoReplicaPoolLimit, Value: SettingMongoReplicaPoolLimitDefault},
+		{Key: SettingStatusBatchWindow, Value: SettingStatusBatchWindowDefault},
+		{Key: SettingDashboardStreamInterval, Value: SettingDashboardStreamIntervalDefault},
+		{Key: SettingPartitionDeviceDeploymentsDays, Value: SettingPartitionDeviceDeploymentsDaysDefault},
+		{Key: SettingSlowQueriesThreshold, Value: SettingSlowQueriesThresholdDefault},
+		{Key: SettingSlowQueriesExplainSampleRate, Value: SettingSlowQueriesExplainSampleRateDefault},
+		{Key: SettingArtifactTrashDays, Value: SettingArtifactTrashDaysDefault},
+		{Key: SettingArtifactUnlockRole, Value: SettingArtifactUnlockRoleDefault},
+		{Key: SettingFreezeOverrideRole, Value: SettingFreezeOverrideRoleDefault},
+		{Key: SettingArtifactVersionPattern, Value: SettingArtifactVersionPatternDefault},
+		{Key: SettingTelemetrySampleRate, Value: SettingTelemetrySampleRateDefault},
+		{Key: SettingMetricsTenantsTop, Value: SettingMetricsTenantsTopDefault},
+		{Key: SettingMetricsTenantsMaxTracked, Value: SettingMetricsTenantsMaxTrackedDefault},
+		{Key: SettingUsageFlushInterval, Value: SettingUsageFlushIntervalDefault},
+		{Key: SettingDeviceDeploymentMaxRetries, Value: SettingDeviceDeploymentMaxRetriesDefault},
+		{Key: SettingMetricsDeploymentsMax, Value: SettingMetricsDeploymentsMaxDefault},
+		{Key: SettingMetricsDownloads, Value: SettingMetricsDownloadsDefault},
+		{Key: SettingAlertsInterval, Value: SettingAlertsIntervalDefault},
+		{Key: SettingPhasesInterval, Value: SettingPhasesIntervalDefault},
 	}
 )
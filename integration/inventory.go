@@ -19,6 +19,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/url"
+	"strconv"
 	"time"
 
 	"github.com/asaskevich/govalidator"
@@ -28,9 +30,15 @@ import (
 
 // Routes
 const (
-	DevicesInventory string = "/api/0.1.0/devices/%s"
+	DevicesInventory      string = "/api/0.1.0/devices/%s"
+	DevicesInventoryGroup string = "/api/0.1.0/devices/%s/group"
+	DevicesInventoryList  string = "/api/0.1.0/devices"
 )
 
+// Maximum number of devices matching an attribute value that are fetched;
+// external identifiers are expected to match a single device.
+const FindDevicesByAttributeLimit = 10
+
 type Attribute struct {
 	Name        string      `json:"name" valid:"length(1|4096),required"`
 	Description string      `json:"description" valid:"optional"`
@@ -56,7 +64,11 @@ func (d DeviceID) String() string {
 
 type Inventory interface {
 	// Fetch Device object from inventory service.
-	GetDeviceInventory(id DeviceID) (*Device, error)
+	GetDeviceInventory(ctx context.Context, id DeviceID) (*Device, error)
+	// Fetch name of the group the device belongs to, empty if none.
+	GetDeviceGroup(ctx context.Context, id DeviceID) (string, error)
+	// Find IDs of devices with the attribute set to the value.
+	FindDevicesByAttribute(ctx context.Context, name, value string) ([]DeviceID, error)
 }
 
 // GetDeviceInventory returns device object from inventory
@@ -98,3 +110,87 @@ func (api *MenderAPI) GetDeviceInventory(ctx context.Context, id DeviceID) (*Dev
 
 	return &device, nil
 }
+
+func (api *MenderAPI) GetDeviceGroup(ctx context.Context, id DeviceID) (string, error) {
+	url := fmt.Sprintf(api.uri+DevicesInventoryGroup, id)
+
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return "", errors.Wrap(err, "creating request for device group")
+	}
+
+	//propagate request id
+	reqId := ctx.Value(requestid.RequestIdHeader)
+	if reqId != nil {
+		req.Header.Set(requestid.RequestIdHeader, reqId.(string))
+	}
+
+	resp, err := api.client.Do(req)
+
+	if err != nil {
+		return "", errors.Wrap(err, "sending request for device group")
+	}
+
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return "", nil
+	case resp.StatusCode != http.StatusOK:
+		return "", errors.Wrap(api.parseErrorResponse(resp.Body), "error server response")
+	}
+
+	group := struct {
+		Group string `json:"group"`
+	}{}
+	if err := json.NewDecoder(resp.Body).Decode(&group); err != nil {
+		return "", errors.Wrap(err, "parsig server response")
+	}
+
+	return group.Group, nil
+}
+
+// FindDevicesByAttribute returns IDs of devices which have the inventory
+// attribute set to the value, at most FindDevicesByAttributeLimit of them.
+func (api *MenderAPI) FindDevicesByAttribute(ctx context.Context,
+	name, value string) ([]DeviceID, error) {
+
+	req, err := http.NewRequest(http.MethodGet, api.uri+DevicesInventoryList, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "creating request for devices")
+	}
+
+	q := url.Values{}
+	q.Set(name, value)
+	q.Set("per_page", strconv.Itoa(FindDevicesByAttributeLimit))
+	req.URL.RawQuery = q.Encode()
+
+	//propagate request id
+	reqId := ctx.Value(requestid.RequestIdHeader)
+	if reqId != nil {
+		req.Header.Set(requestid.RequestIdHeader, reqId.(string))
+	}
+
+	resp, err := api.client.Do(req)
+
+	if err != nil {
+		return nil, errors.Wrap(err, "sending request for devices")
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Wrap(api.parseErrorResponse(resp.Body), "error server response")
+	}
+
+	var devices []Device
+	if err := json.NewDecoder(resp.Body).Decode(&devices); err != nil {
+		return nil, errors.Wrap(err, "parsig server response")
+	}
+
+	ids := make([]DeviceID, 0, len(devices))
+	for _, device := range devices {
+		ids = append(ids, device.ID)
+	}
+	return ids, nil
+}
@@ -120,3 +120,145 @@ func TestGetDeviceInventory(t *testing.T) {
 	}
 
 }
+
+func TestGetDeviceGroup(t *testing.T) {
+
+	t.Parallel()
+
+	testCases := map[string]struct {
+		// Input
+		Code int
+		Body interface{}
+
+		//Output
+		Group string
+		Err   error
+	}{
+		"internal server error with payload": {
+			Code: http.StatusInternalServerError,
+			Body: struct {
+				Error string `json:"error"`
+			}{Error: "dead db"},
+
+			Err: errors.New("error server response: dead db"),
+		},
+		"not found": {
+			Code: http.StatusNotFound,
+		},
+		"success": {
+			Code: http.StatusOK,
+			Body: struct {
+				Group string `json:"group"`
+			}{Group: "site-a"},
+
+			Group: "site-a",
+		},
+	}
+
+	for caseName, test := range testCases {
+
+		t.Logf("Case: %s\n", caseName)
+
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "/api/0.1.0/devices/whatever/group", r.URL.Path)
+
+			w.WriteHeader(test.Code)
+			if test.Body != nil {
+				payload, err := json.Marshal(test.Body)
+				assert.NoError(t, err, "invalid test")
+
+				_, err = w.Write(payload)
+				assert.NoError(t, err, "invalid test")
+			}
+		}))
+		defer ts.Close()
+
+		api, err := NewMenderAPI(ts.URL)
+		assert.NoError(t, err, "api client init")
+
+		group, err := api.GetDeviceGroup(context.TODO(), DeviceID("whatever"))
+
+		if test.Err != nil {
+			assert.EqualError(t, err, test.Err.Error())
+		} else {
+			assert.NoError(t, err)
+		}
+
+		assert.Equal(t, test.Group, group)
+	}
+
+}
+
+func TestFindDevicesByAttribute(t *testing.T) {
+
+	t.Parallel()
+
+	testCases := map[string]struct {
+		// Input
+		Code int
+		Body interface{}
+
+		//Output
+		IDs []DeviceID
+		Err error
+	}{
+		"internal server error with payload": {
+			Code: http.StatusInternalServerError,
+			Body: struct {
+				Error string `json:"error"`
+			}{Error: "dead db"},
+
+			Err: errors.New("error server response: dead db"),
+		},
+		"no devices": {
+			Code: http.StatusOK,
+			Body: []Device{},
+
+			IDs: []DeviceID{},
+		},
+		"success": {
+			Code: http.StatusOK,
+			Body: []Device{
+				{ID: "foo"},
+				{ID: "bar"},
+			},
+
+			IDs: []DeviceID{"foo", "bar"},
+		},
+	}
+
+	for caseName, test := range testCases {
+
+		t.Logf("Case: %s\n", caseName)
+
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "/api/0.1.0/devices", r.URL.Path)
+			assert.Equal(t, "SN 123", r.URL.Query().Get("serial_no"))
+			assert.Equal(t, "10", r.URL.Query().Get("per_page"))
+
+			w.WriteHeader(test.Code)
+			if test.Body != nil {
+				payload, err := json.Marshal(test.Body)
+				assert.NoError(t, err, "invalid test")
+
+				_, err = w.Write(payload)
+				assert.NoError(t, err, "invalid test")
+			}
+		}))
+		defer ts.Close()
+
+		api, err := NewMenderAPI(ts.URL)
+		assert.NoError(t, err, "api client init")
+
+		ids, err := api.FindDevicesByAttribute(context.TODO(), "serial_no", "SN 123")
+
+		if test.Err != nil {
+			assert.EqualError(t, err, test.Err.Error())
+		} else {
+			assert.NoError(t, err)
+		}
+
+		assert.Equal(t, test.IDs, ids)
+	}
+
+}
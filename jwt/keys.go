@@ -0,0 +1,307 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	DefaultJWKSRefresh = 10 * time.Minute
+	// Minimum interval between fetches, after failed ones and on unknown
+	// key ids
+	DefaultJWKSMinRefresh = time.Minute
+)
+
+// KeySet provides public keys for token signature verification.
+type KeySet interface {
+	// Key returns the key with the given id; if the id is empty and
+	// the set contains a single key, that key is returned.
+	Key(kid string) (crypto.PublicKey, error)
+}
+
+// StaticKeys is a fixed set of keys, any of them is tried for tokens
+// without a key id.
+type StaticKeys map[string]crypto.PublicKey
+
+func (s StaticKeys) Key(kid string) (crypto.PublicKey, error) {
+	if key, ok := s[kid]; ok {
+		return key, nil
+	}
+	if kid == "" && len(s) == 1 {
+		for _, key := range s {
+			return key, nil
+		}
+	}
+	return nil, ErrTokenKeyNotFound
+}
+
+// MultiKeySet looks the key up in each of the sets in order.
+type MultiKeySet []KeySet
+
+func (m MultiKeySet) Key(kid string) (crypto.PublicKey, error) {
+	err := ErrTokenKeyNotFound
+	for _, set := range m {
+		var key crypto.PublicKey
+		key, err = set.Key(kid)
+		if err == nil {
+			return key, nil
+		}
+	}
+	return nil, err
+}
+
+// LoadPEMKeys reads public keys (PKIX or PKCS1 PEM encoded) from files.
+// Keys are identified by the file path.
+func LoadPEMKeys(paths ...string) (StaticKeys, error) {
+	keys := make(StaticKeys, len(paths))
+
+	for _, path := range paths {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, errors.Wrap(err, "reading key file")
+		}
+
+		key, err := ParsePEMKey(data)
+		if err != nil {
+			return nil, errors.Wrapf(err, "parsing key file %s", path)
+		}
+		keys[path] = key
+	}
+
+	return keys, nil
+}
+
+// ParsePEMKey parses PEM encoded RSA or ECDSA public key.
+func ParsePEMKey(data []byte) (crypto.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, ErrTokenKeyMalformed
+	}
+
+	if key, err := x509.ParsePKIXPublicKey(block.Bytes); err == nil {
+		switch key.(type) {
+		case *rsa.PublicKey, *ecdsa.PublicKey:
+			return key, nil
+		}
+		return nil, ErrTokenKeyMalformed
+	}
+
+	if key, err := x509.ParsePKCS1PublicKey(block.Bytes); err == nil {
+		return key, nil
+	}
+
+	return nil, ErrTokenKeyMalformed
+}
+
+// JWKS is a key set fetched from a JSON Web Key Set endpoint.
+// Keys are refreshed periodically and on unknown key id, at most once per
+// DefaultJWKSMinRefresh. Only one fetch runs at a time; requests with keys
+// known already do not wait for it, and the last fetched keys are served
+// while the endpoint fails.
+type JWKS struct {
+	client     *http.Client
+	uri        string
+	refresh    time.Duration
+	minRefresh time.Duration
+
+	lock      sync.Mutex
+	keys      StaticKeys
+	fetched   time.Time
+	attempted time.Time
+	fetchErr  error
+	// closed when the running fetch is done, nil if none is running
+	fetching chan struct{}
+}
+
+func NewJWKS(uri string, client *http.Client) *JWKS {
+	if client == nil {
+		client = &http.Client{Timeout: 5 * time.Second}
+	}
+
+	return &JWKS{
+		client:     client,
+		uri:        uri,
+		refresh:    DefaultJWKSRefresh,
+		minRefresh: DefaultJWKSMinRefresh,
+	}
+}
+
+func (j *JWKS) Key(kid string) (crypto.PublicKey, error) {
+	j.lock.Lock()
+	key, err := j.lookup(kid)
+	now := time.Now()
+	stale := j.keys == nil || now.Sub(j.fetched) >= j.refresh
+	start := (stale || err != nil) && j.fetching == nil &&
+		now.Sub(j.attempted) >= j.minRefresh
+	if start {
+		j.attempted = now
+		j.fetching = make(chan struct{})
+	}
+	done := j.fetching
+	j.lock.Unlock()
+
+	// known key is served while the keys are refreshed
+	if err == nil {
+		if start {
+			go j.update()
+		}
+		return key, nil
+	}
+
+	if start {
+		j.update()
+	} else if done != nil {
+		<-done
+	}
+
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	return j.lookup(kid)
+}
+
+// lookup returns the key from the last fetched keys, or error of the last
+// fetch if none were fetched.
+func (j *JWKS) lookup(kid string) (crypto.PublicKey, error) {
+	if j.keys == nil {
+		if j.fetchErr != nil {
+			return nil, j.fetchErr
+		}
+		return nil, ErrTokenKeyNotFound
+	}
+	return j.keys.Key(kid)
+}
+
+// update fetches the keys, keeping the last fetched ones on failure.
+func (j *JWKS) update() {
+	keys, err := j.fetch()
+
+	j.lock.Lock()
+	defer j.lock.Unlock()
+
+	j.fetchErr = err
+	if err == nil {
+		j.keys = keys
+		j.fetched = time.Now()
+	}
+	close(j.fetching)
+	j.fetching = nil
+}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func (j *JWKS) fetch() (StaticKeys, error) {
+	resp, err := j.client.Get(j.uri)
+	if err != nil {
+		return nil, errors.Wrap(err, "fetching JWKS")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("unexpected JWKS response status: %d", resp.StatusCode)
+	}
+
+	set := struct {
+		Keys []jwk `json:"keys"`
+	}{}
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return nil, errors.Wrap(err, "parsing JWKS")
+	}
+
+	keys := make(StaticKeys, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		key, err := k.publicKey()
+		if err != nil {
+			// skip key types the service does not support
+			continue
+		}
+		keys[k.Kid] = key
+	}
+
+	return keys, nil
+}
+
+func (k *jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, ErrTokenKeyMalformed
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	}
+
+	return nil, ErrTokenKeyMalformed
+}
+
+func decodeBigInt(value string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil || len(b) == 0 {
+		return nil, ErrTokenKeyMalformed
+	}
+	return new(big.Int).SetBytes(b), nil
+}
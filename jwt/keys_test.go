@@ -0,0 +1,198 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package jwt
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadPEMKeys(t *testing.T) {
+
+	t.Parallel()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	assert.NoError(t, err)
+
+	dir, err := ioutil.TempDir("", "jwt")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "key.pem")
+	assert.NoError(t, ioutil.WriteFile(path,
+		pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0600))
+
+	keys, err := LoadPEMKeys(path)
+	assert.NoError(t, err)
+
+	loaded, err := keys.Key("")
+	assert.NoError(t, err)
+	assert.Equal(t, &key.PublicKey, loaded)
+
+	broken := filepath.Join(dir, "broken.pem")
+	assert.NoError(t, ioutil.WriteFile(broken, []byte("not a key"), 0600))
+
+	_, err = LoadPEMKeys(broken)
+	assert.Error(t, err)
+
+	_, err = LoadPEMKeys(filepath.Join(dir, "missing.pem"))
+	assert.Error(t, err)
+}
+
+func TestJWKS(t *testing.T) {
+
+	t.Parallel()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+
+	calls := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{
+				{
+					"kty": "RSA",
+					"kid": "key1",
+					"use": "sig",
+					"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+					"e": base64.RawURLEncoding.EncodeToString(
+						big.NewInt(int64(key.E)).Bytes()),
+				},
+				{
+					"kty": "oct",
+					"kid": "key2",
+				},
+			},
+		})
+	}))
+	defer ts.Close()
+
+	jwks := NewJWKS(ts.URL, nil)
+
+	loaded, err := jwks.Key("key1")
+	assert.NoError(t, err)
+	assert.Equal(t, &key.PublicKey, loaded)
+
+	// served from cache
+	_, err = jwks.Key("key1")
+	assert.NoError(t, err)
+
+	// unsupported key types are skipped
+	_, err = jwks.Key("key2")
+	assert.Equal(t, ErrTokenKeyNotFound, err)
+
+	assert.Equal(t, 1, calls)
+}
+
+func TestJWKSFailure(t *testing.T) {
+
+	t.Parallel()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+
+	var calls, failing int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		if atomic.LoadInt32(&failing) != 0 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{
+				{
+					"kty": "RSA",
+					"kid": "key1",
+					"use": "sig",
+					"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+					"e": base64.RawURLEncoding.EncodeToString(
+						big.NewInt(int64(key.E)).Bytes()),
+				},
+			},
+		})
+	}))
+	defer ts.Close()
+
+	jwks := NewJWKS(ts.URL, nil)
+	jwks.minRefresh = 0
+
+	_, err = jwks.Key("key1")
+	assert.NoError(t, err)
+
+	atomic.StoreInt32(&failing, 1)
+
+	// unknown key refetches; failure keeps the last fetched keys
+	_, err = jwks.Key("key3")
+	assert.Equal(t, ErrTokenKeyNotFound, err)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+
+	jwks.refresh = 0
+	loaded, err := jwks.Key("key1")
+	assert.NoError(t, err)
+	assert.Equal(t, &key.PublicKey, loaded)
+
+	// refetches are limited
+	jwks.lock.Lock()
+	if jwks.fetching != nil {
+		done := jwks.fetching
+		jwks.lock.Unlock()
+		<-done
+		jwks.lock.Lock()
+	}
+	jwks.minRefresh = time.Hour
+	jwks.lock.Unlock()
+	before := atomic.LoadInt32(&calls)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := jwks.Key("key3")
+			assert.Equal(t, ErrTokenKeyNotFound, err)
+			loaded, err := jwks.Key("key1")
+			assert.NoError(t, err)
+			assert.Equal(t, &key.PublicKey, loaded)
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, before, atomic.LoadInt32(&calls))
+
+	// nothing fetched yet: the fetch error is returned
+	jwks = NewJWKS(ts.URL, nil)
+	_, err = jwks.Key("key1")
+	assert.Error(t, err)
+	assert.NotEqual(t, ErrTokenKeyNotFound, err)
+}
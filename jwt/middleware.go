@@ -0,0 +1,55 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package jwt
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/ant0ine/go-json-rest/rest"
+	"github.com/mendersoftware/go-lib-micro/log"
+	"github.com/mendersoftware/go-lib-micro/rest_utils"
+	"github.com/pkg/errors"
+)
+
+// Errors
+var (
+	ErrAuthorizationMissing = errors.New("missing or malformed Authorization header")
+)
+
+// JWTMiddleware rejects requests without a valid bearer token with 401.
+type JWTMiddleware struct {
+	Validator *Validator
+}
+
+// MiddlewareFunc makes JWTMiddleware implement the Middleware interface.
+func (mw *JWTMiddleware) MiddlewareFunc(h rest.HandlerFunc) rest.HandlerFunc {
+	return func(w rest.ResponseWriter, r *rest.Request) {
+		l := log.FromContext(r.Context())
+
+		auth := strings.SplitN(r.Header.Get("Authorization"), " ", 2)
+		if len(auth) != 2 || !strings.EqualFold(auth[0], "Bearer") {
+			rest_utils.RestErrWithLog(w, r, l, ErrAuthorizationMissing, http.StatusUnauthorized)
+			return
+		}
+
+		if _, err := mw.Validator.Validate(strings.TrimSpace(auth[1])); err != nil {
+			rest_utils.RestErrWithLog(w, r, l, err, http.StatusUnauthorized)
+			return
+		}
+
+		h(w, r)
+	}
+}
@@ -0,0 +1,81 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package jwt
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/ant0ine/go-json-rest/rest"
+	"github.com/ant0ine/go-json-rest/rest/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJWTMiddleware(t *testing.T) {
+
+	t.Parallel()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+
+	token := sign(t, "RS256", "", key, map[string]interface{}{
+		"exp": time.Now().Add(time.Hour).Unix(),
+	})
+
+	testCases := map[string]struct {
+		Authorization string
+		Code          int
+	}{
+		"valid token": {
+			Authorization: "Bearer " + token,
+			Code:          http.StatusOK,
+		},
+		"missing header": {
+			Code: http.StatusUnauthorized,
+		},
+		"not a bearer token": {
+			Authorization: "Basic dXNlcjpwYXNz",
+			Code:          http.StatusUnauthorized,
+		},
+		"invalid token": {
+			Authorization: "Bearer " + token + "x",
+			Code:          http.StatusUnauthorized,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			api := rest.NewApi()
+			api.Use(&JWTMiddleware{
+				Validator: &Validator{
+					Keys: StaticKeys{"key": &key.PublicKey},
+				},
+			})
+			api.SetApp(rest.AppSimple(func(w rest.ResponseWriter, r *rest.Request) {
+				w.WriteJson(map[string]string{})
+			}))
+
+			req := test.MakeSimpleRequest("GET", "http://localhost/api/deployments", nil)
+			if tc.Authorization != "" {
+				req.Header.Set("Authorization", tc.Authorization)
+			}
+			recorded := test.RunRequest(t, api.MakeHandler(), req)
+			recorded.CodeIs(tc.Code)
+		})
+	}
+}
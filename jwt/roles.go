@@ -0,0 +1,89 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package jwt
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/ant0ine/go-json-rest/rest"
+	"github.com/mendersoftware/go-lib-micro/log"
+	"github.com/mendersoftware/go-lib-micro/rest_utils"
+	"github.com/pkg/errors"
+)
+
+// RolesClaim lists roles of the user the token was issued to.
+const RolesClaim = "mender.roles"
+
+// Errors
+var (
+	ErrRoleRequired = errors.New("operation requires a role the user does not have")
+)
+
+// RolesFromRequest returns roles from the bearer token of the request.
+// As with the caller identity, the token signature is not verified here;
+// tokens are verified by the API gateway, or by JWTMiddleware if configured.
+func RolesFromRequest(r *http.Request) ([]string, error) {
+	auth := strings.SplitN(r.Header.Get("Authorization"), " ", 2)
+	if len(auth) != 2 || !strings.EqualFold(auth[0], "Bearer") {
+		return nil, ErrAuthorizationMissing
+	}
+
+	parts := strings.Split(strings.TrimSpace(auth[1]), ".")
+	if len(parts) != 3 {
+		return nil, ErrTokenMalformed
+	}
+
+	var claims map[string]interface{}
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, ErrTokenMalformed
+	}
+
+	raw, ok := claims[RolesClaim].([]interface{})
+	if !ok {
+		return nil, nil
+	}
+
+	roles := make([]string, 0, len(raw))
+	for _, role := range raw {
+		if name, ok := role.(string); ok {
+			roles = append(roles, name)
+		}
+	}
+	return roles, nil
+}
+
+// RequireRole allows requests to the handler only if the token of the caller
+// carries the role, rejects them with 403 otherwise.
+func RequireRole(role string, h rest.HandlerFunc) rest.HandlerFunc {
+	return func(w rest.ResponseWriter, r *rest.Request) {
+		l := log.FromContext(r.Context())
+
+		roles, err := RolesFromRequest(r.Request)
+		if err != nil {
+			rest_utils.RestErrWithLog(w, r, l, err, http.StatusUnauthorized)
+			return
+		}
+
+		for _, name := range roles {
+			if name == role {
+				h(w, r)
+				return
+			}
+		}
+
+		rest_utils.RestErrWithLog(w, r, l, ErrRoleRequired, http.StatusForbidden)
+	}
+}
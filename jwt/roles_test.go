@@ -0,0 +1,92 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package jwt
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/ant0ine/go-json-rest/rest"
+	"github.com/ant0ine/go-json-rest/rest/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func unsigned(claims interface{}) string {
+	hdr, _ := json.Marshal(header{Alg: "RS256"})
+	payload, _ := json.Marshal(claims)
+	return base64.RawURLEncoding.EncodeToString(hdr) + "." +
+		base64.RawURLEncoding.EncodeToString(payload) + ".c2ln"
+}
+
+func TestRequireRole(t *testing.T) {
+
+	t.Parallel()
+
+	testCases := map[string]struct {
+		Authorization string
+		Code          int
+	}{
+		"has role": {
+			Authorization: "Bearer " + unsigned(map[string]interface{}{
+				"sub":      "user",
+				RolesClaim: []string{"RBAC_ROLE_OBSERVER", "RBAC_ROLE_PERMIT_ALL"},
+			}),
+			Code: http.StatusOK,
+		},
+		"other roles": {
+			Authorization: "Bearer " + unsigned(map[string]interface{}{
+				"sub":      "user",
+				RolesClaim: []string{"RBAC_ROLE_OBSERVER"},
+			}),
+			Code: http.StatusForbidden,
+		},
+		"no roles": {
+			Authorization: "Bearer " + unsigned(map[string]interface{}{
+				"sub": "user",
+			}),
+			Code: http.StatusForbidden,
+		},
+		"missing header": {
+			Code: http.StatusUnauthorized,
+		},
+		"malformed token": {
+			Authorization: "Bearer foo",
+			Code:          http.StatusUnauthorized,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			api := rest.NewApi()
+			router, err := rest.MakeRouter(
+				rest.Delete("/r", RequireRole("RBAC_ROLE_PERMIT_ALL",
+					func(w rest.ResponseWriter, r *rest.Request) {
+						w.WriteJson(map[string]string{})
+					})),
+			)
+			assert.NoError(t, err)
+			api.SetApp(router)
+
+			req := test.MakeSimpleRequest("DELETE", "http://localhost/r", nil)
+			if tc.Authorization != "" {
+				req.Header.Set("Authorization", tc.Authorization)
+			}
+			recorded := test.RunRequest(t, api.MakeHandler(), req)
+			recorded.CodeIs(tc.Code)
+		})
+	}
+}
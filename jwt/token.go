@@ -0,0 +1,229 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Errors
+var (
+	ErrTokenMalformed    = errors.New("malformed token")
+	ErrTokenAlgorithm    = errors.New("unsupported token signing algorithm")
+	ErrTokenSignature    = errors.New("invalid token signature")
+	ErrTokenExpired      = errors.New("token expired")
+	ErrTokenNotYetValid  = errors.New("token not valid yet")
+	ErrTokenAudience     = errors.New("invalid token audience")
+	ErrTokenKeyNotFound  = errors.New("token signing key not found")
+	ErrTokenKeyMalformed = errors.New("invalid token signing key")
+	ErrTokenType         = errors.New("token type not allowed")
+)
+
+// Allowed clock difference between the issuer and the service
+const ClockSkew = time.Minute
+
+type header struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+// Claims of the token checked by the service.
+type Claims struct {
+	Subject   string   `json:"sub"`
+	Issuer    string   `json:"iss"`
+	Audience  Audience `json:"aud"`
+	ExpiresAt int64    `json:"exp"`
+	NotBefore int64    `json:"nbf"`
+	Device    bool     `json:"mender.device"`
+	User      bool     `json:"mender.user"`
+}
+
+// TokenType tells device tokens from user tokens.
+type TokenType int
+
+const (
+	// Any token accepted
+	TokenTypeAny TokenType = iota
+	// Token has to carry the mender.device claim
+	TokenTypeDevice
+	// Token has to carry the mender.user claim
+	TokenTypeUser
+)
+
+// Is returns true if the claims mark the token as of the type.
+func (c *Claims) Is(typ TokenType) bool {
+	switch typ {
+	case TokenTypeDevice:
+		return c.Device && !c.User
+	case TokenTypeUser:
+		return c.User && !c.Device
+	}
+	return true
+}
+
+// Audience claim, either a single string or an array of strings.
+type Audience []string
+
+func (a *Audience) UnmarshalJSON(b []byte) error {
+	var single string
+	if err := json.Unmarshal(b, &single); err == nil {
+		*a = Audience{single}
+		return nil
+	}
+
+	var multiple []string
+	if err := json.Unmarshal(b, &multiple); err != nil {
+		return err
+	}
+	*a = Audience(multiple)
+	return nil
+}
+
+func (a Audience) Contains(audience string) bool {
+	for _, aud := range a {
+		if aud == audience {
+			return true
+		}
+	}
+	return false
+}
+
+type algorithm struct {
+	hash crypto.Hash
+	ec   bool
+}
+
+var algorithms = map[string]algorithm{
+	"RS256": {hash: crypto.SHA256},
+	"RS384": {hash: crypto.SHA384},
+	"RS512": {hash: crypto.SHA512},
+	"ES256": {hash: crypto.SHA256, ec: true},
+	"ES384": {hash: crypto.SHA384, ec: true},
+	"ES512": {hash: crypto.SHA512, ec: true},
+}
+
+// Validator verifies token signature against the key set and checks
+// token expiry, audience and type.
+type Validator struct {
+	Keys KeySet
+
+	// Required audience, not checked if empty
+	Audience string
+
+	// Required token type, not checked if TokenTypeAny
+	Type TokenType
+}
+
+// Validate parses and verifies the token, returning its claims.
+func (v *Validator) Validate(token string) (*Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrTokenMalformed
+	}
+
+	var hdr header
+	if err := decodeSegment(parts[0], &hdr); err != nil {
+		return nil, ErrTokenMalformed
+	}
+
+	alg, ok := algorithms[hdr.Alg]
+	if !ok {
+		return nil, ErrTokenAlgorithm
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrTokenMalformed
+	}
+
+	key, err := v.Keys.Key(hdr.Kid)
+	if err != nil {
+		return nil, err
+	}
+
+	h := alg.hash.New()
+	h.Write([]byte(parts[0] + "." + parts[1]))
+	digest := h.Sum(nil)
+
+	if err := verify(alg, key, digest, signature); err != nil {
+		return nil, err
+	}
+
+	var claims Claims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, ErrTokenMalformed
+	}
+
+	now := time.Now()
+	if claims.ExpiresAt == 0 || now.After(time.Unix(claims.ExpiresAt, 0).Add(ClockSkew)) {
+		return nil, ErrTokenExpired
+	}
+	if claims.NotBefore != 0 && now.Add(ClockSkew).Before(time.Unix(claims.NotBefore, 0)) {
+		return nil, ErrTokenNotYetValid
+	}
+	if v.Audience != "" && !claims.Audience.Contains(v.Audience) {
+		return nil, ErrTokenAudience
+	}
+	if !claims.Is(v.Type) {
+		return nil, ErrTokenType
+	}
+
+	return &claims, nil
+}
+
+func verify(alg algorithm, key crypto.PublicKey, digest, signature []byte) error {
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		if alg.ec {
+			return ErrTokenAlgorithm
+		}
+		if err := rsa.VerifyPKCS1v15(k, alg.hash, digest, signature); err != nil {
+			return ErrTokenSignature
+		}
+	case *ecdsa.PublicKey:
+		if !alg.ec {
+			return ErrTokenAlgorithm
+		}
+		size := (k.Curve.Params().BitSize + 7) / 8
+		if len(signature) != 2*size {
+			return ErrTokenSignature
+		}
+		r := new(big.Int).SetBytes(signature[:size])
+		s := new(big.Int).SetBytes(signature[size:])
+		if !ecdsa.Verify(k, digest, r, s) {
+			return ErrTokenSignature
+		}
+	default:
+		return ErrTokenKeyMalformed
+	}
+	return nil
+}
+
+func decodeSegment(segment string, v interface{}) error {
+	b, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
@@ -0,0 +1,213 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func sign(t *testing.T, alg, kid string, key crypto.PrivateKey, claims interface{}) string {
+	hdr, _ := json.Marshal(header{Alg: alg, Kid: kid})
+	payload, _ := json.Marshal(claims)
+
+	signed := base64.RawURLEncoding.EncodeToString(hdr) + "." +
+		base64.RawURLEncoding.EncodeToString(payload)
+
+	h := algorithms[alg].hash.New()
+	h.Write([]byte(signed))
+	digest := h.Sum(nil)
+
+	var signature []byte
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		var err error
+		signature, err = rsa.SignPKCS1v15(rand.Reader, k, algorithms[alg].hash, digest)
+		assert.NoError(t, err)
+	case *ecdsa.PrivateKey:
+		r, s, err := ecdsa.Sign(rand.Reader, k, digest)
+		assert.NoError(t, err)
+		size := (k.Curve.Params().BitSize + 7) / 8
+		signature = make([]byte, 2*size)
+		r.FillBytes(signature[:size])
+		s.FillBytes(signature[size:])
+	}
+
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func TestValidatorValidate(t *testing.T) {
+
+	t.Parallel()
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+
+	now := time.Now()
+	valid := map[string]interface{}{
+		"sub": "user1",
+		"aud": "mender",
+		"exp": now.Add(time.Hour).Unix(),
+	}
+
+	validator := &Validator{
+		Keys: StaticKeys{
+			"rsa": &rsaKey.PublicKey,
+			"ec":  &ecKey.PublicKey,
+		},
+		Audience: "mender",
+	}
+
+	testCases := map[string]struct {
+		Token string
+		Err   error
+	}{
+		"valid RS256": {
+			Token: sign(t, "RS256", "rsa", rsaKey, valid),
+		},
+		"valid ES256": {
+			Token: sign(t, "ES256", "ec", ecKey, valid),
+		},
+		"audience array": {
+			Token: sign(t, "RS256", "rsa", rsaKey, map[string]interface{}{
+				"aud": []string{"other", "mender"},
+				"exp": now.Add(time.Hour).Unix(),
+			}),
+		},
+		"wrong key": {
+			Token: sign(t, "RS256", "rsa", otherKey, valid),
+			Err:   ErrTokenSignature,
+		},
+		"unknown key": {
+			Token: sign(t, "RS256", "other", rsaKey, valid),
+			Err:   ErrTokenKeyNotFound,
+		},
+		"algorithm mismatch": {
+			Token: sign(t, "RS256", "ec", rsaKey, valid),
+			Err:   ErrTokenAlgorithm,
+		},
+		"unsupported algorithm": {
+			Token: "eyJhbGciOiJub25lIn0.e30.",
+			Err:   ErrTokenAlgorithm,
+		},
+		"expired": {
+			Token: sign(t, "RS256", "rsa", rsaKey, map[string]interface{}{
+				"aud": "mender",
+				"exp": now.Add(-time.Hour).Unix(),
+			}),
+			Err: ErrTokenExpired,
+		},
+		"no expiry": {
+			Token: sign(t, "RS256", "rsa", rsaKey, map[string]interface{}{
+				"aud": "mender",
+			}),
+			Err: ErrTokenExpired,
+		},
+		"not valid yet": {
+			Token: sign(t, "RS256", "rsa", rsaKey, map[string]interface{}{
+				"aud": "mender",
+				"exp": now.Add(2 * time.Hour).Unix(),
+				"nbf": now.Add(time.Hour).Unix(),
+			}),
+			Err: ErrTokenNotYetValid,
+		},
+		"wrong audience": {
+			Token: sign(t, "RS256", "rsa", rsaKey, map[string]interface{}{
+				"aud": "other",
+				"exp": now.Add(time.Hour).Unix(),
+			}),
+			Err: ErrTokenAudience,
+		},
+		"malformed": {
+			Token: "foo.bar",
+			Err:   ErrTokenMalformed,
+		},
+	}
+
+	for name, test := range testCases {
+		t.Run(name, func(t *testing.T) {
+			claims, err := validator.Validate(test.Token)
+			if test.Err != nil {
+				assert.Equal(t, test.Err, err)
+				assert.Nil(t, claims)
+			} else {
+				assert.NoError(t, err)
+				assert.NotNil(t, claims)
+			}
+		})
+	}
+}
+
+func TestValidatorTokenType(t *testing.T) {
+
+	t.Parallel()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+
+	exp := time.Now().Add(time.Hour).Unix()
+	device := sign(t, "RS256", "", key, map[string]interface{}{
+		"exp": exp, "mender.device": true,
+	})
+	user := sign(t, "RS256", "", key, map[string]interface{}{
+		"exp": exp, "mender.user": true,
+	})
+	both := sign(t, "RS256", "", key, map[string]interface{}{
+		"exp": exp, "mender.user": true, "mender.device": true,
+	})
+	untyped := sign(t, "RS256", "", key, map[string]interface{}{
+		"exp": exp,
+	})
+
+	testCases := map[string]struct {
+		Type  TokenType
+		Token string
+		Err   error
+	}{
+		"any, device":     {Type: TokenTypeAny, Token: device},
+		"any, untyped":    {Type: TokenTypeAny, Token: untyped},
+		"user, user":      {Type: TokenTypeUser, Token: user},
+		"user, device":    {Type: TokenTypeUser, Token: device, Err: ErrTokenType},
+		"user, both":      {Type: TokenTypeUser, Token: both, Err: ErrTokenType},
+		"user, untyped":   {Type: TokenTypeUser, Token: untyped, Err: ErrTokenType},
+		"device, device":  {Type: TokenTypeDevice, Token: device},
+		"device, user":    {Type: TokenTypeDevice, Token: user, Err: ErrTokenType},
+		"device, untyped": {Type: TokenTypeDevice, Token: untyped, Err: ErrTokenType},
+	}
+
+	for name, test := range testCases {
+		t.Run(name, func(t *testing.T) {
+			validator := &Validator{
+				Keys: StaticKeys{"": &key.PublicKey},
+				Type: test.Type,
+			}
+			_, err := validator.Validate(test.Token)
+			assert.Equal(t, test.Err, err)
+		})
+	}
+}
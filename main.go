@@ -18,13 +18,19 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"time"
 
+	"github.com/mendersoftware/go-lib-micro/identity"
 	"github.com/mendersoftware/go-lib-micro/log"
 	mstore "github.com/mendersoftware/go-lib-micro/store"
 	"github.com/urfave/cli"
 
 	"github.com/mendersoftware/deployments/config"
 	"github.com/mendersoftware/deployments/migrations"
+	deploymentsModel "github.com/mendersoftware/deployments/resources/deployments/model"
+	deploymentsMongo "github.com/mendersoftware/deployments/resources/deployments/mongo"
+	imagesModel "github.com/mendersoftware/deployments/resources/images/model"
+	imagesMongo "github.com/mendersoftware/deployments/resources/images/mongo"
 )
 
 func main() {
@@ -45,6 +51,11 @@ func doMain(args []string) {
 			Usage:       "Configuration `FILE`. Supports JSON, TOML, YAML and HCL formatted configs.",
 			Destination: &configPath,
 		},
+		cli.BoolFlag{
+			Name: "validate-config",
+			Usage: "Check configuration, database connectivity and artifact storage access, " +
+				"print a report and exit. Exits non-zero if any check fails.",
+		},
 	}
 
 	app.Commands = []cli.Command{
@@ -72,6 +83,43 @@ func doMain(args []string) {
 
 			Action: cmdMigrate,
 		},
+		{
+			Name:  "archive-artifacts",
+			Usage: "Move files of artifacts not deployed recently to the archive storage class and exit",
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "tenant",
+					Usage: "Tenant ID (optional).",
+				},
+			},
+
+			Action: cmdArchiveArtifacts,
+		},
+		{
+			Name:  "purge-artifact-trash",
+			Usage: "Permanently delete artifacts kept in the trash longer than configured and exit",
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "tenant",
+					Usage: "Tenant ID (optional).",
+				},
+			},
+
+			Action: cmdPurgeArtifactTrash,
+		},
+		{
+			Name: "partition-device-deployments",
+			Usage: "Move device deployments finished long ago to monthly partitions " +
+				"and exit",
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "tenant",
+					Usage: "Tenant ID (optional).",
+				},
+			},
+
+			Action: cmdPartitionDeviceDeployments,
+		},
 	}
 
 	app.Action = cmdServer
@@ -91,6 +139,10 @@ func doMain(args []string) {
 }
 
 func cmdServer(args *cli.Context) error {
+	if args.GlobalBool("validate-config") {
+		return cmdValidateConfig(args)
+	}
+
 	devSetup := args.GlobalBool("dev")
 
 	l := log.New(log.Ctx{})
@@ -131,6 +183,18 @@ func cmdServer(args *cli.Context) error {
 	return nil
 }
 
+func cmdValidateConfig(args *cli.Context) error {
+	results := RunSelfChecks(config.Config, os.Stdout, SelfChecks())
+
+	if failed := SelfChecksFailed(results); failed > 0 {
+		return cli.NewExitError(
+			fmt.Sprintf("%d of %d checks failed", failed, len(results)),
+			1)
+	}
+
+	return nil
+}
+
 func cmdMigrate(args *cli.Context) error {
 	tenant := args.String("tenant")
 	db := mstore.DbNameForTenant(tenant, migrations.DbName)
@@ -153,3 +217,124 @@ func cmdMigrate(args *cli.Context) error {
 
 	return nil
 }
+
+func cmdArchiveArtifacts(args *cli.Context) error {
+	storageClass := config.Config.GetString(SettingAwsArchiveStorageClass)
+	if storageClass == "" {
+		return cli.NewExitError(
+			fmt.Sprintf("archive storage class not configured (%s)",
+				SettingAwsArchiveStorageClass),
+			1)
+	}
+
+	ctx := context.Background()
+	if tenant := args.String("tenant"); tenant != "" {
+		ctx = identity.WithContext(ctx, &identity.Identity{Tenant: tenant})
+	}
+
+	dbSession, err := NewMongoSession(config.Config)
+	if err != nil {
+		return cli.NewExitError(
+			fmt.Sprintf("failed to connect to db: %v", err),
+			3)
+	}
+	defer dbSession.Close()
+
+	fileStorage, err := SetupS3(config.Config)
+	if err != nil {
+		return cli.NewExitError(
+			fmt.Sprintf("failed to set up file storage: %v", err),
+			3)
+	}
+
+	deploymentModel := deploymentsModel.NewDeploymentModel(deploymentsModel.DeploymentsModelConfig{
+		DeploymentsStorage:       deploymentsMongo.NewDeploymentsStorage(dbSession),
+		DeviceDeploymentsStorage: deploymentsMongo.NewDeviceDeploymentsStorage(dbSession),
+	})
+	imagesModel := imagesModel.NewImagesModel(fileStorage, deploymentModel,
+		imagesMongo.NewSoftwareImagesStorage(dbSession))
+
+	days := config.Config.GetInt(SettingAwsArchiveUnusedDays)
+	unusedSince := time.Now().AddDate(0, 0, -days)
+
+	archived, err := imagesModel.ArchiveImages(ctx, unusedSince, storageClass)
+	log.FromContext(ctx).Infof("archived %d artifacts", archived)
+	if err != nil {
+		return cli.NewExitError(
+			fmt.Sprintf("failed to archive artifacts: %v", err),
+			4)
+	}
+
+	return nil
+}
+
+func cmdPurgeArtifactTrash(args *cli.Context) error {
+	ctx := context.Background()
+	if tenant := args.String("tenant"); tenant != "" {
+		ctx = identity.WithContext(ctx, &identity.Identity{Tenant: tenant})
+	}
+
+	dbSession, err := NewMongoSession(config.Config)
+	if err != nil {
+		return cli.NewExitError(
+			fmt.Sprintf("failed to connect to db: %v", err),
+			3)
+	}
+	defer dbSession.Close()
+
+	fileStorage, err := SetupS3(config.Config)
+	if err != nil {
+		return cli.NewExitError(
+			fmt.Sprintf("failed to set up file storage: %v", err),
+			3)
+	}
+
+	imagesModel := imagesModel.NewImagesModel(fileStorage, nil,
+		imagesMongo.NewSoftwareImagesStorage(dbSession))
+
+	days := config.Config.GetInt(SettingArtifactTrashDays)
+	trashedBefore := time.Now().AddDate(0, 0, -days)
+
+	purged, err := imagesModel.PurgeTrash(ctx, trashedBefore)
+	log.FromContext(ctx).Infof("purged %d artifacts", purged)
+	if err != nil {
+		return cli.NewExitError(
+			fmt.Sprintf("failed to purge artifacts: %v", err),
+			4)
+	}
+
+	return nil
+}
+
+func cmdPartitionDeviceDeployments(args *cli.Context) error {
+	ctx := context.Background()
+	if tenant := args.String("tenant"); tenant != "" {
+		ctx = identity.WithContext(ctx, &identity.Identity{Tenant: tenant})
+	}
+
+	dbSession, err := NewMongoSession(config.Config)
+	if err != nil {
+		return cli.NewExitError(
+			fmt.Sprintf("failed to connect to db: %v", err),
+			3)
+	}
+	defer dbSession.Close()
+
+	deploymentModel := deploymentsModel.NewDeploymentModel(deploymentsModel.DeploymentsModelConfig{
+		DeploymentsStorage:       deploymentsMongo.NewDeploymentsStorage(dbSession),
+		DeviceDeploymentsStorage: deploymentsMongo.NewDeviceDeploymentsStorage(dbSession),
+	})
+
+	days := config.Config.GetInt(SettingPartitionDeviceDeploymentsDays)
+	finishedBefore := time.Now().AddDate(0, 0, -days)
+
+	moved, err := deploymentModel.PartitionDeviceDeployments(ctx, finishedBefore)
+	log.FromContext(ctx).Infof("partitioned %d device deployments", moved)
+	if err != nil {
+		return cli.NewExitError(
+			fmt.Sprintf("failed to partition device deployments: %v", err),
+			4)
+	}
+
+	return nil
+}
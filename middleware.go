@@ -18,6 +18,8 @@ import (
 	"mime"
 	"net/http"
 	"regexp"
+	"strings"
+	"time"
 
 	"github.com/ant0ine/go-json-rest/rest"
 	"github.com/mendersoftware/go-lib-micro/accesslog"
@@ -26,7 +28,12 @@ import (
 	"github.com/mendersoftware/go-lib-micro/requestid"
 	"github.com/mendersoftware/go-lib-micro/requestlog"
 
+	"github.com/mendersoftware/deployments/authz"
 	"github.com/mendersoftware/deployments/config"
+	"github.com/mendersoftware/deployments/jwt"
+	"github.com/mendersoftware/deployments/resources/apitokens"
+	"github.com/mendersoftware/deployments/resources/images/mirror"
+	"github.com/mendersoftware/deployments/utils/restutil"
 )
 
 const (
@@ -67,14 +74,91 @@ var defaultDevStack = []rest.Middleware{
 }
 
 var defaultProdStack = []rest.Middleware{
-	// catches the panic errorsx
-	&rest.RecoverMiddleware{},
+	// catches the panic errors, logs stack trace
+	&restutil.RecoverMiddleware{},
 
 	// response compression
 	&rest.GzipMiddleware{},
 }
 
-func SetupMiddleware(c config.ConfigReader, api *rest.Api) {
+// SetupJWTValidator creates token validator from configuration,
+// nil if no verification keys are configured.
+func SetupJWTValidator(c config.ConfigReader) (*jwt.Validator, error) {
+	var keys []jwt.KeySet
+
+	if files := c.GetStringSlice(SettingJWTKeyFiles); len(files) > 0 {
+		static, err := jwt.LoadPEMKeys(files...)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, static)
+	}
+
+	if uri := c.GetString(SettingJWTJWKSURL); uri != "" {
+		keys = append(keys, jwt.NewJWKS(uri, nil))
+	}
+
+	if len(keys) == 0 {
+		return nil, nil
+	}
+
+	return &jwt.Validator{
+		Keys:     jwt.MultiKeySet(keys),
+		Audience: c.GetString(SettingJWTAudience),
+	}, nil
+}
+
+// NewJWTRoutesMiddleware validates tokens of requests of the route group,
+// accepting only user tokens on management routes and only device tokens
+// on device routes, except proxied downloads; nil if the group is unknown.
+func NewJWTRoutesMiddleware(group string, validator *jwt.Validator) rest.Middleware {
+	typed := *validator
+	var prefix string
+	switch group {
+	case JWTRoutesManagement:
+		prefix, typed.Type = ApiUrlManagement, jwt.TokenTypeUser
+	case JWTRoutesDevices:
+		prefix, typed.Type = ApiUrlDevices, jwt.TokenTypeDevice
+	default:
+		return nil
+	}
+
+	return &rest.IfMiddleware{
+		Condition: func(r *rest.Request) bool {
+			// API tokens are verified by the router
+			if apitokens.FromRequest(r.Request) != "" || isProxiedDownload(r) {
+				return false
+			}
+			return strings.HasPrefix(r.URL.Path, prefix)
+		},
+		IfTrue: &jwt.JWTMiddleware{Validator: &typed},
+	}
+}
+
+// isProxiedDownload tells if the request is a proxied artifact download,
+// authorized by the signature of the link; devices send no token with it.
+func isProxiedDownload(r *rest.Request) bool {
+	return r.Method == http.MethodGet &&
+		strings.HasPrefix(r.URL.Path, ApiUrlDevicesDownload+"/")
+}
+
+// NewAuthzMiddleware authorizes requests against the authorizer, except
+// requests with API tokens: identity of those is not known before the
+// router, and they are limited to the requests of the token scopes, and
+// proxied downloads, which carry no identity.
+func NewAuthzMiddleware(authorizer authz.Authorizer, failOpen bool) rest.Middleware {
+	return &rest.IfMiddleware{
+		Condition: func(r *rest.Request) bool {
+			return apitokens.FromRequest(r.Request) == "" && !isProxiedDownload(r)
+		},
+		IfTrue: &authz.AuthzMiddleware{
+			Authorizer: authorizer,
+			FailOpen:   failOpen,
+		},
+	}
+}
+
+func SetupMiddleware(c config.ConfigReader, api *rest.Api) error {
 
 	api.Use(&customheader.CustomHeaderMiddleware{
 		HeaderName:  "X-DEPLOYMENTS-VERSION",
@@ -90,11 +174,51 @@ func SetupMiddleware(c config.ConfigReader, api *rest.Api) {
 		api.Use(defaultProdStack...)
 	}
 
+	// Collect device request details used for selecting artifact download mirror.
+	if c.IsSet(SettingMirrorsNetworks) || c.IsSet(SettingMirrorsAttributes) {
+		api.Use(&rest.IfMiddleware{
+			Condition: func(r *rest.Request) bool {
+				return strings.HasPrefix(r.URL.Path, ApiUrlDevices)
+			},
+			IfTrue: &mirror.ClientMiddleware{},
+		})
+	}
+
+	// Validate JWTs in the service, if configured.
+	validator, err := SetupJWTValidator(c)
+	if err != nil {
+		return err
+	}
+	if validator != nil {
+		for _, group := range c.GetStringSlice(SettingJWTRoutes) {
+			if mw := NewJWTRoutesMiddleware(group, validator); mw != nil {
+				api.Use(mw)
+			}
+		}
+	}
+
 	api.Use(&requestid.RequestIdMiddleware{},
 		&identity.IdentityMiddleware{
 			UpdateLogger: true,
 		})
 
+	// Optional fine-grained authorization against Open Policy Agent.
+	if uri := c.GetString(SettingAuthzOPAURL); uri != "" {
+		authorizer, err := authz.NewOPAClient(uri,
+			authz.WithCacheTTL(time.Duration(c.GetInt(SettingAuthzCacheTTL))*time.Second),
+			authz.WithCacheSize(c.GetInt(SettingAuthzCacheSize)))
+		if err != nil {
+			return err
+		}
+
+		api.Use(NewAuthzMiddleware(authorizer, c.GetBool(SettingAuthzFailOpen)))
+	}
+
+	// Reject unknown fields of request bodies, if configured.
+	if c.GetBool(SettingStrictJSON) {
+		api.Use(&restutil.StrictJSONMiddleware{})
+	}
+
 	// Verifies the request Content-Type header if the content is non-null.
 	// For the POST /api/0.0.1/images request expected Content-Type is 'multipart/form-data'.
 	// For the rest of the requests expected Content-Type is 'application/json'.
@@ -168,4 +292,47 @@ func SetupMiddleware(c config.ConfigReader, api *rest.Api) {
 			HttpHeaderLink,
 		},
 	})
+
+	// Handler timeouts, innermost so that only handler execution is limited.
+	timeouts, err := SetupTimeouts(c)
+	if err != nil {
+		return err
+	}
+	if timeouts != nil {
+		api.Use(timeouts)
+	}
+
+	return nil
+}
+
+// SetupTimeouts creates handler timeout middleware from configuration,
+// nil if no timeouts are configured.
+func SetupTimeouts(c config.ConfigReader) (*restutil.TimeoutMiddleware, error) {
+	mw := &restutil.TimeoutMiddleware{
+		Default: c.GetDuration(SettingTimeoutsDefault),
+	}
+
+	for _, rule := range c.GetStringSlice(SettingTimeoutsRoutes) {
+		route, err := restutil.ParseRouteTimeout(rule)
+		if err != nil {
+			return nil, err
+		}
+		mw.Routes = append(mw.Routes, *route)
+	}
+
+	if mw.Default <= 0 && len(mw.Routes) == 0 {
+		return nil, nil
+	}
+
+	// the dashboard stream lasts as long as the client stays connected
+	mw.Streaming = []restutil.RouteTimeout{
+		{Method: http.MethodGet, Prefix: ApiUrlManagementStream},
+	}
+	// artifact uploads last as long as the client sends the artifact;
+	// routes configured for them still apply
+	mw.NoDefault = []restutil.RouteTimeout{
+		{Method: http.MethodPost, Prefix: ApiUrlManagementArtifacts, Exact: true},
+	}
+
+	return mw, nil
 }
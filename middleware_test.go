@@ -0,0 +1,166 @@
+// Copyright 2018 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package main
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/ant0ine/go-json-rest/rest"
+	"github.com/ant0ine/go-json-rest/rest/test"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mendersoftware/deployments/authz"
+	"github.com/mendersoftware/deployments/jwt"
+	"github.com/mendersoftware/deployments/resources/apitokens"
+)
+
+func signRS256(t *testing.T, key *rsa.PrivateKey, claims map[string]interface{}) string {
+	hdr, _ := json.Marshal(map[string]string{"alg": "RS256"})
+	payload, _ := json.Marshal(claims)
+	signed := base64.RawURLEncoding.EncodeToString(hdr) + "." +
+		base64.RawURLEncoding.EncodeToString(payload)
+
+	digest := sha256.Sum256([]byte(signed))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	assert.NoError(t, err)
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func TestJWTRoutesMiddleware(t *testing.T) {
+
+	t.Parallel()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	validator := &jwt.Validator{Keys: jwt.StaticKeys{"": &key.PublicKey}}
+
+	exp := time.Now().Add(time.Hour).Unix()
+	device := signRS256(t, key, map[string]interface{}{"exp": exp, "mender.device": true})
+	user := signRS256(t, key, map[string]interface{}{"exp": exp, "mender.user": true})
+
+	testCases := map[string]struct {
+		Path  string
+		Token string
+		Code  int
+	}{
+		"user token, management route": {
+			Path:  ApiUrlManagement + "/deployments",
+			Token: user,
+			Code:  http.StatusOK,
+		},
+		"device token, management route": {
+			Path:  ApiUrlManagement + "/deployments",
+			Token: device,
+			Code:  http.StatusUnauthorized,
+		},
+		"device token, device route": {
+			Path:  ApiUrlDevices + "/device/deployments/next",
+			Token: device,
+			Code:  http.StatusOK,
+		},
+		"user token, device route": {
+			Path:  ApiUrlDevices + "/device/deployments/next",
+			Token: user,
+			Code:  http.StatusUnauthorized,
+		},
+		"internal route": {
+			Path: ApiUrlInternal + "/health",
+			Code: http.StatusOK,
+		},
+		"proxied download": {
+			Path: ApiUrlDevicesDownload + "/b532b01a-9313-404f-8d19-e7fcbe5cc347/device-1?signature=abc",
+			Code: http.StatusOK,
+		},
+		"no token, device route": {
+			Path: ApiUrlDevices + "/device/deployments/next",
+			Code: http.StatusUnauthorized,
+		},
+	}
+
+	api := rest.NewApi()
+	api.Use(NewJWTRoutesMiddleware(JWTRoutesManagement, validator))
+	api.Use(NewJWTRoutesMiddleware(JWTRoutesDevices, validator))
+	api.SetApp(rest.AppSimple(func(w rest.ResponseWriter, r *rest.Request) {
+		w.WriteJson(map[string]string{})
+	}))
+	handler := api.MakeHandler()
+
+	assert.Nil(t, NewJWTRoutesMiddleware("internal", validator))
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			req := test.MakeSimpleRequest(http.MethodGet, "http://localhost"+tc.Path, nil)
+			if tc.Token != "" {
+				req.Header.Set("Authorization", "Bearer "+tc.Token)
+			}
+			recorded := test.RunRequest(t, handler, req)
+			recorded.CodeIs(tc.Code)
+		})
+	}
+}
+
+type denyAuthorizer struct {
+	calls int
+}
+
+func (a *denyAuthorizer) Authorize(ctx context.Context, input *authz.Input) (bool, error) {
+	a.calls++
+	return false, nil
+}
+
+func TestAuthzMiddlewareAPITokens(t *testing.T) {
+
+	t.Parallel()
+
+	authorizer := &denyAuthorizer{}
+
+	api := rest.NewApi()
+	api.Use(NewAuthzMiddleware(authorizer, false))
+	api.SetApp(rest.AppSimple(func(w rest.ResponseWriter, r *rest.Request) {
+		w.WriteJson(map[string]string{})
+	}))
+	handler := api.MakeHandler()
+
+	req := test.MakeSimpleRequest(http.MethodGet,
+		"http://localhost"+ApiUrlManagement+"/deployments", nil)
+	req.Header.Set("Authorization", "Bearer token")
+	recorded := test.RunRequest(t, handler, req)
+	recorded.CodeIs(http.StatusForbidden)
+	assert.Equal(t, 1, authorizer.calls)
+
+	// scopes of API tokens are checked by the router
+	req = test.MakeSimpleRequest(http.MethodPost,
+		"http://localhost"+ApiUrlManagementArtifacts, nil)
+	req.Header.Set("Authorization", "Bearer "+apitokens.TokenPrefix+"id.secret")
+	recorded = test.RunRequest(t, handler, req)
+	recorded.CodeIs(http.StatusOK)
+	assert.Equal(t, 1, authorizer.calls)
+
+	// proxied downloads are authorized by the link signature
+	req = test.MakeSimpleRequest(http.MethodGet,
+		"http://localhost"+ApiUrlDevicesDownload+"/b532b01a-9313-404f-8d19-e7fcbe5cc347/device-1", nil)
+	recorded = test.RunRequest(t, handler, req)
+	recorded.CodeIs(http.StatusOK)
+	assert.Equal(t, 1, authorizer.calls)
+}
@@ -0,0 +1,315 @@
+// Copyright 2018 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/globalsign/mgo"
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/deployments/config"
+)
+
+const (
+	mongoScheme    = "mongodb://"
+	mongoSRVScheme = "mongodb+srv://"
+
+	// Authentication mechanisms supported by the mongo driver.
+	MongoAuthSCRAMSHA1 = "SCRAM-SHA-1"
+	MongoAuthMongoCR   = "MONGODB-CR"
+	MongoAuthX509      = "MONGODB-X509"
+)
+
+// mongoSRVOptions are the only connection string options allowed in TXT
+// records of mongodb+srv hosts.
+var mongoSRVOptions = map[string]bool{
+	"authSource": true,
+	"replicaSet": true,
+}
+
+// MongoSRVResolver looks up DNS records of mongodb+srv connection strings.
+// Satisfied by *net.Resolver.
+type MongoSRVResolver interface {
+	LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error)
+	LookupTXT(ctx context.Context, name string) ([]string, error)
+}
+
+// mongoURL is a connection string split into its parts; credentials and
+// options are kept escaped as given.
+type mongoURL struct {
+	userinfo string
+	hosts    []string
+	database string
+	options  []string
+}
+
+func parseMongoURL(url string) (*mongoURL, error) {
+	u := &mongoURL{}
+	s := url
+	for _, scheme := range []string{mongoSRVScheme, mongoScheme} {
+		if strings.HasPrefix(s, scheme) {
+			s = strings.TrimPrefix(s, scheme)
+			break
+		}
+	}
+
+	if i := strings.Index(s, "?"); i != -1 {
+		u.options = strings.FieldsFunc(s[i+1:], func(r rune) bool {
+			return r == '&' || r == ';'
+		})
+		s = s[:i]
+	}
+	if i := strings.LastIndex(s, "@"); i != -1 {
+		u.userinfo = s[:i]
+		s = s[i+1:]
+	}
+	if i := strings.Index(s, "/"); i != -1 {
+		u.database = s[i+1:]
+		s = s[:i]
+	}
+	if s == "" {
+		return nil, errors.New("no hosts in connection string")
+	}
+	u.hosts = strings.Split(s, ",")
+
+	return u, nil
+}
+
+func (u *mongoURL) option(key string) (string, bool) {
+	for _, opt := range u.options {
+		if kv := strings.SplitN(opt, "=", 2); kv[0] == key {
+			if len(kv) == 2 {
+				return kv[1], true
+			}
+			return "", true
+		}
+	}
+	return "", false
+}
+
+func (u *mongoURL) String() string {
+	s := mongoScheme
+	if u.userinfo != "" {
+		s += u.userinfo + "@"
+	}
+	s += strings.Join(u.hosts, ",") + "/" + u.database
+	if len(u.options) > 0 {
+		s += "?" + strings.Join(u.options, "&")
+	}
+	return s
+}
+
+// parseMongoSRVURL parses mongodb+srv connection string, which must name
+// a single host without port, of at least three labels.
+func parseMongoSRVURL(url string) (*mongoURL, error) {
+	u, err := parseMongoURL(url)
+	if err != nil {
+		return nil, err
+	}
+	if len(u.hosts) != 1 {
+		return nil, errors.New("mongodb+srv connection string must have exactly one host")
+	}
+	if strings.Contains(u.hosts[0], ":") {
+		return nil, errors.New("mongodb+srv connection string must not have a port")
+	}
+	if len(strings.Split(u.hosts[0], ".")) < 3 {
+		return nil, errors.New("mongodb+srv host must have at least three labels")
+	}
+	return u, nil
+}
+
+// ResolveMongoSRV translates mongodb+srv connection string to a plain
+// mongodb one: hosts are taken from the SRV records of the host, options
+// missing in the connection string from its TXT record, and TLS is enabled
+// unless disabled explicitly.
+func ResolveMongoSRV(ctx context.Context, resolver MongoSRVResolver,
+	url string) (string, error) {
+
+	u, err := parseMongoSRVURL(url)
+	if err != nil {
+		return "", err
+	}
+	host := u.hosts[0]
+	domain := host[strings.Index(host, ".")+1:]
+
+	_, records, err := resolver.LookupSRV(ctx, "mongodb", "tcp", host)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to look up SRV records of %s", host)
+	}
+	if len(records) == 0 {
+		return "", errors.Errorf("no SRV records found for %s", host)
+	}
+	hosts := make([]string, 0, len(records))
+	for _, record := range records {
+		target := strings.TrimSuffix(record.Target, ".")
+		if !strings.HasSuffix(target, "."+domain) {
+			return "", errors.Errorf(
+				"SRV record target %s is not within domain %s", target, domain)
+		}
+		hosts = append(hosts, net.JoinHostPort(target, strconv.Itoa(int(record.Port))))
+	}
+	u.hosts = hosts
+
+	txts, err := resolver.LookupTXT(ctx, host)
+	if dnsErr, ok := err.(*net.DNSError); ok && dnsErr.IsNotFound {
+		err = nil
+	}
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to look up TXT record of %s", host)
+	}
+	if len(txts) > 1 {
+		return "", errors.Errorf("multiple TXT records found for %s", host)
+	}
+	if len(txts) == 1 {
+		for _, opt := range strings.FieldsFunc(txts[0], func(r rune) bool {
+			return r == '&'
+		}) {
+			key := strings.SplitN(opt, "=", 2)[0]
+			if !mongoSRVOptions[key] {
+				return "", errors.Errorf("unsupported option %s in TXT record of %s",
+					key, host)
+			}
+			if _, ok := u.option(key); !ok {
+				u.options = append(u.options, opt)
+			}
+		}
+	}
+
+	if _, ok := u.option("ssl"); !ok {
+		u.options = append(u.options, "ssl=true")
+	}
+
+	return u.String(), nil
+}
+
+// SetupMongoTLS creates TLS configuration of mongo connections.
+func SetupMongoTLS(c config.ConfigReader) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: c.GetBool(SettingDbSSLSkipVerify),
+	}
+
+	if path := c.GetString(SettingDbSSLCAFile); path != "" {
+		pem, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, errors.Wrap(err, SettingDbSSLCAFile)
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("%s: no certificates found in %s",
+				SettingDbSSLCAFile, path)
+		}
+	}
+
+	certFile := c.GetString(SettingDbSSLCertFile)
+	keyFile := c.GetString(SettingDbSSLKeyFile)
+	if certFile != "" || keyFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, errors.Wrap(err, SettingDbSSLCertFile)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
+
+// NewMongoDialInfo resolves connection string and applies credentials,
+// authentication, replica set and TLS settings on top of it.
+func NewMongoDialInfo(c config.ConfigReader, resolver MongoSRVResolver,
+	url string) (*mgo.DialInfo, error) {
+
+	// Set 10s timeout - same as set by Dial
+	timeout := 10 * time.Second
+
+	if strings.HasPrefix(url, mongoSRVScheme) {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+
+		resolved, err := ResolveMongoSRV(ctx, resolver, url)
+		if err != nil {
+			return nil, err
+		}
+		url = resolved
+	}
+
+	dialInfo, err := mgo.ParseURL(url)
+	if err != nil {
+		return nil, err
+	}
+	dialInfo.Timeout = timeout
+
+	if username := c.GetString(SettingDbUsername); username != "" {
+		dialInfo.Username = username
+	}
+	if password := c.GetString(SettingDbPassword); password != "" {
+		dialInfo.Password = password
+	}
+	if mechanism := c.GetString(SettingDbAuthMechanism); mechanism != "" {
+		dialInfo.Mechanism = mechanism
+	}
+	if source := c.GetString(SettingDbAuthSource); source != "" {
+		dialInfo.Source = source
+	}
+	if dialInfo.ReplicaSetName == "" {
+		dialInfo.ReplicaSetName = c.GetString(SettingDbReplicaSet)
+	}
+
+	// TLS is enabled either by the setting or by the connection string
+	if c.GetBool(SettingDbSSL) || dialInfo.DialServer != nil {
+		tlsConfig, err := SetupMongoTLS(c)
+		if err != nil {
+			return nil, err
+		}
+		dialInfo.DialServer = func(addr *mgo.ServerAddr) (net.Conn, error) {
+			return tls.Dial("tcp", addr.String(), tlsConfig)
+		}
+	}
+
+	return dialInfo, nil
+}
+
+// mongoX509Credential returns credential of the client certificate, for
+// X.509 authentication without explicit username.
+func mongoX509Credential(c config.ConfigReader,
+	dialInfo *mgo.DialInfo) (*mgo.Credential, error) {
+
+	if dialInfo.Mechanism != MongoAuthX509 || dialInfo.Username != "" {
+		return nil, nil
+	}
+
+	tlsConfig, err := SetupMongoTLS(c)
+	if err != nil {
+		return nil, err
+	}
+	if len(tlsConfig.Certificates) == 0 {
+		return nil, fmt.Errorf("%s: client certificate is required by %s",
+			SettingDbAuthMechanism, MongoAuthX509)
+	}
+	cert, err := x509.ParseCertificate(tlsConfig.Certificates[0].Certificate[0])
+	if err != nil {
+		return nil, errors.Wrap(err, SettingDbSSLCertFile)
+	}
+
+	return &mgo.Credential{Certificate: cert}, nil
+}
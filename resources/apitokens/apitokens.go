@@ -0,0 +1,207 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package apitokens
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Scopes of operations a token can be used for
+const (
+	ScopeArtifactsUpload   = "artifacts:upload"
+	ScopeDeploymentsCreate = "deployments:create"
+)
+
+var (
+	ValidScopes = []string{ScopeArtifactsUpload, ScopeDeploymentsCreate}
+)
+
+// TokenPrefix starts values of API tokens, telling them apart from JWTs
+// in the Authorization header.
+const TokenPrefix = "mat_"
+
+const (
+	// Longest token name
+	MaxNameLength = 128
+	// Number of random bytes of the token secret
+	secretLength = 32
+)
+
+// Errors
+var (
+	ErrNameRequired   = errors.New("name is required")
+	ErrNameTooLong    = errors.New("name too long")
+	ErrScopesRequired = errors.New("at least one scope is required")
+	ErrInvalidScope   = errors.New("unsupported scope")
+	ErrExpiresInPast  = errors.New("expiry has to be in the future")
+	ErrTokenMalformed = errors.New("malformed API token")
+)
+
+// APIToken is a long-lived token of the tenant, usable for the operations
+// of its scopes only, e.g. by CI systems. Only hash of the secret is kept.
+type APIToken struct {
+	ID       string `json:"id" bson:"_id"`
+	TenantID string `json:"-" bson:"tenant_id"`
+
+	Name   string   `json:"name" bson:"name"`
+	Scopes []string `json:"scopes" bson:"scopes"`
+
+	// Subject of the user who created the token
+	CreatedBy string    `json:"created_by,omitempty" bson:"created_by,omitempty"`
+	Created   time.Time `json:"created" bson:"created"`
+
+	// Token is not valid after the time, never expires if not set
+	ExpiresAt *time.Time `json:"expires_at,omitempty" bson:"expires_at,omitempty"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty" bson:"revoked_at,omitempty"`
+
+	SecretHash string `json:"-" bson:"secret_hash"`
+}
+
+// NewAPIToken is the request to create a token.
+type NewAPIToken struct {
+	Name      string     `json:"name"`
+	Scopes    []string   `json:"scopes"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// Validate checks the request as of the time.
+func (n *NewAPIToken) Validate(now time.Time) error {
+	if strings.TrimSpace(n.Name) == "" {
+		return ErrNameRequired
+	}
+	if len(n.Name) > MaxNameLength {
+		return ErrNameTooLong
+	}
+	if len(n.Scopes) == 0 {
+		return ErrScopesRequired
+	}
+	for _, scope := range n.Scopes {
+		if !IsValidScope(scope) {
+			return errors.Wrapf(ErrInvalidScope, "%q", scope)
+		}
+	}
+	if n.ExpiresAt != nil && !n.ExpiresAt.After(now) {
+		return ErrExpiresInPast
+	}
+	return nil
+}
+
+// IsValidScope checks if tokens can have the scope.
+func IsValidScope(scope string) bool {
+	for _, s := range ValidScopes {
+		if scope == s {
+			return true
+		}
+	}
+	return false
+}
+
+// HasScope returns true if the token can be used for the scope.
+func (t *APIToken) HasScope(scope string) bool {
+	for _, s := range t.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// IsActive returns true if the token is neither revoked nor expired at
+// the time.
+func (t *APIToken) IsActive(now time.Time) bool {
+	if t.RevokedAt != nil {
+		return false
+	}
+	return t.ExpiresAt == nil || now.Before(*t.ExpiresAt)
+}
+
+// MatchesSecret compares the secret with the hash kept in the token.
+func (t *APIToken) MatchesSecret(secret string) bool {
+	return subtle.ConstantTimeCompare([]byte(HashSecret(secret)),
+		[]byte(t.SecretHash)) == 1
+}
+
+// NewSecret returns a random token secret.
+func NewSecret() (string, error) {
+	b := make([]byte, secretLength)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// HashSecret returns the hash of the secret kept in the storage.
+func HashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// FormatToken returns the value of the token handed out to the user.
+func FormatToken(id, secret string) string {
+	return TokenPrefix + id + "_" + secret
+}
+
+// ParseToken splits the value of the token into the token ID and secret.
+func ParseToken(token string) (id, secret string, err error) {
+	if !strings.HasPrefix(token, TokenPrefix) {
+		return "", "", ErrTokenMalformed
+	}
+	parts := strings.SplitN(strings.TrimPrefix(token, TokenPrefix), "_", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", ErrTokenMalformed
+	}
+	return parts[0], parts[1], nil
+}
+
+// FromRequest returns the API token of the bearer Authorization header of
+// the request, empty if the request does not carry an API token.
+func FromRequest(r *http.Request) string {
+	auth := strings.SplitN(r.Header.Get("Authorization"), " ", 2)
+	if len(auth) != 2 || !strings.EqualFold(auth[0], "Bearer") {
+		return ""
+	}
+	token := strings.TrimSpace(auth[1])
+	if !strings.HasPrefix(token, TokenPrefix) {
+		return ""
+	}
+	return token
+}
+
+// ScopeRule allows requests of the method to the path with tokens of the
+// scope.
+type ScopeRule struct {
+	Method string
+	Path   string
+	Scope  string
+}
+
+// ScopeOf returns the scope a token needs for the request, empty if
+// tokens cannot be used for it.
+func ScopeOf(rules []ScopeRule, method, path string) string {
+	for _, rule := range rules {
+		if rule.Method == method && rule.Path == path {
+			return rule.Scope
+		}
+	}
+	return ""
+}
@@ -0,0 +1,108 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package controller
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/ant0ine/go-json-rest/rest"
+	"github.com/mendersoftware/go-lib-micro/requestlog"
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/deployments/resources/apitokens"
+	"github.com/mendersoftware/deployments/utils/restutil"
+)
+
+type APITokensController struct {
+	view  RESTView
+	model APITokensModel
+}
+
+func NewAPITokensController(model APITokensModel, view RESTView) *APITokensController {
+	return &APITokensController{
+		model: model,
+		view:  view,
+	}
+}
+
+// createdToken is the created token along with its value, which is
+// rendered only once.
+type createdToken struct {
+	*apitokens.APIToken
+	Token string `json:"token"`
+}
+
+// CreateToken creates an API token of the tenant for the scopes of the
+// request.
+func (c *APITokensController) CreateToken(w rest.ResponseWriter, r *rest.Request) {
+	l := requestlog.GetRequestLogger(r)
+
+	var req apitokens.NewAPIToken
+	if err := restutil.DecodeJSONPayload(r, &req); err != nil {
+		c.view.RenderError(w, r, errors.Wrap(err, "Validating request body"),
+			http.StatusBadRequest, l)
+		return
+	}
+	if err := req.Validate(time.Now()); err != nil {
+		c.view.RenderError(w, r, errors.Wrap(err, "Validating request body"),
+			http.StatusBadRequest, l)
+		return
+	}
+
+	token, value, err := c.model.CreateToken(r.Context(), &req)
+	switch {
+	case err == ErrTooManyAPITokens:
+		c.view.RenderError(w, r, err, http.StatusUnprocessableEntity, l)
+		return
+	case err != nil:
+		c.view.RenderInternalError(w, r, err, l)
+		return
+	}
+
+	l.Infof("API token %s created with scopes %v", token.ID, token.Scopes)
+	c.view.RenderSuccessPostObject(w, r, token.ID, &createdToken{
+		APIToken: token,
+		Token:    value,
+	})
+}
+
+// ListTokens lists API tokens of the tenant, without their values.
+func (c *APITokensController) ListTokens(w rest.ResponseWriter, r *rest.Request) {
+	l := requestlog.GetRequestLogger(r)
+
+	list, err := c.model.ListTokens(r.Context())
+	if err != nil {
+		c.view.RenderInternalError(w, r, err, l)
+		return
+	}
+
+	c.view.RenderSuccessGet(w, list)
+}
+
+// RevokeToken revokes the API token, which cannot be used since.
+func (c *APITokensController) RevokeToken(w rest.ResponseWriter, r *rest.Request) {
+	l := requestlog.GetRequestLogger(r)
+
+	switch err := c.model.RevokeToken(r.Context(), r.PathParam("id")); err {
+	case nil:
+		l.Infof("API token %s revoked", r.PathParam("id"))
+		c.view.RenderSuccessDelete(w)
+	case ErrAPITokenNotFound:
+		c.view.RenderError(w, r, err, http.StatusNotFound, l)
+	default:
+		c.view.RenderInternalError(w, r, err, l)
+	}
+}
@@ -0,0 +1,314 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package controller_test
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/ant0ine/go-json-rest/rest"
+	"github.com/ant0ine/go-json-rest/rest/test"
+	"github.com/mendersoftware/go-lib-micro/identity"
+	"github.com/mendersoftware/go-lib-micro/requestid"
+	"github.com/mendersoftware/go-lib-micro/requestlog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/mendersoftware/deployments/resources/apitokens"
+	. "github.com/mendersoftware/deployments/resources/apitokens/controller"
+	"github.com/mendersoftware/deployments/resources/apitokens/controller/mocks"
+	"github.com/mendersoftware/deployments/utils/restutil/view"
+)
+
+func contextMatcher() interface{} {
+	return mock.MatchedBy(func(_ context.Context) bool {
+		return true
+	})
+}
+
+func setUpRestTest(routes ...*rest.Route) *rest.Api {
+	router, _ := rest.MakeRouter(routes...)
+	api := rest.NewApi()
+	api.Use(
+		&requestlog.RequestLogMiddleware{
+			BaseLogger: &logrus.Logger{Out: ioutil.Discard},
+		},
+		&requestid.RequestIdMiddleware{},
+	)
+	api.SetApp(router)
+
+	return api
+}
+
+func TestCreateToken(t *testing.T) {
+	created := time.Date(2019, 3, 1, 12, 0, 0, 0, time.UTC)
+	token := &apitokens.APIToken{
+		ID:         "1",
+		TenantID:   "foo",
+		Name:       "ci",
+		Scopes:     []string{apitokens.ScopeArtifactsUpload},
+		CreatedBy:  "user-1",
+		Created:    created,
+		SecretHash: "hash",
+	}
+
+	testCases := map[string]struct {
+		body  interface{}
+		req   *apitokens.NewAPIToken
+		token *apitokens.APIToken
+		err   error
+
+		code  int
+		body2 string
+		error string
+	}{
+		"ok": {
+			body: map[string]interface{}{
+				"name":   "ci",
+				"scopes": []string{apitokens.ScopeArtifactsUpload},
+			},
+			req: &apitokens.NewAPIToken{
+				Name:   "ci",
+				Scopes: []string{apitokens.ScopeArtifactsUpload},
+			},
+			token: token,
+			code:  http.StatusCreated,
+			body2: `{"id":"1","name":"ci","scopes":["artifacts:upload"],` +
+				`"created_by":"user-1","created":"2019-03-01T12:00:00Z",` +
+				`"token":"mat_1_secret"}`,
+		},
+		"empty body": {
+			code:  http.StatusBadRequest,
+			error: "Validating request body: JSON payload is empty",
+		},
+		"invalid scope": {
+			body: map[string]interface{}{
+				"name":   "ci",
+				"scopes": []string{"admin"},
+			},
+			code:  http.StatusBadRequest,
+			error: `Validating request body: \"admin\": unsupported scope`,
+		},
+		"too many": {
+			body: map[string]interface{}{
+				"name":   "ci",
+				"scopes": []string{apitokens.ScopeDeploymentsCreate},
+			},
+			req: &apitokens.NewAPIToken{
+				Name:   "ci",
+				Scopes: []string{apitokens.ScopeDeploymentsCreate},
+			},
+			err:   ErrTooManyAPITokens,
+			code:  http.StatusUnprocessableEntity,
+			error: ErrTooManyAPITokens.Error(),
+		},
+		"model error": {
+			body: map[string]interface{}{
+				"name":   "ci",
+				"scopes": []string{apitokens.ScopeDeploymentsCreate},
+			},
+			req: &apitokens.NewAPIToken{
+				Name:   "ci",
+				Scopes: []string{apitokens.ScopeDeploymentsCreate},
+			},
+			err:  errors.New("db failed"),
+			code: http.StatusInternalServerError,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			model := &mocks.APITokensModel{}
+			if tc.req != nil {
+				value := ""
+				if tc.token != nil {
+					value = "mat_1_secret"
+				}
+				model.On("CreateToken", contextMatcher(), tc.req).
+					Return(tc.token, value, tc.err)
+			}
+
+			controller := NewAPITokensController(model, new(view.RESTView))
+			api := setUpRestTest(rest.Post(
+				"/api/management/v1/deployments/api-tokens", controller.CreateToken))
+
+			recorded := test.RunRequest(t, api.MakeHandler(),
+				test.MakeSimpleRequest("POST",
+					"http://localhost/api/management/v1/deployments/api-tokens", tc.body))
+			recorded.CodeIs(tc.code)
+			if tc.body2 != "" {
+				assert.JSONEq(t, tc.body2, recorded.Recorder.Body.String())
+				assert.Equal(t, "./management/v1/deployments/api-tokens/1", recorded.Recorder.Header().Get("Location"))
+			}
+			if tc.error != "" {
+				assert.Contains(t, recorded.Recorder.Body.String(), tc.error)
+			}
+
+			model.AssertExpectations(t)
+		})
+	}
+}
+
+func TestListTokens(t *testing.T) {
+	revoked := time.Date(2019, 3, 2, 12, 0, 0, 0, time.UTC)
+
+	model := &mocks.APITokensModel{}
+	model.On("ListTokens", contextMatcher()).Return([]apitokens.APIToken{{
+		ID:         "1",
+		Name:       "ci",
+		Scopes:     []string{apitokens.ScopeDeploymentsCreate},
+		Created:    time.Date(2019, 3, 1, 12, 0, 0, 0, time.UTC),
+		RevokedAt:  &revoked,
+		SecretHash: "hash",
+	}}, nil).Once()
+	model.On("ListTokens", contextMatcher()).Return(nil, errors.New("db failed")).Once()
+
+	controller := NewAPITokensController(model, new(view.RESTView))
+	api := setUpRestTest(rest.Get("/api-tokens", controller.ListTokens))
+
+	recorded := test.RunRequest(t, api.MakeHandler(),
+		test.MakeSimpleRequest("GET", "http://localhost/api-tokens", nil))
+	recorded.CodeIs(http.StatusOK)
+	assert.JSONEq(t, `[{"id":"1","name":"ci","scopes":["deployments:create"],`+
+		`"created":"2019-03-01T12:00:00Z","revoked_at":"2019-03-02T12:00:00Z"}]`,
+		recorded.Recorder.Body.String())
+
+	recorded = test.RunRequest(t, api.MakeHandler(),
+		test.MakeSimpleRequest("GET", "http://localhost/api-tokens", nil))
+	recorded.CodeIs(http.StatusInternalServerError)
+
+	model.AssertExpectations(t)
+}
+
+func TestRevokeToken(t *testing.T) {
+	model := &mocks.APITokensModel{}
+	model.On("RevokeToken", contextMatcher(), "1").Return(nil)
+	model.On("RevokeToken", contextMatcher(), "2").Return(ErrAPITokenNotFound)
+	model.On("RevokeToken", contextMatcher(), "3").Return(errors.New("db failed"))
+
+	controller := NewAPITokensController(model, new(view.RESTView))
+	api := setUpRestTest(rest.Delete("/api-tokens/:id", controller.RevokeToken))
+
+	for id, code := range map[string]int{
+		"1": http.StatusNoContent,
+		"2": http.StatusNotFound,
+		"3": http.StatusInternalServerError,
+	} {
+		recorded := test.RunRequest(t, api.MakeHandler(),
+			test.MakeSimpleRequest("DELETE", "http://localhost/api-tokens/"+id, nil))
+		recorded.CodeIs(code)
+	}
+
+	model.AssertExpectations(t)
+}
+
+func TestAPITokenMiddleware(t *testing.T) {
+	token := &apitokens.APIToken{
+		ID:       "1",
+		TenantID: "foo",
+		Scopes:   []string{apitokens.ScopeArtifactsUpload},
+	}
+
+	testCases := map[string]struct {
+		method string
+		auth   string
+		token  *apitokens.APIToken
+		err    error
+
+		code   int
+		tenant string
+	}{
+		"no API token": {
+			method: http.MethodPost,
+			auth:   "Bearer eyJhbGciOiJSUzI1NiJ9.e30.sig",
+			code:   http.StatusNoContent,
+		},
+		"in scope": {
+			method: http.MethodPost,
+			auth:   "Bearer mat_1_secret",
+			token:  token,
+			code:   http.StatusNoContent,
+			tenant: "foo",
+		},
+		"out of scope": {
+			method: http.MethodGet,
+			auth:   "Bearer mat_1_secret",
+			token:  token,
+			code:   http.StatusForbidden,
+		},
+		"invalid": {
+			method: http.MethodPost,
+			auth:   "Bearer mat_1_secret",
+			err:    ErrAPITokenInvalid,
+			code:   http.StatusUnauthorized,
+		},
+		"model error": {
+			method: http.MethodPost,
+			auth:   "Bearer mat_1_secret",
+			err:    errors.New("db failed"),
+			code:   http.StatusInternalServerError,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			model := &mocks.APITokensModel{}
+			if tc.token != nil || tc.err != nil {
+				model.On("Authenticate", contextMatcher(), "mat_1_secret").
+					Return(tc.token, tc.err)
+			}
+
+			var tenant string
+			handler := func(w rest.ResponseWriter, r *rest.Request) {
+				if id := identity.FromContext(r.Context()); id != nil {
+					tenant = id.Tenant
+				}
+				w.WriteHeader(http.StatusNoContent)
+			}
+			router, _ := rest.MakeRouter(
+				rest.Get("/artifacts", handler),
+				rest.Post("/artifacts", handler),
+			)
+			api := rest.NewApi()
+			api.Use(
+				&requestlog.RequestLogMiddleware{
+					BaseLogger: &logrus.Logger{Out: ioutil.Discard},
+				},
+				&APITokenMiddleware{
+					Model: model,
+					Rules: []apitokens.ScopeRule{{
+						Method: http.MethodPost,
+						Path:   "/artifacts",
+						Scope:  apitokens.ScopeArtifactsUpload,
+					}},
+				},
+			)
+			api.SetApp(router)
+
+			req := test.MakeSimpleRequest(tc.method, "http://localhost/artifacts", nil)
+			req.Header.Set("Authorization", tc.auth)
+			recorded := test.RunRequest(t, api.MakeHandler(), req)
+			recorded.CodeIs(tc.code)
+			assert.Equal(t, tc.tenant, tenant)
+
+			model.AssertExpectations(t)
+		})
+	}
+}
@@ -0,0 +1,40 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package controller
+
+import (
+	"context"
+	"errors"
+
+	"github.com/mendersoftware/deployments/resources/apitokens"
+)
+
+// Errors expected from the model
+var (
+	ErrAPITokenNotFound   = errors.New("API token not found")
+	ErrAPITokenInvalid    = errors.New("invalid, expired or revoked API token")
+	ErrTooManyAPITokens   = errors.New("too many API tokens")
+	ErrAPITokenOutOfScope = errors.New("API token not valid for this operation")
+)
+
+type APITokensModel interface {
+	// CreateToken creates a token of the tenant of the request, returning
+	// it with the token value, which is not available later.
+	CreateToken(ctx context.Context, req *apitokens.NewAPIToken) (*apitokens.APIToken, string, error)
+	ListTokens(ctx context.Context) ([]apitokens.APIToken, error)
+	RevokeToken(ctx context.Context, id string) error
+	// Authenticate returns the active token of the value.
+	Authenticate(ctx context.Context, token string) (*apitokens.APIToken, error)
+}
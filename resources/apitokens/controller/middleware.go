@@ -0,0 +1,79 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package controller
+
+import (
+	"net/http"
+
+	"github.com/ant0ine/go-json-rest/rest"
+	"github.com/mendersoftware/go-lib-micro/identity"
+	"github.com/mendersoftware/go-lib-micro/log"
+	"github.com/mendersoftware/go-lib-micro/rest_utils"
+
+	"github.com/mendersoftware/deployments/resources/apitokens"
+)
+
+// APITokenMiddleware authenticates requests carrying an API token in place
+// of a JWT and sets the identity of the token tenant. Such requests are
+// allowed only if matching a rule of one of the token scopes.
+// Requests without API token are passed on unchanged.
+// Has to be used after the identity middleware.
+type APITokenMiddleware struct {
+	Model APITokensModel
+	Rules []apitokens.ScopeRule
+}
+
+func (mw *APITokenMiddleware) MiddlewareFunc(h rest.HandlerFunc) rest.HandlerFunc {
+	return func(w rest.ResponseWriter, r *rest.Request) {
+		value := apitokens.FromRequest(r.Request)
+		if value == "" {
+			h(w, r)
+			return
+		}
+
+		ctx := r.Context()
+		l := log.FromContext(ctx)
+
+		token, err := mw.Model.Authenticate(ctx, value)
+		switch {
+		case err == ErrAPITokenInvalid:
+			rest_utils.RestErrWithLog(w, r, l, err, http.StatusUnauthorized)
+			return
+		case err != nil:
+			rest_utils.RestErrWithLogInternal(w, r, l, err)
+			return
+		}
+
+		l = l.F(log.Ctx{"api_token_id": token.ID})
+		if token.TenantID != "" {
+			l = l.F(log.Ctx{"tenant_id": token.TenantID})
+		}
+
+		scope := apitokens.ScopeOf(mw.Rules, r.Method, r.URL.Path)
+		if scope == "" || !token.HasScope(scope) {
+			rest_utils.RestErrWithLog(w, r, l, ErrAPITokenOutOfScope, http.StatusForbidden)
+			return
+		}
+
+		ctx = log.WithContext(ctx, l)
+		ctx = identity.WithContext(ctx, &identity.Identity{
+			Subject: token.ID,
+			Tenant:  token.TenantID,
+		})
+		r.Request = r.WithContext(ctx)
+
+		h(w, r)
+	}
+}
@@ -0,0 +1,114 @@
+// Copyright 2018 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package mocks
+
+import apitokens "github.com/mendersoftware/deployments/resources/apitokens"
+import context "context"
+import mock "github.com/stretchr/testify/mock"
+
+// APITokensModel is an autogenerated mock type for the APITokensModel type
+type APITokensModel struct {
+	mock.Mock
+}
+
+// Authenticate provides a mock function with given fields: ctx, token
+func (_m *APITokensModel) Authenticate(ctx context.Context, token string) (*apitokens.APIToken, error) {
+	ret := _m.Called(ctx, token)
+
+	var r0 *apitokens.APIToken
+	if rf, ok := ret.Get(0).(func(context.Context, string) *apitokens.APIToken); ok {
+		r0 = rf(ctx, token)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*apitokens.APIToken)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, token)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// CreateToken provides a mock function with given fields: ctx, req
+func (_m *APITokensModel) CreateToken(ctx context.Context, req *apitokens.NewAPIToken) (*apitokens.APIToken, string, error) {
+	ret := _m.Called(ctx, req)
+
+	var r0 *apitokens.APIToken
+	if rf, ok := ret.Get(0).(func(context.Context, *apitokens.NewAPIToken) *apitokens.APIToken); ok {
+		r0 = rf(ctx, req)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*apitokens.APIToken)
+		}
+	}
+
+	var r1 string
+	if rf, ok := ret.Get(1).(func(context.Context, *apitokens.NewAPIToken) string); ok {
+		r1 = rf(ctx, req)
+	} else {
+		r1 = ret.Get(1).(string)
+	}
+
+	var r2 error
+	if rf, ok := ret.Get(2).(func(context.Context, *apitokens.NewAPIToken) error); ok {
+		r2 = rf(ctx, req)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
+// ListTokens provides a mock function with given fields: ctx
+func (_m *APITokensModel) ListTokens(ctx context.Context) ([]apitokens.APIToken, error) {
+	ret := _m.Called(ctx)
+
+	var r0 []apitokens.APIToken
+	if rf, ok := ret.Get(0).(func(context.Context) []apitokens.APIToken); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]apitokens.APIToken)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// RevokeToken provides a mock function with given fields: ctx, id
+func (_m *APITokensModel) RevokeToken(ctx context.Context, id string) error {
+	ret := _m.Called(ctx, id)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
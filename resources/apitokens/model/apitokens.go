@@ -0,0 +1,140 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"context"
+	"time"
+
+	"github.com/mendersoftware/go-lib-micro/identity"
+	"github.com/pkg/errors"
+	"github.com/satori/go.uuid"
+
+	"github.com/mendersoftware/deployments/resources/apitokens"
+	"github.com/mendersoftware/deployments/resources/apitokens/controller"
+)
+
+// Most tokens a tenant can have, revoked tokens not counted
+const MaxTokensPerTenant = 50
+
+// APITokensModel manages API tokens of tenants and authenticates requests
+// carrying them.
+type APITokensModel struct {
+	storage APITokensStorage
+	now     func() time.Time
+}
+
+func NewAPITokensModel(storage APITokensStorage) *APITokensModel {
+	return &APITokensModel{
+		storage: storage,
+		now:     time.Now,
+	}
+}
+
+// CreateToken creates a token of the tenant of the request for the scopes
+// of the validated request. The token value is returned only here, the
+// storage keeps a hash of its secret.
+func (m *APITokensModel) CreateToken(ctx context.Context,
+	req *apitokens.NewAPIToken) (*apitokens.APIToken, string, error) {
+
+	var tenantID, createdBy string
+	if id := identity.FromContext(ctx); id != nil {
+		tenantID = id.Tenant
+		createdBy = id.Subject
+	}
+
+	count, err := m.storage.CountActiveTokens(ctx, tenantID)
+	if err != nil {
+		return nil, "", errors.Wrap(err, "counting API tokens")
+	}
+	if count >= MaxTokensPerTenant {
+		return nil, "", controller.ErrTooManyAPITokens
+	}
+
+	secret, err := apitokens.NewSecret()
+	if err != nil {
+		return nil, "", errors.Wrap(err, "generating API token secret")
+	}
+
+	token := &apitokens.APIToken{
+		ID:         uuid.NewV4().String(),
+		TenantID:   tenantID,
+		Name:       req.Name,
+		Scopes:     req.Scopes,
+		CreatedBy:  createdBy,
+		Created:    m.now().UTC(),
+		ExpiresAt:  req.ExpiresAt,
+		SecretHash: apitokens.HashSecret(secret),
+	}
+	if err := m.storage.InsertToken(ctx, token); err != nil {
+		return nil, "", errors.Wrap(err, "saving API token")
+	}
+
+	return token, apitokens.FormatToken(token.ID, secret), nil
+}
+
+// ListTokens returns tokens of the tenant of the request, revoked
+// included.
+func (m *APITokensModel) ListTokens(ctx context.Context) ([]apitokens.APIToken, error) {
+	list, err := m.storage.FindTokens(ctx, tenantFromContext(ctx))
+	if err != nil {
+		return nil, errors.Wrap(err, "searching for API tokens")
+	}
+
+	if list == nil {
+		return make([]apitokens.APIToken, 0), nil
+	}
+
+	return list, nil
+}
+
+// RevokeToken revokes the token of the tenant of the request; revoked
+// tokens are kept for reference.
+func (m *APITokensModel) RevokeToken(ctx context.Context, id string) error {
+	ok, err := m.storage.RevokeToken(ctx, tenantFromContext(ctx), id, m.now().UTC())
+	if err != nil {
+		return errors.Wrap(err, "revoking API token")
+	}
+	if !ok {
+		return controller.ErrAPITokenNotFound
+	}
+	return nil
+}
+
+// Authenticate returns the token of the value if it exists, the secret
+// matches, and it is neither revoked nor expired.
+func (m *APITokensModel) Authenticate(ctx context.Context, value string) (*apitokens.APIToken, error) {
+	id, secret, err := apitokens.ParseToken(value)
+	if err != nil {
+		return nil, controller.ErrAPITokenInvalid
+	}
+
+	token, err := m.storage.FindTokenByID(ctx, id)
+	if err != nil {
+		return nil, errors.Wrap(err, "searching for API token")
+	}
+	if token == nil || !token.MatchesSecret(secret) || !token.IsActive(m.now()) {
+		return nil, controller.ErrAPITokenInvalid
+	}
+
+	return token, nil
+}
+
+func tenantFromContext(ctx context.Context) string {
+	if id := identity.FromContext(ctx); id != nil {
+		return id.Tenant
+	}
+	return ""
+}
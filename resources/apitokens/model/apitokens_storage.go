@@ -0,0 +1,33 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"context"
+	"time"
+
+	"github.com/mendersoftware/deployments/resources/apitokens"
+)
+
+type APITokensStorage interface {
+	InsertToken(ctx context.Context, token *apitokens.APIToken) error
+	// FindTokenByID returns the token of any tenant, nil if not found.
+	FindTokenByID(ctx context.Context, id string) (*apitokens.APIToken, error)
+	FindTokens(ctx context.Context, tenantID string) ([]apitokens.APIToken, error)
+	CountActiveTokens(ctx context.Context, tenantID string) (int, error)
+	// RevokeToken returns false if the tenant has no such token not
+	// revoked yet.
+	RevokeToken(ctx context.Context, tenantID, id string, at time.Time) (bool, error)
+}
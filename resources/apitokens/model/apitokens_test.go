@@ -0,0 +1,200 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mendersoftware/go-lib-micro/identity"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/mendersoftware/deployments/resources/apitokens"
+	"github.com/mendersoftware/deployments/resources/apitokens/controller"
+	"github.com/mendersoftware/deployments/resources/apitokens/model/mocks"
+)
+
+func TestCreateToken(t *testing.T) {
+	now := time.Date(2019, 3, 1, 12, 0, 0, 0, time.UTC)
+	ctx := identity.WithContext(context.Background(),
+		&identity.Identity{Tenant: "foo", Subject: "user-1"})
+	req := &apitokens.NewAPIToken{
+		Name:   "ci",
+		Scopes: []string{apitokens.ScopeArtifactsUpload},
+	}
+
+	storage := &mocks.APITokensStorage{}
+	m := NewAPITokensModel(storage)
+	m.now = func() time.Time { return now }
+
+	storage.On("CountActiveTokens", ctx, "foo").Return(MaxTokensPerTenant, nil).Once()
+	_, _, err := m.CreateToken(ctx, req)
+	assert.Equal(t, controller.ErrTooManyAPITokens, err)
+
+	storage.On("CountActiveTokens", ctx, "foo").Return(0, errors.New("db failed")).Once()
+	_, _, err = m.CreateToken(ctx, req)
+	assert.EqualError(t, err, "counting API tokens: db failed")
+
+	var saved *apitokens.APIToken
+	storage.On("CountActiveTokens", ctx, "foo").Return(1, nil).Once()
+	storage.On("InsertToken", ctx, mock.AnythingOfType("*apitokens.APIToken")).
+		Run(func(args mock.Arguments) {
+			saved = args.Get(1).(*apitokens.APIToken)
+		}).Return(nil).Once()
+	token, value, err := m.CreateToken(ctx, req)
+	assert.NoError(t, err)
+	assert.Equal(t, saved, token)
+	assert.Equal(t, "foo", token.TenantID)
+	assert.Equal(t, "user-1", token.CreatedBy)
+	assert.Equal(t, "ci", token.Name)
+	assert.Equal(t, req.Scopes, token.Scopes)
+	assert.Equal(t, now, token.Created)
+
+	// the value is not stored, only hash of its secret
+	id, secret, err := apitokens.ParseToken(value)
+	assert.NoError(t, err)
+	assert.Equal(t, token.ID, id)
+	assert.NotContains(t, token.SecretHash, secret)
+	assert.True(t, token.MatchesSecret(secret))
+
+	storage.On("CountActiveTokens", ctx, "foo").Return(1, nil).Once()
+	storage.On("InsertToken", ctx, mock.AnythingOfType("*apitokens.APIToken")).
+		Return(errors.New("db failed")).Once()
+	_, _, err = m.CreateToken(ctx, req)
+	assert.EqualError(t, err, "saving API token: db failed")
+
+	storage.AssertExpectations(t)
+}
+
+func TestListAndRevokeTokens(t *testing.T) {
+	now := time.Date(2019, 3, 1, 12, 0, 0, 0, time.UTC)
+	ctx := identity.WithContext(context.Background(), &identity.Identity{Tenant: "foo"})
+
+	storage := &mocks.APITokensStorage{}
+	m := NewAPITokensModel(storage)
+	m.now = func() time.Time { return now }
+
+	storage.On("FindTokens", ctx, "foo").Return(nil, nil).Once()
+	list, err := m.ListTokens(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, []apitokens.APIToken{}, list)
+
+	storage.On("FindTokens", ctx, "foo").Return(nil, errors.New("db failed")).Once()
+	_, err = m.ListTokens(ctx)
+	assert.EqualError(t, err, "searching for API tokens: db failed")
+
+	storage.On("RevokeToken", ctx, "foo", "1", now).Return(true, nil).Once()
+	assert.NoError(t, m.RevokeToken(ctx, "1"))
+
+	storage.On("RevokeToken", ctx, "foo", "2", now).Return(false, nil).Once()
+	assert.Equal(t, controller.ErrAPITokenNotFound, m.RevokeToken(ctx, "2"))
+
+	storage.On("RevokeToken", ctx, "foo", "3", now).Return(false, errors.New("db failed")).Once()
+	assert.EqualError(t, m.RevokeToken(ctx, "3"), "revoking API token: db failed")
+
+	storage.AssertExpectations(t)
+}
+
+func TestAuthenticate(t *testing.T) {
+	now := time.Date(2019, 3, 1, 12, 0, 0, 0, time.UTC)
+	expired := now.Add(-time.Minute)
+	ctx := context.Background()
+
+	secret, err := apitokens.NewSecret()
+	assert.NoError(t, err)
+	wrong := "0" + secret[1:]
+	if wrong == secret {
+		wrong = "1" + secret[1:]
+	}
+	token := &apitokens.APIToken{
+		ID:         "1",
+		TenantID:   "foo",
+		Scopes:     []string{apitokens.ScopeDeploymentsCreate},
+		SecretHash: apitokens.HashSecret(secret),
+	}
+
+	testCases := map[string]struct {
+		value string
+		token *apitokens.APIToken
+		err   error
+
+		outToken *apitokens.APIToken
+		outErr   string
+	}{
+		"ok": {
+			value:    apitokens.FormatToken("1", secret),
+			token:    token,
+			outToken: token,
+		},
+		"malformed": {
+			value:  "mat_1",
+			outErr: controller.ErrAPITokenInvalid.Error(),
+		},
+		"not found": {
+			value:  apitokens.FormatToken("1", secret),
+			outErr: controller.ErrAPITokenInvalid.Error(),
+		},
+		"wrong secret": {
+			value:  apitokens.FormatToken("1", wrong),
+			token:  token,
+			outErr: controller.ErrAPITokenInvalid.Error(),
+		},
+		"expired": {
+			value: apitokens.FormatToken("1", secret),
+			token: &apitokens.APIToken{
+				ID:         "1",
+				SecretHash: token.SecretHash,
+				ExpiresAt:  &expired,
+			},
+			outErr: controller.ErrAPITokenInvalid.Error(),
+		},
+		"revoked": {
+			value: apitokens.FormatToken("1", secret),
+			token: &apitokens.APIToken{
+				ID:         "1",
+				SecretHash: token.SecretHash,
+				RevokedAt:  &expired,
+			},
+			outErr: controller.ErrAPITokenInvalid.Error(),
+		},
+		"storage error": {
+			value:  apitokens.FormatToken("1", secret),
+			err:    errors.New("db failed"),
+			outErr: "searching for API token: db failed",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			storage := &mocks.APITokensStorage{}
+			m := NewAPITokensModel(storage)
+			m.now = func() time.Time { return now }
+
+			storage.On("FindTokenByID", ctx, "1").Return(tc.token, tc.err)
+
+			out, err := m.Authenticate(ctx, tc.value)
+			if tc.outErr != "" {
+				assert.EqualError(t, err, tc.outErr)
+				assert.Nil(t, out)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tc.outToken, out)
+			}
+		})
+	}
+}
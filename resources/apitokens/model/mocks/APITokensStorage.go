@@ -0,0 +1,127 @@
+// Copyright 2018 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package mocks
+
+import apitokens "github.com/mendersoftware/deployments/resources/apitokens"
+import context "context"
+import mock "github.com/stretchr/testify/mock"
+import time "time"
+
+// APITokensStorage is an autogenerated mock type for the APITokensStorage type
+type APITokensStorage struct {
+	mock.Mock
+}
+
+// CountActiveTokens provides a mock function with given fields: ctx, tenantID
+func (_m *APITokensStorage) CountActiveTokens(ctx context.Context, tenantID string) (int, error) {
+	ret := _m.Called(ctx, tenantID)
+
+	var r0 int
+	if rf, ok := ret.Get(0).(func(context.Context, string) int); ok {
+		r0 = rf(ctx, tenantID)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, tenantID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// FindTokenByID provides a mock function with given fields: ctx, id
+func (_m *APITokensStorage) FindTokenByID(ctx context.Context, id string) (*apitokens.APIToken, error) {
+	ret := _m.Called(ctx, id)
+
+	var r0 *apitokens.APIToken
+	if rf, ok := ret.Get(0).(func(context.Context, string) *apitokens.APIToken); ok {
+		r0 = rf(ctx, id)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*apitokens.APIToken)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// FindTokens provides a mock function with given fields: ctx, tenantID
+func (_m *APITokensStorage) FindTokens(ctx context.Context, tenantID string) ([]apitokens.APIToken, error) {
+	ret := _m.Called(ctx, tenantID)
+
+	var r0 []apitokens.APIToken
+	if rf, ok := ret.Get(0).(func(context.Context, string) []apitokens.APIToken); ok {
+		r0 = rf(ctx, tenantID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]apitokens.APIToken)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, tenantID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// InsertToken provides a mock function with given fields: ctx, token
+func (_m *APITokensStorage) InsertToken(ctx context.Context, token *apitokens.APIToken) error {
+	ret := _m.Called(ctx, token)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *apitokens.APIToken) error); ok {
+		r0 = rf(ctx, token)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// RevokeToken provides a mock function with given fields: ctx, tenantID, id, at
+func (_m *APITokensStorage) RevokeToken(ctx context.Context, tenantID string, id string, at time.Time) (bool, error) {
+	ret := _m.Called(ctx, tenantID, id, at)
+
+	var r0 bool
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, time.Time) bool); ok {
+		r0 = rf(ctx, tenantID, id, at)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string, string, time.Time) error); ok {
+		r1 = rf(ctx, tenantID, id, at)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
@@ -0,0 +1,152 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package mongo
+
+import (
+	"context"
+	"time"
+
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+
+	"github.com/mendersoftware/deployments/resources/apitokens"
+)
+
+// Database
+//
+// Tokens of all tenants are kept in the main database, as the tenant of
+// a request is known only after its token is found.
+const (
+	DatabaseName        = "deployment_service"
+	CollectionAPITokens = "api_tokens"
+)
+
+// Keys
+const (
+	StorageKeyAPITokenID        = "_id"
+	StorageKeyAPITokenTenant    = "tenant_id"
+	StorageKeyAPITokenCreated   = "created"
+	StorageKeyAPITokenRevokedAt = "revoked_at"
+)
+
+// Indexes
+const (
+	IndexAPITokenTenantStr = "apiTokenTenantIndex"
+)
+
+// APITokensStorage is a data layer for API tokens based on MongoDB
+// Implements model.APITokensStorage
+type APITokensStorage struct {
+	session *mgo.Session
+}
+
+func NewAPITokensStorage(session *mgo.Session) *APITokensStorage {
+	return &APITokensStorage{
+		session: session,
+	}
+}
+
+// Tokens are looked up by ID when authenticating, listed by tenant.
+func (s *APITokensStorage) ensureIndexing(session *mgo.Session) error {
+	return session.DB(DatabaseName).C(CollectionAPITokens).
+		EnsureIndex(mgo.Index{
+			Key:        []string{StorageKeyAPITokenTenant, StorageKeyAPITokenCreated},
+			Name:       IndexAPITokenTenantStr,
+			Background: true,
+		})
+}
+
+func (s *APITokensStorage) InsertToken(ctx context.Context, token *apitokens.APIToken) error {
+	session := s.session.Copy()
+	defer session.Close()
+
+	if err := s.ensureIndexing(session); err != nil {
+		return err
+	}
+
+	return session.DB(DatabaseName).C(CollectionAPITokens).Insert(token)
+}
+
+// FindTokenByID returns the token, nil if not found.
+func (s *APITokensStorage) FindTokenByID(ctx context.Context, id string) (*apitokens.APIToken, error) {
+	session := s.session.Copy()
+	defer session.Close()
+
+	var token apitokens.APIToken
+	if err := session.DB(DatabaseName).C(CollectionAPITokens).
+		FindId(id).One(&token); err != nil {
+		if err == mgo.ErrNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &token, nil
+}
+
+// FindTokens returns tokens of the tenant, oldest first.
+func (s *APITokensStorage) FindTokens(ctx context.Context, tenantID string) ([]apitokens.APIToken, error) {
+	session := s.session.Copy()
+	defer session.Close()
+
+	var list []apitokens.APIToken
+	if err := session.DB(DatabaseName).C(CollectionAPITokens).
+		Find(bson.M{StorageKeyAPITokenTenant: tenantID}).
+		Sort(StorageKeyAPITokenCreated, StorageKeyAPITokenID).
+		All(&list); err != nil {
+		return nil, err
+	}
+
+	return list, nil
+}
+
+// CountActiveTokens counts tokens of the tenant not revoked; expired
+// tokens are counted until revoked.
+func (s *APITokensStorage) CountActiveTokens(ctx context.Context, tenantID string) (int, error) {
+	session := s.session.Copy()
+	defer session.Close()
+
+	return session.DB(DatabaseName).C(CollectionAPITokens).
+		Find(bson.M{
+			StorageKeyAPITokenTenant:    tenantID,
+			StorageKeyAPITokenRevokedAt: bson.M{"$exists": false},
+		}).Count()
+}
+
+// RevokeToken marks the token of the tenant revoked at the time, returns
+// false if there is no such token not revoked yet.
+func (s *APITokensStorage) RevokeToken(ctx context.Context, tenantID, id string,
+	at time.Time) (bool, error) {
+
+	session := s.session.Copy()
+	defer session.Close()
+
+	err := session.DB(DatabaseName).C(CollectionAPITokens).Update(
+		bson.M{
+			StorageKeyAPITokenID:        id,
+			StorageKeyAPITokenTenant:    tenantID,
+			StorageKeyAPITokenRevokedAt: bson.M{"$exists": false},
+		},
+		bson.M{
+			"$set": bson.M{StorageKeyAPITokenRevokedAt: at},
+		})
+	if err == mgo.ErrNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,88 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package mongo
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mendersoftware/deployments/resources/apitokens"
+)
+
+func TestAPITokensStorage(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping TestAPITokensStorage in short mode.")
+	}
+
+	db.Wipe()
+	store := NewAPITokensStorage(db.Session())
+	ctx := context.Background()
+
+	now := time.Date(2019, 3, 1, 10, 0, 0, 0, time.UTC)
+	for _, token := range []*apitokens.APIToken{
+		{ID: "1", TenantID: "foo", Name: "ci", Created: now,
+			Scopes: []string{apitokens.ScopeArtifactsUpload}, SecretHash: "a"},
+		{ID: "2", TenantID: "foo", Name: "ci-2", Created: now.Add(time.Hour),
+			Scopes: []string{apitokens.ScopeDeploymentsCreate}, SecretHash: "b"},
+		{ID: "3", TenantID: "bar", Name: "ci", Created: now,
+			Scopes: []string{apitokens.ScopeArtifactsUpload}, SecretHash: "c"},
+	} {
+		assert.NoError(t, store.InsertToken(ctx, token))
+	}
+
+	token, err := store.FindTokenByID(ctx, "3")
+	assert.NoError(t, err)
+	if assert.NotNil(t, token) {
+		assert.Equal(t, "bar", token.TenantID)
+		assert.Equal(t, []string{apitokens.ScopeArtifactsUpload}, token.Scopes)
+		assert.Equal(t, "c", token.SecretHash)
+		assert.True(t, now.Equal(token.Created))
+	}
+
+	token, err = store.FindTokenByID(ctx, "4")
+	assert.NoError(t, err)
+	assert.Nil(t, token)
+
+	list, err := store.FindTokens(ctx, "foo")
+	assert.NoError(t, err)
+	if assert.Len(t, list, 2) {
+		assert.Equal(t, "1", list[0].ID)
+		assert.Equal(t, "2", list[1].ID)
+	}
+
+	// tokens are revoked within the tenant, once
+	ok, err := store.RevokeToken(ctx, "bar", "1", now)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+	ok, err = store.RevokeToken(ctx, "foo", "1", now)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	ok, err = store.RevokeToken(ctx, "foo", "1", now)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	token, err = store.FindTokenByID(ctx, "1")
+	assert.NoError(t, err)
+	if assert.NotNil(t, token) && assert.NotNil(t, token.RevokedAt) {
+		assert.True(t, now.Equal(*token.RevokedAt))
+	}
+
+	count, err := store.CountActiveTokens(ctx, "foo")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, count)
+}
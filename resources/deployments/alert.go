@@ -0,0 +1,160 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package deployments
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/satori/go.uuid"
+)
+
+// Alert conditions
+const (
+	// Failed devices exceed the threshold percentage of devices which
+	// finished the update
+	AlertConditionFailureRate = "failure_rate"
+	// Deployment is not finished
+	AlertConditionNotFinished = "not_finished"
+)
+
+// AlertMaxDuration limits the time in minutes an alert condition can be
+// required to hold for, 30 days.
+const AlertMaxDuration = 30 * 24 * 60
+
+// AlertConstructor represents input data needed for creating new Alert
+type AlertConstructor struct {
+	// Alert name, required
+	Name string `json:"name" bson:"name"`
+
+	// Condition checked on each active deployment, required
+	Condition string `json:"condition" bson:"condition"`
+
+	// Failure rate in percent the failure_rate condition holds above
+	Threshold float64 `json:"threshold,omitempty" bson:"threshold,omitempty"`
+
+	// Minutes the condition has to hold for before the alert fires;
+	// not_finished condition holds since the deployment was created
+	Duration int `json:"duration" bson:"duration"`
+}
+
+// Validate checks all fields and reports each invalid one.
+// Returned error is *ValidationError.
+func (c *AlertConstructor) Validate() error {
+	verr := &ValidationError{}
+
+	validateName(verr, "name", &c.Name)
+
+	switch c.Condition {
+	case AlertConditionFailureRate:
+		if c.Threshold < 0 || c.Threshold >= 100 {
+			verr.Add("threshold", ValidationCodeInvalid,
+				"threshold must be at least 0 and less than 100")
+		}
+	case AlertConditionNotFinished:
+		if c.Threshold != 0 {
+			verr.Add("threshold", ValidationCodeInvalid,
+				"threshold is not used by the condition")
+		}
+		if c.Duration == 0 {
+			verr.Add("duration", ValidationCodeRequired,
+				"duration is required by the condition")
+		}
+	case "":
+		verr.Add("condition", ValidationCodeRequired, "value is required")
+	default:
+		verr.Add("condition", ValidationCodeInvalid, fmt.Sprintf(
+			"expected one of: %s, %s", AlertConditionFailureRate, AlertConditionNotFinished))
+	}
+
+	if c.Duration < 0 || c.Duration > AlertMaxDuration {
+		verr.Add("duration", ValidationCodeInvalid,
+			fmt.Sprintf("duration must be between 0 and %d minutes", AlertMaxDuration))
+	}
+
+	return verr.ErrorOrNil()
+}
+
+// Alert is a rule checked on active deployments of the tenant, notifying
+// subscribers of deployment events once its condition holds on
+// a deployment for long enough.
+type Alert struct {
+	// User provided field set
+	*AlertConstructor
+
+	// Alert id
+	Id string `json:"id" bson:"_id"`
+
+	// Auto set on create
+	Created *time.Time `json:"created" bson:"created"`
+}
+
+// NewAlertFromConstructor creates new Alert object based on constructor data.
+func NewAlertFromConstructor(constructor *AlertConstructor) *Alert {
+	now := time.Now()
+
+	return &Alert{
+		AlertConstructor: constructor,
+		Id:               uuid.NewV4().String(),
+		Created:          &now,
+	}
+}
+
+// Validate checks structure of the alert.
+func (a *Alert) Validate() error {
+	if a.AlertConstructor == nil {
+		return NewValidationError("condition", ValidationCodeRequired, "value is required")
+	}
+	return a.AlertConstructor.Validate()
+}
+
+// Holds tells whether the condition of the alert holds for the active
+// deployment.
+func (a *Alert) Holds(d *Deployment) bool {
+	switch a.Condition {
+	case AlertConditionFailureRate:
+		p := NewDeploymentProgress(d)
+		finished := p.Failure + p.Success
+		return finished > 0 && float64(p.Failure)*100/float64(finished) > a.Threshold
+	case AlertConditionNotFinished:
+		return !d.IsFinished()
+	}
+	return false
+}
+
+// AlertState tracks the condition of an alert holding on a single active
+// deployment.
+type AlertState struct {
+	Id           string `bson:"_id"`
+	AlertID      string `bson:"alert_id"`
+	DeploymentID string `bson:"deployment_id"`
+
+	// Time the condition holds since
+	Since time.Time `bson:"since"`
+
+	// Time the alert fired, nil if not yet
+	Fired *time.Time `bson:"fired,omitempty"`
+}
+
+// NewAlertState creates state of the alert on the deployment, the condition
+// holding since the given time.
+func NewAlertState(alertID, deploymentID string, since time.Time) *AlertState {
+	return &AlertState{
+		Id:           alertID + ":" + deploymentID,
+		AlertID:      alertID,
+		DeploymentID: deploymentID,
+		Since:        since,
+	}
+}
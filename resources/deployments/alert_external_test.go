@@ -0,0 +1,182 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package deployments_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	. "github.com/mendersoftware/deployments/resources/deployments"
+)
+
+func TestAlertConstructorValidate(t *testing.T) {
+
+	t.Parallel()
+
+	testCases := map[string]struct {
+		InputConstructor AlertConstructor
+		OutputError      string
+	}{
+		"ok, failure rate": {
+			InputConstructor: AlertConstructor{
+				Name:      "failures",
+				Condition: AlertConditionFailureRate,
+				Threshold: 10,
+				Duration:  15,
+			},
+		},
+		"ok, any failure": {
+			InputConstructor: AlertConstructor{
+				Name:      "failures",
+				Condition: AlertConditionFailureRate,
+			},
+		},
+		"ok, not finished": {
+			InputConstructor: AlertConstructor{
+				Name:      "stalled",
+				Condition: AlertConditionNotFinished,
+				Duration:  24 * 60,
+			},
+		},
+		"missing name and condition": {
+			OutputError: "name: value is required;condition: value is required;",
+		},
+		"unknown condition": {
+			InputConstructor: AlertConstructor{
+				Name:      "failures",
+				Condition: "slow",
+			},
+			OutputError: "condition: expected one of: failure_rate, not_finished;",
+		},
+		"invalid threshold": {
+			InputConstructor: AlertConstructor{
+				Name:      "failures",
+				Condition: AlertConditionFailureRate,
+				Threshold: 100,
+			},
+			OutputError: "threshold: threshold must be at least 0 and less than 100;",
+		},
+		"threshold not used": {
+			InputConstructor: AlertConstructor{
+				Name:      "stalled",
+				Condition: AlertConditionNotFinished,
+				Threshold: 10,
+				Duration:  60,
+			},
+			OutputError: "threshold: threshold is not used by the condition;",
+		},
+		"missing duration": {
+			InputConstructor: AlertConstructor{
+				Name:      "stalled",
+				Condition: AlertConditionNotFinished,
+			},
+			OutputError: "duration: duration is required by the condition;",
+		},
+		"duration too long": {
+			InputConstructor: AlertConstructor{
+				Name:      "failures",
+				Condition: AlertConditionFailureRate,
+				Duration:  AlertMaxDuration + 1,
+			},
+			OutputError: "duration: duration must be between 0 and 43200 minutes;",
+		},
+	}
+
+	for name, test := range testCases {
+		err := test.InputConstructor.Validate()
+		if test.OutputError != "" {
+			assert.EqualError(t, err, test.OutputError, name)
+		} else {
+			assert.NoError(t, err, name)
+		}
+	}
+}
+
+func TestNewAlertFromConstructor(t *testing.T) {
+
+	t.Parallel()
+
+	alert := NewAlertFromConstructor(&AlertConstructor{
+		Name:      "failures",
+		Condition: AlertConditionFailureRate,
+		Threshold: 10,
+	})
+
+	assert.NotEmpty(t, alert.Id)
+	assert.NotNil(t, alert.Created)
+	assert.NoError(t, alert.Validate())
+
+	assert.Error(t, (&Alert{}).Validate())
+}
+
+func TestAlertHolds(t *testing.T) {
+
+	t.Parallel()
+
+	failureRate := &Alert{AlertConstructor: &AlertConstructor{
+		Condition: AlertConditionFailureRate,
+		Threshold: 10,
+	}}
+	notFinished := &Alert{AlertConstructor: &AlertConstructor{
+		Condition: AlertConditionNotFinished,
+		Duration:  60,
+	}}
+
+	testCases := map[string]struct {
+		Alert *Alert
+		Stats map[string]int
+
+		Holds bool
+	}{
+		"failure rate, no finished devices": {
+			Alert: failureRate,
+			Stats: map[string]int{DeviceDeploymentStatusPending: 10},
+		},
+		"failure rate, below threshold": {
+			Alert: failureRate,
+			Stats: map[string]int{
+				DeviceDeploymentStatusPending: 10,
+				DeviceDeploymentStatusFailure: 1,
+				DeviceDeploymentStatusSuccess: 9,
+			},
+		},
+		"failure rate, above threshold": {
+			Alert: failureRate,
+			Stats: map[string]int{
+				DeviceDeploymentStatusPending:     10,
+				DeviceDeploymentStatusFailure:     2,
+				DeviceDeploymentStatusSuccess:     7,
+				DeviceDeploymentStatusAlreadyInst: 1,
+			},
+			Holds: true,
+		},
+		"not finished": {
+			Alert: notFinished,
+			Stats: map[string]int{DeviceDeploymentStatusInstalling: 1},
+			Holds: true,
+		},
+		"finished": {
+			Alert: notFinished,
+			Stats: map[string]int{DeviceDeploymentStatusSuccess: 1},
+		},
+	}
+
+	for name, test := range testCases {
+		d := NewDeployment()
+		d.Stats = test.Stats
+		assert.Equal(t, test.Holds, test.Alert.Holds(d), name)
+	}
+}
@@ -0,0 +1,113 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package deployments
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/asaskevich/govalidator"
+	"github.com/satori/go.uuid"
+)
+
+// Campaign statuses, set by the user
+const (
+	CampaignStatusActive  = "active"
+	CampaignStatusPaused  = "paused"
+	CampaignStatusAborted = "aborted"
+)
+
+// CampaignMaxDeployments limits the number of deployments grouped
+// in a single campaign.
+const CampaignMaxDeployments = 100
+
+// CampaignConstructor represents input data needed for creating
+// new Campaign
+type CampaignConstructor struct {
+	// Campaign name, required
+	Name string `json:"name" bson:"name"`
+
+	// IDs of member deployments, required
+	Deployments []string `json:"deployments" bson:"deployments"`
+}
+
+// Validate checks all fields and reports each invalid one.
+// Returned error is *ValidationError.
+func (c *CampaignConstructor) Validate() error {
+	verr := &ValidationError{}
+
+	validateName(verr, "name", &c.Name)
+
+	switch {
+	case len(c.Deployments) == 0:
+		verr.Add("deployments", ValidationCodeRequired,
+			"at least one deployment is required")
+	case len(c.Deployments) > CampaignMaxDeployments:
+		verr.Add("deployments", ValidationCodeLength,
+			fmt.Sprintf("at most %d deployments are allowed", CampaignMaxDeployments))
+	}
+
+	seen := make(map[string]bool, len(c.Deployments))
+	for i, id := range c.Deployments {
+		field := fmt.Sprintf("deployments[%d]", i)
+		if !govalidator.IsUUIDv4(id) {
+			verr.Add(field, ValidationCodeInvalid, "invalid deployment ID")
+		} else if seen[id] {
+			verr.Add(field, ValidationCodeInvalid, "duplicate deployment ID")
+		}
+		seen[id] = true
+	}
+
+	return verr.ErrorOrNil()
+}
+
+// Campaign groups related deployments, e.g. the same release rolled out
+// to several regions, so that they can be monitored and controlled together.
+type Campaign struct {
+	// User provided field set
+	*CampaignConstructor
+
+	// Campaign id
+	Id string `json:"id" bson:"_id"`
+
+	// Auto set on create
+	Created *time.Time `json:"created" bson:"created"`
+
+	// Status set by the user; devices are not served deployments
+	// of paused campaigns
+	Status string `json:"status" bson:"status"`
+}
+
+// NewCampaignFromConstructor creates new active Campaign object based on
+// constructor data.
+func NewCampaignFromConstructor(constructor *CampaignConstructor) *Campaign {
+	now := time.Now()
+
+	return &Campaign{
+		CampaignConstructor: constructor,
+		Id:                  uuid.NewV4().String(),
+		Created:             &now,
+		Status:              CampaignStatusActive,
+	}
+}
+
+// Validate checks structure of the campaign.
+func (c *Campaign) Validate() error {
+	if c.CampaignConstructor == nil {
+		return NewValidationError("deployments", ValidationCodeRequired,
+			"at least one deployment is required")
+	}
+	return c.CampaignConstructor.Validate()
+}
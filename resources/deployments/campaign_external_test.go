@@ -0,0 +1,109 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package deployments_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	. "github.com/mendersoftware/deployments/resources/deployments"
+)
+
+func TestCampaignConstructorValidate(t *testing.T) {
+
+	t.Parallel()
+
+	id := "a3d2f4d1-b7b6-4ad4-8e4c-2d2f3c1b9d01"
+
+	tooMany := make([]string, CampaignMaxDeployments+1)
+	for i := range tooMany {
+		tooMany[i] = id
+	}
+
+	testCases := map[string]struct {
+		InputConstructor CampaignConstructor
+		OutputError      string
+	}{
+		"ok": {
+			InputConstructor: CampaignConstructor{
+				Name:        "release 1.2",
+				Deployments: []string{id},
+			},
+		},
+		"missing name": {
+			InputConstructor: CampaignConstructor{
+				Deployments: []string{id},
+			},
+			OutputError: "name: value is required;",
+		},
+		"missing deployments": {
+			InputConstructor: CampaignConstructor{
+				Name: "release 1.2",
+			},
+			OutputError: "deployments: at least one deployment is required;",
+		},
+		"invalid deployment ID": {
+			InputConstructor: CampaignConstructor{
+				Name:        "release 1.2",
+				Deployments: []string{id, "foo"},
+			},
+			OutputError: "deployments[1]: invalid deployment ID;",
+		},
+		"duplicate deployment ID": {
+			InputConstructor: CampaignConstructor{
+				Name:        "release 1.2",
+				Deployments: []string{id, id},
+			},
+			OutputError: "deployments[1]: duplicate deployment ID;",
+		},
+		"too many deployments": {
+			InputConstructor: CampaignConstructor{
+				Name:        "release 1.2",
+				Deployments: tooMany,
+			},
+			OutputError: "deployments: at most 100 deployments are allowed;",
+		},
+	}
+
+	for name, test := range testCases {
+		err := test.InputConstructor.Validate()
+		if test.OutputError != "" {
+			if assert.Error(t, err, name) {
+				assert.True(t, strings.HasPrefix(err.Error(), test.OutputError), name)
+			}
+		} else {
+			assert.NoError(t, err, name)
+		}
+	}
+}
+
+func TestNewCampaignFromConstructor(t *testing.T) {
+
+	t.Parallel()
+
+	campaign := NewCampaignFromConstructor(&CampaignConstructor{
+		Name:        "release 1.2",
+		Deployments: []string{"a3d2f4d1-b7b6-4ad4-8e4c-2d2f3c1b9d01"},
+	})
+
+	assert.NotEmpty(t, campaign.Id)
+	assert.NotNil(t, campaign.Created)
+	assert.Equal(t, CampaignStatusActive, campaign.Status)
+	assert.NoError(t, campaign.Validate())
+
+	assert.Error(t, (&Campaign{}).Validate())
+}
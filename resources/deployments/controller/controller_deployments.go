@@ -15,14 +15,19 @@
 package controller
 
 import (
+	"io"
 	"net/http"
 	"net/url"
+	"path"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/ant0ine/go-json-rest/rest"
 	"github.com/asaskevich/govalidator"
 	"github.com/mendersoftware/deployments/resources/deployments"
+	"github.com/mendersoftware/deployments/resources/limits"
+	"github.com/mendersoftware/deployments/utils/restutil"
 	"github.com/mendersoftware/go-lib-micro/identity"
 	"github.com/mendersoftware/go-lib-micro/log"
 	"github.com/mendersoftware/go-lib-micro/rest_utils"
@@ -38,46 +43,138 @@ var (
 	ErrUnexpectedDeploymentStatus = errors.New("Unexpected deployment status")
 	ErrMissingIdentity            = errors.New("Missing identity data")
 	ErrNoArtifact                 = errors.New("No artifact for the deployment")
+	ErrMissingSearchQuery         = errors.New("Missing search query")
+	ErrUnexpectedCampaignStatus   = errors.New("Unexpected campaign status")
 )
 
 type DeploymentsController struct {
-	view  RESTView
-	model DeploymentsModel
+	view   RESTView
+	model  DeploymentsModel
+	limits LimitsModel
+
+	streamInterval time.Duration
 }
 
 func NewDeploymentsController(model DeploymentsModel, view RESTView) *DeploymentsController {
 	return &DeploymentsController{
-		view:  view,
-		model: model,
+		view:           view,
+		model:          model,
+		streamInterval: DefaultStreamInterval,
 	}
 }
 
 func (d *DeploymentsController) PostDeployment(w rest.ResponseWriter, r *rest.Request) {
+	d.postDeployment(w, r, false)
+}
+
+// PostDeploymentOverrideFreeze creates deployment ignoring tenant freeze
+// periods. Having a separate endpoint allows the authorizer to grant
+// the override to selected users only.
+func (d *DeploymentsController) PostDeploymentOverrideFreeze(w rest.ResponseWriter, r *rest.Request) {
+	d.postDeployment(w, r, true)
+}
+
+func (d *DeploymentsController) postDeployment(w rest.ResponseWriter, r *rest.Request,
+	overrideFreeze bool) {
+
 	ctx := r.Context()
 	l := log.FromContext(ctx)
 
 	constructor, err := d.getDeploymentConstructorFromBody(r)
 	if err != nil {
-		d.view.RenderError(w, r, errors.Wrap(err, "Validating request body"), http.StatusBadRequest, l)
+		d.renderBodyError(w, r, errors.Wrap(err, "Validating request body"), l)
 		return
 	}
+	constructor.OverrideFreeze = overrideFreeze
+
+	if d.limits != nil {
+		if err := d.checkDeploymentsLimit(ctx); err != nil {
+			d.renderLimitError(w, r, err, http.StatusTooManyRequests, l)
+			return
+		}
+	}
 
 	id, err := d.model.CreateDeployment(ctx, constructor)
 	if err != nil {
 		if err == ErrNoArtifact {
+			field := "artifact_name"
+			if constructor.ArtifactID != "" {
+				field = "artifact_id"
+			}
+			d.view.RenderValidationError(w, r, err, []deployments.FieldError{{
+				Field:   field,
+				Code:    deployments.ValidationCodeNotFound,
+				Message: err.Error(),
+			}}, http.StatusUnprocessableEntity, l)
+		} else if verr, ok := errors.Cause(err).(*deployments.ValidationError); ok {
+			d.view.RenderValidationError(w, r, err, verr.Fields, http.StatusBadRequest, l)
+		} else if errors.Cause(err) == ErrPolicyRejected {
+			d.view.RenderError(w, r, err, http.StatusUnprocessableEntity, l)
+		} else if _, ok := errors.Cause(err).(*limits.ExceededError); ok {
 			d.view.RenderError(w, r, err, http.StatusUnprocessableEntity, l)
+		} else if errors.Cause(err) == ErrConflictingDeployment ||
+			errors.Cause(err) == ErrDeploymentFrozen ||
+			errors.Cause(err) == ErrDeploymentSlugTaken {
+			d.view.RenderError(w, r, err, http.StatusConflict, l)
 		} else {
 			d.view.RenderInternalError(w, r, err, l)
 		}
 		return
 	}
 
-	d.view.RenderSuccessPost(w, r, id)
+	if overrideFreeze {
+		// location of the deployment is within the deployments collection
+		r.URL.Path = path.Dir(r.URL.Path)
+	}
+
+	// deployment is created already, missing estimate is not an error
+	estimate, err := d.model.EstimateDeployment(ctx, id)
+	if err != nil {
+		l.Warnf("failed to estimate deployment %s: %v", id, err)
+	}
+	if estimate == nil {
+		d.view.RenderSuccessPost(w, r, id)
+		return
+	}
+	d.view.RenderSuccessPostObject(w, r, id, estimate)
+}
+
+// statusQuery returns query parameters of the request with the status
+// filter given by its display name translated to the status.
+func (d *DeploymentsController) statusQuery(r *rest.Request) url.Values {
+	vals := r.URL.Query()
+	if status := vals.Get("status"); status != "" {
+		vals.Set("status", d.view.InternalStatus(status))
+	}
+	return vals
+}
+
+// decodeBody decodes the request body into v; fields rejected by the strict
+// decoding are reported as validation errors.
+func decodeBody(r *rest.Request, v interface{}) error {
+	err := restutil.DecodeJSONPayload(r, v)
+	if ferr, ok := err.(*restutil.UnknownFieldError); ok {
+		return deployments.NewValidationError(ferr.Field,
+			deployments.ValidationCodeUnknown, ferr.Error())
+	}
+	return err
+}
+
+// renderBodyError renders error of an invalid request body, listing
+// the invalid fields in case of a validation error.
+func (d *DeploymentsController) renderBodyError(w rest.ResponseWriter, r *rest.Request,
+	err error, l *log.Logger) {
+
+	if verr, ok := errors.Cause(err).(*deployments.ValidationError); ok {
+		d.view.RenderValidationError(w, r, err, verr.Fields, http.StatusBadRequest, l)
+	} else {
+		d.view.RenderError(w, r, err, http.StatusBadRequest, l)
+	}
 }
 
 func (d *DeploymentsController) getDeploymentConstructorFromBody(r *rest.Request) (*deployments.DeploymentConstructor, error) {
 	var constructor *deployments.DeploymentConstructor
-	if err := r.DecodeJsonPayload(&constructor); err != nil {
+	if err := decodeBody(r, &constructor); err != nil {
 		return nil, err
 	}
 
@@ -138,6 +235,154 @@ func (d *DeploymentsController) GetDeploymentStats(w rest.ResponseWriter, r *res
 	d.view.RenderSuccessGet(w, stats)
 }
 
+func (d *DeploymentsController) GetDeploymentFailureStats(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+	l := log.FromContext(ctx)
+
+	id := r.PathParam("id")
+
+	if !govalidator.IsUUIDv4(id) {
+		d.view.RenderError(w, r, ErrIDNotUUIDv4, http.StatusBadRequest, l)
+		return
+	}
+
+	stats, err := d.model.GetDeploymentFailureStats(ctx, id)
+	if err != nil {
+		d.view.RenderInternalError(w, r, err, l)
+		return
+	}
+
+	if stats == nil {
+		d.view.RenderErrorNotFound(w, r, l)
+		return
+	}
+
+	d.view.RenderSuccessGet(w, stats)
+}
+
+func (d *DeploymentsController) GetDeploymentDurationStats(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+	l := log.FromContext(ctx)
+
+	id := r.PathParam("id")
+
+	if !govalidator.IsUUIDv4(id) {
+		d.view.RenderError(w, r, ErrIDNotUUIDv4, http.StatusBadRequest, l)
+		return
+	}
+
+	stats, err := d.model.GetDeploymentDurationStats(ctx, id)
+	if err != nil {
+		d.view.RenderInternalError(w, r, err, l)
+		return
+	}
+
+	if stats == nil {
+		d.view.RenderErrorNotFound(w, r, l)
+		return
+	}
+
+	d.view.RenderSuccessGet(w, stats)
+}
+
+func (d *DeploymentsController) GetDeploymentTelemetryStats(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+	l := log.FromContext(ctx)
+
+	id := r.PathParam("id")
+
+	if !govalidator.IsUUIDv4(id) {
+		d.view.RenderError(w, r, ErrIDNotUUIDv4, http.StatusBadRequest, l)
+		return
+	}
+
+	stats, err := d.model.GetDeploymentTelemetryStats(ctx, id)
+	if err != nil {
+		d.view.RenderInternalError(w, r, err, l)
+		return
+	}
+
+	if stats == nil {
+		d.view.RenderErrorNotFound(w, r, l)
+		return
+	}
+
+	d.view.RenderSuccessGet(w, stats)
+}
+
+func (d *DeploymentsController) GetDeploymentPhaseStats(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+	l := log.FromContext(ctx)
+
+	id := r.PathParam("id")
+
+	if !govalidator.IsUUIDv4(id) {
+		d.view.RenderError(w, r, ErrIDNotUUIDv4, http.StatusBadRequest, l)
+		return
+	}
+
+	stats, err := d.model.GetDeploymentPhaseStats(ctx, id)
+	if err != nil {
+		d.view.RenderInternalError(w, r, err, l)
+		return
+	}
+
+	if stats == nil {
+		d.view.RenderErrorNotFound(w, r, l)
+		return
+	}
+
+	d.view.RenderSuccessGet(w, stats)
+}
+
+func (d *DeploymentsController) GetStatsSummary(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+	l := log.FromContext(ctx)
+
+	summary, err := d.model.GetStatsSummary(ctx)
+	if err != nil {
+		d.view.RenderInternalError(w, r, err, l)
+		return
+	}
+
+	d.view.RenderSuccessGet(w, summary)
+}
+
+const (
+	GetDeploymentStatsByGroupQueryBy        = "by"
+	GetDeploymentStatsByGroupQueryByDefault = "group"
+)
+
+func (d *DeploymentsController) GetDeploymentStatsByGroup(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+	l := log.FromContext(ctx)
+
+	id := r.PathParam("id")
+
+	if !govalidator.IsUUIDv4(id) {
+		d.view.RenderError(w, r, ErrIDNotUUIDv4, http.StatusBadRequest, l)
+		return
+	}
+
+	attribute := r.URL.Query().Get(GetDeploymentStatsByGroupQueryBy)
+	if attribute == "" {
+		attribute = GetDeploymentStatsByGroupQueryByDefault
+	}
+
+	stats, err := d.model.GetDeploymentStatsByGroup(ctx, id, attribute)
+	if err != nil {
+		d.view.RenderInternalError(w, r, err, l)
+		return
+	}
+
+	if stats == nil {
+		d.view.RenderErrorNotFound(w, r, l)
+		return
+	}
+
+	d.view.RenderSuccessGet(w, stats)
+}
+
 func (d *DeploymentsController) AbortDeployment(w rest.ResponseWriter, r *rest.Request) {
 	ctx := r.Context()
 	l := log.FromContext(ctx)
@@ -152,34 +397,101 @@ func (d *DeploymentsController) AbortDeployment(w rest.ResponseWriter, r *rest.R
 	// receive request body
 	var status struct {
 		Status string
+		Reason string
 	}
 
-	err := r.DecodeJsonPayload(&status)
+	err := decodeBody(r, &status)
 	if err != nil {
-		d.view.RenderError(w, r, err, http.StatusBadRequest, l)
+		d.renderBodyError(w, r, err, l)
 		return
 	}
 	// "aborted" is the only supported status
 	if status.Status != deployments.DeviceDeploymentStatusAborted {
 		d.view.RenderError(w, r, ErrUnexpectedDeploymentStatus, http.StatusBadRequest, l)
+		return
+	}
+
+	abort := &deployments.AbortInfo{
+		Reason: status.Reason,
+	}
+	if _, err := govalidator.ValidateStruct(abort); err != nil {
+		d.view.RenderError(w, r, err, http.StatusBadRequest, l)
+		return
+	}
+	if idata := identity.FromContext(ctx); idata != nil {
+		abort.AbortedBy = idata.Subject
 	}
 
 	l.Infof("Abort deployment: %s", id)
 
-	// Check if deployment is finished
-	isDeploymentFinished, err := d.model.IsDeploymentFinished(ctx, id)
-	if err != nil {
-		d.view.RenderInternalError(w, r, err, l)
+	// Abort deployments for devices and update deployment stats
+	if err := d.model.AbortDeployment(ctx, id, abort); err != nil {
+		switch err {
+		case ErrModelDeploymentNotFound:
+			d.view.RenderError(w, r, err, http.StatusNotFound, l)
+		case ErrDeploymentAlreadyFinished:
+			d.view.RenderError(w, r, err, http.StatusUnprocessableEntity, l)
+		default:
+			d.view.RenderInternalError(w, r, err, l)
+		}
 		return
 	}
-	if isDeploymentFinished {
-		d.view.RenderError(w, r, ErrDeploymentAlreadyFinished, http.StatusUnprocessableEntity, l)
+
+	d.view.RenderEmptySuccessResponse(w)
+}
+
+// ForceFinishDeployment finishes the deployment without waiting for devices
+// which will never report; device deployments which did not finish yet get
+// the status from the request body, aborted or timed_out.
+func (d *DeploymentsController) ForceFinishDeployment(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+	l := log.FromContext(ctx)
+
+	id := r.PathParam("id")
+
+	if !govalidator.IsUUIDv4(id) {
+		d.view.RenderError(w, r, ErrIDNotUUIDv4, http.StatusBadRequest, l)
 		return
 	}
 
-	// Abort deployments for devices and update deployment stats
-	if err := d.model.AbortDeployment(ctx, id); err != nil {
-		d.view.RenderInternalError(w, r, err, l)
+	var status struct {
+		Status string
+		Reason string
+	}
+	if err := decodeBody(r, &status); err != nil {
+		d.renderBodyError(w, r, err, l)
+		return
+	}
+	switch status.Status {
+	case deployments.DeviceDeploymentStatusAborted, deployments.DeviceDeploymentStatusTimedOut:
+	default:
+		d.view.RenderError(w, r, ErrInvalidFinishStatus, http.StatusBadRequest, l)
+		return
+	}
+
+	abort := &deployments.AbortInfo{
+		Reason: status.Reason,
+	}
+	if _, err := govalidator.ValidateStruct(abort); err != nil {
+		d.view.RenderError(w, r, err, http.StatusBadRequest, l)
+		return
+	}
+	if idata := identity.FromContext(ctx); idata != nil {
+		abort.AbortedBy = idata.Subject
+	}
+
+	l.Infof("Force finish deployment: %s, status: %s", id, status.Status)
+
+	if err := d.model.ForceFinishDeployment(ctx, id, status.Status, abort); err != nil {
+		switch err {
+		case ErrModelDeploymentNotFound:
+			d.view.RenderError(w, r, err, http.StatusNotFound, l)
+		case ErrDeploymentAlreadyFinished:
+			d.view.RenderError(w, r, err, http.StatusUnprocessableEntity, l)
+		default:
+			d.view.RenderInternalError(w, r, err, l)
+		}
+		return
 	}
 
 	d.view.RenderEmptySuccessResponse(w)
@@ -240,23 +552,26 @@ func (d *DeploymentsController) PutDeploymentStatusForDevice(w rest.ResponseWrit
 	// receive request body
 	var report statusReport
 
-	err := r.DecodeJsonPayload(&report)
+	err := decodeBody(r, &report)
 	if err != nil {
-		d.view.RenderError(w, r, err, http.StatusBadRequest, l)
+		d.renderBodyError(w, r, err, l)
 		return
 	}
 
 	l.Infof("status: %+v", report)
 	if err := d.model.UpdateDeviceDeploymentStatus(ctx, did,
 		idata.Subject, deployments.DeviceDeploymentStatus{
-			Status:   report.Status,
-			SubState: report.SubState,
+			Status:     report.Status,
+			SubState:   report.SubState,
+			Error:      report.Error,
+			ReportTime: report.Time,
+			Telemetry:  report.Telemetry,
 		}); err != nil {
 
 		if err == ErrDeploymentAborted || err == ErrDeviceDecommissioned {
 			d.view.RenderError(w, r, err, http.StatusConflict, l)
 		} else {
-			d.view.RenderInternalError(w, r, err, l)
+			d.renderStoreError(w, r, err, l)
 		}
 		return
 	}
@@ -275,6 +590,23 @@ func (d *DeploymentsController) GetDeviceStatusesForDeployment(w rest.ResponseWr
 		return
 	}
 
+	// devices can be looked up by external ID, e.g. serial number
+	var deviceID string
+	externalID := r.URL.Query().Get("external_id")
+	if externalID != "" {
+		var err error
+		deviceID, err = d.model.ResolveDeviceExternalID(ctx, externalID)
+		switch errors.Cause(err) {
+		case nil:
+		case ErrExternalIDDisabled, ErrExternalIDAmbiguous:
+			d.view.RenderError(w, r, err, http.StatusBadRequest, l)
+			return
+		default:
+			d.view.RenderInternalError(w, r, err, l)
+			return
+		}
+	}
+
 	statuses, err := d.model.GetDeviceStatusesForDeployment(ctx, did)
 	if err != nil {
 		switch err {
@@ -287,30 +619,325 @@ func (d *DeploymentsController) GetDeviceStatusesForDeployment(w rest.ResponseWr
 		}
 	}
 
-	d.view.RenderSuccessGet(w, statuses)
+	if externalID != "" {
+		filtered := []deployments.DeviceDeployment{}
+		for _, status := range statuses {
+			if deviceID != "" && status.DeviceId != nil && *status.DeviceId == deviceID {
+				filtered = append(filtered, status)
+			}
+		}
+		statuses = filtered
+	}
+
+	// statuses resolved by the server can be looked up by reason code
+	if reason := r.URL.Query().Get("reason"); reason != "" {
+		filtered := []deployments.DeviceDeployment{}
+		for _, status := range statuses {
+			if status.Reason != nil && status.Reason.Code == reason {
+				filtered = append(filtered, status)
+			}
+		}
+		statuses = filtered
+	}
+
+	d.view.RenderCollection(w, r, statuses)
 }
 
-func ParseLookupQuery(vals url.Values) (deployments.Query, error) {
-	query := deployments.Query{}
+// GetDeviceDeploymentsCount serves number of devices in the deployment,
+// optionally only devices in the status given by query parameter.
+func (d *DeploymentsController) GetDeviceDeploymentsCount(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+	l := log.FromContext(ctx)
 
-	search := vals.Get("search")
-	if search != "" {
-		query.SearchText = search
+	did := r.PathParam("id")
+
+	if !govalidator.IsUUIDv4(did) {
+		d.view.RenderError(w, r, ErrIDNotUUIDv4, http.StatusBadRequest, l)
+		return
 	}
 
-	createdBefore := vals.Get("created_before")
-	if createdBefore != "" {
-		if createdBeforeTime, err := parseEpochToTimestamp(createdBefore); err != nil {
-			return query, errors.Wrap(err, "timestamp parsing failed for created_before parameter")
-		} else {
-			query.CreatedBefore = &createdBeforeTime
-		}
+	status := d.statusQuery(r).Get("status")
+	if status != "" && !deployments.IsDeviceDeploymentStatus(status) {
+		d.view.RenderError(w, r, errors.Errorf("unknown status %s", status),
+			http.StatusBadRequest, l)
+		return
 	}
 
-	createdAfter := vals.Get("created_after")
-	if createdAfter != "" {
-		if createdAfterTime, err := parseEpochToTimestamp(createdAfter); err != nil {
-			return query, errors.Wrap(err, "timestamp parsing failed created_after parameter")
+	count, err := d.model.GetDeviceDeploymentsCount(ctx, did, status)
+	if err != nil {
+		switch errors.Cause(err) {
+		case ErrModelDeploymentNotFound:
+			d.view.RenderError(w, r, ErrModelDeploymentNotFound, http.StatusNotFound, l)
+		default:
+			d.view.RenderInternalError(w, r, err, l)
+		}
+		return
+	}
+
+	d.view.RenderSuccessGet(w, struct {
+		Count int `json:"count"`
+	}{
+		Count: count,
+	})
+}
+
+const (
+	GetLatestDeviceDeploymentQueryTenant        = "tenant_id"
+	GetLatestDeviceDeploymentQueryDeploymentIDs = "deployment_id"
+)
+
+// GetLatestDeviceDeployment serves the most recent deployment of the device
+// to other services (internal API).
+func (d *DeploymentsController) GetLatestDeviceDeployment(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+	l := log.FromContext(ctx)
+
+	deviceID := r.PathParam("id")
+
+	query := r.URL.Query()
+	if tenantID := query.Get(GetLatestDeviceDeploymentQueryTenant); tenantID != "" {
+		ctx = identity.WithContext(ctx, &identity.Identity{Tenant: tenantID})
+	}
+
+	var deploymentIDs []string
+	for _, value := range query[GetLatestDeviceDeploymentQueryDeploymentIDs] {
+		for _, id := range strings.Split(value, ",") {
+			if id = strings.TrimSpace(id); id != "" {
+				deploymentIDs = append(deploymentIDs, id)
+			}
+		}
+	}
+
+	deviceDeployment, err := d.model.GetLatestDeviceDeployment(ctx, deviceID, deploymentIDs)
+	if err != nil {
+		d.view.RenderInternalError(w, r, err, l)
+		return
+	}
+
+	if deviceDeployment == nil {
+		d.view.RenderErrorNotFound(w, r, l)
+		return
+	}
+
+	d.view.RenderSuccessGet(w, newDeviceDeploymentWithID(deviceDeployment))
+}
+
+// GetActiveDeviceDeployments serves active deployments of many devices at
+// once to other services (internal API), by device ID.
+func (d *DeploymentsController) GetActiveDeviceDeployments(w rest.ResponseWriter,
+	r *rest.Request) {
+
+	ctx := r.Context()
+	l := log.FromContext(ctx)
+
+	if tenantID := r.URL.Query().Get(GetLatestDeviceDeploymentQueryTenant); tenantID != "" {
+		ctx = identity.WithContext(ctx, &identity.Identity{Tenant: tenantID})
+	}
+
+	var lookup deployments.DevicesLookup
+	if err := decodeBody(r, &lookup); err != nil {
+		d.renderBodyError(w, r, errors.Wrap(err, "Validating request body"), l)
+		return
+	}
+	if err := lookup.Validate(); err != nil {
+		d.renderBodyError(w, r, errors.Wrap(err, "Validating request body"), l)
+		return
+	}
+
+	active, err := d.model.GetActiveDeviceDeployments(ctx, lookup.DeviceIDs)
+	if err != nil {
+		d.view.RenderInternalError(w, r, err, l)
+		return
+	}
+
+	out := make(map[string][]deviceDeploymentWithID, len(active))
+	for deviceID, list := range active {
+		out[deviceID] = make([]deviceDeploymentWithID, 0, len(list))
+		for i := range list {
+			out[deviceID] = append(out[deviceID], newDeviceDeploymentWithID(&list[i]))
+		}
+	}
+
+	d.view.RenderSuccessGet(w, out)
+}
+
+// deviceDeploymentWithID exposes the deployment the device deployment
+// belongs to, device deployment does not serialize it on its own.
+type deviceDeploymentWithID struct {
+	*deployments.DeviceDeployment
+	DeploymentID *string `json:"deployment_id"`
+}
+
+func newDeviceDeploymentWithID(deviceDeployment *deployments.DeviceDeployment) deviceDeploymentWithID {
+	return deviceDeploymentWithID{
+		DeviceDeployment: deviceDeployment,
+		DeploymentID:     deviceDeployment.DeploymentId,
+	}
+}
+
+func ParseDeviceDeploymentsQuery(vals url.Values) (deployments.DeviceDeploymentsQuery, error) {
+	query := deployments.DeviceDeploymentsQuery{}
+
+	status := vals.Get("status")
+	if status != "" && !deployments.IsDeviceDeploymentStatus(status) {
+		return query, errors.Errorf("unknown status %s", status)
+	}
+	query.Status = status
+
+	createdBefore := vals.Get("created_before")
+	if createdBefore != "" {
+		createdBeforeTime, err := parseEpochToTimestamp(createdBefore)
+		if err != nil {
+			return query, errors.Wrap(err, "timestamp parsing failed for created_before parameter")
+		}
+		query.CreatedBefore = &createdBeforeTime
+	}
+
+	createdAfter := vals.Get("created_after")
+	if createdAfter != "" {
+		createdAfterTime, err := parseEpochToTimestamp(createdAfter)
+		if err != nil {
+			return query, errors.Wrap(err, "timestamp parsing failed for created_after parameter")
+		}
+		query.CreatedAfter = &createdAfterTime
+	}
+
+	return query, nil
+}
+
+// LookupDeviceDeployments lists device deployments of all deployments,
+// e.g. all currently failing devices.
+func (d *DeploymentsController) LookupDeviceDeployments(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+	l := log.FromContext(ctx)
+
+	query, err := ParseDeviceDeploymentsQuery(d.statusQuery(r))
+	if err != nil {
+		d.view.RenderError(w, r, err, http.StatusBadRequest, l)
+		return
+	}
+
+	page, perPage, err := rest_utils.ParsePagination(r)
+	if err != nil {
+		d.view.RenderError(w, r, err, http.StatusBadRequest, l)
+		return
+	}
+	query.Skip = int((page - 1) * perPage)
+	query.Limit = int(perPage + 1)
+
+	list, err := d.model.LookupDeviceDeployments(ctx, query)
+	if err != nil {
+		d.view.RenderInternalError(w, r, err, l)
+		return
+	}
+
+	hasNext := false
+	if uint64(len(list)) > perPage {
+		hasNext = true
+		list = list[:perPage]
+	}
+
+	links := rest_utils.MakePageLinkHdrs(r, page, perPage, hasNext)
+	for _, l := range links {
+		w.Header().Add("Link", l)
+	}
+
+	out := make([]deviceDeploymentWithID, len(list))
+	for i := range list {
+		out[i] = newDeviceDeploymentWithID(&list[i])
+	}
+
+	d.view.RenderCollection(w, r, out)
+}
+
+func ParseDownloadsQuery(vals url.Values) (deployments.DownloadsQuery, error) {
+	query := deployments.DownloadsQuery{
+		ArtifactID:   vals.Get("artifact_id"),
+		DeploymentID: vals.Get("deployment_id"),
+		DeviceID:     vals.Get("device_id"),
+	}
+
+	createdBefore := vals.Get("created_before")
+	if createdBefore != "" {
+		createdBeforeTime, err := parseEpochToTimestamp(createdBefore)
+		if err != nil {
+			return query, errors.Wrap(err, "timestamp parsing failed for created_before parameter")
+		}
+		query.CreatedBefore = &createdBeforeTime
+	}
+
+	createdAfter := vals.Get("created_after")
+	if createdAfter != "" {
+		createdAfterTime, err := parseEpochToTimestamp(createdAfter)
+		if err != nil {
+			return query, errors.Wrap(err, "timestamp parsing failed for created_after parameter")
+		}
+		query.CreatedAfter = &createdAfterTime
+	}
+
+	return query, nil
+}
+
+// LookupDownloads lists audit records of issued artifact download links.
+func (d *DeploymentsController) LookupDownloads(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+	l := log.FromContext(ctx)
+
+	query, err := ParseDownloadsQuery(r.URL.Query())
+	if err != nil {
+		d.view.RenderError(w, r, err, http.StatusBadRequest, l)
+		return
+	}
+
+	page, perPage, err := rest_utils.ParsePagination(r)
+	if err != nil {
+		d.view.RenderError(w, r, err, http.StatusBadRequest, l)
+		return
+	}
+	query.Skip = int((page - 1) * perPage)
+	query.Limit = int(perPage + 1)
+
+	list, err := d.model.LookupDownloads(ctx, query)
+	if err != nil {
+		d.view.RenderInternalError(w, r, err, l)
+		return
+	}
+
+	hasNext := false
+	if uint64(len(list)) > perPage {
+		hasNext = true
+		list = list[:perPage]
+	}
+
+	links := rest_utils.MakePageLinkHdrs(r, page, perPage, hasNext)
+	for _, l := range links {
+		w.Header().Add("Link", l)
+	}
+
+	d.view.RenderCollection(w, r, list)
+}
+
+func ParseLookupQuery(vals url.Values) (deployments.Query, error) {
+	query := deployments.Query{}
+
+	search := vals.Get("search")
+	if search != "" {
+		query.SearchText = search
+	}
+
+	createdBefore := vals.Get("created_before")
+	if createdBefore != "" {
+		if createdBeforeTime, err := parseEpochToTimestamp(createdBefore); err != nil {
+			return query, errors.Wrap(err, "timestamp parsing failed for created_before parameter")
+		} else {
+			query.CreatedBefore = &createdBeforeTime
+		}
+	}
+
+	createdAfter := vals.Get("created_after")
+	if createdAfter != "" {
+		if createdAfterTime, err := parseEpochToTimestamp(createdAfter); err != nil {
+			return query, errors.Wrap(err, "timestamp parsing failed created_after parameter")
 		} else {
 			query.CreatedAfter = &createdAfterTime
 		}
@@ -348,7 +975,7 @@ func (d *DeploymentsController) LookupDeployment(w rest.ResponseWriter, r *rest.
 	ctx := r.Context()
 	l := log.FromContext(ctx)
 
-	query, err := ParseLookupQuery(r.URL.Query())
+	query, err := ParseLookupQuery(d.statusQuery(r))
 	if err != nil {
 		d.view.RenderError(w, r, err, http.StatusBadRequest, l)
 		return
@@ -380,9 +1007,13 @@ func (d *DeploymentsController) LookupDeployment(w rest.ResponseWriter, r *rest.
 		w.Header().Add("Link", l)
 	}
 
-	d.view.RenderSuccessGet(w, deps[:len])
+	d.view.RenderCollection(w, r, deps[:len])
 }
 
+// MaxDeploymentLogBodySize limits the deployment log request body, leaving
+// room for JSON encoding of messages up to the deployment log size limit.
+const MaxDeploymentLogBodySize = 2 * deployments.MaxDeploymentLogSize
+
 func (d *DeploymentsController) PutDeploymentLogForDevice(w rest.ResponseWriter, r *rest.Request) {
 	ctx := r.Context()
 	l := log.FromContext(ctx)
@@ -395,23 +1026,41 @@ func (d *DeploymentsController) PutDeploymentLogForDevice(w rest.ResponseWriter,
 		return
 	}
 
+	if r.ContentLength > MaxDeploymentLogBodySize {
+		d.view.RenderError(w, r, deployments.ErrDeploymentLogTooLarge,
+			http.StatusRequestEntityTooLarge, l)
+		return
+	}
+	// body of unknown length is cut at the limit, failing to decode
+	r.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.LimitReader(r.Body, MaxDeploymentLogBodySize), r.Body}
+
 	// reuse DeploymentLog, device and deployment IDs are ignored when
 	// (un-)marshalling DeploymentLog to/from JSON
 	var log deployments.DeploymentLog
 
-	err := r.DecodeJsonPayload(&log)
+	err := decodeBody(r, &log)
 	if err != nil {
-		d.view.RenderError(w, r, err, http.StatusBadRequest, l)
+		if errors.Cause(err) == deployments.ErrDeploymentLogTooLarge {
+			d.view.RenderError(w, r, err, http.StatusRequestEntityTooLarge, l)
+		} else {
+			d.renderBodyError(w, r, err, l)
+		}
 		return
 	}
 
 	if err := d.model.SaveDeviceDeploymentLog(ctx, idata.Subject,
 		did, log.Messages); err != nil {
 
-		if err == ErrModelDeploymentNotFound {
+		switch {
+		case err == ErrModelDeploymentNotFound:
 			d.view.RenderError(w, r, err, http.StatusNotFound, l)
-		} else {
-			d.view.RenderInternalError(w, r, err, l)
+		case errors.Cause(err) == deployments.ErrDeploymentLogTooLarge:
+			d.view.RenderError(w, r, err, http.StatusRequestEntityTooLarge, l)
+		default:
+			d.renderStoreError(w, r, err, l)
 		}
 		return
 	}
@@ -429,7 +1078,7 @@ func (d *DeploymentsController) GetDeploymentLogForDevice(w rest.ResponseWriter,
 	depl, err := d.model.GetDeviceDeploymentLog(ctx, devid, did)
 
 	if err != nil {
-		d.view.RenderInternalError(w, r, err, l)
+		d.renderStoreError(w, r, err, l)
 		return
 	}
 
@@ -441,20 +1090,533 @@ func (d *DeploymentsController) GetDeploymentLogForDevice(w rest.ResponseWriter,
 	d.view.RenderDeploymentLog(w, *depl)
 }
 
-func (d *DeploymentsController) DecommissionDevice(w rest.ResponseWriter, r *rest.Request) {
+// SearchDeploymentLogs lists devices of the deployment whose logs contain
+// the text given by the "q" query parameter.
+func (d *DeploymentsController) SearchDeploymentLogs(w rest.ResponseWriter, r *rest.Request) {
 	ctx := r.Context()
 	l := log.FromContext(ctx)
 
-	id := r.PathParam("id")
+	did := r.PathParam("id")
 
-	// Decommission deployments for devices and update deployment stats
-	err := d.model.DecommissionDevice(ctx, id)
+	if !govalidator.IsUUIDv4(did) {
+		d.view.RenderError(w, r, ErrIDNotUUIDv4, http.StatusBadRequest, l)
+		return
+	}
+
+	text := strings.TrimSpace(r.URL.Query().Get("q"))
+	if text == "" {
+		d.view.RenderError(w, r, ErrMissingSearchQuery, http.StatusBadRequest, l)
+		return
+	}
+
+	devices, err := d.model.SearchDeviceDeploymentLogs(ctx, did, text)
+	if err != nil {
+		switch err {
+		case ErrModelDeploymentNotFound, ErrLogSearchDisabled:
+			d.view.RenderError(w, r, err, http.StatusNotFound, l)
+		default:
+			d.view.RenderInternalError(w, r, err, l)
+		}
+		return
+	}
+
+	d.view.RenderCollection(w, r, devices)
+}
+
+// RevokeDeviceDeploymentLink invalidates artifact download link issued to
+// the device for the deployment, e.g. when the artifact has to be pulled.
+func (d *DeploymentsController) RevokeDeviceDeploymentLink(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+	l := log.FromContext(ctx)
+
+	did := r.PathParam("id")
+	devid := r.PathParam("devid")
+
+	if !govalidator.IsUUIDv4(did) {
+		d.view.RenderError(w, r, ErrIDNotUUIDv4, http.StatusBadRequest, l)
+		return
+	}
 
-	switch err {
-	case nil, ErrStorageNotFound:
+	err := d.model.RevokeDeviceDeploymentLink(ctx, did, devid)
+	switch errors.Cause(err) {
+	case nil:
 		d.view.RenderEmptySuccessResponse(w)
+	case ErrDownloadProxyDisabled:
+		d.view.RenderError(w, r, err, http.StatusConflict, l)
 	default:
-		d.view.RenderInternalError(w, r, err, l)
+		d.renderStoreError(w, r, err, l)
+	}
+}
+
+// GetDeviceDeploymentDownload redirects the device to the artifact storage,
+// unless the download link of the device was revoked. Links are signed by
+// the service and carry the tenant, devices send no token with downloads.
+func (d *DeploymentsController) GetDeviceDeploymentDownload(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+	l := log.FromContext(ctx)
+
+	did := r.PathParam("id")
+	devid := r.PathParam("devid")
+	query := r.URL.Query()
+
+	expire, err := strconv.ParseInt(query.Get("expire"), 10, 64)
+	if err != nil {
+		d.view.RenderError(w, r, ErrDownloadLinkInvalid, http.StatusForbidden, l)
+		return
+	}
+	if tenant := query.Get("tenant_id"); tenant != "" {
+		ctx = identity.WithContext(ctx, &identity.Identity{Tenant: tenant})
+	}
+
+	link, err := d.model.GetDeviceDeploymentDownload(ctx, did, devid,
+		expire, query.Get("signature"))
+	switch errors.Cause(err) {
+	case nil:
+		http.Redirect(w.(http.ResponseWriter), r.Request, link.Uri, http.StatusFound)
+	case ErrDownloadLinkInvalid:
+		d.view.RenderError(w, r, err, http.StatusForbidden, l)
+	case ErrDownloadLinkRevoked:
+		d.view.RenderError(w, r, err, http.StatusGone, l)
+	case ErrDownloadProxyDisabled:
+		d.view.RenderErrorNotFound(w, r, l)
+	default:
+		d.renderStoreError(w, r, err, l)
+	}
+}
+
+// RetryDeviceDeployment puts failed or aborted deployment of a single
+// device back to pending.
+func (d *DeploymentsController) RetryDeviceDeployment(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+	l := log.FromContext(ctx)
+
+	did := r.PathParam("id")
+	devid := r.PathParam("devid")
+
+	if !govalidator.IsUUIDv4(did) {
+		d.view.RenderError(w, r, ErrIDNotUUIDv4, http.StatusBadRequest, l)
+		return
+	}
 
+	err := d.model.RetryDeviceDeployment(ctx, did, devid)
+	switch errors.Cause(err) {
+	case nil:
+		d.view.RenderEmptySuccessResponse(w)
+	case ErrRetryNotAllowed, ErrRetryLimitReached, ErrDownloadLinkRevoked:
+		d.view.RenderError(w, r, err, http.StatusConflict, l)
+	default:
+		d.renderStoreError(w, r, err, l)
+	}
+}
+
+func (d *DeploymentsController) DecommissionDevice(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+	l := log.FromContext(ctx)
+
+	id := r.PathParam("id")
+
+	// Decommission deployments for devices and update deployment stats
+	err := d.model.DecommissionDevice(ctx, id)
+
+	if err != nil && !deployments.IsStoreError(err, ErrStorageNotFound) {
+		d.view.RenderInternalError(w, r, err, l)
+		return
+	}
+
+	d.view.RenderEmptySuccessResponse(w)
+}
+
+func (d *DeploymentsController) PostFreezePeriod(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+	l := log.FromContext(ctx)
+
+	var constructor *deployments.FreezePeriodConstructor
+	if err := decodeBody(r, &constructor); err != nil {
+		d.renderBodyError(w, r, errors.Wrap(err, "Validating request body"), l)
+		return
+	}
+	if constructor == nil {
+		d.view.RenderError(w, r, ErrModelMissingInput, http.StatusBadRequest, l)
+		return
+	}
+	if err := constructor.Validate(); err != nil {
+		d.view.RenderError(w, r, errors.Wrap(err, "Validating request body"), http.StatusBadRequest, l)
+		return
+	}
+
+	id, err := d.model.CreateFreezePeriod(ctx, constructor)
+	if err != nil {
+		d.view.RenderInternalError(w, r, err, l)
+		return
+	}
+
+	d.view.RenderSuccessPost(w, r, id)
+}
+
+func (d *DeploymentsController) GetFreezePeriods(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+	l := log.FromContext(ctx)
+
+	periods, err := d.model.GetFreezePeriods(ctx)
+	if err != nil {
+		d.view.RenderInternalError(w, r, err, l)
+		return
+	}
+
+	d.view.RenderCollection(w, r, periods)
+}
+
+func (d *DeploymentsController) DeleteFreezePeriod(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+	l := log.FromContext(ctx)
+
+	id := r.PathParam("id")
+
+	if !govalidator.IsUUIDv4(id) {
+		d.view.RenderError(w, r, ErrIDNotUUIDv4, http.StatusBadRequest, l)
+		return
+	}
+
+	if err := d.model.DeleteFreezePeriod(ctx, id); err != nil {
+		d.renderStoreError(w, r, err, l)
+		return
+	}
+
+	d.view.RenderEmptySuccessResponse(w)
+}
+
+func (d *DeploymentsController) GetDeviceTypeAliases(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+	l := log.FromContext(ctx)
+
+	aliases, err := d.model.GetDeviceTypeAliases(ctx)
+	if err != nil {
+		d.view.RenderInternalError(w, r, err, l)
+		return
+	}
+
+	d.view.RenderCollection(w, r, aliases)
+}
+
+func (d *DeploymentsController) PutDeviceTypeAlias(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+	l := log.FromContext(ctx)
+
+	var alias *deployments.DeviceTypeAlias
+	if err := decodeBody(r, &alias); err != nil {
+		d.renderBodyError(w, r, errors.Wrap(err, "Validating request body"), l)
+		return
+	}
+	if alias == nil {
+		d.view.RenderError(w, r, ErrModelMissingInput, http.StatusBadRequest, l)
+		return
+	}
+	// the alias is identified by the path
+	alias.Alias = r.PathParam("alias")
+	if err := alias.Validate(); err != nil {
+		d.renderBodyError(w, r, errors.Wrap(err, "Validating request body"), l)
+		return
+	}
+
+	if err := d.model.SetDeviceTypeAlias(ctx, alias); err != nil {
+		d.view.RenderInternalError(w, r, err, l)
+		return
+	}
+
+	d.view.RenderEmptySuccessResponse(w)
+}
+
+func (d *DeploymentsController) DeleteDeviceTypeAlias(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+	l := log.FromContext(ctx)
+
+	if err := d.model.DeleteDeviceTypeAlias(ctx, r.PathParam("alias")); err != nil {
+		d.renderStoreError(w, r, err, l)
+		return
+	}
+
+	d.view.RenderEmptySuccessResponse(w)
+}
+
+func (d *DeploymentsController) GetDeploymentSettings(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+	l := log.FromContext(ctx)
+
+	settings, err := d.model.GetDeploymentSettings(ctx)
+	if err != nil {
+		d.view.RenderInternalError(w, r, err, l)
+		return
+	}
+
+	d.view.RenderSuccessGet(w, settings)
+}
+
+func (d *DeploymentsController) PutDeploymentSettings(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+	l := log.FromContext(ctx)
+
+	var settings *deployments.DeploymentSettings
+	if err := decodeBody(r, &settings); err != nil {
+		d.renderBodyError(w, r, errors.Wrap(err, "Validating request body"), l)
+		return
+	}
+	if settings == nil {
+		d.view.RenderError(w, r, ErrModelMissingInput, http.StatusBadRequest, l)
+		return
+	}
+	if err := settings.Validate(); err != nil {
+		d.renderBodyError(w, r, errors.Wrap(err, "Validating request body"), l)
+		return
+	}
+
+	if err := d.model.SetDeploymentSettings(ctx, settings); err != nil {
+		d.view.RenderInternalError(w, r, err, l)
+		return
+	}
+
+	d.view.RenderEmptySuccessResponse(w)
+}
+
+func (d *DeploymentsController) PostCampaign(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+	l := log.FromContext(ctx)
+
+	var constructor *deployments.CampaignConstructor
+	if err := decodeBody(r, &constructor); err != nil {
+		d.renderBodyError(w, r, errors.Wrap(err, "Validating request body"), l)
+		return
+	}
+	if constructor == nil {
+		d.view.RenderError(w, r, ErrModelMissingInput, http.StatusBadRequest, l)
+		return
+	}
+	if err := constructor.Validate(); err != nil {
+		err = errors.Wrap(err, "Validating request body")
+		d.view.RenderValidationError(w, r, err,
+			errors.Cause(err).(*deployments.ValidationError).Fields, http.StatusBadRequest, l)
+		return
+	}
+
+	id, err := d.model.CreateCampaign(ctx, constructor)
+	if err != nil {
+		if verr, ok := errors.Cause(err).(*deployments.ValidationError); ok {
+			d.view.RenderValidationError(w, r, err, verr.Fields, http.StatusUnprocessableEntity, l)
+		} else {
+			d.view.RenderInternalError(w, r, err, l)
+		}
+		return
+	}
+
+	d.view.RenderSuccessPost(w, r, id)
+}
+
+func (d *DeploymentsController) GetCampaigns(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+	l := log.FromContext(ctx)
+
+	campaigns, err := d.model.GetCampaigns(ctx)
+	if err != nil {
+		d.view.RenderInternalError(w, r, err, l)
+		return
+	}
+
+	d.view.RenderCollection(w, r, campaigns)
+}
+
+func (d *DeploymentsController) GetCampaign(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+	l := log.FromContext(ctx)
+
+	id := r.PathParam("id")
+
+	if !govalidator.IsUUIDv4(id) {
+		d.view.RenderError(w, r, ErrIDNotUUIDv4, http.StatusBadRequest, l)
+		return
+	}
+
+	campaign, err := d.model.GetCampaign(ctx, id)
+	if err != nil {
+		d.view.RenderInternalError(w, r, err, l)
+		return
+	}
+
+	if campaign == nil {
+		d.view.RenderErrorNotFound(w, r, l)
+		return
+	}
+
+	d.view.RenderSuccessGet(w, campaign)
+}
+
+func (d *DeploymentsController) GetCampaignStats(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+	l := log.FromContext(ctx)
+
+	id := r.PathParam("id")
+
+	if !govalidator.IsUUIDv4(id) {
+		d.view.RenderError(w, r, ErrIDNotUUIDv4, http.StatusBadRequest, l)
+		return
+	}
+
+	stats, err := d.model.GetCampaignStats(ctx, id)
+	if err != nil {
+		d.view.RenderInternalError(w, r, err, l)
+		return
+	}
+
+	if stats == nil {
+		d.view.RenderErrorNotFound(w, r, l)
+		return
+	}
+
+	d.view.RenderSuccessGet(w, stats)
+}
+
+// PutCampaignStatus pauses, resumes or aborts deployments of the campaign.
+func (d *DeploymentsController) PutCampaignStatus(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+	l := log.FromContext(ctx)
+
+	id := r.PathParam("id")
+
+	if !govalidator.IsUUIDv4(id) {
+		d.view.RenderError(w, r, ErrIDNotUUIDv4, http.StatusBadRequest, l)
+		return
+	}
+
+	var status struct {
+		Status string
+		Reason string
+	}
+
+	if err := decodeBody(r, &status); err != nil {
+		d.renderBodyError(w, r, err, l)
+		return
+	}
+
+	var err error
+	switch status.Status {
+	case deployments.CampaignStatusPaused, deployments.CampaignStatusActive:
+		l.Infof("Set campaign %s status: %s", id, status.Status)
+		err = d.model.PauseCampaign(ctx, id,
+			status.Status == deployments.CampaignStatusPaused)
+	case deployments.CampaignStatusAborted:
+		abort := &deployments.AbortInfo{
+			Reason: status.Reason,
+		}
+		if _, err := govalidator.ValidateStruct(abort); err != nil {
+			d.view.RenderError(w, r, err, http.StatusBadRequest, l)
+			return
+		}
+		if idata := identity.FromContext(ctx); idata != nil {
+			abort.AbortedBy = idata.Subject
+		}
+		l.Infof("Abort campaign: %s", id)
+		err = d.model.AbortCampaign(ctx, id, abort)
+	default:
+		d.view.RenderError(w, r, ErrUnexpectedCampaignStatus, http.StatusBadRequest, l)
+		return
+	}
+
+	switch errors.Cause(err) {
+	case nil:
+		d.view.RenderEmptySuccessResponse(w)
+	case ErrModelCampaignNotFound:
+		d.view.RenderErrorNotFound(w, r, l)
+	case ErrCampaignAborted:
+		d.view.RenderError(w, r, err, http.StatusUnprocessableEntity, l)
+	default:
+		d.view.RenderInternalError(w, r, err, l)
+	}
+}
+
+func (d *DeploymentsController) PostAlert(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+	l := log.FromContext(ctx)
+
+	var constructor *deployments.AlertConstructor
+	if err := decodeBody(r, &constructor); err != nil {
+		d.renderBodyError(w, r, errors.Wrap(err, "Validating request body"), l)
+		return
+	}
+	if constructor == nil {
+		d.view.RenderError(w, r, ErrModelMissingInput, http.StatusBadRequest, l)
+		return
+	}
+	if err := constructor.Validate(); err != nil {
+		err = errors.Wrap(err, "Validating request body")
+		d.view.RenderValidationError(w, r, err,
+			errors.Cause(err).(*deployments.ValidationError).Fields, http.StatusBadRequest, l)
+		return
+	}
+
+	id, err := d.model.CreateAlert(ctx, constructor)
+	if err != nil {
+		d.view.RenderInternalError(w, r, err, l)
+		return
+	}
+
+	d.view.RenderSuccessPost(w, r, id)
+}
+
+func (d *DeploymentsController) GetAlerts(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+	l := log.FromContext(ctx)
+
+	alerts, err := d.model.GetAlerts(ctx)
+	if err != nil {
+		d.view.RenderInternalError(w, r, err, l)
+		return
+	}
+
+	d.view.RenderCollection(w, r, alerts)
+}
+
+func (d *DeploymentsController) DeleteAlert(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+	l := log.FromContext(ctx)
+
+	id := r.PathParam("id")
+
+	if !govalidator.IsUUIDv4(id) {
+		d.view.RenderError(w, r, ErrIDNotUUIDv4, http.StatusBadRequest, l)
+		return
+	}
+
+	switch err := d.model.DeleteAlert(ctx, id); errors.Cause(err) {
+	case nil:
+		d.view.RenderEmptySuccessResponse(w)
+	case ErrModelAlertNotFound:
+		d.view.RenderErrorNotFound(w, r, l)
+	default:
+		d.view.RenderInternalError(w, r, err, l)
+	}
+}
+
+// renderStoreError maps storage errors to HTTP status codes. Details of
+// the failed storage operation are logged, but not returned to the client.
+func (d *DeploymentsController) renderStoreError(w rest.ResponseWriter, r *rest.Request,
+	err error, l *log.Logger) {
+
+	if storeErr, ok := deployments.AsStoreError(err); ok {
+		l = l.F(log.Ctx{
+			"store_op":   storeErr.Op,
+			"collection": storeErr.Collection,
+			"ids":        storeErr.IDs,
+		})
+	}
+
+	switch {
+	case deployments.IsStoreError(err, ErrStorageNotFound):
+		d.view.RenderErrorNotFound(w, r, l)
+	case deployments.IsStoreError(err, deployments.ErrStorageInvalidID):
+		d.view.RenderError(w, r, deployments.ErrStorageInvalidID, http.StatusBadRequest, l)
+	case deployments.IsStoreError(err, deployments.ErrStorageInvalidInput):
+		d.view.RenderError(w, r, deployments.ErrStorageInvalidInput, http.StatusBadRequest, l)
+	case deployments.IsStoreError(err, deployments.ErrStorageFinishedStatus):
+		d.view.RenderError(w, r, deployments.ErrStorageFinishedStatus, http.StatusConflict, l)
+	default:
+		d.view.RenderInternalError(w, r, err, l)
 	}
 }
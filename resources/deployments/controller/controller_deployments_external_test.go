@@ -15,6 +15,7 @@
 package controller_test
 
 import (
+	"context"
 	"encoding/base64"
 	"errors"
 	"fmt"
@@ -22,6 +23,7 @@ import (
 	"net/http"
 	"net/url"
 	"strconv"
+	"strings"
 	"testing"
 	"time"
 
@@ -31,6 +33,7 @@ import (
 	"github.com/mendersoftware/go-lib-micro/identity"
 	"github.com/mendersoftware/go-lib-micro/requestid"
 	"github.com/mendersoftware/go-lib-micro/requestlog"
+	pkgerrors "github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 
@@ -39,7 +42,9 @@ import (
 	"github.com/mendersoftware/deployments/resources/deployments/controller/mocks"
 	"github.com/mendersoftware/deployments/resources/deployments/view"
 	"github.com/mendersoftware/deployments/resources/images"
+	"github.com/mendersoftware/deployments/resources/limits"
 	. "github.com/mendersoftware/deployments/utils/pointers"
+	"github.com/mendersoftware/deployments/utils/restutil"
 	h "github.com/mendersoftware/deployments/utils/testing"
 )
 
@@ -362,6 +367,88 @@ func TestControllerGetDeployment(t *testing.T) {
 	}
 }
 
+func TestControllerResolveSlug(t *testing.T) {
+
+	t.Parallel()
+
+	deployment := &deployments.Deployment{
+		Id: StringToPointer("f826484e-1157-4109-af21-304e6d711560"),
+	}
+
+	testCases := map[string]struct {
+		h.JSONResponseParams
+
+		InputID string
+
+		InputModelID    string
+		InputModelError error
+	}{
+		"id": {
+			InputID: "f826484e-1157-4109-af21-304e6d711560",
+			JSONResponseParams: h.JSONResponseParams{
+				OutputStatus:     http.StatusOK,
+				OutputBodyObject: deployment,
+			},
+		},
+		"slug": {
+			InputID:      "nyc-production",
+			InputModelID: "f826484e-1157-4109-af21-304e6d711560",
+			JSONResponseParams: h.JSONResponseParams{
+				OutputStatus:     http.StatusOK,
+				OutputBodyObject: deployment,
+			},
+		},
+		"slug, not found": {
+			InputID: "nyc-production",
+			JSONResponseParams: h.JSONResponseParams{
+				OutputStatus:     http.StatusNotFound,
+				OutputBodyObject: h.ErrorToErrStruct(errors.New("Resource not found")),
+			},
+		},
+		"slug, model error": {
+			InputID:         "nyc-production",
+			InputModelError: errors.New("model error"),
+			JSONResponseParams: h.JSONResponseParams{
+				OutputStatus:     http.StatusInternalServerError,
+				OutputBodyObject: h.ErrorToErrStruct(errors.New("internal error")),
+			},
+		},
+		"neither id nor slug": {
+			InputID: "broken_id",
+			JSONResponseParams: h.JSONResponseParams{
+				OutputStatus:     http.StatusBadRequest,
+				OutputBodyObject: h.ErrorToErrStruct(ErrIDNotUUIDv4),
+			},
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+
+			deploymentModel := new(mocks.DeploymentsModel)
+			deploymentModel.On("GetDeploymentIDBySlug",
+				h.ContextMatcher(), testCase.InputID).
+				Return(testCase.InputModelID, testCase.InputModelError)
+			deploymentModel.On("GetDeployment",
+				h.ContextMatcher(), *deployment.Id).
+				Return(deployment, nil)
+
+			c := NewDeploymentsController(deploymentModel, new(view.DeploymentsView))
+			router, err := rest.MakeRouter(
+				rest.Get("/r/:id", c.ResolveSlug(c.GetDeployment)))
+			assert.NoError(t, err)
+
+			api := makeApi(router)
+
+			req := test.MakeSimpleRequest("GET", "http://localhost/r/"+testCase.InputID, nil)
+			req.Header.Add(requestid.RequestIdHeader, "test")
+			recorded := test.RunRequest(t, api.MakeHandler(), req)
+
+			h.CheckRecordedResponse(t, recorded, testCase.JSONResponseParams)
+		})
+	}
+}
+
 func TestControllerPostDeployment(t *testing.T) {
 
 	t.Parallel()
@@ -373,6 +460,9 @@ func TestControllerPostDeployment(t *testing.T) {
 
 		InputModelID    string
 		InputModelError error
+
+		InputEstimate      *deployments.DeploymentEstimate
+		InputEstimateError error
 	}{
 		{
 			InputBodyObject: nil,
@@ -384,8 +474,16 @@ func TestControllerPostDeployment(t *testing.T) {
 		{
 			InputBodyObject: deployments.NewDeploymentConstructor(),
 			JSONResponseParams: h.JSONResponseParams{
-				OutputStatus:     http.StatusBadRequest,
-				OutputBodyObject: h.ErrorToErrStruct(errors.New(`Validating request body: Name: non zero value required;ArtifactName: non zero value required;Devices: non zero value required;`)),
+				OutputStatus: http.StatusBadRequest,
+				OutputBodyObject: map[string]interface{}{
+					"error":      "Validating request body: name: value is required;artifact_name: value is required;devices: at least one device is required;",
+					"request_id": "test",
+					"fields": []deployments.FieldError{
+						{Field: "name", Code: deployments.ValidationCodeRequired, Message: "value is required"},
+						{Field: "artifact_name", Code: deployments.ValidationCodeRequired, Message: "value is required"},
+						{Field: "devices", Code: deployments.ValidationCodeRequired, Message: "at least one device is required"},
+					},
+				},
 			},
 		},
 		{
@@ -413,6 +511,194 @@ func TestControllerPostDeployment(t *testing.T) {
 				OutputHeaders:    map[string]string{"Location": "./r/1234"},
 			},
 		},
+		{
+			InputBodyObject: &deployments.DeploymentConstructor{
+				Name:         StringToPointer("NYC Production"),
+				ArtifactName: StringToPointer("App 123"),
+				Devices:      []string{"f826484e-1157-4109-af21-304e6d711560"},
+			},
+			InputModelID: "1234",
+			InputEstimate: &deployments.DeploymentEstimate{
+				Devices:         1,
+				ArtifactSize:    1024,
+				DownloadBytes:   1024,
+				Duration:        600,
+				DurationSamples: 12,
+			},
+			JSONResponseParams: h.JSONResponseParams{
+				OutputStatus: http.StatusCreated,
+				OutputBodyObject: &deployments.DeploymentEstimate{
+					Devices:         1,
+					ArtifactSize:    1024,
+					DownloadBytes:   1024,
+					Duration:        600,
+					DurationSamples: 12,
+				},
+				OutputHeaders: map[string]string{"Location": "./r/1234"},
+			},
+		},
+		{
+			InputBodyObject: &deployments.DeploymentConstructor{
+				Name:         StringToPointer("NYC Production"),
+				ArtifactName: StringToPointer("App 123"),
+				Devices:      []string{"f826484e-1157-4109-af21-304e6d711560"},
+			},
+			InputModelID:       "1234",
+			InputEstimateError: errors.New("storage issue"),
+			JSONResponseParams: h.JSONResponseParams{
+				OutputStatus:  http.StatusCreated,
+				OutputHeaders: map[string]string{"Location": "./r/1234"},
+			},
+		},
+		{
+			InputBodyObject: &deployments.DeploymentConstructor{
+				Name:           StringToPointer("NYC Production"),
+				ArtifactName:   StringToPointer("App 123"),
+				Devices:        []string{"f826484e-1157-4109-af21-304e6d711560"},
+				ConflictPolicy: deployments.ConflictPolicyReject,
+			},
+			InputModelError: ErrConflictingDeployment,
+			JSONResponseParams: h.JSONResponseParams{
+				OutputStatus:     http.StatusConflict,
+				OutputBodyObject: h.ErrorToErrStruct(ErrConflictingDeployment),
+			},
+		},
+		{
+			InputBodyObject: &deployments.DeploymentConstructor{
+				Name:           StringToPointer("NYC Production"),
+				ArtifactName:   StringToPointer("App 123"),
+				Devices:        []string{"f826484e-1157-4109-af21-304e6d711560"},
+				ConflictPolicy: "ignore",
+			},
+			JSONResponseParams: h.JSONResponseParams{
+				OutputStatus: http.StatusBadRequest,
+				OutputBodyObject: map[string]interface{}{
+					"error":      "Validating request body: conflict_policy: " + deployments.ErrInvalidConflictPolicy.Error() + ";",
+					"request_id": "test",
+					"fields": []deployments.FieldError{
+						{
+							Field:   "conflict_policy",
+							Code:    deployments.ValidationCodeInvalid,
+							Message: deployments.ErrInvalidConflictPolicy.Error(),
+						},
+					},
+				},
+			},
+		},
+		{
+			InputBodyObject: &deployments.DeploymentConstructor{
+				Name:         StringToPointer("NYC Production"),
+				ArtifactName: StringToPointer("App 123"),
+				Devices:      []string{"f826484e-1157-4109-af21-304e6d711560", "bad id"},
+			},
+			JSONResponseParams: h.JSONResponseParams{
+				OutputStatus: http.StatusBadRequest,
+				OutputBodyObject: map[string]interface{}{
+					"error":      "Validating request body: devices[1]: " + deployments.ErrInvalidDeviceID.Error() + ";",
+					"request_id": "test",
+					"fields": []deployments.FieldError{
+						{
+							Field:   "devices[1]",
+							Code:    deployments.ValidationCodeInvalid,
+							Message: deployments.ErrInvalidDeviceID.Error(),
+						},
+					},
+				},
+			},
+		},
+		{
+			InputBodyObject: &deployments.DeploymentConstructor{
+				Name:         StringToPointer("NYC Production"),
+				ArtifactName: StringToPointer("App 123"),
+				Devices:      []string{"f826484e-1157-4109-af21-304e6d711560"},
+			},
+			InputModelError: ErrNoArtifact,
+			JSONResponseParams: h.JSONResponseParams{
+				OutputStatus: http.StatusUnprocessableEntity,
+				OutputBodyObject: map[string]interface{}{
+					"error":      ErrNoArtifact.Error(),
+					"request_id": "test",
+					"fields": []deployments.FieldError{
+						{
+							Field:   "artifact_name",
+							Code:    deployments.ValidationCodeNotFound,
+							Message: ErrNoArtifact.Error(),
+						},
+					},
+				},
+			},
+		},
+		{
+			InputBodyObject: &deployments.DeploymentConstructor{
+				Name:       StringToPointer("NYC Production"),
+				ArtifactID: validUUIDv4,
+				Devices:    []string{"f826484e-1157-4109-af21-304e6d711560"},
+			},
+			InputModelError: ErrNoArtifact,
+			JSONResponseParams: h.JSONResponseParams{
+				OutputStatus: http.StatusUnprocessableEntity,
+				OutputBodyObject: map[string]interface{}{
+					"error":      ErrNoArtifact.Error(),
+					"request_id": "test",
+					"fields": []deployments.FieldError{
+						{
+							Field:   "artifact_id",
+							Code:    deployments.ValidationCodeNotFound,
+							Message: ErrNoArtifact.Error(),
+						},
+					},
+				},
+			},
+		},
+		{
+			InputBodyObject: &deployments.DeploymentConstructor{
+				Name:         StringToPointer("NYC Production"),
+				ArtifactName: StringToPointer("App 124"),
+				ArtifactID:   validUUIDv4,
+				Devices:      []string{"f826484e-1157-4109-af21-304e6d711560"},
+			},
+			InputModelError: deployments.NewValidationError("artifact_name",
+				deployments.ValidationCodeInvalid, "does not match"),
+			JSONResponseParams: h.JSONResponseParams{
+				OutputStatus: http.StatusBadRequest,
+				OutputBodyObject: map[string]interface{}{
+					"error":      "artifact_name: does not match;",
+					"request_id": "test",
+					"fields": []deployments.FieldError{
+						{
+							Field:   "artifact_name",
+							Code:    deployments.ValidationCodeInvalid,
+							Message: "does not match",
+						},
+					},
+				},
+			},
+		},
+		{
+			InputBodyObject: &deployments.DeploymentConstructor{
+				Name:         StringToPointer("NYC Production"),
+				ArtifactName: StringToPointer("App 123"),
+				Devices:      []string{"f826484e-1157-4109-af21-304e6d711560"},
+			},
+			InputModelError: ErrDeploymentFrozen,
+			JSONResponseParams: h.JSONResponseParams{
+				OutputStatus:     http.StatusConflict,
+				OutputBodyObject: h.ErrorToErrStruct(ErrDeploymentFrozen),
+			},
+		},
+		{
+			InputBodyObject: &deployments.DeploymentConstructor{
+				Name:         StringToPointer("NYC Production"),
+				ArtifactName: StringToPointer("App 123"),
+				Devices:      []string{"f826484e-1157-4109-af21-304e6d711560"},
+				Slug:         "nyc-production",
+			},
+			InputModelError: ErrDeploymentSlugTaken,
+			JSONResponseParams: h.JSONResponseParams{
+				OutputStatus:     http.StatusConflict,
+				OutputBodyObject: h.ErrorToErrStruct(ErrDeploymentSlugTaken),
+			},
+		},
 	}
 
 	for testCaseNumber, testCase := range testCases {
@@ -424,6 +710,9 @@ func TestControllerPostDeployment(t *testing.T) {
 			deploymentModel.On("CreateDeployment",
 				h.ContextMatcher(), testCase.InputBodyObject).
 				Return(testCase.InputModelID, testCase.InputModelError)
+			deploymentModel.On("EstimateDeployment",
+				h.ContextMatcher(), testCase.InputModelID).
+				Return(testCase.InputEstimate, testCase.InputEstimateError)
 
 			router, err := rest.MakeRouter(
 				rest.Post("/r",
@@ -442,13 +731,220 @@ func TestControllerPostDeployment(t *testing.T) {
 	}
 }
 
+func TestControllerPostDeploymentOverrideFreeze(t *testing.T) {
+
+	t.Parallel()
+
+	deploymentModel := new(mocks.DeploymentsModel)
+	deploymentModel.On("CreateDeployment",
+		h.ContextMatcher(), &deployments.DeploymentConstructor{
+			Name:           StringToPointer("NYC Production"),
+			ArtifactName:   StringToPointer("App 123"),
+			Devices:        []string{"f826484e-1157-4109-af21-304e6d711560"},
+			OverrideFreeze: true,
+		}).
+		Return("1234", nil)
+	deploymentModel.On("EstimateDeployment", h.ContextMatcher(), "1234").
+		Return(nil, nil)
+
+	router, err := rest.MakeRouter(
+		rest.Post("/r/override-freeze",
+			NewDeploymentsController(deploymentModel,
+				new(view.DeploymentsView)).PostDeploymentOverrideFreeze))
+	assert.NoError(t, err)
+
+	api := makeApi(router)
+
+	req := test.MakeSimpleRequest("POST", "http://localhost/r/override-freeze",
+		&deployments.DeploymentConstructor{
+			Name:         StringToPointer("NYC Production"),
+			ArtifactName: StringToPointer("App 123"),
+			Devices:      []string{"f826484e-1157-4109-af21-304e6d711560"},
+		})
+	req.Header.Add(requestid.RequestIdHeader, "test")
+	recorded := test.RunRequest(t, api.MakeHandler(), req)
+
+	h.CheckRecordedResponse(t, recorded, h.JSONResponseParams{
+		OutputStatus:  http.StatusCreated,
+		OutputHeaders: map[string]string{"Location": "./r/1234"},
+	})
+}
+
+func TestControllerPostDeploymentLimits(t *testing.T) {
+
+	t.Parallel()
+
+	devices := []string{
+		"f826484e-1157-4109-af21-304e6d711560",
+		"a9e7fa20-6ec8-4b79-a0ef-cbdfc3dc5a39",
+	}
+
+	testCases := map[string]struct {
+		deploymentsLimit *limits.Limit
+		deploymentsErr   error
+		lookup           []*deployments.Deployment
+		createErr        error
+
+		status int
+		err    string
+	}{
+		"ok": {
+			deploymentsLimit: &limits.Limit{Value: 2},
+			lookup:           []*deployments.Deployment{{}},
+
+			status: http.StatusCreated,
+		},
+		"ok, no limits": {
+			deploymentsLimit: &limits.Limit{},
+
+			status: http.StatusCreated,
+		},
+		"too many devices": {
+			deploymentsLimit: &limits.Limit{},
+			createErr: &limits.ExceededError{
+				Limit: limits.Limit{Name: limits.LimitDevicesPerDeployment, Value: 1},
+			},
+
+			status: http.StatusUnprocessableEntity,
+			err:    "devices_per_deployment limit of 1 exceeded",
+		},
+		"too many deployments": {
+			deploymentsLimit: &limits.Limit{
+				Name:  limits.LimitDeploymentsPerDay,
+				Value: 2,
+			},
+			lookup: []*deployments.Deployment{{}, {}},
+
+			status: http.StatusTooManyRequests,
+			err:    "deployments_per_day limit of 2 exceeded",
+		},
+		"error": {
+			deploymentsErr: errors.New("db error"),
+
+			status: http.StatusInternalServerError,
+			err:    "internal error",
+		},
+	}
+
+	for name := range testCases {
+		tc := testCases[name]
+
+		t.Run(name, func(t *testing.T) {
+			deploymentModel := new(mocks.DeploymentsModel)
+			limitsModel := new(mocks.LimitsModel)
+
+			limitsModel.On("GetLimit", h.ContextMatcher(),
+				limits.LimitDeploymentsPerDay).
+				Return(tc.deploymentsLimit, tc.deploymentsErr)
+			if tc.lookup != nil {
+				deploymentModel.On("LookupDeployment", h.ContextMatcher(),
+					mock.MatchedBy(func(q deployments.Query) bool {
+						return q.CreatedAfter != nil &&
+							q.Limit == int(tc.deploymentsLimit.Value)
+					})).
+					Return(tc.lookup, nil)
+			}
+			if tc.status == http.StatusCreated || tc.createErr != nil {
+				deploymentModel.On("CreateDeployment", h.ContextMatcher(),
+					mock.AnythingOfType("*deployments.DeploymentConstructor")).
+					Return("1234", tc.createErr)
+			}
+			if tc.status == http.StatusCreated {
+				deploymentModel.On("EstimateDeployment", h.ContextMatcher(), "1234").
+					Return(nil, nil)
+			}
+
+			controller := NewDeploymentsController(deploymentModel,
+				new(view.DeploymentsView))
+			controller.SetLimits(limitsModel)
+			router, err := rest.MakeRouter(rest.Post("/r", controller.PostDeployment))
+			assert.NoError(t, err)
+
+			api := makeApi(router)
+
+			req := test.MakeSimpleRequest("POST", "http://localhost/r",
+				&deployments.DeploymentConstructor{
+					Name:         StringToPointer("NYC Production"),
+					ArtifactName: StringToPointer("App 123"),
+					Devices:      devices,
+				})
+			req.Header.Add(requestid.RequestIdHeader, "test")
+			recorded := test.RunRequest(t, api.MakeHandler(), req)
+
+			recorded.CodeIs(tc.status)
+			if tc.err != "" {
+				h.CheckRecordedResponse(t, recorded, h.JSONResponseParams{
+					OutputStatus:     tc.status,
+					OutputBodyObject: h.ErrorToErrStruct(errors.New(tc.err)),
+				})
+			}
+			limitsModel.AssertExpectations(t)
+			deploymentModel.AssertExpectations(t)
+		})
+	}
+}
+
+func TestControllerPostDeploymentStrictJSON(t *testing.T) {
+
+	t.Parallel()
+
+	body := map[string]interface{}{
+		"name":          "NYC Production",
+		"artifact_nmae": "App 123",
+		"devices":       []string{"f826484e-1157-4109-af21-304e6d711560"},
+	}
+
+	controller := NewDeploymentsController(new(mocks.DeploymentsModel),
+		new(view.DeploymentsView))
+	router, err := rest.MakeRouter(rest.Post("/r", controller.PostDeployment))
+	assert.NoError(t, err)
+
+	// misspelled field is ignored by default
+	api := makeApi(router)
+
+	req := test.MakeSimpleRequest("POST", "http://localhost/r", body)
+	req.Header.Add(requestid.RequestIdHeader, "test")
+	recorded := test.RunRequest(t, api.MakeHandler(), req)
+
+	h.CheckRecordedResponse(t, recorded, h.JSONResponseParams{
+		OutputStatus: http.StatusBadRequest,
+		OutputBodyObject: map[string]interface{}{
+			"error":      "Validating request body: artifact_name: value is required;",
+			"request_id": "test",
+			"fields": []deployments.FieldError{
+				{Field: "artifact_name", Code: deployments.ValidationCodeRequired, Message: "value is required"},
+			},
+		},
+	})
+
+	// and rejected in strict mode
+	api = makeApi(router)
+	api.Use(&restutil.StrictJSONMiddleware{})
+
+	req = test.MakeSimpleRequest("POST", "http://localhost/r", body)
+	req.Header.Add(requestid.RequestIdHeader, "test")
+	recorded = test.RunRequest(t, api.MakeHandler(), req)
+
+	h.CheckRecordedResponse(t, recorded, h.JSONResponseParams{
+		OutputStatus: http.StatusBadRequest,
+		OutputBodyObject: map[string]interface{}{
+			"error":      `Validating request body: artifact_nmae: unknown field "artifact_nmae";`,
+			"request_id": "test",
+			"fields": []deployments.FieldError{
+				{Field: "artifact_nmae", Code: deployments.ValidationCodeUnknown, Message: `unknown field "artifact_nmae"`},
+			},
+		},
+	})
+}
+
 func TestControllerPutDeploymentStatus(t *testing.T) {
 
 	t.Parallel()
 
 	type report struct {
-		Status   string `json:"status"`
-		SubState string `json:"substate,omitempty"`
+		Status   string                             `json:"status"`
+		SubState string                             `json:"substate,omitempty"`
+		Error    *deployments.DeviceDeploymentError `json:"error,omitempty"`
 	}
 
 	testCases := []struct {
@@ -522,6 +1018,23 @@ func TestControllerPutDeploymentStatus(t *testing.T) {
 				"Authorization": makeDeviceAuthHeader(`{"sub": "device-id-4"}`),
 			},
 		},
+		{
+			// success -> installing, rejected by the store
+			InputBodyObject:        &report{Status: "installing"},
+			InputModelDeploymentID: "f826484e-1157-4109-af21-304e6d711560",
+			InputModelDeviceID:     "device-id-2",
+			InputModelStatus:       &deployments.DeviceDeploymentStatus{Status: "installing"},
+			InputModelError: deployments.NewStoreError("UpdateDeviceDeploymentStatus",
+				"devices", deployments.ErrStorageFinishedStatus, "device-id-2"),
+
+			JSONResponseParams: h.JSONResponseParams{
+				OutputStatus:     http.StatusConflict,
+				OutputBodyObject: h.ErrorToErrStruct(deployments.ErrStorageFinishedStatus),
+			},
+			Headers: map[string]string{
+				"Authorization": makeDeviceAuthHeader(`{"sub": "device-id-2"}`),
+			},
+		},
 		{
 			// aborted -> installing, forbidden
 			InputBodyObject:        &report{Status: "installing"},
@@ -575,30 +1088,77 @@ func TestControllerPutDeploymentStatus(t *testing.T) {
 			},
 		},
 		{
-			// substate too long
+			// failure with error details
 			InputBodyObject: &report{
-				Status: "installing",
-				// 202 chars
-				SubState: "ppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppff",
+				Status: "failure",
+				Error: &deployments.DeviceDeploymentError{
+					Code:     "ENOSPC",
+					Category: "storage-full",
+				},
 			},
 			InputModelDeploymentID: "f826484e-1157-4109-af21-304e6d711561",
 			InputModelDeviceID:     "device-id-2",
-			InputModelStatus:       nil,
+			InputModelStatus: &deployments.DeviceDeploymentStatus{
+				Status: "failure",
+				Error: &deployments.DeviceDeploymentError{
+					Code:     "ENOSPC",
+					Category: "storage-full",
+				},
+			},
 
 			JSONResponseParams: h.JSONResponseParams{
-				OutputStatus:     http.StatusBadRequest,
-				OutputBodyObject: h.ErrorToErrStruct(errors.New("SubState: ppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppff does not validate as length(0|200);")),
+				OutputStatus:     http.StatusNoContent,
+				OutputBodyObject: nil,
 			},
 			Headers: map[string]string{
 				"Authorization": makeDeviceAuthHeader(`{"sub": "device-id-2"}`),
 			},
 		},
-	}
-
-	for testCaseNumber, testCase := range testCases {
-
-		t.Run(fmt.Sprintf("test case %d", testCaseNumber+1), func(t *testing.T) {
-
+		{
+			// error details with non-failure status
+			InputBodyObject: &report{
+				Status: "success",
+				Error: &deployments.DeviceDeploymentError{
+					Category: "storage-full",
+				},
+			},
+			InputModelDeploymentID: "f826484e-1157-4109-af21-304e6d711561",
+			InputModelDeviceID:     "device-id-2",
+			InputModelStatus:       nil,
+
+			JSONResponseParams: h.JSONResponseParams{
+				OutputStatus:     http.StatusBadRequest,
+				OutputBodyObject: h.ErrorToErrStruct(ErrUnexpectedError),
+			},
+			Headers: map[string]string{
+				"Authorization": makeDeviceAuthHeader(`{"sub": "device-id-2"}`),
+			},
+		},
+		{
+			// substate too long
+			InputBodyObject: &report{
+				Status: "installing",
+				// 202 chars
+				SubState: "ppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppff",
+			},
+			InputModelDeploymentID: "f826484e-1157-4109-af21-304e6d711561",
+			InputModelDeviceID:     "device-id-2",
+			InputModelStatus:       nil,
+
+			JSONResponseParams: h.JSONResponseParams{
+				OutputStatus:     http.StatusBadRequest,
+				OutputBodyObject: h.ErrorToErrStruct(errors.New("SubState: ppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppppff does not validate as length(0|200);")),
+			},
+			Headers: map[string]string{
+				"Authorization": makeDeviceAuthHeader(`{"sub": "device-id-2"}`),
+			},
+		},
+	}
+
+	for testCaseNumber, testCase := range testCases {
+
+		t.Run(fmt.Sprintf("test case %d", testCaseNumber+1), func(t *testing.T) {
+
 			deploymentModel := new(mocks.DeploymentsModel)
 
 			if testCase.InputModelStatus != nil {
@@ -723,226 +1283,138 @@ func TestControllerGetDeploymentStats(t *testing.T) {
 	}
 }
 
-func TestControllerGetDeviceStatusesForDeployment(t *testing.T) {
-	t.Parallel()
-
-	// common device status list for all tests
+func TestControllerGetDeploymentFailureStats(t *testing.T) {
 
-	statuses := []deployments.DeviceDeployment{
-		*deployments.NewDeviceDeployment("device0001", "30b3e62c-9ec2-4312-a7fa-cff24cc7397a"),
-		*deployments.NewDeviceDeployment("device0002", "30b3e62c-9ec2-4312-a7fa-cff24cc7397a"),
-		*deployments.NewDeviceDeployment("device0003", "30b3e62c-9ec2-4312-a7fa-cff24cc7397a"),
-	}
+	t.Parallel()
 
-	testCases := map[string]struct {
+	testCases := []struct {
 		h.JSONResponseParams
 
-		deploymentID  string
-		modelStatuses []deployments.DeviceDeployment
-		modelErr      error
+		InputModelDeploymentID string
+		InputModelStats        deployments.FailureStats
+		InputModelError        error
 	}{
-		"existing deployment and statuses": {
-			JSONResponseParams: h.JSONResponseParams{
-				OutputStatus:     http.StatusOK,
-				OutputBodyObject: statuses,
-			},
-			deploymentID:  "30b3e62c-9ec2-4312-a7fa-cff24cc7397a",
-			modelStatuses: statuses,
-			modelErr:      nil,
-		},
-		"deployment ID format error": {
+		{
+			InputModelDeploymentID: "bad-id",
+
 			JSONResponseParams: h.JSONResponseParams{
 				OutputStatus:     http.StatusBadRequest,
-				OutputBodyObject: h.ErrorToErrStruct(errors.New("ID is not UUIDv4")),
+				OutputBodyObject: h.ErrorToErrStruct(ErrIDNotUUIDv4),
 			},
-			deploymentID:  "30b3e62c9ec24312a7facff24cc7397a",
-			modelStatuses: nil,
-			modelErr:      nil,
 		},
-		"model error: deployment doesn't exist": {
+		{
+			InputModelDeploymentID: "f826484e-1157-4109-af21-304e6d711560",
+
 			JSONResponseParams: h.JSONResponseParams{
 				OutputStatus:     http.StatusNotFound,
-				OutputBodyObject: h.ErrorToErrStruct(errors.New("Deployment not found")),
+				OutputBodyObject: h.ErrorToErrStruct(errors.New("Resource not found")),
 			},
-			deploymentID:  "30b3e62c-9ec2-4312-a7fa-cff24cc7397a",
-			modelStatuses: nil,
-			modelErr:      ErrModelDeploymentNotFound,
 		},
-		"unknown model error": {
+		{
+			InputModelDeploymentID: "f826484e-1157-4109-af21-304e6d711560",
+			InputModelError:        errors.New("storage issue"),
+
 			JSONResponseParams: h.JSONResponseParams{
 				OutputStatus:     http.StatusInternalServerError,
 				OutputBodyObject: h.ErrorToErrStruct(errors.New("internal error")),
 			},
-			deploymentID:  "30b3e62c-9ec2-4312-a7fa-cff24cc7397a",
-			modelStatuses: nil,
-			modelErr:      errors.New("some unknown error"),
+		},
+		{
+			InputModelDeploymentID: "23bbc7ba-3278-4b1c-a345-4080afe59e96",
+			InputModelStats: deployments.FailureStats{
+				"signature-mismatch":                  3,
+				"storage-full":                        1,
+				deployments.FailureStatsUncategorized: 2,
+			},
+
+			JSONResponseParams: h.JSONResponseParams{
+				OutputStatus: http.StatusOK,
+				OutputBodyObject: deployments.FailureStats{
+					"signature-mismatch":                  3,
+					"storage-full":                        1,
+					deployments.FailureStatsUncategorized: 2,
+				},
+			},
 		},
 	}
 
-	for caseName, tc := range testCases {
+	for testCaseNumber, testCase := range testCases {
 
-		t.Run(caseName, func(t *testing.T) {
+		t.Run(fmt.Sprintf("test case %d", testCaseNumber+1), func(t *testing.T) {
 
 			deploymentModel := new(mocks.DeploymentsModel)
-			deploymentModel.On("GetDeviceStatusesForDeployment",
-				h.ContextMatcher(), tc.deploymentID).
-				Return(tc.modelStatuses, tc.modelErr)
+
+			deploymentModel.On("GetDeploymentFailureStats",
+				h.ContextMatcher(), testCase.InputModelDeploymentID).
+				Return(testCase.InputModelStats, testCase.InputModelError)
 
 			router, err := rest.MakeRouter(
 				rest.Get("/r/:id",
 					NewDeploymentsController(deploymentModel,
-						new(view.DeploymentsView)).GetDeviceStatusesForDeployment))
+						new(view.DeploymentsView)).GetDeploymentFailureStats))
 
 			assert.NoError(t, err)
 
 			api := makeApi(router)
 
-			req := test.MakeSimpleRequest("GET", "http://localhost/r/"+tc.deploymentID, nil)
+			req := test.MakeSimpleRequest("GET", "http://localhost/r/"+testCase.InputModelDeploymentID,
+				nil)
 			req.Header.Add(requestid.RequestIdHeader, "test")
 			recorded := test.RunRequest(t, api.MakeHandler(), req)
 
-			h.CheckRecordedResponse(t, recorded, tc.JSONResponseParams)
+			h.CheckRecordedResponse(t, recorded, testCase.JSONResponseParams)
 		})
 	}
 }
 
-func TestControllerLookupDeployment(t *testing.T) {
+func TestControllerGetDeploymentDurationStats(t *testing.T) {
 
 	t.Parallel()
 
-	someDeployments := []*deployments.Deployment{
-		{
-			DeploymentConstructor: &deployments.DeploymentConstructor{
-				Name:         StringToPointer("zen"),
-				ArtifactName: StringToPointer("baz"),
-				Devices:      []string{"device0001", "device0002", "device0003"},
-			},
-			Id: StringToPointer("a108ae14-bb4e-455f-9b40-2ef4bab97bb7"),
-		},
-		{
-			DeploymentConstructor: &deployments.DeploymentConstructor{
-				Name:         StringToPointer("foo"),
-				ArtifactName: StringToPointer("bar"),
-				Devices:      []string{"device0001", "device0002", "device0003"},
-			},
-			Id: StringToPointer("e8c32ff6-7c1b-43c7-aa31-2e4fc3a3c130"),
-		},
-	}
-
-	statuses := []deployments.DeviceDeployment{
-		*deployments.NewDeviceDeployment("device0001", "a108ae14-bb4e-455f-9b40-2ef4bab97bb7"),
-		*deployments.NewDeviceDeployment("device0002", "a108ae14-bb4e-455f-9b40-2ef4bab97bb7"),
-		*deployments.NewDeviceDeployment("device0003", "a108ae14-bb4e-455f-9b40-2ef4bab97bb7"),
-	}
+	durations := deployments.NewDurationStats()
+	durations[deployments.DeviceDeploymentStatusSuccess][0].Count = 12
+	durations[deployments.DeviceDeploymentStatusSuccess][1].Count = 3
+	durations[deployments.DeviceDeploymentStatusFailure][6].Count = 1
 
 	testCases := []struct {
 		h.JSONResponseParams
 
-		SearchStatus string
-
-		InputModelQuery       deployments.Query
-		InputModelError       error
-		InputModelDeployments []*deployments.Deployment
-		DeviceStatuses        []deployments.DeviceDeployment
+		InputModelDeploymentID string
+		InputModelStats        deployments.DurationStats
+		InputModelError        error
 	}{
 		{
-			InputModelQuery: deployments.Query{
-				SearchText: " ",
-				Limit:      2,
-			},
-			InputModelError: errors.New("bad query"),
-
-			JSONResponseParams: h.JSONResponseParams{
-				OutputStatus:     http.StatusBadRequest,
-				OutputBodyObject: h.ErrorToErrStruct(errors.New("bad query")),
-			},
-		},
-		{
-			SearchStatus:    "badstatus",
-			InputModelError: errors.New("bad query"),
+			InputModelDeploymentID: "bad-id",
 
 			JSONResponseParams: h.JSONResponseParams{
 				OutputStatus:     http.StatusBadRequest,
-				OutputBodyObject: h.ErrorToErrStruct(errors.New("unknown status badstatus")),
+				OutputBodyObject: h.ErrorToErrStruct(ErrIDNotUUIDv4),
 			},
 		},
 		{
-			InputModelQuery: deployments.Query{
-				SearchText: "foo-not-found",
-				Limit:      2,
-			},
-			InputModelDeployments: []*deployments.Deployment{},
+			InputModelDeploymentID: "f826484e-1157-4109-af21-304e6d711560",
 
 			JSONResponseParams: h.JSONResponseParams{
-				OutputStatus:     http.StatusOK,
-				OutputBodyObject: []*deployments.Deployment{},
+				OutputStatus:     http.StatusNotFound,
+				OutputBodyObject: h.ErrorToErrStruct(errors.New("Resource not found")),
 			},
 		},
 		{
-			InputModelQuery: deployments.Query{
-				SearchText: "foo",
-				Status:     deployments.StatusQueryInProgress,
-				Limit:      2,
-			},
-			SearchStatus:          "inprogress",
-			InputModelDeployments: someDeployments,
+			InputModelDeploymentID: "f826484e-1157-4109-af21-304e6d711560",
+			InputModelError:        errors.New("storage issue"),
 
 			JSONResponseParams: h.JSONResponseParams{
-				OutputStatus: http.StatusOK,
-				OutputBodyObject: []struct {
-					deployments.Deployment
-					Status string `json:"status"`
-				}{
-					{
-						Deployment: deployments.Deployment{
-							DeploymentConstructor: &deployments.DeploymentConstructor{
-								Name:         StringToPointer("zen"),
-								ArtifactName: StringToPointer("baz"),
-							},
-							Id: StringToPointer("a108ae14-bb4e-455f-9b40-2ef4bab97bb7"),
-						},
-						Status: "finished",
-					},
-					{
-						Deployment: deployments.Deployment{
-							DeploymentConstructor: &deployments.DeploymentConstructor{
-								Name:         StringToPointer("foo"),
-								ArtifactName: StringToPointer("bar"),
-							},
-							Id: StringToPointer("e8c32ff6-7c1b-43c7-aa31-2e4fc3a3c130"),
-						},
-						Status: "finished",
-					},
-				},
+				OutputStatus:     http.StatusInternalServerError,
+				OutputBodyObject: h.ErrorToErrStruct(errors.New("internal error")),
 			},
 		},
 		{
-			InputModelQuery: deployments.Query{
-				SearchText: "foo",
-				Status:     deployments.StatusQueryInProgress,
-				Limit:      1,
-			},
-			SearchStatus:          "inprogress",
-			InputModelDeployments: someDeployments,
-			DeviceStatuses:        statuses,
+			InputModelDeploymentID: "23bbc7ba-3278-4b1c-a345-4080afe59e96",
+			InputModelStats:        durations,
 
 			JSONResponseParams: h.JSONResponseParams{
-				OutputStatus: http.StatusOK,
-				OutputBodyObject: []struct {
-					deployments.Deployment
-					Status string `json:"status"`
-				}{
-					{
-						Deployment: deployments.Deployment{
-							DeploymentConstructor: &deployments.DeploymentConstructor{
-								Name:         StringToPointer("zen"),
-								ArtifactName: StringToPointer("baz"),
-							},
-							Id: StringToPointer("a108ae14-bb4e-455f-9b40-2ef4bab97bb7"),
-						},
-						Status: "finished",
-					},
-				},
+				OutputStatus:     http.StatusOK,
+				OutputBodyObject: durations,
 			},
 		},
 	}
@@ -953,275 +1425,3300 @@ func TestControllerLookupDeployment(t *testing.T) {
 
 			deploymentModel := new(mocks.DeploymentsModel)
 
-			deploymentModel.On("LookupDeployment",
-				h.ContextMatcher(), mock.AnythingOfType("deployments.Query")).
-				Return(testCase.InputModelDeployments, testCase.InputModelError)
-
-			deploymentModel.On("GetDeviceStatusesForDeployment",
-				h.ContextMatcher(), mock.AnythingOfType("string")).
-				Return(testCase.DeviceStatuses, nil)
+			deploymentModel.On("GetDeploymentDurationStats",
+				h.ContextMatcher(), testCase.InputModelDeploymentID).
+				Return(testCase.InputModelStats, testCase.InputModelError)
 
 			router, err := rest.MakeRouter(
-				rest.Get("/r",
+				rest.Get("/r/:id",
 					NewDeploymentsController(deploymentModel,
-						new(view.DeploymentsView)).LookupDeployment))
+						new(view.DeploymentsView)).GetDeploymentDurationStats))
 
 			assert.NoError(t, err)
 
 			api := makeApi(router)
 
-			u := url.URL{
-				Scheme: "http",
-				Host:   "localhost",
-				Path:   "/r",
-			}
-			q := u.Query()
-			q.Set("search", testCase.InputModelQuery.SearchText)
-			if testCase.SearchStatus != "" {
-				q.Set("status", testCase.SearchStatus)
-			}
-
+			req := test.MakeSimpleRequest("GET", "http://localhost/r/"+testCase.InputModelDeploymentID,
+				nil)
+			req.Header.Add(requestid.RequestIdHeader, "test")
+			recorded := test.RunRequest(t, api.MakeHandler(), req)
+
+			h.CheckRecordedResponse(t, recorded, testCase.JSONResponseParams)
+		})
+	}
+}
+
+func TestControllerGetDeploymentTelemetryStats(t *testing.T) {
+
+	t.Parallel()
+
+	stats := deployments.TelemetryStats{
+		deployments.DeviceDeploymentStatusFailure: {
+			deployments.TelemetryBatteryLevel: {Count: 2, Min: 5, Max: 15, Avg: 10},
+		},
+	}
+
+	testCases := map[string]struct {
+		h.JSONResponseParams
+
+		InputModelDeploymentID string
+		InputModelStats        deployments.TelemetryStats
+		InputModelError        error
+	}{
+		"bad id": {
+			InputModelDeploymentID: "bad-id",
+
+			JSONResponseParams: h.JSONResponseParams{
+				OutputStatus:     http.StatusBadRequest,
+				OutputBodyObject: h.ErrorToErrStruct(ErrIDNotUUIDv4),
+			},
+		},
+		"not found": {
+			InputModelDeploymentID: "f826484e-1157-4109-af21-304e6d711560",
+
+			JSONResponseParams: h.JSONResponseParams{
+				OutputStatus:     http.StatusNotFound,
+				OutputBodyObject: h.ErrorToErrStruct(errors.New("Resource not found")),
+			},
+		},
+		"model error": {
+			InputModelDeploymentID: "f826484e-1157-4109-af21-304e6d711560",
+			InputModelError:        errors.New("storage issue"),
+
+			JSONResponseParams: h.JSONResponseParams{
+				OutputStatus:     http.StatusInternalServerError,
+				OutputBodyObject: h.ErrorToErrStruct(errors.New("internal error")),
+			},
+		},
+		"ok": {
+			InputModelDeploymentID: "23bbc7ba-3278-4b1c-a345-4080afe59e96",
+			InputModelStats:        stats,
+
+			JSONResponseParams: h.JSONResponseParams{
+				OutputStatus:     http.StatusOK,
+				OutputBodyObject: stats,
+			},
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+
+			deploymentModel := new(mocks.DeploymentsModel)
+
+			deploymentModel.On("GetDeploymentTelemetryStats",
+				h.ContextMatcher(), testCase.InputModelDeploymentID).
+				Return(testCase.InputModelStats, testCase.InputModelError)
+
+			router, err := rest.MakeRouter(
+				rest.Get("/r/:id",
+					NewDeploymentsController(deploymentModel,
+						new(view.DeploymentsView)).GetDeploymentTelemetryStats))
+
+			assert.NoError(t, err)
+
+			api := makeApi(router)
+
+			req := test.MakeSimpleRequest("GET", "http://localhost/r/"+testCase.InputModelDeploymentID,
+				nil)
+			req.Header.Add(requestid.RequestIdHeader, "test")
+			recorded := test.RunRequest(t, api.MakeHandler(), req)
+
+			h.CheckRecordedResponse(t, recorded, testCase.JSONResponseParams)
+		})
+	}
+}
+
+func TestControllerGetDeploymentPhaseStats(t *testing.T) {
+
+	t.Parallel()
+
+	start := time.Date(2018, 5, 1, 10, 0, 0, 0, time.UTC)
+	stats := []deployments.PhaseStats{
+		{
+			DeploymentPhase: deployments.DeploymentPhase{
+				BatchSize:   10,
+				StartTs:     &start,
+				DeviceCount: 1,
+				Activated:   &start,
+			},
+			Phase: 0,
+			Stats: deployments.NewDeviceDeploymentStats(),
+		},
+	}
+
+	testCases := map[string]struct {
+		h.JSONResponseParams
+
+		InputModelDeploymentID string
+		InputModelStats        []deployments.PhaseStats
+		InputModelError        error
+	}{
+		"bad id": {
+			InputModelDeploymentID: "bad-id",
+
+			JSONResponseParams: h.JSONResponseParams{
+				OutputStatus:     http.StatusBadRequest,
+				OutputBodyObject: h.ErrorToErrStruct(ErrIDNotUUIDv4),
+			},
+		},
+		"not found": {
+			InputModelDeploymentID: "f826484e-1157-4109-af21-304e6d711560",
+
+			JSONResponseParams: h.JSONResponseParams{
+				OutputStatus:     http.StatusNotFound,
+				OutputBodyObject: h.ErrorToErrStruct(errors.New("Resource not found")),
+			},
+		},
+		"model error": {
+			InputModelDeploymentID: "f826484e-1157-4109-af21-304e6d711560",
+			InputModelError:        errors.New("storage issue"),
+
+			JSONResponseParams: h.JSONResponseParams{
+				OutputStatus:     http.StatusInternalServerError,
+				OutputBodyObject: h.ErrorToErrStruct(errors.New("internal error")),
+			},
+		},
+		"not phased": {
+			InputModelDeploymentID: "23bbc7ba-3278-4b1c-a345-4080afe59e96",
+			InputModelStats:        []deployments.PhaseStats{},
+
+			JSONResponseParams: h.JSONResponseParams{
+				OutputStatus:     http.StatusOK,
+				OutputBodyObject: []deployments.PhaseStats{},
+			},
+		},
+		"ok": {
+			InputModelDeploymentID: "23bbc7ba-3278-4b1c-a345-4080afe59e96",
+			InputModelStats:        stats,
+
+			JSONResponseParams: h.JSONResponseParams{
+				OutputStatus:     http.StatusOK,
+				OutputBodyObject: stats,
+			},
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+
+			deploymentModel := new(mocks.DeploymentsModel)
+
+			deploymentModel.On("GetDeploymentPhaseStats",
+				h.ContextMatcher(), testCase.InputModelDeploymentID).
+				Return(testCase.InputModelStats, testCase.InputModelError)
+
+			router, err := rest.MakeRouter(
+				rest.Get("/r/:id",
+					NewDeploymentsController(deploymentModel,
+						new(view.DeploymentsView)).GetDeploymentPhaseStats))
+
+			assert.NoError(t, err)
+
+			api := makeApi(router)
+
+			req := test.MakeSimpleRequest("GET", "http://localhost/r/"+testCase.InputModelDeploymentID,
+				nil)
+			req.Header.Add(requestid.RequestIdHeader, "test")
+			recorded := test.RunRequest(t, api.MakeHandler(), req)
+
+			h.CheckRecordedResponse(t, recorded, testCase.JSONResponseParams)
+		})
+	}
+}
+
+func TestControllerGetStatsSummary(t *testing.T) {
+
+	t.Parallel()
+
+	summary := &deployments.StatsSummary{
+		Deployments: deployments.DeploymentsCount{
+			Pending:    1,
+			InProgress: 2,
+			Finished:   3,
+		},
+		DevicesUpdated24h: 4,
+		DevicesUpdated7d:  10,
+		FailureRate:       0.5,
+	}
+
+	testCases := []struct {
+		h.JSONResponseParams
+
+		InputModelSummary *deployments.StatsSummary
+		InputModelError   error
+	}{
+		{
+			InputModelError: errors.New("storage issue"),
+
+			JSONResponseParams: h.JSONResponseParams{
+				OutputStatus:     http.StatusInternalServerError,
+				OutputBodyObject: h.ErrorToErrStruct(errors.New("internal error")),
+			},
+		},
+		{
+			InputModelSummary: summary,
+
+			JSONResponseParams: h.JSONResponseParams{
+				OutputStatus:     http.StatusOK,
+				OutputBodyObject: summary,
+			},
+		},
+	}
+
+	for testCaseNumber, testCase := range testCases {
+
+		t.Run(fmt.Sprintf("test case %d", testCaseNumber+1), func(t *testing.T) {
+
+			deploymentModel := new(mocks.DeploymentsModel)
+
+			deploymentModel.On("GetStatsSummary", h.ContextMatcher()).
+				Return(testCase.InputModelSummary, testCase.InputModelError)
+
+			router, err := rest.MakeRouter(
+				rest.Get("/r",
+					NewDeploymentsController(deploymentModel,
+						new(view.DeploymentsView)).GetStatsSummary))
+
+			assert.NoError(t, err)
+
+			api := makeApi(router)
+
+			req := test.MakeSimpleRequest("GET", "http://localhost/r", nil)
+			req.Header.Add(requestid.RequestIdHeader, "test")
+			recorded := test.RunRequest(t, api.MakeHandler(), req)
+
+			h.CheckRecordedResponse(t, recorded, testCase.JSONResponseParams)
+		})
+	}
+}
+
+func TestControllerGetDeploymentStatsByGroup(t *testing.T) {
+
+	t.Parallel()
+
+	stats := deployments.GroupStats{
+		"site-a": deployments.Stats{
+			deployments.DeviceDeploymentStatusSuccess: 3,
+			deployments.DeviceDeploymentStatusFailure: 1,
+		},
+	}
+
+	testCases := map[string]struct {
+		h.JSONResponseParams
+
+		InputDeploymentID string
+		InputQuery        string
+
+		InputModelAttribute string
+		InputModelStats     deployments.GroupStats
+		InputModelError     error
+	}{
+		"invalid id": {
+			InputDeploymentID: "bad-id",
+
+			JSONResponseParams: h.JSONResponseParams{
+				OutputStatus:     http.StatusBadRequest,
+				OutputBodyObject: h.ErrorToErrStruct(ErrIDNotUUIDv4),
+			},
+		},
+		"not found": {
+			InputDeploymentID:   "f826484e-1157-4109-af21-304e6d711560",
+			InputModelAttribute: "group",
+
+			JSONResponseParams: h.JSONResponseParams{
+				OutputStatus:     http.StatusNotFound,
+				OutputBodyObject: h.ErrorToErrStruct(errors.New("Resource not found")),
+			},
+		},
+		"model error": {
+			InputDeploymentID:   "f826484e-1157-4109-af21-304e6d711560",
+			InputModelAttribute: "group",
+			InputModelError:     errors.New("inventory issue"),
+
+			JSONResponseParams: h.JSONResponseParams{
+				OutputStatus:     http.StatusInternalServerError,
+				OutputBodyObject: h.ErrorToErrStruct(errors.New("internal error")),
+			},
+		},
+		"by group": {
+			InputDeploymentID:   "f826484e-1157-4109-af21-304e6d711560",
+			InputModelAttribute: "group",
+			InputModelStats:     stats,
+
+			JSONResponseParams: h.JSONResponseParams{
+				OutputStatus:     http.StatusOK,
+				OutputBodyObject: stats,
+			},
+		},
+		"by attribute": {
+			InputDeploymentID:   "f826484e-1157-4109-af21-304e6d711560",
+			InputQuery:          "?by=region",
+			InputModelAttribute: "region",
+			InputModelStats:     stats,
+
+			JSONResponseParams: h.JSONResponseParams{
+				OutputStatus:     http.StatusOK,
+				OutputBodyObject: stats,
+			},
+		},
+	}
+
+	for name, testCase := range testCases {
+
+		t.Run(name, func(t *testing.T) {
+
+			deploymentModel := new(mocks.DeploymentsModel)
+
+			deploymentModel.On("GetDeploymentStatsByGroup",
+				h.ContextMatcher(), testCase.InputDeploymentID, testCase.InputModelAttribute).
+				Return(testCase.InputModelStats, testCase.InputModelError)
+
+			router, err := rest.MakeRouter(
+				rest.Get("/r/:id",
+					NewDeploymentsController(deploymentModel,
+						new(view.DeploymentsView)).GetDeploymentStatsByGroup))
+
+			assert.NoError(t, err)
+
+			api := makeApi(router)
+
+			req := test.MakeSimpleRequest("GET",
+				"http://localhost/r/"+testCase.InputDeploymentID+testCase.InputQuery, nil)
+			req.Header.Add(requestid.RequestIdHeader, "test")
+			recorded := test.RunRequest(t, api.MakeHandler(), req)
+
+			h.CheckRecordedResponse(t, recorded, testCase.JSONResponseParams)
+		})
+	}
+}
+
+func TestControllerGetLatestDeviceDeployment(t *testing.T) {
+
+	t.Parallel()
+
+	deviceDeployment := deployments.NewDeviceDeployment("dev1",
+		"f826484e-1157-4109-af21-304e6d711560")
+
+	testCases := map[string]struct {
+		h.JSONResponseParams
+
+		InputQuery string
+
+		InputModelDeploymentIDs []string
+		InputModelDeployment    *deployments.DeviceDeployment
+		InputModelError         error
+	}{
+		"found": {
+			InputModelDeployment: deviceDeployment,
+
+			JSONResponseParams: h.JSONResponseParams{
+				OutputStatus: http.StatusOK,
+				OutputBodyObject: struct {
+					*deployments.DeviceDeployment
+					DeploymentID *string `json:"deployment_id"`
+				}{
+					DeviceDeployment: deviceDeployment,
+					DeploymentID:     deviceDeployment.DeploymentId,
+				},
+			},
+		},
+		"filtered by deployments": {
+			InputQuery: "?tenant_id=acme&deployment_id=d1,d2&deployment_id=d3",
+
+			InputModelDeploymentIDs: []string{"d1", "d2", "d3"},
+
+			JSONResponseParams: h.JSONResponseParams{
+				OutputStatus:     http.StatusNotFound,
+				OutputBodyObject: h.ErrorToErrStruct(errors.New("Resource not found")),
+			},
+		},
+		"model error": {
+			InputModelError: errors.New("storage issue"),
+
+			JSONResponseParams: h.JSONResponseParams{
+				OutputStatus:     http.StatusInternalServerError,
+				OutputBodyObject: h.ErrorToErrStruct(errors.New("internal error")),
+			},
+		},
+	}
+
+	for name, testCase := range testCases {
+
+		t.Run(name, func(t *testing.T) {
+
+			deploymentModel := new(mocks.DeploymentsModel)
+
+			deploymentModel.On("GetLatestDeviceDeployment",
+				h.ContextMatcher(), "dev1", testCase.InputModelDeploymentIDs).
+				Return(testCase.InputModelDeployment, testCase.InputModelError)
+
+			router, err := rest.MakeRouter(
+				rest.Get("/r/:id",
+					NewDeploymentsController(deploymentModel,
+						new(view.DeploymentsView)).GetLatestDeviceDeployment))
+
+			assert.NoError(t, err)
+
+			api := makeApi(router)
+
+			req := test.MakeSimpleRequest("GET",
+				"http://localhost/r/dev1"+testCase.InputQuery, nil)
+			req.Header.Add(requestid.RequestIdHeader, "test")
+			recorded := test.RunRequest(t, api.MakeHandler(), req)
+
+			h.CheckRecordedResponse(t, recorded, testCase.JSONResponseParams)
+		})
+	}
+}
+
+func TestControllerGetActiveDeviceDeployments(t *testing.T) {
+
+	t.Parallel()
+
+	deviceDeployment := deployments.NewDeviceDeployment("dev1",
+		"f826484e-1157-4109-af21-304e6d711560")
+
+	testCases := map[string]struct {
+		h.JSONResponseParams
+
+		InputQuery string
+		InputBody  interface{}
+
+		InputModelDeviceIDs []string
+		InputModelActive    map[string][]deployments.DeviceDeployment
+		InputModelError     error
+	}{
+		"found": {
+			InputQuery: "?tenant_id=acme",
+			InputBody:  map[string]interface{}{"device_ids": []string{"dev1", "dev2"}},
+
+			InputModelDeviceIDs: []string{"dev1", "dev2"},
+			InputModelActive: map[string][]deployments.DeviceDeployment{
+				"dev1": {*deviceDeployment},
+				"dev2": {},
+			},
+
+			JSONResponseParams: h.JSONResponseParams{
+				OutputStatus: http.StatusOK,
+				OutputBodyObject: map[string]interface{}{
+					"dev1": []interface{}{
+						struct {
+							*deployments.DeviceDeployment
+							DeploymentID *string `json:"deployment_id"`
+						}{
+							DeviceDeployment: deviceDeployment,
+							DeploymentID:     deviceDeployment.DeploymentId,
+						},
+					},
+					"dev2": []interface{}{},
+				},
+			},
+		},
+		"no devices": {
+			InputBody: map[string]interface{}{"device_ids": []string{}},
+
+			JSONResponseParams: h.JSONResponseParams{
+				OutputStatus: http.StatusBadRequest,
+				OutputBodyObject: map[string]interface{}{
+					"error":      "Validating request body: device_ids: value is required;",
+					"request_id": "test",
+					"fields": []deployments.FieldError{
+						{
+							Field:   "device_ids",
+							Code:    deployments.ValidationCodeRequired,
+							Message: "value is required",
+						},
+					},
+				},
+			},
+		},
+		"unknown field": {
+			InputBody: map[string]interface{}{"devices": []string{"dev1"}},
+
+			JSONResponseParams: h.JSONResponseParams{
+				OutputStatus: http.StatusBadRequest,
+			},
+		},
+		"model error": {
+			InputBody: map[string]interface{}{"device_ids": []string{"dev1"}},
+
+			InputModelDeviceIDs: []string{"dev1"},
+			InputModelError:     errors.New("storage issue"),
+
+			JSONResponseParams: h.JSONResponseParams{
+				OutputStatus:     http.StatusInternalServerError,
+				OutputBodyObject: h.ErrorToErrStruct(errors.New("internal error")),
+			},
+		},
+	}
+
+	for name, testCase := range testCases {
+
+		t.Run(name, func(t *testing.T) {
+
+			deploymentModel := new(mocks.DeploymentsModel)
+
+			deploymentModel.On("GetActiveDeviceDeployments",
+				h.ContextMatcher(), testCase.InputModelDeviceIDs).
+				Return(testCase.InputModelActive, testCase.InputModelError)
+
+			router, err := rest.MakeRouter(
+				rest.Post("/r",
+					NewDeploymentsController(deploymentModel,
+						new(view.DeploymentsView)).GetActiveDeviceDeployments))
+
+			assert.NoError(t, err)
+
+			api := makeApi(router)
+
+			req := test.MakeSimpleRequest("POST",
+				"http://localhost/r"+testCase.InputQuery, testCase.InputBody)
+			req.Header.Add(requestid.RequestIdHeader, "test")
+			recorded := test.RunRequest(t, api.MakeHandler(), req)
+
+			if testCase.OutputBodyObject == nil {
+				recorded.CodeIs(testCase.OutputStatus)
+				deploymentModel.AssertNotCalled(t, "GetActiveDeviceDeployments",
+					mock.Anything, mock.Anything)
+				return
+			}
+			h.CheckRecordedResponse(t, recorded, testCase.JSONResponseParams)
+		})
+	}
+}
+
+func TestControllerGetDeviceStatusesForDeployment(t *testing.T) {
+	t.Parallel()
+
+	// common device status list for all tests
+
+	statuses := []deployments.DeviceDeployment{
+		*deployments.NewDeviceDeployment("device0001", "30b3e62c-9ec2-4312-a7fa-cff24cc7397a"),
+		*deployments.NewDeviceDeployment("device0002", "30b3e62c-9ec2-4312-a7fa-cff24cc7397a"),
+		*deployments.NewDeviceDeployment("device0003", "30b3e62c-9ec2-4312-a7fa-cff24cc7397a"),
+	}
+	statuses[2].Reason = deployments.NewNoArtifactReason("raspberrypi4")
+
+	testCases := map[string]struct {
+		h.JSONResponseParams
+
+		deploymentID    string
+		externalID      string
+		reason          string
+		modelDeviceID   string
+		modelResolveErr error
+		modelStatuses   []deployments.DeviceDeployment
+		modelErr        error
+	}{
+		"existing deployment and statuses": {
+			JSONResponseParams: h.JSONResponseParams{
+				OutputStatus:     http.StatusOK,
+				OutputBodyObject: statuses,
+			},
+			deploymentID:  "30b3e62c-9ec2-4312-a7fa-cff24cc7397a",
+			modelStatuses: statuses,
+			modelErr:      nil,
+		},
+		"deployment ID format error": {
+			JSONResponseParams: h.JSONResponseParams{
+				OutputStatus:     http.StatusBadRequest,
+				OutputBodyObject: h.ErrorToErrStruct(errors.New("ID is not UUIDv4")),
+			},
+			deploymentID:  "30b3e62c9ec24312a7facff24cc7397a",
+			modelStatuses: nil,
+			modelErr:      nil,
+		},
+		"model error: deployment doesn't exist": {
+			JSONResponseParams: h.JSONResponseParams{
+				OutputStatus:     http.StatusNotFound,
+				OutputBodyObject: h.ErrorToErrStruct(errors.New("Deployment not found")),
+			},
+			deploymentID:  "30b3e62c-9ec2-4312-a7fa-cff24cc7397a",
+			modelStatuses: nil,
+			modelErr:      ErrModelDeploymentNotFound,
+		},
+		"unknown model error": {
+			JSONResponseParams: h.JSONResponseParams{
+				OutputStatus:     http.StatusInternalServerError,
+				OutputBodyObject: h.ErrorToErrStruct(errors.New("internal error")),
+			},
+			deploymentID:  "30b3e62c-9ec2-4312-a7fa-cff24cc7397a",
+			modelStatuses: nil,
+			modelErr:      errors.New("some unknown error"),
+		},
+		"external ID": {
+			JSONResponseParams: h.JSONResponseParams{
+				OutputStatus:     http.StatusOK,
+				OutputBodyObject: statuses[1:2],
+			},
+			deploymentID:  "30b3e62c-9ec2-4312-a7fa-cff24cc7397a",
+			externalID:    "SN123",
+			modelDeviceID: "device0002",
+			modelStatuses: statuses,
+		},
+		"external ID, no device": {
+			JSONResponseParams: h.JSONResponseParams{
+				OutputStatus:     http.StatusOK,
+				OutputBodyObject: []deployments.DeviceDeployment{},
+			},
+			deploymentID:  "30b3e62c-9ec2-4312-a7fa-cff24cc7397a",
+			externalID:    "SN123",
+			modelStatuses: statuses,
+		},
+		"external ID, disabled": {
+			JSONResponseParams: h.JSONResponseParams{
+				OutputStatus:     http.StatusBadRequest,
+				OutputBodyObject: h.ErrorToErrStruct(ErrExternalIDDisabled),
+			},
+			deploymentID:    "30b3e62c-9ec2-4312-a7fa-cff24cc7397a",
+			externalID:      "SN123",
+			modelResolveErr: ErrExternalIDDisabled,
+		},
+		"external ID, inventory error": {
+			JSONResponseParams: h.JSONResponseParams{
+				OutputStatus:     http.StatusInternalServerError,
+				OutputBodyObject: h.ErrorToErrStruct(errors.New("internal error")),
+			},
+			deploymentID:    "30b3e62c-9ec2-4312-a7fa-cff24cc7397a",
+			externalID:      "SN123",
+			modelResolveErr: errors.New("inventory unavailable"),
+		},
+		"reason": {
+			JSONResponseParams: h.JSONResponseParams{
+				OutputStatus:     http.StatusOK,
+				OutputBodyObject: statuses[2:],
+			},
+			deploymentID:  "30b3e62c-9ec2-4312-a7fa-cff24cc7397a",
+			reason:        deployments.DeviceDeploymentReasonNoArtifact,
+			modelStatuses: statuses,
+		},
+		"reason, no statuses": {
+			JSONResponseParams: h.JSONResponseParams{
+				OutputStatus:     http.StatusOK,
+				OutputBodyObject: []deployments.DeviceDeployment{},
+			},
+			deploymentID:  "30b3e62c-9ec2-4312-a7fa-cff24cc7397a",
+			reason:        deployments.DeviceDeploymentReasonAlreadyInstalled,
+			modelStatuses: statuses,
+		},
+	}
+
+	for caseName, tc := range testCases {
+
+		t.Run(caseName, func(t *testing.T) {
+
+			deploymentModel := new(mocks.DeploymentsModel)
+			deploymentModel.On("GetDeviceStatusesForDeployment",
+				h.ContextMatcher(), tc.deploymentID).
+				Return(tc.modelStatuses, tc.modelErr)
+			deploymentModel.On("ResolveDeviceExternalID",
+				h.ContextMatcher(), tc.externalID).
+				Return(tc.modelDeviceID, tc.modelResolveErr)
+
+			router, err := rest.MakeRouter(
+				rest.Get("/r/:id",
+					NewDeploymentsController(deploymentModel,
+						new(view.DeploymentsView)).GetDeviceStatusesForDeployment))
+
+			assert.NoError(t, err)
+
+			api := makeApi(router)
+
+			url := "http://localhost/r/" + tc.deploymentID
+			if tc.externalID != "" {
+				url += "?external_id=" + tc.externalID
+			}
+			if tc.reason != "" {
+				url += "?reason=" + tc.reason
+			}
+			req := test.MakeSimpleRequest("GET", url, nil)
+			req.Header.Add(requestid.RequestIdHeader, "test")
+			recorded := test.RunRequest(t, api.MakeHandler(), req)
+
+			h.CheckRecordedResponse(t, recorded, tc.JSONResponseParams)
+		})
+	}
+}
+
+func TestControllerLookupDeployment(t *testing.T) {
+
+	t.Parallel()
+
+	someDeployments := []*deployments.Deployment{
+		{
+			DeploymentConstructor: &deployments.DeploymentConstructor{
+				Name:         StringToPointer("zen"),
+				ArtifactName: StringToPointer("baz"),
+				Devices:      []string{"device0001", "device0002", "device0003"},
+			},
+			Id: StringToPointer("a108ae14-bb4e-455f-9b40-2ef4bab97bb7"),
+		},
+		{
+			DeploymentConstructor: &deployments.DeploymentConstructor{
+				Name:         StringToPointer("foo"),
+				ArtifactName: StringToPointer("bar"),
+				Devices:      []string{"device0001", "device0002", "device0003"},
+			},
+			Id: StringToPointer("e8c32ff6-7c1b-43c7-aa31-2e4fc3a3c130"),
+		},
+	}
+
+	statuses := []deployments.DeviceDeployment{
+		*deployments.NewDeviceDeployment("device0001", "a108ae14-bb4e-455f-9b40-2ef4bab97bb7"),
+		*deployments.NewDeviceDeployment("device0002", "a108ae14-bb4e-455f-9b40-2ef4bab97bb7"),
+		*deployments.NewDeviceDeployment("device0003", "a108ae14-bb4e-455f-9b40-2ef4bab97bb7"),
+	}
+
+	testCases := []struct {
+		h.JSONResponseParams
+
+		SearchStatus string
+
+		InputModelQuery       deployments.Query
+		InputModelError       error
+		InputModelDeployments []*deployments.Deployment
+		DeviceStatuses        []deployments.DeviceDeployment
+	}{
+		{
+			InputModelQuery: deployments.Query{
+				SearchText: " ",
+				Limit:      2,
+			},
+			InputModelError: errors.New("bad query"),
+
+			JSONResponseParams: h.JSONResponseParams{
+				OutputStatus:     http.StatusBadRequest,
+				OutputBodyObject: h.ErrorToErrStruct(errors.New("bad query")),
+			},
+		},
+		{
+			SearchStatus:    "badstatus",
+			InputModelError: errors.New("bad query"),
+
+			JSONResponseParams: h.JSONResponseParams{
+				OutputStatus:     http.StatusBadRequest,
+				OutputBodyObject: h.ErrorToErrStruct(errors.New("unknown status badstatus")),
+			},
+		},
+		{
+			InputModelQuery: deployments.Query{
+				SearchText: "foo-not-found",
+				Limit:      2,
+			},
+			InputModelDeployments: []*deployments.Deployment{},
+
+			JSONResponseParams: h.JSONResponseParams{
+				OutputStatus:     http.StatusOK,
+				OutputBodyObject: []*deployments.Deployment{},
+			},
+		},
+		{
+			InputModelQuery: deployments.Query{
+				SearchText: "foo",
+				Status:     deployments.StatusQueryInProgress,
+				Limit:      2,
+			},
+			SearchStatus:          "inprogress",
+			InputModelDeployments: someDeployments,
+
+			JSONResponseParams: h.JSONResponseParams{
+				OutputStatus: http.StatusOK,
+				OutputBodyObject: []struct {
+					deployments.Deployment
+					Status string `json:"status"`
+				}{
+					{
+						Deployment: deployments.Deployment{
+							DeploymentConstructor: &deployments.DeploymentConstructor{
+								Name:         StringToPointer("zen"),
+								ArtifactName: StringToPointer("baz"),
+							},
+							Id: StringToPointer("a108ae14-bb4e-455f-9b40-2ef4bab97bb7"),
+						},
+						Status: "finished",
+					},
+					{
+						Deployment: deployments.Deployment{
+							DeploymentConstructor: &deployments.DeploymentConstructor{
+								Name:         StringToPointer("foo"),
+								ArtifactName: StringToPointer("bar"),
+							},
+							Id: StringToPointer("e8c32ff6-7c1b-43c7-aa31-2e4fc3a3c130"),
+						},
+						Status: "finished",
+					},
+				},
+			},
+		},
+		{
+			InputModelQuery: deployments.Query{
+				SearchText: "foo",
+				Status:     deployments.StatusQueryInProgress,
+				Limit:      1,
+			},
+			SearchStatus:          "inprogress",
+			InputModelDeployments: someDeployments,
+			DeviceStatuses:        statuses,
+
+			JSONResponseParams: h.JSONResponseParams{
+				OutputStatus: http.StatusOK,
+				OutputBodyObject: []struct {
+					deployments.Deployment
+					Status string `json:"status"`
+				}{
+					{
+						Deployment: deployments.Deployment{
+							DeploymentConstructor: &deployments.DeploymentConstructor{
+								Name:         StringToPointer("zen"),
+								ArtifactName: StringToPointer("baz"),
+							},
+							Id: StringToPointer("a108ae14-bb4e-455f-9b40-2ef4bab97bb7"),
+						},
+						Status: "finished",
+					},
+				},
+			},
+		},
+	}
+
+	for testCaseNumber, testCase := range testCases {
+
+		t.Run(fmt.Sprintf("test case %d", testCaseNumber+1), func(t *testing.T) {
+
+			deploymentModel := new(mocks.DeploymentsModel)
+
+			deploymentModel.On("LookupDeployment",
+				h.ContextMatcher(), mock.AnythingOfType("deployments.Query")).
+				Return(testCase.InputModelDeployments, testCase.InputModelError)
+
+			deploymentModel.On("GetDeviceStatusesForDeployment",
+				h.ContextMatcher(), mock.AnythingOfType("string")).
+				Return(testCase.DeviceStatuses, nil)
+
+			router, err := rest.MakeRouter(
+				rest.Get("/r",
+					NewDeploymentsController(deploymentModel,
+						new(view.DeploymentsView)).LookupDeployment))
+
+			assert.NoError(t, err)
+
+			api := makeApi(router)
+
+			u := url.URL{
+				Scheme: "http",
+				Host:   "localhost",
+				Path:   "/r",
+			}
+			q := u.Query()
+			q.Set("search", testCase.InputModelQuery.SearchText)
+			if testCase.SearchStatus != "" {
+				q.Set("status", testCase.SearchStatus)
+			}
+
 			if testCase.InputModelQuery.CreatedBefore != nil {
 				createdBeforeStr := strconv.FormatInt(testCase.InputModelQuery.CreatedBefore.Unix(), 10)
 				q.Set("created_before", createdBeforeStr)
 			}
 
-			q.Set("per_page", strconv.Itoa(testCase.InputModelQuery.Limit))
-			u.RawQuery = q.Encode()
+			q.Set("per_page", strconv.Itoa(testCase.InputModelQuery.Limit))
+			u.RawQuery = q.Encode()
+
+			req := test.MakeSimpleRequest("GET", u.String(), nil)
+			req.Header.Add(requestid.RequestIdHeader, "test")
+			recorded := test.RunRequest(t, api.MakeHandler(), req)
+			h.CheckRecordedResponse(t, recorded, testCase.JSONResponseParams)
+		})
+	}
+}
+
+func TestControllerLookupDeploymentPagination(t *testing.T) {
+
+	t.Parallel()
+
+	deps := []*deployments.Deployment{
+		{
+			DeploymentConstructor: &deployments.DeploymentConstructor{
+				Name:         StringToPointer("foo"),
+				ArtifactName: StringToPointer("bar"),
+			},
+			Id: StringToPointer("a108ae14-bb4e-455f-9b40-2ef4bab97bb7"),
+		},
+		{
+			DeploymentConstructor: &deployments.DeploymentConstructor{
+				Name:         StringToPointer("zen"),
+				ArtifactName: StringToPointer("baz"),
+			},
+			Id: StringToPointer("e8c32ff6-7c1b-43c7-aa31-2e4fc3a3c130"),
+		},
+	}
+
+	testCases := map[string]struct {
+		InputQuery      string
+		InputModelQuery deployments.Query
+		InputModelDeps  []*deployments.Deployment
+
+		OutputStatus int
+		OutputCount  int
+		OutputLinks  []string
+	}{
+		"defaults": {
+			InputModelQuery: deployments.Query{Limit: 21},
+			InputModelDeps:  deps,
+
+			OutputStatus: http.StatusOK,
+			OutputCount:  2,
+			OutputLinks:  []string{`rel="first"`},
+		},
+		"first page, more available": {
+			InputQuery:      "page=1&per_page=1",
+			InputModelQuery: deployments.Query{Limit: 2},
+			InputModelDeps:  deps,
+
+			OutputStatus: http.StatusOK,
+			OutputCount:  1,
+			OutputLinks:  []string{`rel="first"`, `rel="next"`},
+		},
+		"last page": {
+			InputQuery:      "page=3&per_page=1",
+			InputModelQuery: deployments.Query{Skip: 2, Limit: 2},
+			InputModelDeps:  deps[:1],
+
+			OutputStatus: http.StatusOK,
+			OutputCount:  1,
+			OutputLinks:  []string{`rel="first"`, `rel="prev"`},
+		},
+		"bad page": {
+			InputQuery: "page=0",
+
+			OutputStatus: http.StatusBadRequest,
+		},
+		"bad per page": {
+			InputQuery: "per_page=foo",
+
+			OutputStatus: http.StatusBadRequest,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+
+			deploymentModel := new(mocks.DeploymentsModel)
+			if tc.InputModelDeps != nil {
+				deploymentModel.On("LookupDeployment",
+					h.ContextMatcher(), tc.InputModelQuery).
+					Return(tc.InputModelDeps, nil)
+			}
+
+			router, err := rest.MakeRouter(
+				rest.Get("/r",
+					NewDeploymentsController(deploymentModel,
+						new(view.DeploymentsView)).LookupDeployment))
+			assert.NoError(t, err)
+
+			api := makeApi(router)
+
+			req := test.MakeSimpleRequest("GET",
+				"http://localhost/r?"+tc.InputQuery, nil)
+			req.Header.Add(requestid.RequestIdHeader, "test")
+			recorded := test.RunRequest(t, api.MakeHandler(), req)
+
+			recorded.CodeIs(tc.OutputStatus)
+			deploymentModel.AssertExpectations(t)
+			if tc.OutputStatus != http.StatusOK {
+				return
+			}
+
+			var body []interface{}
+			assert.NoError(t, recorded.DecodeJsonPayload(&body))
+			assert.Len(t, body, tc.OutputCount)
+
+			links := recorded.Recorder.HeaderMap["Link"]
+			assert.Len(t, links, len(tc.OutputLinks))
+			all := strings.Join(links, ",")
+			for _, rel := range tc.OutputLinks {
+				assert.Contains(t, all, rel)
+			}
+		})
+	}
+}
+
+func TestParseLookupQuery(t *testing.T) {
+	testCases := []struct {
+		vals  url.Values
+		query deployments.Query
+		err   error
+	}{
+		{
+			vals: url.Values{
+				"search": []string{"foo"},
+				"status": []string{"inprogress"},
+			},
+			query: deployments.Query{
+				SearchText: "foo",
+				Status:     deployments.StatusQueryInProgress,
+			},
+		},
+		{
+			vals: url.Values{
+				"search": []string{"foo"},
+				"status": []string{"bar"},
+			},
+			err: errors.New("unknown status bar"),
+		},
+		{
+			vals: url.Values{
+				"search": []string{"foo"},
+				"status": []string{"finished"},
+			},
+			query: deployments.Query{
+				SearchText: "foo",
+				Status:     deployments.StatusQueryFinished,
+			},
+		},
+		{
+			vals: url.Values{
+				"search": []string{"foo"},
+				"status": []string{"pending"},
+			},
+			query: deployments.Query{
+				SearchText: "foo",
+				Status:     deployments.StatusQueryPending,
+			},
+		},
+		{
+			vals: url.Values{
+				"search": []string{"foo"},
+				"status": []string{"aborted"},
+			},
+			query: deployments.Query{
+				SearchText: "foo",
+				Status:     deployments.StatusQueryAborted,
+			},
+		},
+		{
+			vals: url.Values{
+				"search": []string{"foo"},
+			},
+			query: deployments.Query{
+				SearchText: "foo",
+				Status:     deployments.StatusQueryAny,
+			},
+		},
+		{
+			vals: url.Values{},
+			query: deployments.Query{
+				SearchText: "",
+				Status:     deployments.StatusQueryAny,
+			},
+		},
+		{
+			vals: url.Values{
+				"status": []string{"pending"},
+			},
+			query: deployments.Query{
+				SearchText: "",
+				Status:     deployments.StatusQueryPending,
+			},
+		},
+		{
+			vals: url.Values{
+				"created_after":  []string{"100"},
+				"created_before": []string{"x"},
+			},
+			query: deployments.Query{
+				CreatedAfter:  nil,
+				CreatedBefore: nil,
+			},
+		},
+		{
+			vals: url.Values{
+				"created_after":  []string{"x"},
+				"created_before": []string{"x"},
+			},
+			query: deployments.Query{
+				CreatedAfter:  nil,
+				CreatedBefore: nil,
+			},
+		},
+		{
+			vals: url.Values{
+				"created_before": []string{"111111111111"},
+				"created_after":  []string{"111111111111"},
+			},
+			query: deployments.Query{
+				CreatedBefore: TimePtr(time.Unix(111111111111, 0).UTC()),
+				CreatedAfter:  TimePtr(time.Unix(111111111111, 0).UTC()),
+			},
+		},
+	}
+
+	for testCaseNumber, tc := range testCases {
+
+		t.Run(fmt.Sprintf("test case %d", testCaseNumber+1), func(t *testing.T) {
+
+			q, err := ParseLookupQuery(tc.vals)
+			if tc.err != nil {
+				assert.Error(t, err)
+				assert.EqualError(t, tc.err, err.Error())
+			} else {
+				assert.Equal(t, tc.query, q)
+			}
+		})
+	}
+}
+
+func TestControllerPutDeploymentLog(t *testing.T) {
+
+	t.Parallel()
+
+	type log struct {
+		Messages string `json:"messages"`
+	}
+
+	tref := time.Now().UTC()
+
+	messages := []deployments.LogMessage{
+		{
+			Timestamp: &tref,
+			Message:   "foo",
+			Level:     "notice",
+		},
+	}
+
+	tooMany := make([]deployments.LogMessage, deployments.MaxDeploymentLogMessages+1)
+	for i := range tooMany {
+		tooMany[i] = messages[0]
+	}
+
+	testCases := []struct {
+		h.JSONResponseParams
+		InputBodyObject interface{}
+
+		InputModelDeploymentID string
+		InputModelDeviceID     string
+		InputModelMessages     []deployments.LogMessage
+		InputModelError        error
+
+		Headers map[string]string
+	}{
+		{
+			// empty log body
+			InputBodyObject: nil,
+
+			InputModelDeploymentID: "f826484e-1157-4109-af21-304e6d711560",
+			InputModelDeviceID:     "device-id-1",
+			InputModelMessages:     nil,
+
+			JSONResponseParams: h.JSONResponseParams{
+				OutputStatus:     http.StatusBadRequest,
+				OutputBodyObject: h.ErrorToErrStruct(errors.New("JSON payload is empty")),
+			},
+			Headers: map[string]string{
+				"Authorization": makeDeviceAuthHeader(`{"sub": "device-id-1"}`),
+			},
+		},
+		{
+			// all correct
+			InputBodyObject: &deployments.DeploymentLog{
+				Messages: messages,
+			},
+			InputModelDeploymentID: "f826484e-1157-4109-af21-304e6d711560",
+			InputModelDeviceID:     "device-id-2",
+			InputModelMessages:     messages,
+
+			JSONResponseParams: h.JSONResponseParams{
+				OutputStatus:     http.StatusNoContent,
+				OutputBodyObject: nil,
+			},
+			Headers: map[string]string{
+				"Authorization": makeDeviceAuthHeader(`{"sub": "device-id-2"}`),
+			},
+		},
+		{
+			// no authorization
+			InputBodyObject: &deployments.DeploymentLog{
+				Messages: messages,
+			},
+			InputModelDeploymentID: "f826484e-1157-4109-af21-304e6d711560",
+			InputModelDeviceID:     "device-id-3",
+			InputModelMessages:     messages,
+
+			JSONResponseParams: h.JSONResponseParams{
+				OutputStatus:     http.StatusBadRequest,
+				OutputBodyObject: h.ErrorToErrStruct(errors.New("Missing identity data")),
+			},
+		},
+		{
+			// model error
+			InputBodyObject: &deployments.DeploymentLog{
+				Messages: messages,
+			},
+			InputModelDeploymentID: "f826484e-1157-4109-af21-304e6d711560",
+			InputModelDeviceID:     "device-id-4",
+			InputModelError:        errors.New("model error"),
+			InputModelMessages:     messages,
+
+			JSONResponseParams: h.JSONResponseParams{
+				OutputStatus:     http.StatusInternalServerError,
+				OutputBodyObject: h.ErrorToErrStruct(errors.New("internal error")),
+			},
+			Headers: map[string]string{
+				"Authorization": makeDeviceAuthHeader(`{"sub": "device-id-4"}`),
+			},
+		},
+		{
+			// deployment not assigned to device
+			InputBodyObject: &deployments.DeploymentLog{
+				Messages: messages,
+			},
+			InputModelDeploymentID: "f826484e-1157-4109-af21-304e6d711560",
+			InputModelDeviceID:     "device-id-5",
+			InputModelError:        ErrModelDeploymentNotFound,
+			InputModelMessages:     messages,
+
+			JSONResponseParams: h.JSONResponseParams{
+				OutputStatus:     http.StatusNotFound,
+				OutputBodyObject: h.ErrorToErrStruct(errors.New("Deployment not found")),
+			},
+			Headers: map[string]string{
+				"Authorization": makeDeviceAuthHeader(`{"sub": "device-id-5"}`),
+			},
+		},
+		{
+			// too many messages
+			InputBodyObject: &deployments.DeploymentLog{
+				Messages: tooMany,
+			},
+			InputModelDeploymentID: "f826484e-1157-4109-af21-304e6d711560",
+			InputModelDeviceID:     "device-id-6",
+
+			JSONResponseParams: h.JSONResponseParams{
+				OutputStatus: http.StatusRequestEntityTooLarge,
+				OutputBodyObject: h.ErrorToErrStruct(errors.New(
+					"over 10000 messages: deployment log too large")),
+			},
+			Headers: map[string]string{
+				"Authorization": makeDeviceAuthHeader(`{"sub": "device-id-6"}`),
+			},
+		},
+		{
+			// message too long
+			InputBodyObject: &deployments.DeploymentLog{
+				Messages: []deployments.LogMessage{{
+					Timestamp: &tref,
+					Message:   strings.Repeat("x", deployments.MaxLogMessageLength+1),
+					Level:     "notice",
+				}},
+			},
+			InputModelDeploymentID: "f826484e-1157-4109-af21-304e6d711560",
+			InputModelDeviceID:     "device-id-7",
+
+			JSONResponseParams: h.JSONResponseParams{
+				OutputStatus: http.StatusBadRequest,
+				OutputBodyObject: h.ErrorToErrStruct(errors.New(
+					"over 16384 bytes: log message too long")),
+			},
+			Headers: map[string]string{
+				"Authorization": makeDeviceAuthHeader(`{"sub": "device-id-7"}`),
+			},
+		},
+		{
+			// log over size limit in model
+			InputBodyObject: &deployments.DeploymentLog{
+				Messages: messages,
+			},
+			InputModelDeploymentID: "f826484e-1157-4109-af21-304e6d711560",
+			InputModelDeviceID:     "device-id-8",
+			InputModelError: pkgerrors.Wrap(deployments.ErrDeploymentLogTooLarge,
+				ErrStorageInvalidLog.Error()),
+			InputModelMessages: messages,
+
+			JSONResponseParams: h.JSONResponseParams{
+				OutputStatus: http.StatusRequestEntityTooLarge,
+				OutputBodyObject: h.ErrorToErrStruct(errors.New(
+					"Invalid deployment log: deployment log too large")),
+			},
+			Headers: map[string]string{
+				"Authorization": makeDeviceAuthHeader(`{"sub": "device-id-8"}`),
+			},
+		},
+	}
+
+	for testCaseNumber, testCase := range testCases {
+
+		t.Run(fmt.Sprintf("test case %d", testCaseNumber+1), func(t *testing.T) {
+
+			deploymentModel := new(mocks.DeploymentsModel)
+
+			deploymentModel.On("SaveDeviceDeploymentLog",
+				h.ContextMatcher(),
+				testCase.InputModelDeviceID,
+				testCase.InputModelDeploymentID,
+				testCase.InputModelMessages).
+				Return(testCase.InputModelError)
+
+			router, err := rest.MakeRouter(
+				rest.Put("/r/:id",
+					NewDeploymentsController(deploymentModel,
+						new(view.DeploymentsView)).PutDeploymentLogForDevice))
+			assert.NoError(t, err)
+
+			api := makeApi(router)
+
+			req := test.MakeSimpleRequest("PUT", "http://localhost/r/"+testCase.InputModelDeploymentID,
+				testCase.InputBodyObject)
+			for k, v := range testCase.Headers {
+				req.Header.Set(k, v)
+			}
+			req.Header.Add(requestid.RequestIdHeader, "test")
+			recorded := test.RunRequest(t, api.MakeHandler(), req)
+
+			h.CheckRecordedResponse(t, recorded, testCase.JSONResponseParams)
+		})
+	}
+}
+
+func parseTime(t *testing.T, value string) *time.Time {
+	tm, err := time.Parse(time.RFC3339, value)
+	if assert.NoError(t, err) == false {
+		t.Fatalf("failed to parse time %s", value)
+	}
+
+	return &tm
+}
+
+func TestControllerGetDeploymentLog(t *testing.T) {
+
+	t.Parallel()
+
+	type log struct {
+		Messages string `json:"messages"`
+	}
+
+	tref := parseTime(t, "2006-01-02T15:04:05-07:00")
+
+	messages := []deployments.LogMessage{
+		{
+			Timestamp: tref,
+			Message:   "foo",
+			Level:     "notice",
+		},
+		{
+			Timestamp: tref,
+			Message:   "zed zed zed",
+			Level:     "debug",
+		},
+		{
+			Timestamp: tref,
+			Message:   "bar bar bar",
+			Level:     "info",
+		},
+	}
+
+	testCases := []struct {
+		h.JSONResponseParams
+
+		InputModelDeploymentLog *deployments.DeploymentLog
+		InputModelDeploymentID  string
+		InputModelDeviceID      string
+		InputModelMessages      []deployments.LogMessage
+		InputModelError         error
+
+		Body string
+	}{
+		{
+			// all correct
+			InputModelDeploymentLog: &deployments.DeploymentLog{
+				DeploymentID: "f826484e-1157-4109-af21-304e6d711560",
+				DeviceID:     "device-id-1",
+				Messages:     messages,
+			},
+			InputModelDeploymentID: "f826484e-1157-4109-af21-304e6d711560",
+			InputModelDeviceID:     "device-id-1",
+			InputModelMessages:     messages,
+
+			JSONResponseParams: h.JSONResponseParams{
+				OutputStatus:     http.StatusOK,
+				OutputBodyObject: nil,
+			},
+			Body: `2006-01-02 22:04:05 +0000 UTC notice: foo
+2006-01-02 22:04:05 +0000 UTC debug: zed zed zed
+2006-01-02 22:04:05 +0000 UTC info: bar bar bar
+`,
+		},
+		{
+			// model error
+			InputModelDeploymentLog: nil,
+			InputModelDeploymentID:  "f826484e-1157-4109-af21-304e6d711560",
+			InputModelDeviceID:      "device-id-4",
+			InputModelError:         errors.New("model error"),
+			InputModelMessages:      messages,
+
+			JSONResponseParams: h.JSONResponseParams{
+				OutputStatus:     http.StatusInternalServerError,
+				OutputBodyObject: h.ErrorToErrStruct(errors.New("internal error")),
+			},
+		},
+		{
+			// deployment not assigned to device
+			InputModelDeploymentLog: nil,
+			InputModelDeploymentID:  "f826484e-1157-4109-af21-304e6d711560",
+			InputModelDeviceID:      "device-id-5",
+			InputModelError:         nil,
+			InputModelMessages:      messages,
+
+			JSONResponseParams: h.JSONResponseParams{
+				OutputStatus:     http.StatusNotFound,
+				OutputBodyObject: h.ErrorToErrStruct(errors.New("Resource not found")),
+			},
+		},
+	}
+
+	for testCaseNumber, testCase := range testCases {
+
+		t.Run(fmt.Sprintf("test case %d", testCaseNumber+1), func(t *testing.T) {
+			deploymentModel := new(mocks.DeploymentsModel)
+
+			deploymentModel.On("GetDeviceDeploymentLog",
+				h.ContextMatcher(),
+				testCase.InputModelDeviceID,
+				testCase.InputModelDeploymentID).
+				Return(testCase.InputModelDeploymentLog, testCase.InputModelError)
+
+			router, err := rest.MakeRouter(
+				rest.Get("/r/:id/:devid",
+					NewDeploymentsController(deploymentModel,
+						new(view.DeploymentsView)).GetDeploymentLogForDevice))
+			assert.NoError(t, err)
+
+			api := makeApi(router)
+
+			req := test.MakeSimpleRequest("GET", "http://localhost/r/"+
+				testCase.InputModelDeploymentID+"/"+testCase.InputModelDeviceID,
+				nil)
+			req.Header.Add(requestid.RequestIdHeader, "test")
+			recorded := test.RunRequest(t, api.MakeHandler(), req)
+			if testCase.JSONResponseParams.OutputStatus != http.StatusOK {
+				h.CheckRecordedResponse(t, recorded, testCase.JSONResponseParams)
+			} else {
+				assert.Equal(t, testCase.Body, recorded.Recorder.Body.String())
+				assert.Equal(t, http.StatusOK, recorded.Recorder.Code)
+				assert.Equal(t, "text/plain", recorded.Recorder.HeaderMap.Get("Content-Type"))
+			}
+		})
+	}
+}
+
+func TestControllerAbortDeployment(t *testing.T) {
+
+	t.Parallel()
+
+	type report struct {
+		Status string `json:"status"`
+		Reason string `json:"reason,omitempty"`
+	}
+
+	testCases := []struct {
+		h.JSONResponseParams
+
+		InputBodyObject interface{}
+		Headers         map[string]string
+
+		InputModelDeploymentID string
+		InputModelStatus       string
+		InputModelAbort        *deployments.AbortInfo
+		InputModelError        error
+	}{
+		{
+			// empty body
+			InputBodyObject: nil,
+
+			InputModelDeploymentID: "f826484e-1157-4109-af21-304e6d711560",
+			InputModelStatus:       "none",
+
+			JSONResponseParams: h.JSONResponseParams{
+				OutputStatus:     http.StatusBadRequest,
+				OutputBodyObject: h.ErrorToErrStruct(errors.New("JSON payload is empty")),
+			},
+		},
+		{
+			// wrong status
+			InputBodyObject:        &report{Status: "finished"},
+			InputModelDeploymentID: "f826484e-1157-4109-af21-304e6d711560",
+			InputModelStatus:       "finished",
+
+			JSONResponseParams: h.JSONResponseParams{
+				OutputStatus:     http.StatusBadRequest,
+				OutputBodyObject: h.ErrorToErrStruct(errors.New("Unexpected deployment status")),
+			},
+		},
+		{
+			// deployment finished already
+			InputBodyObject:        &report{Status: "aborted"},
+			InputModelDeploymentID: "f826484e-1157-4109-af21-304e6d711560",
+			InputModelStatus:       "aborted",
+			InputModelAbort:        &deployments.AbortInfo{},
+			InputModelError:        ErrDeploymentAlreadyFinished,
+
+			JSONResponseParams: h.JSONResponseParams{
+				OutputStatus:     http.StatusUnprocessableEntity,
+				OutputBodyObject: h.ErrorToErrStruct(errors.New("Deployment already finished")),
+			},
+		},
+		{
+			// deployment not found
+			InputBodyObject:        &report{Status: "aborted"},
+			InputModelDeploymentID: "f826484e-1157-4109-af21-304e6d711560",
+			InputModelStatus:       "aborted",
+			InputModelAbort:        &deployments.AbortInfo{},
+			InputModelError:        ErrModelDeploymentNotFound,
+
+			JSONResponseParams: h.JSONResponseParams{
+				OutputStatus:     http.StatusNotFound,
+				OutputBodyObject: h.ErrorToErrStruct(ErrModelDeploymentNotFound),
+			},
+		},
+		{
+			// all correct
+			InputBodyObject:        &report{Status: "aborted"},
+			InputModelDeploymentID: "f826484e-1157-4109-af21-304e6d711560",
+			InputModelStatus:       "aborted",
+			InputModelAbort:        &deployments.AbortInfo{},
+
+			JSONResponseParams: h.JSONResponseParams{
+				OutputStatus: http.StatusNoContent,
+			},
+		},
+		{
+			// reason and actor recorded
+			InputBodyObject: &report{
+				Status: "aborted",
+				Reason: "bricks devices with old bootloader",
+			},
+			Headers: map[string]string{
+				"Authorization": makeDeviceAuthHeader(`{"sub": "user-1"}`),
+			},
+			InputModelDeploymentID: "f826484e-1157-4109-af21-304e6d711560",
+			InputModelStatus:       "aborted",
+			InputModelAbort: &deployments.AbortInfo{
+				Reason:    "bricks devices with old bootloader",
+				AbortedBy: "user-1",
+			},
+
+			JSONResponseParams: h.JSONResponseParams{
+				OutputStatus: http.StatusNoContent,
+			},
+		},
+		{
+			// reason too long
+			InputBodyObject: &report{
+				Status: "aborted",
+				Reason: strings.Repeat("r", 1025),
+			},
+			InputModelDeploymentID: "f826484e-1157-4109-af21-304e6d711560",
+			InputModelStatus:       "aborted",
+
+			JSONResponseParams: h.JSONResponseParams{
+				OutputStatus: http.StatusBadRequest,
+				OutputBodyObject: h.ErrorToErrStruct(errors.New("Reason: " +
+					strings.Repeat("r", 1025) + " does not validate as length(0|1024);")),
+			},
+		},
+		{
+			// model error
+			InputBodyObject:        &report{Status: "aborted"},
+			InputModelDeploymentID: "f826484e-1157-4109-af21-304e6d711560",
+			InputModelStatus:       "aborted",
+			InputModelAbort:        &deployments.AbortInfo{},
+			InputModelError:        errors.New("storage issue"),
+
+			JSONResponseParams: h.JSONResponseParams{
+				OutputStatus:     http.StatusInternalServerError,
+				OutputBodyObject: h.ErrorToErrStruct(errors.New("internal error")),
+			},
+		},
+	}
+
+	for testCaseNumber, testCase := range testCases {
+
+		t.Run(fmt.Sprintf("test case %d", testCaseNumber+1), func(t *testing.T) {
+
+			deploymentModel := new(mocks.DeploymentsModel)
+
+			if testCase.InputModelAbort != nil {
+				deploymentModel.On("AbortDeployment",
+					h.ContextMatcher(), testCase.InputModelDeploymentID,
+					testCase.InputModelAbort).
+					Return(testCase.InputModelError)
+			}
+
+			router, err := rest.MakeRouter(
+				rest.Post("/r/:id",
+					NewDeploymentsController(deploymentModel,
+						new(view.DeploymentsView)).AbortDeployment))
+			assert.NoError(t, err)
+
+			api := makeApi(router)
+
+			req := test.MakeSimpleRequest("POST", "http://localhost/r/"+testCase.InputModelDeploymentID,
+				testCase.InputBodyObject)
+			for k, v := range testCase.Headers {
+				req.Header.Set(k, v)
+			}
+			req.Header.Add(requestid.RequestIdHeader, "test")
+			recorded := test.RunRequest(t, api.MakeHandler(), req)
+
+			h.CheckRecordedResponse(t, recorded, testCase.JSONResponseParams)
+		})
+	}
+}
+
+func TestControllerForceFinishDeployment(t *testing.T) {
+
+	t.Parallel()
+
+	deploymentID := "f826484e-1157-4109-af21-304e6d711560"
+
+	testCases := map[string]struct {
+		h.JSONResponseParams
+
+		InputDeploymentID string
+		InputBodyObject   interface{}
+		Headers           map[string]string
+
+		InputModelStatus string
+		InputModelAbort  *deployments.AbortInfo
+		InputModelError  error
+	}{
+		"timed out": {
+			InputDeploymentID: deploymentID,
+			InputBodyObject:   map[string]string{"status": "timed_out"},
+			Headers: map[string]string{
+				"Authorization": makeDeviceAuthHeader(`{"sub": "user-1"}`),
+			},
+			InputModelStatus: deployments.DeviceDeploymentStatusTimedOut,
+			InputModelAbort:  &deployments.AbortInfo{AbortedBy: "user-1"},
+
+			JSONResponseParams: h.JSONResponseParams{
+				OutputStatus: http.StatusNoContent,
+			},
+		},
+		"aborted with reason": {
+			InputDeploymentID: deploymentID,
+			InputBodyObject: map[string]string{
+				"status": "aborted",
+				"reason": "fleet retired",
+			},
+			InputModelStatus: deployments.DeviceDeploymentStatusAborted,
+			InputModelAbort:  &deployments.AbortInfo{Reason: "fleet retired"},
+
+			JSONResponseParams: h.JSONResponseParams{
+				OutputStatus: http.StatusNoContent,
+			},
+		},
+		"bad id": {
+			InputDeploymentID: "bad-id",
+			InputBodyObject:   map[string]string{"status": "timed_out"},
+
+			JSONResponseParams: h.JSONResponseParams{
+				OutputStatus:     http.StatusBadRequest,
+				OutputBodyObject: h.ErrorToErrStruct(ErrIDNotUUIDv4),
+			},
+		},
+		"invalid status": {
+			InputDeploymentID: deploymentID,
+			InputBodyObject:   map[string]string{"status": "success"},
+
+			JSONResponseParams: h.JSONResponseParams{
+				OutputStatus:     http.StatusBadRequest,
+				OutputBodyObject: h.ErrorToErrStruct(ErrInvalidFinishStatus),
+			},
+		},
+		"not found": {
+			InputDeploymentID: deploymentID,
+			InputBodyObject:   map[string]string{"status": "timed_out"},
+			InputModelStatus:  deployments.DeviceDeploymentStatusTimedOut,
+			InputModelAbort:   &deployments.AbortInfo{},
+			InputModelError:   ErrModelDeploymentNotFound,
+
+			JSONResponseParams: h.JSONResponseParams{
+				OutputStatus:     http.StatusNotFound,
+				OutputBodyObject: h.ErrorToErrStruct(ErrModelDeploymentNotFound),
+			},
+		},
+		"finished already": {
+			InputDeploymentID: deploymentID,
+			InputBodyObject:   map[string]string{"status": "timed_out"},
+			InputModelStatus:  deployments.DeviceDeploymentStatusTimedOut,
+			InputModelAbort:   &deployments.AbortInfo{},
+			InputModelError:   ErrDeploymentAlreadyFinished,
+
+			JSONResponseParams: h.JSONResponseParams{
+				OutputStatus:     http.StatusUnprocessableEntity,
+				OutputBodyObject: h.ErrorToErrStruct(ErrDeploymentAlreadyFinished),
+			},
+		},
+		"model error": {
+			InputDeploymentID: deploymentID,
+			InputBodyObject:   map[string]string{"status": "timed_out"},
+			InputModelStatus:  deployments.DeviceDeploymentStatusTimedOut,
+			InputModelAbort:   &deployments.AbortInfo{},
+			InputModelError:   errors.New("storage issue"),
+
+			JSONResponseParams: h.JSONResponseParams{
+				OutputStatus:     http.StatusInternalServerError,
+				OutputBodyObject: h.ErrorToErrStruct(errors.New("internal error")),
+			},
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+
+			deploymentModel := new(mocks.DeploymentsModel)
+			if testCase.InputModelAbort != nil {
+				deploymentModel.On("ForceFinishDeployment",
+					h.ContextMatcher(), testCase.InputDeploymentID,
+					testCase.InputModelStatus, testCase.InputModelAbort).
+					Return(testCase.InputModelError)
+			}
+
+			router, err := rest.MakeRouter(
+				rest.Post("/r/:id",
+					NewDeploymentsController(deploymentModel,
+						new(view.DeploymentsView)).ForceFinishDeployment))
+			assert.NoError(t, err)
+
+			api := makeApi(router)
+
+			req := test.MakeSimpleRequest("POST", "http://localhost/r/"+testCase.InputDeploymentID,
+				testCase.InputBodyObject)
+			for k, v := range testCase.Headers {
+				req.Header.Set(k, v)
+			}
+			req.Header.Add(requestid.RequestIdHeader, "test")
+			recorded := test.RunRequest(t, api.MakeHandler(), req)
+
+			h.CheckRecordedResponse(t, recorded, testCase.JSONResponseParams)
+			deploymentModel.AssertExpectations(t)
+		})
+	}
+}
+
+func TestControllerSearchDeploymentLogs(t *testing.T) {
+
+	t.Parallel()
+
+	testCases := []struct {
+		h.JSONResponseParams
+
+		InputID    string
+		InputQuery string
+
+		InputModelDevices []string
+		InputModelError   error
+	}{
+		{
+			InputID:    "bad-id",
+			InputQuery: "connection refused",
+			JSONResponseParams: h.JSONResponseParams{
+				OutputStatus:     http.StatusBadRequest,
+				OutputBodyObject: h.ErrorToErrStruct(ErrIDNotUUIDv4),
+			},
+		},
+		{
+			InputID: validUUIDv4,
+			JSONResponseParams: h.JSONResponseParams{
+				OutputStatus:     http.StatusBadRequest,
+				OutputBodyObject: h.ErrorToErrStruct(ErrMissingSearchQuery),
+			},
+		},
+		{
+			InputID:         validUUIDv4,
+			InputQuery:      "connection refused",
+			InputModelError: ErrLogSearchDisabled,
+			JSONResponseParams: h.JSONResponseParams{
+				OutputStatus:     http.StatusNotFound,
+				OutputBodyObject: h.ErrorToErrStruct(ErrLogSearchDisabled),
+			},
+		},
+		{
+			InputID:         validUUIDv4,
+			InputQuery:      "connection refused",
+			InputModelError: ErrModelDeploymentNotFound,
+			JSONResponseParams: h.JSONResponseParams{
+				OutputStatus:     http.StatusNotFound,
+				OutputBodyObject: h.ErrorToErrStruct(ErrModelDeploymentNotFound),
+			},
+		},
+		{
+			InputID:         validUUIDv4,
+			InputQuery:      "connection refused",
+			InputModelError: errors.New("model error"),
+			JSONResponseParams: h.JSONResponseParams{
+				OutputStatus:     http.StatusInternalServerError,
+				OutputBodyObject: h.ErrorToErrStruct(errors.New("internal error")),
+			},
+		},
+		{
+			InputID:           validUUIDv4,
+			InputQuery:        "connection refused",
+			InputModelDevices: []string{"device-1", "device-2"},
+			JSONResponseParams: h.JSONResponseParams{
+				OutputStatus:     http.StatusOK,
+				OutputBodyObject: []string{"device-1", "device-2"},
+			},
+		},
+	}
+
+	for testCaseNumber, testCase := range testCases {
+
+		t.Run(fmt.Sprintf("test case %d", testCaseNumber+1), func(t *testing.T) {
+
+			deploymentModel := new(mocks.DeploymentsModel)
+
+			deploymentModel.On("SearchDeviceDeploymentLogs",
+				h.ContextMatcher(), testCase.InputID, testCase.InputQuery).
+				Return(testCase.InputModelDevices, testCase.InputModelError)
+
+			router, err := rest.MakeRouter(
+				rest.Get("/r/:id/logs/search",
+					NewDeploymentsController(deploymentModel,
+						new(view.DeploymentsView)).SearchDeploymentLogs))
+			assert.NoError(t, err)
+
+			api := makeApi(router)
+
+			req := test.MakeSimpleRequest("GET",
+				"http://localhost/r/"+testCase.InputID+"/logs/search?q="+
+					url.QueryEscape(testCase.InputQuery), nil)
+			req.Header.Add(requestid.RequestIdHeader, "test")
+			recorded := test.RunRequest(t, api.MakeHandler(), req)
+
+			h.CheckRecordedResponse(t, recorded, testCase.JSONResponseParams)
+		})
+	}
+}
+
+func TestControllerGetDeviceDeploymentsCount(t *testing.T) {
+
+	t.Parallel()
+
+	testCases := []struct {
+		h.JSONResponseParams
+
+		InputID     string
+		InputStatus string
+
+		InputModelCount int
+		InputModelError error
+	}{
+		{
+			InputID: "bad-id",
+			JSONResponseParams: h.JSONResponseParams{
+				OutputStatus:     http.StatusBadRequest,
+				OutputBodyObject: h.ErrorToErrStruct(ErrIDNotUUIDv4),
+			},
+		},
+		{
+			InputID:     validUUIDv4,
+			InputStatus: "broken",
+			JSONResponseParams: h.JSONResponseParams{
+				OutputStatus:     http.StatusBadRequest,
+				OutputBodyObject: h.ErrorToErrStruct(errors.New("unknown status broken")),
+			},
+		},
+		{
+			InputID:         validUUIDv4,
+			InputModelError: ErrModelDeploymentNotFound,
+			JSONResponseParams: h.JSONResponseParams{
+				OutputStatus:     http.StatusNotFound,
+				OutputBodyObject: h.ErrorToErrStruct(ErrModelDeploymentNotFound),
+			},
+		},
+		{
+			InputID:         validUUIDv4,
+			InputModelError: errors.New("model error"),
+			JSONResponseParams: h.JSONResponseParams{
+				OutputStatus:     http.StatusInternalServerError,
+				OutputBodyObject: h.ErrorToErrStruct(errors.New("internal error")),
+			},
+		},
+		{
+			InputID:         validUUIDv4,
+			InputModelCount: 50000,
+			JSONResponseParams: h.JSONResponseParams{
+				OutputStatus:     http.StatusOK,
+				OutputBodyObject: map[string]int{"count": 50000},
+			},
+		},
+		{
+			InputID:         validUUIDv4,
+			InputStatus:     deployments.DeviceDeploymentStatusFailure,
+			InputModelCount: 12,
+			JSONResponseParams: h.JSONResponseParams{
+				OutputStatus:     http.StatusOK,
+				OutputBodyObject: map[string]int{"count": 12},
+			},
+		},
+	}
+
+	for testCaseNumber, testCase := range testCases {
+
+		t.Run(fmt.Sprintf("test case %d", testCaseNumber+1), func(t *testing.T) {
+
+			deploymentModel := new(mocks.DeploymentsModel)
+
+			deploymentModel.On("GetDeviceDeploymentsCount",
+				h.ContextMatcher(), testCase.InputID, testCase.InputStatus).
+				Return(testCase.InputModelCount, testCase.InputModelError)
+
+			router, err := rest.MakeRouter(
+				rest.Get("/r/:id/devices/count",
+					NewDeploymentsController(deploymentModel,
+						new(view.DeploymentsView)).GetDeviceDeploymentsCount))
+			assert.NoError(t, err)
+
+			api := makeApi(router)
+
+			req := test.MakeSimpleRequest("GET",
+				"http://localhost/r/"+testCase.InputID+"/devices/count?status="+
+					testCase.InputStatus, nil)
+			req.Header.Add(requestid.RequestIdHeader, "test")
+			recorded := test.RunRequest(t, api.MakeHandler(), req)
+
+			h.CheckRecordedResponse(t, recorded, testCase.JSONResponseParams)
+		})
+	}
+}
+
+func TestControllerStatusNames(t *testing.T) {
+
+	t.Parallel()
+
+	names, err := deployments.NewStatusNames(map[string]string{
+		deployments.DeviceDeploymentStatusFailure: "broken",
+	})
+	assert.NoError(t, err)
+
+	deploymentModel := new(mocks.DeploymentsModel)
+	deploymentModel.On("GetDeviceDeploymentsCount",
+		h.ContextMatcher(), validUUIDv4, deployments.DeviceDeploymentStatusFailure).
+		Return(3, nil)
+	deploymentModel.On("GetDeploymentStats", h.ContextMatcher(), validUUIDv4).
+		Return(deployments.Stats{
+			deployments.DeviceDeploymentStatusFailure: 3,
+			deployments.DeviceDeploymentStatusSuccess: 1,
+		}, nil)
+
+	controller := NewDeploymentsController(deploymentModel,
+		&view.DeploymentsView{StatusNames: names})
+	router, err := rest.MakeRouter(
+		rest.Get("/r/:id/devices/count", controller.GetDeviceDeploymentsCount),
+		rest.Get("/r/:id/statistics", controller.GetDeploymentStats))
+	assert.NoError(t, err)
+
+	api := makeApi(router)
+
+	// filters accept both status and its display name
+	for _, status := range []string{"broken", deployments.DeviceDeploymentStatusFailure} {
+		req := test.MakeSimpleRequest("GET",
+			"http://localhost/r/"+validUUIDv4+"/devices/count?status="+status, nil)
+		req.Header.Add(requestid.RequestIdHeader, "test")
+		recorded := test.RunRequest(t, api.MakeHandler(), req)
+
+		h.CheckRecordedResponse(t, recorded, h.JSONResponseParams{
+			OutputStatus:     http.StatusOK,
+			OutputBodyObject: map[string]int{"count": 3},
+		})
+	}
+
+	req := test.MakeSimpleRequest("GET",
+		"http://localhost/r/"+validUUIDv4+"/statistics", nil)
+	req.Header.Add(requestid.RequestIdHeader, "test")
+	recorded := test.RunRequest(t, api.MakeHandler(), req)
+
+	h.CheckRecordedResponse(t, recorded, h.JSONResponseParams{
+		OutputStatus:     http.StatusOK,
+		OutputBodyObject: map[string]int{"broken": 3, "success": 1},
+	})
+}
+
+func TestControllerLookupDeviceDeployments(t *testing.T) {
+
+	t.Parallel()
+
+	createdAfter := time.Unix(1500000000, 0).UTC()
+
+	failing := []deployments.DeviceDeployment{
+		*deployments.NewDeviceDeployment("device0001", "a108ae14-bb4e-455f-9b40-2ef4bab97bb7"),
+		*deployments.NewDeviceDeployment("device0002", "e8c32ff6-7c1b-43c7-aa31-2e4fc3a3c130"),
+	}
+	failure := deployments.DeviceDeploymentStatusFailure
+	for i := range failing {
+		failing[i].Status = &failure
+	}
+
+	withID := func(d deployments.DeviceDeployment) interface{} {
+		return struct {
+			deployments.DeviceDeployment
+			DeploymentID *string `json:"deployment_id"`
+		}{d, d.DeploymentId}
+	}
+
+	testCases := []struct {
+		h.JSONResponseParams
+
+		InputQuery string
+
+		InputModelQuery             *deployments.DeviceDeploymentsQuery
+		InputModelDeviceDeployments []deployments.DeviceDeployment
+		InputModelError             error
+
+		OutputHasNext bool
+	}{
+		{
+			InputQuery: "status=broken",
+			JSONResponseParams: h.JSONResponseParams{
+				OutputStatus:     http.StatusBadRequest,
+				OutputBodyObject: h.ErrorToErrStruct(errors.New("unknown status broken")),
+			},
+		},
+		{
+			InputQuery: "created_after=yesterday",
+			JSONResponseParams: h.JSONResponseParams{
+				OutputStatus: http.StatusBadRequest,
+				OutputBodyObject: h.ErrorToErrStruct(errors.New(
+					"timestamp parsing failed for created_after parameter: invalid timestamp: yesterday")),
+			},
+		},
+		{
+			InputQuery: "per_page=0",
+			JSONResponseParams: h.JSONResponseParams{
+				OutputStatus:     http.StatusBadRequest,
+				OutputBodyObject: h.ErrorToErrStruct(errors.New("Param per_page is out of bounds")),
+			},
+		},
+		{
+			InputModelQuery: &deployments.DeviceDeploymentsQuery{
+				Limit: 21,
+			},
+			InputModelError: errors.New("model error"),
+			JSONResponseParams: h.JSONResponseParams{
+				OutputStatus:     http.StatusInternalServerError,
+				OutputBodyObject: h.ErrorToErrStruct(errors.New("internal error")),
+			},
+		},
+		{
+			InputModelQuery: &deployments.DeviceDeploymentsQuery{
+				Limit: 21,
+			},
+			InputModelDeviceDeployments: []deployments.DeviceDeployment{},
+			JSONResponseParams: h.JSONResponseParams{
+				OutputStatus:     http.StatusOK,
+				OutputBodyObject: []deployments.DeviceDeployment{},
+			},
+		},
+		{
+			InputQuery: "status=failure&created_after=1500000000",
+			InputModelQuery: &deployments.DeviceDeploymentsQuery{
+				Status:       deployments.DeviceDeploymentStatusFailure,
+				CreatedAfter: &createdAfter,
+				Limit:        21,
+			},
+			InputModelDeviceDeployments: failing,
+			JSONResponseParams: h.JSONResponseParams{
+				OutputStatus: http.StatusOK,
+				OutputBodyObject: []interface{}{
+					withID(failing[0]),
+					withID(failing[1]),
+				},
+			},
+		},
+		{
+			InputQuery: "status=failure&page=2&per_page=1",
+			InputModelQuery: &deployments.DeviceDeploymentsQuery{
+				Status: deployments.DeviceDeploymentStatusFailure,
+				Skip:   1,
+				Limit:  2,
+			},
+			InputModelDeviceDeployments: failing,
+			JSONResponseParams: h.JSONResponseParams{
+				OutputStatus: http.StatusOK,
+				OutputBodyObject: []interface{}{
+					withID(failing[0]),
+				},
+			},
+			OutputHasNext: true,
+		},
+	}
+
+	for testCaseNumber, testCase := range testCases {
+
+		t.Run(fmt.Sprintf("test case %d", testCaseNumber+1), func(t *testing.T) {
+
+			deploymentModel := new(mocks.DeploymentsModel)
+
+			if testCase.InputModelQuery != nil {
+				deploymentModel.On("LookupDeviceDeployments",
+					h.ContextMatcher(), *testCase.InputModelQuery).
+					Return(testCase.InputModelDeviceDeployments, testCase.InputModelError)
+			}
+
+			router, err := rest.MakeRouter(
+				rest.Get("/r",
+					NewDeploymentsController(deploymentModel,
+						new(view.DeploymentsView)).LookupDeviceDeployments))
+			assert.NoError(t, err)
+
+			api := makeApi(router)
+
+			req := test.MakeSimpleRequest("GET",
+				"http://localhost/r?"+testCase.InputQuery, nil)
+			req.Header.Add(requestid.RequestIdHeader, "test")
+			recorded := test.RunRequest(t, api.MakeHandler(), req)
+
+			h.CheckRecordedResponse(t, recorded, testCase.JSONResponseParams)
+			deploymentModel.AssertExpectations(t)
+
+			if recorded.Recorder.Code == http.StatusOK {
+				links := strings.Join(recorded.Recorder.HeaderMap["Link"], ",")
+				assert.Equal(t, testCase.OutputHasNext, strings.Contains(links, `rel="next"`))
+			}
+		})
+	}
+}
+
+func TestControllerLookupDownloads(t *testing.T) {
+
+	t.Parallel()
+
+	createdBefore := time.Unix(1500000000, 0).UTC()
+	created := createdBefore.Add(-time.Hour)
+
+	downloads := []deployments.Download{
+		{
+			Id:           "a108ae14-bb4e-455f-9b40-2ef4bab97bb7",
+			ArtifactID:   validUUIDv4,
+			DeploymentID: "e8c32ff6-7c1b-43c7-aa31-2e4fc3a3c130",
+			DeviceID:     "device0001",
+			Created:      &created,
+		},
+		{
+			Id:         "b108ae14-bb4e-455f-9b40-2ef4bab97bb7",
+			ArtifactID: validUUIDv4,
+			UserID:     "user0001",
+			Created:    &created,
+		},
+	}
+
+	testCases := []struct {
+		h.JSONResponseParams
+
+		InputQuery string
+
+		InputModelQuery     *deployments.DownloadsQuery
+		InputModelDownloads []deployments.Download
+		InputModelError     error
+
+		OutputHasNext bool
+	}{
+		{
+			InputQuery: "created_before=yesterday",
+			JSONResponseParams: h.JSONResponseParams{
+				OutputStatus: http.StatusBadRequest,
+				OutputBodyObject: h.ErrorToErrStruct(errors.New(
+					"timestamp parsing failed for created_before parameter: invalid timestamp: yesterday")),
+			},
+		},
+		{
+			InputModelQuery: &deployments.DownloadsQuery{
+				Limit: 21,
+			},
+			InputModelError: errors.New("model error"),
+			JSONResponseParams: h.JSONResponseParams{
+				OutputStatus:     http.StatusInternalServerError,
+				OutputBodyObject: h.ErrorToErrStruct(errors.New("internal error")),
+			},
+		},
+		{
+			InputQuery: "artifact_id=" + validUUIDv4 + "&created_before=1500000000",
+			InputModelQuery: &deployments.DownloadsQuery{
+				ArtifactID:    validUUIDv4,
+				CreatedBefore: &createdBefore,
+				Limit:         21,
+			},
+			InputModelDownloads: downloads,
+			JSONResponseParams: h.JSONResponseParams{
+				OutputStatus:     http.StatusOK,
+				OutputBodyObject: downloads,
+			},
+		},
+		{
+			InputQuery: "device_id=device0001&deployment_id=e8c32ff6-7c1b-43c7-aa31-2e4fc3a3c130&per_page=1",
+			InputModelQuery: &deployments.DownloadsQuery{
+				DeviceID:     "device0001",
+				DeploymentID: "e8c32ff6-7c1b-43c7-aa31-2e4fc3a3c130",
+				Limit:        2,
+			},
+			InputModelDownloads: downloads,
+			JSONResponseParams: h.JSONResponseParams{
+				OutputStatus:     http.StatusOK,
+				OutputBodyObject: downloads[:1],
+			},
+			OutputHasNext: true,
+		},
+	}
+
+	for testCaseNumber, testCase := range testCases {
+
+		t.Run(fmt.Sprintf("test case %d", testCaseNumber+1), func(t *testing.T) {
+
+			deploymentModel := new(mocks.DeploymentsModel)
+
+			if testCase.InputModelQuery != nil {
+				deploymentModel.On("LookupDownloads",
+					h.ContextMatcher(), *testCase.InputModelQuery).
+					Return(testCase.InputModelDownloads, testCase.InputModelError)
+			}
+
+			router, err := rest.MakeRouter(
+				rest.Get("/r",
+					NewDeploymentsController(deploymentModel,
+						new(view.DeploymentsView)).LookupDownloads))
+			assert.NoError(t, err)
+
+			api := makeApi(router)
+
+			req := test.MakeSimpleRequest("GET",
+				"http://localhost/r?"+testCase.InputQuery, nil)
+			req.Header.Add(requestid.RequestIdHeader, "test")
+			recorded := test.RunRequest(t, api.MakeHandler(), req)
+
+			h.CheckRecordedResponse(t, recorded, testCase.JSONResponseParams)
+			deploymentModel.AssertExpectations(t)
+
+			if recorded.Recorder.Code == http.StatusOK {
+				links := strings.Join(recorded.Recorder.HeaderMap["Link"], ",")
+				assert.Equal(t, testCase.OutputHasNext, strings.Contains(links, `rel="next"`))
+			}
+		})
+	}
+}
+
+func TestControllerRevokeDeviceDeploymentLink(t *testing.T) {
+
+	t.Parallel()
+
+	testCases := []struct {
+		h.JSONResponseParams
+
+		InputID         string
+		InputModelError error
+	}{
+		{
+			InputID: "bad-id",
+			JSONResponseParams: h.JSONResponseParams{
+				OutputStatus:     http.StatusBadRequest,
+				OutputBodyObject: h.ErrorToErrStruct(ErrIDNotUUIDv4),
+			},
+		},
+		{
+			InputID:         validUUIDv4,
+			InputModelError: ErrStorageNotFound,
+			JSONResponseParams: h.JSONResponseParams{
+				OutputStatus:     http.StatusNotFound,
+				OutputBodyObject: h.ErrorToErrStruct(errors.New("Resource not found")),
+			},
+		},
+		{
+			InputID: validUUIDv4,
+			InputModelError: pkgerrors.Wrap(deployments.NewStoreError("RevokeDeviceDeploymentLink",
+				"devices", ErrStorageNotFound, "device-1", validUUIDv4), "failed to revoke link"),
+			JSONResponseParams: h.JSONResponseParams{
+				OutputStatus:     http.StatusNotFound,
+				OutputBodyObject: h.ErrorToErrStruct(errors.New("Resource not found")),
+			},
+		},
+		{
+			InputID: validUUIDv4,
+			InputModelError: deployments.NewStoreError("RevokeDeviceDeploymentLink",
+				"devices", deployments.ErrStorageInvalidID, "device-1", validUUIDv4),
+			JSONResponseParams: h.JSONResponseParams{
+				OutputStatus:     http.StatusBadRequest,
+				OutputBodyObject: h.ErrorToErrStruct(deployments.ErrStorageInvalidID),
+			},
+		},
+		{
+			InputID:         validUUIDv4,
+			InputModelError: ErrDownloadProxyDisabled,
+			JSONResponseParams: h.JSONResponseParams{
+				OutputStatus:     http.StatusConflict,
+				OutputBodyObject: h.ErrorToErrStruct(ErrDownloadProxyDisabled),
+			},
+		},
+		{
+			InputID:         validUUIDv4,
+			InputModelError: errors.New("model error"),
+			JSONResponseParams: h.JSONResponseParams{
+				OutputStatus:     http.StatusInternalServerError,
+				OutputBodyObject: h.ErrorToErrStruct(errors.New("internal error")),
+			},
+		},
+		{
+			InputID: validUUIDv4,
+			JSONResponseParams: h.JSONResponseParams{
+				OutputStatus: http.StatusNoContent,
+			},
+		},
+	}
+
+	for testCaseNumber, testCase := range testCases {
+
+		t.Run(fmt.Sprintf("test case %d", testCaseNumber+1), func(t *testing.T) {
+
+			deploymentModel := new(mocks.DeploymentsModel)
+
+			deploymentModel.On("RevokeDeviceDeploymentLink",
+				h.ContextMatcher(), testCase.InputID, "device-1").
+				Return(testCase.InputModelError)
+
+			router, err := rest.MakeRouter(
+				rest.Delete("/r/:id/devices/:devid/link",
+					NewDeploymentsController(deploymentModel,
+						new(view.DeploymentsView)).RevokeDeviceDeploymentLink))
+			assert.NoError(t, err)
+
+			api := makeApi(router)
+
+			req := test.MakeSimpleRequest("DELETE",
+				"http://localhost/r/"+testCase.InputID+"/devices/device-1/link", nil)
+			req.Header.Add(requestid.RequestIdHeader, "test")
+			recorded := test.RunRequest(t, api.MakeHandler(), req)
+
+			h.CheckRecordedResponse(t, recorded, testCase.JSONResponseParams)
+		})
+	}
+}
+
+func TestControllerGetDeviceDeploymentDownload(t *testing.T) {
+
+	t.Parallel()
+
+	testCases := map[string]struct {
+		InputQuery      string
+		InputLink       *images.Link
+		InputModelError error
+
+		OutputTenant   string
+		OutputStatus   int
+		OutputLocation string
+		OutputError    error
+	}{
+		"ok": {
+			InputQuery: "?tenant_id=tenant-1&expire=1700000000&signature=abc",
+			InputLink:  &images.Link{Uri: "https://s3.example.com/artifact?sig=1"},
+
+			OutputTenant:   "tenant-1",
+			OutputStatus:   http.StatusFound,
+			OutputLocation: "https://s3.example.com/artifact?sig=1",
+		},
+		"ok, no tenant": {
+			InputQuery: "?expire=1700000000&signature=abc",
+			InputLink:  &images.Link{Uri: "https://s3.example.com/artifact?sig=1"},
+
+			OutputStatus:   http.StatusFound,
+			OutputLocation: "https://s3.example.com/artifact?sig=1",
+		},
+		"bad expire": {
+			InputQuery: "?tenant_id=tenant-1&expire=never&signature=abc",
+
+			OutputStatus: http.StatusForbidden,
+			OutputError:  ErrDownloadLinkInvalid,
+		},
+		"invalid link": {
+			InputQuery:      "?tenant_id=tenant-1&expire=1700000000&signature=abc",
+			InputModelError: ErrDownloadLinkInvalid,
+
+			OutputTenant: "tenant-1",
+			OutputStatus: http.StatusForbidden,
+			OutputError:  ErrDownloadLinkInvalid,
+		},
+		"revoked": {
+			InputQuery:      "?tenant_id=tenant-1&expire=1700000000&signature=abc",
+			InputModelError: ErrDownloadLinkRevoked,
+
+			OutputTenant: "tenant-1",
+			OutputStatus: http.StatusGone,
+			OutputError:  ErrDownloadLinkRevoked,
+		},
+		"proxy disabled": {
+			InputQuery:      "?tenant_id=tenant-1&expire=1700000000&signature=abc",
+			InputModelError: ErrDownloadProxyDisabled,
+
+			OutputTenant: "tenant-1",
+			OutputStatus: http.StatusNotFound,
+			OutputError:  errors.New("Resource not found"),
+		},
+		"not found": {
+			InputQuery:      "?tenant_id=tenant-1&expire=1700000000&signature=abc",
+			InputModelError: ErrStorageNotFound,
+
+			OutputTenant: "tenant-1",
+			OutputStatus: http.StatusNotFound,
+			OutputError:  errors.New("Resource not found"),
+		},
+	}
+
+	for name, testCase := range testCases {
+		testCase := testCase
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			deploymentModel := new(mocks.DeploymentsModel)
+			deploymentModel.On("GetDeviceDeploymentDownload",
+				mock.MatchedBy(func(ctx context.Context) bool {
+					id := identity.FromContext(ctx)
+					if testCase.OutputTenant == "" {
+						return id == nil
+					}
+					return id != nil && id.Tenant == testCase.OutputTenant
+				}),
+				validUUIDv4, "device-1", int64(1700000000), "abc").
+				Return(testCase.InputLink, testCase.InputModelError)
+
+			router, err := rest.MakeRouter(
+				rest.Get("/r/download/:id/:devid",
+					NewDeploymentsController(deploymentModel,
+						new(view.DeploymentsView)).GetDeviceDeploymentDownload))
+			assert.NoError(t, err)
+
+			api := makeApi(router)
+
+			req := test.MakeSimpleRequest("GET",
+				"http://localhost/r/download/"+validUUIDv4+"/device-1"+testCase.InputQuery,
+				nil)
+			req.Header.Add(requestid.RequestIdHeader, "test")
+			recorded := test.RunRequest(t, api.MakeHandler(), req)
+
+			if testCase.OutputError != nil {
+				h.CheckRecordedResponse(t, recorded, h.JSONResponseParams{
+					OutputStatus:     testCase.OutputStatus,
+					OutputBodyObject: h.ErrorToErrStruct(testCase.OutputError),
+				})
+			} else {
+				recorded.CodeIs(testCase.OutputStatus)
+				recorded.HeaderIs("Location", testCase.OutputLocation)
+			}
+		})
+	}
+}
+
+func TestControllerRetryDeviceDeployment(t *testing.T) {
+
+	t.Parallel()
+
+	testCases := map[string]struct {
+		h.JSONResponseParams
+
+		InputID         string
+		InputModelError error
+	}{
+		"bad id": {
+			InputID: "bad-id",
+			JSONResponseParams: h.JSONResponseParams{
+				OutputStatus:     http.StatusBadRequest,
+				OutputBodyObject: h.ErrorToErrStruct(ErrIDNotUUIDv4),
+			},
+		},
+		"not found": {
+			InputID:         validUUIDv4,
+			InputModelError: ErrStorageNotFound,
+			JSONResponseParams: h.JSONResponseParams{
+				OutputStatus:     http.StatusNotFound,
+				OutputBodyObject: h.ErrorToErrStruct(errors.New("Resource not found")),
+			},
+		},
+		"not failed": {
+			InputID:         validUUIDv4,
+			InputModelError: ErrRetryNotAllowed,
+			JSONResponseParams: h.JSONResponseParams{
+				OutputStatus:     http.StatusConflict,
+				OutputBodyObject: h.ErrorToErrStruct(ErrRetryNotAllowed),
+			},
+		},
+		"limit reached": {
+			InputID:         validUUIDv4,
+			InputModelError: ErrRetryLimitReached,
+			JSONResponseParams: h.JSONResponseParams{
+				OutputStatus:     http.StatusConflict,
+				OutputBodyObject: h.ErrorToErrStruct(ErrRetryLimitReached),
+			},
+		},
+		"model error": {
+			InputID:         validUUIDv4,
+			InputModelError: errors.New("model error"),
+			JSONResponseParams: h.JSONResponseParams{
+				OutputStatus:     http.StatusInternalServerError,
+				OutputBodyObject: h.ErrorToErrStruct(errors.New("internal error")),
+			},
+		},
+		"ok": {
+			InputID: validUUIDv4,
+			JSONResponseParams: h.JSONResponseParams{
+				OutputStatus: http.StatusNoContent,
+			},
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+
+			deploymentModel := new(mocks.DeploymentsModel)
+			deploymentModel.On("RetryDeviceDeployment",
+				h.ContextMatcher(), testCase.InputID, "device-1").
+				Return(testCase.InputModelError)
+
+			router, err := rest.MakeRouter(
+				rest.Post("/r/:id/devices/:devid/retry",
+					NewDeploymentsController(deploymentModel,
+						new(view.DeploymentsView)).RetryDeviceDeployment))
+			assert.NoError(t, err)
+
+			api := makeApi(router)
+
+			req := test.MakeSimpleRequest("POST",
+				"http://localhost/r/"+testCase.InputID+"/devices/device-1/retry", nil)
+			req.Header.Add(requestid.RequestIdHeader, "test")
+			recorded := test.RunRequest(t, api.MakeHandler(), req)
+
+			h.CheckRecordedResponse(t, recorded, testCase.JSONResponseParams)
+		})
+	}
+}
+
+func TestControllerDecommissionDevice(t *testing.T) {
+
+	t.Parallel()
+
+	testCases := []struct {
+		h.JSONResponseParams
+
+		InputModelDeviceId string
+		InputModelError    error
+	}{
+		{
+			// input model error
+			InputModelDeviceId: "58e755077478ee0001b065ed",
+			InputModelError:    errors.New("Input model error"),
+
+			JSONResponseParams: h.JSONResponseParams{
+				OutputStatus:     http.StatusInternalServerError,
+				OutputBodyObject: h.ErrorToErrStruct(errors.New("internal error")),
+			},
+		},
+		{
+			// all correct
+			InputModelDeviceId: "58e755077478ee0001b065ed",
+
+			JSONResponseParams: h.JSONResponseParams{
+				OutputStatus: http.StatusNoContent,
+			},
+		},
+	}
+
+	for testCaseNumber, testCase := range testCases {
+
+		t.Run(fmt.Sprintf("test case %d", testCaseNumber+1), func(t *testing.T) {
+
+			deploymentModel := new(mocks.DeploymentsModel)
+
+			deploymentModel.On("DecommissionDevice",
+				h.ContextMatcher(), testCase.InputModelDeviceId).
+				Return(testCase.InputModelError)
+
+			router, err := rest.MakeRouter(
+				rest.Delete("/r/:id",
+					NewDeploymentsController(deploymentModel,
+						new(view.DeploymentsView)).DecommissionDevice))
+			assert.NoError(t, err)
+
+			api := makeApi(router)
+
+			req := test.MakeSimpleRequest("DELETE", "http://localhost/r/"+testCase.InputModelDeviceId, nil)
+			req.Header.Add(requestid.RequestIdHeader, "test")
+			recorded := test.RunRequest(t, api.MakeHandler(), req)
+
+			h.CheckRecordedResponse(t, recorded, testCase.JSONResponseParams)
+		})
+	}
+}
+
+func TestControllerPostFreezePeriod(t *testing.T) {
+
+	t.Parallel()
+
+	start := time.Date(2018, 12, 20, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2019, 1, 2, 0, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		h.JSONResponseParams
+
+		InputBodyObject interface{}
+
+		InputModelID    string
+		InputModelError error
+	}{
+		{
+			InputBodyObject: nil,
+			JSONResponseParams: h.JSONResponseParams{
+				OutputStatus:     http.StatusBadRequest,
+				OutputBodyObject: h.ErrorToErrStruct(errors.New("Validating request body: JSON payload is empty")),
+			},
+		},
+		{
+			InputBodyObject: &deployments.FreezePeriodConstructor{
+				Name:  "holidays",
+				Start: &end,
+				End:   &start,
+			},
+			JSONResponseParams: h.JSONResponseParams{
+				OutputStatus: http.StatusBadRequest,
+				OutputBodyObject: h.ErrorToErrStruct(errors.New(
+					"Validating request body: " + deployments.ErrInvalidFreezePeriod.Error())),
+			},
+		},
+		{
+			InputBodyObject: &deployments.FreezePeriodConstructor{
+				Name:  "holidays",
+				Start: &start,
+				End:   &end,
+			},
+			InputModelError: errors.New("model error"),
+			JSONResponseParams: h.JSONResponseParams{
+				OutputStatus:     http.StatusInternalServerError,
+				OutputBodyObject: h.ErrorToErrStruct(errors.New("internal error")),
+			},
+		},
+		{
+			InputBodyObject: &deployments.FreezePeriodConstructor{
+				Name:  "holidays",
+				Start: &start,
+				End:   &end,
+			},
+			InputModelID: "1234",
+			JSONResponseParams: h.JSONResponseParams{
+				OutputStatus:  http.StatusCreated,
+				OutputHeaders: map[string]string{"Location": "./r/1234"},
+			},
+		},
+	}
+
+	for testCaseNumber, testCase := range testCases {
+
+		t.Run(fmt.Sprintf("test case %d", testCaseNumber+1), func(t *testing.T) {
+
+			deploymentModel := new(mocks.DeploymentsModel)
+
+			deploymentModel.On("CreateFreezePeriod",
+				h.ContextMatcher(), testCase.InputBodyObject).
+				Return(testCase.InputModelID, testCase.InputModelError)
+
+			router, err := rest.MakeRouter(
+				rest.Post("/r",
+					NewDeploymentsController(deploymentModel,
+						new(view.DeploymentsView)).PostFreezePeriod))
+			assert.NoError(t, err)
+
+			api := makeApi(router)
+
+			req := test.MakeSimpleRequest("POST", "http://localhost/r", testCase.InputBodyObject)
+			req.Header.Add(requestid.RequestIdHeader, "test")
+			recorded := test.RunRequest(t, api.MakeHandler(), req)
+
+			h.CheckRecordedResponse(t, recorded, testCase.JSONResponseParams)
+		})
+	}
+}
+
+func TestControllerGetFreezePeriods(t *testing.T) {
+
+	t.Parallel()
+
+	start := time.Date(2018, 12, 20, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2019, 1, 2, 0, 0, 0, 0, time.UTC)
+	periods := []*deployments.FreezePeriod{
+		{
+			FreezePeriodConstructor: &deployments.FreezePeriodConstructor{
+				Name:  "holidays",
+				Start: &start,
+				End:   &end,
+			},
+			Id:      validUUIDv4,
+			Created: &start,
+		},
+	}
+
+	testCases := []struct {
+		h.JSONResponseParams
+
+		InputModelPeriods []*deployments.FreezePeriod
+		InputModelError   error
+	}{
+		{
+			InputModelError: errors.New("model error"),
+			JSONResponseParams: h.JSONResponseParams{
+				OutputStatus:     http.StatusInternalServerError,
+				OutputBodyObject: h.ErrorToErrStruct(errors.New("internal error")),
+			},
+		},
+		{
+			InputModelPeriods: periods,
+			JSONResponseParams: h.JSONResponseParams{
+				OutputStatus:     http.StatusOK,
+				OutputBodyObject: periods,
+			},
+		},
+	}
+
+	for testCaseNumber, testCase := range testCases {
+
+		t.Run(fmt.Sprintf("test case %d", testCaseNumber+1), func(t *testing.T) {
+
+			deploymentModel := new(mocks.DeploymentsModel)
+
+			deploymentModel.On("GetFreezePeriods", h.ContextMatcher()).
+				Return(testCase.InputModelPeriods, testCase.InputModelError)
+
+			router, err := rest.MakeRouter(
+				rest.Get("/r",
+					NewDeploymentsController(deploymentModel,
+						new(view.DeploymentsView)).GetFreezePeriods))
+			assert.NoError(t, err)
+
+			api := makeApi(router)
+
+			req := test.MakeSimpleRequest("GET", "http://localhost/r", nil)
+			req.Header.Add(requestid.RequestIdHeader, "test")
+			recorded := test.RunRequest(t, api.MakeHandler(), req)
+
+			h.CheckRecordedResponse(t, recorded, testCase.JSONResponseParams)
+		})
+	}
+}
+
+func TestControllerDeleteFreezePeriod(t *testing.T) {
+
+	t.Parallel()
+
+	testCases := []struct {
+		h.JSONResponseParams
+
+		InputID         string
+		InputModelError error
+	}{
+		{
+			InputID: "bad-id",
+			JSONResponseParams: h.JSONResponseParams{
+				OutputStatus:     http.StatusBadRequest,
+				OutputBodyObject: h.ErrorToErrStruct(ErrIDNotUUIDv4),
+			},
+		},
+		{
+			InputID:         validUUIDv4,
+			InputModelError: errors.New("model error"),
+			JSONResponseParams: h.JSONResponseParams{
+				OutputStatus:     http.StatusInternalServerError,
+				OutputBodyObject: h.ErrorToErrStruct(errors.New("internal error")),
+			},
+		},
+		{
+			InputID: validUUIDv4,
+			JSONResponseParams: h.JSONResponseParams{
+				OutputStatus: http.StatusNoContent,
+			},
+		},
+	}
+
+	for testCaseNumber, testCase := range testCases {
+
+		t.Run(fmt.Sprintf("test case %d", testCaseNumber+1), func(t *testing.T) {
+
+			deploymentModel := new(mocks.DeploymentsModel)
+
+			deploymentModel.On("DeleteFreezePeriod",
+				h.ContextMatcher(), testCase.InputID).
+				Return(testCase.InputModelError)
+
+			router, err := rest.MakeRouter(
+				rest.Delete("/r/:id",
+					NewDeploymentsController(deploymentModel,
+						new(view.DeploymentsView)).DeleteFreezePeriod))
+			assert.NoError(t, err)
+
+			api := makeApi(router)
+
+			req := test.MakeSimpleRequest("DELETE", "http://localhost/r/"+testCase.InputID, nil)
+			req.Header.Add(requestid.RequestIdHeader, "test")
+			recorded := test.RunRequest(t, api.MakeHandler(), req)
+
+			h.CheckRecordedResponse(t, recorded, testCase.JSONResponseParams)
+		})
+	}
+}
+
+func TestControllerGetDeviceTypeAliases(t *testing.T) {
+
+	t.Parallel()
+
+	aliases := []*deployments.DeviceTypeAlias{
+		{Alias: "rpi4", DeviceType: "raspberrypi4"},
+	}
+
+	testCases := map[string]struct {
+		h.JSONResponseParams
+
+		InputModelAliases []*deployments.DeviceTypeAlias
+		InputModelError   error
+	}{
+		"model error": {
+			InputModelError: errors.New("model error"),
+			JSONResponseParams: h.JSONResponseParams{
+				OutputStatus:     http.StatusInternalServerError,
+				OutputBodyObject: h.ErrorToErrStruct(errors.New("internal error")),
+			},
+		},
+		"ok": {
+			InputModelAliases: aliases,
+			JSONResponseParams: h.JSONResponseParams{
+				OutputStatus:     http.StatusOK,
+				OutputBodyObject: aliases,
+			},
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+
+			deploymentModel := new(mocks.DeploymentsModel)
+			deploymentModel.On("GetDeviceTypeAliases", h.ContextMatcher()).
+				Return(testCase.InputModelAliases, testCase.InputModelError)
+
+			router, err := rest.MakeRouter(
+				rest.Get("/r",
+					NewDeploymentsController(deploymentModel,
+						new(view.DeploymentsView)).GetDeviceTypeAliases))
+			assert.NoError(t, err)
+
+			api := makeApi(router)
+
+			req := test.MakeSimpleRequest("GET", "http://localhost/r", nil)
+			req.Header.Add(requestid.RequestIdHeader, "test")
+			recorded := test.RunRequest(t, api.MakeHandler(), req)
+
+			h.CheckRecordedResponse(t, recorded, testCase.JSONResponseParams)
+		})
+	}
+}
+
+func TestControllerPutDeviceTypeAlias(t *testing.T) {
+
+	t.Parallel()
+
+	testCases := map[string]struct {
+		h.JSONResponseParams
+
+		InputAlias      string
+		InputBodyObject interface{}
+		InputModelAlias *deployments.DeviceTypeAlias
+		InputModelError error
+	}{
+		"empty body": {
+			InputAlias: "rpi4",
+			JSONResponseParams: h.JSONResponseParams{
+				OutputStatus:     http.StatusBadRequest,
+				OutputBodyObject: h.ErrorToErrStruct(errors.New("Validating request body: JSON payload is empty")),
+			},
+		},
+		"missing device type": {
+			InputAlias:      "rpi4",
+			InputBodyObject: map[string]interface{}{"device_type": ""},
+			JSONResponseParams: h.JSONResponseParams{
+				OutputStatus: http.StatusBadRequest,
+				OutputBodyObject: h.ErrorToErrStruct(
+					errors.New("Validating request body: DeviceType: non zero value required;")),
+			},
+		},
+		"alias of itself": {
+			InputAlias:      "rpi4",
+			InputBodyObject: map[string]interface{}{"device_type": "rpi4"},
+			JSONResponseParams: h.JSONResponseParams{
+				OutputStatus: http.StatusBadRequest,
+				OutputBodyObject: h.ErrorToErrStruct(errors.New("Validating request body: " +
+					deployments.ErrDeviceTypeAliasSelf.Error())),
+			},
+		},
+		"model error": {
+			InputAlias:      "rpi4",
+			InputBodyObject: map[string]interface{}{"device_type": "raspberrypi4"},
+			InputModelAlias: &deployments.DeviceTypeAlias{
+				Alias:      "rpi4",
+				DeviceType: "raspberrypi4",
+			},
+			InputModelError: errors.New("model error"),
+			JSONResponseParams: h.JSONResponseParams{
+				OutputStatus:     http.StatusInternalServerError,
+				OutputBodyObject: h.ErrorToErrStruct(errors.New("internal error")),
+			},
+		},
+		"ok, alias taken from path": {
+			InputAlias: "rpi4",
+			InputBodyObject: map[string]interface{}{
+				"alias":       "other",
+				"device_type": "raspberrypi4",
+			},
+			InputModelAlias: &deployments.DeviceTypeAlias{
+				Alias:      "rpi4",
+				DeviceType: "raspberrypi4",
+			},
+			JSONResponseParams: h.JSONResponseParams{
+				OutputStatus: http.StatusNoContent,
+			},
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+
+			deploymentModel := new(mocks.DeploymentsModel)
+			deploymentModel.On("SetDeviceTypeAlias",
+				h.ContextMatcher(), testCase.InputModelAlias).
+				Return(testCase.InputModelError)
+
+			router, err := rest.MakeRouter(
+				rest.Put("/r/:alias",
+					NewDeploymentsController(deploymentModel,
+						new(view.DeploymentsView)).PutDeviceTypeAlias))
+			assert.NoError(t, err)
+
+			api := makeApi(router)
 
-			req := test.MakeSimpleRequest("GET", u.String(), nil)
+			req := test.MakeSimpleRequest("PUT",
+				"http://localhost/r/"+testCase.InputAlias, testCase.InputBodyObject)
 			req.Header.Add(requestid.RequestIdHeader, "test")
 			recorded := test.RunRequest(t, api.MakeHandler(), req)
+
 			h.CheckRecordedResponse(t, recorded, testCase.JSONResponseParams)
 		})
 	}
 }
 
-func TestParseLookupQuery(t *testing.T) {
-	testCases := []struct {
-		vals  url.Values
-		query deployments.Query
-		err   error
+func TestControllerDeleteDeviceTypeAlias(t *testing.T) {
+
+	t.Parallel()
+
+	testCases := map[string]struct {
+		h.JSONResponseParams
+
+		InputModelError error
 	}{
-		{
-			vals: url.Values{
-				"search": []string{"foo"},
-				"status": []string{"inprogress"},
+		"model error": {
+			InputModelError: errors.New("model error"),
+			JSONResponseParams: h.JSONResponseParams{
+				OutputStatus:     http.StatusInternalServerError,
+				OutputBodyObject: h.ErrorToErrStruct(errors.New("internal error")),
 			},
-			query: deployments.Query{
-				SearchText: "foo",
-				Status:     deployments.StatusQueryInProgress,
+		},
+		"ok": {
+			JSONResponseParams: h.JSONResponseParams{
+				OutputStatus: http.StatusNoContent,
 			},
 		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+
+			deploymentModel := new(mocks.DeploymentsModel)
+			deploymentModel.On("DeleteDeviceTypeAlias",
+				h.ContextMatcher(), "rpi4").
+				Return(testCase.InputModelError)
+
+			router, err := rest.MakeRouter(
+				rest.Delete("/r/:alias",
+					NewDeploymentsController(deploymentModel,
+						new(view.DeploymentsView)).DeleteDeviceTypeAlias))
+			assert.NoError(t, err)
+
+			api := makeApi(router)
+
+			req := test.MakeSimpleRequest("DELETE", "http://localhost/r/rpi4", nil)
+			req.Header.Add(requestid.RequestIdHeader, "test")
+			recorded := test.RunRequest(t, api.MakeHandler(), req)
+
+			h.CheckRecordedResponse(t, recorded, testCase.JSONResponseParams)
+		})
+	}
+}
+
+func TestControllerPostCampaign(t *testing.T) {
+
+	t.Parallel()
+
+	constructor := &deployments.CampaignConstructor{
+		Name:        "release 1.2",
+		Deployments: []string{validUUIDv4},
+	}
+
+	testCases := []struct {
+		h.JSONResponseParams
+
+		InputBodyObject interface{}
+
+		InputModelID    string
+		InputModelError error
+	}{
 		{
-			vals: url.Values{
-				"search": []string{"foo"},
-				"status": []string{"bar"},
+			InputBodyObject: nil,
+			JSONResponseParams: h.JSONResponseParams{
+				OutputStatus:     http.StatusBadRequest,
+				OutputBodyObject: h.ErrorToErrStruct(errors.New("Validating request body: JSON payload is empty")),
 			},
-			err: errors.New("unknown status bar"),
 		},
 		{
-			vals: url.Values{
-				"search": []string{"foo"},
-				"status": []string{"finished"},
+			InputBodyObject: &deployments.CampaignConstructor{
+				Name: "release 1.2",
 			},
-			query: deployments.Query{
-				SearchText: "foo",
-				Status:     deployments.StatusQueryFinished,
+			JSONResponseParams: h.JSONResponseParams{
+				OutputStatus: http.StatusBadRequest,
+				OutputBodyObject: map[string]interface{}{
+					"error":      "Validating request body: deployments: at least one deployment is required;",
+					"request_id": "test",
+					"fields": []deployments.FieldError{
+						{
+							Field:   "deployments",
+							Code:    deployments.ValidationCodeRequired,
+							Message: "at least one deployment is required",
+						},
+					},
+				},
 			},
 		},
 		{
-			vals: url.Values{
-				"search": []string{"foo"},
-				"status": []string{"pending"},
-			},
-			query: deployments.Query{
-				SearchText: "foo",
-				Status:     deployments.StatusQueryPending,
+			InputBodyObject: constructor,
+			InputModelError: deployments.NewValidationError("deployments[0]",
+				deployments.ValidationCodeNotFound, ErrModelDeploymentNotFound.Error()),
+			JSONResponseParams: h.JSONResponseParams{
+				OutputStatus: http.StatusUnprocessableEntity,
+				OutputBodyObject: map[string]interface{}{
+					"error":      "deployments[0]: " + ErrModelDeploymentNotFound.Error() + ";",
+					"request_id": "test",
+					"fields": []deployments.FieldError{
+						{
+							Field:   "deployments[0]",
+							Code:    deployments.ValidationCodeNotFound,
+							Message: ErrModelDeploymentNotFound.Error(),
+						},
+					},
+				},
 			},
 		},
 		{
-			vals: url.Values{
-				"search": []string{"foo"},
-			},
-			query: deployments.Query{
-				SearchText: "foo",
-				Status:     deployments.StatusQueryAny,
+			InputBodyObject: constructor,
+			InputModelError: errors.New("model error"),
+			JSONResponseParams: h.JSONResponseParams{
+				OutputStatus:     http.StatusInternalServerError,
+				OutputBodyObject: h.ErrorToErrStruct(errors.New("internal error")),
 			},
 		},
 		{
-			vals: url.Values{},
-			query: deployments.Query{
-				SearchText: "",
-				Status:     deployments.StatusQueryAny,
+			InputBodyObject: constructor,
+			InputModelID:    "1234",
+			JSONResponseParams: h.JSONResponseParams{
+				OutputStatus:  http.StatusCreated,
+				OutputHeaders: map[string]string{"Location": "./r/1234"},
 			},
 		},
+	}
+
+	for testCaseNumber, testCase := range testCases {
+
+		t.Run(fmt.Sprintf("test case %d", testCaseNumber+1), func(t *testing.T) {
+
+			deploymentModel := new(mocks.DeploymentsModel)
+
+			deploymentModel.On("CreateCampaign",
+				h.ContextMatcher(), testCase.InputBodyObject).
+				Return(testCase.InputModelID, testCase.InputModelError)
+
+			router, err := rest.MakeRouter(
+				rest.Post("/r",
+					NewDeploymentsController(deploymentModel,
+						new(view.DeploymentsView)).PostCampaign))
+			assert.NoError(t, err)
+
+			api := makeApi(router)
+
+			req := test.MakeSimpleRequest("POST", "http://localhost/r", testCase.InputBodyObject)
+			req.Header.Add(requestid.RequestIdHeader, "test")
+			recorded := test.RunRequest(t, api.MakeHandler(), req)
+
+			h.CheckRecordedResponse(t, recorded, testCase.JSONResponseParams)
+		})
+	}
+}
+
+func TestControllerGetCampaignStats(t *testing.T) {
+
+	t.Parallel()
+
+	testCases := []struct {
+		h.JSONResponseParams
+
+		InputID         string
+		InputModelStats deployments.Stats
+		InputModelError error
+	}{
 		{
-			vals: url.Values{
-				"status": []string{"pending"},
-			},
-			query: deployments.Query{
-				SearchText: "",
-				Status:     deployments.StatusQueryPending,
+			InputID: "bad-id",
+			JSONResponseParams: h.JSONResponseParams{
+				OutputStatus:     http.StatusBadRequest,
+				OutputBodyObject: h.ErrorToErrStruct(ErrIDNotUUIDv4),
 			},
 		},
 		{
-			vals: url.Values{
-				"created_after":  []string{"100"},
-				"created_before": []string{"x"},
-			},
-			query: deployments.Query{
-				CreatedAfter:  nil,
-				CreatedBefore: nil,
+			InputID:         validUUIDv4,
+			InputModelError: errors.New("model error"),
+			JSONResponseParams: h.JSONResponseParams{
+				OutputStatus:     http.StatusInternalServerError,
+				OutputBodyObject: h.ErrorToErrStruct(errors.New("internal error")),
 			},
 		},
 		{
-			vals: url.Values{
-				"created_after":  []string{"x"},
-				"created_before": []string{"x"},
-			},
-			query: deployments.Query{
-				CreatedAfter:  nil,
-				CreatedBefore: nil,
+			InputID: validUUIDv4,
+			JSONResponseParams: h.JSONResponseParams{
+				OutputStatus:     http.StatusNotFound,
+				OutputBodyObject: h.ErrorToErrStruct(errors.New("Resource not found")),
 			},
 		},
 		{
-			vals: url.Values{
-				"created_before": []string{"111111111111"},
-				"created_after":  []string{"111111111111"},
-			},
-			query: deployments.Query{
-				CreatedBefore: TimePtr(time.Unix(111111111111, 0).UTC()),
-				CreatedAfter:  TimePtr(time.Unix(111111111111, 0).UTC()),
+			InputID:         validUUIDv4,
+			InputModelStats: deployments.Stats{deployments.DeviceDeploymentStatusSuccess: 12},
+			JSONResponseParams: h.JSONResponseParams{
+				OutputStatus:     http.StatusOK,
+				OutputBodyObject: deployments.Stats{deployments.DeviceDeploymentStatusSuccess: 12},
 			},
 		},
 	}
 
-	for testCaseNumber, tc := range testCases {
+	for testCaseNumber, testCase := range testCases {
 
 		t.Run(fmt.Sprintf("test case %d", testCaseNumber+1), func(t *testing.T) {
 
-			q, err := ParseLookupQuery(tc.vals)
-			if tc.err != nil {
-				assert.Error(t, err)
-				assert.EqualError(t, tc.err, err.Error())
-			} else {
-				assert.Equal(t, tc.query, q)
-			}
-		})
-	}
-}
+			deploymentModel := new(mocks.DeploymentsModel)
 
-func TestControllerPutDeploymentLog(t *testing.T) {
+			deploymentModel.On("GetCampaignStats",
+				h.ContextMatcher(), testCase.InputID).
+				Return(testCase.InputModelStats, testCase.InputModelError)
 
-	t.Parallel()
+			router, err := rest.MakeRouter(
+				rest.Get("/r/:id",
+					NewDeploymentsController(deploymentModel,
+						new(view.DeploymentsView)).GetCampaignStats))
+			assert.NoError(t, err)
 
-	type log struct {
-		Messages string `json:"messages"`
-	}
+			api := makeApi(router)
 
-	tref := time.Now().UTC()
+			req := test.MakeSimpleRequest("GET", "http://localhost/r/"+testCase.InputID, nil)
+			req.Header.Add(requestid.RequestIdHeader, "test")
+			recorded := test.RunRequest(t, api.MakeHandler(), req)
 
-	messages := []deployments.LogMessage{
-		{
-			Timestamp: &tref,
-			Message:   "foo",
-			Level:     "notice",
-		},
+			h.CheckRecordedResponse(t, recorded, testCase.JSONResponseParams)
+		})
 	}
+}
+
+func TestControllerPutCampaignStatus(t *testing.T) {
+
+	t.Parallel()
 
 	testCases := []struct {
 		h.JSONResponseParams
-		InputBodyObject interface{}
 
-		InputModelDeploymentID string
-		InputModelDeviceID     string
-		InputModelMessages     []deployments.LogMessage
-		InputModelError        error
+		InputID         string
+		InputBodyObject interface{}
 
-		Headers map[string]string
+		InputModelMethod string
+		InputModelArg    interface{}
+		InputModelError  error
 	}{
 		{
-			// empty log body
-			InputBodyObject: nil,
-
-			InputModelDeploymentID: "f826484e-1157-4109-af21-304e6d711560",
-			InputModelDeviceID:     "device-id-1",
-			InputModelMessages:     nil,
-
+			InputID:         "bad-id",
+			InputBodyObject: map[string]string{"status": "paused"},
 			JSONResponseParams: h.JSONResponseParams{
 				OutputStatus:     http.StatusBadRequest,
-				OutputBodyObject: h.ErrorToErrStruct(errors.New("JSON payload is empty")),
-			},
-			Headers: map[string]string{
-				"Authorization": makeDeviceAuthHeader(`{"sub": "device-id-1"}`),
-			},
-		},
-		{
-			// all correct
-			InputBodyObject: &deployments.DeploymentLog{
-				Messages: messages,
-			},
-			InputModelDeploymentID: "f826484e-1157-4109-af21-304e6d711560",
-			InputModelDeviceID:     "device-id-2",
-			InputModelMessages:     messages,
-
-			JSONResponseParams: h.JSONResponseParams{
-				OutputStatus:     http.StatusNoContent,
-				OutputBodyObject: nil,
-			},
-			Headers: map[string]string{
-				"Authorization": makeDeviceAuthHeader(`{"sub": "device-id-2"}`),
+				OutputBodyObject: h.ErrorToErrStruct(ErrIDNotUUIDv4),
 			},
 		},
 		{
-			// no authorization
-			InputBodyObject: &deployments.DeploymentLog{
-				Messages: messages,
-			},
-			InputModelDeploymentID: "f826484e-1157-4109-af21-304e6d711560",
-			InputModelDeviceID:     "device-id-3",
-			InputModelMessages:     messages,
-
+			InputID:         validUUIDv4,
+			InputBodyObject: map[string]string{"status": "finished"},
 			JSONResponseParams: h.JSONResponseParams{
 				OutputStatus:     http.StatusBadRequest,
-				OutputBodyObject: h.ErrorToErrStruct(errors.New("Missing identity data")),
+				OutputBodyObject: h.ErrorToErrStruct(ErrUnexpectedCampaignStatus),
 			},
 		},
 		{
-			// model error
-			InputBodyObject: &deployments.DeploymentLog{
-				Messages: messages,
-			},
-			InputModelDeploymentID: "f826484e-1157-4109-af21-304e6d711560",
-			InputModelDeviceID:     "device-id-4",
-			InputModelError:        errors.New("model error"),
-			InputModelMessages:     messages,
-
+			InputID:          validUUIDv4,
+			InputBodyObject:  map[string]string{"status": "paused"},
+			InputModelMethod: "PauseCampaign",
+			InputModelArg:    true,
 			JSONResponseParams: h.JSONResponseParams{
-				OutputStatus:     http.StatusInternalServerError,
-				OutputBodyObject: h.ErrorToErrStruct(errors.New("internal error")),
+				OutputStatus: http.StatusNoContent,
 			},
-			Headers: map[string]string{
-				"Authorization": makeDeviceAuthHeader(`{"sub": "device-id-4"}`),
+		},
+		{
+			InputID:          validUUIDv4,
+			InputBodyObject:  map[string]string{"status": "active"},
+			InputModelMethod: "PauseCampaign",
+			InputModelArg:    false,
+			InputModelError:  ErrModelCampaignNotFound,
+			JSONResponseParams: h.JSONResponseParams{
+				OutputStatus:     http.StatusNotFound,
+				OutputBodyObject: h.ErrorToErrStruct(errors.New("Resource not found")),
 			},
 		},
 		{
-			// deployment not assigned to device
-			InputBodyObject: &deployments.DeploymentLog{
-				Messages: messages,
+			InputID:          validUUIDv4,
+			InputBodyObject:  map[string]string{"status": "aborted", "reason": "broken release"},
+			InputModelMethod: "AbortCampaign",
+			InputModelArg:    &deployments.AbortInfo{Reason: "broken release"},
+			JSONResponseParams: h.JSONResponseParams{
+				OutputStatus: http.StatusNoContent,
 			},
-			InputModelDeploymentID: "f826484e-1157-4109-af21-304e6d711560",
-			InputModelDeviceID:     "device-id-5",
-			InputModelError:        ErrModelDeploymentNotFound,
-			InputModelMessages:     messages,
-
+		},
+		{
+			InputID:          validUUIDv4,
+			InputBodyObject:  map[string]string{"status": "aborted"},
+			InputModelMethod: "AbortCampaign",
+			InputModelArg:    &deployments.AbortInfo{},
+			InputModelError:  ErrCampaignAborted,
 			JSONResponseParams: h.JSONResponseParams{
-				OutputStatus:     http.StatusNotFound,
-				OutputBodyObject: h.ErrorToErrStruct(errors.New("Deployment not found")),
+				OutputStatus:     http.StatusUnprocessableEntity,
+				OutputBodyObject: h.ErrorToErrStruct(ErrCampaignAborted),
 			},
-			Headers: map[string]string{
-				"Authorization": makeDeviceAuthHeader(`{"sub": "device-id-5"}`),
+		},
+		{
+			InputID:          validUUIDv4,
+			InputBodyObject:  map[string]string{"status": "paused"},
+			InputModelMethod: "PauseCampaign",
+			InputModelArg:    true,
+			InputModelError:  errors.New("model error"),
+			JSONResponseParams: h.JSONResponseParams{
+				OutputStatus:     http.StatusInternalServerError,
+				OutputBodyObject: h.ErrorToErrStruct(errors.New("internal error")),
 			},
 		},
 	}
@@ -1232,171 +4729,145 @@ func TestControllerPutDeploymentLog(t *testing.T) {
 
 			deploymentModel := new(mocks.DeploymentsModel)
 
-			deploymentModel.On("SaveDeviceDeploymentLog",
-				h.ContextMatcher(),
-				testCase.InputModelDeviceID,
-				testCase.InputModelDeploymentID,
-				testCase.InputModelMessages).
-				Return(testCase.InputModelError)
+			if testCase.InputModelMethod != "" {
+				deploymentModel.On(testCase.InputModelMethod,
+					h.ContextMatcher(), testCase.InputID, testCase.InputModelArg).
+					Return(testCase.InputModelError)
+			}
 
 			router, err := rest.MakeRouter(
-				rest.Put("/r/:id",
+				rest.Put("/r/:id/status",
 					NewDeploymentsController(deploymentModel,
-						new(view.DeploymentsView)).PutDeploymentLogForDevice))
+						new(view.DeploymentsView)).PutCampaignStatus))
 			assert.NoError(t, err)
 
 			api := makeApi(router)
 
-			req := test.MakeSimpleRequest("PUT", "http://localhost/r/"+testCase.InputModelDeploymentID,
-				testCase.InputBodyObject)
-			for k, v := range testCase.Headers {
-				req.Header.Set(k, v)
-			}
+			req := test.MakeSimpleRequest("PUT",
+				"http://localhost/r/"+testCase.InputID+"/status", testCase.InputBodyObject)
 			req.Header.Add(requestid.RequestIdHeader, "test")
 			recorded := test.RunRequest(t, api.MakeHandler(), req)
 
 			h.CheckRecordedResponse(t, recorded, testCase.JSONResponseParams)
+			deploymentModel.AssertExpectations(t)
 		})
 	}
 }
 
-func parseTime(t *testing.T, value string) *time.Time {
-	tm, err := time.Parse(time.RFC3339, value)
-	if assert.NoError(t, err) == false {
-		t.Fatalf("failed to parse time %s", value)
-	}
-
-	return &tm
-}
-
-func TestControllerGetDeploymentLog(t *testing.T) {
+func TestControllerPutDeploymentSettings(t *testing.T) {
 
 	t.Parallel()
 
-	type log struct {
-		Messages string `json:"messages"`
-	}
-
-	tref := parseTime(t, "2006-01-02T15:04:05-07:00")
-
-	messages := []deployments.LogMessage{
-		{
-			Timestamp: tref,
-			Message:   "foo",
-			Level:     "notice",
-		},
-		{
-			Timestamp: tref,
-			Message:   "zed zed zed",
-			Level:     "debug",
-		},
-		{
-			Timestamp: tref,
-			Message:   "bar bar bar",
-			Level:     "info",
-		},
-	}
+	three := 3
 
-	testCases := []struct {
+	testCases := map[string]struct {
 		h.JSONResponseParams
 
-		InputModelDeploymentLog *deployments.DeploymentLog
-		InputModelDeploymentID  string
-		InputModelDeviceID      string
-		InputModelMessages      []deployments.LogMessage
-		InputModelError         error
-
-		Body string
+		InputBodyObject interface{}
+		InputModelError error
 	}{
-		{
-			// all correct
-			InputModelDeploymentLog: &deployments.DeploymentLog{
-				DeploymentID: "f826484e-1157-4109-af21-304e6d711560",
-				DeviceID:     "device-id-1",
-				Messages:     messages,
+		"empty body": {
+			JSONResponseParams: h.JSONResponseParams{
+				OutputStatus:     http.StatusBadRequest,
+				OutputBodyObject: h.ErrorToErrStruct(errors.New("Validating request body: JSON payload is empty")),
 			},
-			InputModelDeploymentID: "f826484e-1157-4109-af21-304e6d711560",
-			InputModelDeviceID:     "device-id-1",
-			InputModelMessages:     messages,
-
+		},
+		"invalid": {
+			InputBodyObject: &deployments.DeploymentSettings{ConflictPolicy: "ignore"},
 			JSONResponseParams: h.JSONResponseParams{
-				OutputStatus:     http.StatusOK,
-				OutputBodyObject: nil,
+				OutputStatus: http.StatusBadRequest,
+				OutputBodyObject: map[string]interface{}{
+					"error": "Validating request body: conflict_policy: " +
+						deployments.ErrInvalidConflictPolicy.Error() + ";",
+					"request_id": "test",
+					"fields": []deployments.FieldError{{
+						Field:   "conflict_policy",
+						Code:    deployments.ValidationCodeInvalid,
+						Message: deployments.ErrInvalidConflictPolicy.Error(),
+					}},
+				},
 			},
-			Body: `2006-01-02 22:04:05 +0000 UTC notice: foo
-2006-01-02 22:04:05 +0000 UTC debug: zed zed zed
-2006-01-02 22:04:05 +0000 UTC info: bar bar bar
-`,
 		},
-		{
-			// model error
-			InputModelDeploymentLog: nil,
-			InputModelDeploymentID:  "f826484e-1157-4109-af21-304e6d711560",
-			InputModelDeviceID:      "device-id-4",
-			InputModelError:         errors.New("model error"),
-			InputModelMessages:      messages,
-
+		"model error": {
+			InputBodyObject: &deployments.DeploymentSettings{MaxRetries: &three},
+			InputModelError: errors.New("model error"),
 			JSONResponseParams: h.JSONResponseParams{
 				OutputStatus:     http.StatusInternalServerError,
 				OutputBodyObject: h.ErrorToErrStruct(errors.New("internal error")),
 			},
 		},
-		{
-			// deployment not assigned to device
-			InputModelDeploymentLog: nil,
-			InputModelDeploymentID:  "f826484e-1157-4109-af21-304e6d711560",
-			InputModelDeviceID:      "device-id-5",
-			InputModelError:         nil,
-			InputModelMessages:      messages,
-
+		"ok": {
+			InputBodyObject: &deployments.DeploymentSettings{MaxRetries: &three},
 			JSONResponseParams: h.JSONResponseParams{
-				OutputStatus:     http.StatusNotFound,
-				OutputBodyObject: h.ErrorToErrStruct(errors.New("Resource not found")),
+				OutputStatus: http.StatusNoContent,
 			},
 		},
 	}
 
-	for testCaseNumber, testCase := range testCases {
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
 
-		t.Run(fmt.Sprintf("test case %d", testCaseNumber+1), func(t *testing.T) {
 			deploymentModel := new(mocks.DeploymentsModel)
-
-			deploymentModel.On("GetDeviceDeploymentLog",
-				h.ContextMatcher(),
-				testCase.InputModelDeviceID,
-				testCase.InputModelDeploymentID).
-				Return(testCase.InputModelDeploymentLog, testCase.InputModelError)
+			deploymentModel.On("SetDeploymentSettings",
+				h.ContextMatcher(), testCase.InputBodyObject).
+				Return(testCase.InputModelError)
 
 			router, err := rest.MakeRouter(
-				rest.Get("/r/:id/:devid",
+				rest.Put("/r",
 					NewDeploymentsController(deploymentModel,
-						new(view.DeploymentsView)).GetDeploymentLogForDevice))
+						new(view.DeploymentsView)).PutDeploymentSettings))
 			assert.NoError(t, err)
 
 			api := makeApi(router)
 
-			req := test.MakeSimpleRequest("GET", "http://localhost/r/"+
-				testCase.InputModelDeploymentID+"/"+testCase.InputModelDeviceID,
-				nil)
+			req := test.MakeSimpleRequest("PUT", "http://localhost/r", testCase.InputBodyObject)
 			req.Header.Add(requestid.RequestIdHeader, "test")
 			recorded := test.RunRequest(t, api.MakeHandler(), req)
-			if testCase.JSONResponseParams.OutputStatus != http.StatusOK {
-				h.CheckRecordedResponse(t, recorded, testCase.JSONResponseParams)
-			} else {
-				assert.Equal(t, testCase.Body, recorded.Recorder.Body.String())
-				assert.Equal(t, http.StatusOK, recorded.Recorder.Code)
-				assert.Equal(t, "text/plain", recorded.Recorder.HeaderMap.Get("Content-Type"))
-			}
+
+			h.CheckRecordedResponse(t, recorded, testCase.JSONResponseParams)
 		})
 	}
 }
 
-func TestControllerAbortDeployment(t *testing.T) {
+func TestControllerGetDeploymentSettings(t *testing.T) {
 
 	t.Parallel()
 
-	type report struct {
-		Status string `json:"status"`
+	settings := &deployments.DeploymentSettings{
+		ConflictPolicy: deployments.ConflictPolicySkip,
+	}
+
+	deploymentModel := new(mocks.DeploymentsModel)
+	deploymentModel.On("GetDeploymentSettings", h.ContextMatcher()).
+		Return(settings, nil)
+
+	router, err := rest.MakeRouter(
+		rest.Get("/r",
+			NewDeploymentsController(deploymentModel,
+				new(view.DeploymentsView)).GetDeploymentSettings))
+	assert.NoError(t, err)
+
+	api := makeApi(router)
+
+	req := test.MakeSimpleRequest("GET", "http://localhost/r", nil)
+	req.Header.Add(requestid.RequestIdHeader, "test")
+	recorded := test.RunRequest(t, api.MakeHandler(), req)
+
+	h.CheckRecordedResponse(t, recorded, h.JSONResponseParams{
+		OutputStatus:     http.StatusOK,
+		OutputBodyObject: settings,
+	})
+}
+
+func TestControllerPostAlert(t *testing.T) {
+
+	t.Parallel()
+
+	constructor := &deployments.AlertConstructor{
+		Name:      "failures",
+		Condition: deployments.AlertConditionFailureRate,
+		Threshold: 10,
+		Duration:  15,
 	}
 
 	testCases := []struct {
@@ -1404,71 +4875,51 @@ func TestControllerAbortDeployment(t *testing.T) {
 
 		InputBodyObject interface{}
 
-		InputModelDeploymentID              string
-		InputModelStatus                    string
-		InputModelDeploymentFinishedFlag    bool
-		InputModelIsDeploymentFinishedError error
-		InputModelError                     error
+		InputModelID    string
+		InputModelError error
 	}{
 		{
-			// empty body
 			InputBodyObject: nil,
-
-			InputModelDeploymentID:           "f826484e-1157-4109-af21-304e6d711560",
-			InputModelStatus:                 "none",
-			InputModelDeploymentFinishedFlag: false,
-
 			JSONResponseParams: h.JSONResponseParams{
 				OutputStatus:     http.StatusBadRequest,
-				OutputBodyObject: h.ErrorToErrStruct(errors.New("JSON payload is empty")),
+				OutputBodyObject: h.ErrorToErrStruct(errors.New("Validating request body: JSON payload is empty")),
 			},
 		},
 		{
-			// wrong status
-			InputBodyObject:                  &report{Status: "finished"},
-			InputModelDeploymentID:           "f826484e-1157-4109-af21-304e6d711560",
-			InputModelStatus:                 "finished",
-			InputModelDeploymentFinishedFlag: false,
-
-			JSONResponseParams: h.JSONResponseParams{
-				OutputStatus:     http.StatusBadRequest,
-				OutputBodyObject: h.ErrorToErrStruct(errors.New("Unexpected deployment status")),
+			InputBodyObject: &deployments.AlertConstructor{
+				Name:      "failures",
+				Condition: deployments.AlertConditionFailureRate,
+				Threshold: 100,
 			},
-		},
-		{
-			// deployment finished already
-			InputBodyObject:                  &report{Status: "aborted"},
-			InputModelDeploymentID:           "f826484e-1157-4109-af21-304e6d711560",
-			InputModelStatus:                 "aborted",
-			InputModelDeploymentFinishedFlag: true,
-
 			JSONResponseParams: h.JSONResponseParams{
-				OutputStatus:     http.StatusUnprocessableEntity,
-				OutputBodyObject: h.ErrorToErrStruct(errors.New("Deployment already finished")),
+				OutputStatus: http.StatusBadRequest,
+				OutputBodyObject: map[string]interface{}{
+					"error":      "Validating request body: threshold: threshold must be at least 0 and less than 100;",
+					"request_id": "test",
+					"fields": []deployments.FieldError{
+						{
+							Field:   "threshold",
+							Code:    deployments.ValidationCodeInvalid,
+							Message: "threshold must be at least 0 and less than 100",
+						},
+					},
+				},
 			},
 		},
 		{
-			// checking if deploymen was finished error
-			InputBodyObject:                     &report{Status: "aborted"},
-			InputModelDeploymentID:              "f826484e-1157-4109-af21-304e6d711560",
-			InputModelStatus:                    "aborted",
-			InputModelDeploymentFinishedFlag:    true,
-			InputModelIsDeploymentFinishedError: errors.New("IsDeploymentFinished error"),
-
+			InputBodyObject: constructor,
+			InputModelError: errors.New("model error"),
 			JSONResponseParams: h.JSONResponseParams{
 				OutputStatus:     http.StatusInternalServerError,
 				OutputBodyObject: h.ErrorToErrStruct(errors.New("internal error")),
 			},
 		},
 		{
-			// all correct
-			InputBodyObject:                  &report{Status: "aborted"},
-			InputModelDeploymentID:           "f826484e-1157-4109-af21-304e6d711560",
-			InputModelStatus:                 "aborted",
-			InputModelDeploymentFinishedFlag: false,
-
+			InputBodyObject: constructor,
+			InputModelID:    "1234",
 			JSONResponseParams: h.JSONResponseParams{
-				OutputStatus: http.StatusNoContent,
+				OutputStatus:  http.StatusCreated,
+				OutputHeaders: map[string]string{"Location": "./r/1234"},
 			},
 		},
 	}
@@ -1479,25 +4930,19 @@ func TestControllerAbortDeployment(t *testing.T) {
 
 			deploymentModel := new(mocks.DeploymentsModel)
 
-			deploymentModel.On("AbortDeployment",
-				h.ContextMatcher(), testCase.InputModelDeploymentID).
-				Return(testCase.InputModelError)
-
-			deploymentModel.On("IsDeploymentFinished",
-				h.ContextMatcher(), testCase.InputModelDeploymentID).
-				Return(testCase.InputModelDeploymentFinishedFlag,
-					testCase.InputModelIsDeploymentFinishedError)
+			deploymentModel.On("CreateAlert",
+				h.ContextMatcher(), testCase.InputBodyObject).
+				Return(testCase.InputModelID, testCase.InputModelError)
 
 			router, err := rest.MakeRouter(
-				rest.Post("/r/:id",
+				rest.Post("/r",
 					NewDeploymentsController(deploymentModel,
-						new(view.DeploymentsView)).AbortDeployment))
+						new(view.DeploymentsView)).PostAlert))
 			assert.NoError(t, err)
 
 			api := makeApi(router)
 
-			req := test.MakeSimpleRequest("POST", "http://localhost/r/"+testCase.InputModelDeploymentID,
-				testCase.InputBodyObject)
+			req := test.MakeSimpleRequest("POST", "http://localhost/r", testCase.InputBodyObject)
 			req.Header.Add(requestid.RequestIdHeader, "test")
 			recorded := test.RunRequest(t, api.MakeHandler(), req)
 
@@ -1506,30 +4951,41 @@ func TestControllerAbortDeployment(t *testing.T) {
 	}
 }
 
-func TestControllerDecommissionDevice(t *testing.T) {
+func TestControllerDeleteAlert(t *testing.T) {
 
 	t.Parallel()
 
 	testCases := []struct {
 		h.JSONResponseParams
 
-		InputModelDeviceId string
-		InputModelError    error
+		InputID         string
+		InputModelError error
 	}{
 		{
-			// input model error
-			InputModelDeviceId: "58e755077478ee0001b065ed",
-			InputModelError:    errors.New("Input model error"),
-
+			InputID: "bad-id",
+			JSONResponseParams: h.JSONResponseParams{
+				OutputStatus:     http.StatusBadRequest,
+				OutputBodyObject: h.ErrorToErrStruct(ErrIDNotUUIDv4),
+			},
+		},
+		{
+			InputID:         validUUIDv4,
+			InputModelError: errors.New("model error"),
 			JSONResponseParams: h.JSONResponseParams{
 				OutputStatus:     http.StatusInternalServerError,
 				OutputBodyObject: h.ErrorToErrStruct(errors.New("internal error")),
 			},
 		},
 		{
-			// all correct
-			InputModelDeviceId: "58e755077478ee0001b065ed",
-
+			InputID:         validUUIDv4,
+			InputModelError: ErrModelAlertNotFound,
+			JSONResponseParams: h.JSONResponseParams{
+				OutputStatus:     http.StatusNotFound,
+				OutputBodyObject: h.ErrorToErrStruct(errors.New("Resource not found")),
+			},
+		},
+		{
+			InputID: validUUIDv4,
 			JSONResponseParams: h.JSONResponseParams{
 				OutputStatus: http.StatusNoContent,
 			},
@@ -1542,19 +4998,19 @@ func TestControllerDecommissionDevice(t *testing.T) {
 
 			deploymentModel := new(mocks.DeploymentsModel)
 
-			deploymentModel.On("DecommissionDevice",
-				h.ContextMatcher(), testCase.InputModelDeviceId).
+			deploymentModel.On("DeleteAlert",
+				h.ContextMatcher(), testCase.InputID).
 				Return(testCase.InputModelError)
 
 			router, err := rest.MakeRouter(
 				rest.Delete("/r/:id",
 					NewDeploymentsController(deploymentModel,
-						new(view.DeploymentsView)).DecommissionDevice))
+						new(view.DeploymentsView)).DeleteAlert))
 			assert.NoError(t, err)
 
 			api := makeApi(router)
 
-			req := test.MakeSimpleRequest("DELETE", "http://localhost/r/"+testCase.InputModelDeviceId, nil)
+			req := test.MakeSimpleRequest("DELETE", "http://localhost/r/"+testCase.InputID, nil)
 			req.Header.Add(requestid.RequestIdHeader, "test")
 			recorded := test.RunRequest(t, api.MakeHandler(), req)
 
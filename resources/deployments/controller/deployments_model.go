@@ -19,6 +19,7 @@ import (
 	"errors"
 
 	"github.com/mendersoftware/deployments/resources/deployments"
+	"github.com/mendersoftware/deployments/resources/images"
 )
 
 // Errors
@@ -28,9 +29,25 @@ var (
 	ErrModelDeploymentNotFound = errors.New("Deployment not found")
 	ErrModelInternal           = errors.New("Internal error")
 	ErrStorageInvalidLog       = errors.New("Invalid deployment log")
-	ErrStorageNotFound         = errors.New("Not found")
+	ErrStorageNotFound         = deployments.ErrStorageNotFound
 	ErrDeploymentAborted       = errors.New("Deployment aborted")
 	ErrDeviceDecommissioned    = errors.New("Device decommissioned")
+	ErrPolicyRejected          = errors.New("Rejected by deployment policy")
+	ErrConflictingDeployment   = errors.New("Conflicting active deployment")
+	ErrDeploymentFrozen        = errors.New("Deployments are frozen")
+	ErrDeploymentSlugTaken     = errors.New("Deployment slug already in use")
+	ErrRetryNotAllowed         = errors.New("Only failed or aborted device deployments can be retried")
+	ErrRetryLimitReached       = errors.New("Device deployment retry limit reached")
+	ErrLogSearchDisabled       = errors.New("Device log search is not enabled")
+	ErrModelCampaignNotFound   = errors.New("Campaign not found")
+	ErrCampaignAborted         = errors.New("Campaign aborted")
+	ErrModelAlertNotFound      = errors.New("Alert not found")
+	ErrExternalIDDisabled      = errors.New("Device external IDs are not configured")
+	ErrExternalIDAmbiguous     = errors.New("External ID matches more than one device")
+	ErrInvalidFinishStatus     = errors.New("Invalid status, expected aborted or timed_out")
+	ErrDownloadProxyDisabled   = errors.New("Download proxy is not configured, issued links can not be revoked")
+	ErrDownloadLinkInvalid     = errors.New("Invalid or expired download link")
+	ErrDownloadLinkRevoked     = errors.New("Download link revoked")
 )
 
 // Domain model for deployment
@@ -38,9 +55,26 @@ type DeploymentsModel interface {
 	CreateDeployment(ctx context.Context,
 		constructor *deployments.DeploymentConstructor) (string, error)
 	GetDeployment(ctx context.Context, deploymentID string) (*deployments.Deployment, error)
+	GetDeploymentIDBySlug(ctx context.Context, slug string) (string, error)
 	IsDeploymentFinished(ctx context.Context, deploymentID string) (bool, error)
-	AbortDeployment(ctx context.Context, deploymentID string) error
+	AbortDeployment(ctx context.Context, deploymentID string,
+		abort *deployments.AbortInfo) error
+	ForceFinishDeployment(ctx context.Context, deploymentID string,
+		status string, abort *deployments.AbortInfo) error
 	GetDeploymentStats(ctx context.Context, deploymentID string) (deployments.Stats, error)
+	GetDeploymentFailureStats(ctx context.Context,
+		deploymentID string) (deployments.FailureStats, error)
+	GetDeploymentDurationStats(ctx context.Context,
+		deploymentID string) (deployments.DurationStats, error)
+	GetDeploymentTelemetryStats(ctx context.Context,
+		deploymentID string) (deployments.TelemetryStats, error)
+	GetDeploymentPhaseStats(ctx context.Context,
+		deploymentID string) ([]deployments.PhaseStats, error)
+	EstimateDeployment(ctx context.Context,
+		deploymentID string) (*deployments.DeploymentEstimate, error)
+	GetStatsSummary(ctx context.Context) (*deployments.StatsSummary, error)
+	GetDeploymentStatsByGroup(ctx context.Context, deploymentID string,
+		attribute string) (deployments.GroupStats, error)
 	GetDeploymentForDeviceWithCurrent(ctx context.Context, deviceID string,
 		current deployments.InstalledDeviceDeployment) (*deployments.DeploymentInstructions, error)
 	HasDeploymentForDevice(ctx context.Context, deploymentID string,
@@ -49,11 +83,50 @@ type DeploymentsModel interface {
 		deviceID string, status deployments.DeviceDeploymentStatus) error
 	GetDeviceStatusesForDeployment(ctx context.Context,
 		deploymentID string) ([]deployments.DeviceDeployment, error)
+	ResolveDeviceExternalID(ctx context.Context, externalID string) (string, error)
+	GetDeviceDeploymentsCount(ctx context.Context,
+		deploymentID, status string) (int, error)
+	LookupDeviceDeployments(ctx context.Context,
+		query deployments.DeviceDeploymentsQuery) ([]deployments.DeviceDeployment, error)
+	LookupDownloads(ctx context.Context,
+		query deployments.DownloadsQuery) ([]deployments.Download, error)
+	RevokeDeviceDeploymentLink(ctx context.Context,
+		deploymentID, deviceID string) error
+	GetDeviceDeploymentDownload(ctx context.Context, deploymentID, deviceID string,
+		expire int64, signature string) (*images.Link, error)
+	RetryDeviceDeployment(ctx context.Context,
+		deploymentID, deviceID string) error
+	GetLatestDeviceDeployment(ctx context.Context,
+		deviceID string, deploymentIDs []string) (*deployments.DeviceDeployment, error)
+	GetActiveDeviceDeployments(ctx context.Context,
+		deviceIDs []string) (map[string][]deployments.DeviceDeployment, error)
 	LookupDeployment(ctx context.Context,
 		query deployments.Query) ([]*deployments.Deployment, error)
 	SaveDeviceDeploymentLog(ctx context.Context, deviceID string,
 		deploymentID string, logs []deployments.LogMessage) error
 	GetDeviceDeploymentLog(ctx context.Context,
 		deviceID, deploymentID string) (*deployments.DeploymentLog, error)
+	SearchDeviceDeploymentLogs(ctx context.Context,
+		deploymentID, text string) ([]string, error)
 	DecommissionDevice(ctx context.Context, deviceID string) error
+	CreateFreezePeriod(ctx context.Context,
+		constructor *deployments.FreezePeriodConstructor) (string, error)
+	GetFreezePeriods(ctx context.Context) ([]*deployments.FreezePeriod, error)
+	DeleteFreezePeriod(ctx context.Context, id string) error
+	SetDeviceTypeAlias(ctx context.Context, alias *deployments.DeviceTypeAlias) error
+	GetDeviceTypeAliases(ctx context.Context) ([]*deployments.DeviceTypeAlias, error)
+	DeleteDeviceTypeAlias(ctx context.Context, alias string) error
+	GetDeploymentSettings(ctx context.Context) (*deployments.DeploymentSettings, error)
+	SetDeploymentSettings(ctx context.Context, settings *deployments.DeploymentSettings) error
+	CreateCampaign(ctx context.Context,
+		constructor *deployments.CampaignConstructor) (string, error)
+	GetCampaigns(ctx context.Context) ([]*deployments.Campaign, error)
+	GetCampaign(ctx context.Context, id string) (*deployments.Campaign, error)
+	GetCampaignStats(ctx context.Context, id string) (deployments.Stats, error)
+	PauseCampaign(ctx context.Context, id string, paused bool) error
+	AbortCampaign(ctx context.Context, id string, abort *deployments.AbortInfo) error
+	CreateAlert(ctx context.Context,
+		constructor *deployments.AlertConstructor) (string, error)
+	GetAlerts(ctx context.Context) ([]*deployments.Alert, error)
+	DeleteAlert(ctx context.Context, id string) error
 }
@@ -0,0 +1,73 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package controller
+
+import (
+	"context"
+	"time"
+
+	"github.com/ant0ine/go-json-rest/rest"
+	"github.com/mendersoftware/go-lib-micro/log"
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/deployments/resources/deployments"
+	"github.com/mendersoftware/deployments/resources/limits"
+)
+
+// LimitsModel gives limits of the tenant.
+type LimitsModel interface {
+	GetLimit(ctx context.Context, name string) (*limits.Limit, error)
+}
+
+// SetLimits enables enforcement of the deployments per day limit of
+// tenants; devices per deployment are limited by the model.
+func (d *DeploymentsController) SetLimits(limits LimitsModel) {
+	d.limits = limits
+}
+
+// checkDeploymentsLimit returns *limits.ExceededError if one more
+// deployment would exceed the deployments per day limit of the tenant.
+func (d *DeploymentsController) checkDeploymentsLimit(ctx context.Context) error {
+	limit, err := d.limits.GetLimit(ctx, limits.LimitDeploymentsPerDay)
+	if err != nil {
+		return err
+	}
+	if limit.Value == 0 {
+		return nil
+	}
+
+	since := time.Now().Add(-24 * time.Hour)
+	list, err := d.model.LookupDeployment(ctx, deployments.Query{
+		CreatedAfter: &since,
+		Limit:        int(limit.Value),
+	})
+	if err != nil {
+		return errors.Wrap(err, "Counting deployments")
+	}
+
+	return limit.Check(uint64(len(list)) + 1)
+}
+
+// renderLimitError renders exceeded limit with the given status and any
+// other error as internal.
+func (d *DeploymentsController) renderLimitError(w rest.ResponseWriter, r *rest.Request,
+	err error, status int, l *log.Logger) {
+
+	if _, ok := err.(*limits.ExceededError); ok {
+		d.view.RenderError(w, r, err, status, l)
+	} else {
+		d.view.RenderInternalError(w, r, err, l)
+	}
+}
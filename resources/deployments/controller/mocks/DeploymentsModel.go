@@ -17,6 +17,7 @@ package mocks
 import context "context"
 import controller "github.com/mendersoftware/deployments/resources/deployments/controller"
 import deployments "github.com/mendersoftware/deployments/resources/deployments"
+import images "github.com/mendersoftware/deployments/resources/images"
 import mock "github.com/stretchr/testify/mock"
 
 // DeploymentsModel is an autogenerated mock type for the DeploymentsModel type
@@ -24,13 +25,27 @@ type DeploymentsModel struct {
 	mock.Mock
 }
 
-// AbortDeployment provides a mock function with given fields: ctx, deploymentID
-func (_m *DeploymentsModel) AbortDeployment(ctx context.Context, deploymentID string) error {
-	ret := _m.Called(ctx, deploymentID)
+// AbortCampaign provides a mock function with given fields: ctx, id, abort
+func (_m *DeploymentsModel) AbortCampaign(ctx context.Context, id string, abort *deployments.AbortInfo) error {
+	ret := _m.Called(ctx, id, abort)
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
-		r0 = rf(ctx, deploymentID)
+	if rf, ok := ret.Get(0).(func(context.Context, string, *deployments.AbortInfo) error); ok {
+		r0 = rf(ctx, id, abort)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// AbortDeployment provides a mock function with given fields: ctx, deploymentID, abort
+func (_m *DeploymentsModel) AbortDeployment(ctx context.Context, deploymentID string, abort *deployments.AbortInfo) error {
+	ret := _m.Called(ctx, deploymentID, abort)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, *deployments.AbortInfo) error); ok {
+		r0 = rf(ctx, deploymentID, abort)
 	} else {
 		r0 = ret.Error(0)
 	}
@@ -38,6 +53,48 @@ func (_m *DeploymentsModel) AbortDeployment(ctx context.Context, deploymentID st
 	return r0
 }
 
+// CreateAlert provides a mock function with given fields: ctx, constructor
+func (_m *DeploymentsModel) CreateAlert(ctx context.Context, constructor *deployments.AlertConstructor) (string, error) {
+	ret := _m.Called(ctx, constructor)
+
+	var r0 string
+	if rf, ok := ret.Get(0).(func(context.Context, *deployments.AlertConstructor) string); ok {
+		r0 = rf(ctx, constructor)
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, *deployments.AlertConstructor) error); ok {
+		r1 = rf(ctx, constructor)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// CreateCampaign provides a mock function with given fields: ctx, constructor
+func (_m *DeploymentsModel) CreateCampaign(ctx context.Context, constructor *deployments.CampaignConstructor) (string, error) {
+	ret := _m.Called(ctx, constructor)
+
+	var r0 string
+	if rf, ok := ret.Get(0).(func(context.Context, *deployments.CampaignConstructor) string); ok {
+		r0 = rf(ctx, constructor)
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, *deployments.CampaignConstructor) error); ok {
+		r1 = rf(ctx, constructor)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // CreateDeployment provides a mock function with given fields: ctx, constructor
 func (_m *DeploymentsModel) CreateDeployment(ctx context.Context, constructor *deployments.DeploymentConstructor) (string, error) {
 	ret := _m.Called(ctx, constructor)
@@ -59,6 +116,27 @@ func (_m *DeploymentsModel) CreateDeployment(ctx context.Context, constructor *d
 	return r0, r1
 }
 
+// CreateFreezePeriod provides a mock function with given fields: ctx, constructor
+func (_m *DeploymentsModel) CreateFreezePeriod(ctx context.Context, constructor *deployments.FreezePeriodConstructor) (string, error) {
+	ret := _m.Called(ctx, constructor)
+
+	var r0 string
+	if rf, ok := ret.Get(0).(func(context.Context, *deployments.FreezePeriodConstructor) string); ok {
+		r0 = rf(ctx, constructor)
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, *deployments.FreezePeriodConstructor) error); ok {
+		r1 = rf(ctx, constructor)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // DecommissionDevice provides a mock function with given fields: ctx, deviceID
 func (_m *DeploymentsModel) DecommissionDevice(ctx context.Context, deviceID string) error {
 	ret := _m.Called(ctx, deviceID)
@@ -73,6 +151,200 @@ func (_m *DeploymentsModel) DecommissionDevice(ctx context.Context, deviceID str
 	return r0
 }
 
+// DeleteAlert provides a mock function with given fields: ctx, id
+func (_m *DeploymentsModel) DeleteAlert(ctx context.Context, id string) error {
+	ret := _m.Called(ctx, id)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// DeleteDeviceTypeAlias provides a mock function with given fields: ctx, alias
+func (_m *DeploymentsModel) DeleteDeviceTypeAlias(ctx context.Context, alias string) error {
+	ret := _m.Called(ctx, alias)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, alias)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// DeleteFreezePeriod provides a mock function with given fields: ctx, id
+func (_m *DeploymentsModel) DeleteFreezePeriod(ctx context.Context, id string) error {
+	ret := _m.Called(ctx, id)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// EstimateDeployment provides a mock function with given fields: ctx, deploymentID
+func (_m *DeploymentsModel) EstimateDeployment(ctx context.Context, deploymentID string) (*deployments.DeploymentEstimate, error) {
+	ret := _m.Called(ctx, deploymentID)
+
+	var r0 *deployments.DeploymentEstimate
+	if rf, ok := ret.Get(0).(func(context.Context, string) *deployments.DeploymentEstimate); ok {
+		r0 = rf(ctx, deploymentID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*deployments.DeploymentEstimate)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, deploymentID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ForceFinishDeployment provides a mock function with given fields: ctx, deploymentID, status, abort
+func (_m *DeploymentsModel) ForceFinishDeployment(ctx context.Context, deploymentID string, status string, abort *deployments.AbortInfo) error {
+	ret := _m.Called(ctx, deploymentID, status, abort)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, *deployments.AbortInfo) error); ok {
+		r0 = rf(ctx, deploymentID, status, abort)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// GetActiveDeviceDeployments provides a mock function with given fields: ctx, deviceIDs
+func (_m *DeploymentsModel) GetActiveDeviceDeployments(ctx context.Context, deviceIDs []string) (map[string][]deployments.DeviceDeployment, error) {
+	ret := _m.Called(ctx, deviceIDs)
+
+	var r0 map[string][]deployments.DeviceDeployment
+	if rf, ok := ret.Get(0).(func(context.Context, []string) map[string][]deployments.DeviceDeployment); ok {
+		r0 = rf(ctx, deviceIDs)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(map[string][]deployments.DeviceDeployment)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, []string) error); ok {
+		r1 = rf(ctx, deviceIDs)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetAlerts provides a mock function with given fields: ctx
+func (_m *DeploymentsModel) GetAlerts(ctx context.Context) ([]*deployments.Alert, error) {
+	ret := _m.Called(ctx)
+
+	var r0 []*deployments.Alert
+	if rf, ok := ret.Get(0).(func(context.Context) []*deployments.Alert); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*deployments.Alert)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetCampaign provides a mock function with given fields: ctx, id
+func (_m *DeploymentsModel) GetCampaign(ctx context.Context, id string) (*deployments.Campaign, error) {
+	ret := _m.Called(ctx, id)
+
+	var r0 *deployments.Campaign
+	if rf, ok := ret.Get(0).(func(context.Context, string) *deployments.Campaign); ok {
+		r0 = rf(ctx, id)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*deployments.Campaign)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetCampaignStats provides a mock function with given fields: ctx, id
+func (_m *DeploymentsModel) GetCampaignStats(ctx context.Context, id string) (deployments.Stats, error) {
+	ret := _m.Called(ctx, id)
+
+	var r0 deployments.Stats
+	if rf, ok := ret.Get(0).(func(context.Context, string) deployments.Stats); ok {
+		r0 = rf(ctx, id)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(deployments.Stats)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetCampaigns provides a mock function with given fields: ctx
+func (_m *DeploymentsModel) GetCampaigns(ctx context.Context) ([]*deployments.Campaign, error) {
+	ret := _m.Called(ctx)
+
+	var r0 []*deployments.Campaign
+	if rf, ok := ret.Get(0).(func(context.Context) []*deployments.Campaign); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*deployments.Campaign)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // GetDeployment provides a mock function with given fields: ctx, deploymentID
 func (_m *DeploymentsModel) GetDeployment(ctx context.Context, deploymentID string) (*deployments.Deployment, error) {
 	ret := _m.Called(ctx, deploymentID)
@@ -96,6 +368,52 @@ func (_m *DeploymentsModel) GetDeployment(ctx context.Context, deploymentID stri
 	return r0, r1
 }
 
+// GetDeploymentDurationStats provides a mock function with given fields: ctx, deploymentID
+func (_m *DeploymentsModel) GetDeploymentDurationStats(ctx context.Context, deploymentID string) (deployments.DurationStats, error) {
+	ret := _m.Called(ctx, deploymentID)
+
+	var r0 deployments.DurationStats
+	if rf, ok := ret.Get(0).(func(context.Context, string) deployments.DurationStats); ok {
+		r0 = rf(ctx, deploymentID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(deployments.DurationStats)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, deploymentID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetDeploymentFailureStats provides a mock function with given fields: ctx, deploymentID
+func (_m *DeploymentsModel) GetDeploymentFailureStats(ctx context.Context, deploymentID string) (deployments.FailureStats, error) {
+	ret := _m.Called(ctx, deploymentID)
+
+	var r0 deployments.FailureStats
+	if rf, ok := ret.Get(0).(func(context.Context, string) deployments.FailureStats); ok {
+		r0 = rf(ctx, deploymentID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(deployments.FailureStats)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, deploymentID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // GetDeploymentForDeviceWithCurrent provides a mock function with given fields: ctx, deviceID, current
 func (_m *DeploymentsModel) GetDeploymentForDeviceWithCurrent(ctx context.Context, deviceID string, current deployments.InstalledDeviceDeployment) (*deployments.DeploymentInstructions, error) {
 	ret := _m.Called(ctx, deviceID, current)
@@ -119,6 +437,73 @@ func (_m *DeploymentsModel) GetDeploymentForDeviceWithCurrent(ctx context.Contex
 	return r0, r1
 }
 
+// GetDeploymentIDBySlug provides a mock function with given fields: ctx, slug
+func (_m *DeploymentsModel) GetDeploymentIDBySlug(ctx context.Context, slug string) (string, error) {
+	ret := _m.Called(ctx, slug)
+
+	var r0 string
+	if rf, ok := ret.Get(0).(func(context.Context, string) string); ok {
+		r0 = rf(ctx, slug)
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, slug)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetDeploymentPhaseStats provides a mock function with given fields: ctx, deploymentID
+func (_m *DeploymentsModel) GetDeploymentPhaseStats(ctx context.Context, deploymentID string) ([]deployments.PhaseStats, error) {
+	ret := _m.Called(ctx, deploymentID)
+
+	var r0 []deployments.PhaseStats
+	if rf, ok := ret.Get(0).(func(context.Context, string) []deployments.PhaseStats); ok {
+		r0 = rf(ctx, deploymentID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]deployments.PhaseStats)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, deploymentID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetDeploymentSettings provides a mock function with given fields: ctx
+func (_m *DeploymentsModel) GetDeploymentSettings(ctx context.Context) (*deployments.DeploymentSettings, error) {
+	ret := _m.Called(ctx)
+
+	var r0 *deployments.DeploymentSettings
+	if rf, ok := ret.Get(0).(func(context.Context) *deployments.DeploymentSettings); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*deployments.DeploymentSettings)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // GetDeploymentStats provides a mock function with given fields: ctx, deploymentID
 func (_m *DeploymentsModel) GetDeploymentStats(ctx context.Context, deploymentID string) (deployments.Stats, error) {
 	ret := _m.Called(ctx, deploymentID)
@@ -142,6 +527,75 @@ func (_m *DeploymentsModel) GetDeploymentStats(ctx context.Context, deploymentID
 	return r0, r1
 }
 
+// GetDeploymentStatsByGroup provides a mock function with given fields: ctx, deploymentID, attribute
+func (_m *DeploymentsModel) GetDeploymentStatsByGroup(ctx context.Context, deploymentID string, attribute string) (deployments.GroupStats, error) {
+	ret := _m.Called(ctx, deploymentID, attribute)
+
+	var r0 deployments.GroupStats
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) deployments.GroupStats); ok {
+		r0 = rf(ctx, deploymentID, attribute)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(deployments.GroupStats)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string, string) error); ok {
+		r1 = rf(ctx, deploymentID, attribute)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetDeploymentTelemetryStats provides a mock function with given fields: ctx, deploymentID
+func (_m *DeploymentsModel) GetDeploymentTelemetryStats(ctx context.Context, deploymentID string) (deployments.TelemetryStats, error) {
+	ret := _m.Called(ctx, deploymentID)
+
+	var r0 deployments.TelemetryStats
+	if rf, ok := ret.Get(0).(func(context.Context, string) deployments.TelemetryStats); ok {
+		r0 = rf(ctx, deploymentID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(deployments.TelemetryStats)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, deploymentID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetDeviceDeploymentDownload provides a mock function with given fields: ctx, deploymentID, deviceID, expire, signature
+func (_m *DeploymentsModel) GetDeviceDeploymentDownload(ctx context.Context, deploymentID string, deviceID string, expire int64, signature string) (*images.Link, error) {
+	ret := _m.Called(ctx, deploymentID, deviceID, expire, signature)
+
+	var r0 *images.Link
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, int64, string) *images.Link); ok {
+		r0 = rf(ctx, deploymentID, deviceID, expire, signature)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*images.Link)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string, string, int64, string) error); ok {
+		r1 = rf(ctx, deploymentID, deviceID, expire, signature)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // GetDeviceDeploymentLog provides a mock function with given fields: ctx, deviceID, deploymentID
 func (_m *DeploymentsModel) GetDeviceDeploymentLog(ctx context.Context, deviceID string, deploymentID string) (*deployments.DeploymentLog, error) {
 	ret := _m.Called(ctx, deviceID, deploymentID)
@@ -165,6 +619,27 @@ func (_m *DeploymentsModel) GetDeviceDeploymentLog(ctx context.Context, deviceID
 	return r0, r1
 }
 
+// GetDeviceDeploymentsCount provides a mock function with given fields: ctx, deploymentID, status
+func (_m *DeploymentsModel) GetDeviceDeploymentsCount(ctx context.Context, deploymentID string, status string) (int, error) {
+	ret := _m.Called(ctx, deploymentID, status)
+
+	var r0 int
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) int); ok {
+		r0 = rf(ctx, deploymentID, status)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string, string) error); ok {
+		r1 = rf(ctx, deploymentID, status)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // GetDeviceStatusesForDeployment provides a mock function with given fields: ctx, deploymentID
 func (_m *DeploymentsModel) GetDeviceStatusesForDeployment(ctx context.Context, deploymentID string) ([]deployments.DeviceDeployment, error) {
 	ret := _m.Called(ctx, deploymentID)
@@ -188,6 +663,98 @@ func (_m *DeploymentsModel) GetDeviceStatusesForDeployment(ctx context.Context,
 	return r0, r1
 }
 
+// GetDeviceTypeAliases provides a mock function with given fields: ctx
+func (_m *DeploymentsModel) GetDeviceTypeAliases(ctx context.Context) ([]*deployments.DeviceTypeAlias, error) {
+	ret := _m.Called(ctx)
+
+	var r0 []*deployments.DeviceTypeAlias
+	if rf, ok := ret.Get(0).(func(context.Context) []*deployments.DeviceTypeAlias); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*deployments.DeviceTypeAlias)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetFreezePeriods provides a mock function with given fields: ctx
+func (_m *DeploymentsModel) GetFreezePeriods(ctx context.Context) ([]*deployments.FreezePeriod, error) {
+	ret := _m.Called(ctx)
+
+	var r0 []*deployments.FreezePeriod
+	if rf, ok := ret.Get(0).(func(context.Context) []*deployments.FreezePeriod); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*deployments.FreezePeriod)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetLatestDeviceDeployment provides a mock function with given fields: ctx, deviceID, deploymentIDs
+func (_m *DeploymentsModel) GetLatestDeviceDeployment(ctx context.Context, deviceID string, deploymentIDs []string) (*deployments.DeviceDeployment, error) {
+	ret := _m.Called(ctx, deviceID, deploymentIDs)
+
+	var r0 *deployments.DeviceDeployment
+	if rf, ok := ret.Get(0).(func(context.Context, string, []string) *deployments.DeviceDeployment); ok {
+		r0 = rf(ctx, deviceID, deploymentIDs)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*deployments.DeviceDeployment)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string, []string) error); ok {
+		r1 = rf(ctx, deviceID, deploymentIDs)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetStatsSummary provides a mock function with given fields: ctx
+func (_m *DeploymentsModel) GetStatsSummary(ctx context.Context) (*deployments.StatsSummary, error) {
+	ret := _m.Called(ctx)
+
+	var r0 *deployments.StatsSummary
+	if rf, ok := ret.Get(0).(func(context.Context) *deployments.StatsSummary); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*deployments.StatsSummary)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // HasDeploymentForDevice provides a mock function with given fields: ctx, deploymentID, deviceID
 func (_m *DeploymentsModel) HasDeploymentForDevice(ctx context.Context, deploymentID string, deviceID string) (bool, error) {
 	ret := _m.Called(ctx, deploymentID, deviceID)
@@ -253,6 +820,115 @@ func (_m *DeploymentsModel) LookupDeployment(ctx context.Context, query deployme
 	return r0, r1
 }
 
+// LookupDeviceDeployments provides a mock function with given fields: ctx, query
+func (_m *DeploymentsModel) LookupDeviceDeployments(ctx context.Context, query deployments.DeviceDeploymentsQuery) ([]deployments.DeviceDeployment, error) {
+	ret := _m.Called(ctx, query)
+
+	var r0 []deployments.DeviceDeployment
+	if rf, ok := ret.Get(0).(func(context.Context, deployments.DeviceDeploymentsQuery) []deployments.DeviceDeployment); ok {
+		r0 = rf(ctx, query)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]deployments.DeviceDeployment)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, deployments.DeviceDeploymentsQuery) error); ok {
+		r1 = rf(ctx, query)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// LookupDownloads provides a mock function with given fields: ctx, query
+func (_m *DeploymentsModel) LookupDownloads(ctx context.Context, query deployments.DownloadsQuery) ([]deployments.Download, error) {
+	ret := _m.Called(ctx, query)
+
+	var r0 []deployments.Download
+	if rf, ok := ret.Get(0).(func(context.Context, deployments.DownloadsQuery) []deployments.Download); ok {
+		r0 = rf(ctx, query)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]deployments.Download)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, deployments.DownloadsQuery) error); ok {
+		r1 = rf(ctx, query)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// PauseCampaign provides a mock function with given fields: ctx, id, paused
+func (_m *DeploymentsModel) PauseCampaign(ctx context.Context, id string, paused bool) error {
+	ret := _m.Called(ctx, id, paused)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, bool) error); ok {
+		r0 = rf(ctx, id, paused)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// ResolveDeviceExternalID provides a mock function with given fields: ctx, externalID
+func (_m *DeploymentsModel) ResolveDeviceExternalID(ctx context.Context, externalID string) (string, error) {
+	ret := _m.Called(ctx, externalID)
+
+	var r0 string
+	if rf, ok := ret.Get(0).(func(context.Context, string) string); ok {
+		r0 = rf(ctx, externalID)
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, externalID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// RetryDeviceDeployment provides a mock function with given fields: ctx, deploymentID, deviceID
+func (_m *DeploymentsModel) RetryDeviceDeployment(ctx context.Context, deploymentID string, deviceID string) error {
+	ret := _m.Called(ctx, deploymentID, deviceID)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) error); ok {
+		r0 = rf(ctx, deploymentID, deviceID)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// RevokeDeviceDeploymentLink provides a mock function with given fields: ctx, deploymentID, deviceID
+func (_m *DeploymentsModel) RevokeDeviceDeploymentLink(ctx context.Context, deploymentID string, deviceID string) error {
+	ret := _m.Called(ctx, deploymentID, deviceID)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) error); ok {
+		r0 = rf(ctx, deploymentID, deviceID)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // SaveDeviceDeploymentLog provides a mock function with given fields: ctx, deviceID, deploymentID, logs
 func (_m *DeploymentsModel) SaveDeviceDeploymentLog(ctx context.Context, deviceID string, deploymentID string, logs []deployments.LogMessage) error {
 	ret := _m.Called(ctx, deviceID, deploymentID, logs)
@@ -267,6 +943,57 @@ func (_m *DeploymentsModel) SaveDeviceDeploymentLog(ctx context.Context, deviceI
 	return r0
 }
 
+// SearchDeviceDeploymentLogs provides a mock function with given fields: ctx, deploymentID, text
+func (_m *DeploymentsModel) SearchDeviceDeploymentLogs(ctx context.Context, deploymentID string, text string) ([]string, error) {
+	ret := _m.Called(ctx, deploymentID, text)
+
+	var r0 []string
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) []string); ok {
+		r0 = rf(ctx, deploymentID, text)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]string)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string, string) error); ok {
+		r1 = rf(ctx, deploymentID, text)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// SetDeploymentSettings provides a mock function with given fields: ctx, settings
+func (_m *DeploymentsModel) SetDeploymentSettings(ctx context.Context, settings *deployments.DeploymentSettings) error {
+	ret := _m.Called(ctx, settings)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *deployments.DeploymentSettings) error); ok {
+		r0 = rf(ctx, settings)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// SetDeviceTypeAlias provides a mock function with given fields: ctx, alias
+func (_m *DeploymentsModel) SetDeviceTypeAlias(ctx context.Context, alias *deployments.DeviceTypeAlias) error {
+	ret := _m.Called(ctx, alias)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *deployments.DeviceTypeAlias) error); ok {
+		r0 = rf(ctx, alias)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // UpdateDeviceDeploymentStatus provides a mock function with given fields: ctx, deploymentID, deviceID, status
 func (_m *DeploymentsModel) UpdateDeviceDeploymentStatus(ctx context.Context, deploymentID string, deviceID string, status deployments.DeviceDeploymentStatus) error {
 	ret := _m.Called(ctx, deploymentID, deviceID, status)
@@ -24,10 +24,17 @@ import (
 type RESTView interface {
 	RenderNoUpdateForDevice(w rest.ResponseWriter)
 	RenderSuccessPost(w rest.ResponseWriter, r *rest.Request, id string)
+	RenderSuccessPostObject(w rest.ResponseWriter, r *rest.Request, id string,
+		object interface{})
 	RenderSuccessGet(w rest.ResponseWriter, object interface{})
+	RenderCollection(w rest.ResponseWriter, r *rest.Request, collection interface{})
 	RenderEmptySuccessResponse(w rest.ResponseWriter)
 	RenderError(w rest.ResponseWriter, r *rest.Request, err error, status int, l *log.Logger)
+	RenderValidationError(w rest.ResponseWriter, r *rest.Request, err error,
+		fields []deployments.FieldError, status int, l *log.Logger)
 	RenderInternalError(w rest.ResponseWriter, r *rest.Request, err error, l *log.Logger)
 	RenderErrorNotFound(w rest.ResponseWriter, r *rest.Request, l *log.Logger)
 	RenderDeploymentLog(w rest.ResponseWriter, dlog deployments.DeploymentLog)
+	InternalStatus(name string) string
+	DisplayStatuses(object interface{}) interface{}
 }
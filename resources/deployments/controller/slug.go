@@ -0,0 +1,51 @@
+// Copyright 2026 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package controller
+
+import (
+	"github.com/ant0ine/go-json-rest/rest"
+	"github.com/asaskevich/govalidator"
+	"github.com/mendersoftware/go-lib-micro/log"
+
+	"github.com/mendersoftware/deployments/resources/deployments"
+)
+
+// ResolveSlug lets the handler accept a deployment slug in place of the
+// deployment ID; the slug is replaced with the ID before calling the handler.
+func (d *DeploymentsController) ResolveSlug(h rest.HandlerFunc) rest.HandlerFunc {
+	return func(w rest.ResponseWriter, r *rest.Request) {
+		id := r.PathParam("id")
+		if govalidator.IsUUIDv4(id) || !deployments.IsValidSlug(id) {
+			h(w, r)
+			return
+		}
+
+		ctx := r.Context()
+		l := log.FromContext(ctx)
+
+		deploymentID, err := d.model.GetDeploymentIDBySlug(ctx, id)
+		if err != nil {
+			d.view.RenderInternalError(w, r, err, l)
+			return
+		}
+		if deploymentID == "" {
+			d.view.RenderErrorNotFound(w, r, l)
+			return
+		}
+
+		r.PathParams["id"] = deploymentID
+		h(w, r)
+	}
+}
@@ -16,6 +16,7 @@ package controller
 
 import (
 	"encoding/json"
+	"time"
 
 	"github.com/asaskevich/govalidator"
 	"github.com/pkg/errors"
@@ -24,12 +25,19 @@ import (
 )
 
 var (
-	ErrBadStatus = errors.New("unknown status value")
+	ErrBadStatus       = errors.New("unknown status value")
+	ErrUnexpectedError = errors.New("error details are allowed only with failure status")
 )
 
 type statusReport struct {
 	Status   string
-	SubState *string `json:"substate" valid:"length(0|200)"`
+	SubState *string                            `json:"substate" valid:"length(0|200)"`
+	Error    *deployments.DeviceDeploymentError `json:"error" valid:"-"`
+	// time of the status change on the device, used to detect reports
+	// arriving out of order
+	Time *time.Time `json:"time" valid:"-"`
+	// optional telemetry of the device, e.g. battery level
+	Telemetry *deployments.DeviceTelemetry `json:"telemetry" valid:"-"`
 }
 
 func containsString(what string, in []string) bool {
@@ -69,9 +77,27 @@ func (s *statusReport) UnmarshalJSON(raw []byte) error {
 		return err
 	}
 
+	if temp.Error != nil {
+		if temp.Status != deployments.DeviceDeploymentStatusFailure {
+			return ErrUnexpectedError
+		}
+		if ok, err := govalidator.ValidateStruct(temp.Error); !ok {
+			return err
+		}
+	}
+
+	if temp.Telemetry != nil {
+		if err := temp.Telemetry.Validate(); err != nil {
+			return err
+		}
+	}
+
 	// all good
 	s.Status = temp.Status
 	s.SubState = temp.SubState
+	s.Error = temp.Error
+	s.Time = temp.Time
+	s.Telemetry = temp.Telemetry
 
 	return nil
 }
@@ -17,6 +17,7 @@ package controller
 import (
 	"encoding/json"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 
@@ -37,6 +38,57 @@ func TestStatusUnmarshal(t *testing.T) {
 	assert.Equal(t,
 		statusReport{Status: deployments.DeviceDeploymentStatusInstalling},
 		report)
+
+	err = json.Unmarshal([]byte(`{"status": "installing", "error": {"category": "storage-full"}}`),
+		&report)
+	assert.EqualError(t, err, ErrUnexpectedError.Error())
+
+	err = json.Unmarshal([]byte(`{"status": "failure", "error": {"code": "E12"}}`), &report)
+	assert.Error(t, err)
+
+	report = statusReport{}
+	err = json.Unmarshal([]byte(`{"status": "failure", "error": {"code": "E12", "category": "storage-full"}}`),
+		&report)
+	assert.NoError(t, err)
+	assert.Equal(t,
+		statusReport{
+			Status: deployments.DeviceDeploymentStatusFailure,
+			Error: &deployments.DeviceDeploymentError{
+				Code:     "E12",
+				Category: "storage-full",
+			},
+		},
+		report)
+
+	report = statusReport{}
+	err = json.Unmarshal([]byte(`{"status": "rebooting", "time": "2026-10-16T12:00:00Z"}`),
+		&report)
+	assert.NoError(t, err)
+	reported := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	assert.Equal(t,
+		statusReport{
+			Status: deployments.DeviceDeploymentStatusRebooting,
+			Time:   &reported,
+		},
+		report)
+
+	report = statusReport{}
+	err = json.Unmarshal([]byte(`{"status": "failure", "telemetry": {"battery_level": 4.5}}`),
+		&report)
+	assert.NoError(t, err)
+	battery := 4.5
+	assert.Equal(t,
+		statusReport{
+			Status: deployments.DeviceDeploymentStatusFailure,
+			Telemetry: &deployments.DeviceTelemetry{
+				BatteryLevel: &battery,
+			},
+		},
+		report)
+
+	err = json.Unmarshal([]byte(`{"status": "installing", "telemetry": {"battery_level": 101}}`),
+		&report)
+	assert.EqualError(t, err, deployments.ErrInvalidBatteryLevel.Error())
 }
 
 func TestContainsString(t *testing.T) {
@@ -0,0 +1,284 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"time"
+
+	"github.com/ant0ine/go-json-rest/rest"
+	"github.com/asaskevich/govalidator"
+	"github.com/mendersoftware/go-lib-micro/log"
+	"github.com/mendersoftware/go-lib-micro/rest_utils"
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/deployments/resources/deployments"
+	"github.com/mendersoftware/deployments/utils/websocket"
+)
+
+// DefaultStreamInterval is the default interval deployments are checked
+// for changes streamed to the dashboards.
+const DefaultStreamInterval = 5 * time.Second
+
+// MaxStreamSubscriptions limits number of deployments a single stream
+// sends statistics of.
+const MaxStreamSubscriptions = 100
+
+// Stream message types
+const (
+	StreamMessageDeployments = "deployments"
+	StreamMessageStats       = "stats"
+	StreamMessageError       = "error"
+)
+
+// Errors
+var (
+	ErrTooManySubscriptions = errors.New("Too many subscribed deployments")
+	ErrDeploymentNotFound   = errors.New("Deployment not found")
+)
+
+// StreamRequest is the message clients send to the stream to change
+// the set of deployments they receive statistics of.
+type StreamRequest struct {
+	Subscribe   []string `json:"subscribe,omitempty"`
+	Unsubscribe []string `json:"unsubscribe,omitempty"`
+}
+
+// StreamMessage is the message sent to clients of the stream.
+type StreamMessage struct {
+	Type         string      `json:"type"`
+	Deployments  interface{} `json:"deployments,omitempty"`
+	DeploymentID string      `json:"deployment_id,omitempty"`
+	Stats        interface{} `json:"stats,omitempty"`
+	Error        string      `json:"error,omitempty"`
+}
+
+// SetStreamInterval sets interval of checking deployments for changes
+// sent to the stream clients.
+func (d *DeploymentsController) SetStreamInterval(interval time.Duration) {
+	d.streamInterval = interval
+}
+
+// StreamDeployments upgrades the request to a WebSocket connection
+// streaming changes of the deployment list, as selected with the same
+// query parameters as LookupDeployment, and changes of statistics of
+// subscribed deployments. The list is sent whole on change, statistics
+// as counters which changed since the last message.
+func (d *DeploymentsController) StreamDeployments(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+	l := log.FromContext(ctx)
+
+	query, err := ParseLookupQuery(d.statusQuery(r))
+	if err != nil {
+		d.view.RenderError(w, r, err, http.StatusBadRequest, l)
+		return
+	}
+
+	page, perPage, err := rest_utils.ParsePagination(r)
+	if err != nil {
+		d.view.RenderError(w, r, err, http.StatusBadRequest, l)
+		return
+	}
+	query.Skip = int((page - 1) * perPage)
+	query.Limit = int(perPage)
+
+	if !websocket.IsUpgrade(r.Request) {
+		d.view.RenderError(w, r, websocket.ErrNotWebSocket, http.StatusBadRequest, l)
+		return
+	}
+
+	h, _ := w.(http.ResponseWriter)
+	conn, err := websocket.Upgrade(h, r.Request)
+	if err == websocket.ErrNotWebSocket {
+		d.view.RenderError(w, r, err, http.StatusBadRequest, l)
+		return
+	} else if err != nil {
+		d.view.RenderInternalError(w, r, err, l)
+		return
+	}
+	defer conn.Close()
+
+	// the connection is taken over, the request context is not
+	// canceled once the client leaves
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	requests := make(chan StreamRequest)
+	go func() {
+		defer cancel()
+		for {
+			message, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+
+			var req StreamRequest
+			if err := json.Unmarshal(message, &req); err != nil {
+				conn.WriteJSON(StreamMessage{
+					Type:  StreamMessageError,
+					Error: errors.Wrap(err, "parsing message").Error(),
+				})
+				continue
+			}
+
+			select {
+			case requests <- req:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	stream := &deploymentsStream{
+		controller: d,
+		conn:       conn,
+		query:      query,
+		stats:      make(map[string]deployments.Stats),
+	}
+
+	ticker := time.NewTicker(d.streamInterval)
+	defer ticker.Stop()
+
+	err = stream.update(ctx)
+	for err == nil {
+		select {
+		case <-ctx.Done():
+			return
+		case req := <-requests:
+			err = stream.subscribe(ctx, req)
+		case <-ticker.C:
+			if err = conn.Ping(); err == nil {
+				err = stream.update(ctx)
+			}
+		}
+	}
+
+	if ctx.Err() == nil {
+		l.Warnf("deployments stream: %s", err.Error())
+	}
+}
+
+// deploymentsStream keeps what was sent to the stream client,
+// to send only changes.
+type deploymentsStream struct {
+	controller *DeploymentsController
+	conn       *websocket.Conn
+	query      deployments.Query
+
+	deployments []*deployments.Deployment
+	stats       map[string]deployments.Stats
+}
+
+func (s *deploymentsStream) subscribe(ctx context.Context, req StreamRequest) error {
+	for _, id := range req.Unsubscribe {
+		delete(s.stats, id)
+	}
+
+	for _, id := range req.Subscribe {
+		if _, ok := s.stats[id]; ok {
+			continue
+		}
+
+		if !govalidator.IsUUIDv4(id) {
+			if err := s.sendError(id, ErrIDNotUUIDv4); err != nil {
+				return err
+			}
+			continue
+		}
+		if len(s.stats) >= MaxStreamSubscriptions {
+			if err := s.sendError(id, ErrTooManySubscriptions); err != nil {
+				return err
+			}
+			continue
+		}
+
+		s.stats[id] = deployments.Stats{}
+		if err := s.updateStats(ctx, id); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *deploymentsStream) update(ctx context.Context) error {
+	deps, err := s.controller.model.LookupDeployment(ctx, s.query)
+	if err != nil {
+		return errors.Wrap(err, "looking up deployments")
+	}
+
+	if s.deployments == nil || !reflect.DeepEqual(deps, s.deployments) {
+		if deps == nil {
+			deps = []*deployments.Deployment{}
+		}
+		err := s.conn.WriteJSON(StreamMessage{
+			Type:        StreamMessageDeployments,
+			Deployments: s.controller.view.DisplayStatuses(deps),
+		})
+		if err != nil {
+			return err
+		}
+		s.deployments = deps
+	}
+
+	for id := range s.stats {
+		if err := s.updateStats(ctx, id); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *deploymentsStream) updateStats(ctx context.Context, id string) error {
+	stats, err := s.controller.model.GetDeploymentStats(ctx, id)
+	if err != nil {
+		return errors.Wrap(err, "getting deployment statistics")
+	}
+
+	if stats == nil {
+		delete(s.stats, id)
+		return s.sendError(id, ErrDeploymentNotFound)
+	}
+
+	delta := deployments.Stats{}
+	for status, count := range stats {
+		if last, ok := s.stats[id][status]; !ok || last != count {
+			delta[status] = count
+		}
+	}
+	s.stats[id] = stats
+
+	if len(delta) == 0 {
+		return nil
+	}
+
+	return s.conn.WriteJSON(StreamMessage{
+		Type:         StreamMessageStats,
+		DeploymentID: id,
+		Stats:        s.controller.view.DisplayStatuses(delta),
+	})
+}
+
+func (s *deploymentsStream) sendError(id string, err error) error {
+	return s.conn.WriteJSON(StreamMessage{
+		Type:         StreamMessageError,
+		DeploymentID: id,
+		Error:        err.Error(),
+	})
+}
@@ -0,0 +1,136 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package controller_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ant0ine/go-json-rest/rest"
+	"github.com/ant0ine/go-json-rest/rest/test"
+	"github.com/mendersoftware/go-lib-micro/requestid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/mendersoftware/deployments/resources/deployments"
+	. "github.com/mendersoftware/deployments/resources/deployments/controller"
+	"github.com/mendersoftware/deployments/resources/deployments/controller/mocks"
+	"github.com/mendersoftware/deployments/resources/deployments/view"
+	. "github.com/mendersoftware/deployments/utils/pointers"
+	h "github.com/mendersoftware/deployments/utils/testing"
+	"github.com/mendersoftware/deployments/utils/websocket"
+)
+
+func TestControllerStreamDeploymentsNotWebSocket(t *testing.T) {
+
+	t.Parallel()
+
+	router, err := rest.MakeRouter(
+		rest.Get("/r",
+			NewDeploymentsController(new(mocks.DeploymentsModel),
+				new(view.DeploymentsView)).StreamDeployments))
+	assert.NoError(t, err)
+
+	api := makeApi(router)
+
+	req := test.MakeSimpleRequest("GET", "http://localhost/r", nil)
+	req.Header.Add(requestid.RequestIdHeader, "test")
+	recorded := test.RunRequest(t, api.MakeHandler(), req)
+
+	h.CheckRecordedResponse(t, recorded, h.JSONResponseParams{
+		OutputStatus:     http.StatusBadRequest,
+		OutputBodyObject: h.ErrorToErrStruct(websocket.ErrNotWebSocket),
+	})
+}
+
+func TestControllerStreamDeployments(t *testing.T) {
+
+	t.Parallel()
+
+	someDeployments := []*deployments.Deployment{
+		{
+			DeploymentConstructor: &deployments.DeploymentConstructor{
+				Name:         StringToPointer("foo"),
+				ArtifactName: StringToPointer("bar"),
+			},
+			Id: StringToPointer(validUUIDv4),
+		},
+	}
+
+	deploymentModel := new(mocks.DeploymentsModel)
+	deploymentModel.On("LookupDeployment", h.ContextMatcher(),
+		deployments.Query{Limit: 20, Status: deployments.StatusQueryAny}).
+		Return(someDeployments, nil)
+	deploymentModel.On("GetDeploymentStats", h.ContextMatcher(), validUUIDv4).
+		Return(deployments.Stats{"pending": 2, "success": 0}, nil).Once()
+	deploymentModel.On("GetDeploymentStats", h.ContextMatcher(), validUUIDv4).
+		Return(deployments.Stats{"pending": 1, "success": 1}, nil)
+	deploymentModel.On("GetDeploymentStats", h.ContextMatcher(),
+		mock.AnythingOfType("string")).
+		Return(nil, nil)
+
+	controller := NewDeploymentsController(deploymentModel, new(view.DeploymentsView))
+	controller.SetStreamInterval(50 * time.Millisecond)
+	router, err := rest.MakeRouter(rest.Get("/r", controller.StreamDeployments))
+	assert.NoError(t, err)
+
+	srv := httptest.NewServer(makeApi(router).MakeHandler())
+	defer srv.Close()
+
+	client, err := h.DialWebSocket(srv.URL+"/r", nil)
+	assert.NoError(t, err)
+	defer client.Close()
+	assert.Equal(t, http.StatusSwitchingProtocols, client.Response.StatusCode)
+
+	var msg map[string]interface{}
+	assert.NoError(t, client.ReadJSON(&msg, time.Second))
+	assert.Equal(t, StreamMessageDeployments, msg["type"])
+	assert.Len(t, msg["deployments"], 1)
+
+	assert.NoError(t, client.WriteJSON(StreamRequest{
+		Subscribe: []string{validUUIDv4, "bad-id", "c0f3fd7c-6a5b-4b6e-8d1f-3a0c0e4a9f5e"},
+	}))
+
+	expected := []map[string]interface{}{
+		{
+			"type":          StreamMessageStats,
+			"deployment_id": validUUIDv4,
+			"stats":         map[string]interface{}{"pending": 2.0, "success": 0.0},
+		},
+		{
+			"type":          StreamMessageError,
+			"deployment_id": "bad-id",
+			"error":         ErrIDNotUUIDv4.Error(),
+		},
+		{
+			"type":          StreamMessageError,
+			"deployment_id": "c0f3fd7c-6a5b-4b6e-8d1f-3a0c0e4a9f5e",
+			"error":         ErrDeploymentNotFound.Error(),
+		},
+		// only the changed counters, the list is unchanged
+		{
+			"type":          StreamMessageStats,
+			"deployment_id": validUUIDv4,
+			"stats":         map[string]interface{}{"pending": 1.0, "success": 1.0},
+		},
+	}
+	for _, e := range expected {
+		msg = nil
+		assert.NoError(t, client.ReadJSON(&msg, time.Second))
+		assert.Equal(t, e, msg)
+	}
+}
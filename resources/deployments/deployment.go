@@ -17,7 +17,10 @@ package deployments
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
+	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/asaskevich/govalidator"
 	"github.com/satori/go.uuid"
@@ -25,7 +28,39 @@ import (
 
 // Errors
 var (
-	ErrInvalidDeviceID = errors.New("Invalid device ID")
+	ErrInvalidDeviceID       = errors.New("Invalid device ID")
+	ErrInvalidExternalID     = errors.New("Invalid external device ID")
+	ErrInvalidArtifactID     = errors.New("Invalid artifact ID, expected UUIDv4")
+	ErrInvalidConflictPolicy = errors.New("Invalid conflict policy, expected reject, skip or queue")
+
+	ErrInvalidDeviceDeployments = errors.New("Invalid device deployments creation, expected eager or lazy")
+	ErrAutoLatestWithArtifactID = errors.New("Latest artifact cannot be resolved for deployment pinned to artifact ID")
+)
+
+// Input limits
+const (
+	DeploymentNameMaxLength = 4096
+	DeviceIDMaxLength       = 256
+)
+
+// Handling of devices which already have an active deployment
+const (
+	// Reject the deployment
+	ConflictPolicyReject = "reject"
+	// Leave such devices out of the deployment
+	ConflictPolicySkip = "skip"
+	// Include such devices, they will get the deployment after finishing
+	// the active one (default)
+	ConflictPolicyQueue = "queue"
+)
+
+// Creation of device deployments; by default lazy for deployments to
+// large numbers of devices, eager otherwise
+const (
+	// Create device deployments with the deployment
+	DeviceDeploymentsEager = "eager"
+	// Create device deployment on the first update check of the device
+	DeviceDeploymentsLazy = "lazy"
 )
 
 // DeploymentConstructor represent input data needed for creating new Deployment (they differ in fields)
@@ -33,31 +68,152 @@ type DeploymentConstructor struct {
 	// Deployment name, required
 	Name *string `json:"name,omitempty" valid:"length(1|4096),required"`
 
+	// Unique human-friendly alias of the deployment ID, generated from
+	// the name if not provided
+	Slug string `json:"slug,omitempty" valid:"-" bson:"slug,omitempty"`
+
 	// Artifact name to be installed required, associated with image
 	ArtifactName *string `json:"artifact_name,omitempty" valid:"length(1|4096),required"`
 
-	// List of device id's targeted for deployments, required
+	// Artifact ID to be installed, optional; pins the deployment to exactly
+	// this artifact, artifact name is then taken from the artifact
+	ArtifactID string `json:"artifact_id,omitempty" valid:"-" bson:"artifact_id,omitempty"`
+
+	// Artifact name is a prefix, replaced with the name of the release of
+	// the highest version parsed from the names when the deployment is
+	// created, optional
+	AutoLatest bool `json:"auto_latest,omitempty" valid:"-" bson:"auto_latest,omitempty"`
+
+	// Target devices with matching artifact installed, resolved to
+	// devices when the deployment is created, optional
+	InstalledArtifact *InstalledArtifactFilter `json:"installed_artifact,omitempty" valid:"-" bson:"installed_artifact,omitempty"`
+
+	// List of device id's targeted for deployments, required unless
+	// external device identifiers are given
 	Devices []string `json:"devices,omitempty" valid:"required" bson:"-"`
+
+	// External identifiers (e.g. serial numbers) of targeted devices,
+	// resolved to device id's through the inventory, optional
+	ExternalDevices []string `json:"external_devices,omitempty" valid:"-" bson:"-"`
+
+	// Restrictions of artifact download time and rate, optional
+	DownloadSchedule *DownloadSchedule `json:"download_schedule,omitempty" valid:"-" bson:"download_schedule,omitempty"`
+
+	// Mark pending device deployments of older deployments for the same
+	// devices as superseded, optional
+	Supersede bool `json:"supersede,omitempty" valid:"-" bson:"supersede,omitempty"`
+
+	// Handling of devices with active deployments, optional
+	ConflictPolicy string `json:"conflict_policy,omitempty" valid:"-" bson:"conflict_policy,omitempty"`
+
+	// Resolve artifact for each device type when the deployment is created
+	// instead of on device update requests, optional
+	SnapshotArtifacts bool `json:"snapshot_artifacts,omitempty" valid:"-" bson:"snapshot_artifacts,omitempty"`
+
+	// Creation of device deployments, eager or lazy, optional
+	DeviceDeployments string `json:"device_deployments,omitempty" valid:"-" bson:"device_deployments,omitempty"`
+
+	// Ignore tenant freeze periods, set only through the override endpoint
+	OverrideFreeze bool `json:"-" valid:"-" bson:"override_freeze,omitempty"`
+
+	// Confirms that the artifacts may run state scripts on devices,
+	// required by tenants configured to acknowledge scripts, optional
+	AcknowledgeScripts bool `json:"acknowledge_scripts,omitempty" valid:"-" bson:"acknowledge_scripts,omitempty"`
+
+	// Timed phases the devices are split into, in order, optional; devices
+	// of a phase are served the deployment from its start time
+	Phases []DeploymentPhase `json:"phases,omitempty" valid:"-" bson:"phases,omitempty"`
 }
 
 func NewDeploymentConstructor() *DeploymentConstructor {
 	return &DeploymentConstructor{}
 }
 
-// Validate checkes structure according to valid tags
-// TODO: Add custom validator to check devices array content (such us UUID formatting)
+// Validate checks all fields and reports each invalid one.
+// Returned error is *ValidationError.
 func (c *DeploymentConstructor) Validate() error {
-	if _, err := govalidator.ValidateStruct(c); err != nil {
-		return err
+	verr := &ValidationError{}
+
+	validateName(verr, "name", c.Name)
+	if c.Slug != "" && !IsValidSlug(c.Slug) {
+		verr.Add("slug", ValidationCodeInvalid, ErrInvalidSlug.Error())
+	}
+	if c.ArtifactID == "" || c.ArtifactName != nil {
+		validateName(verr, "artifact_name", c.ArtifactName)
+	}
+	if c.ArtifactID != "" && !govalidator.IsUUIDv4(c.ArtifactID) {
+		verr.Add("artifact_id", ValidationCodeInvalid, ErrInvalidArtifactID.Error())
+	}
+	if c.AutoLatest && c.ArtifactID != "" {
+		verr.Add("auto_latest", ValidationCodeInvalid, ErrAutoLatestWithArtifactID.Error())
+	}
+
+	if len(c.Devices) == 0 && len(c.ExternalDevices) == 0 && c.InstalledArtifact == nil {
+		verr.Add("devices", ValidationCodeRequired, "at least one device is required")
+	}
+	for i, id := range c.Devices {
+		if !isValidDeviceID(id) {
+			verr.Add(fmt.Sprintf("devices[%d]", i), ValidationCodeInvalid,
+				ErrInvalidDeviceID.Error())
+		}
+	}
+	for i, id := range c.ExternalDevices {
+		if id == "" || len(id) > DeviceIDMaxLength {
+			verr.Add(fmt.Sprintf("external_devices[%d]", i), ValidationCodeInvalid,
+				ErrInvalidExternalID.Error())
+		}
+	}
+
+	if c.InstalledArtifact != nil {
+		c.InstalledArtifact.validate(verr, "installed_artifact")
+	}
+
+	if c.DownloadSchedule != nil {
+		if err := c.DownloadSchedule.Validate(); err != nil {
+			verr.Add("download_schedule", ValidationCodeInvalid, err.Error())
+		}
 	}
 
-	for _, id := range c.Devices {
-		if govalidator.IsNull(id) {
-			return ErrInvalidDeviceID
+	switch c.ConflictPolicy {
+	case "", ConflictPolicyReject, ConflictPolicySkip, ConflictPolicyQueue:
+	default:
+		verr.Add("conflict_policy", ValidationCodeInvalid, ErrInvalidConflictPolicy.Error())
+	}
+
+	switch c.DeviceDeployments {
+	case "", DeviceDeploymentsEager, DeviceDeploymentsLazy:
+	default:
+		verr.Add("device_deployments", ValidationCodeInvalid,
+			ErrInvalidDeviceDeployments.Error())
+	}
+
+	if len(c.Phases) > 0 {
+		validatePhases(verr, c.Phases)
+		if c.DeviceDeployments == DeviceDeploymentsLazy {
+			verr.Add("device_deployments", ValidationCodeInvalid, ErrPhasesLazy.Error())
 		}
 	}
 
-	return nil
+	return verr.ErrorOrNil()
+}
+
+func validateName(verr *ValidationError, field string, value *string) {
+	switch {
+	case value == nil || *value == "":
+		verr.Add(field, ValidationCodeRequired, "value is required")
+	case utf8.RuneCountInString(*value) > DeploymentNameMaxLength:
+		verr.Add(field, ValidationCodeLength,
+			fmt.Sprintf("value is longer than %d characters", DeploymentNameMaxLength))
+	}
+}
+
+// isValidDeviceID accepts printable ASCII device IDs without spaces,
+// such as UUIDs or mongo object IDs.
+func isValidDeviceID(id string) bool {
+	if id == "" || len(id) > DeviceIDMaxLength {
+		return false
+	}
+	return govalidator.IsPrintableASCII(id) && !strings.Contains(id, " ")
 }
 
 type Deployment struct {
@@ -83,6 +239,44 @@ type Deployment struct {
 
 	// Total number of devices targeted
 	DeviceCount int `json:"device_count" bson:"-"`
+
+	// Changelogs of the deployed artifacts, filled in on retrieval
+	Changelog string `json:"changelog,omitempty" bson:"-"`
+
+	// Abort details, set when deployment was aborted
+	Abort *AbortInfo `json:"abort,omitempty" valid:"-" bson:"abort,omitempty"`
+
+	// Set when some of the artifacts were being restored from archive on creation
+	Restoring bool `json:"-" bson:"restoring,omitempty"`
+
+	// Set while the campaign of the deployment is paused, devices are not
+	// served the deployment
+	Paused bool `json:"paused,omitempty" valid:"-" bson:"paused,omitempty"`
+
+	// Artifact resolved for each device type on creation, set if
+	// artifact snapshot was requested
+	DeviceTypeArtifacts []DeviceTypeArtifact `json:"device_type_artifacts,omitempty" bson:"device_type_artifacts,omitempty"`
+
+	// Set when the deployment was created over a tenant limit
+	OverQuota *QuotaExceeded `json:"over_quota,omitempty" valid:"-" bson:"over_quota,omitempty"`
+}
+
+// DeviceTypeArtifact is the artifact installed by devices of the device type.
+type DeviceTypeArtifact struct {
+	DeviceType string `json:"device_type" bson:"device_type"`
+	ArtifactID string `json:"artifact_id" bson:"artifact_id"`
+}
+
+// AbortInfo records who aborted the deployment, when and why.
+type AbortInfo struct {
+	// Reason provided by the user, optional
+	Reason string `json:"reason,omitempty" valid:"length(0|1024)" bson:"reason,omitempty"`
+
+	// Identity of the user who aborted the deployment
+	AbortedBy string `json:"aborted_by,omitempty" valid:"-" bson:"aborted_by,omitempty"`
+
+	// Abort time
+	Aborted *time.Time `json:"aborted,omitempty" valid:"-" bson:"aborted,omitempty"`
 }
 
 // NewDeployment creates new deployment object, sets create data by default.
@@ -150,7 +344,9 @@ func (d *Deployment) IsInProgress() bool {
 				d.Stats[DeviceDeploymentStatusSuccess] > 0 ||
 				d.Stats[DeviceDeploymentStatusFailure] > 0 ||
 				d.Stats[DeviceDeploymentStatusNoArtifact] > 0 ||
-				d.Stats[DeviceDeploymentStatusAborted] > 0)) {
+				d.Stats[DeviceDeploymentStatusAborted] > 0 ||
+				d.Stats[DeviceDeploymentStatusSuperseded] > 0 ||
+				d.Stats[DeviceDeploymentStatusTimedOut] > 0)) {
 		return true
 	}
 	return false
@@ -195,11 +391,14 @@ func (d *Deployment) IsPending() bool {
 
 func (d *Deployment) GetStatus() string {
 	if d.IsPending() {
-		return "pending"
+		if d.Restoring {
+			return DeploymentStatusRestoring
+		}
+		return DeploymentStatusPending
 	} else if d.IsFinished() {
-		return "finished"
+		return DeploymentStatusFinished
 	} else {
-		return "inprogress"
+		return DeploymentStatusInProgress
 	}
 }
 
@@ -225,4 +424,6 @@ type Query struct {
 	// only return deployments between timestamp range
 	CreatedAfter  *time.Time
 	CreatedBefore *time.Time
+	// only return deployments created over a tenant limit
+	OverQuota bool
 }
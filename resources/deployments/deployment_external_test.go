@@ -16,6 +16,7 @@ package deployments_test
 
 import (
 	"math/rand"
+	"strings"
 	"testing"
 	"time"
 
@@ -33,6 +34,10 @@ func TestDeploymentConstructorValidate(t *testing.T) {
 		InputName         *string
 		InputArtifactName *string
 		InputDevices      []string
+		InputExternal     []string
+		InputPolicy       string
+		InputArtifactID   string
+		InputAutoLatest   bool
 		IsValid           bool
 	}{
 		{
@@ -77,12 +82,83 @@ func TestDeploymentConstructorValidate(t *testing.T) {
 			InputDevices:      []string{"lala"},
 			IsValid:           true,
 		},
+		{
+			InputName:         StringToPointer("f826484e-1157-4109-af21-304e6d711560"),
+			InputArtifactName: StringToPointer("f826484e-1157-4109-af21-304e6d711560"),
+			InputDevices:      []string{"la la"},
+			IsValid:           false,
+		},
+		{
+			InputName:       StringToPointer("f826484e-1157-4109-af21-304e6d711560"),
+			InputArtifactID: "f826484e-1157-4109-af21-304e6d711560",
+			InputDevices:    []string{"lala"},
+			IsValid:         true,
+		},
+		{
+			InputName:       StringToPointer("f826484e-1157-4109-af21-304e6d711560"),
+			InputArtifactID: "artifact-1",
+			InputDevices:    []string{"lala"},
+			IsValid:         false,
+		},
+		{
+			InputName:         StringToPointer("f826484e-1157-4109-af21-304e6d711560"),
+			InputArtifactName: StringToPointer(""),
+			InputArtifactID:   "f826484e-1157-4109-af21-304e6d711560",
+			InputDevices:      []string{"lala"},
+			IsValid:           false,
+		},
+		{
+			InputName:         StringToPointer(strings.Repeat("a", DeploymentNameMaxLength+1)),
+			InputArtifactName: StringToPointer("f826484e-1157-4109-af21-304e6d711560"),
+			InputDevices:      []string{"lala"},
+			IsValid:           false,
+		},
 		{
 			InputName:         StringToPointer("f826484e-1157-4109-af21-304e6d711560"),
 			InputArtifactName: StringToPointer("f826484e-1157-4109-af21-304e6d711560"),
 			InputDevices:      []string{"f826484e-1157-4109-af21-304e6d711560"},
 			IsValid:           true,
 		},
+		{
+			InputName:         StringToPointer("f826484e-1157-4109-af21-304e6d711560"),
+			InputArtifactName: StringToPointer("f826484e-1157-4109-af21-304e6d711560"),
+			InputDevices:      []string{"f826484e-1157-4109-af21-304e6d711560"},
+			InputPolicy:       ConflictPolicySkip,
+			IsValid:           true,
+		},
+		{
+			InputName:         StringToPointer("f826484e-1157-4109-af21-304e6d711560"),
+			InputArtifactName: StringToPointer("f826484e-1157-4109-af21-304e6d711560"),
+			InputDevices:      []string{"f826484e-1157-4109-af21-304e6d711560"},
+			InputPolicy:       "ignore",
+			IsValid:           false,
+		},
+		{
+			InputName:         StringToPointer("f826484e-1157-4109-af21-304e6d711560"),
+			InputArtifactName: StringToPointer("f826484e-1157-4109-af21-304e6d711560"),
+			InputExternal:     []string{"SN123"},
+			IsValid:           true,
+		},
+		{
+			InputName:         StringToPointer("f826484e-1157-4109-af21-304e6d711560"),
+			InputArtifactName: StringToPointer("f826484e-1157-4109-af21-304e6d711560"),
+			InputExternal:     []string{""},
+			IsValid:           false,
+		},
+		{
+			InputName:         StringToPointer("f826484e-1157-4109-af21-304e6d711560"),
+			InputArtifactName: StringToPointer("app-"),
+			InputDevices:      []string{"lala"},
+			InputAutoLatest:   true,
+			IsValid:           true,
+		},
+		{
+			InputName:       StringToPointer("f826484e-1157-4109-af21-304e6d711560"),
+			InputArtifactID: "f826484e-1157-4109-af21-304e6d711560",
+			InputDevices:    []string{"lala"},
+			InputAutoLatest: true,
+			IsValid:         false,
+		},
 	}
 
 	for _, test := range testCases {
@@ -91,6 +167,10 @@ func TestDeploymentConstructorValidate(t *testing.T) {
 		dep.Name = test.InputName
 		dep.ArtifactName = test.InputArtifactName
 		dep.Devices = test.InputDevices
+		dep.ExternalDevices = test.InputExternal
+		dep.ConflictPolicy = test.InputPolicy
+		dep.ArtifactID = test.InputArtifactID
+		dep.AutoLatest = test.InputAutoLatest
 
 		err := dep.Validate()
 
@@ -103,6 +183,60 @@ func TestDeploymentConstructorValidate(t *testing.T) {
 
 }
 
+func TestDeploymentConstructorValidateFields(t *testing.T) {
+
+	t.Parallel()
+
+	constructor := &DeploymentConstructor{
+		Name:              StringToPointer(strings.Repeat("a", DeploymentNameMaxLength+1)),
+		Slug:              "NYC Production",
+		Devices:           []string{"device-1", "", "device 3"},
+		ConflictPolicy:    "ignore",
+		DeviceDeployments: "later",
+	}
+
+	err := constructor.Validate()
+	if assert.IsType(t, &ValidationError{}, err) {
+		assert.Equal(t, []FieldError{
+			{
+				Field:   "name",
+				Code:    ValidationCodeLength,
+				Message: "value is longer than 4096 characters",
+			},
+			{
+				Field:   "slug",
+				Code:    ValidationCodeInvalid,
+				Message: ErrInvalidSlug.Error(),
+			},
+			{
+				Field:   "artifact_name",
+				Code:    ValidationCodeRequired,
+				Message: "value is required",
+			},
+			{
+				Field:   "devices[1]",
+				Code:    ValidationCodeInvalid,
+				Message: ErrInvalidDeviceID.Error(),
+			},
+			{
+				Field:   "devices[2]",
+				Code:    ValidationCodeInvalid,
+				Message: ErrInvalidDeviceID.Error(),
+			},
+			{
+				Field:   "conflict_policy",
+				Code:    ValidationCodeInvalid,
+				Message: ErrInvalidConflictPolicy.Error(),
+			},
+			{
+				Field:   "device_deployments",
+				Code:    ValidationCodeInvalid,
+				Message: ErrInvalidDeviceDeployments.Error(),
+			},
+		}, err.(*ValidationError).Fields)
+	}
+}
+
 func TestNewDeploymentFromConstructor(t *testing.T) {
 
 	t.Parallel()
@@ -263,6 +397,7 @@ func TestDeploymentGetStatus(t *testing.T) {
 
 	tests := map[string]struct {
 		Stats        map[string]int
+		Restoring    bool
 		OutputStatus string
 	}{
 		"Single NoArtifact": {
@@ -305,6 +440,13 @@ func TestDeploymentGetStatus(t *testing.T) {
 			},
 			OutputStatus: "finished",
 		},
+		"Success + TimedOut": {
+			Stats: map[string]int{
+				DeviceDeploymentStatusSuccess:  1,
+				DeviceDeploymentStatusTimedOut: 1,
+			},
+			OutputStatus: "finished",
+		},
 		"Rebooting + NoArtifact": {
 			Stats: map[string]int{
 				DeviceDeploymentStatusRebooting:  1,
@@ -332,6 +474,21 @@ func TestDeploymentGetStatus(t *testing.T) {
 			},
 			OutputStatus: "pending",
 		},
+		"Pending, artifact restoring": {
+			Stats: map[string]int{
+				DeviceDeploymentStatusPending: 1,
+			},
+			Restoring:    true,
+			OutputStatus: "restoring",
+		},
+		"Downloading, artifact was restoring": {
+			Stats: map[string]int{
+				DeviceDeploymentStatusPending:     1,
+				DeviceDeploymentStatusDownloading: 1,
+			},
+			Restoring:    true,
+			OutputStatus: "inprogress",
+		},
 		"Empty": {
 			OutputStatus: "finished",
 		},
@@ -359,6 +516,7 @@ func TestDeploymentGetStatus(t *testing.T) {
 
 		dep := NewDeployment()
 		dep.Stats = test.Stats
+		dep.Restoring = test.Restoring
 
 		assert.Equal(t, test.OutputStatus, dep.GetStatus())
 	}
@@ -386,6 +544,7 @@ func TestFuzzyGetStatus(t *testing.T) {
 		dep.Stats[DeviceDeploymentStatusAlreadyInst] = rand(0, max)
 		dep.Stats[DeviceDeploymentStatusAborted] = rand(0, max)
 		dep.Stats[DeviceDeploymentStatusDecommissioned] = rand(0, max)
+		dep.Stats[DeviceDeploymentStatusSuperseded] = rand(0, max)
 
 		pending := 0
 		inprogress := 0
@@ -15,6 +15,7 @@
 package deployments
 
 import (
+	"fmt"
 	"time"
 
 	"github.com/asaskevich/govalidator"
@@ -35,6 +36,8 @@ const (
 	DeviceDeploymentStatusAlreadyInst    = "already-installed"
 	DeviceDeploymentStatusAborted        = "aborted"
 	DeviceDeploymentStatusDecommissioned = "decommissioned"
+	DeviceDeploymentStatusSuperseded     = "superseded"
+	DeviceDeploymentStatusTimedOut       = "timed_out"
 )
 
 // DeviceDeploymentStatus is a helper type for reporting status changes through
@@ -46,6 +49,124 @@ type DeviceDeploymentStatus struct {
 	SubState *string
 	// finish time
 	FinishTime *time.Time
+	// failure details reported by device
+	Error *DeviceDeploymentError
+	// time of the status change as reported by device, optional
+	ReportTime *time.Time
+	// why the server resolved the status, set for statuses not reported
+	// by device
+	Reason *DeviceDeploymentReason
+	// telemetry reported by device, optional
+	Telemetry *DeviceTelemetry
+}
+
+// DeviceDeploymentStatusUpdate changes status of the device deployment,
+// applied only if the device deployment is still in the From status.
+type DeviceDeploymentStatusUpdate struct {
+	// Unique ID of the update, stored along with the status so that the
+	// applied updates can be found; optional
+	ID           string
+	DeviceID     string
+	DeploymentID string
+	From         string
+	Status       DeviceDeploymentStatus
+}
+
+// Codes of the reasons the server resolved the device deployment without
+// the device installing the update
+const (
+	// No artifact of the deployment is compatible with the device
+	DeviceDeploymentReasonNoArtifact = "no_compatible_artifact"
+	// The device already has the artifact of the deployment installed
+	DeviceDeploymentReasonAlreadyInstalled = "already_installed"
+	// Download link of the device was revoked
+	DeviceDeploymentReasonLinkRevoked = "link_revoked"
+)
+
+// Keys of the device provides the server resolves device deployments by
+const (
+	DeviceProvideDeviceType   = "device_type"
+	DeviceProvideArtifactName = "artifact_name"
+)
+
+// DeviceDeploymentReason tells why the server resolved status of the device
+// deployment, e.g. the device type no artifact matched.
+type DeviceDeploymentReason struct {
+	Code string `json:"code" bson:"code"`
+
+	// Device provide which decided the status, and its value reported by
+	// the device
+	Key   string `json:"key" bson:"key"`
+	Value string `json:"value" bson:"value"`
+
+	Message string `json:"message" bson:"message"`
+}
+
+// NewNoArtifactReason tells that no artifact is compatible with the
+// device type.
+func NewNoArtifactReason(deviceType string) *DeviceDeploymentReason {
+	return &DeviceDeploymentReason{
+		Code:  DeviceDeploymentReasonNoArtifact,
+		Key:   DeviceProvideDeviceType,
+		Value: deviceType,
+		Message: fmt.Sprintf("no artifact of the deployment is compatible with device type %s",
+			deviceType),
+	}
+}
+
+// NewAlreadyInstalledReason tells that the device has the artifact
+// installed.
+func NewAlreadyInstalledReason(artifactName string) *DeviceDeploymentReason {
+	return &DeviceDeploymentReason{
+		Code:    DeviceDeploymentReasonAlreadyInstalled,
+		Key:     DeviceProvideArtifactName,
+		Value:   artifactName,
+		Message: fmt.Sprintf("artifact %s is already installed on the device", artifactName),
+	}
+}
+
+// NewLinkRevokedReason tells that the download link of the device was
+// revoked.
+func NewLinkRevokedReason() *DeviceDeploymentReason {
+	return &DeviceDeploymentReason{
+		Code:    DeviceDeploymentReasonLinkRevoked,
+		Message: "artifact download link of the device was revoked",
+	}
+}
+
+// MaxDevicesLookup is the number of devices deployments can be looked up
+// for at once.
+const MaxDevicesLookup = 1000
+
+// DevicesLookup lists devices deployments are looked up for.
+type DevicesLookup struct {
+	DeviceIDs []string `json:"device_ids"`
+}
+
+func (l *DevicesLookup) Validate() error {
+	verr := &ValidationError{}
+	switch {
+	case len(l.DeviceIDs) == 0:
+		verr.Add("device_ids", ValidationCodeRequired, "value is required")
+	case len(l.DeviceIDs) > MaxDevicesLookup:
+		verr.Add("device_ids", ValidationCodeLength,
+			fmt.Sprintf("at most %d devices are allowed", MaxDevicesLookup))
+	}
+	for i, id := range l.DeviceIDs {
+		if id == "" {
+			verr.Add(fmt.Sprintf("device_ids[%d]", i), ValidationCodeRequired,
+				"value is required")
+		}
+	}
+	return verr.ErrorOrNil()
+}
+
+// DeviceDeploymentError classifies the cause of a failed device deployment.
+// Category groups failures for statistics (e.g. "signature-mismatch",
+// "storage-full"), code is a client specific error identifier.
+type DeviceDeploymentError struct {
+	Code     string `json:"code,omitempty" valid:"length(0|200)" bson:"code,omitempty"`
+	Category string `json:"category" valid:"length(1|200),required" bson:"category"`
 }
 
 type DeviceDeployment struct {
@@ -76,8 +197,49 @@ type DeviceDeployment struct {
 	// Presence of deployment log
 	IsLogAvailable bool `json:"log" valid:"-" bson:"log"`
 
+	// Phase of the phased deployment the device is assigned to
+	Phase int `json:"phase,omitempty" valid:"-" bson:"phase,omitempty"`
+
 	// Device reported substate
 	SubState *string `json:"substate,omitempty" valid:"-" bson:"substate"`
+
+	// Device reported failure details
+	Error *DeviceDeploymentError `json:"error,omitempty" valid:"-" bson:"error,omitempty"`
+
+	// Abort details, set when deployment was aborted for the device
+	Abort *AbortInfo `json:"abort,omitempty" valid:"-" bson:"abort,omitempty"`
+
+	// ID of the deployment which superseded this one
+	SupersededBy *string `json:"superseded_by,omitempty" valid:"-" bson:"superseded_by,omitempty"`
+
+	// Set when download link of the device was revoked, links issued
+	// before are refused by the download proxy and no more links are issued
+	LinkRevoked bool `json:"link_revoked,omitempty" valid:"-" bson:"link_revoked,omitempty"`
+
+	// Number of download links issued to the device
+	Attempts int `json:"attempts" valid:"-" bson:"attempts,omitempty"`
+
+	// Number of times the device reported going back to an earlier
+	// state of the update
+	StatusResets int `json:"status_resets" valid:"-" bson:"status_resets,omitempty"`
+
+	// Device reported time of the current status, if device reports it
+	StatusReported *time.Time `json:"status_reported,omitempty" valid:"-" bson:"status_reported,omitempty"`
+
+	// Number of status reports ignored because they arrived out of order
+	IgnoredReports int `json:"ignored_reports" valid:"-" bson:"ignored_reports,omitempty"`
+
+	// Number of times the failed or aborted device deployment was put
+	// back to pending
+	Retries int `json:"retries" valid:"-" bson:"retries,omitempty"`
+
+	// Inventory attributes of the device when the deployment was first
+	// served to it, by attribute name
+	Inventory map[string]string `json:"inventory,omitempty" valid:"-" bson:"inventory,omitempty"`
+
+	// Why the server resolved the status, set for noartifact and
+	// already-installed statuses
+	Reason *DeviceDeploymentReason `json:"reason,omitempty" valid:"-" bson:"reason,omitempty"`
 }
 
 func NewDeviceDeployment(deviceId, deploymentId string) *DeviceDeployment {
@@ -101,6 +263,17 @@ func (d *DeviceDeployment) Validate() error {
 	return err
 }
 
+// DeviceDeploymentsQuery selects device deployments across deployments.
+type DeviceDeploymentsQuery struct {
+	// device deployment status, any if empty
+	Status string
+	Limit  int
+	Skip   int
+	// only return device deployments created in timestamp range
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
+}
+
 // Deployment statistics wrapper, each value carries a count of deployments
 // aggregated by state.
 type Stats map[string]int
@@ -117,6 +290,8 @@ func NewDeviceDeploymentStats() Stats {
 		DeviceDeploymentStatusAlreadyInst,
 		DeviceDeploymentStatusAborted,
 		DeviceDeploymentStatusDecommissioned,
+		DeviceDeploymentStatusSuperseded,
+		DeviceDeploymentStatusTimedOut,
 	}
 
 	s := make(Stats)
@@ -129,15 +304,89 @@ func NewDeviceDeploymentStats() Stats {
 	return s
 }
 
+// Deployment statistics broken down by value of a device attribute
+// (e.g. inventory group), devices without the attribute are counted under
+// GroupStatsUnknown.
+type GroupStats map[string]Stats
+
+const GroupStatsUnknown = "unknown"
+
+// Number of failed device deployments by reported error category,
+// failures reported without details are counted under
+// FailureStatsUncategorized.
+type FailureStats map[string]int
+
+const FailureStatsUncategorized = "uncategorized"
+
+// Upper bounds of buckets counting device deployment durations, the last
+// bucket counts durations longer than the last bound.
+var DurationStatsBuckets = []time.Duration{
+	time.Minute,
+	5 * time.Minute,
+	15 * time.Minute,
+	time.Hour,
+	6 * time.Hour,
+	24 * time.Hour,
+}
+
+// Distribution of times from creation of device deployments until they
+// finished, broken down by final status (success or failure).
+type DurationStats map[string][]DurationBucket
+
+// DurationBucket counts device deployments finished in less than UpTo
+// seconds, but not before the bound of the preceding bucket.
+// UpTo is not set for the last bucket.
+type DurationBucket struct {
+	UpTo  int64 `json:"up_to,omitempty"`
+	Count int   `json:"count"`
+}
+
+// NewDurationStats creates empty histograms for successful and failed
+// device deployments.
+func NewDurationStats() DurationStats {
+	stats := make(DurationStats)
+	for _, status := range []string{DeviceDeploymentStatusSuccess,
+		DeviceDeploymentStatusFailure} {
+
+		buckets := make([]DurationBucket, len(DurationStatsBuckets)+1)
+		for i, bound := range DurationStatsBuckets {
+			buckets[i].UpTo = int64(bound / time.Second)
+		}
+		stats[status] = buckets
+	}
+	return stats
+}
+
+// IsDeviceDeploymentStatus checks if status is a known device deployment status.
+func IsDeviceDeploymentStatus(status string) bool {
+	_, ok := NewDeviceDeploymentStats()[status]
+	return ok
+}
+
 func IsDeviceDeploymentStatusFinished(status string) bool {
-	if status == DeviceDeploymentStatusFailure || status == DeviceDeploymentStatusSuccess ||
-		status == DeviceDeploymentStatusNoArtifact || status == DeviceDeploymentStatusAlreadyInst ||
-		status == DeviceDeploymentStatusAborted || status == DeviceDeploymentStatusDecommissioned {
-		return true
+	for _, finished := range FinishedDeploymentStatuses() {
+		if status == finished {
+			return true
+		}
 	}
 	return false
 }
 
+// FinishedDeploymentStatuses lists terminal statuses of device deployments,
+// no progress of the update is reported after them.
+func FinishedDeploymentStatuses() []string {
+	return []string{
+		DeviceDeploymentStatusFailure,
+		DeviceDeploymentStatusSuccess,
+		DeviceDeploymentStatusNoArtifact,
+		DeviceDeploymentStatusAlreadyInst,
+		DeviceDeploymentStatusAborted,
+		DeviceDeploymentStatusDecommissioned,
+		DeviceDeploymentStatusSuperseded,
+		DeviceDeploymentStatusTimedOut,
+	}
+}
+
 // ActiveDeploymentStatuses lists statuses that represent deployment in active state (not finished).
 func ActiveDeploymentStatuses() []string {
 	return []string{
@@ -148,6 +397,22 @@ func ActiveDeploymentStatuses() []string {
 	}
 }
 
+// IsDeviceDeploymentStatusReset checks if change of the status goes back
+// to an earlier state of the update, e.g. device restarted download after
+// failed installation.
+func IsDeviceDeploymentStatusReset(from, to string) bool {
+	fromIdx, toIdx := -1, -1
+	for i, status := range ActiveDeploymentStatuses() {
+		if status == from {
+			fromIdx = i
+		}
+		if status == to {
+			toIdx = i
+		}
+	}
+	return fromIdx >= 0 && toIdx >= 0 && toIdx < fromIdx
+}
+
 // InstalledDeviceDeployment describes a deployment currently installed on the
 // device, usually reported by a device
 type InstalledDeviceDeployment struct {
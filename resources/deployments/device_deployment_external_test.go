@@ -168,3 +168,83 @@ func TestDeviceDeploymentIsFinished(t *testing.T) {
 		}
 	}
 }
+
+func TestDeviceDeploymentIsStatusReset(t *testing.T) {
+	tcs := []struct {
+		from  string
+		to    string
+		reset bool
+	}{
+		{DeviceDeploymentStatusInstalling, DeviceDeploymentStatusDownloading, true},
+		{DeviceDeploymentStatusRebooting, DeviceDeploymentStatusDownloading, true},
+		{DeviceDeploymentStatusRebooting, DeviceDeploymentStatusInstalling, true},
+		// regular progress of the update
+		{DeviceDeploymentStatusPending, DeviceDeploymentStatusDownloading, false},
+		{DeviceDeploymentStatusDownloading, DeviceDeploymentStatusInstalling, false},
+		{DeviceDeploymentStatusInstalling, DeviceDeploymentStatusRebooting, false},
+		{DeviceDeploymentStatusDownloading, DeviceDeploymentStatusDownloading, false},
+		// finished deployments
+		{DeviceDeploymentStatusRebooting, DeviceDeploymentStatusFailure, false},
+		{DeviceDeploymentStatusFailure, DeviceDeploymentStatusDownloading, false},
+	}
+	for _, tc := range tcs {
+		assert.Equal(t, tc.reset, IsDeviceDeploymentStatusReset(tc.from, tc.to),
+			"%s -> %s", tc.from, tc.to)
+	}
+}
+
+func TestDeviceDeploymentReason(t *testing.T) {
+	reason := NewNoArtifactReason("raspberrypi4")
+	assert.Equal(t, DeviceDeploymentReasonNoArtifact, reason.Code)
+	assert.Equal(t, DeviceProvideDeviceType, reason.Key)
+	assert.Equal(t, "raspberrypi4", reason.Value)
+	assert.Equal(t,
+		"no artifact of the deployment is compatible with device type raspberrypi4",
+		reason.Message)
+
+	reason = NewAlreadyInstalledReason("release-1")
+	assert.Equal(t, DeviceDeploymentReasonAlreadyInstalled, reason.Code)
+	assert.Equal(t, DeviceProvideArtifactName, reason.Key)
+	assert.Equal(t, "release-1", reason.Value)
+	assert.Equal(t, "artifact release-1 is already installed on the device", reason.Message)
+}
+
+func TestDevicesLookupValidate(t *testing.T) {
+	tooMany := make([]string, MaxDevicesLookup+1)
+	for i := range tooMany {
+		tooMany[i] = "device"
+	}
+
+	testCases := map[string]struct {
+		lookup DevicesLookup
+
+		err string
+	}{
+		"ok": {
+			lookup: DevicesLookup{DeviceIDs: []string{"dev1", "dev2"}},
+		},
+		"no devices": {
+			lookup: DevicesLookup{},
+			err:    "device_ids: value is required;",
+		},
+		"too many devices": {
+			lookup: DevicesLookup{DeviceIDs: tooMany},
+			err:    "device_ids: at most 1000 devices are allowed;",
+		},
+		"empty device ID": {
+			lookup: DevicesLookup{DeviceIDs: []string{"dev1", ""}},
+			err:    "device_ids[1]: value is required;",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			err := tc.lookup.Validate()
+			if tc.err != "" {
+				assert.EqualError(t, err, tc.err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
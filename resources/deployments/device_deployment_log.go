@@ -23,6 +23,16 @@ import (
 	"github.com/pkg/errors"
 )
 
+const (
+	// Most messages a deployment log can have.
+	MaxDeploymentLogMessages = 10000
+	// Longest message of a deployment log, in bytes.
+	MaxLogMessageLength = 16 * 1024
+	// Largest deployment log accepted, sum of message lengths in bytes;
+	// keeps the stored document well within the MongoDB document limit.
+	MaxDeploymentLogSize = 4 * 1024 * 1024
+)
+
 type LogMessage struct {
 	Timestamp *time.Time `json:"timestamp" valid:"required"`
 	Level     string     `json:"level" valid:"required"`
@@ -38,8 +48,10 @@ type DeploymentLog struct {
 }
 
 var (
-	ErrInvalidDeploymentLog = errors.New("invalid deployment log")
-	ErrInvalidLogMessage    = errors.New("invalid log message")
+	ErrInvalidDeploymentLog  = errors.New("invalid deployment log")
+	ErrInvalidLogMessage     = errors.New("invalid log message")
+	ErrLogMessageTooLong     = errors.New("log message too long")
+	ErrDeploymentLogTooLarge = errors.New("deployment log too large")
 )
 
 func (l *LogMessage) UnmarshalJSON(raw []byte) error {
@@ -62,8 +74,13 @@ func (l *LogMessage) UnmarshalJSON(raw []byte) error {
 }
 
 func (l LogMessage) Validate() error {
-	_, err := govalidator.ValidateStruct(l)
-	return err
+	if _, err := govalidator.ValidateStruct(l); err != nil {
+		return err
+	}
+	if len(l.Message) > MaxLogMessageLength {
+		return errors.Wrapf(ErrLogMessageTooLong, "over %d bytes", MaxLogMessageLength)
+	}
+	return nil
 }
 
 func (l LogMessage) String() string {
@@ -83,11 +100,39 @@ func (d *DeploymentLog) UnmarshalJSON(raw []byte) error {
 		return errors.Wrapf(ErrInvalidDeploymentLog, "no messages")
 	}
 
+	if err := validateLogSize(adl.Messages); err != nil {
+		return err
+	}
+
 	d.Messages = adl.Messages
 	return nil
 }
 
 func (d DeploymentLog) Validate() error {
-	_, err := govalidator.ValidateStruct(d)
-	return err
+	if _, err := govalidator.ValidateStruct(d); err != nil {
+		return err
+	}
+	for _, m := range d.Messages {
+		if err := m.Validate(); err != nil {
+			return err
+		}
+	}
+	return validateLogSize(d.Messages)
+}
+
+// validateLogSize checks the number of messages and their total length.
+func validateLogSize(messages []LogMessage) error {
+	if len(messages) > MaxDeploymentLogMessages {
+		return errors.Wrapf(ErrDeploymentLogTooLarge,
+			"over %d messages", MaxDeploymentLogMessages)
+	}
+	size := 0
+	for _, m := range messages {
+		size += len(m.Message)
+	}
+	if size > MaxDeploymentLogSize {
+		return errors.Wrapf(ErrDeploymentLogTooLarge,
+			"over %d bytes", MaxDeploymentLogSize)
+	}
+	return nil
 }
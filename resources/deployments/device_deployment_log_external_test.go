@@ -16,6 +16,7 @@ package deployments_test
 
 import (
 	"encoding/json"
+	"strings"
 	"testing"
 	"time"
 
@@ -29,6 +30,16 @@ func TestValidateDeploymentLog(t *testing.T) {
 	tref, err := time.Parse(time.RFC3339, "2006-01-02T15:04:05-07:00")
 	assert.NoError(t, err)
 
+	longMessage := LogMessage{
+		Level:     "notice",
+		Message:   strings.Repeat("x", MaxLogMessageLength),
+		Timestamp: &tref,
+	}
+	tooLarge := make([]LogMessage, MaxDeploymentLogSize/MaxLogMessageLength+1)
+	for i := range tooLarge {
+		tooLarge[i] = longMessage
+	}
+
 	tcs := []struct {
 		input DeploymentLog
 		err   error
@@ -79,6 +90,28 @@ func TestValidateDeploymentLog(t *testing.T) {
 			},
 			err: errors.New("DeploymentID: asdasdad1231 does not validate as uuidv4;"),
 		},
+		{
+			input: DeploymentLog{
+				DeviceID:     "1234",
+				DeploymentID: "30b3e62c-9ec2-4312-a7fa-cff24cc7397a",
+				Messages: []LogMessage{
+					{
+						Level:     "notice",
+						Message:   longMessage.Message + "x",
+						Timestamp: &tref,
+					},
+				},
+			},
+			err: errors.New("over 16384 bytes: log message too long"),
+		},
+		{
+			input: DeploymentLog{
+				DeviceID:     "1234",
+				DeploymentID: "30b3e62c-9ec2-4312-a7fa-cff24cc7397a",
+				Messages:     tooLarge,
+			},
+			err: errors.New("over 4194304 bytes: deployment log too large"),
+		},
 	}
 
 	for _, tc := range tcs {
@@ -0,0 +1,121 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package deployments
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+
+	"github.com/asaskevich/govalidator"
+)
+
+// Errors
+var (
+	ErrInvalidDeviceIDFormat  = errors.New("Invalid device ID format, expected uuid, mac or regex")
+	ErrInvalidDeviceIDPattern = errors.New("Invalid device ID pattern, expected regular expression")
+	ErrDeviceIDFormatMismatch = errors.New("Device ID does not match the device ID format of the tenant")
+)
+
+// Device ID formats
+const (
+	// UUID of any version
+	DeviceIDFormatUUID = "uuid"
+	// Six pairs of hex digits, separated by colons, dashes or nothing
+	DeviceIDFormatMAC = "mac"
+	// Regular expression given by the pattern
+	DeviceIDFormatRegex = "regex"
+
+	DeviceIDPatternMaxLength = 256
+)
+
+var macDeviceID = regexp.MustCompile(`^(?:[[:xdigit:]]{2}(?::[[:xdigit:]]{2}){5}|` +
+	`[[:xdigit:]]{2}(?:-[[:xdigit:]]{2}){5}|[[:xdigit:]]{12})$`)
+
+// DeviceIDFormat restricts IDs of devices given to new deployments of the
+// tenant, catching e.g. emails or hostnames pasted by mistake.
+type DeviceIDFormat struct {
+	Type string `json:"type" bson:"type"`
+
+	// Regular expression the whole device ID has to match, regex type only
+	Pattern string `json:"pattern,omitempty" bson:"pattern,omitempty"`
+}
+
+// validate reports each invalid field under the prefix.
+func (f *DeviceIDFormat) validate(verr *ValidationError, prefix string) {
+	switch f.Type {
+	case DeviceIDFormatUUID, DeviceIDFormatMAC:
+		if f.Pattern != "" {
+			verr.Add(prefix+".pattern", ValidationCodeInvalid,
+				"pattern is allowed with regex type only")
+		}
+	case DeviceIDFormatRegex:
+		if f.Pattern == "" {
+			verr.Add(prefix+".pattern", ValidationCodeRequired, "pattern is required")
+		} else if len(f.Pattern) > DeviceIDPatternMaxLength {
+			verr.Add(prefix+".pattern", ValidationCodeLength,
+				fmt.Sprintf("at most %d characters", DeviceIDPatternMaxLength))
+		} else if _, err := f.regexp(); err != nil {
+			verr.Add(prefix+".pattern", ValidationCodeInvalid,
+				ErrInvalidDeviceIDPattern.Error())
+		}
+	default:
+		verr.Add(prefix+".type", ValidationCodeInvalid, ErrInvalidDeviceIDFormat.Error())
+	}
+}
+
+// regexp compiles the pattern anchored to the whole device ID.
+func (f *DeviceIDFormat) regexp() (*regexp.Regexp, error) {
+	return regexp.Compile("^(?:" + f.Pattern + ")$")
+}
+
+// matcher returns function accepting device IDs of the format.
+func (f *DeviceIDFormat) matcher() (func(id string) bool, error) {
+	switch f.Type {
+	case DeviceIDFormatUUID:
+		return govalidator.IsUUID, nil
+	case DeviceIDFormatMAC:
+		return macDeviceID.MatchString, nil
+	case DeviceIDFormatRegex:
+		re, err := f.regexp()
+		if err != nil {
+			return nil, err
+		}
+		return re.MatchString, nil
+	}
+	return nil, ErrInvalidDeviceIDFormat
+}
+
+// ValidateDeviceIDs checks device IDs of the constructor against the format
+// and reports each mismatching one. Returned error is *ValidationError.
+func (c *DeploymentConstructor) ValidateDeviceIDs(format *DeviceIDFormat) error {
+	if format == nil {
+		return nil
+	}
+
+	match, err := format.matcher()
+	if err != nil {
+		return err
+	}
+
+	verr := &ValidationError{}
+	for i, id := range c.Devices {
+		if !match(id) {
+			verr.Add(fmt.Sprintf("devices[%d]", i), ValidationCodeInvalid,
+				ErrDeviceIDFormatMismatch.Error())
+		}
+	}
+	return verr.ErrorOrNil()
+}
@@ -0,0 +1,129 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package deployments_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	. "github.com/mendersoftware/deployments/resources/deployments"
+)
+
+func TestDeviceIDFormatValidate(t *testing.T) {
+
+	t.Parallel()
+
+	testCases := map[string]struct {
+		InputFormat DeviceIDFormat
+		OutputField string
+	}{
+		"uuid":  {InputFormat: DeviceIDFormat{Type: DeviceIDFormatUUID}},
+		"mac":   {InputFormat: DeviceIDFormat{Type: DeviceIDFormatMAC}},
+		"regex": {InputFormat: DeviceIDFormat{Type: DeviceIDFormatRegex, Pattern: "[0-9a-f]{24}"}},
+		"unknown type": {
+			InputFormat: DeviceIDFormat{Type: "email"},
+			OutputField: "device_id_format.type",
+		},
+		"pattern without regex": {
+			InputFormat: DeviceIDFormat{Type: DeviceIDFormatUUID, Pattern: ".*"},
+			OutputField: "device_id_format.pattern",
+		},
+		"missing pattern": {
+			InputFormat: DeviceIDFormat{Type: DeviceIDFormatRegex},
+			OutputField: "device_id_format.pattern",
+		},
+		"invalid pattern": {
+			InputFormat: DeviceIDFormat{Type: DeviceIDFormatRegex, Pattern: "[0-9"},
+			OutputField: "device_id_format.pattern",
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			settings := DeploymentSettings{DeviceIDFormat: &testCase.InputFormat}
+			err := settings.Validate()
+			if testCase.OutputField == "" {
+				assert.NoError(t, err)
+				return
+			}
+			if assert.IsType(t, &ValidationError{}, err) {
+				fields := err.(*ValidationError).Fields
+				if assert.Len(t, fields, 1) {
+					assert.Equal(t, testCase.OutputField, fields[0].Field)
+				}
+			}
+		})
+	}
+}
+
+func TestDeploymentConstructorValidateDeviceIDs(t *testing.T) {
+
+	t.Parallel()
+
+	devices := []string{
+		"b532b01a-9313-404f-8d19-e7fcbe5cc347",
+		"00:1a:2B:3c:4d:5e",
+		"00-1a-2b-3c-4d-5e",
+		"001a2b3c4d5e",
+		"5a0c1b2e3f4a5b6c7d8e9f00",
+		"john@example.com",
+	}
+
+	testCases := map[string]struct {
+		InputFormat *DeviceIDFormat
+		OutputValid []int
+	}{
+		"no format": {
+			OutputValid: []int{0, 1, 2, 3, 4, 5},
+		},
+		"uuid": {
+			InputFormat: &DeviceIDFormat{Type: DeviceIDFormatUUID},
+			OutputValid: []int{0},
+		},
+		"mac": {
+			InputFormat: &DeviceIDFormat{Type: DeviceIDFormatMAC},
+			OutputValid: []int{1, 2, 3},
+		},
+		"regex matches whole ID": {
+			InputFormat: &DeviceIDFormat{Type: DeviceIDFormatRegex, Pattern: "[0-9a-f]{24}"},
+			OutputValid: []int{4},
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			constructor := DeploymentConstructor{Devices: devices}
+			err := constructor.ValidateDeviceIDs(testCase.InputFormat)
+
+			invalid := map[string]bool{}
+			if err != nil && assert.IsType(t, &ValidationError{}, err) {
+				for _, f := range err.(*ValidationError).Fields {
+					assert.Equal(t, ValidationCodeInvalid, f.Code)
+					assert.Equal(t, ErrDeviceIDFormatMismatch.Error(), f.Message)
+					invalid[f.Field] = true
+				}
+			}
+			valid := []int{}
+			for i := range devices {
+				if !invalid[fmt.Sprintf("devices[%d]", i)] {
+					valid = append(valid, i)
+				}
+			}
+			assert.Equal(t, testCase.OutputValid, valid)
+		})
+	}
+}
@@ -0,0 +1,38 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package deployments
+
+// DeviceDataPurge counts device data removed from the storage of a single
+// tenant. Device deployments are anonymized rather than removed, so that
+// deployment statistics stay consistent.
+type DeviceDataPurge struct {
+	TenantID          string `json:"tenant_id,omitempty"`
+	DeviceDeployments int    `json:"device_deployments"`
+	Logs              int    `json:"logs"`
+	Downloads         int    `json:"downloads"`
+	Telemetry         int    `json:"telemetry"`
+}
+
+// Empty tells whether no data of the device was found.
+func (p *DeviceDataPurge) Empty() bool {
+	return p.DeviceDeployments == 0 && p.Logs == 0 && p.Downloads == 0 &&
+		p.Telemetry == 0
+}
+
+// DeviceDataPurgeReport lists tenants which had data of the device.
+type DeviceDataPurgeReport struct {
+	DeviceID string            `json:"device_id"`
+	Tenants  []DeviceDataPurge `json:"tenants"`
+}
@@ -0,0 +1,81 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package deployments
+
+import (
+	"errors"
+	"sort"
+
+	"github.com/asaskevich/govalidator"
+)
+
+// Errors
+var (
+	ErrDeviceTypeAliasSelf = errors.New("Device type cannot be an alias of itself")
+)
+
+// DeviceTypeAlias makes devices of the alias device type compatible with
+// artifacts of the device type and vice versa, e.g. of re-branded hardware,
+// so that the same artifact does not have to be uploaded for both.
+type DeviceTypeAlias struct {
+	// Alias device type, e.g. "rpi4"
+	Alias string `json:"alias" valid:"length(1|256),required" bson:"_id"`
+
+	// Device type aliased, e.g. "raspberrypi4"
+	DeviceType string `json:"device_type" valid:"length(1|256),required" bson:"device_type"`
+}
+
+// Validate checks structure according to valid tags.
+func (a *DeviceTypeAlias) Validate() error {
+	if _, err := govalidator.ValidateStruct(a); err != nil {
+		return err
+	}
+
+	if a.Alias == a.DeviceType {
+		return ErrDeviceTypeAliasSelf
+	}
+
+	return nil
+}
+
+// EquivalentDeviceTypes returns device types the given one is aliased with,
+// directly or through other aliases, sorted; the device type itself is not
+// included.
+func EquivalentDeviceTypes(aliases []*DeviceTypeAlias, deviceType string) []string {
+	linked := map[string][]string{}
+	for _, alias := range aliases {
+		linked[alias.Alias] = append(linked[alias.Alias], alias.DeviceType)
+		linked[alias.DeviceType] = append(linked[alias.DeviceType], alias.Alias)
+	}
+
+	seen := map[string]bool{deviceType: true}
+	queue := []string{deviceType}
+	var equivalent []string
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		for _, next := range linked[current] {
+			if seen[next] {
+				continue
+			}
+			seen[next] = true
+			queue = append(queue, next)
+			equivalent = append(equivalent, next)
+		}
+	}
+	sort.Strings(equivalent)
+
+	return equivalent
+}
@@ -0,0 +1,102 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package deployments_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	. "github.com/mendersoftware/deployments/resources/deployments"
+)
+
+func TestDeviceTypeAliasValidate(t *testing.T) {
+
+	t.Parallel()
+
+	testCases := []struct {
+		InputAlias  DeviceTypeAlias
+		OutputError error
+	}{
+		{
+			InputAlias: DeviceTypeAlias{Alias: "rpi4", DeviceType: "raspberrypi4"},
+		},
+		{
+			InputAlias:  DeviceTypeAlias{DeviceType: "raspberrypi4"},
+			OutputError: errors.New("Alias: non zero value required;"),
+		},
+		{
+			InputAlias:  DeviceTypeAlias{Alias: "rpi4"},
+			OutputError: errors.New("DeviceType: non zero value required;"),
+		},
+		{
+			InputAlias:  DeviceTypeAlias{Alias: "rpi4", DeviceType: "rpi4"},
+			OutputError: ErrDeviceTypeAliasSelf,
+		},
+	}
+
+	for _, test := range testCases {
+		err := test.InputAlias.Validate()
+		if test.OutputError != nil {
+			assert.EqualError(t, err, test.OutputError.Error())
+		} else {
+			assert.NoError(t, err)
+		}
+	}
+}
+
+func TestEquivalentDeviceTypes(t *testing.T) {
+
+	t.Parallel()
+
+	aliases := []*DeviceTypeAlias{
+		{Alias: "rpi4", DeviceType: "raspberrypi4"},
+		{Alias: "acme-gateway", DeviceType: "rpi4"},
+		{Alias: "bbb", DeviceType: "beaglebone"},
+	}
+
+	testCases := map[string]struct {
+		InputDeviceType string
+		Output          []string
+	}{
+		"device type": {
+			InputDeviceType: "raspberrypi4",
+			Output:          []string{"acme-gateway", "rpi4"},
+		},
+		"alias": {
+			InputDeviceType: "rpi4",
+			Output:          []string{"acme-gateway", "raspberrypi4"},
+		},
+		"alias of alias": {
+			InputDeviceType: "acme-gateway",
+			Output:          []string{"raspberrypi4", "rpi4"},
+		},
+		"other group": {
+			InputDeviceType: "bbb",
+			Output:          []string{"beaglebone"},
+		},
+		"not aliased": {
+			InputDeviceType: "qemux86-64",
+		},
+	}
+
+	for name, test := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, test.Output,
+				EquivalentDeviceTypes(aliases, test.InputDeviceType))
+		})
+	}
+}
@@ -0,0 +1,73 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package deployments
+
+import (
+	"time"
+
+	"github.com/asaskevich/govalidator"
+	"github.com/satori/go.uuid"
+)
+
+// Download is an audit record of an artifact download link issued to
+// a device updated by a deployment, or to a user.
+type Download struct {
+	// Download record id
+	Id string `json:"id" bson:"_id" valid:"uuidv4,required"`
+
+	// Downloaded artifact id
+	ArtifactID string `json:"artifact_id" bson:"artifact_id" valid:"required"`
+
+	// Deployment the artifact was downloaded for, empty for user downloads
+	DeploymentID string `json:"deployment_id,omitempty" bson:"deployment_id,omitempty" valid:"-"`
+
+	// Downloading device id, empty for user downloads
+	DeviceID string `json:"device_id,omitempty" bson:"device_id,omitempty" valid:"-"`
+
+	// Downloading user id, empty for device downloads
+	UserID string `json:"user_id,omitempty" bson:"user_id,omitempty" valid:"-"`
+
+	// Time the download link was issued
+	Created *time.Time `json:"created" bson:"created" valid:"required"`
+}
+
+// NewDownload creates new download record of the artifact.
+func NewDownload(artifactID string) *Download {
+	now := time.Now()
+
+	return &Download{
+		Id:         uuid.NewV4().String(),
+		ArtifactID: artifactID,
+		Created:    &now,
+	}
+}
+
+// Validate checks structure according to valid tags.
+func (d *Download) Validate() error {
+	_, err := govalidator.ValidateStruct(d)
+	return err
+}
+
+// DownloadsQuery selects download records, empty fields match any value.
+type DownloadsQuery struct {
+	ArtifactID   string
+	DeploymentID string
+	DeviceID     string
+	Limit        int
+	Skip         int
+	// only return downloads between timestamp range
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
+}
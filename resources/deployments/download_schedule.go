@@ -0,0 +1,110 @@
+// Copyright 2018 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package deployments
+
+import (
+	"errors"
+	"time"
+)
+
+const (
+	// Time of day format used by download windows
+	DownloadWindowTimeFormat = "15:04"
+)
+
+// Errors
+var (
+	ErrInvalidDownloadWindow    = errors.New("Invalid download window, expected HH:MM start and end times")
+	ErrInvalidDownloadRateLimit = errors.New("Invalid download rate limit")
+)
+
+// DownloadWindow is a daily time range (UTC) in which devices may download
+// the artifact. Window with start time after end time spans over midnight.
+type DownloadWindow struct {
+	Start string `json:"start" valid:"required"`
+	End   string `json:"end" valid:"required"`
+}
+
+// DownloadSchedule restricts artifact delivery of the deployment
+// to protect constrained field networks.
+type DownloadSchedule struct {
+	// Allowed download time windows, all day if empty
+	Windows []DownloadWindow `json:"windows,omitempty" valid:"-"`
+
+	// Maximum number of download links issued per minute, unlimited if 0
+	MaxDownloadsPerMinute int `json:"max_downloads_per_minute,omitempty" valid:"-"`
+}
+
+func parseTimeOfDay(value string) (time.Duration, error) {
+	t, err := time.Parse(DownloadWindowTimeFormat, value)
+	if err != nil {
+		return 0, ErrInvalidDownloadWindow
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// Validate checks window time formats and rate limit value.
+func (s *DownloadSchedule) Validate() error {
+	if s.MaxDownloadsPerMinute < 0 {
+		return ErrInvalidDownloadRateLimit
+	}
+
+	for _, w := range s.Windows {
+		if _, err := parseTimeOfDay(w.Start); err != nil {
+			return err
+		}
+		if _, err := parseTimeOfDay(w.End); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// IsOpen returns true if downloads are allowed at the given moment.
+func (s *DownloadSchedule) IsOpen(when time.Time) bool {
+	if s == nil || len(s.Windows) == 0 {
+		return true
+	}
+
+	when = when.UTC()
+	now := time.Duration(when.Hour())*time.Hour + time.Duration(when.Minute())*time.Minute
+
+	for _, w := range s.Windows {
+		start, err := parseTimeOfDay(w.Start)
+		if err != nil {
+			continue
+		}
+		end, err := parseTimeOfDay(w.End)
+		if err != nil {
+			continue
+		}
+
+		if start <= end {
+			if now >= start && now < end {
+				return true
+			}
+		} else if now >= start || now < end {
+			return true
+		}
+	}
+
+	return false
+}
+
+// IsRateLimited returns true if the schedule limits number of downloads.
+func (s *DownloadSchedule) IsRateLimited() bool {
+	return s != nil && s.MaxDownloadsPerMinute > 0
+}
@@ -0,0 +1,139 @@
+// Copyright 2018 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package deployments_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	. "github.com/mendersoftware/deployments/resources/deployments"
+)
+
+func TestDownloadScheduleValidate(t *testing.T) {
+
+	t.Parallel()
+
+	testCases := []struct {
+		InputSchedule DownloadSchedule
+		OutputError   error
+	}{
+		{
+			InputSchedule: DownloadSchedule{},
+		},
+		{
+			InputSchedule: DownloadSchedule{
+				Windows:               []DownloadWindow{{Start: "22:00", End: "06:00"}},
+				MaxDownloadsPerMinute: 100,
+			},
+		},
+		{
+			InputSchedule: DownloadSchedule{
+				Windows: []DownloadWindow{{Start: "22", End: "06:00"}},
+			},
+			OutputError: ErrInvalidDownloadWindow,
+		},
+		{
+			InputSchedule: DownloadSchedule{
+				Windows: []DownloadWindow{{Start: "22:00", End: "25:00"}},
+			},
+			OutputError: ErrInvalidDownloadWindow,
+		},
+		{
+			InputSchedule: DownloadSchedule{
+				MaxDownloadsPerMinute: -1,
+			},
+			OutputError: ErrInvalidDownloadRateLimit,
+		},
+	}
+
+	for _, test := range testCases {
+		assert.Equal(t, test.OutputError, test.InputSchedule.Validate())
+	}
+}
+
+func TestDownloadScheduleIsOpen(t *testing.T) {
+
+	t.Parallel()
+
+	day := time.Date(2018, 5, 1, 0, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		InputSchedule *DownloadSchedule
+		InputTime     time.Time
+		IsOpen        bool
+	}{
+		{
+			InputSchedule: nil,
+			InputTime:     day,
+			IsOpen:        true,
+		},
+		{
+			InputSchedule: &DownloadSchedule{},
+			InputTime:     day,
+			IsOpen:        true,
+		},
+		{
+			InputSchedule: &DownloadSchedule{
+				Windows: []DownloadWindow{{Start: "08:00", End: "16:00"}},
+			},
+			InputTime: day.Add(8 * time.Hour),
+			IsOpen:    true,
+		},
+		{
+			InputSchedule: &DownloadSchedule{
+				Windows: []DownloadWindow{{Start: "08:00", End: "16:00"}},
+			},
+			InputTime: day.Add(16 * time.Hour),
+			IsOpen:    false,
+		},
+		{
+			InputSchedule: &DownloadSchedule{
+				Windows: []DownloadWindow{{Start: "22:00", End: "06:00"}},
+			},
+			InputTime: day.Add(23 * time.Hour),
+			IsOpen:    true,
+		},
+		{
+			InputSchedule: &DownloadSchedule{
+				Windows: []DownloadWindow{{Start: "22:00", End: "06:00"}},
+			},
+			InputTime: day.Add(5 * time.Hour),
+			IsOpen:    true,
+		},
+		{
+			InputSchedule: &DownloadSchedule{
+				Windows: []DownloadWindow{{Start: "22:00", End: "06:00"}},
+			},
+			InputTime: day.Add(12 * time.Hour),
+			IsOpen:    false,
+		},
+		{
+			InputSchedule: &DownloadSchedule{
+				Windows: []DownloadWindow{
+					{Start: "01:00", End: "02:00"},
+					{Start: "12:00", End: "13:00"},
+				},
+			},
+			InputTime: day.Add(12*time.Hour + 30*time.Minute),
+			IsOpen:    true,
+		},
+	}
+
+	for _, test := range testCases {
+		assert.Equal(t, test.IsOpen, test.InputSchedule.IsOpen(test.InputTime))
+	}
+}
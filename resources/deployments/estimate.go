@@ -0,0 +1,34 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package deployments
+
+// DeploymentEstimate predicts network usage and duration of a deployment.
+type DeploymentEstimate struct {
+	// Number of devices targeted by the deployment
+	Devices int `json:"devices"`
+
+	// Size of the largest payload of the deployment artifacts in bytes
+	ArtifactSize int64 `json:"artifact_size"`
+
+	// Bytes downloaded if all devices download the largest payload
+	DownloadBytes int64 `json:"download_bytes"`
+
+	// Average time in seconds successful device deployments took recently,
+	// not set without recent successful device deployments
+	Duration int64 `json:"duration,omitempty"`
+
+	// Number of device deployments the duration is based on
+	DurationSamples int `json:"duration_samples"`
+}
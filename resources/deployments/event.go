@@ -0,0 +1,63 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package deployments
+
+import (
+	"time"
+)
+
+// Event types
+const (
+	// Failed device uploaded its deployment log
+	EventDeviceDeploymentLogAvailable = "device_deployment.log_available"
+	// Deployment was aborted while the device was updating
+	EventDeviceDeploymentAborted = "device_deployment.aborted"
+	// Condition of an alert held on the deployment for long enough
+	EventDeploymentAlert = "deployment.alert"
+	// Deployment reached a terminal state, finished by all devices or
+	// aborted
+	EventDeploymentFinished = "deployment.finished"
+	// Phase of a phased deployment started
+	EventDeploymentPhaseStarted = "deployment.phase_started"
+	// Phase the device is assigned to started, the device can get the
+	// deployment
+	EventDeviceDeploymentPhaseStarted = "device_deployment.phase_started"
+)
+
+// Event describes a notable change of a deployment, published to
+// external subscribers.
+type Event struct {
+	Type         string    `json:"type"`
+	Time         time.Time `json:"time"`
+	DeploymentID string    `json:"deployment_id"`
+	DeviceID     string    `json:"device_id,omitempty"`
+	Status       string    `json:"status,omitempty"`
+
+	// Alert which fired, set for alert events
+	AlertID   string `json:"alert_id,omitempty"`
+	AlertName string `json:"alert_name,omitempty"`
+
+	// Phase which started, set for phase events
+	Phase *int `json:"phase,omitempty"`
+}
+
+// NewEvent creates event of the given type for the deployment.
+func NewEvent(eventType, deploymentID string) *Event {
+	return &Event{
+		Type:         eventType,
+		Time:         time.Now().UTC(),
+		DeploymentID: deploymentID,
+	}
+}
@@ -0,0 +1,78 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package events
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/deployments/resources/deployments"
+)
+
+// Background jobs delivering single event
+const (
+	JobTypePublish      = "deployment_event"
+	JobTypeNotifyDevice = "device_notification"
+)
+
+// Publisher delivers events to the subscriber.
+type Publisher interface {
+	Publish(ctx context.Context, event *deployments.Event) error
+}
+
+// JobQueue runs work in the background, retrying failed jobs.
+type JobQueue interface {
+	Register(jobType string,
+		handler func(ctx context.Context, payload json.RawMessage) error)
+	Enqueue(ctx context.Context, jobType string, payload interface{}) error
+}
+
+// Queue publishes events asynchronously through the background job queue,
+// so that slow or failing subscriber does not affect the API and failed
+// deliveries are retried.
+type Queue struct {
+	jobs    JobQueue
+	jobType string
+}
+
+// NewQueue creates publisher queueing events for delivery by publisher.
+func NewQueue(jobs JobQueue, publisher Publisher) *Queue {
+	return NewQueueWithJobType(jobs, JobTypePublish, publisher)
+}
+
+// NewQueueWithJobType creates publisher queueing events for delivery by
+// publisher, in background jobs of the given type; each publisher needs
+// a job type of its own.
+func NewQueueWithJobType(jobs JobQueue, jobType string, publisher Publisher) *Queue {
+	jobs.Register(jobType,
+		func(ctx context.Context, payload json.RawMessage) error {
+			var event deployments.Event
+			if err := json.Unmarshal(payload, &event); err != nil {
+				return errors.Wrap(err, "decoding event")
+			}
+			return publisher.Publish(ctx, &event)
+		})
+
+	return &Queue{
+		jobs:    jobs,
+		jobType: jobType,
+	}
+}
+
+func (q *Queue) Publish(ctx context.Context, event *deployments.Event) error {
+	return q.jobs.Enqueue(ctx, q.jobType, event)
+}
@@ -0,0 +1,110 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mendersoftware/deployments/resources/deployments"
+	"github.com/mendersoftware/deployments/resources/deployments/model"
+)
+
+var (
+	_ model.EventPublisher = (*Queue)(nil)
+)
+
+type fakeJobQueue struct {
+	jobType  string
+	handler  func(ctx context.Context, payload json.RawMessage) error
+	enqueued []json.RawMessage
+}
+
+func (q *fakeJobQueue) Register(jobType string,
+	handler func(ctx context.Context, payload json.RawMessage) error) {
+	q.jobType = jobType
+	q.handler = handler
+}
+
+func (q *fakeJobQueue) Enqueue(ctx context.Context, jobType string, payload interface{}) error {
+	data, err := json.Marshal(payload)
+	q.enqueued = append(q.enqueued, data)
+	return err
+}
+
+type fakePublisher struct {
+	published []deployments.Event
+	err       error
+}
+
+func (p *fakePublisher) Publish(ctx context.Context, event *deployments.Event) error {
+	p.published = append(p.published, *event)
+	return p.err
+}
+
+func TestQueuePublish(t *testing.T) {
+
+	t.Parallel()
+
+	jobs := &fakeJobQueue{}
+	publisher := &fakePublisher{}
+	queue := NewQueue(jobs, publisher)
+	assert.Equal(t, JobTypePublish, jobs.jobType)
+
+	event := deployments.NewEvent(deployments.EventDeviceDeploymentLogAvailable, "foo")
+	event.Time = event.Time.Truncate(time.Second)
+	event.DeviceID = "bar"
+
+	assert.NoError(t, queue.Publish(context.Background(), event))
+	assert.Empty(t, publisher.published)
+	assert.Len(t, jobs.enqueued, 1)
+
+	// delivery happens in the job, its errors are returned for retry
+	publisher.err = errors.New("unexpected webhook response status: 500")
+	assert.EqualError(t, jobs.handler(context.Background(), jobs.enqueued[0]),
+		"unexpected webhook response status: 500")
+	if assert.Len(t, publisher.published, 1) {
+		assert.True(t, event.Time.Equal(publisher.published[0].Time))
+		publisher.published[0].Time = event.Time
+		assert.Equal(t, *event, publisher.published[0])
+	}
+
+	assert.Error(t, jobs.handler(context.Background(), json.RawMessage(`[]`)))
+}
+
+func TestQueueWithJobType(t *testing.T) {
+
+	t.Parallel()
+
+	jobs := &fakeJobQueue{}
+	publisher := &fakePublisher{}
+	queue := NewQueueWithJobType(jobs, JobTypeNotifyDevice, publisher)
+	assert.Equal(t, JobTypeNotifyDevice, jobs.jobType)
+
+	event := deployments.NewEvent(deployments.EventDeviceDeploymentAborted, "foo")
+	event.DeviceID = "bar"
+
+	assert.NoError(t, queue.Publish(context.Background(), event))
+	assert.Len(t, jobs.enqueued, 1)
+	assert.NoError(t, jobs.handler(context.Background(), jobs.enqueued[0]))
+	if assert.Len(t, publisher.published, 1) {
+		assert.Equal(t, "bar", publisher.published[0].DeviceID)
+	}
+}
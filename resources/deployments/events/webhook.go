@@ -0,0 +1,126 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package events
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/asaskevich/govalidator"
+	"github.com/mendersoftware/go-lib-micro/identity"
+	"github.com/mendersoftware/go-lib-micro/requestid"
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/deployments/resources/deployments"
+)
+
+const (
+	DefaultTimeout = 5 * time.Second
+)
+
+// Payload sent to the webhook.
+type Payload struct {
+	*deployments.Event
+
+	TenantID string `json:"tenant_id,omitempty"`
+
+	// Link to the device deployment log, set for log related events if
+	// management API base URL is configured
+	LogURL string `json:"log_url,omitempty"`
+}
+
+// Webhook publishes deployment events to an external HTTP endpoint.
+//
+// Every event is sent as POST request with Payload body, the endpoint is
+// expected to respond with any 2xx status.
+type Webhook struct {
+	client  *http.Client
+	uri     string
+	baseURL string
+}
+
+// NewWebhook creates webhook publisher sending events to uri. baseURL is
+// the public address of the management API
+// (e.g. https://hosted.mender.io/api/management/v1/deployments), used for
+// links in the payload; links are omitted if empty.
+func NewWebhook(uri, baseURL string, client *http.Client) (*Webhook, error) {
+	if !govalidator.IsURL(uri) {
+		return nil, errors.New("invalid webhook uri")
+	}
+
+	if baseURL != "" && !govalidator.IsURL(baseURL) {
+		return nil, errors.New("invalid management API base url")
+	}
+
+	if client == nil {
+		client = &http.Client{Timeout: DefaultTimeout}
+	}
+
+	return &Webhook{
+		client:  client,
+		uri:     uri,
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+	}, nil
+}
+
+func (w *Webhook) logURL(event *deployments.Event) string {
+	if w.baseURL == "" || event.Type != deployments.EventDeviceDeploymentLogAvailable {
+		return ""
+	}
+	return w.baseURL + "/deployments/" + event.DeploymentID +
+		"/devices/" + event.DeviceID + "/log"
+}
+
+func (w *Webhook) Publish(ctx context.Context, event *deployments.Event) error {
+	payload := Payload{
+		Event:  event,
+		LogURL: w.logURL(event),
+	}
+	if id := identity.FromContext(ctx); id != nil {
+		payload.TenantID = id.Tenant
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return errors.Wrap(err, "serializing event")
+	}
+
+	req, err := http.NewRequest(http.MethodPost, w.uri, bytes.NewReader(body))
+	if err != nil {
+		return errors.Wrap(err, "creating webhook request")
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	//propagate request id
+	if reqId := requestid.FromContext(ctx); reqId != "" {
+		req.Header.Set(requestid.RequestIdHeader, reqId)
+	}
+
+	resp, err := w.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return errors.Wrap(err, "sending webhook request")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return errors.Errorf("unexpected webhook response status: %d", resp.StatusCode)
+	}
+
+	return nil
+}
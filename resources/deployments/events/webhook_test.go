@@ -0,0 +1,126 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mendersoftware/go-lib-micro/identity"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mendersoftware/deployments/resources/deployments"
+	"github.com/mendersoftware/deployments/resources/deployments/model"
+)
+
+var (
+	_ model.EventPublisher = (*Webhook)(nil)
+)
+
+func TestNewWebhook(t *testing.T) {
+
+	t.Parallel()
+
+	_, err := NewWebhook("not an url", "", nil)
+	assert.EqualError(t, err, "invalid webhook uri")
+
+	_, err = NewWebhook("http://localhost/events", "not an url", nil)
+	assert.EqualError(t, err, "invalid management API base url")
+
+	w, err := NewWebhook("http://localhost/events", "", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, DefaultTimeout, w.client.Timeout)
+}
+
+func TestWebhookPublish(t *testing.T) {
+
+	t.Parallel()
+
+	testCases := map[string]struct {
+		Code    int
+		BaseURL string
+		Type    string
+
+		OutLogURL string
+		OutErr    error
+	}{
+		"log available": {
+			Code:      http.StatusNoContent,
+			BaseURL:   "https://hosted.mender.io/api/management/v1/deployments/",
+			Type:      deployments.EventDeviceDeploymentLogAvailable,
+			OutLogURL: "https://hosted.mender.io/api/management/v1/deployments/deployments/dep-1/devices/dev-1/log",
+		},
+		"no base url": {
+			Code: http.StatusOK,
+			Type: deployments.EventDeviceDeploymentLogAvailable,
+		},
+		"other event": {
+			Code:    http.StatusAccepted,
+			BaseURL: "https://hosted.mender.io/api/management/v1/deployments",
+			Type:    "other",
+		},
+		"endpoint failure": {
+			Code:   http.StatusBadGateway,
+			Type:   deployments.EventDeviceDeploymentLogAvailable,
+			OutErr: errors.New("unexpected webhook response status: 502"),
+		},
+	}
+
+	for name, test := range testCases {
+		t.Run(name, func(t *testing.T) {
+			var payload map[string]interface{}
+
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, http.MethodPost, r.Method)
+				assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+				assert.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+				w.WriteHeader(test.Code)
+			}))
+			defer srv.Close()
+
+			w, err := NewWebhook(srv.URL, test.BaseURL, nil)
+			assert.NoError(t, err)
+
+			ctx := identity.WithContext(context.Background(),
+				&identity.Identity{Tenant: "acme"})
+
+			event := deployments.NewEvent(test.Type, "dep-1")
+			event.DeviceID = "dev-1"
+			event.Status = deployments.DeviceDeploymentStatusFailure
+
+			err = w.Publish(ctx, event)
+			if test.OutErr != nil {
+				assert.EqualError(t, err, test.OutErr.Error())
+				return
+			}
+			assert.NoError(t, err)
+
+			assert.Equal(t, test.Type, payload["type"])
+			assert.Equal(t, "acme", payload["tenant_id"])
+			assert.Equal(t, "dep-1", payload["deployment_id"])
+			assert.Equal(t, "dev-1", payload["device_id"])
+			assert.Equal(t, deployments.DeviceDeploymentStatusFailure, payload["status"])
+			if test.OutLogURL != "" {
+				assert.Equal(t, test.OutLogURL, payload["log_url"])
+			} else {
+				assert.NotContains(t, payload, "log_url")
+			}
+		})
+	}
+}
@@ -0,0 +1,99 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package events
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/asaskevich/govalidator"
+	"github.com/mendersoftware/go-lib-micro/identity"
+	"github.com/mendersoftware/go-lib-micro/requestid"
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/deployments/resources/deployments"
+)
+
+// WorkflowInput is sent to the workflow started for a finalized deployment.
+type WorkflowInput struct {
+	*deployments.ArchivedStats
+
+	TenantID string `json:"tenant_id,omitempty"`
+}
+
+// Workflow starts a job of an external workflow engine for every
+// deployment reaching a terminal state.
+//
+// The job is started with POST request with WorkflowInput body, the engine
+// is expected to respond with any 2xx status.
+type Workflow struct {
+	client *http.Client
+	uri    string
+}
+
+// NewWorkflow creates finalization hook starting workflow jobs at uri.
+func NewWorkflow(uri string, client *http.Client) (*Workflow, error) {
+	if !govalidator.IsURL(uri) {
+		return nil, errors.New("invalid workflow uri")
+	}
+
+	if client == nil {
+		client = &http.Client{Timeout: DefaultTimeout}
+	}
+
+	return &Workflow{
+		client: client,
+		uri:    uri,
+	}, nil
+}
+
+func (w *Workflow) Finalize(ctx context.Context, deployment *deployments.Deployment) error {
+	input := WorkflowInput{
+		ArchivedStats: deployments.NewArchivedStats(deployment),
+	}
+	if id := identity.FromContext(ctx); id != nil {
+		input.TenantID = id.Tenant
+	}
+
+	body, err := json.Marshal(input)
+	if err != nil {
+		return errors.Wrap(err, "serializing workflow input")
+	}
+
+	req, err := http.NewRequest(http.MethodPost, w.uri, bytes.NewReader(body))
+	if err != nil {
+		return errors.Wrap(err, "creating workflow request")
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	//propagate request id
+	if reqId := requestid.FromContext(ctx); reqId != "" {
+		req.Header.Set(requestid.RequestIdHeader, reqId)
+	}
+
+	resp, err := w.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return errors.Wrap(err, "sending workflow request")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return errors.Errorf("unexpected workflow response status: %d", resp.StatusCode)
+	}
+
+	return nil
+}
@@ -0,0 +1,109 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mendersoftware/go-lib-micro/identity"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mendersoftware/deployments/resources/deployments"
+	"github.com/mendersoftware/deployments/resources/deployments/model"
+	. "github.com/mendersoftware/deployments/utils/pointers"
+)
+
+var (
+	_ model.FinalizeHook = (*Workflow)(nil)
+)
+
+func TestNewWorkflow(t *testing.T) {
+
+	t.Parallel()
+
+	_, err := NewWorkflow("not an url", nil)
+	assert.EqualError(t, err, "invalid workflow uri")
+
+	w, err := NewWorkflow("http://localhost/workflows/deployment_finished", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, DefaultTimeout, w.client.Timeout)
+}
+
+func TestWorkflowFinalize(t *testing.T) {
+
+	t.Parallel()
+
+	testCases := map[string]struct {
+		Code int
+
+		OutErr string
+	}{
+		"started": {
+			Code: http.StatusCreated,
+		},
+		"engine failure": {
+			Code:   http.StatusServiceUnavailable,
+			OutErr: "unexpected workflow response status: 503",
+		},
+	}
+
+	for name, test := range testCases {
+		t.Run(name, func(t *testing.T) {
+			var input map[string]interface{}
+
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, http.MethodPost, r.Method)
+				assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+				assert.NoError(t, json.NewDecoder(r.Body).Decode(&input))
+				w.WriteHeader(test.Code)
+			}))
+			defer srv.Close()
+
+			w, err := NewWorkflow(srv.URL, nil)
+			assert.NoError(t, err)
+
+			ctx := identity.WithContext(context.Background(),
+				&identity.Identity{Tenant: "acme"})
+
+			deployment := deployments.NewDeploymentFromConstructor(
+				&deployments.DeploymentConstructor{
+					Name:         StringToPointer("release 1"),
+					ArtifactName: StringToPointer("release-1"),
+				})
+			deployment.Stats = map[string]int{
+				deployments.DeviceDeploymentStatusSuccess: 3,
+			}
+
+			err = w.Finalize(ctx, deployment)
+			if test.OutErr != "" {
+				assert.EqualError(t, err, test.OutErr)
+				return
+			}
+			assert.NoError(t, err)
+
+			assert.Equal(t, "acme", input["tenant_id"])
+			assert.Equal(t, *deployment.Id, input["deployment_id"])
+			assert.Equal(t, "release 1", input["name"])
+			assert.Equal(t, "release-1", input["artifact_name"])
+			assert.Equal(t, map[string]interface{}{
+				deployments.DeviceDeploymentStatusSuccess: float64(3),
+			}, input["stats"].(map[string]interface{}))
+		})
+	}
+}
@@ -0,0 +1,91 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package deployments
+
+import (
+	"errors"
+	"time"
+
+	"github.com/asaskevich/govalidator"
+	"github.com/satori/go.uuid"
+)
+
+// Errors
+var (
+	ErrInvalidFreezePeriod = errors.New("Invalid freeze period, expected start before end")
+)
+
+// FreezePeriodConstructor represents input data needed for creating
+// new FreezePeriod
+type FreezePeriodConstructor struct {
+	// Freeze period name, required
+	Name string `json:"name" valid:"length(1|256),required" bson:"name"`
+
+	// Start of the freeze period, required
+	Start *time.Time `json:"start" valid:"required" bson:"start"`
+
+	// End of the freeze period, required
+	End *time.Time `json:"end" valid:"required" bson:"end"`
+
+	// Reason of the freeze, optional
+	Reason string `json:"reason,omitempty" valid:"length(0|1024)" bson:"reason,omitempty"`
+}
+
+// Validate checks structure according to valid tags and the time range.
+func (c *FreezePeriodConstructor) Validate() error {
+	if _, err := govalidator.ValidateStruct(c); err != nil {
+		return err
+	}
+
+	if !c.End.After(*c.Start) {
+		return ErrInvalidFreezePeriod
+	}
+
+	return nil
+}
+
+// FreezePeriod is a tenant wide time range (e.g. holidays) in which new
+// deployments cannot be created and pending deployments are not served
+// to devices.
+type FreezePeriod struct {
+	// User provided field set
+	*FreezePeriodConstructor `valid:"required"`
+
+	// Freeze period id
+	Id string `json:"id" bson:"_id"`
+
+	// Auto set on create
+	Created *time.Time `json:"created" bson:"created"`
+}
+
+// NewFreezePeriodFromConstructor creates new FreezePeriod object based on
+// constructor data.
+func NewFreezePeriodFromConstructor(constructor *FreezePeriodConstructor) *FreezePeriod {
+	now := time.Now()
+
+	return &FreezePeriod{
+		FreezePeriodConstructor: constructor,
+		Id:                      uuid.NewV4().String(),
+		Created:                 &now,
+	}
+}
+
+// Validate checks structure according to valid tags
+func (f *FreezePeriod) Validate() error {
+	if f.FreezePeriodConstructor == nil {
+		return ErrInvalidFreezePeriod
+	}
+	return f.FreezePeriodConstructor.Validate()
+}
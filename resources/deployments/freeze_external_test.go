@@ -0,0 +1,86 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package deployments_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	. "github.com/mendersoftware/deployments/resources/deployments"
+)
+
+func TestFreezePeriodConstructorValidate(t *testing.T) {
+
+	t.Parallel()
+
+	start := time.Date(2018, 12, 20, 0, 0, 0, 0, time.UTC)
+	end := start.Add(14 * 24 * time.Hour)
+
+	testCases := []struct {
+		InputConstructor FreezePeriodConstructor
+		OutputError      error
+	}{
+		{
+			InputConstructor: FreezePeriodConstructor{
+				Name:   "holidays",
+				Start:  &start,
+				End:    &end,
+				Reason: "nobody on call",
+			},
+		},
+		{
+			InputConstructor: FreezePeriodConstructor{
+				Start: &start,
+				End:   &end,
+			},
+			OutputError: errors.New("Name: non zero value required;"),
+		},
+		{
+			InputConstructor: FreezePeriodConstructor{
+				Name:  "holidays",
+				Start: &start,
+			},
+			OutputError: errors.New("End: non zero value required;"),
+		},
+		{
+			InputConstructor: FreezePeriodConstructor{
+				Name:  "holidays",
+				Start: &end,
+				End:   &start,
+			},
+			OutputError: ErrInvalidFreezePeriod,
+		},
+		{
+			InputConstructor: FreezePeriodConstructor{
+				Name:  "holidays",
+				Start: &start,
+				End:   &start,
+			},
+			OutputError: ErrInvalidFreezePeriod,
+		},
+	}
+
+	for _, test := range testCases {
+		err := test.InputConstructor.Validate()
+		if test.OutputError != nil {
+			assert.EqualError(t, err, test.OutputError.Error())
+		} else {
+			assert.NoError(t, err)
+		}
+	}
+}
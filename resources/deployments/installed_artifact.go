@@ -0,0 +1,108 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package deployments
+
+import (
+	"errors"
+
+	"github.com/asaskevich/govalidator"
+
+	"github.com/mendersoftware/deployments/resources/images"
+)
+
+// Errors
+var (
+	ErrInvalidMinVersion   = errors.New("Invalid minimum version")
+	ErrInvalidMaxVersion   = errors.New("Invalid maximum version")
+	ErrEmptyVersionRange   = errors.New("Minimum version must precede maximum version")
+	ErrNoInstalledArtifact = errors.New("No device has matching artifact installed")
+)
+
+// InstalledArtifact is the artifact a device last reported as installed
+// when checking for updates.
+type InstalledArtifact struct {
+	DeviceID string `json:"device_id" valid:"required" bson:"_id"`
+
+	ArtifactName string `json:"artifact_name" valid:"required" bson:"artifact_name"`
+
+	DeviceType string `json:"device_type" valid:"required" bson:"device_type"`
+}
+
+// Validate checks structure according to valid tags.
+func (a *InstalledArtifact) Validate() error {
+	_, err := govalidator.ValidateStruct(a)
+	return err
+}
+
+// InstalledArtifactFilter selects devices of a deployment by the artifact
+// installed on them. Without version range the name has to match exactly,
+// otherwise it is a name prefix and the version in the name has to be
+// within the range.
+type InstalledArtifactFilter struct {
+	// Artifact name or name prefix, e.g. "app-"
+	Name string `json:"name" bson:"name"`
+
+	// Inclusive lower bound of the version, optional
+	MinVersion string `json:"min_version,omitempty" bson:"min_version,omitempty"`
+
+	// Exclusive upper bound of the version, optional
+	MaxVersion string `json:"max_version,omitempty" bson:"max_version,omitempty"`
+}
+
+// HasVersionRange returns true if the name is a prefix of versioned names.
+func (f *InstalledArtifactFilter) HasVersionRange() bool {
+	return f.MinVersion != "" || f.MaxVersion != ""
+}
+
+// validate reports each invalid field under the prefix.
+func (f *InstalledArtifactFilter) validate(verr *ValidationError, prefix string) {
+	validateName(verr, prefix+".name", &f.Name)
+
+	min, max := f.versionRange()
+	if f.MinVersion != "" && min == nil {
+		verr.Add(prefix+".min_version", ValidationCodeInvalid, ErrInvalidMinVersion.Error())
+	}
+	if f.MaxVersion != "" && max == nil {
+		verr.Add(prefix+".max_version", ValidationCodeInvalid, ErrInvalidMaxVersion.Error())
+	}
+	if min != nil && max != nil && min.Compare(max) >= 0 {
+		verr.Add(prefix+".max_version", ValidationCodeInvalid, ErrEmptyVersionRange.Error())
+	}
+}
+
+// versionRange returns parsed bounds of the range, nil if not set or
+// invalid.
+func (f *InstalledArtifactFilter) versionRange() (min, max *images.Version) {
+	if f.MinVersion != "" {
+		min, _ = images.ParseVersion(f.MinVersion)
+	}
+	if f.MaxVersion != "" {
+		max, _ = images.ParseVersion(f.MaxVersion)
+	}
+	return min, max
+}
+
+// InRange returns true if the version is within the version range of the
+// filter.
+func (f *InstalledArtifactFilter) InRange(version *images.Version) bool {
+	min, max := f.versionRange()
+	if min != nil && version.Compare(min) < 0 {
+		return false
+	}
+	if max != nil && version.Compare(max) >= 0 {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,147 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package deployments_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	. "github.com/mendersoftware/deployments/resources/deployments"
+	"github.com/mendersoftware/deployments/resources/images"
+	. "github.com/mendersoftware/deployments/utils/pointers"
+)
+
+func TestInstalledArtifactValidate(t *testing.T) {
+
+	t.Parallel()
+
+	assert.NoError(t, (&InstalledArtifact{
+		DeviceID:     "device-1",
+		ArtifactName: "app-1.2.0",
+		DeviceType:   "rpi4",
+	}).Validate())
+	assert.Error(t, (&InstalledArtifact{
+		DeviceID:   "device-1",
+		DeviceType: "rpi4",
+	}).Validate())
+}
+
+func TestDeploymentConstructorValidateInstalledArtifact(t *testing.T) {
+
+	t.Parallel()
+
+	testCases := map[string]struct {
+		InputFilter *InstalledArtifactFilter
+
+		OutputFields []FieldError
+	}{
+		"name": {
+			InputFilter: &InstalledArtifactFilter{Name: "app-1.2.0"},
+		},
+		"version range": {
+			InputFilter: &InstalledArtifactFilter{
+				Name:       "app-",
+				MinVersion: "1.2",
+				MaxVersion: "1.3.0-rc1",
+			},
+		},
+		"missing name": {
+			InputFilter: &InstalledArtifactFilter{MinVersion: "1.2.0"},
+
+			OutputFields: []FieldError{{
+				Field:   "installed_artifact.name",
+				Code:    ValidationCodeRequired,
+				Message: "value is required",
+			}},
+		},
+		"invalid versions": {
+			InputFilter: &InstalledArtifactFilter{
+				Name:       "app-",
+				MinVersion: "one",
+				MaxVersion: "1.2.3.4",
+			},
+
+			OutputFields: []FieldError{
+				{
+					Field:   "installed_artifact.min_version",
+					Code:    ValidationCodeInvalid,
+					Message: ErrInvalidMinVersion.Error(),
+				},
+				{
+					Field:   "installed_artifact.max_version",
+					Code:    ValidationCodeInvalid,
+					Message: ErrInvalidMaxVersion.Error(),
+				},
+			},
+		},
+		"empty version range": {
+			InputFilter: &InstalledArtifactFilter{
+				Name:       "app-",
+				MinVersion: "1.2.0",
+				MaxVersion: "1.2",
+			},
+
+			OutputFields: []FieldError{{
+				Field:   "installed_artifact.max_version",
+				Code:    ValidationCodeInvalid,
+				Message: ErrEmptyVersionRange.Error(),
+			}},
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			// devices are not required with installed artifact filter
+			constructor := &DeploymentConstructor{
+				Name:              StringToPointer("Emergency patch"),
+				ArtifactName:      StringToPointer("app-1.3.0"),
+				InstalledArtifact: testCase.InputFilter,
+			}
+
+			err := constructor.Validate()
+			if testCase.OutputFields == nil {
+				assert.NoError(t, err)
+				return
+			}
+			if assert.IsType(t, &ValidationError{}, err) {
+				assert.Equal(t, testCase.OutputFields, err.(*ValidationError).Fields)
+			}
+		})
+	}
+}
+
+func TestInstalledArtifactFilterInRange(t *testing.T) {
+
+	t.Parallel()
+
+	filter := &InstalledArtifactFilter{
+		Name:       "app-",
+		MinVersion: "1.2.0",
+		MaxVersion: "1.3.0",
+	}
+	for version, inRange := range map[string]bool{
+		"1.1.9":      false,
+		"1.2.0-rc1":  false,
+		"1.2.0":      true,
+		"1.2.15":     true,
+		"1.3.0-beta": true,
+		"1.3.0":      false,
+	} {
+		v, err := images.ParseVersion(version)
+		assert.NoError(t, err)
+		assert.Equal(t, inRange, filter.InRange(v), version)
+	}
+}
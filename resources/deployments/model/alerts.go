@@ -0,0 +1,244 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"context"
+	"time"
+
+	"github.com/mendersoftware/go-lib-micro/identity"
+	"github.com/mendersoftware/go-lib-micro/log"
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/deployments/resources/deployments"
+	"github.com/mendersoftware/deployments/resources/deployments/controller"
+)
+
+// TenantLister lists tenants with databases of their own.
+type TenantLister interface {
+	ListTenants(ctx context.Context) ([]string, error)
+}
+
+// CreateAlert stores new alert checked on active deployments.
+func (d *DeploymentsModel) CreateAlert(ctx context.Context,
+	constructor *deployments.AlertConstructor) (string, error) {
+
+	if constructor == nil {
+		return "", controller.ErrModelMissingInput
+	}
+
+	if err := constructor.Validate(); err != nil {
+		return "", errors.Wrap(err, "Validating alert")
+	}
+
+	alert := deployments.NewAlertFromConstructor(constructor)
+	if err := d.alertsStorage.InsertAlert(ctx, alert); err != nil {
+		return "", errors.Wrap(err, "Storing alert")
+	}
+
+	return alert.Id, nil
+}
+
+// GetAlerts lists alerts, newest first.
+func (d *DeploymentsModel) GetAlerts(ctx context.Context) ([]*deployments.Alert, error) {
+	alerts, err := d.alertsStorage.FindAlerts(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "Searching for alerts")
+	}
+
+	if alerts == nil {
+		alerts = []*deployments.Alert{}
+	}
+
+	return alerts, nil
+}
+
+// DeleteAlert removes the alert; states of the alert are cleaned up on
+// the next evaluation.
+func (d *DeploymentsModel) DeleteAlert(ctx context.Context, id string) error {
+	err := d.alertsStorage.DeleteAlert(ctx, id)
+	if deployments.IsStoreError(err, deployments.ErrStorageNotFound) {
+		return controller.ErrModelAlertNotFound
+	}
+	if err != nil {
+		return errors.Wrap(err, "Removing alert")
+	}
+
+	return nil
+}
+
+// EvaluateAlerts checks alerts of the tenant in context on its active
+// deployments. Alert fires once per deployment, when its condition held
+// for the duration of the alert; it may fire again if the condition stops
+// and then starts holding again. Fired alerts are published as deployment
+// events.
+func (d *DeploymentsModel) EvaluateAlerts(ctx context.Context) error {
+	if d.eventPublisher == nil {
+		return nil
+	}
+
+	alerts, err := d.alertsStorage.FindAlerts(ctx)
+	if err != nil {
+		return errors.Wrap(err, "Searching for alerts")
+	}
+
+	states, err := d.alertsStorage.FindAlertStates(ctx)
+	if err != nil {
+		return errors.Wrap(err, "Searching for alert states")
+	}
+
+	if len(alerts) == 0 && len(states) == 0 {
+		return nil
+	}
+
+	var active []*deployments.Deployment
+	if len(alerts) > 0 {
+		for _, status := range []deployments.StatusQuery{
+			deployments.StatusQueryInProgress,
+			deployments.StatusQueryPending,
+		} {
+			list, err := d.deploymentsStorage.Find(ctx, deployments.Query{Status: status})
+			if err != nil {
+				return errors.Wrap(err, "Searching for active deployments")
+			}
+			active = append(active, list...)
+		}
+	}
+
+	stored := make(map[string]*deployments.AlertState, len(states))
+	for _, state := range states {
+		stored[state.Id] = state
+	}
+
+	now := time.Now()
+	holding := map[string]bool{}
+	for _, alert := range alerts {
+		for _, deployment := range active {
+			if !alert.Holds(deployment) {
+				continue
+			}
+
+			since := now
+			if alert.Condition == deployments.AlertConditionNotFinished &&
+				deployment.Created != nil {
+				since = *deployment.Created
+			}
+			state := deployments.NewAlertState(alert.Id, *deployment.Id, since)
+			holding[state.Id] = true
+
+			if s, ok := stored[state.Id]; ok {
+				state = s
+			} else if err := d.alertsStorage.InsertAlertState(ctx, state); err != nil {
+				return errors.Wrap(err, "Storing alert state")
+			}
+
+			if state.Fired != nil ||
+				now.Sub(state.Since) < time.Duration(alert.Duration)*time.Minute {
+				continue
+			}
+
+			if err := d.fireAlert(ctx, alert, deployment, state, now); err != nil {
+				return err
+			}
+		}
+	}
+
+	// conditions which stopped holding, of finished deployments and of
+	// removed alerts
+	var stale []string
+	for id := range stored {
+		if !holding[id] {
+			stale = append(stale, id)
+		}
+	}
+	if err := d.alertsStorage.DeleteAlertStates(ctx, stale); err != nil {
+		return errors.Wrap(err, "Removing alert states")
+	}
+
+	return nil
+}
+
+// fireAlert publishes the alert event, unless the alert was fired already
+// e.g. by another instance of the service.
+func (d *DeploymentsModel) fireAlert(ctx context.Context, alert *deployments.Alert,
+	deployment *deployments.Deployment, state *deployments.AlertState, now time.Time) error {
+
+	fired, err := d.alertsStorage.MarkAlertStateFired(ctx, state.Id, now)
+	if err != nil {
+		return errors.Wrap(err, "Updating alert state")
+	}
+	if !fired {
+		return nil
+	}
+
+	event := deployments.NewEvent(deployments.EventDeploymentAlert, *deployment.Id)
+	event.Status = deployment.GetStatus()
+	event.AlertID = alert.Id
+	event.AlertName = alert.Name
+
+	if err := d.eventPublisher.Publish(ctx, event); err != nil {
+		return errors.Wrap(err, "Publishing alert")
+	}
+
+	return nil
+}
+
+// StartAlerts evaluates alerts of all tenants every interval until the
+// context is done.
+func (d *DeploymentsModel) StartAlerts(ctx context.Context, interval time.Duration,
+	tenants TenantLister) {
+
+	go func() {
+		l := log.FromContext(ctx)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := d.evaluateTenantsAlerts(ctx, tenants); err != nil {
+					l.Errorf("evaluating alerts: %s", err.Error())
+				}
+			}
+		}
+	}()
+}
+
+func (d *DeploymentsModel) evaluateTenantsAlerts(ctx context.Context,
+	tenants TenantLister) error {
+
+	ids, err := tenants.ListTenants(ctx)
+	if err != nil {
+		return errors.Wrap(err, "listing tenants")
+	}
+
+	// default database is used when multi-tenancy is off; failure of
+	// a single tenant does not stop evaluation of the others
+	l := log.FromContext(ctx)
+	for _, tenantID := range append([]string{""}, ids...) {
+		tctx := ctx
+		if tenantID != "" {
+			tctx = identity.WithContext(ctx, &identity.Identity{Tenant: tenantID})
+		}
+
+		if err := d.EvaluateAlerts(tctx); err != nil {
+			l.Errorf("evaluating alerts of tenant %s: %s", tenantID, err.Error())
+		}
+	}
+
+	return nil
+}
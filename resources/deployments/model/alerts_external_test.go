@@ -0,0 +1,362 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/mendersoftware/deployments/resources/deployments"
+	"github.com/mendersoftware/deployments/resources/deployments/controller"
+	. "github.com/mendersoftware/deployments/resources/deployments/model"
+	"github.com/mendersoftware/deployments/resources/deployments/model/mocks"
+	h "github.com/mendersoftware/deployments/utils/testing"
+)
+
+const (
+	alertID           = "c3d2f4d1-b7b6-4ad4-8e4c-2d2f3c1b9d03"
+	alertDeploymentID = "a3d2f4d1-b7b6-4ad4-8e4c-2d2f3c1b9d01"
+)
+
+func TestDeploymentModelCreateAlert(t *testing.T) {
+
+	testCases := map[string]struct {
+		InputConstructor *deployments.AlertConstructor
+		InputInsertError error
+
+		OutputError string
+	}{
+		"ok": {
+			InputConstructor: &deployments.AlertConstructor{
+				Name:      "failures",
+				Condition: deployments.AlertConditionFailureRate,
+				Threshold: 10,
+				Duration:  15,
+			},
+		},
+		"missing input": {
+			OutputError: controller.ErrModelMissingInput.Error(),
+		},
+		"invalid input": {
+			InputConstructor: &deployments.AlertConstructor{
+				Name: "failures",
+			},
+			OutputError: "Validating alert: condition: value is required;",
+		},
+		"storage error": {
+			InputConstructor: &deployments.AlertConstructor{
+				Name:      "stalled",
+				Condition: deployments.AlertConditionNotFinished,
+				Duration:  60,
+			},
+			InputInsertError: errors.New("connection failed"),
+			OutputError:      "Storing alert: connection failed",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+
+			alertsStorage := new(mocks.AlertsStorage)
+			alertsStorage.On("InsertAlert", h.ContextMatcher(),
+				mock.AnythingOfType("*deployments.Alert")).
+				Return(tc.InputInsertError)
+
+			model := NewDeploymentModel(DeploymentsModelConfig{
+				AlertsStorage: alertsStorage,
+			})
+
+			id, err := model.CreateAlert(context.Background(), tc.InputConstructor)
+			if tc.OutputError != "" {
+				assert.EqualError(t, err, tc.OutputError)
+				if tc.InputInsertError == nil {
+					alertsStorage.AssertNotCalled(t, "InsertAlert",
+						mock.Anything, mock.Anything)
+				}
+			} else {
+				assert.NoError(t, err)
+				assert.NotEmpty(t, id)
+			}
+		})
+	}
+}
+
+func TestDeploymentModelGetAlerts(t *testing.T) {
+
+	alertsStorage := new(mocks.AlertsStorage)
+	alertsStorage.On("FindAlerts", h.ContextMatcher()).Return(nil, nil).Once()
+	alertsStorage.On("FindAlerts", h.ContextMatcher()).
+		Return(nil, errors.New("connection failed")).Once()
+
+	model := NewDeploymentModel(DeploymentsModelConfig{
+		AlertsStorage: alertsStorage,
+	})
+
+	alerts, err := model.GetAlerts(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []*deployments.Alert{}, alerts)
+
+	_, err = model.GetAlerts(context.Background())
+	assert.EqualError(t, err, "Searching for alerts: connection failed")
+}
+
+func TestDeploymentModelDeleteAlert(t *testing.T) {
+
+	testCases := map[string]struct {
+		InputDeleteError error
+
+		OutputError error
+	}{
+		"ok": {},
+		"not found": {
+			InputDeleteError: deployments.NewStoreError("DeleteAlert", "alerts",
+				deployments.ErrStorageNotFound, alertID),
+			OutputError: controller.ErrModelAlertNotFound,
+		},
+		"storage error": {
+			InputDeleteError: errors.New("connection failed"),
+			OutputError:      errors.New("Removing alert: connection failed"),
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+
+			alertsStorage := new(mocks.AlertsStorage)
+			alertsStorage.On("DeleteAlert", h.ContextMatcher(), alertID).
+				Return(tc.InputDeleteError)
+
+			model := NewDeploymentModel(DeploymentsModelConfig{
+				AlertsStorage: alertsStorage,
+			})
+
+			err := model.DeleteAlert(context.Background(), alertID)
+			if tc.OutputError != nil {
+				assert.EqualError(t, err, tc.OutputError.Error())
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestDeploymentModelEvaluateAlerts(t *testing.T) {
+
+	id := alertDeploymentID
+	stateID := alertID + ":" + alertDeploymentID
+	now := time.Now()
+	hourAgo := now.Add(-time.Hour)
+	dayAgo := now.Add(-24 * time.Hour)
+
+	failing := &deployments.Deployment{
+		Id:      &id,
+		Created: &dayAgo,
+		Stats: map[string]int{
+			deployments.DeviceDeploymentStatusPending: 5,
+			deployments.DeviceDeploymentStatusFailure: 5,
+			deployments.DeviceDeploymentStatusSuccess: 5,
+		},
+	}
+	healthy := &deployments.Deployment{
+		Id:      &id,
+		Created: &hourAgo,
+		Stats: map[string]int{
+			deployments.DeviceDeploymentStatusPending: 5,
+			deployments.DeviceDeploymentStatusSuccess: 5,
+		},
+	}
+
+	failureRate := &deployments.Alert{
+		Id: alertID,
+		AlertConstructor: &deployments.AlertConstructor{
+			Name:      "failures",
+			Condition: deployments.AlertConditionFailureRate,
+			Threshold: 10,
+			Duration:  15,
+		},
+	}
+	notFinished := &deployments.Alert{
+		Id: alertID,
+		AlertConstructor: &deployments.AlertConstructor{
+			Name:      "stalled",
+			Condition: deployments.AlertConditionNotFinished,
+			Duration:  2 * 60,
+		},
+	}
+
+	testCases := map[string]struct {
+		NoPublisher bool
+		Alerts      []*deployments.Alert
+		States      []*deployments.AlertState
+		Active      []*deployments.Deployment
+		FindError   error
+		MarkFired   bool
+
+		InsertedSince *time.Time
+		Fired         bool
+		Deleted       []string
+		OutputError   string
+	}{
+		"no alerts": {},
+		"no publisher": {
+			NoPublisher: true,
+			Alerts:      []*deployments.Alert{failureRate},
+		},
+		"failure rate, starts holding": {
+			Alerts:        []*deployments.Alert{failureRate},
+			Active:        []*deployments.Deployment{failing},
+			InsertedSince: &now,
+		},
+		"failure rate, holds for long enough": {
+			Alerts: []*deployments.Alert{failureRate},
+			States: []*deployments.AlertState{
+				deployments.NewAlertState(alertID, id, hourAgo),
+			},
+			Active:    []*deployments.Deployment{failing},
+			MarkFired: true,
+			Fired:     true,
+		},
+		"failure rate, fired by another instance": {
+			Alerts: []*deployments.Alert{failureRate},
+			States: []*deployments.AlertState{
+				deployments.NewAlertState(alertID, id, hourAgo),
+			},
+			Active: []*deployments.Deployment{failing},
+		},
+		"failure rate, already fired": {
+			Alerts: []*deployments.Alert{failureRate},
+			States: []*deployments.AlertState{{
+				Id:           stateID,
+				AlertID:      alertID,
+				DeploymentID: id,
+				Since:        dayAgo,
+				Fired:        &hourAgo,
+			}},
+			Active: []*deployments.Deployment{failing},
+		},
+		"failure rate, stopped holding": {
+			Alerts: []*deployments.Alert{failureRate},
+			States: []*deployments.AlertState{
+				deployments.NewAlertState(alertID, id, hourAgo),
+			},
+			Active:  []*deployments.Deployment{healthy},
+			Deleted: []string{stateID},
+		},
+		"not finished, within duration": {
+			Alerts:        []*deployments.Alert{notFinished},
+			Active:        []*deployments.Deployment{healthy},
+			InsertedSince: &hourAgo,
+		},
+		"not finished, past duration": {
+			Alerts:        []*deployments.Alert{notFinished},
+			Active:        []*deployments.Deployment{failing},
+			InsertedSince: &dayAgo,
+			MarkFired:     true,
+			Fired:         true,
+		},
+		"alert removed": {
+			States: []*deployments.AlertState{
+				deployments.NewAlertState(alertID, id, hourAgo),
+			},
+			Deleted: []string{stateID},
+		},
+		"deployments error": {
+			Alerts:      []*deployments.Alert{failureRate},
+			FindError:   errors.New("connection failed"),
+			OutputError: "Searching for active deployments: connection failed",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+
+			deploymentsStorage := new(mocks.DeploymentsStorage)
+			deploymentsStorage.On("Find", h.ContextMatcher(), deployments.Query{
+				Status: deployments.StatusQueryInProgress,
+			}).Return(tc.Active, tc.FindError)
+			deploymentsStorage.On("Find", h.ContextMatcher(), deployments.Query{
+				Status: deployments.StatusQueryPending,
+			}).Return(nil, nil)
+
+			alertsStorage := new(mocks.AlertsStorage)
+			alertsStorage.On("FindAlerts", h.ContextMatcher()).Return(tc.Alerts, nil)
+			alertsStorage.On("FindAlertStates", h.ContextMatcher()).Return(tc.States, nil)
+			alertsStorage.On("InsertAlertState", h.ContextMatcher(),
+				mock.AnythingOfType("*deployments.AlertState")).Return(nil)
+			alertsStorage.On("MarkAlertStateFired", h.ContextMatcher(), stateID,
+				mock.AnythingOfType("time.Time")).Return(tc.MarkFired, nil)
+			alertsStorage.On("DeleteAlertStates", h.ContextMatcher(),
+				mock.AnythingOfType("[]string")).Return(nil)
+
+			publisher := new(mocks.EventPublisher)
+			publisher.On("Publish", h.ContextMatcher(),
+				mock.AnythingOfType("*deployments.Event")).Return(nil)
+
+			config := DeploymentsModelConfig{
+				DeploymentsStorage: deploymentsStorage,
+				AlertsStorage:      alertsStorage,
+			}
+			if !tc.NoPublisher {
+				config.EventPublisher = publisher
+			}
+			model := NewDeploymentModel(config)
+
+			err := model.EvaluateAlerts(context.Background())
+			if tc.OutputError != "" {
+				assert.EqualError(t, err, tc.OutputError)
+				return
+			}
+			assert.NoError(t, err)
+
+			if tc.InsertedSince != nil {
+				alertsStorage.AssertCalled(t, "InsertAlertState", h.ContextMatcher(),
+					mock.MatchedBy(func(s *deployments.AlertState) bool {
+						return s.Id == stateID &&
+							!s.Since.Before(*tc.InsertedSince) &&
+							s.Since.Sub(*tc.InsertedSince) < time.Minute
+					}))
+			} else {
+				alertsStorage.AssertNotCalled(t, "InsertAlertState",
+					mock.Anything, mock.Anything)
+			}
+
+			if tc.Fired {
+				publisher.AssertCalled(t, "Publish", h.ContextMatcher(),
+					mock.MatchedBy(func(e *deployments.Event) bool {
+						return e.Type == deployments.EventDeploymentAlert &&
+							e.DeploymentID == id &&
+							e.AlertID == alertID &&
+							e.AlertName == tc.Alerts[0].Name &&
+							e.Status == deployments.DeploymentStatusInProgress
+					}))
+			} else {
+				publisher.AssertNotCalled(t, "Publish", mock.Anything, mock.Anything)
+			}
+
+			if len(tc.Deleted) > 0 {
+				alertsStorage.AssertCalled(t, "DeleteAlertStates", h.ContextMatcher(),
+					tc.Deleted)
+			} else if !tc.NoPublisher && (len(tc.Alerts) > 0 || len(tc.States) > 0) {
+				alertsStorage.AssertCalled(t, "DeleteAlertStates", h.ContextMatcher(),
+					[]string(nil))
+			}
+		})
+	}
+}
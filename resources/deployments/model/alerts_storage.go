@@ -0,0 +1,36 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"context"
+	"time"
+
+	"github.com/mendersoftware/deployments/resources/deployments"
+)
+
+// Deployment alerts storage
+type AlertsStorage interface {
+	InsertAlert(ctx context.Context, alert *deployments.Alert) error
+	FindAlerts(ctx context.Context) ([]*deployments.Alert, error)
+	DeleteAlert(ctx context.Context, id string) error
+
+	FindAlertStates(ctx context.Context) ([]*deployments.AlertState, error)
+	// InsertAlertState ignores the state if already stored
+	InsertAlertState(ctx context.Context, state *deployments.AlertState) error
+	// MarkAlertStateFired returns false if the alert already fired
+	MarkAlertStateFired(ctx context.Context, id string, when time.Time) (bool, error)
+	DeleteAlertStates(ctx context.Context, ids []string) error
+}
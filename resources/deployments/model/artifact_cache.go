@@ -0,0 +1,111 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/mendersoftware/go-lib-micro/identity"
+
+	"github.com/mendersoftware/deployments/resources/images"
+)
+
+// artifactCache keeps artifacts resolved for device deployments together
+// with their download links, so that devices of the same type updated by
+// the same deployment share a single artifact lookup and link signing.
+type artifactCache struct {
+	ttl time.Duration
+
+	lock    sync.Mutex
+	entries map[string]cachedArtifact
+}
+
+type cachedArtifact struct {
+	artifact *images.SoftwareImage
+	link     *images.Link
+	expires  time.Time
+}
+
+// newArtifactCache creates cache keeping entries for ttl, 0 disables caching.
+func newArtifactCache(ttl time.Duration) *artifactCache {
+	return &artifactCache{
+		ttl:     ttl,
+		entries: make(map[string]cachedArtifact),
+	}
+}
+
+func artifactCacheKey(ctx context.Context, deploymentID, deviceType string) string {
+	var tenant string
+	if id := identity.FromContext(ctx); id != nil {
+		tenant = id.Tenant
+	}
+	return tenant + "/" + deploymentID + "/" + deviceType
+}
+
+// get returns cached artifact of the deployment for the device type, or nil.
+func (c *artifactCache) get(ctx context.Context,
+	deploymentID, deviceType string) *cachedArtifact {
+
+	if c.ttl <= 0 {
+		return nil
+	}
+
+	key := artifactCacheKey(ctx, deploymentID, deviceType)
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok {
+		return nil
+	}
+	if time.Now().After(entry.expires) {
+		delete(c.entries, key)
+		return nil
+	}
+	return &entry
+}
+
+// store caches artifact and its download link. Links expiring before
+// the cache entry would are not cached.
+func (c *artifactCache) store(ctx context.Context, deploymentID, deviceType string,
+	artifact *images.SoftwareImage, link *images.Link) {
+
+	if c.ttl <= 0 {
+		return
+	}
+
+	now := time.Now()
+	expires := now.Add(c.ttl)
+	if expires.After(link.Expire) {
+		return
+	}
+
+	key := artifactCacheKey(ctx, deploymentID, deviceType)
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	// drop expired entries to keep the cache bounded by active deployments
+	for k, entry := range c.entries {
+		if now.After(entry.expires) {
+			delete(c.entries, k)
+		}
+	}
+
+	c.entries[key] = cachedArtifact{artifact: artifact, link: link, expires: expires}
+}
@@ -0,0 +1,26 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"context"
+)
+
+// ArtifactRestorer brings archived artifact files back from cold storage.
+type ArtifactRestorer interface {
+	// Restore requests temporary copy of the archived object, available for given number of days.
+	// Returns true if the object can be downloaded already.
+	Restore(ctx context.Context, objectId string, days int64) (bool, error)
+}
@@ -0,0 +1,186 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/deployments/resources/deployments"
+	"github.com/mendersoftware/deployments/resources/deployments/controller"
+)
+
+// CreateCampaign stores new campaign grouping existing deployments.
+func (d *DeploymentsModel) CreateCampaign(ctx context.Context,
+	constructor *deployments.CampaignConstructor) (string, error) {
+
+	if constructor == nil {
+		return "", controller.ErrModelMissingInput
+	}
+
+	if err := constructor.Validate(); err != nil {
+		return "", errors.Wrap(err, "Validating campaign")
+	}
+
+	verr := &deployments.ValidationError{}
+	for i, id := range constructor.Deployments {
+		deployment, err := d.deploymentsStorage.FindByID(ctx, id)
+		if err != nil {
+			return "", errors.Wrap(err, "Searching for deployment")
+		}
+		if deployment == nil {
+			verr.Add(fmt.Sprintf("deployments[%d]", i), deployments.ValidationCodeNotFound,
+				controller.ErrModelDeploymentNotFound.Error())
+		}
+	}
+	if err := verr.ErrorOrNil(); err != nil {
+		return "", err
+	}
+
+	campaign := deployments.NewCampaignFromConstructor(constructor)
+	if err := d.campaignsStorage.InsertCampaign(ctx, campaign); err != nil {
+		return "", errors.Wrap(err, "Storing campaign")
+	}
+
+	return campaign.Id, nil
+}
+
+// GetCampaigns lists campaigns, newest first.
+func (d *DeploymentsModel) GetCampaigns(ctx context.Context) ([]*deployments.Campaign, error) {
+	campaigns, err := d.campaignsStorage.FindCampaigns(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "Searching for campaigns")
+	}
+
+	if campaigns == nil {
+		campaigns = []*deployments.Campaign{}
+	}
+
+	return campaigns, nil
+}
+
+// GetCampaign returns the campaign, nil if not found.
+func (d *DeploymentsModel) GetCampaign(ctx context.Context,
+	id string) (*deployments.Campaign, error) {
+
+	campaign, err := d.campaignsStorage.FindCampaignByID(ctx, id)
+	if err != nil {
+		return nil, errors.Wrap(err, "Searching for campaign")
+	}
+
+	return campaign, nil
+}
+
+// GetCampaignStats sums device status counters of the campaign deployments.
+// Returns nil if the campaign is not found.
+func (d *DeploymentsModel) GetCampaignStats(ctx context.Context,
+	id string) (deployments.Stats, error) {
+
+	campaign, err := d.GetCampaign(ctx, id)
+	if err != nil || campaign == nil {
+		return nil, err
+	}
+
+	stats := deployments.NewDeviceDeploymentStats()
+	for _, deploymentID := range campaign.Deployments {
+		deployment, err := d.deploymentsStorage.FindByID(ctx, deploymentID)
+		if err != nil {
+			return nil, errors.Wrap(err, "Searching for deployment")
+		}
+		// removed deployments no longer count
+		if deployment == nil {
+			continue
+		}
+		for status, count := range deployment.Stats {
+			stats[status] += count
+		}
+	}
+
+	return stats, nil
+}
+
+// getActiveCampaign returns the campaign which can be paused or aborted.
+func (d *DeploymentsModel) getActiveCampaign(ctx context.Context,
+	id string) (*deployments.Campaign, error) {
+
+	campaign, err := d.GetCampaign(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if campaign == nil {
+		return nil, controller.ErrModelCampaignNotFound
+	}
+	if campaign.Status == deployments.CampaignStatusAborted {
+		return nil, controller.ErrCampaignAborted
+	}
+
+	return campaign, nil
+}
+
+// PauseCampaign pauses or resumes unfinished deployments of the campaign.
+// Devices are not served deployments of paused campaigns.
+func (d *DeploymentsModel) PauseCampaign(ctx context.Context, id string, paused bool) error {
+	campaign, err := d.getActiveCampaign(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if err := d.deploymentsStorage.SetPaused(ctx, campaign.Deployments, paused); err != nil {
+		return errors.Wrap(err, "Pausing campaign deployments")
+	}
+
+	status := deployments.CampaignStatusActive
+	if paused {
+		status = deployments.CampaignStatusPaused
+	}
+	if err := d.campaignsStorage.SetCampaignStatus(ctx, id, status); err != nil {
+		return errors.Wrap(err, "Updating campaign status")
+	}
+
+	return nil
+}
+
+// AbortCampaign aborts all unfinished deployments of the campaign.
+func (d *DeploymentsModel) AbortCampaign(ctx context.Context, id string,
+	abort *deployments.AbortInfo) error {
+
+	campaign, err := d.getActiveCampaign(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	for _, deploymentID := range campaign.Deployments {
+		err := d.AbortDeployment(ctx, deploymentID, abort)
+		switch err {
+		case nil, controller.ErrDeploymentAlreadyFinished,
+			controller.ErrModelDeploymentNotFound:
+		default:
+			return errors.Wrapf(err, "Aborting deployment %s", deploymentID)
+		}
+	}
+
+	if err := d.deploymentsStorage.SetPaused(ctx, campaign.Deployments, false); err != nil {
+		return errors.Wrap(err, "Resuming campaign deployments")
+	}
+
+	if err := d.campaignsStorage.SetCampaignStatus(ctx, id,
+		deployments.CampaignStatusAborted); err != nil {
+		return errors.Wrap(err, "Updating campaign status")
+	}
+
+	return nil
+}
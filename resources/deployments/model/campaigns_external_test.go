@@ -0,0 +1,308 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/mendersoftware/deployments/resources/deployments"
+	"github.com/mendersoftware/deployments/resources/deployments/controller"
+	. "github.com/mendersoftware/deployments/resources/deployments/model"
+	"github.com/mendersoftware/deployments/resources/deployments/model/mocks"
+	. "github.com/mendersoftware/deployments/utils/pointers"
+	h "github.com/mendersoftware/deployments/utils/testing"
+)
+
+const (
+	campaignDeployment1 = "a3d2f4d1-b7b6-4ad4-8e4c-2d2f3c1b9d01"
+	campaignDeployment2 = "b3d2f4d1-b7b6-4ad4-8e4c-2d2f3c1b9d02"
+)
+
+func TestDeploymentModelCreateCampaign(t *testing.T) {
+
+	testCases := map[string]struct {
+		InputConstructor *deployments.CampaignConstructor
+		InputMissing     string
+		InputInsertError error
+
+		OutputError string
+	}{
+		"ok": {
+			InputConstructor: &deployments.CampaignConstructor{
+				Name:        "release 1.2",
+				Deployments: []string{campaignDeployment1, campaignDeployment2},
+			},
+		},
+		"missing input": {
+			OutputError: controller.ErrModelMissingInput.Error(),
+		},
+		"invalid input": {
+			InputConstructor: &deployments.CampaignConstructor{
+				Name: "release 1.2",
+			},
+			OutputError: "Validating campaign: deployments: at least one deployment is required;",
+		},
+		"deployment not found": {
+			InputConstructor: &deployments.CampaignConstructor{
+				Name:        "release 1.2",
+				Deployments: []string{campaignDeployment1, campaignDeployment2},
+			},
+			InputMissing: campaignDeployment2,
+			OutputError:  "deployments[1]: Deployment not found;",
+		},
+		"storage error": {
+			InputConstructor: &deployments.CampaignConstructor{
+				Name:        "release 1.2",
+				Deployments: []string{campaignDeployment1},
+			},
+			InputInsertError: errors.New("connection failed"),
+			OutputError:      "Storing campaign: connection failed",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+
+			deploymentsStorage := new(mocks.DeploymentsStorage)
+			deploymentsStorage.On("FindByID", h.ContextMatcher(),
+				mock.MatchedBy(func(id string) bool { return id != tc.InputMissing })).
+				Return(&deployments.Deployment{}, nil)
+			deploymentsStorage.On("FindByID", h.ContextMatcher(), tc.InputMissing).
+				Return(nil, nil)
+
+			campaignsStorage := new(mocks.CampaignsStorage)
+			campaignsStorage.On("InsertCampaign", h.ContextMatcher(),
+				mock.AnythingOfType("*deployments.Campaign")).
+				Return(tc.InputInsertError)
+
+			model := NewDeploymentModel(DeploymentsModelConfig{
+				DeploymentsStorage: deploymentsStorage,
+				CampaignsStorage:   campaignsStorage,
+			})
+
+			id, err := model.CreateCampaign(context.Background(), tc.InputConstructor)
+			if tc.OutputError != "" {
+				assert.EqualError(t, err, tc.OutputError)
+				if tc.InputInsertError == nil {
+					campaignsStorage.AssertNotCalled(t, "InsertCampaign",
+						mock.Anything, mock.Anything)
+				}
+			} else {
+				assert.NoError(t, err)
+				assert.NotEmpty(t, id)
+			}
+		})
+	}
+}
+
+func TestDeploymentModelGetCampaignStats(t *testing.T) {
+
+	campaign := &deployments.Campaign{
+		CampaignConstructor: &deployments.CampaignConstructor{
+			Name:        "release 1.2",
+			Deployments: []string{campaignDeployment1, campaignDeployment2},
+		},
+		Id: validUUIDv4,
+	}
+
+	deploymentsStorage := new(mocks.DeploymentsStorage)
+	deploymentsStorage.On("FindByID", h.ContextMatcher(), campaignDeployment1).
+		Return(&deployments.Deployment{
+			Stats: deployments.Stats{
+				deployments.DeviceDeploymentStatusSuccess: 10,
+				deployments.DeviceDeploymentStatusFailure: 2,
+			},
+		}, nil)
+	// removed deployment
+	deploymentsStorage.On("FindByID", h.ContextMatcher(), campaignDeployment2).
+		Return(nil, nil)
+
+	campaignsStorage := new(mocks.CampaignsStorage)
+	campaignsStorage.On("FindCampaignByID", h.ContextMatcher(), validUUIDv4).
+		Return(campaign, nil)
+	campaignsStorage.On("FindCampaignByID", h.ContextMatcher(), "missing").
+		Return(nil, nil)
+
+	model := NewDeploymentModel(DeploymentsModelConfig{
+		DeploymentsStorage: deploymentsStorage,
+		CampaignsStorage:   campaignsStorage,
+	})
+
+	stats, err := model.GetCampaignStats(context.Background(), validUUIDv4)
+	assert.NoError(t, err)
+	expected := deployments.NewDeviceDeploymentStats()
+	expected[deployments.DeviceDeploymentStatusSuccess] = 10
+	expected[deployments.DeviceDeploymentStatusFailure] = 2
+	assert.Equal(t, expected, stats)
+
+	stats, err = model.GetCampaignStats(context.Background(), "missing")
+	assert.NoError(t, err)
+	assert.Nil(t, stats)
+}
+
+func TestDeploymentModelPauseCampaign(t *testing.T) {
+
+	testCases := map[string]struct {
+		InputCampaign *deployments.Campaign
+		InputPaused   bool
+		InputSetError error
+
+		OutputStatus string
+		OutputError  error
+	}{
+		"pause": {
+			InputCampaign: &deployments.Campaign{
+				CampaignConstructor: &deployments.CampaignConstructor{
+					Deployments: []string{campaignDeployment1},
+				},
+				Status: deployments.CampaignStatusActive,
+			},
+			InputPaused:  true,
+			OutputStatus: deployments.CampaignStatusPaused,
+		},
+		"resume": {
+			InputCampaign: &deployments.Campaign{
+				CampaignConstructor: &deployments.CampaignConstructor{
+					Deployments: []string{campaignDeployment1},
+				},
+				Status: deployments.CampaignStatusPaused,
+			},
+			OutputStatus: deployments.CampaignStatusActive,
+		},
+		"not found": {
+			OutputError: controller.ErrModelCampaignNotFound,
+		},
+		"aborted": {
+			InputCampaign: &deployments.Campaign{
+				CampaignConstructor: &deployments.CampaignConstructor{
+					Deployments: []string{campaignDeployment1},
+				},
+				Status: deployments.CampaignStatusAborted,
+			},
+			InputPaused: true,
+			OutputError: controller.ErrCampaignAborted,
+		},
+		"storage error": {
+			InputCampaign: &deployments.Campaign{
+				CampaignConstructor: &deployments.CampaignConstructor{
+					Deployments: []string{campaignDeployment1},
+				},
+				Status: deployments.CampaignStatusActive,
+			},
+			InputPaused:   true,
+			InputSetError: errors.New("connection failed"),
+			OutputError:   errors.New("Pausing campaign deployments: connection failed"),
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+
+			deploymentsStorage := new(mocks.DeploymentsStorage)
+			deploymentsStorage.On("SetPaused", h.ContextMatcher(),
+				[]string{campaignDeployment1}, tc.InputPaused).
+				Return(tc.InputSetError)
+
+			campaignsStorage := new(mocks.CampaignsStorage)
+			campaignsStorage.On("FindCampaignByID", h.ContextMatcher(), validUUIDv4).
+				Return(tc.InputCampaign, nil)
+			campaignsStorage.On("SetCampaignStatus", h.ContextMatcher(), validUUIDv4,
+				tc.OutputStatus).
+				Return(nil)
+
+			model := NewDeploymentModel(DeploymentsModelConfig{
+				DeploymentsStorage: deploymentsStorage,
+				CampaignsStorage:   campaignsStorage,
+			})
+
+			err := model.PauseCampaign(context.Background(), validUUIDv4, tc.InputPaused)
+			if tc.OutputError != nil {
+				assert.EqualError(t, err, tc.OutputError.Error())
+				campaignsStorage.AssertNotCalled(t, "SetCampaignStatus",
+					mock.Anything, mock.Anything, mock.Anything)
+			} else {
+				assert.NoError(t, err)
+				campaignsStorage.AssertExpectations(t)
+			}
+		})
+	}
+}
+
+func TestDeploymentModelAbortCampaign(t *testing.T) {
+
+	campaign := &deployments.Campaign{
+		CampaignConstructor: &deployments.CampaignConstructor{
+			Name:        "release 1.2",
+			Deployments: []string{campaignDeployment1, campaignDeployment2},
+		},
+		Id:     validUUIDv4,
+		Status: deployments.CampaignStatusPaused,
+	}
+
+	deploymentsStorage := new(mocks.DeploymentsStorage)
+	deploymentsStorage.On("FindByID", h.ContextMatcher(), campaignDeployment1).
+		Return(&deployments.Deployment{Id: StringToPointer(campaignDeployment1)}, nil)
+	// already finished
+	deploymentsStorage.On("FindByID", h.ContextMatcher(), campaignDeployment2).
+		Return(&deployments.Deployment{
+			Id:       StringToPointer(campaignDeployment2),
+			Finished: TimeToPointer(time.Now()),
+		}, nil)
+	deploymentsStorage.On("SetAbortInfo", h.ContextMatcher(), campaignDeployment1,
+		mock.AnythingOfType("*deployments.AbortInfo")).
+		Return(nil)
+	deploymentsStorage.On("UpdateStatsAndFinishDeployment", h.ContextMatcher(),
+		campaignDeployment1, mock.AnythingOfType("deployments.Stats")).
+		Return(nil)
+	deploymentsStorage.On("SetPaused", h.ContextMatcher(),
+		campaign.Deployments, false).
+		Return(nil)
+
+	deviceDeploymentsStorage := new(mocks.DeviceDeploymentStorage)
+	deviceDeploymentsStorage.On("AbortDeviceDeployments", h.ContextMatcher(),
+		campaignDeployment1, mock.AnythingOfType("*deployments.AbortInfo")).
+		Return(nil)
+	deviceDeploymentsStorage.On("AggregateDeviceDeploymentByStatus", h.ContextMatcher(),
+		campaignDeployment1).
+		Return(deployments.Stats{deployments.DeviceDeploymentStatusAborted: 3}, nil)
+
+	campaignsStorage := new(mocks.CampaignsStorage)
+	campaignsStorage.On("FindCampaignByID", h.ContextMatcher(), validUUIDv4).
+		Return(campaign, nil)
+	campaignsStorage.On("SetCampaignStatus", h.ContextMatcher(), validUUIDv4,
+		deployments.CampaignStatusAborted).
+		Return(nil)
+
+	model := NewDeploymentModel(DeploymentsModelConfig{
+		DeploymentsStorage:       deploymentsStorage,
+		DeviceDeploymentsStorage: deviceDeploymentsStorage,
+		CampaignsStorage:         campaignsStorage,
+	})
+
+	err := model.AbortCampaign(context.Background(), validUUIDv4,
+		&deployments.AbortInfo{Reason: "broken release"})
+	assert.NoError(t, err)
+
+	deploymentsStorage.AssertNotCalled(t, "SetAbortInfo", mock.Anything,
+		campaignDeployment2, mock.Anything)
+	deploymentsStorage.AssertExpectations(t)
+	campaignsStorage.AssertExpectations(t)
+}
@@ -0,0 +1,30 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"context"
+
+	"github.com/mendersoftware/deployments/resources/deployments"
+)
+
+// Deployment campaigns storage
+type CampaignsStorage interface {
+	InsertCampaign(ctx context.Context, campaign *deployments.Campaign) error
+	FindCampaigns(ctx context.Context) ([]*deployments.Campaign, error)
+	// FindCampaignByID returns nil if the campaign does not exist
+	FindCampaignByID(ctx context.Context, id string) (*deployments.Campaign, error)
+	SetCampaignStatus(ctx context.Context, id, status string) error
+}
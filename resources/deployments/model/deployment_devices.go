@@ -0,0 +1,173 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"context"
+
+	"github.com/mendersoftware/go-lib-micro/log"
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/deployments/resources/deployments"
+)
+
+// Device deployments of lazy deployments, by default those with at least
+// lazyDevicesThreshold devices, are not created with the deployment.
+// The device list is stored instead and device deployment is created when
+// it is first needed, usually on the update check of the device; devices
+// which never check in cost only their ID.
+//
+// Until then the device is counted as pending in deployment statistics,
+// but is not listed among device deployments of the deployment.
+
+// isLazy decides if device deployments are created lazily, as requested
+// or by number of devices. Lazy creation needs the device list storage.
+// Devices of phased deployments are assigned to phases on creation, lazy
+// creation requested for them is a validation error.
+func (d *DeploymentsModel) isLazy(constructor *deployments.DeploymentConstructor) (bool, error) {
+	if len(constructor.Phases) > 0 {
+		if constructor.DeviceDeployments == deployments.DeviceDeploymentsLazy {
+			return false, deployments.NewValidationError("device_deployments",
+				deployments.ValidationCodeInvalid, deployments.ErrPhasesLazy.Error())
+		}
+		return false, nil
+	}
+	if d.deploymentDevicesStorage == nil {
+		return false, nil
+	}
+
+	switch constructor.DeviceDeployments {
+	case deployments.DeviceDeploymentsEager:
+		return false, nil
+	case deployments.DeviceDeploymentsLazy:
+		return true, nil
+	}
+
+	return d.lazyDevicesThreshold > 0 &&
+		len(constructor.Devices) >= d.lazyDevicesThreshold, nil
+}
+
+// insertDeviceDeployments creates device deployments of the new deployment,
+// or stores its device list if they are to be created lazily.
+func (d *DeploymentsModel) insertDeviceDeployments(ctx context.Context,
+	deployment *deployments.Deployment,
+	constructor *deployments.DeploymentConstructor, lazy bool) error {
+
+	devices := constructor.Devices
+	if lazy {
+		err := d.deploymentDevicesStorage.InsertDeploymentDevices(ctx,
+			*deployment.Id, devices)
+		if err != nil {
+			if _, errCleanup := d.deploymentDevicesStorage.DeleteDeploymentDevices(ctx,
+				*deployment.Id); errCleanup != nil {
+				err = errors.Wrap(err, errCleanup.Error())
+			}
+		}
+		return err
+	}
+
+	return d.deviceDeploymentsStorage.InsertMany(ctx,
+		newDeviceDeployments(deployment, devices)...)
+}
+
+// Do not assign artifacts to the particular device deployment.
+// Artifacts will be assigned on device update request handling, based on
+// information provided by the device in the update request.
+// Devices of phased deployments are assigned to phases in order.
+func newDeviceDeployments(deployment *deployments.Deployment,
+	devices []string) []*deployments.DeviceDeployment {
+
+	deviceDeployments := make([]*deployments.DeviceDeployment, 0, len(devices))
+	for i, id := range devices {
+		deviceDeployment := deployments.NewDeviceDeployment(id, *deployment.Id)
+		deviceDeployment.Created = deployment.Created
+		deviceDeployment.Phase = deployment.PhaseOfDevice(i)
+		deviceDeployments = append(deviceDeployments, deviceDeployment)
+	}
+	return deviceDeployments
+}
+
+// expandDevices creates device deployments of the devices in all deployments
+// which list them but have not created them yet.
+func (d *DeploymentsModel) expandDevices(ctx context.Context, deviceIDs ...string) error {
+	if d.deploymentDevicesStorage == nil || len(deviceIDs) == 0 {
+		return nil
+	}
+
+	pulled, err := d.deploymentDevicesStorage.PullDeploymentDevices(ctx, deviceIDs)
+	if err != nil {
+		return errors.Wrap(err, "Searching for deployment device lists")
+	}
+
+	for deploymentID, devices := range pulled {
+		if err := d.expandDeployment(ctx, deploymentID, devices); err != nil {
+			// put the devices back, so that they are expanded next time
+			if errRestore := d.deploymentDevicesStorage.InsertDeploymentDevices(ctx,
+				deploymentID, devices); errRestore != nil {
+				log.FromContext(ctx).Errorf("restoring devices of deployment %s: %v",
+					deploymentID, errRestore)
+			}
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (d *DeploymentsModel) expandDeployment(ctx context.Context,
+	deploymentID string, devices []string) error {
+
+	deployment, err := d.deploymentsStorage.FindByID(ctx, deploymentID)
+	if err != nil {
+		return errors.Wrap(err, "Searching for deployment by ID")
+	}
+	// removed together with its device deployments
+	if deployment == nil {
+		return nil
+	}
+
+	if err := d.deviceDeploymentsStorage.InsertMany(ctx,
+		newDeviceDeployments(deployment, devices)...); err != nil {
+		return errors.Wrap(err, "Storing assigned deployments to devices")
+	}
+
+	return nil
+}
+
+// aggregateStats counts device deployments of the deployment by status,
+// including devices which do not have device deployment yet.
+func (d *DeploymentsModel) aggregateStats(ctx context.Context,
+	deploymentID string) (deployments.Stats, error) {
+
+	stats, err := d.deviceDeploymentsStorage.AggregateDeviceDeploymentByStatus(
+		ctx, deploymentID)
+	if err != nil {
+		return nil, err
+	}
+
+	if d.deploymentDevicesStorage != nil {
+		pending, err := d.deploymentDevicesStorage.CountDeploymentDevices(ctx,
+			deploymentID)
+		if err != nil {
+			return nil, err
+		}
+		if stats == nil {
+			stats = deployments.NewDeviceDeploymentStats()
+		}
+		stats[deployments.DeviceDeploymentStatusPending] += pending
+	}
+
+	return stats, nil
+}
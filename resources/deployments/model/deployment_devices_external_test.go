@@ -0,0 +1,395 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/mendersoftware/deployments/resources/deployments"
+	. "github.com/mendersoftware/deployments/resources/deployments/model"
+	"github.com/mendersoftware/deployments/resources/deployments/model/mocks"
+	"github.com/mendersoftware/deployments/resources/images"
+	. "github.com/mendersoftware/deployments/utils/pointers"
+	h "github.com/mendersoftware/deployments/utils/testing"
+)
+
+func TestDeploymentModelCreateDeploymentLazy(t *testing.T) {
+
+	phases := []deployments.DeploymentPhase{
+		{BatchSize: 50},
+		{StartTs: TimeToPointer(time.Now().Add(time.Hour))},
+	}
+
+	testCases := map[string]struct {
+		InputDevices           []string
+		InputDeviceDeployments string
+		InputPhases            []deployments.DeploymentPhase
+		InsertError            error
+
+		OutputLazy  bool
+		OutputError string
+	}{
+		"below threshold": {
+			InputDevices: []string{"device-1"},
+		},
+		"lazy": {
+			InputDevices: []string{"device-1", "device-2"},
+			OutputLazy:   true,
+		},
+		"eager requested": {
+			InputDevices:           []string{"device-1", "device-2"},
+			InputDeviceDeployments: deployments.DeviceDeploymentsEager,
+		},
+		"lazy requested": {
+			InputDevices:           []string{"device-1"},
+			InputDeviceDeployments: deployments.DeviceDeploymentsLazy,
+			OutputLazy:             true,
+		},
+		"phased": {
+			InputDevices: []string{"device-1", "device-2"},
+			InputPhases:  phases,
+		},
+		"lazy requested, phased": {
+			InputDevices:           []string{"device-1", "device-2"},
+			InputDeviceDeployments: deployments.DeviceDeploymentsLazy,
+			InputPhases:            phases,
+			OutputError: "Validating deployment: device_deployments: " +
+				deployments.ErrPhasesLazy.Error() + ";",
+		},
+		"lazy, storage error": {
+			InputDevices: []string{"device-1", "device-2"},
+			InsertError:  errors.New("storage issue"),
+			OutputLazy:   true,
+			OutputError:  "Storing assigned deployments to devices: storage issue",
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+
+			var inserted *deployments.Deployment
+			deploymentStorage := new(mocks.DeploymentsStorage)
+			deploymentStorage.On("FindBySlug",
+				h.ContextMatcher(), mock.AnythingOfType("string")).
+				Return(nil, nil)
+			deploymentStorage.On("Insert",
+				h.ContextMatcher(),
+				mock.AnythingOfType("*deployments.Deployment")).
+				Run(func(args mock.Arguments) {
+					inserted = args.Get(1).(*deployments.Deployment)
+				}).
+				Return(nil)
+			deploymentStorage.On("Delete",
+				h.ContextMatcher(), mock.AnythingOfType("string")).
+				Return(nil)
+
+			deviceDeploymentStorage := new(mocks.DeviceDeploymentStorage)
+			deviceDeploymentStorage.On("InsertMany",
+				h.ContextMatcher(),
+				mock.AnythingOfType("[]*deployments.DeviceDeployment")).
+				Return(nil)
+
+			deploymentDevicesStorage := new(mocks.DeploymentDevicesStorage)
+			deploymentDevicesStorage.On("InsertDeploymentDevices",
+				h.ContextMatcher(), mock.AnythingOfType("string"),
+				testCase.InputDevices).
+				Return(testCase.InsertError)
+			deploymentDevicesStorage.On("DeleteDeploymentDevices",
+				h.ContextMatcher(), mock.AnythingOfType("string")).
+				Return(0, nil)
+
+			artifactGetter := new(mocks.ArtifactGetter)
+			artifactGetter.On("ImagesByName",
+				h.ContextMatcher(), "App 123").
+				Return([]*images.SoftwareImage{{Id: validUUIDv4}}, nil)
+
+			model := NewDeploymentModel(DeploymentsModelConfig{
+				DeploymentsStorage:       deploymentStorage,
+				DeviceDeploymentsStorage: deviceDeploymentStorage,
+				DeploymentDevicesStorage: deploymentDevicesStorage,
+				LazyDevicesThreshold:     2,
+				ArtifactGetter:           artifactGetter,
+			})
+
+			_, err := model.CreateDeployment(context.Background(),
+				&deployments.DeploymentConstructor{
+					Name:         StringToPointer("NYC Production"),
+					ArtifactName: StringToPointer("App 123"),
+					Devices:      testCase.InputDevices,
+
+					DeviceDeployments: testCase.InputDeviceDeployments,
+					Phases:            testCase.InputPhases,
+				})
+			if testCase.OutputError != "" && testCase.InsertError == nil {
+				// rejected before anything is stored
+				assert.EqualError(t, err, testCase.OutputError)
+				assert.Nil(t, inserted)
+				return
+			}
+			if testCase.OutputError != "" {
+				assert.EqualError(t, err, testCase.OutputError)
+				deploymentStorage.AssertCalled(t, "Delete",
+					h.ContextMatcher(), *inserted.Id)
+				deploymentDevicesStorage.AssertCalled(t, "DeleteDeploymentDevices",
+					h.ContextMatcher(), *inserted.Id)
+			} else {
+				assert.NoError(t, err)
+			}
+
+			if assert.NotNil(t, inserted) {
+				assert.Equal(t, len(testCase.InputDevices),
+					inserted.Stats[deployments.DeviceDeploymentStatusPending])
+			}
+			if testCase.OutputLazy {
+				deviceDeploymentStorage.AssertNotCalled(t, "InsertMany",
+					mock.Anything, mock.Anything)
+			} else {
+				deploymentDevicesStorage.AssertNotCalled(t, "InsertDeploymentDevices",
+					mock.Anything, mock.Anything, mock.Anything)
+			}
+		})
+	}
+}
+
+func TestDeploymentModelExpandDevices(t *testing.T) {
+
+	deployment := deployments.NewDeploymentFromConstructor(
+		&deployments.DeploymentConstructor{
+			Name:         StringToPointer("NYC Production"),
+			ArtifactName: StringToPointer("App 123"),
+			Devices:      []string{"device-1", "device-2"},
+		})
+
+	testCases := map[string]struct {
+		PullError       error
+		InsertManyError error
+
+		OutputError string
+	}{
+		"ok": {},
+		"pull error": {
+			PullError:   errors.New("storage issue"),
+			OutputError: "Searching for deployment device lists: storage issue",
+		},
+		"insert error": {
+			InsertManyError: errors.New("storage issue"),
+			OutputError:     "Storing assigned deployments to devices: storage issue",
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+
+			deploymentDevicesStorage := new(mocks.DeploymentDevicesStorage)
+			deploymentDevicesStorage.On("PullDeploymentDevices",
+				h.ContextMatcher(), []string{"device-1"}).
+				Return(map[string][]string{*deployment.Id: {"device-1"}},
+					testCase.PullError)
+			deploymentDevicesStorage.On("InsertDeploymentDevices",
+				h.ContextMatcher(), *deployment.Id, []string{"device-1"}).
+				Return(nil)
+
+			deploymentStorage := new(mocks.DeploymentsStorage)
+			deploymentStorage.On("FindByID",
+				h.ContextMatcher(), *deployment.Id).
+				Return(deployment, nil)
+
+			deviceDeploymentStorage := new(mocks.DeviceDeploymentStorage)
+			deviceDeploymentStorage.On("InsertMany",
+				h.ContextMatcher(),
+				mock.MatchedBy(func(dds []*deployments.DeviceDeployment) bool {
+					dd := dds[0]
+					return len(dds) == 1 &&
+						*dd.DeviceId == "device-1" &&
+						*dd.DeploymentId == *deployment.Id &&
+						*dd.Status == deployments.DeviceDeploymentStatusPending &&
+						dd.Created.Equal(*deployment.Created)
+				})).
+				Return(testCase.InsertManyError)
+			deviceDeploymentStorage.On("FindOldestDeploymentForDeviceIDWithStatuses",
+				h.ContextMatcher(), "device-1",
+				deployments.ActiveDeploymentStatuses()).
+				Return(nil, nil)
+
+			model := NewDeploymentModel(DeploymentsModelConfig{
+				DeploymentsStorage:       deploymentStorage,
+				DeviceDeploymentsStorage: deviceDeploymentStorage,
+				DeploymentDevicesStorage: deploymentDevicesStorage,
+				LazyDevicesThreshold:     2,
+			})
+
+			instructions, err := model.GetDeploymentForDeviceWithCurrent(
+				context.Background(), "device-1",
+				deployments.InstalledDeviceDeployment{})
+			assert.Nil(t, instructions)
+			if testCase.OutputError != "" {
+				assert.EqualError(t, err, testCase.OutputError)
+			} else {
+				assert.NoError(t, err)
+			}
+
+			// devices are returned to the list if device deployments
+			// could not be created
+			if testCase.InsertManyError != nil {
+				deploymentDevicesStorage.AssertCalled(t, "InsertDeploymentDevices",
+					h.ContextMatcher(), *deployment.Id, []string{"device-1"})
+			} else {
+				deploymentDevicesStorage.AssertNotCalled(t, "InsertDeploymentDevices",
+					mock.Anything, mock.Anything, mock.Anything)
+			}
+		})
+	}
+}
+
+func TestDeploymentModelAbortDeploymentLazy(t *testing.T) {
+
+	deploymentID := "f826484e-1157-4109-af21-304e6d711561"
+
+	deploymentDevicesStorage := new(mocks.DeploymentDevicesStorage)
+	deploymentDevicesStorage.On("DeleteDeploymentDevices",
+		h.ContextMatcher(), deploymentID).
+		Return(5, nil)
+	deploymentDevicesStorage.On("CountDeploymentDevices",
+		h.ContextMatcher(), deploymentID).
+		Return(0, nil)
+
+	deviceDeploymentStorage := new(mocks.DeviceDeploymentStorage)
+	deviceDeploymentStorage.On("AbortDeviceDeployments",
+		h.ContextMatcher(), deploymentID, mock.AnythingOfType("*deployments.AbortInfo")).
+		Return(nil)
+	deviceDeploymentStorage.On("AggregateDeviceDeploymentByStatus",
+		h.ContextMatcher(), deploymentID).
+		Return(deployments.Stats{
+			deployments.DeviceDeploymentStatusAborted: 2,
+			deployments.DeviceDeploymentStatusSuccess: 1,
+		}, nil)
+
+	deploymentStorage := new(mocks.DeploymentsStorage)
+	deploymentStorage.On("FindByID", h.ContextMatcher(), deploymentID).
+		Return(&deployments.Deployment{Id: StringToPointer(deploymentID)}, nil)
+	deploymentStorage.On("SetAbortInfo",
+		h.ContextMatcher(), deploymentID, mock.AnythingOfType("*deployments.AbortInfo")).
+		Return(nil)
+	deploymentStorage.On("UpdateStatsAndFinishDeployment",
+		h.ContextMatcher(), deploymentID, deployments.Stats{
+			deployments.DeviceDeploymentStatusAborted: 7,
+			deployments.DeviceDeploymentStatusSuccess: 1,
+			deployments.DeviceDeploymentStatusPending: 0,
+		}).
+		Return(nil)
+
+	model := NewDeploymentModel(DeploymentsModelConfig{
+		DeploymentsStorage:       deploymentStorage,
+		DeviceDeploymentsStorage: deviceDeploymentStorage,
+		DeploymentDevicesStorage: deploymentDevicesStorage,
+	})
+
+	assert.NoError(t, model.AbortDeployment(context.Background(), deploymentID, nil))
+	deploymentStorage.AssertExpectations(t)
+}
+
+func TestDeploymentModelGetDeviceDeploymentsCountLazy(t *testing.T) {
+
+	deploymentID := "30b3e62c-9ec2-4312-a7fa-cff24cc7397b"
+
+	testCases := map[string]struct {
+		InputStatus string
+		OutputCount int
+	}{
+		"all": {
+			OutputCount: 7,
+		},
+		"pending": {
+			InputStatus: deployments.DeviceDeploymentStatusPending,
+			OutputCount: 7,
+		},
+		"success": {
+			InputStatus: deployments.DeviceDeploymentStatusSuccess,
+			OutputCount: 3,
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+
+			deploymentStorage := new(mocks.DeploymentsStorage)
+			deploymentStorage.On("FindByID", h.ContextMatcher(), deploymentID).
+				Return(&deployments.Deployment{}, nil)
+
+			deviceDeploymentStorage := new(mocks.DeviceDeploymentStorage)
+			deviceDeploymentStorage.On("CountDeviceDeployments",
+				h.ContextMatcher(), deploymentID, testCase.InputStatus).
+				Return(3, nil)
+
+			deploymentDevicesStorage := new(mocks.DeploymentDevicesStorage)
+			deploymentDevicesStorage.On("CountDeploymentDevices",
+				h.ContextMatcher(), deploymentID).
+				Return(4, nil)
+
+			model := NewDeploymentModel(DeploymentsModelConfig{
+				DeploymentsStorage:       deploymentStorage,
+				DeviceDeploymentsStorage: deviceDeploymentStorage,
+				DeploymentDevicesStorage: deploymentDevicesStorage,
+			})
+
+			count, err := model.GetDeviceDeploymentsCount(context.Background(),
+				deploymentID, testCase.InputStatus)
+			assert.NoError(t, err)
+			assert.Equal(t, testCase.OutputCount, count)
+		})
+	}
+}
+
+func TestGetDeploymentStatsLazy(t *testing.T) {
+
+	deploymentID := "30b3e62c-9ec2-4312-a7fa-cff24cc7397b"
+
+	deploymentStorage := new(mocks.DeploymentsStorage)
+	deploymentStorage.On("FindByID", h.ContextMatcher(), deploymentID).
+		Return(&deployments.Deployment{}, nil)
+
+	deviceDeploymentStorage := new(mocks.DeviceDeploymentStorage)
+	deviceDeploymentStorage.On("AggregateDeviceDeploymentByStatus",
+		h.ContextMatcher(), deploymentID).
+		Return(deployments.Stats{
+			deployments.DeviceDeploymentStatusPending: 1,
+			deployments.DeviceDeploymentStatusSuccess: 2,
+		}, nil)
+
+	deploymentDevicesStorage := new(mocks.DeploymentDevicesStorage)
+	deploymentDevicesStorage.On("CountDeploymentDevices",
+		h.ContextMatcher(), deploymentID).
+		Return(4, nil)
+
+	model := NewDeploymentModel(DeploymentsModelConfig{
+		DeploymentsStorage:       deploymentStorage,
+		DeviceDeploymentsStorage: deviceDeploymentStorage,
+		DeploymentDevicesStorage: deploymentDevicesStorage,
+	})
+
+	// devices without device deployment are pending
+	stats, err := model.GetDeploymentStats(context.Background(), deploymentID)
+	assert.NoError(t, err)
+	assert.Equal(t, deployments.Stats{
+		deployments.DeviceDeploymentStatusPending: 5,
+		deployments.DeviceDeploymentStatusSuccess: 2,
+	}, stats)
+}
@@ -0,0 +1,29 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"context"
+)
+
+// Device lists of deployments with lazily created device deployments
+type DeploymentDevicesStorage interface {
+	InsertDeploymentDevices(ctx context.Context,
+		deploymentID string, deviceIDs []string) error
+	PullDeploymentDevices(ctx context.Context,
+		deviceIDs []string) (map[string][]string, error)
+	CountDeploymentDevices(ctx context.Context, deploymentID string) (int, error)
+	DeleteDeploymentDevices(ctx context.Context, deploymentID string) (int, error)
+}
@@ -16,11 +16,16 @@ package model
 
 import (
 	"context"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/mendersoftware/go-lib-micro/log"
 	"github.com/pkg/errors"
 
+	"github.com/mendersoftware/deployments/integration"
 	"github.com/mendersoftware/deployments/resources/deployments"
 	"github.com/mendersoftware/deployments/resources/deployments/controller"
 	"github.com/mendersoftware/deployments/resources/images"
@@ -38,6 +43,9 @@ type ArtifactGetter interface {
 		ids []string, deviceType string) (*images.SoftwareImage, error)
 	ImageByNameAndDeviceType(ctx context.Context,
 		name, deviceType string) (*images.SoftwareImage, error)
+	FindByID(ctx context.Context, id string) (*images.SoftwareImage, error)
+	ImagesByNamePrefix(ctx context.Context,
+		prefix, deviceType string) ([]*images.SoftwareImage, error)
 }
 
 type DeploymentsModel struct {
@@ -45,8 +53,44 @@ type DeploymentsModel struct {
 	deviceDeploymentsStorage    DeviceDeploymentStorage
 	deviceDeploymentLogsStorage DeviceDeploymentLogsStorage
 	imageLinker                 GetRequester
+	linkRewriter                LinkRewriter
+	downloadProxy               *DownloadProxy
 	artifactGetter              ArtifactGetter
+	inventory                   Inventory
 	imageContentType            string
+	preCreateHooks              []PreCreateHook
+	preServeHooks               []PreServeHook
+	eventPublisher              EventPublisher
+	artifactRestorer            ArtifactRestorer
+	artifactRestoreDays         int64
+	freezePeriodsStorage        FreezePeriodsStorage
+	deviceLogsSearch            bool
+	artifactCache               *artifactCache
+	downloadsStorage            DownloadsStorage
+	jobs                        JobQueue
+	deploymentDevicesStorage    DeploymentDevicesStorage
+	lazyDevicesThreshold        int
+	campaignsStorage            CampaignsStorage
+	scriptsAckTenants           []string
+	deviceExternalIDAttribute   string
+	replicaDeploymentsStorage   DeploymentsStorage
+	replicaDeviceDeployments    DeviceDeploymentStorage
+	statusBatcher               *statusBatcher
+	deviceNotifier              EventPublisher
+	egress                      EgressRecorder
+	maxRetries                  int
+	inventorySnapshot           []string
+	settingsStorage             SettingsStorage
+	alertsStorage               AlertsStorage
+	finalizeHooks               []namedFinalizeHook
+	deviceTypeAliasesStorage    DeviceTypeAliasesStorage
+	versions                    *images.VersionParser
+	installedArtifactsStorage   InstalledArtifactsStorage
+	limits                      LimitsGetter
+	telemetryStorage            TelemetryStorage
+	telemetrySampleRate         float64
+	sample                      func() float64
+	downloadMetrics             *DownloadMetrics
 }
 
 type DeploymentsModelConfig struct {
@@ -55,18 +99,144 @@ type DeploymentsModelConfig struct {
 	DeviceDeploymentLogsStorage DeviceDeploymentLogsStorage
 	ImageLinker                 GetRequester
 	ArtifactGetter              ArtifactGetter
+	Inventory                   Inventory
 	ImageContentType            string
+	PreCreateHooks              []PreCreateHook
+	PreServeHooks               []PreServeHook
+	EventPublisher              EventPublisher
+	// Restores archived artifacts, optional
+	ArtifactRestorer    ArtifactRestorer
+	ArtifactRestoreDays int64
+	// Tenant deployment freeze periods, optional
+	FreezePeriodsStorage FreezePeriodsStorage
+	// Index device deployment logs for search
+	DeviceLogsSearch bool
+	// Time resolved artifacts and download links are cached for, 0 disables caching
+	ArtifactLinkCacheTTL time.Duration
+	// Rewrites download links for the requesting device, optional
+	LinkRewriter LinkRewriter
+	// Devices download artifacts through the service, optional; required
+	// for revoking issued download links
+	DownloadProxy *DownloadProxy
+	// Artifact downloads audit, optional
+	DownloadsStorage DownloadsStorage
+	// Background job queue, optional; statistics rollups are updated
+	// synchronously without it
+	Jobs JobQueue
+	// Device lists of deployments with lazily created device deployments,
+	// optional; used for deployments with at least LazyDevicesThreshold
+	// devices, 0 disables lazy creation
+	DeploymentDevicesStorage DeploymentDevicesStorage
+	LazyDevicesThreshold     int
+	// Campaigns grouping related deployments
+	CampaignsStorage CampaignsStorage
+	// Tenants required to acknowledge state scripts of deployed artifacts,
+	// "*" for all
+	ScriptsAckTenants []string
+	// Inventory attribute identifying devices outside of the system,
+	// e.g. serial number; resolving external IDs is disabled if empty
+	DeviceExternalIDAttribute string
+	// Storages reading from replicas of the database, optional; serve
+	// update checks of devices with artifacts already resolved, so that
+	// the primary is left for status updates
+	ReplicaDeploymentsStorage       DeploymentsStorage
+	ReplicaDeviceDeploymentsStorage DeviceDeploymentStorage
+	// Time status updates of devices are buffered for to be written in bulk,
+	// 0 writes each update on its own
+	StatusBatchWindow time.Duration
+	// Number of status updates written without waiting for the window,
+	// DefaultStatusBatchSize if 0
+	StatusBatchSize int
+	// Pushes notifications to connected devices, e.g. through MQTT or
+	// WebSocket bridge, optional; devices learn of aborted deployments
+	// on their next update check without it
+	DeviceNotifier EventPublisher
+	// Accounts size of artifacts download links are issued for to the
+	// tenant, optional
+	Egress EgressRecorder
+	// Number of times a failed or aborted device deployment can be put
+	// back to pending, 0 disables retries
+	MaxRetries int
+	// Inventory attributes saved to device deployments when first served,
+	// DeviceAttributeGroup for the inventory group; disabled if empty
+	InventorySnapshot []string
+	// Tenant defaults of deployment parameters, optional
+	SettingsStorage SettingsStorage
+	// Alerts checked on active deployments
+	AlertsStorage AlertsStorage
+	// Tenant device type aliases consulted when resolving artifacts of
+	// devices, optional
+	DeviceTypeAliasesStorage DeviceTypeAliasesStorage
+	// Versions parsed from artifact names to find the latest release for
+	// auto_latest deployments, the default pattern if nil
+	VersionParser *images.VersionParser
+	// Artifacts reported as installed by devices on update checks, for
+	// deployments targeting devices by installed artifact, optional
+	InstalledArtifactsStorage InstalledArtifactsStorage
+	// Tenant limits of deployments, optional; devices per deployment are
+	// not limited without it
+	Limits LimitsGetter
+	// Telemetry attached by devices to status reports, optional; saved for
+	// TelemetrySampleRate (0 to 1) of the reports
+	TelemetryStorage    TelemetryStorage
+	TelemetrySampleRate float64
+	// Histograms of download speeds reported in telemetry, optional
+	DownloadMetrics *DownloadMetrics
 }
 
 func NewDeploymentModel(config DeploymentsModelConfig) *DeploymentsModel {
-	return &DeploymentsModel{
+	model := &DeploymentsModel{
 		deploymentsStorage:          config.DeploymentsStorage,
 		deviceDeploymentsStorage:    config.DeviceDeploymentsStorage,
 		deviceDeploymentLogsStorage: config.DeviceDeploymentLogsStorage,
 		imageLinker:                 config.ImageLinker,
+		linkRewriter:                config.LinkRewriter,
+		downloadProxy:               config.DownloadProxy,
 		artifactGetter:              config.ArtifactGetter,
+		inventory:                   config.Inventory,
 		imageContentType:            config.ImageContentType,
+		preCreateHooks:              config.PreCreateHooks,
+		preServeHooks:               config.PreServeHooks,
+		eventPublisher:              config.EventPublisher,
+		artifactRestorer:            config.ArtifactRestorer,
+		artifactRestoreDays:         config.ArtifactRestoreDays,
+		freezePeriodsStorage:        config.FreezePeriodsStorage,
+		deviceLogsSearch:            config.DeviceLogsSearch,
+		artifactCache:               newArtifactCache(config.ArtifactLinkCacheTTL),
+		downloadsStorage:            config.DownloadsStorage,
+		jobs:                        config.Jobs,
+		deploymentDevicesStorage:    config.DeploymentDevicesStorage,
+		lazyDevicesThreshold:        config.LazyDevicesThreshold,
+		campaignsStorage:            config.CampaignsStorage,
+		scriptsAckTenants:           config.ScriptsAckTenants,
+		deviceExternalIDAttribute:   config.DeviceExternalIDAttribute,
+		replicaDeploymentsStorage:   config.ReplicaDeploymentsStorage,
+		replicaDeviceDeployments:    config.ReplicaDeviceDeploymentsStorage,
+		deviceNotifier:              config.DeviceNotifier,
+		egress:                      config.Egress,
+		maxRetries:                  config.MaxRetries,
+		inventorySnapshot:           config.InventorySnapshot,
+		settingsStorage:             config.SettingsStorage,
+		alertsStorage:               config.AlertsStorage,
+		deviceTypeAliasesStorage:    config.DeviceTypeAliasesStorage,
+		versions:                    config.VersionParser,
+		installedArtifactsStorage:   config.InstalledArtifactsStorage,
+		limits:                      config.Limits,
+		telemetryStorage:            config.TelemetryStorage,
+		telemetrySampleRate:         config.TelemetrySampleRate,
+		sample:                      rand.Float64,
+		downloadMetrics:             config.DownloadMetrics,
 	}
+	if model.versions == nil {
+		model.versions = images.DefaultVersionParser()
+	}
+	if config.StatusBatchWindow > 0 {
+		model.statusBatcher = newStatusBatcher(config.StatusBatchWindow, config.StatusBatchSize,
+			config.DeviceDeploymentsStorage, config.DeploymentsStorage)
+	}
+	model.registerJobs()
+
+	return model
 }
 
 func getArtifactIDs(artifacts []*images.SoftwareImage) []string {
@@ -77,6 +247,22 @@ func getArtifactIDs(artifacts []*images.SoftwareImage) []string {
 	return artifactIDs
 }
 
+// getDeviceTypeArtifacts maps device types to compatible artifacts.
+// Artifact names are unique per device type, so there is at most one
+// artifact of the deployment for each device type.
+func getDeviceTypeArtifacts(artifacts []*images.SoftwareImage) []deployments.DeviceTypeArtifact {
+	var deviceTypeArtifacts []deployments.DeviceTypeArtifact
+	for _, artifact := range artifacts {
+		for _, deviceType := range artifact.DeviceTypesCompatible {
+			deviceTypeArtifacts = append(deviceTypeArtifacts, deployments.DeviceTypeArtifact{
+				DeviceType: deviceType,
+				ArtifactID: artifact.Id,
+			})
+		}
+	}
+	return deviceTypeArtifacts
+}
+
 // CreateDeployment precomputes new deplyomet and schedules it for devices.
 // TODO: check if specified devices are bootstrapped (when have a way to do this)
 func (d *DeploymentsModel) CreateDeployment(ctx context.Context,
@@ -86,35 +272,119 @@ func (d *DeploymentsModel) CreateDeployment(ctx context.Context,
 		return "", controller.ErrModelMissingInput
 	}
 
+	settings, err := d.deploymentSettings(ctx)
+	if err != nil {
+		return "", err
+	}
+	if settings != nil {
+		settings.Apply(constructor)
+	}
+
 	if err := constructor.Validate(); err != nil {
 		return "", errors.Wrap(err, "Validating deployment")
 	}
+	if settings != nil {
+		if err := constructor.ValidateDeviceIDs(settings.DeviceIDFormat); err != nil {
+			return "", errors.Wrap(err, "Validating deployment")
+		}
+	}
+
+	if err := d.resolveExternalDevices(ctx, constructor); err != nil {
+		return "", err
+	}
+	if err := d.resolveInstalledArtifactDevices(ctx, constructor); err != nil {
+		return "", err
+	}
+
+	// Artifact name of pinned deployment is known only after looking
+	// the artifact up, before hooks so that they can check it as well.
+	pinned, err := d.getPinnedArtifact(ctx, constructor)
+	if err != nil {
+		return "", err
+	}
+	if err := d.resolveLatestArtifact(ctx, constructor); err != nil {
+		return "", err
+	}
+
+	if len(d.preCreateHooks) > 0 {
+		for _, hook := range d.preCreateHooks {
+			if err := hook.PreCreate(ctx, constructor); err != nil {
+				return "", errors.Wrap(err, "Applying deployment policy")
+			}
+		}
+
+		// hooks may have modified the deployment
+		if err := constructor.Validate(); err != nil {
+			return "", errors.Wrap(err, "Validating deployment")
+		}
+	}
+
+	if !constructor.OverrideFreeze {
+		if err := d.checkFreeze(ctx); err != nil {
+			return "", err
+		}
+	}
+
+	if err := d.resolveConflicts(ctx, constructor); err != nil {
+		return "", err
+	}
+
+	// devices are known only after conflicts are resolved
+	lazy, err := d.isLazy(constructor)
+	if err != nil {
+		return "", errors.Wrap(err, "Validating deployment")
+	}
+	quota, err := d.checkDevicesLimit(ctx, constructor)
+	if err != nil {
+		return "", err
+	}
+
+	// pending device deployments of older deployments are superseded,
+	// they have to exist first
+	if constructor.Supersede {
+		if err := d.expandDevices(ctx, constructor.Devices...); err != nil {
+			return "", err
+		}
+	}
+
+	if err := d.assignSlug(ctx, constructor); err != nil {
+		return "", err
+	}
 
 	deployment := deployments.NewDeploymentFromConstructor(constructor)
+	deployment.OverQuota = quota
+	deployment.AssignPhases(len(constructor.Devices))
 
 	// Assign artifacts to the deployment.
 	// Only artifacts present in the system at the moment of deployment creation
 	// will be part of this deployment.
-	artifacts, err := d.artifactGetter.ImagesByName(ctx, *deployment.ArtifactName)
-	if err != nil {
-		return "", errors.Wrap(err, "Finding artifact with given name")
+	artifacts := []*images.SoftwareImage{pinned}
+	if pinned == nil {
+		artifacts, err = d.artifactGetter.ImagesByName(ctx, *deployment.ArtifactName)
+		if err != nil {
+			return "", errors.Wrap(err, "Finding artifact with given name")
+		}
 	}
 
 	if len(artifacts) == 0 {
 		return "", controller.ErrNoArtifact
 	}
 
+	if !constructor.AcknowledgeScripts {
+		if err := d.checkScriptsAck(ctx, artifacts); err != nil {
+			return "", err
+		}
+	}
+
 	deployment.Artifacts = getArtifactIDs(artifacts)
+	if constructor.SnapshotArtifacts {
+		deployment.DeviceTypeArtifacts = getDeviceTypeArtifacts(artifacts)
+	}
 
-	// Generate deployment for each specified device.
-	// Do not assign artifacts to the particular device deployment.
-	// Artifacts will be assigned on device update request handling, based on
-	// information provided by the device in the update request.
-	deviceDeployments := make([]*deployments.DeviceDeployment, 0, len(constructor.Devices))
-	for _, id := range constructor.Devices {
-		deviceDeployment := deployments.NewDeviceDeployment(id, *deployment.Id)
-		deviceDeployment.Created = deployment.Created
-		deviceDeployments = append(deviceDeployments, deviceDeployment)
+	// Archived artifacts have to be restored before devices can download them.
+	// Deployment stays in "restoring" state until the first device picks it up.
+	if deployment.Restoring, err = d.restoreArtifacts(ctx, artifacts); err != nil {
+		return "", err
 	}
 
 	// Set initial statistics cache values
@@ -124,7 +394,8 @@ func (d *DeploymentsModel) CreateDeployment(ctx context.Context,
 		return "", errors.Wrap(err, "Storing deployment data")
 	}
 
-	if err := d.deviceDeploymentsStorage.InsertMany(ctx, deviceDeployments...); err != nil {
+	// Generate deployment for each specified device.
+	if err := d.insertDeviceDeployments(ctx, deployment, constructor, lazy); err != nil {
 		if errCleanup := d.deploymentsStorage.Delete(ctx, *deployment.Id); errCleanup != nil {
 			err = errors.Wrap(err, errCleanup.Error())
 		}
@@ -132,9 +403,160 @@ func (d *DeploymentsModel) CreateDeployment(ctx context.Context,
 		return "", errors.Wrap(err, "Storing assigned deployments to devices")
 	}
 
+	if constructor.Supersede {
+		// the deployment is already stored, failing to supersede older
+		// deployments leaves them pending as if the option was not set
+		if err := d.supersedeDeployments(ctx, *deployment.Id,
+			constructor.Devices); err != nil {
+			log.FromContext(ctx).Errorf("failed to supersede deployments: %v", err)
+		}
+	}
+
 	return *deployment.Id, nil
 }
 
+// getPinnedArtifact returns the artifact selected by ID and sets
+// the deployment artifact name. Returns nil if artifact ID is not set.
+func (d *DeploymentsModel) getPinnedArtifact(ctx context.Context,
+	constructor *deployments.DeploymentConstructor) (*images.SoftwareImage, error) {
+
+	if constructor.ArtifactID == "" {
+		return nil, nil
+	}
+
+	artifact, err := d.artifactGetter.FindByID(ctx, constructor.ArtifactID)
+	if err != nil {
+		return nil, errors.Wrap(err, "Finding artifact with given ID")
+	}
+
+	if artifact == nil {
+		return nil, controller.ErrNoArtifact
+	}
+
+	if constructor.ArtifactName != nil && *constructor.ArtifactName != artifact.Name {
+		return nil, errors.Wrap(deployments.NewValidationError("artifact_name",
+			deployments.ValidationCodeInvalid,
+			"does not match name of the artifact with given ID"), "Validating deployment")
+	}
+
+	constructor.ArtifactName = &artifact.Name
+
+	return artifact, nil
+}
+
+// resolveLatestArtifact replaces the artifact name of auto_latest
+// deployment, a prefix of names, with the name of the release of
+// the highest version.
+func (d *DeploymentsModel) resolveLatestArtifact(ctx context.Context,
+	constructor *deployments.DeploymentConstructor) error {
+
+	if !constructor.AutoLatest {
+		return nil
+	}
+
+	artifacts, err := d.artifactGetter.ImagesByNamePrefix(ctx, *constructor.ArtifactName, "")
+	if err != nil {
+		return errors.Wrap(err, "Finding artifacts with given name prefix")
+	}
+
+	latest := d.versions.Latest(artifacts)
+	if latest == nil {
+		return controller.ErrNoArtifact
+	}
+
+	constructor.ArtifactName = &latest.Name
+
+	return nil
+}
+
+// resolveConflicts applies the conflict policy of the deployment to devices
+// which already have an active deployment. With the queue policy (default)
+// devices keep receiving deployments oldest first.
+func (d *DeploymentsModel) resolveConflicts(ctx context.Context,
+	constructor *deployments.DeploymentConstructor) error {
+
+	switch constructor.ConflictPolicy {
+	case deployments.ConflictPolicyReject, deployments.ConflictPolicySkip:
+	default:
+		return nil
+	}
+
+	statuses := deployments.ActiveDeploymentStatuses()
+	if constructor.Supersede {
+		// pending device deployments will be superseded
+		statuses = []string{
+			deployments.DeviceDeploymentStatusDownloading,
+			deployments.DeviceDeploymentStatusInstalling,
+			deployments.DeviceDeploymentStatusRebooting,
+		}
+	}
+
+	if err := d.expandDevices(ctx, constructor.Devices...); err != nil {
+		return err
+	}
+
+	conflicts, err := d.deviceDeploymentsStorage.FindDeviceIDsWithStatuses(ctx,
+		constructor.Devices, statuses...)
+	if err != nil {
+		return errors.Wrap(err, "Checking active deployments")
+	}
+
+	if len(conflicts) == 0 {
+		return nil
+	}
+
+	if constructor.ConflictPolicy == deployments.ConflictPolicyReject {
+		return errors.Wrapf(controller.ErrConflictingDeployment,
+			"Devices %s", strings.Join(conflicts, ", "))
+	}
+
+	skip := make(map[string]bool, len(conflicts))
+	for _, id := range conflicts {
+		skip[id] = true
+	}
+
+	devices := make([]string, 0, len(constructor.Devices))
+	for _, id := range constructor.Devices {
+		if !skip[id] {
+			devices = append(devices, id)
+		}
+	}
+
+	if len(devices) == 0 {
+		return errors.Wrap(controller.ErrConflictingDeployment,
+			"All devices have active deployments")
+	}
+
+	constructor.Devices = devices
+
+	return nil
+}
+
+// supersedeDeployments marks pending device deployments of older deployments
+// for the given devices as superseded and updates their statistics.
+func (d *DeploymentsModel) supersedeDeployments(ctx context.Context,
+	deploymentID string, deviceIDs []string) error {
+
+	superseded, err := d.deviceDeploymentsStorage.SupersedeDeviceDeployments(ctx,
+		deploymentID, deviceIDs)
+	if err != nil {
+		return err
+	}
+
+	for _, id := range superseded {
+		stats, err := d.aggregateStats(ctx, id)
+		if err != nil {
+			return err
+		}
+		if err := d.deploymentsStorage.UpdateStatsAndFinishDeployment(ctx,
+			id, stats); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 // IsDeploymentFinished checks if there is unfinished deployment with given ID
 func (d *DeploymentsModel) IsDeploymentFinished(ctx context.Context, deploymentID string) (bool, error) {
 
@@ -158,9 +580,52 @@ func (d *DeploymentsModel) GetDeployment(ctx context.Context,
 		return nil, errors.Wrap(err, "Searching for deployment by ID")
 	}
 
+	if deployment != nil {
+		if deployment.Changelog, err = d.getDeploymentChangelog(ctx, deployment); err != nil {
+			return nil, err
+		}
+	}
+
 	return deployment, nil
 }
 
+// getDeploymentChangelog joins changelogs of the artifacts assigned to the deployment.
+// Identical changelogs (e.g. the same release built for several device types) are included once.
+func (d *DeploymentsModel) getDeploymentChangelog(ctx context.Context,
+	deployment *deployments.Deployment) (string, error) {
+
+	if len(deployment.Artifacts) == 0 || deployment.DeploymentConstructor == nil ||
+		deployment.ArtifactName == nil {
+		return "", nil
+	}
+
+	artifacts, err := d.artifactGetter.ImagesByName(ctx, *deployment.ArtifactName)
+	if err != nil {
+		return "", errors.Wrap(err, "Searching for deployment artifacts")
+	}
+
+	assigned := make(map[string]bool, len(deployment.Artifacts))
+	for _, id := range deployment.Artifacts {
+		assigned[id] = true
+	}
+
+	var changelogs []string
+	seen := make(map[string]bool)
+	for _, artifact := range artifacts {
+		if !assigned[artifact.Id] {
+			continue
+		}
+		changelog := artifact.Changelog
+		if changelog == "" || seen[changelog] {
+			continue
+		}
+		seen[changelog] = true
+		changelogs = append(changelogs, changelog)
+	}
+
+	return strings.Join(changelogs, "\n\n"), nil
+}
+
 // ImageUsedInActiveDeployment checks if specified image is in use by deployments
 // Image is considered to be in use if it's participating in at lest one non success/error deployment.
 func (d *DeploymentsModel) ImageUsedInActiveDeployment(ctx context.Context,
@@ -209,6 +674,49 @@ func (d *DeploymentsModel) ImageUsedInDeployment(ctx context.Context, imageID st
 	return found, nil
 }
 
+// ImageDeployedSince checks if specified image was part of any deployment created after given time.
+func (d *DeploymentsModel) ImageDeployedSince(ctx context.Context, imageID string,
+	since time.Time) (bool, error) {
+
+	found, err := d.deploymentsStorage.ExistByArtifactIdCreatedAfter(ctx, imageID, since)
+	if err != nil {
+		return false, errors.Wrap(err, "Checking if image was deployed recently")
+	}
+
+	return found, nil
+}
+
+// restoreArtifacts starts restore of archived artifacts.
+// Returns true if any of the artifacts is not available for download yet.
+func (d *DeploymentsModel) restoreArtifacts(ctx context.Context,
+	artifacts []*images.SoftwareImage) (bool, error) {
+
+	restoring := false
+	for _, artifact := range artifacts {
+		available, err := d.isArtifactAvailable(ctx, artifact)
+		if err != nil {
+			return false, errors.Wrap(err, "Restoring archived artifact")
+		}
+		if !available {
+			restoring = true
+		}
+	}
+
+	return restoring, nil
+}
+
+// isArtifactAvailable checks if artifact file can be downloaded,
+// requesting restore of archived artifact if necessary.
+func (d *DeploymentsModel) isArtifactAvailable(ctx context.Context,
+	artifact *images.SoftwareImage) (bool, error) {
+
+	if !artifact.Archived || d.artifactRestorer == nil {
+		return true, nil
+	}
+
+	return d.artifactRestorer.Restore(ctx, artifact.FileID(), d.artifactRestoreDays)
+}
+
 // assignArtifact assignes artifact to the device deployment
 func (d *DeploymentsModel) assignArtifact(
 	ctx context.Context,
@@ -224,20 +732,36 @@ func (d *DeploymentsModel) assignArtifact(
 	// TODO: Should selecting different artifact be treated as an error?
 	deviceDeployment.Image = nil
 
-	// First case is for backward compatibility.
-	// It is possible that there is old deployment structure in the system.
-	// In such case we need to select artifact using name and device type.
-	if deployment.Artifacts == nil || len(deployment.Artifacts) == 0 {
-		artifact, err = d.artifactGetter.ImageByNameAndDeviceType(ctx, installed.Artifact, installed.DeviceType)
+	if len(deployment.Artifacts) > 0 {
+		if cached := d.artifactCache.get(ctx,
+			*deviceDeployment.DeploymentId, installed.DeviceType); cached != nil {
+			// Artifact was resolved for another device of the same type.
+			artifact = cached.artifact
+		}
+	}
+	if artifact == nil {
+		artifact, err = d.resolveArtifact(ctx, deployment, installed.Artifact,
+			installed.DeviceType)
 		if err != nil {
 			return errors.Wrap(err, "assigning artifact to device deployment")
 		}
-	} else {
-		// Select artifact for the device deployment from artifacts assgined to the deployment.
-		artifact, err = d.artifactGetter.ImageByIdsAndDeviceType(ctx, deployment.Artifacts, installed.DeviceType)
+	}
+
+	// Fall back to artifacts of device types aliased with the device type.
+	if artifact == nil {
+		aliased, err := d.equivalentDeviceTypes(ctx, installed.DeviceType)
 		if err != nil {
 			return errors.Wrap(err, "assigning artifact to device deployment")
 		}
+		for _, deviceType := range aliased {
+			artifact, err = d.resolveArtifact(ctx, deployment, installed.Artifact, deviceType)
+			if err != nil {
+				return errors.Wrap(err, "assigning artifact to device deployment")
+			}
+			if artifact != nil {
+				break
+			}
+		}
 	}
 
 	if deviceDeployment.DeploymentId == nil || deviceDeployment.DeviceId == nil {
@@ -250,6 +774,7 @@ func (d *DeploymentsModel) assignArtifact(
 			*deviceDeployment.DeviceId,
 			deployments.DeviceDeploymentStatus{
 				Status: deployments.DeviceDeploymentStatusNoArtifact,
+				Reason: deployments.NewNoArtifactReason(installed.DeviceType),
 			}); err != nil {
 			return errors.Wrap(err, "Failed to update deployment status")
 		}
@@ -267,15 +792,193 @@ func (d *DeploymentsModel) assignArtifact(
 	return nil
 }
 
+// resolveArtifact selects artifact of the deployment compatible with
+// the device type, nil if there is none.
+func (d *DeploymentsModel) resolveArtifact(ctx context.Context,
+	deployment *deployments.Deployment, installedArtifact, deviceType string) (
+	*images.SoftwareImage, error) {
+
+	// First case is for backward compatibility.
+	// It is possible that there is old deployment structure in the system.
+	// In such case we need to select artifact using name and device type.
+	if deployment.Artifacts == nil || len(deployment.Artifacts) == 0 {
+		return d.artifactGetter.ImageByNameAndDeviceType(ctx, installedArtifact, deviceType)
+	}
+
+	if len(deployment.DeviceTypeArtifacts) > 0 {
+		// Artifact was resolved when the deployment was created.
+		for _, a := range deployment.DeviceTypeArtifacts {
+			if a.DeviceType == deviceType {
+				return d.artifactGetter.FindByID(ctx, a.ArtifactID)
+			}
+		}
+		return nil, nil
+	}
+
+	// Select artifact for the device deployment from artifacts assgined to the deployment.
+	return d.artifactGetter.ImageByIdsAndDeviceType(ctx, deployment.Artifacts, deviceType)
+}
+
+// isDownloadAllowed checks if the download schedule of the deployment allows
+// issuing a download link now. For rate limited deployments one download slot
+// of the current minute is consumed.
+func (d *DeploymentsModel) isDownloadAllowed(ctx context.Context,
+	deployment *deployments.Deployment) (bool, error) {
+
+	if deployment.DeploymentConstructor == nil {
+		return true, nil
+	}
+
+	schedule := deployment.DownloadSchedule
+	now := time.Now()
+
+	if !schedule.IsOpen(now) {
+		return false, nil
+	}
+
+	if !schedule.IsRateLimited() {
+		return true, nil
+	}
+
+	count, err := d.deploymentsStorage.IncrementDownloadCount(ctx,
+		*deployment.Id, now.Truncate(time.Minute))
+	if err != nil {
+		return false, err
+	}
+
+	return count <= schedule.MaxDownloadsPerMinute, nil
+}
+
+// findActiveDeviceDeployment returns the oldest active deployment of the
+// device which can be served now, with the deployment it belongs to; nil if
+// there is none.
+// Replicas are read if the artifact of the device deployment is already
+// resolved for the installed device type, as serving the update check does
+// not write then; the primary is read otherwise, also because the replica
+// may be lagging behind.
+func (d *DeploymentsModel) findActiveDeviceDeployment(ctx context.Context,
+	deviceID string, installed deployments.InstalledDeviceDeployment) (
+	*deployments.DeviceDeployment, *deployments.Deployment, error) {
+
+	if d.replicaDeviceDeployments != nil && d.replicaDeploymentsStorage != nil {
+		resolved := func(deviceDeployment *deployments.DeviceDeployment) bool {
+			return deviceDeployment.Image != nil && deviceDeployment.DeviceType != nil &&
+				*deviceDeployment.DeviceType == installed.DeviceType
+		}
+		deviceDeployment, deployment, err := d.findServableDeviceDeployment(ctx,
+			d.replicaDeviceDeployments, d.replicaDeploymentsStorage, deviceID, resolved)
+		if err != nil {
+			log.FromContext(ctx).Warnf("failed to read device %s deployment from replica: %v",
+				deviceID, err)
+		} else if deviceDeployment != nil {
+			return deviceDeployment, deployment, nil
+		}
+	}
+
+	return d.findServableDeviceDeployment(ctx,
+		d.deviceDeploymentsStorage, d.deploymentsStorage, deviceID, nil)
+}
+
+// findServableDeviceDeployment returns the oldest active device deployment
+// of the device which is neither paused nor waiting for its phase to start.
+// Pending device deployments which are waiting are skipped, so that they
+// do not hold back newer deployments of the device; the device keeps
+// waiting if it already started on the oldest one.
+// Nothing is returned once a device deployment not accepted by the filter,
+// if set, is found.
+func (d *DeploymentsModel) findServableDeviceDeployment(ctx context.Context,
+	deviceDeployments DeviceDeploymentStorage, deploymentsStorage DeploymentsStorage,
+	deviceID string, accept func(*deployments.DeviceDeployment) bool) (
+	*deployments.DeviceDeployment, *deployments.Deployment, error) {
+
+	oldest, err := deviceDeployments.FindOldestDeploymentForDeviceIDWithStatuses(ctx,
+		deviceID, deployments.ActiveDeploymentStatuses()...)
+	if err != nil || oldest == nil {
+		return nil, nil, err
+	}
+	if accept != nil && !accept(oldest) {
+		return nil, nil, nil
+	}
+
+	deployment, servable, err := isDeviceDeploymentServable(ctx, deploymentsStorage, oldest)
+	if err != nil {
+		return nil, nil, err
+	}
+	if servable {
+		return oldest, deployment, nil
+	}
+	if deployment == nil || oldest.Status == nil ||
+		*oldest.Status != deployments.DeviceDeploymentStatusPending {
+		return nil, nil, nil
+	}
+
+	// the rare case of waiting device deployment, look for newer ones
+	candidates, err := deviceDeployments.FindAllDeploymentsForDeviceIDWithStatuses(ctx,
+		deviceID, deployments.DeviceDeploymentStatusPending)
+	if err != nil {
+		return nil, nil, err
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].Created.Before(*candidates[j].Created)
+	})
+
+	for i := range candidates {
+		candidate := &candidates[i]
+		if *candidate.Id == *oldest.Id {
+			continue
+		}
+		if accept != nil && !accept(candidate) {
+			return nil, nil, nil
+		}
+		deployment, servable, err := isDeviceDeploymentServable(ctx,
+			deploymentsStorage, candidate)
+		if err != nil {
+			return nil, nil, err
+		}
+		if servable {
+			return candidate, deployment, nil
+		}
+	}
+	return nil, nil, nil
+}
+
+// isDeviceDeploymentServable returns the deployment of the device
+// deployment and whether the device deployment can be served now; not if
+// the deployment is not found, its campaign is paused or its phase of the
+// device deployment has not started yet.
+func isDeviceDeploymentServable(ctx context.Context, deploymentsStorage DeploymentsStorage,
+	deviceDeployment *deployments.DeviceDeployment) (*deployments.Deployment, bool, error) {
+
+	deployment, err := deploymentsStorage.FindByID(ctx, *deviceDeployment.DeploymentId)
+	if err != nil {
+		return nil, false, controller.ErrModelInternal
+	}
+	if deployment == nil {
+		return nil, false, nil
+	}
+
+	// device will receive the deployment once its campaign is resumed, or
+	// its phase starts
+	servable := !deployment.Paused &&
+		deployment.IsPhaseStarted(deviceDeployment.Phase, time.Now())
+	return deployment, servable, nil
+}
+
 // GetDeploymentForDeviceWithCurrent returns deployment for the device
 func (d *DeploymentsModel) GetDeploymentForDeviceWithCurrent(ctx context.Context, deviceID string,
 	installed deployments.InstalledDeviceDeployment) (*deployments.DeploymentInstructions, error) {
 
-	deviceDeployment, err := d.deviceDeploymentsStorage.FindOldestDeploymentForDeviceIDWithStatuses(
-		ctx,
-		deviceID,
-		deployments.ActiveDeploymentStatuses()...)
+	if err := d.expandDevices(ctx, deviceID); err != nil {
+		return nil, err
+	}
+
+	d.recordInstalledArtifact(ctx, deviceID, installed)
 
+	deviceDeployment, deployment, err := d.findActiveDeviceDeployment(ctx,
+		deviceID, installed)
+	if err == controller.ErrModelInternal {
+		return nil, err
+	}
 	if err != nil {
 		return nil, errors.Wrap(err, "Searching for oldest active deployment for the device")
 	}
@@ -284,12 +987,8 @@ func (d *DeploymentsModel) GetDeploymentForDeviceWithCurrent(ctx context.Context
 		return nil, nil
 	}
 
-	deployment, err := d.deploymentsStorage.FindByID(ctx, *deviceDeployment.DeploymentId)
-	if err != nil {
-		return nil, controller.ErrModelInternal
-	}
-
-	if deployment == nil {
+	// artifact was pulled for the device
+	if deviceDeployment.LinkRevoked {
 		return nil, nil
 	}
 
@@ -300,6 +999,7 @@ func (d *DeploymentsModel) GetDeploymentForDeviceWithCurrent(ctx context.Context
 		if err := d.UpdateDeviceDeploymentStatus(ctx, *deviceDeployment.DeploymentId, deviceID,
 			deployments.DeviceDeploymentStatus{
 				Status: deployments.DeviceDeploymentStatusAlreadyInst,
+				Reason: deployments.NewAlreadyInstalledReason(installed.Artifact),
 			}); err != nil {
 
 			return nil, errors.Wrap(err, "Failed to update deployment status")
@@ -308,10 +1008,21 @@ func (d *DeploymentsModel) GetDeploymentForDeviceWithCurrent(ctx context.Context
 		return nil, nil
 	}
 
-	// assign artifact only if the artifact was not assigned previously or the device type has changed
-	if deviceDeployment.Image == nil || deviceDeployment.DeviceType == nil || *deviceDeployment.DeviceType != installed.DeviceType {
-		if err := d.assignArtifact(ctx, deployment, deviceDeployment, installed); err != nil {
-			return nil, err
+	// device will receive the deployment after the freeze period ends
+	if deployment.DeploymentConstructor == nil || !deployment.OverrideFreeze {
+		period, err := d.activeFreezePeriod(ctx)
+		if err != nil {
+			return nil, errors.Wrap(err, "Checking deployment freeze periods")
+		}
+		if period != nil {
+			return nil, nil
+		}
+	}
+
+	// assign artifact only if the artifact was not assigned previously or the device type has changed
+	if deviceDeployment.Image == nil || deviceDeployment.DeviceType == nil || *deviceDeployment.DeviceType != installed.DeviceType {
+		if err := d.assignArtifact(ctx, deployment, deviceDeployment, installed); err != nil {
+			return nil, err
 		}
 	}
 
@@ -319,8 +1030,25 @@ func (d *DeploymentsModel) GetDeploymentForDeviceWithCurrent(ctx context.Context
 		return nil, nil
 	}
 
-	link, err := d.imageLinker.GetRequest(ctx, deviceDeployment.Image.Id,
-		DefaultUpdateDownloadLinkExpire, d.imageContentType)
+	// device will receive the deployment once the artifact is restored
+	available, err := d.isArtifactAvailable(ctx, deviceDeployment.Image)
+	if err != nil {
+		return nil, errors.Wrap(err, "Restoring archived artifact")
+	}
+	if !available {
+		return nil, nil
+	}
+
+	// device will receive the deployment on one of the next update checks
+	allowed, err := d.isDownloadAllowed(ctx, deployment)
+	if err != nil {
+		return nil, errors.Wrap(err, "Checking deployment download schedule")
+	}
+	if !allowed {
+		return nil, nil
+	}
+
+	link, err := d.getDownloadLink(ctx, deployment, deviceDeployment)
 	if err != nil {
 		return nil, errors.Wrap(err, "Generating download link for the device")
 	}
@@ -328,15 +1056,89 @@ func (d *DeploymentsModel) GetDeploymentForDeviceWithCurrent(ctx context.Context
 	instructions := &deployments.DeploymentInstructions{
 		ID: *deviceDeployment.DeploymentId,
 		Artifact: deployments.ArtifactDeploymentInstructions{
-			ArtifactName:          deviceDeployment.Image.Name,
-			Source:                *link,
-			DeviceTypesCompatible: deviceDeployment.Image.DeviceTypesCompatible,
+			ArtifactName: deviceDeployment.Image.Name,
+			Source:       *link,
+			DeviceTypesCompatible: compatibleDeviceTypes(
+				deviceDeployment.Image.DeviceTypesCompatible, installed.DeviceType),
 		},
 	}
 
+	for _, hook := range d.preServeHooks {
+		if err := hook.PreServe(ctx, deviceID, instructions); err != nil {
+			// vetoed by policy, device will ask again on the next update check
+			if errors.Cause(err) == controller.ErrPolicyRejected {
+				return nil, nil
+			}
+			return nil, errors.Wrap(err, "Applying deployment policy")
+		}
+	}
+
+	// snapshot is kept for analysis only, do not fail the update check
+	if err := d.snapshotInventory(ctx, deviceDeployment); err != nil {
+		log.FromContext(ctx).Warnf("failed to snapshot inventory of device %s: %v",
+			deviceID, err)
+	}
+
+	d.recordDeviceDownload(ctx, deviceID, *deviceDeployment.DeploymentId,
+		deviceDeployment.Image)
+
+	// attempts are counted for visibility only, do not fail the update check
+	if err := d.deviceDeploymentsStorage.IncrementDeviceDeploymentAttempts(ctx,
+		deviceID, *deviceDeployment.DeploymentId); err != nil {
+		log.FromContext(ctx).Warnf("failed to count attempt of device %s: %v",
+			deviceID, err)
+	}
+
 	return instructions, nil
 }
 
+// getDownloadLink returns download link of the device deployment artifact.
+// Storage links are shared by devices of the same type within the cache
+// time to live, the rewrite to the device's mirror is applied per request.
+func (d *DeploymentsModel) getDownloadLink(ctx context.Context,
+	deployment *deployments.Deployment,
+	deviceDeployment *deployments.DeviceDeployment) (*images.Link, error) {
+
+	// the proxy redirects to storage links only if the link is not revoked
+	if d.downloadProxy != nil {
+		return d.downloadProxy.link(ctx, *deviceDeployment.DeploymentId,
+			*deviceDeployment.DeviceId, time.Now().Add(DefaultUpdateDownloadLinkExpire)), nil
+	}
+
+	// device type is not known for artifacts selected by name only
+	cacheable := len(deployment.Artifacts) > 0 && deviceDeployment.DeviceType != nil
+
+	var link *images.Link
+	if cacheable {
+		cached := d.artifactCache.get(ctx,
+			*deviceDeployment.DeploymentId, *deviceDeployment.DeviceType)
+		if cached != nil && cached.artifact.Id == deviceDeployment.Image.Id {
+			link = cached.link
+		}
+	}
+
+	if link == nil {
+		var err error
+		link, err = d.imageLinker.GetRequest(ctx, deviceDeployment.Image.FileID(),
+			DefaultUpdateDownloadLinkExpire, d.imageContentType)
+		if err != nil {
+			return nil, err
+		}
+
+		if cacheable {
+			d.artifactCache.store(ctx, *deviceDeployment.DeploymentId,
+				*deviceDeployment.DeviceType, deviceDeployment.Image, link)
+		}
+	}
+
+	// storage links are shared, rewriting depends on the requesting device
+	if d.linkRewriter != nil {
+		return d.linkRewriter.RewriteLink(ctx, link)
+	}
+
+	return link, nil
+}
+
 // UpdateDeviceDeploymentStatus will update the deployment status for device of
 // ID `deviceID`. Returns nil if update was successful.
 func (d *DeploymentsModel) UpdateDeviceDeploymentStatus(ctx context.Context, deploymentID string,
@@ -358,7 +1160,10 @@ func (d *DeploymentsModel) UpdateDeviceDeploymentStatus(ctx context.Context, dep
 		return err
 	}
 
-	if currentStatus == deployments.DeviceDeploymentStatusAborted {
+	// devices reporting after the deployment timed out for them get
+	// the same answer as after abort
+	if currentStatus == deployments.DeviceDeploymentStatusAborted ||
+		currentStatus == deployments.DeviceDeploymentStatusTimedOut {
 		return controller.ErrDeploymentAborted
 	}
 
@@ -366,22 +1171,53 @@ func (d *DeploymentsModel) UpdateDeviceDeploymentStatus(ctx context.Context, dep
 		return controller.ErrDeviceDecommissioned
 	}
 
+	// telemetry is kept also for repeated and out of order reports
+	d.recordTelemetry(ctx, deploymentID, deviceID, ddStatus)
+
 	// nothing to do
 	if ddStatus.Status == currentStatus {
 		return nil
 	}
 
+	ignore, err := d.isStatusReportOutOfOrder(ctx, deviceID, deploymentID,
+		currentStatus, ddStatus)
+	if err != nil {
+		return err
+	}
+	if ignore {
+		l.Warnf("Ignoring status %s of device %s deployment %s reported out of order, "+
+			"current status: %s", ddStatus.Status, deviceID, deploymentID, currentStatus)
+		if err := d.deviceDeploymentsStorage.IncrementDeviceDeploymentIgnoredReports(ctx,
+			deviceID, deploymentID); err != nil {
+			l.Warnf("failed to count ignored status report of device %s: %v", deviceID, err)
+		}
+		return nil
+	}
+
 	// update finish time
 	ddStatus.FinishTime = finishTime
 
-	old, err := d.deviceDeploymentsStorage.UpdateDeviceDeploymentStatus(ctx,
-		deviceID, deploymentID, ddStatus)
+	old, err := d.updateDeviceDeploymentStatus(ctx, deviceID, deploymentID,
+		currentStatus, ddStatus)
 	if err != nil {
 		return err
 	}
 
-	if err = d.deploymentsStorage.UpdateStats(ctx, deploymentID, old, ddStatus.Status); err != nil {
-		return err
+	// a download is observed once, on the status change it is reported with
+	d.observeDownload(ctx, deploymentID, deviceID, ddStatus)
+
+	if deployments.IsDeviceDeploymentStatusReset(old, ddStatus.Status) {
+		if err := d.deviceDeploymentsStorage.IncrementDeviceDeploymentStatusResets(ctx,
+			deviceID, deploymentID); err != nil {
+			l.Warnf("failed to count status reset of device %s: %v", deviceID, err)
+		}
+	}
+
+	// statistics rollups are best effort, do not fail the status update
+	if finishTime != nil {
+		if err := d.rollupStats(ctx, *finishTime, ddStatus.Status); err != nil {
+			l.Warnf("failed to update statistics rollup: %v", err)
+		}
 	}
 
 	// fetch deployment stats and update finished field if needed
@@ -397,11 +1233,39 @@ func (d *DeploymentsModel) UpdateDeviceDeploymentStatus(ctx context.Context, dep
 		if err := d.deploymentsStorage.Finish(ctx, deploymentID, time.Now()); err != nil {
 			return errors.Wrap(err, "failed to mark deployment as finished")
 		}
+		d.finalizeDeployment(ctx, deploymentID)
 	}
 
 	return nil
 }
 
+// updateDeviceDeploymentStatus writes the device deployment status, along
+// with the deployment statistics, and returns the replaced status.
+func (d *DeploymentsModel) updateDeviceDeploymentStatus(ctx context.Context,
+	deviceID, deploymentID, current string,
+	ddStatus deployments.DeviceDeploymentStatus) (string, error) {
+
+	if d.statusBatcher != nil {
+		old, err := d.statusBatcher.update(ctx, deviceID, deploymentID,
+			current, ddStatus)
+		if err != errStatusNotBatched {
+			return old, err
+		}
+	}
+
+	old, err := d.deviceDeploymentsStorage.UpdateDeviceDeploymentStatus(ctx,
+		deviceID, deploymentID, ddStatus)
+	if err != nil {
+		return "", err
+	}
+
+	if err = d.deploymentsStorage.UpdateStats(ctx, deploymentID, old, ddStatus.Status); err != nil {
+		return "", err
+	}
+
+	return old, nil
+}
+
 func (d *DeploymentsModel) GetDeploymentStats(ctx context.Context,
 	deploymentID string) (deployments.Stats, error) {
 
@@ -415,7 +1279,190 @@ func (d *DeploymentsModel) GetDeploymentStats(ctx context.Context,
 		return nil, nil
 	}
 
-	return d.deviceDeploymentsStorage.AggregateDeviceDeploymentByStatus(ctx, deploymentID)
+	return d.aggregateStats(ctx, deploymentID)
+}
+
+// GetDeploymentFailureStats counts failed device deployments of the
+// deployment by reported error category.
+func (d *DeploymentsModel) GetDeploymentFailureStats(ctx context.Context,
+	deploymentID string) (deployments.FailureStats, error) {
+
+	deployment, err := d.deploymentsStorage.FindByID(ctx, deploymentID)
+	if err != nil {
+		return nil, errors.Wrap(err, "checking deployment id")
+	}
+
+	if deployment == nil {
+		return nil, nil
+	}
+
+	return d.deviceDeploymentsStorage.AggregateDeviceDeploymentFailures(ctx, deploymentID)
+}
+
+// GetDeploymentDurationStats counts finished device deployments of the
+// deployment by time it took them to succeed or fail.
+func (d *DeploymentsModel) GetDeploymentDurationStats(ctx context.Context,
+	deploymentID string) (deployments.DurationStats, error) {
+
+	deployment, err := d.deploymentsStorage.FindByID(ctx, deploymentID)
+	if err != nil {
+		return nil, errors.Wrap(err, "checking deployment id")
+	}
+
+	if deployment == nil {
+		return nil, nil
+	}
+
+	return d.deviceDeploymentsStorage.AggregateDeviceDeploymentDurations(ctx, deploymentID)
+}
+
+// GetDeploymentStatsByGroup computes deployment statistics broken down by
+// inventory group or by value of the given device attribute.
+func (d *DeploymentsModel) GetDeploymentStatsByGroup(ctx context.Context,
+	deploymentID string, attribute string) (deployments.GroupStats, error) {
+
+	deployment, err := d.deploymentsStorage.FindByID(ctx, deploymentID)
+	if err != nil {
+		return nil, errors.Wrap(err, "checking deployment id")
+	}
+
+	if deployment == nil {
+		return nil, nil
+	}
+
+	statuses, err := d.deviceDeploymentsStorage.GetDeviceStatusesForDeployment(ctx, deploymentID)
+	if err != nil {
+		return nil, errors.Wrap(err, "Searching for device deployments")
+	}
+
+	stats := make(deployments.GroupStats)
+	for _, status := range statuses {
+		if status.DeviceId == nil || status.Status == nil {
+			continue
+		}
+
+		// attributes snapshotted when the deployment was served take
+		// precedence over the current inventory
+		group, ok := status.Inventory[attribute]
+		if !ok {
+			group, err = d.getDeviceGroup(ctx, *status.DeviceId, attribute)
+			if err != nil {
+				return nil, errors.Wrap(err, "Fetching device inventory")
+			}
+		}
+
+		if _, ok := stats[group]; !ok {
+			stats[group] = deployments.NewDeviceDeploymentStats()
+		}
+		stats[group][*status.Status]++
+	}
+
+	return stats, nil
+}
+
+// getDeviceGroup resolves value of the device attribute used for grouping.
+func (d *DeploymentsModel) getDeviceGroup(ctx context.Context,
+	deviceID string, attribute string) (string, error) {
+
+	if attribute == DeviceAttributeGroup {
+		group, err := d.inventory.GetDeviceGroup(ctx, integration.DeviceID(deviceID))
+		if err != nil {
+			return "", err
+		}
+		if group == "" {
+			return deployments.GroupStatsUnknown, nil
+		}
+		return group, nil
+	}
+
+	device, err := d.inventory.GetDeviceInventory(ctx, integration.DeviceID(deviceID))
+	if err != nil {
+		return "", err
+	}
+
+	if device != nil {
+		for _, attr := range device.Attributes {
+			if attr != nil && attr.Name == attribute && attr.Value != nil {
+				return fmt.Sprint(attr.Value), nil
+			}
+		}
+	}
+
+	return deployments.GroupStatsUnknown, nil
+}
+
+// GetLatestDeviceDeployment returns the most recent deployment of the device,
+// optionally limited to the given deployments; nil if there is none.
+func (d *DeploymentsModel) GetLatestDeviceDeployment(ctx context.Context,
+	deviceID string, deploymentIDs []string) (*deployments.DeviceDeployment, error) {
+
+	deviceDeployment, err := d.deviceDeploymentsStorage.FindLatestDeploymentForDeviceID(ctx,
+		deviceID, deploymentIDs...)
+	if err != nil {
+		return nil, errors.Wrap(err, "Searching for latest deployment for the device")
+	}
+
+	return deviceDeployment, nil
+}
+
+// GetActiveDeviceDeployments returns active deployments of each of the
+// devices, oldest first, looked up at once; devices without active
+// deployments are listed with none.
+func (d *DeploymentsModel) GetActiveDeviceDeployments(ctx context.Context,
+	deviceIDs []string) (map[string][]deployments.DeviceDeployment, error) {
+
+	list, err := d.deviceDeploymentsStorage.FindDeploymentsForDeviceIDsWithStatuses(ctx,
+		deviceIDs, deployments.ActiveDeploymentStatuses()...)
+	if err != nil {
+		return nil, errors.Wrap(err, "Searching for active deployments of the devices")
+	}
+
+	active := make(map[string][]deployments.DeviceDeployment, len(deviceIDs))
+	for _, deviceID := range deviceIDs {
+		active[deviceID] = []deployments.DeviceDeployment{}
+	}
+	for _, deviceDeployment := range list {
+		if deviceDeployment.DeviceId == nil {
+			continue
+		}
+		deviceID := *deviceDeployment.DeviceId
+		active[deviceID] = append(active[deviceID], deviceDeployment)
+	}
+
+	return active, nil
+}
+
+// GetStatsSummary computes tenant wide deployment statistics.
+// Device statistics are read from hourly rollups maintained on device
+// deployment status updates.
+func (d *DeploymentsModel) GetStatsSummary(ctx context.Context) (*deployments.StatsSummary, error) {
+	var count deployments.DeploymentsCount
+
+	for status, out := range map[deployments.StatusQuery]*int{
+		deployments.StatusQueryPending:    &count.Pending,
+		deployments.StatusQueryInProgress: &count.InProgress,
+		deployments.StatusQueryFinished:   &count.Finished,
+	} {
+		n, err := d.deploymentsStorage.CountByStatus(ctx, status)
+		if err != nil {
+			return nil, errors.Wrap(err, "Counting deployments")
+		}
+		*out = n
+	}
+
+	now := time.Now()
+
+	day, err := d.deploymentsStorage.AggregateStatsRollups(ctx, now.Add(-24*time.Hour))
+	if err != nil {
+		return nil, errors.Wrap(err, "Aggregating device deployment statistics")
+	}
+
+	week, err := d.deploymentsStorage.AggregateStatsRollups(ctx, now.Add(-7*24*time.Hour))
+	if err != nil {
+		return nil, errors.Wrap(err, "Aggregating device deployment statistics")
+	}
+
+	return deployments.NewStatsSummary(count, day, week), nil
 }
 
 //GetDeviceStatusesForDeployment retrieve device deployment statuses for a given deployment.
@@ -439,6 +1486,53 @@ func (d *DeploymentsModel) GetDeviceStatusesForDeployment(ctx context.Context,
 	return statuses, nil
 }
 
+// GetDeviceDeploymentsCount counts devices of the deployment,
+// only devices in the given status if status is not empty.
+func (d *DeploymentsModel) GetDeviceDeploymentsCount(ctx context.Context,
+	deploymentID, status string) (int, error) {
+
+	deployment, err := d.deploymentsStorage.FindByID(ctx, deploymentID)
+	if err != nil {
+		return 0, errors.Wrap(err, "Searching for deployment by ID")
+	}
+
+	if deployment == nil {
+		return 0, controller.ErrModelDeploymentNotFound
+	}
+
+	count, err := d.deviceDeploymentsStorage.CountDeviceDeployments(ctx, deploymentID, status)
+	if err != nil {
+		return 0, errors.Wrap(err, "Counting device deployments")
+	}
+
+	if d.deploymentDevicesStorage != nil &&
+		(status == "" || status == deployments.DeviceDeploymentStatusPending) {
+		pending, err := d.deploymentDevicesStorage.CountDeploymentDevices(ctx, deploymentID)
+		if err != nil {
+			return 0, errors.Wrap(err, "Counting device deployments")
+		}
+		count += pending
+	}
+
+	return count, nil
+}
+
+// LookupDeviceDeployments finds device deployments across all deployments.
+func (d *DeploymentsModel) LookupDeviceDeployments(ctx context.Context,
+	query deployments.DeviceDeploymentsQuery) ([]deployments.DeviceDeployment, error) {
+
+	list, err := d.deviceDeploymentsStorage.FindDeviceDeployments(ctx, query)
+	if err != nil {
+		return nil, errors.Wrap(err, "searching for device deployments")
+	}
+
+	if list == nil {
+		return make([]deployments.DeviceDeployment, 0), nil
+	}
+
+	return list, nil
+}
+
 func (d *DeploymentsModel) LookupDeployment(ctx context.Context,
 	query deployments.Query) ([]*deployments.Deployment, error) {
 	list, err := d.deploymentsStorage.Find(ctx, query)
@@ -486,12 +1580,82 @@ func (d *DeploymentsModel) SaveDeviceDeploymentLog(ctx context.Context, deviceID
 		}
 	}
 
+	if d.deviceLogsSearch {
+		// log is still stored, but cannot be found by search
+		if err := d.deviceDeploymentLogsStorage.EnsureSearchIndex(ctx); err != nil {
+			log.FromContext(ctx).Warnf("failed to create log search index: %v", err)
+		}
+	}
+
 	if err := d.deviceDeploymentLogsStorage.SaveDeviceDeploymentLog(ctx, dlog); err != nil {
 		return err
 	}
 
-	return d.deviceDeploymentsStorage.UpdateDeviceDeploymentLogAvailability(ctx,
-		deviceID, deploymentID, true)
+	if err := d.deviceDeploymentsStorage.UpdateDeviceDeploymentLogAvailability(ctx,
+		deviceID, deploymentID, true); err != nil {
+		return err
+	}
+
+	// notification is best effort, the log is already stored
+	if err := d.notifyLogAvailable(ctx, deviceID, deploymentID); err != nil {
+		log.FromContext(ctx).Warnf("failed to publish log availability: %v", err)
+	}
+
+	return nil
+}
+
+// SearchDeviceDeploymentLogs finds devices of the deployment with logs
+// containing the given text.
+func (d *DeploymentsModel) SearchDeviceDeploymentLogs(ctx context.Context,
+	deploymentID, text string) ([]string, error) {
+
+	if !d.deviceLogsSearch {
+		return nil, controller.ErrLogSearchDisabled
+	}
+
+	deployment, err := d.deploymentsStorage.FindByID(ctx, deploymentID)
+	if err != nil {
+		return nil, errors.Wrap(err, "Searching for deployment by ID")
+	}
+
+	if deployment == nil {
+		return nil, controller.ErrModelDeploymentNotFound
+	}
+
+	devices, err := d.deviceDeploymentLogsStorage.SearchDeviceDeploymentLogs(ctx,
+		deploymentID, text)
+	if err != nil {
+		return nil, errors.Wrap(err, "Searching device deployment logs")
+	}
+
+	return devices, nil
+}
+
+// notifyLogAvailable publishes log availability event if the device
+// deployment has failed.
+func (d *DeploymentsModel) notifyLogAvailable(ctx context.Context,
+	deviceID, deploymentID string) error {
+
+	if d.eventPublisher == nil {
+		return nil
+	}
+
+	status, err := d.deviceDeploymentsStorage.GetDeviceDeploymentStatus(ctx,
+		deploymentID, deviceID)
+	if err != nil {
+		return err
+	}
+
+	if status != deployments.DeviceDeploymentStatusFailure {
+		return nil
+	}
+
+	event := deployments.NewEvent(deployments.EventDeviceDeploymentLogAvailable,
+		deploymentID)
+	event.DeviceID = deviceID
+	event.Status = status
+
+	return d.eventPublisher.Publish(ctx, event)
 }
 
 func (d *DeploymentsModel) GetDeviceDeploymentLog(ctx context.Context,
@@ -506,34 +1670,210 @@ func (d *DeploymentsModel) HasDeploymentForDevice(ctx context.Context,
 	return d.deviceDeploymentsStorage.HasDeploymentForDevice(ctx, deploymentID, deviceID)
 }
 
-// AbortDeployment aborts deployment for devices and updates deployment stats
-func (d *DeploymentsModel) AbortDeployment(ctx context.Context, deploymentID string) error {
+// AbortDeployment aborts deployment for devices and updates deployment stats.
+// Abort details are recorded on the deployment and affected device deployments.
+// Only device deployments which did not finish yet are aborted; finished
+// deployments cannot be aborted.
+func (d *DeploymentsModel) AbortDeployment(ctx context.Context, deploymentID string,
+	abort *deployments.AbortInfo) error {
+
+	if err := d.checkDeploymentUnfinished(ctx, deploymentID); err != nil {
+		return err
+	}
+
+	if abort == nil {
+		abort = &deployments.AbortInfo{}
+	}
+	if abort.Aborted == nil {
+		now := time.Now()
+		abort.Aborted = &now
+	}
+
+	// devices without device deployment are counted as aborted,
+	// their device deployments are never created
+	notStarted := 0
+	if d.deploymentDevicesStorage != nil {
+		var err error
+		notStarted, err = d.deploymentDevicesStorage.DeleteDeploymentDevices(ctx,
+			deploymentID)
+		if err != nil {
+			return err
+		}
+	}
+
+	updating, err := d.updatingDevices(ctx, deploymentID)
+	if err != nil {
+		return err
+	}
+
+	if err := d.deviceDeploymentsStorage.AbortDeviceDeployments(ctx, deploymentID,
+		abort); err != nil {
+		return err
+	}
 
-	if err := d.deviceDeploymentsStorage.AbortDeviceDeployments(ctx, deploymentID); err != nil {
+	if err := d.deploymentsStorage.SetAbortInfo(ctx, deploymentID, abort); err != nil {
 		return err
 	}
 
-	stats, err := d.deviceDeploymentsStorage.AggregateDeviceDeploymentByStatus(
-		ctx, deploymentID)
+	d.notifyAborted(ctx, deploymentID, updating)
+
+	stats, err := d.aggregateStats(ctx, deploymentID)
 	if err != nil {
 		return err
 	}
+	if notStarted > 0 {
+		stats[deployments.DeviceDeploymentStatusAborted] += notStarted
+	}
 
 	// Update deployment stats and finish deployment (set finished timestamp to current time)
 	// Aborted deployment is considered to be finished even if some devices are
 	// still processing this deployment.
-	return d.deploymentsStorage.UpdateStatsAndFinishDeployment(ctx,
-		deploymentID, stats)
+	if err := d.deploymentsStorage.UpdateStatsAndFinishDeployment(ctx,
+		deploymentID, stats); err != nil {
+		return err
+	}
+
+	d.finalizeDeployment(ctx, deploymentID)
+	return nil
+}
+
+// ForceFinishDeployment finishes the deployment without waiting for devices
+// which will never report, e.g. of retired fleets. Device deployments which
+// did not finish yet get the terminal status, aborted or timed out.
+func (d *DeploymentsModel) ForceFinishDeployment(ctx context.Context,
+	deploymentID string, status string, abort *deployments.AbortInfo) error {
+
+	switch status {
+	case deployments.DeviceDeploymentStatusAborted:
+		return d.AbortDeployment(ctx, deploymentID, abort)
+	case deployments.DeviceDeploymentStatusTimedOut:
+	default:
+		return controller.ErrInvalidFinishStatus
+	}
+
+	if err := d.checkDeploymentUnfinished(ctx, deploymentID); err != nil {
+		return err
+	}
+
+	// devices without device deployment time out as well
+	notStarted := 0
+	if d.deploymentDevicesStorage != nil {
+		var err error
+		notStarted, err = d.deploymentDevicesStorage.DeleteDeploymentDevices(ctx,
+			deploymentID)
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := d.deviceDeploymentsStorage.FinishDeviceDeployments(ctx, deploymentID,
+		status, time.Now()); err != nil {
+		return errors.Wrap(err, "Finishing device deployments")
+	}
+
+	stats, err := d.aggregateStats(ctx, deploymentID)
+	if err != nil {
+		return err
+	}
+	if notStarted > 0 {
+		stats[status] += notStarted
+	}
+
+	if err := d.deploymentsStorage.UpdateStatsAndFinishDeployment(ctx,
+		deploymentID, stats); err != nil {
+		return err
+	}
+
+	d.finalizeDeployment(ctx, deploymentID)
+	return nil
+}
+
+// checkDeploymentUnfinished returns error if the deployment does not exist
+// or is finished already.
+func (d *DeploymentsModel) checkDeploymentUnfinished(ctx context.Context,
+	deploymentID string) error {
+
+	deployment, err := d.deploymentsStorage.FindByID(ctx, deploymentID)
+	if err != nil {
+		return errors.Wrap(err, "Searching for deployment by ID")
+	}
+	if deployment == nil {
+		return controller.ErrModelDeploymentNotFound
+	}
+	if deployment.Finished != nil {
+		return controller.ErrDeploymentAlreadyFinished
+	}
+	return nil
+}
+
+// updatingDevices returns devices in the middle of the deployment update,
+// to be notified if the deployment is aborted.
+func (d *DeploymentsModel) updatingDevices(ctx context.Context,
+	deploymentID string) ([]string, error) {
+
+	if d.deviceNotifier == nil {
+		return nil, nil
+	}
+
+	deviceDeployments, err := d.deviceDeploymentsStorage.GetDeviceStatusesForDeployment(ctx,
+		deploymentID)
+	if err != nil {
+		return nil, errors.Wrap(err, "Searching for devices to notify")
+	}
+
+	var devices []string
+	for _, deviceDeployment := range deviceDeployments {
+		if deviceDeployment.Status == nil || deviceDeployment.DeviceId == nil {
+			continue
+		}
+		switch *deviceDeployment.Status {
+		case deployments.DeviceDeploymentStatusDownloading,
+			deployments.DeviceDeploymentStatusInstalling,
+			deployments.DeviceDeploymentStatusRebooting:
+			devices = append(devices, *deviceDeployment.DeviceId)
+		}
+	}
+	return devices, nil
+}
+
+// notifyAborted pushes abort of the deployment to the devices; notifications
+// are best effort, devices learn of the abort on their next update check
+// anyway.
+func (d *DeploymentsModel) notifyAborted(ctx context.Context,
+	deploymentID string, devices []string) {
+
+	for _, deviceID := range devices {
+		event := deployments.NewEvent(deployments.EventDeviceDeploymentAborted,
+			deploymentID)
+		event.DeviceID = deviceID
+		event.Status = deployments.DeviceDeploymentStatusAborted
+
+		if err := d.deviceNotifier.Publish(ctx, event); err != nil {
+			log.FromContext(ctx).Warnf("failed to notify device %s of aborted deployment %s: %v",
+				deviceID, deploymentID, err)
+		}
+	}
 }
 
 func (d *DeploymentsModel) DecommissionDevice(ctx context.Context, deviceId string) error {
 
+	if err := d.expandDevices(ctx, deviceId); err != nil {
+		return err
+	}
+
 	if err := d.deviceDeploymentsStorage.DecommissionDeviceDeployments(ctx,
 		deviceId); err != nil {
 
 		return err
 	}
 
+	if d.installedArtifactsStorage != nil {
+		if err := d.installedArtifactsStorage.DeleteInstalledArtifact(ctx,
+			deviceId); err != nil {
+			return err
+		}
+	}
+
 	//get all affected deployments and update its stats
 	deviceDeployments, err := d.deviceDeploymentsStorage.FindAllDeploymentsForDeviceIDWithStatuses(
 		ctx,
@@ -545,8 +1885,7 @@ func (d *DeploymentsModel) DecommissionDevice(ctx context.Context, deviceId stri
 
 	for _, deviceDeployment := range deviceDeployments {
 
-		stats, err := d.deviceDeploymentsStorage.AggregateDeviceDeploymentByStatus(
-			ctx, *deviceDeployment.DeploymentId)
+		stats, err := d.aggregateStats(ctx, *deviceDeployment.DeploymentId)
 		if err != nil {
 			return err
 		}
@@ -18,17 +18,21 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net"
 	"testing"
 	"time"
 
+	"github.com/mendersoftware/go-lib-micro/identity"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 
+	"github.com/mendersoftware/deployments/integration"
 	"github.com/mendersoftware/deployments/resources/deployments"
 	"github.com/mendersoftware/deployments/resources/deployments/controller"
 	. "github.com/mendersoftware/deployments/resources/deployments/model"
 	"github.com/mendersoftware/deployments/resources/deployments/model/mocks"
 	"github.com/mendersoftware/deployments/resources/images"
+	"github.com/mendersoftware/deployments/resources/images/mirror"
 	. "github.com/mendersoftware/deployments/utils/pointers"
 	h "github.com/mendersoftware/deployments/utils/testing"
 )
@@ -43,6 +47,8 @@ func TestDeploymentModelGetDeployment(t *testing.T) {
 		InputDeploymentID       string
 		InoutFindByIDDeployment *deployments.Deployment
 		InoutFindByIDError      error
+		InputImagesByName       []*images.SoftwareImage
+		InputImagesByNameError  error
 
 		OutputError      error
 		OutputDeployment *deployments.Deployment
@@ -62,6 +68,62 @@ func TestDeploymentModelGetDeployment(t *testing.T) {
 
 			OutputDeployment: new(deployments.Deployment),
 		},
+		{
+			InputDeploymentID: "123",
+			InoutFindByIDDeployment: &deployments.Deployment{
+				DeploymentConstructor: &deployments.DeploymentConstructor{
+					ArtifactName: StringToPointer("App 123"),
+				},
+				Artifacts: []string{"a1", "a2", "a3"},
+			},
+			InputImagesByName: []*images.SoftwareImage{
+				{
+					Id: "a1",
+					SoftwareImageMetaConstructor: images.SoftwareImageMetaConstructor{
+						Changelog: "* fix A",
+					},
+				},
+				{
+					Id: "a2",
+					SoftwareImageMetaConstructor: images.SoftwareImageMetaConstructor{
+						Changelog: "* fix A",
+					},
+				},
+				{
+					Id: "a3",
+					SoftwareImageMetaConstructor: images.SoftwareImageMetaConstructor{
+						Changelog: "* fix B",
+					},
+				},
+				{
+					// uploaded after the deployment was created
+					Id: "a4",
+					SoftwareImageMetaConstructor: images.SoftwareImageMetaConstructor{
+						Changelog: "* fix C",
+					},
+				},
+			},
+
+			OutputDeployment: &deployments.Deployment{
+				DeploymentConstructor: &deployments.DeploymentConstructor{
+					ArtifactName: StringToPointer("App 123"),
+				},
+				Artifacts: []string{"a1", "a2", "a3"},
+				Changelog: "* fix A\n\n* fix B",
+			},
+		},
+		{
+			InputDeploymentID: "123",
+			InoutFindByIDDeployment: &deployments.Deployment{
+				DeploymentConstructor: &deployments.DeploymentConstructor{
+					ArtifactName: StringToPointer("App 123"),
+				},
+				Artifacts: []string{"a1"},
+			},
+			InputImagesByNameError: errors.New("storage error"),
+
+			OutputError: errors.New("Searching for deployment artifacts: storage error"),
+		},
 	}
 
 	for testCaseNumber, testCase := range testCases {
@@ -73,7 +135,15 @@ func TestDeploymentModelGetDeployment(t *testing.T) {
 				testCase.InputDeploymentID).
 				Return(testCase.InoutFindByIDDeployment, testCase.InoutFindByIDError)
 
-			model := NewDeploymentModel(DeploymentsModelConfig{DeploymentsStorage: deploymentStorage})
+			artifactGetter := new(mocks.ArtifactGetter)
+			artifactGetter.On("ImagesByName",
+				h.ContextMatcher(), mock.AnythingOfType("string")).
+				Return(testCase.InputImagesByName, testCase.InputImagesByNameError)
+
+			model := NewDeploymentModel(DeploymentsModelConfig{
+				DeploymentsStorage: deploymentStorage,
+				ArtifactGetter:     artifactGetter,
+			})
 
 			deployment, err := model.GetDeployment(context.Background(),
 				testCase.InputDeploymentID)
@@ -247,6 +317,9 @@ func TestDeploymentModelGetDeploymentForDevice(t *testing.T) {
 			},
 		})
 
+	archivedImage := *image
+	archivedImage.Archived = true
+
 	testCases := []struct {
 		InputID string
 
@@ -267,8 +340,23 @@ func TestDeploymentModelGetDeploymentForDevice(t *testing.T) {
 		InputExistUnfinishedByArtifactIdFlag bool
 		ExistUnfinishedByArtifactIdError     error
 
+		InputDownloadSchedule *deployments.DownloadSchedule
+		InputDownloadCount    int
+
+		InputPreServeHookError error
+
+		InputRestored     bool
+		InputRestoreError error
+
+		InputFreezePeriod      *deployments.FreezePeriod
+		InputFreezePeriodError error
+		InputOverrideFreeze    bool
+
+		InputPaused bool
+
 		OutputError                  error
 		OutputDeploymentInstructions *deployments.DeploymentInstructions
+		OutputReason                 *deployments.DeviceDeploymentReason
 	}{
 		{
 			InputID: "ID:123",
@@ -333,6 +421,20 @@ func TestDeploymentModelGetDeploymentForDevice(t *testing.T) {
 				},
 			},
 		},
+		{
+			// download link revoked for the device
+			InputID: "ID:123",
+			InputOlderstDeviceDeployment: &deployments.DeviceDeployment{
+				Image:        image,
+				DeviceId:     StringToPointer("ID:123"),
+				DeploymentId: StringToPointer("ID:678"),
+				LinkRevoked:  true,
+			},
+			InputArtifact:       image,
+			InputGetRequestLink: &images.Link{},
+
+			OutputDeploymentInstructions: nil,
+		},
 		{
 			// currently installed artifact is the same as defined by deployment
 			InputID: "ID:123",
@@ -348,6 +450,196 @@ func TestDeploymentModelGetDeploymentForDevice(t *testing.T) {
 				Artifact:   image.Name,
 				DeviceType: "hammer",
 			},
+
+			OutputReason: deployments.NewAlreadyInstalledReason(image.Name),
+		},
+		{
+			// outside of download window
+			InputID: "ID:123",
+			InputOlderstDeviceDeployment: &deployments.DeviceDeployment{
+				Image:        image,
+				DeviceId:     StringToPointer("ID:123"),
+				DeploymentId: StringToPointer("ID:678"),
+			},
+			InputArtifact:       image,
+			InputGetRequestLink: &images.Link{},
+			InputDownloadSchedule: &deployments.DownloadSchedule{
+				Windows: []deployments.DownloadWindow{
+					{
+						Start: time.Now().UTC().Add(2 * time.Hour).Format("15:04"),
+						End:   time.Now().UTC().Add(3 * time.Hour).Format("15:04"),
+					},
+				},
+			},
+		},
+		{
+			// download rate limit exceeded
+			InputID: "ID:123",
+			InputOlderstDeviceDeployment: &deployments.DeviceDeployment{
+				Image:        image,
+				DeviceId:     StringToPointer("ID:123"),
+				DeploymentId: StringToPointer("ID:678"),
+			},
+			InputArtifact:       image,
+			InputGetRequestLink: &images.Link{},
+			InputDownloadSchedule: &deployments.DownloadSchedule{
+				MaxDownloadsPerMinute: 10,
+			},
+			InputDownloadCount: 11,
+		},
+		{
+			// within download rate limit
+			InputID: "ID:123",
+			InputOlderstDeviceDeployment: &deployments.DeviceDeployment{
+				Image:        image,
+				DeviceId:     StringToPointer("ID:123"),
+				DeploymentId: StringToPointer("ID:678"),
+			},
+			InputArtifact:       image,
+			InputGetRequestLink: &images.Link{},
+			InputDownloadSchedule: &deployments.DownloadSchedule{
+				MaxDownloadsPerMinute: 10,
+			},
+			InputDownloadCount: 10,
+
+			OutputDeploymentInstructions: &deployments.DeploymentInstructions{
+				ID: "ID:678",
+				Artifact: deployments.ArtifactDeploymentInstructions{
+					ArtifactName:          image.Name,
+					Source:                images.Link{},
+					DeviceTypesCompatible: image.DeviceTypesCompatible,
+				},
+			},
+		},
+		{
+			// vetoed by deployment policy
+			InputID: "ID:123",
+			InputOlderstDeviceDeployment: &deployments.DeviceDeployment{
+				Image:        image,
+				DeviceId:     StringToPointer("ID:123"),
+				DeploymentId: StringToPointer("ID:678"),
+			},
+			InputArtifact:          image,
+			InputGetRequestLink:    &images.Link{},
+			InputPreServeHookError: controller.ErrPolicyRejected,
+		},
+		{
+			// deployment policy failure
+			InputID: "ID:123",
+			InputOlderstDeviceDeployment: &deployments.DeviceDeployment{
+				Image:        image,
+				DeviceId:     StringToPointer("ID:123"),
+				DeploymentId: StringToPointer("ID:678"),
+			},
+			InputArtifact:          image,
+			InputGetRequestLink:    &images.Link{},
+			InputPreServeHookError: errors.New("policy endpoint unreachable"),
+
+			OutputError: errors.New("Applying deployment policy: policy endpoint unreachable"),
+		},
+		{
+			// artifact is being restored from archive
+			InputID: "ID:123",
+			InputOlderstDeviceDeployment: &deployments.DeviceDeployment{
+				Image:        &archivedImage,
+				DeviceId:     StringToPointer("ID:123"),
+				DeploymentId: StringToPointer("ID:678"),
+			},
+			InputArtifact:       &archivedImage,
+			InputGetRequestLink: &images.Link{},
+		},
+		{
+			// artifact restore failure
+			InputID: "ID:123",
+			InputOlderstDeviceDeployment: &deployments.DeviceDeployment{
+				Image:        &archivedImage,
+				DeviceId:     StringToPointer("ID:123"),
+				DeploymentId: StringToPointer("ID:678"),
+			},
+			InputArtifact:       &archivedImage,
+			InputGetRequestLink: &images.Link{},
+			InputRestoreError:   errors.New("s3 error"),
+
+			OutputError: errors.New("Restoring archived artifact: s3 error"),
+		},
+		{
+			// artifact restored from archive
+			InputID: "ID:123",
+			InputOlderstDeviceDeployment: &deployments.DeviceDeployment{
+				Image:        &archivedImage,
+				DeviceId:     StringToPointer("ID:123"),
+				DeploymentId: StringToPointer("ID:678"),
+			},
+			InputArtifact:       &archivedImage,
+			InputGetRequestLink: &images.Link{},
+			InputRestored:       true,
+
+			OutputDeploymentInstructions: &deployments.DeploymentInstructions{
+				ID: "ID:678",
+				Artifact: deployments.ArtifactDeploymentInstructions{
+					ArtifactName:          image.Name,
+					Source:                images.Link{},
+					DeviceTypesCompatible: image.DeviceTypesCompatible,
+				},
+			},
+		},
+		{
+			// deployments are frozen
+			InputID: "ID:123",
+			InputOlderstDeviceDeployment: &deployments.DeviceDeployment{
+				Image:        image,
+				DeviceId:     StringToPointer("ID:123"),
+				DeploymentId: StringToPointer("ID:678"),
+			},
+			InputArtifact:       image,
+			InputGetRequestLink: &images.Link{},
+			InputFreezePeriod:   &deployments.FreezePeriod{Id: validUUIDv4},
+		},
+		{
+			// deployments are frozen, but the deployment overrides the freeze
+			InputID: "ID:123",
+			InputOlderstDeviceDeployment: &deployments.DeviceDeployment{
+				Image:        image,
+				DeviceId:     StringToPointer("ID:123"),
+				DeploymentId: StringToPointer("ID:678"),
+			},
+			InputArtifact:       image,
+			InputGetRequestLink: &images.Link{},
+			InputFreezePeriod:   &deployments.FreezePeriod{Id: validUUIDv4},
+			InputOverrideFreeze: true,
+
+			OutputDeploymentInstructions: &deployments.DeploymentInstructions{
+				ID: "ID:678",
+				Artifact: deployments.ArtifactDeploymentInstructions{
+					ArtifactName:          image.Name,
+					Source:                images.Link{},
+					DeviceTypesCompatible: image.DeviceTypesCompatible,
+				},
+			},
+		},
+		{
+			InputID: "ID:123",
+			InputOlderstDeviceDeployment: &deployments.DeviceDeployment{
+				Image:        image,
+				DeviceId:     StringToPointer("ID:123"),
+				DeploymentId: StringToPointer("ID:678"),
+			},
+			InputArtifact:          image,
+			InputFreezePeriodError: errors.New("storage issue"),
+
+			OutputError: errors.New("Checking deployment freeze periods: storage issue"),
+		},
+		{
+			// campaign of the deployment is paused
+			InputID: "ID:123",
+			InputOlderstDeviceDeployment: &deployments.DeviceDeployment{
+				Image:        image,
+				DeviceId:     StringToPointer("ID:123"),
+				DeploymentId: StringToPointer("ID:678"),
+			},
+			InputArtifact:       image,
+			InputGetRequestLink: &images.Link{},
+			InputPaused:         true,
 		},
 	}
 
@@ -385,6 +677,9 @@ func TestDeploymentModelGetDeploymentForDevice(t *testing.T) {
 				mock.AnythingOfType("string"),
 				mock.AnythingOfType("*images.SoftwareImage")).
 				Return(nil)
+			deviceDeploymentStorage.On("IncrementDeviceDeploymentAttempts",
+				h.ContextMatcher(), mock.AnythingOfType("string"), mock.AnythingOfType("string")).
+				Return(nil)
 				//Return(testCase.InputAssignArtifactError)
 
 			imageLinker := new(mocks.GetRequester)
@@ -409,6 +704,11 @@ func TestDeploymentModelGetDeploymentForDevice(t *testing.T) {
 					mock.AnythingOfType("string"),
 					mock.AnythingOfType("string")).
 					Return(nil)
+				deploymentStorage.On("IncrementStatsRollup",
+					h.ContextMatcher(),
+					mock.AnythingOfType("time.Time"),
+					deployments.DeviceDeploymentStatusAlreadyInst).
+					Return(nil)
 
 				deploymentStorage.On("FindByID",
 					h.ContextMatcher(),
@@ -417,10 +717,19 @@ func TestDeploymentModelGetDeploymentForDevice(t *testing.T) {
 						Id:    testCase.InputOlderstDeviceDeployment.DeploymentId,
 						Stats: deployments.NewDeviceDeploymentStats(),
 						DeploymentConstructor: &deployments.DeploymentConstructor{
-							ArtifactName: &image.Name,
+							ArtifactName:     &image.Name,
+							DownloadSchedule: testCase.InputDownloadSchedule,
+							OverrideFreeze:   testCase.InputOverrideFreeze,
 						},
+						Paused: testCase.InputPaused,
 					}, nil)
 
+				deploymentStorage.On("IncrementDownloadCount",
+					h.ContextMatcher(),
+					*testCase.InputOlderstDeviceDeployment.DeploymentId,
+					mock.AnythingOfType("time.Time")).
+					Return(testCase.InputDownloadCount, nil)
+
 				// if deployment is found to be finished, we need to
 				// mock another call
 				deploymentStorage.On("Finish",
@@ -445,11 +754,32 @@ func TestDeploymentModelGetDeploymentForDevice(t *testing.T) {
 				Return(testCase.InputArtifact,
 					testCase.InputImageByNameAndDeviceTypeError)
 
+			preServeHook := new(mocks.PreServeHook)
+			preServeHook.On("PreServe",
+				h.ContextMatcher(),
+				testCase.InputID,
+				mock.AnythingOfType("*deployments.DeploymentInstructions")).
+				Return(testCase.InputPreServeHookError)
+
+			restorer := new(mocks.ArtifactRestorer)
+			restorer.On("Restore",
+				h.ContextMatcher(), validUUIDv4, int64(7)).
+				Return(testCase.InputRestored, testCase.InputRestoreError)
+
+			freezePeriodsStorage := new(mocks.FreezePeriodsStorage)
+			freezePeriodsStorage.On("FindActiveFreezePeriod",
+				h.ContextMatcher(), mock.AnythingOfType("time.Time")).
+				Return(testCase.InputFreezePeriod, testCase.InputFreezePeriodError)
+
 			model := NewDeploymentModel(DeploymentsModelConfig{
 				DeviceDeploymentsStorage: deviceDeploymentStorage,
 				DeploymentsStorage:       deploymentStorage,
 				ImageLinker:              imageLinker,
 				ArtifactGetter:           artifactGetter,
+				PreServeHooks:            []PreServeHook{preServeHook},
+				ArtifactRestorer:         restorer,
+				ArtifactRestoreDays:      7,
+				FreezePeriodsStorage:     freezePeriodsStorage,
 			})
 
 			out, err := model.GetDeploymentForDeviceWithCurrent(context.Background(),
@@ -467,6 +797,14 @@ func TestDeploymentModelGetDeploymentForDevice(t *testing.T) {
 					assert.Nil(t, out)
 				}
 			}
+			if testCase.OutputReason != nil {
+				deviceDeploymentStorage.AssertCalled(t, "UpdateDeviceDeploymentStatus",
+					h.ContextMatcher(), mock.AnythingOfType("string"),
+					mock.AnythingOfType("string"),
+					mock.MatchedBy(func(status deployments.DeviceDeploymentStatus) bool {
+						return assert.ObjectsAreEqual(testCase.OutputReason, status.Reason)
+					}))
+			}
 		})
 	}
 
@@ -483,16 +821,23 @@ func TestDeploymentModelCreateDeployment(t *testing.T) {
 		InputDeviceDeploymentStorageInsertManyError error
 		InputDeploymentStorageDeleteError           error
 		InputImagesByNameError                      error
+		InputPreCreateHookError                     error
+		InputSupersededDeployments                  []string
+		InputSupersedeError                         error
+		InputConflictingDevices                     []string
+		InputConflictsError                         error
 
-		OutputError error
-		OutputBody  bool
+		OutputError      error
+		OutputBody       bool
+		OutputSuperseded bool
+		OutputDevices    []string
 	}{
 		{
 			OutputError: controller.ErrModelMissingInput,
 		},
 		{
 			InputConstructor: deployments.NewDeploymentConstructor(),
-			OutputError:      errors.New("Validating deployment: Name: non zero value required;ArtifactName: non zero value required;Devices: non zero value required;"),
+			OutputError:      errors.New("Validating deployment: name: value is required;artifact_name: value is required;devices: at least one device is required;"),
 		},
 		{
 			InputConstructor: &deployments.DeploymentConstructor{
@@ -543,34 +888,152 @@ func TestDeploymentModelCreateDeployment(t *testing.T) {
 
 			OutputBody: true,
 		},
-	}
+		{
+			InputConstructor: &deployments.DeploymentConstructor{
+				Name:         StringToPointer("NYC Production"),
+				ArtifactName: StringToPointer("App 123"),
+				Devices:      []string{"b532b01a-9313-404f-8d19-e7fcbe5cc347"},
+			},
+			InputPreCreateHookError: controller.ErrPolicyRejected,
 
-	for testCaseNumber, testCase := range testCases {
-		t.Run(fmt.Sprintf("test case %d", testCaseNumber+1), func(t *testing.T) {
+			OutputError: errors.New("Applying deployment policy: Rejected by deployment policy"),
+		},
+		{
+			InputConstructor: &deployments.DeploymentConstructor{
+				Name:         StringToPointer("NYC Production"),
+				ArtifactName: StringToPointer("App 123"),
+				Devices:      []string{"b532b01a-9313-404f-8d19-e7fcbe5cc347"},
+				Supersede:    true,
+			},
+			InputSupersededDeployments: []string{"older-1", "older-2"},
 
-			deploymentStorage := new(mocks.DeploymentsStorage)
-			deploymentStorage.On("Insert",
-				h.ContextMatcher(),
-				mock.AnythingOfType("*deployments.Deployment")).
-				Return(testCase.InputDeploymentStorageInsertError)
-			deploymentStorage.On("Delete",
-				h.ContextMatcher(),
-				mock.AnythingOfType("string")).
-				Return(testCase.InputDeploymentStorageDeleteError)
+			OutputBody:       true,
+			OutputSuperseded: true,
+		},
+		{
+			// superseding is best effort
+			InputConstructor: &deployments.DeploymentConstructor{
+				Name:         StringToPointer("NYC Production"),
+				ArtifactName: StringToPointer("App 123"),
+				Devices:      []string{"b532b01a-9313-404f-8d19-e7fcbe5cc347"},
+				Supersede:    true,
+			},
+			InputSupersedeError: errors.New("storage issue"),
 
-			deviceDeploymentStorage := new(mocks.DeviceDeploymentStorage)
-			deviceDeploymentStorage.On("InsertMany",
-				h.ContextMatcher(),
-				mock.AnythingOfType("[]*deployments.DeviceDeployment")).
-				Return(testCase.InputDeviceDeploymentStorageInsertManyError)
+			OutputBody:       true,
+			OutputSuperseded: true,
+		},
+		{
+			InputConstructor: &deployments.DeploymentConstructor{
+				Name:           StringToPointer("NYC Production"),
+				ArtifactName:   StringToPointer("App 123"),
+				Devices:        []string{"device-1", "device-2", "device-3"},
+				ConflictPolicy: deployments.ConflictPolicyReject,
+			},
+			InputConflictingDevices: []string{"device-1", "device-3"},
 
-			artifactGetter := new(mocks.ArtifactGetter)
-			artifactGetter.On("ImagesByName",
-				h.ContextMatcher(),
-				mock.AnythingOfType("string")).
-				Return(
-					[]*images.SoftwareImage{images.NewSoftwareImage(
-						validUUIDv4,
+			OutputError: errors.New("Devices device-1, device-3: Conflicting active deployment"),
+		},
+		{
+			InputConstructor: &deployments.DeploymentConstructor{
+				Name:           StringToPointer("NYC Production"),
+				ArtifactName:   StringToPointer("App 123"),
+				Devices:        []string{"device-1", "device-2", "device-3"},
+				ConflictPolicy: deployments.ConflictPolicySkip,
+			},
+			InputConflictingDevices: []string{"device-1", "device-3"},
+
+			OutputBody:    true,
+			OutputDevices: []string{"device-2"},
+		},
+		{
+			InputConstructor: &deployments.DeploymentConstructor{
+				Name:           StringToPointer("NYC Production"),
+				ArtifactName:   StringToPointer("App 123"),
+				Devices:        []string{"device-1"},
+				ConflictPolicy: deployments.ConflictPolicySkip,
+			},
+			InputConflictingDevices: []string{"device-1"},
+
+			OutputError: errors.New("All devices have active deployments: Conflicting active deployment"),
+		},
+		{
+			InputConstructor: &deployments.DeploymentConstructor{
+				Name:           StringToPointer("NYC Production"),
+				ArtifactName:   StringToPointer("App 123"),
+				Devices:        []string{"device-1"},
+				ConflictPolicy: deployments.ConflictPolicySkip,
+			},
+			InputConflictsError: errors.New("storage issue"),
+
+			OutputError: errors.New("Checking active deployments: storage issue"),
+		},
+		{
+			InputConstructor: &deployments.DeploymentConstructor{
+				Name:           StringToPointer("NYC Production"),
+				ArtifactName:   StringToPointer("App 123"),
+				Devices:        []string{"device-1", "device-2"},
+				ConflictPolicy: deployments.ConflictPolicyQueue,
+			},
+			InputConflictingDevices: []string{"device-1"},
+
+			OutputBody:    true,
+			OutputDevices: []string{"device-1", "device-2"},
+		},
+	}
+
+	for testCaseNumber, testCase := range testCases {
+		t.Run(fmt.Sprintf("test case %d", testCaseNumber+1), func(t *testing.T) {
+
+			deploymentStorage := new(mocks.DeploymentsStorage)
+			deploymentStorage.On("FindBySlug",
+				h.ContextMatcher(), mock.AnythingOfType("string")).
+				Return(nil, nil)
+			deploymentStorage.On("Insert",
+				h.ContextMatcher(),
+				mock.AnythingOfType("*deployments.Deployment")).
+				Return(testCase.InputDeploymentStorageInsertError)
+			deploymentStorage.On("Delete",
+				h.ContextMatcher(),
+				mock.AnythingOfType("string")).
+				Return(testCase.InputDeploymentStorageDeleteError)
+
+			deviceDeploymentStorage := new(mocks.DeviceDeploymentStorage)
+			deviceDeploymentStorage.On("InsertMany",
+				h.ContextMatcher(),
+				mock.AnythingOfType("[]*deployments.DeviceDeployment")).
+				Return(testCase.InputDeviceDeploymentStorageInsertManyError)
+			deviceDeploymentStorage.On("SupersedeDeviceDeployments",
+				h.ContextMatcher(),
+				mock.AnythingOfType("string"),
+				[]string{"b532b01a-9313-404f-8d19-e7fcbe5cc347"}).
+				Return(testCase.InputSupersededDeployments, testCase.InputSupersedeError)
+			if testCase.InputConstructor != nil &&
+				testCase.InputConstructor.ConflictPolicy != "" {
+				deviceDeploymentStorage.On("FindDeviceIDsWithStatuses",
+					h.ContextMatcher(),
+					mock.AnythingOfType("[]string"),
+					deployments.ActiveDeploymentStatuses()).
+					Return(testCase.InputConflictingDevices, testCase.InputConflictsError)
+			}
+			for _, id := range testCase.InputSupersededDeployments {
+				stats := deployments.NewDeviceDeploymentStats()
+				stats[deployments.DeviceDeploymentStatusSuperseded] = 1
+				deviceDeploymentStorage.On("AggregateDeviceDeploymentByStatus",
+					h.ContextMatcher(), id).
+					Return(stats, nil)
+				deploymentStorage.On("UpdateStatsAndFinishDeployment",
+					h.ContextMatcher(), id, stats).
+					Return(nil)
+			}
+
+			artifactGetter := new(mocks.ArtifactGetter)
+			artifactGetter.On("ImagesByName",
+				h.ContextMatcher(),
+				mock.AnythingOfType("string")).
+				Return(
+					[]*images.SoftwareImage{images.NewSoftwareImage(
+						validUUIDv4,
 						&images.SoftwareImageMetaConstructor{},
 						&images.SoftwareImageMetaArtifactConstructor{
 							//Name: *testCase.InputConstructor.ArtifactName,
@@ -581,10 +1044,17 @@ func TestDeploymentModelCreateDeployment(t *testing.T) {
 						})},
 					testCase.InputImagesByNameError)
 
+			preCreateHook := new(mocks.PreCreateHook)
+			preCreateHook.On("PreCreate",
+				h.ContextMatcher(),
+				mock.AnythingOfType("*deployments.DeploymentConstructor")).
+				Return(testCase.InputPreCreateHookError)
+
 			model := NewDeploymentModel(DeploymentsModelConfig{
 				DeploymentsStorage:       deploymentStorage,
 				DeviceDeploymentsStorage: deviceDeploymentStorage,
 				ArtifactGetter:           artifactGetter,
+				PreCreateHooks:           []PreCreateHook{preCreateHook},
 			})
 
 			out, err := model.CreateDeployment(context.Background(), testCase.InputConstructor)
@@ -596,599 +1066,2826 @@ func TestDeploymentModelCreateDeployment(t *testing.T) {
 			if testCase.OutputBody {
 				assert.NotNil(t, out)
 			}
+			if testCase.OutputDevices != nil {
+				assert.Equal(t, testCase.OutputDevices, testCase.InputConstructor.Devices)
+			}
+			if testCase.OutputSuperseded {
+				deviceDeploymentStorage.AssertExpectations(t)
+				deploymentStorage.AssertNumberOfCalls(t, "UpdateStatsAndFinishDeployment",
+					len(testCase.InputSupersededDeployments))
+			} else {
+				deviceDeploymentStorage.AssertNotCalled(t, "SupersedeDeviceDeployments",
+					mock.Anything, mock.Anything, mock.Anything)
+			}
 		})
 	}
 
 }
 
-func TestDeploymentModelUpdateDeviceDeploymentStatus(t *testing.T) {
+func TestDeploymentModelCreateDeploymentRestoring(t *testing.T) {
 
-	//t.Parallel()
+	testCases := map[string]struct {
+		InputArchived     bool
+		InputRestored     bool
+		InputRestoreError error
 
-	testCases := []struct {
-		InputDeployment *deployments.Deployment
-		InputDeviceID   string
-		OldStatus       string
-		InputStatus     string
+		OutputRestoring bool
+		OutputError     error
+	}{
+		"not archived": {},
+		"archived, restore requested": {
+			InputArchived: true,
 
-		InputDevsStorageError error
-		InputDepsStorageError error
-		InputDepsFinishError  error
-		InputDepsFindError    error
+			OutputRestoring: true,
+		},
+		"archived, restored already": {
+			InputArchived: true,
+			InputRestored: true,
+		},
+		"archived, restore error": {
+			InputArchived:     true,
+			InputRestoreError: errors.New("s3 error"),
 
-		isFinished bool
+			OutputError: errors.New("Restoring archived artifact: s3 error"),
+		},
+	}
 
-		OutputError error
-	}{
-		{
-			InputDeployment: &deployments.Deployment{
-				Id: StringToPointer("123"),
-				Stats: deployments.Stats{
-					deployments.DeviceDeploymentStatusPending: 1,
-				},
-			},
-			InputDeviceID: "123",
-			InputStatus:   "installing",
-			OldStatus:     "pending",
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
 
-			InputDevsStorageError: errors.New("device deployments storage issue"),
-			InputDepsStorageError: nil,
+			var inserted *deployments.Deployment
+			deploymentStorage := new(mocks.DeploymentsStorage)
+			deploymentStorage.On("FindBySlug",
+				h.ContextMatcher(), mock.AnythingOfType("string")).
+				Return(nil, nil)
+			deploymentStorage.On("Insert",
+				h.ContextMatcher(),
+				mock.AnythingOfType("*deployments.Deployment")).
+				Run(func(args mock.Arguments) {
+					inserted = args.Get(1).(*deployments.Deployment)
+				}).
+				Return(nil)
 
-			OutputError: errors.New("device deployments storage issue"),
-		},
-		{
-			InputDeployment: &deployments.Deployment{
-				Id: StringToPointer("234"),
-				Stats: deployments.Stats{
-					deployments.DeviceDeploymentStatusPending: 1,
-				},
-			},
-			InputDeviceID: "234",
-			InputStatus:   "none",
-			OldStatus:     "pending",
+			deviceDeploymentStorage := new(mocks.DeviceDeploymentStorage)
+			deviceDeploymentStorage.On("InsertMany",
+				h.ContextMatcher(),
+				mock.AnythingOfType("[]*deployments.DeviceDeployment")).
+				Return(nil)
 
-			InputDevsStorageError: nil,
-			InputDepsStorageError: errors.New("deployments storage issue"),
+			artifact := images.NewSoftwareImage(
+				validUUIDv4,
+				&images.SoftwareImageMetaConstructor{},
+				&images.SoftwareImageMetaArtifactConstructor{
+					Name:                  "App 123",
+					DeviceTypesCompatible: []string{"hammer"},
+				})
+			artifact.Archived = testCase.InputArchived
 
-			OutputError: errors.New("deployments storage issue"),
-		},
-		{
-			isFinished: true,
-			InputDeployment: &deployments.Deployment{
-				Id: StringToPointer("345"),
-				Stats: deployments.Stats{
-					deployments.DeviceDeploymentStatusSuccess: 1,
-				},
-			},
-			InputDeviceID: "345",
-			InputStatus:   "success",
-			OldStatus:     "installing",
+			artifactGetter := new(mocks.ArtifactGetter)
+			artifactGetter.On("ImagesByName",
+				h.ContextMatcher(), "App 123").
+				Return([]*images.SoftwareImage{artifact}, nil)
 
-			InputDevsStorageError: nil,
-		},
-		{
-			isFinished: false,
-			InputDeployment: &deployments.Deployment{
-				Id: StringToPointer("345"),
-				Stats: deployments.Stats{
-					deployments.DeviceDeploymentStatusSuccess: 1,
-				},
-			},
-			InputDeviceID: "345",
-			InputStatus:   "installing",
-			OldStatus:     "installing",
+			restorer := new(mocks.ArtifactRestorer)
+			restorer.On("Restore",
+				h.ContextMatcher(), validUUIDv4, int64(7)).
+				Return(testCase.InputRestored, testCase.InputRestoreError)
 
-			InputDevsStorageError: nil,
-		},
-		{
-			isFinished: true,
-			InputDeployment: &deployments.Deployment{
-				Id: StringToPointer("456"),
-				Stats: deployments.Stats{
-					deployments.DeviceDeploymentStatusAlreadyInst: 1,
-				},
-			},
-			InputDeviceID: "456",
-			InputStatus:   "already-installed",
-			OldStatus:     "pending",
+			model := NewDeploymentModel(DeploymentsModelConfig{
+				DeploymentsStorage:       deploymentStorage,
+				DeviceDeploymentsStorage: deviceDeploymentStorage,
+				ArtifactGetter:           artifactGetter,
+				ArtifactRestorer:         restorer,
+				ArtifactRestoreDays:      7,
+			})
 
-			InputDevsStorageError: nil,
+			_, err := model.CreateDeployment(context.Background(),
+				&deployments.DeploymentConstructor{
+					Name:         StringToPointer("NYC Production"),
+					ArtifactName: StringToPointer("App 123"),
+					Devices:      []string{"b532b01a-9313-404f-8d19-e7fcbe5cc347"},
+				})
+			if testCase.OutputError != nil {
+				assert.EqualError(t, err, testCase.OutputError.Error())
+				deploymentStorage.AssertNotCalled(t, "Insert", mock.Anything, mock.Anything)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.NotNil(t, inserted)
+			assert.Equal(t, testCase.OutputRestoring, inserted.Restoring)
+			if !testCase.InputArchived {
+				restorer.AssertNotCalled(t, "Restore", mock.Anything, mock.Anything, mock.Anything)
+			}
+		})
+	}
+}
+
+func TestDeploymentModelCreateDeploymentPinned(t *testing.T) {
+
+	artifact := images.NewSoftwareImage(
+		validUUIDv4,
+		&images.SoftwareImageMetaConstructor{},
+		&images.SoftwareImageMetaArtifactConstructor{
+			Name:                  "App 123",
+			DeviceTypesCompatible: []string{"hammer"},
+		})
+
+	testCases := map[string]struct {
+		InputArtifactName *string
+		InputArtifact     *images.SoftwareImage
+		InputFindError    error
+
+		OutputError error
+	}{
+		"ok": {
+			InputArtifact: artifact,
 		},
-		{
-			isFinished: true,
-			InputDeployment: &deployments.Deployment{
-				Id: StringToPointer("567"),
-				Stats: deployments.Stats{
-					deployments.DeviceDeploymentStatusAborted: 1,
-				},
-			},
-			InputDeviceID: "567",
-			InputStatus:   "aborted",
-			OldStatus:     "pending",
+		"ok, matching name": {
+			InputArtifactName: StringToPointer("App 123"),
+			InputArtifact:     artifact,
 		},
-		{
-			isFinished: true,
-			InputDeployment: &deployments.Deployment{
-				Id: StringToPointer("678"),
-				Stats: deployments.Stats{
-					deployments.DeviceDeploymentStatusSuccess: 1,
-				},
-			},
-			InputDeviceID: "678",
-			InputStatus:   "success",
-			OldStatus:     "rebooting",
+		"name mismatch": {
+			InputArtifactName: StringToPointer("App 124"),
+			InputArtifact:     artifact,
 
-			InputDepsFinishError: errors.New("deployments storage finish issue"),
-			OutputError:          errors.New("failed to mark deployment as finished: deployments storage finish issue"),
+			OutputError: errors.New("Validating deployment: artifact_name: " +
+				"does not match name of the artifact with given ID;"),
 		},
-		{
-			isFinished: true,
-			InputDeployment: &deployments.Deployment{
-				Id: StringToPointer("789"),
-				Stats: deployments.Stats{
-					deployments.DeviceDeploymentStatusAborted: 1,
-				},
-			},
-			InputDeviceID: "789",
-			InputStatus:   "rebooting",
-			OldStatus:     "aborted",
+		"artifact not found": {
+			OutputError: controller.ErrNoArtifact,
+		},
+		"storage error": {
+			InputFindError: errors.New("storage issue"),
 
-			OutputError: controller.ErrDeploymentAborted,
+			OutputError: errors.New("Finding artifact with given ID: storage issue"),
 		},
 	}
 
-	for testCaseNumber, testCase := range testCases {
-		t.Run(fmt.Sprintf("test case %d", testCaseNumber+1), func(t *testing.T) {
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
 
-			t.Logf("testing %s %s %s (in) %s (old) %v %v",
-				*testCase.InputDeployment.Id,
-				testCase.InputDeviceID, testCase.InputStatus,
-				testCase.OldStatus,
-				testCase.InputDevsStorageError, testCase.isFinished)
+			var inserted *deployments.Deployment
+			deploymentStorage := new(mocks.DeploymentsStorage)
+			deploymentStorage.On("FindBySlug",
+				h.ContextMatcher(), mock.AnythingOfType("string")).
+				Return(nil, nil)
+			deploymentStorage.On("Insert",
+				h.ContextMatcher(),
+				mock.AnythingOfType("*deployments.Deployment")).
+				Run(func(args mock.Arguments) {
+					inserted = args.Get(1).(*deployments.Deployment)
+				}).
+				Return(nil)
 
-			// status is always fetched
 			deviceDeploymentStorage := new(mocks.DeviceDeploymentStorage)
-			deviceDeploymentStorage.On("GetDeviceDeploymentStatus",
+			deviceDeploymentStorage.On("InsertMany",
 				h.ContextMatcher(),
-				*testCase.InputDeployment.Id, testCase.InputDeviceID).
-				Return(testCase.OldStatus, testCase.InputDevsStorageError)
+				mock.AnythingOfType("[]*deployments.DeviceDeployment")).
+				Return(nil)
 
-			deploymentStorage := new(mocks.DeploymentsStorage)
+			artifactGetter := new(mocks.ArtifactGetter)
+			artifactGetter.On("FindByID",
+				h.ContextMatcher(), validUUIDv4).
+				Return(testCase.InputArtifact, testCase.InputFindError)
 
-			// however any status updates in DB are done only if the
-			// status reported by device is different from the
-			// previous one in DB
-			if testCase.OldStatus != testCase.InputStatus {
-				deviceDeploymentStorage.On("UpdateDeviceDeploymentStatus",
-					h.ContextMatcher(),
-					testCase.InputDeviceID, *testCase.InputDeployment.Id,
-					mock.MatchedBy(func(ddStatus deployments.DeviceDeploymentStatus) bool {
+			model := NewDeploymentModel(DeploymentsModelConfig{
+				DeploymentsStorage:       deploymentStorage,
+				DeviceDeploymentsStorage: deviceDeploymentStorage,
+				ArtifactGetter:           artifactGetter,
+			})
 
-						statusOk := assert.Equal(t, testCase.InputStatus, ddStatus.Status)
-						finishOk := true
-						if testCase.isFinished {
-							finishOk = assert.NotNil(t, ddStatus.FinishTime) &&
-								assert.WithinDuration(t, time.Now(),
-									*ddStatus.FinishTime, time.Second)
-						}
-						return statusOk && finishOk
-					})).
-					Return("dontcare", testCase.InputDevsStorageError)
+			_, err := model.CreateDeployment(context.Background(),
+				&deployments.DeploymentConstructor{
+					Name:         StringToPointer("NYC Production"),
+					ArtifactName: testCase.InputArtifactName,
+					ArtifactID:   validUUIDv4,
+					Devices:      []string{"b532b01a-9313-404f-8d19-e7fcbe5cc347"},
+				})
+			if testCase.OutputError != nil {
+				assert.EqualError(t, err, testCase.OutputError.Error())
+				deploymentStorage.AssertNotCalled(t, "Insert", mock.Anything, mock.Anything)
+				return
+			}
 
-				deploymentStorage.On("UpdateStats",
-					h.ContextMatcher(),
-					*testCase.InputDeployment.Id, mock.AnythingOfType("string"),
-					mock.AnythingOfType("string")).
-					Return(testCase.InputDepsStorageError)
-				// deployment will be marked as finished when possible, for this we need to
-				// mock a couple of additional calls
-				deploymentStorage.On("FindByID",
-					h.ContextMatcher(),
-					*testCase.InputDeployment.Id).
-					Return(testCase.InputDeployment, testCase.InputDepsFindError)
-				if testCase.isFinished {
-					deploymentStorage.On("Finish",
-						h.ContextMatcher(),
-						*testCase.InputDeployment.Id,
-						mock.MatchedBy(func(tm time.Time) bool {
-							return assert.WithinDuration(t, time.Now(), tm, time.Second)
-						})).
-						Return(testCase.InputDepsFinishError)
+			assert.NoError(t, err)
+			if assert.NotNil(t, inserted) {
+				assert.Equal(t, "App 123", *inserted.ArtifactName)
+				assert.Equal(t, []string{validUUIDv4}, inserted.Artifacts)
+			}
+			// artifacts with the same name are not considered
+			artifactGetter.AssertNotCalled(t, "ImagesByName", mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestDeploymentModelCreateDeploymentAutoLatest(t *testing.T) {
+
+	newArtifact := func(id, name string) *images.SoftwareImage {
+		return images.NewSoftwareImage(
+			id,
+			&images.SoftwareImageMetaConstructor{},
+			&images.SoftwareImageMetaArtifactConstructor{
+				Name:                  name,
+				DeviceTypesCompatible: []string{"hammer"},
+			})
+	}
+	latest := newArtifact(validUUIDv4, "app-1.10.0")
+
+	testCases := map[string]struct {
+		InputArtifacts []*images.SoftwareImage
+		InputFindError error
+
+		OutputError error
+	}{
+		"ok": {
+			InputArtifacts: []*images.SoftwareImage{
+				newArtifact("b532b01a-9313-404f-8d19-e7fcbe5cc347", "app-1.9.2"),
+				latest,
+				newArtifact("c532b01a-9313-404f-8d19-e7fcbe5cc347", "app-1.10.0-rc1"),
+				newArtifact("d532b01a-9313-404f-8d19-e7fcbe5cc347", "app-nightly"),
+			},
+		},
+		"no versioned artifacts": {
+			InputArtifacts: []*images.SoftwareImage{
+				newArtifact("d532b01a-9313-404f-8d19-e7fcbe5cc347", "app-nightly"),
+			},
+
+			OutputError: controller.ErrNoArtifact,
+		},
+		"artifact not found": {
+			OutputError: controller.ErrNoArtifact,
+		},
+		"storage error": {
+			InputFindError: errors.New("storage issue"),
+
+			OutputError: errors.New("Finding artifacts with given name prefix: storage issue"),
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+
+			var inserted *deployments.Deployment
+			deploymentStorage := new(mocks.DeploymentsStorage)
+			deploymentStorage.On("FindBySlug",
+				h.ContextMatcher(), mock.AnythingOfType("string")).
+				Return(nil, nil)
+			deploymentStorage.On("Insert",
+				h.ContextMatcher(),
+				mock.AnythingOfType("*deployments.Deployment")).
+				Run(func(args mock.Arguments) {
+					inserted = args.Get(1).(*deployments.Deployment)
+				}).
+				Return(nil)
+
+			deviceDeploymentStorage := new(mocks.DeviceDeploymentStorage)
+			deviceDeploymentStorage.On("InsertMany",
+				h.ContextMatcher(),
+				mock.AnythingOfType("[]*deployments.DeviceDeployment")).
+				Return(nil)
+
+			artifactGetter := new(mocks.ArtifactGetter)
+			artifactGetter.On("ImagesByNamePrefix",
+				h.ContextMatcher(), "app-", "").
+				Return(testCase.InputArtifacts, testCase.InputFindError)
+			artifactGetter.On("ImagesByName",
+				h.ContextMatcher(), "app-1.10.0").
+				Return([]*images.SoftwareImage{latest}, nil)
+
+			model := NewDeploymentModel(DeploymentsModelConfig{
+				DeploymentsStorage:       deploymentStorage,
+				DeviceDeploymentsStorage: deviceDeploymentStorage,
+				ArtifactGetter:           artifactGetter,
+			})
+
+			_, err := model.CreateDeployment(context.Background(),
+				&deployments.DeploymentConstructor{
+					Name:         StringToPointer("NYC Production"),
+					ArtifactName: StringToPointer("app-"),
+					AutoLatest:   true,
+					Devices:      []string{"b532b01a-9313-404f-8d19-e7fcbe5cc347"},
+				})
+			if testCase.OutputError != nil {
+				assert.EqualError(t, err, testCase.OutputError.Error())
+				deploymentStorage.AssertNotCalled(t, "Insert", mock.Anything, mock.Anything)
+				return
+			}
+
+			assert.NoError(t, err)
+			if assert.NotNil(t, inserted) {
+				assert.Equal(t, "app-1.10.0", *inserted.ArtifactName)
+				assert.Equal(t, []string{validUUIDv4}, inserted.Artifacts)
+			}
+		})
+	}
+}
+
+func TestDeploymentModelCreateDeploymentSnapshot(t *testing.T) {
+
+	artifacts := []*images.SoftwareImage{
+		images.NewSoftwareImage(
+			"artifact-1",
+			&images.SoftwareImageMetaConstructor{},
+			&images.SoftwareImageMetaArtifactConstructor{
+				Name:                  "App 123",
+				DeviceTypesCompatible: []string{"hammer", "drill"},
+			}),
+		images.NewSoftwareImage(
+			"artifact-2",
+			&images.SoftwareImageMetaConstructor{},
+			&images.SoftwareImageMetaArtifactConstructor{
+				Name:                  "App 123",
+				DeviceTypesCompatible: []string{"saw"},
+			}),
+	}
+
+	testCases := map[string]struct {
+		InputSnapshot bool
+
+		OutputDeviceTypeArtifacts []deployments.DeviceTypeArtifact
+	}{
+		"no snapshot": {},
+		"snapshot": {
+			InputSnapshot: true,
+
+			OutputDeviceTypeArtifacts: []deployments.DeviceTypeArtifact{
+				{DeviceType: "hammer", ArtifactID: "artifact-1"},
+				{DeviceType: "drill", ArtifactID: "artifact-1"},
+				{DeviceType: "saw", ArtifactID: "artifact-2"},
+			},
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+
+			var inserted *deployments.Deployment
+			deploymentStorage := new(mocks.DeploymentsStorage)
+			deploymentStorage.On("FindBySlug",
+				h.ContextMatcher(), mock.AnythingOfType("string")).
+				Return(nil, nil)
+			deploymentStorage.On("Insert",
+				h.ContextMatcher(),
+				mock.AnythingOfType("*deployments.Deployment")).
+				Run(func(args mock.Arguments) {
+					inserted = args.Get(1).(*deployments.Deployment)
+				}).
+				Return(nil)
+
+			deviceDeploymentStorage := new(mocks.DeviceDeploymentStorage)
+			deviceDeploymentStorage.On("InsertMany",
+				h.ContextMatcher(),
+				mock.AnythingOfType("[]*deployments.DeviceDeployment")).
+				Return(nil)
+
+			artifactGetter := new(mocks.ArtifactGetter)
+			artifactGetter.On("ImagesByName",
+				h.ContextMatcher(), "App 123").
+				Return(artifacts, nil)
+
+			model := NewDeploymentModel(DeploymentsModelConfig{
+				DeploymentsStorage:       deploymentStorage,
+				DeviceDeploymentsStorage: deviceDeploymentStorage,
+				ArtifactGetter:           artifactGetter,
+			})
+
+			_, err := model.CreateDeployment(context.Background(),
+				&deployments.DeploymentConstructor{
+					Name:              StringToPointer("NYC Production"),
+					ArtifactName:      StringToPointer("App 123"),
+					Devices:           []string{"b532b01a-9313-404f-8d19-e7fcbe5cc347"},
+					SnapshotArtifacts: testCase.InputSnapshot,
+				})
+			assert.NoError(t, err)
+			if assert.NotNil(t, inserted) {
+				assert.Equal(t, testCase.OutputDeviceTypeArtifacts, inserted.DeviceTypeArtifacts)
+			}
+		})
+	}
+}
+
+func TestDeploymentModelGetDeploymentForDeviceSnapshot(t *testing.T) {
+
+	artifact := images.NewSoftwareImage(
+		validUUIDv4,
+		&images.SoftwareImageMetaConstructor{},
+		&images.SoftwareImageMetaArtifactConstructor{
+			Name:                  "App 123",
+			DeviceTypesCompatible: []string{"hammer"},
+		})
+
+	testCases := map[string]struct {
+		InputDeviceType string
+		InputFindError  error
+
+		OutputError        error
+		OutputInstructions bool
+		OutputReason       *deployments.DeviceDeploymentReason
+	}{
+		"resolved artifact": {
+			InputDeviceType: "hammer",
+
+			OutputInstructions: true,
+		},
+		"no artifact for device type": {
+			InputDeviceType: "saw",
+
+			OutputReason: deployments.NewNoArtifactReason("saw"),
+		},
+		"storage error": {
+			InputDeviceType: "hammer",
+			InputFindError:  errors.New("storage issue"),
+
+			OutputError: errors.New("assigning artifact to device deployment: storage issue"),
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+
+			deploymentID := "b532b01a-9313-404f-8d19-e7fcbe5cc347"
+
+			deviceDeploymentStorage := new(mocks.DeviceDeploymentStorage)
+			deviceDeploymentStorage.On("FindOldestDeploymentForDeviceIDWithStatuses",
+				h.ContextMatcher(), "device-1", mock.AnythingOfType("[]string")).
+				Return(&deployments.DeviceDeployment{
+					DeviceId:     StringToPointer("device-1"),
+					DeploymentId: StringToPointer(deploymentID),
+				}, nil)
+			deviceDeploymentStorage.On("AssignArtifact",
+				h.ContextMatcher(), "device-1", deploymentID,
+				mock.AnythingOfType("*images.SoftwareImage")).
+				Return(nil)
+			deviceDeploymentStorage.On("IncrementDeviceDeploymentAttempts",
+				h.ContextMatcher(), mock.AnythingOfType("string"), mock.AnythingOfType("string")).
+				Return(nil)
+			deviceDeploymentStorage.On("GetDeviceDeploymentStatus",
+				h.ContextMatcher(), deploymentID, "device-1").
+				Return(deployments.DeviceDeploymentStatusPending, nil)
+			deviceDeploymentStorage.On("UpdateDeviceDeploymentStatus",
+				h.ContextMatcher(), "device-1", deploymentID,
+				mock.AnythingOfType("deployments.DeviceDeploymentStatus")).
+				Return(deployments.DeviceDeploymentStatusPending, nil)
+
+			deploymentStorage := new(mocks.DeploymentsStorage)
+			deploymentStorage.On("FindByID",
+				h.ContextMatcher(), deploymentID).
+				Return(&deployments.Deployment{
+					Id:        StringToPointer(deploymentID),
+					Artifacts: []string{validUUIDv4},
+					Stats:     deployments.NewDeviceDeploymentStats(),
+					DeploymentConstructor: &deployments.DeploymentConstructor{
+						ArtifactName:      StringToPointer("App 123"),
+						SnapshotArtifacts: true,
+					},
+					DeviceTypeArtifacts: []deployments.DeviceTypeArtifact{
+						{DeviceType: "hammer", ArtifactID: validUUIDv4},
+					},
+				}, nil)
+			deploymentStorage.On("UpdateStats",
+				h.ContextMatcher(), deploymentID,
+				mock.AnythingOfType("string"), mock.AnythingOfType("string")).
+				Return(nil)
+			deploymentStorage.On("IncrementStatsRollup",
+				h.ContextMatcher(), mock.AnythingOfType("time.Time"),
+				mock.AnythingOfType("string")).
+				Return(nil)
+			deploymentStorage.On("Finish",
+				h.ContextMatcher(), deploymentID, mock.AnythingOfType("time.Time")).
+				Return(nil)
+
+			artifactGetter := new(mocks.ArtifactGetter)
+			artifactGetter.On("FindByID",
+				h.ContextMatcher(), validUUIDv4).
+				Return(artifact, testCase.InputFindError)
+
+			imageLinker := new(mocks.GetRequester)
+			imageLinker.On("GetRequest", h.ContextMatcher(), validUUIDv4,
+				DefaultUpdateDownloadLinkExpire, mock.AnythingOfType("string")).
+				Return(&images.Link{}, nil)
+
+			model := NewDeploymentModel(DeploymentsModelConfig{
+				DeploymentsStorage:       deploymentStorage,
+				DeviceDeploymentsStorage: deviceDeploymentStorage,
+				ArtifactGetter:           artifactGetter,
+				ImageLinker:              imageLinker,
+			})
+
+			out, err := model.GetDeploymentForDeviceWithCurrent(context.Background(),
+				"device-1", deployments.InstalledDeviceDeployment{
+					Artifact:   "App 122",
+					DeviceType: testCase.InputDeviceType,
+				})
+			if testCase.OutputError != nil {
+				assert.EqualError(t, err, testCase.OutputError.Error())
+				return
+			}
+
+			assert.NoError(t, err)
+			if testCase.OutputInstructions {
+				if assert.NotNil(t, out) {
+					assert.Equal(t, "App 123", out.Artifact.ArtifactName)
 				}
+			} else {
+				assert.Nil(t, out)
+				artifactGetter.AssertNotCalled(t, "FindByID", mock.Anything, mock.Anything)
 			}
+			if testCase.OutputReason != nil {
+				deviceDeploymentStorage.AssertCalled(t, "UpdateDeviceDeploymentStatus",
+					h.ContextMatcher(), "device-1", deploymentID,
+					mock.MatchedBy(func(status deployments.DeviceDeploymentStatus) bool {
+						return assert.ObjectsAreEqual(testCase.OutputReason, status.Reason)
+					}))
+			}
+			artifactGetter.AssertNotCalled(t, "ImageByIdsAndDeviceType",
+				mock.Anything, mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestDeploymentModelGetDeploymentForDeviceCache(t *testing.T) {
+
+	artifact := images.NewSoftwareImage(
+		validUUIDv4,
+		&images.SoftwareImageMetaConstructor{},
+		&images.SoftwareImageMetaArtifactConstructor{
+			Name:                  "App 123",
+			DeviceTypesCompatible: []string{"hammer"},
+		})
+	artifact.Size = 1024
+
+	testCases := map[string]struct {
+		InputCacheTTL   time.Duration
+		InputLinkExpire time.Time
+
+		OutputLookups int
+	}{
+		"cached": {
+			InputCacheTTL:   time.Hour,
+			InputLinkExpire: time.Now().Add(DefaultUpdateDownloadLinkExpire),
+
+			OutputLookups: 1,
+		},
+		"cache disabled": {
+			InputLinkExpire: time.Now().Add(DefaultUpdateDownloadLinkExpire),
+
+			OutputLookups: 3,
+		},
+		"link expires before cache entry": {
+			InputCacheTTL:   time.Hour,
+			InputLinkExpire: time.Now().Add(time.Minute),
+
+			OutputLookups: 3,
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+
+			deploymentID := "b532b01a-9313-404f-8d19-e7fcbe5cc347"
+			devices := []string{"device-1", "device-2", "device-3"}
+
+			deviceDeploymentStorage := new(mocks.DeviceDeploymentStorage)
+			for _, device := range devices {
+				deviceDeploymentStorage.On("FindOldestDeploymentForDeviceIDWithStatuses",
+					h.ContextMatcher(), device, mock.AnythingOfType("[]string")).
+					Return(&deployments.DeviceDeployment{
+						DeviceId:     StringToPointer(device),
+						DeploymentId: StringToPointer(deploymentID),
+					}, nil)
+			}
+			deviceDeploymentStorage.On("AssignArtifact",
+				h.ContextMatcher(), mock.AnythingOfType("string"), deploymentID,
+				artifact).
+				Return(nil)
+			deviceDeploymentStorage.On("IncrementDeviceDeploymentAttempts",
+				h.ContextMatcher(), mock.AnythingOfType("string"), mock.AnythingOfType("string")).
+				Return(nil)
+
+			deploymentStorage := new(mocks.DeploymentsStorage)
+			deploymentStorage.On("FindByID",
+				h.ContextMatcher(), deploymentID).
+				Return(&deployments.Deployment{
+					Id:        StringToPointer(deploymentID),
+					Artifacts: []string{validUUIDv4},
+					DeploymentConstructor: &deployments.DeploymentConstructor{
+						ArtifactName: StringToPointer("App 123"),
+					},
+				}, nil)
+
+			artifactGetter := new(mocks.ArtifactGetter)
+			artifactGetter.On("ImageByIdsAndDeviceType",
+				h.ContextMatcher(), []string{validUUIDv4}, "hammer").
+				Return(artifact, nil)
+
+			link := &images.Link{Uri: "http://download", Expire: testCase.InputLinkExpire}
+			imageLinker := new(mocks.GetRequester)
+			imageLinker.On("GetRequest", h.ContextMatcher(), validUUIDv4,
+				DefaultUpdateDownloadLinkExpire, mock.AnythingOfType("string")).
+				Return(link, nil)
+
+			downloadsStorage := new(mocks.DownloadsStorage)
+			downloadsStorage.On("InsertDownload",
+				h.ContextMatcher(),
+				mock.MatchedBy(func(d *deployments.Download) bool {
+					return d.ArtifactID == validUUIDv4 &&
+						d.DeploymentID == deploymentID &&
+						d.DeviceID != ""
+				})).
+				Return(nil)
+
+			egress := new(mocks.EgressRecorder)
+			egress.On("RecordEgress", h.ContextMatcher(), int64(1024)).
+				Return()
 
 			model := NewDeploymentModel(DeploymentsModelConfig{
 				DeploymentsStorage:       deploymentStorage,
 				DeviceDeploymentsStorage: deviceDeploymentStorage,
+				ArtifactGetter:           artifactGetter,
+				ImageLinker:              imageLinker,
+				ArtifactLinkCacheTTL:     testCase.InputCacheTTL,
+				DownloadsStorage:         downloadsStorage,
+				Egress:                   egress,
 			})
 
-			err := model.UpdateDeviceDeploymentStatus(context.Background(),
-				*testCase.InputDeployment.Id,
+			for _, device := range devices {
+				out, err := model.GetDeploymentForDeviceWithCurrent(context.Background(),
+					device, deployments.InstalledDeviceDeployment{
+						Artifact:   "App 122",
+						DeviceType: "hammer",
+					})
+				assert.NoError(t, err)
+				if assert.NotNil(t, out) {
+					assert.Equal(t, *link, out.Artifact.Source)
+				}
+			}
+
+			artifactGetter.AssertNumberOfCalls(t, "ImageByIdsAndDeviceType", testCase.OutputLookups)
+			imageLinker.AssertNumberOfCalls(t, "GetRequest", testCase.OutputLookups)
+			deviceDeploymentStorage.AssertNumberOfCalls(t, "AssignArtifact", len(devices))
+			// every issued link is recorded, cached or not
+			downloadsStorage.AssertNumberOfCalls(t, "InsertDownload", len(devices))
+			egress.AssertNumberOfCalls(t, "RecordEgress", len(devices))
+		})
+	}
+}
+
+func TestDeploymentModelGetDeploymentForDeviceCacheMirrors(t *testing.T) {
+
+	artifact := images.NewSoftwareImage(
+		validUUIDv4,
+		&images.SoftwareImageMetaConstructor{},
+		&images.SoftwareImageMetaArtifactConstructor{
+			Name:                  "App 123",
+			DeviceTypesCompatible: []string{"hammer"},
+		})
+
+	deploymentID := "b532b01a-9313-404f-8d19-e7fcbe5cc347"
+	devices := map[string]struct {
+		IP  string
+		URI string
+	}{
+		"device-eu":   {IP: "10.1.0.5", URI: "https://eu.cdn.example.com/artifact?sig=1"},
+		"device-apac": {IP: "10.2.0.5", URI: "https://apac.cdn.example.com/artifact?sig=1"},
+		"device-none": {IP: "192.168.0.5", URI: "https://s3.example.com/artifact?sig=1"},
+	}
+
+	deviceDeploymentStorage := new(mocks.DeviceDeploymentStorage)
+	for device := range devices {
+		deviceDeploymentStorage.On("FindOldestDeploymentForDeviceIDWithStatuses",
+			h.ContextMatcher(), device, mock.AnythingOfType("[]string")).
+			Return(&deployments.DeviceDeployment{
+				DeviceId:     StringToPointer(device),
+				DeploymentId: StringToPointer(deploymentID),
+			}, nil)
+	}
+	deviceDeploymentStorage.On("AssignArtifact",
+		h.ContextMatcher(), mock.AnythingOfType("string"), deploymentID, artifact).
+		Return(nil)
+	deviceDeploymentStorage.On("IncrementDeviceDeploymentAttempts",
+		h.ContextMatcher(), mock.AnythingOfType("string"), deploymentID).
+		Return(nil)
+
+	deploymentStorage := new(mocks.DeploymentsStorage)
+	deploymentStorage.On("FindByID",
+		h.ContextMatcher(), deploymentID).
+		Return(&deployments.Deployment{
+			Id:        StringToPointer(deploymentID),
+			Artifacts: []string{validUUIDv4},
+			DeploymentConstructor: &deployments.DeploymentConstructor{
+				ArtifactName: StringToPointer("App 123"),
+			},
+		}, nil)
+
+	artifactGetter := new(mocks.ArtifactGetter)
+	artifactGetter.On("ImageByIdsAndDeviceType",
+		h.ContextMatcher(), []string{validUUIDv4}, "hammer").
+		Return(artifact, nil)
+
+	link := &images.Link{
+		Uri:    "https://s3.example.com/artifact?sig=1",
+		Expire: time.Now().Add(DefaultUpdateDownloadLinkExpire),
+	}
+	imageLinker := new(mocks.GetRequester)
+	imageLinker.On("GetRequest", h.ContextMatcher(), validUUIDv4,
+		DefaultUpdateDownloadLinkExpire, mock.AnythingOfType("string")).
+		Return(link, nil)
+
+	eu, err := mirror.ParseNetworkRule("10.1.0.0/16=https://eu.cdn.example.com")
+	assert.NoError(t, err)
+	apac, err := mirror.ParseNetworkRule("10.2.0.0/16=https://apac.cdn.example.com")
+	assert.NoError(t, err)
+
+	model := NewDeploymentModel(DeploymentsModelConfig{
+		DeploymentsStorage:       deploymentStorage,
+		DeviceDeploymentsStorage: deviceDeploymentStorage,
+		ArtifactGetter:           artifactGetter,
+		ImageLinker:              imageLinker,
+		LinkRewriter:             mirror.NewLinker(imageLinker, *eu, *apac),
+		ArtifactLinkCacheTTL:     time.Hour,
+	})
+
+	for _, device := range []string{"device-eu", "device-apac", "device-none"} {
+		ctx := mirror.WithClient(context.Background(), &mirror.Client{
+			IP: net.ParseIP(devices[device].IP),
+		})
+		out, err := model.GetDeploymentForDeviceWithCurrent(ctx,
+			device, deployments.InstalledDeviceDeployment{
+				Artifact:   "App 122",
+				DeviceType: "hammer",
+			})
+		assert.NoError(t, err)
+		if assert.NotNil(t, out) {
+			assert.Equal(t, devices[device].URI, out.Artifact.Source.Uri, device)
+		}
+	}
+
+	// storage link is cached, mirror is selected for every device
+	imageLinker.AssertNumberOfCalls(t, "GetRequest", 1)
+	assert.Equal(t, "https://s3.example.com/artifact?sig=1", link.Uri)
+}
+
+func TestDeploymentModelCreateDeploymentFreeze(t *testing.T) {
+
+	start := time.Date(2018, 12, 20, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2019, 1, 2, 0, 0, 0, 0, time.UTC)
+	holidays := deployments.NewFreezePeriodFromConstructor(
+		&deployments.FreezePeriodConstructor{
+			Name:  "holidays",
+			Start: &start,
+			End:   &end,
+		})
+
+	testCases := map[string]struct {
+		InputOverrideFreeze    bool
+		InputFreezePeriod      *deployments.FreezePeriod
+		InputFreezePeriodError error
+
+		OutputError error
+	}{
+		"not frozen": {},
+		"frozen": {
+			InputFreezePeriod: holidays,
+
+			OutputError: errors.New("Freeze period holidays in effect until 2019-01-02T00:00:00Z: " +
+				controller.ErrDeploymentFrozen.Error()),
+		},
+		"frozen, override": {
+			InputOverrideFreeze: true,
+			InputFreezePeriod:   holidays,
+		},
+		"storage error": {
+			InputFreezePeriodError: errors.New("storage issue"),
+
+			OutputError: errors.New("Checking deployment freeze periods: storage issue"),
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+
+			deploymentStorage := new(mocks.DeploymentsStorage)
+			deploymentStorage.On("FindBySlug",
+				h.ContextMatcher(), mock.AnythingOfType("string")).
+				Return(nil, nil)
+			deploymentStorage.On("Insert",
+				h.ContextMatcher(),
+				mock.AnythingOfType("*deployments.Deployment")).
+				Return(nil)
+
+			deviceDeploymentStorage := new(mocks.DeviceDeploymentStorage)
+			deviceDeploymentStorage.On("InsertMany",
+				h.ContextMatcher(),
+				mock.AnythingOfType("[]*deployments.DeviceDeployment")).
+				Return(nil)
+
+			artifactGetter := new(mocks.ArtifactGetter)
+			artifactGetter.On("ImagesByName",
+				h.ContextMatcher(), "App 123").
+				Return([]*images.SoftwareImage{
+					images.NewSoftwareImage(
+						validUUIDv4,
+						&images.SoftwareImageMetaConstructor{},
+						&images.SoftwareImageMetaArtifactConstructor{
+							Name:                  "App 123",
+							DeviceTypesCompatible: []string{"hammer"},
+						}),
+				}, nil)
+
+			freezePeriodsStorage := new(mocks.FreezePeriodsStorage)
+			freezePeriodsStorage.On("FindActiveFreezePeriod",
+				h.ContextMatcher(), mock.AnythingOfType("time.Time")).
+				Return(testCase.InputFreezePeriod, testCase.InputFreezePeriodError)
+
+			model := NewDeploymentModel(DeploymentsModelConfig{
+				DeploymentsStorage:       deploymentStorage,
+				DeviceDeploymentsStorage: deviceDeploymentStorage,
+				ArtifactGetter:           artifactGetter,
+				FreezePeriodsStorage:     freezePeriodsStorage,
+			})
+
+			_, err := model.CreateDeployment(context.Background(),
+				&deployments.DeploymentConstructor{
+					Name:           StringToPointer("NYC Production"),
+					ArtifactName:   StringToPointer("App 123"),
+					Devices:        []string{"b532b01a-9313-404f-8d19-e7fcbe5cc347"},
+					OverrideFreeze: testCase.InputOverrideFreeze,
+				})
+			if testCase.OutputError != nil {
+				assert.EqualError(t, err, testCase.OutputError.Error())
+				deploymentStorage.AssertNotCalled(t, "Insert", mock.Anything, mock.Anything)
+				return
+			}
+
+			assert.NoError(t, err)
+			if testCase.InputOverrideFreeze {
+				freezePeriodsStorage.AssertNotCalled(t, "FindActiveFreezePeriod",
+					mock.Anything, mock.Anything)
+			}
+		})
+	}
+}
+
+func TestDeploymentModelCreateDeploymentScriptsAck(t *testing.T) {
+
+	testCases := map[string]struct {
+		InputScriptsAckTenants []string
+		InputTenant            string
+		InputScripts           []string
+		InputAcknowledge       bool
+
+		OutputError error
+	}{
+		"not required": {
+			InputScripts: []string{"ArtifactInstall_Enter_00"},
+		},
+		"required, no scripts": {
+			InputScriptsAckTenants: []string{"*"},
+		},
+		"required for all": {
+			InputScriptsAckTenants: []string{"*"},
+			InputScripts:           []string{"ArtifactInstall_Enter_00", "ArtifactCommit_Leave_00"},
+
+			OutputError: deployments.NewValidationError("acknowledge_scripts",
+				deployments.ValidationCodeRequired,
+				"artifacts contain state scripts: ArtifactInstall_Enter_00, ArtifactCommit_Leave_00"),
+		},
+		"required for tenant": {
+			InputScriptsAckTenants: []string{"acme"},
+			InputTenant:            "acme",
+			InputScripts:           []string{"ArtifactInstall_Enter_00"},
+
+			OutputError: deployments.NewValidationError("acknowledge_scripts",
+				deployments.ValidationCodeRequired,
+				"artifacts contain state scripts: ArtifactInstall_Enter_00"),
+		},
+		"required for other tenant": {
+			InputScriptsAckTenants: []string{"acme"},
+			InputTenant:            "initech",
+			InputScripts:           []string{"ArtifactInstall_Enter_00"},
+		},
+		"acknowledged": {
+			InputScriptsAckTenants: []string{"acme"},
+			InputTenant:            "acme",
+			InputScripts:           []string{"ArtifactInstall_Enter_00"},
+			InputAcknowledge:       true,
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+
+			deploymentStorage := new(mocks.DeploymentsStorage)
+			deploymentStorage.On("FindBySlug",
+				h.ContextMatcher(), mock.AnythingOfType("string")).
+				Return(nil, nil)
+			deploymentStorage.On("Insert",
+				h.ContextMatcher(),
+				mock.AnythingOfType("*deployments.Deployment")).
+				Return(nil)
+
+			deviceDeploymentStorage := new(mocks.DeviceDeploymentStorage)
+			deviceDeploymentStorage.On("InsertMany",
+				h.ContextMatcher(),
+				mock.AnythingOfType("[]*deployments.DeviceDeployment")).
+				Return(nil)
+
+			artifactGetter := new(mocks.ArtifactGetter)
+			artifactGetter.On("ImagesByName",
+				h.ContextMatcher(), "App 123").
+				Return([]*images.SoftwareImage{
+					images.NewSoftwareImage(
+						validUUIDv4,
+						&images.SoftwareImageMetaConstructor{},
+						&images.SoftwareImageMetaArtifactConstructor{
+							Name:                  "App 123",
+							DeviceTypesCompatible: []string{"hammer"},
+							Scripts:               testCase.InputScripts,
+						}),
+				}, nil)
+
+			model := NewDeploymentModel(DeploymentsModelConfig{
+				DeploymentsStorage:       deploymentStorage,
+				DeviceDeploymentsStorage: deviceDeploymentStorage,
+				ArtifactGetter:           artifactGetter,
+				ScriptsAckTenants:        testCase.InputScriptsAckTenants,
+			})
+
+			ctx := context.Background()
+			if testCase.InputTenant != "" {
+				ctx = identity.WithContext(ctx, &identity.Identity{
+					Tenant: testCase.InputTenant,
+				})
+			}
+
+			_, err := model.CreateDeployment(ctx,
+				&deployments.DeploymentConstructor{
+					Name:               StringToPointer("NYC Production"),
+					ArtifactName:       StringToPointer("App 123"),
+					Devices:            []string{"b532b01a-9313-404f-8d19-e7fcbe5cc347"},
+					AcknowledgeScripts: testCase.InputAcknowledge,
+				})
+			if testCase.OutputError != nil {
+				assert.EqualError(t, err, testCase.OutputError.Error())
+				deploymentStorage.AssertNotCalled(t, "Insert", mock.Anything, mock.Anything)
+				return
+			}
+
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestDeploymentModelCreateFreezePeriod(t *testing.T) {
+
+	start := time.Date(2018, 12, 20, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2019, 1, 2, 0, 0, 0, 0, time.UTC)
+
+	testCases := map[string]struct {
+		InputConstructor *deployments.FreezePeriodConstructor
+		InputInsertError error
+
+		OutputError error
+	}{
+		"ok": {
+			InputConstructor: &deployments.FreezePeriodConstructor{
+				Name:  "holidays",
+				Start: &start,
+				End:   &end,
+			},
+		},
+		"missing input": {
+			OutputError: controller.ErrModelMissingInput,
+		},
+		"invalid period": {
+			InputConstructor: &deployments.FreezePeriodConstructor{
+				Name:  "holidays",
+				Start: &end,
+				End:   &start,
+			},
+			OutputError: errors.New("Validating freeze period: " +
+				deployments.ErrInvalidFreezePeriod.Error()),
+		},
+		"storage error": {
+			InputConstructor: &deployments.FreezePeriodConstructor{
+				Name:  "holidays",
+				Start: &start,
+				End:   &end,
+			},
+			InputInsertError: errors.New("storage issue"),
+
+			OutputError: errors.New("Storing freeze period: storage issue"),
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+
+			freezePeriodsStorage := new(mocks.FreezePeriodsStorage)
+			freezePeriodsStorage.On("InsertFreezePeriod",
+				h.ContextMatcher(), mock.AnythingOfType("*deployments.FreezePeriod")).
+				Return(testCase.InputInsertError)
+
+			model := NewDeploymentModel(DeploymentsModelConfig{
+				FreezePeriodsStorage: freezePeriodsStorage,
+			})
+
+			id, err := model.CreateFreezePeriod(context.Background(),
+				testCase.InputConstructor)
+			if testCase.OutputError != nil {
+				assert.EqualError(t, err, testCase.OutputError.Error())
+				assert.Empty(t, id)
+			} else {
+				assert.NoError(t, err)
+				assert.NotEmpty(t, id)
+			}
+		})
+	}
+}
+
+func TestDeploymentModelImageDeployedSince(t *testing.T) {
+
+	since := time.Now().AddDate(0, 0, -30)
+
+	deploymentStorage := new(mocks.DeploymentsStorage)
+	deploymentStorage.On("ExistByArtifactIdCreatedAfter",
+		h.ContextMatcher(), "used", since).
+		Return(true, nil)
+	deploymentStorage.On("ExistByArtifactIdCreatedAfter",
+		h.ContextMatcher(), "broken", since).
+		Return(false, errors.New("storage error"))
+
+	model := NewDeploymentModel(DeploymentsModelConfig{
+		DeploymentsStorage: deploymentStorage,
+	})
+
+	found, err := model.ImageDeployedSince(context.Background(), "used", since)
+	assert.NoError(t, err)
+	assert.True(t, found)
+
+	found, err = model.ImageDeployedSince(context.Background(), "broken", since)
+	assert.EqualError(t, err, "Checking if image was deployed recently: storage error")
+	assert.False(t, found)
+}
+
+func TestDeploymentModelUpdateDeviceDeploymentStatus(t *testing.T) {
+
+	//t.Parallel()
+
+	testCases := []struct {
+		InputDeployment *deployments.Deployment
+		InputDeviceID   string
+		OldStatus       string
+		InputStatus     string
+
+		InputDevsStorageError error
+		InputDepsStorageError error
+		InputDepsFinishError  error
+		InputDepsFindError    error
+
+		isFinished      bool
+		isReset         bool
+		InputResetError error
+
+		OutputError error
+	}{
+		{
+			InputDeployment: &deployments.Deployment{
+				Id: StringToPointer("123"),
+				Stats: deployments.Stats{
+					deployments.DeviceDeploymentStatusPending: 1,
+				},
+			},
+			InputDeviceID: "123",
+			InputStatus:   "installing",
+			OldStatus:     "pending",
+
+			InputDevsStorageError: errors.New("device deployments storage issue"),
+			InputDepsStorageError: nil,
+
+			OutputError: errors.New("device deployments storage issue"),
+		},
+		{
+			InputDeployment: &deployments.Deployment{
+				Id: StringToPointer("234"),
+				Stats: deployments.Stats{
+					deployments.DeviceDeploymentStatusPending: 1,
+				},
+			},
+			InputDeviceID: "234",
+			InputStatus:   "none",
+			OldStatus:     "pending",
+
+			InputDevsStorageError: nil,
+			InputDepsStorageError: errors.New("deployments storage issue"),
+
+			OutputError: errors.New("deployments storage issue"),
+		},
+		{
+			isFinished: true,
+			InputDeployment: &deployments.Deployment{
+				Id: StringToPointer("345"),
+				Stats: deployments.Stats{
+					deployments.DeviceDeploymentStatusSuccess: 1,
+				},
+			},
+			InputDeviceID: "345",
+			InputStatus:   "success",
+			OldStatus:     "installing",
+
+			InputDevsStorageError: nil,
+		},
+		{
+			isFinished: false,
+			InputDeployment: &deployments.Deployment{
+				Id: StringToPointer("345"),
+				Stats: deployments.Stats{
+					deployments.DeviceDeploymentStatusSuccess: 1,
+				},
+			},
+			InputDeviceID: "345",
+			InputStatus:   "installing",
+			OldStatus:     "installing",
+
+			InputDevsStorageError: nil,
+		},
+		{
+			isFinished: true,
+			InputDeployment: &deployments.Deployment{
+				Id: StringToPointer("456"),
+				Stats: deployments.Stats{
+					deployments.DeviceDeploymentStatusAlreadyInst: 1,
+				},
+			},
+			InputDeviceID: "456",
+			InputStatus:   "already-installed",
+			OldStatus:     "pending",
+
+			InputDevsStorageError: nil,
+		},
+		{
+			isFinished: true,
+			InputDeployment: &deployments.Deployment{
+				Id: StringToPointer("567"),
+				Stats: deployments.Stats{
+					deployments.DeviceDeploymentStatusAborted: 1,
+				},
+			},
+			InputDeviceID: "567",
+			InputStatus:   "aborted",
+			OldStatus:     "pending",
+		},
+		{
+			isFinished: true,
+			InputDeployment: &deployments.Deployment{
+				Id: StringToPointer("678"),
+				Stats: deployments.Stats{
+					deployments.DeviceDeploymentStatusSuccess: 1,
+				},
+			},
+			InputDeviceID: "678",
+			InputStatus:   "success",
+			OldStatus:     "rebooting",
+
+			InputDepsFinishError: errors.New("deployments storage finish issue"),
+			OutputError:          errors.New("failed to mark deployment as finished: deployments storage finish issue"),
+		},
+		{
+			isFinished: true,
+			InputDeployment: &deployments.Deployment{
+				Id: StringToPointer("789"),
+				Stats: deployments.Stats{
+					deployments.DeviceDeploymentStatusAborted: 1,
+				},
+			},
+			InputDeviceID: "789",
+			InputStatus:   "rebooting",
+			OldStatus:     "aborted",
+
+			OutputError: controller.ErrDeploymentAborted,
+		},
+		{
+			isFinished: true,
+			InputDeployment: &deployments.Deployment{
+				Id: StringToPointer("790"),
+				Stats: deployments.Stats{
+					deployments.DeviceDeploymentStatusTimedOut: 1,
+				},
+			},
+			InputDeviceID: "790",
+			InputStatus:   "success",
+			OldStatus:     "timed_out",
+
+			OutputError: controller.ErrDeploymentAborted,
+		},
+		{
+			isReset: true,
+			InputDeployment: &deployments.Deployment{
+				Id: StringToPointer("890"),
+				Stats: deployments.Stats{
+					deployments.DeviceDeploymentStatusDownloading: 1,
+				},
+			},
+			InputDeviceID: "890",
+			InputStatus:   "downloading",
+			OldStatus:     "installing",
+		},
+		{
+			isReset: true,
+			InputDeployment: &deployments.Deployment{
+				Id: StringToPointer("901"),
+				Stats: deployments.Stats{
+					deployments.DeviceDeploymentStatusDownloading: 1,
+				},
+			},
+			InputDeviceID:   "901",
+			InputStatus:     "downloading",
+			OldStatus:       "rebooting",
+			InputResetError: errors.New("counter issue"),
+		},
+	}
+
+	for testCaseNumber, testCase := range testCases {
+		t.Run(fmt.Sprintf("test case %d", testCaseNumber+1), func(t *testing.T) {
+
+			t.Logf("testing %s %s %s (in) %s (old) %v %v",
+				*testCase.InputDeployment.Id,
+				testCase.InputDeviceID, testCase.InputStatus,
+				testCase.OldStatus,
+				testCase.InputDevsStorageError, testCase.isFinished)
+
+			// status is always fetched
+			deviceDeploymentStorage := new(mocks.DeviceDeploymentStorage)
+			deviceDeploymentStorage.On("GetDeviceDeploymentStatus",
+				h.ContextMatcher(),
+				*testCase.InputDeployment.Id, testCase.InputDeviceID).
+				Return(testCase.OldStatus, testCase.InputDevsStorageError)
+
+			deploymentStorage := new(mocks.DeploymentsStorage)
+
+			// however any status updates in DB are done only if the
+			// status reported by device is different from the
+			// previous one in DB
+			if testCase.OldStatus != testCase.InputStatus {
+				deviceDeploymentStorage.On("UpdateDeviceDeploymentStatus",
+					h.ContextMatcher(),
+					testCase.InputDeviceID, *testCase.InputDeployment.Id,
+					mock.MatchedBy(func(ddStatus deployments.DeviceDeploymentStatus) bool {
+
+						statusOk := assert.Equal(t, testCase.InputStatus, ddStatus.Status)
+						finishOk := true
+						if testCase.isFinished {
+							finishOk = assert.NotNil(t, ddStatus.FinishTime) &&
+								assert.WithinDuration(t, time.Now(),
+									*ddStatus.FinishTime, time.Second)
+						}
+						return statusOk && finishOk
+					})).
+					Return(testCase.OldStatus, testCase.InputDevsStorageError)
+				if testCase.isReset {
+					deviceDeploymentStorage.On("IncrementDeviceDeploymentStatusResets",
+						h.ContextMatcher(),
+						testCase.InputDeviceID, *testCase.InputDeployment.Id).
+						Return(testCase.InputResetError)
+				}
+
+				deploymentStorage.On("UpdateStats",
+					h.ContextMatcher(),
+					*testCase.InputDeployment.Id, mock.AnythingOfType("string"),
+					mock.AnythingOfType("string")).
+					Return(testCase.InputDepsStorageError)
+				if testCase.isFinished {
+					deploymentStorage.On("IncrementStatsRollup",
+						h.ContextMatcher(),
+						mock.AnythingOfType("time.Time"),
+						testCase.InputStatus).
+						Return(errors.New("rollup failure"))
+				}
+				// deployment will be marked as finished when possible, for this we need to
+				// mock a couple of additional calls
+				deploymentStorage.On("FindByID",
+					h.ContextMatcher(),
+					*testCase.InputDeployment.Id).
+					Return(testCase.InputDeployment, testCase.InputDepsFindError)
+				if testCase.isFinished {
+					deploymentStorage.On("Finish",
+						h.ContextMatcher(),
+						*testCase.InputDeployment.Id,
+						mock.MatchedBy(func(tm time.Time) bool {
+							return assert.WithinDuration(t, time.Now(), tm, time.Second)
+						})).
+						Return(testCase.InputDepsFinishError)
+				}
+			}
+
+			model := NewDeploymentModel(DeploymentsModelConfig{
+				DeploymentsStorage:       deploymentStorage,
+				DeviceDeploymentsStorage: deviceDeploymentStorage,
+			})
+
+			err := model.UpdateDeviceDeploymentStatus(context.Background(),
+				*testCase.InputDeployment.Id,
+				testCase.InputDeviceID,
+				deployments.DeviceDeploymentStatus{
+					Status: testCase.InputStatus,
+				})
+			if testCase.OutputError != nil {
+				assert.EqualError(t, err, testCase.OutputError.Error())
+			} else {
+				assert.NoError(t, err)
+
+				// make sure that storage calls were done as
+				// expected
+				mock.AssertExpectationsForObjects(t, deviceDeploymentStorage,
+					deploymentStorage)
+			}
+		})
+	}
+
+}
+
+func TestGetDeploymentStats(t *testing.T) {
+
+	//t.Parallel()
+
+	testCases := []struct {
+		InputDeploymentID         string
+		InputModelDeploymentStats deployments.Stats
+		InputModelError           error
+
+		InoutFindByIDDeployment *deployments.Deployment
+		InoutFindByIDError      error
+
+		OutputStats deployments.Stats
+		OutputError error
+	}{
+		{
+			InputDeploymentID:         "ID:123",
+			InputModelDeploymentStats: nil,
+
+			InoutFindByIDDeployment: new(deployments.Deployment),
+
+			OutputStats: nil,
+		},
+		{
+			InputDeploymentID: "ID:234",
+			InputModelError:   errors.New("storage issue"),
+
+			InoutFindByIDDeployment: new(deployments.Deployment),
+
+			OutputError: errors.New("storage issue"),
+		},
+		{
+			InputDeploymentID: "ID:234",
+			InputModelError:   errors.New("storage issue"),
+
+			InoutFindByIDDeployment: nil,
+		},
+		{
+			InputDeploymentID: "ID:234",
+			InputModelError:   errors.New("storage issue"),
+
+			InoutFindByIDDeployment: new(deployments.Deployment),
+			InoutFindByIDError:      errors.New("an error"),
+
+			OutputError: errors.New("checking deployment id: an error"),
+		},
+		{
+			InputDeploymentID:       "ID:345",
+			InoutFindByIDDeployment: new(deployments.Deployment),
+			InputModelDeploymentStats: deployments.Stats{
+				deployments.DeviceDeploymentStatusPending:     2,
+				deployments.DeviceDeploymentStatusSuccess:     4,
+				deployments.DeviceDeploymentStatusFailure:     1,
+				deployments.DeviceDeploymentStatusInstalling:  3,
+				deployments.DeviceDeploymentStatusRebooting:   3,
+				deployments.DeviceDeploymentStatusDownloading: 3,
+				deployments.DeviceDeploymentStatusAlreadyInst: 0,
+			},
+
+			OutputStats: deployments.Stats{
+				deployments.DeviceDeploymentStatusDownloading: 3,
+				deployments.DeviceDeploymentStatusRebooting:   3,
+				deployments.DeviceDeploymentStatusInstalling:  3,
+				deployments.DeviceDeploymentStatusSuccess:     4,
+				deployments.DeviceDeploymentStatusFailure:     1,
+				deployments.DeviceDeploymentStatusPending:     2,
+				deployments.DeviceDeploymentStatusAlreadyInst: 0,
+			},
+		},
+	}
+
+	for testCaseNumber, testCase := range testCases {
+		t.Run(fmt.Sprintf("test case %d", testCaseNumber+1), func(t *testing.T) {
+			t.Logf("testing %s %v %v", testCase.InputDeploymentID,
+				testCase.InputModelDeploymentStats, testCase.InputModelError)
+
+			deviceDeploymentStorage := new(mocks.DeviceDeploymentStorage)
+			deviceDeploymentStorage.On("AggregateDeviceDeploymentByStatus",
+				h.ContextMatcher(),
+				testCase.InputDeploymentID).
+				Return(testCase.InputModelDeploymentStats, testCase.InputModelError)
+
+			deploymentStorage := new(mocks.DeploymentsStorage)
+			deploymentStorage.On("FindByID",
+				h.ContextMatcher(),
+				testCase.InputDeploymentID).
+				Return(testCase.InoutFindByIDDeployment, testCase.InoutFindByIDError)
+
+			model := NewDeploymentModel(DeploymentsModelConfig{
+				DeploymentsStorage:       deploymentStorage,
+				DeviceDeploymentsStorage: deviceDeploymentStorage,
+			})
+
+			stats, err := model.GetDeploymentStats(context.Background(),
+				testCase.InputDeploymentID)
+
+			if testCase.OutputError != nil {
+				assert.EqualError(t, err, testCase.OutputError.Error())
+			} else {
+				assert.NoError(t, err)
+
+				assert.Equal(t, testCase.OutputStats, stats)
+			}
+		})
+	}
+}
+
+func TestGetDeploymentFailureStats(t *testing.T) {
+
+	t.Parallel()
+
+	testCases := []struct {
+		InputDeploymentID   string
+		InputFailureStats   deployments.FailureStats
+		InputAggregateError error
+
+		InputFindByIDDeployment *deployments.Deployment
+		InputFindByIDError      error
+
+		OutputStats deployments.FailureStats
+		OutputError error
+	}{
+		{
+			InputDeploymentID:       "ID:123",
+			InputFindByIDDeployment: nil,
+		},
+		{
+			InputDeploymentID:  "ID:123",
+			InputFindByIDError: errors.New("an error"),
+
+			OutputError: errors.New("checking deployment id: an error"),
+		},
+		{
+			InputDeploymentID:       "ID:123",
+			InputFindByIDDeployment: new(deployments.Deployment),
+			InputAggregateError:     errors.New("storage issue"),
+
+			OutputError: errors.New("storage issue"),
+		},
+		{
+			InputDeploymentID:       "ID:123",
+			InputFindByIDDeployment: new(deployments.Deployment),
+			InputFailureStats: deployments.FailureStats{
+				"storage-full":                        2,
+				deployments.FailureStatsUncategorized: 1,
+			},
+
+			OutputStats: deployments.FailureStats{
+				"storage-full":                        2,
+				deployments.FailureStatsUncategorized: 1,
+			},
+		},
+	}
+
+	for testCaseNumber, testCase := range testCases {
+		t.Run(fmt.Sprintf("test case %d", testCaseNumber+1), func(t *testing.T) {
+
+			deviceDeploymentStorage := new(mocks.DeviceDeploymentStorage)
+			deviceDeploymentStorage.On("AggregateDeviceDeploymentFailures",
+				h.ContextMatcher(),
+				testCase.InputDeploymentID).
+				Return(testCase.InputFailureStats, testCase.InputAggregateError)
+
+			deploymentStorage := new(mocks.DeploymentsStorage)
+			deploymentStorage.On("FindByID",
+				h.ContextMatcher(),
+				testCase.InputDeploymentID).
+				Return(testCase.InputFindByIDDeployment, testCase.InputFindByIDError)
+
+			model := NewDeploymentModel(DeploymentsModelConfig{
+				DeploymentsStorage:       deploymentStorage,
+				DeviceDeploymentsStorage: deviceDeploymentStorage,
+			})
+
+			stats, err := model.GetDeploymentFailureStats(context.Background(),
+				testCase.InputDeploymentID)
+
+			if testCase.OutputError != nil {
+				assert.EqualError(t, err, testCase.OutputError.Error())
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, testCase.OutputStats, stats)
+			}
+		})
+	}
+}
+
+func TestGetDeploymentDurationStats(t *testing.T) {
+
+	t.Parallel()
+
+	durations := deployments.NewDurationStats()
+	durations[deployments.DeviceDeploymentStatusSuccess][2].Count = 5
+	durations[deployments.DeviceDeploymentStatusFailure][0].Count = 1
+
+	testCases := []struct {
+		InputDeploymentID   string
+		InputDurationStats  deployments.DurationStats
+		InputAggregateError error
+
+		InputFindByIDDeployment *deployments.Deployment
+		InputFindByIDError      error
+
+		OutputStats deployments.DurationStats
+		OutputError error
+	}{
+		{
+			InputDeploymentID:       "ID:123",
+			InputFindByIDDeployment: nil,
+		},
+		{
+			InputDeploymentID:  "ID:123",
+			InputFindByIDError: errors.New("an error"),
+
+			OutputError: errors.New("checking deployment id: an error"),
+		},
+		{
+			InputDeploymentID:       "ID:123",
+			InputFindByIDDeployment: new(deployments.Deployment),
+			InputAggregateError:     errors.New("storage issue"),
+
+			OutputError: errors.New("storage issue"),
+		},
+		{
+			InputDeploymentID:       "ID:123",
+			InputFindByIDDeployment: new(deployments.Deployment),
+			InputDurationStats:      durations,
+
+			OutputStats: durations,
+		},
+	}
+
+	for testCaseNumber, testCase := range testCases {
+		t.Run(fmt.Sprintf("test case %d", testCaseNumber+1), func(t *testing.T) {
+
+			deviceDeploymentStorage := new(mocks.DeviceDeploymentStorage)
+			deviceDeploymentStorage.On("AggregateDeviceDeploymentDurations",
+				h.ContextMatcher(),
+				testCase.InputDeploymentID).
+				Return(testCase.InputDurationStats, testCase.InputAggregateError)
+
+			deploymentStorage := new(mocks.DeploymentsStorage)
+			deploymentStorage.On("FindByID",
+				h.ContextMatcher(),
+				testCase.InputDeploymentID).
+				Return(testCase.InputFindByIDDeployment, testCase.InputFindByIDError)
+
+			model := NewDeploymentModel(DeploymentsModelConfig{
+				DeploymentsStorage:       deploymentStorage,
+				DeviceDeploymentsStorage: deviceDeploymentStorage,
+			})
+
+			stats, err := model.GetDeploymentDurationStats(context.Background(),
+				testCase.InputDeploymentID)
+
+			if testCase.OutputError != nil {
+				assert.EqualError(t, err, testCase.OutputError.Error())
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, testCase.OutputStats, stats)
+			}
+		})
+	}
+}
+
+func TestGetStatsSummary(t *testing.T) {
+
+	t.Parallel()
+
+	testCases := []struct {
+		InputCount      map[deployments.StatusQuery]int
+		InputCountError error
+
+		InputDayStats  deployments.Stats
+		InputWeekStats deployments.Stats
+		InputAggError  error
+
+		OutputSummary *deployments.StatsSummary
+		OutputError   error
+	}{
+		{
+			InputCount: map[deployments.StatusQuery]int{
+				deployments.StatusQueryPending:    1,
+				deployments.StatusQueryInProgress: 2,
+				deployments.StatusQueryFinished:   3,
+			},
+			InputDayStats: deployments.Stats{
+				deployments.DeviceDeploymentStatusSuccess: 2,
+			},
+			InputWeekStats: deployments.Stats{
+				deployments.DeviceDeploymentStatusSuccess: 6,
+				deployments.DeviceDeploymentStatusFailure: 2,
+			},
+
+			OutputSummary: &deployments.StatsSummary{
+				Deployments: deployments.DeploymentsCount{
+					Pending:    1,
+					InProgress: 2,
+					Finished:   3,
+				},
+				DevicesUpdated24h: 2,
+				DevicesUpdated7d:  6,
+				FailureRate:       0.25,
+			},
+		},
+		{
+			InputCountError: errors.New("storage issue"),
+
+			OutputError: errors.New("Counting deployments: storage issue"),
+		},
+		{
+			InputAggError: errors.New("storage issue"),
+
+			OutputError: errors.New("Aggregating device deployment statistics: storage issue"),
+		},
+	}
+
+	for testCaseNumber, testCase := range testCases {
+		t.Run(fmt.Sprintf("test case %d", testCaseNumber+1), func(t *testing.T) {
+
+			deploymentStorage := new(mocks.DeploymentsStorage)
+			for _, status := range []deployments.StatusQuery{
+				deployments.StatusQueryPending,
+				deployments.StatusQueryInProgress,
+				deployments.StatusQueryFinished,
+			} {
+				deploymentStorage.On("CountByStatus",
+					h.ContextMatcher(), status).
+					Return(testCase.InputCount[status], testCase.InputCountError)
+			}
+			deploymentStorage.On("AggregateStatsRollups",
+				h.ContextMatcher(),
+				mock.MatchedBy(func(since time.Time) bool {
+					return time.Since(since) < 25*time.Hour
+				})).
+				Return(testCase.InputDayStats, testCase.InputAggError)
+			deploymentStorage.On("AggregateStatsRollups",
+				h.ContextMatcher(),
+				mock.AnythingOfType("time.Time")).
+				Return(testCase.InputWeekStats, testCase.InputAggError)
+
+			model := NewDeploymentModel(DeploymentsModelConfig{
+				DeploymentsStorage: deploymentStorage,
+			})
+
+			summary, err := model.GetStatsSummary(context.Background())
+
+			if testCase.OutputError != nil {
+				assert.EqualError(t, err, testCase.OutputError.Error())
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, testCase.OutputSummary, summary)
+			}
+		})
+	}
+}
+
+func TestGetDeploymentStatsByGroup(t *testing.T) {
+
+	t.Parallel()
+
+	statuses := []deployments.DeviceDeployment{
+		{
+			DeviceId: StringToPointer("dev1"),
+			Status:   StringToPointer(deployments.DeviceDeploymentStatusSuccess),
+		},
+		{
+			DeviceId: StringToPointer("dev2"),
+			Status:   StringToPointer(deployments.DeviceDeploymentStatusFailure),
+		},
+		{
+			DeviceId: StringToPointer("dev3"),
+			Status:   StringToPointer(deployments.DeviceDeploymentStatusFailure),
+		},
+	}
+
+	withStatus := func(counts map[string]int) deployments.Stats {
+		stats := deployments.NewDeviceDeploymentStats()
+		for status, count := range counts {
+			stats[status] = count
+		}
+		return stats
+	}
+
+	testCases := map[string]struct {
+		InputAttribute string
+
+		InputFindByIDDeployment *deployments.Deployment
+		InputFindByIDError      error
+
+		InputStatuses      []deployments.DeviceDeployment
+		InputStatusesError error
+
+		InputGroups   map[string]string
+		InputDevices  map[string]*integration.Device
+		InputInvError error
+
+		OutputStats deployments.GroupStats
+		OutputError error
+	}{
+		"deployment not found": {
+			InputAttribute: DeviceAttributeGroup,
+		},
+		"find deployment error": {
+			InputAttribute:     DeviceAttributeGroup,
+			InputFindByIDError: errors.New("db error"),
+
+			OutputError: errors.New("checking deployment id: db error"),
+		},
+		"statuses error": {
+			InputAttribute:          DeviceAttributeGroup,
+			InputFindByIDDeployment: new(deployments.Deployment),
+			InputStatusesError:      errors.New("db error"),
+
+			OutputError: errors.New("Searching for device deployments: db error"),
+		},
+		"inventory error": {
+			InputAttribute:          DeviceAttributeGroup,
+			InputFindByIDDeployment: new(deployments.Deployment),
+			InputStatuses:           statuses,
+			InputInvError:           errors.New("connection refused"),
+
+			OutputError: errors.New("Fetching device inventory: connection refused"),
+		},
+		"by group": {
+			InputAttribute:          DeviceAttributeGroup,
+			InputFindByIDDeployment: new(deployments.Deployment),
+			InputStatuses:           statuses,
+			InputGroups: map[string]string{
+				"dev1": "site-a",
+				"dev2": "site-b",
+				"dev3": "",
+			},
+
+			OutputStats: deployments.GroupStats{
+				"site-a": withStatus(map[string]int{
+					deployments.DeviceDeploymentStatusSuccess: 1,
+				}),
+				"site-b": withStatus(map[string]int{
+					deployments.DeviceDeploymentStatusFailure: 1,
+				}),
+				deployments.GroupStatsUnknown: withStatus(map[string]int{
+					deployments.DeviceDeploymentStatusFailure: 1,
+				}),
+			},
+		},
+		"by attribute": {
+			InputAttribute:          "region",
+			InputFindByIDDeployment: new(deployments.Deployment),
+			InputStatuses:           statuses,
+			InputDevices: map[string]*integration.Device{
+				"dev1": {Attributes: []*integration.Attribute{
+					{Name: "region", Value: "eu"},
+				}},
+				"dev2": {Attributes: []*integration.Attribute{
+					{Name: "region", Value: "eu"},
+				}},
+			},
+
+			OutputStats: deployments.GroupStats{
+				"eu": withStatus(map[string]int{
+					deployments.DeviceDeploymentStatusSuccess: 1,
+					deployments.DeviceDeploymentStatusFailure: 1,
+				}),
+				deployments.GroupStatsUnknown: withStatus(map[string]int{
+					deployments.DeviceDeploymentStatusFailure: 1,
+				}),
+			},
+		},
+		"by snapshot": {
+			InputAttribute:          DeviceAttributeGroup,
+			InputFindByIDDeployment: new(deployments.Deployment),
+			InputStatuses: []deployments.DeviceDeployment{
+				{
+					DeviceId:  StringToPointer("dev1"),
+					Status:    StringToPointer(deployments.DeviceDeploymentStatusSuccess),
+					Inventory: map[string]string{DeviceAttributeGroup: "site-a"},
+				},
+				{
+					DeviceId: StringToPointer("dev2"),
+					Status:   StringToPointer(deployments.DeviceDeploymentStatusFailure),
+				},
+			},
+			InputGroups: map[string]string{
+				"dev1": "site-b",
+				"dev2": "site-b",
+			},
+
+			OutputStats: deployments.GroupStats{
+				"site-a": withStatus(map[string]int{
+					deployments.DeviceDeploymentStatusSuccess: 1,
+				}),
+				"site-b": withStatus(map[string]int{
+					deployments.DeviceDeploymentStatusFailure: 1,
+				}),
+			},
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+
+			deploymentStorage := new(mocks.DeploymentsStorage)
+			deploymentStorage.On("FindByID",
+				h.ContextMatcher(), validUUIDv4).
+				Return(testCase.InputFindByIDDeployment, testCase.InputFindByIDError)
+
+			deviceDeploymentStorage := new(mocks.DeviceDeploymentStorage)
+			deviceDeploymentStorage.On("GetDeviceStatusesForDeployment",
+				h.ContextMatcher(), validUUIDv4).
+				Return(testCase.InputStatuses, testCase.InputStatusesError)
+
+			inventory := new(mocks.Inventory)
+			for _, status := range testCase.InputStatuses {
+				id := integration.DeviceID(*status.DeviceId)
+				inventory.On("GetDeviceGroup", h.ContextMatcher(), id).
+					Return(testCase.InputGroups[*status.DeviceId], testCase.InputInvError)
+				inventory.On("GetDeviceInventory", h.ContextMatcher(), id).
+					Return(testCase.InputDevices[*status.DeviceId], testCase.InputInvError)
+			}
+
+			model := NewDeploymentModel(DeploymentsModelConfig{
+				DeploymentsStorage:       deploymentStorage,
+				DeviceDeploymentsStorage: deviceDeploymentStorage,
+				Inventory:                inventory,
+			})
+
+			stats, err := model.GetDeploymentStatsByGroup(context.Background(),
+				validUUIDv4, testCase.InputAttribute)
+
+			if testCase.OutputError != nil {
+				assert.EqualError(t, err, testCase.OutputError.Error())
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, testCase.OutputStats, stats)
+			}
+		})
+	}
+}
+
+func TestDeploymentModelGetLatestDeviceDeployment(t *testing.T) {
+
+	t.Parallel()
+
+	deviceDeployment := deployments.NewDeviceDeployment("dev1", validUUIDv4)
+
+	testCases := map[string]struct {
+		InputDeploymentIDs []string
+
+		InputStorageDeployment *deployments.DeviceDeployment
+		InputStorageError      error
+
+		OutputDeployment *deployments.DeviceDeployment
+		OutputError      error
+	}{
+		"found": {
+			InputStorageDeployment: deviceDeployment,
+			OutputDeployment:       deviceDeployment,
+		},
+		"found in given deployments": {
+			InputDeploymentIDs:     []string{validUUIDv4},
+			InputStorageDeployment: deviceDeployment,
+			OutputDeployment:       deviceDeployment,
+		},
+		"not found": {},
+		"storage error": {
+			InputStorageError: errors.New("db error"),
+			OutputError:       errors.New("Searching for latest deployment for the device: db error"),
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+
+			deviceDeploymentStorage := new(mocks.DeviceDeploymentStorage)
+			deviceDeploymentStorage.On("FindLatestDeploymentForDeviceID",
+				h.ContextMatcher(), "dev1", testCase.InputDeploymentIDs).
+				Return(testCase.InputStorageDeployment, testCase.InputStorageError)
+
+			model := NewDeploymentModel(DeploymentsModelConfig{
+				DeviceDeploymentsStorage: deviceDeploymentStorage,
+			})
+
+			out, err := model.GetLatestDeviceDeployment(context.Background(),
+				"dev1", testCase.InputDeploymentIDs)
+			if testCase.OutputError != nil {
+				assert.EqualError(t, err, testCase.OutputError.Error())
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, testCase.OutputDeployment, out)
+		})
+	}
+}
+
+func TestDeploymentModelGetActiveDeviceDeployments(t *testing.T) {
+
+	t.Parallel()
+
+	first := deployments.NewDeviceDeployment("dev1", validUUIDv4)
+	second := deployments.NewDeviceDeployment("dev1", "f826484e-1157-4109-af21-304e6d711560")
+	other := deployments.NewDeviceDeployment("dev2", validUUIDv4)
+
+	testCases := map[string]struct {
+		InputStorageDeployments []deployments.DeviceDeployment
+		InputStorageError       error
+
+		OutputActive map[string][]deployments.DeviceDeployment
+		OutputError  error
+	}{
+		"found": {
+			InputStorageDeployments: []deployments.DeviceDeployment{*first, *other, *second},
+			OutputActive: map[string][]deployments.DeviceDeployment{
+				"dev1": {*first, *second},
+				"dev2": {*other},
+				"dev3": {},
+			},
+		},
+		"none active": {
+			OutputActive: map[string][]deployments.DeviceDeployment{
+				"dev1": {},
+				"dev2": {},
+				"dev3": {},
+			},
+		},
+		"storage error": {
+			InputStorageError: errors.New("db error"),
+			OutputError: errors.New("Searching for active deployments of the devices: " +
+				"db error"),
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+
+			deviceIDs := []string{"dev1", "dev2", "dev3"}
+
+			deviceDeploymentStorage := new(mocks.DeviceDeploymentStorage)
+			deviceDeploymentStorage.On("FindDeploymentsForDeviceIDsWithStatuses",
+				h.ContextMatcher(), deviceIDs, deployments.ActiveDeploymentStatuses()).
+				Return(testCase.InputStorageDeployments, testCase.InputStorageError)
+
+			model := NewDeploymentModel(DeploymentsModelConfig{
+				DeviceDeploymentsStorage: deviceDeploymentStorage,
+			})
+
+			out, err := model.GetActiveDeviceDeployments(context.Background(), deviceIDs)
+			if testCase.OutputError != nil {
+				assert.EqualError(t, err, testCase.OutputError.Error())
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, testCase.OutputActive, out)
+		})
+	}
+}
+
+func TestDeploymentModelGetDeviceStatusesForDeployment(t *testing.T) {
+	//t.Parallel()
+
+	testCases := map[string]struct {
+		inDeploymentId string
+
+		devsStorageStatuses []deployments.DeviceDeployment
+		devsStorageErr      error
+
+		depsStorageDeployment *deployments.Deployment
+		depsStorageErr        error
+
+		modelErr error
+	}{
+		"existing deployment with statuses": {
+			inDeploymentId: "30b3e62c-9ec2-4312-a7fa-cff24cc7397b",
+
+			devsStorageStatuses: []deployments.DeviceDeployment{
+				*deployments.NewDeviceDeployment("dev0001", "30b3e62c-9ec2-4312-a7fa-cff24cc7397b"),
+				*deployments.NewDeviceDeployment("dev0002", "30b3e62c-9ec2-4312-a7fa-cff24cc7397b"),
+				*deployments.NewDeviceDeployment("dev0003", "30b3e62c-9ec2-4312-a7fa-cff24cc7397b"),
+			},
+			devsStorageErr: nil,
+
+			depsStorageDeployment: &deployments.Deployment{},
+			depsStorageErr:        nil,
+
+			modelErr: nil,
+		},
+		"deployment doesn't exist": {
+			devsStorageStatuses: []deployments.DeviceDeployment{
+				*deployments.NewDeviceDeployment("dev0001", "30b3e62c-9ec2-4312-a7fa-cff24cc7397b"),
+				*deployments.NewDeviceDeployment("dev0002", "30b3e62c-9ec2-4312-a7fa-cff24cc7397b"),
+				*deployments.NewDeviceDeployment("dev0003", "30b3e62c-9ec2-4312-a7fa-cff24cc7397b"),
+			},
+			devsStorageErr: nil,
+
+			depsStorageDeployment: nil,
+			depsStorageErr:        nil,
+
+			modelErr: controller.ErrModelDeploymentNotFound,
+		},
+		"DeviceDeployments storage layer error": {
+			inDeploymentId: "30b3e62c-9ec2-4312-a7fa-cff24cc7397b",
+
+			devsStorageStatuses: nil,
+			devsStorageErr:      errors.New("some verbose, low-level db error"),
+
+			depsStorageDeployment: &deployments.Deployment{},
+			depsStorageErr:        nil,
+
+			modelErr: errors.New("Internal error"),
+		},
+		"Deployments storage layer error": {
+			inDeploymentId: "30b3e62c-9ec2-4312-a7fa-cff24cc7397b",
+
+			devsStorageStatuses: nil,
+			devsStorageErr:      nil,
+
+			depsStorageDeployment: nil,
+			depsStorageErr:        errors.New("some verbose, low-level db error"),
+
+			modelErr: errors.New("Internal error"),
+		},
+	}
+
+	for testCaseName, tc := range testCases {
+		t.Run(fmt.Sprintf("test case %s", testCaseName), func(t *testing.T) {
+
+			devsDb := new(mocks.DeviceDeploymentStorage)
+
+			devsDb.On("GetDeviceStatusesForDeployment",
+				h.ContextMatcher(), tc.inDeploymentId).
+				Return(tc.devsStorageStatuses, tc.devsStorageErr)
+
+			depsDb := new(mocks.DeploymentsStorage)
+
+			depsDb.On("FindByID", h.ContextMatcher(), tc.inDeploymentId).
+				Return(tc.depsStorageDeployment, tc.depsStorageErr)
+
+			model := NewDeploymentModel(DeploymentsModelConfig{
+				DeploymentsStorage:       depsDb,
+				DeviceDeploymentsStorage: devsDb,
+			})
+			statuses, err := model.GetDeviceStatusesForDeployment(context.Background(),
+				tc.inDeploymentId)
+
+			if tc.modelErr != nil {
+				assert.EqualError(t, err, tc.modelErr.Error())
+			} else {
+				assert.NoError(t, err)
+
+				for i, expected := range tc.devsStorageStatuses {
+					assert.Equal(t, expected, statuses[i])
+				}
+			}
+		})
+	}
+}
+
+func TestDeploymentModelGetDeviceDeploymentsCount(t *testing.T) {
+
+	testCases := map[string]struct {
+		inDeploymentId string
+		inStatus       string
+
+		devsStorageCount int
+		devsStorageErr   error
+
+		depsStorageDeployment *deployments.Deployment
+		depsStorageErr        error
+
+		modelCount int
+		modelErr   error
+	}{
+		"existing deployment": {
+			inDeploymentId: "30b3e62c-9ec2-4312-a7fa-cff24cc7397b",
+
+			devsStorageCount:      3,
+			depsStorageDeployment: &deployments.Deployment{},
+
+			modelCount: 3,
+		},
+		"existing deployment, status": {
+			inDeploymentId: "30b3e62c-9ec2-4312-a7fa-cff24cc7397b",
+			inStatus:       deployments.DeviceDeploymentStatusFailure,
+
+			devsStorageCount:      1,
+			depsStorageDeployment: &deployments.Deployment{},
+
+			modelCount: 1,
+		},
+		"deployment doesn't exist": {
+			inDeploymentId: "30b3e62c-9ec2-4312-a7fa-cff24cc7397b",
+
+			modelErr: controller.ErrModelDeploymentNotFound,
+		},
+		"DeviceDeployments storage layer error": {
+			inDeploymentId: "30b3e62c-9ec2-4312-a7fa-cff24cc7397b",
+
+			devsStorageErr:        errors.New("db error"),
+			depsStorageDeployment: &deployments.Deployment{},
+
+			modelErr: errors.New("Counting device deployments: db error"),
+		},
+		"Deployments storage layer error": {
+			inDeploymentId: "30b3e62c-9ec2-4312-a7fa-cff24cc7397b",
+
+			depsStorageErr: errors.New("db error"),
+
+			modelErr: errors.New("Searching for deployment by ID: db error"),
+		},
+	}
+
+	for testCaseName, tc := range testCases {
+		t.Run(fmt.Sprintf("test case %s", testCaseName), func(t *testing.T) {
+
+			devsDb := new(mocks.DeviceDeploymentStorage)
+
+			devsDb.On("CountDeviceDeployments",
+				h.ContextMatcher(), tc.inDeploymentId, tc.inStatus).
+				Return(tc.devsStorageCount, tc.devsStorageErr)
+
+			depsDb := new(mocks.DeploymentsStorage)
+
+			depsDb.On("FindByID", h.ContextMatcher(), tc.inDeploymentId).
+				Return(tc.depsStorageDeployment, tc.depsStorageErr)
+
+			model := NewDeploymentModel(DeploymentsModelConfig{
+				DeploymentsStorage:       depsDb,
+				DeviceDeploymentsStorage: devsDb,
+			})
+			count, err := model.GetDeviceDeploymentsCount(context.Background(),
+				tc.inDeploymentId, tc.inStatus)
+
+			if tc.modelErr != nil {
+				assert.EqualError(t, err, tc.modelErr.Error())
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tc.modelCount, count)
+			}
+		})
+	}
+}
+
+func TestDeploymentModelSaveDeviceDeploymentLog(t *testing.T) {
+
+	//t.Parallel()
+
+	tref := time.Now()
+	messages := []deployments.LogMessage{
+		{
+			Timestamp: &tref,
+			Message:   "foo",
+			Level:     "notice",
+		},
+	}
+	testCases := []struct {
+		InputDeploymentID string
+		InputDeviceID     string
+		InputLog          []deployments.LogMessage
+
+		InputModelError     error
+		InputHasDeployment  bool
+		InputHasModelError  error
+		InputUpdateLogError error
+		InputStatus         string
+		InputStatusError    error
+		InputPublishError   error
+		InputLogsSearch     bool
+		InputIndexError     error
+
+		OutputError     error
+		OutputPublished bool
+	}{
+		{
+			InputDeploymentID:  "f826484e-1157-4109-af21-304e6d711560",
+			InputDeviceID:      "123",
+			InputLog:           messages,
+			InputModelError:    errors.New("Storage issue"),
+			InputHasDeployment: true,
+
+			OutputError: errors.New("Storage issue"),
+		},
+		{
+			InputDeploymentID:  "ID:234",
+			InputDeviceID:      "234",
+			InputLog:           []deployments.LogMessage{},
+			InputModelError:    nil,
+			InputHasDeployment: true,
+
+			OutputError: errors.New("Invalid deployment log: DeploymentID: ID:234 does not validate as uuidv4;Messages: non zero value required;"),
+		},
+		{
+			InputDeploymentID:  "f826484e-1157-4109-af21-304e6d711561",
+			InputDeviceID:      "345",
+			InputLog:           messages,
+			InputModelError:    nil,
+			InputHasDeployment: false,
+
+			OutputError: errors.New("Deployment not found"),
+		},
+		{
+			InputDeploymentID:   "f826484e-1157-4109-af21-304e6d711562",
+			InputDeviceID:       "456",
+			InputLog:            messages,
+			InputModelError:     nil,
+			InputHasDeployment:  true,
+			InputUpdateLogError: errors.New("Could not set log availability"),
+
+			OutputError: errors.New("Could not set log availability"),
+		},
+		{
+			InputDeploymentID:  "f826484e-1157-4109-af21-304e6d711563",
+			InputDeviceID:      "567",
+			InputLog:           messages,
+			InputHasDeployment: true,
+			InputStatus:        deployments.DeviceDeploymentStatusSuccess,
+		},
+		{
+			InputDeploymentID:  "f826484e-1157-4109-af21-304e6d711563",
+			InputDeviceID:      "567",
+			InputLog:           messages,
+			InputHasDeployment: true,
+			InputStatus:        deployments.DeviceDeploymentStatusFailure,
+
+			OutputPublished: true,
+		},
+		{
+			// publishing is best effort
+			InputDeploymentID:  "f826484e-1157-4109-af21-304e6d711563",
+			InputDeviceID:      "567",
+			InputLog:           messages,
+			InputHasDeployment: true,
+			InputStatus:        deployments.DeviceDeploymentStatusFailure,
+			InputPublishError:  errors.New("webhook down"),
+
+			OutputPublished: true,
+		},
+		{
+			InputDeploymentID:  "f826484e-1157-4109-af21-304e6d711563",
+			InputDeviceID:      "567",
+			InputLog:           messages,
+			InputHasDeployment: true,
+			InputStatusError:   errors.New("storage issue"),
+		},
+		{
+			InputDeploymentID:  "f826484e-1157-4109-af21-304e6d711563",
+			InputDeviceID:      "567",
+			InputLog:           messages,
+			InputHasDeployment: true,
+			InputLogsSearch:    true,
+		},
+		{
+			// search index is best effort
+			InputDeploymentID:  "f826484e-1157-4109-af21-304e6d711563",
+			InputDeviceID:      "567",
+			InputLog:           messages,
+			InputHasDeployment: true,
+			InputLogsSearch:    true,
+			InputIndexError:    errors.New("storage issue"),
+		},
+	}
+
+	for testCaseNumber, testCase := range testCases {
+		t.Run(fmt.Sprintf("test case %d", testCaseNumber+1), func(t *testing.T) {
+
+			t.Logf("testing %s %s %s %v", testCase.InputDeploymentID, testCase.InputDeviceID,
+				testCase.InputLog, testCase.InputModelError)
+
+			deviceDeploymentLogStorage := new(mocks.DeviceDeploymentLogsStorage)
+			deviceDeploymentLogStorage.On("SaveDeviceDeploymentLog",
+				h.ContextMatcher(),
+				deployments.DeploymentLog{
+					DeviceID:     testCase.InputDeviceID,
+					DeploymentID: testCase.InputDeploymentID,
+					Messages:     testCase.InputLog,
+				}).
+				Return(testCase.InputModelError)
+			deviceDeploymentLogStorage.On("EnsureSearchIndex",
+				h.ContextMatcher()).
+				Return(testCase.InputIndexError)
+
+			deviceDeploymentStorage := new(mocks.DeviceDeploymentStorage)
+			deviceDeploymentStorage.On("HasDeploymentForDevice",
+				h.ContextMatcher(),
+				testCase.InputDeploymentID, testCase.InputDeviceID).
+				Return(testCase.InputHasDeployment, testCase.InputHasModelError)
+			deviceDeploymentStorage.On("UpdateDeviceDeploymentLogAvailability",
+				h.ContextMatcher(),
+				testCase.InputDeviceID, testCase.InputDeploymentID, true).
+				Return(testCase.InputUpdateLogError)
+			deviceDeploymentStorage.On("GetDeviceDeploymentStatus",
+				h.ContextMatcher(),
+				testCase.InputDeploymentID, testCase.InputDeviceID).
+				Return(testCase.InputStatus, testCase.InputStatusError)
+
+			publisher := new(mocks.EventPublisher)
+			publisher.On("Publish",
+				h.ContextMatcher(),
+				mock.MatchedBy(func(event *deployments.Event) bool {
+					return event.Type == deployments.EventDeviceDeploymentLogAvailable &&
+						event.DeploymentID == testCase.InputDeploymentID &&
+						event.DeviceID == testCase.InputDeviceID &&
+						event.Status == deployments.DeviceDeploymentStatusFailure
+				})).
+				Return(testCase.InputPublishError)
+
+			model := NewDeploymentModel(DeploymentsModelConfig{
+				DeviceDeploymentsStorage:    deviceDeploymentStorage,
+				DeviceDeploymentLogsStorage: deviceDeploymentLogStorage,
+				EventPublisher:              publisher,
+				DeviceLogsSearch:            testCase.InputLogsSearch,
+			})
+
+			err := model.SaveDeviceDeploymentLog(context.Background(),
 				testCase.InputDeviceID,
-				deployments.DeviceDeploymentStatus{
-					Status: testCase.InputStatus,
-				})
+				testCase.InputDeploymentID, testCase.InputLog)
 			if testCase.OutputError != nil {
 				assert.EqualError(t, err, testCase.OutputError.Error())
 			} else {
 				assert.NoError(t, err)
+			}
 
-				// make sure that storage calls were done as
-				// expected
-				mock.AssertExpectationsForObjects(t, deviceDeploymentStorage,
-					deploymentStorage)
+			if testCase.OutputPublished {
+				publisher.AssertExpectations(t)
+			} else {
+				publisher.AssertNotCalled(t, "Publish", mock.Anything, mock.Anything)
+			}
+
+			if testCase.OutputError == nil && testCase.InputLogsSearch {
+				deviceDeploymentLogStorage.AssertCalled(t, "EnsureSearchIndex", mock.Anything)
+			} else {
+				deviceDeploymentLogStorage.AssertNotCalled(t, "EnsureSearchIndex", mock.Anything)
 			}
 		})
 	}
-
 }
 
-func TestGetDeploymentStats(t *testing.T) {
-
-	//t.Parallel()
-
-	testCases := []struct {
-		InputDeploymentID         string
-		InputModelDeploymentStats deployments.Stats
-		InputModelError           error
-
-		InoutFindByIDDeployment *deployments.Deployment
-		InoutFindByIDError      error
+func TestDeploymentModelSearchDeviceDeploymentLogs(t *testing.T) {
 
-		OutputStats deployments.Stats
-		OutputError error
+	testCases := map[string]struct {
+		InputLogsSearch    bool
+		InputDeployment    *deployments.Deployment
+		InputDeploymentErr error
+		InputSearchDevices []string
+		InputSearchError   error
+		OutputDevices      []string
+		OutputError        error
 	}{
-		{
-			InputDeploymentID:         "ID:123",
-			InputModelDeploymentStats: nil,
-
-			InoutFindByIDDeployment: new(deployments.Deployment),
+		"ok": {
+			InputLogsSearch:    true,
+			InputDeployment:    &deployments.Deployment{Id: StringToPointer(validUUIDv4)},
+			InputSearchDevices: []string{"device-1", "device-2"},
 
-			OutputStats: nil,
+			OutputDevices: []string{"device-1", "device-2"},
 		},
-		{
-			InputDeploymentID: "ID:234",
-			InputModelError:   errors.New("storage issue"),
-
-			InoutFindByIDDeployment: new(deployments.Deployment),
-
-			OutputError: errors.New("storage issue"),
+		"search disabled": {
+			OutputError: controller.ErrLogSearchDisabled,
 		},
-		{
-			InputDeploymentID: "ID:234",
-			InputModelError:   errors.New("storage issue"),
+		"deployment not found": {
+			InputLogsSearch: true,
 
-			InoutFindByIDDeployment: nil,
+			OutputError: controller.ErrModelDeploymentNotFound,
 		},
-		{
-			InputDeploymentID: "ID:234",
-			InputModelError:   errors.New("storage issue"),
+		"deployment storage error": {
+			InputLogsSearch:    true,
+			InputDeploymentErr: errors.New("storage issue"),
 
-			InoutFindByIDDeployment: new(deployments.Deployment),
-			InoutFindByIDError:      errors.New("an error"),
-
-			OutputError: errors.New("checking deployment id: an error"),
+			OutputError: errors.New("Searching for deployment by ID: storage issue"),
 		},
-		{
-			InputDeploymentID:       "ID:345",
-			InoutFindByIDDeployment: new(deployments.Deployment),
-			InputModelDeploymentStats: deployments.Stats{
-				deployments.DeviceDeploymentStatusPending:     2,
-				deployments.DeviceDeploymentStatusSuccess:     4,
-				deployments.DeviceDeploymentStatusFailure:     1,
-				deployments.DeviceDeploymentStatusInstalling:  3,
-				deployments.DeviceDeploymentStatusRebooting:   3,
-				deployments.DeviceDeploymentStatusDownloading: 3,
-				deployments.DeviceDeploymentStatusAlreadyInst: 0,
-			},
+		"search error": {
+			InputLogsSearch:  true,
+			InputDeployment:  &deployments.Deployment{Id: StringToPointer(validUUIDv4)},
+			InputSearchError: errors.New("text index required"),
 
-			OutputStats: deployments.Stats{
-				deployments.DeviceDeploymentStatusDownloading: 3,
-				deployments.DeviceDeploymentStatusRebooting:   3,
-				deployments.DeviceDeploymentStatusInstalling:  3,
-				deployments.DeviceDeploymentStatusSuccess:     4,
-				deployments.DeviceDeploymentStatusFailure:     1,
-				deployments.DeviceDeploymentStatusPending:     2,
-				deployments.DeviceDeploymentStatusAlreadyInst: 0,
-			},
+			OutputError: errors.New("Searching device deployment logs: text index required"),
 		},
 	}
 
-	for testCaseNumber, testCase := range testCases {
-		t.Run(fmt.Sprintf("test case %d", testCaseNumber+1), func(t *testing.T) {
-			t.Logf("testing %s %v %v", testCase.InputDeploymentID,
-				testCase.InputModelDeploymentStats, testCase.InputModelError)
-
-			deviceDeploymentStorage := new(mocks.DeviceDeploymentStorage)
-			deviceDeploymentStorage.On("AggregateDeviceDeploymentByStatus",
-				h.ContextMatcher(),
-				testCase.InputDeploymentID).
-				Return(testCase.InputModelDeploymentStats, testCase.InputModelError)
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
 
 			deploymentStorage := new(mocks.DeploymentsStorage)
 			deploymentStorage.On("FindByID",
-				h.ContextMatcher(),
-				testCase.InputDeploymentID).
-				Return(testCase.InoutFindByIDDeployment, testCase.InoutFindByIDError)
+				h.ContextMatcher(), validUUIDv4).
+				Return(testCase.InputDeployment, testCase.InputDeploymentErr)
+
+			deviceDeploymentLogStorage := new(mocks.DeviceDeploymentLogsStorage)
+			deviceDeploymentLogStorage.On("SearchDeviceDeploymentLogs",
+				h.ContextMatcher(), validUUIDv4, "connection refused").
+				Return(testCase.InputSearchDevices, testCase.InputSearchError)
 
 			model := NewDeploymentModel(DeploymentsModelConfig{
-				DeploymentsStorage:       deploymentStorage,
-				DeviceDeploymentsStorage: deviceDeploymentStorage,
+				DeploymentsStorage:          deploymentStorage,
+				DeviceDeploymentLogsStorage: deviceDeploymentLogStorage,
+				DeviceLogsSearch:            testCase.InputLogsSearch,
 			})
 
-			stats, err := model.GetDeploymentStats(context.Background(),
-				testCase.InputDeploymentID)
-
+			devices, err := model.SearchDeviceDeploymentLogs(context.Background(),
+				validUUIDv4, "connection refused")
 			if testCase.OutputError != nil {
 				assert.EqualError(t, err, testCase.OutputError.Error())
+				assert.Nil(t, devices)
 			} else {
 				assert.NoError(t, err)
-
-				assert.Equal(t, testCase.OutputStats, stats)
+				assert.Equal(t, testCase.OutputDevices, devices)
 			}
 		})
 	}
 }
 
-func TestDeploymentModelGetDeviceStatusesForDeployment(t *testing.T) {
+func TestDeploymentModelLookupDeployment(t *testing.T) {
+
 	//t.Parallel()
 
 	testCases := map[string]struct {
-		inDeploymentId string
-
-		devsStorageStatuses []deployments.DeviceDeployment
-		devsStorageErr      error
-
-		depsStorageDeployment *deployments.Deployment
-		depsStorageErr        error
+		MockDeployments []*deployments.Deployment
+		MockError       error
 
-		modelErr error
+		OutputError       error
+		OutputDeployments []*deployments.Deployment
 	}{
-		"existing deployment with statuses": {
-			inDeploymentId: "30b3e62c-9ec2-4312-a7fa-cff24cc7397b",
-
-			devsStorageStatuses: []deployments.DeviceDeployment{
-				*deployments.NewDeviceDeployment("dev0001", "30b3e62c-9ec2-4312-a7fa-cff24cc7397b"),
-				*deployments.NewDeviceDeployment("dev0002", "30b3e62c-9ec2-4312-a7fa-cff24cc7397b"),
-				*deployments.NewDeviceDeployment("dev0003", "30b3e62c-9ec2-4312-a7fa-cff24cc7397b"),
-			},
-			devsStorageErr: nil,
-
-			depsStorageDeployment: &deployments.Deployment{},
-			depsStorageErr:        nil,
-
-			modelErr: nil,
+		"nothing found": {
+			MockDeployments:   nil,
+			OutputDeployments: []*deployments.Deployment{},
 		},
-		"deployment doesn't exist": {
-			devsStorageStatuses: []deployments.DeviceDeployment{
-				*deployments.NewDeviceDeployment("dev0001", "30b3e62c-9ec2-4312-a7fa-cff24cc7397b"),
-				*deployments.NewDeviceDeployment("dev0002", "30b3e62c-9ec2-4312-a7fa-cff24cc7397b"),
-				*deployments.NewDeviceDeployment("dev0003", "30b3e62c-9ec2-4312-a7fa-cff24cc7397b"),
-			},
-			devsStorageErr: nil,
+		"error": {
+			MockError:   errors.New("bad bad bad"),
+			OutputError: errors.New("searching for deployments: bad bad bad"),
+		},
+		"found deployments": {
+			MockDeployments:   []*deployments.Deployment{{Id: StringToPointer("lala")}},
+			OutputDeployments: []*deployments.Deployment{{Id: StringToPointer("lala")}},
+		},
+	}
 
-			depsStorageDeployment: nil,
-			depsStorageErr:        nil,
+	for testCaseName, testCase := range testCases {
+		t.Run(fmt.Sprintf("test case %s", testCaseName), func(t *testing.T) {
 
-			modelErr: controller.ErrModelDeploymentNotFound,
-		},
-		"DeviceDeployments storage layer error": {
-			inDeploymentId: "30b3e62c-9ec2-4312-a7fa-cff24cc7397b",
+			deploymentStorage := new(mocks.DeploymentsStorage)
+			deploymentStorage.On("Find",
+				h.ContextMatcher(), mock.AnythingOfType("deployments.Query")).
+				Return(testCase.MockDeployments, testCase.MockError)
 
-			devsStorageStatuses: nil,
-			devsStorageErr:      errors.New("some verbose, low-level db error"),
+			deploymentStorage.On("DeviceCountByDeployment",
+				h.ContextMatcher(), mock.AnythingOfType("string")).
+				Return(0, nil)
 
-			depsStorageDeployment: &deployments.Deployment{},
-			depsStorageErr:        nil,
+			model := NewDeploymentModel(DeploymentsModelConfig{DeploymentsStorage: deploymentStorage})
 
-			modelErr: errors.New("Internal error"),
-		},
-		"Deployments storage layer error": {
-			inDeploymentId: "30b3e62c-9ec2-4312-a7fa-cff24cc7397b",
+			deployments, err := model.LookupDeployment(context.Background(),
+				deployments.Query{})
+			if testCase.OutputError != nil {
+				assert.EqualError(t, err, testCase.OutputError.Error())
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, testCase.OutputDeployments, deployments)
+		})
+	}
+}
 
-			devsStorageStatuses: nil,
-			devsStorageErr:      nil,
+func TestDeploymentModelLookupDeviceDeployments(t *testing.T) {
 
-			depsStorageDeployment: nil,
-			depsStorageErr:        errors.New("some verbose, low-level db error"),
+	query := deployments.DeviceDeploymentsQuery{
+		Status: deployments.DeviceDeploymentStatusFailure,
+		Limit:  21,
+	}
 
-			modelErr: errors.New("Internal error"),
+	testCases := map[string]struct {
+		MockDeviceDeployments []deployments.DeviceDeployment
+		MockError             error
+
+		OutputError             error
+		OutputDeviceDeployments []deployments.DeviceDeployment
+	}{
+		"nothing found": {
+			MockDeviceDeployments:   nil,
+			OutputDeviceDeployments: []deployments.DeviceDeployment{},
+		},
+		"error": {
+			MockError:   errors.New("bad bad bad"),
+			OutputError: errors.New("searching for device deployments: bad bad bad"),
+		},
+		"found device deployments": {
+			MockDeviceDeployments:   []deployments.DeviceDeployment{{DeviceId: StringToPointer("lala")}},
+			OutputDeviceDeployments: []deployments.DeviceDeployment{{DeviceId: StringToPointer("lala")}},
 		},
 	}
 
-	for testCaseName, tc := range testCases {
+	for testCaseName, testCase := range testCases {
 		t.Run(fmt.Sprintf("test case %s", testCaseName), func(t *testing.T) {
 
-			devsDb := new(mocks.DeviceDeploymentStorage)
-
-			devsDb.On("GetDeviceStatusesForDeployment",
-				h.ContextMatcher(), tc.inDeploymentId).
-				Return(tc.devsStorageStatuses, tc.devsStorageErr)
-
-			depsDb := new(mocks.DeploymentsStorage)
-
-			depsDb.On("FindByID", h.ContextMatcher(), tc.inDeploymentId).
-				Return(tc.depsStorageDeployment, tc.depsStorageErr)
+			deviceDeploymentStorage := new(mocks.DeviceDeploymentStorage)
+			deviceDeploymentStorage.On("FindDeviceDeployments",
+				h.ContextMatcher(), query).
+				Return(testCase.MockDeviceDeployments, testCase.MockError)
 
 			model := NewDeploymentModel(DeploymentsModelConfig{
-				DeploymentsStorage:       depsDb,
-				DeviceDeploymentsStorage: devsDb,
+				DeviceDeploymentsStorage: deviceDeploymentStorage,
 			})
-			statuses, err := model.GetDeviceStatusesForDeployment(context.Background(),
-				tc.inDeploymentId)
 
-			if tc.modelErr != nil {
-				assert.EqualError(t, err, tc.modelErr.Error())
+			deviceDeployments, err := model.LookupDeviceDeployments(context.Background(), query)
+			if testCase.OutputError != nil {
+				assert.EqualError(t, err, testCase.OutputError.Error())
 			} else {
 				assert.NoError(t, err)
-
-				for i, expected := range tc.devsStorageStatuses {
-					assert.Equal(t, expected, statuses[i])
-				}
 			}
+			assert.Equal(t, testCase.OutputDeviceDeployments, deviceDeployments)
 		})
 	}
 }
 
-func TestDeploymentModelSaveDeviceDeploymentLog(t *testing.T) {
+func TestDeploymentModelRecordArtifactDownload(t *testing.T) {
 
-	//t.Parallel()
+	testCases := map[string]struct {
+		InputIdentity *identity.Identity
+		InputError    error
 
-	tref := time.Now()
-	messages := []deployments.LogMessage{
-		{
-			Timestamp: &tref,
-			Message:   "foo",
-			Level:     "notice",
+		OutputUserID string
+		OutputError  error
+	}{
+		"user download": {
+			InputIdentity: &identity.Identity{Subject: "user-1", IsUser: true},
+
+			OutputUserID: "user-1",
+		},
+		"no identity": {},
+		"error": {
+			InputIdentity: &identity.Identity{Subject: "user-1", IsUser: true},
+			InputError:    errors.New("db error"),
+
+			OutputUserID: "user-1",
+			OutputError:  errors.New("Recording artifact download: db error"),
 		},
 	}
-	testCases := []struct {
-		InputDeploymentID string
-		InputDeviceID     string
-		InputLog          []deployments.LogMessage
 
-		InputModelError     error
-		InputHasDeployment  bool
-		InputHasModelError  error
-		InputUpdateLogError error
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+
+			var recorded *deployments.Download
+			downloadsStorage := new(mocks.DownloadsStorage)
+			downloadsStorage.On("InsertDownload",
+				h.ContextMatcher(), mock.AnythingOfType("*deployments.Download")).
+				Run(func(args mock.Arguments) {
+					recorded = args.Get(1).(*deployments.Download)
+				}).
+				Return(testCase.InputError)
+
+			model := NewDeploymentModel(DeploymentsModelConfig{
+				DownloadsStorage: downloadsStorage,
+			})
+
+			ctx := context.Background()
+			if testCase.InputIdentity != nil {
+				ctx = identity.WithContext(ctx, testCase.InputIdentity)
+			}
+
+			err := model.RecordArtifactDownload(ctx, validUUIDv4)
+			if testCase.OutputError != nil {
+				assert.EqualError(t, err, testCase.OutputError.Error())
+			} else {
+				assert.NoError(t, err)
+			}
+			if assert.NotNil(t, recorded) {
+				assert.Equal(t, validUUIDv4, recorded.ArtifactID)
+				assert.Equal(t, testCase.OutputUserID, recorded.UserID)
+				assert.Empty(t, recorded.DeviceID)
+			}
+		})
+	}
+}
 
-		OutputError error
+func TestDeploymentModelRevokeDeviceDeploymentLink(t *testing.T) {
+
+	testCases := map[string]struct {
+		InputProxyDisabled      bool
+		InputDeviceDeployment   *deployments.DeviceDeployment
+		InputFindError          error
+		InputRevokeError        error
+		InputUpdateStatusError  error
+		InputDeploymentFinished bool
+
+		OutputAbort  bool
+		OutputFinish bool
+		OutputError  error
 	}{
-		{
-			InputDeploymentID:  "f826484e-1157-4109-af21-304e6d711560",
-			InputDeviceID:      "123",
-			InputLog:           messages,
-			InputModelError:    errors.New("Storage issue"),
-			InputHasDeployment: true,
+		"ok": {
+			InputDeviceDeployment: &deployments.DeviceDeployment{
+				Status: StringToPointer(deployments.DeviceDeploymentStatusDownloading),
+			},
 
-			OutputError: errors.New("Storage issue"),
+			OutputAbort: true,
 		},
-		{
-			InputDeploymentID:  "ID:234",
-			InputDeviceID:      "234",
-			InputLog:           []deployments.LogMessage{},
-			InputModelError:    nil,
-			InputHasDeployment: true,
+		"ok, deployment finished": {
+			InputDeviceDeployment: &deployments.DeviceDeployment{
+				Status: StringToPointer(deployments.DeviceDeploymentStatusPending),
+			},
+			InputDeploymentFinished: true,
 
-			OutputError: errors.New("Invalid deployment log: DeploymentID: ID:234 does not validate as uuidv4;Messages: non zero value required;"),
+			OutputAbort:  true,
+			OutputFinish: true,
 		},
-		{
-			InputDeploymentID:  "f826484e-1157-4109-af21-304e6d711561",
-			InputDeviceID:      "345",
-			InputLog:           messages,
-			InputModelError:    nil,
-			InputHasDeployment: false,
-
-			OutputError: errors.New("Deployment not found"),
+		"ok, device deployment finished": {
+			InputDeviceDeployment: &deployments.DeviceDeployment{
+				Status: StringToPointer(deployments.DeviceDeploymentStatusSuccess),
+			},
 		},
-		{
-			InputDeploymentID:   "f826484e-1157-4109-af21-304e6d711562",
-			InputDeviceID:       "456",
-			InputLog:            messages,
-			InputModelError:     nil,
-			InputHasDeployment:  true,
-			InputUpdateLogError: errors.New("Could not set log availability"),
+		"proxy disabled": {
+			InputProxyDisabled: true,
+			InputDeviceDeployment: &deployments.DeviceDeployment{
+				Status: StringToPointer(deployments.DeviceDeploymentStatusDownloading),
+			},
 
-			OutputError: errors.New("Could not set log availability"),
+			OutputError: controller.ErrDownloadProxyDisabled,
 		},
-		{
-			InputDeploymentID:  "f826484e-1157-4109-af21-304e6d711563",
-			InputDeviceID:      "567",
-			InputLog:           messages,
-			InputHasDeployment: true,
+		"not found": {
+			OutputError: controller.ErrStorageNotFound,
 		},
-	}
+		"search error": {
+			InputFindError: errors.New("db error"),
 
-	for testCaseNumber, testCase := range testCases {
-		t.Run(fmt.Sprintf("test case %d", testCaseNumber+1), func(t *testing.T) {
+			OutputError: errors.New("Searching for device deployment: db error"),
+		},
+		"revoke error": {
+			InputDeviceDeployment: &deployments.DeviceDeployment{
+				Status: StringToPointer(deployments.DeviceDeploymentStatusDownloading),
+			},
+			InputRevokeError: errors.New("db error"),
 
-			t.Logf("testing %s %s %s %v", testCase.InputDeploymentID, testCase.InputDeviceID,
-				testCase.InputLog, testCase.InputModelError)
+			OutputError: errors.New("Revoking device download link: db error"),
+		},
+		"abort error": {
+			InputDeviceDeployment: &deployments.DeviceDeployment{
+				Status: StringToPointer(deployments.DeviceDeploymentStatusDownloading),
+			},
+			InputUpdateStatusError: errors.New("db error"),
 
-			deviceDeploymentLogStorage := new(mocks.DeviceDeploymentLogsStorage)
-			deviceDeploymentLogStorage.On("SaveDeviceDeploymentLog",
-				h.ContextMatcher(),
-				deployments.DeploymentLog{
-					DeviceID:     testCase.InputDeviceID,
-					DeploymentID: testCase.InputDeploymentID,
-					Messages:     testCase.InputLog,
-				}).
-				Return(testCase.InputModelError)
+			OutputError: errors.New("Aborting device deployment: db error"),
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
 
 			deviceDeploymentStorage := new(mocks.DeviceDeploymentStorage)
-			deviceDeploymentStorage.On("HasDeploymentForDevice",
-				h.ContextMatcher(),
-				testCase.InputDeploymentID, testCase.InputDeviceID).
-				Return(testCase.InputHasDeployment, testCase.InputHasModelError)
-			deviceDeploymentStorage.On("UpdateDeviceDeploymentLogAvailability",
-				h.ContextMatcher(),
-				testCase.InputDeviceID, testCase.InputDeploymentID, true).
-				Return(testCase.InputUpdateLogError)
+			deviceDeploymentStorage.On("FindLatestDeploymentForDeviceID",
+				h.ContextMatcher(), "device-1", []string{validUUIDv4}).
+				Return(testCase.InputDeviceDeployment, testCase.InputFindError)
+			deviceDeploymentStorage.On("RevokeDeviceDeploymentLink",
+				h.ContextMatcher(), "device-1", validUUIDv4).
+				Return(testCase.InputRevokeError)
+			if testCase.InputDeviceDeployment != nil {
+				deviceDeploymentStorage.On("GetDeviceDeploymentStatus",
+					h.ContextMatcher(), validUUIDv4, "device-1").
+					Return(*testCase.InputDeviceDeployment.Status, nil)
+			}
+			deviceDeploymentStorage.On("UpdateDeviceDeploymentStatus",
+				h.ContextMatcher(), "device-1", validUUIDv4,
+				mock.MatchedBy(func(status deployments.DeviceDeploymentStatus) bool {
+					return status.Status == deployments.DeviceDeploymentStatusAborted &&
+						status.FinishTime != nil &&
+						assert.ObjectsAreEqual(deployments.NewLinkRevokedReason(), status.Reason)
+				})).
+				Return(deployments.DeviceDeploymentStatusDownloading,
+					testCase.InputUpdateStatusError)
+
+			deployment := &deployments.Deployment{
+				Id: StringToPointer(validUUIDv4),
+				Stats: deployments.Stats{
+					deployments.DeviceDeploymentStatusDownloading: 1,
+				},
+			}
+			if testCase.InputDeploymentFinished {
+				deployment.Stats = deployments.Stats{
+					deployments.DeviceDeploymentStatusAborted: 1,
+				}
+			}
+			deploymentStorage := new(mocks.DeploymentsStorage)
+			deploymentStorage.On("UpdateStats", h.ContextMatcher(), validUUIDv4,
+				deployments.DeviceDeploymentStatusDownloading,
+				deployments.DeviceDeploymentStatusAborted).
+				Return(nil)
+			deploymentStorage.On("IncrementStatsRollup", h.ContextMatcher(),
+				mock.AnythingOfType("time.Time"), deployments.DeviceDeploymentStatusAborted).
+				Return(nil)
+			deploymentStorage.On("FindByID", h.ContextMatcher(), validUUIDv4).
+				Return(deployment, nil)
+			deploymentStorage.On("Finish", h.ContextMatcher(), validUUIDv4,
+				mock.AnythingOfType("time.Time")).
+				Return(nil)
 
-			model := NewDeploymentModel(DeploymentsModelConfig{
-				DeviceDeploymentsStorage:    deviceDeploymentStorage,
-				DeviceDeploymentLogsStorage: deviceDeploymentLogStorage,
-			})
+			config := DeploymentsModelConfig{
+				DeploymentsStorage:       deploymentStorage,
+				DeviceDeploymentsStorage: deviceDeploymentStorage,
+			}
+			if !testCase.InputProxyDisabled {
+				config.DownloadProxy = &DownloadProxy{
+					URL:    "https://example.com/api/devices/v1/deployments",
+					Secret: []byte("secret"),
+				}
+			}
+			model := NewDeploymentModel(config)
 
-			err := model.SaveDeviceDeploymentLog(context.Background(),
-				testCase.InputDeviceID,
-				testCase.InputDeploymentID, testCase.InputLog)
+			err := model.RevokeDeviceDeploymentLink(context.Background(),
+				validUUIDv4, "device-1")
 			if testCase.OutputError != nil {
 				assert.EqualError(t, err, testCase.OutputError.Error())
 			} else {
 				assert.NoError(t, err)
+				deviceDeploymentStorage.AssertCalled(t, "RevokeDeviceDeploymentLink",
+					h.ContextMatcher(), "device-1", validUUIDv4)
+			}
+			if testCase.OutputAbort {
+				deploymentStorage.AssertCalled(t, "UpdateStats", h.ContextMatcher(),
+					validUUIDv4, deployments.DeviceDeploymentStatusDownloading,
+					deployments.DeviceDeploymentStatusAborted)
+			} else if testCase.OutputError == nil {
+				deviceDeploymentStorage.AssertNotCalled(t, "UpdateDeviceDeploymentStatus",
+					mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+			}
+			if testCase.OutputFinish {
+				deploymentStorage.AssertCalled(t, "Finish", h.ContextMatcher(),
+					validUUIDv4, mock.AnythingOfType("time.Time"))
+			} else {
+				deploymentStorage.AssertNotCalled(t, "Finish",
+					mock.Anything, mock.Anything, mock.Anything)
+			}
+			if testCase.InputProxyDisabled {
+				deviceDeploymentStorage.AssertNotCalled(t, "RevokeDeviceDeploymentLink",
+					mock.Anything, mock.Anything, mock.Anything)
 			}
 		})
 	}
 }
 
-func TestDeploymentModelLookupDeployment(t *testing.T) {
+func TestDeploymentModelLookupDownloads(t *testing.T) {
 
-	//t.Parallel()
+	query := deployments.DownloadsQuery{
+		DeviceID: "device-1",
+		Limit:    21,
+	}
 
 	testCases := map[string]struct {
-		MockDeployments []*deployments.Deployment
-		MockError       error
+		MockDownloads []deployments.Download
+		MockError     error
 
-		OutputError       error
-		OutputDeployments []*deployments.Deployment
+		OutputError     error
+		OutputDownloads []deployments.Download
 	}{
 		"nothing found": {
-			MockDeployments:   nil,
-			OutputDeployments: []*deployments.Deployment{},
+			MockDownloads:   nil,
+			OutputDownloads: []deployments.Download{},
 		},
 		"error": {
 			MockError:   errors.New("bad bad bad"),
-			OutputError: errors.New("searching for deployments: bad bad bad"),
+			OutputError: errors.New("searching for downloads: bad bad bad"),
 		},
-		"found deployments": {
-			MockDeployments:   []*deployments.Deployment{{Id: StringToPointer("lala")}},
-			OutputDeployments: []*deployments.Deployment{{Id: StringToPointer("lala")}},
+		"found downloads": {
+			MockDownloads:   []deployments.Download{{Id: "lala", DeviceID: "device-1"}},
+			OutputDownloads: []deployments.Download{{Id: "lala", DeviceID: "device-1"}},
 		},
 	}
 
 	for testCaseName, testCase := range testCases {
 		t.Run(fmt.Sprintf("test case %s", testCaseName), func(t *testing.T) {
 
-			deploymentStorage := new(mocks.DeploymentsStorage)
-			deploymentStorage.On("Find",
-				h.ContextMatcher(), mock.AnythingOfType("deployments.Query")).
-				Return(testCase.MockDeployments, testCase.MockError)
-
-			deploymentStorage.On("DeviceCountByDeployment",
-				h.ContextMatcher(), mock.AnythingOfType("string")).
-				Return(0, nil)
+			downloadsStorage := new(mocks.DownloadsStorage)
+			downloadsStorage.On("FindDownloads",
+				h.ContextMatcher(), query).
+				Return(testCase.MockDownloads, testCase.MockError)
 
-			model := NewDeploymentModel(DeploymentsModelConfig{DeploymentsStorage: deploymentStorage})
+			model := NewDeploymentModel(DeploymentsModelConfig{
+				DownloadsStorage: downloadsStorage,
+			})
 
-			deployments, err := model.LookupDeployment(context.Background(),
-				deployments.Query{})
+			downloads, err := model.LookupDownloads(context.Background(), query)
 			if testCase.OutputError != nil {
 				assert.EqualError(t, err, testCase.OutputError.Error())
 			} else {
 				assert.NoError(t, err)
 			}
-			assert.Equal(t, testCase.OutputDeployments, deployments)
+			assert.Equal(t, testCase.OutputDownloads, downloads)
 		})
 	}
 }
@@ -1248,35 +3945,70 @@ func TestDeploymentModelIsDeploymentFinished(t *testing.T) {
 func TestDeploymentModelAbortDeployment(t *testing.T) {
 	//t.Parallel()
 
+	unfinished := &deployments.Deployment{
+		Id: StringToPointer("f826484e-1157-4109-af21-304e6d711561"),
+	}
+
 	testCases := map[string]struct {
 		InputDeploymentID string
 
+		FindByIDDeployment                     *deployments.Deployment
+		FindByIDError                          error
 		AbortDeviceDeploymentsError            error
+		SetAbortInfoError                      error
 		AggregateDeviceDeploymentByStatusStats deployments.Stats
 		AggregateDeviceDeploymentByStatusError error
 		UpdateStatsAndFinishDeploymentError    error
 
 		OutputError error
 	}{
+		"FindByID error": {
+			InputDeploymentID: "f826484e-1157-4109-af21-304e6d711561",
+			FindByIDError:     errors.New("FindByIDError"),
+			OutputError:       errors.New("Searching for deployment by ID: FindByIDError"),
+		},
+		"not found": {
+			InputDeploymentID:  "f826484e-1157-4109-af21-304e6d711561",
+			FindByIDDeployment: nil,
+			OutputError:        controller.ErrModelDeploymentNotFound,
+		},
+		"finished already": {
+			InputDeploymentID: "f826484e-1157-4109-af21-304e6d711561",
+			FindByIDDeployment: &deployments.Deployment{
+				Id:       StringToPointer("f826484e-1157-4109-af21-304e6d711561"),
+				Finished: TimeToPointer(time.Now()),
+			},
+			OutputError: controller.ErrDeploymentAlreadyFinished,
+		},
 		"AbortDeviceDeployments error": {
 			InputDeploymentID:           "f826484e-1157-4109-af21-304e6d711561",
+			FindByIDDeployment:          unfinished,
 			AbortDeviceDeploymentsError: errors.New("AbortDeviceDeploymentsError"),
 			OutputError:                 errors.New("AbortDeviceDeploymentsError"),
 		},
+		"SetAbortInfo error": {
+			InputDeploymentID:  "f826484e-1157-4109-af21-304e6d711561",
+			FindByIDDeployment: unfinished,
+			SetAbortInfoError:  errors.New("SetAbortInfoError"),
+			OutputError:        errors.New("SetAbortInfoError"),
+		},
 		"AggregateDeviceDeploymentByStatus error": {
 			InputDeploymentID:                      "f826484e-1157-4109-af21-304e6d711561",
+			FindByIDDeployment:                     unfinished,
 			AggregateDeviceDeploymentByStatusError: errors.New("AggregateDeviceDeploymentByStatusError"),
 			AggregateDeviceDeploymentByStatusStats: deployments.Stats{},
 			OutputError:                            errors.New("AggregateDeviceDeploymentByStatusError"),
 		},
 		"UpdateStatsAndFinishDeployment error": {
 			InputDeploymentID:                      "f826484e-1157-4109-af21-304e6d711561",
+			FindByIDDeployment:                     unfinished,
 			AggregateDeviceDeploymentByStatusStats: deployments.Stats{"aaa": 1},
 			UpdateStatsAndFinishDeploymentError:    errors.New("UpdateStatsAndFinishDeploymentError"),
 			OutputError:                            errors.New("UpdateStatsAndFinishDeploymentError"),
 		},
 		"all correct": {
 			InputDeploymentID:                      "f826484e-1157-4109-af21-304e6d711561",
+			FindByIDDeployment:                     unfinished,
 			AggregateDeviceDeploymentByStatusStats: deployments.Stats{"aaa": 1},
 		},
 	}
@@ -1286,9 +4018,20 @@ func TestDeploymentModelAbortDeployment(t *testing.T) {
 
 			deviceDeploymentStorage := new(mocks.DeviceDeploymentStorage)
 			deploymentStorage := new(mocks.DeploymentsStorage)
+			abortMatcher := mock.MatchedBy(func(abort *deployments.AbortInfo) bool {
+				return abort.Reason == "bad release" &&
+					abort.AbortedBy == "user-1" &&
+					abort.Aborted != nil
+			})
+			deploymentStorage.On("FindByID",
+				h.ContextMatcher(), testCase.InputDeploymentID).
+				Return(testCase.FindByIDDeployment, testCase.FindByIDError)
 			deviceDeploymentStorage.On("AbortDeviceDeployments",
-				h.ContextMatcher(), mock.AnythingOfType("string")).
+				h.ContextMatcher(), mock.AnythingOfType("string"), abortMatcher).
 				Return(testCase.AbortDeviceDeploymentsError)
+			deploymentStorage.On("SetAbortInfo",
+				h.ContextMatcher(), mock.AnythingOfType("string"), abortMatcher).
+				Return(testCase.SetAbortInfoError)
 			deviceDeploymentStorage.On("AggregateDeviceDeploymentByStatus",
 				h.ContextMatcher(), mock.AnythingOfType("string")).
 				Return(testCase.AggregateDeviceDeploymentByStatusStats,
@@ -1304,7 +4047,10 @@ func TestDeploymentModelAbortDeployment(t *testing.T) {
 			})
 
 			err := model.AbortDeployment(context.Background(),
-				testCase.InputDeploymentID)
+				testCase.InputDeploymentID, &deployments.AbortInfo{
+					Reason:    "bad release",
+					AbortedBy: "user-1",
+				})
 			if testCase.OutputError != nil {
 				assert.EqualError(t, err, testCase.OutputError.Error())
 			} else {
@@ -1314,6 +4060,196 @@ func TestDeploymentModelAbortDeployment(t *testing.T) {
 	}
 }
 
+func TestDeploymentModelAbortDeploymentNotify(t *testing.T) {
+
+	deploymentID := "f826484e-1157-4109-af21-304e6d711561"
+
+	deviceDeployment := func(deviceID, status string) deployments.DeviceDeployment {
+		d := deployments.NewDeviceDeployment(deviceID, deploymentID)
+		d.Status = StringToPointer(status)
+		return *d
+	}
+
+	deviceDeploymentStorage := new(mocks.DeviceDeploymentStorage)
+	deviceDeploymentStorage.On("GetDeviceStatusesForDeployment",
+		h.ContextMatcher(), deploymentID).
+		Return([]deployments.DeviceDeployment{
+			deviceDeployment("device-1", deployments.DeviceDeploymentStatusPending),
+			deviceDeployment("device-2", deployments.DeviceDeploymentStatusDownloading),
+			deviceDeployment("device-3", deployments.DeviceDeploymentStatusRebooting),
+			deviceDeployment("device-4", deployments.DeviceDeploymentStatusSuccess),
+		}, nil)
+	deviceDeploymentStorage.On("AbortDeviceDeployments",
+		h.ContextMatcher(), deploymentID, mock.AnythingOfType("*deployments.AbortInfo")).
+		Return(nil)
+	deviceDeploymentStorage.On("AggregateDeviceDeploymentByStatus",
+		h.ContextMatcher(), deploymentID).
+		Return(deployments.Stats{deployments.DeviceDeploymentStatusAborted: 3}, nil)
+
+	deploymentStorage := new(mocks.DeploymentsStorage)
+	deploymentStorage.On("FindByID", h.ContextMatcher(), deploymentID).
+		Return(&deployments.Deployment{Id: StringToPointer(deploymentID)}, nil)
+	deploymentStorage.On("SetAbortInfo",
+		h.ContextMatcher(), deploymentID, mock.AnythingOfType("*deployments.AbortInfo")).
+		Return(nil)
+	deploymentStorage.On("UpdateStatsAndFinishDeployment",
+		h.ContextMatcher(), deploymentID, mock.AnythingOfType("deployments.Stats")).
+		Return(nil)
+
+	notifier := new(mocks.EventPublisher)
+	notified := func(deviceID string) interface{} {
+		return mock.MatchedBy(func(event *deployments.Event) bool {
+			return event.Type == deployments.EventDeviceDeploymentAborted &&
+				event.DeploymentID == deploymentID &&
+				event.DeviceID == deviceID
+		})
+	}
+	notifier.On("Publish", h.ContextMatcher(), notified("device-2")).
+		Return(nil).Once()
+	// failed notification does not fail the abort
+	notifier.On("Publish", h.ContextMatcher(), notified("device-3")).
+		Return(errors.New("bridge unavailable")).Once()
+
+	model := NewDeploymentModel(DeploymentsModelConfig{
+		DeploymentsStorage:       deploymentStorage,
+		DeviceDeploymentsStorage: deviceDeploymentStorage,
+		DeviceNotifier:           notifier,
+	})
+
+	assert.NoError(t, model.AbortDeployment(context.Background(), deploymentID, nil))
+	notifier.AssertExpectations(t)
+	notifier.AssertNumberOfCalls(t, "Publish", 2)
+}
+
+func TestDeploymentModelForceFinishDeployment(t *testing.T) {
+
+	deploymentID := "f826484e-1157-4109-af21-304e6d711561"
+	unfinished := &deployments.Deployment{Id: StringToPointer(deploymentID)}
+
+	testCases := map[string]struct {
+		InputStatus             string
+		InputDeployment         *deployments.Deployment
+		InputFinishDevicesError error
+
+		OutputStats deployments.Stats
+		OutputError error
+	}{
+		"timed out": {
+			InputStatus:     deployments.DeviceDeploymentStatusTimedOut,
+			InputDeployment: unfinished,
+
+			// devices without device deployment time out as well
+			OutputStats: deployments.Stats{
+				deployments.DeviceDeploymentStatusTimedOut: 5,
+				deployments.DeviceDeploymentStatusSuccess:  1,
+				deployments.DeviceDeploymentStatusPending:  0,
+			},
+		},
+		"aborted": {
+			InputStatus:     deployments.DeviceDeploymentStatusAborted,
+			InputDeployment: unfinished,
+
+			OutputStats: deployments.Stats{
+				deployments.DeviceDeploymentStatusTimedOut: 2,
+				deployments.DeviceDeploymentStatusSuccess:  1,
+				deployments.DeviceDeploymentStatusPending:  0,
+				deployments.DeviceDeploymentStatusAborted:  3,
+			},
+		},
+		"invalid status": {
+			InputStatus:     deployments.DeviceDeploymentStatusFailure,
+			InputDeployment: unfinished,
+
+			OutputError: controller.ErrInvalidFinishStatus,
+		},
+		"not found": {
+			InputStatus: deployments.DeviceDeploymentStatusTimedOut,
+
+			OutputError: controller.ErrModelDeploymentNotFound,
+		},
+		"finished already": {
+			InputStatus: deployments.DeviceDeploymentStatusTimedOut,
+			InputDeployment: &deployments.Deployment{
+				Id:       StringToPointer(deploymentID),
+				Finished: TimeToPointer(time.Now()),
+			},
+
+			OutputError: controller.ErrDeploymentAlreadyFinished,
+		},
+		"storage error": {
+			InputStatus:             deployments.DeviceDeploymentStatusTimedOut,
+			InputDeployment:         unfinished,
+			InputFinishDevicesError: errors.New("storage issue"),
+
+			OutputError: errors.New("Finishing device deployments: storage issue"),
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+
+			deploymentDevicesStorage := new(mocks.DeploymentDevicesStorage)
+			deploymentDevicesStorage.On("DeleteDeploymentDevices",
+				h.ContextMatcher(), deploymentID).
+				Return(3, nil)
+			deploymentDevicesStorage.On("CountDeploymentDevices",
+				h.ContextMatcher(), deploymentID).
+				Return(0, nil)
+
+			deviceDeploymentStorage := new(mocks.DeviceDeploymentStorage)
+			deviceDeploymentStorage.On("FinishDeviceDeployments",
+				h.ContextMatcher(), deploymentID, deployments.DeviceDeploymentStatusTimedOut,
+				mock.AnythingOfType("time.Time")).
+				Return(testCase.InputFinishDevicesError)
+			deviceDeploymentStorage.On("AbortDeviceDeployments",
+				h.ContextMatcher(), deploymentID, mock.AnythingOfType("*deployments.AbortInfo")).
+				Return(nil)
+			deviceDeploymentStorage.On("AggregateDeviceDeploymentByStatus",
+				h.ContextMatcher(), deploymentID).
+				Return(deployments.Stats{
+					deployments.DeviceDeploymentStatusTimedOut: 2,
+					deployments.DeviceDeploymentStatusSuccess:  1,
+				}, nil)
+
+			deploymentStorage := new(mocks.DeploymentsStorage)
+			deploymentStorage.On("FindByID", h.ContextMatcher(), deploymentID).
+				Return(testCase.InputDeployment, nil)
+			deploymentStorage.On("SetAbortInfo",
+				h.ContextMatcher(), deploymentID, mock.AnythingOfType("*deployments.AbortInfo")).
+				Return(nil)
+			deploymentStorage.On("UpdateStatsAndFinishDeployment",
+				h.ContextMatcher(), deploymentID, mock.AnythingOfType("deployments.Stats")).
+				Return(nil)
+
+			model := NewDeploymentModel(DeploymentsModelConfig{
+				DeploymentsStorage:       deploymentStorage,
+				DeviceDeploymentsStorage: deviceDeploymentStorage,
+				DeploymentDevicesStorage: deploymentDevicesStorage,
+			})
+
+			err := model.ForceFinishDeployment(context.Background(), deploymentID,
+				testCase.InputStatus, &deployments.AbortInfo{AbortedBy: "user-1"})
+			if testCase.OutputError != nil {
+				assert.EqualError(t, err, testCase.OutputError.Error())
+				deploymentStorage.AssertNotCalled(t, "UpdateStatsAndFinishDeployment",
+					mock.Anything, mock.Anything, mock.Anything)
+				return
+			}
+
+			assert.NoError(t, err)
+			deploymentStorage.AssertCalled(t, "UpdateStatsAndFinishDeployment",
+				h.ContextMatcher(), deploymentID, testCase.OutputStats)
+			if testCase.InputStatus == deployments.DeviceDeploymentStatusAborted {
+				deviceDeploymentStorage.AssertNotCalled(t, "FinishDeviceDeployments",
+					mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+			} else {
+				deviceDeploymentStorage.AssertNotCalled(t, "AbortDeviceDeployments",
+					mock.Anything, mock.Anything, mock.Anything)
+			}
+		})
+	}
+}
+
 func TestDeploymentModelDecommissionDevice(t *testing.T) {
 	//t.Parallel()
 
@@ -1393,3 +4329,130 @@ func TestDeploymentModelDecommissionDevice(t *testing.T) {
 		})
 	}
 }
+
+func TestDeploymentModelGetDeploymentForDeviceReplica(t *testing.T) {
+
+	artifact := images.NewSoftwareImage(
+		validUUIDv4,
+		&images.SoftwareImageMetaConstructor{},
+		&images.SoftwareImageMetaArtifactConstructor{
+			Name:                  "App 123",
+			DeviceTypesCompatible: []string{"hammer"},
+		})
+	deploymentID := "b532b01a-9313-404f-8d19-e7fcbe5cc347"
+
+	testCases := map[string]struct {
+		InputReplica      *deployments.DeviceDeployment
+		InputReplicaError error
+
+		OutputReplica bool
+	}{
+		"resolved on replica": {
+			InputReplica: &deployments.DeviceDeployment{
+				DeviceId:     StringToPointer("device-1"),
+				DeploymentId: StringToPointer(deploymentID),
+				Image:        artifact,
+				DeviceType:   StringToPointer("hammer"),
+			},
+
+			OutputReplica: true,
+		},
+		"not resolved on replica": {
+			InputReplica: &deployments.DeviceDeployment{
+				DeviceId:     StringToPointer("device-1"),
+				DeploymentId: StringToPointer(deploymentID),
+			},
+		},
+		"device type changed": {
+			InputReplica: &deployments.DeviceDeployment{
+				DeviceId:     StringToPointer("device-1"),
+				DeploymentId: StringToPointer(deploymentID),
+				Image:        artifact,
+				DeviceType:   StringToPointer("drill"),
+			},
+		},
+		"not found on replica": {},
+		"replica error": {
+			InputReplicaError: errors.New("replica failed"),
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+
+			deployment := &deployments.Deployment{
+				Id:        StringToPointer(deploymentID),
+				Artifacts: []string{validUUIDv4},
+				DeploymentConstructor: &deployments.DeploymentConstructor{
+					ArtifactName: StringToPointer("App 123"),
+				},
+			}
+
+			replicaDeviceDeployments := new(mocks.DeviceDeploymentStorage)
+			replicaDeviceDeployments.On("FindOldestDeploymentForDeviceIDWithStatuses",
+				h.ContextMatcher(), "device-1", mock.AnythingOfType("[]string")).
+				Return(testCase.InputReplica, testCase.InputReplicaError)
+			replicaDeployments := new(mocks.DeploymentsStorage)
+			replicaDeployments.On("FindByID", h.ContextMatcher(), deploymentID).
+				Return(deployment, nil)
+
+			deviceDeploymentStorage := new(mocks.DeviceDeploymentStorage)
+			deviceDeploymentStorage.On("FindOldestDeploymentForDeviceIDWithStatuses",
+				h.ContextMatcher(), "device-1", mock.AnythingOfType("[]string")).
+				Return(&deployments.DeviceDeployment{
+					DeviceId:     StringToPointer("device-1"),
+					DeploymentId: StringToPointer(deploymentID),
+				}, nil)
+			deviceDeploymentStorage.On("AssignArtifact",
+				h.ContextMatcher(), "device-1", deploymentID, artifact).
+				Return(nil)
+			deviceDeploymentStorage.On("IncrementDeviceDeploymentAttempts",
+				h.ContextMatcher(), "device-1", deploymentID).
+				Return(nil)
+			deploymentStorage := new(mocks.DeploymentsStorage)
+			deploymentStorage.On("FindByID", h.ContextMatcher(), deploymentID).
+				Return(deployment, nil)
+
+			artifactGetter := new(mocks.ArtifactGetter)
+			artifactGetter.On("ImageByIdsAndDeviceType",
+				h.ContextMatcher(), []string{validUUIDv4}, "hammer").
+				Return(artifact, nil)
+
+			imageLinker := new(mocks.GetRequester)
+			imageLinker.On("GetRequest", h.ContextMatcher(), validUUIDv4,
+				DefaultUpdateDownloadLinkExpire, mock.AnythingOfType("string")).
+				Return(&images.Link{Uri: "http://download"}, nil)
+
+			model := NewDeploymentModel(DeploymentsModelConfig{
+				DeploymentsStorage:              deploymentStorage,
+				DeviceDeploymentsStorage:        deviceDeploymentStorage,
+				ArtifactGetter:                  artifactGetter,
+				ImageLinker:                     imageLinker,
+				ReplicaDeploymentsStorage:       replicaDeployments,
+				ReplicaDeviceDeploymentsStorage: replicaDeviceDeployments,
+			})
+
+			out, err := model.GetDeploymentForDeviceWithCurrent(context.Background(),
+				"device-1", deployments.InstalledDeviceDeployment{
+					Artifact:   "App 122",
+					DeviceType: "hammer",
+				})
+			assert.NoError(t, err)
+			if assert.NotNil(t, out) {
+				assert.Equal(t, "App 123", out.Artifact.ArtifactName)
+			}
+
+			if testCase.OutputReplica {
+				replicaDeployments.AssertExpectations(t)
+				deploymentStorage.AssertNotCalled(t, "FindByID", mock.Anything, mock.Anything)
+				deviceDeploymentStorage.AssertNotCalled(t,
+					"FindOldestDeploymentForDeviceIDWithStatuses",
+					mock.Anything, mock.Anything, mock.Anything)
+			} else {
+				replicaDeployments.AssertNotCalled(t, "FindByID", mock.Anything, mock.Anything)
+				deploymentStorage.AssertExpectations(t)
+				deviceDeploymentStorage.AssertExpectations(t)
+			}
+		})
+	}
+}
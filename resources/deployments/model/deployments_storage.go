@@ -26,15 +26,37 @@ type DeploymentsStorage interface {
 	Insert(ctx context.Context, deployment *deployments.Deployment) error
 	Delete(ctx context.Context, id string) error
 	FindByID(ctx context.Context, id string) (*deployments.Deployment, error)
+	// FindBySlug returns nil if there is no deployment with the slug
+	FindBySlug(ctx context.Context, slug string) (*deployments.Deployment, error)
 	FindUnfinishedByID(ctx context.Context,
 		id string) (*deployments.Deployment, error)
 	UpdateStats(ctx context.Context, id string, state_from, state_to string) error
+	IncrementStats(ctx context.Context, stats map[string]deployments.Stats) error
 	UpdateStatsAndFinishDeployment(ctx context.Context,
 		id string, stats deployments.Stats) error
 	Find(ctx context.Context,
 		query deployments.Query) ([]*deployments.Deployment, error)
 	Finish(ctx context.Context, id string, when time.Time) error
+	// Reopen clears finish time of the deployment
+	Reopen(ctx context.Context, id string) error
 	ExistUnfinishedByArtifactId(ctx context.Context, id string) (bool, error)
 	ExistByArtifactId(ctx context.Context, id string) (bool, error)
+	ExistByArtifactIdCreatedAfter(ctx context.Context, id string,
+		since time.Time) (bool, error)
 	DeviceCountByDeployment(ctx context.Context, id string) (int, error)
+	IncrementDownloadCount(ctx context.Context, id string,
+		period time.Time) (int, error)
+	SetAbortInfo(ctx context.Context, id string, abort *deployments.AbortInfo) error
+	SetPaused(ctx context.Context, ids []string, paused bool) error
+	CountByStatus(ctx context.Context, status deployments.StatusQuery) (int, error)
+	IncrementStatsRollup(ctx context.Context, when time.Time, status string) error
+	AggregateStatsRollups(ctx context.Context,
+		since time.Time) (deployments.Stats, error)
+	// FindWithStartedPhases returns unfinished deployments with phases
+	// started by the time but not activated yet
+	FindWithStartedPhases(ctx context.Context,
+		now time.Time) ([]*deployments.Deployment, error)
+	// ActivatePhase returns false if the phase was activated already
+	ActivatePhase(ctx context.Context, id string, phase int,
+		when time.Time) (bool, error)
 }
@@ -25,4 +25,8 @@ type DeviceDeploymentLogsStorage interface {
 	SaveDeviceDeploymentLog(ctx context.Context, log deployments.DeploymentLog) error
 	GetDeviceDeploymentLog(ctx context.Context,
 		deviceID, deploymentID string) (*deployments.DeploymentLog, error)
+	DeleteDeviceDeploymentLogs(ctx context.Context, deviceID string) (int, error)
+	EnsureSearchIndex(ctx context.Context) error
+	SearchDeviceDeploymentLogs(ctx context.Context,
+		deploymentID, text string) ([]string, error)
 }
@@ -16,6 +16,7 @@ package model
 
 import (
 	"context"
+	"time"
 
 	"github.com/mendersoftware/deployments/resources/deployments"
 	"github.com/mendersoftware/deployments/resources/images"
@@ -31,22 +32,71 @@ type DeviceDeploymentStorage interface {
 		deviceID string, statuses ...string) (*deployments.DeviceDeployment, error)
 	FindAllDeploymentsForDeviceIDWithStatuses(ctx context.Context,
 		deviceID string, statuses ...string) ([]deployments.DeviceDeployment, error)
+	FindDeviceIDsWithStatuses(ctx context.Context,
+		deviceIDs []string, statuses ...string) ([]string, error)
+	FindDeploymentsForDeviceIDsWithStatuses(ctx context.Context,
+		deviceIDs []string, statuses ...string) ([]deployments.DeviceDeployment, error)
+	FindLatestDeploymentForDeviceID(ctx context.Context,
+		deviceID string, deploymentIDs ...string) (*deployments.DeviceDeployment, error)
 
 	UpdateDeviceDeploymentStatus(ctx context.Context, deviceID string,
 		deploymentID string, status deployments.DeviceDeploymentStatus) (string, error)
+	UpdateDeviceDeploymentStatuses(ctx context.Context,
+		updates []deployments.DeviceDeploymentStatusUpdate) (int, error)
+	// FindAppliedStatusUpdates returns IDs of the updates which are the
+	// last ones applied to their device deployments
+	FindAppliedStatusUpdates(ctx context.Context,
+		updates []deployments.DeviceDeploymentStatusUpdate) ([]string, error)
 
 	UpdateDeviceDeploymentLogAvailability(ctx context.Context,
 		deviceID string, deploymentID string, log bool) error
+	RevokeDeviceDeploymentLink(ctx context.Context,
+		deviceID string, deploymentID string) error
+	// RetryDeviceDeployment puts the device deployment in the from status
+	// back to pending
+	RetryDeviceDeployment(ctx context.Context,
+		deviceID string, deploymentID string, from string) error
 	AssignArtifact(ctx context.Context, deviceID string,
 		deploymentID string, artifact *images.SoftwareImage) error
+	IncrementDeviceDeploymentAttempts(ctx context.Context,
+		deviceID string, deploymentID string) error
+	IncrementDeviceDeploymentStatusResets(ctx context.Context,
+		deviceID string, deploymentID string) error
+	IncrementDeviceDeploymentIgnoredReports(ctx context.Context,
+		deviceID string, deploymentID string) error
+	// SaveDeviceDeploymentInventory sets the inventory snapshot of the
+	// device deployment unless it has one already
+	SaveDeviceDeploymentInventory(ctx context.Context, deviceID string,
+		deploymentID string, inventory map[string]string) error
 	AggregateDeviceDeploymentByStatus(ctx context.Context,
 		id string) (deployments.Stats, error)
+	AggregateDeviceDeploymentByPhase(ctx context.Context,
+		id string) (map[int]deployments.Stats, error)
+	AggregateDeviceDeploymentFailures(ctx context.Context,
+		id string) (deployments.FailureStats, error)
+	AggregateDeviceDeploymentDurations(ctx context.Context,
+		id string) (deployments.DurationStats, error)
+	AverageDeviceDeploymentDuration(ctx context.Context,
+		since time.Time) (time.Duration, int, error)
 	GetDeviceStatusesForDeployment(ctx context.Context,
 		deploymentID string) ([]deployments.DeviceDeployment, error)
+	CountDeviceDeployments(ctx context.Context,
+		deploymentID, status string) (int, error)
+	FindDeviceDeployments(ctx context.Context,
+		query deployments.DeviceDeploymentsQuery) ([]deployments.DeviceDeployment, error)
 	HasDeploymentForDevice(ctx context.Context,
 		deploymentID string, deviceID string) (bool, error)
 	GetDeviceDeploymentStatus(ctx context.Context,
 		deploymentID string, deviceID string) (string, error)
-	AbortDeviceDeployments(ctx context.Context, deploymentID string) error
+	AbortDeviceDeployments(ctx context.Context, deploymentID string,
+		abort *deployments.AbortInfo) error
+	FinishDeviceDeployments(ctx context.Context, deploymentID string,
+		status string, finished time.Time) error
 	DecommissionDeviceDeployments(ctx context.Context, deviceId string) error
+	AnonymizeDeviceDeployments(ctx context.Context,
+		deviceID, anonymousID string) (int, error)
+	SupersedeDeviceDeployments(ctx context.Context, deploymentID string,
+		deviceIDs []string) ([]string, error)
+	PartitionDeviceDeployments(ctx context.Context,
+		finishedBefore time.Time) (int, error)
 }
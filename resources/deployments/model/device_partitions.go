@@ -0,0 +1,37 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// PartitionDeviceDeployments moves device deployments of the tenant in
+// context which finished before the given time out of the active storage
+// to the monthly partitions. They stay available to all lookups.
+// Returns number of moved device deployments.
+func (d *DeploymentsModel) PartitionDeviceDeployments(ctx context.Context,
+	finishedBefore time.Time) (int, error) {
+
+	moved, err := d.deviceDeploymentsStorage.PartitionDeviceDeployments(ctx, finishedBefore)
+	if err != nil {
+		return moved, errors.Wrap(err, "partitioning device deployments")
+	}
+
+	return moved, nil
+}
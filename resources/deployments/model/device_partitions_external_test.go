@@ -0,0 +1,76 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	. "github.com/mendersoftware/deployments/resources/deployments/model"
+	"github.com/mendersoftware/deployments/resources/deployments/model/mocks"
+	h "github.com/mendersoftware/deployments/utils/testing"
+)
+
+func TestDeploymentModelPartitionDeviceDeployments(t *testing.T) {
+
+	before := time.Date(2019, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	testCases := map[string]struct {
+		StorageMoved int
+		StorageError error
+
+		OutputMoved int
+		OutputError string
+	}{
+		"ok": {
+			StorageMoved: 12,
+			OutputMoved:  12,
+		},
+		"error": {
+			StorageMoved: 3,
+			StorageError: errors.New("connection failed"),
+			OutputMoved:  3,
+			OutputError:  "partitioning device deployments: connection failed",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+
+			deviceDeploymentStorage := new(mocks.DeviceDeploymentStorage)
+			deviceDeploymentStorage.On("PartitionDeviceDeployments",
+				h.ContextMatcher(), before).
+				Return(tc.StorageMoved, tc.StorageError)
+
+			model := NewDeploymentModel(DeploymentsModelConfig{
+				DeviceDeploymentsStorage: deviceDeploymentStorage,
+			})
+
+			moved, err := model.PartitionDeviceDeployments(context.Background(), before)
+			if tc.OutputError != "" {
+				assert.EqualError(t, err, tc.OutputError)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tc.OutputMoved, moved)
+
+			deviceDeploymentStorage.AssertExpectations(t)
+		})
+	}
+}
@@ -0,0 +1,77 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"context"
+
+	"github.com/mendersoftware/go-lib-micro/identity"
+	"github.com/pkg/errors"
+	"github.com/satori/go.uuid"
+
+	"github.com/mendersoftware/deployments/resources/deployments"
+	"github.com/mendersoftware/deployments/resources/deployments/controller"
+)
+
+// PurgeDeviceData removes deployment logs, downloads audit records and
+// telemetry samples of the device from the storage of the tenant in
+// context. Active deployments of the device are decommissioned first;
+// device deployments are then anonymized, so deployment statistics are
+// kept.
+func (d *DeploymentsModel) PurgeDeviceData(ctx context.Context,
+	deviceID string) (*deployments.DeviceDataPurge, error) {
+
+	if deviceID == "" {
+		return nil, controller.ErrModelMissingInput
+	}
+
+	purge := &deployments.DeviceDataPurge{}
+	if id := identity.FromContext(ctx); id != nil {
+		purge.TenantID = id.Tenant
+	}
+
+	if err := d.DecommissionDevice(ctx, deviceID); err != nil &&
+		!deployments.IsStoreError(err, deployments.ErrStorageNotFound) {
+		return nil, errors.Wrap(err, "failed to decommission device")
+	}
+
+	var err error
+	purge.DeviceDeployments, err = d.deviceDeploymentsStorage.AnonymizeDeviceDeployments(ctx,
+		deviceID, uuid.NewV4().String())
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to anonymize device deployments")
+	}
+
+	purge.Logs, err = d.deviceDeploymentLogsStorage.DeleteDeviceDeploymentLogs(ctx, deviceID)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to delete deployment logs")
+	}
+
+	if d.downloadsStorage != nil {
+		purge.Downloads, err = d.downloadsStorage.DeleteDeviceDownloads(ctx, deviceID)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to delete downloads")
+		}
+	}
+
+	if d.telemetryStorage != nil {
+		purge.Telemetry, err = d.telemetryStorage.DeleteDeviceTelemetry(ctx, deviceID)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to delete telemetry")
+		}
+	}
+
+	return purge, nil
+}
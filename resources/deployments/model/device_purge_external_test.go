@@ -0,0 +1,184 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/mendersoftware/go-lib-micro/identity"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/mendersoftware/deployments/resources/deployments"
+	"github.com/mendersoftware/deployments/resources/deployments/controller"
+	. "github.com/mendersoftware/deployments/resources/deployments/model"
+	"github.com/mendersoftware/deployments/resources/deployments/model/mocks"
+	h "github.com/mendersoftware/deployments/utils/testing"
+)
+
+func TestDeploymentModelPurgeDeviceData(t *testing.T) {
+
+	testCases := map[string]struct {
+		InputDeviceID string
+		InputTenant   string
+		NoDownloads   bool
+		WithTelemetry bool
+
+		DecommissionError error
+		AnonymizeError    error
+		LogsError         error
+		DownloadsError    error
+		TelemetryError    error
+
+		OutputPurge *deployments.DeviceDataPurge
+		OutputError string
+	}{
+		"ok": {
+			InputDeviceID: "foo",
+			OutputPurge: &deployments.DeviceDataPurge{
+				DeviceDeployments: 3,
+				Logs:              2,
+				Downloads:         4,
+			},
+		},
+		"ok, tenant": {
+			InputDeviceID: "foo",
+			InputTenant:   "acme",
+			OutputPurge: &deployments.DeviceDataPurge{
+				TenantID:          "acme",
+				DeviceDeployments: 3,
+				Logs:              2,
+				Downloads:         4,
+			},
+		},
+		"ok, no downloads audit": {
+			InputDeviceID: "foo",
+			NoDownloads:   true,
+			OutputPurge: &deployments.DeviceDataPurge{
+				DeviceDeployments: 3,
+				Logs:              2,
+			},
+		},
+		"ok, telemetry": {
+			InputDeviceID: "foo",
+			WithTelemetry: true,
+			OutputPurge: &deployments.DeviceDataPurge{
+				DeviceDeployments: 3,
+				Logs:              2,
+				Downloads:         4,
+				Telemetry:         5,
+			},
+		},
+		"ok, no device deployments": {
+			InputDeviceID:     "foo",
+			DecommissionError: deployments.NewStoreError("DecommissionDeviceDeployments", "devices", deployments.ErrStorageNotFound),
+			OutputPurge: &deployments.DeviceDataPurge{
+				DeviceDeployments: 3,
+				Logs:              2,
+				Downloads:         4,
+			},
+		},
+		"missing device ID": {
+			OutputError: controller.ErrModelMissingInput.Error(),
+		},
+		"decommission error": {
+			InputDeviceID:     "foo",
+			DecommissionError: errors.New("connection failed"),
+			OutputError:       "failed to decommission device: connection failed",
+		},
+		"anonymize error": {
+			InputDeviceID:  "foo",
+			AnonymizeError: errors.New("connection failed"),
+			OutputError:    "failed to anonymize device deployments: connection failed",
+		},
+		"logs error": {
+			InputDeviceID: "foo",
+			LogsError:     errors.New("connection failed"),
+			OutputError:   "failed to delete deployment logs: connection failed",
+		},
+		"downloads error": {
+			InputDeviceID:  "foo",
+			DownloadsError: errors.New("connection failed"),
+			OutputError:    "failed to delete downloads: connection failed",
+		},
+		"telemetry error": {
+			InputDeviceID:  "foo",
+			WithTelemetry:  true,
+			TelemetryError: errors.New("connection failed"),
+			OutputError:    "failed to delete telemetry: connection failed",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+
+			deviceDeploymentStorage := new(mocks.DeviceDeploymentStorage)
+			deviceDeploymentStorage.On("DecommissionDeviceDeployments",
+				h.ContextMatcher(), tc.InputDeviceID).
+				Return(tc.DecommissionError)
+			deviceDeploymentStorage.On("FindAllDeploymentsForDeviceIDWithStatuses",
+				h.ContextMatcher(), tc.InputDeviceID,
+				mock.AnythingOfType("[]string")).
+				Return(nil, nil)
+			deviceDeploymentStorage.On("AnonymizeDeviceDeployments",
+				h.ContextMatcher(), tc.InputDeviceID,
+				mock.AnythingOfType("string")).
+				Return(3, tc.AnonymizeError)
+
+			logsStorage := new(mocks.DeviceDeploymentLogsStorage)
+			logsStorage.On("DeleteDeviceDeploymentLogs",
+				h.ContextMatcher(), tc.InputDeviceID).
+				Return(2, tc.LogsError)
+
+			config := DeploymentsModelConfig{
+				DeviceDeploymentsStorage:    deviceDeploymentStorage,
+				DeviceDeploymentLogsStorage: logsStorage,
+			}
+			if !tc.NoDownloads {
+				downloadsStorage := new(mocks.DownloadsStorage)
+				downloadsStorage.On("DeleteDeviceDownloads",
+					h.ContextMatcher(), tc.InputDeviceID).
+					Return(4, tc.DownloadsError)
+				config.DownloadsStorage = downloadsStorage
+			}
+			if tc.WithTelemetry {
+				telemetryStorage := new(mocks.TelemetryStorage)
+				telemetryStorage.On("DeleteDeviceTelemetry",
+					h.ContextMatcher(), tc.InputDeviceID).
+					Return(5, tc.TelemetryError)
+				config.TelemetryStorage = telemetryStorage
+			}
+			model := NewDeploymentModel(config)
+
+			ctx := context.Background()
+			if tc.InputTenant != "" {
+				ctx = identity.WithContext(ctx, &identity.Identity{
+					Tenant: tc.InputTenant,
+				})
+			}
+
+			purge, err := model.PurgeDeviceData(ctx, tc.InputDeviceID)
+			if tc.OutputError != "" {
+				assert.EqualError(t, err, tc.OutputError)
+				assert.Nil(t, purge)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tc.OutputPurge, purge)
+			}
+		})
+	}
+}
@@ -0,0 +1,104 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/deployments/resources/deployments"
+	"github.com/mendersoftware/deployments/resources/deployments/controller"
+)
+
+// SetDeviceTypeAlias creates device type alias or replaces the device type
+// of existing one.
+func (d *DeploymentsModel) SetDeviceTypeAlias(ctx context.Context,
+	alias *deployments.DeviceTypeAlias) error {
+
+	if alias == nil {
+		return controller.ErrModelMissingInput
+	}
+
+	if err := alias.Validate(); err != nil {
+		return errors.Wrap(err, "Validating device type alias")
+	}
+
+	if err := d.deviceTypeAliasesStorage.UpsertDeviceTypeAlias(ctx, alias); err != nil {
+		return errors.Wrap(err, "Storing device type alias")
+	}
+
+	return nil
+}
+
+// GetDeviceTypeAliases lists tenant device type aliases.
+func (d *DeploymentsModel) GetDeviceTypeAliases(ctx context.Context) (
+	[]*deployments.DeviceTypeAlias, error) {
+
+	aliases, err := d.deviceTypeAliasesStorage.FindDeviceTypeAliases(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "Searching for device type aliases")
+	}
+
+	if aliases == nil {
+		aliases = []*deployments.DeviceTypeAlias{}
+	}
+
+	return aliases, nil
+}
+
+// DeleteDeviceTypeAlias removes tenant device type alias.
+func (d *DeploymentsModel) DeleteDeviceTypeAlias(ctx context.Context, alias string) error {
+	if err := d.deviceTypeAliasesStorage.DeleteDeviceTypeAlias(ctx, alias); err != nil {
+		return errors.Wrap(err, "Removing device type alias")
+	}
+
+	return nil
+}
+
+// equivalentDeviceTypes returns device types aliased with the given one,
+// none if device type aliases are not configured.
+func (d *DeploymentsModel) equivalentDeviceTypes(ctx context.Context,
+	deviceType string) ([]string, error) {
+
+	if d.deviceTypeAliasesStorage == nil {
+		return nil, nil
+	}
+
+	aliases, err := d.deviceTypeAliasesStorage.FindDeviceTypeAliases(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "Searching for device type aliases")
+	}
+
+	return deployments.EquivalentDeviceTypes(aliases, deviceType), nil
+}
+
+// compatibleDeviceTypes returns device types compatible with the artifact,
+// including the type of the device if the artifact was selected through
+// an alias, so that the device accepts the deployment.
+func compatibleDeviceTypes(artifactDeviceTypes []string, deviceType string) []string {
+	if deviceType == "" {
+		return artifactDeviceTypes
+	}
+	for _, compatible := range artifactDeviceTypes {
+		if compatible == deviceType {
+			return artifactDeviceTypes
+		}
+	}
+
+	compatible := make([]string, 0, len(artifactDeviceTypes)+1)
+	compatible = append(compatible, artifactDeviceTypes...)
+	return append(compatible, deviceType)
+}
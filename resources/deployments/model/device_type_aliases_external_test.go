@@ -0,0 +1,292 @@
+// Copyright 2026 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/mendersoftware/deployments/resources/deployments"
+	"github.com/mendersoftware/deployments/resources/deployments/controller"
+	. "github.com/mendersoftware/deployments/resources/deployments/model"
+	"github.com/mendersoftware/deployments/resources/deployments/model/mocks"
+	"github.com/mendersoftware/deployments/resources/images"
+	. "github.com/mendersoftware/deployments/utils/pointers"
+	h "github.com/mendersoftware/deployments/utils/testing"
+)
+
+func TestDeploymentModelSetDeviceTypeAlias(t *testing.T) {
+
+	testCases := map[string]struct {
+		InputAlias       *deployments.DeviceTypeAlias
+		InputUpsertError error
+
+		OutputError error
+	}{
+		"ok": {
+			InputAlias: &deployments.DeviceTypeAlias{
+				Alias:      "rpi4",
+				DeviceType: "raspberrypi4",
+			},
+		},
+		"missing input": {
+			OutputError: controller.ErrModelMissingInput,
+		},
+		"alias of itself": {
+			InputAlias: &deployments.DeviceTypeAlias{
+				Alias:      "rpi4",
+				DeviceType: "rpi4",
+			},
+			OutputError: errors.New("Validating device type alias: " +
+				deployments.ErrDeviceTypeAliasSelf.Error()),
+		},
+		"storage error": {
+			InputAlias: &deployments.DeviceTypeAlias{
+				Alias:      "rpi4",
+				DeviceType: "raspberrypi4",
+			},
+			InputUpsertError: errors.New("storage issue"),
+
+			OutputError: errors.New("Storing device type alias: storage issue"),
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+
+			aliasesStorage := new(mocks.DeviceTypeAliasesStorage)
+			aliasesStorage.On("UpsertDeviceTypeAlias",
+				h.ContextMatcher(), testCase.InputAlias).
+				Return(testCase.InputUpsertError)
+
+			model := NewDeploymentModel(DeploymentsModelConfig{
+				DeviceTypeAliasesStorage: aliasesStorage,
+			})
+
+			err := model.SetDeviceTypeAlias(context.Background(), testCase.InputAlias)
+			if testCase.OutputError != nil {
+				assert.EqualError(t, err, testCase.OutputError.Error())
+			} else {
+				assert.NoError(t, err)
+				aliasesStorage.AssertExpectations(t)
+			}
+		})
+	}
+}
+
+func TestDeploymentModelGetDeviceTypeAliases(t *testing.T) {
+
+	aliasesStorage := new(mocks.DeviceTypeAliasesStorage)
+	aliasesStorage.On("FindDeviceTypeAliases", h.ContextMatcher()).
+		Return(nil, nil).Once()
+	aliasesStorage.On("FindDeviceTypeAliases", h.ContextMatcher()).
+		Return(nil, errors.New("storage issue")).Once()
+
+	model := NewDeploymentModel(DeploymentsModelConfig{
+		DeviceTypeAliasesStorage: aliasesStorage,
+	})
+
+	aliases, err := model.GetDeviceTypeAliases(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []*deployments.DeviceTypeAlias{}, aliases)
+
+	_, err = model.GetDeviceTypeAliases(context.Background())
+	assert.EqualError(t, err, "Searching for device type aliases: storage issue")
+}
+
+func TestDeploymentModelGetDeploymentForDeviceAlias(t *testing.T) {
+
+	artifact := images.NewSoftwareImage(
+		validUUIDv4,
+		&images.SoftwareImageMetaConstructor{},
+		&images.SoftwareImageMetaArtifactConstructor{
+			Name:                  "App 123",
+			DeviceTypesCompatible: []string{"raspberrypi4"},
+		})
+
+	testCases := map[string]struct {
+		InputDeviceType   string
+		InputAliases      []*deployments.DeviceTypeAlias
+		InputAliasesError error
+		InputNoStorage    bool
+
+		OutputError          error
+		OutputDeviceTypes    []string
+		OutputAliasesQueried bool
+	}{
+		"device type": {
+			InputDeviceType: "raspberrypi4",
+			InputAliases: []*deployments.DeviceTypeAlias{
+				{Alias: "rpi4", DeviceType: "raspberrypi4"},
+			},
+
+			OutputDeviceTypes: []string{"raspberrypi4"},
+		},
+		"alias": {
+			InputDeviceType: "rpi4",
+			InputAliases: []*deployments.DeviceTypeAlias{
+				{Alias: "rpi4", DeviceType: "raspberrypi4"},
+			},
+
+			OutputDeviceTypes:    []string{"raspberrypi4", "rpi4"},
+			OutputAliasesQueried: true,
+		},
+		"alias of alias": {
+			InputDeviceType: "acme-gateway",
+			InputAliases: []*deployments.DeviceTypeAlias{
+				{Alias: "acme-gateway", DeviceType: "rpi4"},
+				{Alias: "rpi4", DeviceType: "raspberrypi4"},
+			},
+
+			OutputDeviceTypes:    []string{"raspberrypi4", "acme-gateway"},
+			OutputAliasesQueried: true,
+		},
+		"not aliased": {
+			InputDeviceType: "beaglebone",
+			InputAliases: []*deployments.DeviceTypeAlias{
+				{Alias: "rpi4", DeviceType: "raspberrypi4"},
+			},
+
+			OutputAliasesQueried: true,
+		},
+		"aliases not configured": {
+			InputDeviceType: "rpi4",
+			InputNoStorage:  true,
+		},
+		"storage error": {
+			InputDeviceType:   "rpi4",
+			InputAliasesError: errors.New("storage issue"),
+
+			OutputError: errors.New("assigning artifact to device deployment: " +
+				"Searching for device type aliases: storage issue"),
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+
+			deploymentID := "b532b01a-9313-404f-8d19-e7fcbe5cc347"
+
+			deviceDeploymentStorage := new(mocks.DeviceDeploymentStorage)
+			deviceDeploymentStorage.On("FindOldestDeploymentForDeviceIDWithStatuses",
+				h.ContextMatcher(), "device-1", mock.AnythingOfType("[]string")).
+				Return(&deployments.DeviceDeployment{
+					DeviceId:     StringToPointer("device-1"),
+					DeploymentId: StringToPointer(deploymentID),
+				}, nil)
+			deviceDeploymentStorage.On("AssignArtifact",
+				h.ContextMatcher(), "device-1", deploymentID, artifact).
+				Return(nil)
+			deviceDeploymentStorage.On("IncrementDeviceDeploymentAttempts",
+				h.ContextMatcher(), "device-1", deploymentID).
+				Return(nil)
+			deviceDeploymentStorage.On("GetDeviceDeploymentStatus",
+				h.ContextMatcher(), deploymentID, "device-1").
+				Return(deployments.DeviceDeploymentStatusPending, nil)
+			deviceDeploymentStorage.On("UpdateDeviceDeploymentStatus",
+				h.ContextMatcher(), "device-1", deploymentID,
+				mock.AnythingOfType("deployments.DeviceDeploymentStatus")).
+				Return(deployments.DeviceDeploymentStatusPending, nil)
+
+			deploymentStorage := new(mocks.DeploymentsStorage)
+			deploymentStorage.On("FindByID",
+				h.ContextMatcher(), deploymentID).
+				Return(&deployments.Deployment{
+					Id:        StringToPointer(deploymentID),
+					Artifacts: []string{validUUIDv4},
+					Stats:     deployments.NewDeviceDeploymentStats(),
+					DeploymentConstructor: &deployments.DeploymentConstructor{
+						ArtifactName: StringToPointer("App 123"),
+					},
+					DeviceTypeArtifacts: []deployments.DeviceTypeArtifact{
+						{DeviceType: "raspberrypi4", ArtifactID: validUUIDv4},
+					},
+				}, nil)
+			deploymentStorage.On("UpdateStats",
+				h.ContextMatcher(), deploymentID,
+				mock.AnythingOfType("string"), mock.AnythingOfType("string")).
+				Return(nil)
+			deploymentStorage.On("IncrementStatsRollup",
+				h.ContextMatcher(), mock.AnythingOfType("time.Time"),
+				mock.AnythingOfType("string")).
+				Return(nil)
+			deploymentStorage.On("Finish",
+				h.ContextMatcher(), deploymentID, mock.AnythingOfType("time.Time")).
+				Return(nil)
+
+			artifactGetter := new(mocks.ArtifactGetter)
+			artifactGetter.On("FindByID",
+				h.ContextMatcher(), validUUIDv4).
+				Return(artifact, nil)
+
+			imageLinker := new(mocks.GetRequester)
+			imageLinker.On("GetRequest", h.ContextMatcher(), validUUIDv4,
+				DefaultUpdateDownloadLinkExpire, mock.AnythingOfType("string")).
+				Return(&images.Link{}, nil)
+
+			aliasesStorage := new(mocks.DeviceTypeAliasesStorage)
+			aliasesStorage.On("FindDeviceTypeAliases", h.ContextMatcher()).
+				Return(testCase.InputAliases, testCase.InputAliasesError)
+
+			config := DeploymentsModelConfig{
+				DeploymentsStorage:       deploymentStorage,
+				DeviceDeploymentsStorage: deviceDeploymentStorage,
+				ArtifactGetter:           artifactGetter,
+				ImageLinker:              imageLinker,
+			}
+			if !testCase.InputNoStorage {
+				config.DeviceTypeAliasesStorage = aliasesStorage
+			}
+			model := NewDeploymentModel(config)
+
+			out, err := model.GetDeploymentForDeviceWithCurrent(context.Background(),
+				"device-1", deployments.InstalledDeviceDeployment{
+					Artifact:   "App 122",
+					DeviceType: testCase.InputDeviceType,
+				})
+			if testCase.OutputError != nil {
+				assert.EqualError(t, err, testCase.OutputError.Error())
+				return
+			}
+
+			assert.NoError(t, err)
+			if testCase.OutputDeviceTypes != nil {
+				if assert.NotNil(t, out) {
+					assert.Equal(t, "App 123", out.Artifact.ArtifactName)
+					assert.Equal(t, testCase.OutputDeviceTypes,
+						out.Artifact.DeviceTypesCompatible)
+				}
+				// artifact device types are not modified
+				assert.Equal(t, []string{"raspberrypi4"}, artifact.DeviceTypesCompatible)
+			} else {
+				assert.Nil(t, out)
+				deviceDeploymentStorage.AssertCalled(t, "UpdateDeviceDeploymentStatus",
+					h.ContextMatcher(), "device-1", deploymentID,
+					mock.MatchedBy(func(status deployments.DeviceDeploymentStatus) bool {
+						return status.Status == deployments.DeviceDeploymentStatusNoArtifact
+					}))
+			}
+			if testCase.OutputAliasesQueried {
+				aliasesStorage.AssertCalled(t, "FindDeviceTypeAliases", h.ContextMatcher())
+			} else {
+				aliasesStorage.AssertNotCalled(t, "FindDeviceTypeAliases", mock.Anything)
+			}
+		})
+	}
+}
@@ -0,0 +1,111 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"context"
+
+	"github.com/mendersoftware/go-lib-micro/log"
+
+	"github.com/mendersoftware/deployments/resources/deployments"
+	"github.com/mendersoftware/deployments/utils/metrics"
+)
+
+const (
+	kiB = 1024
+	miB = 1024 * kiB
+	giB = 1024 * miB
+)
+
+// artifacts are labeled by the smallest size bucket they fit in
+var artifactSizeBuckets = []struct {
+	upTo  int64
+	label string
+}{
+	{16 * miB, "up_to_16MiB"},
+	{128 * miB, "up_to_128MiB"},
+	{giB, "up_to_1GiB"},
+}
+
+const artifactSizeOverLabel = "over_1GiB"
+
+// DownloadMetrics are histograms of artifact download duration and
+// throughput by artifact size, computed from the download speed devices
+// report with status updates.
+type DownloadMetrics struct {
+	Duration   *metrics.Histogram
+	Throughput *metrics.Histogram
+}
+
+func NewDownloadMetrics() *DownloadMetrics {
+	return &DownloadMetrics{
+		Duration: metrics.NewHistogram(
+			"deployments_artifact_download_duration_seconds",
+			"Duration of artifact downloads reported by devices, by artifact size.",
+			[]float64{10, 30, 60, 120, 300, 600, 1800, 3600, 7200},
+			"artifact_size"),
+		Throughput: metrics.NewHistogram(
+			"deployments_artifact_download_throughput_bytes_per_second",
+			"Throughput of artifact downloads reported by devices, by artifact size.",
+			[]float64{32 * kiB, 128 * kiB, 512 * kiB, miB, 4 * miB, 16 * miB, 64 * miB},
+			"artifact_size"),
+	}
+}
+
+// Observe records download of an artifact of the size in bytes at the speed
+// in bytes per second.
+func (m *DownloadMetrics) Observe(size int64, speed float64) {
+	if size <= 0 || speed <= 0 {
+		return
+	}
+	label := artifactSizeOverLabel
+	for _, bucket := range artifactSizeBuckets {
+		if size <= bucket.upTo {
+			label = bucket.label
+			break
+		}
+	}
+	m.Duration.Observe(float64(size)/speed, label)
+	m.Throughput.Observe(speed, label)
+}
+
+// Families returns the histograms for exposition.
+func (m *DownloadMetrics) Families() []metrics.Family {
+	return []metrics.Family{m.Duration, m.Throughput}
+}
+
+// observeDownload records the download speed the device reported along
+// with the size of the artifact assigned to it. Failure is only logged.
+func (d *DeploymentsModel) observeDownload(ctx context.Context, deploymentID,
+	deviceID string, ddStatus deployments.DeviceDeploymentStatus) {
+
+	if d.downloadMetrics == nil || ddStatus.Telemetry == nil ||
+		ddStatus.Telemetry.DownloadSpeed == nil || *ddStatus.Telemetry.DownloadSpeed <= 0 {
+		return
+	}
+
+	deviceDeployment, err := d.deviceDeploymentsStorage.FindLatestDeploymentForDeviceID(ctx,
+		deviceID, deploymentID)
+	if err != nil {
+		log.FromContext(ctx).Warnf("failed to get artifact size of device %s: %v",
+			deviceID, err)
+		return
+	}
+	if deviceDeployment == nil || deviceDeployment.Image == nil {
+		return
+	}
+
+	d.downloadMetrics.Observe(deviceDeployment.Image.Size, *ddStatus.Telemetry.DownloadSpeed)
+}
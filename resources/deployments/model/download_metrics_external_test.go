@@ -0,0 +1,146 @@
+// Copyright 2026 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/mendersoftware/deployments/resources/deployments"
+	. "github.com/mendersoftware/deployments/resources/deployments/model"
+	"github.com/mendersoftware/deployments/resources/deployments/model/mocks"
+	"github.com/mendersoftware/deployments/resources/images"
+	"github.com/mendersoftware/deployments/utils/metrics"
+	h "github.com/mendersoftware/deployments/utils/testing"
+)
+
+func TestDownloadMetrics(t *testing.T) {
+	m := NewDownloadMetrics()
+	// 10MiB in 20s, 2GiB in 1h
+	m.Observe(10*1024*1024, 512*1024)
+	m.Observe(2*1024*1024*1024, 2*1024*1024*1024/3600.0)
+	// not observed
+	m.Observe(0, 1024)
+	m.Observe(1024, 0)
+
+	var b bytes.Buffer
+	assert.NoError(t, metrics.WriteOpenMetrics(&b, m.Families()...))
+	out := b.String()
+	assert.Contains(t, out,
+		`deployments_artifact_download_duration_seconds_bucket{artifact_size="up_to_16MiB",le="10"} 0`)
+	assert.Contains(t, out,
+		`deployments_artifact_download_duration_seconds_bucket{artifact_size="up_to_16MiB",le="30"} 1`)
+	assert.Contains(t, out,
+		`deployments_artifact_download_duration_seconds_count{artifact_size="over_1GiB"} 1`)
+	assert.Contains(t, out,
+		`deployments_artifact_download_duration_seconds_sum{artifact_size="over_1GiB"} 3600`)
+	assert.Contains(t, out,
+		`deployments_artifact_download_throughput_bytes_per_second_bucket{artifact_size="up_to_16MiB",le="524288"} 1`)
+	assert.NotContains(t, out, "up_to_128MiB")
+	assert.NotContains(t, out, "up_to_1GiB")
+}
+
+func TestDeploymentModelUpdateDeviceDeploymentStatusDownloadMetrics(t *testing.T) {
+
+	speed := 1024.0 * 1024
+
+	testCases := map[string]struct {
+		InputTelemetry        *deployments.DeviceTelemetry
+		InputDeviceDeployment *deployments.DeviceDeployment
+		InputError            error
+
+		OutputObserved bool
+	}{
+		"observed": {
+			InputTelemetry: &deployments.DeviceTelemetry{DownloadSpeed: &speed},
+			InputDeviceDeployment: &deployments.DeviceDeployment{
+				Image: &images.SoftwareImage{Size: 60 * 1024 * 1024},
+			},
+
+			OutputObserved: true,
+		},
+		"no download speed": {
+			InputTelemetry: &deployments.DeviceTelemetry{},
+		},
+		"no artifact size": {
+			InputTelemetry: &deployments.DeviceTelemetry{DownloadSpeed: &speed},
+			InputDeviceDeployment: &deployments.DeviceDeployment{
+				Image: &images.SoftwareImage{},
+			},
+		},
+		"storage error": {
+			InputTelemetry: &deployments.DeviceTelemetry{DownloadSpeed: &speed},
+			InputError:     errors.New("storage issue"),
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+
+			deviceDeploymentStorage := new(mocks.DeviceDeploymentStorage)
+			deviceDeploymentStorage.On("GetDeviceDeploymentStatus",
+				h.ContextMatcher(), validUUIDv4, "device-1").
+				Return(deployments.DeviceDeploymentStatusDownloading, nil)
+			deviceDeploymentStorage.On("UpdateDeviceDeploymentStatus",
+				h.ContextMatcher(), "device-1", validUUIDv4,
+				mock.AnythingOfType("deployments.DeviceDeploymentStatus")).
+				Return(deployments.DeviceDeploymentStatusDownloading, nil)
+			deviceDeploymentStorage.On("FindLatestDeploymentForDeviceID",
+				h.ContextMatcher(), "device-1", []string{validUUIDv4}).
+				Return(testCase.InputDeviceDeployment, testCase.InputError)
+
+			deploymentStorage := new(mocks.DeploymentsStorage)
+			deploymentStorage.On("UpdateStats", h.ContextMatcher(), validUUIDv4,
+				deployments.DeviceDeploymentStatusDownloading,
+				deployments.DeviceDeploymentStatusInstalling).
+				Return(nil)
+			deploymentStorage.On("FindByID", h.ContextMatcher(), validUUIDv4).
+				Return(&deployments.Deployment{
+					Stats: deployments.Stats{
+						deployments.DeviceDeploymentStatusInstalling: 1,
+					},
+				}, nil)
+
+			downloadMetrics := NewDownloadMetrics()
+			model := NewDeploymentModel(DeploymentsModelConfig{
+				DeploymentsStorage:       deploymentStorage,
+				DeviceDeploymentsStorage: deviceDeploymentStorage,
+				DownloadMetrics:          downloadMetrics,
+			})
+
+			err := model.UpdateDeviceDeploymentStatus(context.Background(),
+				validUUIDv4, "device-1",
+				deployments.DeviceDeploymentStatus{
+					Status:    deployments.DeviceDeploymentStatusInstalling,
+					Telemetry: testCase.InputTelemetry,
+				})
+			assert.NoError(t, err)
+
+			var b bytes.Buffer
+			assert.NoError(t, metrics.WriteOpenMetrics(&b, downloadMetrics.Families()...))
+			if testCase.OutputObserved {
+				assert.Contains(t, b.String(),
+					`deployments_artifact_download_duration_seconds_sum{artifact_size="up_to_128MiB"} 60`)
+			} else {
+				assert.NotContains(t, b.String(), "_count")
+			}
+		})
+	}
+}
@@ -0,0 +1,140 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mendersoftware/go-lib-micro/identity"
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/deployments/resources/deployments"
+	"github.com/mendersoftware/deployments/resources/deployments/controller"
+	"github.com/mendersoftware/deployments/resources/images"
+)
+
+// DefaultDownloadRedirectExpire is the validity of storage links the download
+// proxy redirects devices to. Interrupted downloads are resumed through the
+// proxy, so the links only have to be valid for the download to start.
+const DefaultDownloadRedirectExpire = 5 * time.Minute
+
+// DownloadProxy issues device download links pointing to the service instead
+// of the file storage. Links are signed, and the device is redirected to the
+// storage only if the link of the device was not revoked.
+type DownloadProxy struct {
+	// Address of the devices API as seen by devices
+	URL string
+	// Key the links are signed with
+	Secret []byte
+}
+
+func (p *DownloadProxy) signature(tenant, deploymentID, deviceID string,
+	expire int64) string {
+
+	mac := hmac.New(sha256.New, p.Secret)
+	fmt.Fprintf(mac, "%s\n%s\n%s\n%d", tenant, deploymentID, deviceID, expire)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// link returns signed download link of the device deployment.
+func (p *DownloadProxy) link(ctx context.Context, deploymentID, deviceID string,
+	expire time.Time) *images.Link {
+
+	var tenant string
+	if id := identity.FromContext(ctx); id != nil {
+		tenant = id.Tenant
+	}
+
+	query := url.Values{}
+	if tenant != "" {
+		query.Set("tenant_id", tenant)
+	}
+	query.Set("expire", strconv.FormatInt(expire.Unix(), 10))
+	query.Set("signature", p.signature(tenant, deploymentID, deviceID, expire.Unix()))
+
+	uri := strings.TrimSuffix(p.URL, "/") + "/download/" +
+		url.PathEscape(deploymentID) + "/" + url.PathEscape(deviceID) +
+		"?" + query.Encode()
+
+	return images.NewLink(uri, expire)
+}
+
+// verify checks the link was signed by the proxy and did not expire.
+func (p *DownloadProxy) verify(ctx context.Context, deploymentID, deviceID string,
+	expire int64, signature string) bool {
+
+	if time.Now().Unix() > expire {
+		return false
+	}
+
+	var tenant string
+	if id := identity.FromContext(ctx); id != nil {
+		tenant = id.Tenant
+	}
+
+	expected := p.signature(tenant, deploymentID, deviceID, expire)
+	return hmac.Equal([]byte(expected), []byte(signature))
+}
+
+// GetDeviceDeploymentDownload verifies download link issued to the device by
+// the download proxy and returns short lived storage link of the artifact to
+// redirect the device to. Links of device deployments which were revoked or
+// are finished are refused.
+func (d *DeploymentsModel) GetDeviceDeploymentDownload(ctx context.Context,
+	deploymentID, deviceID string, expire int64, signature string) (*images.Link, error) {
+
+	if d.downloadProxy == nil {
+		return nil, controller.ErrDownloadProxyDisabled
+	}
+
+	if !d.downloadProxy.verify(ctx, deploymentID, deviceID, expire, signature) {
+		return nil, controller.ErrDownloadLinkInvalid
+	}
+
+	deviceDeployment, err := d.deviceDeploymentsStorage.FindLatestDeploymentForDeviceID(ctx,
+		deviceID, deploymentID)
+	if err != nil {
+		return nil, errors.Wrap(err, "Searching for device deployment")
+	}
+	if deviceDeployment == nil || deviceDeployment.Status == nil ||
+		deviceDeployment.Image == nil {
+		return nil, controller.ErrStorageNotFound
+	}
+
+	if deviceDeployment.LinkRevoked ||
+		deployments.IsDeviceDeploymentStatusFinished(*deviceDeployment.Status) {
+		return nil, controller.ErrDownloadLinkRevoked
+	}
+
+	link, err := d.imageLinker.GetRequest(ctx, deviceDeployment.Image.FileID(),
+		DefaultDownloadRedirectExpire, d.imageContentType)
+	if err != nil {
+		return nil, errors.Wrap(err, "Generating download link for the device")
+	}
+
+	if d.linkRewriter != nil {
+		return d.linkRewriter.RewriteLink(ctx, link)
+	}
+
+	return link, nil
+}
@@ -0,0 +1,260 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model_test
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mendersoftware/go-lib-micro/identity"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/mendersoftware/deployments/resources/deployments"
+	"github.com/mendersoftware/deployments/resources/deployments/controller"
+	. "github.com/mendersoftware/deployments/resources/deployments/model"
+	"github.com/mendersoftware/deployments/resources/deployments/model/mocks"
+	"github.com/mendersoftware/deployments/resources/images"
+	. "github.com/mendersoftware/deployments/utils/pointers"
+	h "github.com/mendersoftware/deployments/utils/testing"
+)
+
+func TestDeploymentModelGetDeviceDeploymentDownload(t *testing.T) {
+
+	proxy := &DownloadProxy{
+		URL:    "https://example.com/api/devices/v1/deployments/",
+		Secret: []byte("secret"),
+	}
+	tenantCtx := identity.WithContext(context.Background(),
+		&identity.Identity{Tenant: "tenant-1"})
+
+	artifact := images.NewSoftwareImage(
+		validUUIDv4,
+		&images.SoftwareImageMetaConstructor{},
+		&images.SoftwareImageMetaArtifactConstructor{Name: "App 123"})
+
+	testCases := map[string]struct {
+		InputLink             *images.Link
+		InputCtx              context.Context
+		InputProxyDisabled    bool
+		InputDeviceDeployment *deployments.DeviceDeployment
+		InputFindError        error
+
+		OutputError error
+	}{
+		"ok": {
+			InputLink: proxy.Link(tenantCtx, validUUIDv4, "device-1",
+				time.Now().Add(time.Hour)),
+			InputCtx: tenantCtx,
+			InputDeviceDeployment: &deployments.DeviceDeployment{
+				Status: StringToPointer(deployments.DeviceDeploymentStatusDownloading),
+				Image:  artifact,
+			},
+		},
+		"revoked": {
+			InputLink: proxy.Link(tenantCtx, validUUIDv4, "device-1",
+				time.Now().Add(time.Hour)),
+			InputCtx: tenantCtx,
+			InputDeviceDeployment: &deployments.DeviceDeployment{
+				Status:      StringToPointer(deployments.DeviceDeploymentStatusDownloading),
+				Image:       artifact,
+				LinkRevoked: true,
+			},
+
+			OutputError: controller.ErrDownloadLinkRevoked,
+		},
+		"finished": {
+			InputLink: proxy.Link(tenantCtx, validUUIDv4, "device-1",
+				time.Now().Add(time.Hour)),
+			InputCtx: tenantCtx,
+			InputDeviceDeployment: &deployments.DeviceDeployment{
+				Status: StringToPointer(deployments.DeviceDeploymentStatusAborted),
+				Image:  artifact,
+			},
+
+			OutputError: controller.ErrDownloadLinkRevoked,
+		},
+		"expired": {
+			InputLink: proxy.Link(tenantCtx, validUUIDv4, "device-1",
+				time.Now().Add(-time.Minute)),
+			InputCtx: tenantCtx,
+
+			OutputError: controller.ErrDownloadLinkInvalid,
+		},
+		"other tenant": {
+			InputLink: proxy.Link(tenantCtx, validUUIDv4, "device-1",
+				time.Now().Add(time.Hour)),
+			InputCtx: identity.WithContext(context.Background(),
+				&identity.Identity{Tenant: "tenant-2"}),
+
+			OutputError: controller.ErrDownloadLinkInvalid,
+		},
+		"other signing key": {
+			InputLink: (&DownloadProxy{URL: proxy.URL, Secret: []byte("other")}).Link(
+				tenantCtx, validUUIDv4, "device-1", time.Now().Add(time.Hour)),
+			InputCtx: tenantCtx,
+
+			OutputError: controller.ErrDownloadLinkInvalid,
+		},
+		"not found": {
+			InputLink: proxy.Link(tenantCtx, validUUIDv4, "device-1",
+				time.Now().Add(time.Hour)),
+			InputCtx: tenantCtx,
+
+			OutputError: controller.ErrStorageNotFound,
+		},
+		"search error": {
+			InputLink: proxy.Link(tenantCtx, validUUIDv4, "device-1",
+				time.Now().Add(time.Hour)),
+			InputCtx:       tenantCtx,
+			InputFindError: errors.New("db error"),
+
+			OutputError: errors.New("Searching for device deployment: db error"),
+		},
+		"proxy disabled": {
+			InputLink: proxy.Link(tenantCtx, validUUIDv4, "device-1",
+				time.Now().Add(time.Hour)),
+			InputCtx:           tenantCtx,
+			InputProxyDisabled: true,
+
+			OutputError: controller.ErrDownloadProxyDisabled,
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+
+			uri, err := url.Parse(testCase.InputLink.Uri)
+			assert.NoError(t, err)
+			assert.Equal(t, "/api/devices/v1/deployments/download/"+validUUIDv4+"/device-1",
+				uri.Path)
+			assert.Equal(t, "tenant-1", uri.Query().Get("tenant_id"))
+
+			deviceDeploymentStorage := new(mocks.DeviceDeploymentStorage)
+			deviceDeploymentStorage.On("FindLatestDeploymentForDeviceID",
+				h.ContextMatcher(), "device-1", []string{validUUIDv4}).
+				Return(testCase.InputDeviceDeployment, testCase.InputFindError)
+
+			link := &images.Link{Uri: "https://s3.example.com/artifact"}
+			imageLinker := new(mocks.GetRequester)
+			imageLinker.On("GetRequest", h.ContextMatcher(), validUUIDv4,
+				DefaultDownloadRedirectExpire, mock.AnythingOfType("string")).
+				Return(link, nil)
+
+			config := DeploymentsModelConfig{
+				DeviceDeploymentsStorage: deviceDeploymentStorage,
+				ImageLinker:              imageLinker,
+			}
+			if !testCase.InputProxyDisabled {
+				config.DownloadProxy = proxy
+			}
+			model := NewDeploymentModel(config)
+
+			query := uri.Query()
+			expire, err := strconv.ParseInt(query.Get("expire"), 10, 64)
+			assert.NoError(t, err)
+
+			out, err := model.GetDeviceDeploymentDownload(testCase.InputCtx,
+				validUUIDv4, "device-1", expire, query.Get("signature"))
+			if testCase.OutputError != nil {
+				assert.EqualError(t, err, testCase.OutputError.Error())
+				imageLinker.AssertNotCalled(t, "GetRequest",
+					mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, link, out)
+			}
+		})
+	}
+}
+
+func TestDeploymentModelGetDeploymentForDeviceDownloadProxy(t *testing.T) {
+
+	artifact := images.NewSoftwareImage(
+		validUUIDv4,
+		&images.SoftwareImageMetaConstructor{},
+		&images.SoftwareImageMetaArtifactConstructor{
+			Name:                  "App 123",
+			DeviceTypesCompatible: []string{"hammer"},
+		})
+	deploymentID := "b532b01a-9313-404f-8d19-e7fcbe5cc347"
+
+	deviceDeploymentStorage := new(mocks.DeviceDeploymentStorage)
+	deviceDeploymentStorage.On("FindOldestDeploymentForDeviceIDWithStatuses",
+		h.ContextMatcher(), "device-1", mock.AnythingOfType("[]string")).
+		Return(&deployments.DeviceDeployment{
+			DeviceId:     StringToPointer("device-1"),
+			DeploymentId: StringToPointer(deploymentID),
+		}, nil)
+	deviceDeploymentStorage.On("AssignArtifact",
+		h.ContextMatcher(), "device-1", deploymentID, artifact).
+		Return(nil)
+	deviceDeploymentStorage.On("IncrementDeviceDeploymentAttempts",
+		h.ContextMatcher(), "device-1", deploymentID).
+		Return(nil)
+
+	deploymentStorage := new(mocks.DeploymentsStorage)
+	deploymentStorage.On("FindByID",
+		h.ContextMatcher(), deploymentID).
+		Return(&deployments.Deployment{
+			Id:        StringToPointer(deploymentID),
+			Artifacts: []string{validUUIDv4},
+			DeploymentConstructor: &deployments.DeploymentConstructor{
+				ArtifactName: StringToPointer("App 123"),
+			},
+		}, nil)
+
+	artifactGetter := new(mocks.ArtifactGetter)
+	artifactGetter.On("ImageByIdsAndDeviceType",
+		h.ContextMatcher(), []string{validUUIDv4}, "hammer").
+		Return(artifact, nil)
+
+	imageLinker := new(mocks.GetRequester)
+
+	proxy := &DownloadProxy{
+		URL:    "https://example.com/api/devices/v1/deployments",
+		Secret: []byte("secret"),
+	}
+	model := NewDeploymentModel(DeploymentsModelConfig{
+		DeploymentsStorage:       deploymentStorage,
+		DeviceDeploymentsStorage: deviceDeploymentStorage,
+		ArtifactGetter:           artifactGetter,
+		ImageLinker:              imageLinker,
+		DownloadProxy:            proxy,
+	})
+
+	out, err := model.GetDeploymentForDeviceWithCurrent(context.Background(),
+		"device-1", deployments.InstalledDeviceDeployment{
+			Artifact:   "App 122",
+			DeviceType: "hammer",
+		})
+	assert.NoError(t, err)
+	if assert.NotNil(t, out) {
+		assert.True(t, strings.HasPrefix(out.Artifact.Source.Uri,
+			"https://example.com/api/devices/v1/deployments/download/"+
+				deploymentID+"/device-1?"))
+		assert.WithinDuration(t, time.Now().Add(DefaultUpdateDownloadLinkExpire),
+			out.Artifact.Source.Expire, time.Minute)
+	}
+
+	// storage links are generated by the proxy only
+	imageLinker.AssertNotCalled(t, "GetRequest",
+		mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
@@ -0,0 +1,137 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"context"
+
+	"github.com/mendersoftware/go-lib-micro/identity"
+	"github.com/mendersoftware/go-lib-micro/log"
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/deployments/resources/deployments"
+	"github.com/mendersoftware/deployments/resources/deployments/controller"
+	"github.com/mendersoftware/deployments/resources/images"
+)
+
+// EgressRecorder accounts artifact bytes served to the tenant.
+type EgressRecorder interface {
+	RecordEgress(ctx context.Context, bytes int64)
+}
+
+// recordDeviceDownload records download link issued to the device.
+// Failing to record does not prevent the device from being updated.
+func (d *DeploymentsModel) recordDeviceDownload(ctx context.Context,
+	deviceID, deploymentID string, artifact *images.SoftwareImage) {
+
+	if d.egress != nil {
+		d.egress.RecordEgress(ctx, artifact.Size)
+	}
+
+	if d.downloadsStorage == nil {
+		return
+	}
+
+	download := deployments.NewDownload(artifact.Id)
+	download.DeviceID = deviceID
+	download.DeploymentID = deploymentID
+
+	if err := d.downloadsStorage.InsertDownload(ctx, download); err != nil {
+		log.FromContext(ctx).Warnf("failed to record download of artifact %s by device %s: %v",
+			artifact.Id, deviceID, err)
+	}
+}
+
+// RecordArtifactDownload records download link of the artifact issued
+// to the user of the request.
+func (d *DeploymentsModel) RecordArtifactDownload(ctx context.Context, artifactID string) error {
+	if d.downloadsStorage == nil {
+		return nil
+	}
+
+	download := deployments.NewDownload(artifactID)
+	if id := identity.FromContext(ctx); id != nil {
+		download.UserID = id.Subject
+	}
+
+	if err := d.downloadsStorage.InsertDownload(ctx, download); err != nil {
+		return errors.Wrap(err, "Recording artifact download")
+	}
+
+	return nil
+}
+
+// RevokeDeviceDeploymentLink invalidates download link issued to the device
+// for the deployment and aborts the deployment for the device. The download
+// proxy refuses revoked links, including the ones issued before.
+func (d *DeploymentsModel) RevokeDeviceDeploymentLink(ctx context.Context,
+	deploymentID, deviceID string) error {
+
+	// presigned storage links can not be invalidated
+	if d.downloadProxy == nil {
+		return controller.ErrDownloadProxyDisabled
+	}
+
+	deviceDeployment, err := d.deviceDeploymentsStorage.FindLatestDeploymentForDeviceID(ctx,
+		deviceID, deploymentID)
+	if err != nil {
+		return errors.Wrap(err, "Searching for device deployment")
+	}
+	if deviceDeployment == nil || deviceDeployment.Status == nil {
+		return controller.ErrStorageNotFound
+	}
+
+	if err := d.deviceDeploymentsStorage.RevokeDeviceDeploymentLink(ctx,
+		deviceID, deploymentID); err != nil {
+		return errors.Wrap(err, "Revoking device download link")
+	}
+
+	if deployments.IsDeviceDeploymentStatusFinished(*deviceDeployment.Status) {
+		return nil
+	}
+
+	// deployment statistics are updated and the deployment finished along
+	// with the status; reports of the device are refused from now on
+	err = d.UpdateDeviceDeploymentStatus(ctx, deploymentID, deviceID,
+		deployments.DeviceDeploymentStatus{
+			Status: deployments.DeviceDeploymentStatusAborted,
+			Reason: deployments.NewLinkRevokedReason(),
+		})
+	if err != nil && err != controller.ErrDeploymentAborted {
+		return errors.Wrap(err, "Aborting device deployment")
+	}
+
+	return nil
+}
+
+// LookupDownloads finds artifact download records.
+func (d *DeploymentsModel) LookupDownloads(ctx context.Context,
+	query deployments.DownloadsQuery) ([]deployments.Download, error) {
+
+	if d.downloadsStorage == nil {
+		return make([]deployments.Download, 0), nil
+	}
+
+	list, err := d.downloadsStorage.FindDownloads(ctx, query)
+	if err != nil {
+		return nil, errors.Wrap(err, "searching for downloads")
+	}
+
+	if list == nil {
+		return make([]deployments.Download, 0), nil
+	}
+
+	return list, nil
+}
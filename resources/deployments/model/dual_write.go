@@ -0,0 +1,304 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"context"
+	"reflect"
+	"time"
+
+	"github.com/mendersoftware/go-lib-micro/log"
+
+	"github.com/mendersoftware/deployments/resources/deployments"
+)
+
+// DualWriteDeploymentsStorage helps migrating deployments to a new storage.
+// Writes go to both the primary and the shadow storage, reads are done from
+// both and any difference of the shadow results is logged, so that the new
+// storage can be validated against production traffic before the cutover.
+// Results and errors always come from the primary storage, failures of the
+// shadow storage are only logged.
+type DualWriteDeploymentsStorage struct {
+	primary DeploymentsStorage
+	shadow  DeploymentsStorage
+}
+
+func NewDualWriteDeploymentsStorage(primary,
+	shadow DeploymentsStorage) *DualWriteDeploymentsStorage {
+
+	return &DualWriteDeploymentsStorage{
+		primary: primary,
+		shadow:  shadow,
+	}
+}
+
+// shadowWrite repeats write in the shadow storage if it succeeded
+// in the primary one.
+func shadowWrite(ctx context.Context, method string, err error,
+	write func() error) {
+
+	if err != nil {
+		return
+	}
+	if err := write(); err != nil {
+		log.FromContext(ctx).Warnf("dual write: %s: shadow storage: %s",
+			method, err.Error())
+	}
+}
+
+// shadowCompare logs mismatch of the shadow and the primary read results.
+func shadowCompare(ctx context.Context, method string,
+	result interface{}, err error, shadowResult interface{}, shadowErr error) {
+
+	l := log.FromContext(ctx)
+	switch {
+	case err == nil && shadowErr != nil:
+		l.Warnf("dual write: %s: shadow storage: %s", method, shadowErr.Error())
+	case err != nil && shadowErr == nil:
+		l.Warnf("dual write: %s: shadow storage succeeded, primary failed: %s",
+			method, err.Error())
+	case err == nil && !reflect.DeepEqual(result, shadowResult):
+		l.Warnf("dual write: %s: shadow result mismatch: primary: %+v, shadow: %+v",
+			method, indirect(result), indirect(shadowResult))
+	}
+}
+
+func indirect(v interface{}) interface{} {
+	if rv := reflect.ValueOf(v); rv.Kind() == reflect.Ptr && !rv.IsNil() {
+		return rv.Elem().Interface()
+	}
+	return v
+}
+
+func (s *DualWriteDeploymentsStorage) Insert(ctx context.Context,
+	deployment *deployments.Deployment) error {
+
+	err := s.primary.Insert(ctx, deployment)
+	shadowWrite(ctx, "Insert", err, func() error {
+		return s.shadow.Insert(ctx, deployment)
+	})
+	return err
+}
+
+func (s *DualWriteDeploymentsStorage) Delete(ctx context.Context, id string) error {
+	err := s.primary.Delete(ctx, id)
+	shadowWrite(ctx, "Delete", err, func() error {
+		return s.shadow.Delete(ctx, id)
+	})
+	return err
+}
+
+func (s *DualWriteDeploymentsStorage) FindByID(ctx context.Context,
+	id string) (*deployments.Deployment, error) {
+
+	deployment, err := s.primary.FindByID(ctx, id)
+	shadow, shadowErr := s.shadow.FindByID(ctx, id)
+	shadowCompare(ctx, "FindByID", deployment, err, shadow, shadowErr)
+	return deployment, err
+}
+
+func (s *DualWriteDeploymentsStorage) FindBySlug(ctx context.Context,
+	slug string) (*deployments.Deployment, error) {
+
+	deployment, err := s.primary.FindBySlug(ctx, slug)
+	shadow, shadowErr := s.shadow.FindBySlug(ctx, slug)
+	shadowCompare(ctx, "FindBySlug", deployment, err, shadow, shadowErr)
+	return deployment, err
+}
+
+func (s *DualWriteDeploymentsStorage) FindUnfinishedByID(ctx context.Context,
+	id string) (*deployments.Deployment, error) {
+
+	deployment, err := s.primary.FindUnfinishedByID(ctx, id)
+	shadow, shadowErr := s.shadow.FindUnfinishedByID(ctx, id)
+	shadowCompare(ctx, "FindUnfinishedByID", deployment, err, shadow, shadowErr)
+	return deployment, err
+}
+
+func (s *DualWriteDeploymentsStorage) UpdateStats(ctx context.Context,
+	id string, stateFrom, stateTo string) error {
+
+	err := s.primary.UpdateStats(ctx, id, stateFrom, stateTo)
+	shadowWrite(ctx, "UpdateStats", err, func() error {
+		return s.shadow.UpdateStats(ctx, id, stateFrom, stateTo)
+	})
+	return err
+}
+
+func (s *DualWriteDeploymentsStorage) IncrementStats(ctx context.Context,
+	stats map[string]deployments.Stats) error {
+
+	err := s.primary.IncrementStats(ctx, stats)
+	shadowWrite(ctx, "IncrementStats", err, func() error {
+		return s.shadow.IncrementStats(ctx, stats)
+	})
+	return err
+}
+
+func (s *DualWriteDeploymentsStorage) UpdateStatsAndFinishDeployment(ctx context.Context,
+	id string, stats deployments.Stats) error {
+
+	err := s.primary.UpdateStatsAndFinishDeployment(ctx, id, stats)
+	shadowWrite(ctx, "UpdateStatsAndFinishDeployment", err, func() error {
+		return s.shadow.UpdateStatsAndFinishDeployment(ctx, id, stats)
+	})
+	return err
+}
+
+func (s *DualWriteDeploymentsStorage) Find(ctx context.Context,
+	query deployments.Query) ([]*deployments.Deployment, error) {
+
+	found, err := s.primary.Find(ctx, query)
+	shadow, shadowErr := s.shadow.Find(ctx, query)
+	shadowCompare(ctx, "Find", found, err, shadow, shadowErr)
+	return found, err
+}
+
+func (s *DualWriteDeploymentsStorage) Finish(ctx context.Context,
+	id string, when time.Time) error {
+
+	err := s.primary.Finish(ctx, id, when)
+	shadowWrite(ctx, "Finish", err, func() error {
+		return s.shadow.Finish(ctx, id, when)
+	})
+	return err
+}
+
+func (s *DualWriteDeploymentsStorage) Reopen(ctx context.Context, id string) error {
+	err := s.primary.Reopen(ctx, id)
+	shadowWrite(ctx, "Reopen", err, func() error {
+		return s.shadow.Reopen(ctx, id)
+	})
+	return err
+}
+
+func (s *DualWriteDeploymentsStorage) ExistUnfinishedByArtifactId(ctx context.Context,
+	id string) (bool, error) {
+
+	exists, err := s.primary.ExistUnfinishedByArtifactId(ctx, id)
+	shadow, shadowErr := s.shadow.ExistUnfinishedByArtifactId(ctx, id)
+	shadowCompare(ctx, "ExistUnfinishedByArtifactId", exists, err, shadow, shadowErr)
+	return exists, err
+}
+
+func (s *DualWriteDeploymentsStorage) ExistByArtifactId(ctx context.Context,
+	id string) (bool, error) {
+
+	exists, err := s.primary.ExistByArtifactId(ctx, id)
+	shadow, shadowErr := s.shadow.ExistByArtifactId(ctx, id)
+	shadowCompare(ctx, "ExistByArtifactId", exists, err, shadow, shadowErr)
+	return exists, err
+}
+
+func (s *DualWriteDeploymentsStorage) ExistByArtifactIdCreatedAfter(ctx context.Context,
+	id string, since time.Time) (bool, error) {
+
+	exists, err := s.primary.ExistByArtifactIdCreatedAfter(ctx, id, since)
+	shadow, shadowErr := s.shadow.ExistByArtifactIdCreatedAfter(ctx, id, since)
+	shadowCompare(ctx, "ExistByArtifactIdCreatedAfter", exists, err, shadow, shadowErr)
+	return exists, err
+}
+
+func (s *DualWriteDeploymentsStorage) DeviceCountByDeployment(ctx context.Context,
+	id string) (int, error) {
+
+	count, err := s.primary.DeviceCountByDeployment(ctx, id)
+	shadow, shadowErr := s.shadow.DeviceCountByDeployment(ctx, id)
+	shadowCompare(ctx, "DeviceCountByDeployment", count, err, shadow, shadowErr)
+	return count, err
+}
+
+// IncrementDownloadCount is a write returning the result, it is compared
+// same as reads.
+func (s *DualWriteDeploymentsStorage) IncrementDownloadCount(ctx context.Context,
+	id string, period time.Time) (int, error) {
+
+	count, err := s.primary.IncrementDownloadCount(ctx, id, period)
+	if err != nil {
+		return count, err
+	}
+	shadow, shadowErr := s.shadow.IncrementDownloadCount(ctx, id, period)
+	shadowCompare(ctx, "IncrementDownloadCount", count, err, shadow, shadowErr)
+	return count, err
+}
+
+func (s *DualWriteDeploymentsStorage) SetAbortInfo(ctx context.Context,
+	id string, abort *deployments.AbortInfo) error {
+
+	err := s.primary.SetAbortInfo(ctx, id, abort)
+	shadowWrite(ctx, "SetAbortInfo", err, func() error {
+		return s.shadow.SetAbortInfo(ctx, id, abort)
+	})
+	return err
+}
+
+func (s *DualWriteDeploymentsStorage) SetPaused(ctx context.Context,
+	ids []string, paused bool) error {
+
+	err := s.primary.SetPaused(ctx, ids, paused)
+	shadowWrite(ctx, "SetPaused", err, func() error {
+		return s.shadow.SetPaused(ctx, ids, paused)
+	})
+	return err
+}
+
+func (s *DualWriteDeploymentsStorage) FindWithStartedPhases(ctx context.Context,
+	now time.Time) ([]*deployments.Deployment, error) {
+
+	found, err := s.primary.FindWithStartedPhases(ctx, now)
+	shadow, shadowErr := s.shadow.FindWithStartedPhases(ctx, now)
+	shadowCompare(ctx, "FindWithStartedPhases", found, err, shadow, shadowErr)
+	return found, err
+}
+
+func (s *DualWriteDeploymentsStorage) ActivatePhase(ctx context.Context,
+	id string, phase int, when time.Time) (bool, error) {
+
+	activated, err := s.primary.ActivatePhase(ctx, id, phase, when)
+	if err != nil {
+		return activated, err
+	}
+	shadow, shadowErr := s.shadow.ActivatePhase(ctx, id, phase, when)
+	shadowCompare(ctx, "ActivatePhase", activated, err, shadow, shadowErr)
+	return activated, err
+}
+
+func (s *DualWriteDeploymentsStorage) CountByStatus(ctx context.Context,
+	status deployments.StatusQuery) (int, error) {
+
+	count, err := s.primary.CountByStatus(ctx, status)
+	shadow, shadowErr := s.shadow.CountByStatus(ctx, status)
+	shadowCompare(ctx, "CountByStatus", count, err, shadow, shadowErr)
+	return count, err
+}
+
+func (s *DualWriteDeploymentsStorage) IncrementStatsRollup(ctx context.Context,
+	when time.Time, status string) error {
+
+	err := s.primary.IncrementStatsRollup(ctx, when, status)
+	shadowWrite(ctx, "IncrementStatsRollup", err, func() error {
+		return s.shadow.IncrementStatsRollup(ctx, when, status)
+	})
+	return err
+}
+
+func (s *DualWriteDeploymentsStorage) AggregateStatsRollups(ctx context.Context,
+	since time.Time) (deployments.Stats, error) {
+
+	stats, err := s.primary.AggregateStatsRollups(ctx, since)
+	shadow, shadowErr := s.shadow.AggregateStatsRollups(ctx, since)
+	shadowCompare(ctx, "AggregateStatsRollups", stats, err, shadow, shadowErr)
+	return stats, err
+}
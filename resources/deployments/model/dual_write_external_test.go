@@ -0,0 +1,165 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/mendersoftware/go-lib-micro/log"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mendersoftware/deployments/resources/deployments"
+	. "github.com/mendersoftware/deployments/resources/deployments/model"
+	"github.com/mendersoftware/deployments/resources/deployments/model/mocks"
+	. "github.com/mendersoftware/deployments/utils/pointers"
+	h "github.com/mendersoftware/deployments/utils/testing"
+)
+
+func TestDualWriteDeploymentsStorageWrite(t *testing.T) {
+
+	testCases := map[string]struct {
+		PrimaryError error
+		ShadowError  error
+
+		ShadowCalled bool
+		Log          string
+	}{
+		"ok": {
+			ShadowCalled: true,
+		},
+		"primary error": {
+			PrimaryError: errors.New("primary failed"),
+		},
+		"shadow error": {
+			ShadowError:  errors.New("shadow failed"),
+			ShadowCalled: true,
+			Log:          "dual write: Delete: shadow storage: shadow failed",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			var out bytes.Buffer
+			ctx := log.WithContext(context.Background(),
+				log.NewFromLogger(&logrus.Logger{
+					Out:       &out,
+					Formatter: new(logrus.TextFormatter),
+					Level:     logrus.WarnLevel,
+				}, log.Ctx{}))
+
+			primary := new(mocks.DeploymentsStorage)
+			primary.On("Delete", h.ContextMatcher(), "foo").Return(tc.PrimaryError)
+			shadow := new(mocks.DeploymentsStorage)
+			shadow.On("Delete", h.ContextMatcher(), "foo").Return(tc.ShadowError)
+
+			err := NewDualWriteDeploymentsStorage(primary, shadow).Delete(ctx, "foo")
+			assert.Equal(t, tc.PrimaryError, err)
+
+			if tc.ShadowCalled {
+				shadow.AssertExpectations(t)
+			} else {
+				shadow.AssertNotCalled(t, "Delete", h.ContextMatcher(), "foo")
+			}
+			if tc.Log != "" {
+				assert.Contains(t, out.String(), tc.Log)
+			} else {
+				assert.Empty(t, out.String())
+			}
+		})
+	}
+}
+
+func TestDualWriteDeploymentsStorageRead(t *testing.T) {
+
+	deployment := func(name string) *deployments.Deployment {
+		return &deployments.Deployment{
+			Id: StringToPointer("foo"),
+			DeploymentConstructor: &deployments.DeploymentConstructor{
+				Name: &name,
+			},
+		}
+	}
+
+	testCases := map[string]struct {
+		Primary      *deployments.Deployment
+		PrimaryError error
+		Shadow       *deployments.Deployment
+		ShadowError  error
+
+		Log string
+	}{
+		"ok": {
+			Primary: deployment("bar"),
+			Shadow:  deployment("bar"),
+		},
+		"ok, both not found": {},
+		"ok, both failed": {
+			PrimaryError: errors.New("primary failed"),
+			ShadowError:  errors.New("shadow failed"),
+		},
+		"mismatch": {
+			Primary: deployment("bar"),
+			Shadow:  deployment("baz"),
+			Log:     "dual write: FindByID: shadow result mismatch",
+		},
+		"mismatch, missing in shadow": {
+			Primary: deployment("bar"),
+			Log:     "dual write: FindByID: shadow result mismatch",
+		},
+		"shadow error": {
+			Primary:     deployment("bar"),
+			ShadowError: errors.New("shadow failed"),
+			Log:         "dual write: FindByID: shadow storage: shadow failed",
+		},
+		"primary error": {
+			PrimaryError: errors.New("primary failed"),
+			Shadow:       deployment("bar"),
+			Log:          "dual write: FindByID: shadow storage succeeded, primary failed: primary failed",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			var out bytes.Buffer
+			ctx := log.WithContext(context.Background(),
+				log.NewFromLogger(&logrus.Logger{
+					Out:       &out,
+					Formatter: new(logrus.TextFormatter),
+					Level:     logrus.WarnLevel,
+				}, log.Ctx{}))
+
+			primary := new(mocks.DeploymentsStorage)
+			primary.On("FindByID", h.ContextMatcher(), "foo").
+				Return(tc.Primary, tc.PrimaryError)
+			shadow := new(mocks.DeploymentsStorage)
+			shadow.On("FindByID", h.ContextMatcher(), "foo").
+				Return(tc.Shadow, tc.ShadowError)
+
+			found, err := NewDualWriteDeploymentsStorage(primary, shadow).FindByID(ctx, "foo")
+			assert.Equal(t, tc.PrimaryError, err)
+			assert.Equal(t, tc.Primary, found)
+
+			if tc.Log != "" {
+				assert.Contains(t, out.String(), tc.Log)
+			} else {
+				assert.Empty(t, out.String())
+			}
+		})
+	}
+}
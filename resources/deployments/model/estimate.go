@@ -0,0 +1,86 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/deployments/resources/deployments"
+	"github.com/mendersoftware/deployments/resources/images"
+)
+
+// DeploymentEstimateHistory is how far back successful device deployments
+// are considered when predicting duration of deployments.
+const DeploymentEstimateHistory = 30 * 24 * time.Hour
+
+// EstimateDeployment predicts bytes downloaded by devices of the deployment
+// and time devices take to finish it. Devices of different device types may
+// get different artifacts, so download size is estimated with the largest
+// payload. Returns nil if the deployment does not exist.
+func (d *DeploymentsModel) EstimateDeployment(ctx context.Context,
+	deploymentID string) (*deployments.DeploymentEstimate, error) {
+
+	deployment, err := d.deploymentsStorage.FindByID(ctx, deploymentID)
+	if err != nil {
+		return nil, errors.Wrap(err, "Searching for deployment by ID")
+	}
+
+	if deployment == nil {
+		return nil, nil
+	}
+
+	estimate := &deployments.DeploymentEstimate{}
+	for _, count := range deployment.Stats {
+		estimate.Devices += count
+	}
+
+	for _, id := range deployment.Artifacts {
+		artifact, err := d.artifactGetter.FindByID(ctx, id)
+		if err != nil {
+			return nil, errors.Wrap(err, "Searching for artifact")
+		}
+		if artifact == nil {
+			continue
+		}
+		if size := payloadSize(artifact); size > estimate.ArtifactSize {
+			estimate.ArtifactSize = size
+		}
+	}
+	estimate.DownloadBytes = estimate.ArtifactSize * int64(estimate.Devices)
+
+	duration, samples, err := d.deviceDeploymentsStorage.AverageDeviceDeploymentDuration(ctx,
+		time.Now().Add(-DeploymentEstimateHistory))
+	if err != nil {
+		return nil, errors.Wrap(err, "Computing device deployment durations")
+	}
+	estimate.Duration = int64(duration / time.Second)
+	estimate.DurationSamples = samples
+
+	return estimate, nil
+}
+
+// payloadSize sums sizes of all update files of the artifact.
+func payloadSize(artifact *images.SoftwareImage) int64 {
+	var size int64
+	for _, update := range artifact.Updates {
+		for _, file := range update.Files {
+			size += file.Size
+		}
+	}
+	return size
+}
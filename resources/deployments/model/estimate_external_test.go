@@ -0,0 +1,154 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/mendersoftware/deployments/resources/deployments"
+	. "github.com/mendersoftware/deployments/resources/deployments/model"
+	"github.com/mendersoftware/deployments/resources/deployments/model/mocks"
+	"github.com/mendersoftware/deployments/resources/images"
+	. "github.com/mendersoftware/deployments/utils/pointers"
+	h "github.com/mendersoftware/deployments/utils/testing"
+)
+
+func TestDeploymentModelEstimateDeployment(t *testing.T) {
+
+	artifact := func(id string, sizes ...int64) *images.SoftwareImage {
+		var files []images.UpdateFile
+		for _, size := range sizes {
+			files = append(files, images.UpdateFile{Name: "rootfs", Size: size})
+		}
+		return images.NewSoftwareImage(id,
+			&images.SoftwareImageMetaConstructor{},
+			&images.SoftwareImageMetaArtifactConstructor{
+				Name:    "App 123",
+				Updates: []images.Update{{Files: files}},
+			})
+	}
+
+	testCases := map[string]struct {
+		InputDeployment      *deployments.Deployment
+		InputDeploymentError error
+		InputArtifacts       map[string]*images.SoftwareImage
+		InputDuration        time.Duration
+		InputSamples         int
+		InputDurationError   error
+
+		OutputEstimate *deployments.DeploymentEstimate
+		OutputError    string
+	}{
+		"ok": {
+			InputDeployment: &deployments.Deployment{
+				Id:        StringToPointer("d1"),
+				Artifacts: []string{"a1", "a2", "a3"},
+				Stats: map[string]int{
+					deployments.DeviceDeploymentStatusPending: 8,
+					deployments.DeviceDeploymentStatusSuccess: 2,
+				},
+			},
+			InputArtifacts: map[string]*images.SoftwareImage{
+				"a1": artifact("a1", 100, 50),
+				"a2": artifact("a2", 300),
+			},
+			InputDuration: 10*time.Minute + 500*time.Millisecond,
+			InputSamples:  42,
+
+			OutputEstimate: &deployments.DeploymentEstimate{
+				Devices:         10,
+				ArtifactSize:    300,
+				DownloadBytes:   3000,
+				Duration:        600,
+				DurationSamples: 42,
+			},
+		},
+		"ok, no history": {
+			InputDeployment: &deployments.Deployment{
+				Id:        StringToPointer("d1"),
+				Artifacts: []string{"a1"},
+				Stats: map[string]int{
+					deployments.DeviceDeploymentStatusPending: 2,
+				},
+			},
+			InputArtifacts: map[string]*images.SoftwareImage{
+				"a1": artifact("a1", 100),
+			},
+
+			OutputEstimate: &deployments.DeploymentEstimate{
+				Devices:       2,
+				ArtifactSize:  100,
+				DownloadBytes: 200,
+			},
+		},
+		"not found": {},
+		"deployment storage error": {
+			InputDeploymentError: errors.New("storage issue"),
+
+			OutputError: "Searching for deployment by ID: storage issue",
+		},
+		"duration storage error": {
+			InputDeployment: &deployments.Deployment{
+				Id:    StringToPointer("d1"),
+				Stats: map[string]int{},
+			},
+			InputDurationError: errors.New("storage issue"),
+
+			OutputError: "Computing device deployment durations: storage issue",
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+
+			deploymentStorage := new(mocks.DeploymentsStorage)
+			deploymentStorage.On("FindByID", h.ContextMatcher(), "d1").
+				Return(testCase.InputDeployment, testCase.InputDeploymentError)
+
+			artifactGetter := new(mocks.ArtifactGetter)
+			artifactGetter.On("FindByID", h.ContextMatcher(), mock.AnythingOfType("string")).
+				Return(func(_ context.Context, id string) *images.SoftwareImage {
+					return testCase.InputArtifacts[id]
+				}, nil)
+
+			deviceDeploymentStorage := new(mocks.DeviceDeploymentStorage)
+			deviceDeploymentStorage.On("AverageDeviceDeploymentDuration",
+				h.ContextMatcher(), mock.AnythingOfType("time.Time")).
+				Return(testCase.InputDuration, testCase.InputSamples,
+					testCase.InputDurationError)
+
+			model := NewDeploymentModel(DeploymentsModelConfig{
+				DeploymentsStorage:       deploymentStorage,
+				DeviceDeploymentsStorage: deviceDeploymentStorage,
+				ArtifactGetter:           artifactGetter,
+			})
+
+			estimate, err := model.EstimateDeployment(context.Background(), "d1")
+			if testCase.OutputError != "" {
+				assert.EqualError(t, err, testCase.OutputError)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, testCase.OutputEstimate, estimate)
+		})
+	}
+}
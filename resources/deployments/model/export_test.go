@@ -0,0 +1,56 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"context"
+	"time"
+
+	"github.com/mendersoftware/deployments/resources/deployments"
+	"github.com/mendersoftware/deployments/resources/images"
+)
+
+// WriteStatusBatch writes the updates as a single batch and returns the
+// replaced status and the error of each of them.
+func WriteStatusBatch(ctx context.Context,
+	deviceDeployments DeviceDeploymentStorage, deploymentsStorage DeploymentsStorage,
+	updates []deployments.DeviceDeploymentStatusUpdate) ([]string, []error) {
+
+	b := newStatusBatcher(0, len(updates), deviceDeployments, deploymentsStorage)
+	batch := &statusBatch{ctx: ctx}
+	for _, update := range updates {
+		batch.items = append(batch.items, &statusBatchItem{
+			update: update,
+			done:   make(chan error, 1),
+		})
+	}
+
+	b.write(batch)
+
+	from := make([]string, len(updates))
+	errs := make([]error, len(updates))
+	for i, item := range batch.items {
+		from[i] = item.update.From
+		errs[i] = <-item.done
+	}
+	return from, errs
+}
+
+// Link returns signed download link of the device deployment.
+func (p *DownloadProxy) Link(ctx context.Context, deploymentID, deviceID string,
+	expire time.Time) *images.Link {
+
+	return p.link(ctx, deploymentID, deviceID, expire)
+}
@@ -0,0 +1,95 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/deployments/resources/deployments"
+	"github.com/mendersoftware/deployments/resources/deployments/controller"
+)
+
+// ResolveDeviceExternalID finds the device with the external ID set as its
+// configured inventory attribute. Returns empty ID if there is no such device.
+func (d *DeploymentsModel) ResolveDeviceExternalID(ctx context.Context,
+	externalID string) (string, error) {
+
+	if d.deviceExternalIDAttribute == "" {
+		return "", controller.ErrExternalIDDisabled
+	}
+
+	if externalID == "" {
+		return "", controller.ErrModelMissingInput
+	}
+
+	ids, err := d.inventory.FindDevicesByAttribute(ctx,
+		d.deviceExternalIDAttribute, externalID)
+	if err != nil {
+		return "", errors.Wrap(err, "Searching for devices in inventory")
+	}
+
+	switch len(ids) {
+	case 0:
+		return "", nil
+	case 1:
+		return ids[0].String(), nil
+	default:
+		return "", controller.ErrExternalIDAmbiguous
+	}
+}
+
+// resolveExternalDevices adds devices identified by external IDs to
+// devices of the deployment. External IDs which do not match exactly one
+// device are reported as validation error.
+func (d *DeploymentsModel) resolveExternalDevices(ctx context.Context,
+	constructor *deployments.DeploymentConstructor) error {
+
+	if len(constructor.ExternalDevices) == 0 {
+		return nil
+	}
+
+	if d.deviceExternalIDAttribute == "" {
+		return deployments.NewValidationError("external_devices",
+			deployments.ValidationCodeInvalid, controller.ErrExternalIDDisabled.Error())
+	}
+
+	devices := make(map[string]bool, len(constructor.Devices))
+	for _, id := range constructor.Devices {
+		devices[id] = true
+	}
+
+	verr := &deployments.ValidationError{}
+	for i, externalID := range constructor.ExternalDevices {
+		field := fmt.Sprintf("external_devices[%d]", i)
+
+		id, err := d.ResolveDeviceExternalID(ctx, externalID)
+		switch {
+		case err == controller.ErrExternalIDAmbiguous:
+			verr.Add(field, deployments.ValidationCodeInvalid, err.Error())
+		case err != nil:
+			return errors.Wrapf(err, "Resolving external device ID %s", externalID)
+		case id == "":
+			verr.Add(field, deployments.ValidationCodeNotFound, "device not found")
+		case !devices[id]:
+			devices[id] = true
+			constructor.Devices = append(constructor.Devices, id)
+		}
+	}
+
+	return verr.ErrorOrNil()
+}
@@ -0,0 +1,232 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/mendersoftware/deployments/integration"
+	"github.com/mendersoftware/deployments/resources/deployments"
+	"github.com/mendersoftware/deployments/resources/deployments/controller"
+	. "github.com/mendersoftware/deployments/resources/deployments/model"
+	"github.com/mendersoftware/deployments/resources/deployments/model/mocks"
+	"github.com/mendersoftware/deployments/resources/images"
+	. "github.com/mendersoftware/deployments/utils/pointers"
+	h "github.com/mendersoftware/deployments/utils/testing"
+)
+
+func TestDeploymentModelResolveDeviceExternalID(t *testing.T) {
+
+	testCases := map[string]struct {
+		InputAttribute  string
+		InputExternalID string
+		InventoryIDs    []integration.DeviceID
+		InventoryError  error
+
+		OutputID    string
+		OutputError error
+	}{
+		"ok": {
+			InputAttribute:  "serial_no",
+			InputExternalID: "SN123",
+			InventoryIDs:    []integration.DeviceID{"foo"},
+
+			OutputID: "foo",
+		},
+		"not found": {
+			InputAttribute:  "serial_no",
+			InputExternalID: "SN123",
+			InventoryIDs:    []integration.DeviceID{},
+		},
+		"ambiguous": {
+			InputAttribute:  "serial_no",
+			InputExternalID: "SN123",
+			InventoryIDs:    []integration.DeviceID{"foo", "bar"},
+
+			OutputError: controller.ErrExternalIDAmbiguous,
+		},
+		"disabled": {
+			InputExternalID: "SN123",
+
+			OutputError: controller.ErrExternalIDDisabled,
+		},
+		"missing input": {
+			InputAttribute: "serial_no",
+
+			OutputError: controller.ErrModelMissingInput,
+		},
+		"inventory error": {
+			InputAttribute:  "serial_no",
+			InputExternalID: "SN123",
+			InventoryError:  errors.New("inventory unavailable"),
+
+			OutputError: errors.New("Searching for devices in inventory: inventory unavailable"),
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+
+			inventory := new(mocks.Inventory)
+			inventory.On("FindDevicesByAttribute",
+				h.ContextMatcher(), "serial_no", testCase.InputExternalID).
+				Return(testCase.InventoryIDs, testCase.InventoryError)
+
+			model := NewDeploymentModel(DeploymentsModelConfig{
+				Inventory:                 inventory,
+				DeviceExternalIDAttribute: testCase.InputAttribute,
+			})
+
+			id, err := model.ResolveDeviceExternalID(context.Background(),
+				testCase.InputExternalID)
+			if testCase.OutputError != nil {
+				assert.EqualError(t, err, testCase.OutputError.Error())
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, testCase.OutputID, id)
+		})
+	}
+}
+
+func TestDeploymentModelCreateDeploymentExternalDevices(t *testing.T) {
+
+	testCases := map[string]struct {
+		InputAttribute string
+		InputDevices   []string
+		InputExternal  []string
+
+		OutputDevices []string
+		OutputError   error
+	}{
+		"ok": {
+			InputAttribute: "serial_no",
+			InputDevices:   []string{"dev-1"},
+			InputExternal:  []string{"SN1", "SN2"},
+
+			OutputDevices: []string{"dev-1", "dev-2"},
+		},
+		"not found and ambiguous": {
+			InputAttribute: "serial_no",
+			InputExternal:  []string{"SN1", "SN3", "SN4"},
+
+			OutputError: &deployments.ValidationError{
+				Fields: []deployments.FieldError{
+					{
+						Field:   "external_devices[1]",
+						Code:    deployments.ValidationCodeNotFound,
+						Message: "device not found",
+					},
+					{
+						Field:   "external_devices[2]",
+						Code:    deployments.ValidationCodeInvalid,
+						Message: controller.ErrExternalIDAmbiguous.Error(),
+					},
+				},
+			},
+		},
+		"disabled": {
+			InputExternal: []string{"SN1"},
+
+			OutputError: deployments.NewValidationError("external_devices",
+				deployments.ValidationCodeInvalid, controller.ErrExternalIDDisabled.Error()),
+		},
+		"inventory error": {
+			InputAttribute: "serial_no",
+			InputExternal:  []string{"SN5"},
+
+			OutputError: errors.New("Resolving external device ID SN5: " +
+				"Searching for devices in inventory: inventory unavailable"),
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+
+			inventory := new(mocks.Inventory)
+			for externalID, ids := range map[string][]integration.DeviceID{
+				"SN1": {"dev-1"},
+				"SN2": {"dev-2"},
+				"SN3": {},
+				"SN4": {"dev-3", "dev-4"},
+			} {
+				inventory.On("FindDevicesByAttribute",
+					h.ContextMatcher(), "serial_no", externalID).
+					Return(ids, nil)
+			}
+			inventory.On("FindDevicesByAttribute",
+				h.ContextMatcher(), "serial_no", "SN5").
+				Return(nil, errors.New("inventory unavailable"))
+
+			deploymentStorage := new(mocks.DeploymentsStorage)
+			deploymentStorage.On("FindBySlug",
+				h.ContextMatcher(), mock.AnythingOfType("string")).
+				Return(nil, nil)
+			deploymentStorage.On("Insert",
+				h.ContextMatcher(),
+				mock.AnythingOfType("*deployments.Deployment")).
+				Return(nil)
+
+			deviceDeploymentStorage := new(mocks.DeviceDeploymentStorage)
+			deviceDeploymentStorage.On("InsertMany",
+				h.ContextMatcher(),
+				mock.AnythingOfType("[]*deployments.DeviceDeployment")).
+				Return(nil)
+
+			artifactGetter := new(mocks.ArtifactGetter)
+			artifactGetter.On("ImagesByName",
+				h.ContextMatcher(), "App 123").
+				Return([]*images.SoftwareImage{
+					images.NewSoftwareImage(
+						validUUIDv4,
+						&images.SoftwareImageMetaConstructor{},
+						&images.SoftwareImageMetaArtifactConstructor{
+							Name:                  "App 123",
+							DeviceTypesCompatible: []string{"hammer"},
+						}),
+				}, nil)
+
+			model := NewDeploymentModel(DeploymentsModelConfig{
+				DeploymentsStorage:        deploymentStorage,
+				DeviceDeploymentsStorage:  deviceDeploymentStorage,
+				ArtifactGetter:            artifactGetter,
+				Inventory:                 inventory,
+				DeviceExternalIDAttribute: testCase.InputAttribute,
+			})
+
+			constructor := &deployments.DeploymentConstructor{
+				Name:            StringToPointer("NYC Production"),
+				ArtifactName:    StringToPointer("App 123"),
+				Devices:         testCase.InputDevices,
+				ExternalDevices: testCase.InputExternal,
+			}
+			_, err := model.CreateDeployment(context.Background(), constructor)
+			if testCase.OutputError != nil {
+				assert.EqualError(t, err, testCase.OutputError.Error())
+				deploymentStorage.AssertNotCalled(t, "Insert", mock.Anything, mock.Anything)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, testCase.OutputDevices, constructor.Devices)
+		})
+	}
+}
@@ -0,0 +1,179 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/mendersoftware/go-lib-micro/log"
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/deployments/resources/deployments"
+)
+
+// JobTypeFinalizeDeployment is the background job running a finalization
+// hook of the deployment which reached a terminal state.
+const JobTypeFinalizeDeployment = "deployment_finalize"
+
+// FinalizeHook is invoked once the deployment reaches a terminal state,
+// either finished by all devices or aborted.
+type FinalizeHook interface {
+	Finalize(ctx context.Context, deployment *deployments.Deployment) error
+}
+
+// FinalizeHookFunc adapts function to the FinalizeHook interface.
+type FinalizeHookFunc func(ctx context.Context, deployment *deployments.Deployment) error
+
+func (f FinalizeHookFunc) Finalize(ctx context.Context,
+	deployment *deployments.Deployment) error {
+	return f(ctx, deployment)
+}
+
+type namedFinalizeHook struct {
+	name string
+	hook FinalizeHook
+}
+
+// finalizeJob is the payload of the finalization job
+type finalizeJob struct {
+	DeploymentID string `json:"deployment_id"`
+	Hook         string `json:"hook"`
+}
+
+// RegisterFinalizeHook adds hook run for every deployment reaching
+// a terminal state. The name identifies the hook in background jobs, so it
+// has to be unique and stable across restarts. Hooks have to be registered
+// before the model serves requests.
+func (d *DeploymentsModel) RegisterFinalizeHook(name string, hook FinalizeHook) {
+	d.finalizeHooks = append(d.finalizeHooks, namedFinalizeHook{
+		name: name,
+		hook: hook,
+	})
+}
+
+func (d *DeploymentsModel) findFinalizeHook(name string) FinalizeHook {
+	for _, h := range d.finalizeHooks {
+		if h.name == name {
+			return h.hook
+		}
+	}
+	return nil
+}
+
+// finalizeDeployment runs finalization hooks of the deployment; each hook
+// is queued as a job of its own if the queue is configured, so that failed
+// hooks are retried independently, and run directly otherwise. Hooks are
+// best effort, failures do not affect the deployment.
+func (d *DeploymentsModel) finalizeDeployment(ctx context.Context, deploymentID string) {
+	l := log.FromContext(ctx)
+
+	for _, h := range d.finalizeHooks {
+		var err error
+		if d.jobs != nil {
+			err = d.jobs.Enqueue(ctx, JobTypeFinalizeDeployment, finalizeJob{
+				DeploymentID: deploymentID,
+				Hook:         h.name,
+			})
+		} else {
+			err = d.runFinalizeHook(ctx, h.hook, deploymentID)
+		}
+		if err != nil {
+			l.Warnf("failed to finalize deployment %s with %s hook: %v",
+				deploymentID, h.name, err)
+		}
+	}
+}
+
+func (d *DeploymentsModel) runFinalizeHook(ctx context.Context,
+	hook FinalizeHook, deploymentID string) error {
+
+	deployment, err := d.deploymentsStorage.FindByID(ctx, deploymentID)
+	if err != nil {
+		return errors.Wrap(err, "searching for deployment")
+	}
+	// deleted in the meantime, nothing to finalize
+	if deployment == nil {
+		return nil
+	}
+
+	return hook.Finalize(ctx, deployment)
+}
+
+func (d *DeploymentsModel) handleFinalizeJob(ctx context.Context,
+	payload json.RawMessage) error {
+
+	var job finalizeJob
+	if err := json.Unmarshal(payload, &job); err != nil {
+		return errors.Wrap(err, "decoding finalization job")
+	}
+
+	hook := d.findFinalizeHook(job.Hook)
+	if hook == nil {
+		return errors.Errorf("unknown finalization hook %s", job.Hook)
+	}
+
+	return d.runFinalizeHook(ctx, hook, job.DeploymentID)
+}
+
+// NewEventFinalizeHook publishes deployment.finished event of finalized
+// deployments, e.g. to the webhook.
+func NewEventFinalizeHook(publisher EventPublisher) FinalizeHook {
+	return FinalizeHookFunc(func(ctx context.Context,
+		deployment *deployments.Deployment) error {
+
+		event := deployments.NewEvent(deployments.EventDeploymentFinished,
+			*deployment.Id)
+		event.Status = deployments.DeploymentStatusFinished
+		if deployment.Abort != nil {
+			event.Status = deployments.DeviceDeploymentStatusAborted
+		}
+
+		return publisher.Publish(ctx, event)
+	})
+}
+
+// ArtifactLocker protects artifacts from deletion and edits.
+type ArtifactLocker interface {
+	LockImage(ctx context.Context, imageID string) error
+}
+
+// NewArtifactLockFinalizeHook locks artifacts of deployments which updated
+// at least one device, so that released artifacts are kept.
+func NewArtifactLockFinalizeHook(locker ArtifactLocker) FinalizeHook {
+	return FinalizeHookFunc(func(ctx context.Context,
+		deployment *deployments.Deployment) error {
+
+		if deployment.Stats[deployments.DeviceDeploymentStatusSuccess] == 0 {
+			return nil
+		}
+
+		for _, artifactID := range deployment.Artifacts {
+			if err := locker.LockImage(ctx, artifactID); err != nil {
+				return errors.Wrapf(err, "locking artifact %s", artifactID)
+			}
+		}
+		return nil
+	})
+}
+
+// NewStatsArchiveFinalizeHook archives final statistics of deployments.
+func NewStatsArchiveFinalizeHook(storage StatsArchiveStorage) FinalizeHook {
+	return FinalizeHookFunc(func(ctx context.Context,
+		deployment *deployments.Deployment) error {
+
+		return storage.ArchiveStats(ctx, deployments.NewArchivedStats(deployment))
+	})
+}
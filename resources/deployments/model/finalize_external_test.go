@@ -0,0 +1,275 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/mendersoftware/deployments/resources/deployments"
+	. "github.com/mendersoftware/deployments/resources/deployments/model"
+	"github.com/mendersoftware/deployments/resources/deployments/model/mocks"
+	. "github.com/mendersoftware/deployments/utils/pointers"
+	h "github.com/mendersoftware/deployments/utils/testing"
+)
+
+// recordingJobQueue keeps payloads of enqueued jobs
+type recordingJobQueue struct {
+	fakeJobQueue
+	enqueued []interface{}
+}
+
+func (q *recordingJobQueue) Enqueue(ctx context.Context,
+	jobType string, payload interface{}) error {
+	q.enqueued = append(q.enqueued, payload)
+	return nil
+}
+
+func abortableDeployment(deploymentID string) (*mocks.DeploymentsStorage,
+	*mocks.DeviceDeploymentStorage) {
+
+	deviceDeploymentStorage := new(mocks.DeviceDeploymentStorage)
+	deviceDeploymentStorage.On("AbortDeviceDeployments",
+		h.ContextMatcher(), deploymentID, mock.AnythingOfType("*deployments.AbortInfo")).
+		Return(nil)
+	deviceDeploymentStorage.On("AggregateDeviceDeploymentByStatus",
+		h.ContextMatcher(), deploymentID).
+		Return(deployments.Stats{deployments.DeviceDeploymentStatusAborted: 3}, nil)
+
+	deploymentStorage := new(mocks.DeploymentsStorage)
+	deploymentStorage.On("SetAbortInfo",
+		h.ContextMatcher(), deploymentID, mock.AnythingOfType("*deployments.AbortInfo")).
+		Return(nil)
+	deploymentStorage.On("UpdateStatsAndFinishDeployment",
+		h.ContextMatcher(), deploymentID, mock.AnythingOfType("deployments.Stats")).
+		Return(nil)
+
+	return deploymentStorage, deviceDeploymentStorage
+}
+
+func TestFinalizeDeployment(t *testing.T) {
+
+	deploymentID := "f826484e-1157-4109-af21-304e6d711561"
+	deployment := &deployments.Deployment{
+		Id:    StringToPointer(deploymentID),
+		Abort: &deployments.AbortInfo{},
+	}
+
+	deploymentStorage, deviceDeploymentStorage := abortableDeployment(deploymentID)
+	deploymentStorage.On("FindByID", h.ContextMatcher(), deploymentID).
+		Return(deployment, nil)
+
+	failing := new(mocks.FinalizeHook)
+	failing.On("Finalize", h.ContextMatcher(), deployment).
+		Return(errors.New("endpoint unavailable"))
+	archive := new(mocks.FinalizeHook)
+	archive.On("Finalize", h.ContextMatcher(), deployment).
+		Return(nil)
+
+	model := NewDeploymentModel(DeploymentsModelConfig{
+		DeploymentsStorage:       deploymentStorage,
+		DeviceDeploymentsStorage: deviceDeploymentStorage,
+	})
+	model.RegisterFinalizeHook("failing", failing)
+	model.RegisterFinalizeHook("archive", archive)
+
+	// failed hook does not fail the abort, nor the other hooks
+	assert.NoError(t, model.AbortDeployment(context.Background(), deploymentID, nil))
+	failing.AssertExpectations(t)
+	archive.AssertExpectations(t)
+}
+
+func TestFinalizeDeploymentJob(t *testing.T) {
+
+	deploymentID := "f826484e-1157-4109-af21-304e6d711561"
+	deployment := &deployments.Deployment{
+		Id:    StringToPointer(deploymentID),
+		Abort: &deployments.AbortInfo{},
+	}
+
+	deploymentStorage, deviceDeploymentStorage := abortableDeployment(deploymentID)
+	deploymentStorage.On("FindByID", h.ContextMatcher(), deploymentID).
+		Return(deployment, nil)
+	deploymentStorage.On("FindByID", h.ContextMatcher(), "deleted").
+		Return(nil, nil)
+
+	archive := new(mocks.FinalizeHook)
+	archive.On("Finalize", h.ContextMatcher(), deployment).
+		Return(nil).Once()
+
+	queue := &recordingJobQueue{
+		fakeJobQueue: fakeJobQueue{
+			handlers: map[string]func(ctx context.Context, payload json.RawMessage) error{},
+		},
+	}
+	model := NewDeploymentModel(DeploymentsModelConfig{
+		DeploymentsStorage:       deploymentStorage,
+		DeviceDeploymentsStorage: deviceDeploymentStorage,
+		Jobs:                     queue,
+	})
+	model.RegisterFinalizeHook("archive", archive)
+
+	// hooks are queued, not run directly
+	assert.NoError(t, model.AbortDeployment(context.Background(), deploymentID, nil))
+	archive.AssertNotCalled(t, "Finalize", mock.Anything, mock.Anything)
+	if assert.Len(t, queue.enqueued, 1) {
+		payload, err := json.Marshal(queue.enqueued[0])
+		assert.NoError(t, err)
+		assert.JSONEq(t,
+			`{"deployment_id":"f826484e-1157-4109-af21-304e6d711561","hook":"archive"}`,
+			string(payload))
+	}
+
+	handler := queue.handlers[JobTypeFinalizeDeployment]
+	if assert.NotNil(t, handler) {
+		assert.NoError(t, handler(context.Background(), json.RawMessage(
+			`{"deployment_id":"f826484e-1157-4109-af21-304e6d711561","hook":"archive"}`)))
+		// deployment deleted in the meantime
+		assert.NoError(t, handler(context.Background(), json.RawMessage(
+			`{"deployment_id":"deleted","hook":"archive"}`)))
+		assert.EqualError(t, handler(context.Background(), json.RawMessage(
+			`{"deployment_id":"f826484e-1157-4109-af21-304e6d711561","hook":"email"}`)),
+			"unknown finalization hook email")
+		assert.Error(t, handler(context.Background(), json.RawMessage(`[]`)))
+	}
+	archive.AssertExpectations(t)
+}
+
+func TestEventFinalizeHook(t *testing.T) {
+
+	testCases := map[string]struct {
+		abort *deployments.AbortInfo
+
+		status string
+	}{
+		"finished": {
+			status: deployments.DeploymentStatusFinished,
+		},
+		"aborted": {
+			abort:  &deployments.AbortInfo{Reason: "bricks devices"},
+			status: deployments.DeviceDeploymentStatusAborted,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			publisher := new(mocks.EventPublisher)
+			publisher.On("Publish", h.ContextMatcher(),
+				mock.MatchedBy(func(event *deployments.Event) bool {
+					return event.Type == deployments.EventDeploymentFinished &&
+						event.DeploymentID == "dep-1" &&
+						event.Status == tc.status
+				})).
+				Return(nil)
+
+			hook := NewEventFinalizeHook(publisher)
+			assert.NoError(t, hook.Finalize(context.Background(), &deployments.Deployment{
+				Id:    StringToPointer("dep-1"),
+				Abort: tc.abort,
+			}))
+			publisher.AssertExpectations(t)
+		})
+	}
+}
+
+func TestArtifactLockFinalizeHook(t *testing.T) {
+
+	testCases := map[string]struct {
+		stats     map[string]int
+		lockError error
+
+		locked []string
+		err    string
+	}{
+		"updated devices": {
+			stats: map[string]int{
+				deployments.DeviceDeploymentStatusSuccess: 1,
+				deployments.DeviceDeploymentStatusFailure: 1,
+			},
+			locked: []string{"artifact-1", "artifact-2"},
+		},
+		"no device updated": {
+			stats: map[string]int{
+				deployments.DeviceDeploymentStatusFailure: 2,
+			},
+		},
+		"lock error": {
+			stats: map[string]int{
+				deployments.DeviceDeploymentStatusSuccess: 1,
+			},
+			lockError: errors.New("storage issue"),
+			locked:    []string{"artifact-1"},
+			err:       "locking artifact artifact-1: storage issue",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			locker := new(mocks.ArtifactLocker)
+			locker.On("LockImage", h.ContextMatcher(), mock.AnythingOfType("string")).
+				Return(tc.lockError)
+
+			err := NewArtifactLockFinalizeHook(locker).Finalize(context.Background(),
+				&deployments.Deployment{
+					Id:        StringToPointer("dep-1"),
+					Artifacts: []string{"artifact-1", "artifact-2"},
+					Stats:     tc.stats,
+				})
+			if tc.err != "" {
+				assert.EqualError(t, err, tc.err)
+			} else {
+				assert.NoError(t, err)
+			}
+
+			locker.AssertNumberOfCalls(t, "LockImage", len(tc.locked))
+			for _, id := range tc.locked {
+				locker.AssertCalled(t, "LockImage", h.ContextMatcher(), id)
+			}
+		})
+	}
+}
+
+func TestStatsArchiveFinalizeHook(t *testing.T) {
+
+	deployment := &deployments.Deployment{
+		Id: StringToPointer("dep-1"),
+		DeploymentConstructor: &deployments.DeploymentConstructor{
+			Name:         StringToPointer("release 1"),
+			ArtifactName: StringToPointer("release-1"),
+		},
+		Stats: map[string]int{
+			deployments.DeviceDeploymentStatusSuccess: 3,
+		},
+	}
+
+	storage := new(mocks.StatsArchiveStorage)
+	storage.On("ArchiveStats", h.ContextMatcher(), &deployments.ArchivedStats{
+		DeploymentID: "dep-1",
+		Name:         "release 1",
+		ArtifactName: "release-1",
+		Stats: deployments.Stats{
+			deployments.DeviceDeploymentStatusSuccess: 3,
+		},
+	}).Return(nil)
+
+	assert.NoError(t, NewStatsArchiveFinalizeHook(storage).Finalize(context.Background(),
+		deployment))
+	storage.AssertExpectations(t)
+}
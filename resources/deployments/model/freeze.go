@@ -0,0 +1,93 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/deployments/resources/deployments"
+	"github.com/mendersoftware/deployments/resources/deployments/controller"
+)
+
+// activeFreezePeriod returns the tenant freeze period in effect, nil if
+// deployments are not frozen or freeze periods are not configured.
+func (d *DeploymentsModel) activeFreezePeriod(ctx context.Context) (*deployments.FreezePeriod, error) {
+	if d.freezePeriodsStorage == nil {
+		return nil, nil
+	}
+
+	return d.freezePeriodsStorage.FindActiveFreezePeriod(ctx, time.Now())
+}
+
+// checkFreeze returns ErrDeploymentFrozen if a freeze period is in effect.
+func (d *DeploymentsModel) checkFreeze(ctx context.Context) error {
+	period, err := d.activeFreezePeriod(ctx)
+	if err != nil {
+		return errors.Wrap(err, "Checking deployment freeze periods")
+	}
+
+	if period != nil {
+		return errors.Wrapf(controller.ErrDeploymentFrozen, "Freeze period %s in effect until %s",
+			period.Name, period.End.UTC().Format(time.RFC3339))
+	}
+
+	return nil
+}
+
+// CreateFreezePeriod stores new tenant freeze period.
+func (d *DeploymentsModel) CreateFreezePeriod(ctx context.Context,
+	constructor *deployments.FreezePeriodConstructor) (string, error) {
+
+	if constructor == nil {
+		return "", controller.ErrModelMissingInput
+	}
+
+	if err := constructor.Validate(); err != nil {
+		return "", errors.Wrap(err, "Validating freeze period")
+	}
+
+	period := deployments.NewFreezePeriodFromConstructor(constructor)
+	if err := d.freezePeriodsStorage.InsertFreezePeriod(ctx, period); err != nil {
+		return "", errors.Wrap(err, "Storing freeze period")
+	}
+
+	return period.Id, nil
+}
+
+// GetFreezePeriods lists tenant freeze periods.
+func (d *DeploymentsModel) GetFreezePeriods(ctx context.Context) ([]*deployments.FreezePeriod, error) {
+	periods, err := d.freezePeriodsStorage.FindFreezePeriods(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "Searching for freeze periods")
+	}
+
+	if periods == nil {
+		periods = []*deployments.FreezePeriod{}
+	}
+
+	return periods, nil
+}
+
+// DeleteFreezePeriod removes tenant freeze period.
+func (d *DeploymentsModel) DeleteFreezePeriod(ctx context.Context, id string) error {
+	if err := d.freezePeriodsStorage.DeleteFreezePeriod(ctx, id); err != nil {
+		return errors.Wrap(err, "Removing freeze period")
+	}
+
+	return nil
+}
@@ -0,0 +1,32 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"context"
+	"time"
+
+	"github.com/mendersoftware/deployments/resources/deployments"
+)
+
+// Tenant deployment freeze periods storage
+type FreezePeriodsStorage interface {
+	InsertFreezePeriod(ctx context.Context, period *deployments.FreezePeriod) error
+	FindFreezePeriods(ctx context.Context) ([]*deployments.FreezePeriod, error)
+	// FindActiveFreezePeriod returns freeze period covering given moment,
+	// nil if there is none
+	FindActiveFreezePeriod(ctx context.Context, at time.Time) (*deployments.FreezePeriod, error)
+	DeleteFreezePeriod(ctx context.Context, id string) error
+}
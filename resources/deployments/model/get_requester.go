@@ -26,3 +26,9 @@ type GetRequester interface {
 	GetRequest(ctx context.Context, objectId string,
 		duration time.Duration, responseContentType string) (*images.Link, error)
 }
+
+// LinkRewriter adjusts download links to the requesting device,
+// e.g. points them to the mirror closest to the device.
+type LinkRewriter interface {
+	RewriteLink(ctx context.Context, link *images.Link) (*images.Link, error)
+}
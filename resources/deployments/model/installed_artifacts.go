@@ -0,0 +1,108 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"context"
+
+	"github.com/mendersoftware/go-lib-micro/log"
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/deployments/resources/deployments"
+)
+
+// recordInstalledArtifact stores the artifact reported as installed by the
+// device, so that deployments can target devices by installed artifact.
+// Failure is only logged, update checks do not depend on it.
+func (d *DeploymentsModel) recordInstalledArtifact(ctx context.Context,
+	deviceID string, installed deployments.InstalledDeviceDeployment) {
+
+	if d.installedArtifactsStorage == nil || installed.Artifact == "" {
+		return
+	}
+
+	if err := d.installedArtifactsStorage.UpsertInstalledArtifact(ctx,
+		&deployments.InstalledArtifact{
+			DeviceID:     deviceID,
+			ArtifactName: installed.Artifact,
+			DeviceType:   installed.DeviceType,
+		}); err != nil {
+		log.FromContext(ctx).Warnf("failed to record installed artifact of device %s: %v",
+			deviceID, err)
+	}
+}
+
+// findInstalledArtifacts returns devices with artifact matching the filter
+// installed.
+func (d *DeploymentsModel) findInstalledArtifacts(ctx context.Context,
+	filter *deployments.InstalledArtifactFilter) ([]*deployments.InstalledArtifact, error) {
+
+	if d.installedArtifactsStorage == nil {
+		return nil, nil
+	}
+
+	if !filter.HasVersionRange() {
+		return d.installedArtifactsStorage.FindInstalledArtifactsByName(ctx, filter.Name)
+	}
+
+	candidates, err := d.installedArtifactsStorage.FindInstalledArtifactsByNamePrefix(ctx,
+		filter.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	var installed []*deployments.InstalledArtifact
+	for _, candidate := range candidates {
+		version, ok := d.versions.Parse(candidate.ArtifactName)
+		if ok && filter.InRange(version) {
+			installed = append(installed, candidate)
+		}
+	}
+
+	return installed, nil
+}
+
+// resolveInstalledArtifactDevices adds devices with matching artifact
+// installed to devices of the deployment.
+func (d *DeploymentsModel) resolveInstalledArtifactDevices(ctx context.Context,
+	constructor *deployments.DeploymentConstructor) error {
+
+	if constructor.InstalledArtifact == nil {
+		return nil
+	}
+
+	installed, err := d.findInstalledArtifacts(ctx, constructor.InstalledArtifact)
+	if err != nil {
+		return errors.Wrap(err, "Searching for devices with installed artifact")
+	}
+
+	if len(installed) == 0 {
+		return deployments.NewValidationError("installed_artifact",
+			deployments.ValidationCodeNotFound, deployments.ErrNoInstalledArtifact.Error())
+	}
+
+	devices := make(map[string]bool, len(constructor.Devices))
+	for _, id := range constructor.Devices {
+		devices[id] = true
+	}
+	for _, artifact := range installed {
+		if !devices[artifact.DeviceID] {
+			devices[artifact.DeviceID] = true
+			constructor.Devices = append(constructor.Devices, artifact.DeviceID)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,234 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/mendersoftware/deployments/resources/deployments"
+	. "github.com/mendersoftware/deployments/resources/deployments/model"
+	"github.com/mendersoftware/deployments/resources/deployments/model/mocks"
+	"github.com/mendersoftware/deployments/resources/images"
+	. "github.com/mendersoftware/deployments/utils/pointers"
+	h "github.com/mendersoftware/deployments/utils/testing"
+)
+
+func TestDeploymentModelRecordInstalledArtifact(t *testing.T) {
+
+	testCases := map[string]struct {
+		InputUpsertError error
+	}{
+		"ok": {},
+		"storage error": {
+			InputUpsertError: errors.New("storage issue"),
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+
+			deviceDeploymentStorage := new(mocks.DeviceDeploymentStorage)
+			deviceDeploymentStorage.On("FindOldestDeploymentForDeviceIDWithStatuses",
+				h.ContextMatcher(), "device-1", mock.Anything).
+				Return(nil, nil)
+
+			installedArtifactsStorage := new(mocks.InstalledArtifactsStorage)
+			installedArtifactsStorage.On("UpsertInstalledArtifact",
+				h.ContextMatcher(), &deployments.InstalledArtifact{
+					DeviceID:     "device-1",
+					ArtifactName: "app-1.2.0",
+					DeviceType:   "rpi4",
+				}).
+				Return(testCase.InputUpsertError)
+
+			model := NewDeploymentModel(DeploymentsModelConfig{
+				DeviceDeploymentsStorage:  deviceDeploymentStorage,
+				InstalledArtifactsStorage: installedArtifactsStorage,
+			})
+
+			// update check does not fail if the artifact is not recorded
+			out, err := model.GetDeploymentForDeviceWithCurrent(context.Background(),
+				"device-1", deployments.InstalledDeviceDeployment{
+					Artifact:   "app-1.2.0",
+					DeviceType: "rpi4",
+				})
+			assert.NoError(t, err)
+			assert.Nil(t, out)
+			installedArtifactsStorage.AssertExpectations(t)
+		})
+	}
+}
+
+func TestDeploymentModelCreateDeploymentInstalledArtifact(t *testing.T) {
+
+	installed := []*deployments.InstalledArtifact{
+		{DeviceID: "dev-1", ArtifactName: "app-1.1.9", DeviceType: "rpi4"},
+		{DeviceID: "dev-2", ArtifactName: "app-1.2.0-rc1", DeviceType: "rpi4"},
+		{DeviceID: "dev-3", ArtifactName: "app-1.2.0", DeviceType: "rpi4"},
+		{DeviceID: "dev-4", ArtifactName: "app-1.2.7", DeviceType: "bbb"},
+		{DeviceID: "dev-5", ArtifactName: "app-1.3.0", DeviceType: "rpi4"},
+		{DeviceID: "dev-6", ArtifactName: "app-nightly", DeviceType: "rpi4"},
+	}
+
+	testCases := map[string]struct {
+		InputDevices []string
+		InputFilter  *deployments.InstalledArtifactFilter
+		InputFound   []*deployments.InstalledArtifact
+		InputError   error
+
+		OutputDevices []string
+		OutputError   error
+	}{
+		"ok, name": {
+			InputDevices: []string{"dev-0", "dev-3"},
+			InputFilter:  &deployments.InstalledArtifactFilter{Name: "app-1.2.0"},
+			InputFound:   installed[2:3],
+
+			OutputDevices: []string{"dev-0", "dev-3"},
+		},
+		"ok, version range": {
+			InputFilter: &deployments.InstalledArtifactFilter{
+				Name:       "app-",
+				MinVersion: "1.2.0",
+				MaxVersion: "1.3.0",
+			},
+			InputFound: installed,
+
+			OutputDevices: []string{"dev-3", "dev-4"},
+		},
+		"ok, open version range": {
+			InputFilter: &deployments.InstalledArtifactFilter{
+				Name:       "app-",
+				MaxVersion: "1.2",
+			},
+			InputFound: installed,
+
+			OutputDevices: []string{"dev-1", "dev-2"},
+		},
+		"no devices": {
+			InputFilter: &deployments.InstalledArtifactFilter{
+				Name:       "app-",
+				MinVersion: "2.0.0",
+			},
+			InputFound: installed,
+
+			OutputError: deployments.NewValidationError("installed_artifact",
+				deployments.ValidationCodeNotFound,
+				deployments.ErrNoInstalledArtifact.Error()),
+		},
+		"storage error": {
+			InputFilter: &deployments.InstalledArtifactFilter{Name: "app-1.2.0"},
+			InputError:  errors.New("storage issue"),
+
+			OutputError: errors.New("Searching for devices with installed artifact: " +
+				"storage issue"),
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+
+			installedArtifactsStorage := new(mocks.InstalledArtifactsStorage)
+			installedArtifactsStorage.On("FindInstalledArtifactsByName",
+				h.ContextMatcher(), testCase.InputFilter.Name).
+				Return(testCase.InputFound, testCase.InputError)
+			installedArtifactsStorage.On("FindInstalledArtifactsByNamePrefix",
+				h.ContextMatcher(), testCase.InputFilter.Name).
+				Return(testCase.InputFound, testCase.InputError)
+
+			deploymentStorage := new(mocks.DeploymentsStorage)
+			deploymentStorage.On("FindBySlug",
+				h.ContextMatcher(), mock.AnythingOfType("string")).
+				Return(nil, nil)
+			deploymentStorage.On("Insert",
+				h.ContextMatcher(),
+				mock.AnythingOfType("*deployments.Deployment")).
+				Return(nil)
+
+			deviceDeploymentStorage := new(mocks.DeviceDeploymentStorage)
+			deviceDeploymentStorage.On("InsertMany",
+				h.ContextMatcher(),
+				mock.AnythingOfType("[]*deployments.DeviceDeployment")).
+				Return(nil)
+
+			artifactGetter := new(mocks.ArtifactGetter)
+			artifactGetter.On("ImagesByName",
+				h.ContextMatcher(), "app-1.3.0").
+				Return([]*images.SoftwareImage{
+					images.NewSoftwareImage(
+						validUUIDv4,
+						&images.SoftwareImageMetaConstructor{},
+						&images.SoftwareImageMetaArtifactConstructor{
+							Name:                  "app-1.3.0",
+							DeviceTypesCompatible: []string{"rpi4"},
+						}),
+				}, nil)
+
+			model := NewDeploymentModel(DeploymentsModelConfig{
+				DeploymentsStorage:        deploymentStorage,
+				DeviceDeploymentsStorage:  deviceDeploymentStorage,
+				ArtifactGetter:            artifactGetter,
+				InstalledArtifactsStorage: installedArtifactsStorage,
+			})
+
+			constructor := &deployments.DeploymentConstructor{
+				Name:              StringToPointer("Emergency patch"),
+				ArtifactName:      StringToPointer("app-1.3.0"),
+				Devices:           testCase.InputDevices,
+				InstalledArtifact: testCase.InputFilter,
+			}
+			_, err := model.CreateDeployment(context.Background(), constructor)
+			if testCase.OutputError != nil {
+				assert.EqualError(t, err, testCase.OutputError.Error())
+				deploymentStorage.AssertNotCalled(t, "Insert", mock.Anything, mock.Anything)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, testCase.OutputDevices, constructor.Devices)
+		})
+	}
+}
+
+func TestDeploymentModelDecommissionDeviceInstalledArtifact(t *testing.T) {
+
+	deviceDeploymentStorage := new(mocks.DeviceDeploymentStorage)
+	deviceDeploymentStorage.On("DecommissionDeviceDeployments",
+		h.ContextMatcher(), "device-1").
+		Return(nil)
+	deviceDeploymentStorage.On("FindAllDeploymentsForDeviceIDWithStatuses",
+		h.ContextMatcher(), "device-1",
+		[]string{deployments.DeviceDeploymentStatusDecommissioned}).
+		Return(nil, nil)
+
+	installedArtifactsStorage := new(mocks.InstalledArtifactsStorage)
+	installedArtifactsStorage.On("DeleteInstalledArtifact",
+		h.ContextMatcher(), "device-1").
+		Return(nil)
+
+	model := NewDeploymentModel(DeploymentsModelConfig{
+		DeviceDeploymentsStorage:  deviceDeploymentStorage,
+		InstalledArtifactsStorage: installedArtifactsStorage,
+	})
+
+	// decommissioned devices are no longer targeted by installed artifact
+	assert.NoError(t, model.DecommissionDevice(context.Background(), "device-1"))
+	installedArtifactsStorage.AssertExpectations(t)
+}
@@ -0,0 +1,32 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"context"
+
+	"github.com/mendersoftware/deployments/resources/deployments"
+)
+
+// Artifacts last reported as installed by devices
+type InstalledArtifactsStorage interface {
+	// UpsertInstalledArtifact replaces the artifact recorded for the device
+	UpsertInstalledArtifact(ctx context.Context, installed *deployments.InstalledArtifact) error
+	FindInstalledArtifactsByName(ctx context.Context,
+		name string) ([]*deployments.InstalledArtifact, error)
+	FindInstalledArtifactsByNamePrefix(ctx context.Context,
+		prefix string) ([]*deployments.InstalledArtifact, error)
+	DeleteInstalledArtifact(ctx context.Context, deviceID string) error
+}
@@ -0,0 +1,33 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"context"
+
+	"github.com/mendersoftware/deployments/integration"
+)
+
+// Name of the device attribute resolved to the device's inventory group
+const DeviceAttributeGroup = "group"
+
+// Provides device attributes and grouping from the inventory service
+type Inventory interface {
+	GetDeviceInventory(ctx context.Context,
+		id integration.DeviceID) (*integration.Device, error)
+	GetDeviceGroup(ctx context.Context, id integration.DeviceID) (string, error)
+	FindDevicesByAttribute(ctx context.Context,
+		name, value string) ([]integration.DeviceID, error)
+}
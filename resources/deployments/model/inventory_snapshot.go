@@ -0,0 +1,79 @@
+// Copyright 2026 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/deployments/integration"
+	"github.com/mendersoftware/deployments/resources/deployments"
+)
+
+// snapshotInventory saves the configured inventory attributes of the device
+// to the device deployment, unless it has a snapshot already. Attributes the
+// device does not have are left out.
+func (d *DeploymentsModel) snapshotInventory(ctx context.Context,
+	deviceDeployment *deployments.DeviceDeployment) error {
+
+	if len(d.inventorySnapshot) == 0 || d.inventory == nil ||
+		deviceDeployment.Inventory != nil {
+		return nil
+	}
+
+	deviceID := integration.DeviceID(*deviceDeployment.DeviceId)
+	snapshot := make(map[string]string, len(d.inventorySnapshot))
+
+	var device *integration.Device
+	for _, attribute := range d.inventorySnapshot {
+		if attribute == DeviceAttributeGroup {
+			group, err := d.inventory.GetDeviceGroup(ctx, deviceID)
+			if err != nil {
+				return errors.Wrap(err, "Fetching device group")
+			}
+			if group != "" {
+				snapshot[attribute] = group
+			}
+			continue
+		}
+
+		if device == nil {
+			var err error
+			device, err = d.inventory.GetDeviceInventory(ctx, deviceID)
+			if err != nil {
+				return errors.Wrap(err, "Fetching device inventory")
+			}
+			if device == nil {
+				device = &integration.Device{}
+			}
+		}
+		for _, attr := range device.Attributes {
+			if attr != nil && attr.Name == attribute && attr.Value != nil {
+				snapshot[attribute] = fmt.Sprint(attr.Value)
+				break
+			}
+		}
+	}
+
+	if err := d.deviceDeploymentsStorage.SaveDeviceDeploymentInventory(ctx,
+		*deviceDeployment.DeviceId, *deviceDeployment.DeploymentId, snapshot); err != nil {
+		return errors.Wrap(err, "Saving device inventory snapshot")
+	}
+	deviceDeployment.Inventory = snapshot
+
+	return nil
+}
@@ -0,0 +1,148 @@
+// Copyright 2026 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/mendersoftware/deployments/integration"
+	"github.com/mendersoftware/deployments/resources/deployments"
+	. "github.com/mendersoftware/deployments/resources/deployments/model"
+	"github.com/mendersoftware/deployments/resources/deployments/model/mocks"
+	"github.com/mendersoftware/deployments/resources/images"
+	. "github.com/mendersoftware/deployments/utils/pointers"
+	h "github.com/mendersoftware/deployments/utils/testing"
+)
+
+func TestDeploymentModelGetDeploymentForDeviceInventorySnapshot(t *testing.T) {
+
+	artifact := images.NewSoftwareImage(
+		validUUIDv4,
+		&images.SoftwareImageMetaConstructor{},
+		&images.SoftwareImageMetaArtifactConstructor{
+			Name:                  "App 123",
+			DeviceTypesCompatible: []string{"hammer"},
+		})
+	deploymentID := "b532b01a-9313-404f-8d19-e7fcbe5cc347"
+
+	testCases := map[string]struct {
+		InputSnapshot []string
+		InputExisting map[string]string
+		InputInvError error
+
+		OutputSnapshot map[string]string
+	}{
+		"snapshot": {
+			InputSnapshot: []string{DeviceAttributeGroup, "hw_revision", "geo-country"},
+
+			OutputSnapshot: map[string]string{
+				DeviceAttributeGroup: "site-a",
+				"hw_revision":        "2",
+			},
+		},
+		"snapshot taken before": {
+			InputSnapshot: []string{DeviceAttributeGroup},
+			InputExisting: map[string]string{DeviceAttributeGroup: "site-b"},
+		},
+		"disabled": {},
+		"inventory error": {
+			InputSnapshot: []string{"hw_revision"},
+			InputInvError: errors.New("connection refused"),
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+
+			deployment := &deployments.Deployment{
+				Id:        StringToPointer(deploymentID),
+				Artifacts: []string{validUUIDv4},
+				DeploymentConstructor: &deployments.DeploymentConstructor{
+					ArtifactName: StringToPointer("App 123"),
+				},
+			}
+
+			deviceDeploymentStorage := new(mocks.DeviceDeploymentStorage)
+			deviceDeploymentStorage.On("FindOldestDeploymentForDeviceIDWithStatuses",
+				h.ContextMatcher(), "device-1", mock.AnythingOfType("[]string")).
+				Return(&deployments.DeviceDeployment{
+					DeviceId:     StringToPointer("device-1"),
+					DeploymentId: StringToPointer(deploymentID),
+					Image:        artifact,
+					DeviceType:   StringToPointer("hammer"),
+					Inventory:    testCase.InputExisting,
+				}, nil)
+			deviceDeploymentStorage.On("IncrementDeviceDeploymentAttempts",
+				h.ContextMatcher(), "device-1", deploymentID).
+				Return(nil)
+			if testCase.OutputSnapshot != nil {
+				deviceDeploymentStorage.On("SaveDeviceDeploymentInventory",
+					h.ContextMatcher(), "device-1", deploymentID, testCase.OutputSnapshot).
+					Return(nil)
+			}
+			deploymentStorage := new(mocks.DeploymentsStorage)
+			deploymentStorage.On("FindByID", h.ContextMatcher(), deploymentID).
+				Return(deployment, nil)
+
+			imageLinker := new(mocks.GetRequester)
+			imageLinker.On("GetRequest", h.ContextMatcher(), validUUIDv4,
+				DefaultUpdateDownloadLinkExpire, mock.AnythingOfType("string")).
+				Return(&images.Link{Uri: "http://download"}, nil)
+
+			inventory := new(mocks.Inventory)
+			inventory.On("GetDeviceGroup", h.ContextMatcher(), integration.DeviceID("device-1")).
+				Return("site-a", testCase.InputInvError)
+			inventory.On("GetDeviceInventory", h.ContextMatcher(), integration.DeviceID("device-1")).
+				Return(&integration.Device{
+					ID: "device-1",
+					Attributes: []*integration.Attribute{
+						{Name: "hw_revision", Value: 2},
+						{Name: "mac", Value: "00:11:22:33:44:55"},
+					},
+				}, testCase.InputInvError)
+
+			model := NewDeploymentModel(DeploymentsModelConfig{
+				DeploymentsStorage:       deploymentStorage,
+				DeviceDeploymentsStorage: deviceDeploymentStorage,
+				ImageLinker:              imageLinker,
+				Inventory:                inventory,
+				InventorySnapshot:        testCase.InputSnapshot,
+			})
+
+			out, err := model.GetDeploymentForDeviceWithCurrent(context.Background(),
+				"device-1", deployments.InstalledDeviceDeployment{
+					Artifact:   "App 122",
+					DeviceType: "hammer",
+				})
+			assert.NoError(t, err)
+			assert.NotNil(t, out)
+
+			deviceDeploymentStorage.AssertExpectations(t)
+			if testCase.OutputSnapshot == nil {
+				deviceDeploymentStorage.AssertNotCalled(t, "SaveDeviceDeploymentInventory",
+					mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+			}
+			if testCase.InputSnapshot == nil || testCase.InputExisting != nil {
+				inventory.AssertNotCalled(t, "GetDeviceGroup", mock.Anything, mock.Anything)
+				inventory.AssertNotCalled(t, "GetDeviceInventory", mock.Anything, mock.Anything)
+			}
+		})
+	}
+}
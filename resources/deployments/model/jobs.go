@@ -0,0 +1,74 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// JobTypeStatsRollup is the background job counting finished device
+// deployment in the statistics rollups.
+const JobTypeStatsRollup = "stats_rollup"
+
+// JobQueue runs work in the background, retrying failed jobs.
+type JobQueue interface {
+	Register(jobType string,
+		handler func(ctx context.Context, payload json.RawMessage) error)
+	Enqueue(ctx context.Context, jobType string, payload interface{}) error
+}
+
+// statsRollupJob is the payload of the statistics rollup job
+type statsRollupJob struct {
+	Finished time.Time `json:"finished"`
+	Status   string    `json:"status"`
+}
+
+func (d *DeploymentsModel) registerJobs() {
+	if d.jobs == nil {
+		return
+	}
+	d.jobs.Register(JobTypeStatsRollup, d.handleStatsRollupJob)
+	d.jobs.Register(JobTypeFinalizeDeployment, d.handleFinalizeJob)
+}
+
+// rollupStats counts device deployment finished with given status;
+// queued as a job if the queue is configured, done directly otherwise.
+func (d *DeploymentsModel) rollupStats(ctx context.Context,
+	finished time.Time, status string) error {
+
+	if d.jobs == nil {
+		return d.deploymentsStorage.IncrementStatsRollup(ctx, finished, status)
+	}
+
+	return d.jobs.Enqueue(ctx, JobTypeStatsRollup, statsRollupJob{
+		Finished: finished,
+		Status:   status,
+	})
+}
+
+func (d *DeploymentsModel) handleStatsRollupJob(ctx context.Context,
+	payload json.RawMessage) error {
+
+	var job statsRollupJob
+	if err := json.Unmarshal(payload, &job); err != nil {
+		return errors.Wrap(err, "decoding statistics rollup job")
+	}
+
+	return d.deploymentsStorage.IncrementStatsRollup(ctx, job.Finished, job.Status)
+}
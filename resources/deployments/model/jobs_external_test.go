@@ -0,0 +1,68 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mendersoftware/deployments/resources/deployments"
+	. "github.com/mendersoftware/deployments/resources/deployments/model"
+	"github.com/mendersoftware/deployments/resources/deployments/model/mocks"
+	h "github.com/mendersoftware/deployments/utils/testing"
+)
+
+type fakeJobQueue struct {
+	handlers map[string]func(ctx context.Context, payload json.RawMessage) error
+}
+
+func (q *fakeJobQueue) Register(jobType string,
+	handler func(ctx context.Context, payload json.RawMessage) error) {
+	q.handlers[jobType] = handler
+}
+
+func (q *fakeJobQueue) Enqueue(ctx context.Context, jobType string, payload interface{}) error {
+	return nil
+}
+
+func TestStatsRollupJob(t *testing.T) {
+	finished := time.Date(2018, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	deploymentStorage := &mocks.DeploymentsStorage{}
+	deploymentStorage.On("IncrementStatsRollup", h.ContextMatcher(),
+		finished, deployments.DeviceDeploymentStatusSuccess).
+		Return(nil)
+
+	queue := &fakeJobQueue{
+		handlers: map[string]func(ctx context.Context, payload json.RawMessage) error{},
+	}
+	NewDeploymentModel(DeploymentsModelConfig{
+		DeploymentsStorage: deploymentStorage,
+		Jobs:               queue,
+	})
+
+	handler := queue.handlers[JobTypeStatsRollup]
+	if assert.NotNil(t, handler) {
+		assert.NoError(t, handler(context.Background(), json.RawMessage(
+			`{"finished":"2018-01-02T03:04:05Z","status":"success"}`)))
+		assert.Error(t, handler(context.Background(), json.RawMessage(`[]`)))
+	}
+
+	deploymentStorage.AssertExpectations(t)
+}
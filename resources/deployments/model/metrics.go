@@ -0,0 +1,345 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"context"
+	"time"
+
+	"github.com/mendersoftware/deployments/resources/deployments"
+	"github.com/mendersoftware/deployments/resources/deployments/controller"
+	"github.com/mendersoftware/deployments/resources/images"
+	"github.com/mendersoftware/deployments/utils/metrics"
+	"github.com/mendersoftware/go-lib-micro/identity"
+)
+
+// MetricsModel decorates the deployments model recording count, latency
+// and errors of calls to each of its methods, per tenant of the request
+// if the recorder tracks tenants.
+type MetricsModel struct {
+	model    controller.DeploymentsModel
+	recorder *metrics.Recorder
+}
+
+var _ controller.DeploymentsModel = (*MetricsModel)(nil)
+
+func NewMetricsModel(model controller.DeploymentsModel,
+	recorder *metrics.Recorder) *MetricsModel {
+
+	return &MetricsModel{
+		model:    model,
+		recorder: recorder,
+	}
+}
+
+func (m *MetricsModel) observe(ctx context.Context, method string,
+	start time.Time, err *error) {
+
+	var tenant string
+	if id := identity.FromContext(ctx); id != nil {
+		tenant = id.Tenant
+	}
+	m.recorder.ObserveTenant(method, tenant, time.Since(start), *err)
+}
+
+func (m *MetricsModel) CreateDeployment(ctx context.Context,
+	constructor *deployments.DeploymentConstructor) (_ string, err error) {
+	defer m.observe(ctx, "CreateDeployment", time.Now(), &err)
+	return m.model.CreateDeployment(ctx, constructor)
+}
+
+func (m *MetricsModel) GetDeployment(ctx context.Context,
+	deploymentID string) (_ *deployments.Deployment, err error) {
+	defer m.observe(ctx, "GetDeployment", time.Now(), &err)
+	return m.model.GetDeployment(ctx, deploymentID)
+}
+
+func (m *MetricsModel) GetDeploymentIDBySlug(ctx context.Context,
+	slug string) (_ string, err error) {
+	defer m.observe(ctx, "GetDeploymentIDBySlug", time.Now(), &err)
+	return m.model.GetDeploymentIDBySlug(ctx, slug)
+}
+
+func (m *MetricsModel) RetryDeviceDeployment(ctx context.Context,
+	deploymentID, deviceID string) (err error) {
+	defer m.observe(ctx, "RetryDeviceDeployment", time.Now(), &err)
+	return m.model.RetryDeviceDeployment(ctx, deploymentID, deviceID)
+}
+
+func (m *MetricsModel) IsDeploymentFinished(ctx context.Context,
+	deploymentID string) (_ bool, err error) {
+	defer m.observe(ctx, "IsDeploymentFinished", time.Now(), &err)
+	return m.model.IsDeploymentFinished(ctx, deploymentID)
+}
+
+func (m *MetricsModel) AbortDeployment(ctx context.Context,
+	deploymentID string, abort *deployments.AbortInfo) (err error) {
+	defer m.observe(ctx, "AbortDeployment", time.Now(), &err)
+	return m.model.AbortDeployment(ctx, deploymentID, abort)
+}
+
+func (m *MetricsModel) ForceFinishDeployment(ctx context.Context,
+	deploymentID string, status string, abort *deployments.AbortInfo) (err error) {
+	defer m.observe(ctx, "ForceFinishDeployment", time.Now(), &err)
+	return m.model.ForceFinishDeployment(ctx, deploymentID, status, abort)
+}
+
+func (m *MetricsModel) GetDeploymentStats(ctx context.Context,
+	deploymentID string) (_ deployments.Stats, err error) {
+	defer m.observe(ctx, "GetDeploymentStats", time.Now(), &err)
+	return m.model.GetDeploymentStats(ctx, deploymentID)
+}
+
+func (m *MetricsModel) GetDeploymentFailureStats(ctx context.Context,
+	deploymentID string) (_ deployments.FailureStats, err error) {
+	defer m.observe(ctx, "GetDeploymentFailureStats", time.Now(), &err)
+	return m.model.GetDeploymentFailureStats(ctx, deploymentID)
+}
+
+func (m *MetricsModel) GetDeploymentDurationStats(ctx context.Context,
+	deploymentID string) (_ deployments.DurationStats, err error) {
+	defer m.observe(ctx, "GetDeploymentDurationStats", time.Now(), &err)
+	return m.model.GetDeploymentDurationStats(ctx, deploymentID)
+}
+
+func (m *MetricsModel) GetDeploymentTelemetryStats(ctx context.Context,
+	deploymentID string) (_ deployments.TelemetryStats, err error) {
+	defer m.observe(ctx, "GetDeploymentTelemetryStats", time.Now(), &err)
+	return m.model.GetDeploymentTelemetryStats(ctx, deploymentID)
+}
+
+func (m *MetricsModel) GetDeploymentPhaseStats(ctx context.Context,
+	deploymentID string) (_ []deployments.PhaseStats, err error) {
+	defer m.observe(ctx, "GetDeploymentPhaseStats", time.Now(), &err)
+	return m.model.GetDeploymentPhaseStats(ctx, deploymentID)
+}
+
+func (m *MetricsModel) ResolveDeviceExternalID(ctx context.Context,
+	externalID string) (_ string, err error) {
+	defer m.observe(ctx, "ResolveDeviceExternalID", time.Now(), &err)
+	return m.model.ResolveDeviceExternalID(ctx, externalID)
+}
+
+func (m *MetricsModel) EstimateDeployment(ctx context.Context,
+	deploymentID string) (_ *deployments.DeploymentEstimate, err error) {
+	defer m.observe(ctx, "EstimateDeployment", time.Now(), &err)
+	return m.model.EstimateDeployment(ctx, deploymentID)
+}
+
+func (m *MetricsModel) GetStatsSummary(
+	ctx context.Context) (_ *deployments.StatsSummary, err error) {
+	defer m.observe(ctx, "GetStatsSummary", time.Now(), &err)
+	return m.model.GetStatsSummary(ctx)
+}
+
+func (m *MetricsModel) GetDeploymentStatsByGroup(ctx context.Context,
+	deploymentID string, attribute string) (_ deployments.GroupStats, err error) {
+	defer m.observe(ctx, "GetDeploymentStatsByGroup", time.Now(), &err)
+	return m.model.GetDeploymentStatsByGroup(ctx, deploymentID, attribute)
+}
+
+func (m *MetricsModel) GetDeploymentForDeviceWithCurrent(ctx context.Context,
+	deviceID string, current deployments.InstalledDeviceDeployment,
+) (_ *deployments.DeploymentInstructions, err error) {
+	defer m.observe(ctx, "GetDeploymentForDeviceWithCurrent", time.Now(), &err)
+	return m.model.GetDeploymentForDeviceWithCurrent(ctx, deviceID, current)
+}
+
+func (m *MetricsModel) HasDeploymentForDevice(ctx context.Context,
+	deploymentID string, deviceID string) (_ bool, err error) {
+	defer m.observe(ctx, "HasDeploymentForDevice", time.Now(), &err)
+	return m.model.HasDeploymentForDevice(ctx, deploymentID, deviceID)
+}
+
+func (m *MetricsModel) UpdateDeviceDeploymentStatus(ctx context.Context,
+	deploymentID string, deviceID string, status deployments.DeviceDeploymentStatus) (err error) {
+	defer m.observe(ctx, "UpdateDeviceDeploymentStatus", time.Now(), &err)
+	return m.model.UpdateDeviceDeploymentStatus(ctx, deploymentID, deviceID, status)
+}
+
+func (m *MetricsModel) GetDeviceStatusesForDeployment(ctx context.Context,
+	deploymentID string) (_ []deployments.DeviceDeployment, err error) {
+	defer m.observe(ctx, "GetDeviceStatusesForDeployment", time.Now(), &err)
+	return m.model.GetDeviceStatusesForDeployment(ctx, deploymentID)
+}
+
+func (m *MetricsModel) GetDeviceDeploymentsCount(ctx context.Context,
+	deploymentID, status string) (_ int, err error) {
+	defer m.observe(ctx, "GetDeviceDeploymentsCount", time.Now(), &err)
+	return m.model.GetDeviceDeploymentsCount(ctx, deploymentID, status)
+}
+
+func (m *MetricsModel) LookupDeviceDeployments(ctx context.Context,
+	query deployments.DeviceDeploymentsQuery) (_ []deployments.DeviceDeployment, err error) {
+	defer m.observe(ctx, "LookupDeviceDeployments", time.Now(), &err)
+	return m.model.LookupDeviceDeployments(ctx, query)
+}
+
+func (m *MetricsModel) LookupDownloads(ctx context.Context,
+	query deployments.DownloadsQuery) (_ []deployments.Download, err error) {
+	defer m.observe(ctx, "LookupDownloads", time.Now(), &err)
+	return m.model.LookupDownloads(ctx, query)
+}
+
+func (m *MetricsModel) RevokeDeviceDeploymentLink(ctx context.Context,
+	deploymentID, deviceID string) (err error) {
+	defer m.observe(ctx, "RevokeDeviceDeploymentLink", time.Now(), &err)
+	return m.model.RevokeDeviceDeploymentLink(ctx, deploymentID, deviceID)
+}
+
+func (m *MetricsModel) GetDeviceDeploymentDownload(ctx context.Context,
+	deploymentID, deviceID string, expire int64,
+	signature string) (_ *images.Link, err error) {
+	defer m.observe(ctx, "GetDeviceDeploymentDownload", time.Now(), &err)
+	return m.model.GetDeviceDeploymentDownload(ctx, deploymentID, deviceID, expire, signature)
+}
+
+func (m *MetricsModel) GetLatestDeviceDeployment(ctx context.Context,
+	deviceID string, deploymentIDs []string) (_ *deployments.DeviceDeployment, err error) {
+	defer m.observe(ctx, "GetLatestDeviceDeployment", time.Now(), &err)
+	return m.model.GetLatestDeviceDeployment(ctx, deviceID, deploymentIDs)
+}
+
+func (m *MetricsModel) GetActiveDeviceDeployments(ctx context.Context,
+	deviceIDs []string) (_ map[string][]deployments.DeviceDeployment, err error) {
+	defer m.observe(ctx, "GetActiveDeviceDeployments", time.Now(), &err)
+	return m.model.GetActiveDeviceDeployments(ctx, deviceIDs)
+}
+
+func (m *MetricsModel) LookupDeployment(ctx context.Context,
+	query deployments.Query) (_ []*deployments.Deployment, err error) {
+	defer m.observe(ctx, "LookupDeployment", time.Now(), &err)
+	return m.model.LookupDeployment(ctx, query)
+}
+
+func (m *MetricsModel) SaveDeviceDeploymentLog(ctx context.Context,
+	deviceID string, deploymentID string, logs []deployments.LogMessage) (err error) {
+	defer m.observe(ctx, "SaveDeviceDeploymentLog", time.Now(), &err)
+	return m.model.SaveDeviceDeploymentLog(ctx, deviceID, deploymentID, logs)
+}
+
+func (m *MetricsModel) GetDeviceDeploymentLog(ctx context.Context,
+	deviceID, deploymentID string) (_ *deployments.DeploymentLog, err error) {
+	defer m.observe(ctx, "GetDeviceDeploymentLog", time.Now(), &err)
+	return m.model.GetDeviceDeploymentLog(ctx, deviceID, deploymentID)
+}
+
+func (m *MetricsModel) SearchDeviceDeploymentLogs(ctx context.Context,
+	deploymentID, text string) (_ []string, err error) {
+	defer m.observe(ctx, "SearchDeviceDeploymentLogs", time.Now(), &err)
+	return m.model.SearchDeviceDeploymentLogs(ctx, deploymentID, text)
+}
+
+func (m *MetricsModel) DecommissionDevice(ctx context.Context, deviceID string) (err error) {
+	defer m.observe(ctx, "DecommissionDevice", time.Now(), &err)
+	return m.model.DecommissionDevice(ctx, deviceID)
+}
+
+func (m *MetricsModel) CreateFreezePeriod(ctx context.Context,
+	constructor *deployments.FreezePeriodConstructor) (_ string, err error) {
+	defer m.observe(ctx, "CreateFreezePeriod", time.Now(), &err)
+	return m.model.CreateFreezePeriod(ctx, constructor)
+}
+
+func (m *MetricsModel) GetFreezePeriods(
+	ctx context.Context) (_ []*deployments.FreezePeriod, err error) {
+	defer m.observe(ctx, "GetFreezePeriods", time.Now(), &err)
+	return m.model.GetFreezePeriods(ctx)
+}
+
+func (m *MetricsModel) DeleteFreezePeriod(ctx context.Context, id string) (err error) {
+	defer m.observe(ctx, "DeleteFreezePeriod", time.Now(), &err)
+	return m.model.DeleteFreezePeriod(ctx, id)
+}
+
+func (m *MetricsModel) SetDeviceTypeAlias(ctx context.Context,
+	alias *deployments.DeviceTypeAlias) (err error) {
+	defer m.observe(ctx, "SetDeviceTypeAlias", time.Now(), &err)
+	return m.model.SetDeviceTypeAlias(ctx, alias)
+}
+
+func (m *MetricsModel) GetDeviceTypeAliases(
+	ctx context.Context) (_ []*deployments.DeviceTypeAlias, err error) {
+	defer m.observe(ctx, "GetDeviceTypeAliases", time.Now(), &err)
+	return m.model.GetDeviceTypeAliases(ctx)
+}
+
+func (m *MetricsModel) DeleteDeviceTypeAlias(ctx context.Context, alias string) (err error) {
+	defer m.observe(ctx, "DeleteDeviceTypeAlias", time.Now(), &err)
+	return m.model.DeleteDeviceTypeAlias(ctx, alias)
+}
+
+func (m *MetricsModel) GetDeploymentSettings(
+	ctx context.Context) (_ *deployments.DeploymentSettings, err error) {
+	defer m.observe(ctx, "GetDeploymentSettings", time.Now(), &err)
+	return m.model.GetDeploymentSettings(ctx)
+}
+
+func (m *MetricsModel) SetDeploymentSettings(ctx context.Context,
+	settings *deployments.DeploymentSettings) (err error) {
+	defer m.observe(ctx, "SetDeploymentSettings", time.Now(), &err)
+	return m.model.SetDeploymentSettings(ctx, settings)
+}
+
+func (m *MetricsModel) CreateCampaign(ctx context.Context,
+	constructor *deployments.CampaignConstructor) (_ string, err error) {
+	defer m.observe(ctx, "CreateCampaign", time.Now(), &err)
+	return m.model.CreateCampaign(ctx, constructor)
+}
+
+func (m *MetricsModel) GetCampaigns(
+	ctx context.Context) (_ []*deployments.Campaign, err error) {
+	defer m.observe(ctx, "GetCampaigns", time.Now(), &err)
+	return m.model.GetCampaigns(ctx)
+}
+
+func (m *MetricsModel) GetCampaign(ctx context.Context,
+	id string) (_ *deployments.Campaign, err error) {
+	defer m.observe(ctx, "GetCampaign", time.Now(), &err)
+	return m.model.GetCampaign(ctx, id)
+}
+
+func (m *MetricsModel) GetCampaignStats(ctx context.Context,
+	id string) (_ deployments.Stats, err error) {
+	defer m.observe(ctx, "GetCampaignStats", time.Now(), &err)
+	return m.model.GetCampaignStats(ctx, id)
+}
+
+func (m *MetricsModel) PauseCampaign(ctx context.Context, id string, paused bool) (err error) {
+	defer m.observe(ctx, "PauseCampaign", time.Now(), &err)
+	return m.model.PauseCampaign(ctx, id, paused)
+}
+
+func (m *MetricsModel) AbortCampaign(ctx context.Context, id string,
+	abort *deployments.AbortInfo) (err error) {
+	defer m.observe(ctx, "AbortCampaign", time.Now(), &err)
+	return m.model.AbortCampaign(ctx, id, abort)
+}
+
+func (m *MetricsModel) CreateAlert(ctx context.Context,
+	constructor *deployments.AlertConstructor) (_ string, err error) {
+	defer m.observe(ctx, "CreateAlert", time.Now(), &err)
+	return m.model.CreateAlert(ctx, constructor)
+}
+
+func (m *MetricsModel) GetAlerts(ctx context.Context) (_ []*deployments.Alert, err error) {
+	defer m.observe(ctx, "GetAlerts", time.Now(), &err)
+	return m.model.GetAlerts(ctx)
+}
+
+func (m *MetricsModel) DeleteAlert(ctx context.Context, id string) (err error) {
+	defer m.observe(ctx, "DeleteAlert", time.Now(), &err)
+	return m.model.DeleteAlert(ctx, id)
+}
@@ -0,0 +1,93 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/mendersoftware/go-lib-micro/identity"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mendersoftware/deployments/resources/deployments"
+	"github.com/mendersoftware/deployments/resources/deployments/controller/mocks"
+	. "github.com/mendersoftware/deployments/resources/deployments/model"
+	"github.com/mendersoftware/deployments/utils/metrics"
+	. "github.com/mendersoftware/deployments/utils/pointers"
+	h "github.com/mendersoftware/deployments/utils/testing"
+)
+
+func TestMetricsModel(t *testing.T) {
+	deployment := &deployments.Deployment{Id: StringToPointer(validUUIDv4)}
+
+	model := &mocks.DeploymentsModel{}
+	model.On("GetDeployment", h.ContextMatcher(), validUUIDv4).
+		Return(deployment, nil)
+	model.On("GetDeployment", h.ContextMatcher(), "missing").
+		Return(nil, errors.New("failed"))
+	model.On("DeleteFreezePeriod", h.ContextMatcher(), "id").
+		Return(nil)
+
+	recorder := metrics.NewRecorder()
+	decorated := NewMetricsModel(model, recorder)
+
+	out, err := decorated.GetDeployment(context.Background(), validUUIDv4)
+	assert.NoError(t, err)
+	assert.Equal(t, deployment, out)
+
+	out, err = decorated.GetDeployment(context.Background(), "missing")
+	assert.EqualError(t, err, "failed")
+	assert.Nil(t, out)
+
+	assert.NoError(t, decorated.DeleteFreezePeriod(context.Background(), "id"))
+
+	snapshot := recorder.Snapshot()
+	assert.Len(t, snapshot, 2)
+	assert.Equal(t, int64(2), snapshot["GetDeployment"].Count)
+	assert.Equal(t, int64(1), snapshot["GetDeployment"].Errors)
+	assert.Equal(t, 0.5, snapshot["GetDeployment"].ErrorRate)
+	assert.Equal(t, int64(1), snapshot["DeleteFreezePeriod"].Count)
+	assert.Equal(t, int64(0), snapshot["DeleteFreezePeriod"].Errors)
+
+	assert.Empty(t, recorder.TenantSnapshot())
+
+	model.AssertExpectations(t)
+}
+
+func TestMetricsModelTenants(t *testing.T) {
+	model := &mocks.DeploymentsModel{}
+	model.On("DeleteFreezePeriod", h.ContextMatcher(), "id").
+		Return(nil)
+
+	recorder := metrics.NewTenantRecorder(1, 10)
+	decorated := NewMetricsModel(model, recorder)
+
+	for _, tenant := range []string{"tenant-a", "tenant-a", "tenant-b", ""} {
+		ctx := context.Background()
+		if tenant != "" {
+			ctx = identity.WithContext(ctx, &identity.Identity{Tenant: tenant})
+		}
+		assert.NoError(t, decorated.DeleteFreezePeriod(ctx, "id"))
+	}
+
+	snapshot := recorder.TenantSnapshot()
+	assert.Len(t, snapshot, 2)
+	assert.Equal(t, int64(2), snapshot["tenant-a"]["DeleteFreezePeriod"].Count)
+	assert.Equal(t, int64(1), snapshot[metrics.OtherTenants]["DeleteFreezePeriod"].Count)
+	assert.Equal(t, int64(4), recorder.Snapshot()["DeleteFreezePeriod"].Count)
+
+	model.AssertExpectations(t)
+}
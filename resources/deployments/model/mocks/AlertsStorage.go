@@ -0,0 +1,148 @@
+// Copyright 2018 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package mocks
+
+import context "context"
+import deployments "github.com/mendersoftware/deployments/resources/deployments"
+import mock "github.com/stretchr/testify/mock"
+import time "time"
+
+// AlertsStorage is an autogenerated mock type for the AlertsStorage type
+type AlertsStorage struct {
+	mock.Mock
+}
+
+// DeleteAlert provides a mock function with given fields: ctx, id
+func (_m *AlertsStorage) DeleteAlert(ctx context.Context, id string) error {
+	ret := _m.Called(ctx, id)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// DeleteAlertStates provides a mock function with given fields: ctx, ids
+func (_m *AlertsStorage) DeleteAlertStates(ctx context.Context, ids []string) error {
+	ret := _m.Called(ctx, ids)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, []string) error); ok {
+		r0 = rf(ctx, ids)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// FindAlertStates provides a mock function with given fields: ctx
+func (_m *AlertsStorage) FindAlertStates(ctx context.Context) ([]*deployments.AlertState, error) {
+	ret := _m.Called(ctx)
+
+	var r0 []*deployments.AlertState
+	if rf, ok := ret.Get(0).(func(context.Context) []*deployments.AlertState); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*deployments.AlertState)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// FindAlerts provides a mock function with given fields: ctx
+func (_m *AlertsStorage) FindAlerts(ctx context.Context) ([]*deployments.Alert, error) {
+	ret := _m.Called(ctx)
+
+	var r0 []*deployments.Alert
+	if rf, ok := ret.Get(0).(func(context.Context) []*deployments.Alert); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*deployments.Alert)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// InsertAlert provides a mock function with given fields: ctx, alert
+func (_m *AlertsStorage) InsertAlert(ctx context.Context, alert *deployments.Alert) error {
+	ret := _m.Called(ctx, alert)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *deployments.Alert) error); ok {
+		r0 = rf(ctx, alert)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// InsertAlertState provides a mock function with given fields: ctx, state
+func (_m *AlertsStorage) InsertAlertState(ctx context.Context, state *deployments.AlertState) error {
+	ret := _m.Called(ctx, state)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *deployments.AlertState) error); ok {
+		r0 = rf(ctx, state)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// MarkAlertStateFired provides a mock function with given fields: ctx, id, when
+func (_m *AlertsStorage) MarkAlertStateFired(ctx context.Context, id string, when time.Time) (bool, error) {
+	ret := _m.Called(ctx, id, when)
+
+	var r0 bool
+	if rf, ok := ret.Get(0).(func(context.Context, string, time.Time) bool); ok {
+		r0 = rf(ctx, id, when)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string, time.Time) error); ok {
+		r1 = rf(ctx, id, when)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
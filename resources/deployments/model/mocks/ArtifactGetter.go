@@ -24,6 +24,29 @@ type ArtifactGetter struct {
 	mock.Mock
 }
 
+// FindByID provides a mock function with given fields: ctx, id
+func (_m *ArtifactGetter) FindByID(ctx context.Context, id string) (*images.SoftwareImage, error) {
+	ret := _m.Called(ctx, id)
+
+	var r0 *images.SoftwareImage
+	if rf, ok := ret.Get(0).(func(context.Context, string) *images.SoftwareImage); ok {
+		r0 = rf(ctx, id)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*images.SoftwareImage)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // ImageByIdsAndDeviceType provides a mock function with given fields: ctx, ids, deviceType
 func (_m *ArtifactGetter) ImageByIdsAndDeviceType(ctx context.Context, ids []string, deviceType string) (*images.SoftwareImage, error) {
 	ret := _m.Called(ctx, ids, deviceType)
@@ -93,4 +116,27 @@ func (_m *ArtifactGetter) ImagesByName(ctx context.Context, artifactName string)
 	return r0, r1
 }
 
+// ImagesByNamePrefix provides a mock function with given fields: ctx, prefix, deviceType
+func (_m *ArtifactGetter) ImagesByNamePrefix(ctx context.Context, prefix string, deviceType string) ([]*images.SoftwareImage, error) {
+	ret := _m.Called(ctx, prefix, deviceType)
+
+	var r0 []*images.SoftwareImage
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) []*images.SoftwareImage); ok {
+		r0 = rf(ctx, prefix, deviceType)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*images.SoftwareImage)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string, string) error); ok {
+		r1 = rf(ctx, prefix, deviceType)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 var _ model.ArtifactGetter = (*ArtifactGetter)(nil)
@@ -0,0 +1,44 @@
+// Copyright 2018 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package mocks
+
+import context "context"
+import mock "github.com/stretchr/testify/mock"
+
+// ArtifactRestorer is an autogenerated mock type for the ArtifactRestorer type
+type ArtifactRestorer struct {
+	mock.Mock
+}
+
+// Restore provides a mock function with given fields: ctx, objectId, days
+func (_m *ArtifactRestorer) Restore(ctx context.Context, objectId string, days int64) (bool, error) {
+	ret := _m.Called(ctx, objectId, days)
+
+	var r0 bool
+	if rf, ok := ret.Get(0).(func(context.Context, string, int64) bool); ok {
+		r0 = rf(ctx, objectId, days)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string, int64) error); ok {
+		r1 = rf(ctx, objectId, days)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
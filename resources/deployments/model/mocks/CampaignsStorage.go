@@ -0,0 +1,98 @@
+// Copyright 2018 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package mocks
+
+import context "context"
+import deployments "github.com/mendersoftware/deployments/resources/deployments"
+import mock "github.com/stretchr/testify/mock"
+
+// CampaignsStorage is an autogenerated mock type for the CampaignsStorage type
+type CampaignsStorage struct {
+	mock.Mock
+}
+
+// FindCampaignByID provides a mock function with given fields: ctx, id
+func (_m *CampaignsStorage) FindCampaignByID(ctx context.Context, id string) (*deployments.Campaign, error) {
+	ret := _m.Called(ctx, id)
+
+	var r0 *deployments.Campaign
+	if rf, ok := ret.Get(0).(func(context.Context, string) *deployments.Campaign); ok {
+		r0 = rf(ctx, id)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*deployments.Campaign)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// FindCampaigns provides a mock function with given fields: ctx
+func (_m *CampaignsStorage) FindCampaigns(ctx context.Context) ([]*deployments.Campaign, error) {
+	ret := _m.Called(ctx)
+
+	var r0 []*deployments.Campaign
+	if rf, ok := ret.Get(0).(func(context.Context) []*deployments.Campaign); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*deployments.Campaign)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// InsertCampaign provides a mock function with given fields: ctx, campaign
+func (_m *CampaignsStorage) InsertCampaign(ctx context.Context, campaign *deployments.Campaign) error {
+	ret := _m.Called(ctx, campaign)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *deployments.Campaign) error); ok {
+		r0 = rf(ctx, campaign)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// SetCampaignStatus provides a mock function with given fields: ctx, id, status
+func (_m *CampaignsStorage) SetCampaignStatus(ctx context.Context, id string, status string) error {
+	ret := _m.Called(ctx, id, status)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) error); ok {
+		r0 = rf(ctx, id, status)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
@@ -0,0 +1,102 @@
+// Copyright 2018 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package mocks
+
+import context "context"
+import mock "github.com/stretchr/testify/mock"
+
+// DeploymentDevicesStorage is an autogenerated mock type for the DeploymentDevicesStorage type
+type DeploymentDevicesStorage struct {
+	mock.Mock
+}
+
+// CountDeploymentDevices provides a mock function with given fields: ctx, deploymentID
+func (_m *DeploymentDevicesStorage) CountDeploymentDevices(ctx context.Context, deploymentID string) (int, error) {
+	ret := _m.Called(ctx, deploymentID)
+
+	var r0 int
+	if rf, ok := ret.Get(0).(func(context.Context, string) int); ok {
+		r0 = rf(ctx, deploymentID)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, deploymentID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// DeleteDeploymentDevices provides a mock function with given fields: ctx, deploymentID
+func (_m *DeploymentDevicesStorage) DeleteDeploymentDevices(ctx context.Context, deploymentID string) (int, error) {
+	ret := _m.Called(ctx, deploymentID)
+
+	var r0 int
+	if rf, ok := ret.Get(0).(func(context.Context, string) int); ok {
+		r0 = rf(ctx, deploymentID)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, deploymentID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// InsertDeploymentDevices provides a mock function with given fields: ctx, deploymentID, deviceIDs
+func (_m *DeploymentDevicesStorage) InsertDeploymentDevices(ctx context.Context, deploymentID string, deviceIDs []string) error {
+	ret := _m.Called(ctx, deploymentID, deviceIDs)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, []string) error); ok {
+		r0 = rf(ctx, deploymentID, deviceIDs)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// PullDeploymentDevices provides a mock function with given fields: ctx, deviceIDs
+func (_m *DeploymentDevicesStorage) PullDeploymentDevices(ctx context.Context, deviceIDs []string) (map[string][]string, error) {
+	ret := _m.Called(ctx, deviceIDs)
+
+	var r0 map[string][]string
+	if rf, ok := ret.Get(0).(func(context.Context, []string) map[string][]string); ok {
+		r0 = rf(ctx, deviceIDs)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(map[string][]string)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, []string) error); ok {
+		r1 = rf(ctx, deviceIDs)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
@@ -25,6 +25,71 @@ type DeploymentsStorage struct {
 	mock.Mock
 }
 
+// ActivatePhase provides a mock function with given fields: ctx, id, phase, when
+func (_m *DeploymentsStorage) ActivatePhase(ctx context.Context, id string, phase int, when time.Time) (bool, error) {
+	ret := _m.Called(ctx, id, phase, when)
+
+	var r0 bool
+	if rf, ok := ret.Get(0).(func(context.Context, string, int, time.Time) bool); ok {
+		r0 = rf(ctx, id, phase, when)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string, int, time.Time) error); ok {
+		r1 = rf(ctx, id, phase, when)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// AggregateStatsRollups provides a mock function with given fields: ctx, since
+func (_m *DeploymentsStorage) AggregateStatsRollups(ctx context.Context, since time.Time) (deployments.Stats, error) {
+	ret := _m.Called(ctx, since)
+
+	var r0 deployments.Stats
+	if rf, ok := ret.Get(0).(func(context.Context, time.Time) deployments.Stats); ok {
+		r0 = rf(ctx, since)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(deployments.Stats)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, time.Time) error); ok {
+		r1 = rf(ctx, since)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// CountByStatus provides a mock function with given fields: ctx, status
+func (_m *DeploymentsStorage) CountByStatus(ctx context.Context, status deployments.StatusQuery) (int, error) {
+	ret := _m.Called(ctx, status)
+
+	var r0 int
+	if rf, ok := ret.Get(0).(func(context.Context, deployments.StatusQuery) int); ok {
+		r0 = rf(ctx, status)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, deployments.StatusQuery) error); ok {
+		r1 = rf(ctx, status)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // Delete provides a mock function with given fields: ctx, id
 func (_m *DeploymentsStorage) Delete(ctx context.Context, id string) error {
 	ret := _m.Called(ctx, id)
@@ -81,6 +146,27 @@ func (_m *DeploymentsStorage) ExistByArtifactId(ctx context.Context, id string)
 	return r0, r1
 }
 
+// ExistByArtifactIdCreatedAfter provides a mock function with given fields: ctx, id, since
+func (_m *DeploymentsStorage) ExistByArtifactIdCreatedAfter(ctx context.Context, id string, since time.Time) (bool, error) {
+	ret := _m.Called(ctx, id, since)
+
+	var r0 bool
+	if rf, ok := ret.Get(0).(func(context.Context, string, time.Time) bool); ok {
+		r0 = rf(ctx, id, since)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string, time.Time) error); ok {
+		r1 = rf(ctx, id, since)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // ExistUnfinishedByArtifactId provides a mock function with given fields: ctx, id
 func (_m *DeploymentsStorage) ExistUnfinishedByArtifactId(ctx context.Context, id string) (bool, error) {
 	ret := _m.Called(ctx, id)
@@ -148,6 +234,29 @@ func (_m *DeploymentsStorage) FindByID(ctx context.Context, id string) (*deploym
 	return r0, r1
 }
 
+// FindBySlug provides a mock function with given fields: ctx, slug
+func (_m *DeploymentsStorage) FindBySlug(ctx context.Context, slug string) (*deployments.Deployment, error) {
+	ret := _m.Called(ctx, slug)
+
+	var r0 *deployments.Deployment
+	if rf, ok := ret.Get(0).(func(context.Context, string) *deployments.Deployment); ok {
+		r0 = rf(ctx, slug)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*deployments.Deployment)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, slug)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // FindUnfinishedByID provides a mock function with given fields: ctx, id
 func (_m *DeploymentsStorage) FindUnfinishedByID(ctx context.Context, id string) (*deployments.Deployment, error) {
 	ret := _m.Called(ctx, id)
@@ -171,6 +280,29 @@ func (_m *DeploymentsStorage) FindUnfinishedByID(ctx context.Context, id string)
 	return r0, r1
 }
 
+// FindWithStartedPhases provides a mock function with given fields: ctx, now
+func (_m *DeploymentsStorage) FindWithStartedPhases(ctx context.Context, now time.Time) ([]*deployments.Deployment, error) {
+	ret := _m.Called(ctx, now)
+
+	var r0 []*deployments.Deployment
+	if rf, ok := ret.Get(0).(func(context.Context, time.Time) []*deployments.Deployment); ok {
+		r0 = rf(ctx, now)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*deployments.Deployment)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, time.Time) error); ok {
+		r1 = rf(ctx, now)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // Finish provides a mock function with given fields: ctx, id, when
 func (_m *DeploymentsStorage) Finish(ctx context.Context, id string, when time.Time) error {
 	ret := _m.Called(ctx, id, when)
@@ -185,6 +317,55 @@ func (_m *DeploymentsStorage) Finish(ctx context.Context, id string, when time.T
 	return r0
 }
 
+// IncrementDownloadCount provides a mock function with given fields: ctx, id, period
+func (_m *DeploymentsStorage) IncrementDownloadCount(ctx context.Context, id string, period time.Time) (int, error) {
+	ret := _m.Called(ctx, id, period)
+
+	var r0 int
+	if rf, ok := ret.Get(0).(func(context.Context, string, time.Time) int); ok {
+		r0 = rf(ctx, id, period)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string, time.Time) error); ok {
+		r1 = rf(ctx, id, period)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// IncrementStats provides a mock function with given fields: ctx, stats
+func (_m *DeploymentsStorage) IncrementStats(ctx context.Context, stats map[string]deployments.Stats) error {
+	ret := _m.Called(ctx, stats)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, map[string]deployments.Stats) error); ok {
+		r0 = rf(ctx, stats)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// IncrementStatsRollup provides a mock function with given fields: ctx, when, status
+func (_m *DeploymentsStorage) IncrementStatsRollup(ctx context.Context, when time.Time, status string) error {
+	ret := _m.Called(ctx, when, status)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, time.Time, string) error); ok {
+		r0 = rf(ctx, when, status)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // Insert provides a mock function with given fields: ctx, deployment
 func (_m *DeploymentsStorage) Insert(ctx context.Context, deployment *deployments.Deployment) error {
 	ret := _m.Called(ctx, deployment)
@@ -199,6 +380,48 @@ func (_m *DeploymentsStorage) Insert(ctx context.Context, deployment *deployment
 	return r0
 }
 
+// Reopen provides a mock function with given fields: ctx, id
+func (_m *DeploymentsStorage) Reopen(ctx context.Context, id string) error {
+	ret := _m.Called(ctx, id)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// SetAbortInfo provides a mock function with given fields: ctx, id, abort
+func (_m *DeploymentsStorage) SetAbortInfo(ctx context.Context, id string, abort *deployments.AbortInfo) error {
+	ret := _m.Called(ctx, id, abort)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, *deployments.AbortInfo) error); ok {
+		r0 = rf(ctx, id, abort)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// SetPaused provides a mock function with given fields: ctx, ids, paused
+func (_m *DeploymentsStorage) SetPaused(ctx context.Context, ids []string, paused bool) error {
+	ret := _m.Called(ctx, ids, paused)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, []string, bool) error); ok {
+		r0 = rf(ctx, ids, paused)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // UpdateStats provides a mock function with given fields: ctx, id, state_from, state_to
 func (_m *DeploymentsStorage) UpdateStats(ctx context.Context, id string, state_from string, state_to string) error {
 	ret := _m.Called(ctx, id, state_from, state_to)
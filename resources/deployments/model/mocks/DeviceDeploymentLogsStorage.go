@@ -24,6 +24,41 @@ type DeviceDeploymentLogsStorage struct {
 	mock.Mock
 }
 
+// DeleteDeviceDeploymentLogs provides a mock function with given fields: ctx, deviceID
+func (_m *DeviceDeploymentLogsStorage) DeleteDeviceDeploymentLogs(ctx context.Context, deviceID string) (int, error) {
+	ret := _m.Called(ctx, deviceID)
+
+	var r0 int
+	if rf, ok := ret.Get(0).(func(context.Context, string) int); ok {
+		r0 = rf(ctx, deviceID)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, deviceID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// EnsureSearchIndex provides a mock function with given fields: ctx
+func (_m *DeviceDeploymentLogsStorage) EnsureSearchIndex(ctx context.Context) error {
+	ret := _m.Called(ctx)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context) error); ok {
+		r0 = rf(ctx)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // GetDeviceDeploymentLog provides a mock function with given fields: ctx, deviceID, deploymentID
 func (_m *DeviceDeploymentLogsStorage) GetDeviceDeploymentLog(ctx context.Context, deviceID string, deploymentID string) (*deployments.DeploymentLog, error) {
 	ret := _m.Called(ctx, deviceID, deploymentID)
@@ -61,4 +96,27 @@ func (_m *DeviceDeploymentLogsStorage) SaveDeviceDeploymentLog(ctx context.Conte
 	return r0
 }
 
+// SearchDeviceDeploymentLogs provides a mock function with given fields: ctx, deploymentID, text
+func (_m *DeviceDeploymentLogsStorage) SearchDeviceDeploymentLogs(ctx context.Context, deploymentID string, text string) ([]string, error) {
+	ret := _m.Called(ctx, deploymentID, text)
+
+	var r0 []string
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) []string); ok {
+		r0 = rf(ctx, deploymentID, text)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]string)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string, string) error); ok {
+		r1 = rf(ctx, deploymentID, text)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 var _ model.DeviceDeploymentLogsStorage = (*DeviceDeploymentLogsStorage)(nil)
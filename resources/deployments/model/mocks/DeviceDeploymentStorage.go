@@ -19,19 +19,20 @@ import deployments "github.com/mendersoftware/deployments/resources/deployments"
 import images "github.com/mendersoftware/deployments/resources/images"
 import mock "github.com/stretchr/testify/mock"
 import model "github.com/mendersoftware/deployments/resources/deployments/model"
+import time "time"
 
 // DeviceDeploymentStorage is an autogenerated mock type for the DeviceDeploymentStorage type
 type DeviceDeploymentStorage struct {
 	mock.Mock
 }
 
-// AbortDeviceDeployments provides a mock function with given fields: ctx, deploymentID
-func (_m *DeviceDeploymentStorage) AbortDeviceDeployments(ctx context.Context, deploymentID string) error {
-	ret := _m.Called(ctx, deploymentID)
+// AbortDeviceDeployments provides a mock function with given fields: ctx, deploymentID, abort
+func (_m *DeviceDeploymentStorage) AbortDeviceDeployments(ctx context.Context, deploymentID string, abort *deployments.AbortInfo) error {
+	ret := _m.Called(ctx, deploymentID, abort)
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
-		r0 = rf(ctx, deploymentID)
+	if rf, ok := ret.Get(0).(func(context.Context, string, *deployments.AbortInfo) error); ok {
+		r0 = rf(ctx, deploymentID, abort)
 	} else {
 		r0 = ret.Error(0)
 	}
@@ -39,6 +40,29 @@ func (_m *DeviceDeploymentStorage) AbortDeviceDeployments(ctx context.Context, d
 	return r0
 }
 
+// AggregateDeviceDeploymentByPhase provides a mock function with given fields: ctx, id
+func (_m *DeviceDeploymentStorage) AggregateDeviceDeploymentByPhase(ctx context.Context, id string) (map[int]deployments.Stats, error) {
+	ret := _m.Called(ctx, id)
+
+	var r0 map[int]deployments.Stats
+	if rf, ok := ret.Get(0).(func(context.Context, string) map[int]deployments.Stats); ok {
+		r0 = rf(ctx, id)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(map[int]deployments.Stats)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // AggregateDeviceDeploymentByStatus provides a mock function with given fields: ctx, id
 func (_m *DeviceDeploymentStorage) AggregateDeviceDeploymentByStatus(ctx context.Context, id string) (deployments.Stats, error) {
 	ret := _m.Called(ctx, id)
@@ -62,6 +86,73 @@ func (_m *DeviceDeploymentStorage) AggregateDeviceDeploymentByStatus(ctx context
 	return r0, r1
 }
 
+// AggregateDeviceDeploymentDurations provides a mock function with given fields: ctx, id
+func (_m *DeviceDeploymentStorage) AggregateDeviceDeploymentDurations(ctx context.Context, id string) (deployments.DurationStats, error) {
+	ret := _m.Called(ctx, id)
+
+	var r0 deployments.DurationStats
+	if rf, ok := ret.Get(0).(func(context.Context, string) deployments.DurationStats); ok {
+		r0 = rf(ctx, id)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(deployments.DurationStats)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// AggregateDeviceDeploymentFailures provides a mock function with given fields: ctx, id
+func (_m *DeviceDeploymentStorage) AggregateDeviceDeploymentFailures(ctx context.Context, id string) (deployments.FailureStats, error) {
+	ret := _m.Called(ctx, id)
+
+	var r0 deployments.FailureStats
+	if rf, ok := ret.Get(0).(func(context.Context, string) deployments.FailureStats); ok {
+		r0 = rf(ctx, id)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(deployments.FailureStats)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// AnonymizeDeviceDeployments provides a mock function with given fields: ctx, deviceID, anonymousID
+func (_m *DeviceDeploymentStorage) AnonymizeDeviceDeployments(ctx context.Context, deviceID string, anonymousID string) (int, error) {
+	ret := _m.Called(ctx, deviceID, anonymousID)
+
+	var r0 int
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) int); ok {
+		r0 = rf(ctx, deviceID, anonymousID)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string, string) error); ok {
+		r1 = rf(ctx, deviceID, anonymousID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // AssignArtifact provides a mock function with given fields: ctx, deviceID, deploymentID, artifact
 func (_m *DeviceDeploymentStorage) AssignArtifact(ctx context.Context, deviceID string, deploymentID string, artifact *images.SoftwareImage) error {
 	ret := _m.Called(ctx, deviceID, deploymentID, artifact)
@@ -76,6 +167,55 @@ func (_m *DeviceDeploymentStorage) AssignArtifact(ctx context.Context, deviceID
 	return r0
 }
 
+// AverageDeviceDeploymentDuration provides a mock function with given fields: ctx, since
+func (_m *DeviceDeploymentStorage) AverageDeviceDeploymentDuration(ctx context.Context, since time.Time) (time.Duration, int, error) {
+	ret := _m.Called(ctx, since)
+
+	var r0 time.Duration
+	if rf, ok := ret.Get(0).(func(context.Context, time.Time) time.Duration); ok {
+		r0 = rf(ctx, since)
+	} else {
+		r0 = ret.Get(0).(time.Duration)
+	}
+
+	var r1 int
+	if rf, ok := ret.Get(1).(func(context.Context, time.Time) int); ok {
+		r1 = rf(ctx, since)
+	} else {
+		r1 = ret.Get(1).(int)
+	}
+
+	var r2 error
+	if rf, ok := ret.Get(2).(func(context.Context, time.Time) error); ok {
+		r2 = rf(ctx, since)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
+// CountDeviceDeployments provides a mock function with given fields: ctx, deploymentID, status
+func (_m *DeviceDeploymentStorage) CountDeviceDeployments(ctx context.Context, deploymentID string, status string) (int, error) {
+	ret := _m.Called(ctx, deploymentID, status)
+
+	var r0 int
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) int); ok {
+		r0 = rf(ctx, deploymentID, status)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string, string) error); ok {
+		r1 = rf(ctx, deploymentID, status)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // DecommissionDeviceDeployments provides a mock function with given fields: ctx, deviceId
 func (_m *DeviceDeploymentStorage) DecommissionDeviceDeployments(ctx context.Context, deviceId string) error {
 	ret := _m.Called(ctx, deviceId)
@@ -134,6 +274,121 @@ func (_m *DeviceDeploymentStorage) FindAllDeploymentsForDeviceIDWithStatuses(ctx
 	return r0, r1
 }
 
+// FindAppliedStatusUpdates provides a mock function with given fields: ctx, updates
+func (_m *DeviceDeploymentStorage) FindAppliedStatusUpdates(ctx context.Context, updates []deployments.DeviceDeploymentStatusUpdate) ([]string, error) {
+	ret := _m.Called(ctx, updates)
+
+	var r0 []string
+	if rf, ok := ret.Get(0).(func(context.Context, []deployments.DeviceDeploymentStatusUpdate) []string); ok {
+		r0 = rf(ctx, updates)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]string)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, []deployments.DeviceDeploymentStatusUpdate) error); ok {
+		r1 = rf(ctx, updates)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// FindDeploymentsForDeviceIDsWithStatuses provides a mock function with given fields: ctx, deviceIDs, statuses
+func (_m *DeviceDeploymentStorage) FindDeploymentsForDeviceIDsWithStatuses(ctx context.Context, deviceIDs []string, statuses ...string) ([]deployments.DeviceDeployment, error) {
+	ret := _m.Called(ctx, deviceIDs, statuses)
+
+	var r0 []deployments.DeviceDeployment
+	if rf, ok := ret.Get(0).(func(context.Context, []string, ...string) []deployments.DeviceDeployment); ok {
+		r0 = rf(ctx, deviceIDs, statuses...)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]deployments.DeviceDeployment)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, []string, ...string) error); ok {
+		r1 = rf(ctx, deviceIDs, statuses...)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// FindDeviceDeployments provides a mock function with given fields: ctx, query
+func (_m *DeviceDeploymentStorage) FindDeviceDeployments(ctx context.Context, query deployments.DeviceDeploymentsQuery) ([]deployments.DeviceDeployment, error) {
+	ret := _m.Called(ctx, query)
+
+	var r0 []deployments.DeviceDeployment
+	if rf, ok := ret.Get(0).(func(context.Context, deployments.DeviceDeploymentsQuery) []deployments.DeviceDeployment); ok {
+		r0 = rf(ctx, query)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]deployments.DeviceDeployment)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, deployments.DeviceDeploymentsQuery) error); ok {
+		r1 = rf(ctx, query)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// FindDeviceIDsWithStatuses provides a mock function with given fields: ctx, deviceIDs, statuses
+func (_m *DeviceDeploymentStorage) FindDeviceIDsWithStatuses(ctx context.Context, deviceIDs []string, statuses ...string) ([]string, error) {
+	ret := _m.Called(ctx, deviceIDs, statuses)
+
+	var r0 []string
+	if rf, ok := ret.Get(0).(func(context.Context, []string, ...string) []string); ok {
+		r0 = rf(ctx, deviceIDs, statuses...)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]string)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, []string, ...string) error); ok {
+		r1 = rf(ctx, deviceIDs, statuses...)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// FindLatestDeploymentForDeviceID provides a mock function with given fields: ctx, deviceID, deploymentIDs
+func (_m *DeviceDeploymentStorage) FindLatestDeploymentForDeviceID(ctx context.Context, deviceID string, deploymentIDs ...string) (*deployments.DeviceDeployment, error) {
+	ret := _m.Called(ctx, deviceID, deploymentIDs)
+
+	var r0 *deployments.DeviceDeployment
+	if rf, ok := ret.Get(0).(func(context.Context, string, ...string) *deployments.DeviceDeployment); ok {
+		r0 = rf(ctx, deviceID, deploymentIDs...)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*deployments.DeviceDeployment)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string, ...string) error); ok {
+		r1 = rf(ctx, deviceID, deploymentIDs...)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // FindOldestDeploymentForDeviceIDWithStatuses provides a mock function with given fields: ctx, deviceID, statuses
 func (_m *DeviceDeploymentStorage) FindOldestDeploymentForDeviceIDWithStatuses(ctx context.Context, deviceID string, statuses ...string) (*deployments.DeviceDeployment, error) {
 	ret := _m.Called(ctx, deviceID, statuses)
@@ -157,6 +412,20 @@ func (_m *DeviceDeploymentStorage) FindOldestDeploymentForDeviceIDWithStatuses(c
 	return r0, r1
 }
 
+// FinishDeviceDeployments provides a mock function with given fields: ctx, deploymentID, status, finished
+func (_m *DeviceDeploymentStorage) FinishDeviceDeployments(ctx context.Context, deploymentID string, status string, finished time.Time) error {
+	ret := _m.Called(ctx, deploymentID, status, finished)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, time.Time) error); ok {
+		r0 = rf(ctx, deploymentID, status, finished)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // GetDeviceDeploymentStatus provides a mock function with given fields: ctx, deploymentID, deviceID
 func (_m *DeviceDeploymentStorage) GetDeviceDeploymentStatus(ctx context.Context, deploymentID string, deviceID string) (string, error) {
 	ret := _m.Called(ctx, deploymentID, deviceID)
@@ -222,6 +491,48 @@ func (_m *DeviceDeploymentStorage) HasDeploymentForDevice(ctx context.Context, d
 	return r0, r1
 }
 
+// IncrementDeviceDeploymentAttempts provides a mock function with given fields: ctx, deviceID, deploymentID
+func (_m *DeviceDeploymentStorage) IncrementDeviceDeploymentAttempts(ctx context.Context, deviceID string, deploymentID string) error {
+	ret := _m.Called(ctx, deviceID, deploymentID)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) error); ok {
+		r0 = rf(ctx, deviceID, deploymentID)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// IncrementDeviceDeploymentIgnoredReports provides a mock function with given fields: ctx, deviceID, deploymentID
+func (_m *DeviceDeploymentStorage) IncrementDeviceDeploymentIgnoredReports(ctx context.Context, deviceID string, deploymentID string) error {
+	ret := _m.Called(ctx, deviceID, deploymentID)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) error); ok {
+		r0 = rf(ctx, deviceID, deploymentID)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// IncrementDeviceDeploymentStatusResets provides a mock function with given fields: ctx, deviceID, deploymentID
+func (_m *DeviceDeploymentStorage) IncrementDeviceDeploymentStatusResets(ctx context.Context, deviceID string, deploymentID string) error {
+	ret := _m.Called(ctx, deviceID, deploymentID)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) error); ok {
+		r0 = rf(ctx, deviceID, deploymentID)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // InsertMany provides a mock function with given fields: ctx, deployment
 func (_m *DeviceDeploymentStorage) InsertMany(ctx context.Context, deployment ...*deployments.DeviceDeployment) error {
 	ret := _m.Called(ctx, deployment)
@@ -236,6 +547,92 @@ func (_m *DeviceDeploymentStorage) InsertMany(ctx context.Context, deployment ..
 	return r0
 }
 
+// PartitionDeviceDeployments provides a mock function with given fields: ctx, finishedBefore
+func (_m *DeviceDeploymentStorage) PartitionDeviceDeployments(ctx context.Context, finishedBefore time.Time) (int, error) {
+	ret := _m.Called(ctx, finishedBefore)
+
+	var r0 int
+	if rf, ok := ret.Get(0).(func(context.Context, time.Time) int); ok {
+		r0 = rf(ctx, finishedBefore)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, time.Time) error); ok {
+		r1 = rf(ctx, finishedBefore)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// RetryDeviceDeployment provides a mock function with given fields: ctx, deviceID, deploymentID, from
+func (_m *DeviceDeploymentStorage) RetryDeviceDeployment(ctx context.Context, deviceID string, deploymentID string, from string) error {
+	ret := _m.Called(ctx, deviceID, deploymentID, from)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, string) error); ok {
+		r0 = rf(ctx, deviceID, deploymentID, from)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// RevokeDeviceDeploymentLink provides a mock function with given fields: ctx, deviceID, deploymentID
+func (_m *DeviceDeploymentStorage) RevokeDeviceDeploymentLink(ctx context.Context, deviceID string, deploymentID string) error {
+	ret := _m.Called(ctx, deviceID, deploymentID)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) error); ok {
+		r0 = rf(ctx, deviceID, deploymentID)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// SaveDeviceDeploymentInventory provides a mock function with given fields: ctx, deviceID, deploymentID, inventory
+func (_m *DeviceDeploymentStorage) SaveDeviceDeploymentInventory(ctx context.Context, deviceID string, deploymentID string, inventory map[string]string) error {
+	ret := _m.Called(ctx, deviceID, deploymentID, inventory)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, map[string]string) error); ok {
+		r0 = rf(ctx, deviceID, deploymentID, inventory)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// SupersedeDeviceDeployments provides a mock function with given fields: ctx, deploymentID, deviceIDs
+func (_m *DeviceDeploymentStorage) SupersedeDeviceDeployments(ctx context.Context, deploymentID string, deviceIDs []string) ([]string, error) {
+	ret := _m.Called(ctx, deploymentID, deviceIDs)
+
+	var r0 []string
+	if rf, ok := ret.Get(0).(func(context.Context, string, []string) []string); ok {
+		r0 = rf(ctx, deploymentID, deviceIDs)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]string)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string, []string) error); ok {
+		r1 = rf(ctx, deploymentID, deviceIDs)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // UpdateDeviceDeploymentLogAvailability provides a mock function with given fields: ctx, deviceID, deploymentID, log
 func (_m *DeviceDeploymentStorage) UpdateDeviceDeploymentLogAvailability(ctx context.Context, deviceID string, deploymentID string, log bool) error {
 	ret := _m.Called(ctx, deviceID, deploymentID, log)
@@ -271,4 +668,25 @@ func (_m *DeviceDeploymentStorage) UpdateDeviceDeploymentStatus(ctx context.Cont
 	return r0, r1
 }
 
+// UpdateDeviceDeploymentStatuses provides a mock function with given fields: ctx, updates
+func (_m *DeviceDeploymentStorage) UpdateDeviceDeploymentStatuses(ctx context.Context, updates []deployments.DeviceDeploymentStatusUpdate) (int, error) {
+	ret := _m.Called(ctx, updates)
+
+	var r0 int
+	if rf, ok := ret.Get(0).(func(context.Context, []deployments.DeviceDeploymentStatusUpdate) int); ok {
+		r0 = rf(ctx, updates)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, []deployments.DeviceDeploymentStatusUpdate) error); ok {
+		r1 = rf(ctx, updates)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 var _ model.DeviceDeploymentStorage = (*DeviceDeploymentStorage)(nil)
@@ -0,0 +1,75 @@
+// Copyright 2018 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package mocks
+
+import context "context"
+import deployments "github.com/mendersoftware/deployments/resources/deployments"
+import mock "github.com/stretchr/testify/mock"
+
+// DeviceTypeAliasesStorage is an autogenerated mock type for the DeviceTypeAliasesStorage type
+type DeviceTypeAliasesStorage struct {
+	mock.Mock
+}
+
+// DeleteDeviceTypeAlias provides a mock function with given fields: ctx, alias
+func (_m *DeviceTypeAliasesStorage) DeleteDeviceTypeAlias(ctx context.Context, alias string) error {
+	ret := _m.Called(ctx, alias)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, alias)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// FindDeviceTypeAliases provides a mock function with given fields: ctx
+func (_m *DeviceTypeAliasesStorage) FindDeviceTypeAliases(ctx context.Context) ([]*deployments.DeviceTypeAlias, error) {
+	ret := _m.Called(ctx)
+
+	var r0 []*deployments.DeviceTypeAlias
+	if rf, ok := ret.Get(0).(func(context.Context) []*deployments.DeviceTypeAlias); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*deployments.DeviceTypeAlias)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// UpsertDeviceTypeAlias provides a mock function with given fields: ctx, alias
+func (_m *DeviceTypeAliasesStorage) UpsertDeviceTypeAlias(ctx context.Context, alias *deployments.DeviceTypeAlias) error {
+	ret := _m.Called(ctx, alias)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *deployments.DeviceTypeAlias) error); ok {
+		r0 = rf(ctx, alias)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
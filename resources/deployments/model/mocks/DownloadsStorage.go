@@ -0,0 +1,82 @@
+// Copyright 2018 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package mocks
+
+import context "context"
+import deployments "github.com/mendersoftware/deployments/resources/deployments"
+import mock "github.com/stretchr/testify/mock"
+
+// DownloadsStorage is an autogenerated mock type for the DownloadsStorage type
+type DownloadsStorage struct {
+	mock.Mock
+}
+
+// DeleteDeviceDownloads provides a mock function with given fields: ctx, deviceID
+func (_m *DownloadsStorage) DeleteDeviceDownloads(ctx context.Context, deviceID string) (int, error) {
+	ret := _m.Called(ctx, deviceID)
+
+	var r0 int
+	if rf, ok := ret.Get(0).(func(context.Context, string) int); ok {
+		r0 = rf(ctx, deviceID)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, deviceID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// FindDownloads provides a mock function with given fields: ctx, query
+func (_m *DownloadsStorage) FindDownloads(ctx context.Context, query deployments.DownloadsQuery) ([]deployments.Download, error) {
+	ret := _m.Called(ctx, query)
+
+	var r0 []deployments.Download
+	if rf, ok := ret.Get(0).(func(context.Context, deployments.DownloadsQuery) []deployments.Download); ok {
+		r0 = rf(ctx, query)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]deployments.Download)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, deployments.DownloadsQuery) error); ok {
+		r1 = rf(ctx, query)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// InsertDownload provides a mock function with given fields: ctx, download
+func (_m *DownloadsStorage) InsertDownload(ctx context.Context, download *deployments.Download) error {
+	ret := _m.Called(ctx, download)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *deployments.Download) error); ok {
+		r0 = rf(ctx, download)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
@@ -0,0 +1,28 @@
+// Copyright 2018 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package mocks
+
+import context "context"
+import mock "github.com/stretchr/testify/mock"
+
+// EgressRecorder is an autogenerated mock type for the EgressRecorder type
+type EgressRecorder struct {
+	mock.Mock
+}
+
+// RecordEgress provides a mock function with given fields: ctx, bytes
+func (_m *EgressRecorder) RecordEgress(ctx context.Context, bytes int64) {
+	_m.Called(ctx, bytes)
+}
@@ -0,0 +1,99 @@
+// Copyright 2018 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package mocks
+
+import context "context"
+import deployments "github.com/mendersoftware/deployments/resources/deployments"
+import mock "github.com/stretchr/testify/mock"
+import time "time"
+
+// FreezePeriodsStorage is an autogenerated mock type for the FreezePeriodsStorage type
+type FreezePeriodsStorage struct {
+	mock.Mock
+}
+
+// DeleteFreezePeriod provides a mock function with given fields: ctx, id
+func (_m *FreezePeriodsStorage) DeleteFreezePeriod(ctx context.Context, id string) error {
+	ret := _m.Called(ctx, id)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// FindActiveFreezePeriod provides a mock function with given fields: ctx, at
+func (_m *FreezePeriodsStorage) FindActiveFreezePeriod(ctx context.Context, at time.Time) (*deployments.FreezePeriod, error) {
+	ret := _m.Called(ctx, at)
+
+	var r0 *deployments.FreezePeriod
+	if rf, ok := ret.Get(0).(func(context.Context, time.Time) *deployments.FreezePeriod); ok {
+		r0 = rf(ctx, at)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*deployments.FreezePeriod)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, time.Time) error); ok {
+		r1 = rf(ctx, at)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// FindFreezePeriods provides a mock function with given fields: ctx
+func (_m *FreezePeriodsStorage) FindFreezePeriods(ctx context.Context) ([]*deployments.FreezePeriod, error) {
+	ret := _m.Called(ctx)
+
+	var r0 []*deployments.FreezePeriod
+	if rf, ok := ret.Get(0).(func(context.Context) []*deployments.FreezePeriod); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*deployments.FreezePeriod)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// InsertFreezePeriod provides a mock function with given fields: ctx, period
+func (_m *FreezePeriodsStorage) InsertFreezePeriod(ctx context.Context, period *deployments.FreezePeriod) error {
+	ret := _m.Called(ctx, period)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *deployments.FreezePeriod) error); ok {
+		r0 = rf(ctx, period)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
@@ -0,0 +1,98 @@
+// Copyright 2018 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package mocks
+
+import context "context"
+import deployments "github.com/mendersoftware/deployments/resources/deployments"
+import mock "github.com/stretchr/testify/mock"
+
+// InstalledArtifactsStorage is an autogenerated mock type for the InstalledArtifactsStorage type
+type InstalledArtifactsStorage struct {
+	mock.Mock
+}
+
+// DeleteInstalledArtifact provides a mock function with given fields: ctx, deviceID
+func (_m *InstalledArtifactsStorage) DeleteInstalledArtifact(ctx context.Context, deviceID string) error {
+	ret := _m.Called(ctx, deviceID)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, deviceID)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// FindInstalledArtifactsByName provides a mock function with given fields: ctx, name
+func (_m *InstalledArtifactsStorage) FindInstalledArtifactsByName(ctx context.Context, name string) ([]*deployments.InstalledArtifact, error) {
+	ret := _m.Called(ctx, name)
+
+	var r0 []*deployments.InstalledArtifact
+	if rf, ok := ret.Get(0).(func(context.Context, string) []*deployments.InstalledArtifact); ok {
+		r0 = rf(ctx, name)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*deployments.InstalledArtifact)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, name)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// FindInstalledArtifactsByNamePrefix provides a mock function with given fields: ctx, prefix
+func (_m *InstalledArtifactsStorage) FindInstalledArtifactsByNamePrefix(ctx context.Context, prefix string) ([]*deployments.InstalledArtifact, error) {
+	ret := _m.Called(ctx, prefix)
+
+	var r0 []*deployments.InstalledArtifact
+	if rf, ok := ret.Get(0).(func(context.Context, string) []*deployments.InstalledArtifact); ok {
+		r0 = rf(ctx, prefix)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*deployments.InstalledArtifact)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, prefix)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// UpsertInstalledArtifact provides a mock function with given fields: ctx, installed
+func (_m *InstalledArtifactsStorage) UpsertInstalledArtifact(ctx context.Context, installed *deployments.InstalledArtifact) error {
+	ret := _m.Called(ctx, installed)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *deployments.InstalledArtifact) error); ok {
+		r0 = rf(ctx, installed)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
@@ -0,0 +1,91 @@
+// Copyright 2018 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package mocks
+
+import context "context"
+import integration "github.com/mendersoftware/deployments/integration"
+import mock "github.com/stretchr/testify/mock"
+
+// Inventory is an autogenerated mock type for the Inventory type
+type Inventory struct {
+	mock.Mock
+}
+
+// FindDevicesByAttribute provides a mock function with given fields: ctx, name, value
+func (_m *Inventory) FindDevicesByAttribute(ctx context.Context, name string, value string) ([]integration.DeviceID, error) {
+	ret := _m.Called(ctx, name, value)
+
+	var r0 []integration.DeviceID
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) []integration.DeviceID); ok {
+		r0 = rf(ctx, name, value)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]integration.DeviceID)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string, string) error); ok {
+		r1 = rf(ctx, name, value)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetDeviceGroup provides a mock function with given fields: ctx, id
+func (_m *Inventory) GetDeviceGroup(ctx context.Context, id integration.DeviceID) (string, error) {
+	ret := _m.Called(ctx, id)
+
+	var r0 string
+	if rf, ok := ret.Get(0).(func(context.Context, integration.DeviceID) string); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, integration.DeviceID) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetDeviceInventory provides a mock function with given fields: ctx, id
+func (_m *Inventory) GetDeviceInventory(ctx context.Context, id integration.DeviceID) (*integration.Device, error) {
+	ret := _m.Called(ctx, id)
+
+	var r0 *integration.Device
+	if rf, ok := ret.Get(0).(func(context.Context, integration.DeviceID) *integration.Device); ok {
+		r0 = rf(ctx, id)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*integration.Device)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, integration.DeviceID) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
@@ -0,0 +1,47 @@
+// Copyright 2018 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package mocks
+
+import context "context"
+import limits "github.com/mendersoftware/deployments/resources/limits"
+import mock "github.com/stretchr/testify/mock"
+
+// LimitsGetter is an autogenerated mock type for the LimitsGetter type
+type LimitsGetter struct {
+	mock.Mock
+}
+
+// GetLimit provides a mock function with given fields: ctx, name
+func (_m *LimitsGetter) GetLimit(ctx context.Context, name string) (*limits.Limit, error) {
+	ret := _m.Called(ctx, name)
+
+	var r0 *limits.Limit
+	if rf, ok := ret.Get(0).(func(context.Context, string) *limits.Limit); ok {
+		r0 = rf(ctx, name)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*limits.Limit)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, name)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
@@ -0,0 +1,38 @@
+// Copyright 2018 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package mocks
+
+import context "context"
+import deployments "github.com/mendersoftware/deployments/resources/deployments"
+import mock "github.com/stretchr/testify/mock"
+
+// PreCreateHook is an autogenerated mock type for the PreCreateHook type
+type PreCreateHook struct {
+	mock.Mock
+}
+
+// PreCreate provides a mock function with given fields: ctx, constructor
+func (_m *PreCreateHook) PreCreate(ctx context.Context, constructor *deployments.DeploymentConstructor) error {
+	ret := _m.Called(ctx, constructor)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *deployments.DeploymentConstructor) error); ok {
+		r0 = rf(ctx, constructor)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
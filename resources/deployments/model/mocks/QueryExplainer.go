@@ -0,0 +1,48 @@
+// Copyright 2018 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package mocks
+
+import context "context"
+import deployments "github.com/mendersoftware/deployments/resources/deployments"
+import mock "github.com/stretchr/testify/mock"
+import time "time"
+
+// QueryExplainer is an autogenerated mock type for the QueryExplainer type
+type QueryExplainer struct {
+	mock.Mock
+}
+
+// ExplainSlowQueries provides a mock function with given fields: ctx, since
+func (_m *QueryExplainer) ExplainSlowQueries(ctx context.Context, since time.Time) ([]deployments.SlowQuery, error) {
+	ret := _m.Called(ctx, since)
+
+	var r0 []deployments.SlowQuery
+	if rf, ok := ret.Get(0).(func(context.Context, time.Time) []deployments.SlowQuery); ok {
+		r0 = rf(ctx, since)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]deployments.SlowQuery)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, time.Time) error); ok {
+		r1 = rf(ctx, since)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
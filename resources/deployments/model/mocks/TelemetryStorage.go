@@ -0,0 +1,82 @@
+// Copyright 2018 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package mocks
+
+import context "context"
+import deployments "github.com/mendersoftware/deployments/resources/deployments"
+import mock "github.com/stretchr/testify/mock"
+
+// TelemetryStorage is an autogenerated mock type for the TelemetryStorage type
+type TelemetryStorage struct {
+	mock.Mock
+}
+
+// AggregateTelemetry provides a mock function with given fields: ctx, deploymentID
+func (_m *TelemetryStorage) AggregateTelemetry(ctx context.Context, deploymentID string) (deployments.TelemetryStats, error) {
+	ret := _m.Called(ctx, deploymentID)
+
+	var r0 deployments.TelemetryStats
+	if rf, ok := ret.Get(0).(func(context.Context, string) deployments.TelemetryStats); ok {
+		r0 = rf(ctx, deploymentID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(deployments.TelemetryStats)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, deploymentID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// DeleteDeviceTelemetry provides a mock function with given fields: ctx, deviceID
+func (_m *TelemetryStorage) DeleteDeviceTelemetry(ctx context.Context, deviceID string) (int, error) {
+	ret := _m.Called(ctx, deviceID)
+
+	var r0 int
+	if rf, ok := ret.Get(0).(func(context.Context, string) int); ok {
+		r0 = rf(ctx, deviceID)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, deviceID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// InsertTelemetrySample provides a mock function with given fields: ctx, sample
+func (_m *TelemetryStorage) InsertTelemetrySample(ctx context.Context, sample *deployments.TelemetrySample) error {
+	ret := _m.Called(ctx, sample)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *deployments.TelemetrySample) error); ok {
+		r0 = rf(ctx, sample)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
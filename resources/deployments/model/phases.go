@@ -0,0 +1,206 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"context"
+	"time"
+
+	"github.com/mendersoftware/go-lib-micro/identity"
+	"github.com/mendersoftware/go-lib-micro/log"
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/deployments/resources/deployments"
+)
+
+// Devices of a phased deployment are assigned to its phases on creation and
+// are not served the deployment before their phase starts, whether or not
+// the phase was activated. Activation only publishes the start of the phase
+// and notifies its devices, so that they do not wait for the next update
+// check.
+
+// ActivatePhases activates started phases of deployments of the tenant in
+// the context.
+func (d *DeploymentsModel) ActivatePhases(ctx context.Context) error {
+	now := time.Now()
+
+	found, err := d.deploymentsStorage.FindWithStartedPhases(ctx, now)
+	if err != nil {
+		return errors.Wrap(err, "Searching for deployments with started phases")
+	}
+
+	for _, deployment := range found {
+		if deployment.DeploymentConstructor == nil {
+			continue
+		}
+		for i, phase := range deployment.Phases {
+			if phase.Activated != nil || !deployment.IsPhaseStarted(i, now) {
+				continue
+			}
+
+			activated, err := d.deploymentsStorage.ActivatePhase(ctx,
+				*deployment.Id, i, now)
+			if err != nil {
+				return errors.Wrap(err, "Activating deployment phase")
+			}
+			// activated by another instance of the service
+			if !activated {
+				continue
+			}
+
+			d.notifyPhaseStarted(ctx, *deployment.Id, i)
+		}
+	}
+
+	return nil
+}
+
+// notifyPhaseStarted publishes start of the phase and notifies pending
+// devices of the phase; notifications are best effort, devices get the
+// deployment on their next update check anyway.
+func (d *DeploymentsModel) notifyPhaseStarted(ctx context.Context,
+	deploymentID string, phase int) {
+
+	l := log.FromContext(ctx)
+
+	if d.eventPublisher != nil {
+		event := deployments.NewEvent(deployments.EventDeploymentPhaseStarted,
+			deploymentID)
+		event.Phase = &phase
+		if err := d.eventPublisher.Publish(ctx, event); err != nil {
+			l.Warnf("failed to publish start of phase %d of deployment %s: %v",
+				phase, deploymentID, err)
+		}
+	}
+
+	if d.deviceNotifier == nil {
+		return
+	}
+
+	deviceDeployments, err := d.deviceDeploymentsStorage.GetDeviceStatusesForDeployment(ctx,
+		deploymentID)
+	if err != nil {
+		l.Warnf("failed to find devices of phase %d of deployment %s: %v",
+			phase, deploymentID, err)
+		return
+	}
+
+	for _, deviceDeployment := range deviceDeployments {
+		if deviceDeployment.Phase != phase || deviceDeployment.DeviceId == nil ||
+			deviceDeployment.Status == nil ||
+			*deviceDeployment.Status != deployments.DeviceDeploymentStatusPending {
+			continue
+		}
+
+		event := deployments.NewEvent(deployments.EventDeviceDeploymentPhaseStarted,
+			deploymentID)
+		event.DeviceID = *deviceDeployment.DeviceId
+		event.Phase = &phase
+		if err := d.deviceNotifier.Publish(ctx, event); err != nil {
+			l.Warnf("failed to notify device %s of phase %d of deployment %s: %v",
+				*deviceDeployment.DeviceId, phase, deploymentID, err)
+		}
+	}
+}
+
+// StartPhases activates started phases of deployments of all tenants every
+// interval until the context is done.
+func (d *DeploymentsModel) StartPhases(ctx context.Context, interval time.Duration,
+	tenants TenantLister) {
+
+	go func() {
+		l := log.FromContext(ctx)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := d.activateTenantsPhases(ctx, tenants); err != nil {
+					l.Errorf("activating deployment phases: %s", err.Error())
+				}
+			}
+		}
+	}()
+}
+
+func (d *DeploymentsModel) activateTenantsPhases(ctx context.Context,
+	tenants TenantLister) error {
+
+	ids, err := tenants.ListTenants(ctx)
+	if err != nil {
+		return errors.Wrap(err, "listing tenants")
+	}
+
+	// default database is used when multi-tenancy is off; failure of
+	// a single tenant does not stop activation for the others
+	l := log.FromContext(ctx)
+	for _, tenantID := range append([]string{""}, ids...) {
+		tctx := ctx
+		if tenantID != "" {
+			tctx = identity.WithContext(ctx, &identity.Identity{Tenant: tenantID})
+		}
+
+		if err := d.ActivatePhases(tctx); err != nil {
+			l.Errorf("activating deployment phases of tenant %s: %s",
+				tenantID, err.Error())
+		}
+	}
+
+	return nil
+}
+
+// GetDeploymentPhaseStats returns device deployment statistics of each
+// phase of the deployment, empty if the deployment is not phased, nil if
+// the deployment does not exist.
+func (d *DeploymentsModel) GetDeploymentPhaseStats(ctx context.Context,
+	deploymentID string) ([]deployments.PhaseStats, error) {
+
+	deployment, err := d.deploymentsStorage.FindByID(ctx, deploymentID)
+	if err != nil {
+		return nil, errors.Wrap(err, "checking deployment id")
+	}
+
+	if deployment == nil {
+		return nil, nil
+	}
+
+	stats := []deployments.PhaseStats{}
+	if deployment.DeploymentConstructor == nil || len(deployment.Phases) == 0 {
+		return stats, nil
+	}
+
+	byPhase, err := d.deviceDeploymentsStorage.AggregateDeviceDeploymentByPhase(ctx,
+		deploymentID)
+	if err != nil {
+		return nil, errors.Wrap(err, "counting device deployments by phase")
+	}
+
+	for i, phase := range deployment.Phases {
+		phaseStats := byPhase[i]
+		if phaseStats == nil {
+			phaseStats = deployments.NewDeviceDeploymentStats()
+		}
+		stats = append(stats, deployments.PhaseStats{
+			DeploymentPhase: phase,
+			Phase:           i,
+			Stats:           phaseStats,
+		})
+	}
+
+	return stats, nil
+}
@@ -0,0 +1,535 @@
+// Copyright 2026 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/mendersoftware/deployments/resources/deployments"
+	. "github.com/mendersoftware/deployments/resources/deployments/model"
+	"github.com/mendersoftware/deployments/resources/deployments/model/mocks"
+	"github.com/mendersoftware/deployments/resources/images"
+	. "github.com/mendersoftware/deployments/utils/pointers"
+	h "github.com/mendersoftware/deployments/utils/testing"
+)
+
+func TestDeploymentModelCreateDeploymentPhases(t *testing.T) {
+
+	later := time.Now().Add(24 * time.Hour)
+
+	var inserted *deployments.Deployment
+	deploymentStorage := new(mocks.DeploymentsStorage)
+	deploymentStorage.On("FindBySlug",
+		h.ContextMatcher(), mock.AnythingOfType("string")).
+		Return(nil, nil)
+	deploymentStorage.On("Insert",
+		h.ContextMatcher(),
+		mock.AnythingOfType("*deployments.Deployment")).
+		Run(func(args mock.Arguments) {
+			inserted = args.Get(1).(*deployments.Deployment)
+		}).
+		Return(nil)
+
+	var deviceDeployments []*deployments.DeviceDeployment
+	deviceDeploymentStorage := new(mocks.DeviceDeploymentStorage)
+	deviceDeploymentStorage.On("InsertMany",
+		h.ContextMatcher(),
+		mock.AnythingOfType("[]*deployments.DeviceDeployment")).
+		Run(func(args mock.Arguments) {
+			deviceDeployments = args.Get(1).([]*deployments.DeviceDeployment)
+		}).
+		Return(nil)
+
+	artifactGetter := new(mocks.ArtifactGetter)
+	artifactGetter.On("ImagesByName",
+		h.ContextMatcher(), "App 123").
+		Return([]*images.SoftwareImage{{Id: validUUIDv4}}, nil)
+
+	// phased deployments are never lazy
+	model := NewDeploymentModel(DeploymentsModelConfig{
+		DeploymentsStorage:       deploymentStorage,
+		DeviceDeploymentsStorage: deviceDeploymentStorage,
+		DeploymentDevicesStorage: new(mocks.DeploymentDevicesStorage),
+		LazyDevicesThreshold:     2,
+		ArtifactGetter:           artifactGetter,
+	})
+
+	_, err := model.CreateDeployment(context.Background(),
+		&deployments.DeploymentConstructor{
+			Name:         StringToPointer("NYC Production"),
+			ArtifactName: StringToPointer("App 123"),
+			Devices:      []string{"device-1", "device-2", "device-3", "device-4"},
+			Phases: []deployments.DeploymentPhase{
+				{BatchSize: 25},
+				{StartTs: &later},
+			},
+		})
+	assert.NoError(t, err)
+
+	if assert.NotNil(t, inserted) {
+		assert.Equal(t, []deployments.DeploymentPhase{
+			{
+				BatchSize:   25,
+				StartTs:     inserted.Created,
+				DeviceCount: 1,
+				Activated:   inserted.Created,
+			},
+			{StartTs: &later, DeviceCount: 3},
+		}, inserted.Phases)
+	}
+
+	phases := map[string]int{}
+	for _, deviceDeployment := range deviceDeployments {
+		phases[*deviceDeployment.DeviceId] = deviceDeployment.Phase
+	}
+	assert.Equal(t, map[string]int{
+		"device-1": 0,
+		"device-2": 1,
+		"device-3": 1,
+		"device-4": 1,
+	}, phases)
+}
+
+func TestDeploymentModelGetDeploymentForDeviceWithCurrentPhases(t *testing.T) {
+
+	earlier := time.Now().Add(-time.Hour)
+	later := time.Now().Add(time.Hour)
+
+	testCases := map[string]struct {
+		InputStart *time.Time
+
+		OutputError string
+	}{
+		"phase not started": {
+			InputStart: &later,
+		},
+		"phase started": {
+			InputStart: &earlier,
+
+			// already installed artifact is reported for the device
+			OutputError: "Failed to update deployment status: storage issue",
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+
+			deviceDeploymentStorage := new(mocks.DeviceDeploymentStorage)
+			deviceDeploymentStorage.On("FindOldestDeploymentForDeviceIDWithStatuses",
+				h.ContextMatcher(), "device-1", mock.Anything).
+				Return(&deployments.DeviceDeployment{
+					DeploymentId: StringToPointer(validUUIDv4),
+					Phase:        1,
+				}, nil)
+			deviceDeploymentStorage.On("GetDeviceDeploymentStatus",
+				h.ContextMatcher(), validUUIDv4, "device-1").
+				Return("", errors.New("storage issue"))
+
+			deploymentStorage := new(mocks.DeploymentsStorage)
+			deploymentStorage.On("FindByID", h.ContextMatcher(), validUUIDv4).
+				Return(&deployments.Deployment{
+					Id: StringToPointer(validUUIDv4),
+					DeploymentConstructor: &deployments.DeploymentConstructor{
+						ArtifactName: StringToPointer("app-1.2.0"),
+						Phases: []deployments.DeploymentPhase{
+							{BatchSize: 10, StartTs: &earlier},
+							{StartTs: testCase.InputStart},
+						},
+					},
+				}, nil)
+
+			model := NewDeploymentModel(DeploymentsModelConfig{
+				DeploymentsStorage:       deploymentStorage,
+				DeviceDeploymentsStorage: deviceDeploymentStorage,
+			})
+
+			out, err := model.GetDeploymentForDeviceWithCurrent(context.Background(),
+				"device-1", deployments.InstalledDeviceDeployment{
+					Artifact:   "app-1.2.0",
+					DeviceType: "rpi4",
+				})
+			assert.Nil(t, out)
+			if testCase.OutputError != "" {
+				assert.EqualError(t, err, testCase.OutputError)
+			} else {
+				assert.NoError(t, err)
+				deviceDeploymentStorage.AssertNotCalled(t, "GetDeviceDeploymentStatus",
+					mock.Anything, mock.Anything, mock.Anything)
+			}
+		})
+	}
+}
+
+func TestDeploymentModelActivatePhases(t *testing.T) {
+
+	earlier := time.Now().Add(-time.Hour)
+	later := time.Now().Add(time.Hour)
+
+	deployment := &deployments.Deployment{
+		Id: StringToPointer(validUUIDv4),
+		DeploymentConstructor: &deployments.DeploymentConstructor{
+			Phases: []deployments.DeploymentPhase{
+				{BatchSize: 10, StartTs: &earlier, Activated: &earlier},
+				{BatchSize: 40, StartTs: &earlier},
+				{StartTs: &later},
+			},
+		},
+	}
+
+	testCases := map[string]struct {
+		InputFindError     error
+		InputActivated     bool
+		InputActivateError error
+
+		OutputNotified []string
+		OutputError    string
+	}{
+		"activated": {
+			InputActivated: true,
+
+			OutputNotified: []string{"device-2"},
+		},
+		"activated by another instance": {},
+		"find error": {
+			InputFindError: errors.New("storage issue"),
+
+			OutputError: "Searching for deployments with started phases: storage issue",
+		},
+		"activate error": {
+			InputActivateError: errors.New("storage issue"),
+
+			OutputError: "Activating deployment phase: storage issue",
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+
+			deploymentStorage := new(mocks.DeploymentsStorage)
+			deploymentStorage.On("FindWithStartedPhases",
+				h.ContextMatcher(), mock.AnythingOfType("time.Time")).
+				Return([]*deployments.Deployment{deployment}, testCase.InputFindError)
+			deploymentStorage.On("ActivatePhase",
+				h.ContextMatcher(), validUUIDv4, 1, mock.AnythingOfType("time.Time")).
+				Return(testCase.InputActivated, testCase.InputActivateError)
+
+			// only pending devices of the phase are notified
+			deviceDeploymentStorage := new(mocks.DeviceDeploymentStorage)
+			deviceDeploymentStorage.On("GetDeviceStatusesForDeployment",
+				h.ContextMatcher(), validUUIDv4).
+				Return([]deployments.DeviceDeployment{
+					{
+						DeviceId: StringToPointer("device-1"),
+						Status:   StringToPointer(deployments.DeviceDeploymentStatusPending),
+					},
+					{
+						DeviceId: StringToPointer("device-2"),
+						Status:   StringToPointer(deployments.DeviceDeploymentStatusPending),
+						Phase:    1,
+					},
+					{
+						DeviceId: StringToPointer("device-3"),
+						Status:   StringToPointer(deployments.DeviceDeploymentStatusAborted),
+						Phase:    1,
+					},
+					{
+						DeviceId: StringToPointer("device-4"),
+						Status:   StringToPointer(deployments.DeviceDeploymentStatusPending),
+						Phase:    2,
+					},
+				}, nil)
+
+			eventPublisher := new(mocks.EventPublisher)
+			eventPublisher.On("Publish", h.ContextMatcher(),
+				mock.AnythingOfType("*deployments.Event")).
+				Return(nil)
+
+			deviceNotifier := new(mocks.EventPublisher)
+			deviceNotifier.On("Publish", h.ContextMatcher(),
+				mock.AnythingOfType("*deployments.Event")).
+				Return(errors.New("notification issue"))
+
+			model := NewDeploymentModel(DeploymentsModelConfig{
+				DeploymentsStorage:       deploymentStorage,
+				DeviceDeploymentsStorage: deviceDeploymentStorage,
+				EventPublisher:           eventPublisher,
+				DeviceNotifier:           deviceNotifier,
+			})
+
+			err := model.ActivatePhases(context.Background())
+			if testCase.OutputError != "" {
+				assert.EqualError(t, err, testCase.OutputError)
+			} else {
+				assert.NoError(t, err)
+			}
+
+			if testCase.OutputNotified == nil {
+				eventPublisher.AssertNotCalled(t, "Publish", mock.Anything, mock.Anything)
+				deviceNotifier.AssertNotCalled(t, "Publish", mock.Anything, mock.Anything)
+				return
+			}
+
+			eventPublisher.AssertNumberOfCalls(t, "Publish", 1)
+			event := eventPublisher.Calls[0].Arguments.Get(1).(*deployments.Event)
+			assert.Equal(t, deployments.EventDeploymentPhaseStarted, event.Type)
+			assert.Equal(t, validUUIDv4, event.DeploymentID)
+			if assert.NotNil(t, event.Phase) {
+				assert.Equal(t, 1, *event.Phase)
+			}
+
+			var notified []string
+			for _, call := range deviceNotifier.Calls {
+				event := call.Arguments.Get(1).(*deployments.Event)
+				assert.Equal(t, deployments.EventDeviceDeploymentPhaseStarted, event.Type)
+				notified = append(notified, event.DeviceID)
+			}
+			assert.Equal(t, testCase.OutputNotified, notified)
+		})
+	}
+}
+
+func TestDeploymentModelGetDeploymentPhaseStats(t *testing.T) {
+
+	start := time.Date(2018, 5, 1, 10, 0, 0, 0, time.UTC)
+	phases := []deployments.DeploymentPhase{
+		{BatchSize: 10, StartTs: &start, DeviceCount: 1, Activated: &start},
+		{DeviceCount: 9},
+	}
+
+	first := deployments.NewDeviceDeploymentStats()
+	first[deployments.DeviceDeploymentStatusSuccess] = 1
+
+	testCases := map[string]struct {
+		InputDeployment     *deployments.Deployment
+		InputDeploymentErr  error
+		InputAggregate      map[int]deployments.Stats
+		InputAggregateError error
+
+		OutputStats []deployments.PhaseStats
+		OutputError string
+	}{
+		"ok": {
+			InputDeployment: &deployments.Deployment{
+				DeploymentConstructor: &deployments.DeploymentConstructor{
+					Phases: phases,
+				},
+			},
+			InputAggregate: map[int]deployments.Stats{0: first},
+
+			OutputStats: []deployments.PhaseStats{
+				{DeploymentPhase: phases[0], Phase: 0, Stats: first},
+				{
+					DeploymentPhase: phases[1],
+					Phase:           1,
+					Stats:           deployments.NewDeviceDeploymentStats(),
+				},
+			},
+		},
+		"not phased": {
+			InputDeployment: &deployments.Deployment{
+				DeploymentConstructor: &deployments.DeploymentConstructor{},
+			},
+
+			OutputStats: []deployments.PhaseStats{},
+		},
+		"not found": {},
+		"deployment error": {
+			InputDeploymentErr: errors.New("storage issue"),
+
+			OutputError: "checking deployment id: storage issue",
+		},
+		"aggregate error": {
+			InputDeployment: &deployments.Deployment{
+				DeploymentConstructor: &deployments.DeploymentConstructor{
+					Phases: phases,
+				},
+			},
+			InputAggregateError: errors.New("storage issue"),
+
+			OutputError: "counting device deployments by phase: storage issue",
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+
+			deploymentStorage := new(mocks.DeploymentsStorage)
+			deploymentStorage.On("FindByID", h.ContextMatcher(), validUUIDv4).
+				Return(testCase.InputDeployment, testCase.InputDeploymentErr)
+
+			deviceDeploymentStorage := new(mocks.DeviceDeploymentStorage)
+			deviceDeploymentStorage.On("AggregateDeviceDeploymentByPhase",
+				h.ContextMatcher(), validUUIDv4).
+				Return(testCase.InputAggregate, testCase.InputAggregateError)
+
+			model := NewDeploymentModel(DeploymentsModelConfig{
+				DeploymentsStorage:       deploymentStorage,
+				DeviceDeploymentsStorage: deviceDeploymentStorage,
+			})
+
+			stats, err := model.GetDeploymentPhaseStats(context.Background(), validUUIDv4)
+			if testCase.OutputError != "" {
+				assert.EqualError(t, err, testCase.OutputError)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, testCase.OutputStats, stats)
+		})
+	}
+}
+
+func TestDeploymentModelGetDeploymentForDeviceWithCurrentWaiting(t *testing.T) {
+
+	earlier := time.Now().Add(-time.Hour)
+	later := time.Now().Add(48 * time.Hour)
+	older := time.Now().Add(-2 * time.Hour)
+	newer := time.Now().Add(-time.Minute)
+
+	deploymentA := "b532b01a-9313-404f-8d19-e7fcbe5cc34a"
+	deploymentB := "b532b01a-9313-404f-8d19-e7fcbe5cc34b"
+
+	testCases := map[string]struct {
+		InputStatus string
+		InputPaused bool
+		InputStart  *time.Time
+		InputNewer  *time.Time
+
+		OutputDeployment string
+	}{
+		"oldest phase not started": {
+			InputStatus: deployments.DeviceDeploymentStatusPending,
+			InputStart:  &later,
+			InputNewer:  &earlier,
+
+			OutputDeployment: deploymentB,
+		},
+		"oldest paused": {
+			InputStatus: deployments.DeviceDeploymentStatusPending,
+			InputPaused: true,
+			InputStart:  &earlier,
+			InputNewer:  &earlier,
+
+			OutputDeployment: deploymentB,
+		},
+		"oldest started": {
+			InputStatus: deployments.DeviceDeploymentStatusPending,
+			InputStart:  &earlier,
+			InputNewer:  &earlier,
+
+			OutputDeployment: deploymentA,
+		},
+		"oldest paused while downloading": {
+			InputStatus: deployments.DeviceDeploymentStatusDownloading,
+			InputPaused: true,
+			InputStart:  &earlier,
+			InputNewer:  &earlier,
+		},
+		"none started": {
+			InputStatus: deployments.DeviceDeploymentStatusPending,
+			InputStart:  &later,
+			InputNewer:  &later,
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+
+			oldest := deployments.DeviceDeployment{
+				Id:           StringToPointer("a"),
+				DeviceId:     StringToPointer("device-1"),
+				DeploymentId: StringToPointer(deploymentA),
+				Status:       StringToPointer(testCase.InputStatus),
+				Created:      &older,
+				Phase:        1,
+			}
+			next := deployments.DeviceDeployment{
+				Id:           StringToPointer("b"),
+				DeviceId:     StringToPointer("device-1"),
+				DeploymentId: StringToPointer(deploymentB),
+				Status:       StringToPointer(deployments.DeviceDeploymentStatusPending),
+				Created:      &newer,
+				Phase:        1,
+			}
+
+			deviceDeploymentStorage := new(mocks.DeviceDeploymentStorage)
+			deviceDeploymentStorage.On("FindOldestDeploymentForDeviceIDWithStatuses",
+				h.ContextMatcher(), "device-1", mock.Anything).
+				Return(&oldest, nil)
+			deviceDeploymentStorage.On("FindAllDeploymentsForDeviceIDWithStatuses",
+				h.ContextMatcher(), "device-1", []string{deployments.DeviceDeploymentStatusPending}).
+				Return([]deployments.DeviceDeployment{next, oldest}, nil)
+			// the served deployment is already installed, status update fails
+			deviceDeploymentStorage.On("GetDeviceDeploymentStatus",
+				h.ContextMatcher(), mock.AnythingOfType("string"), "device-1").
+				Return("", errors.New("storage issue"))
+
+			deploymentStorage := new(mocks.DeploymentsStorage)
+			deploymentStorage.On("FindByID", h.ContextMatcher(), deploymentA).
+				Return(&deployments.Deployment{
+					Id:     StringToPointer(deploymentA),
+					Paused: testCase.InputPaused,
+					DeploymentConstructor: &deployments.DeploymentConstructor{
+						ArtifactName: StringToPointer("app-1.2.0"),
+						Phases: []deployments.DeploymentPhase{
+							{BatchSize: 10, StartTs: &earlier},
+							{StartTs: testCase.InputStart},
+						},
+					},
+				}, nil)
+			deploymentStorage.On("FindByID", h.ContextMatcher(), deploymentB).
+				Return(&deployments.Deployment{
+					Id: StringToPointer(deploymentB),
+					DeploymentConstructor: &deployments.DeploymentConstructor{
+						ArtifactName: StringToPointer("app-1.2.0"),
+						Phases: []deployments.DeploymentPhase{
+							{BatchSize: 10, StartTs: &earlier},
+							{StartTs: testCase.InputNewer},
+						},
+					},
+				}, nil)
+
+			model := NewDeploymentModel(DeploymentsModelConfig{
+				DeploymentsStorage:       deploymentStorage,
+				DeviceDeploymentsStorage: deviceDeploymentStorage,
+			})
+
+			out, err := model.GetDeploymentForDeviceWithCurrent(context.Background(),
+				"device-1", deployments.InstalledDeviceDeployment{
+					Artifact:   "app-1.2.0",
+					DeviceType: "rpi4",
+				})
+			assert.Nil(t, out)
+			if testCase.OutputDeployment != "" {
+				assert.EqualError(t, err, "Failed to update deployment status: storage issue")
+				deviceDeploymentStorage.AssertCalled(t, "GetDeviceDeploymentStatus",
+					h.ContextMatcher(), testCase.OutputDeployment, "device-1")
+			} else {
+				assert.NoError(t, err)
+				deviceDeploymentStorage.AssertNotCalled(t, "GetDeviceDeploymentStatus",
+					mock.Anything, mock.Anything, mock.Anything)
+			}
+			if testCase.InputStatus != deployments.DeviceDeploymentStatusPending {
+				deviceDeploymentStorage.AssertNotCalled(t, "FindAllDeploymentsForDeviceIDWithStatuses",
+					mock.Anything, mock.Anything, mock.Anything)
+			}
+		})
+	}
+}
@@ -0,0 +1,37 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"context"
+
+	"github.com/mendersoftware/deployments/resources/deployments"
+)
+
+// PreCreateHook is invoked before a new deployment is created.
+// The hook may modify the constructor, or veto the deployment by returning
+// an error with controller.ErrPolicyRejected cause.
+type PreCreateHook interface {
+	PreCreate(ctx context.Context, constructor *deployments.DeploymentConstructor) error
+}
+
+// PreServeHook is invoked before deployment instructions are sent to the device.
+// The hook may modify the instructions, or veto serving the deployment
+// (device gets no update this time) by returning an error with
+// controller.ErrPolicyRejected cause.
+type PreServeHook interface {
+	PreServe(ctx context.Context, deviceID string,
+		instructions *deployments.DeploymentInstructions) error
+}
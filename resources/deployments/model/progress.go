@@ -0,0 +1,62 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"context"
+
+	"github.com/mendersoftware/go-lib-micro/identity"
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/deployments/resources/deployments"
+)
+
+// ActiveDeploymentsProgress counts devices of at most limit unfinished
+// deployments of the tenant in context by state, in progress deployments
+// first, newest first.
+func (d *DeploymentsModel) ActiveDeploymentsProgress(ctx context.Context,
+	limit int) ([]deployments.DeploymentProgress, error) {
+
+	var tenantID string
+	if id := identity.FromContext(ctx); id != nil {
+		tenantID = id.Tenant
+	}
+
+	progress := []deployments.DeploymentProgress{}
+	for _, status := range []deployments.StatusQuery{
+		deployments.StatusQueryInProgress,
+		deployments.StatusQueryPending,
+	} {
+		if len(progress) >= limit {
+			break
+		}
+
+		list, err := d.deploymentsStorage.Find(ctx, deployments.Query{
+			Status: status,
+			Limit:  limit - len(progress),
+		})
+		if err != nil {
+			return nil, errors.Wrap(err, "searching for active deployments")
+		}
+
+		for _, deployment := range list {
+			p := deployments.NewDeploymentProgress(deployment)
+			p.TenantID = tenantID
+			progress = append(progress, p)
+		}
+	}
+
+	return progress, nil
+}
@@ -0,0 +1,166 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/mendersoftware/go-lib-micro/identity"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mendersoftware/deployments/resources/deployments"
+	. "github.com/mendersoftware/deployments/resources/deployments/model"
+	"github.com/mendersoftware/deployments/resources/deployments/model/mocks"
+	h "github.com/mendersoftware/deployments/utils/testing"
+)
+
+func TestDeploymentModelActiveDeploymentsProgress(t *testing.T) {
+
+	inProgressID := "a108ae14-bb4e-455f-9b40-2ef4bab97bb7"
+	pendingID := "d1804903-5caa-4a73-a3ae-0efcc3205405"
+	name := "foo"
+
+	inProgress := &deployments.Deployment{
+		Id:                    &inProgressID,
+		DeploymentConstructor: &deployments.DeploymentConstructor{Name: &name},
+		Stats: map[string]int{
+			deployments.DeviceDeploymentStatusPending:     1,
+			deployments.DeviceDeploymentStatusDownloading: 1,
+			deployments.DeviceDeploymentStatusSuccess:     2,
+		},
+	}
+	pending := &deployments.Deployment{
+		Id:                    &pendingID,
+		DeploymentConstructor: &deployments.DeploymentConstructor{Name: &name},
+		Stats: map[string]int{
+			deployments.DeviceDeploymentStatusPending: 3,
+		},
+	}
+
+	testCases := map[string]struct {
+		InputLimit  int
+		InputTenant string
+
+		InProgress      []*deployments.Deployment
+		InProgressError error
+		Pending         []*deployments.Deployment
+		PendingError    error
+
+		OutputProgress []deployments.DeploymentProgress
+		OutputError    string
+	}{
+		"ok": {
+			InputLimit: 10,
+			InProgress: []*deployments.Deployment{inProgress},
+			Pending:    []*deployments.Deployment{pending},
+			OutputProgress: []deployments.DeploymentProgress{
+				{
+					DeploymentID: inProgressID,
+					Name:         name,
+					Pending:      1,
+					InProgress:   1,
+					Success:      2,
+					Total:        4,
+				},
+				{
+					DeploymentID: pendingID,
+					Name:         name,
+					Pending:      3,
+					Total:        3,
+				},
+			},
+		},
+		"ok, tenant": {
+			InputLimit:  10,
+			InputTenant: "acme",
+			Pending:     []*deployments.Deployment{pending},
+			OutputProgress: []deployments.DeploymentProgress{
+				{
+					TenantID:     "acme",
+					DeploymentID: pendingID,
+					Name:         name,
+					Pending:      3,
+					Total:        3,
+				},
+			},
+		},
+		"ok, limit reached": {
+			InputLimit: 1,
+			InProgress: []*deployments.Deployment{inProgress},
+			OutputProgress: []deployments.DeploymentProgress{
+				{
+					DeploymentID: inProgressID,
+					Name:         name,
+					Pending:      1,
+					InProgress:   1,
+					Success:      2,
+					Total:        4,
+				},
+			},
+		},
+		"ok, disabled": {
+			OutputProgress: []deployments.DeploymentProgress{},
+		},
+		"error": {
+			InputLimit:   10,
+			PendingError: errors.New("connection failed"),
+			OutputError:  "searching for active deployments: connection failed",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+
+			deploymentsStorage := new(mocks.DeploymentsStorage)
+			deploymentsStorage.On("Find", h.ContextMatcher(), deployments.Query{
+				Status: deployments.StatusQueryInProgress,
+				Limit:  tc.InputLimit,
+			}).Return(tc.InProgress, tc.InProgressError)
+			deploymentsStorage.On("Find", h.ContextMatcher(), deployments.Query{
+				Status: deployments.StatusQueryPending,
+				Limit:  tc.InputLimit - len(tc.InProgress),
+			}).Return(tc.Pending, tc.PendingError)
+
+			model := NewDeploymentModel(DeploymentsModelConfig{
+				DeploymentsStorage: deploymentsStorage,
+			})
+
+			ctx := context.Background()
+			if tc.InputTenant != "" {
+				ctx = identity.WithContext(ctx, &identity.Identity{
+					Tenant: tc.InputTenant,
+				})
+			}
+
+			progress, err := model.ActiveDeploymentsProgress(ctx, tc.InputLimit)
+			if tc.OutputError != "" {
+				assert.EqualError(t, err, tc.OutputError)
+				assert.Nil(t, progress)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tc.OutputProgress, progress)
+			}
+			if tc.InputLimit <= len(tc.InProgress) {
+				deploymentsStorage.AssertNotCalled(t, "Find", h.ContextMatcher(),
+					deployments.Query{
+						Status: deployments.StatusQueryPending,
+						Limit:  0,
+					})
+			}
+		})
+	}
+}
@@ -0,0 +1,88 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"context"
+
+	"github.com/mendersoftware/go-lib-micro/identity"
+	"github.com/mendersoftware/go-lib-micro/log"
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/deployments/resources/deployments"
+	"github.com/mendersoftware/deployments/resources/limits"
+)
+
+// LimitsGetter gives limits of the tenant.
+type LimitsGetter interface {
+	GetLimit(ctx context.Context, name string) (*limits.Limit, error)
+}
+
+// checkDevicesLimit returns *limits.ExceededError if the deployment
+// targets more devices than the tenant is allowed to, unless the grace
+// behavior of the limit allows it; the exceeded quota is returned then.
+func (d *DeploymentsModel) checkDevicesLimit(ctx context.Context,
+	constructor *deployments.DeploymentConstructor) (*deployments.QuotaExceeded, error) {
+
+	if d.limits == nil {
+		return nil, nil
+	}
+
+	limit, err := d.limits.GetLimit(ctx, limits.LimitDevicesPerDeployment)
+	if err != nil {
+		return nil, errors.Wrap(err, "Checking devices limit")
+	}
+
+	usage := uint64(len(constructor.Devices))
+	if err := limit.Check(usage); err == nil || !limit.Warns() {
+		return nil, err
+	}
+
+	log.FromContext(ctx).Warnf("deployment %q targets %d devices over the %s limit of %d",
+		*constructor.Name, usage, limit.Name, limit.Value)
+
+	return &deployments.QuotaExceeded{
+		Limit:    limit.Name,
+		Value:    limit.Value,
+		Usage:    usage,
+		Decision: limits.GraceWarn,
+	}, nil
+}
+
+// OverQuotaDeployments returns deployments created over a tenant limit
+// matching the query, newest first.
+func (d *DeploymentsModel) OverQuotaDeployments(ctx context.Context,
+	query deployments.Query) ([]deployments.OverQuotaDeployment, error) {
+
+	var tenantID string
+	if id := identity.FromContext(ctx); id != nil {
+		tenantID = id.Tenant
+	}
+
+	query.OverQuota = true
+	list, err := d.deploymentsStorage.Find(ctx, query)
+	if err != nil {
+		return nil, errors.Wrap(err, "searching for deployments over quota")
+	}
+
+	report := make([]deployments.OverQuotaDeployment, 0, len(list))
+	for _, deployment := range list {
+		o := deployments.NewOverQuotaDeployment(deployment)
+		o.TenantID = tenantID
+		report = append(report, o)
+	}
+
+	return report, nil
+}
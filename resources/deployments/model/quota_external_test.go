@@ -0,0 +1,232 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mendersoftware/go-lib-micro/identity"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/mendersoftware/deployments/resources/deployments"
+	. "github.com/mendersoftware/deployments/resources/deployments/model"
+	"github.com/mendersoftware/deployments/resources/deployments/model/mocks"
+	"github.com/mendersoftware/deployments/resources/images"
+	"github.com/mendersoftware/deployments/resources/limits"
+	. "github.com/mendersoftware/deployments/utils/pointers"
+	h "github.com/mendersoftware/deployments/utils/testing"
+)
+
+func TestDeploymentModelCreateDeploymentDevicesLimit(t *testing.T) {
+
+	testCases := map[string]struct {
+		InputLimit      *limits.Limit
+		InputLimitError error
+
+		OutputQuota *deployments.QuotaExceeded
+		OutputError error
+	}{
+		"ok, no limit": {},
+		"ok, within limit": {
+			InputLimit: &limits.Limit{Name: limits.LimitDevicesPerDeployment, Value: 2},
+		},
+		"over limit, rejected": {
+			InputLimit: &limits.Limit{Name: limits.LimitDevicesPerDeployment, Value: 1},
+
+			OutputError: errors.New("devices_per_deployment limit of 1 exceeded"),
+		},
+		"over limit, explicitly rejected": {
+			InputLimit: &limits.Limit{
+				Name:  limits.LimitDevicesPerDeployment,
+				Value: 1,
+				Grace: limits.GraceReject,
+			},
+
+			OutputError: errors.New("devices_per_deployment limit of 1 exceeded"),
+		},
+		"over limit, warned": {
+			InputLimit: &limits.Limit{
+				Name:  limits.LimitDevicesPerDeployment,
+				Value: 1,
+				Grace: limits.GraceWarn,
+			},
+
+			OutputQuota: &deployments.QuotaExceeded{
+				Limit:    limits.LimitDevicesPerDeployment,
+				Value:    1,
+				Usage:    2,
+				Decision: limits.GraceWarn,
+			},
+		},
+		"limit error": {
+			InputLimit:      &limits.Limit{},
+			InputLimitError: errors.New("storage issue"),
+
+			OutputError: errors.New("Checking devices limit: storage issue"),
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+
+			var inserted *deployments.Deployment
+			deploymentStorage := new(mocks.DeploymentsStorage)
+			deploymentStorage.On("FindBySlug",
+				h.ContextMatcher(), mock.AnythingOfType("string")).
+				Return(nil, nil)
+			deploymentStorage.On("Insert",
+				h.ContextMatcher(),
+				mock.AnythingOfType("*deployments.Deployment")).
+				Run(func(args mock.Arguments) {
+					inserted = args.Get(1).(*deployments.Deployment)
+				}).
+				Return(nil)
+
+			deviceDeploymentStorage := new(mocks.DeviceDeploymentStorage)
+			deviceDeploymentStorage.On("InsertMany",
+				h.ContextMatcher(),
+				mock.AnythingOfType("[]*deployments.DeviceDeployment")).
+				Return(nil)
+
+			artifactGetter := new(mocks.ArtifactGetter)
+			artifactGetter.On("ImagesByName",
+				h.ContextMatcher(), "App 123").
+				Return([]*images.SoftwareImage{
+					images.NewSoftwareImage(
+						validUUIDv4,
+						&images.SoftwareImageMetaConstructor{},
+						&images.SoftwareImageMetaArtifactConstructor{
+							Name:                  "App 123",
+							DeviceTypesCompatible: []string{"hammer"},
+						}),
+				}, nil)
+
+			config := DeploymentsModelConfig{
+				DeploymentsStorage:       deploymentStorage,
+				DeviceDeploymentsStorage: deviceDeploymentStorage,
+				ArtifactGetter:           artifactGetter,
+			}
+			if testCase.InputLimit != nil {
+				limitsGetter := new(mocks.LimitsGetter)
+				limitsGetter.On("GetLimit",
+					h.ContextMatcher(), limits.LimitDevicesPerDeployment).
+					Return(testCase.InputLimit, testCase.InputLimitError)
+				config.Limits = limitsGetter
+			}
+			model := NewDeploymentModel(config)
+
+			_, err := model.CreateDeployment(context.Background(),
+				&deployments.DeploymentConstructor{
+					Name:         StringToPointer("NYC Production"),
+					ArtifactName: StringToPointer("App 123"),
+					Devices: []string{
+						"b532b01a-9313-404f-8d19-e7fcbe5cc347",
+						"c532b01a-9313-404f-8d19-e7fcbe5cc347",
+					},
+				})
+			if testCase.OutputError != nil {
+				assert.EqualError(t, err, testCase.OutputError.Error())
+				deploymentStorage.AssertNotCalled(t, "Insert", mock.Anything, mock.Anything)
+				return
+			}
+
+			assert.NoError(t, err)
+			if assert.NotNil(t, inserted) {
+				assert.Equal(t, testCase.OutputQuota, inserted.OverQuota)
+			}
+		})
+	}
+}
+
+func TestDeploymentModelOverQuotaDeployments(t *testing.T) {
+
+	id := "a108ae14-bb4e-455f-9b40-2ef4bab97bb7"
+	name := "foo"
+	created := time.Now()
+	quota := &deployments.QuotaExceeded{
+		Limit:    limits.LimitDevicesPerDeployment,
+		Value:    1,
+		Usage:    2,
+		Decision: limits.GraceWarn,
+	}
+
+	testCases := map[string]struct {
+		InputTenant string
+		Found       []*deployments.Deployment
+		FindError   error
+
+		Output      []deployments.OverQuotaDeployment
+		OutputError error
+	}{
+		"ok": {
+			InputTenant: "acme",
+			Found: []*deployments.Deployment{{
+				Id:                    &id,
+				DeploymentConstructor: &deployments.DeploymentConstructor{Name: &name},
+				Created:               &created,
+				OverQuota:             quota,
+			}},
+
+			Output: []deployments.OverQuotaDeployment{{
+				TenantID:     "acme",
+				DeploymentID: id,
+				Name:         name,
+				Created:      created,
+				Quota:        *quota,
+			}},
+		},
+		"none": {
+			Output: []deployments.OverQuotaDeployment{},
+		},
+		"storage error": {
+			FindError: errors.New("storage issue"),
+
+			OutputError: errors.New("searching for deployments over quota: storage issue"),
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			if tc.InputTenant != "" {
+				ctx = identity.WithContext(ctx, &identity.Identity{Tenant: tc.InputTenant})
+			}
+
+			deploymentStorage := new(mocks.DeploymentsStorage)
+			deploymentStorage.On("Find", h.ContextMatcher(),
+				mock.MatchedBy(func(q deployments.Query) bool {
+					return q.OverQuota && q.Limit == 10
+				})).
+				Return(tc.Found, tc.FindError)
+
+			model := NewDeploymentModel(DeploymentsModelConfig{
+				DeploymentsStorage: deploymentStorage,
+			})
+
+			report, err := model.OverQuotaDeployments(ctx, deployments.Query{Limit: 10})
+			if tc.OutputError != nil {
+				assert.EqualError(t, err, tc.OutputError.Error())
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tc.Output, report)
+		})
+	}
+}
@@ -0,0 +1,83 @@
+// Copyright 2026 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/deployments/resources/deployments"
+	"github.com/mendersoftware/deployments/resources/deployments/controller"
+)
+
+// RetryDeviceDeployment puts failed or aborted deployment of the device back
+// to pending, so that the device gets the update on its next check; the
+// deployment is reopened if it was finished.
+func (d *DeploymentsModel) RetryDeviceDeployment(ctx context.Context,
+	deploymentID, deviceID string) error {
+
+	deployment, err := d.deploymentsStorage.FindByID(ctx, deploymentID)
+	if err != nil {
+		return errors.Wrap(err, "Searching for deployment")
+	}
+	if deployment == nil {
+		return controller.ErrStorageNotFound
+	}
+
+	deviceDeployment, err := d.deviceDeploymentsStorage.FindLatestDeploymentForDeviceID(ctx,
+		deviceID, deploymentID)
+	if err != nil {
+		return errors.Wrap(err, "Searching for device deployment")
+	}
+	if deviceDeployment == nil || deviceDeployment.Status == nil {
+		return controller.ErrStorageNotFound
+	}
+
+	status := *deviceDeployment.Status
+	if status != deployments.DeviceDeploymentStatusFailure &&
+		status != deployments.DeviceDeploymentStatusAborted {
+		return controller.ErrRetryNotAllowed
+	}
+	// artifact pulled for the device is not offered again
+	if deviceDeployment.LinkRevoked {
+		return controller.ErrDownloadLinkRevoked
+	}
+	maxRetries, err := d.retryLimit(ctx)
+	if err != nil {
+		return err
+	}
+	if deviceDeployment.Retries >= maxRetries {
+		return controller.ErrRetryLimitReached
+	}
+
+	if err := d.deviceDeploymentsStorage.RetryDeviceDeployment(ctx,
+		deviceID, deploymentID, status); err != nil {
+		return errors.Wrap(err, "Retrying device deployment")
+	}
+
+	if err := d.deploymentsStorage.UpdateStats(ctx, deploymentID,
+		status, deployments.DeviceDeploymentStatusPending); err != nil {
+		return errors.Wrap(err, "Updating deployment statistics")
+	}
+
+	if deployment.Finished != nil {
+		if err := d.deploymentsStorage.Reopen(ctx, deploymentID); err != nil {
+			return errors.Wrap(err, "Reopening deployment")
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,167 @@
+// Copyright 2026 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/mendersoftware/deployments/resources/deployments"
+	"github.com/mendersoftware/deployments/resources/deployments/controller"
+	. "github.com/mendersoftware/deployments/resources/deployments/model"
+	"github.com/mendersoftware/deployments/resources/deployments/model/mocks"
+	. "github.com/mendersoftware/deployments/utils/pointers"
+	h "github.com/mendersoftware/deployments/utils/testing"
+)
+
+func TestDeploymentModelRetryDeviceDeployment(t *testing.T) {
+
+	finished := time.Now()
+	one := 1
+
+	testCases := map[string]struct {
+		InputDeployment       *deployments.Deployment
+		InputDeviceDeployment *deployments.DeviceDeployment
+		InputRetryError       error
+		InputSettings         *deployments.DeploymentSettings
+
+		OutputReopen bool
+		OutputError  error
+	}{
+		"failed": {
+			InputDeployment: &deployments.Deployment{Id: StringToPointer(validUUIDv4)},
+			InputDeviceDeployment: &deployments.DeviceDeployment{
+				Status: StringToPointer(deployments.DeviceDeploymentStatusFailure),
+			},
+		},
+		"aborted, deployment finished": {
+			InputDeployment: &deployments.Deployment{
+				Id:       StringToPointer(validUUIDv4),
+				Finished: &finished,
+			},
+			InputDeviceDeployment: &deployments.DeviceDeployment{
+				Status:  StringToPointer(deployments.DeviceDeploymentStatusAborted),
+				Retries: 2,
+			},
+			OutputReopen: true,
+		},
+		"deployment not found": {
+			OutputError: controller.ErrStorageNotFound,
+		},
+		"device deployment not found": {
+			InputDeployment: &deployments.Deployment{Id: StringToPointer(validUUIDv4)},
+			OutputError:     controller.ErrStorageNotFound,
+		},
+		"not failed": {
+			InputDeployment: &deployments.Deployment{Id: StringToPointer(validUUIDv4)},
+			InputDeviceDeployment: &deployments.DeviceDeployment{
+				Status: StringToPointer(deployments.DeviceDeploymentStatusInstalling),
+			},
+			OutputError: controller.ErrRetryNotAllowed,
+		},
+		"link revoked": {
+			InputDeployment: &deployments.Deployment{Id: StringToPointer(validUUIDv4)},
+			InputDeviceDeployment: &deployments.DeviceDeployment{
+				Status:      StringToPointer(deployments.DeviceDeploymentStatusAborted),
+				LinkRevoked: true,
+			},
+			OutputError: controller.ErrDownloadLinkRevoked,
+		},
+		"limit reached": {
+			InputDeployment: &deployments.Deployment{Id: StringToPointer(validUUIDv4)},
+			InputDeviceDeployment: &deployments.DeviceDeployment{
+				Status:  StringToPointer(deployments.DeviceDeploymentStatusFailure),
+				Retries: 3,
+			},
+			OutputError: controller.ErrRetryLimitReached,
+		},
+		"tenant limit reached": {
+			InputDeployment: &deployments.Deployment{Id: StringToPointer(validUUIDv4)},
+			InputDeviceDeployment: &deployments.DeviceDeployment{
+				Status:  StringToPointer(deployments.DeviceDeploymentStatusFailure),
+				Retries: 1,
+			},
+			InputSettings: &deployments.DeploymentSettings{MaxRetries: &one},
+			OutputError:   controller.ErrRetryLimitReached,
+		},
+		"storage error": {
+			InputDeployment: &deployments.Deployment{Id: StringToPointer(validUUIDv4)},
+			InputDeviceDeployment: &deployments.DeviceDeployment{
+				Status: StringToPointer(deployments.DeviceDeploymentStatusFailure),
+			},
+			InputRetryError: errors.New("storage issue"),
+			OutputError:     errors.New("Retrying device deployment: storage issue"),
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+
+			deploymentStorage := new(mocks.DeploymentsStorage)
+			deploymentStorage.On("FindByID", h.ContextMatcher(), validUUIDv4).
+				Return(testCase.InputDeployment, nil)
+			deploymentStorage.On("UpdateStats", h.ContextMatcher(), validUUIDv4,
+				mock.AnythingOfType("string"), deployments.DeviceDeploymentStatusPending).
+				Return(nil)
+			deploymentStorage.On("Reopen", h.ContextMatcher(), validUUIDv4).
+				Return(nil)
+
+			deviceDeploymentStorage := new(mocks.DeviceDeploymentStorage)
+			deviceDeploymentStorage.On("FindLatestDeploymentForDeviceID",
+				h.ContextMatcher(), "device-1", []string{validUUIDv4}).
+				Return(testCase.InputDeviceDeployment, nil)
+			deviceDeploymentStorage.On("RetryDeviceDeployment",
+				h.ContextMatcher(), "device-1", validUUIDv4,
+				mock.AnythingOfType("string")).
+				Return(testCase.InputRetryError)
+
+			settingsStorage := new(mocks.SettingsStorage)
+			settingsStorage.On("GetDeploymentSettings", h.ContextMatcher()).
+				Return(testCase.InputSettings, nil)
+
+			model := NewDeploymentModel(DeploymentsModelConfig{
+				DeploymentsStorage:       deploymentStorage,
+				DeviceDeploymentsStorage: deviceDeploymentStorage,
+				MaxRetries:               3,
+				SettingsStorage:          settingsStorage,
+			})
+
+			err := model.RetryDeviceDeployment(context.Background(), validUUIDv4, "device-1")
+			if testCase.OutputError != nil {
+				assert.EqualError(t, err, testCase.OutputError.Error())
+				deploymentStorage.AssertNotCalled(t, "UpdateStats",
+					mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+				return
+			}
+
+			assert.NoError(t, err)
+			status := *testCase.InputDeviceDeployment.Status
+			deviceDeploymentStorage.AssertCalled(t, "RetryDeviceDeployment",
+				mock.Anything, "device-1", validUUIDv4, status)
+			deploymentStorage.AssertCalled(t, "UpdateStats",
+				mock.Anything, validUUIDv4, status, deployments.DeviceDeploymentStatusPending)
+			if testCase.OutputReopen {
+				deploymentStorage.AssertCalled(t, "Reopen", mock.Anything, validUUIDv4)
+			} else {
+				deploymentStorage.AssertNotCalled(t, "Reopen", mock.Anything, mock.Anything)
+			}
+		})
+	}
+}
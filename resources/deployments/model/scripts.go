@@ -0,0 +1,70 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"context"
+	"strings"
+
+	"github.com/mendersoftware/go-lib-micro/identity"
+
+	"github.com/mendersoftware/deployments/resources/deployments"
+	"github.com/mendersoftware/deployments/resources/images"
+)
+
+// scriptsAckRequired tells whether the tenant in context has to acknowledge
+// state scripts of deployed artifacts.
+func (d *DeploymentsModel) scriptsAckRequired(ctx context.Context) bool {
+	var tenant string
+	if id := identity.FromContext(ctx); id != nil {
+		tenant = id.Tenant
+	}
+
+	for _, t := range d.scriptsAckTenants {
+		if t == "*" || (t == tenant && tenant != "") {
+			return true
+		}
+	}
+	return false
+}
+
+// checkScriptsAck returns a validation error listing the state scripts of
+// the artifacts if the tenant has to acknowledge them.
+func (d *DeploymentsModel) checkScriptsAck(ctx context.Context,
+	artifacts []*images.SoftwareImage) error {
+
+	if !d.scriptsAckRequired(ctx) {
+		return nil
+	}
+
+	var scripts []string
+	seen := map[string]bool{}
+	for _, artifact := range artifacts {
+		for _, script := range artifact.Scripts {
+			if !seen[script] {
+				seen[script] = true
+				scripts = append(scripts, script)
+			}
+		}
+	}
+
+	if len(scripts) == 0 {
+		return nil
+	}
+
+	return deployments.NewValidationError("acknowledge_scripts",
+		deployments.ValidationCodeRequired,
+		"artifacts contain state scripts: "+strings.Join(scripts, ", "))
+}
@@ -0,0 +1,92 @@
+// Copyright 2026 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/deployments/resources/deployments"
+	"github.com/mendersoftware/deployments/resources/deployments/controller"
+)
+
+// GetDeploymentSettings returns tenant defaults of deployment parameters,
+// empty settings if none were set.
+func (d *DeploymentsModel) GetDeploymentSettings(ctx context.Context) (
+	*deployments.DeploymentSettings, error) {
+
+	settings, err := d.settingsStorage.GetDeploymentSettings(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "Searching for deployment settings")
+	}
+
+	if settings == nil {
+		settings = &deployments.DeploymentSettings{}
+	}
+
+	return settings, nil
+}
+
+// SetDeploymentSettings replaces tenant defaults of deployment parameters.
+func (d *DeploymentsModel) SetDeploymentSettings(ctx context.Context,
+	settings *deployments.DeploymentSettings) error {
+
+	if settings == nil {
+		return controller.ErrModelMissingInput
+	}
+
+	if err := settings.Validate(); err != nil {
+		return errors.Wrap(err, "Validating deployment settings")
+	}
+
+	if err := d.settingsStorage.SetDeploymentSettings(ctx, settings); err != nil {
+		return errors.Wrap(err, "Storing deployment settings")
+	}
+
+	return nil
+}
+
+// deploymentSettings returns tenant defaults of deployment parameters, nil
+// if there are none or settings are not configured.
+func (d *DeploymentsModel) deploymentSettings(ctx context.Context) (
+	*deployments.DeploymentSettings, error) {
+
+	if d.settingsStorage == nil {
+		return nil, nil
+	}
+
+	settings, err := d.settingsStorage.GetDeploymentSettings(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "Searching for deployment settings")
+	}
+
+	return settings, nil
+}
+
+// retryLimit returns the number of times device deployments of the tenant
+// can be retried.
+func (d *DeploymentsModel) retryLimit(ctx context.Context) (int, error) {
+	settings, err := d.deploymentSettings(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	if settings != nil && settings.MaxRetries != nil {
+		return *settings.MaxRetries, nil
+	}
+
+	return d.maxRetries, nil
+}
@@ -0,0 +1,214 @@
+// Copyright 2026 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/mendersoftware/deployments/resources/deployments"
+	. "github.com/mendersoftware/deployments/resources/deployments/model"
+	"github.com/mendersoftware/deployments/resources/deployments/model/mocks"
+	"github.com/mendersoftware/deployments/resources/images"
+	. "github.com/mendersoftware/deployments/utils/pointers"
+	h "github.com/mendersoftware/deployments/utils/testing"
+)
+
+func TestDeploymentModelCreateDeploymentSettings(t *testing.T) {
+
+	settings := &deployments.DeploymentSettings{
+		DeviceDeployments: deployments.DeviceDeploymentsEager,
+		DownloadSchedule:  &deployments.DownloadSchedule{MaxDownloadsPerMinute: 5},
+	}
+
+	testCases := map[string]struct {
+		InputSchedule      *deployments.DownloadSchedule
+		InputSettings      *deployments.DeploymentSettings
+		InputSettingsError error
+
+		OutputSchedule *deployments.DownloadSchedule
+		OutputError    error
+	}{
+		"no settings": {},
+		"defaults applied": {
+			InputSettings: settings,
+
+			OutputSchedule: settings.DownloadSchedule,
+		},
+		"given by constructor": {
+			InputSchedule: &deployments.DownloadSchedule{MaxDownloadsPerMinute: 50},
+			InputSettings: settings,
+
+			OutputSchedule: &deployments.DownloadSchedule{MaxDownloadsPerMinute: 50},
+		},
+		"storage error": {
+			InputSettingsError: errors.New("storage issue"),
+
+			OutputError: errors.New("Searching for deployment settings: storage issue"),
+		},
+		"device ID format matched": {
+			InputSettings: &deployments.DeploymentSettings{
+				DeviceIDFormat: &deployments.DeviceIDFormat{
+					Type: deployments.DeviceIDFormatUUID,
+				},
+			},
+		},
+		"device ID format mismatched": {
+			InputSettings: &deployments.DeploymentSettings{
+				DeviceIDFormat: &deployments.DeviceIDFormat{
+					Type: deployments.DeviceIDFormatMAC,
+				},
+			},
+
+			OutputError: errors.New("Validating deployment: devices[0]: " +
+				deployments.ErrDeviceIDFormatMismatch.Error() + ";"),
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+
+			deploymentStorage := new(mocks.DeploymentsStorage)
+			deploymentStorage.On("FindBySlug",
+				h.ContextMatcher(), mock.AnythingOfType("string")).
+				Return(nil, nil)
+			deploymentStorage.On("Insert",
+				h.ContextMatcher(),
+				mock.AnythingOfType("*deployments.Deployment")).
+				Return(nil)
+
+			deviceDeploymentStorage := new(mocks.DeviceDeploymentStorage)
+			deviceDeploymentStorage.On("InsertMany",
+				h.ContextMatcher(),
+				mock.AnythingOfType("[]*deployments.DeviceDeployment")).
+				Return(nil)
+
+			artifactGetter := new(mocks.ArtifactGetter)
+			artifactGetter.On("ImagesByName",
+				h.ContextMatcher(), "App 123").
+				Return([]*images.SoftwareImage{
+					images.NewSoftwareImage(
+						validUUIDv4,
+						&images.SoftwareImageMetaConstructor{},
+						&images.SoftwareImageMetaArtifactConstructor{
+							Name:                  "App 123",
+							DeviceTypesCompatible: []string{"hammer"},
+						}),
+				}, nil)
+
+			settingsStorage := new(mocks.SettingsStorage)
+			settingsStorage.On("GetDeploymentSettings", h.ContextMatcher()).
+				Return(testCase.InputSettings, testCase.InputSettingsError)
+
+			model := NewDeploymentModel(DeploymentsModelConfig{
+				DeploymentsStorage:       deploymentStorage,
+				DeviceDeploymentsStorage: deviceDeploymentStorage,
+				ArtifactGetter:           artifactGetter,
+				SettingsStorage:          settingsStorage,
+			})
+
+			constructor := &deployments.DeploymentConstructor{
+				Name:             StringToPointer("NYC Production"),
+				ArtifactName:     StringToPointer("App 123"),
+				Devices:          []string{"b532b01a-9313-404f-8d19-e7fcbe5cc347"},
+				DownloadSchedule: testCase.InputSchedule,
+			}
+			_, err := model.CreateDeployment(context.Background(), constructor)
+			if testCase.OutputError != nil {
+				assert.EqualError(t, err, testCase.OutputError.Error())
+				deploymentStorage.AssertNotCalled(t, "Insert", mock.Anything, mock.Anything)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, testCase.OutputSchedule, constructor.DownloadSchedule)
+			if testCase.InputSettings != nil && testCase.InputSchedule == nil {
+				assert.Equal(t, testCase.InputSettings.DeviceDeployments,
+					constructor.DeviceDeployments)
+			}
+		})
+	}
+}
+
+func TestDeploymentModelSetDeploymentSettings(t *testing.T) {
+
+	negative := -1
+
+	testCases := map[string]struct {
+		InputSettings *deployments.DeploymentSettings
+		InputError    error
+
+		OutputError error
+	}{
+		"ok": {
+			InputSettings: &deployments.DeploymentSettings{
+				ConflictPolicy: deployments.ConflictPolicySkip,
+			},
+		},
+		"invalid": {
+			InputSettings: &deployments.DeploymentSettings{MaxRetries: &negative},
+
+			OutputError: errors.New("Validating deployment settings: max_retries: " +
+				deployments.ErrInvalidMaxRetries.Error() + ";"),
+		},
+		"storage error": {
+			InputSettings: &deployments.DeploymentSettings{},
+			InputError:    errors.New("storage issue"),
+
+			OutputError: errors.New("Storing deployment settings: storage issue"),
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+
+			settingsStorage := new(mocks.SettingsStorage)
+			settingsStorage.On("SetDeploymentSettings", h.ContextMatcher(),
+				testCase.InputSettings).
+				Return(testCase.InputError)
+
+			model := NewDeploymentModel(DeploymentsModelConfig{
+				SettingsStorage: settingsStorage,
+			})
+
+			err := model.SetDeploymentSettings(context.Background(), testCase.InputSettings)
+			if testCase.OutputError != nil {
+				assert.EqualError(t, err, testCase.OutputError.Error())
+			} else {
+				assert.NoError(t, err)
+				settingsStorage.AssertExpectations(t)
+			}
+		})
+	}
+}
+
+func TestDeploymentModelGetDeploymentSettings(t *testing.T) {
+
+	settingsStorage := new(mocks.SettingsStorage)
+	settingsStorage.On("GetDeploymentSettings", h.ContextMatcher()).
+		Return(nil, nil)
+
+	model := NewDeploymentModel(DeploymentsModelConfig{
+		SettingsStorage: settingsStorage,
+	})
+
+	settings, err := model.GetDeploymentSettings(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, &deployments.DeploymentSettings{}, settings)
+}
@@ -0,0 +1,520 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"context"
+	"encoding/json"
+	"math/rand"
+	"time"
+
+	"github.com/mendersoftware/go-lib-micro/identity"
+	"github.com/mendersoftware/go-lib-micro/log"
+
+	"github.com/mendersoftware/deployments/resources/deployments"
+	"github.com/mendersoftware/deployments/resources/images"
+	"github.com/mendersoftware/deployments/utils/metrics"
+)
+
+// QueryExplainer explains how the database executed slow queries of
+// the tenant in context since the given time.
+type QueryExplainer interface {
+	ExplainSlowQueries(ctx context.Context,
+		since time.Time) ([]deployments.SlowQuery, error)
+}
+
+// SlowQueryLog records storage calls taking at least Threshold: in
+// the recorder and as logged warnings. For a sample of the slow calls
+// the queries they executed are explained and logged along, to help
+// finding missing indexes.
+type SlowQueryLog struct {
+	Threshold time.Duration
+
+	// Ratio of slow calls explained, 0 to 1
+	ExplainSampleRate float64
+
+	// Optional
+	Recorder  *metrics.Recorder
+	Explainer QueryExplainer
+
+	sample func() float64
+}
+
+// NewSlowQueryLog creates slow query log recording calls taking at least
+// threshold, explaining sampleRate of them if explainer is set.
+func NewSlowQueryLog(threshold time.Duration, sampleRate float64,
+	recorder *metrics.Recorder, explainer QueryExplainer) *SlowQueryLog {
+
+	return &SlowQueryLog{
+		Threshold:         threshold,
+		ExplainSampleRate: sampleRate,
+		Recorder:          recorder,
+		Explainer:         explainer,
+		sample:            rand.Float64,
+	}
+}
+
+func (s *SlowQueryLog) observe(ctx context.Context, method string,
+	start time.Time, err *error) {
+
+	took := time.Since(start)
+	if took < s.Threshold {
+		return
+	}
+
+	if s.Recorder != nil {
+		s.Recorder.Observe(method, took, *err)
+	}
+
+	l := log.FromContext(ctx)
+	l.Warnf("slow storage call: %s took %s", method, took)
+
+	if s.Explainer == nil || s.sample() >= s.ExplainSampleRate {
+		return
+	}
+
+	// the call may have used up the deadline of the request
+	explainCtx := context.Background()
+	if id := identity.FromContext(ctx); id != nil {
+		explainCtx = identity.WithContext(explainCtx, id)
+	}
+
+	queries, explainErr := s.Explainer.ExplainSlowQueries(explainCtx, start)
+	if explainErr != nil {
+		l.Warnf("slow storage call: %s: explaining queries: %s",
+			method, explainErr.Error())
+		return
+	}
+
+	for _, query := range queries {
+		plan, _ := json.Marshal(struct {
+			Command interface{} `json:"command,omitempty"`
+			Plan    interface{} `json:"plan,omitempty"`
+		}{query.Command, query.Plan})
+
+		l.Warnf("slow storage call: %s: %s on %s took %s, plan %s, "+
+			"examined %d keys and %d documents, returned %d: %s",
+			method, query.Operation, query.Namespace, query.Duration,
+			query.PlanSummary, query.KeysExamined, query.DocsExamined,
+			query.Returned, plan)
+	}
+}
+
+// SlowQueryDeploymentsStorage decorates deployments storage recording
+// slow calls in the slow query log.
+type SlowQueryDeploymentsStorage struct {
+	storage DeploymentsStorage
+	log     *SlowQueryLog
+}
+
+var _ DeploymentsStorage = (*SlowQueryDeploymentsStorage)(nil)
+
+func NewSlowQueryDeploymentsStorage(storage DeploymentsStorage,
+	log *SlowQueryLog) *SlowQueryDeploymentsStorage {
+
+	return &SlowQueryDeploymentsStorage{
+		storage: storage,
+		log:     log,
+	}
+}
+
+func (s *SlowQueryDeploymentsStorage) Insert(ctx context.Context,
+	deployment *deployments.Deployment) (err error) {
+	defer s.log.observe(ctx, "Deployments.Insert", time.Now(), &err)
+	return s.storage.Insert(ctx, deployment)
+}
+
+func (s *SlowQueryDeploymentsStorage) Delete(ctx context.Context, id string) (err error) {
+	defer s.log.observe(ctx, "Deployments.Delete", time.Now(), &err)
+	return s.storage.Delete(ctx, id)
+}
+
+func (s *SlowQueryDeploymentsStorage) FindByID(ctx context.Context,
+	id string) (_ *deployments.Deployment, err error) {
+	defer s.log.observe(ctx, "Deployments.FindByID", time.Now(), &err)
+	return s.storage.FindByID(ctx, id)
+}
+
+func (s *SlowQueryDeploymentsStorage) FindBySlug(ctx context.Context,
+	slug string) (_ *deployments.Deployment, err error) {
+	defer s.log.observe(ctx, "Deployments.FindBySlug", time.Now(), &err)
+	return s.storage.FindBySlug(ctx, slug)
+}
+
+func (s *SlowQueryDeploymentsStorage) FindUnfinishedByID(ctx context.Context,
+	id string) (_ *deployments.Deployment, err error) {
+	defer s.log.observe(ctx, "Deployments.FindUnfinishedByID", time.Now(), &err)
+	return s.storage.FindUnfinishedByID(ctx, id)
+}
+
+func (s *SlowQueryDeploymentsStorage) UpdateStats(ctx context.Context,
+	id string, state_from, state_to string) (err error) {
+	defer s.log.observe(ctx, "Deployments.UpdateStats", time.Now(), &err)
+	return s.storage.UpdateStats(ctx, id, state_from, state_to)
+}
+
+func (s *SlowQueryDeploymentsStorage) IncrementStats(ctx context.Context,
+	stats map[string]deployments.Stats) (err error) {
+	defer s.log.observe(ctx, "Deployments.IncrementStats", time.Now(), &err)
+	return s.storage.IncrementStats(ctx, stats)
+}
+
+func (s *SlowQueryDeploymentsStorage) UpdateStatsAndFinishDeployment(ctx context.Context,
+	id string, stats deployments.Stats) (err error) {
+	defer s.log.observe(ctx, "Deployments.UpdateStatsAndFinishDeployment",
+		time.Now(), &err)
+	return s.storage.UpdateStatsAndFinishDeployment(ctx, id, stats)
+}
+
+func (s *SlowQueryDeploymentsStorage) Find(ctx context.Context,
+	query deployments.Query) (_ []*deployments.Deployment, err error) {
+	defer s.log.observe(ctx, "Deployments.Find", time.Now(), &err)
+	return s.storage.Find(ctx, query)
+}
+
+func (s *SlowQueryDeploymentsStorage) Finish(ctx context.Context,
+	id string, when time.Time) (err error) {
+	defer s.log.observe(ctx, "Deployments.Finish", time.Now(), &err)
+	return s.storage.Finish(ctx, id, when)
+}
+
+func (s *SlowQueryDeploymentsStorage) Reopen(ctx context.Context,
+	id string) (err error) {
+	defer s.log.observe(ctx, "Deployments.Reopen", time.Now(), &err)
+	return s.storage.Reopen(ctx, id)
+}
+
+func (s *SlowQueryDeploymentsStorage) ExistUnfinishedByArtifactId(ctx context.Context,
+	id string) (_ bool, err error) {
+	defer s.log.observe(ctx, "Deployments.ExistUnfinishedByArtifactId", time.Now(), &err)
+	return s.storage.ExistUnfinishedByArtifactId(ctx, id)
+}
+
+func (s *SlowQueryDeploymentsStorage) ExistByArtifactId(ctx context.Context,
+	id string) (_ bool, err error) {
+	defer s.log.observe(ctx, "Deployments.ExistByArtifactId", time.Now(), &err)
+	return s.storage.ExistByArtifactId(ctx, id)
+}
+
+func (s *SlowQueryDeploymentsStorage) ExistByArtifactIdCreatedAfter(ctx context.Context,
+	id string, since time.Time) (_ bool, err error) {
+	defer s.log.observe(ctx, "Deployments.ExistByArtifactIdCreatedAfter",
+		time.Now(), &err)
+	return s.storage.ExistByArtifactIdCreatedAfter(ctx, id, since)
+}
+
+func (s *SlowQueryDeploymentsStorage) DeviceCountByDeployment(ctx context.Context,
+	id string) (_ int, err error) {
+	defer s.log.observe(ctx, "Deployments.DeviceCountByDeployment", time.Now(), &err)
+	return s.storage.DeviceCountByDeployment(ctx, id)
+}
+
+func (s *SlowQueryDeploymentsStorage) IncrementDownloadCount(ctx context.Context,
+	id string, period time.Time) (_ int, err error) {
+	defer s.log.observe(ctx, "Deployments.IncrementDownloadCount", time.Now(), &err)
+	return s.storage.IncrementDownloadCount(ctx, id, period)
+}
+
+func (s *SlowQueryDeploymentsStorage) SetAbortInfo(ctx context.Context,
+	id string, abort *deployments.AbortInfo) (err error) {
+	defer s.log.observe(ctx, "Deployments.SetAbortInfo", time.Now(), &err)
+	return s.storage.SetAbortInfo(ctx, id, abort)
+}
+
+func (s *SlowQueryDeploymentsStorage) SetPaused(ctx context.Context,
+	ids []string, paused bool) (err error) {
+	defer s.log.observe(ctx, "Deployments.SetPaused", time.Now(), &err)
+	return s.storage.SetPaused(ctx, ids, paused)
+}
+
+func (s *SlowQueryDeploymentsStorage) FindWithStartedPhases(ctx context.Context,
+	now time.Time) (_ []*deployments.Deployment, err error) {
+	defer s.log.observe(ctx, "Deployments.FindWithStartedPhases", time.Now(), &err)
+	return s.storage.FindWithStartedPhases(ctx, now)
+}
+
+func (s *SlowQueryDeploymentsStorage) ActivatePhase(ctx context.Context,
+	id string, phase int, when time.Time) (_ bool, err error) {
+	defer s.log.observe(ctx, "Deployments.ActivatePhase", time.Now(), &err)
+	return s.storage.ActivatePhase(ctx, id, phase, when)
+}
+
+func (s *SlowQueryDeploymentsStorage) CountByStatus(ctx context.Context,
+	status deployments.StatusQuery) (_ int, err error) {
+	defer s.log.observe(ctx, "Deployments.CountByStatus", time.Now(), &err)
+	return s.storage.CountByStatus(ctx, status)
+}
+
+func (s *SlowQueryDeploymentsStorage) IncrementStatsRollup(ctx context.Context,
+	when time.Time, status string) (err error) {
+	defer s.log.observe(ctx, "Deployments.IncrementStatsRollup", time.Now(), &err)
+	return s.storage.IncrementStatsRollup(ctx, when, status)
+}
+
+func (s *SlowQueryDeploymentsStorage) AggregateStatsRollups(ctx context.Context,
+	since time.Time) (_ deployments.Stats, err error) {
+	defer s.log.observe(ctx, "Deployments.AggregateStatsRollups", time.Now(), &err)
+	return s.storage.AggregateStatsRollups(ctx, since)
+}
+
+// SlowQueryDeviceDeploymentStorage decorates device deployments storage
+// recording slow calls in the slow query log.
+type SlowQueryDeviceDeploymentStorage struct {
+	storage DeviceDeploymentStorage
+	log     *SlowQueryLog
+}
+
+var _ DeviceDeploymentStorage = (*SlowQueryDeviceDeploymentStorage)(nil)
+
+func NewSlowQueryDeviceDeploymentStorage(storage DeviceDeploymentStorage,
+	log *SlowQueryLog) *SlowQueryDeviceDeploymentStorage {
+
+	return &SlowQueryDeviceDeploymentStorage{
+		storage: storage,
+		log:     log,
+	}
+}
+
+func (s *SlowQueryDeviceDeploymentStorage) InsertMany(ctx context.Context,
+	deployment ...*deployments.DeviceDeployment) (err error) {
+	defer s.log.observe(ctx, "DeviceDeployments.InsertMany", time.Now(), &err)
+	return s.storage.InsertMany(ctx, deployment...)
+}
+
+func (s *SlowQueryDeviceDeploymentStorage) ExistAssignedImageWithIDAndStatuses(ctx context.Context,
+	id string, statuses ...string) (_ bool, err error) {
+	defer s.log.observe(ctx, "DeviceDeployments.ExistAssignedImageWithIDAndStatuses",
+		time.Now(), &err)
+	return s.storage.ExistAssignedImageWithIDAndStatuses(ctx, id, statuses...)
+}
+
+func (s *SlowQueryDeviceDeploymentStorage) FindOldestDeploymentForDeviceIDWithStatuses(ctx context.Context,
+	deviceID string, statuses ...string) (_ *deployments.DeviceDeployment, err error) {
+	defer s.log.observe(ctx, "DeviceDeployments.FindOldestDeploymentForDeviceIDWithStatuses",
+		time.Now(), &err)
+	return s.storage.FindOldestDeploymentForDeviceIDWithStatuses(ctx, deviceID, statuses...)
+}
+
+func (s *SlowQueryDeviceDeploymentStorage) FindAllDeploymentsForDeviceIDWithStatuses(ctx context.Context,
+	deviceID string, statuses ...string) (_ []deployments.DeviceDeployment, err error) {
+	defer s.log.observe(ctx, "DeviceDeployments.FindAllDeploymentsForDeviceIDWithStatuses",
+		time.Now(), &err)
+	return s.storage.FindAllDeploymentsForDeviceIDWithStatuses(ctx, deviceID, statuses...)
+}
+
+func (s *SlowQueryDeviceDeploymentStorage) FindDeviceIDsWithStatuses(ctx context.Context,
+	deviceIDs []string, statuses ...string) (_ []string, err error) {
+	defer s.log.observe(ctx, "DeviceDeployments.FindDeviceIDsWithStatuses",
+		time.Now(), &err)
+	return s.storage.FindDeviceIDsWithStatuses(ctx, deviceIDs, statuses...)
+}
+
+func (s *SlowQueryDeviceDeploymentStorage) FindDeploymentsForDeviceIDsWithStatuses(
+	ctx context.Context, deviceIDs []string,
+	statuses ...string) (_ []deployments.DeviceDeployment, err error) {
+	defer s.log.observe(ctx, "DeviceDeployments.FindDeploymentsForDeviceIDsWithStatuses",
+		time.Now(), &err)
+	return s.storage.FindDeploymentsForDeviceIDsWithStatuses(ctx, deviceIDs, statuses...)
+}
+
+func (s *SlowQueryDeviceDeploymentStorage) FindLatestDeploymentForDeviceID(ctx context.Context,
+	deviceID string, deploymentIDs ...string) (_ *deployments.DeviceDeployment, err error) {
+	defer s.log.observe(ctx, "DeviceDeployments.FindLatestDeploymentForDeviceID",
+		time.Now(), &err)
+	return s.storage.FindLatestDeploymentForDeviceID(ctx, deviceID, deploymentIDs...)
+}
+
+func (s *SlowQueryDeviceDeploymentStorage) UpdateDeviceDeploymentStatus(ctx context.Context,
+	deviceID string, deploymentID string, status deployments.DeviceDeploymentStatus) (_ string, err error) {
+	defer s.log.observe(ctx, "DeviceDeployments.UpdateDeviceDeploymentStatus",
+		time.Now(), &err)
+	return s.storage.UpdateDeviceDeploymentStatus(ctx, deviceID, deploymentID, status)
+}
+
+func (s *SlowQueryDeviceDeploymentStorage) UpdateDeviceDeploymentStatuses(ctx context.Context,
+	updates []deployments.DeviceDeploymentStatusUpdate) (_ int, err error) {
+	defer s.log.observe(ctx, "DeviceDeployments.UpdateDeviceDeploymentStatuses",
+		time.Now(), &err)
+	return s.storage.UpdateDeviceDeploymentStatuses(ctx, updates)
+}
+
+func (s *SlowQueryDeviceDeploymentStorage) FindAppliedStatusUpdates(ctx context.Context,
+	updates []deployments.DeviceDeploymentStatusUpdate) (_ []string, err error) {
+	defer s.log.observe(ctx, "DeviceDeployments.FindAppliedStatusUpdates",
+		time.Now(), &err)
+	return s.storage.FindAppliedStatusUpdates(ctx, updates)
+}
+
+func (s *SlowQueryDeviceDeploymentStorage) UpdateDeviceDeploymentLogAvailability(ctx context.Context,
+	deviceID string, deploymentID string, log bool) (err error) {
+	defer s.log.observe(ctx, "DeviceDeployments.UpdateDeviceDeploymentLogAvailability",
+		time.Now(), &err)
+	return s.storage.UpdateDeviceDeploymentLogAvailability(ctx, deviceID, deploymentID, log)
+}
+
+func (s *SlowQueryDeviceDeploymentStorage) RevokeDeviceDeploymentLink(ctx context.Context,
+	deviceID string, deploymentID string) (err error) {
+	defer s.log.observe(ctx, "DeviceDeployments.RevokeDeviceDeploymentLink",
+		time.Now(), &err)
+	return s.storage.RevokeDeviceDeploymentLink(ctx, deviceID, deploymentID)
+}
+
+func (s *SlowQueryDeviceDeploymentStorage) RetryDeviceDeployment(ctx context.Context,
+	deviceID string, deploymentID string, from string) (err error) {
+	defer s.log.observe(ctx, "DeviceDeployments.RetryDeviceDeployment",
+		time.Now(), &err)
+	return s.storage.RetryDeviceDeployment(ctx, deviceID, deploymentID, from)
+}
+
+func (s *SlowQueryDeviceDeploymentStorage) AssignArtifact(ctx context.Context,
+	deviceID string, deploymentID string, artifact *images.SoftwareImage) (err error) {
+	defer s.log.observe(ctx, "DeviceDeployments.AssignArtifact", time.Now(), &err)
+	return s.storage.AssignArtifact(ctx, deviceID, deploymentID, artifact)
+}
+
+func (s *SlowQueryDeviceDeploymentStorage) IncrementDeviceDeploymentAttempts(ctx context.Context,
+	deviceID string, deploymentID string) (err error) {
+	defer s.log.observe(ctx, "DeviceDeployments.IncrementDeviceDeploymentAttempts",
+		time.Now(), &err)
+	return s.storage.IncrementDeviceDeploymentAttempts(ctx, deviceID, deploymentID)
+}
+
+func (s *SlowQueryDeviceDeploymentStorage) IncrementDeviceDeploymentStatusResets(ctx context.Context,
+	deviceID string, deploymentID string) (err error) {
+	defer s.log.observe(ctx, "DeviceDeployments.IncrementDeviceDeploymentStatusResets",
+		time.Now(), &err)
+	return s.storage.IncrementDeviceDeploymentStatusResets(ctx, deviceID, deploymentID)
+}
+
+func (s *SlowQueryDeviceDeploymentStorage) IncrementDeviceDeploymentIgnoredReports(ctx context.Context,
+	deviceID string, deploymentID string) (err error) {
+	defer s.log.observe(ctx, "DeviceDeployments.IncrementDeviceDeploymentIgnoredReports",
+		time.Now(), &err)
+	return s.storage.IncrementDeviceDeploymentIgnoredReports(ctx, deviceID, deploymentID)
+}
+
+func (s *SlowQueryDeviceDeploymentStorage) SaveDeviceDeploymentInventory(ctx context.Context,
+	deviceID string, deploymentID string, inventory map[string]string) (err error) {
+	defer s.log.observe(ctx, "DeviceDeployments.SaveDeviceDeploymentInventory",
+		time.Now(), &err)
+	return s.storage.SaveDeviceDeploymentInventory(ctx, deviceID, deploymentID, inventory)
+}
+
+func (s *SlowQueryDeviceDeploymentStorage) AggregateDeviceDeploymentByStatus(ctx context.Context,
+	id string) (_ deployments.Stats, err error) {
+	defer s.log.observe(ctx, "DeviceDeployments.AggregateDeviceDeploymentByStatus",
+		time.Now(), &err)
+	return s.storage.AggregateDeviceDeploymentByStatus(ctx, id)
+}
+
+func (s *SlowQueryDeviceDeploymentStorage) AggregateDeviceDeploymentByPhase(ctx context.Context,
+	id string) (_ map[int]deployments.Stats, err error) {
+	defer s.log.observe(ctx, "DeviceDeployments.AggregateDeviceDeploymentByPhase",
+		time.Now(), &err)
+	return s.storage.AggregateDeviceDeploymentByPhase(ctx, id)
+}
+
+func (s *SlowQueryDeviceDeploymentStorage) AggregateDeviceDeploymentFailures(ctx context.Context,
+	id string) (_ deployments.FailureStats, err error) {
+	defer s.log.observe(ctx, "DeviceDeployments.AggregateDeviceDeploymentFailures",
+		time.Now(), &err)
+	return s.storage.AggregateDeviceDeploymentFailures(ctx, id)
+}
+
+func (s *SlowQueryDeviceDeploymentStorage) AggregateDeviceDeploymentDurations(ctx context.Context,
+	id string) (_ deployments.DurationStats, err error) {
+	defer s.log.observe(ctx, "DeviceDeployments.AggregateDeviceDeploymentDurations",
+		time.Now(), &err)
+	return s.storage.AggregateDeviceDeploymentDurations(ctx, id)
+}
+
+func (s *SlowQueryDeviceDeploymentStorage) AverageDeviceDeploymentDuration(ctx context.Context,
+	since time.Time) (_ time.Duration, _ int, err error) {
+	defer s.log.observe(ctx, "DeviceDeployments.AverageDeviceDeploymentDuration",
+		time.Now(), &err)
+	return s.storage.AverageDeviceDeploymentDuration(ctx, since)
+}
+
+func (s *SlowQueryDeviceDeploymentStorage) GetDeviceStatusesForDeployment(ctx context.Context,
+	deploymentID string) (_ []deployments.DeviceDeployment, err error) {
+	defer s.log.observe(ctx, "DeviceDeployments.GetDeviceStatusesForDeployment",
+		time.Now(), &err)
+	return s.storage.GetDeviceStatusesForDeployment(ctx, deploymentID)
+}
+
+func (s *SlowQueryDeviceDeploymentStorage) CountDeviceDeployments(ctx context.Context,
+	deploymentID, status string) (_ int, err error) {
+	defer s.log.observe(ctx, "DeviceDeployments.CountDeviceDeployments", time.Now(), &err)
+	return s.storage.CountDeviceDeployments(ctx, deploymentID, status)
+}
+
+func (s *SlowQueryDeviceDeploymentStorage) FindDeviceDeployments(ctx context.Context,
+	query deployments.DeviceDeploymentsQuery) (_ []deployments.DeviceDeployment, err error) {
+	defer s.log.observe(ctx, "DeviceDeployments.FindDeviceDeployments", time.Now(), &err)
+	return s.storage.FindDeviceDeployments(ctx, query)
+}
+
+func (s *SlowQueryDeviceDeploymentStorage) HasDeploymentForDevice(ctx context.Context,
+	deploymentID string, deviceID string) (_ bool, err error) {
+	defer s.log.observe(ctx, "DeviceDeployments.HasDeploymentForDevice", time.Now(), &err)
+	return s.storage.HasDeploymentForDevice(ctx, deploymentID, deviceID)
+}
+
+func (s *SlowQueryDeviceDeploymentStorage) GetDeviceDeploymentStatus(ctx context.Context,
+	deploymentID string, deviceID string) (_ string, err error) {
+	defer s.log.observe(ctx, "DeviceDeployments.GetDeviceDeploymentStatus",
+		time.Now(), &err)
+	return s.storage.GetDeviceDeploymentStatus(ctx, deploymentID, deviceID)
+}
+
+func (s *SlowQueryDeviceDeploymentStorage) AbortDeviceDeployments(ctx context.Context,
+	deploymentID string, abort *deployments.AbortInfo) (err error) {
+	defer s.log.observe(ctx, "DeviceDeployments.AbortDeviceDeployments", time.Now(), &err)
+	return s.storage.AbortDeviceDeployments(ctx, deploymentID, abort)
+}
+
+func (s *SlowQueryDeviceDeploymentStorage) FinishDeviceDeployments(ctx context.Context,
+	deploymentID string, status string, finished time.Time) (err error) {
+	defer s.log.observe(ctx, "DeviceDeployments.FinishDeviceDeployments", time.Now(), &err)
+	return s.storage.FinishDeviceDeployments(ctx, deploymentID, status, finished)
+}
+
+func (s *SlowQueryDeviceDeploymentStorage) DecommissionDeviceDeployments(ctx context.Context,
+	deviceId string) (err error) {
+	defer s.log.observe(ctx, "DeviceDeployments.DecommissionDeviceDeployments",
+		time.Now(), &err)
+	return s.storage.DecommissionDeviceDeployments(ctx, deviceId)
+}
+
+func (s *SlowQueryDeviceDeploymentStorage) AnonymizeDeviceDeployments(ctx context.Context,
+	deviceID, anonymousID string) (_ int, err error) {
+	defer s.log.observe(ctx, "DeviceDeployments.AnonymizeDeviceDeployments",
+		time.Now(), &err)
+	return s.storage.AnonymizeDeviceDeployments(ctx, deviceID, anonymousID)
+}
+
+func (s *SlowQueryDeviceDeploymentStorage) SupersedeDeviceDeployments(ctx context.Context,
+	deploymentID string, deviceIDs []string) (_ []string, err error) {
+	defer s.log.observe(ctx, "DeviceDeployments.SupersedeDeviceDeployments",
+		time.Now(), &err)
+	return s.storage.SupersedeDeviceDeployments(ctx, deploymentID, deviceIDs)
+}
+
+func (s *SlowQueryDeviceDeploymentStorage) PartitionDeviceDeployments(ctx context.Context,
+	finishedBefore time.Time) (_ int, err error) {
+	defer s.log.observe(ctx, "DeviceDeployments.PartitionDeviceDeployments",
+		time.Now(), &err)
+	return s.storage.PartitionDeviceDeployments(ctx, finishedBefore)
+}
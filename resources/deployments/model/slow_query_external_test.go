@@ -0,0 +1,135 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/mendersoftware/go-lib-micro/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/mendersoftware/deployments/resources/deployments"
+	. "github.com/mendersoftware/deployments/resources/deployments/model"
+	"github.com/mendersoftware/deployments/resources/deployments/model/mocks"
+	"github.com/mendersoftware/deployments/utils/metrics"
+	h "github.com/mendersoftware/deployments/utils/testing"
+)
+
+func TestSlowQueryLog(t *testing.T) {
+
+	testCases := map[string]struct {
+		Delay        time.Duration
+		SampleRate   float64
+		ExplainError error
+
+		OutputRecorded bool
+		OutputLog      []string
+	}{
+		"fast": {
+			SampleRate: 1,
+		},
+		"slow, not sampled": {
+			Delay:          30 * time.Millisecond,
+			OutputRecorded: true,
+			OutputLog: []string{
+				"slow storage call: DeviceDeployments.CountDeviceDeployments took",
+			},
+		},
+		"slow, explained": {
+			Delay:          30 * time.Millisecond,
+			SampleRate:     1,
+			OutputRecorded: true,
+			OutputLog: []string{
+				"slow storage call: DeviceDeployments.CountDeviceDeployments took",
+				"slow storage call: DeviceDeployments.CountDeviceDeployments: " +
+					"command on deployment_service.devices took 25ms, plan COLLSCAN, " +
+					"examined 0 keys and 1000 documents, returned 1",
+			},
+		},
+		"slow, explain error": {
+			Delay:          30 * time.Millisecond,
+			SampleRate:     1,
+			ExplainError:   errors.New("not authorized"),
+			OutputRecorded: true,
+			OutputLog: []string{
+				"slow storage call: DeviceDeployments.CountDeviceDeployments: " +
+					"explaining queries: not authorized",
+			},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			var out bytes.Buffer
+			ctx := log.WithContext(context.Background(),
+				log.NewFromLogger(&logrus.Logger{
+					Out:       &out,
+					Formatter: new(logrus.TextFormatter),
+					Level:     logrus.WarnLevel,
+				}, log.Ctx{}))
+
+			storage := new(mocks.DeviceDeploymentStorage)
+			storage.On("CountDeviceDeployments", h.ContextMatcher(), "foo", "").
+				After(tc.Delay).
+				Return(1, nil)
+
+			explainer := new(mocks.QueryExplainer)
+			explainer.On("ExplainSlowQueries", h.ContextMatcher(),
+				mock.AnythingOfType("time.Time")).
+				Return([]deployments.SlowQuery{
+					{
+						Namespace:    "deployment_service.devices",
+						Operation:    "command",
+						Duration:     25 * time.Millisecond,
+						PlanSummary:  "COLLSCAN",
+						DocsExamined: 1000,
+						Returned:     1,
+					},
+				}, tc.ExplainError)
+
+			recorder := metrics.NewRecorder()
+			slowLog := NewSlowQueryLog(20*time.Millisecond, tc.SampleRate,
+				recorder, explainer)
+
+			count, err := NewSlowQueryDeviceDeploymentStorage(storage, slowLog).
+				CountDeviceDeployments(ctx, "foo", "")
+			assert.NoError(t, err)
+			assert.Equal(t, 1, count)
+
+			snapshot := recorder.Snapshot()
+			if tc.OutputRecorded {
+				assert.Equal(t, int64(1),
+					snapshot["DeviceDeployments.CountDeviceDeployments"].Count)
+			} else {
+				assert.Empty(t, snapshot)
+				assert.Empty(t, out.String())
+			}
+
+			for _, line := range tc.OutputLog {
+				assert.Contains(t, out.String(), line)
+			}
+			if tc.SampleRate == 0 {
+				explainer.AssertNotCalled(t, "ExplainSlowQueries",
+					h.ContextMatcher(), mock.AnythingOfType("time.Time"))
+			}
+		})
+	}
+}
@@ -0,0 +1,89 @@
+// Copyright 2026 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pkg/errors"
+	"github.com/satori/go.uuid"
+
+	"github.com/mendersoftware/deployments/resources/deployments"
+	"github.com/mendersoftware/deployments/resources/deployments/controller"
+)
+
+// slugAttempts is the number of numbered slugs tried for a deployment name
+// before falling back to a random suffix.
+const slugAttempts = 9
+
+// assignSlug makes sure the deployment gets a unique slug. Slug requested by
+// the user has to be free, otherwise one is derived from the deployment name.
+func (d *DeploymentsModel) assignSlug(ctx context.Context,
+	constructor *deployments.DeploymentConstructor) error {
+
+	if constructor.Slug != "" {
+		taken, err := d.isSlugTaken(ctx, constructor.Slug)
+		if err != nil {
+			return err
+		}
+		if taken {
+			return controller.ErrDeploymentSlugTaken
+		}
+		return nil
+	}
+
+	base := deployments.Slugify(*constructor.Name)
+	for i := 1; i <= slugAttempts; i++ {
+		slug := base
+		if i > 1 {
+			slug = fmt.Sprintf("%s-%d", base, i)
+		}
+		taken, err := d.isSlugTaken(ctx, slug)
+		if err != nil {
+			return err
+		}
+		if !taken {
+			constructor.Slug = slug
+			return nil
+		}
+	}
+
+	constructor.Slug = base + "-" + uuid.NewV4().String()[:8]
+	return nil
+}
+
+func (d *DeploymentsModel) isSlugTaken(ctx context.Context, slug string) (bool, error) {
+	found, err := d.deploymentsStorage.FindBySlug(ctx, slug)
+	if err != nil {
+		return false, errors.Wrap(err, "Searching for deployment slug")
+	}
+	return found != nil, nil
+}
+
+// GetDeploymentIDBySlug returns ID of the deployment with given slug, empty
+// if there is no such deployment.
+func (d *DeploymentsModel) GetDeploymentIDBySlug(ctx context.Context,
+	slug string) (string, error) {
+
+	found, err := d.deploymentsStorage.FindBySlug(ctx, slug)
+	if err != nil {
+		return "", errors.Wrap(err, "Searching for deployment slug")
+	}
+	if found == nil {
+		return "", nil
+	}
+	return *found.Id, nil
+}
@@ -0,0 +1,197 @@
+// Copyright 2026 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/mendersoftware/deployments/resources/deployments"
+	"github.com/mendersoftware/deployments/resources/deployments/controller"
+	. "github.com/mendersoftware/deployments/resources/deployments/model"
+	"github.com/mendersoftware/deployments/resources/deployments/model/mocks"
+	"github.com/mendersoftware/deployments/resources/images"
+	. "github.com/mendersoftware/deployments/utils/pointers"
+	h "github.com/mendersoftware/deployments/utils/testing"
+)
+
+func TestDeploymentModelCreateDeploymentSlug(t *testing.T) {
+
+	taken := &deployments.Deployment{Id: StringToPointer(validUUIDv4)}
+
+	testCases := map[string]struct {
+		InputName      string
+		InputSlug      string
+		InputTaken     []string
+		InputFindError error
+
+		OutputSlug   string
+		OutputPrefix string
+		OutputError  error
+	}{
+		"from name": {
+			OutputSlug: "nyc-production",
+		},
+		"from reserved name": {
+			InputName:  "Stats",
+			OutputSlug: "stats-deployment",
+		},
+		"from name, taken": {
+			InputTaken: []string{"nyc-production", "nyc-production-2"},
+			OutputSlug: "nyc-production-3",
+		},
+		"from name, all numbered taken": {
+			InputTaken: []string{"nyc-production", "nyc-production-2",
+				"nyc-production-3", "nyc-production-4", "nyc-production-5",
+				"nyc-production-6", "nyc-production-7", "nyc-production-8",
+				"nyc-production-9"},
+			OutputPrefix: "nyc-production-",
+		},
+		"requested": {
+			InputSlug:  "nyc-q3",
+			InputTaken: []string{"nyc-production"},
+			OutputSlug: "nyc-q3",
+		},
+		"requested, taken": {
+			InputSlug:   "nyc-q3",
+			InputTaken:  []string{"nyc-q3"},
+			OutputError: controller.ErrDeploymentSlugTaken,
+		},
+		"storage error": {
+			InputFindError: errors.New("storage issue"),
+			OutputError:    errors.New("Searching for deployment slug: storage issue"),
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+
+			var inserted *deployments.Deployment
+			deploymentStorage := new(mocks.DeploymentsStorage)
+			for _, slug := range testCase.InputTaken {
+				deploymentStorage.On("FindBySlug", h.ContextMatcher(), slug).
+					Return(taken, nil)
+			}
+			deploymentStorage.On("FindBySlug",
+				h.ContextMatcher(), mock.AnythingOfType("string")).
+				Return(nil, testCase.InputFindError)
+			deploymentStorage.On("Insert",
+				h.ContextMatcher(),
+				mock.AnythingOfType("*deployments.Deployment")).
+				Run(func(args mock.Arguments) {
+					inserted = args.Get(1).(*deployments.Deployment)
+				}).
+				Return(nil)
+
+			deviceDeploymentStorage := new(mocks.DeviceDeploymentStorage)
+			deviceDeploymentStorage.On("InsertMany",
+				h.ContextMatcher(),
+				mock.AnythingOfType("[]*deployments.DeviceDeployment")).
+				Return(nil)
+
+			artifactGetter := new(mocks.ArtifactGetter)
+			artifactGetter.On("ImagesByName",
+				h.ContextMatcher(), "App 123").
+				Return([]*images.SoftwareImage{
+					images.NewSoftwareImage(
+						validUUIDv4,
+						&images.SoftwareImageMetaConstructor{},
+						&images.SoftwareImageMetaArtifactConstructor{
+							Name:                  "App 123",
+							DeviceTypesCompatible: []string{"hammer"},
+						}),
+				}, nil)
+
+			model := NewDeploymentModel(DeploymentsModelConfig{
+				DeploymentsStorage:       deploymentStorage,
+				DeviceDeploymentsStorage: deviceDeploymentStorage,
+				ArtifactGetter:           artifactGetter,
+			})
+
+			name := "NYC Production"
+			if testCase.InputName != "" {
+				name = testCase.InputName
+			}
+			_, err := model.CreateDeployment(context.Background(),
+				&deployments.DeploymentConstructor{
+					Name:         StringToPointer(name),
+					ArtifactName: StringToPointer("App 123"),
+					Devices:      []string{"b532b01a-9313-404f-8d19-e7fcbe5cc347"},
+					Slug:         testCase.InputSlug,
+				})
+			if testCase.OutputError != nil {
+				assert.EqualError(t, err, testCase.OutputError.Error())
+				deploymentStorage.AssertNotCalled(t, "Insert", mock.Anything, mock.Anything)
+				return
+			}
+
+			assert.NoError(t, err)
+			if testCase.OutputPrefix != "" {
+				assert.True(t, strings.HasPrefix(inserted.Slug, testCase.OutputPrefix))
+				assert.True(t, deployments.IsValidSlug(inserted.Slug))
+				assert.NotContains(t, testCase.InputTaken, inserted.Slug)
+				return
+			}
+			assert.Equal(t, testCase.OutputSlug, inserted.Slug)
+		})
+	}
+}
+
+func TestDeploymentModelGetDeploymentIDBySlug(t *testing.T) {
+
+	testCases := map[string]struct {
+		InputDeployment *deployments.Deployment
+		InputError      error
+
+		OutputID    string
+		OutputError error
+	}{
+		"found": {
+			InputDeployment: &deployments.Deployment{Id: StringToPointer(validUUIDv4)},
+			OutputID:        validUUIDv4,
+		},
+		"not found": {},
+		"storage error": {
+			InputError:  errors.New("storage issue"),
+			OutputError: errors.New("Searching for deployment slug: storage issue"),
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+
+			deploymentStorage := new(mocks.DeploymentsStorage)
+			deploymentStorage.On("FindBySlug", h.ContextMatcher(), "nyc-production").
+				Return(testCase.InputDeployment, testCase.InputError)
+
+			model := NewDeploymentModel(DeploymentsModelConfig{
+				DeploymentsStorage: deploymentStorage,
+			})
+
+			id, err := model.GetDeploymentIDBySlug(context.Background(), "nyc-production")
+			if testCase.OutputError != nil {
+				assert.EqualError(t, err, testCase.OutputError.Error())
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, testCase.OutputID, id)
+		})
+	}
+}
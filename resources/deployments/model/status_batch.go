@@ -0,0 +1,325 @@
+// Copyright 2018 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/mendersoftware/go-lib-micro/identity"
+	"github.com/mendersoftware/go-lib-micro/log"
+	"github.com/pkg/errors"
+	"github.com/satori/go.uuid"
+
+	"github.com/mendersoftware/deployments/resources/deployments"
+)
+
+// DefaultStatusBatchSize is the number of status updates written early,
+// without waiting for the batch window to pass.
+const DefaultStatusBatchSize = 1000
+
+// errStatusNotBatched is returned for status updates not written by the
+// batch, e.g. because the device deployment changed status meanwhile;
+// these are applied one by one instead.
+var errStatusNotBatched = errors.New("status update not batched")
+
+type statusBatchItem struct {
+	update deployments.DeviceDeploymentStatusUpdate
+	done   chan error
+}
+
+type statusBatch struct {
+	ctx   context.Context
+	items []*statusBatchItem
+}
+
+// statusBatcher buffers status updates of device deployments for a short
+// window and writes them, along with the deployment statistics, in bulk.
+// Updates are written in the order received and each of them waits for
+// its batch to be written, so the order of updates of a device is kept.
+type statusBatcher struct {
+	window            time.Duration
+	size              int
+	deviceDeployments DeviceDeploymentStorage
+	deployments       DeploymentsStorage
+
+	mu      sync.Mutex
+	batches map[string]*statusBatch
+}
+
+func newStatusBatcher(window time.Duration, size int,
+	deviceDeployments DeviceDeploymentStorage,
+	deployments DeploymentsStorage) *statusBatcher {
+
+	if size <= 0 {
+		size = DefaultStatusBatchSize
+	}
+
+	return &statusBatcher{
+		window:            window,
+		size:              size,
+		deviceDeployments: deviceDeployments,
+		deployments:       deployments,
+		batches:           map[string]*statusBatch{},
+	}
+}
+
+// update queues status update of the device deployment, expected to be
+// in the from status, and returns the replaced status once written.
+func (b *statusBatcher) update(ctx context.Context, deviceID, deploymentID,
+	from string, status deployments.DeviceDeploymentStatus) (string, error) {
+
+	// batches are written per tenant database
+	var tenant string
+	id := identity.FromContext(ctx)
+	if id != nil {
+		tenant = id.Tenant
+	}
+
+	item := &statusBatchItem{
+		update: deployments.DeviceDeploymentStatusUpdate{
+			DeviceID:     deviceID,
+			DeploymentID: deploymentID,
+			From:         from,
+			Status:       status,
+		},
+		done: make(chan error, 1),
+	}
+
+	b.mu.Lock()
+	batch, ok := b.batches[tenant]
+	if !ok {
+		// the batch outlives the request which started it
+		batchCtx := identity.WithContext(context.Background(), id)
+		batchCtx = log.WithContext(batchCtx, log.FromContext(ctx))
+		batch = &statusBatch{ctx: batchCtx}
+		b.batches[tenant] = batch
+		time.AfterFunc(b.window, func() {
+			b.flush(tenant, batch)
+		})
+	}
+	// the device deployment is in the status of its update written
+	// earlier in the batch
+	for i := len(batch.items) - 1; i >= 0; i-- {
+		prev := batch.items[i].update
+		if prev.DeviceID == deviceID && prev.DeploymentID == deploymentID {
+			item.update.From = prev.Status.Status
+			break
+		}
+	}
+	batch.items = append(batch.items, item)
+	full := len(batch.items) >= b.size
+	b.mu.Unlock()
+
+	if full {
+		b.flush(tenant, batch)
+	}
+
+	err := <-item.done
+	return item.update.From, err
+}
+
+func (b *statusBatcher) flush(tenant string, batch *statusBatch) {
+	b.mu.Lock()
+	if b.batches[tenant] != batch {
+		// written already
+		b.mu.Unlock()
+		return
+	}
+	delete(b.batches, tenant)
+	b.mu.Unlock()
+
+	b.write(batch)
+}
+
+func (b *statusBatcher) write(batch *statusBatch) {
+	ctx := batch.ctx
+
+	updates := make([]deployments.DeviceDeploymentStatusUpdate, len(batch.items))
+	for i, item := range batch.items {
+		updates[i] = item.update
+	}
+
+	applied := make([]bool, len(updates))
+	indexes := make([]int, len(updates))
+	for i := range indexes {
+		indexes[i] = i
+	}
+	b.writeUpdates(ctx, updates, indexes, applied)
+
+	// updates following a failed one of the same device deployment expect
+	// it in the status of the failed one; they are written again along with
+	// the failed one, to keep their order
+	if retry := b.recomputeFrom(ctx, updates, applied); len(retry) > 0 {
+		for _, i := range retry {
+			batch.items[i].update.From = updates[i].From
+		}
+		b.writeUpdates(ctx, updates, retry, applied)
+	}
+
+	stats := map[string]deployments.Stats{}
+	for i, update := range updates {
+		if !applied[i] {
+			continue
+		}
+		counts, ok := stats[update.DeploymentID]
+		if !ok {
+			counts = deployments.Stats{}
+			stats[update.DeploymentID] = counts
+		}
+		counts[update.From]--
+		counts[update.Status.Status]++
+	}
+
+	err := b.deployments.IncrementStats(ctx, stats)
+	if err != nil {
+		err = errors.Wrap(err, "updating deployment statistics")
+	}
+	for i, item := range batch.items {
+		if applied[i] {
+			item.done <- err
+		} else {
+			item.done <- errStatusNotBatched
+		}
+	}
+}
+
+// writeUpdates writes the updates of the given indexes, in order, and
+// marks those written as applied. Each update is written with a unique ID
+// and those applied are found by it; a device deployment keeps the ID of
+// its last update only, so its updates are written in separate rounds.
+// Updates following one not applied, of the same device deployment, are
+// not written.
+func (b *statusBatcher) writeUpdates(ctx context.Context,
+	updates []deployments.DeviceDeploymentStatusUpdate, indexes []int,
+	applied []bool) {
+
+	failed := map[[2]string]bool{}
+	for len(indexes) > 0 {
+		round := []int{}
+		next := []int{}
+		inRound := map[[2]string]bool{}
+		for _, i := range indexes {
+			key := [2]string{updates[i].DeviceID, updates[i].DeploymentID}
+			switch {
+			case failed[key]:
+				applied[i] = false
+			case inRound[key]:
+				next = append(next, i)
+			default:
+				inRound[key] = true
+				round = append(round, i)
+			}
+		}
+		indexes = next
+		if len(round) == 0 {
+			break
+		}
+
+		batch := make([]deployments.DeviceDeploymentStatusUpdate, len(round))
+		for j, i := range round {
+			updates[i].ID = uuid.NewV4().String()
+			batch[j] = updates[i]
+		}
+
+		written := b.writeRound(ctx, batch)
+		for j, i := range round {
+			applied[i] = written[batch[j].ID]
+			if !applied[i] {
+				failed[[2]string{updates[i].DeviceID, updates[i].DeploymentID}] = true
+			}
+		}
+	}
+}
+
+// writeRound writes the updates, at most one per device deployment, and
+// returns IDs of those applied.
+func (b *statusBatcher) writeRound(ctx context.Context,
+	batch []deployments.DeviceDeploymentStatusUpdate) map[string]bool {
+
+	written := map[string]bool{}
+	matched, err := b.deviceDeployments.UpdateDeviceDeploymentStatuses(ctx, batch)
+	if err == nil && matched == len(batch) {
+		for _, update := range batch {
+			written[update.ID] = true
+		}
+		return written
+	}
+	if err != nil {
+		log.FromContext(ctx).Warnf("failed to write batch of status updates: %v", err)
+	}
+
+	// updates not found applied are left to be applied one by one
+	ids, err := b.deviceDeployments.FindAppliedStatusUpdates(ctx, batch)
+	if err != nil {
+		log.FromContext(ctx).Warnf("failed to find applied status updates: %v", err)
+		return written
+	}
+	for _, id := range ids {
+		written[id] = true
+	}
+	return written
+}
+
+// recomputeFrom sets the replaced status of the updates not applied, of
+// device deployments with more than one of them, to the status of the device
+// deployment in storage for the first update and to the status of the
+// previous update for the following ones. Single updates not applied are
+// left to be applied one by one. Returns indexes of the updates to write
+// again.
+func (b *statusBatcher) recomputeFrom(ctx context.Context,
+	updates []deployments.DeviceDeploymentStatusUpdate, applied []bool) []int {
+
+	failed := map[[2]string]int{}
+	for i, update := range updates {
+		if !applied[i] {
+			failed[[2]string{update.DeviceID, update.DeploymentID}]++
+		}
+	}
+
+	// status of the device deployment before the update, empty if unknown
+	from := map[[2]string]string{}
+	retry := []int{}
+	for i := range updates {
+		update := &updates[i]
+		key := [2]string{update.DeviceID, update.DeploymentID}
+		if applied[i] || failed[key] < 2 {
+			continue
+		}
+
+		status, ok := from[key]
+		if !ok {
+			current, err := b.deviceDeployments.GetDeviceDeploymentStatus(ctx,
+				update.DeploymentID, update.DeviceID)
+			if err != nil {
+				log.FromContext(ctx).Warnf(
+					"failed to get status of device deployment: %v", err)
+				current = ""
+			}
+			status = current
+		}
+		if status == "" {
+			from[key] = ""
+			continue
+		}
+
+		update.From = status
+		from[key] = update.Status.Status
+		retry = append(retry, i)
+	}
+
+	return retry
+}
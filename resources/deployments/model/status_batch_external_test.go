@@ -0,0 +1,351 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/mendersoftware/deployments/resources/deployments"
+	. "github.com/mendersoftware/deployments/resources/deployments/model"
+	"github.com/mendersoftware/deployments/resources/deployments/model/mocks"
+	. "github.com/mendersoftware/deployments/utils/pointers"
+	h "github.com/mendersoftware/deployments/utils/testing"
+)
+
+// updatesMatcher matches the status updates regardless of their IDs, which
+// are expected to be set.
+func updatesMatcher(expected ...deployments.DeviceDeploymentStatusUpdate) interface{} {
+	return mock.MatchedBy(func(updates []deployments.DeviceDeploymentStatusUpdate) bool {
+		if len(updates) != len(expected) {
+			return false
+		}
+		for i, update := range updates {
+			if update.ID == "" {
+				return false
+			}
+			update.ID = expected[i].ID
+			if !reflect.DeepEqual(update, expected[i]) {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+// appliedUpdates returns IDs of the updates of the given devices.
+func appliedUpdates(devices ...string) func(context.Context,
+	[]deployments.DeviceDeploymentStatusUpdate) []string {
+
+	return func(ctx context.Context,
+		updates []deployments.DeviceDeploymentStatusUpdate) []string {
+
+		ids := []string{}
+		for _, update := range updates {
+			for _, device := range devices {
+				if update.DeviceID == device {
+					ids = append(ids, update.ID)
+				}
+			}
+		}
+		return ids
+	}
+}
+
+func TestDeploymentModelUpdateDeviceDeploymentStatusBatch(t *testing.T) {
+
+	deploymentID := "b532b01a-9313-404f-8d19-e7fcbe5cc347"
+	devices := []string{"device-1", "device-2", "device-3"}
+
+	testCases := map[string]struct {
+		InputMatched int
+		InputApplied []string
+		// status of the device deployments written meanwhile
+		InputWritten string
+
+		OutputStats    deployments.Stats
+		OutputFallback []string
+	}{
+		"ok": {
+			InputMatched: 3,
+
+			OutputStats: deployments.Stats{
+				deployments.DeviceDeploymentStatusPending:     -3,
+				deployments.DeviceDeploymentStatusDownloading: 3,
+			},
+		},
+		"status changed meanwhile": {
+			InputMatched: 2,
+			InputApplied: []string{"device-1", "device-3"},
+			InputWritten: deployments.DeviceDeploymentStatusFailure,
+
+			OutputStats: deployments.Stats{
+				deployments.DeviceDeploymentStatusPending:     -2,
+				deployments.DeviceDeploymentStatusDownloading: 2,
+			},
+			OutputFallback: []string{"device-2"},
+		},
+		"target status written meanwhile": {
+			InputMatched: 2,
+			InputApplied: []string{"device-1", "device-3"},
+			InputWritten: deployments.DeviceDeploymentStatusDownloading,
+
+			OutputStats: deployments.Stats{
+				deployments.DeviceDeploymentStatusPending:     -2,
+				deployments.DeviceDeploymentStatusDownloading: 2,
+			},
+			OutputFallback: []string{"device-2"},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+
+			deviceDeploymentStorage := new(mocks.DeviceDeploymentStorage)
+			for _, device := range devices {
+				deviceDeploymentStorage.On("GetDeviceDeploymentStatus",
+					h.ContextMatcher(), deploymentID, device).
+					Return(deployments.DeviceDeploymentStatusPending, nil).Once()
+			}
+			deviceDeploymentStorage.On("UpdateDeviceDeploymentStatuses",
+				h.ContextMatcher(), mock.MatchedBy(
+					func(updates []deployments.DeviceDeploymentStatusUpdate) bool {
+						if len(updates) != len(devices) {
+							return false
+						}
+						for _, update := range updates {
+							if update.ID == "" ||
+								update.DeploymentID != deploymentID ||
+								update.From != deployments.DeviceDeploymentStatusPending ||
+								update.Status.Status != deployments.DeviceDeploymentStatusDownloading {
+								return false
+							}
+						}
+						return true
+					})).
+				Return(tc.InputMatched, nil).Once()
+			if tc.InputApplied != nil {
+				deviceDeploymentStorage.On("FindAppliedStatusUpdates",
+					h.ContextMatcher(), mock.Anything).
+					Return(appliedUpdates(tc.InputApplied...), nil).Once()
+			}
+			// applied one by one, the status written meanwhile is replaced
+			for _, device := range tc.OutputFallback {
+				deviceDeploymentStorage.On("UpdateDeviceDeploymentStatus",
+					h.ContextMatcher(), device, deploymentID,
+					mock.AnythingOfType("deployments.DeviceDeploymentStatus")).
+					Return(tc.InputWritten, nil).Once()
+			}
+
+			deploymentStorage := new(mocks.DeploymentsStorage)
+			deploymentStorage.On("IncrementStats", h.ContextMatcher(),
+				map[string]deployments.Stats{deploymentID: tc.OutputStats}).
+				Return(nil).Once()
+			if tc.OutputFallback != nil {
+				deploymentStorage.On("UpdateStats", h.ContextMatcher(), deploymentID,
+					tc.InputWritten, deployments.DeviceDeploymentStatusDownloading).
+					Return(nil).Times(len(tc.OutputFallback))
+			}
+			deploymentStorage.On("FindByID", h.ContextMatcher(), deploymentID).
+				Return(&deployments.Deployment{
+					Id: StringToPointer(deploymentID),
+					Stats: deployments.Stats{
+						deployments.DeviceDeploymentStatusDownloading: 3,
+					},
+				}, nil)
+
+			model := NewDeploymentModel(DeploymentsModelConfig{
+				DeploymentsStorage:       deploymentStorage,
+				DeviceDeploymentsStorage: deviceDeploymentStorage,
+				StatusBatchWindow:        time.Hour,
+				StatusBatchSize:          len(devices),
+			})
+
+			var wg sync.WaitGroup
+			for _, device := range devices {
+				wg.Add(1)
+				go func(device string) {
+					defer wg.Done()
+					err := model.UpdateDeviceDeploymentStatus(context.Background(),
+						deploymentID, device, deployments.DeviceDeploymentStatus{
+							Status: deployments.DeviceDeploymentStatusDownloading,
+						})
+					assert.NoError(t, err)
+				}(device)
+			}
+			// batch is written once full, without waiting for the window
+			wg.Wait()
+
+			deviceDeploymentStorage.AssertExpectations(t)
+			deploymentStorage.AssertNumberOfCalls(t, "UpdateStats", len(tc.OutputFallback))
+			deploymentStorage.AssertNumberOfCalls(t, "IncrementStats", 1)
+		})
+	}
+}
+
+func TestWriteStatusBatchFailedUpdate(t *testing.T) {
+
+	deploymentID := "b532b01a-9313-404f-8d19-e7fcbe5cc347"
+	update := func(device, from, status string) deployments.DeviceDeploymentStatusUpdate {
+		return deployments.DeviceDeploymentStatusUpdate{
+			DeviceID:     device,
+			DeploymentID: deploymentID,
+			From:         from,
+			Status:       deployments.DeviceDeploymentStatus{Status: status},
+		}
+	}
+	// two updates of device-1, the second expecting the status of the first
+	updates := []deployments.DeviceDeploymentStatusUpdate{
+		update("device-1", deployments.DeviceDeploymentStatusPending,
+			deployments.DeviceDeploymentStatusDownloading),
+		update("device-1", deployments.DeviceDeploymentStatusDownloading,
+			deployments.DeviceDeploymentStatusInstalling),
+		update("device-2", deployments.DeviceDeploymentStatusPending,
+			deployments.DeviceDeploymentStatusDownloading),
+	}
+
+	testCases := map[string]struct {
+		InputApplied      []string
+		InputStatus       string
+		InputRetryMatched int
+
+		OutputFrom    []string
+		OutputApplied []bool
+		OutputStats   deployments.Stats
+	}{
+		"written again": {
+			InputStatus:       deployments.DeviceDeploymentStatusPending,
+			InputRetryMatched: 1,
+
+			OutputFrom: []string{
+				deployments.DeviceDeploymentStatusPending,
+				deployments.DeviceDeploymentStatusDownloading,
+				deployments.DeviceDeploymentStatusPending,
+			},
+			OutputApplied: []bool{true, true, false},
+			OutputStats: deployments.Stats{
+				deployments.DeviceDeploymentStatusPending:     -1,
+				deployments.DeviceDeploymentStatusDownloading: 0,
+				deployments.DeviceDeploymentStatusInstalling:  1,
+			},
+		},
+		"written again, status changed": {
+			InputStatus:       deployments.DeviceDeploymentStatusRebooting,
+			InputRetryMatched: 1,
+
+			OutputFrom: []string{
+				deployments.DeviceDeploymentStatusRebooting,
+				deployments.DeviceDeploymentStatusDownloading,
+				deployments.DeviceDeploymentStatusPending,
+			},
+			OutputApplied: []bool{true, true, false},
+			OutputStats: deployments.Stats{
+				deployments.DeviceDeploymentStatusRebooting:   -1,
+				deployments.DeviceDeploymentStatusDownloading: 0,
+				deployments.DeviceDeploymentStatusInstalling:  1,
+			},
+		},
+		"written again, other device applied": {
+			InputApplied:      []string{"device-2"},
+			InputStatus:       deployments.DeviceDeploymentStatusPending,
+			InputRetryMatched: 1,
+
+			OutputFrom: []string{
+				deployments.DeviceDeploymentStatusPending,
+				deployments.DeviceDeploymentStatusDownloading,
+				deployments.DeviceDeploymentStatusPending,
+			},
+			OutputApplied: []bool{true, true, true},
+			OutputStats: deployments.Stats{
+				deployments.DeviceDeploymentStatusPending:     -2,
+				deployments.DeviceDeploymentStatusDownloading: 1,
+				deployments.DeviceDeploymentStatusInstalling:  1,
+			},
+		},
+		"failed again": {
+			InputStatus: deployments.DeviceDeploymentStatusPending,
+
+			OutputFrom: []string{
+				deployments.DeviceDeploymentStatusPending,
+				deployments.DeviceDeploymentStatusDownloading,
+				deployments.DeviceDeploymentStatusPending,
+			},
+			OutputApplied: []bool{false, false, false},
+			OutputStats:   nil,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+
+			deviceDeploymentStorage := new(mocks.DeviceDeploymentStorage)
+			// the first update of device-1 fails, the following one of
+			// device-1 is not written
+			deviceDeploymentStorage.On("UpdateDeviceDeploymentStatuses",
+				h.ContextMatcher(), updatesMatcher(updates[0], updates[2])).
+				Return(len(tc.InputApplied), errors.New("write failed")).Once()
+			deviceDeploymentStorage.On("FindAppliedStatusUpdates",
+				h.ContextMatcher(), updatesMatcher(updates[0], updates[2])).
+				Return(appliedUpdates(tc.InputApplied...), nil).Once()
+			deviceDeploymentStorage.On("GetDeviceDeploymentStatus",
+				h.ContextMatcher(), deploymentID, "device-1").
+				Return(tc.InputStatus, nil).Once()
+			// both updates of device-1 are written again, in order
+			retry := update("device-1", tc.InputStatus,
+				deployments.DeviceDeploymentStatusDownloading)
+			deviceDeploymentStorage.On("UpdateDeviceDeploymentStatuses",
+				h.ContextMatcher(), updatesMatcher(retry)).
+				Return(tc.InputRetryMatched, nil).Once()
+			if tc.InputRetryMatched == 0 {
+				deviceDeploymentStorage.On("FindAppliedStatusUpdates",
+					h.ContextMatcher(), updatesMatcher(retry)).
+					Return(nil, nil).Once()
+			} else {
+				deviceDeploymentStorage.On("UpdateDeviceDeploymentStatuses",
+					h.ContextMatcher(), updatesMatcher(updates[1])).
+					Return(1, nil).Once()
+			}
+
+			stats := map[string]deployments.Stats{}
+			if tc.OutputStats != nil {
+				stats[deploymentID] = tc.OutputStats
+			}
+			deploymentStorage := new(mocks.DeploymentsStorage)
+			deploymentStorage.On("IncrementStats", h.ContextMatcher(), stats).
+				Return(nil).Once()
+
+			from, errs := WriteStatusBatch(context.Background(),
+				deviceDeploymentStorage, deploymentStorage, updates)
+
+			assert.Equal(t, tc.OutputFrom, from)
+			for i, applied := range tc.OutputApplied {
+				if applied {
+					assert.NoError(t, errs[i])
+				} else {
+					assert.EqualError(t, errs[i], "status update not batched")
+				}
+			}
+			deviceDeploymentStorage.AssertExpectations(t)
+			deploymentStorage.AssertExpectations(t)
+		})
+	}
+}
@@ -0,0 +1,53 @@
+// Copyright 2026 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/deployments/resources/deployments"
+)
+
+// isStatusReportOutOfOrder tells if the status reported by the device arrived
+// late and has to be ignored. Finished device deployments never change their
+// status; going back to an earlier state is a reset of the update, unless the
+// device reported the current status later than the new one.
+func (d *DeploymentsModel) isStatusReportOutOfOrder(ctx context.Context,
+	deviceID, deploymentID, current string,
+	ddStatus deployments.DeviceDeploymentStatus) (bool, error) {
+
+	if deployments.IsDeviceDeploymentStatusFinished(current) {
+		return true, nil
+	}
+
+	// without report times resets and late reports look the same
+	if ddStatus.ReportTime == nil ||
+		!deployments.IsDeviceDeploymentStatusReset(current, ddStatus.Status) {
+		return false, nil
+	}
+
+	deviceDeployment, err := d.deviceDeploymentsStorage.FindLatestDeploymentForDeviceID(ctx,
+		deviceID, deploymentID)
+	if err != nil {
+		return false, errors.Wrap(err, "Searching for device deployment")
+	}
+	if deviceDeployment == nil || deviceDeployment.StatusReported == nil {
+		return false, nil
+	}
+
+	return deviceDeployment.StatusReported.After(*ddStatus.ReportTime), nil
+}
@@ -0,0 +1,183 @@
+// Copyright 2026 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/mendersoftware/deployments/resources/deployments"
+	. "github.com/mendersoftware/deployments/resources/deployments/model"
+	"github.com/mendersoftware/deployments/resources/deployments/model/mocks"
+	. "github.com/mendersoftware/deployments/utils/pointers"
+	h "github.com/mendersoftware/deployments/utils/testing"
+)
+
+func TestDeploymentModelUpdateDeviceDeploymentStatusOutOfOrder(t *testing.T) {
+
+	reported := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	earlier := reported.Add(-time.Minute)
+	later := reported.Add(time.Minute)
+
+	testCases := map[string]struct {
+		OldStatus         string
+		OldStatusReported *time.Time
+		InputStatus       string
+		InputReportTime   *time.Time
+
+		InputFindError    error
+		InputCounterError error
+
+		OutputIgnored bool
+		OutputError   error
+	}{
+		"progress after success": {
+			OldStatus:     deployments.DeviceDeploymentStatusSuccess,
+			InputStatus:   deployments.DeviceDeploymentStatusInstalling,
+			OutputIgnored: true,
+		},
+		"failure after success": {
+			OldStatus:     deployments.DeviceDeploymentStatusSuccess,
+			InputStatus:   deployments.DeviceDeploymentStatusFailure,
+			OutputIgnored: true,
+		},
+		"progress after failure, counter error": {
+			OldStatus:         deployments.DeviceDeploymentStatusFailure,
+			InputStatus:       deployments.DeviceDeploymentStatusDownloading,
+			InputCounterError: errors.New("counter issue"),
+			OutputIgnored:     true,
+		},
+		"reset, no report time": {
+			OldStatus:         deployments.DeviceDeploymentStatusRebooting,
+			OldStatusReported: &reported,
+			InputStatus:       deployments.DeviceDeploymentStatusInstalling,
+		},
+		"reset, reported later": {
+			OldStatus:         deployments.DeviceDeploymentStatusRebooting,
+			OldStatusReported: &reported,
+			InputStatus:       deployments.DeviceDeploymentStatusInstalling,
+			InputReportTime:   &later,
+		},
+		"reset, current status without report time": {
+			OldStatus:       deployments.DeviceDeploymentStatusRebooting,
+			InputStatus:     deployments.DeviceDeploymentStatusInstalling,
+			InputReportTime: &earlier,
+		},
+		"reported earlier": {
+			OldStatus:         deployments.DeviceDeploymentStatusRebooting,
+			OldStatusReported: &reported,
+			InputStatus:       deployments.DeviceDeploymentStatusInstalling,
+			InputReportTime:   &earlier,
+			OutputIgnored:     true,
+		},
+		"storage error": {
+			OldStatus:       deployments.DeviceDeploymentStatusRebooting,
+			InputStatus:     deployments.DeviceDeploymentStatusInstalling,
+			InputReportTime: &earlier,
+			InputFindError:  errors.New("storage issue"),
+			OutputError:     errors.New("Searching for device deployment: storage issue"),
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+
+			deployment := &deployments.Deployment{
+				Id: StringToPointer(validUUIDv4),
+				Stats: deployments.Stats{
+					testCase.InputStatus: 1,
+				},
+			}
+
+			deviceDeploymentStorage := new(mocks.DeviceDeploymentStorage)
+			deviceDeploymentStorage.On("GetDeviceDeploymentStatus",
+				h.ContextMatcher(), validUUIDv4, "device-1").
+				Return(testCase.OldStatus, nil)
+			deviceDeploymentStorage.On("FindLatestDeploymentForDeviceID",
+				h.ContextMatcher(), "device-1", []string{validUUIDv4}).
+				Return(&deployments.DeviceDeployment{
+					Status:         StringToPointer(testCase.OldStatus),
+					StatusReported: testCase.OldStatusReported,
+				}, testCase.InputFindError)
+			deviceDeploymentStorage.On("IncrementDeviceDeploymentIgnoredReports",
+				h.ContextMatcher(), "device-1", validUUIDv4).
+				Return(testCase.InputCounterError)
+			deviceDeploymentStorage.On("UpdateDeviceDeploymentStatus",
+				h.ContextMatcher(), "device-1", validUUIDv4,
+				mock.AnythingOfType("deployments.DeviceDeploymentStatus")).
+				Return(testCase.OldStatus, nil)
+			deviceDeploymentStorage.On("IncrementDeviceDeploymentStatusResets",
+				h.ContextMatcher(), "device-1", validUUIDv4).
+				Return(nil)
+
+			deploymentStorage := new(mocks.DeploymentsStorage)
+			deploymentStorage.On("UpdateStats",
+				h.ContextMatcher(), validUUIDv4,
+				testCase.OldStatus, testCase.InputStatus).
+				Return(nil)
+			deploymentStorage.On("FindByID",
+				h.ContextMatcher(), validUUIDv4).
+				Return(deployment, nil)
+
+			model := NewDeploymentModel(DeploymentsModelConfig{
+				DeploymentsStorage:       deploymentStorage,
+				DeviceDeploymentsStorage: deviceDeploymentStorage,
+			})
+
+			err := model.UpdateDeviceDeploymentStatus(context.Background(),
+				validUUIDv4, "device-1",
+				deployments.DeviceDeploymentStatus{
+					Status:     testCase.InputStatus,
+					ReportTime: testCase.InputReportTime,
+				})
+			if testCase.OutputError != nil {
+				assert.EqualError(t, err, testCase.OutputError.Error())
+				return
+			}
+
+			assert.NoError(t, err)
+			if testCase.OutputIgnored {
+				deviceDeploymentStorage.AssertCalled(t,
+					"IncrementDeviceDeploymentIgnoredReports",
+					mock.Anything, "device-1", validUUIDv4)
+				deviceDeploymentStorage.AssertNotCalled(t,
+					"UpdateDeviceDeploymentStatus",
+					mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+				deploymentStorage.AssertNotCalled(t, "UpdateStats",
+					mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+				return
+			}
+
+			deviceDeploymentStorage.AssertNotCalled(t,
+				"IncrementDeviceDeploymentIgnoredReports",
+				mock.Anything, mock.Anything, mock.Anything)
+			deviceDeploymentStorage.AssertCalled(t,
+				"UpdateDeviceDeploymentStatus",
+				mock.Anything, "device-1", validUUIDv4,
+				deployments.DeviceDeploymentStatus{
+					Status:     testCase.InputStatus,
+					ReportTime: testCase.InputReportTime,
+				})
+			deviceDeploymentStorage.AssertCalled(t,
+				"IncrementDeviceDeploymentStatusResets",
+				mock.Anything, "device-1", validUUIDv4)
+		})
+	}
+}
@@ -0,0 +1,72 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"context"
+	"time"
+
+	"github.com/mendersoftware/go-lib-micro/log"
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/deployments/resources/deployments"
+)
+
+// recordTelemetry stores telemetry of the status report for the sampled
+// subset of reports. Failure is only logged, status updates do not depend
+// on it.
+func (d *DeploymentsModel) recordTelemetry(ctx context.Context, deploymentID,
+	deviceID string, ddStatus deployments.DeviceDeploymentStatus) {
+
+	if d.telemetryStorage == nil || ddStatus.Telemetry == nil ||
+		ddStatus.Telemetry.IsEmpty() {
+		return
+	}
+	if d.sample() >= d.telemetrySampleRate {
+		return
+	}
+
+	if err := d.telemetryStorage.InsertTelemetrySample(ctx, &deployments.TelemetrySample{
+		DeploymentID:    deploymentID,
+		DeviceID:        deviceID,
+		Status:          ddStatus.Status,
+		DeviceTelemetry: *ddStatus.Telemetry,
+		Reported:        time.Now(),
+	}); err != nil {
+		log.FromContext(ctx).Warnf("failed to record telemetry of device %s: %v",
+			deviceID, err)
+	}
+}
+
+// GetDeploymentTelemetryStats summarizes sampled telemetry of the deployment
+// by reported status, nil if the deployment does not exist.
+func (d *DeploymentsModel) GetDeploymentTelemetryStats(ctx context.Context,
+	deploymentID string) (deployments.TelemetryStats, error) {
+
+	deployment, err := d.deploymentsStorage.FindByID(ctx, deploymentID)
+	if err != nil {
+		return nil, errors.Wrap(err, "checking deployment id")
+	}
+
+	if deployment == nil {
+		return nil, nil
+	}
+
+	if d.telemetryStorage == nil {
+		return deployments.TelemetryStats{}, nil
+	}
+
+	return d.telemetryStorage.AggregateTelemetry(ctx, deploymentID)
+}
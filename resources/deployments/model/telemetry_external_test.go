@@ -0,0 +1,196 @@
+// Copyright 2026 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/mendersoftware/deployments/resources/deployments"
+	"github.com/mendersoftware/deployments/resources/deployments/controller"
+	. "github.com/mendersoftware/deployments/resources/deployments/model"
+	"github.com/mendersoftware/deployments/resources/deployments/model/mocks"
+	. "github.com/mendersoftware/deployments/utils/pointers"
+	h "github.com/mendersoftware/deployments/utils/testing"
+)
+
+func TestDeploymentModelUpdateDeviceDeploymentStatusTelemetry(t *testing.T) {
+
+	battery := 12.5
+	telemetry := &deployments.DeviceTelemetry{BatteryLevel: &battery}
+
+	testCases := map[string]struct {
+		CurrentStatus  string
+		InputTelemetry *deployments.DeviceTelemetry
+		InputRate      float64
+		InputError     error
+
+		OutputRecorded bool
+		OutputError    error
+	}{
+		"recorded": {
+			CurrentStatus:  deployments.DeviceDeploymentStatusInstalling,
+			InputTelemetry: telemetry,
+			InputRate:      1,
+
+			OutputRecorded: true,
+		},
+		"recorded, storage error": {
+			CurrentStatus:  deployments.DeviceDeploymentStatusInstalling,
+			InputTelemetry: telemetry,
+			InputRate:      1,
+			InputError:     errors.New("storage issue"),
+
+			OutputRecorded: true,
+		},
+		"not sampled": {
+			CurrentStatus:  deployments.DeviceDeploymentStatusInstalling,
+			InputTelemetry: telemetry,
+		},
+		"no telemetry": {
+			CurrentStatus:  deployments.DeviceDeploymentStatusInstalling,
+			InputTelemetry: &deployments.DeviceTelemetry{},
+			InputRate:      1,
+		},
+		"aborted": {
+			CurrentStatus:  deployments.DeviceDeploymentStatusAborted,
+			InputTelemetry: telemetry,
+			InputRate:      1,
+
+			OutputError: controller.ErrDeploymentAborted,
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+
+			deviceDeploymentStorage := new(mocks.DeviceDeploymentStorage)
+			deviceDeploymentStorage.On("GetDeviceDeploymentStatus",
+				h.ContextMatcher(), validUUIDv4, "device-1").
+				Return(testCase.CurrentStatus, nil)
+
+			telemetryStorage := new(mocks.TelemetryStorage)
+			telemetryStorage.On("InsertTelemetrySample",
+				h.ContextMatcher(), mock.AnythingOfType("*deployments.TelemetrySample")).
+				Return(testCase.InputError)
+
+			model := NewDeploymentModel(DeploymentsModelConfig{
+				DeviceDeploymentsStorage: deviceDeploymentStorage,
+				TelemetryStorage:         telemetryStorage,
+				TelemetrySampleRate:      testCase.InputRate,
+			})
+
+			// status is not changed, only telemetry is recorded
+			err := model.UpdateDeviceDeploymentStatus(context.Background(),
+				validUUIDv4, "device-1",
+				deployments.DeviceDeploymentStatus{
+					Status:    deployments.DeviceDeploymentStatusInstalling,
+					Telemetry: testCase.InputTelemetry,
+				})
+			if testCase.OutputError != nil {
+				assert.EqualError(t, err, testCase.OutputError.Error())
+			} else {
+				assert.NoError(t, err)
+			}
+
+			if !testCase.OutputRecorded {
+				telemetryStorage.AssertNotCalled(t, "InsertTelemetrySample",
+					mock.Anything, mock.Anything)
+				return
+			}
+			telemetryStorage.AssertNumberOfCalls(t, "InsertTelemetrySample", 1)
+			sample := telemetryStorage.Calls[0].Arguments.Get(1).(*deployments.TelemetrySample)
+			assert.Equal(t, validUUIDv4, sample.DeploymentID)
+			assert.Equal(t, "device-1", sample.DeviceID)
+			assert.Equal(t, deployments.DeviceDeploymentStatusInstalling, sample.Status)
+			assert.Equal(t, *telemetry, sample.DeviceTelemetry)
+			assert.WithinDuration(t, time.Now(), sample.Reported, time.Minute)
+		})
+	}
+}
+
+func TestDeploymentModelGetDeploymentTelemetryStats(t *testing.T) {
+
+	stats := deployments.TelemetryStats{
+		deployments.DeviceDeploymentStatusFailure: {
+			deployments.TelemetryBatteryLevel: {Count: 2, Min: 5, Max: 15, Avg: 10},
+		},
+	}
+
+	testCases := map[string]struct {
+		InputDeployment     *deployments.Deployment
+		InputDeploymentErr  error
+		InputNoStorage      bool
+		InputAggregateError error
+
+		OutputStats deployments.TelemetryStats
+		OutputError error
+	}{
+		"ok": {
+			InputDeployment: &deployments.Deployment{Id: StringToPointer(validUUIDv4)},
+
+			OutputStats: stats,
+		},
+		"telemetry not configured": {
+			InputDeployment: &deployments.Deployment{Id: StringToPointer(validUUIDv4)},
+			InputNoStorage:  true,
+
+			OutputStats: deployments.TelemetryStats{},
+		},
+		"deployment not found": {},
+		"deployment error": {
+			InputDeploymentErr: errors.New("storage issue"),
+
+			OutputError: errors.New("checking deployment id: storage issue"),
+		},
+		"aggregate error": {
+			InputDeployment:     &deployments.Deployment{Id: StringToPointer(validUUIDv4)},
+			InputAggregateError: errors.New("storage issue"),
+
+			OutputError: errors.New("storage issue"),
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+
+			deploymentStorage := new(mocks.DeploymentsStorage)
+			deploymentStorage.On("FindByID", h.ContextMatcher(), validUUIDv4).
+				Return(testCase.InputDeployment, testCase.InputDeploymentErr)
+
+			config := DeploymentsModelConfig{DeploymentsStorage: deploymentStorage}
+			if !testCase.InputNoStorage {
+				telemetryStorage := new(mocks.TelemetryStorage)
+				telemetryStorage.On("AggregateTelemetry", h.ContextMatcher(), validUUIDv4).
+					Return(stats, testCase.InputAggregateError)
+				config.TelemetryStorage = telemetryStorage
+			}
+
+			out, err := NewDeploymentModel(config).GetDeploymentTelemetryStats(
+				context.Background(), validUUIDv4)
+			if testCase.OutputError != nil {
+				assert.EqualError(t, err, testCase.OutputError.Error())
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, testCase.OutputStats, out)
+		})
+	}
+}
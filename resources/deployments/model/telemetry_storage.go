@@ -0,0 +1,33 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"context"
+
+	"github.com/mendersoftware/deployments/resources/deployments"
+)
+
+// Sampled telemetry of device status reports
+type TelemetryStorage interface {
+	InsertTelemetrySample(ctx context.Context, sample *deployments.TelemetrySample) error
+	// AggregateTelemetry summarizes samples of the deployment by reported
+	// status and metric
+	AggregateTelemetry(ctx context.Context,
+		deploymentID string) (deployments.TelemetryStats, error)
+	// DeleteDeviceTelemetry removes samples of the device, returns their
+	// number
+	DeleteDeviceTelemetry(ctx context.Context, deviceID string) (int, error)
+}
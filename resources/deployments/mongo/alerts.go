@@ -0,0 +1,189 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package mongo
+
+import (
+	"context"
+	"time"
+
+	"github.com/asaskevich/govalidator"
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+	"github.com/mendersoftware/go-lib-micro/store"
+
+	"github.com/mendersoftware/deployments/resources/deployments"
+)
+
+// Database settings
+const (
+	CollectionAlerts      = "alerts"
+	CollectionAlertStates = "alert_states"
+)
+
+// Database keys
+const (
+	StorageKeyAlertCreated    = "created"
+	StorageKeyAlertStateFired = "fired"
+)
+
+// AlertsStorage is a data layer for deployment alerts based on MongoDB
+type AlertsStorage struct {
+	session *mgo.Session
+}
+
+func NewAlertsStorage(session *mgo.Session) *AlertsStorage {
+	return &AlertsStorage{
+		session: session,
+	}
+}
+
+func (a *AlertsStorage) InsertAlert(ctx context.Context, alert *deployments.Alert) error {
+
+	if alert == nil || alert.AlertConstructor == nil {
+		return deployments.NewStoreError("InsertAlert", CollectionAlerts,
+			ErrStorageInvalidInput)
+	}
+
+	if err := alert.Validate(); err != nil {
+		return err
+	}
+
+	session := a.session.Copy()
+	defer session.Close()
+
+	return session.DB(store.DbFromContext(ctx, DatabaseName)).
+		C(CollectionAlerts).Insert(alert)
+}
+
+// FindAlerts returns all alerts, newest first.
+func (a *AlertsStorage) FindAlerts(ctx context.Context) ([]*deployments.Alert, error) {
+
+	session := a.session.Copy()
+	defer session.Close()
+
+	var alerts []*deployments.Alert
+	if err := session.DB(store.DbFromContext(ctx, DatabaseName)).
+		C(CollectionAlerts).Find(nil).
+		Sort("-" + StorageKeyAlertCreated).All(&alerts); err != nil {
+		return nil, err
+	}
+
+	return alerts, nil
+}
+
+func (a *AlertsStorage) DeleteAlert(ctx context.Context, id string) error {
+
+	if govalidator.IsNull(id) {
+		return deployments.NewStoreError("DeleteAlert", CollectionAlerts,
+			ErrStorageInvalidID, id)
+	}
+
+	session := a.session.Copy()
+	defer session.Close()
+
+	err := session.DB(store.DbFromContext(ctx, DatabaseName)).
+		C(CollectionAlerts).RemoveId(id)
+	if err == mgo.ErrNotFound {
+		return deployments.NewStoreError("DeleteAlert", CollectionAlerts,
+			ErrStorageNotFound, id)
+	}
+
+	return err
+}
+
+func (a *AlertsStorage) FindAlertStates(ctx context.Context) ([]*deployments.AlertState, error) {
+
+	session := a.session.Copy()
+	defer session.Close()
+
+	var states []*deployments.AlertState
+	if err := session.DB(store.DbFromContext(ctx, DatabaseName)).
+		C(CollectionAlertStates).Find(nil).All(&states); err != nil {
+		return nil, err
+	}
+
+	return states, nil
+}
+
+// InsertAlertState stores the state unless already stored, e.g. by another
+// instance of the service evaluating alerts at the same time.
+func (a *AlertsStorage) InsertAlertState(ctx context.Context,
+	state *deployments.AlertState) error {
+
+	if state == nil || govalidator.IsNull(state.Id) {
+		return deployments.NewStoreError("InsertAlertState", CollectionAlertStates,
+			ErrStorageInvalidInput)
+	}
+
+	session := a.session.Copy()
+	defer session.Close()
+
+	err := session.DB(store.DbFromContext(ctx, DatabaseName)).
+		C(CollectionAlertStates).Insert(state)
+	if mgo.IsDup(err) {
+		return nil
+	}
+
+	return err
+}
+
+// MarkAlertStateFired sets the time the alert fired, unless already set;
+// only the caller which set it gets true, so that the alert is delivered
+// once.
+func (a *AlertsStorage) MarkAlertStateFired(ctx context.Context,
+	id string, when time.Time) (bool, error) {
+
+	if govalidator.IsNull(id) {
+		return false, deployments.NewStoreError("MarkAlertStateFired", CollectionAlertStates,
+			ErrStorageInvalidID, id)
+	}
+
+	session := a.session.Copy()
+	defer session.Close()
+
+	err := session.DB(store.DbFromContext(ctx, DatabaseName)).
+		C(CollectionAlertStates).Update(bson.M{
+		"_id":                     id,
+		StorageKeyAlertStateFired: bson.M{"$exists": false},
+	}, bson.M{
+		"$set": bson.M{
+			StorageKeyAlertStateFired: when,
+		},
+	})
+	if err == mgo.ErrNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (a *AlertsStorage) DeleteAlertStates(ctx context.Context, ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	session := a.session.Copy()
+	defer session.Close()
+
+	_, err := session.DB(store.DbFromContext(ctx, DatabaseName)).
+		C(CollectionAlertStates).RemoveAll(bson.M{
+		"_id": bson.M{"$in": ids},
+	})
+
+	return err
+}
@@ -0,0 +1,128 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package mongo_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mendersoftware/go-lib-micro/identity"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mendersoftware/deployments/resources/deployments"
+	. "github.com/mendersoftware/deployments/resources/deployments/mongo"
+)
+
+func TestAlertsStorage(t *testing.T) {
+
+	if testing.Short() {
+		t.Skip("skipping TestAlertsStorage in short mode.")
+	}
+
+	// Make sure we start test with empty database
+	db.Wipe()
+
+	session := db.Session()
+	defer session.Close()
+	store := NewAlertsStorage(session)
+
+	ctx := identity.WithContext(context.Background(), &identity.Identity{
+		Tenant: "acme",
+	})
+
+	first := deployments.NewAlertFromConstructor(&deployments.AlertConstructor{
+		Name:      "failures",
+		Condition: deployments.AlertConditionFailureRate,
+		Threshold: 10,
+		Duration:  15,
+	})
+	first.Created = parseTime(t, "2018-12-20T00:00:00Z")
+	second := deployments.NewAlertFromConstructor(&deployments.AlertConstructor{
+		Name:      "stalled",
+		Condition: deployments.AlertConditionNotFinished,
+		Duration:  24 * 60,
+	})
+	second.Created = parseTime(t, "2018-12-21T00:00:00Z")
+
+	assert.Error(t, store.InsertAlert(ctx, nil))
+	assert.Error(t, store.InsertAlert(ctx, &deployments.Alert{}))
+	assert.NoError(t, store.InsertAlert(ctx, first))
+	assert.NoError(t, store.InsertAlert(ctx, second))
+
+	// newest first
+	alerts, err := store.FindAlerts(ctx)
+	assert.NoError(t, err)
+	if assert.Len(t, alerts, 2) {
+		assert.Equal(t, second.Id, alerts[0].Id)
+		assert.Equal(t, first.Id, alerts[1].Id)
+		assert.Equal(t, first.Threshold, alerts[1].Threshold)
+	}
+
+	assert.NoError(t, store.DeleteAlert(ctx, second.Id))
+	assertError(t, store.DeleteAlert(ctx, second.Id), ErrStorageNotFound)
+
+	alerts, err = store.FindAlerts(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, alerts, 1)
+
+	// alerts are stored per tenant
+	alerts, err = store.FindAlerts(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, alerts, 0)
+
+	since := time.Date(2018, 12, 22, 0, 0, 0, 0, time.UTC)
+	state := deployments.NewAlertState(first.Id, "a3d2f4d1-b7b6-4ad4-8e4c-2d2f3c1b9d01", since)
+	other := deployments.NewAlertState(first.Id, "b3d2f4d1-b7b6-4ad4-8e4c-2d2f3c1b9d02", since)
+
+	assert.Error(t, store.InsertAlertState(ctx, nil))
+	assert.NoError(t, store.InsertAlertState(ctx, state))
+	assert.NoError(t, store.InsertAlertState(ctx, other))
+
+	// already stored state is kept
+	assert.NoError(t, store.InsertAlertState(ctx,
+		deployments.NewAlertState(first.Id, state.DeploymentID, since.Add(time.Hour))))
+
+	// fired once only
+	fired, err := store.MarkAlertStateFired(ctx, state.Id, since.Add(time.Hour))
+	assert.NoError(t, err)
+	assert.True(t, fired)
+	fired, err = store.MarkAlertStateFired(ctx, state.Id, since.Add(2*time.Hour))
+	assert.NoError(t, err)
+	assert.False(t, fired)
+
+	states, err := store.FindAlertStates(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, states, 2)
+	for _, s := range states {
+		assert.True(t, since.Equal(s.Since))
+		if s.Id == state.Id {
+			if assert.NotNil(t, s.Fired) {
+				assert.True(t, since.Add(time.Hour).Equal(*s.Fired))
+			}
+		} else {
+			assert.Nil(t, s.Fired)
+		}
+	}
+
+	assert.NoError(t, store.DeleteAlertStates(ctx, []string{state.Id}))
+	assert.NoError(t, store.DeleteAlertStates(ctx, nil))
+
+	states, err = store.FindAlertStates(ctx)
+	assert.NoError(t, err)
+	if assert.Len(t, states, 1) {
+		assert.Equal(t, other.Id, states[0].Id)
+	}
+}
@@ -0,0 +1,132 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package mongo
+
+import (
+	"context"
+
+	"github.com/asaskevich/govalidator"
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+	"github.com/mendersoftware/go-lib-micro/store"
+
+	"github.com/mendersoftware/deployments/resources/deployments"
+)
+
+// Database settings
+const (
+	CollectionCampaigns = "campaigns"
+)
+
+// Database keys
+const (
+	StorageKeyCampaignCreated = "created"
+	StorageKeyCampaignStatus  = "status"
+)
+
+// CampaignsStorage is a data layer for deployment campaigns based on MongoDB
+type CampaignsStorage struct {
+	session *mgo.Session
+}
+
+func NewCampaignsStorage(session *mgo.Session) *CampaignsStorage {
+	return &CampaignsStorage{
+		session: session,
+	}
+}
+
+func (c *CampaignsStorage) InsertCampaign(ctx context.Context,
+	campaign *deployments.Campaign) error {
+
+	if campaign == nil || campaign.CampaignConstructor == nil {
+		return deployments.NewStoreError("InsertCampaign", CollectionCampaigns,
+			ErrStorageInvalidInput)
+	}
+
+	if err := campaign.Validate(); err != nil {
+		return err
+	}
+
+	session := c.session.Copy()
+	defer session.Close()
+
+	return session.DB(store.DbFromContext(ctx, DatabaseName)).
+		C(CollectionCampaigns).Insert(campaign)
+}
+
+// FindCampaigns returns all campaigns, newest first.
+func (c *CampaignsStorage) FindCampaigns(ctx context.Context) ([]*deployments.Campaign, error) {
+
+	session := c.session.Copy()
+	defer session.Close()
+
+	var campaigns []*deployments.Campaign
+	if err := session.DB(store.DbFromContext(ctx, DatabaseName)).
+		C(CollectionCampaigns).Find(nil).
+		Sort("-" + StorageKeyCampaignCreated).All(&campaigns); err != nil {
+		return nil, err
+	}
+
+	return campaigns, nil
+}
+
+// FindCampaignByID returns the campaign, nil if not found.
+func (c *CampaignsStorage) FindCampaignByID(ctx context.Context,
+	id string) (*deployments.Campaign, error) {
+
+	if govalidator.IsNull(id) {
+		return nil, deployments.NewStoreError("FindCampaignByID", CollectionCampaigns,
+			ErrStorageInvalidID, id)
+	}
+
+	session := c.session.Copy()
+	defer session.Close()
+
+	var campaign deployments.Campaign
+	if err := session.DB(store.DbFromContext(ctx, DatabaseName)).
+		C(CollectionCampaigns).FindId(id).One(&campaign); err != nil {
+		if err == mgo.ErrNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &campaign, nil
+}
+
+func (c *CampaignsStorage) SetCampaignStatus(ctx context.Context,
+	id, status string) error {
+
+	if govalidator.IsNull(id) {
+		return deployments.NewStoreError("SetCampaignStatus", CollectionCampaigns,
+			ErrStorageInvalidID, id)
+	}
+
+	session := c.session.Copy()
+	defer session.Close()
+
+	err := session.DB(store.DbFromContext(ctx, DatabaseName)).
+		C(CollectionCampaigns).UpdateId(id, bson.M{
+		"$set": bson.M{
+			StorageKeyCampaignStatus: status,
+		},
+	})
+	if err == mgo.ErrNotFound {
+		return deployments.NewStoreError("SetCampaignStatus", CollectionCampaigns,
+			ErrStorageNotFound, id)
+	}
+
+	return err
+}
@@ -0,0 +1,88 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package mongo_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/mendersoftware/go-lib-micro/identity"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mendersoftware/deployments/resources/deployments"
+	. "github.com/mendersoftware/deployments/resources/deployments/mongo"
+)
+
+func TestCampaignsStorage(t *testing.T) {
+
+	if testing.Short() {
+		t.Skip("skipping TestCampaignsStorage in short mode.")
+	}
+
+	// Make sure we start test with empty database
+	db.Wipe()
+
+	session := db.Session()
+	defer session.Close()
+	store := NewCampaignsStorage(session)
+
+	ctx := identity.WithContext(context.Background(), &identity.Identity{
+		Tenant: "acme",
+	})
+
+	first := deployments.NewCampaignFromConstructor(&deployments.CampaignConstructor{
+		Name:        "release 1.1",
+		Deployments: []string{"a3d2f4d1-b7b6-4ad4-8e4c-2d2f3c1b9d01"},
+	})
+	first.Created = parseTime(t, "2018-12-20T00:00:00Z")
+	second := deployments.NewCampaignFromConstructor(&deployments.CampaignConstructor{
+		Name:        "release 1.2",
+		Deployments: []string{"b3d2f4d1-b7b6-4ad4-8e4c-2d2f3c1b9d02"},
+	})
+	second.Created = parseTime(t, "2018-12-21T00:00:00Z")
+
+	assert.Error(t, store.InsertCampaign(ctx, nil))
+	assert.Error(t, store.InsertCampaign(ctx, &deployments.Campaign{}))
+	assert.NoError(t, store.InsertCampaign(ctx, first))
+	assert.NoError(t, store.InsertCampaign(ctx, second))
+
+	// newest first
+	campaigns, err := store.FindCampaigns(ctx)
+	assert.NoError(t, err)
+	if assert.Len(t, campaigns, 2) {
+		assert.Equal(t, second.Id, campaigns[0].Id)
+		assert.Equal(t, first.Id, campaigns[1].Id)
+	}
+
+	assert.NoError(t, store.SetCampaignStatus(ctx, first.Id, deployments.CampaignStatusPaused))
+	assertError(t, store.SetCampaignStatus(ctx, "missing", deployments.CampaignStatusPaused),
+		ErrStorageNotFound)
+
+	campaign, err := store.FindCampaignByID(ctx, first.Id)
+	assert.NoError(t, err)
+	if assert.NotNil(t, campaign) {
+		assert.Equal(t, deployments.CampaignStatusPaused, campaign.Status)
+		assert.Equal(t, first.Deployments, campaign.Deployments)
+	}
+
+	campaign, err = store.FindCampaignByID(ctx, "missing")
+	assert.NoError(t, err)
+	assert.Nil(t, campaign)
+
+	// campaigns are stored per tenant
+	campaigns, err = store.FindCampaigns(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, campaigns, 0)
+}
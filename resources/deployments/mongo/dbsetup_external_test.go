@@ -19,6 +19,9 @@ import (
 	"testing"
 
 	mtesting "github.com/mendersoftware/go-lib-micro/mongo/testing"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mendersoftware/deployments/resources/deployments"
 )
 
 var db mtesting.TestDBRunner
@@ -33,3 +36,13 @@ func TestMain(m *testing.M) {
 
 	os.Exit(status)
 }
+
+// assertError checks that storage errors are of expected kind,
+// other errors are compared by message.
+func assertError(t *testing.T, err error, expected error) bool {
+	if _, ok := deployments.AsStoreError(err); ok {
+		return assert.True(t, deployments.IsStoreError(err, expected),
+			"expected %v, got %v", expected, err)
+	}
+	return assert.EqualError(t, err, expected.Error())
+}
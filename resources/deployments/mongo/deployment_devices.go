@@ -0,0 +1,256 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package mongo
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+	"github.com/mendersoftware/go-lib-micro/store"
+
+	"github.com/mendersoftware/deployments/resources/deployments"
+)
+
+// Database settings
+const (
+	CollectionDeploymentDevices = "deployment_devices"
+
+	// Number of device IDs stored in single document, keeps documents
+	// well below the mongo document size limit
+	DeploymentDevicesChunkSize = 10000
+)
+
+// Database keys
+const (
+	StorageKeyDeploymentDevicesDeploymentID = "deployment_id"
+	StorageKeyDeploymentDevicesDevices      = "devices"
+)
+
+// Indexes
+const (
+	IndexDeploymentDevicesDeploymentStr = "deploymentDevicesDeploymentIndex"
+	IndexDeploymentDevicesDeviceStr     = "deploymentDevicesDeviceIndex"
+)
+
+// deploymentDevices is a chunk of the deployment device list
+type deploymentDevices struct {
+	Id           string   `bson:"_id"`
+	DeploymentID string   `bson:"deployment_id"`
+	Devices      []string `bson:"devices"`
+}
+
+// DeploymentDevicesStorage is a data layer for device lists of deployments
+// with lazily created device deployments, based on MongoDB.
+// Lists are stored in chunks; devices are removed from the list once their
+// device deployment is created.
+type DeploymentDevicesStorage struct {
+	session *mgo.Session
+}
+
+func NewDeploymentDevicesStorage(session *mgo.Session) *DeploymentDevicesStorage {
+	return &DeploymentDevicesStorage{
+		session: session,
+	}
+}
+
+// Lists are looked up by deployment and by device.
+func (d *DeploymentDevicesStorage) ensureIndexing(ctx context.Context,
+	session *mgo.Session) error {
+
+	for _, index := range []mgo.Index{
+		{
+			Key:        []string{StorageKeyDeploymentDevicesDeploymentID},
+			Name:       IndexDeploymentDevicesDeploymentStr,
+			Background: true,
+		},
+		{
+			Key:        []string{StorageKeyDeploymentDevicesDevices},
+			Name:       IndexDeploymentDevicesDeviceStr,
+			Background: true,
+		},
+	} {
+		if err := session.DB(store.DbFromContext(ctx, DatabaseName)).
+			C(CollectionDeploymentDevices).EnsureIndex(index); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// InsertDeploymentDevices stores device list of the deployment.
+// Can be called repeatedly for the same deployment.
+func (d *DeploymentDevicesStorage) InsertDeploymentDevices(ctx context.Context,
+	deploymentID string, deviceIDs []string) error {
+
+	if deploymentID == "" {
+		return deployments.NewStoreError("InsertDeploymentDevices", CollectionDeploymentDevices,
+			ErrStorageInvalidID, deploymentID)
+	}
+
+	session := d.session.Copy()
+	defer session.Close()
+
+	if err := d.ensureIndexing(ctx, session); err != nil {
+		return err
+	}
+
+	chunks := make([]interface{}, 0, len(deviceIDs)/DeploymentDevicesChunkSize+1)
+	for start := 0; start < len(deviceIDs); start += DeploymentDevicesChunkSize {
+		end := start + DeploymentDevicesChunkSize
+		if end > len(deviceIDs) {
+			end = len(deviceIDs)
+		}
+		chunks = append(chunks, deploymentDevices{
+			Id:           fmt.Sprintf("%s/%s", deploymentID, bson.NewObjectId().Hex()),
+			DeploymentID: deploymentID,
+			Devices:      deviceIDs[start:end],
+		})
+	}
+	if len(chunks) == 0 {
+		return nil
+	}
+
+	return session.DB(store.DbFromContext(ctx, DatabaseName)).
+		C(CollectionDeploymentDevices).Insert(chunks...)
+}
+
+// PullDeploymentDevices removes devices from lists of all deployments.
+// Returns removed device IDs by deployment ID; each device is returned to
+// one caller only, even if called concurrently.
+func (d *DeploymentDevicesStorage) PullDeploymentDevices(ctx context.Context,
+	deviceIDs []string) (map[string][]string, error) {
+
+	session := d.session.Copy()
+	defer session.Close()
+
+	coll := session.DB(store.DbFromContext(ctx, DatabaseName)).
+		C(CollectionDeploymentDevices)
+
+	query := bson.M{
+		StorageKeyDeploymentDevicesDevices: bson.M{
+			"$in": deviceIDs,
+		},
+	}
+
+	var ids []struct {
+		Id string `bson:"_id"`
+	}
+	if err := coll.Find(query).Select(bson.M{"_id": 1}).All(&ids); err != nil {
+		return nil, err
+	}
+
+	wanted := make(map[string]bool, len(deviceIDs))
+	for _, id := range deviceIDs {
+		wanted[id] = true
+	}
+
+	pulled := map[string][]string{}
+	for _, chunk := range ids {
+		change := mgo.Change{
+			Update: bson.M{
+				"$pullAll": bson.M{
+					StorageKeyDeploymentDevicesDevices: deviceIDs,
+				},
+			},
+		}
+
+		// list before the update tells which devices were removed by us
+		var old deploymentDevices
+		_, err := coll.Find(bson.M{
+			"_id":                              chunk.Id,
+			StorageKeyDeploymentDevicesDevices: query[StorageKeyDeploymentDevicesDevices],
+		}).Apply(change, &old)
+		if err == mgo.ErrNotFound {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		for _, id := range old.Devices {
+			if wanted[id] {
+				pulled[old.DeploymentID] = append(pulled[old.DeploymentID], id)
+			}
+		}
+	}
+
+	return pulled, nil
+}
+
+// CountDeploymentDevices counts devices remaining in the deployment list.
+func (d *DeploymentDevicesStorage) CountDeploymentDevices(ctx context.Context,
+	deploymentID string) (int, error) {
+
+	session := d.session.Copy()
+	defer session.Close()
+
+	pipe := []bson.M{
+		{
+			"$match": bson.M{
+				StorageKeyDeploymentDevicesDeploymentID: deploymentID,
+			},
+		},
+		{
+			"$group": bson.M{
+				"_id": nil,
+				"count": bson.M{
+					"$sum": bson.M{"$size": "$" + StorageKeyDeploymentDevicesDevices},
+				},
+			},
+		},
+	}
+
+	var result struct {
+		Count int `bson:"count"`
+	}
+	err := session.DB(store.DbFromContext(ctx, DatabaseName)).
+		C(CollectionDeploymentDevices).Pipe(&pipe).One(&result)
+	if err == mgo.ErrNotFound {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+
+	return result.Count, nil
+}
+
+// DeleteDeploymentDevices removes the deployment list.
+// Returns number of devices which were in the list.
+func (d *DeploymentDevicesStorage) DeleteDeploymentDevices(ctx context.Context,
+	deploymentID string) (int, error) {
+
+	session := d.session.Copy()
+	defer session.Close()
+
+	coll := session.DB(store.DbFromContext(ctx, DatabaseName)).
+		C(CollectionDeploymentDevices)
+
+	// chunk by chunk, to count exactly devices not pulled concurrently
+	count := 0
+	for {
+		var old deploymentDevices
+		_, err := coll.Find(bson.M{
+			StorageKeyDeploymentDevicesDeploymentID: deploymentID,
+		}).Apply(mgo.Change{Remove: true}, &old)
+		if err == mgo.ErrNotFound {
+			return count, nil
+		} else if err != nil {
+			return count, err
+		}
+		count += len(old.Devices)
+	}
+}
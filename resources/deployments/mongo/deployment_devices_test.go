@@ -0,0 +1,96 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package mongo_test
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"testing"
+
+	"github.com/mendersoftware/go-lib-micro/identity"
+	"github.com/stretchr/testify/assert"
+
+	. "github.com/mendersoftware/deployments/resources/deployments/mongo"
+)
+
+func TestDeploymentDevicesStorage(t *testing.T) {
+
+	if testing.Short() {
+		t.Skip("skipping TestDeploymentDevicesStorage in short mode.")
+	}
+
+	// Make sure we start test with empty database
+	db.Wipe()
+
+	session := db.Session()
+	defer session.Close()
+	store := NewDeploymentDevicesStorage(session)
+
+	ctx := identity.WithContext(context.Background(), &identity.Identity{
+		Tenant: "acme",
+	})
+
+	// spans multiple chunks
+	devices := make([]string, DeploymentDevicesChunkSize+10)
+	for i := range devices {
+		devices[i] = fmt.Sprintf("device-%d", i)
+	}
+
+	assertError(t, store.InsertDeploymentDevices(ctx, "", devices), ErrStorageInvalidID)
+	assert.NoError(t, store.InsertDeploymentDevices(ctx, "deployment-1", devices))
+	assert.NoError(t, store.InsertDeploymentDevices(ctx, "deployment-2",
+		[]string{"device-1", "device-2"}))
+
+	count, err := store.CountDeploymentDevices(ctx, "deployment-1")
+	assert.NoError(t, err)
+	assert.Equal(t, len(devices), count)
+
+	count, err = store.CountDeploymentDevices(ctx, "missing")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, count)
+
+	pulled, err := store.PullDeploymentDevices(ctx,
+		[]string{"device-2", devices[len(devices)-1], "unknown"})
+	assert.NoError(t, err)
+	for _, ids := range pulled {
+		sort.Strings(ids)
+	}
+	assert.Equal(t, map[string][]string{
+		"deployment-1": {"device-2", devices[len(devices)-1]},
+		"deployment-2": {"device-2"},
+	}, pulled)
+
+	// devices are pulled only once
+	pulled, err = store.PullDeploymentDevices(ctx, []string{"device-2"})
+	assert.NoError(t, err)
+	assert.Empty(t, pulled)
+
+	count, err = store.CountDeploymentDevices(ctx, "deployment-1")
+	assert.NoError(t, err)
+	assert.Equal(t, len(devices)-2, count)
+
+	count, err = store.DeleteDeploymentDevices(ctx, "deployment-1")
+	assert.NoError(t, err)
+	assert.Equal(t, len(devices)-2, count)
+
+	count, err = store.CountDeploymentDevices(ctx, "deployment-1")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, count)
+
+	count, err = store.CountDeploymentDevices(ctx, "deployment-2")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, count)
+}
@@ -16,6 +16,7 @@ package mongo
 
 import (
 	"context"
+	"fmt"
 	"time"
 
 	"github.com/asaskevich/govalidator"
@@ -29,30 +30,53 @@ import (
 
 // Database settings
 const (
-	DatabaseName          = "deployment_service"
-	CollectionDeployments = "deployments"
+	DatabaseName               = "deployment_service"
+	CollectionDeployments      = "deployments"
+	CollectionDownloadCounters = "download_counters"
+	CollectionStatsRollups     = "stats_rollups"
 )
 
 // Errors
 var (
 	ErrDeploymentStorageInvalidDeployment = errors.New("Invalid deployment")
-	ErrStorageInvalidID                   = errors.New("Invalid id")
-	ErrStorageNotFound                    = errors.New("Not found")
+	ErrStorageInvalidID                   = deployments.ErrStorageInvalidID
+	ErrStorageNotFound                    = deployments.ErrStorageNotFound
 	ErrDeploymentStorageInvalidQuery      = errors.New("Invalid query")
 	ErrDeploymentStorageCannotExecQuery   = errors.New("Cannot execute query")
-	ErrStorageInvalidInput                = errors.New("invalid input")
+	ErrStorageInvalidInput                = deployments.ErrStorageInvalidInput
+	ErrStorageFinishedStatus              = deployments.ErrStorageFinishedStatus
 )
 
 const (
 	StorageKeyDeploymentName         = "deploymentconstructor.name"
+	StorageKeyDeploymentSlug         = "deploymentconstructor.slug"
 	StorageKeyDeploymentArtifactName = "deploymentconstructor.artifactname"
 	StorageKeyDeploymentStats        = "stats"
 	StorageKeyDeploymentFinished     = "finished"
 	StorageKeyDeploymentArtifacts    = "artifacts"
+	StorageKeyDeploymentAbort        = "abort"
+	StorageKeyDeploymentCreated      = "created"
+	StorageKeyDeploymentPaused       = "paused"
+	StorageKeyDeploymentOverQuota    = "over_quota"
+)
+
+const (
+	StorageKeyDownloadCounterCount   = "count"
+	StorageKeyDownloadCounterCreated = "created"
+)
+
+const (
+	StorageKeyStatsRollupPeriod = "period"
+	StorageKeyStatsRollupStats  = "stats"
 )
 
 const (
 	IndexDeploymentArtifactNameStr = "deploymentArtifactNameIndex"
+	IndexDeploymentSlugStr         = "deploymentSlugIndex"
+	IndexDownloadCountersExpireStr = "downloadCountersExpireIndex"
+	DownloadCountersExpireAfter    = time.Hour
+	IndexStatsRollupsExpireStr     = "statsRollupsExpireIndex"
+	StatsRollupsExpireAfter        = 8 * 24 * time.Hour
 )
 
 var (
@@ -92,6 +116,41 @@ func (d *DeploymentsStorage) DoEnsureIndexing(db string, session *mgo.Session) e
 		EnsureIndex(deploymentArtifactNameIndex)
 }
 
+// Download counters are only needed for the current period, make mongo
+// remove stale ones.
+func (d *DeploymentsStorage) ensureDownloadCountersIndexing(ctx context.Context,
+	session *mgo.Session) error {
+
+	expireIndex := mgo.Index{
+		Key:         []string{StorageKeyDownloadCounterCreated},
+		Name:        IndexDownloadCountersExpireStr,
+		ExpireAfter: DownloadCountersExpireAfter,
+		Background:  true,
+	}
+
+	return session.DB(store.DbFromContext(ctx, DatabaseName)).
+		C(CollectionDownloadCounters).
+		EnsureIndex(expireIndex)
+}
+
+// Slugs are unique; deployments created before slugs were introduced
+// have none.
+func (d *DeploymentsStorage) ensureSlugIndexing(ctx context.Context,
+	session *mgo.Session) error {
+
+	slugIndex := mgo.Index{
+		Key:        []string{StorageKeyDeploymentSlug},
+		Name:       IndexDeploymentSlugStr,
+		Unique:     true,
+		Sparse:     true,
+		Background: true,
+	}
+
+	return session.DB(store.DbFromContext(ctx, DatabaseName)).
+		C(CollectionDeployments).
+		EnsureIndex(slugIndex)
+}
+
 // return true if required indexing was set up
 func (d *DeploymentsStorage) hasIndexing(ctx context.Context, session *mgo.Session) bool {
 	idxs, err := session.DB(store.DbFromContext(ctx, DatabaseName)).
@@ -121,7 +180,8 @@ func (d *DeploymentsStorage) hasIndexing(ctx context.Context, session *mgo.Sessi
 func (d *DeploymentsStorage) Insert(ctx context.Context, deployment *deployments.Deployment) error {
 
 	if deployment == nil {
-		return ErrDeploymentStorageInvalidDeployment
+		return deployments.NewStoreError("Insert", CollectionDeployments,
+			ErrDeploymentStorageInvalidDeployment)
 	}
 
 	if err := deployment.Validate(); err != nil {
@@ -135,6 +195,25 @@ func (d *DeploymentsStorage) Insert(ctx context.Context, deployment *deployments
 		return err
 	}
 
+	if deployment.DeploymentConstructor != nil &&
+		deployment.DownloadSchedule.IsRateLimited() {
+		if err := d.ensureDownloadCountersIndexing(ctx, session); err != nil {
+			return err
+		}
+	}
+
+	if deployment.DeploymentConstructor != nil && deployment.Slug != "" {
+		if err := d.ensureSlugIndexing(ctx, session); err != nil {
+			return err
+		}
+	}
+
+	if deployment.DeploymentConstructor != nil && len(deployment.Phases) > 0 {
+		if err := d.ensurePhasesIndexing(ctx, session); err != nil {
+			return err
+		}
+	}
+
 	if err := session.DB(store.DbFromContext(ctx, DatabaseName)).
 		C(CollectionDeployments).Insert(deployment); err != nil {
 		return err
@@ -147,7 +226,8 @@ func (d *DeploymentsStorage) Insert(ctx context.Context, deployment *deployments
 func (d *DeploymentsStorage) Delete(ctx context.Context, id string) error {
 
 	if govalidator.IsNull(id) {
-		return ErrStorageInvalidID
+		return deployments.NewStoreError("Delete", CollectionDeployments,
+			ErrStorageInvalidID, id)
 	}
 
 	session := d.session.Copy()
@@ -167,7 +247,8 @@ func (d *DeploymentsStorage) Delete(ctx context.Context, id string) error {
 func (d *DeploymentsStorage) FindByID(ctx context.Context, id string) (*deployments.Deployment, error) {
 
 	if govalidator.IsNull(id) {
-		return nil, ErrStorageInvalidID
+		return nil, deployments.NewStoreError("FindByID", CollectionDeployments,
+			ErrStorageInvalidID, id)
 	}
 
 	session := d.session.Copy()
@@ -185,11 +266,37 @@ func (d *DeploymentsStorage) FindByID(ctx context.Context, id string) (*deployme
 	return deployment, nil
 }
 
+// FindBySlug returns deployment with the slug, nil if not found.
+func (d *DeploymentsStorage) FindBySlug(ctx context.Context,
+	slug string) (*deployments.Deployment, error) {
+
+	if govalidator.IsNull(slug) {
+		return nil, deployments.NewStoreError("FindBySlug", CollectionDeployments,
+			ErrStorageInvalidInput, slug)
+	}
+
+	session := d.session.Copy()
+	defer session.Close()
+
+	var deployment *deployments.Deployment
+	if err := session.DB(store.DbFromContext(ctx, DatabaseName)).
+		C(CollectionDeployments).Find(bson.M{StorageKeyDeploymentSlug: slug}).
+		One(&deployment); err != nil {
+		if err.Error() == mgo.ErrNotFound.Error() {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return deployment, nil
+}
+
 func (d *DeploymentsStorage) FindUnfinishedByID(ctx context.Context,
 	id string) (*deployments.Deployment, error) {
 
 	if govalidator.IsNull(id) {
-		return nil, ErrStorageInvalidID
+		return nil, deployments.NewStoreError("FindUnfinishedByID", CollectionDeployments,
+			ErrStorageInvalidID, id)
 	}
 
 	session := d.session.Copy()
@@ -221,13 +328,22 @@ func (d *DeploymentsStorage) DeviceCountByDeployment(ctx context.Context,
 		"deploymentid": id,
 	}
 
-	deviceCount, err := session.DB(store.DbFromContext(ctx, DatabaseName)).
-		C(CollectionDevices).Find(filter).Count()
-
+	// device deployments of finished deployments may be partitioned
+	db := session.DB(store.DbFromContext(ctx, DatabaseName))
+	collections, err := deploymentCollections(db, id)
 	if err != nil {
 		return 0, err
 	}
 
+	deviceCount := 0
+	for _, collection := range collections {
+		count, err := db.C(collection).Find(filter).Count()
+		if err != nil {
+			return 0, err
+		}
+		deviceCount += count
+	}
+
 	return deviceCount, nil
 }
 
@@ -235,7 +351,8 @@ func (d *DeploymentsStorage) UpdateStatsAndFinishDeployment(ctx context.Context,
 	id string, stats deployments.Stats) error {
 
 	if govalidator.IsNull(id) {
-		return ErrStorageInvalidID
+		return deployments.NewStoreError("UpdateStatsAndFinishDeployment", CollectionDeployments,
+			ErrStorageInvalidID, id)
 	}
 
 	session := d.session.Copy()
@@ -263,8 +380,77 @@ func (d *DeploymentsStorage) UpdateStatsAndFinishDeployment(ctx context.Context,
 	err := session.DB(store.DbFromContext(ctx, DatabaseName)).
 		C(CollectionDeployments).UpdateId(id, update)
 	if err == mgo.ErrNotFound {
-		return ErrStorageInvalidID
+		return deployments.NewStoreError("UpdateStatsAndFinishDeployment", CollectionDeployments,
+			ErrStorageInvalidID, id)
+	}
+
+	return err
+}
+
+// SetAbortInfo records abort details on the deployment.
+func (d *DeploymentsStorage) SetAbortInfo(ctx context.Context, id string,
+	abort *deployments.AbortInfo) error {
+
+	if govalidator.IsNull(id) {
+		return deployments.NewStoreError("SetAbortInfo", CollectionDeployments,
+			ErrStorageInvalidID, id)
+	}
+
+	if abort == nil {
+		return deployments.NewStoreError("SetAbortInfo", CollectionDeployments,
+			ErrStorageInvalidInput, id)
+	}
+
+	session := d.session.Copy()
+	defer session.Close()
+
+	update := bson.M{
+		"$set": bson.M{
+			StorageKeyDeploymentAbort: abort,
+		},
+	}
+
+	err := session.DB(store.DbFromContext(ctx, DatabaseName)).
+		C(CollectionDeployments).UpdateId(id, update)
+	if err == mgo.ErrNotFound {
+		return deployments.NewStoreError("SetAbortInfo", CollectionDeployments,
+			ErrStorageInvalidID, id)
+	}
+
+	return err
+}
+
+// SetPaused pauses or resumes the unfinished deployments.
+func (d *DeploymentsStorage) SetPaused(ctx context.Context, ids []string,
+	paused bool) error {
+
+	if len(ids) == 0 {
+		return nil
+	}
+
+	session := d.session.Copy()
+	defer session.Close()
+
+	// finished deployments are never paused, but are always resumed
+	selector := bson.M{
+		"_id": bson.M{"$in": ids},
+	}
+	update := bson.M{
+		"$unset": bson.M{
+			StorageKeyDeploymentPaused: "",
+		},
 	}
+	if paused {
+		selector[StorageKeyDeploymentFinished] = nil
+		update = bson.M{
+			"$set": bson.M{
+				StorageKeyDeploymentPaused: true,
+			},
+		}
+	}
+
+	_, err := session.DB(store.DbFromContext(ctx, DatabaseName)).
+		C(CollectionDeployments).UpdateAll(selector, update)
 
 	return err
 }
@@ -273,15 +459,18 @@ func (d *DeploymentsStorage) UpdateStats(ctx context.Context, id string,
 	state_from, state_to string) error {
 
 	if govalidator.IsNull(id) {
-		return ErrStorageInvalidID
+		return deployments.NewStoreError("UpdateStats", CollectionDeployments,
+			ErrStorageInvalidID, id)
 	}
 
 	if govalidator.IsNull(state_from) {
-		return ErrStorageInvalidInput
+		return deployments.NewStoreError("UpdateStats", CollectionDeployments,
+			ErrStorageInvalidInput, id)
 	}
 
 	if govalidator.IsNull(state_to) {
-		return ErrStorageInvalidInput
+		return deployments.NewStoreError("UpdateStats", CollectionDeployments,
+			ErrStorageInvalidInput, id)
 	}
 
 	// does not need any extra operations
@@ -305,9 +494,42 @@ func (d *DeploymentsStorage) UpdateStats(ctx context.Context, id string,
 		C(CollectionDeployments).UpdateId(id, update)
 
 	if err == mgo.ErrNotFound {
-		return ErrStorageInvalidID
+		return deployments.NewStoreError("UpdateStats", CollectionDeployments,
+			ErrStorageInvalidID, id)
+	}
+
+	return err
+}
+
+// IncrementStats adds the given counts, by deployment ID, to statistics
+// of the deployments with a single bulk write.
+func (d *DeploymentsStorage) IncrementStats(ctx context.Context,
+	stats map[string]deployments.Stats) error {
+
+	session := d.session.Copy()
+	defer session.Close()
+
+	bulk := session.DB(store.DbFromContext(ctx, DatabaseName)).
+		C(CollectionDeployments).Bulk()
+	updates := 0
+	for id, counts := range stats {
+		inc := bson.M{}
+		for status, count := range counts {
+			if count != 0 {
+				inc[buildStatusKey(status)] = count
+			}
+		}
+		if len(inc) == 0 {
+			continue
+		}
+		bulk.Update(bson.M{"_id": id}, bson.M{"$inc": inc})
+		updates++
+	}
+	if updates == 0 {
+		return nil
 	}
 
+	_, err := bulk.Run()
 	return err
 }
 
@@ -319,6 +541,8 @@ func buildStatusQuery(status deployments.StatusQuery) bson.M {
 
 	gt0 := bson.M{"$gt": 0}
 	eq0 := bson.M{"$eq": 0}
+	// matches also deployments created before the counter was introduced
+	notGt0 := bson.M{"$not": gt0}
 	notNull := bson.M{"$ne": nil}
 
 	// empty query, catches StatusQueryAny
@@ -393,6 +617,12 @@ func buildStatusQuery(status deployments.StatusQuery) bson.M {
 					{
 						buildStatusKey(deployments.DeviceDeploymentStatusDecommissioned): eq0,
 					},
+					{
+						buildStatusKey(deployments.DeviceDeploymentStatusSuperseded): notGt0,
+					},
+					{
+						buildStatusKey(deployments.DeviceDeploymentStatusTimedOut): notGt0,
+					},
 					{
 						buildStatusKey(deployments.DeviceDeploymentStatusFailure): eq0,
 					},
@@ -409,6 +639,12 @@ func buildStatusQuery(status deployments.StatusQuery) bson.M {
 		{
 			stq = bson.M{StorageKeyDeploymentFinished: notNull}
 		}
+	case deployments.StatusQueryAborted:
+		{
+			stq = bson.M{
+				buildStatusKey(deployments.DeviceDeploymentStatusAborted): gt0,
+			}
+		}
 	}
 
 	return stq
@@ -426,7 +662,8 @@ func (d *DeploymentsStorage) Find(ctx context.Context,
 	if match.SearchText != "" {
 		// we must have indexing for text search
 		if !d.hasIndexing(ctx, session) {
-			return nil, ErrDeploymentStorageCannotExecQuery
+			return nil, deployments.NewStoreError("Find", CollectionDeployments,
+				ErrDeploymentStorageCannotExecQuery)
 		}
 
 		tq := bson.M{
@@ -452,6 +689,10 @@ func (d *DeploymentsStorage) Find(ctx context.Context,
 		}
 	}
 
+	if match.OverQuota {
+		query[StorageKeyDeploymentOverQuota] = bson.M{"$exists": true}
+	}
+
 	if match.CreatedAfter != nil && match.CreatedBefore != nil {
 		query["created"] = bson.M{
 			"$gte": match.CreatedAfter,
@@ -483,7 +724,8 @@ func (d *DeploymentsStorage) Find(ctx context.Context,
 
 func (d *DeploymentsStorage) Finish(ctx context.Context, id string, when time.Time) error {
 	if govalidator.IsNull(id) {
-		return ErrStorageInvalidID
+		return deployments.NewStoreError("Finish", CollectionDeployments,
+			ErrStorageInvalidID, id)
 	}
 
 	session := d.session.Copy()
@@ -500,7 +742,36 @@ func (d *DeploymentsStorage) Finish(ctx context.Context, id string, when time.Ti
 		C(CollectionDeployments).UpdateId(id, update)
 
 	if err == mgo.ErrNotFound {
-		return ErrStorageInvalidID
+		return deployments.NewStoreError("Finish", CollectionDeployments,
+			ErrStorageInvalidID, id)
+	}
+
+	return err
+}
+
+// Reopen clears finish time of the deployment, e.g. when one of its device
+// deployments is retried.
+func (d *DeploymentsStorage) Reopen(ctx context.Context, id string) error {
+	if govalidator.IsNull(id) {
+		return deployments.NewStoreError("Reopen", CollectionDeployments,
+			ErrStorageInvalidID, id)
+	}
+
+	session := d.session.Copy()
+	defer session.Close()
+
+	update := bson.M{
+		"$unset": bson.M{
+			StorageKeyDeploymentFinished: "",
+		},
+	}
+
+	err := session.DB(store.DbFromContext(ctx, DatabaseName)).
+		C(CollectionDeployments).UpdateId(id, update)
+
+	if err == mgo.ErrNotFound {
+		return deployments.NewStoreError("Reopen", CollectionDeployments,
+			ErrStorageNotFound, id)
 	}
 
 	return err
@@ -512,7 +783,8 @@ func (d *DeploymentsStorage) ExistUnfinishedByArtifactId(ctx context.Context,
 	id string) (bool, error) {
 
 	if govalidator.IsNull(id) {
-		return false, ErrStorageInvalidID
+		return false, deployments.NewStoreError("ExistUnfinishedByArtifactId", CollectionDeployments,
+			ErrStorageInvalidID, id)
 	}
 
 	session := d.session.Copy()
@@ -539,7 +811,36 @@ func (d *DeploymentsStorage) ExistByArtifactId(ctx context.Context,
 	id string) (bool, error) {
 
 	if govalidator.IsNull(id) {
-		return false, ErrStorageInvalidID
+		return false, deployments.NewStoreError("ExistByArtifactId", CollectionDeployments,
+			ErrStorageInvalidID, id)
+	}
+
+	session := d.session.Copy()
+	defer session.Close()
+
+	var tmp interface{}
+	query := bson.M{
+		StorageKeyDeploymentArtifacts: id,
+	}
+	if err := session.DB(store.DbFromContext(ctx, DatabaseName)).
+		C(CollectionDeployments).Find(query).One(&tmp); err != nil {
+		if err.Error() == mgo.ErrNotFound.Error() {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return true, nil
+}
+
+// ExistByArtifactIdCreatedAfter check if there is any deployment created after
+// given time that uses given artifact
+func (d *DeploymentsStorage) ExistByArtifactIdCreatedAfter(ctx context.Context,
+	id string, since time.Time) (bool, error) {
+
+	if govalidator.IsNull(id) {
+		return false, deployments.NewStoreError("ExistByArtifactIdCreatedAfter", CollectionDeployments,
+			ErrStorageInvalidID, id)
 	}
 
 	session := d.session.Copy()
@@ -548,6 +849,9 @@ func (d *DeploymentsStorage) ExistByArtifactId(ctx context.Context,
 	var tmp interface{}
 	query := bson.M{
 		StorageKeyDeploymentArtifacts: id,
+		StorageKeyDeploymentCreated: bson.M{
+			"$gt": since,
+		},
 	}
 	if err := session.DB(store.DbFromContext(ctx, DatabaseName)).
 		C(CollectionDeployments).Find(query).One(&tmp); err != nil {
@@ -559,3 +863,137 @@ func (d *DeploymentsStorage) ExistByArtifactId(ctx context.Context,
 
 	return true, nil
 }
+
+// IncrementDownloadCount increments number of download links issued for the
+// deployment in the period starting at given time. Returns updated counter.
+func (d *DeploymentsStorage) IncrementDownloadCount(ctx context.Context,
+	id string, period time.Time) (int, error) {
+
+	if govalidator.IsNull(id) {
+		return 0, deployments.NewStoreError("IncrementDownloadCount", CollectionDownloadCounters,
+			ErrStorageInvalidID, id)
+	}
+
+	session := d.session.Copy()
+	defer session.Close()
+
+	change := mgo.Change{
+		Update: bson.M{
+			"$inc": bson.M{
+				StorageKeyDownloadCounterCount: 1,
+			},
+			"$setOnInsert": bson.M{
+				StorageKeyDownloadCounterCreated: period,
+			},
+		},
+		Upsert:    true,
+		ReturnNew: true,
+	}
+
+	var counter struct {
+		Count int `bson:"count"`
+	}
+	counterID := fmt.Sprintf("%s/%d", id, period.Unix())
+	if _, err := session.DB(store.DbFromContext(ctx, DatabaseName)).
+		C(CollectionDownloadCounters).FindId(counterID).
+		Apply(change, &counter); err != nil {
+		return 0, err
+	}
+
+	return counter.Count, nil
+}
+
+// CountByStatus counts deployments in the given state.
+func (d *DeploymentsStorage) CountByStatus(ctx context.Context,
+	status deployments.StatusQuery) (int, error) {
+
+	session := d.session.Copy()
+	defer session.Close()
+
+	return session.DB(store.DbFromContext(ctx, DatabaseName)).
+		C(CollectionDeployments).
+		Find(buildStatusQuery(status)).
+		Count()
+}
+
+func (d *DeploymentsStorage) ensureStatsRollupsIndexing(ctx context.Context,
+	session *mgo.Session) error {
+
+	expireIndex := mgo.Index{
+		Key:         []string{StorageKeyStatsRollupPeriod},
+		Name:        IndexStatsRollupsExpireStr,
+		ExpireAfter: StatsRollupsExpireAfter,
+		Background:  true,
+	}
+
+	return session.DB(store.DbFromContext(ctx, DatabaseName)).
+		C(CollectionStatsRollups).
+		EnsureIndex(expireIndex)
+}
+
+// IncrementStatsRollup counts device deployment finished with the given
+// status in the rollup period containing the given time.
+func (d *DeploymentsStorage) IncrementStatsRollup(ctx context.Context,
+	when time.Time, status string) error {
+
+	if govalidator.IsNull(status) {
+		return deployments.NewStoreError("IncrementStatsRollup", CollectionStatsRollups,
+			ErrStorageInvalidInput)
+	}
+
+	session := d.session.Copy()
+	defer session.Close()
+
+	if err := d.ensureStatsRollupsIndexing(ctx, session); err != nil {
+		return err
+	}
+
+	period := when.UTC().Truncate(deployments.StatsRollupPeriod)
+	update := bson.M{
+		"$inc": bson.M{
+			StorageKeyStatsRollupStats + "." + status: 1,
+		},
+		"$setOnInsert": bson.M{
+			StorageKeyStatsRollupPeriod: period,
+		},
+	}
+
+	_, err := session.DB(store.DbFromContext(ctx, DatabaseName)).
+		C(CollectionStatsRollups).
+		UpsertId(period.Unix(), update)
+
+	return err
+}
+
+// AggregateStatsRollups sums device deployment statistics of rollup
+// periods starting at or after the given time.
+func (d *DeploymentsStorage) AggregateStatsRollups(ctx context.Context,
+	since time.Time) (deployments.Stats, error) {
+
+	session := d.session.Copy()
+	defer session.Close()
+
+	query := bson.M{
+		StorageKeyStatsRollupPeriod: bson.M{
+			"$gte": since.UTC().Truncate(deployments.StatsRollupPeriod),
+		},
+	}
+
+	var rollups []struct {
+		Stats map[string]int `bson:"stats"`
+	}
+	if err := session.DB(store.DbFromContext(ctx, DatabaseName)).
+		C(CollectionStatsRollups).
+		Find(query).All(&rollups); err != nil {
+		return nil, err
+	}
+
+	stats := deployments.NewDeviceDeploymentStats()
+	for _, rollup := range rollups {
+		for status, count := range rollup.Stats {
+			stats[status] += count
+		}
+	}
+
+	return stats, nil
+}
@@ -90,7 +90,7 @@ func TestDeploymentStorageInsert(t *testing.T) {
 			err := store.Insert(ctx, testCase.InputDeployment)
 
 			if testCase.OutputError != nil {
-				assert.EqualError(t, err, testCase.OutputError.Error())
+				assertError(t, err, testCase.OutputError)
 			} else {
 				assert.NoError(t, err)
 
@@ -190,7 +190,7 @@ func TestDeploymentStorageDelete(t *testing.T) {
 			err := store.Delete(ctx, testCase.InputID)
 
 			if testCase.OutputError != nil {
-				assert.EqualError(t, err, testCase.OutputError.Error())
+				assertError(t, err, testCase.OutputError)
 			} else {
 				assert.NoError(t, err)
 
@@ -373,7 +373,7 @@ func TestDeploymentStorageFindByID(t *testing.T) {
 			deployment, err := store.FindByID(ctx, testCase.InputID)
 
 			if testCase.OutputError != nil {
-				assert.EqualError(t, err, testCase.OutputError.Error())
+				assertError(t, err, testCase.OutputError)
 			} else {
 				assert.NoError(t, err)
 				if deployment != nil && assert.Equal(t, 0, len(deployment.Artifacts)) {
@@ -590,7 +590,7 @@ func TestDeploymentStorageFindUnfinishedByID(t *testing.T) {
 			deployment, err := store.FindUnfinishedByID(ctx, testCase.InputID)
 
 			if testCase.OutputError != nil {
-				assert.EqualError(t, err, testCase.OutputError.Error())
+				assertError(t, err, testCase.OutputError)
 			} else {
 				assert.NoError(t, err)
 				if deployment != nil && assert.Equal(t, 0, len(deployment.Artifacts)) {
@@ -836,7 +836,7 @@ func TestDeploymentStorageUpdateStats(t *testing.T) {
 				tc.InputID, tc.InputStateFrom, tc.InputStateTo)
 
 			if tc.OutputError != nil {
-				assert.EqualError(t, err, tc.OutputError.Error())
+				assertError(t, err, tc.OutputError)
 			} else {
 				var deployment *deployments.Deployment
 				err := session.DB(ctxstore.DbFromContext(ctx, DatabaseName)).
@@ -865,6 +865,59 @@ func TestDeploymentStorageUpdateStats(t *testing.T) {
 	}
 }
 
+func TestDeploymentStorageIncrementStats(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping TestDeploymentStorageIncrementStats in short mode.")
+	}
+
+	db.Wipe()
+	session := db.Session()
+	defer session.Close()
+
+	store := NewDeploymentsStorage(session)
+	ctx := context.Background()
+
+	ids := []string{"a108ae14-bb4e-455f-9b40-2ef4bab97bb7",
+		"d1804903-5caa-4a73-a3ae-0efcc3205405"}
+	for _, id := range ids {
+		err := session.DB(DatabaseName).C(CollectionDeployments).Insert(
+			&deployments.Deployment{
+				Id: StringToPointer(id),
+				Stats: deployments.Stats{
+					deployments.DeviceDeploymentStatusPending: 5,
+				},
+			})
+		assert.NoError(t, err)
+	}
+
+	err := store.IncrementStats(ctx, map[string]deployments.Stats{
+		ids[0]: {
+			deployments.DeviceDeploymentStatusPending:     -3,
+			deployments.DeviceDeploymentStatusDownloading: 3,
+		},
+		ids[1]: {
+			deployments.DeviceDeploymentStatusPending: 0,
+		},
+	})
+	assert.NoError(t, err)
+
+	for id, stats := range map[string]deployments.Stats{
+		ids[0]: {
+			deployments.DeviceDeploymentStatusPending:     2,
+			deployments.DeviceDeploymentStatusDownloading: 3,
+		},
+		ids[1]: {
+			deployments.DeviceDeploymentStatusPending: 5,
+		},
+	} {
+		var deployment *deployments.Deployment
+		err := session.DB(DatabaseName).C(CollectionDeployments).
+			FindId(id).One(&deployment)
+		assert.NoError(t, err)
+		assert.Equal(t, stats, deployment.Stats)
+	}
+}
+
 func TestDeploymentStorageUpdateStatsAndFinishDeployment(t *testing.T) {
 	if testing.Short() {
 		t.Skip("skipping TestDeploymentStorageUpdateStatsAndFinishDeployment in short mode.")
@@ -964,7 +1017,7 @@ func TestDeploymentStorageUpdateStatsAndFinishDeployment(t *testing.T) {
 				tc.InputID, tc.InputStats)
 
 			if tc.OutputError != nil {
-				assert.EqualError(t, err, tc.OutputError.Error())
+				assertError(t, err, tc.OutputError)
 			} else {
 				var deployment *deployments.Deployment
 				err := session.DB(ctxstore.DbFromContext(ctx, DatabaseName)).
@@ -981,7 +1034,7 @@ func TestDeploymentStorageUpdateStatsAndFinishDeployment(t *testing.T) {
 				// raise an error
 				err := store.UpdateStatsAndFinishDeployment(context.Background(),
 					tc.InputID, tc.InputStats)
-				assert.EqualError(t, err, ErrStorageInvalidID.Error())
+				assertError(t, err, ErrStorageInvalidID)
 			}
 
 			// Need to close all sessions to be able to call wipe at next test case
@@ -1312,6 +1365,30 @@ func TestDeploymentStorageFindBy(t *testing.T) {
 				"a108ae14-bb4e-455f-9b40-000000000001",
 			},
 		},
+		{
+			InputModelQuery: deployments.Query{
+				Status: deployments.StatusQueryAborted,
+			},
+			InputDeploymentsCollection: append([]*deployments.Deployment{
+				{
+					DeploymentConstructor: &deployments.DeploymentConstructor{
+						Name:         StringToPointer("baz"),
+						ArtifactName: StringToPointer("asdf"),
+						Devices:      []string{"b532b01a-9313-404f-8d19-e7fcbe5cc347"},
+					},
+					Id: StringToPointer("a108ae14-bb4e-455f-9b40-000000000015"),
+					Stats: newTestStats(deployments.Stats{
+						deployments.DeviceDeploymentStatusAborted: 1,
+						deployments.DeviceDeploymentStatusSuccess: 1,
+					}),
+					Finished: &now,
+				},
+			}, someDeployments...),
+			OutputError: nil,
+			OutputID: []string{
+				"a108ae14-bb4e-455f-9b40-000000000015",
+			},
+		},
 		{
 			InputModelQuery: deployments.Query{
 				// whatever name
@@ -1419,8 +1496,7 @@ func TestDeploymentStorageFindBy(t *testing.T) {
 				testCase.InputModelQuery)
 
 			if testCase.OutputError != nil {
-				assert.EqualError(t, err,
-					testCase.OutputError.Error())
+				assertError(t, err, testCase.OutputError)
 			} else {
 				assert.NoError(t, err)
 				assert.Len(t, deps, len(testCase.OutputID))
@@ -1534,7 +1610,7 @@ func TestDeploymentFinish(t *testing.T) {
 			err := store.Finish(ctx, tc.InputID, now)
 
 			if tc.OutputError != nil {
-				assert.EqualError(t, err, tc.OutputError.Error())
+				assertError(t, err, tc.OutputError)
 			} else {
 				var deployment *deployments.Deployment
 				err := session.DB(ctxstore.DbFromContext(ctx, DatabaseName)).
@@ -1554,7 +1630,7 @@ func TestDeploymentFinish(t *testing.T) {
 				// deployment was added to tenant's DB, so this
 				// should fail with default DB
 				err := store.Finish(context.Background(), tc.InputID, now)
-				assert.EqualError(t, err, ErrStorageInvalidID.Error())
+				assertError(t, err, ErrStorageInvalidID)
 			}
 			// Need to close all sessions to be able to call wipe at next test case
 			session.Close()
@@ -1562,6 +1638,89 @@ func TestDeploymentFinish(t *testing.T) {
 	}
 }
 
+func TestDeploymentSetPaused(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping TestDeploymentSetPaused in short mode.")
+	}
+
+	db.Wipe()
+
+	session := db.Session()
+	defer session.Close()
+	store := NewDeploymentsStorage(session)
+
+	ctx := context.Background()
+
+	now := time.Now()
+	active := &deployments.Deployment{
+		Id: StringToPointer("a108ae14-bb4e-455f-9b40-2ef4bab97bb7"),
+	}
+	finished := &deployments.Deployment{
+		Id:       StringToPointer("b108ae14-bb4e-455f-9b40-2ef4bab97bb7"),
+		Finished: &now,
+	}
+	dep := session.DB(DatabaseName).C(CollectionDeployments)
+	assert.NoError(t, dep.Insert(active, finished))
+
+	ids := []string{*active.Id, *finished.Id}
+	paused := func(id string) bool {
+		var deployment *deployments.Deployment
+		assert.NoError(t, dep.FindId(id).One(&deployment))
+		return deployment.Paused
+	}
+
+	assert.NoError(t, store.SetPaused(ctx, nil, true))
+
+	// finished deployments are not paused
+	assert.NoError(t, store.SetPaused(ctx, ids, true))
+	assert.True(t, paused(*active.Id))
+	assert.False(t, paused(*finished.Id))
+
+	assert.NoError(t, store.SetPaused(ctx, ids, false))
+	assert.False(t, paused(*active.Id))
+}
+
+func TestDeploymentStorageFindOverQuota(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping TestDeploymentStorageFindOverQuota in short mode.")
+	}
+
+	db.Wipe()
+
+	session := db.Session()
+	defer session.Close()
+	store := NewDeploymentsStorage(session)
+
+	ctx := context.Background()
+
+	quota := &deployments.QuotaExceeded{
+		Limit:    "devices_per_deployment",
+		Value:    10,
+		Usage:    12,
+		Decision: "warn",
+	}
+	overQuota := &deployments.Deployment{
+		Id:        StringToPointer("a108ae14-bb4e-455f-9b40-2ef4bab97bb7"),
+		OverQuota: quota,
+	}
+	withinQuota := &deployments.Deployment{
+		Id: StringToPointer("b108ae14-bb4e-455f-9b40-2ef4bab97bb7"),
+	}
+	dep := session.DB(DatabaseName).C(CollectionDeployments)
+	assert.NoError(t, dep.Insert(overQuota, withinQuota))
+
+	found, err := store.Find(ctx, deployments.Query{OverQuota: true})
+	assert.NoError(t, err)
+	if assert.Len(t, found, 1) {
+		assert.Equal(t, *overQuota.Id, *found[0].Id)
+		assert.Equal(t, quota, found[0].OverQuota)
+	}
+
+	found, err = store.Find(ctx, deployments.Query{})
+	assert.NoError(t, err)
+	assert.Len(t, found, 2)
+}
+
 func TestDeploymentFiltering(t *testing.T) {
 	testCases := []struct {
 		InputDeployment []*deployments.Deployment
@@ -1762,3 +1921,44 @@ func TestDeviceDeploymentCounting(t *testing.T) {
 		})
 	}
 }
+
+func TestDeploymentStatsRollups(t *testing.T) {
+
+	if testing.Short() {
+		t.Skip("skipping TestDeploymentStatsRollups in short mode.")
+	}
+
+	db.Wipe()
+	session := db.Session()
+	defer session.Close()
+
+	store := NewDeploymentsStorage(session)
+	ctx := context.Background()
+
+	now := time.Now()
+	updates := []struct {
+		when   time.Time
+		status string
+	}{
+		{now, deployments.DeviceDeploymentStatusSuccess},
+		{now, deployments.DeviceDeploymentStatusSuccess},
+		{now, deployments.DeviceDeploymentStatusFailure},
+		{now.Add(-48 * time.Hour), deployments.DeviceDeploymentStatusSuccess},
+		{now.Add(-10 * 24 * time.Hour), deployments.DeviceDeploymentStatusFailure},
+	}
+	for _, u := range updates {
+		assert.NoError(t, store.IncrementStatsRollup(ctx, u.when, u.status))
+	}
+
+	assertError(t, store.IncrementStatsRollup(ctx, now, ""), ErrStorageInvalidInput)
+
+	stats, err := store.AggregateStatsRollups(ctx, now.Add(-24*time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, 2, stats[deployments.DeviceDeploymentStatusSuccess])
+	assert.Equal(t, 1, stats[deployments.DeviceDeploymentStatusFailure])
+
+	stats, err = store.AggregateStatsRollups(ctx, now.Add(-7*24*time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, 3, stats[deployments.DeviceDeploymentStatusSuccess])
+	assert.Equal(t, 1, stats[deployments.DeviceDeploymentStatusFailure])
+}
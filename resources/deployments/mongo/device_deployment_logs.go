@@ -16,6 +16,7 @@ package mongo
 
 import (
 	"context"
+	"strings"
 
 	"github.com/globalsign/mgo"
 	"github.com/globalsign/mgo/bson"
@@ -32,7 +33,13 @@ const (
 
 // Database keys
 const (
-	StorageKeyDeviceDeploymentLogMessages = "messages"
+	StorageKeyDeviceDeploymentLogMessages    = "messages"
+	StorageKeyDeviceDeploymentLogMessageText = "messages.message"
+)
+
+// Indexes
+const (
+	IndexDeviceDeploymentLogsTextStr = "messages_text"
 )
 
 // DeviceDeploymentLogsStorage is a data layer for deployment logs based on MongoDB
@@ -98,3 +105,73 @@ func (d *DeviceDeploymentLogsStorage) GetDeviceDeploymentLog(ctx context.Context
 
 	return &depl, nil
 }
+
+// DeleteDeviceDeploymentLogs removes deployment logs of the device.
+// Returns number of removed logs.
+func (d *DeviceDeploymentLogsStorage) DeleteDeviceDeploymentLogs(ctx context.Context,
+	deviceID string) (int, error) {
+
+	session := d.session.Copy()
+	defer session.Close()
+
+	info, err := session.DB(store.DbFromContext(ctx, DatabaseName)).
+		C(CollectionDeviceDeploymentLogs).RemoveAll(bson.M{
+		StorageKeyDeviceDeploymentDeviceId: deviceID,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return info.Removed, nil
+}
+
+// EnsureSearchIndex creates text index of log messages needed
+// by SearchDeviceDeploymentLogs.
+func (d *DeviceDeploymentLogsStorage) EnsureSearchIndex(ctx context.Context) error {
+
+	session := d.session.Copy()
+	defer session.Close()
+
+	textIndex := mgo.Index{
+		Key:        []string{"$text:" + StorageKeyDeviceDeploymentLogMessageText},
+		Name:       IndexDeviceDeploymentLogsTextStr,
+		Background: true,
+	}
+
+	return session.DB(store.DbFromContext(ctx, DatabaseName)).
+		C(CollectionDeviceDeploymentLogs).
+		EnsureIndex(textIndex)
+}
+
+// SearchDeviceDeploymentLogs returns IDs of devices whose logs of the given
+// deployment contain the text as a phrase. Requires the search index.
+func (d *DeviceDeploymentLogsStorage) SearchDeviceDeploymentLogs(ctx context.Context,
+	deploymentID, text string) ([]string, error) {
+
+	session := d.session.Copy()
+	defer session.Close()
+
+	// quotes make mongo match the whole phrase instead of any of the words
+	phrase := `"` + strings.Replace(text, `"`, " ", -1) + `"`
+
+	query := bson.M{
+		StorageKeyDeviceDeploymentDeploymentID: deploymentID,
+		"$text":                                bson.M{"$search": phrase},
+	}
+
+	var logs []deployments.DeploymentLog
+	if err := session.DB(store.DbFromContext(ctx, DatabaseName)).
+		C(CollectionDeviceDeploymentLogs).Find(query).
+		Select(bson.M{StorageKeyDeviceDeploymentDeviceId: 1}).
+		Sort(StorageKeyDeviceDeploymentDeviceId).
+		All(&logs); err != nil {
+		return nil, err
+	}
+
+	devices := make([]string, 0, len(logs))
+	for _, l := range logs {
+		devices = append(devices, l.DeviceID)
+	}
+
+	return devices, nil
+}
@@ -294,3 +294,115 @@ func TestGetDeviceDeploymentLog(t *testing.T) {
 
 	db.Wipe()
 }
+
+func TestSearchDeviceDeploymentLogs(t *testing.T) {
+
+	if testing.Short() {
+		t.Skip("skipping TestSearchDeviceDeploymentLogs in short mode.")
+	}
+
+	// Make sure we start test with empty database
+	db.Wipe()
+
+	session := db.Session()
+	store := NewDeviceDeploymentLogsStorage(session)
+
+	ctx := context.Background()
+	deploymentID := "30b3e62c-9ec2-4312-a7fa-cff24cc7397a"
+
+	logs := map[string]string{
+		"device-1": "download failed: connection refused by server",
+		"device-2": "installation failed: no space left on device",
+		"device-3": "connection refused",
+	}
+	for device, message := range logs {
+		assert.NoError(t, store.SaveDeviceDeploymentLog(ctx, deployments.DeploymentLog{
+			DeviceID:     device,
+			DeploymentID: deploymentID,
+			Messages: []deployments.LogMessage{
+				{
+					Level:     "error",
+					Message:   message,
+					Timestamp: parseTime(t, "2006-01-02T15:04:05-07:00"),
+				},
+			},
+		}))
+	}
+	// same content, different deployment
+	assert.NoError(t, store.SaveDeviceDeploymentLog(ctx, deployments.DeploymentLog{
+		DeviceID:     "device-4",
+		DeploymentID: "30b3e62c-9ec2-4312-a7fa-cff24cc7397b",
+		Messages: []deployments.LogMessage{
+			{
+				Level:     "error",
+				Message:   "connection refused",
+				Timestamp: parseTime(t, "2006-01-02T15:04:05-07:00"),
+			},
+		},
+	}))
+
+	assert.NoError(t, store.EnsureSearchIndex(ctx))
+
+	devices, err := store.SearchDeviceDeploymentLogs(ctx, deploymentID, "connection refused")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"device-1", "device-3"}, devices)
+
+	devices, err = store.SearchDeviceDeploymentLogs(ctx, deploymentID, "disk full")
+	assert.NoError(t, err)
+	assert.Len(t, devices, 0)
+}
+
+func TestDeleteDeviceDeploymentLogs(t *testing.T) {
+
+	if testing.Short() {
+		t.Skip("skipping TestDeleteDeviceDeploymentLogs in short mode.")
+	}
+
+	// Make sure we start test with empty database
+	db.Wipe()
+
+	session := db.Session()
+	defer session.Close()
+	store := NewDeviceDeploymentLogsStorage(session)
+
+	ctx := context.Background()
+
+	messages := []deployments.LogMessage{
+		{
+			Level:     "error",
+			Message:   "foo",
+			Timestamp: parseTime(t, "2006-01-02T15:04:05-07:00"),
+		},
+	}
+	for _, log := range []deployments.DeploymentLog{
+		{
+			DeviceID:     "foo",
+			DeploymentID: "30b3e62c-9ec2-4312-a7fa-cff24cc7397a",
+			Messages:     messages,
+		},
+		{
+			DeviceID:     "foo",
+			DeploymentID: "40b3e62c-9ec2-4312-a7fa-cff24cc7397a",
+			Messages:     messages,
+		},
+		{
+			DeviceID:     "bar",
+			DeploymentID: "30b3e62c-9ec2-4312-a7fa-cff24cc7397a",
+			Messages:     messages,
+		},
+	} {
+		assert.NoError(t, store.SaveDeviceDeploymentLog(ctx, log))
+	}
+
+	count, err := store.DeleteDeviceDeploymentLogs(ctx, "foo")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, count)
+
+	log, err := store.GetDeviceDeploymentLog(ctx, "foo", "30b3e62c-9ec2-4312-a7fa-cff24cc7397a")
+	assert.NoError(t, err)
+	assert.Nil(t, log)
+
+	log, err = store.GetDeviceDeploymentLog(ctx, "bar", "30b3e62c-9ec2-4312-a7fa-cff24cc7397a")
+	assert.NoError(t, err)
+	assert.NotNil(t, log)
+}
@@ -0,0 +1,326 @@
+// Copyright 2018 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package mongo
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+	"github.com/mendersoftware/go-lib-micro/store"
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/deployments/resources/deployments"
+)
+
+// Finished device deployments are moved out of the devices collection
+// to monthly partitions, by the month they finished in, e.g. devices_201903.
+// The partitions of each deployment are recorded in the device partitions
+// collection, so that device deployments of a deployment are looked up
+// only in the partitions holding them. Lookups by device, which are rare
+// for finished device deployments, go through all partitions.
+const (
+	CollectionDevicePartitions       = "device_partitions"
+	CollectionDevicesPartitionPrefix = CollectionDevices + "_"
+
+	devicesPartitionLayout = "200601"
+
+	// number of device deployments moved at once
+	partitionBatchSize = 1000
+)
+
+// Database keys
+const (
+	StorageKeyDevicePartitionsPartitions = "partitions"
+)
+
+// DevicesPartition returns name of the partition holding device
+// deployments finished in the month of the given time.
+func DevicesPartition(finished time.Time) string {
+	return CollectionDevicesPartitionPrefix + finished.UTC().Format(devicesPartitionLayout)
+}
+
+// partitionEnd returns time all device deployments in the partition
+// finished, and were created, before.
+func partitionEnd(name string) (time.Time, bool) {
+	month, err := time.Parse(devicesPartitionLayout,
+		strings.TrimPrefix(name, CollectionDevicesPartitionPrefix))
+	if err != nil || !strings.HasPrefix(name, CollectionDevicesPartitionPrefix) {
+		return time.Time{}, false
+	}
+	return month.AddDate(0, 1, 0), true
+}
+
+// includesFinished checks if device deployments in any of the statuses
+// may be in partitions; any status matches if none is given.
+func includesFinished(statuses ...string) bool {
+	if len(statuses) == 0 {
+		return true
+	}
+	for _, status := range statuses {
+		if status == "" || deployments.IsDeviceDeploymentStatusFinished(status) {
+			return true
+		}
+	}
+	return false
+}
+
+// devicesPartitions lists all partitions of the database, newest first.
+func devicesPartitions(db *mgo.Database) ([]string, error) {
+	names, err := db.CollectionNames()
+	if err != nil {
+		return nil, errors.Wrap(err, "listing device deployment partitions")
+	}
+
+	var partitions []string
+	for _, name := range names {
+		if _, ok := partitionEnd(name); ok {
+			partitions = append(partitions, name)
+		}
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(partitions)))
+	return partitions, nil
+}
+
+// deploymentPartitions lists partitions holding device deployments
+// of the deployments, newest first.
+func deploymentPartitions(db *mgo.Database, deploymentIDs ...string) ([]string, error) {
+	var entries []struct {
+		Partitions []string `bson:"partitions"`
+	}
+	err := db.C(CollectionDevicePartitions).
+		Find(bson.M{"_id": bson.M{"$in": deploymentIDs}}).All(&entries)
+	if err != nil {
+		return nil, errors.Wrap(err, "looking up device deployment partitions")
+	}
+
+	set := make(map[string]bool)
+	var partitions []string
+	for _, entry := range entries {
+		for _, name := range entry.Partitions {
+			if !set[name] {
+				set[name] = true
+				partitions = append(partitions, name)
+			}
+		}
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(partitions)))
+	return partitions, nil
+}
+
+// devicesCollections returns the devices collection followed by
+// the partitions.
+func devicesCollections(partitions []string) []string {
+	return append([]string{CollectionDevices}, partitions...)
+}
+
+// deploymentCollections lists collections holding device deployments
+// of the deployment, active first.
+func deploymentCollections(db *mgo.Database, deploymentID string) ([]string, error) {
+	partitions, err := deploymentPartitions(db, deploymentID)
+	if err != nil {
+		return nil, err
+	}
+	return devicesCollections(partitions), nil
+}
+
+// statusCollections lists collections holding device deployments in one
+// of the statuses, active first; partitions are listed only if any of
+// the statuses is a finished one.
+func statusCollections(db *mgo.Database, statuses ...string) ([]string, error) {
+	if !includesFinished(statuses...) {
+		return []string{CollectionDevices}, nil
+	}
+	partitions, err := devicesPartitions(db)
+	if err != nil {
+		return nil, err
+	}
+	return devicesCollections(partitions), nil
+}
+
+// PartitionDeviceDeployments moves device deployments which finished
+// before the given time to the monthly partitions. Device deployments
+// finished without the finish time recorded, e.g. aborted ones, are moved
+// by the time they were created. Device deployments are first written to
+// the partition and then removed, so running again completes a move which
+// was interrupted. Device deployments updated while being moved, e.g.
+// retried, stay in the devices collection. Returns number of moved device
+// deployments.
+func (d *DeviceDeploymentsStorage) PartitionDeviceDeployments(ctx context.Context,
+	finishedBefore time.Time) (int, error) {
+
+	session := d.session.Copy()
+	defer session.Close()
+
+	db := session.DB(store.DbFromContext(ctx, DatabaseName))
+	selector := partitionSelector(finishedBefore)
+
+	moved := 0
+	for {
+		var batch []bson.M
+		err := db.C(CollectionDevices).Find(selector).
+			Limit(partitionBatchSize).All(&batch)
+		if err != nil {
+			return moved, errors.Wrap(err, "searching for finished device deployments")
+		}
+		if len(batch) == 0 {
+			return moved, nil
+		}
+
+		n, err := d.movePartitions(db, selector, batch)
+		if err != nil {
+			return moved, err
+		}
+		moved += n
+	}
+}
+
+// partitionSelector selects device deployments finished before the time.
+func partitionSelector(finishedBefore time.Time) bson.M {
+	return bson.M{
+		StorageKeyDeviceDeploymentStatus: bson.M{
+			"$nin": deployments.ActiveDeploymentStatuses(),
+		},
+		"$or": []bson.M{
+			{StorageKeyDeviceDeploymentFinished: bson.M{"$lt": finishedBefore}},
+			{
+				StorageKeyDeviceDeploymentFinished: nil,
+				StorageKeyDeviceDeploymentCreated:  bson.M{"$lt": finishedBefore},
+			},
+		},
+	}
+}
+
+// movePartitions copies the batch read with the selector to partitions and
+// removes from the devices collection only device deployments still
+// matching the selector, with status and finish time as read. Copies of
+// the others are removed from partitions, they are moved again later if
+// still finished. Returns number of moved device deployments.
+func (d *DeviceDeploymentsStorage) movePartitions(db *mgo.Database,
+	selector bson.M, batch []bson.M) (int, error) {
+
+	partitions := make(map[string][]bson.M)
+	partitionOf := make(map[interface{}]string, len(batch))
+	for _, doc := range batch {
+		finished, ok := doc[StorageKeyDeviceDeploymentFinished].(time.Time)
+		if !ok {
+			finished, _ = doc[StorageKeyDeviceDeploymentCreated].(time.Time)
+		}
+		name := DevicesPartition(finished)
+		partitions[name] = append(partitions[name], doc)
+		partitionOf[doc["_id"]] = name
+	}
+
+	ids := make([]interface{}, 0, len(batch))
+	unchanged := make([]bson.M, 0, len(batch))
+	for name, docs := range partitions {
+		if err := ensurePartitionIndexing(db.C(name)); err != nil {
+			return 0, err
+		}
+
+		// record the partition before it gets any device deployment of
+		// the deployment, lookups must not miss any
+		recorded := make(map[interface{}]bool)
+		for _, doc := range docs {
+			deploymentID := doc[StorageKeyDeviceDeploymentDeploymentID]
+			if recorded[deploymentID] {
+				continue
+			}
+			recorded[deploymentID] = true
+
+			_, err := db.C(CollectionDevicePartitions).UpsertId(deploymentID,
+				bson.M{"$addToSet": bson.M{StorageKeyDevicePartitionsPartitions: name}})
+			if err != nil {
+				return 0, errors.Wrap(err, "recording device deployment partition")
+			}
+		}
+
+		bulk := db.C(name).Bulk()
+		bulk.Unordered()
+		for _, doc := range docs {
+			bulk.Upsert(bson.M{"_id": doc["_id"]}, doc)
+			ids = append(ids, doc["_id"])
+			unchanged = append(unchanged, bson.M{
+				"_id":                              doc["_id"],
+				StorageKeyDeviceDeploymentStatus:   doc[StorageKeyDeviceDeploymentStatus],
+				StorageKeyDeviceDeploymentFinished: doc[StorageKeyDeviceDeploymentFinished],
+			})
+		}
+		if _, err := bulk.Run(); err != nil {
+			return 0, errors.Wrapf(err, "writing device deployments to %s", name)
+		}
+	}
+
+	info, err := db.C(CollectionDevices).RemoveAll(bson.M{
+		"$and": []bson.M{selector, {"$or": unchanged}},
+	})
+	if err != nil {
+		return 0, errors.Wrap(err, "removing partitioned device deployments")
+	}
+	if info.Removed == len(ids) {
+		return info.Removed, nil
+	}
+
+	// device deployments updated since read are left in the devices
+	// collection, drop their stale copies
+	var changed []struct {
+		ID interface{} `bson:"_id"`
+	}
+	err = db.C(CollectionDevices).Find(bson.M{"_id": bson.M{"$in": ids}}).
+		Select(bson.M{"_id": 1}).All(&changed)
+	if err != nil {
+		return 0, errors.Wrap(err, "searching for updated device deployments")
+	}
+	for _, doc := range changed {
+		err := db.C(partitionOf[doc.ID]).RemoveId(doc.ID)
+		if err != nil && err != mgo.ErrNotFound {
+			return 0, errors.Wrap(err, "removing stale partitioned device deployment")
+		}
+	}
+	return info.Removed, nil
+}
+
+// Partitions are looked up by deployment and status for statistics
+// and by device.
+func ensurePartitionIndexing(c *mgo.Collection) error {
+	indexes := []mgo.Index{
+		{
+			Key: []string{
+				StorageKeyDeviceDeploymentDeploymentID,
+				StorageKeyDeviceDeploymentStatus,
+			},
+			Name:       IndexDeviceDeploymentStatusStr,
+			Background: true,
+		},
+		{
+			Key: []string{
+				StorageKeyDeviceDeploymentDeviceId,
+				StorageKeyDeviceDeploymentCreated,
+			},
+			Name:       IndexDeviceDeploymentDeviceCreatedStr,
+			Background: true,
+		},
+	}
+
+	for _, index := range indexes {
+		if err := c.EnsureIndex(index); err != nil {
+			return errors.Wrapf(err, "indexing %s", c.Name)
+		}
+	}
+	return nil
+}
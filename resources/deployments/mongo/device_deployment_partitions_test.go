@@ -0,0 +1,226 @@
+// Copyright 2018 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package mongo_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/globalsign/mgo/bson"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mendersoftware/deployments/resources/deployments"
+	. "github.com/mendersoftware/deployments/resources/deployments/mongo"
+	"github.com/mendersoftware/deployments/utils/pointers"
+)
+
+func TestPartitionDeviceDeployments(t *testing.T) {
+
+	if testing.Short() {
+		t.Skip("skipping TestPartitionDeviceDeployments in short mode.")
+	}
+
+	db.Wipe()
+	session := db.Session()
+	defer session.Close()
+
+	store := NewDeviceDeploymentsStorage(session)
+	ctx := context.Background()
+
+	deploymentA := "30b3e62c-9ec2-4312-a7fa-cff24cc7397a"
+	deploymentB := "30b3e62c-9ec2-4312-a7fa-cff24cc7397b"
+
+	newDeviceDeployment := func(deviceID, deploymentID, status string,
+		created time.Time, finished *time.Time) *deployments.DeviceDeployment {
+
+		dd := deployments.NewDeviceDeployment(deviceID, deploymentID)
+		dd.Status = pointers.StringToPointer(status)
+		dd.Created = &created
+		dd.Finished = finished
+		return dd
+	}
+	date := func(month time.Month, day int) time.Time {
+		return time.Date(2019, month, day, 0, 0, 0, 0, time.UTC)
+	}
+	datePtr := func(month time.Month, day int) *time.Time {
+		d := date(month, day)
+		return &d
+	}
+
+	err := store.InsertMany(ctx,
+		newDeviceDeployment("001", deploymentA, deployments.DeviceDeploymentStatusSuccess,
+			date(time.January, 1), datePtr(time.January, 10)),
+		newDeviceDeployment("002", deploymentA, deployments.DeviceDeploymentStatusFailure,
+			date(time.January, 1), datePtr(time.February, 5)),
+		// finish time not recorded, moved by creation time
+		newDeviceDeployment("004", deploymentA, deployments.DeviceDeploymentStatusAborted,
+			date(time.January, 2), nil),
+		newDeviceDeployment("003", deploymentA, deployments.DeviceDeploymentStatusPending,
+			date(time.January, 1), nil),
+		newDeviceDeployment("001", deploymentB, deployments.DeviceDeploymentStatusSuccess,
+			date(time.February, 20), datePtr(time.March, 10)),
+	)
+	assert.NoError(t, err)
+
+	moved, err := store.PartitionDeviceDeployments(ctx, date(time.March, 1))
+	assert.NoError(t, err)
+	assert.Equal(t, 3, moved)
+
+	names, err := session.DB(DatabaseName).CollectionNames()
+	assert.NoError(t, err)
+	assert.Contains(t, names, DevicesPartition(date(time.January, 10)))
+	assert.Contains(t, names, DevicesPartition(date(time.February, 5)))
+	assert.Equal(t, "devices_201901", DevicesPartition(date(time.January, 10)))
+
+	active, err := session.DB(DatabaseName).C(CollectionDevices).Count()
+	assert.NoError(t, err)
+	assert.Equal(t, 2, active)
+
+	// moving again finds nothing
+	moved, err = store.PartitionDeviceDeployments(ctx, date(time.March, 1))
+	assert.NoError(t, err)
+	assert.Equal(t, 0, moved)
+
+	stats, err := store.AggregateDeviceDeploymentByStatus(ctx, deploymentA)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, stats[deployments.DeviceDeploymentStatusSuccess])
+	assert.Equal(t, 1, stats[deployments.DeviceDeploymentStatusFailure])
+	assert.Equal(t, 1, stats[deployments.DeviceDeploymentStatusAborted])
+	assert.Equal(t, 1, stats[deployments.DeviceDeploymentStatusPending])
+
+	count, err := store.CountDeviceDeployments(ctx, deploymentA, "")
+	assert.NoError(t, err)
+	assert.Equal(t, 4, count)
+
+	count, err = store.CountDeviceDeployments(ctx, deploymentA,
+		deployments.DeviceDeploymentStatusPending)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, count)
+
+	statuses, err := store.GetDeviceStatusesForDeployment(ctx, deploymentA)
+	assert.NoError(t, err)
+	assert.Len(t, statuses, 4)
+
+	status, err := store.GetDeviceDeploymentStatus(ctx, deploymentA, "002")
+	assert.NoError(t, err)
+	assert.Equal(t, deployments.DeviceDeploymentStatusFailure, status)
+
+	latest, err := store.FindLatestDeploymentForDeviceID(ctx, "001")
+	assert.NoError(t, err)
+	if assert.NotNil(t, latest) {
+		assert.Equal(t, deploymentB, *latest.DeploymentId)
+	}
+
+	latest, err = store.FindLatestDeploymentForDeviceID(ctx, "001", deploymentA)
+	assert.NoError(t, err)
+	if assert.NotNil(t, latest) {
+		assert.Equal(t, deploymentA, *latest.DeploymentId)
+	}
+
+	list, err := store.FindDeviceDeployments(ctx, deployments.DeviceDeploymentsQuery{
+		Skip:  1,
+		Limit: 2,
+	})
+	assert.NoError(t, err)
+	if assert.Len(t, list, 2) {
+		assert.Equal(t, "004", *list[0].DeviceId)
+		assert.Equal(t, date(time.January, 1), list[1].Created.UTC())
+	}
+
+	anonymized, err := store.AnonymizeDeviceDeployments(ctx, "001", "anonymous")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, anonymized)
+}
+
+func TestPartitionDeviceDeploymentsUpdated(t *testing.T) {
+
+	if testing.Short() {
+		t.Skip("skipping TestPartitionDeviceDeploymentsUpdated in short mode.")
+	}
+
+	db.Wipe()
+	session := db.Session()
+	defer session.Close()
+
+	store := NewDeviceDeploymentsStorage(session)
+	ctx := context.Background()
+	devicesDB := session.DB(DatabaseName)
+
+	deploymentID := "30b3e62c-9ec2-4312-a7fa-cff24cc7397a"
+	created := time.Date(2019, time.January, 1, 0, 0, 0, 0, time.UTC)
+	finished := time.Date(2019, time.January, 10, 0, 0, 0, 0, time.UTC)
+
+	var dds []*deployments.DeviceDeployment
+	for _, deviceID := range []string{"001", "002", "003"} {
+		dd := deployments.NewDeviceDeployment(deviceID, deploymentID)
+		dd.Status = pointers.StringToPointer(deployments.DeviceDeploymentStatusFailure)
+		dd.Created = &created
+		dd.Finished = &finished
+		dds = append(dds, dd)
+	}
+	assert.NoError(t, store.InsertMany(ctx, dds...))
+
+	selectorTime := time.Date(2019, time.March, 1, 0, 0, 0, 0, time.UTC)
+	selector := PartitionSelector(selectorTime)
+	var batch []bson.M
+	assert.NoError(t, devicesDB.C(CollectionDevices).Find(selector).All(&batch))
+	assert.Len(t, batch, 3)
+
+	// retried back to pending, and finished again later, after being read
+	assert.NoError(t, devicesDB.C(CollectionDevices).UpdateId(dds[0].Id, bson.M{
+		"$set":   bson.M{StorageKeyDeviceDeploymentStatus: deployments.DeviceDeploymentStatusPending},
+		"$unset": bson.M{StorageKeyDeviceDeploymentFinished: 1},
+	}))
+	finishedAgain := time.Date(2019, time.February, 2, 0, 0, 0, 0, time.UTC)
+	assert.NoError(t, devicesDB.C(CollectionDevices).UpdateId(dds[1].Id, bson.M{
+		"$set": bson.M{
+			StorageKeyDeviceDeploymentStatus:   deployments.DeviceDeploymentStatusSuccess,
+			StorageKeyDeviceDeploymentFinished: finishedAgain,
+		},
+	}))
+
+	moved, err := MovePartitions(store, devicesDB, selector, batch)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, moved)
+
+	partition := devicesDB.C(DevicesPartition(finished))
+	n, err := partition.Count()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+	n, err = partition.FindId(dds[2].Id).Count()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+
+	var pending deployments.DeviceDeployment
+	assert.NoError(t, devicesDB.C(CollectionDevices).FindId(dds[0].Id).One(&pending))
+	assert.Equal(t, deployments.DeviceDeploymentStatusPending, *pending.Status)
+
+	// the one finished again is moved by the next run, by the new finish time
+	moved, err = store.PartitionDeviceDeployments(ctx, selectorTime)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, moved)
+
+	status, err := store.GetDeviceDeploymentStatus(ctx, deploymentID, "002")
+	assert.NoError(t, err)
+	assert.Equal(t, deployments.DeviceDeploymentStatusSuccess, status)
+	n, err = devicesDB.C(DevicesPartition(finishedAgain)).FindId(dds[1].Id).Count()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+
+	n, err = devicesDB.C(CollectionDevices).Count()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+}
@@ -16,6 +16,8 @@ package mongo
 
 import (
 	"context"
+	"sort"
+	"time"
 
 	"github.com/asaskevich/govalidator"
 	"github.com/globalsign/mgo"
@@ -40,10 +42,32 @@ const (
 	StorageKeyDeviceDeploymentDeviceId        = "deviceid"
 	StorageKeyDeviceDeploymentStatus          = "status"
 	StorageKeyDeviceDeploymentSubState        = "substate"
+	StorageKeyDeviceDeploymentErrorCategory   = "error.category"
+	StorageKeyDeviceDeploymentError           = "error"
+	StorageKeyDeviceDeploymentAbort           = "abort"
+	StorageKeyDeviceDeploymentSupersededBy    = "superseded_by"
 	StorageKeyDeviceDeploymentDeploymentID    = "deploymentid"
 	StorageKeyDeviceDeploymentFinished        = "finished"
 	StorageKeyDeviceDeploymentIsLogAvailable  = "log"
 	StorageKeyDeviceDeploymentArtifact        = "image"
+	StorageKeyDeviceDeploymentCreated         = "created"
+	StorageKeyDeviceDeploymentLinkRevoked     = "link_revoked"
+	StorageKeyDeviceDeploymentAttempts        = "attempts"
+	StorageKeyDeviceDeploymentStatusResets    = "status_resets"
+	StorageKeyDeviceDeploymentStatusReported  = "status_reported"
+	StorageKeyDeviceDeploymentIgnoredReports  = "ignored_reports"
+	StorageKeyDeviceDeploymentRetries         = "retries"
+	StorageKeyDeviceDeploymentInventory       = "inventory"
+	StorageKeyDeviceDeploymentReason          = "reason"
+	StorageKeyDeviceDeploymentStatusUpdate    = "status_update"
+)
+
+// Indexes
+const (
+	IndexDeviceDeploymentStatusStr        = "deploymentIdStatusIndex"
+	IndexDeviceDeploymentStatusCreatedStr = "statusCreatedIndex"
+	IndexDeviceDeploymentDeviceCreatedStr = "deviceIdCreatedIndex"
+	IndexDeviceDeploymentDeviceStatusStr  = "deviceIdStatusIndex"
 )
 
 // Errors
@@ -66,18 +90,19 @@ func NewDeviceDeploymentsStorage(session *mgo.Session) *DeviceDeploymentsStorage
 // InsertMany stores multiple device deployment objects.
 // TODO: Handle error cleanup, multi insert is not atomic, loop into two-phase commits
 func (d *DeviceDeploymentsStorage) InsertMany(ctx context.Context,
-	deployments ...*deployments.DeviceDeployment) error {
+	deviceDeployments ...*deployments.DeviceDeployment) error {
 
-	if len(deployments) == 0 {
+	if len(deviceDeployments) == 0 {
 		return nil
 	}
 
 	// Writing to another interface list addresses golang gatcha interface{} == []interface{}
 	var list []interface{}
-	for _, deployment := range deployments {
+	for _, deployment := range deviceDeployments {
 
 		if deployment == nil {
-			return ErrStorageInvalidDeviceDeployment
+			return deployments.NewStoreError("InsertMany", CollectionDevices,
+				ErrStorageInvalidDeviceDeployment)
 		}
 
 		if err := deployment.Validate(); err != nil {
@@ -90,6 +115,10 @@ func (d *DeviceDeploymentsStorage) InsertMany(ctx context.Context,
 	session := d.session.Copy()
 	defer session.Close()
 
+	if err := d.ensureStatusIndexing(ctx, session); err != nil {
+		return err
+	}
+
 	if err := session.DB(store.DbFromContext(ctx, DatabaseName)).
 		C(CollectionDevices).Insert(list...); err != nil {
 		return err
@@ -98,13 +127,64 @@ func (d *DeviceDeploymentsStorage) InsertMany(ctx context.Context,
 	return nil
 }
 
+// Device deployments of a deployment are counted by status.
+func (d *DeviceDeploymentsStorage) ensureStatusIndexing(ctx context.Context,
+	session *mgo.Session) error {
+
+	statusIndex := mgo.Index{
+		Key: []string{
+			StorageKeyDeviceDeploymentDeploymentID,
+			StorageKeyDeviceDeploymentStatus,
+		},
+		Name:       IndexDeviceDeploymentStatusStr,
+		Background: true,
+	}
+
+	if err := session.DB(store.DbFromContext(ctx, DatabaseName)).
+		C(CollectionDevices).
+		EnsureIndex(statusIndex); err != nil {
+		return err
+	}
+
+	// device deployments across deployments are looked up by status
+	statusCreatedIndex := mgo.Index{
+		Key: []string{
+			StorageKeyDeviceDeploymentStatus,
+			"-" + StorageKeyDeviceDeploymentCreated,
+		},
+		Name:       IndexDeviceDeploymentStatusCreatedStr,
+		Background: true,
+	}
+
+	if err := session.DB(store.DbFromContext(ctx, DatabaseName)).
+		C(CollectionDevices).
+		EnsureIndex(statusCreatedIndex); err != nil {
+		return err
+	}
+
+	// device deployments of many devices are looked up by status at once
+	deviceStatusIndex := mgo.Index{
+		Key: []string{
+			StorageKeyDeviceDeploymentDeviceId,
+			StorageKeyDeviceDeploymentStatus,
+		},
+		Name:       IndexDeviceDeploymentDeviceStatusStr,
+		Background: true,
+	}
+
+	return session.DB(store.DbFromContext(ctx, DatabaseName)).
+		C(CollectionDevices).
+		EnsureIndex(deviceStatusIndex)
+}
+
 // ExistAssignedImageWithIDAndStatuses checks if image is used by deplyment with specified status.
 func (d *DeviceDeploymentsStorage) ExistAssignedImageWithIDAndStatuses(ctx context.Context,
 	imageID string, statuses ...string) (bool, error) {
 
 	// Verify ID formatting
 	if govalidator.IsNull(imageID) {
-		return false, ErrStorageInvalidID
+		return false, deployments.NewStoreError("ExistAssignedImageWithIDAndStatuses", CollectionDevices,
+			ErrStorageInvalidID, imageID)
 	}
 
 	query := bson.M{StorageKeyDeviceDeploymentAssignedImageId: imageID}
@@ -118,17 +198,25 @@ func (d *DeviceDeploymentsStorage) ExistAssignedImageWithIDAndStatuses(ctx conte
 	session := d.session.Copy()
 	defer session.Close()
 
+	db := session.DB(store.DbFromContext(ctx, DatabaseName))
+	collections, err := statusCollections(db, statuses...)
+	if err != nil {
+		return false, err
+	}
+
 	// if found at least one then image in active deployment
-	var tmp interface{}
-	if err := session.DB(store.DbFromContext(ctx, DatabaseName)).
-		C(CollectionDevices).Find(query).One(&tmp); err != nil {
-		if err.Error() == mgo.ErrNotFound.Error() {
-			return false, nil
+	for _, collection := range collections {
+		var tmp interface{}
+		if err := db.C(collection).Find(query).One(&tmp); err != nil {
+			if err.Error() == mgo.ErrNotFound.Error() {
+				continue
+			}
+			return false, err
 		}
-		return false, err
+		return true, nil
 	}
 
-	return true, nil
+	return false, nil
 }
 
 // FindOldestDeploymentForDeviceIDWithStatuses find oldest deployment matching device id and one of specified statuses.
@@ -137,7 +225,8 @@ func (d *DeviceDeploymentsStorage) FindOldestDeploymentForDeviceIDWithStatuses(c
 
 	// Verify ID formatting
 	if govalidator.IsNull(deviceID) {
-		return nil, ErrStorageInvalidID
+		return nil, deployments.NewStoreError("FindOldestDeploymentForDeviceIDWithStatuses", CollectionDevices,
+			ErrStorageInvalidID, deviceID)
 	}
 
 	session := d.session.Copy()
@@ -149,17 +238,86 @@ func (d *DeviceDeploymentsStorage) FindOldestDeploymentForDeviceIDWithStatuses(c
 		StorageKeyDeviceDeploymentStatus:   bson.M{"$in": statuses},
 	}
 
+	db := session.DB(store.DbFromContext(ctx, DatabaseName))
+	collections, err := statusCollections(db, statuses...)
+	if err != nil {
+		return nil, err
+	}
+
 	// Select only the oldest one that have not been finished yet.
-	var deployment *deployments.DeviceDeployment
-	if err := session.DB(store.DbFromContext(ctx, DatabaseName)).
-		C(CollectionDevices).Find(query).Sort("created").One(&deployment); err != nil {
-		if err.Error() == mgo.ErrNotFound.Error() {
-			return nil, nil
+	var oldest *deployments.DeviceDeployment
+	for _, collection := range collections {
+		var deployment *deployments.DeviceDeployment
+		if err := db.C(collection).Find(query).Sort("created").One(&deployment); err != nil {
+			if err.Error() == mgo.ErrNotFound.Error() {
+				continue
+			}
+			return nil, err
+		}
+		if oldest == nil || deployment.Created.Before(*oldest.Created) {
+			oldest = deployment
 		}
+	}
+
+	return oldest, nil
+}
+
+// FindLatestDeploymentForDeviceID finds the most recently created deployment
+// of the device, optionally limited to the given deployments.
+func (d *DeviceDeploymentsStorage) FindLatestDeploymentForDeviceID(ctx context.Context,
+	deviceID string, deploymentIDs ...string) (*deployments.DeviceDeployment, error) {
+
+	// Verify ID formatting
+	if govalidator.IsNull(deviceID) {
+		return nil, deployments.NewStoreError("FindLatestDeploymentForDeviceID", CollectionDevices,
+			ErrStorageInvalidID, deviceID)
+	}
+
+	session := d.session.Copy()
+	defer session.Close()
+
+	query := bson.M{
+		StorageKeyDeviceDeploymentDeviceId: deviceID,
+	}
+	if len(deploymentIDs) > 0 {
+		query[StorageKeyDeviceDeploymentDeploymentID] = bson.M{"$in": deploymentIDs}
+	}
+
+	db := session.DB(store.DbFromContext(ctx, DatabaseName))
+
+	var partitions []string
+	var err error
+	if len(deploymentIDs) > 0 {
+		partitions, err = deploymentPartitions(db, deploymentIDs...)
+	} else {
+		partitions, err = devicesPartitions(db)
+	}
+	if err != nil {
 		return nil, err
 	}
 
-	return deployment, nil
+	var latest *deployments.DeviceDeployment
+	for _, collection := range devicesCollections(partitions) {
+		// device deployments of this and older partitions were all
+		// created before the one found
+		if end, ok := partitionEnd(collection); ok &&
+			latest != nil && !latest.Created.Before(end) {
+			break
+		}
+
+		var deployment *deployments.DeviceDeployment
+		if err := db.C(collection).Find(query).Sort("-created").One(&deployment); err != nil {
+			if err.Error() == mgo.ErrNotFound.Error() {
+				continue
+			}
+			return nil, err
+		}
+		if latest == nil || deployment.Created.After(*latest.Created) {
+			latest = deployment
+		}
+	}
+
+	return latest, nil
 }
 
 // FindAllDeploymentsForDeviceIDWithStatuses finds all deployments matching device id and one of specified statuses.
@@ -168,7 +326,8 @@ func (d *DeviceDeploymentsStorage) FindAllDeploymentsForDeviceIDWithStatuses(ctx
 
 	// Verify ID formatting
 	if govalidator.IsNull(deviceID) {
-		return nil, ErrStorageInvalidID
+		return nil, deployments.NewStoreError("FindAllDeploymentsForDeviceIDWithStatuses", CollectionDevices,
+			ErrStorageInvalidID, deviceID)
 	}
 
 	session := d.session.Copy()
@@ -182,16 +341,25 @@ func (d *DeviceDeploymentsStorage) FindAllDeploymentsForDeviceIDWithStatuses(ctx
 		},
 	}
 
-	var deployments []deployments.DeviceDeployment
-	if err := session.DB(store.DbFromContext(ctx, DatabaseName)).
-		C(CollectionDevices).Find(query).All(&deployments); err != nil {
-		if err.Error() == mgo.ErrNotFound.Error() {
-			return nil, nil
-		}
+	db := session.DB(store.DbFromContext(ctx, DatabaseName))
+	collections, err := statusCollections(db, statuses...)
+	if err != nil {
 		return nil, err
 	}
 
-	return deployments, nil
+	var all []deployments.DeviceDeployment
+	for _, collection := range collections {
+		var deployments []deployments.DeviceDeployment
+		if err := db.C(collection).Find(query).All(&deployments); err != nil {
+			if err.Error() == mgo.ErrNotFound.Error() {
+				continue
+			}
+			return nil, err
+		}
+		all = append(all, deployments...)
+	}
+
+	return all, nil
 }
 
 func (d *DeviceDeploymentsStorage) UpdateDeviceDeploymentStatus(ctx context.Context,
@@ -200,11 +368,13 @@ func (d *DeviceDeploymentsStorage) UpdateDeviceDeploymentStatus(ctx context.Cont
 	// Verify ID formatting
 	if govalidator.IsNull(deviceID) ||
 		govalidator.IsNull(deploymentID) {
-		return "", ErrStorageInvalidID
+		return "", deployments.NewStoreError("UpdateDeviceDeploymentStatus", CollectionDevices,
+			ErrStorageInvalidID, deviceID, deploymentID)
 	}
 
 	if ok, _ := govalidator.ValidateStruct(ddStatus); !ok {
-		return "", ErrStorageInvalidInput
+		return "", deployments.NewStoreError("UpdateDeviceDeploymentStatus", CollectionDevices,
+			ErrStorageInvalidInput, deviceID, deploymentID)
 	}
 
 	session := d.session.Copy()
@@ -215,7 +385,137 @@ func (d *DeviceDeploymentsStorage) UpdateDeviceDeploymentStatus(ctx context.Cont
 		StorageKeyDeviceDeploymentDeviceId:     deviceID,
 		StorageKeyDeviceDeploymentDeploymentID: deploymentID,
 	}
+	guarded := !deployments.IsDeviceDeploymentStatusFinished(ddStatus.Status)
+	if guarded {
+		query[StorageKeyDeviceDeploymentStatus] = bson.M{
+			"$nin": deployments.FinishedDeploymentStatuses(),
+		}
+	}
+
+	var old deployments.DeviceDeployment
+
+	// update and return the old status in one go
+	change := mgo.Change{
+		Update: buildStatusUpdate(ddStatus),
+	}
+
+	chi, err := session.DB(store.DbFromContext(ctx, DatabaseName)).
+		C(CollectionDevices).Find(query).Apply(change, &old)
+
+	if err != nil {
+		if err == mgo.ErrNotFound {
+			return "", d.statusUpdateNotFound(ctx, guarded, deviceID, deploymentID)
+		}
+		return "", err
+
+	}
+
+	if chi.Updated == 0 {
+		return "", deployments.NewStoreError("UpdateDeviceDeploymentStatus", CollectionDevices,
+			ErrStorageNotFound, deviceID, deploymentID)
+	}
+
+	return *old.Status, nil
+}
+
+// statusUpdateNotFound tells apart missing device deployment and the one
+// guarded against leaving its finished status.
+func (d *DeviceDeploymentsStorage) statusUpdateNotFound(ctx context.Context,
+	guarded bool, deviceID, deploymentID string) error {
+
+	kind := ErrStorageNotFound
+	if guarded {
+		exists, err := d.HasDeploymentForDevice(ctx, deploymentID, deviceID)
+		if err != nil {
+			return err
+		}
+		if exists {
+			kind = ErrStorageFinishedStatus
+		}
+	}
+	return deployments.NewStoreError("UpdateDeviceDeploymentStatus", CollectionDevices,
+		kind, deviceID, deploymentID)
+}
+
+// UpdateDeviceDeploymentStatuses applies the status updates in order with
+// a single bulk write. Updates of device deployments not in the expected
+// status are skipped; the number of applied updates is returned. IDs of
+// the updates are stored with the device deployments, see
+// FindAppliedStatusUpdates.
+func (d *DeviceDeploymentsStorage) UpdateDeviceDeploymentStatuses(ctx context.Context,
+	updates []deployments.DeviceDeploymentStatusUpdate) (int, error) {
+
+	if len(updates) == 0 {
+		return 0, nil
+	}
+
+	session := d.session.Copy()
+	defer session.Close()
+
+	bulk := session.DB(store.DbFromContext(ctx, DatabaseName)).
+		C(CollectionDevices).Bulk()
+	for _, update := range updates {
+		var status interface{} = update.From
+		if !deployments.IsDeviceDeploymentStatusFinished(update.Status.Status) {
+			status = bson.M{
+				"$eq":  update.From,
+				"$nin": deployments.FinishedDeploymentStatuses(),
+			}
+		}
+		change := buildStatusUpdate(update.Status)
+		if update.ID != "" {
+			change["$set"].(bson.M)[StorageKeyDeviceDeploymentStatusUpdate] = update.ID
+		}
+		bulk.Update(bson.M{
+			StorageKeyDeviceDeploymentDeviceId:     update.DeviceID,
+			StorageKeyDeviceDeploymentDeploymentID: update.DeploymentID,
+			StorageKeyDeviceDeploymentStatus:       status,
+		}, change)
+	}
+
+	res, err := bulk.Run()
+	if res == nil {
+		return 0, err
+	}
+	return res.Matched, err
+}
+
+// FindAppliedStatusUpdates returns IDs of the status updates which are the
+// last ones applied to their device deployments.
+func (d *DeviceDeploymentsStorage) FindAppliedStatusUpdates(ctx context.Context,
+	updates []deployments.DeviceDeploymentStatusUpdate) ([]string, error) {
+
+	if len(updates) == 0 {
+		return nil, nil
+	}
+
+	deviceIDs := make([]string, 0, len(updates))
+	ids := make([]string, 0, len(updates))
+	for _, update := range updates {
+		deviceIDs = append(deviceIDs, update.DeviceID)
+		ids = append(ids, update.ID)
+	}
+
+	session := d.session.Copy()
+	defer session.Close()
+
+	query := bson.M{
+		StorageKeyDeviceDeploymentDeviceId:     bson.M{"$in": deviceIDs},
+		StorageKeyDeviceDeploymentStatusUpdate: bson.M{"$in": ids},
+	}
+
+	var applied []string
+	err := session.DB(store.DbFromContext(ctx, DatabaseName)).
+		C(CollectionDevices).Find(query).
+		Distinct(StorageKeyDeviceDeploymentStatusUpdate, &applied)
+	if err != nil {
+		return nil, err
+	}
 
+	return applied, nil
+}
+
+func buildStatusUpdate(ddStatus deployments.DeviceDeploymentStatus) bson.M {
 	// update status field
 	set := bson.M{
 		StorageKeyDeviceDeploymentStatus: ddStatus.Status,
@@ -229,42 +529,127 @@ func (d *DeviceDeploymentsStorage) UpdateDeviceDeploymentStatus(ctx context.Cont
 		set[StorageKeyDeviceDeploymentSubState] = *ddStatus.SubState
 	}
 
-	update := bson.M{
+	if ddStatus.Error != nil {
+		set[StorageKeyDeviceDeploymentError] = ddStatus.Error
+	}
+
+	if ddStatus.ReportTime != nil {
+		set[StorageKeyDeviceDeploymentStatusReported] = ddStatus.ReportTime
+	}
+
+	if ddStatus.Reason != nil {
+		set[StorageKeyDeviceDeploymentReason] = ddStatus.Reason
+	}
+
+	return bson.M{
 		"$set": set,
 	}
+}
 
-	var old deployments.DeviceDeployment
+func (d *DeviceDeploymentsStorage) UpdateDeviceDeploymentLogAvailability(ctx context.Context,
+	deviceID string, deploymentID string, log bool) error {
 
-	// update and return the old status in one go
-	change := mgo.Change{
-		Update: update,
+	// Verify ID formatting
+	if govalidator.IsNull(deviceID) ||
+		govalidator.IsNull(deploymentID) {
+		return deployments.NewStoreError("UpdateDeviceDeploymentLogAvailability", CollectionDevices,
+			ErrStorageInvalidID, deviceID, deploymentID)
 	}
 
-	chi, err := session.DB(store.DbFromContext(ctx, DatabaseName)).
-		C(CollectionDevices).Find(query).Apply(change, &old)
+	session := d.session.Copy()
+	defer session.Close()
 
-	if err != nil {
+	selector := bson.M{
+		StorageKeyDeviceDeploymentDeviceId:     deviceID,
+		StorageKeyDeviceDeploymentDeploymentID: deploymentID,
+	}
+
+	update := bson.M{
+		"$set": bson.M{
+			StorageKeyDeviceDeploymentIsLogAvailable: log,
+		},
+	}
+
+	if err := session.DB(store.DbFromContext(ctx, DatabaseName)).
+		C(CollectionDevices).Update(selector, update); err != nil {
 		if err == mgo.ErrNotFound {
-			return "", ErrStorageNotFound
+			return deployments.NewStoreError("UpdateDeviceDeploymentLogAvailability", CollectionDevices,
+				ErrStorageNotFound, deviceID, deploymentID)
 		}
-		return "", err
+		return err
+	}
+
+	return nil
+}
+
+// IncrementDeviceDeploymentAttempts counts download link issued to the device.
+func (d *DeviceDeploymentsStorage) IncrementDeviceDeploymentAttempts(ctx context.Context,
+	deviceID string, deploymentID string) error {
+	return d.incrementCounter(ctx, "IncrementDeviceDeploymentAttempts",
+		deviceID, deploymentID, StorageKeyDeviceDeploymentAttempts)
+}
 
+// IncrementDeviceDeploymentStatusResets counts device going back to an
+// earlier state of the update.
+func (d *DeviceDeploymentsStorage) IncrementDeviceDeploymentStatusResets(ctx context.Context,
+	deviceID string, deploymentID string) error {
+	return d.incrementCounter(ctx, "IncrementDeviceDeploymentStatusResets",
+		deviceID, deploymentID, StorageKeyDeviceDeploymentStatusResets)
+}
+
+// IncrementDeviceDeploymentIgnoredReports counts status reports of the
+// device ignored because they arrived out of order.
+func (d *DeviceDeploymentsStorage) IncrementDeviceDeploymentIgnoredReports(ctx context.Context,
+	deviceID string, deploymentID string) error {
+	return d.incrementCounter(ctx, "IncrementDeviceDeploymentIgnoredReports",
+		deviceID, deploymentID, StorageKeyDeviceDeploymentIgnoredReports)
+}
+
+// SaveDeviceDeploymentInventory sets the inventory snapshot of the device
+// deployment, the first snapshot is kept.
+func (d *DeviceDeploymentsStorage) SaveDeviceDeploymentInventory(ctx context.Context,
+	deviceID string, deploymentID string, inventory map[string]string) error {
+
+	// Verify ID formatting
+	if govalidator.IsNull(deviceID) ||
+		govalidator.IsNull(deploymentID) {
+		return deployments.NewStoreError("SaveDeviceDeploymentInventory", CollectionDevices,
+			ErrStorageInvalidID, deviceID, deploymentID)
 	}
 
-	if chi.Updated == 0 {
-		return "", ErrStorageNotFound
+	session := d.session.Copy()
+	defer session.Close()
+
+	selector := bson.M{
+		StorageKeyDeviceDeploymentDeviceId:     deviceID,
+		StorageKeyDeviceDeploymentDeploymentID: deploymentID,
+		StorageKeyDeviceDeploymentInventory: bson.M{
+			"$exists": false,
+		},
 	}
 
-	return *old.Status, nil
+	update := bson.M{
+		"$set": bson.M{
+			StorageKeyDeviceDeploymentInventory: inventory,
+		},
+	}
+
+	if err := session.DB(store.DbFromContext(ctx, DatabaseName)).
+		C(CollectionDevices).Update(selector, update); err != nil && err != mgo.ErrNotFound {
+		return err
+	}
+
+	return nil
 }
 
-func (d *DeviceDeploymentsStorage) UpdateDeviceDeploymentLogAvailability(ctx context.Context,
-	deviceID string, deploymentID string, log bool) error {
+func (d *DeviceDeploymentsStorage) incrementCounter(ctx context.Context, op string,
+	deviceID string, deploymentID string, key string) error {
 
 	// Verify ID formatting
 	if govalidator.IsNull(deviceID) ||
 		govalidator.IsNull(deploymentID) {
-		return ErrStorageInvalidID
+		return deployments.NewStoreError(op, CollectionDevices,
+			ErrStorageInvalidID, deviceID, deploymentID)
 	}
 
 	session := d.session.Copy()
@@ -276,15 +661,16 @@ func (d *DeviceDeploymentsStorage) UpdateDeviceDeploymentLogAvailability(ctx con
 	}
 
 	update := bson.M{
-		"$set": bson.M{
-			StorageKeyDeviceDeploymentIsLogAvailable: log,
+		"$inc": bson.M{
+			key: 1,
 		},
 	}
 
 	if err := session.DB(store.DbFromContext(ctx, DatabaseName)).
 		C(CollectionDevices).Update(selector, update); err != nil {
 		if err == mgo.ErrNotFound {
-			return ErrStorageNotFound
+			return deployments.NewStoreError(op, CollectionDevices,
+				ErrStorageNotFound, deviceID, deploymentID)
 		}
 		return err
 	}
@@ -299,7 +685,8 @@ func (d *DeviceDeploymentsStorage) AssignArtifact(ctx context.Context,
 	// Verify ID formatting
 	if govalidator.IsNull(deviceID) ||
 		govalidator.IsNull(deploymentID) {
-		return ErrStorageInvalidID
+		return deployments.NewStoreError("AssignArtifact", CollectionDevices,
+			ErrStorageInvalidID, deviceID, deploymentID)
 	}
 
 	session := d.session.Copy()
@@ -319,7 +706,8 @@ func (d *DeviceDeploymentsStorage) AssignArtifact(ctx context.Context,
 	if err := session.DB(store.DbFromContext(ctx, DatabaseName)).
 		C(CollectionDevices).Update(selector, update); err != nil {
 		if err == mgo.ErrNotFound {
-			return ErrStorageNotFound
+			return deployments.NewStoreError("AssignArtifact", CollectionDevices,
+				ErrStorageNotFound, deviceID, deploymentID)
 		}
 		return err
 	}
@@ -331,7 +719,8 @@ func (d *DeviceDeploymentsStorage) AggregateDeviceDeploymentByStatus(ctx context
 	id string) (deployments.Stats, error) {
 
 	if govalidator.IsNull(id) {
-		return nil, ErrStorageInvalidID
+		return nil, deployments.NewStoreError("AggregateDeviceDeploymentByStatus", CollectionDevices,
+			ErrStorageInvalidID, id)
 	}
 
 	session := d.session.Copy()
@@ -354,71 +743,581 @@ func (d *DeviceDeploymentsStorage) AggregateDeviceDeploymentByStatus(ctx context
 		match,
 		group,
 	}
-	var results []struct {
-		Name  string `bson:"_id"`
-		Count int
-	}
-	err := session.DB(store.DbFromContext(ctx, DatabaseName)).
-		C(CollectionDevices).Pipe(&pipe).All(&results)
+	db := session.DB(store.DbFromContext(ctx, DatabaseName))
+	collections, err := deploymentCollections(db, id)
 	if err != nil {
-		if err.Error() == mgo.ErrNotFound.Error() {
-			return nil, nil
-		}
 		return nil, err
 	}
 
 	raw := deployments.NewDeviceDeploymentStats()
-	for _, res := range results {
-		raw[res.Name] = res.Count
+	for _, collection := range collections {
+		var results []struct {
+			Name  string `bson:"_id"`
+			Count int
+		}
+		err := db.C(collection).Pipe(&pipe).All(&results)
+		if err != nil {
+			if err.Error() == mgo.ErrNotFound.Error() {
+				return nil, nil
+			}
+			return nil, err
+		}
+
+		for _, res := range results {
+			raw[res.Name] += res.Count
+		}
 	}
 	return raw, nil
 }
 
-//GetDeviceStatusesForDeployment retrieve device deployment statuses for a given deployment.
-func (d *DeviceDeploymentsStorage) GetDeviceStatusesForDeployment(ctx context.Context,
-	deploymentID string) ([]deployments.DeviceDeployment, error) {
+// AggregateDeviceDeploymentFailures counts failed device deployments of
+// the deployment by reported error category.
+func (d *DeviceDeploymentsStorage) AggregateDeviceDeploymentFailures(ctx context.Context,
+	id string) (deployments.FailureStats, error) {
+
+	if govalidator.IsNull(id) {
+		return nil, deployments.NewStoreError("AggregateDeviceDeploymentFailures", CollectionDevices,
+			ErrStorageInvalidID, id)
+	}
 
 	session := d.session.Copy()
 	defer session.Close()
 
-	query := bson.M{
-		StorageKeyDeviceDeploymentDeploymentID: deploymentID,
+	match := bson.M{
+		"$match": bson.M{
+			StorageKeyDeviceDeploymentDeploymentID: id,
+			StorageKeyDeviceDeploymentStatus:       deployments.DeviceDeploymentStatusFailure,
+		},
 	}
-
-	var statuses []deployments.DeviceDeployment
-
-	err := session.DB(store.DbFromContext(ctx, DatabaseName)).
-		C(CollectionDevices).Find(query).All(&statuses)
+	group := bson.M{
+		"$group": bson.M{
+			"_id": bson.M{
+				"$ifNull": []interface{}{
+					"$" + StorageKeyDeviceDeploymentErrorCategory,
+					deployments.FailureStatsUncategorized,
+				},
+			},
+			"count": bson.M{
+				"$sum": 1,
+			},
+		},
+	}
+	pipe := []bson.M{
+		match,
+		group,
+	}
+	db := session.DB(store.DbFromContext(ctx, DatabaseName))
+	collections, err := deploymentCollections(db, id)
 	if err != nil {
 		return nil, err
 	}
 
-	return statuses, nil
+	stats := make(deployments.FailureStats)
+	for _, collection := range collections {
+		var results []struct {
+			Name  string `bson:"_id"`
+			Count int
+		}
+		err := db.C(collection).Pipe(&pipe).All(&results)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, res := range results {
+			stats[res.Name] += res.Count
+		}
+	}
+	return stats, nil
 }
 
-// Returns true if deployment of ID `deploymentID` is assigned to device with ID
-// `deviceID`, false otherwise. In case of errors returns false and an error
-// that occurred
-func (d *DeviceDeploymentsStorage) HasDeploymentForDevice(ctx context.Context,
-	deploymentID string, deviceID string) (bool, error) {
+// AggregateDeviceDeploymentDurations counts finished successful and failed
+// device deployments of the deployment by time it took them to finish.
+func (d *DeviceDeploymentsStorage) AggregateDeviceDeploymentDurations(ctx context.Context,
+	id string) (deployments.DurationStats, error) {
 
-	session := d.session.Copy()
-	defer session.Close()
+	if govalidator.IsNull(id) {
+		return nil, deployments.NewStoreError("AggregateDeviceDeploymentDurations", CollectionDevices,
+			ErrStorageInvalidID, id)
+	}
+
+	session := d.session.Copy()
+	defer session.Close()
+
+	// durations are bucketed in milliseconds, negative durations caused by
+	// clock skew are counted as instant
+	boundaries := []int64{0}
+	for _, bound := range deployments.DurationStatsBuckets {
+		boundaries = append(boundaries, int64(bound/time.Millisecond))
+	}
+
+	countStatus := func(status string) bson.M {
+		return bson.M{
+			"$sum": bson.M{
+				"$cond": []interface{}{
+					bson.M{"$eq": []interface{}{"$" + StorageKeyDeviceDeploymentStatus, status}},
+					1,
+					0,
+				},
+			},
+		}
+	}
+
+	match := bson.M{
+		"$match": bson.M{
+			StorageKeyDeviceDeploymentDeploymentID: id,
+			StorageKeyDeviceDeploymentStatus: bson.M{
+				"$in": []string{
+					deployments.DeviceDeploymentStatusSuccess,
+					deployments.DeviceDeploymentStatusFailure,
+				},
+			},
+			StorageKeyDeviceDeploymentFinished: bson.M{"$ne": nil},
+		},
+	}
+	project := bson.M{
+		"$project": bson.M{
+			StorageKeyDeviceDeploymentStatus: 1,
+			"duration": bson.M{
+				"$max": []interface{}{
+					0,
+					bson.M{
+						"$subtract": []string{
+							"$" + StorageKeyDeviceDeploymentFinished,
+							"$" + StorageKeyDeviceDeploymentCreated,
+						},
+					},
+				},
+			},
+		},
+	}
+	bucket := bson.M{
+		"$bucket": bson.M{
+			"groupBy":    "$duration",
+			"boundaries": boundaries,
+			"default":    "longer",
+			"output": bson.M{
+				deployments.DeviceDeploymentStatusSuccess: countStatus(
+					deployments.DeviceDeploymentStatusSuccess),
+				deployments.DeviceDeploymentStatusFailure: countStatus(
+					deployments.DeviceDeploymentStatusFailure),
+			},
+		},
+	}
+	pipe := []bson.M{
+		match,
+		project,
+		bucket,
+	}
+	db := session.DB(store.DbFromContext(ctx, DatabaseName))
+	collections, err := deploymentCollections(db, id)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := deployments.NewDurationStats()
+	for _, collection := range collections {
+		var results []struct {
+			Bound   interface{} `bson:"_id"`
+			Success int         `bson:"success"`
+			Failure int         `bson:"failure"`
+		}
+		err := db.C(collection).Pipe(&pipe).All(&results)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, res := range results {
+			// buckets are identified by their lower bound
+			i := len(deployments.DurationStatsBuckets)
+			for j, bound := range boundaries {
+				if lower, ok := res.Bound.(int64); ok && lower == bound {
+					i = j
+					break
+				}
+			}
+			stats[deployments.DeviceDeploymentStatusSuccess][i].Count += res.Success
+			stats[deployments.DeviceDeploymentStatusFailure][i].Count += res.Failure
+		}
+	}
+	return stats, nil
+}
+
+// AverageDeviceDeploymentDuration computes average time device deployments
+// finished successfully since the given time took, and their count.
+func (d *DeviceDeploymentsStorage) AverageDeviceDeploymentDuration(ctx context.Context,
+	since time.Time) (time.Duration, int, error) {
+
+	session := d.session.Copy()
+	defer session.Close()
+
+	match := bson.M{
+		"$match": bson.M{
+			StorageKeyDeviceDeploymentStatus:   deployments.DeviceDeploymentStatusSuccess,
+			StorageKeyDeviceDeploymentFinished: bson.M{"$gte": since},
+		},
+	}
+	group := bson.M{
+		"$group": bson.M{
+			"_id": nil,
+			"duration": bson.M{
+				"$avg": bson.M{
+					"$max": []interface{}{
+						0,
+						bson.M{
+							"$subtract": []string{
+								"$" + StorageKeyDeviceDeploymentFinished,
+								"$" + StorageKeyDeviceDeploymentCreated,
+							},
+						},
+					},
+				},
+			},
+			"count": bson.M{"$sum": 1},
+		},
+	}
+	pipe := []bson.M{
+		match,
+		group,
+	}
+	db := session.DB(store.DbFromContext(ctx, DatabaseName))
+	partitions, err := devicesPartitions(db)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var total float64
+	count := 0
+	for _, collection := range devicesCollections(partitions) {
+		// partitions are newest first, the rest finished before
+		if end, ok := partitionEnd(collection); ok && !end.After(since) {
+			break
+		}
+
+		var results []struct {
+			Duration float64 `bson:"duration"`
+			Count    int     `bson:"count"`
+		}
+		err := db.C(collection).Pipe(&pipe).All(&results)
+		if err != nil {
+			return 0, 0, err
+		}
+
+		if len(results) > 0 {
+			total += results[0].Duration * float64(results[0].Count)
+			count += results[0].Count
+		}
+	}
+
+	if count == 0 {
+		return 0, 0, nil
+	}
+	return time.Duration(total/float64(count)) * time.Millisecond, count, nil
+}
+
+//GetDeviceStatusesForDeployment retrieve device deployment statuses for a given deployment.
+func (d *DeviceDeploymentsStorage) GetDeviceStatusesForDeployment(ctx context.Context,
+	deploymentID string) ([]deployments.DeviceDeployment, error) {
+
+	session := d.session.Copy()
+	defer session.Close()
 
 	query := bson.M{
 		StorageKeyDeviceDeploymentDeploymentID: deploymentID,
+	}
+
+	db := session.DB(store.DbFromContext(ctx, DatabaseName))
+	collections, err := deploymentCollections(db, deploymentID)
+	if err != nil {
+		return nil, err
+	}
+
+	var statuses []deployments.DeviceDeployment
+	for _, collection := range collections {
+		var list []deployments.DeviceDeployment
+		err := db.C(collection).Find(query).All(&list)
+		if err != nil {
+			return nil, err
+		}
+		statuses = append(statuses, list...)
+	}
+
+	return statuses, nil
+}
+
+// RevokeDeviceDeploymentLink marks download link of the device deployment
+// as revoked.
+func (d *DeviceDeploymentsStorage) RevokeDeviceDeploymentLink(ctx context.Context,
+	deviceID string, deploymentID string) error {
+
+	// Verify ID formatting
+	if govalidator.IsNull(deviceID) ||
+		govalidator.IsNull(deploymentID) {
+		return deployments.NewStoreError("RevokeDeviceDeploymentLink", CollectionDevices,
+			ErrStorageInvalidID, deviceID, deploymentID)
+	}
+
+	session := d.session.Copy()
+	defer session.Close()
+
+	selector := bson.M{
 		StorageKeyDeviceDeploymentDeviceId:     deviceID,
+		StorageKeyDeviceDeploymentDeploymentID: deploymentID,
 	}
 
-	var dep deployments.DeviceDeployment
-	err := session.DB(store.DbFromContext(ctx, DatabaseName)).
-		C(CollectionDevices).Find(query).One(&dep)
+	update := bson.M{
+		"$set": bson.M{
+			StorageKeyDeviceDeploymentLinkRevoked: true,
+		},
+	}
+
+	db := session.DB(store.DbFromContext(ctx, DatabaseName))
+	collections, err := deploymentCollections(db, deploymentID)
+	if err != nil {
+		return err
+	}
+
+	for _, collection := range collections {
+		if err := db.C(collection).Update(selector, update); err != nil {
+			if err == mgo.ErrNotFound {
+				continue
+			}
+			return err
+		}
+		return nil
+	}
+
+	return deployments.NewStoreError("RevokeDeviceDeploymentLink", CollectionDevices,
+		ErrStorageNotFound, deviceID, deploymentID)
+}
+
+// RetryDeviceDeployment puts the device deployment back to pending, if it is
+// still in the from status. Device deployments moved to partitions are
+// moved back to the devices collection.
+func (d *DeviceDeploymentsStorage) RetryDeviceDeployment(ctx context.Context,
+	deviceID string, deploymentID string, from string) error {
+
+	// Verify ID formatting
+	if govalidator.IsNull(deviceID) ||
+		govalidator.IsNull(deploymentID) {
+		return deployments.NewStoreError("RetryDeviceDeployment", CollectionDevices,
+			ErrStorageInvalidID, deviceID, deploymentID)
+	}
+
+	session := d.session.Copy()
+	defer session.Close()
+
+	selector := bson.M{
+		StorageKeyDeviceDeploymentDeviceId:     deviceID,
+		StorageKeyDeviceDeploymentDeploymentID: deploymentID,
+		StorageKeyDeviceDeploymentStatus:       from,
+	}
+
+	update := bson.M{
+		"$set": bson.M{
+			StorageKeyDeviceDeploymentStatus: deployments.DeviceDeploymentStatusPending,
+		},
+		"$unset": bson.M{
+			StorageKeyDeviceDeploymentFinished:       "",
+			StorageKeyDeviceDeploymentSubState:       "",
+			StorageKeyDeviceDeploymentError:          "",
+			StorageKeyDeviceDeploymentAbort:          "",
+			StorageKeyDeviceDeploymentStatusReported: "",
+			StorageKeyDeviceDeploymentReason:         "",
+		},
+		"$inc": bson.M{
+			StorageKeyDeviceDeploymentRetries: 1,
+		},
+	}
+
+	db := session.DB(store.DbFromContext(ctx, DatabaseName))
+	err := db.C(CollectionDevices).Update(selector, update)
+	if err == mgo.ErrNotFound {
+		err = retryPartitionedDeviceDeployment(db, selector, update)
+	}
+	if err == mgo.ErrNotFound {
+		return deployments.NewStoreError("RetryDeviceDeployment", CollectionDevices,
+			ErrStorageNotFound, deviceID, deploymentID)
+	}
+	return err
+}
+
+// retryPartitionedDeviceDeployment moves the finished device deployment
+// matching the selector from its partition back to the devices collection,
+// as pending, and applies the update. The device deployment is copied
+// before it is removed from the partition; mgo.ErrNotFound is returned if
+// it is not found, or it was moved back concurrently.
+func retryPartitionedDeviceDeployment(db *mgo.Database, selector, update bson.M) error {
+	partitions, err := deploymentPartitions(db,
+		selector[StorageKeyDeviceDeploymentDeploymentID].(string))
 	if err != nil {
+		return err
+	}
+
+	for _, partition := range partitions {
+		var doc bson.M
+		err := db.C(partition).Find(selector).One(&doc)
 		if err == mgo.ErrNotFound {
-			return false, nil
-		} else {
-			return false, err
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		// pending device deployments are not partitioned again
+		doc[StorageKeyDeviceDeploymentStatus] = deployments.DeviceDeploymentStatusPending
+		if err := db.C(CollectionDevices).Insert(doc); err != nil {
+			if mgo.IsDup(err) {
+				return mgo.ErrNotFound
+			}
+			return errors.Wrap(err, "moving device deployment from partition")
+		}
+		if err := db.C(CollectionDevices).UpdateId(doc["_id"], update); err != nil {
+			return err
+		}
+		if err := db.C(partition).RemoveId(doc["_id"]); err != nil && err != mgo.ErrNotFound {
+			return errors.Wrap(err, "removing device deployment from partition")
+		}
+		return nil
+	}
+	return mgo.ErrNotFound
+}
+
+// CountDeviceDeployments counts device deployments of the deployment,
+// only in the given status if status is not empty.
+func (d *DeviceDeploymentsStorage) CountDeviceDeployments(ctx context.Context,
+	deploymentID, status string) (int, error) {
+
+	if govalidator.IsNull(deploymentID) {
+		return 0, deployments.NewStoreError("CountDeviceDeployments", CollectionDevices,
+			ErrStorageInvalidID, deploymentID)
+	}
+
+	session := d.session.Copy()
+	defer session.Close()
+
+	query := bson.M{
+		StorageKeyDeviceDeploymentDeploymentID: deploymentID,
+	}
+	if status != "" {
+		query[StorageKeyDeviceDeploymentStatus] = status
+	}
+
+	db := session.DB(store.DbFromContext(ctx, DatabaseName))
+	collections := []string{CollectionDevices}
+	if includesFinished(status) {
+		var err error
+		collections, err = deploymentCollections(db, deploymentID)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	total := 0
+	for _, collection := range collections {
+		count, err := db.C(collection).Find(query).Count()
+		if err != nil {
+			return 0, err
+		}
+		total += count
+	}
+
+	return total, nil
+}
+
+// FindDeviceDeployments looks up device deployments of all deployments
+// matching the query, newest first.
+func (d *DeviceDeploymentsStorage) FindDeviceDeployments(ctx context.Context,
+	match deployments.DeviceDeploymentsQuery) ([]deployments.DeviceDeployment, error) {
+
+	session := d.session.Copy()
+	defer session.Close()
+
+	query := bson.M{}
+	if match.Status != "" {
+		query[StorageKeyDeviceDeploymentStatus] = match.Status
+	}
+
+	created := bson.M{}
+	if match.CreatedAfter != nil {
+		created["$gte"] = match.CreatedAfter
+	}
+	if match.CreatedBefore != nil {
+		created["$lte"] = match.CreatedBefore
+	}
+	if len(created) > 0 {
+		query[StorageKeyDeviceDeploymentCreated] = created
+	}
+
+	db := session.DB(store.DbFromContext(ctx, DatabaseName))
+	collections, err := statusCollections(db, match.Status)
+	if err != nil {
+		return nil, err
+	}
+
+	// partitions are newest first, the rest were created before
+	if match.CreatedAfter != nil {
+		for i, collection := range collections {
+			if end, ok := partitionEnd(collection); ok && !end.After(*match.CreatedAfter) {
+				collections = collections[:i]
+				break
+			}
+		}
+	}
+
+	var deviceDeployments []deployments.DeviceDeployment
+	if len(collections) == 1 {
+		err := db.C(CollectionDevices).
+			Find(query).Sort("-" + StorageKeyDeviceDeploymentCreated).
+			Skip(match.Skip).Limit(match.Limit).
+			All(&deviceDeployments)
+		if err != nil {
+			return nil, err
+		}
+		return deviceDeployments, nil
+	}
+
+	// each collection is asked for the whole page up to the limit,
+	// the results are merged
+	limit := 0
+	if match.Limit > 0 {
+		limit = match.Skip + match.Limit
+	}
+	for _, collection := range collections {
+		var list []deployments.DeviceDeployment
+		err := db.C(collection).
+			Find(query).Sort("-" + StorageKeyDeviceDeploymentCreated).
+			Limit(limit).
+			All(&list)
+		if err != nil {
+			return nil, err
 		}
+		deviceDeployments = append(deviceDeployments, list...)
+	}
+
+	sort.SliceStable(deviceDeployments, func(i, j int) bool {
+		return deviceDeployments[i].Created.After(*deviceDeployments[j].Created)
+	})
+
+	if match.Skip >= len(deviceDeployments) {
+		return nil, nil
+	}
+	deviceDeployments = deviceDeployments[match.Skip:]
+	if match.Limit > 0 && len(deviceDeployments) > match.Limit {
+		deviceDeployments = deviceDeployments[:match.Limit]
+	}
+
+	return deviceDeployments, nil
+}
+
+// Returns true if deployment of ID `deploymentID` is assigned to device with ID
+// `deviceID`, false otherwise. In case of errors returns false and an error
+// that occurred
+func (d *DeviceDeploymentsStorage) HasDeploymentForDevice(ctx context.Context,
+	deploymentID string, deviceID string) (bool, error) {
+
+	dep, err := d.findDeviceDeployment(ctx, deploymentID, deviceID)
+	if err != nil || dep == nil {
+		return false, err
 	}
 
 	return true, nil
@@ -427,6 +1326,19 @@ func (d *DeviceDeploymentsStorage) HasDeploymentForDevice(ctx context.Context,
 func (d *DeviceDeploymentsStorage) GetDeviceDeploymentStatus(ctx context.Context,
 	deploymentID string, deviceID string) (string, error) {
 
+	dep, err := d.findDeviceDeployment(ctx, deploymentID, deviceID)
+	if err != nil || dep == nil {
+		return "", err
+	}
+
+	return *dep.Status, nil
+}
+
+// findDeviceDeployment looks up device deployment of the device in
+// the deployment, nil if there is none.
+func (d *DeviceDeploymentsStorage) findDeviceDeployment(ctx context.Context,
+	deploymentID string, deviceID string) (*deployments.DeviceDeployment, error) {
+
 	session := d.session.Copy()
 	defer session.Close()
 
@@ -435,25 +1347,32 @@ func (d *DeviceDeploymentsStorage) GetDeviceDeploymentStatus(ctx context.Context
 		StorageKeyDeviceDeploymentDeviceId:     deviceID,
 	}
 
-	var dep deployments.DeviceDeployment
-	err := session.DB(store.DbFromContext(ctx, DatabaseName)).
-		C(CollectionDevices).Find(query).One(&dep)
+	db := session.DB(store.DbFromContext(ctx, DatabaseName))
+	collections, err := deploymentCollections(db, deploymentID)
 	if err != nil {
+		return nil, err
+	}
+
+	for _, collection := range collections {
+		var dep deployments.DeviceDeployment
+		err := db.C(collection).Find(query).One(&dep)
 		if err == mgo.ErrNotFound {
-			return "", nil
-		} else {
-			return "", err
+			continue
+		} else if err != nil {
+			return nil, err
 		}
+		return &dep, nil
 	}
 
-	return *dep.Status, nil
+	return nil, nil
 }
 
 func (d *DeviceDeploymentsStorage) AbortDeviceDeployments(ctx context.Context,
-	deploymentId string) error {
+	deploymentId string, abort *deployments.AbortInfo) error {
 
 	if govalidator.IsNull(deploymentId) {
-		return ErrStorageInvalidID
+		return deployments.NewStoreError("AbortDeviceDeployments", CollectionDevices,
+			ErrStorageInvalidID, deploymentId)
 	}
 
 	session := d.session.Copy()
@@ -471,27 +1390,66 @@ func (d *DeviceDeploymentsStorage) AbortDeviceDeployments(ctx context.Context,
 		},
 	}
 
+	set := bson.M{
+		StorageKeyDeviceDeploymentStatus: deployments.DeviceDeploymentStatusAborted,
+	}
+	if abort != nil {
+		set[StorageKeyDeviceDeploymentAbort] = abort
+	}
+
 	update := bson.M{
-		"$set": bson.M{
-			StorageKeyDeviceDeploymentStatus: deployments.DeviceDeploymentStatusAborted,
-		},
+		"$set": set,
 	}
 
 	_, err := session.DB(store.DbFromContext(ctx, DatabaseName)).
 		C(CollectionDevices).UpdateAll(selector, update)
 
 	if err == mgo.ErrNotFound {
-		return ErrStorageInvalidID
+		return deployments.NewStoreError("AbortDeviceDeployments", CollectionDevices,
+			ErrStorageInvalidID, deploymentId)
 	}
 
 	return err
 }
 
+// FinishDeviceDeployments sets the terminal status and finish time of
+// device deployments of the deployment which did not finish yet.
+func (d *DeviceDeploymentsStorage) FinishDeviceDeployments(ctx context.Context,
+	deploymentID string, status string, finished time.Time) error {
+
+	if govalidator.IsNull(deploymentID) {
+		return deployments.NewStoreError("FinishDeviceDeployments", CollectionDevices,
+			ErrStorageInvalidID, deploymentID)
+	}
+
+	session := d.session.Copy()
+	defer session.Close()
+
+	// device deployments are partitioned only once finished
+	selector := bson.M{
+		StorageKeyDeviceDeploymentDeploymentID: deploymentID,
+		StorageKeyDeviceDeploymentStatus: bson.M{
+			"$in": deployments.ActiveDeploymentStatuses(),
+		},
+	}
+	update := bson.M{
+		"$set": bson.M{
+			StorageKeyDeviceDeploymentStatus:   status,
+			StorageKeyDeviceDeploymentFinished: finished,
+		},
+	}
+
+	_, err := session.DB(store.DbFromContext(ctx, DatabaseName)).
+		C(CollectionDevices).UpdateAll(selector, update)
+	return err
+}
+
 func (d *DeviceDeploymentsStorage) DecommissionDeviceDeployments(ctx context.Context,
 	deviceId string) error {
 
 	if govalidator.IsNull(deviceId) {
-		return ErrStorageInvalidID
+		return deployments.NewStoreError("DecommissionDeviceDeployments", CollectionDevices,
+			ErrStorageInvalidID, deviceId)
 	}
 
 	session := d.session.Copy()
@@ -520,3 +1478,181 @@ func (d *DeviceDeploymentsStorage) DecommissionDeviceDeployments(ctx context.Con
 
 	return err
 }
+
+// AnonymizeDeviceDeployments replaces the device ID of all device deployments
+// of the device and drops the details reported by the device, and its
+// inventory snapshot.
+// Returns number of anonymized device deployments.
+func (d *DeviceDeploymentsStorage) AnonymizeDeviceDeployments(ctx context.Context,
+	deviceID, anonymousID string) (int, error) {
+
+	if govalidator.IsNull(deviceID) || govalidator.IsNull(anonymousID) {
+		return 0, deployments.NewStoreError("AnonymizeDeviceDeployments", CollectionDevices,
+			ErrStorageInvalidID, deviceID)
+	}
+
+	session := d.session.Copy()
+	defer session.Close()
+
+	selector := bson.M{
+		StorageKeyDeviceDeploymentDeviceId: deviceID,
+	}
+
+	update := bson.M{
+		"$set": bson.M{
+			StorageKeyDeviceDeploymentDeviceId:       anonymousID,
+			StorageKeyDeviceDeploymentIsLogAvailable: false,
+		},
+		"$unset": bson.M{
+			StorageKeyDeviceDeploymentSubState:  "",
+			StorageKeyDeviceDeploymentError:     "",
+			StorageKeyDeviceDeploymentInventory: "",
+		},
+	}
+
+	db := session.DB(store.DbFromContext(ctx, DatabaseName))
+	partitions, err := devicesPartitions(db)
+	if err != nil {
+		return 0, err
+	}
+
+	updated := 0
+	for _, collection := range devicesCollections(partitions) {
+		info, err := db.C(collection).UpdateAll(selector, update)
+		if err != nil {
+			return updated, err
+		}
+		updated += info.Updated
+	}
+
+	return updated, nil
+}
+
+// FindDeviceIDsWithStatuses returns IDs of the given devices which have
+// device deployments in one of the statuses.
+func (d *DeviceDeploymentsStorage) FindDeviceIDsWithStatuses(ctx context.Context,
+	deviceIDs []string, statuses ...string) ([]string, error) {
+
+	if len(deviceIDs) == 0 {
+		return nil, nil
+	}
+
+	session := d.session.Copy()
+	defer session.Close()
+
+	query := bson.M{
+		StorageKeyDeviceDeploymentDeviceId: bson.M{"$in": deviceIDs},
+		StorageKeyDeviceDeploymentStatus:   bson.M{"$in": statuses},
+	}
+
+	db := session.DB(store.DbFromContext(ctx, DatabaseName))
+	collections, err := statusCollections(db, statuses...)
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []string
+	found := make(map[string]bool)
+	for _, collection := range collections {
+		var list []string
+		err := db.C(collection).Find(query).
+			Distinct(StorageKeyDeviceDeploymentDeviceId, &list)
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range list {
+			if !found[id] {
+				found[id] = true
+				ids = append(ids, id)
+			}
+		}
+	}
+
+	return ids, nil
+}
+
+// FindDeploymentsForDeviceIDsWithStatuses finds device deployments of the
+// given devices in one of the statuses, oldest first within a collection.
+func (d *DeviceDeploymentsStorage) FindDeploymentsForDeviceIDsWithStatuses(ctx context.Context,
+	deviceIDs []string, statuses ...string) ([]deployments.DeviceDeployment, error) {
+
+	if len(deviceIDs) == 0 {
+		return nil, nil
+	}
+
+	session := d.session.Copy()
+	defer session.Close()
+
+	query := bson.M{
+		StorageKeyDeviceDeploymentDeviceId: bson.M{"$in": deviceIDs},
+		StorageKeyDeviceDeploymentStatus:   bson.M{"$in": statuses},
+	}
+
+	db := session.DB(store.DbFromContext(ctx, DatabaseName))
+	collections, err := statusCollections(db, statuses...)
+	if err != nil {
+		return nil, err
+	}
+
+	var all []deployments.DeviceDeployment
+	for _, collection := range collections {
+		var list []deployments.DeviceDeployment
+		if err := db.C(collection).Find(query).
+			Sort(StorageKeyDeviceDeploymentCreated).All(&list); err != nil {
+			return nil, err
+		}
+		all = append(all, list...)
+	}
+
+	return all, nil
+}
+
+// SupersedeDeviceDeployments marks pending device deployments of the given
+// devices, belonging to other deployments than deploymentID, as superseded
+// by deploymentID. Returns IDs of affected deployments.
+func (d *DeviceDeploymentsStorage) SupersedeDeviceDeployments(ctx context.Context,
+	deploymentID string, deviceIDs []string) ([]string, error) {
+
+	if govalidator.IsNull(deploymentID) {
+		return nil, deployments.NewStoreError("SupersedeDeviceDeployments", CollectionDevices,
+			ErrStorageInvalidID, deploymentID)
+	}
+
+	if len(deviceIDs) == 0 {
+		return nil, nil
+	}
+
+	session := d.session.Copy()
+	defer session.Close()
+
+	selector := bson.M{
+		StorageKeyDeviceDeploymentDeviceId:     bson.M{"$in": deviceIDs},
+		StorageKeyDeviceDeploymentDeploymentID: bson.M{"$ne": deploymentID},
+		StorageKeyDeviceDeploymentStatus:       deployments.DeviceDeploymentStatusPending,
+	}
+
+	c := session.DB(store.DbFromContext(ctx, DatabaseName)).C(CollectionDevices)
+
+	var affected []string
+	if err := c.Find(selector).Distinct(StorageKeyDeviceDeploymentDeploymentID,
+		&affected); err != nil {
+		return nil, err
+	}
+
+	if len(affected) == 0 {
+		return nil, nil
+	}
+
+	update := bson.M{
+		"$set": bson.M{
+			StorageKeyDeviceDeploymentStatus:       deployments.DeviceDeploymentStatusSuperseded,
+			StorageKeyDeviceDeploymentSupersededBy: deploymentID,
+		},
+	}
+
+	if _, err := c.UpdateAll(selector, update); err != nil {
+		return nil, err
+	}
+
+	return affected, nil
+}
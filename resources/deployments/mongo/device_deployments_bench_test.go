@@ -0,0 +1,161 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package mongo_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/globalsign/mgo"
+
+	"github.com/mendersoftware/deployments/resources/deployments"
+	. "github.com/mendersoftware/deployments/resources/deployments/mongo"
+)
+
+// Benchmarks of the device deployments store at growing deployment sizes,
+// needing a MongoDB instance same as the tests. Single scale can be selected
+// with the benchmark name, results of two runs compared with benchstat:
+//
+//	go test -run XXX -bench 'DeviceDeployments.*/devices=100000' -benchtime 20x \
+//		./resources/deployments/mongo > new.txt
+//	benchstat old.txt new.txt
+var benchDeviceCounts = []int{10000, 100000, 1000000}
+
+const benchDeploymentID = "3b2c3e2e-1c6a-4e2b-8a1f-2b6c0b9e1d7a"
+
+func benchDeviceDeployments(count int) []*deployments.DeviceDeployment {
+	statuses := []string{
+		deployments.DeviceDeploymentStatusPending,
+		deployments.DeviceDeploymentStatusDownloading,
+		deployments.DeviceDeploymentStatusInstalling,
+		deployments.DeviceDeploymentStatusSuccess,
+		deployments.DeviceDeploymentStatusFailure,
+	}
+
+	list := make([]*deployments.DeviceDeployment, count)
+	for i := range list {
+		list[i] = newDeviceDeploymentWithStatus(fmt.Sprintf("device-%07d", i),
+			benchDeploymentID, statuses[i%len(statuses)])
+	}
+
+	return list
+}
+
+// benchSetup wipes the database and fills it with device deployments of
+// single deployment. Returned session has to be closed by the caller.
+func benchSetup(b *testing.B, count int) (*DeviceDeploymentsStorage, *mgo.Session) {
+	db.Wipe()
+
+	session := db.Session()
+	store := NewDeviceDeploymentsStorage(session)
+	if err := store.InsertMany(context.Background(), benchDeviceDeployments(count)...); err != nil {
+		session.Close()
+		b.Fatalf("failed to insert device deployments: %v", err)
+	}
+
+	return store, session
+}
+
+func BenchmarkDeviceDeploymentsInsertMany(b *testing.B) {
+	if testing.Short() {
+		b.Skip("skipping BenchmarkDeviceDeploymentsInsertMany in short mode.")
+	}
+
+	for _, count := range benchDeviceCounts {
+		b.Run(fmt.Sprintf("devices=%d", count), func(b *testing.B) {
+			list := benchDeviceDeployments(count)
+			ctx := context.Background()
+
+			var elapsed time.Duration
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				db.Wipe()
+				session := db.Session()
+				store := NewDeviceDeploymentsStorage(session)
+				b.StartTimer()
+
+				start := time.Now()
+				err := store.InsertMany(ctx, list...)
+				elapsed += time.Since(start)
+
+				// Need to close all sessions to be able to call wipe at next iteration
+				session.Close()
+				if err != nil {
+					b.Fatalf("failed to insert device deployments: %v", err)
+				}
+			}
+
+			b.ReportMetric(float64(count*b.N)/elapsed.Seconds(), "devices/s")
+		})
+	}
+}
+
+func BenchmarkDeviceDeploymentsUpdateStatus(b *testing.B) {
+	if testing.Short() {
+		b.Skip("skipping BenchmarkDeviceDeploymentsUpdateStatus in short mode.")
+	}
+
+	for _, count := range benchDeviceCounts {
+		b.Run(fmt.Sprintf("devices=%d", count), func(b *testing.B) {
+			store, session := benchSetup(b, count)
+			defer session.Close()
+			ctx := context.Background()
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				// spread updates over devices, alternating between statuses
+				status := deployments.DeviceDeploymentStatusInstalling
+				if (i/count)%2 == 1 {
+					status = deployments.DeviceDeploymentStatusRebooting
+				}
+
+				_, err := store.UpdateDeviceDeploymentStatus(ctx,
+					fmt.Sprintf("device-%07d", i%count), benchDeploymentID,
+					deployments.DeviceDeploymentStatus{Status: status})
+				if err != nil {
+					b.Fatalf("failed to update device deployment: %v", err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkDeviceDeploymentsAggregateByStatus(b *testing.B) {
+	if testing.Short() {
+		b.Skip("skipping BenchmarkDeviceDeploymentsAggregateByStatus in short mode.")
+	}
+
+	for _, count := range benchDeviceCounts {
+		b.Run(fmt.Sprintf("devices=%d", count), func(b *testing.B) {
+			store, session := benchSetup(b, count)
+			defer session.Close()
+			ctx := context.Background()
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				stats, err := store.AggregateDeviceDeploymentByStatus(ctx, benchDeploymentID)
+				if err != nil {
+					b.Fatalf("failed to aggregate device deployments: %v", err)
+				}
+				if stats[deployments.DeviceDeploymentStatusPending] != (count+4)/5 {
+					b.Fatalf("unexpected pending count: %d", stats[deployments.DeviceDeploymentStatusPending])
+				}
+			}
+		})
+	}
+}
@@ -18,6 +18,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"sort"
 	"testing"
 	"time"
 
@@ -102,7 +103,7 @@ func TestDeviceDeploymentStorageInsert(t *testing.T) {
 				testCase.InputDeviceDeployment...)
 
 			if testCase.OutputError != nil {
-				assert.EqualError(t, err, testCase.OutputError.Error())
+				assertError(t, err, testCase.OutputError)
 			} else {
 				assert.NoError(t, err)
 
@@ -220,6 +221,37 @@ func TestUpdateDeviceDeploymentStatus(t *testing.T) {
 			OutputError:     nil,
 			OutputOldStatus: "pending",
 		},
+		{
+			// finished status is not overwritten by progress
+			InputDeviceID:     "23456",
+			InputDeploymentID: "30b3e62c-9ec2-4312-a7fa-cff24cc7397e",
+			InputStatus:       deployments.DeviceDeploymentStatusInstalling,
+			InputDeviceDeployment: []*deployments.DeviceDeployment{
+				func() *deployments.DeviceDeployment {
+					dd := deployments.NewDeviceDeployment("23456",
+						"30b3e62c-9ec2-4312-a7fa-cff24cc7397e")
+					dd.Status = pointers.StringToPointer(deployments.DeviceDeploymentStatusSuccess)
+					return dd
+				}(),
+			},
+			OutputError: ErrStorageFinishedStatus,
+		},
+		{
+			// finished status can be changed to another one
+			InputDeviceID:     "34567",
+			InputDeploymentID: "30b3e62c-9ec2-4312-a7fa-cff24cc7397e",
+			InputStatus:       deployments.DeviceDeploymentStatusAborted,
+			InputDeviceDeployment: []*deployments.DeviceDeployment{
+				func() *deployments.DeviceDeployment {
+					dd := deployments.NewDeviceDeployment("34567",
+						"30b3e62c-9ec2-4312-a7fa-cff24cc7397e")
+					dd.Status = pointers.StringToPointer(deployments.DeviceDeploymentStatusFailure)
+					return dd
+				}(),
+			},
+			InputFinishTime: &now,
+			OutputOldStatus: deployments.DeviceDeploymentStatusFailure,
+		},
 	}
 
 	for testCaseNumber, testCase := range testCases {
@@ -255,7 +287,7 @@ func TestUpdateDeviceDeploymentStatus(t *testing.T) {
 				})
 
 			if testCase.OutputError != nil {
-				assert.EqualError(t, err, testCase.OutputError.Error())
+				assertError(t, err, testCase.OutputError)
 			} else {
 				assert.NoError(t, err)
 
@@ -272,7 +304,7 @@ func TestUpdateDeviceDeploymentStatus(t *testing.T) {
 							SubState:   testCase.InputSubState,
 						})
 					t.Logf("error: %+v", err)
-					assert.EqualError(t, err, ErrStorageNotFound.Error())
+					assertError(t, err, ErrStorageNotFound)
 				}
 			}
 
@@ -288,7 +320,8 @@ func TestUpdateDeviceDeploymentStatus(t *testing.T) {
 				assert.NoError(t, err)
 				if testCase.OutputError != nil {
 					// status must be unchanged in case of errors
-					assert.Equal(t, deployments.DeviceDeploymentStatusPending, deployment.Status)
+					assert.Equal(t, *testCase.InputDeviceDeployment[0].Status,
+						*deployment.Status)
 				} else {
 					if !assert.NotNil(t, deployment) {
 						return
@@ -397,7 +430,7 @@ func TestUpdateDeviceDeploymentLogAvailability(t *testing.T) {
 				testCase.InputDeviceID, testCase.InputDeploymentID, testCase.InputLog)
 
 			if testCase.OutputError != nil {
-				assert.EqualError(t, err, testCase.OutputError.Error())
+				assertError(t, err, testCase.OutputError)
 			} else {
 				assert.NoError(t, err)
 
@@ -406,7 +439,7 @@ func TestUpdateDeviceDeploymentLogAvailability(t *testing.T) {
 					err := store.UpdateDeviceDeploymentLogAvailability(context.Background(),
 						testCase.InputDeviceID, testCase.InputDeploymentID,
 						testCase.InputLog)
-					assert.EqualError(t, err, ErrStorageNotFound.Error())
+					assertError(t, err, ErrStorageNotFound)
 				}
 			}
 
@@ -486,6 +519,7 @@ func TestAggregateDeviceDeploymentByStatus(t *testing.T) {
 				deployments.DeviceDeploymentStatusAlreadyInst:    0,
 				deployments.DeviceDeploymentStatusAborted:        0,
 				deployments.DeviceDeploymentStatusDecommissioned: 0,
+				deployments.DeviceDeploymentStatusSuperseded:     0,
 			},
 		},
 		{
@@ -529,7 +563,7 @@ func TestAggregateDeviceDeploymentByStatus(t *testing.T) {
 			stats, err := store.AggregateDeviceDeploymentByStatus(ctx,
 				testCase.InputDeploymentID)
 			if testCase.OutputError != nil {
-				assert.EqualError(t, err, testCase.OutputError.Error())
+				assertError(t, err, testCase.OutputError)
 			} else {
 				assert.NoError(t, err)
 
@@ -554,6 +588,316 @@ func TestAggregateDeviceDeploymentByStatus(t *testing.T) {
 	}
 }
 
+func TestAggregateDeviceDeploymentFailures(t *testing.T) {
+
+	if testing.Short() {
+		t.Skip("skipping TestAggregateDeviceDeploymentFailures in short mode.")
+	}
+
+	db.Wipe()
+	session := db.Session()
+	defer session.Close()
+
+	store := NewDeviceDeploymentsStorage(session)
+	ctx := context.Background()
+
+	deploymentID := "30b3e62c-9ec2-4312-a7fa-cff24cc7397a"
+	err := store.InsertMany(ctx,
+		newDeviceDeploymentWithStatus("123", deploymentID,
+			deployments.DeviceDeploymentStatusDownloading),
+		newDeviceDeploymentWithStatus("234", deploymentID,
+			deployments.DeviceDeploymentStatusDownloading),
+		newDeviceDeploymentWithStatus("345", deploymentID,
+			deployments.DeviceDeploymentStatusDownloading),
+		newDeviceDeploymentWithStatus("456", deploymentID,
+			deployments.DeviceDeploymentStatusSuccess),
+	)
+	assert.NoError(t, err)
+
+	for _, device := range []string{"123", "234"} {
+		_, err := store.UpdateDeviceDeploymentStatus(ctx, device, deploymentID,
+			deployments.DeviceDeploymentStatus{
+				Status: deployments.DeviceDeploymentStatusFailure,
+				Error: &deployments.DeviceDeploymentError{
+					Code:     "ENOSPC",
+					Category: "storage-full",
+				},
+			})
+		assert.NoError(t, err)
+	}
+	_, err = store.UpdateDeviceDeploymentStatus(ctx, "345", deploymentID,
+		deployments.DeviceDeploymentStatus{
+			Status: deployments.DeviceDeploymentStatusFailure,
+		})
+	assert.NoError(t, err)
+
+	stats, err := store.AggregateDeviceDeploymentFailures(ctx, deploymentID)
+	assert.NoError(t, err)
+	assert.Equal(t, deployments.FailureStats{
+		"storage-full":                        2,
+		deployments.FailureStatsUncategorized: 1,
+	}, stats)
+
+	_, err = store.AggregateDeviceDeploymentFailures(ctx, "")
+	assertError(t, err, ErrStorageInvalidID)
+}
+
+func TestIncrementDeviceDeploymentCounters(t *testing.T) {
+
+	if testing.Short() {
+		t.Skip("skipping TestIncrementDeviceDeploymentCounters in short mode.")
+	}
+
+	db.Wipe()
+	session := db.Session()
+	defer session.Close()
+
+	store := NewDeviceDeploymentsStorage(session)
+	ctx := context.Background()
+
+	deploymentID := "30b3e62c-9ec2-4312-a7fa-cff24cc7397a"
+	err := store.InsertMany(ctx,
+		newDeviceDeploymentWithStatus("123", deploymentID,
+			deployments.DeviceDeploymentStatusDownloading))
+	assert.NoError(t, err)
+
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, store.IncrementDeviceDeploymentAttempts(ctx, "123", deploymentID))
+	}
+	assert.NoError(t, store.IncrementDeviceDeploymentStatusResets(ctx, "123", deploymentID))
+
+	dd, err := store.FindLatestDeploymentForDeviceID(ctx, "123")
+	assert.NoError(t, err)
+	if assert.NotNil(t, dd) {
+		assert.Equal(t, 3, dd.Attempts)
+		assert.Equal(t, 1, dd.StatusResets)
+	}
+
+	assertError(t, store.IncrementDeviceDeploymentAttempts(ctx, "", deploymentID),
+		ErrStorageInvalidID)
+	assertError(t, store.IncrementDeviceDeploymentStatusResets(ctx, "234", deploymentID),
+		ErrStorageNotFound)
+}
+
+func TestSaveDeviceDeploymentInventory(t *testing.T) {
+
+	if testing.Short() {
+		t.Skip("skipping TestSaveDeviceDeploymentInventory in short mode.")
+	}
+
+	db.Wipe()
+	session := db.Session()
+	defer session.Close()
+
+	store := NewDeviceDeploymentsStorage(session)
+	ctx := context.Background()
+
+	deploymentID := "30b3e62c-9ec2-4312-a7fa-cff24cc7397a"
+	err := store.InsertMany(ctx,
+		newDeviceDeploymentWithStatus("123", deploymentID,
+			deployments.DeviceDeploymentStatusPending))
+	assert.NoError(t, err)
+
+	assert.NoError(t, store.SaveDeviceDeploymentInventory(ctx, "123", deploymentID,
+		map[string]string{"group": "site-a"}))
+	// first snapshot is kept
+	assert.NoError(t, store.SaveDeviceDeploymentInventory(ctx, "123", deploymentID,
+		map[string]string{"group": "site-b"}))
+
+	dd, err := store.FindLatestDeploymentForDeviceID(ctx, "123")
+	assert.NoError(t, err)
+	if assert.NotNil(t, dd) {
+		assert.Equal(t, map[string]string{"group": "site-a"}, dd.Inventory)
+	}
+
+	assertError(t, store.SaveDeviceDeploymentInventory(ctx, "", deploymentID, nil),
+		ErrStorageInvalidID)
+}
+
+func TestAggregateDeviceDeploymentDurations(t *testing.T) {
+
+	if testing.Short() {
+		t.Skip("skipping TestAggregateDeviceDeploymentDurations in short mode.")
+	}
+
+	db.Wipe()
+	session := db.Session()
+	defer session.Close()
+
+	store := NewDeviceDeploymentsStorage(session)
+	ctx := context.Background()
+
+	deploymentID := "30b3e62c-9ec2-4312-a7fa-cff24cc7397a"
+	created := time.Now().UTC().Round(time.Second)
+
+	finished := func(deviceID, status string, after time.Duration) *deployments.DeviceDeployment {
+		d := newDeviceDeploymentWithStatus(deviceID, deploymentID, status)
+		d.Created = &created
+		if after != 0 {
+			when := created.Add(after)
+			d.Finished = &when
+		}
+		return d
+	}
+	err := store.InsertMany(ctx,
+		finished("001", deployments.DeviceDeploymentStatusSuccess, 30*time.Second),
+		finished("002", deployments.DeviceDeploymentStatusSuccess, 59*time.Second),
+		finished("003", deployments.DeviceDeploymentStatusSuccess, 10*time.Minute),
+		finished("004", deployments.DeviceDeploymentStatusSuccess, 48*time.Hour),
+		finished("005", deployments.DeviceDeploymentStatusFailure, 2*time.Minute),
+		finished("006", deployments.DeviceDeploymentStatusFailure, -time.Second),
+		finished("007", deployments.DeviceDeploymentStatusDownloading, 0),
+		finished("008", deployments.DeviceDeploymentStatusAborted, time.Minute),
+	)
+	assert.NoError(t, err)
+
+	expected := deployments.NewDurationStats()
+	expected[deployments.DeviceDeploymentStatusSuccess][0].Count = 2
+	expected[deployments.DeviceDeploymentStatusSuccess][2].Count = 1
+	expected[deployments.DeviceDeploymentStatusSuccess][6].Count = 1
+	expected[deployments.DeviceDeploymentStatusFailure][0].Count = 1
+	expected[deployments.DeviceDeploymentStatusFailure][1].Count = 1
+
+	stats, err := store.AggregateDeviceDeploymentDurations(ctx, deploymentID)
+	assert.NoError(t, err)
+	assert.Equal(t, expected, stats)
+
+	_, err = store.AggregateDeviceDeploymentDurations(ctx, "")
+	assertError(t, err, ErrStorageInvalidID)
+}
+
+func TestAverageDeviceDeploymentDuration(t *testing.T) {
+
+	if testing.Short() {
+		t.Skip("skipping TestAverageDeviceDeploymentDuration in short mode.")
+	}
+
+	db.Wipe()
+	session := db.Session()
+	defer session.Close()
+
+	store := NewDeviceDeploymentsStorage(session)
+	ctx := context.Background()
+
+	deploymentID := "30b3e62c-9ec2-4312-a7fa-cff24cc7397a"
+	now := time.Now().UTC().Round(time.Second)
+
+	finished := func(deviceID, status string, created time.Time,
+		after time.Duration) *deployments.DeviceDeployment {
+		d := newDeviceDeploymentWithStatus(deviceID, deploymentID, status)
+		d.Created = &created
+		when := created.Add(after)
+		d.Finished = &when
+		return d
+	}
+
+	// no history
+	duration, count, err := store.AverageDeviceDeploymentDuration(ctx, now.Add(-time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, time.Duration(0), duration)
+	assert.Equal(t, 0, count)
+
+	err = store.InsertMany(ctx,
+		finished("001", deployments.DeviceDeploymentStatusSuccess, now.Add(-time.Hour), 10*time.Minute),
+		finished("002", deployments.DeviceDeploymentStatusSuccess, now.Add(-time.Hour), 20*time.Minute),
+		finished("003", deployments.DeviceDeploymentStatusFailure, now.Add(-time.Hour), time.Minute),
+		finished("004", deployments.DeviceDeploymentStatusSuccess, now.Add(-48*time.Hour), time.Hour),
+	)
+	assert.NoError(t, err)
+
+	duration, count, err = store.AverageDeviceDeploymentDuration(ctx, now.Add(-24*time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, 15*time.Minute, duration)
+	assert.Equal(t, 2, count)
+}
+
+func TestUpdateDeviceDeploymentStatuses(t *testing.T) {
+
+	if testing.Short() {
+		t.Skip("skipping TestUpdateDeviceDeploymentStatuses in short mode.")
+	}
+
+	db.Wipe()
+	session := db.Session()
+	defer session.Close()
+
+	store := NewDeviceDeploymentsStorage(session)
+	ctx := context.Background()
+
+	deploymentID := "30b3e62c-9ec2-4312-a7fa-cff24cc7397a"
+	err := store.InsertMany(ctx,
+		deployments.NewDeviceDeployment("001", deploymentID),
+		deployments.NewDeviceDeployment("002", deploymentID))
+	assert.NoError(t, err)
+
+	updates := []deployments.DeviceDeploymentStatusUpdate{
+		{
+			ID:           "update-1",
+			DeviceID:     "001",
+			DeploymentID: deploymentID,
+			From:         deployments.DeviceDeploymentStatusPending,
+			Status: deployments.DeviceDeploymentStatus{
+				Status: deployments.DeviceDeploymentStatusDownloading,
+			},
+		},
+		{
+			ID:           "update-2",
+			DeviceID:     "001",
+			DeploymentID: deploymentID,
+			From:         deployments.DeviceDeploymentStatusDownloading,
+			Status: deployments.DeviceDeploymentStatus{
+				Status: deployments.DeviceDeploymentStatusInstalling,
+			},
+		},
+		// not in the expected status
+		{
+			ID:           "update-3",
+			DeviceID:     "002",
+			DeploymentID: deploymentID,
+			From:         deployments.DeviceDeploymentStatusDownloading,
+			Status: deployments.DeviceDeploymentStatus{
+				Status: deployments.DeviceDeploymentStatusInstalling,
+			},
+		},
+	}
+	matched, err := store.UpdateDeviceDeploymentStatuses(ctx, updates)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, matched)
+
+	status, err := store.GetDeviceDeploymentStatus(ctx, deploymentID, "001")
+	assert.NoError(t, err)
+	assert.Equal(t, deployments.DeviceDeploymentStatusInstalling, status)
+
+	status, err = store.GetDeviceDeploymentStatus(ctx, deploymentID, "002")
+	assert.NoError(t, err)
+	assert.Equal(t, deployments.DeviceDeploymentStatusPending, status)
+
+	// the device deployment keeps ID of its last update only
+	applied, err := store.FindAppliedStatusUpdates(ctx, updates)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"update-2"}, applied)
+
+	// target status of the update not applied written by another one
+	matched, err = store.UpdateDeviceDeploymentStatuses(ctx,
+		[]deployments.DeviceDeploymentStatusUpdate{
+			{
+				ID:           "update-4",
+				DeviceID:     "002",
+				DeploymentID: deploymentID,
+				From:         deployments.DeviceDeploymentStatusPending,
+				Status: deployments.DeviceDeploymentStatus{
+					Status: deployments.DeviceDeploymentStatusInstalling,
+				},
+			},
+		})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, matched)
+
+	applied, err = store.FindAppliedStatusUpdates(ctx, updates[2:])
+	assert.NoError(t, err)
+	assert.Empty(t, applied)
+}
+
 func TestGetDeviceStatusesForDeployment(t *testing.T) {
 	if testing.Short() {
 		t.Skip("skipping GetDeviceStatusesForDeployment in short mode.")
@@ -637,6 +981,299 @@ func TestGetDeviceStatusesForDeployment(t *testing.T) {
 	}
 }
 
+func TestCountDeviceDeployments(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping CountDeviceDeployments in short mode.")
+	}
+
+	input := []*deployments.DeviceDeployment{
+		deployments.NewDeviceDeployment("device0001", "30b3e62c-9ec2-4312-a7fa-cff24cc7397a"),
+		deployments.NewDeviceDeployment("device0002", "30b3e62c-9ec2-4312-a7fa-cff24cc7397a"),
+		deployments.NewDeviceDeployment("device0003", "30b3e62c-9ec2-4312-a7fa-cff24cc7397a"),
+		deployments.NewDeviceDeployment("device0004", "30b3e62c-9ec2-4312-a7fa-cff24cc7397b"),
+	}
+	failure := deployments.DeviceDeploymentStatusFailure
+	input[0].Status = &failure
+
+	testCases := map[string]struct {
+		tenant string
+
+		inputDeploymentId string
+		inputStatus       string
+		outputCount       int
+	}{
+		"all devices": {
+			inputDeploymentId: "30b3e62c-9ec2-4312-a7fa-cff24cc7397a",
+			outputCount:       3,
+		},
+		"devices in status": {
+			inputDeploymentId: "30b3e62c-9ec2-4312-a7fa-cff24cc7397a",
+			inputStatus:       deployments.DeviceDeploymentStatusPending,
+			outputCount:       2,
+		},
+		"no devices in status": {
+			inputDeploymentId: "30b3e62c-9ec2-4312-a7fa-cff24cc7397b",
+			inputStatus:       deployments.DeviceDeploymentStatusFailure,
+			outputCount:       0,
+		},
+		"nonexistent deployment": {
+			inputDeploymentId: "aaaaaaaa-9ec2-4312-a7fa-cff24cc7397b",
+			outputCount:       0,
+		},
+		"tenant": {
+			inputDeploymentId: "30b3e62c-9ec2-4312-a7fa-cff24cc7397a",
+			tenant:            "acme",
+			outputCount:       3,
+		},
+	}
+
+	for testCaseName, tc := range testCases {
+		t.Run(fmt.Sprintf("test case %s", testCaseName), func(t *testing.T) {
+
+			db.Wipe()
+
+			session := db.Session()
+			defer session.Close()
+			store := NewDeviceDeploymentsStorage(session)
+
+			ctx := context.Background()
+			if tc.tenant != "" {
+				ctx = identity.WithContext(ctx, &identity.Identity{
+					Tenant: tc.tenant,
+				})
+			}
+
+			err := store.InsertMany(ctx, input...)
+			assert.NoError(t, err)
+
+			count, err := store.CountDeviceDeployments(ctx,
+				tc.inputDeploymentId, tc.inputStatus)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.outputCount, count)
+
+			if tc.tenant != "" {
+				count, err := store.CountDeviceDeployments(context.Background(),
+					tc.inputDeploymentId, tc.inputStatus)
+				assert.NoError(t, err)
+				assert.Equal(t, 0, count)
+			}
+		})
+	}
+}
+
+func TestFindDeviceDeployments(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping FindDeviceDeployments in short mode.")
+	}
+
+	input := []*deployments.DeviceDeployment{
+		deployments.NewDeviceDeployment("device0001", "30b3e62c-9ec2-4312-a7fa-cff24cc7397a"),
+		deployments.NewDeviceDeployment("device0002", "30b3e62c-9ec2-4312-a7fa-cff24cc7397a"),
+		deployments.NewDeviceDeployment("device0003", "30b3e62c-9ec2-4312-a7fa-cff24cc7397b"),
+		deployments.NewDeviceDeployment("device0004", "30b3e62c-9ec2-4312-a7fa-cff24cc7397b"),
+	}
+	failure := deployments.DeviceDeploymentStatusFailure
+	now := time.Now()
+	for i, dd := range input {
+		created := now.Add(time.Duration(i) * time.Hour)
+		dd.Created = &created
+	}
+	input[0].Status = &failure
+	input[2].Status = &failure
+	input[3].Status = &failure
+
+	after := now.Add(90 * time.Minute)
+
+	testCases := map[string]struct {
+		tenant string
+
+		query   deployments.DeviceDeploymentsQuery
+		outputs []string
+	}{
+		"all": {
+			outputs: []string{"device0004", "device0003", "device0002", "device0001"},
+		},
+		"failing": {
+			query: deployments.DeviceDeploymentsQuery{
+				Status: failure,
+			},
+			outputs: []string{"device0004", "device0003", "device0001"},
+		},
+		"failing, created after": {
+			query: deployments.DeviceDeploymentsQuery{
+				Status:       failure,
+				CreatedAfter: &after,
+			},
+			outputs: []string{"device0004", "device0003"},
+		},
+		"failing, paginated": {
+			query: deployments.DeviceDeploymentsQuery{
+				Status: failure,
+				Skip:   1,
+				Limit:  1,
+			},
+			outputs: []string{"device0003"},
+		},
+		"tenant": {
+			tenant: "acme",
+			query: deployments.DeviceDeploymentsQuery{
+				Status: failure,
+			},
+			outputs: []string{"device0004", "device0003", "device0001"},
+		},
+	}
+
+	for testCaseName, tc := range testCases {
+		t.Run(fmt.Sprintf("test case %s", testCaseName), func(t *testing.T) {
+
+			db.Wipe()
+
+			session := db.Session()
+			defer session.Close()
+			store := NewDeviceDeploymentsStorage(session)
+
+			ctx := context.Background()
+			if tc.tenant != "" {
+				ctx = identity.WithContext(ctx, &identity.Identity{
+					Tenant: tc.tenant,
+				})
+			}
+
+			err := store.InsertMany(ctx, input...)
+			assert.NoError(t, err)
+
+			found, err := store.FindDeviceDeployments(ctx, tc.query)
+			assert.NoError(t, err)
+
+			var devices []string
+			for _, dd := range found {
+				devices = append(devices, *dd.DeviceId)
+			}
+			assert.Equal(t, tc.outputs, devices)
+
+			if tc.tenant != "" {
+				found, err := store.FindDeviceDeployments(context.Background(), tc.query)
+				assert.NoError(t, err)
+				assert.Len(t, found, 0)
+			}
+		})
+	}
+}
+
+func TestRevokeDeviceDeploymentLink(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping RevokeDeviceDeploymentLink in short mode.")
+	}
+
+	db.Wipe()
+
+	session := db.Session()
+	defer session.Close()
+	store := NewDeviceDeploymentsStorage(session)
+
+	ctx := identity.WithContext(context.Background(), &identity.Identity{
+		Tenant: "acme",
+	})
+
+	input := []*deployments.DeviceDeployment{
+		deployments.NewDeviceDeployment("device0001", "30b3e62c-9ec2-4312-a7fa-cff24cc7397a"),
+		deployments.NewDeviceDeployment("device0002", "30b3e62c-9ec2-4312-a7fa-cff24cc7397a"),
+	}
+	assert.NoError(t, store.InsertMany(ctx, input...))
+
+	assertError(t, store.RevokeDeviceDeploymentLink(ctx, "", "30b3e62c-9ec2-4312-a7fa-cff24cc7397a"),
+		ErrStorageInvalidID)
+	assertError(t, store.RevokeDeviceDeploymentLink(ctx, "device0003", "30b3e62c-9ec2-4312-a7fa-cff24cc7397a"),
+		ErrStorageNotFound)
+	assert.NoError(t, store.RevokeDeviceDeploymentLink(ctx, "device0001", "30b3e62c-9ec2-4312-a7fa-cff24cc7397a"))
+
+	revoked, err := store.FindOldestDeploymentForDeviceIDWithStatuses(ctx, "device0001",
+		deployments.ActiveDeploymentStatuses()...)
+	assert.NoError(t, err)
+	if assert.NotNil(t, revoked) {
+		assert.True(t, revoked.LinkRevoked)
+	}
+
+	other, err := store.FindOldestDeploymentForDeviceIDWithStatuses(ctx, "device0002",
+		deployments.ActiveDeploymentStatuses()...)
+	assert.NoError(t, err)
+	if assert.NotNil(t, other) {
+		assert.False(t, other.LinkRevoked)
+	}
+}
+
+func TestRetryDeviceDeployment(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping RetryDeviceDeployment in short mode.")
+	}
+
+	db.Wipe()
+
+	session := db.Session()
+	defer session.Close()
+	store := NewDeviceDeploymentsStorage(session)
+
+	ctx := context.Background()
+	deploymentID := "30b3e62c-9ec2-4312-a7fa-cff24cc7397a"
+
+	failed := deployments.NewDeviceDeployment("device0001", deploymentID)
+	failed.Status = pointers.StringToPointer(deployments.DeviceDeploymentStatusFailure)
+	now := time.Now()
+	failed.Finished = &now
+	failed.Error = &deployments.DeviceDeploymentError{Category: "storage-full"}
+	assert.NoError(t, store.InsertMany(ctx, failed))
+
+	assertError(t, store.RetryDeviceDeployment(ctx, "", deploymentID,
+		deployments.DeviceDeploymentStatusFailure), ErrStorageInvalidID)
+	// not in the expected status
+	assertError(t, store.RetryDeviceDeployment(ctx, "device0001", deploymentID,
+		deployments.DeviceDeploymentStatusAborted), ErrStorageNotFound)
+	assert.NoError(t, store.RetryDeviceDeployment(ctx, "device0001", deploymentID,
+		deployments.DeviceDeploymentStatusFailure))
+
+	retried, err := store.FindLatestDeploymentForDeviceID(ctx, "device0001", deploymentID)
+	assert.NoError(t, err)
+	if assert.NotNil(t, retried) {
+		assert.Equal(t, deployments.DeviceDeploymentStatusPending, *retried.Status)
+		assert.Nil(t, retried.Finished)
+		assert.Nil(t, retried.Error)
+		assert.Equal(t, 1, retried.Retries)
+	}
+
+	// moved to a partition
+	old := deployments.NewDeviceDeployment("device0002", deploymentID)
+	old.Status = pointers.StringToPointer(deployments.DeviceDeploymentStatusFailure)
+	finished := time.Date(2019, time.January, 10, 0, 0, 0, 0, time.UTC)
+	old.Created = &finished
+	old.Finished = &finished
+	old.Error = &deployments.DeviceDeploymentError{Category: "storage-full"}
+	assert.NoError(t, store.InsertMany(ctx, old))
+	moved, err := store.PartitionDeviceDeployments(ctx, finished.Add(time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, moved)
+
+	assert.NoError(t, store.RetryDeviceDeployment(ctx, "device0002", deploymentID,
+		deployments.DeviceDeploymentStatusFailure))
+	// moved back already
+	assertError(t, store.RetryDeviceDeployment(ctx, "device0002", deploymentID,
+		deployments.DeviceDeploymentStatusFailure), ErrStorageNotFound)
+
+	var active deployments.DeviceDeployment
+	assert.NoError(t, session.DB(DatabaseName).C(CollectionDevices).FindId(*old.Id).One(&active))
+	assert.Equal(t, deployments.DeviceDeploymentStatusPending, *active.Status)
+	assert.Nil(t, active.Finished)
+	assert.Nil(t, active.Error)
+	assert.Equal(t, 1, active.Retries)
+
+	n, err := session.DB(DatabaseName).C(DevicesPartition(finished)).Count()
+	assert.NoError(t, err)
+	assert.Equal(t, 0, n)
+
+	count, err := store.CountDeviceDeployments(ctx, deploymentID, "")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, count)
+}
+
 func TestHasDeploymentForDevice(t *testing.T) {
 	if testing.Short() {
 		t.Skip("skipping GetDeviceStatusesForDeployment in short mode.")
@@ -732,6 +1369,78 @@ func TestHasDeploymentForDevice(t *testing.T) {
 	}
 }
 
+func TestFindLatestDeploymentForDeviceID(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping FindLatestDeploymentForDeviceID in short mode.")
+	}
+
+	now := time.Now()
+	input := []*deployments.DeviceDeployment{
+		deployments.NewDeviceDeployment("device0001", "30b3e62c-9ec2-4312-a7fa-cff24cc7397a"),
+		deployments.NewDeviceDeployment("device0001", "30b3e62c-9ec2-4312-a7fa-cff24cc7397b"),
+		deployments.NewDeviceDeployment("device0001", "30b3e62c-9ec2-4312-a7fa-cff24cc7397c"),
+		deployments.NewDeviceDeployment("device0002", "30b3e62c-9ec2-4312-a7fa-cff24cc7397c"),
+	}
+	for i, dd := range input {
+		created := now.Add(time.Duration(i) * time.Minute)
+		dd.Created = &created
+	}
+
+	testCases := []struct {
+		deviceID      string
+		deploymentIDs []string
+
+		deploymentID string
+	}{
+		{
+			deviceID:     "device0001",
+			deploymentID: "30b3e62c-9ec2-4312-a7fa-cff24cc7397c",
+		},
+		{
+			deviceID: "device0001",
+			deploymentIDs: []string{
+				"30b3e62c-9ec2-4312-a7fa-cff24cc7397a",
+				"30b3e62c-9ec2-4312-a7fa-cff24cc7397b",
+			},
+			deploymentID: "30b3e62c-9ec2-4312-a7fa-cff24cc7397b",
+		},
+		{
+			deviceID:      "device0002",
+			deploymentIDs: []string{"30b3e62c-9ec2-4312-a7fa-cff24cc7397a"},
+		},
+		{
+			deviceID: "device0003",
+		},
+	}
+
+	for testCaseNumber, tc := range testCases {
+		t.Run(fmt.Sprintf("test case %d", testCaseNumber+1), func(t *testing.T) {
+
+			db.Wipe()
+
+			session := db.Session()
+			store := NewDeviceDeploymentsStorage(session)
+
+			err := store.InsertMany(context.Background(), input...)
+			assert.NoError(t, err)
+
+			dd, err := store.FindLatestDeploymentForDeviceID(context.Background(),
+				tc.deviceID, tc.deploymentIDs...)
+			assert.NoError(t, err)
+
+			if tc.deploymentID == "" {
+				assert.Nil(t, dd)
+			} else {
+				assert.NotNil(t, dd)
+				assert.Equal(t, tc.deploymentID, *dd.DeploymentId)
+				assert.Equal(t, tc.deviceID, *dd.DeviceId)
+			}
+
+			session.Close()
+		})
+	}
+}
+
 func TestGetDeviceDeploymentStatus(t *testing.T) {
 	if testing.Short() {
 		t.Skip("skipping GetDeviceDeploymentStatus in short mode.")
@@ -851,10 +1560,15 @@ func TestAbortDeviceDeployments(t *testing.T) {
 			err := store.InsertMany(context.Background(), testCase.InputDeviceDeployment...)
 			assert.NoError(t, err)
 
-			err = store.AbortDeviceDeployments(context.Background(), testCase.InputDeploymentID)
+			abort := &deployments.AbortInfo{
+				Reason:    "bad release",
+				AbortedBy: "user-1",
+			}
+			err = store.AbortDeviceDeployments(context.Background(), testCase.InputDeploymentID,
+				abort)
 
 			if testCase.OutputError != nil {
-				assert.EqualError(t, err, testCase.OutputError.Error())
+				assertError(t, err, testCase.OutputError)
 			} else {
 				assert.NoError(t, err)
 			}
@@ -879,6 +1593,7 @@ func TestAbortDeviceDeployments(t *testing.T) {
 					for _, deployment := range deploymentList {
 						assert.Equal(t, deployments.DeviceDeploymentStatusAborted,
 							*deployment.Status)
+						assert.Equal(t, abort, deployment.Abort)
 					}
 				}
 			}
@@ -889,6 +1604,57 @@ func TestAbortDeviceDeployments(t *testing.T) {
 	}
 }
 
+func TestFinishDeviceDeployments(t *testing.T) {
+
+	if testing.Short() {
+		t.Skip("skipping TestFinishDeviceDeployments in short mode.")
+	}
+
+	// Make sure we start test with empty database
+	db.Wipe()
+
+	session := db.Session()
+	defer session.Close()
+	store := NewDeviceDeploymentsStorage(session)
+
+	ctx := context.Background()
+	deploymentID := "30b3e62c-9ec2-4312-a7fa-cff24cc7397a"
+
+	pending := deployments.NewDeviceDeployment("456", deploymentID)
+	downloading := deployments.NewDeviceDeployment("567", deploymentID)
+	downloading.Status = pointers.StringToPointer(deployments.DeviceDeploymentStatusDownloading)
+	success := deployments.NewDeviceDeployment("678", deploymentID)
+	success.Status = pointers.StringToPointer(deployments.DeviceDeploymentStatusSuccess)
+	other := deployments.NewDeviceDeployment("456", "30b3e62c-9ec2-4312-a7fa-cff24cc7397b")
+	assert.NoError(t, store.InsertMany(ctx, pending, downloading, success, other))
+
+	err := store.FinishDeviceDeployments(ctx, "",
+		deployments.DeviceDeploymentStatusTimedOut, time.Now())
+	assertError(t, err, ErrStorageInvalidID)
+
+	finished := time.Now().UTC().Round(time.Millisecond)
+	assert.NoError(t, store.FinishDeviceDeployments(ctx, deploymentID,
+		deployments.DeviceDeploymentStatusTimedOut, finished))
+
+	var deviceDeployments []deployments.DeviceDeployment
+	assert.NoError(t, session.DB(DatabaseName).C(CollectionDevices).
+		Find(nil).All(&deviceDeployments))
+	statuses := map[string]string{}
+	for _, deviceDeployment := range deviceDeployments {
+		statuses[*deviceDeployment.DeploymentId+"/"+*deviceDeployment.DeviceId] =
+			*deviceDeployment.Status
+		if *deviceDeployment.Status == deployments.DeviceDeploymentStatusTimedOut {
+			assert.Equal(t, finished, deviceDeployment.Finished.UTC())
+		}
+	}
+	assert.Equal(t, map[string]string{
+		deploymentID + "/456":                      deployments.DeviceDeploymentStatusTimedOut,
+		deploymentID + "/567":                      deployments.DeviceDeploymentStatusTimedOut,
+		deploymentID + "/678":                      deployments.DeviceDeploymentStatusSuccess,
+		"30b3e62c-9ec2-4312-a7fa-cff24cc7397b/456": deployments.DeviceDeploymentStatusPending,
+	}, statuses)
+}
+
 func TestDecommissionDeviceDeployments(t *testing.T) {
 
 	if testing.Short() {
@@ -929,7 +1695,7 @@ func TestDecommissionDeviceDeployments(t *testing.T) {
 			err = store.DecommissionDeviceDeployments(context.Background(), testCase.InputDeviceId)
 
 			if testCase.OutputError != nil {
-				assert.EqualError(t, err, testCase.OutputError.Error())
+				assertError(t, err, testCase.OutputError)
 			} else {
 				assert.NoError(t, err)
 			}
@@ -963,3 +1729,207 @@ func TestDecommissionDeviceDeployments(t *testing.T) {
 		})
 	}
 }
+
+func TestAnonymizeDeviceDeployments(t *testing.T) {
+
+	if testing.Short() {
+		t.Skip("skipping TestAnonymizeDeviceDeployments in short mode.")
+	}
+
+	// Make sure we start test with empty database
+	db.Wipe()
+
+	session := db.Session()
+	defer session.Close()
+	store := NewDeviceDeploymentsStorage(session)
+
+	ctx := context.Background()
+
+	failed := deployments.NewDeviceDeployment("foo", "30b3e62c-9ec2-4312-a7fa-cff24cc7397a")
+	failed.Status = pointers.StringToPointer(deployments.DeviceDeploymentStatusFailure)
+	failed.SubState = pointers.StringToPointer("ArtifactInstall")
+	failed.Error = &deployments.DeviceDeploymentError{Category: "no space left on device"}
+	failed.IsLogAvailable = true
+	failed.Inventory = map[string]string{"mac": "00:11:22:33:44:55"}
+	other := deployments.NewDeviceDeployment("bar", "30b3e62c-9ec2-4312-a7fa-cff24cc7397a")
+	other.Inventory = map[string]string{"mac": "00:11:22:33:44:66"}
+
+	assert.NoError(t, store.InsertMany(ctx, failed, other))
+
+	_, err := store.AnonymizeDeviceDeployments(ctx, "", "anonymous")
+	assertError(t, err, ErrStorageInvalidID)
+
+	count, err := store.AnonymizeDeviceDeployments(ctx, "foo", "anonymous")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, count)
+
+	found, err := store.FindAllDeploymentsForDeviceIDWithStatuses(ctx, "foo",
+		deployments.DeviceDeploymentStatusFailure)
+	assert.NoError(t, err)
+	assert.Len(t, found, 0)
+
+	found, err = store.FindAllDeploymentsForDeviceIDWithStatuses(ctx, "anonymous",
+		deployments.DeviceDeploymentStatusFailure)
+	assert.NoError(t, err)
+	if assert.Len(t, found, 1) {
+		assert.Equal(t, *failed.Id, *found[0].Id)
+		assert.Nil(t, found[0].SubState)
+		assert.Nil(t, found[0].Error)
+		assert.False(t, found[0].IsLogAvailable)
+		assert.Nil(t, found[0].Inventory)
+	}
+
+	// other devices are untouched
+	found, err = store.FindAllDeploymentsForDeviceIDWithStatuses(ctx, "bar",
+		deployments.DeviceDeploymentStatusPending)
+	assert.NoError(t, err)
+	if assert.Len(t, found, 1) {
+		assert.Equal(t, other.Inventory, found[0].Inventory)
+	}
+
+	count, err = store.AnonymizeDeviceDeployments(ctx, "foo", "anonymous")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, count)
+}
+
+func TestSupersedeDeviceDeployments(t *testing.T) {
+
+	if testing.Short() {
+		t.Skip("skipping TestSupersedeDeviceDeployments in short mode.")
+	}
+
+	db.Wipe()
+	session := db.Session()
+	defer session.Close()
+
+	store := NewDeviceDeploymentsStorage(session)
+	ctx := context.Background()
+
+	older := "30b3e62c-9ec2-4312-a7fa-cff24cc7397a"
+	other := "ee13ea8b-a6d3-4d4c-99a6-bcfcaebc7ec3"
+	newer := "b1bb0ba1-2a2d-4c5e-a5b0-6c0f5e4b3b22"
+
+	err := store.InsertMany(ctx,
+		deployments.NewDeviceDeployment("device-1", older),
+		newDeviceDeploymentWithStatus("device-2", older,
+			deployments.DeviceDeploymentStatusDownloading),
+		deployments.NewDeviceDeployment("device-3", other),
+		deployments.NewDeviceDeployment("device-1", newer),
+		deployments.NewDeviceDeployment("device-2", newer),
+	)
+	assert.NoError(t, err)
+
+	_, err = store.SupersedeDeviceDeployments(ctx, "", []string{"device-1"})
+	assertError(t, err, ErrStorageInvalidID)
+
+	affected, err := store.SupersedeDeviceDeployments(ctx, newer,
+		[]string{"device-1", "device-2"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{older}, affected)
+
+	var deviceDeployments []deployments.DeviceDeployment
+	err = session.DB(DatabaseName).C(CollectionDevices).Find(nil).All(&deviceDeployments)
+	assert.NoError(t, err)
+
+	for _, dd := range deviceDeployments {
+		switch {
+		case *dd.DeploymentId == older && *dd.DeviceId == "device-1":
+			assert.Equal(t, deployments.DeviceDeploymentStatusSuperseded, *dd.Status)
+			assert.Equal(t, newer, *dd.SupersededBy)
+		case *dd.DeploymentId == older:
+			// only pending device deployments are superseded
+			assert.Equal(t, deployments.DeviceDeploymentStatusDownloading, *dd.Status)
+		default:
+			assert.Equal(t, deployments.DeviceDeploymentStatusPending, *dd.Status)
+			assert.Nil(t, dd.SupersededBy)
+		}
+	}
+}
+
+func TestFindDeviceIDsWithStatuses(t *testing.T) {
+
+	if testing.Short() {
+		t.Skip("skipping TestFindDeviceIDsWithStatuses in short mode.")
+	}
+
+	db.Wipe()
+	session := db.Session()
+	defer session.Close()
+
+	store := NewDeviceDeploymentsStorage(session)
+	ctx := context.Background()
+
+	deploymentID := "30b3e62c-9ec2-4312-a7fa-cff24cc7397a"
+	err := store.InsertMany(ctx,
+		deployments.NewDeviceDeployment("device-1", deploymentID),
+		newDeviceDeploymentWithStatus("device-2", deploymentID,
+			deployments.DeviceDeploymentStatusInstalling),
+		newDeviceDeploymentWithStatus("device-3", deploymentID,
+			deployments.DeviceDeploymentStatusSuccess),
+		deployments.NewDeviceDeployment("device-4", deploymentID),
+	)
+	assert.NoError(t, err)
+
+	ids, err := store.FindDeviceIDsWithStatuses(ctx,
+		[]string{"device-1", "device-2", "device-3", "device-5"},
+		deployments.ActiveDeploymentStatuses()...)
+	assert.NoError(t, err)
+	sort.Strings(ids)
+	assert.Equal(t, []string{"device-1", "device-2"}, ids)
+
+	ids, err = store.FindDeviceIDsWithStatuses(ctx, nil,
+		deployments.ActiveDeploymentStatuses()...)
+	assert.NoError(t, err)
+	assert.Empty(t, ids)
+}
+
+func TestFindDeploymentsForDeviceIDsWithStatuses(t *testing.T) {
+
+	if testing.Short() {
+		t.Skip("skipping TestFindDeploymentsForDeviceIDsWithStatuses in short mode.")
+	}
+
+	db.Wipe()
+	session := db.Session()
+	defer session.Close()
+
+	store := NewDeviceDeploymentsStorage(session)
+	ctx := context.Background()
+
+	deploymentID := "30b3e62c-9ec2-4312-a7fa-cff24cc7397a"
+	otherID := "f826484e-1157-4109-af21-304e6d711560"
+	created := time.Date(2018, 1, 2, 3, 4, 5, 0, time.UTC)
+	createdAt := func(d *deployments.DeviceDeployment, minutes int) *deployments.DeviceDeployment {
+		when := created.Add(time.Duration(minutes) * time.Minute)
+		d.Created = &when
+		return d
+	}
+	err := store.InsertMany(ctx,
+		createdAt(deployments.NewDeviceDeployment("device-1", otherID), 2),
+		createdAt(deployments.NewDeviceDeployment("device-1", deploymentID), 0),
+		createdAt(newDeviceDeploymentWithStatus("device-2", deploymentID,
+			deployments.DeviceDeploymentStatusInstalling), 1),
+		createdAt(newDeviceDeploymentWithStatus("device-3", deploymentID,
+			deployments.DeviceDeploymentStatusSuccess), 0),
+		createdAt(deployments.NewDeviceDeployment("device-4", deploymentID), 0),
+	)
+	assert.NoError(t, err)
+
+	list, err := store.FindDeploymentsForDeviceIDsWithStatuses(ctx,
+		[]string{"device-1", "device-2", "device-3", "device-5"},
+		deployments.ActiveDeploymentStatuses()...)
+	assert.NoError(t, err)
+	if assert.Len(t, list, 3) {
+		// oldest first
+		assert.Equal(t, "device-1", *list[0].DeviceId)
+		assert.Equal(t, deploymentID, *list[0].DeploymentId)
+		assert.Equal(t, "device-2", *list[1].DeviceId)
+		assert.Equal(t, "device-1", *list[2].DeviceId)
+		assert.Equal(t, otherID, *list[2].DeploymentId)
+	}
+
+	list, err = store.FindDeploymentsForDeviceIDsWithStatuses(ctx, nil,
+		deployments.ActiveDeploymentStatuses()...)
+	assert.NoError(t, err)
+	assert.Empty(t, list)
+}
@@ -0,0 +1,100 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package mongo
+
+import (
+	"context"
+
+	"github.com/asaskevich/govalidator"
+	"github.com/globalsign/mgo"
+	"github.com/mendersoftware/go-lib-micro/store"
+
+	"github.com/mendersoftware/deployments/resources/deployments"
+)
+
+// Database settings
+const (
+	CollectionDeviceTypeAliases = "device_type_aliases"
+)
+
+// DeviceTypeAliasesStorage is a data layer for device type aliases based on MongoDB
+type DeviceTypeAliasesStorage struct {
+	session *mgo.Session
+}
+
+func NewDeviceTypeAliasesStorage(session *mgo.Session) *DeviceTypeAliasesStorage {
+	return &DeviceTypeAliasesStorage{
+		session: session,
+	}
+}
+
+func (s *DeviceTypeAliasesStorage) UpsertDeviceTypeAlias(ctx context.Context,
+	alias *deployments.DeviceTypeAlias) error {
+
+	if alias == nil {
+		return deployments.NewStoreError("UpsertDeviceTypeAlias", CollectionDeviceTypeAliases,
+			ErrStorageInvalidInput)
+	}
+
+	if err := alias.Validate(); err != nil {
+		return err
+	}
+
+	session := s.session.Copy()
+	defer session.Close()
+
+	_, err := session.DB(store.DbFromContext(ctx, DatabaseName)).
+		C(CollectionDeviceTypeAliases).UpsertId(alias.Alias, alias)
+	return err
+}
+
+// FindDeviceTypeAliases returns all aliases sorted by alias.
+func (s *DeviceTypeAliasesStorage) FindDeviceTypeAliases(ctx context.Context) (
+	[]*deployments.DeviceTypeAlias, error) {
+
+	session := s.session.Copy()
+	defer session.Close()
+
+	var aliases []*deployments.DeviceTypeAlias
+	if err := session.DB(store.DbFromContext(ctx, DatabaseName)).
+		C(CollectionDeviceTypeAliases).Find(nil).
+		Sort("_id").All(&aliases); err != nil {
+		return nil, err
+	}
+
+	return aliases, nil
+}
+
+func (s *DeviceTypeAliasesStorage) DeleteDeviceTypeAlias(ctx context.Context,
+	alias string) error {
+
+	if govalidator.IsNull(alias) {
+		return deployments.NewStoreError("DeleteDeviceTypeAlias", CollectionDeviceTypeAliases,
+			ErrStorageInvalidID, alias)
+	}
+
+	session := s.session.Copy()
+	defer session.Close()
+
+	if err := session.DB(store.DbFromContext(ctx, DatabaseName)).
+		C(CollectionDeviceTypeAliases).RemoveId(alias); err != nil {
+		if err == mgo.ErrNotFound {
+			return nil
+		}
+		return err
+	}
+
+	return nil
+}
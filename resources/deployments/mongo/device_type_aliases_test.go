@@ -0,0 +1,77 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package mongo_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/mendersoftware/go-lib-micro/identity"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mendersoftware/deployments/resources/deployments"
+	. "github.com/mendersoftware/deployments/resources/deployments/mongo"
+)
+
+func TestDeviceTypeAliasesStorage(t *testing.T) {
+
+	if testing.Short() {
+		t.Skip("skipping TestDeviceTypeAliasesStorage in short mode.")
+	}
+
+	// Make sure we start test with empty database
+	db.Wipe()
+
+	session := db.Session()
+	store := NewDeviceTypeAliasesStorage(session)
+
+	ctx := identity.WithContext(context.Background(), &identity.Identity{
+		Tenant: "acme",
+	})
+
+	assert.Error(t, store.UpsertDeviceTypeAlias(ctx, &deployments.DeviceTypeAlias{}))
+	assert.NoError(t, store.UpsertDeviceTypeAlias(ctx, &deployments.DeviceTypeAlias{
+		Alias: "rpi4", DeviceType: "raspberrypi",
+	}))
+	assert.NoError(t, store.UpsertDeviceTypeAlias(ctx, &deployments.DeviceTypeAlias{
+		Alias: "bbb", DeviceType: "beaglebone",
+	}))
+	// device type of existing alias is replaced
+	assert.NoError(t, store.UpsertDeviceTypeAlias(ctx, &deployments.DeviceTypeAlias{
+		Alias: "rpi4", DeviceType: "raspberrypi4",
+	}))
+
+	aliases, err := store.FindDeviceTypeAliases(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, []*deployments.DeviceTypeAlias{
+		{Alias: "bbb", DeviceType: "beaglebone"},
+		{Alias: "rpi4", DeviceType: "raspberrypi4"},
+	}, aliases)
+
+	// aliases are stored per tenant
+	aliases, err = store.FindDeviceTypeAliases(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, aliases, 0)
+
+	assert.Error(t, store.DeleteDeviceTypeAlias(ctx, ""))
+	assert.NoError(t, store.DeleteDeviceTypeAlias(ctx, "bbb"))
+	assert.NoError(t, store.DeleteDeviceTypeAlias(ctx, "bbb"))
+
+	aliases, err = store.FindDeviceTypeAliases(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, []*deployments.DeviceTypeAlias{
+		{Alias: "rpi4", DeviceType: "raspberrypi4"},
+	}, aliases)
+}
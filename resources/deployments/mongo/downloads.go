@@ -0,0 +1,162 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package mongo
+
+import (
+	"context"
+
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+	"github.com/mendersoftware/go-lib-micro/store"
+
+	"github.com/mendersoftware/deployments/resources/deployments"
+)
+
+// Database settings
+const (
+	CollectionDownloads = "downloads"
+)
+
+// Database keys
+const (
+	StorageKeyDownloadArtifactID   = "artifact_id"
+	StorageKeyDownloadDeploymentID = "deployment_id"
+	StorageKeyDownloadDeviceID     = "device_id"
+	StorageKeyDownloadCreated      = "created"
+)
+
+// Indexes
+const (
+	IndexDownloadsArtifactStr = "downloadsArtifactIndex"
+	IndexDownloadsDeviceStr   = "downloadsDeviceIndex"
+)
+
+// DownloadsStorage is a data layer for artifact downloads audit based on MongoDB
+type DownloadsStorage struct {
+	session *mgo.Session
+}
+
+func NewDownloadsStorage(session *mgo.Session) *DownloadsStorage {
+	return &DownloadsStorage{
+		session: session,
+	}
+}
+
+// Downloads are looked up by artifact or device, newest first.
+func (d *DownloadsStorage) ensureIndexing(ctx context.Context, session *mgo.Session) error {
+	for _, index := range []mgo.Index{
+		{
+			Key:        []string{StorageKeyDownloadArtifactID, "-" + StorageKeyDownloadCreated},
+			Name:       IndexDownloadsArtifactStr,
+			Background: true,
+		},
+		{
+			Key:        []string{StorageKeyDownloadDeviceID, "-" + StorageKeyDownloadCreated},
+			Name:       IndexDownloadsDeviceStr,
+			Background: true,
+		},
+	} {
+		if err := session.DB(store.DbFromContext(ctx, DatabaseName)).
+			C(CollectionDownloads).EnsureIndex(index); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (d *DownloadsStorage) InsertDownload(ctx context.Context,
+	download *deployments.Download) error {
+
+	if download == nil {
+		return deployments.NewStoreError("InsertDownload", CollectionDownloads,
+			ErrStorageInvalidInput)
+	}
+
+	if err := download.Validate(); err != nil {
+		return err
+	}
+
+	session := d.session.Copy()
+	defer session.Close()
+
+	if err := d.ensureIndexing(ctx, session); err != nil {
+		return err
+	}
+
+	return session.DB(store.DbFromContext(ctx, DatabaseName)).
+		C(CollectionDownloads).Insert(download)
+}
+
+// FindDownloads returns downloads matching the query, newest first.
+func (d *DownloadsStorage) FindDownloads(ctx context.Context,
+	match deployments.DownloadsQuery) ([]deployments.Download, error) {
+
+	session := d.session.Copy()
+	defer session.Close()
+
+	query := bson.M{}
+	for key, value := range map[string]string{
+		StorageKeyDownloadArtifactID:   match.ArtifactID,
+		StorageKeyDownloadDeploymentID: match.DeploymentID,
+		StorageKeyDownloadDeviceID:     match.DeviceID,
+	} {
+		if value != "" {
+			query[key] = value
+		}
+	}
+
+	created := bson.M{}
+	if match.CreatedAfter != nil {
+		created["$gte"] = match.CreatedAfter
+	}
+	if match.CreatedBefore != nil {
+		created["$lte"] = match.CreatedBefore
+	}
+	if len(created) > 0 {
+		query[StorageKeyDownloadCreated] = created
+	}
+
+	var downloads []deployments.Download
+	err := session.DB(store.DbFromContext(ctx, DatabaseName)).
+		C(CollectionDownloads).
+		Find(query).Sort("-" + StorageKeyDownloadCreated).
+		Skip(match.Skip).Limit(match.Limit).
+		All(&downloads)
+	if err != nil {
+		return nil, err
+	}
+
+	return downloads, nil
+}
+
+// DeleteDeviceDownloads removes downloads audit records of the device.
+// Returns number of removed records.
+func (d *DownloadsStorage) DeleteDeviceDownloads(ctx context.Context,
+	deviceID string) (int, error) {
+
+	session := d.session.Copy()
+	defer session.Close()
+
+	info, err := session.DB(store.DbFromContext(ctx, DatabaseName)).
+		C(CollectionDownloads).RemoveAll(bson.M{
+		StorageKeyDownloadDeviceID: deviceID,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return info.Removed, nil
+}
@@ -0,0 +1,109 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package mongo_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/mendersoftware/go-lib-micro/identity"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mendersoftware/deployments/resources/deployments"
+	. "github.com/mendersoftware/deployments/resources/deployments/mongo"
+)
+
+func TestDownloadsStorage(t *testing.T) {
+
+	if testing.Short() {
+		t.Skip("skipping TestDownloadsStorage in short mode.")
+	}
+
+	// Make sure we start test with empty database
+	db.Wipe()
+
+	session := db.Session()
+	defer session.Close()
+	store := NewDownloadsStorage(session)
+
+	ctx := identity.WithContext(context.Background(), &identity.Identity{
+		Tenant: "acme",
+	})
+
+	device := deployments.NewDownload("d50eda0d-2cea-4de1-8d42-9cd3e7e8670d")
+	device.DeviceID = "device-1"
+	device.DeploymentID = "e8c32ff6-7c1b-43c7-aa31-2e4fc3a3c130"
+	device.Created = parseTime(t, "2018-12-20T00:00:00Z")
+
+	user := deployments.NewDownload("d50eda0d-2cea-4de1-8d42-9cd3e7e8670d")
+	user.UserID = "user-1"
+	user.Created = parseTime(t, "2018-12-21T00:00:00Z")
+
+	other := deployments.NewDownload("a50eda0d-2cea-4de1-8d42-9cd3e7e8670d")
+	other.DeviceID = "device-1"
+	other.Created = parseTime(t, "2018-12-22T00:00:00Z")
+
+	assert.Error(t, store.InsertDownload(ctx, nil))
+	assert.Error(t, store.InsertDownload(ctx, &deployments.Download{}))
+	for _, download := range []*deployments.Download{device, user, other} {
+		assert.NoError(t, store.InsertDownload(ctx, download))
+	}
+
+	ids := func(downloads []deployments.Download) []string {
+		var ids []string
+		for _, d := range downloads {
+			ids = append(ids, d.Id)
+		}
+		return ids
+	}
+
+	// newest first
+	downloads, err := store.FindDownloads(ctx, deployments.DownloadsQuery{})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{other.Id, user.Id, device.Id}, ids(downloads))
+
+	downloads, err = store.FindDownloads(ctx, deployments.DownloadsQuery{
+		ArtifactID: "d50eda0d-2cea-4de1-8d42-9cd3e7e8670d",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{user.Id, device.Id}, ids(downloads))
+
+	downloads, err = store.FindDownloads(ctx, deployments.DownloadsQuery{
+		DeviceID:      "device-1",
+		CreatedBefore: parseTime(t, "2018-12-21T00:00:00Z"),
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{device.Id}, ids(downloads))
+
+	downloads, err = store.FindDownloads(ctx, deployments.DownloadsQuery{
+		Skip:  1,
+		Limit: 1,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{user.Id}, ids(downloads))
+
+	// downloads are stored per tenant
+	downloads, err = store.FindDownloads(context.Background(), deployments.DownloadsQuery{})
+	assert.NoError(t, err)
+	assert.Len(t, downloads, 0)
+
+	count, err := store.DeleteDeviceDownloads(ctx, "device-1")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, count)
+
+	downloads, err = store.FindDownloads(ctx, deployments.DownloadsQuery{})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{user.Id}, ids(downloads))
+}
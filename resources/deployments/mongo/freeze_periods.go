@@ -0,0 +1,131 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package mongo
+
+import (
+	"context"
+	"time"
+
+	"github.com/asaskevich/govalidator"
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+	"github.com/mendersoftware/go-lib-micro/store"
+
+	"github.com/mendersoftware/deployments/resources/deployments"
+)
+
+// Database settings
+const (
+	CollectionFreezePeriods = "freeze_periods"
+)
+
+// Database keys
+const (
+	StorageKeyFreezePeriodStart = "freezeperiodconstructor.start"
+	StorageKeyFreezePeriodEnd   = "freezeperiodconstructor.end"
+)
+
+// FreezePeriodsStorage is a data layer for deployment freeze periods based on MongoDB
+type FreezePeriodsStorage struct {
+	session *mgo.Session
+}
+
+func NewFreezePeriodsStorage(session *mgo.Session) *FreezePeriodsStorage {
+	return &FreezePeriodsStorage{
+		session: session,
+	}
+}
+
+func (f *FreezePeriodsStorage) InsertFreezePeriod(ctx context.Context,
+	period *deployments.FreezePeriod) error {
+
+	if period == nil || period.FreezePeriodConstructor == nil {
+		return deployments.NewStoreError("InsertFreezePeriod", CollectionFreezePeriods,
+			ErrStorageInvalidInput)
+	}
+
+	if err := period.Validate(); err != nil {
+		return err
+	}
+
+	session := f.session.Copy()
+	defer session.Close()
+
+	return session.DB(store.DbFromContext(ctx, DatabaseName)).
+		C(CollectionFreezePeriods).Insert(period)
+}
+
+// FindFreezePeriods returns all freeze periods sorted by start time.
+func (f *FreezePeriodsStorage) FindFreezePeriods(ctx context.Context) ([]*deployments.FreezePeriod, error) {
+
+	session := f.session.Copy()
+	defer session.Close()
+
+	var periods []*deployments.FreezePeriod
+	if err := session.DB(store.DbFromContext(ctx, DatabaseName)).
+		C(CollectionFreezePeriods).Find(nil).
+		Sort(StorageKeyFreezePeriodStart).All(&periods); err != nil {
+		return nil, err
+	}
+
+	return periods, nil
+}
+
+// FindActiveFreezePeriod returns the freeze period covering given moment,
+// the one ending last if there are many.
+func (f *FreezePeriodsStorage) FindActiveFreezePeriod(ctx context.Context,
+	at time.Time) (*deployments.FreezePeriod, error) {
+
+	session := f.session.Copy()
+	defer session.Close()
+
+	query := bson.M{
+		StorageKeyFreezePeriodStart: bson.M{"$lte": at},
+		StorageKeyFreezePeriodEnd:   bson.M{"$gt": at},
+	}
+
+	var period deployments.FreezePeriod
+	if err := session.DB(store.DbFromContext(ctx, DatabaseName)).
+		C(CollectionFreezePeriods).Find(query).
+		Sort("-" + StorageKeyFreezePeriodEnd).One(&period); err != nil {
+		if err == mgo.ErrNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &period, nil
+}
+
+func (f *FreezePeriodsStorage) DeleteFreezePeriod(ctx context.Context, id string) error {
+
+	if govalidator.IsNull(id) {
+		return deployments.NewStoreError("DeleteFreezePeriod", CollectionFreezePeriods,
+			ErrStorageInvalidID, id)
+	}
+
+	session := f.session.Copy()
+	defer session.Close()
+
+	if err := session.DB(store.DbFromContext(ctx, DatabaseName)).
+		C(CollectionFreezePeriods).RemoveId(id); err != nil {
+		if err == mgo.ErrNotFound {
+			return nil
+		}
+		return err
+	}
+
+	return nil
+}
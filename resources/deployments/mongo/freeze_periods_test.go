@@ -0,0 +1,96 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package mongo_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/mendersoftware/go-lib-micro/identity"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mendersoftware/deployments/resources/deployments"
+	. "github.com/mendersoftware/deployments/resources/deployments/mongo"
+)
+
+func TestFreezePeriodsStorage(t *testing.T) {
+
+	if testing.Short() {
+		t.Skip("skipping TestFreezePeriodsStorage in short mode.")
+	}
+
+	// Make sure we start test with empty database
+	db.Wipe()
+
+	session := db.Session()
+	store := NewFreezePeriodsStorage(session)
+
+	ctx := identity.WithContext(context.Background(), &identity.Identity{
+		Tenant: "acme",
+	})
+
+	holidays := deployments.NewFreezePeriodFromConstructor(
+		&deployments.FreezePeriodConstructor{
+			Name:  "holidays",
+			Start: parseTime(t, "2018-12-20T00:00:00Z"),
+			End:   parseTime(t, "2019-01-02T00:00:00Z"),
+		})
+	newYear := deployments.NewFreezePeriodFromConstructor(
+		&deployments.FreezePeriodConstructor{
+			Name:  "new year",
+			Start: parseTime(t, "2018-12-31T00:00:00Z"),
+			End:   parseTime(t, "2019-01-07T00:00:00Z"),
+		})
+
+	assert.Error(t, store.InsertFreezePeriod(ctx, &deployments.FreezePeriod{}))
+	assert.NoError(t, store.InsertFreezePeriod(ctx, newYear))
+	assert.NoError(t, store.InsertFreezePeriod(ctx, holidays))
+
+	periods, err := store.FindFreezePeriods(ctx)
+	assert.NoError(t, err)
+	if assert.Len(t, periods, 2) {
+		assert.Equal(t, holidays.Id, periods[0].Id)
+		assert.Equal(t, newYear.Id, periods[1].Id)
+	}
+
+	// periods are stored per tenant
+	periods, err = store.FindFreezePeriods(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, periods, 0)
+
+	active, err := store.FindActiveFreezePeriod(ctx, *parseTime(t, "2018-12-24T12:00:00Z"))
+	assert.NoError(t, err)
+	if assert.NotNil(t, active) {
+		assert.Equal(t, holidays.Id, active.Id)
+	}
+
+	// overlapping periods, the one ending last is returned
+	active, err = store.FindActiveFreezePeriod(ctx, *parseTime(t, "2019-01-01T12:00:00Z"))
+	assert.NoError(t, err)
+	if assert.NotNil(t, active) {
+		assert.Equal(t, newYear.Id, active.Id)
+	}
+
+	active, err = store.FindActiveFreezePeriod(ctx, *parseTime(t, "2019-01-07T00:00:00Z"))
+	assert.NoError(t, err)
+	assert.Nil(t, active)
+
+	assert.NoError(t, store.DeleteFreezePeriod(ctx, newYear.Id))
+	assert.NoError(t, store.DeleteFreezePeriod(ctx, newYear.Id))
+
+	periods, err = store.FindFreezePeriods(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, periods, 1)
+}
@@ -0,0 +1,156 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package mongo
+
+import (
+	"context"
+	"regexp"
+
+	"github.com/asaskevich/govalidator"
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+	"github.com/mendersoftware/go-lib-micro/store"
+
+	"github.com/mendersoftware/deployments/resources/deployments"
+)
+
+// Database settings
+const (
+	CollectionInstalledArtifacts = "installed_artifacts"
+)
+
+// Database keys
+const (
+	StorageKeyInstalledArtifactName = "artifact_name"
+)
+
+// Indexes
+const (
+	IndexInstalledArtifactNameStr = "installedArtifactNameIndex"
+)
+
+// InstalledArtifactsStorage is a data layer for artifacts installed on
+// devices based on MongoDB
+type InstalledArtifactsStorage struct {
+	session *mgo.Session
+}
+
+func NewInstalledArtifactsStorage(session *mgo.Session) *InstalledArtifactsStorage {
+	return &InstalledArtifactsStorage{
+		session: session,
+	}
+}
+
+// Devices are looked up by artifact name or its prefix.
+func (s *InstalledArtifactsStorage) ensureIndexing(ctx context.Context,
+	session *mgo.Session) error {
+
+	return session.DB(store.DbFromContext(ctx, DatabaseName)).
+		C(CollectionInstalledArtifacts).EnsureIndex(mgo.Index{
+		Key:        []string{StorageKeyInstalledArtifactName},
+		Name:       IndexInstalledArtifactNameStr,
+		Background: true,
+	})
+}
+
+func (s *InstalledArtifactsStorage) UpsertInstalledArtifact(ctx context.Context,
+	installed *deployments.InstalledArtifact) error {
+
+	if installed == nil {
+		return deployments.NewStoreError("UpsertInstalledArtifact", CollectionInstalledArtifacts,
+			ErrStorageInvalidInput)
+	}
+
+	if err := installed.Validate(); err != nil {
+		return err
+	}
+
+	session := s.session.Copy()
+	defer session.Close()
+
+	if err := s.ensureIndexing(ctx, session); err != nil {
+		return err
+	}
+
+	_, err := session.DB(store.DbFromContext(ctx, DatabaseName)).
+		C(CollectionInstalledArtifacts).UpsertId(installed.DeviceID, installed)
+	return err
+}
+
+// FindInstalledArtifactsByName returns devices with the artifact installed,
+// sorted by device ID.
+func (s *InstalledArtifactsStorage) FindInstalledArtifactsByName(ctx context.Context,
+	name string) ([]*deployments.InstalledArtifact, error) {
+
+	if govalidator.IsNull(name) {
+		return nil, deployments.NewStoreError("FindInstalledArtifactsByName",
+			CollectionInstalledArtifacts, ErrStorageInvalidInput)
+	}
+
+	return s.find(ctx, bson.M{StorageKeyInstalledArtifactName: name})
+}
+
+// FindInstalledArtifactsByNamePrefix returns devices with artifact of name
+// starting with the prefix installed, sorted by device ID.
+func (s *InstalledArtifactsStorage) FindInstalledArtifactsByNamePrefix(ctx context.Context,
+	prefix string) ([]*deployments.InstalledArtifact, error) {
+
+	if govalidator.IsNull(prefix) {
+		return nil, deployments.NewStoreError("FindInstalledArtifactsByNamePrefix",
+			CollectionInstalledArtifacts, ErrStorageInvalidInput)
+	}
+
+	return s.find(ctx, bson.M{StorageKeyInstalledArtifactName: bson.M{
+		"$regex": "^" + regexp.QuoteMeta(prefix),
+	}})
+}
+
+func (s *InstalledArtifactsStorage) find(ctx context.Context,
+	query bson.M) ([]*deployments.InstalledArtifact, error) {
+
+	session := s.session.Copy()
+	defer session.Close()
+
+	var installed []*deployments.InstalledArtifact
+	if err := session.DB(store.DbFromContext(ctx, DatabaseName)).
+		C(CollectionInstalledArtifacts).Find(query).
+		Sort("_id").All(&installed); err != nil {
+		return nil, err
+	}
+
+	return installed, nil
+}
+
+func (s *InstalledArtifactsStorage) DeleteInstalledArtifact(ctx context.Context,
+	deviceID string) error {
+
+	if govalidator.IsNull(deviceID) {
+		return deployments.NewStoreError("DeleteInstalledArtifact", CollectionInstalledArtifacts,
+			ErrStorageInvalidID, deviceID)
+	}
+
+	session := s.session.Copy()
+	defer session.Close()
+
+	if err := session.DB(store.DbFromContext(ctx, DatabaseName)).
+		C(CollectionInstalledArtifacts).RemoveId(deviceID); err != nil {
+		if err == mgo.ErrNotFound {
+			return nil
+		}
+		return err
+	}
+
+	return nil
+}
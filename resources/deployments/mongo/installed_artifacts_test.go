@@ -0,0 +1,89 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package mongo_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/mendersoftware/go-lib-micro/identity"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mendersoftware/deployments/resources/deployments"
+	. "github.com/mendersoftware/deployments/resources/deployments/mongo"
+)
+
+func TestInstalledArtifactsStorage(t *testing.T) {
+
+	if testing.Short() {
+		t.Skip("skipping TestInstalledArtifactsStorage in short mode.")
+	}
+
+	// Make sure we start test with empty database
+	db.Wipe()
+
+	session := db.Session()
+	store := NewInstalledArtifactsStorage(session)
+
+	ctx := identity.WithContext(context.Background(), &identity.Identity{
+		Tenant: "acme",
+	})
+
+	assert.Error(t, store.UpsertInstalledArtifact(ctx, nil))
+	assert.Error(t, store.UpsertInstalledArtifact(ctx, &deployments.InstalledArtifact{}))
+	for _, installed := range []*deployments.InstalledArtifact{
+		{DeviceID: "device-2", ArtifactName: "app-1.2.0", DeviceType: "rpi4"},
+		{DeviceID: "device-1", ArtifactName: "app-1.1.0", DeviceType: "rpi4"},
+		{DeviceID: "device-3", ArtifactName: "app.1.2.0", DeviceType: "bbb"},
+		{DeviceID: "device-4", ArtifactName: "app-1.2.0", DeviceType: "bbb"},
+		// artifact of existing device is replaced
+		{DeviceID: "device-1", ArtifactName: "app-1.2.0", DeviceType: "rpi4"},
+	} {
+		assert.NoError(t, store.UpsertInstalledArtifact(ctx, installed))
+	}
+
+	installed, err := store.FindInstalledArtifactsByName(ctx, "app-1.2.0")
+	assert.NoError(t, err)
+	assert.Equal(t, []*deployments.InstalledArtifact{
+		{DeviceID: "device-1", ArtifactName: "app-1.2.0", DeviceType: "rpi4"},
+		{DeviceID: "device-2", ArtifactName: "app-1.2.0", DeviceType: "rpi4"},
+		{DeviceID: "device-4", ArtifactName: "app-1.2.0", DeviceType: "bbb"},
+	}, installed)
+
+	// prefix is matched literally
+	installed, err = store.FindInstalledArtifactsByNamePrefix(ctx, "app.")
+	assert.NoError(t, err)
+	assert.Equal(t, []*deployments.InstalledArtifact{
+		{DeviceID: "device-3", ArtifactName: "app.1.2.0", DeviceType: "bbb"},
+	}, installed)
+
+	_, err = store.FindInstalledArtifactsByName(ctx, "")
+	assert.Error(t, err)
+	_, err = store.FindInstalledArtifactsByNamePrefix(ctx, "")
+	assert.Error(t, err)
+
+	// artifacts are stored per tenant
+	installed, err = store.FindInstalledArtifactsByNamePrefix(context.Background(), "app")
+	assert.NoError(t, err)
+	assert.Len(t, installed, 0)
+
+	assert.Error(t, store.DeleteInstalledArtifact(ctx, ""))
+	assert.NoError(t, store.DeleteInstalledArtifact(ctx, "device-1"))
+	assert.NoError(t, store.DeleteInstalledArtifact(ctx, "device-1"))
+
+	installed, err = store.FindInstalledArtifactsByName(ctx, "app-1.2.0")
+	assert.NoError(t, err)
+	assert.Len(t, installed, 2)
+}
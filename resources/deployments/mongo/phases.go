@@ -0,0 +1,187 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package mongo
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/asaskevich/govalidator"
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+	"github.com/mendersoftware/go-lib-micro/store"
+
+	"github.com/mendersoftware/deployments/resources/deployments"
+)
+
+// Database keys
+const (
+	StorageKeyDeploymentPhases          = "deploymentconstructor.phases"
+	StorageKeyDeploymentPhasesStart     = StorageKeyDeploymentPhases + ".start_ts"
+	StorageKeyDeploymentPhasesActivated = "activated"
+	StorageKeyDeviceDeploymentPhase     = "phase"
+)
+
+// Indexes
+const (
+	IndexDeploymentPhasesStartStr = "deploymentPhasesStartIndex"
+)
+
+// Phases waiting for activation are looked up by start time; deployments
+// without phases are left out of the index.
+func (d *DeploymentsStorage) ensurePhasesIndexing(ctx context.Context,
+	session *mgo.Session) error {
+
+	phasesIndex := mgo.Index{
+		Key:        []string{StorageKeyDeploymentPhasesStart},
+		Name:       IndexDeploymentPhasesStartStr,
+		Sparse:     true,
+		Background: true,
+	}
+
+	return session.DB(store.DbFromContext(ctx, DatabaseName)).
+		C(CollectionDeployments).
+		EnsureIndex(phasesIndex)
+}
+
+// FindWithStartedPhases returns unfinished deployments with phases which
+// started by the time but were not activated yet.
+func (d *DeploymentsStorage) FindWithStartedPhases(ctx context.Context,
+	now time.Time) ([]*deployments.Deployment, error) {
+
+	session := d.session.Copy()
+	defer session.Close()
+
+	query := bson.M{
+		StorageKeyDeploymentFinished: nil,
+		StorageKeyDeploymentPhases: bson.M{
+			"$elemMatch": bson.M{
+				"start_ts":                          bson.M{"$lte": now},
+				StorageKeyDeploymentPhasesActivated: bson.M{"$exists": false},
+			},
+		},
+	}
+
+	var found []*deployments.Deployment
+	if err := session.DB(store.DbFromContext(ctx, DatabaseName)).
+		C(CollectionDeployments).
+		Find(query).All(&found); err != nil {
+		return nil, err
+	}
+
+	return found, nil
+}
+
+// ActivatePhase marks the phase of the deployment activated at the time;
+// returns false if it was activated already, e.g. by another instance of
+// the service.
+func (d *DeploymentsStorage) ActivatePhase(ctx context.Context, id string,
+	phase int, when time.Time) (bool, error) {
+
+	if govalidator.IsNull(id) {
+		return false, deployments.NewStoreError("ActivatePhase", CollectionDeployments,
+			ErrStorageInvalidID, id)
+	}
+
+	session := d.session.Copy()
+	defer session.Close()
+
+	key := fmt.Sprintf("%s.%d.%s", StorageKeyDeploymentPhases, phase,
+		StorageKeyDeploymentPhasesActivated)
+	selector := bson.M{
+		"_id": id,
+		key:   bson.M{"$exists": false},
+	}
+	update := bson.M{
+		"$set": bson.M{
+			key: when,
+		},
+	}
+
+	err := session.DB(store.DbFromContext(ctx, DatabaseName)).
+		C(CollectionDeployments).Update(selector, update)
+	if err != nil {
+		if err.Error() == mgo.ErrNotFound.Error() {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return true, nil
+}
+
+// AggregateDeviceDeploymentByPhase counts device deployments of the
+// deployment by phase and status.
+func (d *DeviceDeploymentsStorage) AggregateDeviceDeploymentByPhase(ctx context.Context,
+	id string) (map[int]deployments.Stats, error) {
+
+	if govalidator.IsNull(id) {
+		return nil, deployments.NewStoreError("AggregateDeviceDeploymentByPhase",
+			CollectionDevices, ErrStorageInvalidID, id)
+	}
+
+	session := d.session.Copy()
+	defer session.Close()
+
+	pipe := []bson.M{
+		{
+			"$match": bson.M{
+				StorageKeyDeviceDeploymentDeploymentID: id,
+			},
+		},
+		{
+			"$group": bson.M{
+				"_id": bson.M{
+					"phase":  "$" + StorageKeyDeviceDeploymentPhase,
+					"status": "$" + StorageKeyDeviceDeploymentStatus,
+				},
+				"count": bson.M{
+					"$sum": 1,
+				},
+			},
+		},
+	}
+
+	db := session.DB(store.DbFromContext(ctx, DatabaseName))
+	collections, err := deploymentCollections(db, id)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := map[int]deployments.Stats{}
+	for _, collection := range collections {
+		var results []struct {
+			ID struct {
+				// not stored for the first phase
+				Phase  int    `bson:"phase"`
+				Status string `bson:"status"`
+			} `bson:"_id"`
+			Count int
+		}
+		if err := db.C(collection).Pipe(&pipe).All(&results); err != nil {
+			return nil, err
+		}
+
+		for _, res := range results {
+			if stats[res.ID.Phase] == nil {
+				stats[res.ID.Phase] = deployments.NewDeviceDeploymentStats()
+			}
+			stats[res.ID.Phase][res.ID.Status] += res.Count
+		}
+	}
+
+	return stats, nil
+}
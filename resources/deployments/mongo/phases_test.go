@@ -0,0 +1,149 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package mongo_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mendersoftware/deployments/resources/deployments"
+	. "github.com/mendersoftware/deployments/resources/deployments/mongo"
+	. "github.com/mendersoftware/deployments/utils/pointers"
+)
+
+func TestPhasesStorage(t *testing.T) {
+
+	if testing.Short() {
+		t.Skip("skipping TestPhasesStorage in short mode.")
+	}
+
+	// Make sure we start test with empty database
+	db.Wipe()
+
+	session := db.Session()
+	defer session.Close()
+	store := NewDeploymentsStorage(session)
+	deviceStore := NewDeviceDeploymentsStorage(session)
+
+	ctx := context.Background()
+
+	now := time.Now().UTC().Round(time.Millisecond)
+	earlier := now.Add(-time.Hour)
+	later := now.Add(time.Hour)
+
+	phased := &deployments.Deployment{
+		Id: StringToPointer("a108ae14-bb4e-455f-9b40-2ef4bab97bb7"),
+		DeploymentConstructor: &deployments.DeploymentConstructor{
+			Name:         StringToPointer("phased"),
+			ArtifactName: StringToPointer("App 123"),
+			Phases: []deployments.DeploymentPhase{
+				{BatchSize: 50, StartTs: &earlier, DeviceCount: 1, Activated: &earlier},
+				{BatchSize: 25, StartTs: &earlier, DeviceCount: 1},
+				{StartTs: &later, DeviceCount: 2},
+			},
+		},
+		Created: &earlier,
+	}
+	notStarted := &deployments.Deployment{
+		Id: StringToPointer("b108ae14-bb4e-455f-9b40-2ef4bab97bb7"),
+		DeploymentConstructor: &deployments.DeploymentConstructor{
+			Name:         StringToPointer("not started"),
+			ArtifactName: StringToPointer("App 123"),
+			Phases: []deployments.DeploymentPhase{
+				{BatchSize: 50, StartTs: &earlier, Activated: &earlier},
+				{StartTs: &later},
+			},
+		},
+		Created: &earlier,
+	}
+	finished := &deployments.Deployment{
+		Id: StringToPointer("c108ae14-bb4e-455f-9b40-2ef4bab97bb7"),
+		DeploymentConstructor: &deployments.DeploymentConstructor{
+			Name:         StringToPointer("finished"),
+			ArtifactName: StringToPointer("App 123"),
+			Phases: []deployments.DeploymentPhase{
+				{BatchSize: 50, StartTs: &earlier, Activated: &earlier},
+				{StartTs: &earlier},
+			},
+		},
+		Created:  &earlier,
+		Finished: &now,
+	}
+	for _, deployment := range []*deployments.Deployment{phased, notStarted, finished} {
+		assert.NoError(t, store.Insert(ctx, deployment))
+	}
+
+	found, err := store.FindWithStartedPhases(ctx, now)
+	assert.NoError(t, err)
+	if assert.Len(t, found, 1) {
+		assert.Equal(t, *phased.Id, *found[0].Id)
+	}
+
+	_, err = store.ActivatePhase(ctx, "", 1, now)
+	assert.Error(t, err)
+
+	activated, err := store.ActivatePhase(ctx, *phased.Id, 1, now)
+	assert.NoError(t, err)
+	assert.True(t, activated)
+
+	// already activated
+	activated, err = store.ActivatePhase(ctx, *phased.Id, 1, now)
+	assert.NoError(t, err)
+	assert.False(t, activated)
+
+	found, err = store.FindWithStartedPhases(ctx, now)
+	assert.NoError(t, err)
+	assert.Empty(t, found)
+
+	deployment, err := store.FindByID(ctx, *phased.Id)
+	assert.NoError(t, err)
+	if assert.NotNil(t, deployment) && assert.Len(t, deployment.Phases, 3) {
+		assert.Equal(t, now, deployment.Phases[1].Activated.UTC())
+		assert.Nil(t, deployment.Phases[2].Activated)
+	}
+
+	var deviceDeployments []*deployments.DeviceDeployment
+	for i, status := range []string{
+		deployments.DeviceDeploymentStatusSuccess,
+		deployments.DeviceDeploymentStatusDownloading,
+		deployments.DeviceDeploymentStatusPending,
+		deployments.DeviceDeploymentStatusPending,
+	} {
+		deviceDeployment := deployments.NewDeviceDeployment(
+			fmt.Sprintf("device-%d", i+1), *phased.Id)
+		deviceDeployment.Status = StringToPointer(status)
+		deviceDeployment.Phase = phased.PhaseOfDevice(i)
+		deviceDeployments = append(deviceDeployments, deviceDeployment)
+	}
+	assert.NoError(t, deviceStore.InsertMany(ctx, deviceDeployments...))
+
+	_, err = deviceStore.AggregateDeviceDeploymentByPhase(ctx, "")
+	assert.Error(t, err)
+
+	stats, err := deviceStore.AggregateDeviceDeploymentByPhase(ctx, *phased.Id)
+	assert.NoError(t, err)
+
+	first := deployments.NewDeviceDeploymentStats()
+	first[deployments.DeviceDeploymentStatusSuccess] = 1
+	second := deployments.NewDeviceDeploymentStats()
+	second[deployments.DeviceDeploymentStatusDownloading] = 1
+	third := deployments.NewDeviceDeploymentStats()
+	third[deployments.DeviceDeploymentStatusPending] = 2
+	assert.Equal(t, map[int]deployments.Stats{0: first, 1: second, 2: third}, stats)
+}
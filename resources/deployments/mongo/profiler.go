@@ -0,0 +1,143 @@
+// Copyright 2018 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package mongo
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+	"github.com/mendersoftware/go-lib-micro/store"
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/deployments/resources/deployments"
+)
+
+// Database settings
+const (
+	CollectionProfile = "system.profile"
+
+	// most of the slow queries explained at once
+	profileExplainLimit = 10
+)
+
+// Profiler explains slow queries with the records of the database
+// profiler. Profiling of the database of the tenant is enabled on first
+// use, recording operations taking at least the threshold; queries
+// executed before are not explained. Note that the threshold applies to
+// the slow operation log of the whole server as well.
+type Profiler struct {
+	session   *mgo.Session
+	threshold time.Duration
+
+	lock    sync.Mutex
+	enabled map[string]bool
+}
+
+// NewProfiler creates profiler recording operations taking at least
+// the threshold.
+func NewProfiler(session *mgo.Session, threshold time.Duration) *Profiler {
+	return &Profiler{
+		session:   session,
+		threshold: threshold,
+		enabled:   make(map[string]bool),
+	}
+}
+
+type profileEntry struct {
+	Namespace    string    `bson:"ns"`
+	Operation    string    `bson:"op"`
+	Millis       int       `bson:"millis"`
+	PlanSummary  string    `bson:"planSummary"`
+	KeysExamined int       `bson:"keysExamined"`
+	DocsExamined int       `bson:"docsExamined"`
+	Returned     int       `bson:"nreturned"`
+	Command      bson.M    `bson:"command"`
+	ExecStats    bson.M    `bson:"execStats"`
+	Timestamp    time.Time `bson:"ts"`
+}
+
+// ExplainSlowQueries returns the profiled operations of the tenant database
+// in context which started since the given time, oldest first.
+func (p *Profiler) ExplainSlowQueries(ctx context.Context,
+	since time.Time) ([]deployments.SlowQuery, error) {
+
+	session := p.session.Copy()
+	defer session.Close()
+
+	db := session.DB(store.DbFromContext(ctx, DatabaseName))
+	if err := p.enable(db); err != nil {
+		return nil, err
+	}
+
+	// profiler records operations when they finish
+	query := bson.M{
+		"ts": bson.M{"$gte": since},
+		"ns": bson.M{"$ne": db.Name + "." + CollectionProfile},
+	}
+
+	var entries []profileEntry
+	err := db.C(CollectionProfile).Find(query).Sort("ts").
+		Limit(profileExplainLimit).All(&entries)
+	if err != nil {
+		return nil, errors.Wrap(err, "reading profiled operations")
+	}
+
+	queries := make([]deployments.SlowQuery, 0, len(entries))
+	for _, entry := range entries {
+		query := deployments.SlowQuery{
+			Namespace:    entry.Namespace,
+			Operation:    entry.Operation,
+			Duration:     time.Duration(entry.Millis) * time.Millisecond,
+			PlanSummary:  entry.PlanSummary,
+			KeysExamined: entry.KeysExamined,
+			DocsExamined: entry.DocsExamined,
+			Returned:     entry.Returned,
+		}
+		if entry.Command != nil {
+			query.Command = entry.Command
+		}
+		if entry.ExecStats != nil {
+			query.Plan = entry.ExecStats
+		}
+		queries = append(queries, query)
+	}
+
+	return queries, nil
+}
+
+// enable turns profiling of slow operations of the database on,
+// once per database.
+func (p *Profiler) enable(db *mgo.Database) error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if p.enabled[db.Name] {
+		return nil
+	}
+
+	cmd := bson.D{
+		{Name: "profile", Value: 1},
+		{Name: "slowms", Value: int(p.threshold / time.Millisecond)},
+	}
+	if err := db.Run(cmd, nil); err != nil {
+		return errors.Wrap(err, "enabling database profiler")
+	}
+
+	p.enabled[db.Name] = true
+	return nil
+}
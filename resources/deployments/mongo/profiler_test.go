@@ -0,0 +1,66 @@
+// Copyright 2018 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package mongo_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mendersoftware/deployments/resources/deployments"
+	. "github.com/mendersoftware/deployments/resources/deployments/mongo"
+)
+
+func TestProfilerExplainSlowQueries(t *testing.T) {
+
+	if testing.Short() {
+		t.Skip("skipping TestProfilerExplainSlowQueries in short mode.")
+	}
+
+	db.Wipe()
+	session := db.Session()
+	defer session.Close()
+
+	ctx := context.Background()
+	store := NewDeviceDeploymentsStorage(session)
+	err := store.InsertMany(ctx,
+		deployments.NewDeviceDeployment("001", "30b3e62c-9ec2-4312-a7fa-cff24cc7397a"))
+	assert.NoError(t, err)
+
+	// every operation is slow
+	profiler := NewProfiler(session, 0)
+	queries, err := profiler.ExplainSlowQueries(ctx, time.Now())
+	assert.NoError(t, err)
+	assert.Empty(t, queries)
+
+	since := time.Now().Add(-time.Second)
+	_, err = store.FindAllDeploymentsForDeviceIDWithStatuses(ctx, "001",
+		deployments.DeviceDeploymentStatusPending)
+	assert.NoError(t, err)
+
+	queries, err = profiler.ExplainSlowQueries(ctx, since)
+	assert.NoError(t, err)
+
+	found := false
+	for _, query := range queries {
+		if query.Namespace == DatabaseName+"."+CollectionDevices {
+			found = true
+			assert.NotEmpty(t, query.PlanSummary)
+		}
+	}
+	assert.True(t, found)
+}
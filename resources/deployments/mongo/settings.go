@@ -0,0 +1,82 @@
+// Copyright 2026 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package mongo
+
+import (
+	"context"
+
+	"github.com/globalsign/mgo"
+	"github.com/mendersoftware/go-lib-micro/store"
+
+	"github.com/mendersoftware/deployments/resources/deployments"
+)
+
+// Database settings
+const (
+	CollectionSettings = "settings"
+)
+
+// IDs of the settings documents
+const (
+	SettingsIDDeployments = "deployments"
+)
+
+// SettingsStorage is a data layer for tenant settings based on MongoDB
+type SettingsStorage struct {
+	session *mgo.Session
+}
+
+func NewSettingsStorage(session *mgo.Session) *SettingsStorage {
+	return &SettingsStorage{
+		session: session,
+	}
+}
+
+// GetDeploymentSettings returns deployment settings of the tenant, nil if
+// they were never set.
+func (s *SettingsStorage) GetDeploymentSettings(ctx context.Context) (
+	*deployments.DeploymentSettings, error) {
+
+	session := s.session.Copy()
+	defer session.Close()
+
+	var settings deployments.DeploymentSettings
+	if err := session.DB(store.DbFromContext(ctx, DatabaseName)).
+		C(CollectionSettings).FindId(SettingsIDDeployments).One(&settings); err != nil {
+		if err == mgo.ErrNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &settings, nil
+}
+
+// SetDeploymentSettings replaces deployment settings of the tenant.
+func (s *SettingsStorage) SetDeploymentSettings(ctx context.Context,
+	settings *deployments.DeploymentSettings) error {
+
+	if settings == nil {
+		return deployments.NewStoreError("SetDeploymentSettings", CollectionSettings,
+			ErrStorageInvalidInput)
+	}
+
+	session := s.session.Copy()
+	defer session.Close()
+
+	_, err := session.DB(store.DbFromContext(ctx, DatabaseName)).
+		C(CollectionSettings).UpsertId(SettingsIDDeployments, settings)
+	return err
+}
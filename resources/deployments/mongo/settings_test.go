@@ -0,0 +1,68 @@
+// Copyright 2026 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package mongo_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/mendersoftware/go-lib-micro/identity"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mendersoftware/deployments/resources/deployments"
+	. "github.com/mendersoftware/deployments/resources/deployments/mongo"
+)
+
+func TestSettingsStorage(t *testing.T) {
+
+	if testing.Short() {
+		t.Skip("skipping TestSettingsStorage in short mode.")
+	}
+
+	// Make sure we start test with empty database
+	db.Wipe()
+
+	session := db.Session()
+	store := NewSettingsStorage(session)
+
+	ctx := identity.WithContext(context.Background(), &identity.Identity{
+		Tenant: "acme",
+	})
+
+	settings, err := store.GetDeploymentSettings(ctx)
+	assert.NoError(t, err)
+	assert.Nil(t, settings)
+
+	three := 3
+	assert.Error(t, store.SetDeploymentSettings(ctx, nil))
+	assert.NoError(t, store.SetDeploymentSettings(ctx, &deployments.DeploymentSettings{
+		ConflictPolicy: deployments.ConflictPolicySkip,
+	}))
+	assert.NoError(t, store.SetDeploymentSettings(ctx, &deployments.DeploymentSettings{
+		MaxRetries: &three,
+	}))
+
+	settings, err = store.GetDeploymentSettings(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, &deployments.DeploymentSettings{MaxRetries: &three}, settings)
+
+	// settings of other tenants are kept apart
+	other := identity.WithContext(context.Background(), &identity.Identity{
+		Tenant: "other",
+	})
+	settings, err = store.GetDeploymentSettings(other)
+	assert.NoError(t, err)
+	assert.Nil(t, settings)
+}
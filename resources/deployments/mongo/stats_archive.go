@@ -0,0 +1,61 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package mongo
+
+import (
+	"context"
+
+	"github.com/asaskevich/govalidator"
+	"github.com/globalsign/mgo"
+	"github.com/mendersoftware/go-lib-micro/store"
+
+	"github.com/mendersoftware/deployments/resources/deployments"
+)
+
+// Database settings
+const (
+	CollectionStatsArchive = "deployments_stats_archive"
+)
+
+// StatsArchiveStorage is a data layer for final statistics of finished
+// deployments based on MongoDB
+type StatsArchiveStorage struct {
+	session *mgo.Session
+}
+
+func NewStatsArchiveStorage(session *mgo.Session) *StatsArchiveStorage {
+	return &StatsArchiveStorage{
+		session: session,
+	}
+}
+
+// ArchiveStats stores statistics of the deployment, replacing statistics
+// archived before, e.g. if finalization of the deployment was retried.
+func (s *StatsArchiveStorage) ArchiveStats(ctx context.Context,
+	stats *deployments.ArchivedStats) error {
+
+	if stats == nil || govalidator.IsNull(stats.DeploymentID) {
+		return deployments.NewStoreError("ArchiveStats", CollectionStatsArchive,
+			ErrStorageInvalidInput)
+	}
+
+	session := s.session.Copy()
+	defer session.Close()
+
+	_, err := session.DB(store.DbFromContext(ctx, DatabaseName)).
+		C(CollectionStatsArchive).UpsertId(stats.DeploymentID, stats)
+
+	return err
+}
@@ -0,0 +1,71 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package mongo_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/mendersoftware/go-lib-micro/identity"
+	"github.com/mendersoftware/go-lib-micro/store"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mendersoftware/deployments/resources/deployments"
+	. "github.com/mendersoftware/deployments/resources/deployments/mongo"
+)
+
+func TestStatsArchiveStorage(t *testing.T) {
+
+	if testing.Short() {
+		t.Skip("skipping TestStatsArchiveStorage in short mode.")
+	}
+
+	// Make sure we start test with empty database
+	db.Wipe()
+
+	session := db.Session()
+	defer session.Close()
+	storage := NewStatsArchiveStorage(session)
+
+	ctx := identity.WithContext(context.Background(), &identity.Identity{
+		Tenant: "acme",
+	})
+
+	stats := &deployments.ArchivedStats{
+		DeploymentID: "b532b01a-9313-404f-8d19-e7fcbe5cc347",
+		Name:         "release 1",
+		ArtifactName: "release-1",
+		Stats: deployments.Stats{
+			deployments.DeviceDeploymentStatusSuccess: 2,
+		},
+	}
+
+	assert.Error(t, storage.ArchiveStats(ctx, nil))
+	assert.Error(t, storage.ArchiveStats(ctx, &deployments.ArchivedStats{}))
+	assert.NoError(t, storage.ArchiveStats(ctx, stats))
+
+	// archiving again replaces the statistics
+	stats.Stats[deployments.DeviceDeploymentStatusFailure] = 1
+	assert.NoError(t, storage.ArchiveStats(ctx, stats))
+
+	c := session.DB(store.DbFromContext(ctx, DatabaseName)).C(CollectionStatsArchive)
+	count, err := c.Count()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, count)
+
+	var archived deployments.ArchivedStats
+	assert.NoError(t, c.FindId(stats.DeploymentID).One(&archived))
+	assert.Equal(t, *stats, archived)
+}
@@ -0,0 +1,204 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package mongo
+
+import (
+	"context"
+
+	"github.com/asaskevich/govalidator"
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+	"github.com/mendersoftware/go-lib-micro/store"
+
+	"github.com/mendersoftware/deployments/resources/deployments"
+)
+
+// Database settings
+const (
+	CollectionTelemetry = "telemetry_samples"
+)
+
+// Database keys
+const (
+	StorageKeyTelemetryDeploymentID = "deployment_id"
+	StorageKeyTelemetryDeviceID     = "device_id"
+	StorageKeyTelemetryStatus       = "status"
+)
+
+// Indexes
+const (
+	IndexTelemetryDeploymentStr = "telemetryDeploymentIndex"
+	IndexTelemetryDeviceStr     = "telemetryDeviceIndex"
+)
+
+var telemetryMetrics = []string{
+	deployments.TelemetryDownloadSpeed,
+	deployments.TelemetryInstallDuration,
+	deployments.TelemetryBatteryLevel,
+}
+
+// TelemetryStorage is a data layer for sampled device telemetry based on
+// MongoDB
+type TelemetryStorage struct {
+	session *mgo.Session
+}
+
+func NewTelemetryStorage(session *mgo.Session) *TelemetryStorage {
+	return &TelemetryStorage{
+		session: session,
+	}
+}
+
+// Samples are aggregated per deployment and purged per device.
+func (s *TelemetryStorage) ensureIndexing(ctx context.Context,
+	session *mgo.Session) error {
+
+	c := session.DB(store.DbFromContext(ctx, DatabaseName)).C(CollectionTelemetry)
+	for _, index := range []mgo.Index{
+		{
+			Key:        []string{StorageKeyTelemetryDeploymentID},
+			Name:       IndexTelemetryDeploymentStr,
+			Background: true,
+		},
+		{
+			Key:        []string{StorageKeyTelemetryDeviceID},
+			Name:       IndexTelemetryDeviceStr,
+			Background: true,
+		},
+	} {
+		if err := c.EnsureIndex(index); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *TelemetryStorage) InsertTelemetrySample(ctx context.Context,
+	sample *deployments.TelemetrySample) error {
+
+	if sample == nil || govalidator.IsNull(sample.DeploymentID) ||
+		govalidator.IsNull(sample.DeviceID) {
+		return deployments.NewStoreError("InsertTelemetrySample", CollectionTelemetry,
+			ErrStorageInvalidInput)
+	}
+
+	session := s.session.Copy()
+	defer session.Close()
+
+	if err := s.ensureIndexing(ctx, session); err != nil {
+		return err
+	}
+
+	return session.DB(store.DbFromContext(ctx, DatabaseName)).
+		C(CollectionTelemetry).Insert(sample)
+}
+
+// AggregateTelemetry computes count, minimum, maximum and average of each
+// metric of the deployment samples, grouped by reported status. Metrics
+// missing in all samples of a status are left out.
+func (s *TelemetryStorage) AggregateTelemetry(ctx context.Context,
+	deploymentID string) (deployments.TelemetryStats, error) {
+
+	if govalidator.IsNull(deploymentID) {
+		return nil, deployments.NewStoreError("AggregateTelemetry", CollectionTelemetry,
+			ErrStorageInvalidID, deploymentID)
+	}
+
+	session := s.session.Copy()
+	defer session.Close()
+
+	group := bson.M{"_id": "$" + StorageKeyTelemetryStatus}
+	for _, metric := range telemetryMetrics {
+		group[metric+"_count"] = bson.M{
+			"$sum": bson.M{
+				"$cond": []interface{}{
+					bson.M{"$eq": []interface{}{bson.M{"$type": "$" + metric}, "missing"}},
+					0,
+					1,
+				},
+			},
+		}
+		group[metric+"_min"] = bson.M{"$min": "$" + metric}
+		group[metric+"_max"] = bson.M{"$max": "$" + metric}
+		group[metric+"_avg"] = bson.M{"$avg": "$" + metric}
+	}
+	pipe := []bson.M{
+		{
+			"$match": bson.M{
+				StorageKeyTelemetryDeploymentID: deploymentID,
+			},
+		},
+		{
+			"$group": group,
+		},
+	}
+
+	var results []bson.M
+	if err := session.DB(store.DbFromContext(ctx, DatabaseName)).
+		C(CollectionTelemetry).Pipe(&pipe).All(&results); err != nil {
+		return nil, err
+	}
+
+	stats := deployments.TelemetryStats{}
+	for _, res := range results {
+		status, _ := res["_id"].(string)
+		metrics := map[string]deployments.TelemetryAggregate{}
+		for _, metric := range telemetryMetrics {
+			count, _ := res[metric+"_count"].(int)
+			if count == 0 {
+				continue
+			}
+			metrics[metric] = deployments.TelemetryAggregate{
+				Count: count,
+				Min:   toFloat(res[metric+"_min"]),
+				Max:   toFloat(res[metric+"_max"]),
+				Avg:   toFloat(res[metric+"_avg"]),
+			}
+		}
+		if len(metrics) > 0 {
+			stats[status] = metrics
+		}
+	}
+	return stats, nil
+}
+
+func (s *TelemetryStorage) DeleteDeviceTelemetry(ctx context.Context,
+	deviceID string) (int, error) {
+
+	session := s.session.Copy()
+	defer session.Close()
+
+	info, err := session.DB(store.DbFromContext(ctx, DatabaseName)).
+		C(CollectionTelemetry).RemoveAll(bson.M{
+		StorageKeyTelemetryDeviceID: deviceID,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return info.Removed, nil
+}
+
+func toFloat(v interface{}) float64 {
+	switch v := v.(type) {
+	case float64:
+		return v
+	case int:
+		return float64(v)
+	case int64:
+		return float64(v)
+	}
+	return 0
+}
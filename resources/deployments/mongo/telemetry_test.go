@@ -0,0 +1,128 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package mongo_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mendersoftware/go-lib-micro/identity"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mendersoftware/deployments/resources/deployments"
+	. "github.com/mendersoftware/deployments/resources/deployments/mongo"
+)
+
+func TestTelemetryStorage(t *testing.T) {
+
+	if testing.Short() {
+		t.Skip("skipping TestTelemetryStorage in short mode.")
+	}
+
+	// Make sure we start test with empty database
+	db.Wipe()
+
+	session := db.Session()
+	store := NewTelemetryStorage(session)
+
+	ctx := identity.WithContext(context.Background(), &identity.Identity{
+		Tenant: "acme",
+	})
+
+	value := func(v float64) *float64 {
+		return &v
+	}
+
+	assert.Error(t, store.InsertTelemetrySample(ctx, nil))
+	assert.Error(t, store.InsertTelemetrySample(ctx, &deployments.TelemetrySample{}))
+
+	now := time.Now()
+	for _, sample := range []*deployments.TelemetrySample{
+		{
+			DeploymentID: "deployment-1",
+			DeviceID:     "device-1",
+			Status:       deployments.DeviceDeploymentStatusDownloading,
+			DeviceTelemetry: deployments.DeviceTelemetry{
+				DownloadSpeed: value(1000),
+				BatteryLevel:  value(80),
+			},
+			Reported: now,
+		},
+		{
+			DeploymentID: "deployment-1",
+			DeviceID:     "device-2",
+			Status:       deployments.DeviceDeploymentStatusDownloading,
+			DeviceTelemetry: deployments.DeviceTelemetry{
+				DownloadSpeed: value(3000),
+			},
+			Reported: now,
+		},
+		{
+			DeploymentID: "deployment-1",
+			DeviceID:     "device-1",
+			Status:       deployments.DeviceDeploymentStatusFailure,
+			DeviceTelemetry: deployments.DeviceTelemetry{
+				InstallDuration: value(30),
+				BatteryLevel:    value(0),
+			},
+			Reported: now,
+		},
+		{
+			DeploymentID: "deployment-2",
+			DeviceID:     "device-1",
+			Status:       deployments.DeviceDeploymentStatusFailure,
+			DeviceTelemetry: deployments.DeviceTelemetry{
+				BatteryLevel: value(50),
+			},
+			Reported: now,
+		},
+	} {
+		assert.NoError(t, store.InsertTelemetrySample(ctx, sample))
+	}
+
+	stats, err := store.AggregateTelemetry(ctx, "deployment-1")
+	assert.NoError(t, err)
+	assert.Equal(t, deployments.TelemetryStats{
+		deployments.DeviceDeploymentStatusDownloading: {
+			deployments.TelemetryDownloadSpeed: {Count: 2, Min: 1000, Max: 3000, Avg: 2000},
+			deployments.TelemetryBatteryLevel:  {Count: 1, Min: 80, Max: 80, Avg: 80},
+		},
+		deployments.DeviceDeploymentStatusFailure: {
+			deployments.TelemetryInstallDuration: {Count: 1, Min: 30, Max: 30, Avg: 30},
+			deployments.TelemetryBatteryLevel:    {Count: 1, Min: 0, Max: 0, Avg: 0},
+		},
+	}, stats)
+
+	_, err = store.AggregateTelemetry(ctx, "")
+	assert.Error(t, err)
+
+	// samples are stored per tenant
+	stats, err = store.AggregateTelemetry(context.Background(), "deployment-1")
+	assert.NoError(t, err)
+	assert.Empty(t, stats)
+
+	removed, err := store.DeleteDeviceTelemetry(ctx, "device-1")
+	assert.NoError(t, err)
+	assert.Equal(t, 3, removed)
+
+	stats, err = store.AggregateTelemetry(ctx, "deployment-1")
+	assert.NoError(t, err)
+	assert.Equal(t, deployments.TelemetryStats{
+		deployments.DeviceDeploymentStatusDownloading: {
+			deployments.TelemetryDownloadSpeed: {Count: 1, Min: 3000, Max: 3000, Avg: 3000},
+		},
+	}, stats)
+}
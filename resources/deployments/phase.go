@@ -0,0 +1,153 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package deployments
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Errors
+var (
+	ErrInvalidBatchSize     = errors.New("Batch size must be between 1 and 100")
+	ErrInvalidBatchSizesSum = errors.New("Batch sizes of phases must add up to 100")
+	ErrMissingPhaseStart    = errors.New("Start time is required for all phases but the first")
+	ErrInvalidPhaseStart    = errors.New("Phase must start after the previous one")
+	ErrPhasesLazy           = errors.New("Phased deployment requires eager device deployments")
+)
+
+// DeploymentMaxPhases limits the number of phases of a single deployment.
+const DeploymentMaxPhases = 10
+
+// DeploymentPhase is a part of the devices of a phased deployment, which are
+// served the deployment from the start time of the phase.
+type DeploymentPhase struct {
+	// Percentage of devices of the deployment, optional for the last phase
+	// which then takes the rest
+	BatchSize int `json:"batch_size,omitempty" bson:"batch_size,omitempty"`
+
+	// Start time, the first phase starts with the deployment if not set
+	StartTs *time.Time `json:"start_ts,omitempty" bson:"start_ts,omitempty"`
+
+	// Number of devices assigned to the phase, set on creation
+	DeviceCount int `json:"device_count" bson:"device_count"`
+
+	// Time the phase was activated and its devices notified, not set until
+	// the phase starts
+	Activated *time.Time `json:"activated,omitempty" bson:"activated,omitempty"`
+}
+
+// validatePhases reports each invalid phase of the deployment.
+func validatePhases(verr *ValidationError, phases []DeploymentPhase) {
+	if len(phases) > DeploymentMaxPhases {
+		verr.Add("phases", ValidationCodeLength,
+			fmt.Sprintf("at most %d phases are allowed", DeploymentMaxPhases))
+		return
+	}
+
+	sum := 0
+	for i, phase := range phases {
+		field := fmt.Sprintf("phases[%d]", i)
+		last := i == len(phases)-1
+
+		if phase.BatchSize != 0 || !last {
+			if phase.BatchSize < 1 || phase.BatchSize > 100 {
+				verr.Add(field+".batch_size", ValidationCodeInvalid,
+					ErrInvalidBatchSize.Error())
+			}
+			sum += phase.BatchSize
+		}
+		if last && ((phase.BatchSize == 0 && sum >= 100) ||
+			(phase.BatchSize != 0 && sum != 100)) {
+			verr.Add("phases", ValidationCodeInvalid, ErrInvalidBatchSizesSum.Error())
+		}
+
+		if i == 0 {
+			continue
+		}
+		switch prev := phases[i-1].StartTs; {
+		case phase.StartTs == nil:
+			verr.Add(field+".start_ts", ValidationCodeRequired,
+				ErrMissingPhaseStart.Error())
+		case prev != nil && !phase.StartTs.After(*prev):
+			verr.Add(field+".start_ts", ValidationCodeInvalid,
+				ErrInvalidPhaseStart.Error())
+		}
+	}
+}
+
+// AssignPhases sets start of the first phase, if not set, and number of
+// devices of each phase, in order; the last phase takes the devices left
+// over after rounding down the others. Phases which already started are
+// activated with the deployment.
+func (d *Deployment) AssignPhases(devices int) {
+	if d.DeploymentConstructor == nil || len(d.Phases) == 0 {
+		return
+	}
+
+	if d.Phases[0].StartTs == nil {
+		d.Phases[0].StartTs = d.Created
+	}
+
+	left := devices
+	for i := range d.Phases {
+		d.Phases[i].Activated = nil
+		d.Phases[i].DeviceCount = devices * d.Phases[i].BatchSize / 100
+		if i == len(d.Phases)-1 {
+			d.Phases[i].DeviceCount = left
+		}
+		left -= d.Phases[i].DeviceCount
+
+		if d.Created != nil && d.IsPhaseStarted(i, *d.Created) {
+			d.Phases[i].Activated = d.Created
+		}
+	}
+}
+
+// PhaseOfDevice returns phase of the device at the index of the devices
+// of the deployment, as assigned by AssignPhases.
+func (d *Deployment) PhaseOfDevice(index int) int {
+	if d.DeploymentConstructor == nil {
+		return 0
+	}
+	for i, phase := range d.Phases {
+		if index < phase.DeviceCount {
+			return i
+		}
+		index -= phase.DeviceCount
+	}
+	return 0
+}
+
+// IsPhaseStarted returns true if devices of the phase can be served the
+// deployment at the time.
+func (d *Deployment) IsPhaseStarted(phase int, now time.Time) bool {
+	if d.DeploymentConstructor == nil || phase >= len(d.Phases) {
+		return true
+	}
+	start := d.Phases[phase].StartTs
+	return start == nil || !now.Before(*start)
+}
+
+// PhaseStats are device deployment statistics of a deployment phase.
+type PhaseStats struct {
+	DeploymentPhase
+
+	// Index of the phase
+	Phase int `json:"phase"`
+
+	Stats Stats `json:"stats"`
+}
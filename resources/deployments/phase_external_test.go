@@ -0,0 +1,215 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package deployments_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	. "github.com/mendersoftware/deployments/resources/deployments"
+	. "github.com/mendersoftware/deployments/utils/pointers"
+)
+
+func TestDeploymentConstructorValidatePhases(t *testing.T) {
+
+	t.Parallel()
+
+	start := time.Date(2018, 5, 1, 10, 0, 0, 0, time.UTC)
+	after := func(d time.Duration) *time.Time {
+		ts := start.Add(d)
+		return &ts
+	}
+
+	testCases := map[string]struct {
+		InputPhases            []DeploymentPhase
+		InputDeviceDeployments string
+
+		OutputFields []FieldError
+	}{
+		"last phase takes the rest": {
+			InputPhases: []DeploymentPhase{
+				{BatchSize: 10},
+				{BatchSize: 40, StartTs: after(24 * time.Hour)},
+				{StartTs: after(48 * time.Hour)},
+			},
+		},
+		"batch sizes add up": {
+			InputPhases: []DeploymentPhase{
+				{BatchSize: 20, StartTs: after(time.Hour)},
+				{BatchSize: 80, StartTs: after(2 * time.Hour)},
+			},
+		},
+		"delayed start": {
+			InputPhases: []DeploymentPhase{
+				{StartTs: after(time.Hour)},
+			},
+		},
+		"invalid batch sizes": {
+			InputPhases: []DeploymentPhase{
+				{},
+				{BatchSize: 101, StartTs: after(time.Hour)},
+				{StartTs: after(2 * time.Hour)},
+			},
+
+			OutputFields: []FieldError{
+				{
+					Field:   "phases[0].batch_size",
+					Code:    ValidationCodeInvalid,
+					Message: ErrInvalidBatchSize.Error(),
+				},
+				{
+					Field:   "phases[1].batch_size",
+					Code:    ValidationCodeInvalid,
+					Message: ErrInvalidBatchSize.Error(),
+				},
+				{
+					Field:   "phases",
+					Code:    ValidationCodeInvalid,
+					Message: ErrInvalidBatchSizesSum.Error(),
+				},
+			},
+		},
+		"batch sizes do not add up": {
+			InputPhases: []DeploymentPhase{
+				{BatchSize: 50},
+				{BatchSize: 40, StartTs: after(time.Hour)},
+			},
+
+			OutputFields: []FieldError{{
+				Field:   "phases",
+				Code:    ValidationCodeInvalid,
+				Message: ErrInvalidBatchSizesSum.Error(),
+			}},
+		},
+		"nothing left for the last phase": {
+			InputPhases: []DeploymentPhase{
+				{BatchSize: 100},
+				{StartTs: after(time.Hour)},
+			},
+
+			OutputFields: []FieldError{{
+				Field:   "phases",
+				Code:    ValidationCodeInvalid,
+				Message: ErrInvalidBatchSizesSum.Error(),
+			}},
+		},
+		"invalid start times": {
+			InputPhases: []DeploymentPhase{
+				{BatchSize: 10, StartTs: after(time.Hour)},
+				{BatchSize: 10},
+				{BatchSize: 10, StartTs: after(time.Hour)},
+				{StartTs: after(time.Hour)},
+			},
+
+			OutputFields: []FieldError{
+				{
+					Field:   "phases[1].start_ts",
+					Code:    ValidationCodeRequired,
+					Message: ErrMissingPhaseStart.Error(),
+				},
+				{
+					Field:   "phases[3].start_ts",
+					Code:    ValidationCodeInvalid,
+					Message: ErrInvalidPhaseStart.Error(),
+				},
+			},
+		},
+		"too many phases": {
+			InputPhases: make([]DeploymentPhase, DeploymentMaxPhases+1),
+
+			OutputFields: []FieldError{{
+				Field:   "phases",
+				Code:    ValidationCodeLength,
+				Message: "at most 10 phases are allowed",
+			}},
+		},
+		"lazy device deployments": {
+			InputPhases: []DeploymentPhase{
+				{StartTs: after(time.Hour)},
+			},
+			InputDeviceDeployments: DeviceDeploymentsLazy,
+
+			OutputFields: []FieldError{{
+				Field:   "device_deployments",
+				Code:    ValidationCodeInvalid,
+				Message: ErrPhasesLazy.Error(),
+			}},
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			constructor := &DeploymentConstructor{
+				Name:              StringToPointer("Phased"),
+				ArtifactName:      StringToPointer("app-1.3.0"),
+				Devices:           []string{"device-1"},
+				DeviceDeployments: testCase.InputDeviceDeployments,
+				Phases:            testCase.InputPhases,
+			}
+
+			err := constructor.Validate()
+			if testCase.OutputFields == nil {
+				assert.NoError(t, err)
+				return
+			}
+			if assert.IsType(t, &ValidationError{}, err) {
+				assert.Equal(t, testCase.OutputFields, err.(*ValidationError).Fields)
+			}
+		})
+	}
+}
+
+func TestDeploymentAssignPhases(t *testing.T) {
+
+	t.Parallel()
+
+	created := time.Date(2018, 5, 1, 10, 0, 0, 0, time.UTC)
+	later := created.Add(24 * time.Hour)
+	latest := created.Add(48 * time.Hour)
+
+	deployment := NewDeploymentFromConstructor(&DeploymentConstructor{
+		Phases: []DeploymentPhase{
+			{BatchSize: 10, DeviceCount: 7},
+			{BatchSize: 45, StartTs: &later, Activated: &later},
+			{StartTs: &latest},
+		},
+	})
+	deployment.Created = &created
+
+	deployment.AssignPhases(19)
+
+	// user provided counts and activation times are ignored
+	assert.Equal(t, []DeploymentPhase{
+		{BatchSize: 10, StartTs: &created, DeviceCount: 1, Activated: &created},
+		{BatchSize: 45, StartTs: &later, DeviceCount: 8},
+		{StartTs: &latest, DeviceCount: 10},
+	}, deployment.Phases)
+
+	phases := make([]int, 19)
+	for i := range phases {
+		phases[i] = deployment.PhaseOfDevice(i)
+	}
+	assert.Equal(t, []int{0, 1, 1, 1, 1, 1, 1, 1, 1, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2}, phases)
+
+	assert.True(t, deployment.IsPhaseStarted(0, created))
+	assert.False(t, deployment.IsPhaseStarted(1, created))
+	assert.True(t, deployment.IsPhaseStarted(1, later))
+	assert.False(t, deployment.IsPhaseStarted(2, later))
+	// not phased
+	assert.True(t, NewDeployment().IsPhaseStarted(0, created))
+	assert.Equal(t, 0, NewDeployment().PhaseOfDevice(3))
+}
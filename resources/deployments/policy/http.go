@@ -0,0 +1,150 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package policy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/asaskevich/govalidator"
+	"github.com/mendersoftware/go-lib-micro/identity"
+	"github.com/mendersoftware/go-lib-micro/requestid"
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/deployments/resources/deployments"
+	"github.com/mendersoftware/deployments/resources/deployments/controller"
+)
+
+// Policy events sent to the endpoint
+const (
+	EventPreCreate = "pre-create"
+	EventPreServe  = "pre-serve"
+)
+
+const (
+	DefaultTimeout = 5 * time.Second
+)
+
+// Request sent to the policy endpoint.
+type Request struct {
+	Event        string                              `json:"event"`
+	TenantID     string                              `json:"tenant_id,omitempty"`
+	DeviceID     string                              `json:"device_id,omitempty"`
+	Deployment   *deployments.DeploymentConstructor  `json:"deployment,omitempty"`
+	Instructions *deployments.DeploymentInstructions `json:"instructions,omitempty"`
+}
+
+// HTTPPolicy delegates deployment policy decisions to an external HTTP endpoint.
+//
+// For every event the endpoint receives POST request with Request body and
+// is expected to respond with:
+//
+//	204 - accept the deployment unchanged,
+//	200 - accept the deployment modified, body carries the modified object
+//	      (deployment for pre-create, instructions for pre-serve),
+//	403 - veto the deployment, optional {"error": "reason"} body.
+//
+// Any other response is considered a failure of the policy endpoint.
+type HTTPPolicy struct {
+	client *http.Client
+	uri    string
+}
+
+func NewHTTPPolicy(uri string, client *http.Client) (*HTTPPolicy, error) {
+	if !govalidator.IsURL(uri) {
+		return nil, errors.New("invalid policy endpoint uri")
+	}
+
+	if client == nil {
+		client = &http.Client{Timeout: DefaultTimeout}
+	}
+
+	return &HTTPPolicy{
+		client: client,
+		uri:    uri,
+	}, nil
+}
+
+func (p *HTTPPolicy) PreCreate(ctx context.Context,
+	constructor *deployments.DeploymentConstructor) error {
+
+	return p.call(ctx, &Request{
+		Event:      EventPreCreate,
+		Deployment: constructor,
+	}, constructor)
+}
+
+func (p *HTTPPolicy) PreServe(ctx context.Context, deviceID string,
+	instructions *deployments.DeploymentInstructions) error {
+
+	return p.call(ctx, &Request{
+		Event:        EventPreServe,
+		DeviceID:     deviceID,
+		Instructions: instructions,
+	}, instructions)
+}
+
+// call sends the request and decodes modified object into out, if returned.
+func (p *HTTPPolicy) call(ctx context.Context, request *Request, out interface{}) error {
+	if id := identity.FromContext(ctx); id != nil {
+		request.TenantID = id.Tenant
+	}
+
+	body, err := json.Marshal(request)
+	if err != nil {
+		return errors.Wrap(err, "serializing policy request")
+	}
+
+	req, err := http.NewRequest(http.MethodPost, p.uri, bytes.NewReader(body))
+	if err != nil {
+		return errors.Wrap(err, "creating policy request")
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	//propagate request id
+	reqId := ctx.Value(requestid.RequestIdHeader)
+	if reqId != nil {
+		req.Header.Set(requestid.RequestIdHeader, reqId.(string))
+	}
+
+	resp, err := p.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return errors.Wrap(err, "sending policy request")
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusNoContent:
+		return nil
+	case http.StatusOK:
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return errors.Wrap(err, "parsing policy response")
+		}
+		return nil
+	case http.StatusForbidden:
+		reason := struct {
+			Error string `json:"error"`
+		}{}
+		if err := json.NewDecoder(resp.Body).Decode(&reason); err != nil || reason.Error == "" {
+			return controller.ErrPolicyRejected
+		}
+		return errors.Wrap(controller.ErrPolicyRejected, reason.Error)
+	default:
+		return errors.Errorf("unexpected policy response status: %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,136 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package policy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mendersoftware/deployments/resources/deployments"
+	"github.com/mendersoftware/deployments/resources/deployments/controller"
+	"github.com/mendersoftware/deployments/resources/deployments/model"
+	. "github.com/mendersoftware/deployments/utils/pointers"
+)
+
+var (
+	_ model.PreCreateHook = (*HTTPPolicy)(nil)
+	_ model.PreServeHook  = (*HTTPPolicy)(nil)
+)
+
+func TestHTTPPolicyPreCreate(t *testing.T) {
+
+	t.Parallel()
+
+	testCases := map[string]struct {
+		Code int
+		Body interface{}
+
+		OutName string
+		OutErr  error
+	}{
+		"accepted": {
+			Code:    http.StatusNoContent,
+			OutName: "production",
+		},
+		"modified": {
+			Code: http.StatusOK,
+			Body: &deployments.DeploymentConstructor{
+				Name:         StringToPointer("production (canary)"),
+				ArtifactName: StringToPointer("app-1.0"),
+				Devices:      []string{"dev1"},
+			},
+			OutName: "production (canary)",
+		},
+		"rejected": {
+			Code: http.StatusForbidden,
+			Body: struct {
+				Error string `json:"error"`
+			}{Error: "outside of maintenance window"},
+			OutErr: errors.New("outside of maintenance window: Rejected by deployment policy"),
+		},
+		"rejected without reason": {
+			Code:   http.StatusForbidden,
+			OutErr: controller.ErrPolicyRejected,
+		},
+		"endpoint failure": {
+			Code:   http.StatusInternalServerError,
+			OutErr: errors.New("unexpected policy response status: 500"),
+		},
+	}
+
+	for name, test := range testCases {
+		t.Run(name, func(t *testing.T) {
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var req Request
+				assert.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+				assert.Equal(t, EventPreCreate, req.Event)
+				assert.Equal(t, "production", *req.Deployment.Name)
+
+				w.WriteHeader(test.Code)
+				if test.Body != nil {
+					assert.NoError(t, json.NewEncoder(w).Encode(test.Body))
+				}
+			}))
+			defer ts.Close()
+
+			p, err := NewHTTPPolicy(ts.URL, nil)
+			assert.NoError(t, err)
+
+			constructor := &deployments.DeploymentConstructor{
+				Name:         StringToPointer("production"),
+				ArtifactName: StringToPointer("app-1.0"),
+				Devices:      []string{"dev1"},
+			}
+
+			err = p.PreCreate(context.Background(), constructor)
+			if test.OutErr != nil {
+				assert.EqualError(t, err, test.OutErr.Error())
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, test.OutName, *constructor.Name)
+			}
+		})
+	}
+}
+
+func TestHTTPPolicyPreServe(t *testing.T) {
+
+	t.Parallel()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req Request
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		assert.Equal(t, EventPreServe, req.Event)
+		assert.Equal(t, "dev1", req.DeviceID)
+
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer ts.Close()
+
+	p, err := NewHTTPPolicy(ts.URL, nil)
+	assert.NoError(t, err)
+
+	err = p.PreServe(context.Background(), "dev1", &deployments.DeploymentInstructions{ID: "d1"})
+	assert.Equal(t, controller.ErrPolicyRejected, err)
+
+	_, err = NewHTTPPolicy("ht/localhost", nil)
+	assert.Error(t, err)
+}
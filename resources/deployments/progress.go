@@ -0,0 +1,67 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package deployments
+
+// DeploymentProgress counts devices of a single active deployment by
+// state, for monitoring of rollouts.
+type DeploymentProgress struct {
+	TenantID     string
+	DeploymentID string
+	Name         string
+
+	// Devices which did not start the update yet
+	Pending int
+	// Devices downloading, installing or rebooting
+	InProgress int
+	Failure    int
+	// Devices which were updated or already had the artifact installed
+	Success int
+	// All devices of the deployment, including aborted, decommissioned,
+	// superseded and devices without a matching artifact
+	Total int
+}
+
+// NewDeploymentProgress counts devices of the deployment from its
+// statistics.
+func NewDeploymentProgress(d *Deployment) DeploymentProgress {
+	p := DeploymentProgress{
+		Pending: d.Stats[DeviceDeploymentStatusPending],
+		InProgress: d.Stats[DeviceDeploymentStatusDownloading] +
+			d.Stats[DeviceDeploymentStatusInstalling] +
+			d.Stats[DeviceDeploymentStatusRebooting],
+		Failure: d.Stats[DeviceDeploymentStatusFailure],
+		Success: d.Stats[DeviceDeploymentStatusSuccess] +
+			d.Stats[DeviceDeploymentStatusAlreadyInst],
+	}
+	if d.Id != nil {
+		p.DeploymentID = *d.Id
+	}
+	if d.DeploymentConstructor != nil && d.Name != nil {
+		p.Name = *d.Name
+	}
+	for _, count := range d.Stats {
+		p.Total += count
+	}
+	return p
+}
+
+// PercentComplete is the percentage of devices which finished the
+// deployment, whatever the outcome.
+func (p *DeploymentProgress) PercentComplete() float64 {
+	if p.Total == 0 {
+		return 0
+	}
+	return float64(p.Total-p.Pending-p.InProgress) * 100 / float64(p.Total)
+}
@@ -0,0 +1,95 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package deployments_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	. "github.com/mendersoftware/deployments/resources/deployments"
+)
+
+func TestNewDeploymentProgress(t *testing.T) {
+
+	t.Parallel()
+
+	id := "a108ae14-bb4e-455f-9b40-2ef4bab97bb7"
+	name := "foo"
+
+	testCases := map[string]struct {
+		Stats map[string]int
+
+		Progress        DeploymentProgress
+		PercentComplete float64
+	}{
+		"no devices": {
+			Stats: NewDeviceDeploymentStats(),
+
+			Progress: DeploymentProgress{DeploymentID: id, Name: name},
+		},
+		"pending": {
+			Stats: map[string]int{DeviceDeploymentStatusPending: 4},
+
+			Progress: DeploymentProgress{
+				DeploymentID: id,
+				Name:         name,
+				Pending:      4,
+				Total:        4,
+			},
+		},
+		"in progress": {
+			Stats: map[string]int{
+				DeviceDeploymentStatusPending:        2,
+				DeviceDeploymentStatusDownloading:    1,
+				DeviceDeploymentStatusInstalling:     1,
+				DeviceDeploymentStatusRebooting:      1,
+				DeviceDeploymentStatusSuccess:        2,
+				DeviceDeploymentStatusAlreadyInst:    1,
+				DeviceDeploymentStatusFailure:        1,
+				DeviceDeploymentStatusNoArtifact:     1,
+				DeviceDeploymentStatusAborted:        0,
+				DeviceDeploymentStatusSuperseded:     0,
+				DeviceDeploymentStatusDecommissioned: 0,
+			},
+
+			Progress: DeploymentProgress{
+				DeploymentID: id,
+				Name:         name,
+				Pending:      2,
+				InProgress:   3,
+				Failure:      1,
+				Success:      3,
+				Total:        10,
+			},
+			PercentComplete: 50,
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			d := NewDeploymentFromConstructor(&DeploymentConstructor{
+				Name:         &testCase.Progress.Name,
+				ArtifactName: &testCase.Progress.Name,
+			})
+			d.Id = &id
+			d.Stats = testCase.Stats
+
+			p := NewDeploymentProgress(d)
+			assert.Equal(t, testCase.Progress, p)
+			assert.Equal(t, testCase.PercentComplete, p.PercentComplete())
+		})
+	}
+}
@@ -0,0 +1,58 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package deployments
+
+import (
+	"time"
+)
+
+// QuotaExceeded records a deployment created over a tenant limit, allowed
+// by the grace behavior of the limit.
+type QuotaExceeded struct {
+	// Name of the limit, e.g. "devices_per_deployment"
+	Limit string `json:"limit" bson:"limit"`
+	Value uint64 `json:"value" bson:"value"`
+	Usage uint64 `json:"usage" bson:"usage"`
+
+	// Grace behavior applied, e.g. "warn"
+	Decision string `json:"decision" bson:"decision"`
+}
+
+// OverQuotaDeployment is a deployment created over a tenant limit.
+type OverQuotaDeployment struct {
+	TenantID     string        `json:"tenant_id,omitempty"`
+	DeploymentID string        `json:"deployment_id"`
+	Name         string        `json:"name"`
+	Created      time.Time     `json:"created"`
+	Quota        QuotaExceeded `json:"quota"`
+}
+
+// NewOverQuotaDeployment creates report entry of the deployment, which
+// has to be created over quota.
+func NewOverQuotaDeployment(d *Deployment) OverQuotaDeployment {
+	o := OverQuotaDeployment{
+		Quota: *d.OverQuota,
+	}
+	if d.Id != nil {
+		o.DeploymentID = *d.Id
+	}
+	if d.DeploymentConstructor != nil && d.Name != nil {
+		o.Name = *d.Name
+	}
+	if d.Created != nil {
+		o.Created = *d.Created
+	}
+	return o
+}
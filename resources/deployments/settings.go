@@ -0,0 +1,91 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package deployments
+
+import "errors"
+
+// Errors
+var (
+	ErrInvalidMaxRetries = errors.New("Invalid max retries, expected non-negative number")
+)
+
+// DeploymentSettings are tenant defaults of deployment parameters, applied
+// to deployments created without them.
+type DeploymentSettings struct {
+	// Handling of devices with active deployments
+	ConflictPolicy string `json:"conflict_policy,omitempty" bson:"conflict_policy,omitempty"`
+
+	// Creation of device deployments, eager or lazy
+	DeviceDeployments string `json:"device_deployments,omitempty" bson:"device_deployments,omitempty"`
+
+	// Restrictions of artifact download time and rate
+	DownloadSchedule *DownloadSchedule `json:"download_schedule,omitempty" bson:"download_schedule,omitempty"`
+
+	// Number of times a failed or aborted device deployment can be
+	// retried, the service configuration applies if not set
+	MaxRetries *int `json:"max_retries,omitempty" bson:"max_retries,omitempty"`
+
+	// Format device IDs of new deployments have to match, any if not set
+	DeviceIDFormat *DeviceIDFormat `json:"device_id_format,omitempty" bson:"device_id_format,omitempty"`
+}
+
+// Validate checks all fields and reports each invalid one.
+// Returned error is *ValidationError.
+func (s *DeploymentSettings) Validate() error {
+	verr := &ValidationError{}
+
+	switch s.ConflictPolicy {
+	case "", ConflictPolicyReject, ConflictPolicySkip, ConflictPolicyQueue:
+	default:
+		verr.Add("conflict_policy", ValidationCodeInvalid, ErrInvalidConflictPolicy.Error())
+	}
+
+	switch s.DeviceDeployments {
+	case "", DeviceDeploymentsEager, DeviceDeploymentsLazy:
+	default:
+		verr.Add("device_deployments", ValidationCodeInvalid,
+			ErrInvalidDeviceDeployments.Error())
+	}
+
+	if s.DownloadSchedule != nil {
+		if err := s.DownloadSchedule.Validate(); err != nil {
+			verr.Add("download_schedule", ValidationCodeInvalid, err.Error())
+		}
+	}
+
+	if s.MaxRetries != nil && *s.MaxRetries < 0 {
+		verr.Add("max_retries", ValidationCodeInvalid, ErrInvalidMaxRetries.Error())
+	}
+
+	if s.DeviceIDFormat != nil {
+		s.DeviceIDFormat.validate(verr, "device_id_format")
+	}
+
+	return verr.ErrorOrNil()
+}
+
+// Apply sets parameters the deployment constructor omits to the defaults.
+func (s *DeploymentSettings) Apply(c *DeploymentConstructor) {
+	if c.ConflictPolicy == "" {
+		c.ConflictPolicy = s.ConflictPolicy
+	}
+	if c.DeviceDeployments == "" {
+		c.DeviceDeployments = s.DeviceDeployments
+	}
+	if c.DownloadSchedule == nil && s.DownloadSchedule != nil {
+		schedule := *s.DownloadSchedule
+		c.DownloadSchedule = &schedule
+	}
+}
@@ -0,0 +1,111 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package deployments_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	. "github.com/mendersoftware/deployments/resources/deployments"
+)
+
+func TestDeploymentSettingsValidate(t *testing.T) {
+
+	t.Parallel()
+
+	negative := -1
+	three := 3
+
+	testCases := map[string]struct {
+		InputSettings DeploymentSettings
+		OutputFields  []string
+	}{
+		"empty": {},
+		"valid": {
+			InputSettings: DeploymentSettings{
+				ConflictPolicy:    ConflictPolicySkip,
+				DeviceDeployments: DeviceDeploymentsLazy,
+				DownloadSchedule: &DownloadSchedule{
+					Windows: []DownloadWindow{{Start: "22:00", End: "06:00"}},
+				},
+				MaxRetries: &three,
+				DeviceIDFormat: &DeviceIDFormat{
+					Type:    DeviceIDFormatRegex,
+					Pattern: "[0-9a-f]{24}",
+				},
+			},
+		},
+		"invalid": {
+			InputSettings: DeploymentSettings{
+				ConflictPolicy:    "ignore",
+				DeviceDeployments: "later",
+				DownloadSchedule:  &DownloadSchedule{MaxDownloadsPerMinute: -1},
+				MaxRetries:        &negative,
+				DeviceIDFormat:    &DeviceIDFormat{Type: "email"},
+			},
+			OutputFields: []string{
+				"conflict_policy", "device_deployments", "download_schedule", "max_retries",
+				"device_id_format.type",
+			},
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			err := testCase.InputSettings.Validate()
+			if testCase.OutputFields == nil {
+				assert.NoError(t, err)
+				return
+			}
+			if assert.IsType(t, &ValidationError{}, err) {
+				var fields []string
+				for _, f := range err.(*ValidationError).Fields {
+					fields = append(fields, f.Field)
+				}
+				assert.Equal(t, testCase.OutputFields, fields)
+			}
+		})
+	}
+}
+
+func TestDeploymentSettingsApply(t *testing.T) {
+
+	t.Parallel()
+
+	schedule := &DownloadSchedule{MaxDownloadsPerMinute: 10}
+	settings := DeploymentSettings{
+		ConflictPolicy:    ConflictPolicySkip,
+		DeviceDeployments: DeviceDeploymentsLazy,
+		DownloadSchedule:  schedule,
+	}
+
+	omitted := DeploymentConstructor{}
+	settings.Apply(&omitted)
+	assert.Equal(t, ConflictPolicySkip, omitted.ConflictPolicy)
+	assert.Equal(t, DeviceDeploymentsLazy, omitted.DeviceDeployments)
+	assert.Equal(t, schedule, omitted.DownloadSchedule)
+	assert.False(t, schedule == omitted.DownloadSchedule)
+
+	given := DeploymentConstructor{
+		ConflictPolicy:    ConflictPolicyReject,
+		DeviceDeployments: DeviceDeploymentsEager,
+		DownloadSchedule:  &DownloadSchedule{MaxDownloadsPerMinute: 1},
+	}
+	settings.Apply(&given)
+	assert.Equal(t, ConflictPolicyReject, given.ConflictPolicy)
+	assert.Equal(t, DeviceDeploymentsEager, given.DeviceDeployments)
+	assert.Equal(t, 1, given.DownloadSchedule.MaxDownloadsPerMinute)
+}
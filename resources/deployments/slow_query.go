@@ -0,0 +1,42 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package deployments
+
+import (
+	"time"
+)
+
+// SlowQuery describes how the database executed a query which took
+// longer than expected.
+type SlowQuery struct {
+	// Namespace is the database and collection queried
+	Namespace string `json:"namespace"`
+
+	// Operation type, e.g. query, update, command
+	Operation string `json:"operation"`
+
+	Duration time.Duration `json:"duration"`
+
+	// Summary of the query plan, e.g. "COLLSCAN" if no index was used
+	PlanSummary string `json:"plan_summary"`
+
+	KeysExamined int `json:"keys_examined"`
+	DocsExamined int `json:"docs_examined"`
+	Returned     int `json:"returned"`
+
+	// Command as executed and statistics of each stage of the plan
+	Command interface{} `json:"command,omitempty"`
+	Plan    interface{} `json:"plan,omitempty"`
+}
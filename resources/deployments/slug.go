@@ -0,0 +1,78 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package deployments
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+
+	"github.com/asaskevich/govalidator"
+)
+
+// Slugs
+const (
+	SlugMaxLength = 64
+	// Slug of deployments with names without any letters or digits
+	SlugDefault = "deployment"
+)
+
+var (
+	ErrInvalidSlug = errors.New("Invalid slug, expected lowercase letters, digits and hyphens")
+
+	slugPattern   = regexp.MustCompile("^[a-z0-9]+(-[a-z0-9]+)*$")
+	slugSeparator = regexp.MustCompile("[^a-z0-9]+")
+
+	// static segments of routes under /deployments/, which would take
+	// precedence over deployments with such slugs
+	reservedSlugs = map[string]bool{
+		"devices":         true,
+		"downloads":       true,
+		"override-freeze": true,
+		"releases":        true,
+		"stats":           true,
+		"stream":          true,
+	}
+)
+
+// IsValidSlug checks if slug consists of lowercase letters and digits
+// separated by single hyphens. Slugs can not be UUIDs, so that they can
+// be used in place of deployment IDs, nor segments of other routes.
+func IsValidSlug(slug string) bool {
+	return len(slug) <= SlugMaxLength &&
+		slugPattern.MatchString(slug) &&
+		!govalidator.IsUUID(slug) &&
+		!reservedSlugs[slug]
+}
+
+// Slugify makes slug of the deployment name, leaving room for a suffix
+// making it unique. Names making reserved or UUID slugs get SlugDefault
+// suffix.
+func Slugify(name string) string {
+	slug := slugSeparator.ReplaceAllString(strings.ToLower(name), "-")
+	slug = strings.Trim(slug, "-")
+
+	if max := SlugMaxLength - 16; len(slug) > max {
+		slug = strings.TrimRight(slug[:max], "-")
+	}
+	if slug == "" {
+		return SlugDefault
+	}
+	if !IsValidSlug(slug) {
+		return slug + "-" + SlugDefault
+	}
+
+	return slug
+}
@@ -0,0 +1,67 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package deployments_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	. "github.com/mendersoftware/deployments/resources/deployments"
+)
+
+func TestIsValidSlug(t *testing.T) {
+	for slug, valid := range map[string]bool{
+		"nyc-production":                       true,
+		"release-1-2":                          true,
+		"2019":                                 true,
+		"":                                     false,
+		"NYC":                                  false,
+		"nyc--production":                      false,
+		"-nyc":                                 false,
+		"nyc-":                                 false,
+		"nyc production":                       false,
+		"d50eda0d-2cea-4de1-8d42-9cd3e7e8670d": false,
+		"devices":                              false,
+		"downloads":                            false,
+		"override-freeze":                      false,
+		"releases":                             false,
+		"stats":                                false,
+		"stream":                               false,
+		"devices-2":                            true,
+		strings.Repeat("a", SlugMaxLength):     true,
+		strings.Repeat("a", SlugMaxLength+1):   false,
+	} {
+		assert.Equal(t, valid, IsValidSlug(slug), slug)
+	}
+}
+
+func TestSlugify(t *testing.T) {
+	for name, slug := range map[string]string{
+		"NYC Production":                       "nyc-production",
+		"  Release 1.2 (hotfix)! ":             "release-1-2-hotfix",
+		"Größe":                                "gr-e",
+		"!!!":                                  SlugDefault,
+		"":                                     SlugDefault,
+		"Devices":                              "devices-deployment",
+		"Override freeze":                      "override-freeze-deployment",
+		"D50EDA0D-2CEA-4DE1-8D42-9CD3E7E8670D": "d50eda0d-2cea-4de1-8d42-9cd3e7e8670d-deployment",
+		strings.Repeat("ab ", 40):              strings.TrimRight(strings.Repeat("ab-", 16), "-"),
+	} {
+		assert.Equal(t, slug, Slugify(name), name)
+		assert.True(t, IsValidSlug(Slugify(name)), name)
+	}
+}
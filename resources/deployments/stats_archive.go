@@ -0,0 +1,61 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package deployments
+
+import (
+	"time"
+)
+
+// ArchivedStats are the final statistics of a deployment, kept after the
+// deployment finished for reporting.
+type ArchivedStats struct {
+	DeploymentID string     `json:"deployment_id" bson:"_id"`
+	Name         string     `json:"name" bson:"name"`
+	ArtifactName string     `json:"artifact_name" bson:"artifact_name"`
+	Created      *time.Time `json:"created,omitempty" bson:"created,omitempty"`
+	Finished     *time.Time `json:"finished,omitempty" bson:"finished,omitempty"`
+
+	// Deployment was aborted rather than finished by all devices
+	Aborted bool `json:"aborted,omitempty" bson:"aborted,omitempty"`
+
+	// Number of devices by device deployment status
+	Stats Stats `json:"stats" bson:"stats"`
+}
+
+// NewArchivedStats copies final statistics of the deployment.
+func NewArchivedStats(d *Deployment) *ArchivedStats {
+	archived := &ArchivedStats{
+		Created:  d.Created,
+		Finished: d.Finished,
+		Aborted:  d.Abort != nil,
+		Stats:    Stats{},
+	}
+	if d.Id != nil {
+		archived.DeploymentID = *d.Id
+	}
+	if d.DeploymentConstructor != nil {
+		if d.Name != nil {
+			archived.Name = *d.Name
+		}
+		if d.ArtifactName != nil {
+			archived.ArtifactName = *d.ArtifactName
+		}
+	}
+	for status, count := range d.Stats {
+		archived.Stats[status] = count
+	}
+
+	return archived
+}
@@ -0,0 +1,68 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package deployments_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	. "github.com/mendersoftware/deployments/resources/deployments"
+	. "github.com/mendersoftware/deployments/utils/pointers"
+)
+
+func TestNewArchivedStats(t *testing.T) {
+
+	t.Parallel()
+
+	created := time.Date(2018, 1, 2, 3, 4, 5, 0, time.UTC)
+	finished := created.Add(time.Hour)
+
+	deployment := &Deployment{
+		Id: StringToPointer("a108ae14-bb4e-455f-9b40-2ef4bab97bb7"),
+		DeploymentConstructor: &DeploymentConstructor{
+			Name:         StringToPointer("foo"),
+			ArtifactName: StringToPointer("bar"),
+		},
+		Created:  &created,
+		Finished: &finished,
+		Abort:    &AbortInfo{Reason: "bricks devices"},
+		Stats: map[string]int{
+			DeviceDeploymentStatusSuccess: 2,
+			DeviceDeploymentStatusAborted: 1,
+		},
+	}
+
+	archived := NewArchivedStats(deployment)
+	assert.Equal(t, &ArchivedStats{
+		DeploymentID: "a108ae14-bb4e-455f-9b40-2ef4bab97bb7",
+		Name:         "foo",
+		ArtifactName: "bar",
+		Created:      &created,
+		Finished:     &finished,
+		Aborted:      true,
+		Stats: Stats{
+			DeviceDeploymentStatusSuccess: 2,
+			DeviceDeploymentStatusAborted: 1,
+		},
+	}, archived)
+
+	// statistics are copied
+	deployment.Stats[DeviceDeploymentStatusSuccess] = 3
+	assert.Equal(t, 2, archived.Stats[DeviceDeploymentStatusSuccess])
+
+	assert.Equal(t, &ArchivedStats{Stats: Stats{}}, NewArchivedStats(&Deployment{}))
+}
@@ -0,0 +1,60 @@
+// Copyright 2018 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package deployments
+
+import (
+	"time"
+)
+
+const (
+	// Granularity of device deployment statistics rollups
+	StatsRollupPeriod = time.Hour
+)
+
+// Number of deployments in each state
+type DeploymentsCount struct {
+	Pending    int `json:"pending"`
+	InProgress int `json:"inprogress"`
+	Finished   int `json:"finished"`
+}
+
+// StatsSummary is a tenant wide overview of deployments for dashboards.
+type StatsSummary struct {
+	Deployments DeploymentsCount `json:"deployments"`
+
+	// Number of devices successfully updated in the last 24 hours and 7 days
+	DevicesUpdated24h int `json:"devices_updated_24h"`
+	DevicesUpdated7d  int `json:"devices_updated_7d"`
+
+	// Ratio of failed to all finished device deployments in the last 7 days
+	FailureRate float64 `json:"failure_rate"`
+}
+
+// NewStatsSummary computes summary from deployment counts and device
+// deployment statistics of the last day and week.
+func NewStatsSummary(count DeploymentsCount, day, week Stats) *StatsSummary {
+	summary := &StatsSummary{
+		Deployments:       count,
+		DevicesUpdated24h: day[DeviceDeploymentStatusSuccess],
+		DevicesUpdated7d:  week[DeviceDeploymentStatusSuccess],
+	}
+
+	finished := week[DeviceDeploymentStatusSuccess] + week[DeviceDeploymentStatusFailure]
+	if finished > 0 {
+		summary.FailureRate = float64(week[DeviceDeploymentStatusFailure]) / float64(finished)
+	}
+
+	return summary
+}
@@ -0,0 +1,46 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package deployments_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	. "github.com/mendersoftware/deployments/resources/deployments"
+)
+
+func TestNewStatsSummary(t *testing.T) {
+
+	t.Parallel()
+
+	count := DeploymentsCount{Pending: 1, InProgress: 2, Finished: 3}
+
+	summary := NewStatsSummary(count, NewDeviceDeploymentStats(), NewDeviceDeploymentStats())
+	assert.Equal(t, &StatsSummary{Deployments: count}, summary)
+
+	day := Stats{DeviceDeploymentStatusSuccess: 3, DeviceDeploymentStatusFailure: 1}
+	week := Stats{
+		DeviceDeploymentStatusSuccess:    9,
+		DeviceDeploymentStatusFailure:    1,
+		DeviceDeploymentStatusAborted:    5,
+		DeviceDeploymentStatusNoArtifact: 2,
+	}
+
+	summary = NewStatsSummary(count, day, week)
+	assert.Equal(t, 3, summary.DevicesUpdated24h)
+	assert.Equal(t, 9, summary.DevicesUpdated7d)
+	assert.Equal(t, 0.1, summary.FailureRate)
+}
@@ -0,0 +1,103 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package deployments
+
+import (
+	"github.com/pkg/errors"
+)
+
+// Deployment statuses, as reported in the status field of deployments
+const (
+	DeploymentStatusPending    = "pending"
+	DeploymentStatusRestoring  = "restoring"
+	DeploymentStatusInProgress = "inprogress"
+	DeploymentStatusFinished   = "finished"
+)
+
+// StatusNames maps internal deployment and device deployment statuses to
+// names displayed to users, e.g. matching lifecycle terminology of a UI.
+// Statuses without display name are displayed as they are.
+type StatusNames struct {
+	display  map[string]string
+	internal map[string]string
+}
+
+// NewStatusNames creates mapping of statuses to display names. Only known
+// statuses can be renamed, display names have to be unique and must not be
+// another status, so that filters accept both.
+func NewStatusNames(display map[string]string) (*StatusNames, error) {
+	names := &StatusNames{
+		display:  make(map[string]string, len(display)),
+		internal: make(map[string]string, len(display)),
+	}
+
+	for status, name := range display {
+		if !isStatus(status) {
+			return nil, errors.Errorf("unknown status %s", status)
+		}
+		if name == "" || name == status {
+			continue
+		}
+		if isStatus(name) {
+			return nil, errors.Errorf("status %s displayed as another status %s",
+				status, name)
+		}
+		if other, ok := names.internal[name]; ok {
+			return nil, errors.Errorf("statuses %s and %s displayed as %s",
+				other, status, name)
+		}
+		names.display[status] = name
+		names.internal[name] = status
+	}
+
+	return names, nil
+}
+
+// Empty tells whether no status is renamed.
+func (n *StatusNames) Empty() bool {
+	return n == nil || len(n.display) == 0
+}
+
+// Display returns the display name of the status.
+func (n *StatusNames) Display(status string) string {
+	if n == nil {
+		return status
+	}
+	if name, ok := n.display[status]; ok {
+		return name
+	}
+	return status
+}
+
+// Internal returns the status displayed under the name; statuses are
+// returned as they are.
+func (n *StatusNames) Internal(name string) string {
+	if n == nil {
+		return name
+	}
+	if status, ok := n.internal[name]; ok {
+		return status
+	}
+	return name
+}
+
+func isStatus(status string) bool {
+	switch status {
+	case DeploymentStatusPending, DeploymentStatusRestoring,
+		DeploymentStatusInProgress, DeploymentStatusFinished:
+		return true
+	}
+	return IsDeviceDeploymentStatus(status)
+}
@@ -0,0 +1,80 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package deployments_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	. "github.com/mendersoftware/deployments/resources/deployments"
+)
+
+func TestNewStatusNames(t *testing.T) {
+
+	t.Parallel()
+
+	testCases := map[string]struct {
+		display map[string]string
+		err     string
+	}{
+		"ok": {
+			display: map[string]string{
+				DeviceDeploymentStatusSuccess: "installed",
+				DeploymentStatusInProgress:    "rolling-out",
+				DeviceDeploymentStatusFailure: "",
+			},
+		},
+		"empty": {},
+		"unknown status": {
+			display: map[string]string{"done": "installed"},
+			err:     "unknown status done",
+		},
+		"another status": {
+			display: map[string]string{DeviceDeploymentStatusSuccess: DeviceDeploymentStatusFailure},
+			err:     "status success displayed as another status failure",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			names, err := NewStatusNames(tc.display)
+			if tc.err != "" {
+				assert.EqualError(t, err, tc.err)
+				return
+			}
+
+			assert.NoError(t, err)
+			for status, name := range tc.display {
+				if name == "" {
+					name = status
+				}
+				assert.Equal(t, name, names.Display(status))
+				assert.Equal(t, status, names.Internal(name))
+			}
+		})
+	}
+
+	_, err := NewStatusNames(map[string]string{
+		DeviceDeploymentStatusSuccess:     "done",
+		DeviceDeploymentStatusAlreadyInst: "done",
+	})
+	assert.Error(t, err)
+
+	var names *StatusNames
+	assert.True(t, names.Empty())
+	assert.Equal(t, "success", names.Display("success"))
+	assert.Equal(t, "installed", names.Internal("installed"))
+}
@@ -0,0 +1,77 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package deployments
+
+import (
+	"errors"
+	"strings"
+
+	pkgerrors "github.com/pkg/errors"
+)
+
+// Kinds of storage errors, compare with IsStoreError.
+var (
+	ErrStorageNotFound     = errors.New("Not found")
+	ErrStorageInvalidID    = errors.New("Invalid id")
+	ErrStorageInvalidInput = errors.New("invalid input")
+	// finished status of a device deployment can not be changed back
+	ErrStorageFinishedStatus = errors.New("Status already finished")
+)
+
+// StoreError describes failed storage operation: the store method,
+// collection and IDs of the documents involved. Err is the kind of failure.
+type StoreError struct {
+	Op         string
+	Collection string
+	IDs        []string
+	Err        error
+}
+
+// NewStoreError creates storage error of given kind for documents with ids.
+func NewStoreError(op, collection string, err error, ids ...string) *StoreError {
+	return &StoreError{
+		Op:         op,
+		Collection: collection,
+		IDs:        ids,
+		Err:        err,
+	}
+}
+
+func (e *StoreError) Error() string {
+	msg := e.Op
+	if e.Collection != "" {
+		msg += " " + e.Collection
+	}
+	if len(e.IDs) > 0 {
+		msg += " [" + strings.Join(e.IDs, ", ") + "]"
+	}
+	return msg + ": " + e.Err.Error()
+}
+
+func (e *StoreError) Unwrap() error {
+	return e.Err
+}
+
+// IsStoreError checks if err, possibly wrapped, is a storage error of given kind.
+func IsStoreError(err error, kind error) bool {
+	return errors.Is(pkgerrors.Cause(err), kind)
+}
+
+// AsStoreError extracts storage error details from err, possibly wrapped.
+func AsStoreError(err error) (*StoreError, bool) {
+	var storeErr *StoreError
+	ok := errors.As(pkgerrors.Cause(err), &storeErr)
+	return storeErr, ok
+}
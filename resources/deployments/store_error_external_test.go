@@ -0,0 +1,84 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package deployments_test
+
+import (
+	"errors"
+	"testing"
+
+	pkgerrors "github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+
+	. "github.com/mendersoftware/deployments/resources/deployments"
+)
+
+func TestStoreError(t *testing.T) {
+
+	t.Parallel()
+
+	testCases := []struct {
+		InputError error
+
+		OutputMessage string
+		OutputKind    error
+		OutputOp      string
+	}{
+		{
+			InputError: NewStoreError("FindByID", "deployments",
+				ErrStorageInvalidID, ""),
+			OutputMessage: "FindByID deployments []: Invalid id",
+			OutputKind:    ErrStorageInvalidID,
+			OutputOp:      "FindByID",
+		},
+		{
+			InputError: NewStoreError("RevokeDeviceDeploymentLink", "devices",
+				ErrStorageNotFound, "device-1", "deployment-1"),
+			OutputMessage: "RevokeDeviceDeploymentLink devices [device-1, deployment-1]: Not found",
+			OutputKind:    ErrStorageNotFound,
+			OutputOp:      "RevokeDeviceDeploymentLink",
+		},
+		{
+			InputError: pkgerrors.Wrap(NewStoreError("InsertFreezePeriod", "freeze_periods",
+				ErrStorageInvalidInput), "failed to create freeze period"),
+			OutputMessage: "failed to create freeze period: InsertFreezePeriod freeze_periods: invalid input",
+			OutputKind:    ErrStorageInvalidInput,
+			OutputOp:      "InsertFreezePeriod",
+		},
+		{
+			InputError:    ErrStorageNotFound,
+			OutputMessage: "Not found",
+			OutputKind:    ErrStorageNotFound,
+		},
+		{
+			InputError:    errors.New("Not found"),
+			OutputMessage: "Not found",
+		},
+	}
+
+	for _, tc := range testCases {
+		assert.EqualError(t, tc.InputError, tc.OutputMessage)
+
+		for _, kind := range []error{ErrStorageNotFound, ErrStorageInvalidID,
+			ErrStorageInvalidInput} {
+			assert.Equal(t, kind == tc.OutputKind, IsStoreError(tc.InputError, kind))
+		}
+
+		storeErr, ok := AsStoreError(tc.InputError)
+		assert.Equal(t, tc.OutputOp != "", ok)
+		if ok {
+			assert.Equal(t, tc.OutputOp, storeErr.Op)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package mongo
+
+import (
+	"context"
+	"time"
+
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+
+	"github.com/mendersoftware/deployments/resources/deployments/subscriber"
+)
+
+// Database
+//
+// Device events are read from capped collection of the message bus
+// database shared with the device authentication service; position of the
+// subscriber is stored in the main database of the service.
+const (
+	DatabaseName            = "deployment_service"
+	CollectionDeviceEvents  = "device_events"
+	CollectionSubscriptions = "subscriptions"
+)
+
+// Keys
+const (
+	StorageKeyEventID            = "_id"
+	StorageKeySubscriptionOffset = "offset"
+)
+
+// DefaultTailTimeout limits time single read waits for new events, so that
+// cancellation of the context is noticed.
+const DefaultTailTimeout = 5 * time.Second
+
+type eventDocument struct {
+	ID       bson.ObjectId `bson:"_id"`
+	Type     string        `bson:"type"`
+	DeviceID string        `bson:"device_id"`
+	TenantID string        `bson:"tenant_id,omitempty"`
+}
+
+type subscriptionDocument struct {
+	Name   string        `bson:"_id"`
+	Offset bson.ObjectId `bson:"offset"`
+}
+
+// Source tails device events of the message bus capped collection.
+// Implements subscriber.Source
+type Source struct {
+	session  *mgo.Session
+	database string
+	name     string
+	timeout  time.Duration
+
+	iter *mgo.Iter
+}
+
+// NewSource creates source reading events from the database of the
+// message bus; name identifies position of the subscriber.
+func NewSource(session *mgo.Session, database, name string) *Source {
+	return &Source{
+		session:  session.Copy(),
+		database: database,
+		name:     name,
+		timeout:  DefaultTailTimeout,
+	}
+}
+
+// Next returns event following the last returned one, or the last
+// committed one after restart.
+func (s *Source) Next(ctx context.Context) (*subscriber.DeviceEvent, error) {
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		if s.iter == nil {
+			iter, err := s.tail()
+			if err != nil {
+				return nil, err
+			}
+			s.iter = iter
+		}
+
+		var doc eventDocument
+		if s.iter.Next(&doc) {
+			return &subscriber.DeviceEvent{
+				ID:       doc.ID.Hex(),
+				Type:     doc.Type,
+				DeviceID: doc.DeviceID,
+				TenantID: doc.TenantID,
+			}, nil
+		}
+
+		if s.iter.Timeout() {
+			continue
+		}
+
+		// cursor is dead, e.g. collection was empty; tail again from the
+		// last committed event
+		err := s.iter.Close()
+		s.iter = nil
+		if err != nil {
+			s.session.Refresh()
+			return nil, err
+		}
+
+		select {
+		case <-ctx.Done():
+		case <-time.After(s.timeout):
+		}
+	}
+}
+
+func (s *Source) tail() (*mgo.Iter, error) {
+	query := bson.M{}
+
+	var sub subscriptionDocument
+	err := s.session.DB(DatabaseName).C(CollectionSubscriptions).
+		FindId(s.name).One(&sub)
+	switch err {
+	case nil:
+		query[StorageKeyEventID] = bson.M{"$gt": sub.Offset}
+	case mgo.ErrNotFound:
+	default:
+		return nil, err
+	}
+
+	return s.session.DB(s.database).C(CollectionDeviceEvents).
+		Find(query).Sort("$natural").Tail(s.timeout), nil
+}
+
+// Commit stores position of the subscriber.
+func (s *Source) Commit(ctx context.Context, event *subscriber.DeviceEvent) error {
+	if !bson.IsObjectIdHex(event.ID) {
+		return subscriber.ErrInvalidEventID
+	}
+
+	_, err := s.session.DB(DatabaseName).C(CollectionSubscriptions).
+		UpsertId(s.name, bson.M{
+			"$set": bson.M{
+				StorageKeySubscriptionOffset: bson.ObjectIdHex(event.ID),
+			},
+		})
+	return err
+}
+
+// Close releases the database session.
+func (s *Source) Close() {
+	if s.iter != nil {
+		s.iter.Close()
+	}
+	s.session.Close()
+}
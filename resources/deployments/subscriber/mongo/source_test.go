@@ -0,0 +1,92 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package mongo
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mendersoftware/deployments/resources/deployments/subscriber"
+)
+
+func TestSourceNextCommit(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping TestSourceNextCommit in short mode.")
+	}
+
+	db.Wipe()
+	session := db.Session()
+	defer session.Close()
+
+	events := session.DB("bus").C(CollectionDeviceEvents)
+	assert.NoError(t, events.Create(&mgo.CollectionInfo{
+		Capped:   true,
+		MaxBytes: 1024 * 1024,
+	}))
+
+	docs := []eventDocument{
+		{ID: bson.NewObjectId(), Type: subscriber.EventDeviceDecommissioned, DeviceID: "foo"},
+		{ID: bson.NewObjectId(), Type: subscriber.EventDeviceDecommissioned, DeviceID: "bar",
+			TenantID: "acme"},
+		{ID: bson.NewObjectId(), Type: "device.provisioned", DeviceID: "baz"},
+	}
+	for _, doc := range docs {
+		assert.NoError(t, events.Insert(doc))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	source := NewSource(session, "bus", "test")
+	source.timeout = 100 * time.Millisecond
+	for _, doc := range docs[:2] {
+		event, err := source.Next(ctx)
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		assert.Equal(t, &subscriber.DeviceEvent{
+			ID:       doc.ID.Hex(),
+			Type:     doc.Type,
+			DeviceID: doc.DeviceID,
+			TenantID: doc.TenantID,
+		}, event)
+		assert.NoError(t, source.Commit(ctx, event))
+	}
+	source.Close()
+
+	// restarted source continues after the last committed event
+	source = NewSource(session, "bus", "test")
+	source.timeout = 100 * time.Millisecond
+	event, err := source.Next(ctx)
+	assert.NoError(t, err)
+	if assert.NotNil(t, event) {
+		assert.Equal(t, docs[2].ID.Hex(), event.ID)
+	}
+
+	// source waits for new events until the context is done
+	waitCtx, waitCancel := context.WithTimeout(ctx, 300*time.Millisecond)
+	defer waitCancel()
+	_, err = source.Next(waitCtx)
+	assert.Equal(t, context.DeadlineExceeded, err)
+
+	assert.Equal(t, subscriber.ErrInvalidEventID,
+		source.Commit(ctx, &subscriber.DeviceEvent{ID: "foo"}))
+	source.Close()
+}
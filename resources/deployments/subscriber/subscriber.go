@@ -0,0 +1,158 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package subscriber
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/mendersoftware/go-lib-micro/identity"
+	"github.com/mendersoftware/go-lib-micro/log"
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/deployments/resources/deployments"
+)
+
+// Device event types published by the device authentication service
+const (
+	EventDeviceDecommissioned = "device.decommissioned"
+)
+
+var (
+	ErrInvalidEventID = errors.New("invalid event id")
+)
+
+// JobTypeDecommission is the background job cleaning up deployments of
+// single decommissioned device.
+const JobTypeDecommission = "device_decommission"
+
+// DefaultRetryInterval is the delay before reading from the source again
+// after it failed.
+const DefaultRetryInterval = 5 * time.Second
+
+// DeviceEvent is a device lifecycle change received from the message bus.
+type DeviceEvent struct {
+	ID       string `json:"id"`
+	Type     string `json:"type"`
+	DeviceID string `json:"device_id"`
+	TenantID string `json:"tenant_id,omitempty"`
+}
+
+// Source delivers device events in order. Events are delivered at least
+// once: events received after the last committed one are delivered again
+// when the subscriber restarts.
+type Source interface {
+	// Next blocks until the next event is available or ctx is done.
+	Next(ctx context.Context) (*DeviceEvent, error)
+	// Commit records the event, and all events before it, as processed.
+	Commit(ctx context.Context, event *DeviceEvent) error
+}
+
+// JobQueue runs work in the background, retrying failed jobs.
+type JobQueue interface {
+	Register(jobType string,
+		handler func(ctx context.Context, payload json.RawMessage) error)
+	Enqueue(ctx context.Context, jobType string, payload interface{}) error
+}
+
+// Decommissioner cleans up deployments of removed devices.
+type Decommissioner interface {
+	DecommissionDevice(ctx context.Context, deviceID string) error
+}
+
+// Subscriber processes device events from the message bus, so that
+// deployments of devices removed in the device authentication service are
+// cleaned up even if the synchronous internal API call was missed.
+//
+// Events are turned into background jobs, retried on failure; processing
+// is idempotent, so that redelivered events and events for devices already
+// decommissioned through the API are harmless.
+type Subscriber struct {
+	source        Source
+	jobs          JobQueue
+	retryInterval time.Duration
+}
+
+// NewSubscriber creates subscriber of the source, decommissioning devices
+// through the job queue.
+func NewSubscriber(source Source, jobs JobQueue, model Decommissioner) *Subscriber {
+	jobs.Register(JobTypeDecommission,
+		func(ctx context.Context, payload json.RawMessage) error {
+			var event DeviceEvent
+			if err := json.Unmarshal(payload, &event); err != nil {
+				return errors.Wrap(err, "decoding event")
+			}
+			err := model.DecommissionDevice(ctx, event.DeviceID)
+			if err != nil && !deployments.IsStoreError(err, deployments.ErrStorageNotFound) {
+				return errors.Wrapf(err, "decommissioning device %s", event.DeviceID)
+			}
+			return nil
+		})
+
+	return &Subscriber{
+		source:        source,
+		jobs:          jobs,
+		retryInterval: DefaultRetryInterval,
+	}
+}
+
+// Run processes events until ctx is done.
+func (s *Subscriber) Run(ctx context.Context) {
+	l := log.FromContext(ctx)
+
+	for {
+		err := s.ProcessNext(ctx)
+		if err == nil {
+			continue
+		}
+		if ctx.Err() != nil {
+			return
+		}
+
+		l.Errorf("processing device event: %s", err.Error())
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(s.retryInterval):
+		}
+	}
+}
+
+// ProcessNext waits for the next event and queues its processing.
+// Events of other types are skipped.
+func (s *Subscriber) ProcessNext(ctx context.Context) error {
+	event, err := s.source.Next(ctx)
+	if err != nil {
+		return errors.Wrap(err, "receiving event")
+	}
+
+	if event.Type == EventDeviceDecommissioned && event.DeviceID == "" {
+		log.FromContext(ctx).Warnf("skipping event %s without device id", event.ID)
+	} else if event.Type == EventDeviceDecommissioned {
+		jobCtx := ctx
+		if event.TenantID != "" {
+			jobCtx = identity.WithContext(ctx, &identity.Identity{Tenant: event.TenantID})
+		}
+		if err := s.jobs.Enqueue(jobCtx, JobTypeDecommission, event); err != nil {
+			return errors.Wrapf(err, "queueing event %s", event.ID)
+		}
+	}
+
+	if err := s.source.Commit(ctx, event); err != nil {
+		return errors.Wrapf(err, "committing event %s", event.ID)
+	}
+	return nil
+}
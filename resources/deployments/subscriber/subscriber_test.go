@@ -0,0 +1,154 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package subscriber
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/mendersoftware/go-lib-micro/identity"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mendersoftware/deployments/resources/deployments"
+)
+
+type fakeSource struct {
+	events    []*DeviceEvent
+	err       error
+	committed []string
+}
+
+func (s *fakeSource) Next(ctx context.Context) (*DeviceEvent, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	event := s.events[0]
+	s.events = s.events[1:]
+	return event, nil
+}
+
+func (s *fakeSource) Commit(ctx context.Context, event *DeviceEvent) error {
+	s.committed = append(s.committed, event.ID)
+	return nil
+}
+
+type fakeJobQueue struct {
+	jobType  string
+	handler  func(ctx context.Context, payload json.RawMessage) error
+	enqueued []json.RawMessage
+	tenants  []string
+	err      error
+}
+
+func (q *fakeJobQueue) Register(jobType string,
+	handler func(ctx context.Context, payload json.RawMessage) error) {
+	q.jobType = jobType
+	q.handler = handler
+}
+
+func (q *fakeJobQueue) Enqueue(ctx context.Context, jobType string, payload interface{}) error {
+	if q.err != nil {
+		return q.err
+	}
+	data, err := json.Marshal(payload)
+	q.enqueued = append(q.enqueued, data)
+	tenant := ""
+	if id := identity.FromContext(ctx); id != nil {
+		tenant = id.Tenant
+	}
+	q.tenants = append(q.tenants, tenant)
+	return err
+}
+
+type fakeDecommissioner struct {
+	devices []string
+	err     error
+}
+
+func (d *fakeDecommissioner) DecommissionDevice(ctx context.Context, deviceID string) error {
+	d.devices = append(d.devices, deviceID)
+	return d.err
+}
+
+func TestSubscriberProcessNext(t *testing.T) {
+
+	t.Parallel()
+
+	source := &fakeSource{
+		events: []*DeviceEvent{
+			{ID: "1", Type: EventDeviceDecommissioned, DeviceID: "foo", TenantID: "acme"},
+			{ID: "2", Type: "device.provisioned", DeviceID: "bar"},
+			{ID: "3", Type: EventDeviceDecommissioned},
+			{ID: "4", Type: EventDeviceDecommissioned, DeviceID: "baz"},
+		},
+	}
+	jobs := &fakeJobQueue{}
+	model := &fakeDecommissioner{}
+	sub := NewSubscriber(source, jobs, model)
+	assert.Equal(t, JobTypeDecommission, jobs.jobType)
+
+	ctx := context.Background()
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, sub.ProcessNext(ctx))
+	}
+	assert.Equal(t, []string{"1", "2", "3"}, source.committed)
+	assert.Equal(t, []string{"acme"}, jobs.tenants)
+	assert.Empty(t, model.devices)
+
+	// event is not committed until queued
+	jobs.err = errors.New("storage issue")
+	assert.EqualError(t, sub.ProcessNext(ctx), "queueing event 4: storage issue")
+	assert.Len(t, source.committed, 3)
+
+	source.err = errors.New("connection lost")
+	assert.EqualError(t, sub.ProcessNext(ctx), "receiving event: connection lost")
+
+	// decommissioning happens in the job, its errors are returned for retry
+	if assert.Len(t, jobs.enqueued, 1) {
+		model.err = errors.New("storage issue")
+		assert.EqualError(t, jobs.handler(ctx, jobs.enqueued[0]),
+			"decommissioning device foo: storage issue")
+
+		model.err = deployments.NewStoreError("DecommissionDeviceDeployments", "devices",
+			deployments.ErrStorageNotFound, "foo")
+		assert.NoError(t, jobs.handler(ctx, jobs.enqueued[0]))
+
+		model.err = nil
+		assert.NoError(t, jobs.handler(ctx, jobs.enqueued[0]))
+		assert.Equal(t, []string{"foo", "foo", "foo"}, model.devices)
+	}
+
+	assert.Error(t, jobs.handler(ctx, json.RawMessage(`[]`)))
+}
+
+func TestSubscriberRun(t *testing.T) {
+
+	t.Parallel()
+
+	source := &fakeSource{err: errors.New("connection lost")}
+	sub := NewSubscriber(source, &fakeJobQueue{}, &fakeDecommissioner{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	done := make(chan struct{})
+	go func() {
+		sub.Run(ctx)
+		close(done)
+	}()
+	<-done
+}
@@ -0,0 +1,92 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package deployments
+
+import (
+	"errors"
+	"time"
+)
+
+// Errors
+var (
+	ErrInvalidDownloadSpeed   = errors.New("Download speed must not be negative")
+	ErrInvalidInstallDuration = errors.New("Install duration must not be negative")
+	ErrInvalidBatteryLevel    = errors.New("Battery level must be between 0 and 100")
+)
+
+// Metrics of the device telemetry
+const (
+	TelemetryDownloadSpeed   = "download_speed"
+	TelemetryInstallDuration = "install_duration"
+	TelemetryBatteryLevel    = "battery_level"
+)
+
+// DeviceTelemetry is optional telemetry attached by devices to status
+// reports.
+type DeviceTelemetry struct {
+	// Bytes per second
+	DownloadSpeed *float64 `json:"download_speed,omitempty" bson:"download_speed,omitempty"`
+
+	// Seconds
+	InstallDuration *float64 `json:"install_duration,omitempty" bson:"install_duration,omitempty"`
+
+	// Percent
+	BatteryLevel *float64 `json:"battery_level,omitempty" bson:"battery_level,omitempty"`
+}
+
+// Validate checks ranges of the metrics.
+func (t *DeviceTelemetry) Validate() error {
+	if t.DownloadSpeed != nil && *t.DownloadSpeed < 0 {
+		return ErrInvalidDownloadSpeed
+	}
+	if t.InstallDuration != nil && *t.InstallDuration < 0 {
+		return ErrInvalidInstallDuration
+	}
+	if t.BatteryLevel != nil && (*t.BatteryLevel < 0 || *t.BatteryLevel > 100) {
+		return ErrInvalidBatteryLevel
+	}
+	return nil
+}
+
+// IsEmpty returns true if no metric is set.
+func (t *DeviceTelemetry) IsEmpty() bool {
+	return t.DownloadSpeed == nil && t.InstallDuration == nil && t.BatteryLevel == nil
+}
+
+// TelemetrySample is telemetry of a status report, persisted for a sampled
+// subset of reports.
+type TelemetrySample struct {
+	DeploymentID string `json:"deployment_id" bson:"deployment_id"`
+	DeviceID     string `json:"device_id" bson:"device_id"`
+
+	// Status reported along with the telemetry
+	Status string `json:"status" bson:"status"`
+
+	DeviceTelemetry `bson:",inline"`
+
+	Reported time.Time `json:"reported" bson:"reported"`
+}
+
+// TelemetryAggregate summarizes values of a metric.
+type TelemetryAggregate struct {
+	Count int     `json:"count"`
+	Min   float64 `json:"min"`
+	Max   float64 `json:"max"`
+	Avg   float64 `json:"avg"`
+}
+
+// Aggregates of sampled telemetry of a deployment by reported status and
+// metric, e.g. battery level of devices reporting failure.
+type TelemetryStats map[string]map[string]TelemetryAggregate
@@ -0,0 +1,67 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package deployments_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	. "github.com/mendersoftware/deployments/resources/deployments"
+)
+
+func TestDeviceTelemetryValidate(t *testing.T) {
+
+	t.Parallel()
+
+	value := func(v float64) *float64 {
+		return &v
+	}
+
+	testCases := map[string]struct {
+		Telemetry DeviceTelemetry
+		Error     error
+	}{
+		"empty": {},
+		"ok": {
+			Telemetry: DeviceTelemetry{
+				DownloadSpeed:   value(1048576),
+				InstallDuration: value(0),
+				BatteryLevel:    value(100),
+			},
+		},
+		"negative download speed": {
+			Telemetry: DeviceTelemetry{DownloadSpeed: value(-1)},
+			Error:     ErrInvalidDownloadSpeed,
+		},
+		"negative install duration": {
+			Telemetry: DeviceTelemetry{InstallDuration: value(-0.5)},
+			Error:     ErrInvalidInstallDuration,
+		},
+		"battery level over 100": {
+			Telemetry: DeviceTelemetry{BatteryLevel: value(100.5)},
+			Error:     ErrInvalidBatteryLevel,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.Error, tc.Telemetry.Validate())
+		})
+	}
+
+	assert.True(t, (&DeviceTelemetry{}).IsEmpty())
+	assert.False(t, (&DeviceTelemetry{BatteryLevel: value(0)}).IsEmpty())
+}
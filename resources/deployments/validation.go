@@ -0,0 +1,69 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package deployments
+
+// Field validation error codes
+const (
+	ValidationCodeRequired = "required"
+	ValidationCodeLength   = "length"
+	ValidationCodeInvalid  = "invalid"
+	ValidationCodeNotFound = "not_found"
+	ValidationCodeUnknown  = "unknown"
+)
+
+// FieldError describes why a single input field is not valid.
+type FieldError struct {
+	// Field name as in the JSON input, e.g. "devices[2]"
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// ValidationError collects errors of all invalid input fields.
+type ValidationError struct {
+	Fields []FieldError
+}
+
+// NewValidationError creates validation error with a single invalid field.
+func NewValidationError(field, code, message string) *ValidationError {
+	err := &ValidationError{}
+	err.Add(field, code, message)
+	return err
+}
+
+// Add records invalid field.
+func (e *ValidationError) Add(field, code, message string) {
+	e.Fields = append(e.Fields, FieldError{
+		Field:   field,
+		Code:    code,
+		Message: message,
+	})
+}
+
+func (e *ValidationError) Error() string {
+	var msg string
+	for _, f := range e.Fields {
+		msg += f.Field + ": " + f.Message + ";"
+	}
+	return msg
+}
+
+// ErrorOrNil returns the validation error if any field is invalid, nil otherwise.
+func (e *ValidationError) ErrorOrNil() error {
+	if len(e.Fields) == 0 {
+		return nil
+	}
+	return e
+}
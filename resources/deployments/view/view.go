@@ -15,10 +15,14 @@
 package view
 
 import (
+	"bytes"
+	"encoding/json"
 	"net/http"
 	"strings"
 
 	"github.com/ant0ine/go-json-rest/rest"
+	"github.com/mendersoftware/go-lib-micro/log"
+	"github.com/mendersoftware/go-lib-micro/requestid"
 
 	"github.com/mendersoftware/deployments/resources/deployments"
 	"github.com/mendersoftware/deployments/utils/restutil/view"
@@ -26,6 +30,76 @@ import (
 
 type DeploymentsView struct {
 	view.RESTView
+
+	// Display names of statuses, optional
+	StatusNames *deployments.StatusNames
+}
+
+// RenderSuccessGet renders the object with statuses under display names.
+func (d *DeploymentsView) RenderSuccessGet(w rest.ResponseWriter, object interface{}) {
+	d.RESTView.RenderSuccessGet(w, d.displayStatuses(object))
+}
+
+// RenderCollection renders the collection with statuses under display names.
+func (d *DeploymentsView) RenderCollection(w rest.ResponseWriter, r *rest.Request,
+	collection interface{}) {
+	d.RESTView.RenderCollection(w, r, d.displayStatuses(collection))
+}
+
+// InternalStatus translates status filter given by display name to status.
+func (d *DeploymentsView) InternalStatus(name string) string {
+	return d.StatusNames.Internal(name)
+}
+
+// DisplayStatuses returns the object with statuses under display names,
+// for output not rendered by the view.
+func (d *DeploymentsView) DisplayStatuses(object interface{}) interface{} {
+	return d.displayStatuses(object)
+}
+
+// displayStatuses replaces statuses in values of status fields and in keys
+// of statistics with their display names. The object is returned as it is
+// if no status is renamed.
+func (d *DeploymentsView) displayStatuses(object interface{}) interface{} {
+	if d.StatusNames.Empty() {
+		return object
+	}
+
+	data, err := json.Marshal(object)
+	if err != nil {
+		return object
+	}
+
+	var generic interface{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&generic); err != nil {
+		return object
+	}
+
+	return d.renameStatuses(generic)
+}
+
+func (d *DeploymentsView) renameStatuses(value interface{}) interface{} {
+	switch v := value.(type) {
+	case []interface{}:
+		for i := range v {
+			v[i] = d.renameStatuses(v[i])
+		}
+		return v
+	case map[string]interface{}:
+		renamed := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			if status, ok := item.(string); ok && key == "status" {
+				renamed[key] = d.StatusNames.Display(status)
+				continue
+			}
+			renamed[d.StatusNames.Display(key)] = d.renameStatuses(item)
+		}
+		return renamed
+	default:
+		return v
+	}
 }
 
 func (d *DeploymentsView) RenderNoUpdateForDevice(w rest.ResponseWriter) {
@@ -51,3 +125,24 @@ func (d *DeploymentsView) RenderDeploymentLog(w rest.ResponseWriter, dlog deploy
 		}
 	}
 }
+
+// RenderValidationError renders error response extended with the list
+// of invalid input fields.
+func (d *DeploymentsView) RenderValidationError(w rest.ResponseWriter, r *rest.Request,
+	err error, fields []deployments.FieldError, status int, l *log.Logger) {
+
+	l.Error(err.Error())
+	w.WriteHeader(status)
+	writeErr := w.WriteJson(struct {
+		Error     string                   `json:"error"`
+		RequestID string                   `json:"request_id"`
+		Fields    []deployments.FieldError `json:"fields"`
+	}{
+		Error:     err.Error(),
+		RequestID: requestid.GetReqId(r),
+		Fields:    fields,
+	})
+	if writeErr != nil {
+		panic(writeErr)
+	}
+}
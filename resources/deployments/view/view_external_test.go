@@ -118,3 +118,87 @@ func TestRenderDeploymentLog(t *testing.T) {
 		assert.Equal(t, tc.Body, recorded.Recorder.Body.String())
 	}
 }
+
+func TestRenderStatusNames(t *testing.T) {
+
+	t.Parallel()
+
+	names, err := deployments.NewStatusNames(map[string]string{
+		deployments.DeviceDeploymentStatusSuccess: "installed",
+		deployments.DeploymentStatusInProgress:    "rolling-out",
+	})
+	assert.NoError(t, err)
+
+	testCases := map[string]struct {
+		names      *deployments.StatusNames
+		collection bool
+		object     interface{}
+		body       string
+	}{
+		"statistics": {
+			names: names,
+			object: deployments.Stats{
+				deployments.DeviceDeploymentStatusSuccess: 3,
+				deployments.DeviceDeploymentStatusFailure: 1,
+			},
+			body: `{"failure":1,"installed":3}`,
+		},
+		"status fields": {
+			names:      names,
+			collection: true,
+			object: []map[string]interface{}{
+				{"id": "success", "status": "inprogress", "size": 12345678901},
+				{"id": "foo", "status": "success"},
+			},
+			body: `[{"id":"success","size":12345678901,"status":"rolling-out"},` +
+				`{"id":"foo","status":"installed"}]`,
+		},
+		"no names": {
+			object: deployments.Stats{
+				deployments.DeviceDeploymentStatusSuccess: 3,
+			},
+			body: `{"success":3}`,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			router, err := rest.MakeRouter(rest.Get("/test",
+				func(w rest.ResponseWriter, r *rest.Request) {
+					view := &DeploymentsView{StatusNames: tc.names}
+					if tc.collection {
+						view.RenderCollection(w, r, tc.object)
+					} else {
+						view.RenderSuccessGet(w, tc.object)
+					}
+				}))
+			assert.NoError(t, err)
+
+			api := rest.NewApi()
+			api.SetApp(router)
+
+			recorded := test.RunRequest(t, api.MakeHandler(),
+				test.MakeSimpleRequest("GET", "http://localhost/test", nil))
+
+			recorded.CodeIs(http.StatusOK)
+			assert.JSONEq(t, tc.body, recorded.Recorder.Body.String())
+		})
+	}
+}
+
+func TestInternalStatus(t *testing.T) {
+
+	t.Parallel()
+
+	names, err := deployments.NewStatusNames(map[string]string{
+		deployments.DeviceDeploymentStatusSuccess: "installed",
+	})
+	assert.NoError(t, err)
+
+	view := &DeploymentsView{StatusNames: names}
+	assert.Equal(t, deployments.DeviceDeploymentStatusSuccess, view.InternalStatus("installed"))
+	assert.Equal(t, deployments.DeviceDeploymentStatusSuccess, view.InternalStatus("success"))
+	assert.Equal(t, "foo", view.InternalStatus("foo"))
+
+	assert.Equal(t, "installed", new(DeploymentsView).InternalStatus("installed"))
+}
@@ -0,0 +1,77 @@
+// Copyright 2026 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package images
+
+import "sort"
+
+// ArtifactDeviceTypes lists device types artifacts of the name are
+// available for.
+type ArtifactDeviceTypes struct {
+	Name        string   `bson:"_id"`
+	DeviceTypes []string `bson:"device_types"`
+}
+
+// ArtifactCompatibility is a row of the compatibility matrix: availability
+// of the artifact name for each device type of the matrix.
+type ArtifactCompatibility struct {
+	Name        string          `json:"name"`
+	DeviceTypes map[string]bool `json:"device_types"`
+}
+
+// CompatibilityMatrix tells which artifact names can be deployed to which
+// device types.
+type CompatibilityMatrix struct {
+	DeviceTypes []string                `json:"device_types"`
+	Artifacts   []ArtifactCompatibility `json:"artifacts"`
+}
+
+// NewCompatibilityMatrix builds the matrix of all artifact names and device
+// types, both sorted.
+func NewCompatibilityMatrix(artifacts []ArtifactDeviceTypes) *CompatibilityMatrix {
+	known := map[string]bool{}
+	for _, artifact := range artifacts {
+		for _, deviceType := range artifact.DeviceTypes {
+			known[deviceType] = true
+		}
+	}
+
+	matrix := &CompatibilityMatrix{
+		DeviceTypes: make([]string, 0, len(known)),
+		Artifacts:   make([]ArtifactCompatibility, 0, len(artifacts)),
+	}
+	for deviceType := range known {
+		matrix.DeviceTypes = append(matrix.DeviceTypes, deviceType)
+	}
+	sort.Strings(matrix.DeviceTypes)
+
+	for _, artifact := range artifacts {
+		row := ArtifactCompatibility{
+			Name:        artifact.Name,
+			DeviceTypes: make(map[string]bool, len(matrix.DeviceTypes)),
+		}
+		for _, deviceType := range matrix.DeviceTypes {
+			row.DeviceTypes[deviceType] = false
+		}
+		for _, deviceType := range artifact.DeviceTypes {
+			row.DeviceTypes[deviceType] = true
+		}
+		matrix.Artifacts = append(matrix.Artifacts, row)
+	}
+	sort.Slice(matrix.Artifacts, func(i, j int) bool {
+		return matrix.Artifacts[i].Name < matrix.Artifacts[j].Name
+	})
+
+	return matrix
+}
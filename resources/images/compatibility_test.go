@@ -0,0 +1,53 @@
+// Copyright 2026 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package images
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewCompatibilityMatrix(t *testing.T) {
+	matrix := NewCompatibilityMatrix([]ArtifactDeviceTypes{
+		{Name: "release-2", DeviceTypes: []string{"raspberrypi3"}},
+		{Name: "release-1", DeviceTypes: []string{"raspberrypi3", "beaglebone"}},
+	})
+	assert.Equal(t, &CompatibilityMatrix{
+		DeviceTypes: []string{"beaglebone", "raspberrypi3"},
+		Artifacts: []ArtifactCompatibility{
+			{
+				Name: "release-1",
+				DeviceTypes: map[string]bool{
+					"beaglebone":   true,
+					"raspberrypi3": true,
+				},
+			},
+			{
+				Name: "release-2",
+				DeviceTypes: map[string]bool{
+					"beaglebone":   false,
+					"raspberrypi3": true,
+				},
+			},
+		},
+	}, matrix)
+
+	empty := NewCompatibilityMatrix(nil)
+	assert.Equal(t, &CompatibilityMatrix{
+		DeviceTypes: []string{},
+		Artifacts:   []ArtifactCompatibility{},
+	}, empty)
+}
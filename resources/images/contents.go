@@ -0,0 +1,88 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package images
+
+// ArtifactPayload describes a single payload of an artifact.
+type ArtifactPayload struct {
+	// Update type of the payload
+	Type string `json:"type"`
+
+	// Files carried by the payload
+	Files []UpdateFile `json:"files"`
+
+	// Total size of the payload files
+	Size int64 `json:"size"`
+}
+
+// ArtifactDepends lists what an artifact requires from the device.
+type ArtifactDepends struct {
+	DeviceType []string `json:"device_type"`
+}
+
+// ArtifactProvides lists what an artifact provides once installed.
+type ArtifactProvides struct {
+	ArtifactName string `json:"artifact_name"`
+}
+
+// ArtifactContents is a view of the parsed artifact header.
+type ArtifactContents struct {
+	Name     string            `json:"name"`
+	Info     *ArtifactInfo     `json:"info"`
+	Signed   bool              `json:"signed"`
+	Payloads []ArtifactPayload `json:"payloads"`
+	Scripts  []string          `json:"scripts"`
+	Depends  ArtifactDepends   `json:"depends"`
+	Provides ArtifactProvides  `json:"provides"`
+}
+
+// NewArtifactContents builds the contents view from the artifact metadata
+// recorded on upload. Supported artifact formats carry no explicit
+// depends/provides sections, so those are derived from the compatible device
+// types and the artifact name.
+func NewArtifactContents(image *SoftwareImage) *ArtifactContents {
+	contents := &ArtifactContents{
+		Name:     image.Name,
+		Info:     image.Info,
+		Signed:   image.Signed,
+		Payloads: make([]ArtifactPayload, 0, len(image.Updates)),
+		Scripts:  []string{},
+		Depends: ArtifactDepends{
+			DeviceType: image.DeviceTypesCompatible,
+		},
+		Provides: ArtifactProvides{
+			ArtifactName: image.Name,
+		},
+	}
+
+	for _, u := range image.Updates {
+		payload := ArtifactPayload{
+			Type:  u.TypeInfo.Type,
+			Files: u.Files,
+		}
+		if payload.Files == nil {
+			payload.Files = []UpdateFile{}
+		}
+		for _, f := range u.Files {
+			payload.Size += f.Size
+		}
+		contents.Payloads = append(contents.Payloads, payload)
+	}
+
+	if image.Scripts != nil {
+		contents.Scripts = image.Scripts
+	}
+
+	return contents
+}
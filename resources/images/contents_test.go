@@ -0,0 +1,70 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package images
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewArtifactContents(t *testing.T) {
+	metaArtifact := NewSoftwareImageMetaArtifactConstructor()
+	metaArtifact.Name = "release-1"
+	metaArtifact.DeviceTypesCompatible = []string{"foo", "bar"}
+	metaArtifact.Info = &ArtifactInfo{Format: "mender", Version: 2}
+	metaArtifact.Signed = true
+	metaArtifact.Scripts = []string{"ArtifactInstall_Enter_01"}
+	metaArtifact.Updates = []Update{
+		{
+			TypeInfo: ArtifactUpdateTypeInfo{Type: "rootfs-image"},
+			Files: []UpdateFile{
+				{Name: "rootfs.ext4", Size: 100},
+				{Name: "extra.bin", Size: 23},
+			},
+		},
+		{
+			TypeInfo: ArtifactUpdateTypeInfo{Type: "empty"},
+		},
+	}
+	image := NewSoftwareImage(validUUIDv4, NewSoftwareImageMetaConstructor(), metaArtifact)
+
+	contents := NewArtifactContents(image)
+
+	assert.Equal(t, &ArtifactContents{
+		Name:   "release-1",
+		Info:   &ArtifactInfo{Format: "mender", Version: 2},
+		Signed: true,
+		Payloads: []ArtifactPayload{
+			{
+				Type:  "rootfs-image",
+				Files: metaArtifact.Updates[0].Files,
+				Size:  123,
+			},
+			{
+				Type:  "empty",
+				Files: []UpdateFile{},
+			},
+		},
+		Scripts:  []string{"ArtifactInstall_Enter_01"},
+		Depends:  ArtifactDepends{DeviceType: []string{"foo", "bar"}},
+		Provides: ArtifactProvides{ArtifactName: "release-1"},
+	}, contents)
+
+	// artifacts stored before scripts were recorded
+	image.Scripts = nil
+	contents = NewArtifactContents(image)
+	assert.Equal(t, []string{}, contents.Scripts)
+}
@@ -30,6 +30,8 @@ import (
 	"github.com/pkg/errors"
 
 	"github.com/mendersoftware/deployments/resources/images"
+	"github.com/mendersoftware/deployments/resources/limits"
+	"github.com/mendersoftware/deployments/utils/restutil"
 )
 
 // API input validation constants
@@ -44,11 +46,16 @@ var (
 	ErrIDNotUUIDv4                    = errors.New("ID is not UUIDv4")
 	ErrArtifactUsedInActiveDeployment = errors.New("Artifact is used in active deployment")
 	ErrInvalidExpireParam             = errors.New("Invalid expire parameter")
+	ErrArtifactNameTaken              = errors.New("Artifact with the same name and device type was uploaded after deletion")
+	ErrArtifactLocked                 = errors.New("Artifact is locked")
+	ErrArtifactNotDeployed            = errors.New("Artifact has not been used in any deployment yet")
+	ErrMissingNamePrefix              = errors.New("Missing name_prefix parameter")
 )
 
 type SoftwareImagesController struct {
-	view  RESTView
-	model ImagesModel
+	view   RESTView
+	model  ImagesModel
+	limits LimitsModel
 }
 
 // MultipartUploadMsg is a structure with fields extracted from the mulitpart/form-data form
@@ -60,6 +67,9 @@ type MultipartUploadMsg struct {
 	ArtifactSize int64
 	// reader pointing to the beginning of the artifact data
 	ArtifactReader io.Reader
+	// expected SHA256 checksum of the artifact file, hex encoded;
+	// not verified if empty
+	Checksum string
 }
 
 func NewSoftwareImagesController(model ImagesModel, view RESTView) *SoftwareImagesController {
@@ -93,16 +103,53 @@ func (s *SoftwareImagesController) GetImage(w rest.ResponseWriter, r *rest.Reque
 	s.view.RenderSuccessGet(w, image)
 }
 
+// GetImageContents returns the parsed header of the artifact: payloads with
+// their files, state scripts and depends/provides.
+func (s *SoftwareImagesController) GetImageContents(w rest.ResponseWriter, r *rest.Request) {
+	l := log.FromContext(r.Context())
+
+	id := r.PathParam("id")
+
+	if !govalidator.IsUUIDv4(id) {
+		s.view.RenderError(w, r, ErrIDNotUUIDv4, http.StatusBadRequest, l)
+		return
+	}
+
+	image, err := s.model.GetImage(r.Context(), id)
+	if err != nil {
+		s.view.RenderInternalError(w, r, err, l)
+		return
+	}
+
+	if image == nil {
+		s.view.RenderErrorNotFound(w, r, l)
+		return
+	}
+
+	s.view.RenderSuccessGet(w, images.NewArtifactContents(image))
+}
+
 func (s *SoftwareImagesController) ListImages(w rest.ResponseWriter, r *rest.Request) {
 	l := log.FromContext(r.Context())
 
-	list, err := s.model.ListImages(r.Context(), r.PathParams)
+	filters := map[string]string{}
+	for _, key := range []string{images.FilterProvides, images.FilterClearsProvides} {
+		if value := r.URL.Query().Get(key); value != "" {
+			filters[key] = value
+		}
+	}
+	if _, err := images.ParseProvidesFilter(filters); err != nil {
+		s.view.RenderError(w, r, err, http.StatusBadRequest, l)
+		return
+	}
+
+	list, err := s.model.ListImages(r.Context(), filters)
 	if err != nil {
 		s.view.RenderInternalError(w, r, err, l)
 		return
 	}
 
-	s.view.RenderSuccessGet(w, list)
+	s.view.RenderCollection(w, r, list)
 }
 
 func (s *SoftwareImagesController) DownloadLink(w rest.ResponseWriter, r *rest.Request) {
@@ -147,6 +194,197 @@ func (s *SoftwareImagesController) DeleteImage(w rest.ResponseWriter, r *rest.Re
 			s.view.RenderErrorNotFound(w, r, l)
 		case ErrModelImageInActiveDeployment:
 			s.view.RenderError(w, r, ErrArtifactUsedInActiveDeployment, http.StatusConflict, l)
+		case ErrModelImageLocked:
+			s.view.RenderError(w, r, ErrArtifactLocked, http.StatusConflict, l)
+		}
+		return
+	}
+
+	s.view.RenderSuccessDelete(w)
+}
+
+// LockImage protects deployed artifact from deletion and edits.
+func (s *SoftwareImagesController) LockImage(w rest.ResponseWriter, r *rest.Request) {
+	l := log.FromContext(r.Context())
+
+	id := r.PathParam("id")
+
+	if !govalidator.IsUUIDv4(id) {
+		s.view.RenderError(w, r, ErrIDNotUUIDv4, http.StatusBadRequest, l)
+		return
+	}
+
+	if err := s.model.LockImage(r.Context(), id); err != nil {
+		switch err {
+		default:
+			s.view.RenderInternalError(w, r, err, l)
+		case ErrImageMetaNotFound:
+			s.view.RenderErrorNotFound(w, r, l)
+		case ErrModelImageNotDeployed:
+			s.view.RenderError(w, r, ErrArtifactNotDeployed, http.StatusUnprocessableEntity, l)
+		}
+		return
+	}
+
+	s.view.RenderSuccessPut(w)
+}
+
+// UnlockImage removes lock of the artifact.
+func (s *SoftwareImagesController) UnlockImage(w rest.ResponseWriter, r *rest.Request) {
+	l := log.FromContext(r.Context())
+
+	id := r.PathParam("id")
+
+	if !govalidator.IsUUIDv4(id) {
+		s.view.RenderError(w, r, ErrIDNotUUIDv4, http.StatusBadRequest, l)
+		return
+	}
+
+	if err := s.model.UnlockImage(r.Context(), id); err != nil {
+		switch err {
+		default:
+			s.view.RenderInternalError(w, r, err, l)
+		case ErrImageMetaNotFound:
+			s.view.RenderErrorNotFound(w, r, l)
+		}
+		return
+	}
+
+	s.view.RenderSuccessDelete(w)
+}
+
+// ListTrashedImages lists deleted artifacts which can be restored.
+func (s *SoftwareImagesController) ListTrashedImages(w rest.ResponseWriter, r *rest.Request) {
+	l := log.FromContext(r.Context())
+
+	list, err := s.model.ListTrashedImages(r.Context())
+	if err != nil {
+		s.view.RenderInternalError(w, r, err, l)
+		return
+	}
+
+	s.view.RenderCollection(w, r, list)
+}
+
+// GetCompatibility returns which artifact names are available for which
+// device types.
+func (s *SoftwareImagesController) GetCompatibility(w rest.ResponseWriter, r *rest.Request) {
+	l := log.FromContext(r.Context())
+
+	matrix, err := s.model.GetCompatibility(r.Context())
+	if err != nil {
+		s.view.RenderInternalError(w, r, err, l)
+		return
+	}
+
+	s.view.RenderSuccessGet(w, matrix)
+}
+
+// GetLatestImage returns the artifact of the highest version among those
+// with names starting with the prefix, optionally of the device type.
+func (s *SoftwareImagesController) GetLatestImage(w rest.ResponseWriter, r *rest.Request) {
+	l := log.FromContext(r.Context())
+
+	namePrefix := r.URL.Query().Get("name_prefix")
+	if namePrefix == "" {
+		s.view.RenderError(w, r, ErrMissingNamePrefix, http.StatusBadRequest, l)
+		return
+	}
+
+	image, err := s.model.GetLatestImage(r.Context(), namePrefix,
+		r.URL.Query().Get("device_type"))
+	if err != nil {
+		s.view.RenderInternalError(w, r, err, l)
+		return
+	}
+
+	if image == nil {
+		s.view.RenderErrorNotFound(w, r, l)
+		return
+	}
+
+	s.view.RenderSuccessGet(w, image)
+}
+
+// GetNamingPolicy returns rules artifact names have to follow.
+func (s *SoftwareImagesController) GetNamingPolicy(w rest.ResponseWriter, r *rest.Request) {
+	l := log.FromContext(r.Context())
+
+	policy, err := s.model.GetNamingPolicy(r.Context())
+	if err != nil {
+		s.view.RenderInternalError(w, r, err, l)
+		return
+	}
+
+	s.view.RenderSuccessGet(w, policy)
+}
+
+// PutNamingPolicy replaces rules artifact names have to follow.
+func (s *SoftwareImagesController) PutNamingPolicy(w rest.ResponseWriter, r *rest.Request) {
+	l := log.FromContext(r.Context())
+
+	var policy images.NamingPolicy
+	if err := restutil.DecodeJSONPayload(r, &policy); err != nil {
+		s.view.RenderError(w, r, errors.Wrap(err, "Validating request body"), http.StatusBadRequest, l)
+		return
+	}
+
+	if err := policy.Validate(); err != nil {
+		s.view.RenderError(w, r, errors.Wrap(err, "Validating request body"), http.StatusBadRequest, l)
+		return
+	}
+
+	if err := s.model.SetNamingPolicy(r.Context(), &policy); err != nil {
+		s.view.RenderInternalError(w, r, err, l)
+		return
+	}
+
+	s.view.RenderSuccessPut(w)
+}
+
+// RestoreImage moves deleted artifact back from the trash.
+func (s *SoftwareImagesController) RestoreImage(w rest.ResponseWriter, r *rest.Request) {
+	l := log.FromContext(r.Context())
+
+	id := r.PathParam("id")
+
+	if !govalidator.IsUUIDv4(id) {
+		s.view.RenderError(w, r, ErrIDNotUUIDv4, http.StatusBadRequest, l)
+		return
+	}
+
+	if err := s.model.RestoreImage(r.Context(), id); err != nil {
+		switch err {
+		default:
+			s.view.RenderInternalError(w, r, err, l)
+		case ErrImageMetaNotFound:
+			s.view.RenderErrorNotFound(w, r, l)
+		case ErrModelArtifactNotUnique:
+			s.view.RenderError(w, r, ErrArtifactNameTaken, http.StatusConflict, l)
+		}
+		return
+	}
+
+	s.view.RenderSuccessPut(w)
+}
+
+// PurgeImage permanently deletes artifact from the trash.
+func (s *SoftwareImagesController) PurgeImage(w rest.ResponseWriter, r *rest.Request) {
+	l := log.FromContext(r.Context())
+
+	id := r.PathParam("id")
+
+	if !govalidator.IsUUIDv4(id) {
+		s.view.RenderError(w, r, ErrIDNotUUIDv4, http.StatusBadRequest, l)
+		return
+	}
+
+	if err := s.model.PurgeImage(r.Context(), id); err != nil {
+		switch err {
+		default:
+			s.view.RenderInternalError(w, r, err, l)
+		case ErrImageMetaNotFound:
+			s.view.RenderErrorNotFound(w, r, l)
 		}
 		return
 	}
@@ -176,6 +414,10 @@ func (s *SoftwareImagesController) EditImage(w rest.ResponseWriter, r *rest.Requ
 			s.view.RenderError(w, r, err, http.StatusUnprocessableEntity, l)
 			return
 		}
+		if err == ErrModelImageLocked {
+			s.view.RenderError(w, r, ErrArtifactLocked, http.StatusConflict, l)
+			return
+		}
 		s.view.RenderInternalError(w, r, err, l)
 		return
 	}
@@ -192,7 +434,7 @@ func (s SoftwareImagesController) getSoftwareImageMetaConstructorFromBody(r *res
 
 	var constructor *images.SoftwareImageMetaConstructor
 
-	if err := r.DecodeJsonPayload(&constructor); err != nil {
+	if err := restutil.DecodeJSONPayload(r, &constructor); err != nil {
 		return nil, err
 	}
 
@@ -203,6 +445,59 @@ func (s SoftwareImagesController) getSoftwareImageMetaConstructorFromBody(r *res
 	return constructor, nil
 }
 
+// FetchImage starts download of the artifact from remote location.
+// Responds with location of the fetch resource reporting the download progress.
+func (s *SoftwareImagesController) FetchImage(w rest.ResponseWriter, r *rest.Request) {
+	l := log.FromContext(r.Context())
+
+	var constructor images.FetchConstructor
+	if err := restutil.DecodeJSONPayload(r, &constructor); err != nil {
+		s.view.RenderError(w, r, errors.Wrap(err, "Validating request body"), http.StatusBadRequest, l)
+		return
+	}
+
+	if err := constructor.Validate(); err != nil {
+		s.view.RenderError(w, r, errors.Wrap(err, "Validating request body"), http.StatusBadRequest, l)
+		return
+	}
+
+	if !s.checkArtifactsLimit(w, r) {
+		return
+	}
+
+	id, err := s.model.FetchImage(r.Context(), &constructor)
+	if err != nil {
+		s.view.RenderInternalError(w, r, err, l)
+		return
+	}
+
+	s.view.RenderSuccessPost(w, r, id)
+}
+
+func (s *SoftwareImagesController) GetFetch(w rest.ResponseWriter, r *rest.Request) {
+	l := log.FromContext(r.Context())
+
+	id := r.PathParam("id")
+
+	if !govalidator.IsUUIDv4(id) {
+		s.view.RenderError(w, r, ErrIDNotUUIDv4, http.StatusBadRequest, l)
+		return
+	}
+
+	fetch, err := s.model.GetFetch(r.Context(), id)
+	if err != nil {
+		s.view.RenderInternalError(w, r, err, l)
+		return
+	}
+
+	if fetch == nil {
+		s.view.RenderErrorNotFound(w, r, l)
+		return
+	}
+
+	s.view.RenderSuccessGet(w, fetch)
+}
+
 // Multipart Image/Meta upload handler.
 // Request should be of type "multipart/form-data".
 // First part should contain Metadata file. This file should be of type "application/json".
@@ -210,6 +505,10 @@ func (s SoftwareImagesController) getSoftwareImageMetaConstructorFromBody(r *res
 func (s *SoftwareImagesController) NewImage(w rest.ResponseWriter, r *rest.Request) {
 	l := log.FromContext(r.Context())
 
+	if !s.checkArtifactsLimit(w, r) {
+		return
+	}
+
 	// parse content type and params according to RFC 1521
 	_, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
 	if err != nil {
@@ -227,6 +526,11 @@ func (s *SoftwareImagesController) NewImage(w rest.ResponseWriter, r *rest.Reque
 
 	imgID, err := s.model.CreateImage(r.Context(), multipartUploadMsg)
 	cause := errors.Cause(err)
+	if policyErr, ok := cause.(*images.NamingPolicyError); ok {
+		s.view.RenderErrorDetails(w, r, policyErr, http.StatusUnprocessableEntity,
+			map[string]interface{}{"policy": policyErr.Policy}, l)
+		return
+	}
 	switch cause {
 	default:
 		s.view.RenderInternalError(w, r, err, l)
@@ -240,14 +544,47 @@ func (s *SoftwareImagesController) NewImage(w rest.ResponseWriter, r *rest.Reque
 		s.view.RenderError(w, r, formatArtifactUploadError(err), http.StatusBadRequest, l)
 	case ErrModelMissingInputMetadata, ErrModelMissingInputArtifact,
 		ErrModelInvalidMetadata, ErrModelMultipartUploadMsgMalformed,
-		ErrModelArtifactFileTooLarge:
+		ErrModelArtifactFileTooLarge, ErrModelParsingTimeout:
 		l.Error(err.Error())
 		s.view.RenderError(w, r, cause, http.StatusBadRequest, l)
+	case ErrModelParserBusy:
+		l.Error(err.Error())
+		s.view.RenderError(w, r, cause, http.StatusServiceUnavailable, l)
 	}
 
 	return
 }
 
+// checkArtifactsLimit renders error and returns false if the request
+// would exceed the artifacts limit.
+func (s *SoftwareImagesController) checkArtifactsLimit(w rest.ResponseWriter,
+	r *rest.Request) bool {
+
+	err := s.CheckArtifactsLimit(r.Context())
+	switch err.(type) {
+	case nil:
+		return true
+	case *limits.ExceededError:
+		s.view.RenderError(w, r, err, http.StatusUnprocessableEntity, log.FromContext(r.Context()))
+	default:
+		s.view.RenderInternalError(w, r, err, log.FromContext(r.Context()))
+	}
+	return false
+}
+
+// GetParserStats returns usage of the artifact parsers.
+func (s *SoftwareImagesController) GetParserStats(w rest.ResponseWriter, r *rest.Request) {
+	l := log.FromContext(r.Context())
+
+	stats, err := s.model.ParserStats(r.Context())
+	if err != nil {
+		s.view.RenderInternalError(w, r, err, l)
+		return
+	}
+
+	s.view.RenderSuccessGet(w, stats)
+}
+
 func formatArtifactUploadError(err error) error {
 	// remove generic message
 	errMsg := strings.TrimSuffix(err.Error(), ": "+ErrModelParsingArtifactFailed.Error())
@@ -292,6 +629,12 @@ func (s *SoftwareImagesController) ParseMultipart(mr *multipart.Reader, maxMetaS
 				return nil, err
 			}
 			multipartUploadMsg.MetaConstructor.Description = *desc
+		case "changelog":
+			changelog, err := s.getFormFieldValue(p, maxMetaSize)
+			if err != nil {
+				return nil, err
+			}
+			multipartUploadMsg.MetaConstructor.Changelog = *changelog
 		case "artifact":
 			// valide metadata provided by the user and the image size
 			if err := multipartUploadMsg.MetaConstructor.Validate(); err != nil {
@@ -36,6 +36,7 @@ import (
 	"github.com/mendersoftware/deployments/resources/images"
 	. "github.com/mendersoftware/deployments/resources/images/controller"
 	"github.com/mendersoftware/deployments/resources/images/controller/mocks"
+	"github.com/mendersoftware/deployments/resources/limits"
 	"github.com/mendersoftware/deployments/utils/pointers"
 	"github.com/mendersoftware/deployments/utils/restutil/view"
 	h "github.com/mendersoftware/deployments/utils/testing"
@@ -108,6 +109,72 @@ func TestControllerGetImage(t *testing.T) {
 	}
 }
 
+func TestControllerGetImageContents(t *testing.T) {
+	imagesModel := &mocks.ImagesModel{}
+	controller := NewSoftwareImagesController(imagesModel, new(view.RESTView))
+
+	api := setUpRestTest("/api/0.0.1/images/:id/contents", rest.Get, controller.GetImageContents)
+
+	//no uuid provided
+	recorded := test.RunRequest(t, api.MakeHandler(),
+		test.MakeSimpleRequest("GET", "http://localhost/api/0.0.1/images/123/contents", nil))
+	recorded.CodeIs(http.StatusBadRequest)
+
+	//have correct id, but no image
+	id := uuid.NewV4().String()
+	imagesModel.On("GetImage", h.ContextMatcher(), id).
+		Return(nil, nil)
+	recorded = test.RunRequest(t, api.MakeHandler(),
+		test.MakeSimpleRequest("GET", "http://localhost/api/0.0.1/images/"+id+"/contents", nil))
+	recorded.CodeIs(http.StatusNotFound)
+
+	//have correct id, but error getting image
+	id = uuid.NewV4().String()
+	imagesModel.On("GetImage", h.ContextMatcher(), id).
+		Return(nil, errors.New("error"))
+	recorded = test.RunRequest(t, api.MakeHandler(),
+		test.MakeSimpleRequest("GET", "http://localhost/api/0.0.1/images/"+id+"/contents", nil))
+	recorded.CodeIs(http.StatusInternalServerError)
+
+	// have image, get OK
+	id = uuid.NewV4().String()
+	imageMetaArtifact := images.NewSoftwareImageMetaArtifactConstructor()
+	imageMetaArtifact.Name = "release-1"
+	imageMetaArtifact.DeviceTypesCompatible = []string{"foo"}
+	imageMetaArtifact.Scripts = []string{"ArtifactCommit_Leave_50"}
+	imageMetaArtifact.Updates = []images.Update{{
+		TypeInfo: images.ArtifactUpdateTypeInfo{Type: "rootfs-image"},
+		Files:    []images.UpdateFile{{Name: "rootfs.ext4", Size: 42}},
+	}}
+	constructorImage := images.NewSoftwareImage(validUUIDv4,
+		images.NewSoftwareImageMetaConstructor(), imageMetaArtifact)
+	imagesModel.On("GetImage", h.ContextMatcher(), id).
+		Return(constructorImage, nil)
+	recorded = test.RunRequest(t, api.MakeHandler(),
+		test.MakeSimpleRequest("GET", "http://localhost/api/0.0.1/images/"+id+"/contents", nil))
+	h.CheckRecordedResponse(t, recorded, h.JSONResponseParams{
+		OutputStatus:     http.StatusOK,
+		OutputBodyObject: images.NewArtifactContents(constructorImage),
+	})
+}
+
+func TestControllerGetParserStats(t *testing.T) {
+	imagesModel := &mocks.ImagesModel{}
+	controller := NewSoftwareImagesController(imagesModel, new(view.RESTView))
+
+	api := setUpRestTest("/api/0.0.1/artifacts/parser", rest.Get, controller.GetParserStats)
+
+	stats := &images.ParserStats{Workers: 4, QueueSize: 16, Active: 4, Queued: 1, Rejected: 2}
+	imagesModel.On("ParserStats", h.ContextMatcher()).Return(stats, nil)
+
+	recorded := test.RunRequest(t, api.MakeHandler(),
+		test.MakeSimpleRequest("GET", "http://localhost/api/0.0.1/artifacts/parser", nil))
+	h.CheckRecordedResponse(t, recorded, h.JSONResponseParams{
+		OutputStatus:     http.StatusOK,
+		OutputBodyObject: stats,
+	})
+}
+
 func TestControllerListImages(t *testing.T) {
 	imagesModel := &mocks.ImagesModel{}
 	controller := NewSoftwareImagesController(imagesModel, new(view.RESTView))
@@ -134,6 +201,25 @@ func TestControllerListImages(t *testing.T) {
 		test.MakeSimpleRequest("GET", "http://localhost/api/0.0.1/images", nil))
 	recorded.CodeIs(http.StatusOK)
 	recorded.ContentTypeIsJson()
+
+	//filtering by provides
+	imagesModel = &mocks.ImagesModel{}
+	controller = NewSoftwareImagesController(imagesModel, new(view.RESTView))
+	api = setUpRestTest("/api/0.0.1/images", rest.Get, controller.ListImages)
+	imagesModel.On("ListImages", h.ContextMatcher(), map[string]string{
+		images.FilterProvides:       "rootfs-image.version:release-1",
+		images.FilterClearsProvides: "rootfs-image.*",
+	}).Return([]*images.SoftwareImage{constructorImage}, nil)
+	recorded = test.RunRequest(t, api.MakeHandler(),
+		test.MakeSimpleRequest("GET", "http://localhost/api/0.0.1/images"+
+			"?provides=rootfs-image.version:release-1&clears_provides=rootfs-image.*", nil))
+	recorded.CodeIs(http.StatusOK)
+	imagesModel.AssertExpectations(t)
+
+	//malformed provides filter
+	recorded = test.RunRequest(t, api.MakeHandler(),
+		test.MakeSimpleRequest("GET", "http://localhost/api/0.0.1/images?provides=release-1", nil))
+	recorded.CodeIs(http.StatusBadRequest)
 }
 
 func TestControllerDeleteImage(t *testing.T) {
@@ -169,6 +255,358 @@ func TestControllerDeleteImage(t *testing.T) {
 		test.MakeSimpleRequest("DELETE", "http://localhost/api/0.0.1/images/"+id, nil))
 	recorded.CodeIs(http.StatusNoContent)
 	recorded.BodyIs("")
+
+	// valid id; image locked
+	id = uuid.NewV4().String()
+	imagesModel.On("DeleteImage", h.ContextMatcher(), id).
+		Return(ErrModelImageLocked)
+	recorded = test.RunRequest(t, api.MakeHandler(),
+		test.MakeSimpleRequest("DELETE", "http://localhost/api/0.0.1/images/"+id, nil))
+	recorded.CodeIs(http.StatusConflict)
+}
+
+func TestControllerListTrashedImages(t *testing.T) {
+	imagesModel := &mocks.ImagesModel{}
+	controller := NewSoftwareImagesController(imagesModel, new(view.RESTView))
+
+	api := setUpRestTest("/r", rest.Get, controller.ListTrashedImages)
+
+	image := images.NewSoftwareImage(validUUIDv4,
+		images.NewSoftwareImageMetaConstructor(),
+		images.NewSoftwareImageMetaArtifactConstructor())
+	now := time.Now()
+	image.Trashed = &now
+	imagesModel.On("ListTrashedImages", h.ContextMatcher()).
+		Return([]*images.SoftwareImage{image}, nil).Once()
+	recorded := test.RunRequest(t, api.MakeHandler(),
+		test.MakeSimpleRequest("GET", "http://localhost/r", nil))
+	h.CheckRecordedResponse(t, recorded, h.JSONResponseParams{
+		OutputStatus:     http.StatusOK,
+		OutputBodyObject: []*images.SoftwareImage{image},
+	})
+
+	imagesModel.On("ListTrashedImages", h.ContextMatcher()).
+		Return(nil, errors.New("error")).Once()
+	recorded = test.RunRequest(t, api.MakeHandler(),
+		test.MakeSimpleRequest("GET", "http://localhost/r", nil))
+	recorded.CodeIs(http.StatusInternalServerError)
+}
+
+func TestControllerGetCompatibility(t *testing.T) {
+	imagesModel := &mocks.ImagesModel{}
+	controller := NewSoftwareImagesController(imagesModel, new(view.RESTView))
+
+	api := setUpRestTest("/r", rest.Get, controller.GetCompatibility)
+
+	matrix := images.NewCompatibilityMatrix([]images.ArtifactDeviceTypes{
+		{Name: "release-1", DeviceTypes: []string{"beaglebone"}},
+		{Name: "release-2", DeviceTypes: []string{"raspberrypi3"}},
+	})
+	imagesModel.On("GetCompatibility", h.ContextMatcher()).
+		Return(matrix, nil).Once()
+	recorded := test.RunRequest(t, api.MakeHandler(),
+		test.MakeSimpleRequest("GET", "http://localhost/r", nil))
+	h.CheckRecordedResponse(t, recorded, h.JSONResponseParams{
+		OutputStatus:     http.StatusOK,
+		OutputBodyObject: matrix,
+	})
+
+	imagesModel.On("GetCompatibility", h.ContextMatcher()).
+		Return(nil, errors.New("error")).Once()
+	recorded = test.RunRequest(t, api.MakeHandler(),
+		test.MakeSimpleRequest("GET", "http://localhost/r", nil))
+	recorded.CodeIs(http.StatusInternalServerError)
+}
+
+func TestControllerGetLatestImage(t *testing.T) {
+	imagesModel := &mocks.ImagesModel{}
+	controller := NewSoftwareImagesController(imagesModel, new(view.RESTView))
+
+	api := setUpRestTest("/r", rest.Get, controller.GetLatestImage)
+
+	recorded := test.RunRequest(t, api.MakeHandler(),
+		test.MakeSimpleRequest("GET", "http://localhost/r?device_type=foo", nil))
+	recorded.CodeIs(http.StatusBadRequest)
+	assert.Contains(t, recorded.Recorder.Body.String(), ErrMissingNamePrefix.Error())
+
+	image := images.NewSoftwareImage(validUUIDv4,
+		&images.SoftwareImageMetaConstructor{},
+		&images.SoftwareImageMetaArtifactConstructor{
+			Name:                  "app-1.2.3",
+			DeviceTypesCompatible: []string{"foo"},
+		})
+	imagesModel.On("GetLatestImage", h.ContextMatcher(), "app-", "foo").
+		Return(image, nil).Once()
+	recorded = test.RunRequest(t, api.MakeHandler(),
+		test.MakeSimpleRequest("GET", "http://localhost/r?name_prefix=app-&device_type=foo", nil))
+	h.CheckRecordedResponse(t, recorded, h.JSONResponseParams{
+		OutputStatus:     http.StatusOK,
+		OutputBodyObject: image,
+	})
+
+	imagesModel.On("GetLatestImage", h.ContextMatcher(), "app-", "").
+		Return(nil, nil).Once()
+	recorded = test.RunRequest(t, api.MakeHandler(),
+		test.MakeSimpleRequest("GET", "http://localhost/r?name_prefix=app-", nil))
+	recorded.CodeIs(http.StatusNotFound)
+
+	imagesModel.On("GetLatestImage", h.ContextMatcher(), "app-", "").
+		Return(nil, errors.New("error")).Once()
+	recorded = test.RunRequest(t, api.MakeHandler(),
+		test.MakeSimpleRequest("GET", "http://localhost/r?name_prefix=app-", nil))
+	recorded.CodeIs(http.StatusInternalServerError)
+}
+
+func TestControllerGetNamingPolicy(t *testing.T) {
+	imagesModel := &mocks.ImagesModel{}
+	controller := NewSoftwareImagesController(imagesModel, new(view.RESTView))
+
+	api := setUpRestTest("/r", rest.Get, controller.GetNamingPolicy)
+
+	policy := &images.NamingPolicy{Pattern: "app-.*", Semver: true}
+	imagesModel.On("GetNamingPolicy", h.ContextMatcher()).
+		Return(policy, nil).Once()
+	recorded := test.RunRequest(t, api.MakeHandler(),
+		test.MakeSimpleRequest("GET", "http://localhost/r", nil))
+	h.CheckRecordedResponse(t, recorded, h.JSONResponseParams{
+		OutputStatus:     http.StatusOK,
+		OutputBodyObject: policy,
+	})
+
+	imagesModel.On("GetNamingPolicy", h.ContextMatcher()).
+		Return(nil, errors.New("error")).Once()
+	recorded = test.RunRequest(t, api.MakeHandler(),
+		test.MakeSimpleRequest("GET", "http://localhost/r", nil))
+	recorded.CodeIs(http.StatusInternalServerError)
+}
+
+func TestControllerPutNamingPolicy(t *testing.T) {
+	testCases := map[string]struct {
+		h.JSONResponseParams
+
+		InputBodyObject interface{}
+		InputModelError error
+	}{
+		"empty body": {
+			JSONResponseParams: h.JSONResponseParams{
+				OutputStatus:     http.StatusBadRequest,
+				OutputBodyObject: h.ErrorToErrStruct(errors.New("Validating request body: JSON payload is empty")),
+			},
+		},
+		"invalid pattern": {
+			InputBodyObject: &images.NamingPolicy{Pattern: "app-[a-z"},
+			JSONResponseParams: h.JSONResponseParams{
+				OutputStatus: http.StatusBadRequest,
+				OutputBodyObject: h.ErrorToErrStruct(errors.New("Validating request body: " +
+					"Invalid pattern: error parsing regexp: missing closing ]: `[a-z)$`")),
+			},
+		},
+		"model error": {
+			InputBodyObject: &images.NamingPolicy{Semver: true},
+			InputModelError: errors.New("model error"),
+			JSONResponseParams: h.JSONResponseParams{
+				OutputStatus:     http.StatusInternalServerError,
+				OutputBodyObject: h.ErrorToErrStruct(errors.New("internal error")),
+			},
+		},
+		"ok": {
+			InputBodyObject: &images.NamingPolicy{Pattern: "app-.*", Semver: true},
+			JSONResponseParams: h.JSONResponseParams{
+				OutputStatus: http.StatusNoContent,
+			},
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			imagesModel := &mocks.ImagesModel{}
+			imagesModel.On("SetNamingPolicy", h.ContextMatcher(), testCase.InputBodyObject).
+				Return(testCase.InputModelError)
+
+			api := setUpRestTest("/r", rest.Put,
+				NewSoftwareImagesController(imagesModel, new(view.RESTView)).PutNamingPolicy)
+
+			req := test.MakeSimpleRequest("PUT", "http://localhost/r", testCase.InputBodyObject)
+			req.Header.Add(requestid.RequestIdHeader, "test")
+			recorded := test.RunRequest(t, api.MakeHandler(), req)
+
+			h.CheckRecordedResponse(t, recorded, testCase.JSONResponseParams)
+		})
+	}
+}
+
+func TestControllerRestoreImage(t *testing.T) {
+	testCases := map[string]struct {
+		id         string
+		modelError error
+		status     int
+	}{
+		"ok": {
+			id:     validUUIDv4,
+			status: http.StatusNoContent,
+		},
+		"wrong id": {
+			id:     "wrong_id",
+			status: http.StatusBadRequest,
+		},
+		"not found": {
+			id:         validUUIDv4,
+			modelError: ErrImageMetaNotFound,
+			status:     http.StatusNotFound,
+		},
+		"name taken": {
+			id:         validUUIDv4,
+			modelError: ErrModelArtifactNotUnique,
+			status:     http.StatusConflict,
+		},
+		"error": {
+			id:         validUUIDv4,
+			modelError: errors.New("error"),
+			status:     http.StatusInternalServerError,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			imagesModel := &mocks.ImagesModel{}
+			imagesModel.On("RestoreImage", h.ContextMatcher(), tc.id).
+				Return(tc.modelError)
+			controller := NewSoftwareImagesController(imagesModel, new(view.RESTView))
+
+			api := setUpRestTest("/r/:id/restore", rest.Post, controller.RestoreImage)
+			recorded := test.RunRequest(t, api.MakeHandler(),
+				test.MakeSimpleRequest("POST", "http://localhost/r/"+tc.id+"/restore", nil))
+			recorded.CodeIs(tc.status)
+		})
+	}
+}
+
+func TestControllerPurgeImage(t *testing.T) {
+	testCases := map[string]struct {
+		id         string
+		modelError error
+		status     int
+	}{
+		"ok": {
+			id:     validUUIDv4,
+			status: http.StatusNoContent,
+		},
+		"wrong id": {
+			id:     "wrong_id",
+			status: http.StatusBadRequest,
+		},
+		"not found": {
+			id:         validUUIDv4,
+			modelError: ErrImageMetaNotFound,
+			status:     http.StatusNotFound,
+		},
+		"error": {
+			id:         validUUIDv4,
+			modelError: errors.New("error"),
+			status:     http.StatusInternalServerError,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			imagesModel := &mocks.ImagesModel{}
+			imagesModel.On("PurgeImage", h.ContextMatcher(), tc.id).
+				Return(tc.modelError)
+			controller := NewSoftwareImagesController(imagesModel, new(view.RESTView))
+
+			api := setUpRestTest("/r/:id", rest.Delete, controller.PurgeImage)
+			recorded := test.RunRequest(t, api.MakeHandler(),
+				test.MakeSimpleRequest("DELETE", "http://localhost/r/"+tc.id, nil))
+			recorded.CodeIs(tc.status)
+		})
+	}
+}
+
+func TestControllerLockImage(t *testing.T) {
+	testCases := map[string]struct {
+		id         string
+		modelError error
+		status     int
+	}{
+		"ok": {
+			id:     validUUIDv4,
+			status: http.StatusNoContent,
+		},
+		"wrong id": {
+			id:     "wrong_id",
+			status: http.StatusBadRequest,
+		},
+		"not found": {
+			id:         validUUIDv4,
+			modelError: ErrImageMetaNotFound,
+			status:     http.StatusNotFound,
+		},
+		"not deployed": {
+			id:         validUUIDv4,
+			modelError: ErrModelImageNotDeployed,
+			status:     http.StatusUnprocessableEntity,
+		},
+		"error": {
+			id:         validUUIDv4,
+			modelError: errors.New("error"),
+			status:     http.StatusInternalServerError,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			imagesModel := &mocks.ImagesModel{}
+			imagesModel.On("LockImage", h.ContextMatcher(), tc.id).
+				Return(tc.modelError)
+			controller := NewSoftwareImagesController(imagesModel, new(view.RESTView))
+
+			api := setUpRestTest("/r/:id/lock", rest.Put, controller.LockImage)
+			recorded := test.RunRequest(t, api.MakeHandler(),
+				test.MakeSimpleRequest("PUT", "http://localhost/r/"+tc.id+"/lock", nil))
+			recorded.CodeIs(tc.status)
+		})
+	}
+}
+
+func TestControllerUnlockImage(t *testing.T) {
+	testCases := map[string]struct {
+		id         string
+		modelError error
+		status     int
+	}{
+		"ok": {
+			id:     validUUIDv4,
+			status: http.StatusNoContent,
+		},
+		"wrong id": {
+			id:     "wrong_id",
+			status: http.StatusBadRequest,
+		},
+		"not found": {
+			id:         validUUIDv4,
+			modelError: ErrImageMetaNotFound,
+			status:     http.StatusNotFound,
+		},
+		"error": {
+			id:         validUUIDv4,
+			modelError: errors.New("error"),
+			status:     http.StatusInternalServerError,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			imagesModel := &mocks.ImagesModel{}
+			imagesModel.On("UnlockImage", h.ContextMatcher(), tc.id).
+				Return(tc.modelError)
+			controller := NewSoftwareImagesController(imagesModel, new(view.RESTView))
+
+			api := setUpRestTest("/r/:id/lock", rest.Delete, controller.UnlockImage)
+			recorded := test.RunRequest(t, api.MakeHandler(),
+				test.MakeSimpleRequest("DELETE", "http://localhost/r/"+tc.id+"/lock", nil))
+			recorded.CodeIs(tc.status)
+		})
+	}
 }
 
 func TestControllerEditImage(t *testing.T) {
@@ -352,6 +790,58 @@ func TestSoftwareImagesControllerNewImage(t *testing.T) {
 				OutputBodyObject: h.ErrorToErrStruct(ErrModelArtifactNotUnique),
 			},
 		},
+		{
+			InputBodyObject: []h.Part{
+				{
+					FieldName:  "size",
+					FieldValue: strconv.Itoa(len(imageBody)),
+				},
+				{
+					FieldName:   "artifact",
+					ContentType: "application/octet-stream",
+					ImageData:   imageBody,
+				},
+			},
+			InputContentType: "multipart/form-data",
+			InputModelID:     "1234",
+			InputModelError:  ErrModelParserBusy,
+			JSONResponseParams: h.JSONResponseParams{
+				OutputStatus:     http.StatusServiceUnavailable,
+				OutputBodyObject: h.ErrorToErrStruct(ErrModelParserBusy),
+			},
+		},
+		{
+			InputBodyObject: []h.Part{
+				{
+					FieldName:  "size",
+					FieldValue: strconv.Itoa(len(imageBody)),
+				},
+				{
+					FieldName:   "artifact",
+					ContentType: "application/octet-stream",
+					ImageData:   imageBody,
+				},
+			},
+			InputContentType: "multipart/form-data",
+			InputModelID:     "1234",
+			InputModelError: &images.NamingPolicyError{
+				Name:   "release",
+				Policy: images.NamingPolicy{Pattern: "app-.*", Semver: true},
+				Reason: "name does not match pattern app-.*",
+			},
+			JSONResponseParams: h.JSONResponseParams{
+				OutputStatus: http.StatusUnprocessableEntity,
+				OutputBodyObject: map[string]interface{}{
+					"error": "Artifact name release violates naming policy: " +
+						"name does not match pattern app-.*",
+					"request_id": "test",
+					"policy": images.NamingPolicy{
+						Pattern: "app-.*",
+						Semver:  true,
+					},
+				},
+			},
+		},
 		{
 			InputBodyObject: []h.Part{
 				{
@@ -477,3 +967,183 @@ func TestSoftwareImagesControllerDownloadLink(t *testing.T) {
 		h.CheckRecordedResponse(t, recorded, testCase.JSONResponseParams)
 	}
 }
+
+func TestSoftwareImagesControllerNewImageChangelog(t *testing.T) {
+	imageBody := []byte("123456790")
+	changelog := "# 1.2.0\n\n* fixed boot loop on rollback"
+
+	model := &mocks.ImagesModel{}
+	model.On("CreateImage", h.ContextMatcher(),
+		mock.MatchedBy(func(msg *MultipartUploadMsg) bool {
+			return msg.MetaConstructor.Description == "dt" &&
+				msg.MetaConstructor.Changelog == changelog
+		})).
+		Return("1234", nil)
+
+	api := setUpRestTest("/r", rest.Post,
+		NewSoftwareImagesController(model, new(view.RESTView)).NewImage)
+
+	req := h.MakeMultipartRequest("POST", "http://localhost/r",
+		"multipart/form-data", []h.Part{
+			{
+				FieldName:  "size",
+				FieldValue: strconv.Itoa(len(imageBody)),
+			},
+			{
+				FieldName:  "description",
+				FieldValue: "dt",
+			},
+			{
+				FieldName:  "changelog",
+				FieldValue: changelog,
+			},
+			{
+				FieldName:   "artifact",
+				ContentType: "application/octet-stream",
+				ImageData:   imageBody,
+			},
+		})
+	req.Header.Add(requestid.RequestIdHeader, "test")
+	recorded := test.RunRequest(t, api.MakeHandler(), req)
+
+	h.CheckRecordedResponse(t, recorded, h.JSONResponseParams{
+		OutputStatus:  http.StatusCreated,
+		OutputHeaders: map[string]string{"Location": "./r/1234"},
+	})
+	model.AssertExpectations(t)
+}
+
+func TestControllerFetchImage(t *testing.T) {
+	imagesModel := &mocks.ImagesModel{}
+	controller := NewSoftwareImagesController(imagesModel, new(view.RESTView))
+
+	api := setUpRestTest("/r", rest.Post, controller.FetchImage)
+
+	// no payload
+	recorded := test.RunRequest(t, api.MakeHandler(),
+		test.MakeSimpleRequest("POST", "http://localhost/r", nil))
+	recorded.CodeIs(http.StatusBadRequest)
+
+	// plain http
+	recorded = test.RunRequest(t, api.MakeHandler(),
+		test.MakeSimpleRequest("POST", "http://localhost/r",
+			map[string]string{"uri": "http://ci.example.com/app.mender"}))
+	recorded.CodeIs(http.StatusBadRequest)
+
+	// model error
+	uri := "https://ci.example.com/app-1.mender"
+	imagesModel.On("FetchImage", h.ContextMatcher(),
+		&images.FetchConstructor{URI: uri}).
+		Return("", errors.New("error"))
+	recorded = test.RunRequest(t, api.MakeHandler(),
+		test.MakeSimpleRequest("POST", "http://localhost/r",
+			map[string]string{"uri": uri}))
+	recorded.CodeIs(http.StatusInternalServerError)
+
+	// ok
+	uri = "https://ci.example.com/app-2.mender"
+	constructor := &images.FetchConstructor{
+		URI:      uri,
+		Checksum: "0f1d934b48b53ee5e185bd2753dd8cf5cc1b7999e5369236b0d0daec49cb147f",
+		SoftwareImageMetaConstructor: images.SoftwareImageMetaConstructor{
+			Description: "nightly",
+		},
+	}
+	imagesModel.On("FetchImage", h.ContextMatcher(), constructor).
+		Return("1234", nil)
+	req := test.MakeSimpleRequest("POST", "http://localhost/r", constructor)
+	req.Header.Add(requestid.RequestIdHeader, "test")
+	recorded = test.RunRequest(t, api.MakeHandler(), req)
+	recorded.CodeIs(http.StatusCreated)
+	recorded.HeaderIs("Location", "./r/1234")
+}
+
+func TestControllerArtifactsLimit(t *testing.T) {
+	imagesModel := &mocks.ImagesModel{}
+	limitsModel := &mocks.LimitsModel{}
+	controller := NewSoftwareImagesController(imagesModel, new(view.RESTView))
+	controller.SetLimits(limitsModel)
+
+	api := setUpRestTest("/r", rest.Post, controller.FetchImage)
+	body := map[string]string{"uri": "https://ci.example.com/app.mender"}
+
+	// limit lookup error
+	limitsModel.On("GetLimit", h.ContextMatcher(), limits.LimitArtifacts).
+		Return(nil, errors.New("error")).Once()
+	recorded := test.RunRequest(t, api.MakeHandler(),
+		test.MakeSimpleRequest("POST", "http://localhost/r", body))
+	recorded.CodeIs(http.StatusInternalServerError)
+
+	// limit reached
+	limitsModel.On("GetLimit", h.ContextMatcher(), limits.LimitArtifacts).
+		Return(&limits.Limit{Name: limits.LimitArtifacts, Value: 2}, nil).Once()
+	imagesModel.On("ListImages", h.ContextMatcher(), map[string]string(nil)).
+		Return([]*images.SoftwareImage{{}, {}}, nil).Once()
+	req := test.MakeSimpleRequest("POST", "http://localhost/r", body)
+	req.Header.Add(requestid.RequestIdHeader, "test")
+	recorded = test.RunRequest(t, api.MakeHandler(), req)
+	recorded.CodeIs(http.StatusUnprocessableEntity)
+	recorded.BodyIs(`{"error":"artifacts limit of 2 exceeded","request_id":"test"}`)
+
+	// below limit
+	limitsModel.On("GetLimit", h.ContextMatcher(), limits.LimitArtifacts).
+		Return(&limits.Limit{Name: limits.LimitArtifacts, Value: 3}, nil).Once()
+	imagesModel.On("ListImages", h.ContextMatcher(), map[string]string(nil)).
+		Return([]*images.SoftwareImage{{}, {}}, nil).Once()
+	imagesModel.On("FetchImage", h.ContextMatcher(),
+		&images.FetchConstructor{URI: body["uri"]}).
+		Return("1234", nil)
+	recorded = test.RunRequest(t, api.MakeHandler(),
+		test.MakeSimpleRequest("POST", "http://localhost/r", body))
+	recorded.CodeIs(http.StatusCreated)
+
+	limitsModel.AssertExpectations(t)
+	imagesModel.AssertExpectations(t)
+}
+
+func TestControllerGetFetch(t *testing.T) {
+	imagesModel := &mocks.ImagesModel{}
+	controller := NewSoftwareImagesController(imagesModel, new(view.RESTView))
+
+	api := setUpRestTest("/r/:id", rest.Get, controller.GetFetch)
+
+	// wrong id
+	recorded := test.RunRequest(t, api.MakeHandler(),
+		test.MakeSimpleRequest("GET", "http://localhost/r/wrong_id", nil))
+	recorded.CodeIs(http.StatusBadRequest)
+
+	// model error
+	id := uuid.NewV4().String()
+	imagesModel.On("GetFetch", h.ContextMatcher(), id).
+		Return(nil, errors.New("error"))
+	recorded = test.RunRequest(t, api.MakeHandler(),
+		test.MakeSimpleRequest("GET", "http://localhost/r/"+id, nil))
+	recorded.CodeIs(http.StatusInternalServerError)
+
+	// not found
+	id = uuid.NewV4().String()
+	imagesModel.On("GetFetch", h.ContextMatcher(), id).
+		Return(nil, nil)
+	recorded = test.RunRequest(t, api.MakeHandler(),
+		test.MakeSimpleRequest("GET", "http://localhost/r/"+id, nil))
+	recorded.CodeIs(http.StatusNotFound)
+
+	// ok
+	id = uuid.NewV4().String()
+	fetch := &images.Fetch{
+		Id:         id,
+		URI:        "https://ci.example.com/app.mender",
+		Status:     images.FetchStatusDownloading,
+		Size:       1024,
+		Downloaded: 512,
+	}
+	imagesModel.On("GetFetch", h.ContextMatcher(), id).
+		Return(fetch, nil)
+	req := test.MakeSimpleRequest("GET", "http://localhost/r/"+id, nil)
+	req.Header.Add(requestid.RequestIdHeader, "test")
+	recorded = test.RunRequest(t, api.MakeHandler(), req)
+	h.CheckRecordedResponse(t, recorded, h.JSONResponseParams{
+		OutputStatus:     http.StatusOK,
+		OutputBodyObject: fetch,
+	})
+}
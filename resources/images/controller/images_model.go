@@ -35,8 +35,14 @@ var (
 	ErrModelImageInActiveDeployment     = errors.New("Image is used in active deployment and cannot be removed")
 	ErrModelImageUsedInAnyDeployment    = errors.New("Image has already been used in deployment")
 	ErrModelParsingArtifactFailed       = errors.New("Cannot parse artifact file")
+	ErrModelParsingTimeout              = errors.New("Parsing artifact file took too long")
+	ErrModelParserBusy                  = errors.New("Too many artifacts being processed, try again later")
+	ErrModelImageLocked                 = errors.New("Image is locked")
+	ErrModelImageNotDeployed            = errors.New("Image has not been used in any deployment yet")
+	ErrModelMissingInputNamingPolicy    = errors.New("Missing input naming policy")
 )
 
+// Domain model for artifacts
 type ImagesModel interface {
 	ListImages(ctx context.Context,
 		filters map[string]string) ([]*images.SoftwareImage, error)
@@ -48,4 +54,18 @@ type ImagesModel interface {
 		multipartUploadMsg *MultipartUploadMsg) (string, error)
 	EditImage(ctx context.Context, id string,
 		constructorData *images.SoftwareImageMetaConstructor) (bool, error)
+	FetchImage(ctx context.Context,
+		constructor *images.FetchConstructor) (string, error)
+	GetFetch(ctx context.Context, id string) (*images.Fetch, error)
+	ParserStats(ctx context.Context) (*images.ParserStats, error)
+	ListTrashedImages(ctx context.Context) ([]*images.SoftwareImage, error)
+	RestoreImage(ctx context.Context, imageID string) error
+	PurgeImage(ctx context.Context, imageID string) error
+	LockImage(ctx context.Context, imageID string) error
+	UnlockImage(ctx context.Context, imageID string) error
+	GetCompatibility(ctx context.Context) (*images.CompatibilityMatrix, error)
+	GetLatestImage(ctx context.Context,
+		namePrefix, deviceType string) (*images.SoftwareImage, error)
+	GetNamingPolicy(ctx context.Context) (*images.NamingPolicy, error)
+	SetNamingPolicy(ctx context.Context, policy *images.NamingPolicy) error
 }
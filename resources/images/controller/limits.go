@@ -0,0 +1,56 @@
+// Copyright 2018 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package controller
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/deployments/resources/limits"
+)
+
+// LimitsModel gives limits of the tenant.
+type LimitsModel interface {
+	GetLimit(ctx context.Context, name string) (*limits.Limit, error)
+}
+
+// SetLimits enables enforcement of the tenant limits of artifacts.
+func (s *SoftwareImagesController) SetLimits(limits LimitsModel) {
+	s.limits = limits
+}
+
+// CheckArtifactsLimit returns *limits.ExceededError if one more artifact
+// would exceed the artifacts limit of the tenant.
+func (s *SoftwareImagesController) CheckArtifactsLimit(ctx context.Context) error {
+	if s.limits == nil {
+		return nil
+	}
+
+	limit, err := s.limits.GetLimit(ctx, limits.LimitArtifacts)
+	if err != nil {
+		return err
+	}
+	if limit.Value == 0 {
+		return nil
+	}
+
+	list, err := s.model.ListImages(ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "Counting artifacts")
+	}
+
+	return limit.Check(uint64(len(list)) + 1)
+}
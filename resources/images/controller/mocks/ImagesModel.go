@@ -103,6 +103,73 @@ func (_m *ImagesModel) EditImage(ctx context.Context, id string, constructorData
 	return r0, r1
 }
 
+// FetchImage provides a mock function with given fields: ctx, constructor
+func (_m *ImagesModel) FetchImage(ctx context.Context, constructor *images.FetchConstructor) (string, error) {
+	ret := _m.Called(ctx, constructor)
+
+	var r0 string
+	if rf, ok := ret.Get(0).(func(context.Context, *images.FetchConstructor) string); ok {
+		r0 = rf(ctx, constructor)
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, *images.FetchConstructor) error); ok {
+		r1 = rf(ctx, constructor)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetCompatibility provides a mock function with given fields: ctx
+func (_m *ImagesModel) GetCompatibility(ctx context.Context) (*images.CompatibilityMatrix, error) {
+	ret := _m.Called(ctx)
+
+	var r0 *images.CompatibilityMatrix
+	if rf, ok := ret.Get(0).(func(context.Context) *images.CompatibilityMatrix); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*images.CompatibilityMatrix)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetFetch provides a mock function with given fields: ctx, id
+func (_m *ImagesModel) GetFetch(ctx context.Context, id string) (*images.Fetch, error) {
+	ret := _m.Called(ctx, id)
+
+	var r0 *images.Fetch
+	if rf, ok := ret.Get(0).(func(context.Context, string) *images.Fetch); ok {
+		r0 = rf(ctx, id)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*images.Fetch)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // GetImage provides a mock function with given fields: ctx, id
 func (_m *ImagesModel) GetImage(ctx context.Context, id string) (*images.SoftwareImage, error) {
 	ret := _m.Called(ctx, id)
@@ -126,6 +193,52 @@ func (_m *ImagesModel) GetImage(ctx context.Context, id string) (*images.Softwar
 	return r0, r1
 }
 
+// GetLatestImage provides a mock function with given fields: ctx, namePrefix, deviceType
+func (_m *ImagesModel) GetLatestImage(ctx context.Context, namePrefix string, deviceType string) (*images.SoftwareImage, error) {
+	ret := _m.Called(ctx, namePrefix, deviceType)
+
+	var r0 *images.SoftwareImage
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) *images.SoftwareImage); ok {
+		r0 = rf(ctx, namePrefix, deviceType)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*images.SoftwareImage)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string, string) error); ok {
+		r1 = rf(ctx, namePrefix, deviceType)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetNamingPolicy provides a mock function with given fields: ctx
+func (_m *ImagesModel) GetNamingPolicy(ctx context.Context) (*images.NamingPolicy, error) {
+	ret := _m.Called(ctx)
+
+	var r0 *images.NamingPolicy
+	if rf, ok := ret.Get(0).(func(context.Context) *images.NamingPolicy); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*images.NamingPolicy)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // ListImages provides a mock function with given fields: ctx, filters
 func (_m *ImagesModel) ListImages(ctx context.Context, filters map[string]string) ([]*images.SoftwareImage, error) {
 	ret := _m.Called(ctx, filters)
@@ -149,4 +262,120 @@ func (_m *ImagesModel) ListImages(ctx context.Context, filters map[string]string
 	return r0, r1
 }
 
+// ListTrashedImages provides a mock function with given fields: ctx
+func (_m *ImagesModel) ListTrashedImages(ctx context.Context) ([]*images.SoftwareImage, error) {
+	ret := _m.Called(ctx)
+
+	var r0 []*images.SoftwareImage
+	if rf, ok := ret.Get(0).(func(context.Context) []*images.SoftwareImage); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*images.SoftwareImage)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// LockImage provides a mock function with given fields: ctx, imageID
+func (_m *ImagesModel) LockImage(ctx context.Context, imageID string) error {
+	ret := _m.Called(ctx, imageID)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, imageID)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// ParserStats provides a mock function with given fields: ctx
+func (_m *ImagesModel) ParserStats(ctx context.Context) (*images.ParserStats, error) {
+	ret := _m.Called(ctx)
+
+	var r0 *images.ParserStats
+	if rf, ok := ret.Get(0).(func(context.Context) *images.ParserStats); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*images.ParserStats)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// PurgeImage provides a mock function with given fields: ctx, imageID
+func (_m *ImagesModel) PurgeImage(ctx context.Context, imageID string) error {
+	ret := _m.Called(ctx, imageID)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, imageID)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// RestoreImage provides a mock function with given fields: ctx, imageID
+func (_m *ImagesModel) RestoreImage(ctx context.Context, imageID string) error {
+	ret := _m.Called(ctx, imageID)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, imageID)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// SetNamingPolicy provides a mock function with given fields: ctx, policy
+func (_m *ImagesModel) SetNamingPolicy(ctx context.Context, policy *images.NamingPolicy) error {
+	ret := _m.Called(ctx, policy)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *images.NamingPolicy) error); ok {
+		r0 = rf(ctx, policy)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// UnlockImage provides a mock function with given fields: ctx, imageID
+func (_m *ImagesModel) UnlockImage(ctx context.Context, imageID string) error {
+	ret := _m.Called(ctx, imageID)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, imageID)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 var _ controller.ImagesModel = (*ImagesModel)(nil)
@@ -22,7 +22,10 @@ import (
 type RESTView interface {
 	RenderSuccessPost(w rest.ResponseWriter, r *rest.Request, id string)
 	RenderSuccessGet(w rest.ResponseWriter, object interface{})
+	RenderCollection(w rest.ResponseWriter, r *rest.Request, collection interface{})
 	RenderError(w rest.ResponseWriter, r *rest.Request, err error, status int, l *log.Logger)
+	RenderErrorDetails(w rest.ResponseWriter, r *rest.Request, err error, status int,
+		details map[string]interface{}, l *log.Logger)
 	RenderInternalError(w rest.ResponseWriter, r *rest.Request, err error, l *log.Logger)
 	RenderErrorNotFound(w rest.ResponseWriter, r *rest.Request, l *log.Logger)
 	RenderSuccessDelete(w rest.ResponseWriter)
@@ -0,0 +1,101 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package images
+
+import (
+	"errors"
+	"net/url"
+	"time"
+
+	"github.com/asaskevich/govalidator"
+)
+
+// Artifact fetch statuses
+const (
+	FetchStatusPending     = "pending"
+	FetchStatusDownloading = "downloading"
+	FetchStatusDone        = "done"
+	FetchStatusFailed      = "failed"
+)
+
+var (
+	ErrFetchURINotHTTPS = errors.New("uri: only https scheme is supported")
+)
+
+// FetchConstructor describes remote artifact to be downloaded by the service.
+type FetchConstructor struct {
+	// Artifact location, https only
+	URI string `json:"uri" valid:"url,required"`
+
+	// Expected SHA256 checksum of the artifact file, hex encoded, optional
+	Checksum string `json:"checksum,omitempty" valid:"hexadecimal,length(64|64),optional"`
+
+	// User provided artifact meta data
+	SoftwareImageMetaConstructor `valid:"-"`
+}
+
+// Validate checks structure according to valid tags.
+func (f *FetchConstructor) Validate() error {
+	if _, err := govalidator.ValidateStruct(f); err != nil {
+		return err
+	}
+
+	uri, err := url.Parse(f.URI)
+	if err != nil {
+		return err
+	}
+	if uri.Scheme != "https" {
+		return ErrFetchURINotHTTPS
+	}
+
+	return f.SoftwareImageMetaConstructor.Validate()
+}
+
+// Fetch tracks progress of the remote artifact download.
+type Fetch struct {
+	Id string `json:"id" bson:"_id"`
+
+	URI string `json:"uri" bson:"uri"`
+
+	Status string `json:"status" bson:"status"`
+
+	// Artifact size reported by the remote server
+	Size int64 `json:"size" bson:"size"`
+
+	// Number of bytes downloaded so far
+	Downloaded int64 `json:"downloaded" bson:"downloaded"`
+
+	// ID of the created artifact, set when fetch is done
+	ArtifactID string `json:"artifact_id,omitempty" bson:"artifact_id,omitempty"`
+
+	// Failure reason, set when fetch failed
+	Error string `json:"error,omitempty" bson:"error,omitempty"`
+
+	Created  *time.Time `json:"created" bson:"created"`
+	Modified *time.Time `json:"modified" bson:"modified"`
+}
+
+// NewFetch creates pending fetch for the given constructor.
+func NewFetch(id string, constructor *FetchConstructor) *Fetch {
+	now := time.Now()
+
+	return &Fetch{
+		Id:       id,
+		URI:      constructor.URI,
+		Status:   FetchStatusPending,
+		Created:  &now,
+		Modified: &now,
+	}
+}
@@ -0,0 +1,88 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package images
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFetchConstructorValidate(t *testing.T) {
+	checksum := strings.Repeat("ab", 32)
+
+	testCases := map[string]struct {
+		constructor FetchConstructor
+		valid       bool
+	}{
+		"ok": {
+			constructor: FetchConstructor{
+				URI: "https://ci.example.com/artifacts/app-1.0.mender",
+			},
+			valid: true,
+		},
+		"ok, checksum and meta": {
+			constructor: FetchConstructor{
+				URI:      "https://ci.example.com/artifacts/app-1.0.mender",
+				Checksum: checksum,
+				SoftwareImageMetaConstructor: SoftwareImageMetaConstructor{
+					Description: "nightly",
+					Changelog:   "* fixes",
+				},
+			},
+			valid: true,
+		},
+		"missing uri": {
+			constructor: FetchConstructor{},
+		},
+		"plain http": {
+			constructor: FetchConstructor{
+				URI: "http://ci.example.com/artifacts/app-1.0.mender",
+			},
+		},
+		"checksum too short": {
+			constructor: FetchConstructor{
+				URI:      "https://ci.example.com/artifacts/app-1.0.mender",
+				Checksum: "abcdef",
+			},
+		},
+		"checksum not hex": {
+			constructor: FetchConstructor{
+				URI:      "https://ci.example.com/artifacts/app-1.0.mender",
+				Checksum: strings.Repeat("zz", 32),
+			},
+		},
+		"invalid meta": {
+			constructor: FetchConstructor{
+				URI: "https://ci.example.com/artifacts/app-1.0.mender",
+				SoftwareImageMetaConstructor: SoftwareImageMetaConstructor{
+					Description: strings.Repeat("a", 4097),
+				},
+			},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			err := tc.constructor.Validate()
+			if tc.valid {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}
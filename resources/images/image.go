@@ -24,6 +24,9 @@ import (
 type SoftwareImageMetaConstructor struct {
 	// Image description
 	Description string `json:"description,omitempty" valid:"length(1|4096),optional"`
+
+	// Release notes in markdown format, stored as provided
+	Changelog string `json:"changelog,omitempty" valid:"length(1|65536),optional"`
 }
 
 // Creates new, empty SoftwareImageMetaConstructor
@@ -64,6 +67,31 @@ type SoftwareImageMetaArtifactConstructor struct {
 
 	// List of updates
 	Updates []Update `json:"updates" valid:"-"`
+
+	// Names of state scripts included in the artifact header
+	Scripts []string `json:"scripts,omitempty" bson:"scripts,omitempty" valid:"-"`
+
+	// Checksums of the state scripts, not set for artifacts uploaded
+	// before checksums were recorded
+	StateScripts []StateScript `json:"state_scripts,omitempty" bson:"state_scripts,omitempty" valid:"-"`
+
+	// What the artifact provides once installed, not set for artifacts
+	// uploaded before provides were recorded
+	Provides []ArtifactProvide `json:"provides,omitempty" bson:"provides,omitempty" valid:"-"`
+
+	// Patterns of the provides cleared from the device when the artifact
+	// is installed
+	ClearsProvides []string `json:"clears_provides,omitempty" bson:"clears_provides,omitempty" valid:"-"`
+}
+
+// StateScript describes a state script included in the artifact header.
+type StateScript struct {
+	Name string `json:"name" bson:"name"`
+
+	// SHA256 checksum of the script, hex encoded
+	Checksum string `json:"checksum" bson:"checksum"`
+
+	Size int64 `json:"size" bson:"size"`
 }
 
 func NewSoftwareImageMetaArtifactConstructor() *SoftwareImageMetaArtifactConstructor {
@@ -89,6 +117,33 @@ type SoftwareImage struct {
 
 	// Last modification time, including image upload time
 	Modified *time.Time `json:"modified" valid:"_"`
+
+	// SHA256 checksum of the artifact file, hex encoded
+	Checksum string `json:"checksum,omitempty" bson:"checksum,omitempty"`
+
+	// Size of the artifact file in bytes, 0 for artifacts uploaded
+	// before sizes were recorded
+	Size int64 `json:"size,omitempty" bson:"size,omitempty"`
+
+	// Key of the artifact file in the file storage, image ID if empty
+	ObjectID string `json:"-" bson:"object_id,omitempty"`
+
+	// Artifact file was moved to the archive storage class
+	Archived bool `json:"archived,omitempty" bson:"archived,omitempty"`
+
+	// Deletion time of the artifact kept in the trash, nil if not deleted
+	Trashed *time.Time `json:"trashed,omitempty" bson:"trashed,omitempty"`
+
+	// Artifact can not be deleted nor edited while locked
+	Locked *Lock `json:"locked,omitempty" bson:"locked,omitempty"`
+}
+
+// Lock records who locked the artifact and when.
+type Lock struct {
+	// Subject of the user who locked the artifact
+	Subject string `json:"subject,omitempty" bson:"subject,omitempty"`
+
+	Time time.Time `json:"time" bson:"time"`
 }
 
 // NewSoftwareImage creates new software image object.
@@ -112,6 +167,16 @@ func (s *SoftwareImage) SetModified(time time.Time) {
 	s.Modified = &time
 }
 
+// FileID returns key of the artifact file in the file storage.
+// Artifacts uploaded before files were keyed by content are stored
+// under the image ID.
+func (s *SoftwareImage) FileID() string {
+	if s.ObjectID != "" {
+		return s.ObjectID
+	}
+	return s.Id
+}
+
 // Validate checkes structure according to valid tags.
 func (s *SoftwareImage) Validate() error {
 	_, err := govalidator.ValidateStruct(s)
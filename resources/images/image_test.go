@@ -72,3 +72,31 @@ func TestValidateCorrectImage(t *testing.T) {
 		t.FailNow()
 	}
 }
+
+func TestValidateImageMetaChangelog(t *testing.T) {
+	image := NewSoftwareImageMetaConstructor()
+	image.Changelog = "# 1.2.0\n\n* fixed boot loop on rollback"
+
+	if err := image.Validate(); err != nil {
+		t.FailNow()
+	}
+
+	image.Changelog = string(make([]byte, 65537))
+	if err := image.Validate(); err == nil {
+		t.FailNow()
+	}
+}
+
+func TestSoftwareImageFileID(t *testing.T) {
+	image := NewSoftwareImage(validUUIDv4,
+		NewSoftwareImageMetaConstructor(), NewSoftwareImageMetaArtifactConstructor())
+
+	if image.FileID() != validUUIDv4 {
+		t.FailNow()
+	}
+
+	image.ObjectID = "sha256-abc"
+	if image.FileID() != "sha256-abc" {
+		t.FailNow()
+	}
+}
@@ -0,0 +1,87 @@
+// Copyright 2018 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package mirror
+
+import (
+	"context"
+	"net"
+	"strings"
+
+	"github.com/ant0ine/go-json-rest/rest"
+)
+
+const (
+	HttpHeaderForwardedFor = "X-Forwarded-For"
+)
+
+type clientContextKey struct{}
+
+// Client describes the device requesting a download link.
+type Client struct {
+	// Source IP of the request
+	IP net.IP
+	// Attributes reported by the device in the request (e.g. device_type)
+	Attributes map[string]string
+}
+
+// WithClient returns context carrying client information.
+func WithClient(ctx context.Context, client *Client) context.Context {
+	return context.WithValue(ctx, clientContextKey{}, client)
+}
+
+// ClientFromContext extracts client information from context, nil if not set.
+func ClientFromContext(ctx context.Context) *Client {
+	client, _ := ctx.Value(clientContextKey{}).(*Client)
+	return client
+}
+
+// ClientFromRequest collects source IP and query attributes of the request.
+// First address from X-Forwarded-For header takes precedence over the
+// connection remote address, as the service is usually run behind a gateway.
+func ClientFromRequest(r *rest.Request) *Client {
+	client := &Client{
+		Attributes: make(map[string]string),
+	}
+
+	addr := r.RemoteAddr
+	if fwd := r.Header.Get(HttpHeaderForwardedFor); fwd != "" {
+		addr = strings.TrimSpace(strings.Split(fwd, ",")[0])
+	}
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		addr = host
+	}
+	client.IP = net.ParseIP(addr)
+
+	for name, values := range r.URL.Query() {
+		if len(values) > 0 {
+			client.Attributes[name] = values[0]
+		}
+	}
+
+	return client
+}
+
+// ClientMiddleware adds client information to the request's context.
+type ClientMiddleware struct{}
+
+// MiddlewareFunc makes ClientMiddleware implement the Middleware interface.
+func (mw *ClientMiddleware) MiddlewareFunc(h rest.HandlerFunc) rest.HandlerFunc {
+	return func(w rest.ResponseWriter, r *rest.Request) {
+		ctx := WithClient(r.Context(), ClientFromRequest(r))
+		r.Request = r.WithContext(ctx)
+
+		h(w, r)
+	}
+}
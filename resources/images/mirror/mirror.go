@@ -0,0 +1,195 @@
+// Copyright 2018 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package mirror
+
+import (
+	"context"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/deployments/resources/images"
+)
+
+// Errors
+var (
+	ErrInvalidRule = errors.New("invalid mirror rule")
+)
+
+// GetRequester generates download links for artifacts.
+// Matches GetRequester interface of the deployments model.
+type GetRequester interface {
+	GetRequest(ctx context.Context, objectId string,
+		duration time.Duration, responseContentType string) (*images.Link, error)
+}
+
+// Rule selects the mirror URL for a device either by the network the device
+// request originates from or by a value of a device attribute.
+type Rule struct {
+	// Network matched against source IP of the device request, optional
+	Network *net.IPNet
+
+	// Attribute name and value matched against device attributes, optional
+	Attribute string
+	Value     string
+
+	// Mirror base URL replacing scheme and host of the generated link
+	URL *url.URL
+}
+
+// Matches returns true if the rule applies to the given client.
+func (r Rule) Matches(client *Client) bool {
+	if client == nil {
+		return false
+	}
+
+	if r.Network != nil {
+		return client.IP != nil && r.Network.Contains(client.IP)
+	}
+
+	if r.Attribute != "" {
+		value, ok := client.Attributes[r.Attribute]
+		return ok && value == r.Value
+	}
+
+	return false
+}
+
+// Rewrite returns a copy of the link pointing to the mirror.
+// Scheme and host are replaced, the mirror path (if any) is prepended to
+// the original path; query string (including signature) is preserved.
+func (r Rule) Rewrite(link *images.Link) (*images.Link, error) {
+	uri, err := url.Parse(link.Uri)
+	if err != nil {
+		return nil, errors.Wrap(err, "parsing download link")
+	}
+
+	uri.Scheme = r.URL.Scheme
+	uri.Host = r.URL.Host
+	if prefix := strings.TrimSuffix(r.URL.Path, "/"); prefix != "" {
+		uri.Path = prefix + uri.Path
+		if uri.RawPath != "" {
+			uri.RawPath = prefix + uri.RawPath
+		}
+	}
+
+	return images.NewLink(uri.String(), link.Expire), nil
+}
+
+// ParseNetworkRule parses rule in "<CIDR>=<URL>" format,
+// e.g. "10.1.0.0/16=https://eu.cdn.example.com".
+func ParseNetworkRule(rule string) (*Rule, error) {
+	parts := strings.SplitN(rule, "=", 2)
+	if len(parts) != 2 {
+		return nil, errors.Wrap(ErrInvalidRule, rule)
+	}
+
+	_, network, err := net.ParseCIDR(strings.TrimSpace(parts[0]))
+	if err != nil {
+		return nil, errors.Wrapf(ErrInvalidRule, "%s: %s", rule, err.Error())
+	}
+
+	mirrorURL, err := parseMirrorURL(parts[1])
+	if err != nil {
+		return nil, errors.Wrapf(ErrInvalidRule, "%s: %s", rule, err.Error())
+	}
+
+	return &Rule{
+		Network: network,
+		URL:     mirrorURL,
+	}, nil
+}
+
+// ParseAttributeRule parses rule in "<attribute>:<value>=<URL>" format,
+// e.g. "device_type:raspberrypi4=https://eu.cdn.example.com".
+func ParseAttributeRule(rule string) (*Rule, error) {
+	parts := strings.SplitN(rule, "=", 2)
+	if len(parts) != 2 {
+		return nil, errors.Wrap(ErrInvalidRule, rule)
+	}
+
+	attr := strings.SplitN(strings.TrimSpace(parts[0]), ":", 2)
+	if len(attr) != 2 || attr[0] == "" || attr[1] == "" {
+		return nil, errors.Wrap(ErrInvalidRule, rule)
+	}
+
+	mirrorURL, err := parseMirrorURL(parts[1])
+	if err != nil {
+		return nil, errors.Wrapf(ErrInvalidRule, "%s: %s", rule, err.Error())
+	}
+
+	return &Rule{
+		Attribute: attr[0],
+		Value:     attr[1],
+		URL:       mirrorURL,
+	}, nil
+}
+
+func parseMirrorURL(raw string) (*url.URL, error) {
+	mirrorURL, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return nil, err
+	}
+
+	if mirrorURL.Scheme == "" || mirrorURL.Host == "" {
+		return nil, errors.New("mirror URL requires scheme and host")
+	}
+
+	return mirrorURL, nil
+}
+
+// Linker decorates GetRequester rewriting generated download links
+// to the first mirror matching the requesting device.
+// Links are left unchanged if no rule matches.
+type Linker struct {
+	linker GetRequester
+	rules  []Rule
+}
+
+// NewLinker creates new link rewriting decorator.
+// Rules are evaluated in order, first match wins.
+func NewLinker(linker GetRequester, rules ...Rule) *Linker {
+	return &Linker{
+		linker: linker,
+		rules:  rules,
+	}
+}
+
+func (l *Linker) GetRequest(ctx context.Context, objectId string,
+	duration time.Duration, responseContentType string) (*images.Link, error) {
+
+	link, err := l.linker.GetRequest(ctx, objectId, duration, responseContentType)
+	if err != nil || link == nil {
+		return link, err
+	}
+
+	return l.RewriteLink(ctx, link)
+}
+
+// RewriteLink rewrites the link to the first mirror matching the client
+// found in the context.
+func (l *Linker) RewriteLink(ctx context.Context, link *images.Link) (*images.Link, error) {
+	client := ClientFromContext(ctx)
+	for _, rule := range l.rules {
+		if rule.Matches(client) {
+			return rule.Rewrite(link)
+		}
+	}
+
+	return link, nil
+}
@@ -0,0 +1,128 @@
+// Copyright 2018 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package mirror
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mendersoftware/deployments/resources/images"
+)
+
+type fakeLinker struct {
+	link *images.Link
+	err  error
+}
+
+func (f *fakeLinker) GetRequest(ctx context.Context, objectId string,
+	duration time.Duration, responseContentType string) (*images.Link, error) {
+	return f.link, f.err
+}
+
+func TestParseRules(t *testing.T) {
+	t.Parallel()
+
+	rule, err := ParseNetworkRule("10.1.0.0/16=https://eu.cdn.example.com/mender")
+	assert.NoError(t, err)
+	assert.Equal(t, "10.1.0.0/16", rule.Network.String())
+	assert.Equal(t, "eu.cdn.example.com", rule.URL.Host)
+
+	_, err = ParseNetworkRule("10.1.0.0=https://eu.cdn.example.com")
+	assert.Error(t, err)
+
+	_, err = ParseNetworkRule("10.1.0.0/16=eu.cdn.example.com")
+	assert.Error(t, err)
+
+	rule, err = ParseAttributeRule("device_type:rpi4=https://rpi.cdn.example.com")
+	assert.NoError(t, err)
+	assert.Equal(t, "device_type", rule.Attribute)
+	assert.Equal(t, "rpi4", rule.Value)
+
+	_, err = ParseAttributeRule("device_type=https://rpi.cdn.example.com")
+	assert.Error(t, err)
+}
+
+func TestLinkerGetRequest(t *testing.T) {
+	t.Parallel()
+
+	expire := time.Now()
+	origin := images.NewLink(
+		"https://s3.amazonaws.com/bucket/tenant/artifact?X-Amz-Signature=abc", expire)
+
+	eu, _ := ParseNetworkRule("10.1.0.0/16=https://eu.cdn.example.com/mender")
+	rpi, _ := ParseAttributeRule("device_type:rpi4=http://rpi.cdn.example.com")
+
+	testCases := map[string]struct {
+		client *Client
+		err    error
+
+		outLink string
+		outErr  error
+	}{
+		"no client": {
+			outLink: origin.Uri,
+		},
+		"network match": {
+			client: &Client{IP: net.ParseIP("10.1.2.3")},
+			outLink: "https://eu.cdn.example.com/mender/bucket/tenant/artifact" +
+				"?X-Amz-Signature=abc",
+		},
+		"attribute match": {
+			client: &Client{
+				IP:         net.ParseIP("192.168.1.1"),
+				Attributes: map[string]string{"device_type": "rpi4"},
+			},
+			outLink: "http://rpi.cdn.example.com/bucket/tenant/artifact" +
+				"?X-Amz-Signature=abc",
+		},
+		"no match": {
+			client: &Client{
+				IP:         net.ParseIP("192.168.1.1"),
+				Attributes: map[string]string{"device_type": "bbb"},
+			},
+			outLink: origin.Uri,
+		},
+		"linker error": {
+			client: &Client{IP: net.ParseIP("10.1.2.3")},
+			err:    errors.New("signing failed"),
+			outErr: errors.New("signing failed"),
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			linker := NewLinker(&fakeLinker{link: origin, err: tc.err}, *eu, *rpi)
+
+			ctx := context.Background()
+			if tc.client != nil {
+				ctx = WithClient(ctx, tc.client)
+			}
+
+			link, err := linker.GetRequest(ctx, "artifact", time.Hour, "")
+			if tc.outErr != nil {
+				assert.EqualError(t, err, tc.outErr.Error())
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.outLink, link.Uri)
+			assert.Equal(t, expire, link.Expire)
+		})
+	}
+}
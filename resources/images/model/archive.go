@@ -0,0 +1,69 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ArchiveImages moves files of the artifacts which were not deployed since given time
+// to the archive storage class. Artifacts used by active deployments are never archived.
+// Returns number of archived artifacts.
+func (i *ImagesModel) ArchiveImages(ctx context.Context,
+	unusedSince time.Time, storageClass string) (int, error) {
+
+	list, err := i.imagesStorage.FindAll(ctx)
+	if err != nil {
+		return 0, errors.Wrap(err, "Searching for images")
+	}
+
+	archived := 0
+	for _, image := range list {
+		if image.Archived || image.Modified == nil || image.Modified.After(unusedSince) {
+			continue
+		}
+
+		active, err := i.deployments.ImageUsedInActiveDeployment(ctx, image.Id)
+		if err != nil {
+			return archived, err
+		}
+		if active {
+			continue
+		}
+
+		recent, err := i.deployments.ImageDeployedSince(ctx, image.Id, unusedSince)
+		if err != nil {
+			return archived, err
+		}
+		if recent {
+			continue
+		}
+
+		if err := i.fileStorage.Archive(ctx, image.FileID(), storageClass); err != nil {
+			return archived, errors.Wrapf(err, "Archiving artifact %s", image.Id)
+		}
+
+		image.Archived = true
+		if _, err := i.imagesStorage.Update(ctx, image); err != nil {
+			return archived, errors.Wrapf(err, "Updating artifact %s", image.Id)
+		}
+		archived++
+	}
+
+	return archived, nil
+}
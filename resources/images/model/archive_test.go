@@ -0,0 +1,130 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mendersoftware/deployments/resources/images"
+)
+
+func TestArchiveImages(t *testing.T) {
+	now := time.Now()
+	unusedSince := now.AddDate(0, 0, -30)
+	old := now.AddDate(0, 0, -60)
+
+	newImage := func(id string, modified time.Time, archived bool) *images.SoftwareImage {
+		image := images.NewSoftwareImage(id, createValidImageMeta(), createValidImageMetaArtifact())
+		image.Modified = &modified
+		image.Archived = archived
+		return image
+	}
+
+	testCases := map[string]struct {
+		images       []*images.SoftwareImage
+		findAllError error
+		useChecker   FakeUseChecker
+		archiveError error
+		updateError  error
+		outputCount  int
+		outputIDs    []string
+		outputError  string
+	}{
+		"ok": {
+			images: []*images.SoftwareImage{
+				newImage("1", old, false),
+				// uploaded recently
+				newImage("2", now, false),
+				// archived already
+				newImage("3", old, true),
+			},
+			outputCount: 1,
+			outputIDs:   []string{"1"},
+		},
+		"used in active deployment": {
+			images: []*images.SoftwareImage{
+				newImage("1", old, false),
+			},
+			useChecker: FakeUseChecker{isUsedInActiveDeployment: true},
+		},
+		"deployed recently": {
+			images: []*images.SoftwareImage{
+				newImage("1", old, false),
+			},
+			useChecker: FakeUseChecker{isDeployedSince: true},
+		},
+		"find error": {
+			findAllError: errors.New("db error"),
+			outputError:  "Searching for images: db error",
+		},
+		"use check error": {
+			images: []*images.SoftwareImage{
+				newImage("1", old, false),
+			},
+			useChecker:  FakeUseChecker{deployedSinceErr: errors.New("db error")},
+			outputError: "db error",
+		},
+		"archive error": {
+			images: []*images.SoftwareImage{
+				newImage("1", old, false),
+			},
+			archiveError: errors.New("s3 error"),
+			outputError:  "Archiving artifact 1: s3 error",
+		},
+		"update error": {
+			images: []*images.SoftwareImage{
+				newImage("1", old, false),
+			},
+			updateError: errors.New("db error"),
+			outputIDs:   []string{"1"},
+			outputError: "Updating artifact 1: db error",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			fakeIS := new(FakeImageStorage)
+			fakeIS.findAllImages = tc.images
+			fakeIS.findAllError = tc.findAllError
+			fakeIS.update = true
+			fakeIS.updateError = tc.updateError
+
+			fakeFS := new(FakeFileStorage)
+			fakeFS.archiveError = tc.archiveError
+
+			useChecker := tc.useChecker
+			iModel := NewImagesModel(fakeFS, &useChecker, fakeIS)
+
+			count, err := iModel.ArchiveImages(context.Background(), unusedSince, "GLACIER")
+			if tc.outputError != "" {
+				assert.EqualError(t, err, tc.outputError)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tc.outputCount, count)
+			assert.Equal(t, tc.outputIDs, fakeFS.archived)
+			for _, image := range tc.images {
+				if image.Id == "1" && tc.outputCount == 1 {
+					assert.True(t, image.Archived)
+				}
+			}
+		})
+	}
+}
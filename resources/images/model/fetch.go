@@ -0,0 +1,221 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/mendersoftware/go-lib-micro/log"
+	"github.com/pkg/errors"
+	"github.com/satori/go.uuid"
+
+	"github.com/mendersoftware/deployments/resources/images"
+	"github.com/mendersoftware/deployments/resources/images/controller"
+)
+
+// FetchProgressInterval limits how often download progress is persisted.
+var FetchProgressInterval = 5 * time.Second
+
+// JobTypeFetch is the background job downloading remote artifact.
+const JobTypeFetch = "artifact_fetch"
+
+var (
+	ErrModelJobsNotConfigured = errors.New("background jobs are not configured")
+)
+
+// JobQueue runs work outliving the request in the background.
+// Handlers are run with tenant identity of the enqueuing request;
+// failed jobs are retried.
+type JobQueue interface {
+	Register(jobType string,
+		handler func(ctx context.Context, payload json.RawMessage) error)
+	Enqueue(ctx context.Context, jobType string, payload interface{}) error
+}
+
+// fetchJob is the payload of the artifact fetch job
+type fetchJob struct {
+	FetchID     string                   `json:"fetch_id"`
+	Constructor *images.FetchConstructor `json:"constructor"`
+}
+
+// FetchImage registers download of the artifact from remote location
+// and queues it to be run in the background.
+// Returns ID of the fetch which can be used to track its progress.
+func (i *ImagesModel) FetchImage(ctx context.Context,
+	constructor *images.FetchConstructor) (string, error) {
+
+	if i.jobs == nil {
+		return "", ErrModelJobsNotConfigured
+	}
+
+	fetch := images.NewFetch(uuid.NewV4().String(), constructor)
+	if err := i.imagesStorage.InsertFetch(ctx, fetch); err != nil {
+		return "", errors.Wrap(err, "Storing artifact fetch")
+	}
+
+	err := i.jobs.Enqueue(ctx, JobTypeFetch, fetchJob{
+		FetchID:     fetch.Id,
+		Constructor: constructor,
+	})
+	if err != nil {
+		return "", errors.Wrap(err, "Queueing artifact fetch")
+	}
+
+	return fetch.Id, nil
+}
+
+// handleFetchJob runs the queued artifact fetch. Download failures are
+// recorded in the fetch and not retried, only failure to find the fetch is.
+func (i *ImagesModel) handleFetchJob(ctx context.Context, payload json.RawMessage) error {
+	var job fetchJob
+	if err := json.Unmarshal(payload, &job); err != nil {
+		return errors.Wrap(err, "decoding artifact fetch job")
+	}
+
+	fetch, err := i.imagesStorage.FindFetchByID(ctx, job.FetchID)
+	if err != nil {
+		return errors.Wrap(err, "Searching for artifact fetch with specified ID")
+	}
+	if fetch == nil {
+		return errors.Errorf("artifact fetch %s not found", job.FetchID)
+	}
+
+	i.fetchImage(ctx, fetch, job.Constructor)
+	return nil
+}
+
+// GetFetch returns artifact fetch with specified id, nil if not found.
+func (i *ImagesModel) GetFetch(ctx context.Context, id string) (*images.Fetch, error) {
+
+	fetch, err := i.imagesStorage.FindFetchByID(ctx, id)
+	if err != nil {
+		return nil, errors.Wrap(err, "Searching for artifact fetch with specified ID")
+	}
+
+	return fetch, nil
+}
+
+// fetchImage downloads the artifact and creates image from it,
+// recording progress and result in the fetch.
+func (i *ImagesModel) fetchImage(ctx context.Context,
+	fetch *images.Fetch, constructor *images.FetchConstructor) {
+
+	l := log.FromContext(ctx)
+
+	artifactID, err := i.downloadImage(ctx, fetch, constructor)
+	if err != nil {
+		l.Errorf("fetching artifact from %s: %s", fetch.URI, err.Error())
+		fetch.Status = images.FetchStatusFailed
+		fetch.Error = err.Error()
+	} else {
+		fetch.Status = images.FetchStatusDone
+		fetch.ArtifactID = artifactID
+		fetch.Downloaded = fetch.Size
+	}
+
+	if err := i.imagesStorage.UpdateFetch(ctx, fetch); err != nil {
+		l.Errorf("saving artifact fetch %s: %s", fetch.Id, err.Error())
+	}
+}
+
+func (i *ImagesModel) downloadImage(ctx context.Context,
+	fetch *images.Fetch, constructor *images.FetchConstructor) (string, error) {
+
+	l := log.FromContext(ctx)
+
+	req, err := http.NewRequest(http.MethodGet, fetch.URI, nil)
+	if err != nil {
+		return "", errors.Wrap(err, "creating request")
+	}
+
+	rsp, err := i.fetchClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return "", errors.Wrap(err, "downloading artifact")
+	}
+	defer rsp.Body.Close()
+
+	if rsp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected response status: %s", rsp.Status)
+	}
+	if rsp.ContentLength <= 0 {
+		return "", errors.New("remote server did not report artifact size")
+	}
+
+	fetch.Size = rsp.ContentLength
+	fetch.Status = images.FetchStatusDownloading
+	if err := i.imagesStorage.UpdateFetch(ctx, fetch); err != nil {
+		return "", errors.Wrap(err, "saving artifact fetch")
+	}
+
+	reader := &progressReader{
+		reader: rsp.Body,
+		onProgress: func(n int64) {
+			fetch.Downloaded = n
+			if err := i.imagesStorage.UpdateFetch(ctx, fetch); err != nil {
+				l.Warnf("saving artifact fetch %s progress: %s", fetch.Id, err.Error())
+			}
+		},
+	}
+
+	// the checksum is verified before the image is created
+	meta := constructor.SoftwareImageMetaConstructor
+	return i.CreateImage(ctx, &controller.MultipartUploadMsg{
+		MetaConstructor: &meta,
+		ArtifactSize:    fetch.Size,
+		ArtifactReader:  reader,
+		Checksum:        constructor.Checksum,
+	})
+}
+
+// verifyChecksum compares hex encoded checksums, expected one is not
+// verified if empty.
+func verifyChecksum(actual, expected string) error {
+	if expected == "" {
+		return nil
+	}
+
+	if !strings.EqualFold(actual, expected) {
+		return fmt.Errorf("checksum mismatch: expected %s, got %s", expected, actual)
+	}
+	return nil
+}
+
+// progressReader reports number of bytes read so far,
+// at most once per FetchProgressInterval.
+type progressReader struct {
+	reader     io.Reader
+	onProgress func(n int64)
+
+	read     int64
+	reported time.Time
+}
+
+func (r *progressReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	r.read += int64(n)
+
+	if now := time.Now(); now.Sub(r.reported) >= FetchProgressInterval {
+		r.reported = now
+		r.onProgress(r.read)
+	}
+
+	return n, err
+}
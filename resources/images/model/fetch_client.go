@@ -0,0 +1,94 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Limits of remote artifact downloads.
+const (
+	FetchMaxRedirects          = 5
+	FetchDialTimeout           = 30 * time.Second
+	FetchTLSHandshakeTimeout   = 30 * time.Second
+	FetchResponseHeaderTimeout = time.Minute
+	// Whole download, including reading the artifact
+	FetchTimeout = time.Hour
+)
+
+var (
+	ErrFetchRedirectNotHTTPS  = errors.New("redirect to non-https location")
+	ErrFetchTooManyRedirects  = errors.New("too many redirects")
+	ErrFetchAddressNotAllowed = errors.New("remote address not allowed")
+)
+
+// newFetchClient returns client for downloading remote artifacts, which
+// follows only https redirects and connects only to public addresses, so
+// that fetches can't reach internal services, e.g. cloud metadata endpoints.
+func newFetchClient() *http.Client {
+	dialer := &net.Dialer{
+		Timeout:   FetchDialTimeout,
+		KeepAlive: 30 * time.Second,
+		// checked after name resolution, for every connection
+		Control: func(network, address string, _ syscall.RawConn) error {
+			return checkFetchAddress(address)
+		},
+	}
+
+	return &http.Client{
+		Transport: &http.Transport{
+			DialContext:           dialer.DialContext,
+			TLSHandshakeTimeout:   FetchTLSHandshakeTimeout,
+			ResponseHeaderTimeout: FetchResponseHeaderTimeout,
+			IdleConnTimeout:       90 * time.Second,
+		},
+		CheckRedirect: checkFetchRedirect,
+		Timeout:       FetchTimeout,
+	}
+}
+
+func checkFetchRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) > FetchMaxRedirects {
+		return ErrFetchTooManyRedirects
+	}
+	if req.URL.Scheme != "https" {
+		return ErrFetchRedirectNotHTTPS
+	}
+	return nil
+}
+
+// checkFetchAddress rejects loopback, private, link-local, multicast and
+// unspecified addresses.
+func checkFetchAddress(address string) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return ErrFetchAddressNotAllowed
+	}
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() {
+		return errors.Wrap(ErrFetchAddressNotAllowed, host)
+	}
+	return nil
+}
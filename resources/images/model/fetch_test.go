@@ -0,0 +1,307 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mendersoftware/deployments/resources/images"
+)
+
+func TestFetchImage(t *testing.T) {
+	upd, err := MakeRootfsImageArtifact(2, false)
+	assert.NoError(t, err)
+	artifact := upd.Bytes()
+
+	sum := sha256.Sum256(artifact)
+	checksum := hex.EncodeToString(sum[:])
+
+	testCases := map[string]struct {
+		checksum    string
+		status      int
+		unknownSize bool
+		// another image has the same file
+		contentExists bool
+
+		outputStatus string
+		outputError  string
+	}{
+		"ok": {
+			status: http.StatusOK,
+
+			outputStatus: images.FetchStatusDone,
+		},
+		"ok, checksum verified": {
+			checksum: strings.ToUpper(checksum),
+			status:   http.StatusOK,
+
+			outputStatus: images.FetchStatusDone,
+		},
+		"checksum mismatch": {
+			checksum: strings.Repeat("0", 64),
+			status:   http.StatusOK,
+
+			outputStatus: images.FetchStatusFailed,
+			outputError:  "checksum mismatch: expected " + strings.Repeat("0", 64) + ", got " + checksum,
+		},
+		"checksum mismatch, file shared": {
+			checksum:      strings.Repeat("0", 64),
+			status:        http.StatusOK,
+			contentExists: true,
+
+			outputStatus: images.FetchStatusFailed,
+			outputError:  "checksum mismatch: expected " + strings.Repeat("0", 64) + ", got " + checksum,
+		},
+		"unknown size": {
+			status:      http.StatusOK,
+			unknownSize: true,
+
+			outputStatus: images.FetchStatusFailed,
+			outputError:  "remote server did not report artifact size",
+		},
+		"not found": {
+			status: http.StatusNotFound,
+
+			outputStatus: images.FetchStatusFailed,
+			outputError:  "unexpected response status: 404 Not Found",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tc.status != http.StatusOK {
+					w.WriteHeader(tc.status)
+					return
+				}
+				if !tc.unknownSize {
+					w.Header().Set("Content-Length", strconv.Itoa(len(artifact)))
+				}
+				w.Write(artifact)
+			}))
+			defer srv.Close()
+
+			fakeIS := new(FakeImageStorage)
+			fakeIS.isArtifactUnique = true
+			fakeFS := &FakeFileStorage{imageExists: tc.contentExists}
+			iModel := NewImagesModel(fakeFS, nil, fakeIS)
+			// the test server listens on loopback
+			iModel.fetchClient = srv.Client()
+
+			constructor := &images.FetchConstructor{
+				URI:      srv.URL + "/app.mender",
+				Checksum: tc.checksum,
+			}
+			fetch := images.NewFetch(validUUIDv4, constructor)
+
+			iModel.fetchImage(context.Background(), fetch, constructor)
+
+			assert.NotEmpty(t, fakeIS.updatedFetches)
+			last := fakeIS.updatedFetches[len(fakeIS.updatedFetches)-1]
+			assert.Equal(t, tc.outputStatus, last.Status)
+			assert.Equal(t, tc.outputError, last.Error)
+			if tc.outputStatus == images.FetchStatusDone {
+				assert.NotEmpty(t, last.ArtifactID)
+				assert.Equal(t, int64(len(artifact)), last.Size)
+				assert.Equal(t, int64(len(artifact)), last.Downloaded)
+			} else {
+				assert.Empty(t, last.ArtifactID)
+				// metadata is not stored, content addressed file
+				// of other images is kept
+				assert.Nil(t, fakeIS.inserted)
+				assert.Empty(t, fakeFS.moved)
+				for _, key := range fakeFS.deleted {
+					assert.NotEqual(t, contentObjectID(checksum), key)
+				}
+			}
+		})
+	}
+}
+
+// fakeJobQueue keeps enqueued jobs to be run by the test
+type fakeJobQueue struct {
+	handlers map[string]func(ctx context.Context, payload json.RawMessage) error
+	enqueued []json.RawMessage
+	err      error
+}
+
+func (q *fakeJobQueue) Register(jobType string,
+	handler func(ctx context.Context, payload json.RawMessage) error) {
+	if q.handlers == nil {
+		q.handlers = map[string]func(ctx context.Context, payload json.RawMessage) error{}
+	}
+	q.handlers[jobType] = handler
+}
+
+func (q *fakeJobQueue) Enqueue(ctx context.Context, jobType string, payload interface{}) error {
+	if q.err != nil {
+		return q.err
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	q.enqueued = append(q.enqueued, data)
+	return nil
+}
+
+func TestFetchImageQueued(t *testing.T) {
+	upd, err := MakeRootfsImageArtifact(2, false)
+	assert.NoError(t, err)
+	artifact := upd.Bytes()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", strconv.Itoa(len(artifact)))
+		w.Write(artifact)
+	}))
+	defer srv.Close()
+
+	constructor := &images.FetchConstructor{
+		URI: srv.URL + "/app.mender",
+	}
+
+	fakeIS := new(FakeImageStorage)
+	fakeIS.isArtifactUnique = true
+	iModel := NewImagesModel(new(FakeFileStorage), nil, fakeIS)
+	iModel.fetchClient = srv.Client()
+
+	_, err = iModel.FetchImage(context.Background(), constructor)
+	assert.Equal(t, ErrModelJobsNotConfigured, err)
+
+	queue := &fakeJobQueue{err: errors.New("db error")}
+	iModel.SetJobQueue(queue)
+
+	_, err = iModel.FetchImage(context.Background(), constructor)
+	assert.EqualError(t, err, "Queueing artifact fetch: db error")
+
+	queue.err = nil
+	id, err := iModel.FetchImage(context.Background(), constructor)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, id)
+	assert.Len(t, queue.enqueued, 1)
+	assert.Empty(t, fakeIS.updatedFetches)
+
+	// job fails, and is retried, while the fetch cannot be found
+	handler := queue.handlers[JobTypeFetch]
+	assert.EqualError(t, handler(context.Background(), queue.enqueued[0]),
+		"artifact fetch "+id+" not found")
+
+	fakeIS.findFetch = images.NewFetch(id, constructor)
+	assert.NoError(t, handler(context.Background(), queue.enqueued[0]))
+
+	assert.NotEmpty(t, fakeIS.updatedFetches)
+	last := fakeIS.updatedFetches[len(fakeIS.updatedFetches)-1]
+	assert.Equal(t, images.FetchStatusDone, last.Status)
+	assert.NotEmpty(t, last.ArtifactID)
+}
+
+func TestGetFetch(t *testing.T) {
+	fetch := images.NewFetch(validUUIDv4, &images.FetchConstructor{
+		URI: "https://ci.example.com/app.mender",
+	})
+
+	fakeIS := new(FakeImageStorage)
+	fakeIS.findFetch = fetch
+	iModel := NewImagesModel(nil, nil, fakeIS)
+
+	out, err := iModel.GetFetch(context.Background(), validUUIDv4)
+	assert.NoError(t, err)
+	assert.Equal(t, fetch, out)
+
+	fakeIS.findFetch = nil
+	fakeIS.findFetchError = errors.New("db error")
+	out, err = iModel.GetFetch(context.Background(), validUUIDv4)
+	assert.EqualError(t, err, "Searching for artifact fetch with specified ID: db error")
+	assert.Nil(t, out)
+}
+
+func TestFetchClient(t *testing.T) {
+	https := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("artifact"))
+	}))
+	defer https.Close()
+
+	// internal addresses are not reachable
+	client := newFetchClient()
+	_, err := client.Get(https.URL)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), ErrFetchAddressNotAllowed.Error())
+	}
+
+	for address, allowed := range map[string]bool{
+		"93.184.216.34:443":       true,
+		"[2606:2800:220:1::]:443": true,
+		"127.0.0.1:443":           false,
+		"10.1.2.3:443":            false,
+		"172.16.0.1:443":          false,
+		"192.168.1.1:443":         false,
+		"169.254.169.254:80":      false,
+		"0.0.0.0:443":             false,
+		"[::1]:443":               false,
+		"[fd00::1]:443":           false,
+		"[fe80::1]:443":           false,
+		"224.0.0.1:443":           false,
+	} {
+		err := checkFetchAddress(address)
+		if allowed {
+			assert.NoError(t, err, address)
+		} else {
+			assert.Equal(t, ErrFetchAddressNotAllowed, errors.Cause(err), address)
+		}
+	}
+
+	// redirects are followed only to https, a limited number of times
+	redirect := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/http":
+			http.Redirect(w, r, "http://"+r.Host+"/app.mender", http.StatusFound)
+		case "/https":
+			http.Redirect(w, r, https.URL+"/app.mender", http.StatusFound)
+		default:
+			http.Redirect(w, r, r.URL.Path+"x", http.StatusFound)
+		}
+	}))
+	defer redirect.Close()
+
+	// checked without the address restriction, the test servers listen on
+	// loopback
+	client = https.Client()
+	client.CheckRedirect = checkFetchRedirect
+
+	_, err = client.Get(redirect.URL + "/http")
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), ErrFetchRedirectNotHTTPS.Error())
+	}
+	_, err = client.Get(redirect.URL + "/loop")
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), ErrFetchTooManyRedirects.Error())
+	}
+	rsp, err := client.Get(redirect.URL + "/https")
+	if assert.NoError(t, err) {
+		rsp.Body.Close()
+		assert.Equal(t, http.StatusOK, rsp.StatusCode)
+	}
+}
@@ -39,4 +39,7 @@ type FileStorage interface {
 		duration time.Duration, responseContentType string) (*images.Link, error)
 	UploadArtifact(ctx context.Context, objectId string,
 		artifactSize int64, artifact io.Reader, contentType string) error
+	Move(ctx context.Context, sourceId string, objectId string) error
+	Archive(ctx context.Context, objectId string, storageClass string) error
+	Restore(ctx context.Context, objectId string, days int64) (bool, error)
 }
@@ -16,10 +16,14 @@ package model
 
 import (
 	"context"
+	"time"
 )
 
 // Allows to check of image is used in different deployment status groups
 type ImageUsedIn interface {
 	ImageUsedInActiveDeployment(ctx context.Context, imageId string) (bool, error)
 	ImageUsedInDeployment(ctx context.Context, imageId string) (bool, error)
+	ImageDeployedSince(ctx context.Context, imageId string, since time.Time) (bool, error)
+	// RecordArtifactDownload audits download link issued to the user
+	RecordArtifactDownload(ctx context.Context, imageId string) error
 }
@@ -16,10 +16,15 @@ package model
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"io"
 	"io/ioutil"
+	"net/http"
+	"os"
 	"time"
 
+	"github.com/mendersoftware/go-lib-micro/log"
 	"github.com/mendersoftware/mender-artifact/areader"
 	"github.com/mendersoftware/mender-artifact/artifact"
 	"github.com/mendersoftware/mender-artifact/handlers"
@@ -34,12 +39,28 @@ const (
 	ArtifactContentType = "application/vnd.mender-artifact"
 )
 
+// ImagesModel implements controller.ImagesModel.
 type ImagesModel struct {
 	fileStorage   FileStorage
 	deployments   ImageUsedIn
 	imagesStorage SoftwareImagesStorage
+	fetchClient   *http.Client
+	parsers       *parserPool
+	jobs          JobQueue
+	// time deleted images are kept in the trash, deleted at once if 0
+	trashRetention time.Duration
+	egress         EgressRecorder
+	// versions parsed from artifact names, for finding the latest one
+	versions *images.VersionParser
 }
 
+// EgressRecorder accounts artifact bytes served to the tenant.
+type EgressRecorder interface {
+	RecordEgress(ctx context.Context, bytes int64)
+}
+
+var _ controller.ImagesModel = (*ImagesModel)(nil)
+
 func NewImagesModel(
 	fileStorage FileStorage,
 	checker ImageUsedIn,
@@ -49,9 +70,48 @@ func NewImagesModel(
 		fileStorage:   fileStorage,
 		deployments:   checker,
 		imagesStorage: imagesStorage,
+		fetchClient:   newFetchClient(),
+		parsers: newParserPool(ParserLimits{
+			QueueSize: DefaultParserQueueSize,
+		}),
+		versions: images.DefaultVersionParser(),
 	}
 }
 
+// SetParserLimits replaces limits applied to parsing of uploaded artifacts.
+func (i *ImagesModel) SetParserLimits(limits ParserLimits) {
+	i.parsers = newParserPool(limits)
+}
+
+// SetTrashRetention sets time deleted images are kept in the trash,
+// recoverable, before they can be purged. Images are deleted at once if 0.
+func (i *ImagesModel) SetTrashRetention(retention time.Duration) {
+	i.trashRetention = retention
+}
+
+// SetJobQueue sets queue running background work of the model,
+// e.g. artifact fetches, and registers its handlers.
+func (i *ImagesModel) SetJobQueue(jobs JobQueue) {
+	jobs.Register(JobTypeFetch, i.handleFetchJob)
+	i.jobs = jobs
+}
+
+// SetEgressRecorder sets recorder accounting size of images download
+// links are issued for.
+func (i *ImagesModel) SetEgressRecorder(egress EgressRecorder) {
+	i.egress = egress
+}
+
+// SetVersionParser replaces parser of versions of artifact names.
+func (i *ImagesModel) SetVersionParser(versions *images.VersionParser) {
+	i.versions = versions
+}
+
+// ParserStats returns current usage of the artifact parsers.
+func (i *ImagesModel) ParserStats(ctx context.Context) (*images.ParserStats, error) {
+	return i.parsers.stats(), nil
+}
+
 // CreateImage parses artifact and uploads artifact file to the file storage - in parallel,
 // and creates image structure in the system.
 // Returns image ID and nil on success.
@@ -72,7 +132,25 @@ func (i *ImagesModel) CreateImage(ctx context.Context,
 		return "", controller.ErrModelArtifactFileTooLarge
 	}
 
-	artifactID, err := i.handleArtifact(ctx, multipartUploadMsg)
+	// parsing and checksumming is CPU heavy, bound the number of uploads
+	// processed at the same time
+	release, err := i.parsers.acquire(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer release()
+
+	parseCtx, reader, cancel := i.parsers.limit(ctx, multipartUploadMsg.ArtifactReader)
+	defer cancel()
+
+	limitedMsg := *multipartUploadMsg
+	limitedMsg.ArtifactReader = reader
+
+	artifactID, err := i.handleArtifact(parseCtx, &limitedMsg)
+	if err != nil && parseCtx.Err() == context.DeadlineExceeded {
+		i.parsers.timeout()
+		err = errors.Wrap(controller.ErrModelParsingTimeout, err.Error())
+	}
 	// try to remove artifact file from file storage on error
 	if err != nil {
 		if cleanupErr := i.fileStorage.Delete(ctx,
@@ -94,7 +172,9 @@ func (i *ImagesModel) handleArtifact(ctx context.Context,
 
 	// limit reader to the size provided with the upload message
 	lr := io.LimitReader(multipartUploadMsg.ArtifactReader, multipartUploadMsg.ArtifactSize)
-	tee := io.TeeReader(lr, pW)
+	// artifact is hashed, parsed and uploaded while it is being received
+	checksum := sha256.New()
+	tee := io.TeeReader(lr, io.MultiWriter(pW, checksum))
 
 	artifactID := uuid.NewV4().String()
 
@@ -145,6 +225,11 @@ func (i *ImagesModel) handleArtifact(ctx context.Context,
 		return "", controller.ErrModelInvalidMetadata
 	}
 
+	// the uploaded file is removed on violation of the naming policy
+	if err := i.checkNamingPolicy(ctx, metaArtifactConstructor.Name); err != nil {
+		return artifactID, err
+	}
+
 	// check if artifact is unique
 	// artifact is considered to be unique if there is no artifact with the same name
 	// and supporing the same platform in the system
@@ -159,6 +244,21 @@ func (i *ImagesModel) handleArtifact(ctx context.Context,
 
 	image := images.NewSoftwareImage(
 		artifactID, multipartUploadMsg.MetaConstructor, metaArtifactConstructor)
+	image.Checksum = hex.EncodeToString(checksum.Sum(nil))
+	image.Size = multipartUploadMsg.ArtifactSize
+
+	// the uploaded file is removed on mismatch, before it may replace or
+	// be shared with files of other images
+	if err := verifyChecksum(image.Checksum, multipartUploadMsg.Checksum); err != nil {
+		return artifactID, err
+	}
+
+	// key the artifact file by its content; file left by previous upload
+	// which failed to save the metadata is reused
+	image.ObjectID = contentObjectID(image.Checksum)
+	if err := i.storeContent(ctx, artifactID, image.ObjectID); err != nil {
+		return "", err
+	}
 
 	// save image structure in the system
 	if err = i.imagesStorage.Insert(ctx, image); err != nil {
@@ -168,6 +268,32 @@ func (i *ImagesModel) handleArtifact(ctx context.Context,
 	return artifactID, nil
 }
 
+// contentObjectID returns file storage key of the artifact with given checksum.
+func contentObjectID(checksum string) string {
+	return "sha256-" + checksum
+}
+
+// storeContent moves the uploaded file to the content addressed key,
+// removing it instead if the same content is stored already.
+func (i *ImagesModel) storeContent(ctx context.Context, fileID, objectID string) error {
+	exists, err := i.fileStorage.Exists(ctx, objectID)
+	if err != nil {
+		return errors.Wrap(err, "Searching for artifact file")
+	}
+
+	if exists {
+		if err := i.fileStorage.Delete(ctx, fileID); err != nil {
+			return errors.Wrap(err, "Removing duplicate artifact file")
+		}
+		return nil
+	}
+
+	if err := i.fileStorage.Move(ctx, fileID, objectID); err != nil {
+		return errors.Wrap(err, "Moving artifact file")
+	}
+	return nil
+}
+
 // GetImage allows to fetch image obeject with specified id
 // Nil if not found
 func (i *ImagesModel) GetImage(ctx context.Context, id string) (*images.SoftwareImage, error) {
@@ -184,7 +310,33 @@ func (i *ImagesModel) GetImage(ctx context.Context, id string) (*images.Software
 	return image, nil
 }
 
-// DeleteImage removes metadata and image file
+// GetLatestImage returns the image of the highest version among those with
+// names starting with the prefix, compatible with the device type unless
+// empty. Returns nil if none has a version in its name.
+func (i *ImagesModel) GetLatestImage(ctx context.Context,
+	namePrefix, deviceType string) (*images.SoftwareImage, error) {
+
+	candidates, err := i.imagesStorage.ImagesByNamePrefix(ctx, namePrefix, deviceType)
+	if err != nil {
+		return nil, errors.Wrap(err, "Searching for images with specified name prefix")
+	}
+
+	return i.versions.Latest(candidates), nil
+}
+
+// GetCompatibility returns matrix of artifact names and device types they
+// are available for.
+func (i *ImagesModel) GetCompatibility(ctx context.Context) (*images.CompatibilityMatrix, error) {
+	artifacts, err := i.imagesStorage.FindArtifactDeviceTypes(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "Searching for artifact device types")
+	}
+
+	return images.NewCompatibilityMatrix(artifacts), nil
+}
+
+// DeleteImage removes metadata and image file, or moves the image to the trash
+// if trash retention is set
 // Noop for not exisitng images
 // Allowed to remove image only if image is not scheduled or in progress for an updates - then image file is needed
 // In case of already finished updates only image file is not needed, metadata is attached directly to device deployment
@@ -200,6 +352,10 @@ func (i *ImagesModel) DeleteImage(ctx context.Context, imageID string) error {
 		return controller.ErrImageMetaNotFound
 	}
 
+	if found.Locked != nil {
+		return controller.ErrModelImageLocked
+	}
+
 	inUse, err := i.deployments.ImageUsedInActiveDeployment(ctx, imageID)
 	if err != nil {
 		return errors.Wrap(err, "Checking if image is used in active deployment")
@@ -210,10 +366,18 @@ func (i *ImagesModel) DeleteImage(ctx context.Context, imageID string) error {
 		return controller.ErrModelImageInActiveDeployment
 	}
 
-	// Delete image file (call to external service)
-	// Noop for not existing file
-	if err := i.fileStorage.Delete(ctx, imageID); err != nil {
-		return errors.Wrap(err, "Deleting image file")
+	// Keep the image file and metadata, recoverable, until purged
+	if i.trashRetention > 0 {
+		now := time.Now()
+		found.Trashed = &now
+		if err := i.imagesStorage.Trash(ctx, found); err != nil {
+			return errors.Wrap(err, "Moving image to the trash")
+		}
+		return nil
+	}
+
+	if err := i.deleteFile(ctx, found); err != nil {
+		return err
 	}
 
 	// Delete metadata
@@ -224,11 +388,42 @@ func (i *ImagesModel) DeleteImage(ctx context.Context, imageID string) error {
 	return nil
 }
 
+// deleteFile deletes the image file unless it is shared with other images.
+func (i *ImagesModel) deleteFile(ctx context.Context, image *images.SoftwareImage) error {
+	// Artifact names are unique per device type, but the same artifact
+	// may have been uploaded again while the image was in the trash
+	shared, err := i.imagesStorage.IsFileShared(ctx, image)
+	if err != nil {
+		return errors.Wrap(err, "Checking if image file is shared")
+	}
+	if shared {
+		return nil
+	}
+
+	// Delete image file (call to external service)
+	// Noop for not existing file
+	if err := i.fileStorage.Delete(ctx, image.FileID()); err != nil {
+		return errors.Wrap(err, "Deleting image file")
+	}
+
+	return nil
+}
+
 // ListImages according to specified filers.
 func (i *ImagesModel) ListImages(ctx context.Context,
 	filters map[string]string) ([]*images.SoftwareImage, error) {
 
-	imageList, err := i.imagesStorage.FindAll(ctx)
+	providesFilter, err := images.ParseProvidesFilter(filters)
+	if err != nil {
+		return nil, err
+	}
+
+	var imageList []*images.SoftwareImage
+	if providesFilter != nil {
+		imageList, err = i.imagesStorage.FindByProvides(ctx, *providesFilter)
+	} else {
+		imageList, err = i.imagesStorage.FindAll(ctx)
+	}
 	if err != nil {
 		return nil, errors.Wrap(err, "Searching for image metadata")
 	}
@@ -266,6 +461,10 @@ func (i *ImagesModel) EditImage(ctx context.Context, imageID string,
 		return false, nil
 	}
 
+	if foundImage.Locked != nil {
+		return false, controller.ErrModelImageLocked
+	}
+
 	foundImage.SetModified(time.Now())
 	foundImage.SoftwareImageMetaConstructor = *constructor
 
@@ -282,16 +481,16 @@ func (i *ImagesModel) EditImage(ctx context.Context, imageID string,
 func (i *ImagesModel) DownloadLink(ctx context.Context, imageID string,
 	expire time.Duration) (*images.Link, error) {
 
-	found, err := i.imagesStorage.Exists(ctx, imageID)
+	image, err := i.imagesStorage.FindByID(ctx, imageID)
 	if err != nil {
 		return nil, errors.Wrap(err, "Searching for image with specified ID")
 	}
 
-	if !found {
+	if image == nil {
 		return nil, nil
 	}
 
-	found, err = i.fileStorage.Exists(ctx, imageID)
+	found, err := i.fileStorage.Exists(ctx, image.FileID())
 	if err != nil {
 		return nil, errors.Wrap(err, "Searching for image file")
 	}
@@ -300,12 +499,20 @@ func (i *ImagesModel) DownloadLink(ctx context.Context, imageID string,
 		return nil, nil
 	}
 
-	link, err := i.fileStorage.GetRequest(ctx, imageID,
+	link, err := i.fileStorage.GetRequest(ctx, image.FileID(),
 		expire, ArtifactContentType)
 	if err != nil {
 		return nil, errors.Wrap(err, "Generating download link")
 	}
 
+	if err := i.deployments.RecordArtifactDownload(ctx, imageID); err != nil {
+		log.FromContext(ctx).Warnf("failed to record download of artifact %s: %v",
+			imageID, err)
+	}
+	if i.egress != nil {
+		i.egress.RecordEgress(ctx, image.Size)
+	}
+
 	return link, nil
 }
 
@@ -341,6 +548,22 @@ func getMetaFromArchive(r *io.Reader) (*images.SoftwareImageMetaArtifactConstruc
 		return nil
 	}
 
+	aReader.ScriptsReadCallback = func(r io.Reader, info os.FileInfo) error {
+		h := sha256.New()
+		size, err := io.Copy(h, r)
+		if err != nil {
+			return errors.Wrapf(err, "reading state script %s", info.Name())
+		}
+
+		metaArtifact.Scripts = append(metaArtifact.Scripts, info.Name())
+		metaArtifact.StateScripts = append(metaArtifact.StateScripts, images.StateScript{
+			Name:     info.Name(),
+			Checksum: hex.EncodeToString(h.Sum(nil)),
+			Size:     size,
+		})
+		return nil
+	}
+
 	err := aReader.ReadArtifact()
 	if err != nil {
 		return nil, errors.Wrap(err, "reading artifact error")
@@ -349,6 +572,11 @@ func getMetaFromArchive(r *io.Reader) (*images.SoftwareImageMetaArtifactConstruc
 	metaArtifact.Info = getArtifactInfo(aReader.GetInfo())
 	metaArtifact.DeviceTypesCompatible = aReader.GetCompatibleDevices()
 	metaArtifact.Name = aReader.GetArtifactName()
+	// Supported artifact formats carry no provides section besides the
+	// artifact name, nor any provides to clear.
+	metaArtifact.Provides = []images.ArtifactProvide{
+		{Key: "artifact_name", Value: metaArtifact.Name},
+	}
 
 	for _, p := range aReader.GetHandlers() {
 		uFiles, err := getUpdateFiles(p.GetUpdateFiles())
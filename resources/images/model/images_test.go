@@ -17,10 +17,13 @@ package model
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"io"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"reflect"
 	"testing"
 	"time"
@@ -79,6 +82,38 @@ type FakeImageStorage struct {
 	uploadArtifactError   error
 	isArtifactUnique      bool
 	isArtifactUniqueError error
+	insertFetchError      error
+	updateFetchError      error
+	findFetch             *images.Fetch
+	findFetchError        error
+	// snapshots of the fetch saved with each UpdateFetch call
+	updatedFetches []images.Fetch
+	// image saved with the last Insert call
+	inserted *images.SoftwareImage
+	// images in the trash
+	trashed            []*images.SoftwareImage
+	trashError         error
+	findTrashedError   error
+	deleteTrashedError error
+	fileShared         bool
+	fileSharedError    error
+	// lock saved with the last UpdateLock call
+	lock            *images.Lock
+	lockFound       bool
+	updateLockError error
+	// device types of artifact names
+	artifactDeviceTypes      []images.ArtifactDeviceTypes
+	artifactDeviceTypesError error
+	// filter of the last FindByProvides call
+	providesFilter *images.ProvidesFilter
+	// images found by name prefix, and arguments of the last call
+	namePrefixImages []*images.SoftwareImage
+	namePrefixError  error
+	namePrefixArgs   []string
+	// policy saved with the last SetNamingPolicy call
+	namingPolicy         *images.NamingPolicy
+	namingPolicyError    error
+	setNamingPolicyError error
 }
 
 func (fis *FakeImageStorage) Exists(ctx context.Context, id string) (bool, error) {
@@ -92,6 +127,7 @@ func (fis *FakeImageStorage) Update(ctx context.Context,
 
 func (fis *FakeImageStorage) Insert(ctx context.Context,
 	image *images.SoftwareImage) error {
+	fis.inserted = image
 	return fis.insertError
 }
 
@@ -108,11 +144,146 @@ func (fis *FakeImageStorage) FindAll(ctx context.Context) ([]*images.SoftwareIma
 	return fis.findAllImages, fis.findAllError
 }
 
+func (fis *FakeImageStorage) FindByProvides(ctx context.Context,
+	filter images.ProvidesFilter) ([]*images.SoftwareImage, error) {
+	fis.providesFilter = &filter
+	return fis.findAllImages, fis.findAllError
+}
+
 func (fis *FakeImageStorage) IsArtifactUnique(ctx context.Context,
 	artifactName string, deviceTypesCompatible []string) (bool, error) {
 	return fis.isArtifactUnique, fis.isArtifactUniqueError
 }
 
+func (fis *FakeImageStorage) InsertFetch(ctx context.Context, fetch *images.Fetch) error {
+	return fis.insertFetchError
+}
+
+func (fis *FakeImageStorage) UpdateFetch(ctx context.Context, fetch *images.Fetch) error {
+	fis.updatedFetches = append(fis.updatedFetches, *fetch)
+	return fis.updateFetchError
+}
+
+func (fis *FakeImageStorage) FindFetchByID(ctx context.Context, id string) (*images.Fetch, error) {
+	return fis.findFetch, fis.findFetchError
+}
+
+func (fis *FakeImageStorage) Trash(ctx context.Context, image *images.SoftwareImage) error {
+	if fis.trashError == nil {
+		fis.trashed = append(fis.trashed, image)
+	}
+	return fis.trashError
+}
+
+func (fis *FakeImageStorage) FindTrashed(ctx context.Context) ([]*images.SoftwareImage, error) {
+	return fis.trashed, fis.findTrashedError
+}
+
+func (fis *FakeImageStorage) FindTrashedByID(ctx context.Context,
+	id string) (*images.SoftwareImage, error) {
+	for _, image := range fis.trashed {
+		if image.Id == id {
+			return image, fis.findTrashedError
+		}
+	}
+	return nil, fis.findTrashedError
+}
+
+func (fis *FakeImageStorage) DeleteTrashed(ctx context.Context, id string) error {
+	if fis.deleteTrashedError != nil {
+		return fis.deleteTrashedError
+	}
+	for n, image := range fis.trashed {
+		if image.Id == id {
+			fis.trashed = append(fis.trashed[:n], fis.trashed[n+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+func (fis *FakeImageStorage) IsFileShared(ctx context.Context,
+	image *images.SoftwareImage) (bool, error) {
+	return fis.fileShared, fis.fileSharedError
+}
+
+func (fis *FakeImageStorage) UpdateLock(ctx context.Context,
+	id string, lock *images.Lock) (bool, error) {
+	fis.lock = lock
+	return fis.lockFound, fis.updateLockError
+}
+
+func (fis *FakeImageStorage) FindArtifactDeviceTypes(ctx context.Context) (
+	[]images.ArtifactDeviceTypes, error) {
+	return fis.artifactDeviceTypes, fis.artifactDeviceTypesError
+}
+
+func (fis *FakeImageStorage) ImagesByNamePrefix(ctx context.Context,
+	prefix, deviceType string) ([]*images.SoftwareImage, error) {
+	fis.namePrefixArgs = []string{prefix, deviceType}
+	return fis.namePrefixImages, fis.namePrefixError
+}
+
+func (fis *FakeImageStorage) GetNamingPolicy(ctx context.Context) (
+	*images.NamingPolicy, error) {
+	return fis.namingPolicy, fis.namingPolicyError
+}
+
+func (fis *FakeImageStorage) SetNamingPolicy(ctx context.Context,
+	policy *images.NamingPolicy) error {
+	fis.namingPolicy = policy
+	return fis.setNamingPolicyError
+}
+
+func TestGetCompatibility(t *testing.T) {
+	fakeIS := new(FakeImageStorage)
+	fakeIS.artifactDeviceTypes = []images.ArtifactDeviceTypes{
+		{Name: "release-1", DeviceTypes: []string{"beaglebone", "raspberrypi3"}},
+	}
+	iModel := NewImagesModel(nil, nil, fakeIS)
+
+	matrix, err := iModel.GetCompatibility(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"beaglebone", "raspberrypi3"}, matrix.DeviceTypes)
+	assert.Len(t, matrix.Artifacts, 1)
+
+	fakeIS.artifactDeviceTypesError = errors.New("db error")
+	_, err = iModel.GetCompatibility(context.Background())
+	assert.EqualError(t, err, "Searching for artifact device types: db error")
+}
+
+func TestGetLatestImage(t *testing.T) {
+	newImage := func(id, name string) *images.SoftwareImage {
+		return images.NewSoftwareImage(id, createValidImageMeta(),
+			&images.SoftwareImageMetaArtifactConstructor{Name: name})
+	}
+
+	fakeIS := new(FakeImageStorage)
+	fakeIS.namePrefixImages = []*images.SoftwareImage{
+		newImage("1", "app-1.10.0"),
+		newImage("2", "app-1.9.0"),
+		newImage("3", "app-nightly"),
+	}
+	iModel := NewImagesModel(nil, nil, fakeIS)
+
+	image, err := iModel.GetLatestImage(context.Background(), "app-", "foo")
+	assert.NoError(t, err)
+	assert.Equal(t, "1", image.Id)
+	assert.Equal(t, []string{"app-", "foo"}, fakeIS.namePrefixArgs)
+
+	// versions parsed with custom pattern
+	parser, err := images.NewVersionParser(`^app-(?P<version>[a-z]+)$`)
+	assert.NoError(t, err)
+	iModel.SetVersionParser(parser)
+	image, err = iModel.GetLatestImage(context.Background(), "app-", "foo")
+	assert.NoError(t, err)
+	assert.Nil(t, image)
+
+	fakeIS.namePrefixError = errors.New("db error")
+	_, err = iModel.GetLatestImage(context.Background(), "app-", "")
+	assert.EqualError(t, err, "Searching for images with specified name prefix: db error")
+}
+
 func createValidImageMeta() *images.SoftwareImageMetaConstructor {
 	return images.NewSoftwareImageMetaConstructor()
 }
@@ -182,6 +353,7 @@ func TestCreateImageCreateOK(t *testing.T) {
 	defer os.RemoveAll(td)
 	upd, err := MakeRootfsImageArtifact(1, false)
 	assert.NoError(t, err)
+	checksum := sha256.Sum256(upd.Bytes())
 
 	multipartUploadMessage := &controller.MultipartUploadMsg{
 		MetaConstructor: createValidImageMeta(),
@@ -194,6 +366,86 @@ func TestCreateImageCreateOK(t *testing.T) {
 
 		t.FailNow()
 	}
+	assert.Equal(t, hex.EncodeToString(checksum[:]), fakeIS.inserted.Checksum)
+	assert.Equal(t, "sha256-"+hex.EncodeToString(checksum[:]), fakeIS.inserted.ObjectID)
+	assert.Equal(t, []string{fakeIS.inserted.ObjectID}, fakeFS.moved)
+	assert.Equal(t, []images.ArtifactProvide{
+		{Key: "artifact_name", Value: fakeIS.inserted.Name},
+	}, fakeIS.inserted.Provides)
+}
+
+func TestCreateImageContentStored(t *testing.T) {
+	fakeIS := new(FakeImageStorage)
+	fakeIS.isArtifactUnique = true
+	fakeFS := new(FakeFileStorage)
+	// file left by previous upload which failed to save the metadata
+	fakeFS.imageExists = true
+
+	iModel := NewImagesModel(fakeFS, nil, fakeIS)
+
+	upd, err := MakeRootfsImageArtifact(1, false)
+	assert.NoError(t, err)
+	checksum := sha256.Sum256(upd.Bytes())
+
+	id, err := iModel.CreateImage(context.Background(), &controller.MultipartUploadMsg{
+		MetaConstructor: createValidImageMeta(),
+		ArtifactSize:    int64(upd.Len()),
+		ArtifactReader:  upd,
+	})
+	assert.NoError(t, err)
+
+	// uploaded file is dropped, existing one is used
+	assert.Empty(t, fakeFS.moved)
+	assert.Equal(t, []string{id}, fakeFS.deleted)
+	assert.Equal(t, "sha256-"+hex.EncodeToString(checksum[:]), fakeIS.inserted.ObjectID)
+
+	// moving the file failed
+	fakeFS.imageExists = false
+	fakeFS.moveError = errors.New("move failed")
+	upd, err = MakeRootfsImageArtifact(1, false)
+	assert.NoError(t, err)
+	_, err = iModel.CreateImage(context.Background(), &controller.MultipartUploadMsg{
+		MetaConstructor: createValidImageMeta(),
+		ArtifactSize:    int64(upd.Len()),
+		ArtifactReader:  upd,
+	})
+	assert.EqualError(t, err, "Moving artifact file: move failed")
+}
+
+func TestCreateImageStateScripts(t *testing.T) {
+	fakeIS := new(FakeImageStorage)
+	fakeIS.isArtifactUnique = true
+	fakeFS := new(FakeFileStorage)
+
+	iModel := NewImagesModel(fakeFS, nil, fakeIS)
+
+	td, err := ioutil.TempDir("", "mender-state-scripts-")
+	assert.NoError(t, err)
+	defer os.RemoveAll(td)
+
+	content := []byte("#!/bin/sh\necho install\n")
+	script := filepath.Join(td, "ArtifactInstall_Enter_00")
+	assert.NoError(t, ioutil.WriteFile(script, content, 0755))
+	scripts := new(artifact.Scripts)
+	assert.NoError(t, scripts.Add(script))
+
+	upd, err := MakeRootfsImageArtifactWithScripts(2, false, scripts)
+	assert.NoError(t, err)
+
+	_, err = iModel.CreateImage(context.Background(), &controller.MultipartUploadMsg{
+		MetaConstructor: createValidImageMeta(),
+		ArtifactSize:    int64(upd.Len()),
+		ArtifactReader:  upd,
+	})
+	assert.NoError(t, err)
+
+	checksum := sha256.Sum256(content)
+	assert.Equal(t, []string{"ArtifactInstall_Enter_00"}, fakeIS.inserted.Scripts)
+	assert.Equal(t, []images.StateScript{{
+		Name:     "ArtifactInstall_Enter_00",
+		Checksum: hex.EncodeToString(checksum[:]),
+		Size:     int64(len(content)),
+	}}, fakeIS.inserted.StateScripts)
 }
 
 func TestCreateSignedImageCreateOK(t *testing.T) {
@@ -255,12 +507,30 @@ type FakeFileStorage struct {
 	getReq              *images.Link
 	getError            error
 	uploadArtifactError error
+	archiveError        error
+	archived            []string
+	restored            bool
+	restoreError        error
+	moveError           error
+	// keys of deleted and moved files
+	deleted []string
+	moved   []string
 }
 
 func (ffs *FakeFileStorage) Delete(ctx context.Context, objectId string) error {
+	if ffs.deleteError == nil {
+		ffs.deleted = append(ffs.deleted, objectId)
+	}
 	return ffs.deleteError
 }
 
+func (ffs *FakeFileStorage) Move(ctx context.Context, sourceId string, objectId string) error {
+	if ffs.moveError == nil {
+		ffs.moved = append(ffs.moved, objectId)
+	}
+	return ffs.moveError
+}
+
 func (ffs *FakeFileStorage) Exists(ctx context.Context, objectId string) (bool, error) {
 	return ffs.imageExists, ffs.imageEsistsError
 }
@@ -288,6 +558,19 @@ func (fis *FakeFileStorage) UploadArtifact(ctx context.Context, id string,
 	return fis.uploadArtifactError
 }
 
+func (ffs *FakeFileStorage) Archive(ctx context.Context, objectId string,
+	storageClass string) error {
+	if ffs.archiveError == nil {
+		ffs.archived = append(ffs.archived, objectId)
+	}
+	return ffs.archiveError
+}
+
+func (ffs *FakeFileStorage) Restore(ctx context.Context, objectId string,
+	days int64) (bool, error) {
+	return ffs.restored, ffs.restoreError
+}
+
 func TestGetImageOK(t *testing.T) {
 	imageMeta := createValidImageMeta()
 	imageMetaArtifact := createValidImageMetaArtifact()
@@ -313,6 +596,10 @@ type FakeUseChecker struct {
 	isUsedInActiveDeployment   bool
 	usedInDeploymentsErr       error
 	isUsedInDeployment         bool
+	deployedSinceErr           error
+	isDeployedSince            bool
+	recordDownloadErr          error
+	recordedDownloads          []string
 }
 
 func (fus *FakeUseChecker) ImageUsedInActiveDeployment(ctx context.Context,
@@ -325,6 +612,16 @@ func (fus *FakeUseChecker) ImageUsedInDeployment(ctx context.Context, imageId st
 	return fus.isUsedInDeployment, fus.usedInDeploymentsErr
 }
 
+func (fus *FakeUseChecker) ImageDeployedSince(ctx context.Context, imageId string,
+	since time.Time) (bool, error) {
+	return fus.isDeployedSince, fus.deployedSinceErr
+}
+
+func (fus *FakeUseChecker) RecordArtifactDownload(ctx context.Context, imageId string) error {
+	fus.recordedDownloads = append(fus.recordedDownloads, imageId)
+	return fus.recordDownloadErr
+}
+
 func TestDeleteImage(t *testing.T) {
 	imageMeta := createValidImageMeta()
 	imageMetaArtifact := createValidImageMetaArtifact()
@@ -411,6 +708,23 @@ func TestListImages(t *testing.T) {
 	if _, err := iModel.ListImages(context.Background(), nil); err != nil {
 		t.FailNow()
 	}
+	assert.Nil(t, fakeIS.providesFilter)
+
+	//filtering by provides
+	list, err := iModel.ListImages(context.Background(), map[string]string{
+		images.FilterProvides: "artifact_name:required",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, listedImages, list)
+	assert.Equal(t, &images.ProvidesFilter{
+		Provide: images.ArtifactProvide{Key: "artifact_name", Value: "required"},
+	}, fakeIS.providesFilter)
+
+	//malformed provides filter
+	_, err = iModel.ListImages(context.Background(), map[string]string{
+		images.FilterProvides: "required",
+	})
+	assert.Equal(t, images.ErrInvalidProvidesFilter, err)
 }
 
 func TestEditImage(t *testing.T) {
@@ -469,37 +783,49 @@ func TestEditImage(t *testing.T) {
 	}
 }
 
+type FakeEgressRecorder struct {
+	bytes []int64
+}
+
+func (f *FakeEgressRecorder) RecordEgress(ctx context.Context, bytes int64) {
+	f.bytes = append(f.bytes, bytes)
+}
+
 func TestDownloadLink(t *testing.T) {
 	fakeChecker := new(FakeUseChecker)
 	fakeIS := new(FakeImageStorage)
 	fakeFS := new(FakeFileStorage)
+	fakeEgress := new(FakeEgressRecorder)
 	iModel := NewImagesModel(fakeFS, fakeChecker, fakeIS)
-
-	// image exists error
-	fakeIS.imageEsistsError = errors.New("error")
-	if _, err := iModel.DownloadLink(context.Background(),
-		"iamge", time.Hour); err == nil {
-		t.FailNow()
-	}
+	iModel.SetEgressRecorder(fakeEgress)
 
 	// searching for image failed
-	fakeIS.imageEsistsError = errors.New("Serarching for image failed")
-	fakeIS.imageExists = false
+	fakeIS.findByIdError = errors.New("Serarching for image failed")
 	if link, err := iModel.DownloadLink(context.Background(),
 		"iamge", time.Hour); err == nil || link != nil {
 		t.FailNow()
 	}
 
 	// iamge does not esists
-	fakeIS.imageEsistsError = nil
-	fakeIS.imageExists = false
+	fakeIS.findByIdError = nil
+	fakeIS.findByIdImage = nil
 	if link, err := iModel.DownloadLink(context.Background(),
 		"iamge", time.Hour); err != nil || link != nil {
 		t.FailNow()
 	}
 
+	// image file does not exist
+	fakeIS.findByIdImage = images.NewSoftwareImage("image",
+		images.NewSoftwareImageMetaConstructor(),
+		images.NewSoftwareImageMetaArtifactConstructor())
+	fakeIS.findByIdImage.Size = 1024
+	fakeFS.imageExists = false
+	if link, err := iModel.DownloadLink(context.Background(),
+		"image", time.Hour); err != nil || link != nil {
+		t.FailNow()
+	}
+
 	// can not generate link
-	fakeIS.imageExists = true
 	fakeFS.imageExists = true
 	fakeFS.getError = errors.New("error")
 	if _, err := iModel.DownloadLink(context.Background(),
@@ -517,6 +843,21 @@ func TestDownloadLink(t *testing.T) {
 	if err != nil || !reflect.DeepEqual(link, receivedLink) {
 		t.FailNow()
 	}
+	if !reflect.DeepEqual([]string{"image"}, fakeChecker.recordedDownloads) {
+		t.FailNow()
+	}
+	// only issued links are accounted as egress
+	if !reflect.DeepEqual([]int64{1024}, fakeEgress.bytes) {
+		t.FailNow()
+	}
+
+	// failing to record download does not prevent downloading
+	fakeChecker.recordDownloadErr = errors.New("error")
+	receivedLink, err = iModel.DownloadLink(context.Background(),
+		"image", time.Hour)
+	if err != nil || !reflect.DeepEqual(link, receivedLink) {
+		t.FailNow()
+	}
 }
 
 func MakeFakeUpdate(data string) (string, error) {
@@ -551,6 +892,12 @@ r3rtT0ysHWd7l+Kx/SUCQGlitd5RDfdHl+gKrCwhNnRG7FzRLv5YOQV81+kh7SkU
 `
 
 func MakeRootfsImageArtifact(version int, signed bool) (*bytes.Buffer, error) {
+	return MakeRootfsImageArtifactWithScripts(version, signed, nil)
+}
+
+func MakeRootfsImageArtifactWithScripts(version int, signed bool,
+	scripts *artifact.Scripts) (*bytes.Buffer, error) {
+
 	upd, err := MakeFakeUpdate("test update")
 	if err != nil {
 		return nil, err
@@ -575,7 +922,7 @@ func MakeRootfsImageArtifact(version int, signed bool) (*bytes.Buffer, error) {
 
 	updates := &awriter.Updates{U: []handlers.Composer{u}}
 	err = aw.WriteArtifact("mender", version, []string{"vexpress-qemu"},
-		"mender-1.1", updates, nil)
+		"mender-1.1", updates, scripts)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,73 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"context"
+	"time"
+
+	"github.com/mendersoftware/go-lib-micro/identity"
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/deployments/resources/images"
+	"github.com/mendersoftware/deployments/resources/images/controller"
+)
+
+// LockImage protects image used in a deployment from deletion and edits,
+// recording the user who locked it. Noop for locked images.
+func (i *ImagesModel) LockImage(ctx context.Context, imageID string) error {
+	image, err := i.imagesStorage.FindByID(ctx, imageID)
+	if err != nil {
+		return errors.Wrap(err, "Searching for image with specified ID")
+	}
+	if image == nil {
+		return controller.ErrImageMetaNotFound
+	}
+	if image.Locked != nil {
+		return nil
+	}
+
+	used, err := i.deployments.ImageUsedInDeployment(ctx, imageID)
+	if err != nil {
+		return errors.Wrap(err, "Searching for usage of the image among deployments")
+	}
+	if !used {
+		return controller.ErrModelImageNotDeployed
+	}
+
+	lock := &images.Lock{Time: time.Now()}
+	if id := identity.FromContext(ctx); id != nil {
+		lock.Subject = id.Subject
+	}
+
+	return i.updateLock(ctx, imageID, lock)
+}
+
+// UnlockImage removes lock of the image.
+func (i *ImagesModel) UnlockImage(ctx context.Context, imageID string) error {
+	return i.updateLock(ctx, imageID, nil)
+}
+
+func (i *ImagesModel) updateLock(ctx context.Context, imageID string, lock *images.Lock) error {
+	found, err := i.imagesStorage.UpdateLock(ctx, imageID, lock)
+	if err != nil {
+		return errors.Wrap(err, "Updating image lock")
+	}
+	if !found {
+		return controller.ErrImageMetaNotFound
+	}
+
+	return nil
+}
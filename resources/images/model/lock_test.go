@@ -0,0 +1,135 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mendersoftware/go-lib-micro/identity"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mendersoftware/deployments/resources/images"
+	"github.com/mendersoftware/deployments/resources/images/controller"
+)
+
+func TestLockImage(t *testing.T) {
+	locked := &images.Lock{Subject: "admin", Time: time.Now()}
+
+	testCases := map[string]struct {
+		image       *images.SoftwareImage
+		findError   error
+		useChecker  FakeUseChecker
+		lockFound   bool
+		lockError   error
+		outputLock  bool
+		outputError string
+	}{
+		"ok": {
+			image:      images.NewSoftwareImage(validUUIDv4, createValidImageMeta(), createValidImageMetaArtifact()),
+			useChecker: FakeUseChecker{isUsedInDeployment: true},
+			lockFound:  true,
+			outputLock: true,
+		},
+		"locked already": {
+			image: &images.SoftwareImage{Id: validUUIDv4, Locked: locked},
+		},
+		"not found": {
+			outputError: controller.ErrImageMetaNotFound.Error(),
+		},
+		"find error": {
+			findError:   errors.New("db error"),
+			outputError: "Searching for image with specified ID: db error",
+		},
+		"not deployed": {
+			image:       images.NewSoftwareImage(validUUIDv4, createValidImageMeta(), createValidImageMetaArtifact()),
+			outputError: controller.ErrModelImageNotDeployed.Error(),
+		},
+		"use check error": {
+			image:       images.NewSoftwareImage(validUUIDv4, createValidImageMeta(), createValidImageMetaArtifact()),
+			useChecker:  FakeUseChecker{usedInDeploymentsErr: errors.New("db error")},
+			outputError: "Searching for usage of the image among deployments: db error",
+		},
+		"update error": {
+			image:       images.NewSoftwareImage(validUUIDv4, createValidImageMeta(), createValidImageMetaArtifact()),
+			useChecker:  FakeUseChecker{isUsedInDeployment: true},
+			lockError:   errors.New("db error"),
+			outputLock:  true,
+			outputError: "Updating image lock: db error",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			fakeIS := new(FakeImageStorage)
+			fakeIS.findByIdImage = tc.image
+			fakeIS.findByIdError = tc.findError
+			fakeIS.lockFound = tc.lockFound
+			fakeIS.updateLockError = tc.lockError
+
+			useChecker := tc.useChecker
+			iModel := NewImagesModel(new(FakeFileStorage), &useChecker, fakeIS)
+
+			ctx := identity.WithContext(context.Background(),
+				&identity.Identity{Subject: "user"})
+			err := iModel.LockImage(ctx, validUUIDv4)
+			if tc.outputError != "" {
+				assert.EqualError(t, err, tc.outputError)
+			} else {
+				assert.NoError(t, err)
+			}
+			if tc.outputLock {
+				assert.NotNil(t, fakeIS.lock)
+				assert.Equal(t, "user", fakeIS.lock.Subject)
+			} else {
+				assert.Nil(t, fakeIS.lock)
+			}
+		})
+	}
+}
+
+func TestUnlockImage(t *testing.T) {
+	fakeIS := new(FakeImageStorage)
+	fakeIS.lock = &images.Lock{Subject: "user", Time: time.Now()}
+	iModel := NewImagesModel(new(FakeFileStorage), nil, fakeIS)
+
+	assert.EqualError(t, iModel.UnlockImage(context.Background(), validUUIDv4),
+		controller.ErrImageMetaNotFound.Error())
+
+	fakeIS.lockFound = true
+	assert.NoError(t, iModel.UnlockImage(context.Background(), validUUIDv4))
+	assert.Nil(t, fakeIS.lock)
+}
+
+func TestLockedImage(t *testing.T) {
+	image := images.NewSoftwareImage(validUUIDv4, createValidImageMeta(),
+		createValidImageMetaArtifact())
+	image.Locked = &images.Lock{Subject: "user", Time: time.Now()}
+
+	fakeIS := new(FakeImageStorage)
+	fakeIS.findByIdImage = image
+	fakeFS := new(FakeFileStorage)
+	iModel := NewImagesModel(fakeFS, new(FakeUseChecker), fakeIS)
+
+	assert.Equal(t, controller.ErrModelImageLocked,
+		iModel.DeleteImage(context.Background(), validUUIDv4))
+	assert.Empty(t, fakeFS.deleted)
+
+	_, err := iModel.EditImage(context.Background(), validUUIDv4,
+		createValidImageMeta())
+	assert.Equal(t, controller.ErrModelImageLocked, err)
+}
@@ -0,0 +1,68 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/deployments/resources/images"
+	"github.com/mendersoftware/deployments/resources/images/controller"
+)
+
+// GetNamingPolicy returns artifact naming policy of the tenant, empty one
+// if it was never set.
+func (i *ImagesModel) GetNamingPolicy(ctx context.Context) (*images.NamingPolicy, error) {
+	policy, err := i.imagesStorage.GetNamingPolicy(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "Searching for artifact naming policy")
+	}
+
+	if policy == nil {
+		policy = &images.NamingPolicy{}
+	}
+
+	return policy, nil
+}
+
+// SetNamingPolicy replaces artifact naming policy of the tenant, applied
+// to artifacts uploaded from then on.
+func (i *ImagesModel) SetNamingPolicy(ctx context.Context, policy *images.NamingPolicy) error {
+	if policy == nil {
+		return controller.ErrModelMissingInputNamingPolicy
+	}
+
+	if err := policy.Validate(); err != nil {
+		return errors.Wrap(err, "Validating artifact naming policy")
+	}
+
+	if err := i.imagesStorage.SetNamingPolicy(ctx, policy); err != nil {
+		return errors.Wrap(err, "Storing artifact naming policy")
+	}
+
+	return nil
+}
+
+// checkNamingPolicy returns *images.NamingPolicyError if the artifact name
+// violates naming policy of the tenant.
+func (i *ImagesModel) checkNamingPolicy(ctx context.Context, name string) error {
+	policy, err := i.GetNamingPolicy(ctx)
+	if err != nil {
+		return err
+	}
+
+	return policy.Check(name)
+}
@@ -0,0 +1,136 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mendersoftware/deployments/resources/images"
+	"github.com/mendersoftware/deployments/resources/images/controller"
+)
+
+func TestGetNamingPolicy(t *testing.T) {
+	fakeIS := new(FakeImageStorage)
+	iModel := NewImagesModel(nil, nil, fakeIS)
+
+	// never set
+	policy, err := iModel.GetNamingPolicy(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, &images.NamingPolicy{}, policy)
+
+	fakeIS.namingPolicy = &images.NamingPolicy{Semver: true}
+	policy, err = iModel.GetNamingPolicy(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, &images.NamingPolicy{Semver: true}, policy)
+
+	fakeIS.namingPolicyError = errors.New("db error")
+	_, err = iModel.GetNamingPolicy(context.Background())
+	assert.EqualError(t, err, "Searching for artifact naming policy: db error")
+}
+
+func TestSetNamingPolicy(t *testing.T) {
+	fakeIS := new(FakeImageStorage)
+	iModel := NewImagesModel(nil, nil, fakeIS)
+
+	assert.Equal(t, controller.ErrModelMissingInputNamingPolicy,
+		iModel.SetNamingPolicy(context.Background(), nil))
+
+	err := iModel.SetNamingPolicy(context.Background(),
+		&images.NamingPolicy{Pattern: `(app`})
+	assert.EqualError(t, err, "Validating artifact naming policy: Invalid pattern: "+
+		"error parsing regexp: missing closing ): `^(?:(app)$`")
+	assert.Nil(t, fakeIS.namingPolicy)
+
+	policy := &images.NamingPolicy{Pattern: `app-.*`, Semver: true}
+	assert.NoError(t, iModel.SetNamingPolicy(context.Background(), policy))
+	assert.Equal(t, policy, fakeIS.namingPolicy)
+
+	fakeIS.setNamingPolicyError = errors.New("db error")
+	err = iModel.SetNamingPolicy(context.Background(), policy)
+	assert.EqualError(t, err, "Storing artifact naming policy: db error")
+}
+
+func TestCreateImageNamingPolicy(t *testing.T) {
+	testCases := map[string]struct {
+		policy      *images.NamingPolicy
+		policyError error
+
+		err error
+	}{
+		"name matches": {
+			policy: &images.NamingPolicy{Pattern: `mender-[0-9.]+`},
+		},
+		"name does not match": {
+			policy: &images.NamingPolicy{Pattern: `app-.*`},
+			err: &images.NamingPolicyError{
+				Name:   "mender-1.1",
+				Policy: images.NamingPolicy{Pattern: `app-.*`},
+				Reason: "name does not match pattern app-.*",
+			},
+		},
+		"not a semantic version": {
+			policy: &images.NamingPolicy{Pattern: `mender-.*`, Semver: true},
+			err: &images.NamingPolicyError{
+				Name:   "mender-1.1",
+				Policy: images.NamingPolicy{Pattern: `mender-.*`, Semver: true},
+				Reason: "name does not end with a semantic version",
+			},
+		},
+		"storage error": {
+			policyError: errors.New("db error"),
+			err:         errors.New("Searching for artifact naming policy: db error"),
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			fakeIS := new(FakeImageStorage)
+			fakeIS.isArtifactUnique = true
+			fakeIS.namingPolicy = tc.policy
+			fakeIS.namingPolicyError = tc.policyError
+			fakeFS := new(FakeFileStorage)
+
+			iModel := NewImagesModel(fakeFS, nil, fakeIS)
+
+			upd, err := MakeRootfsImageArtifact(2, false)
+			assert.NoError(t, err)
+
+			id, err := iModel.CreateImage(context.Background(), &controller.MultipartUploadMsg{
+				MetaConstructor: createValidImageMeta(),
+				ArtifactSize:    int64(upd.Len()),
+				ArtifactReader:  upd,
+			})
+			if tc.err == nil {
+				assert.NoError(t, err)
+				assert.NotNil(t, fakeIS.inserted)
+				return
+			}
+
+			if _, ok := tc.err.(*images.NamingPolicyError); ok {
+				assert.Equal(t, tc.err, err)
+			} else {
+				assert.EqualError(t, err, tc.err.Error())
+			}
+			// uploaded file is removed, the image is not stored
+			assert.NotEmpty(t, id)
+			assert.Equal(t, []string{id}, fakeFS.deleted)
+			assert.Nil(t, fakeIS.inserted)
+		})
+	}
+}
@@ -0,0 +1,176 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"context"
+	"io"
+	"runtime"
+	"sync/atomic"
+	"time"
+
+	"github.com/mendersoftware/deployments/resources/images"
+	"github.com/mendersoftware/deployments/resources/images/controller"
+)
+
+const (
+	// Default number of uploads waiting for a free parser
+	DefaultParserQueueSize = 16
+)
+
+// ParserLimits bounds the resources used for parsing and checksumming
+// uploaded artifacts.
+type ParserLimits struct {
+	// Number of artifacts parsed concurrently, defaults to the number of CPUs
+	Workers int
+
+	// Number of uploads allowed to wait for a free worker; further uploads
+	// are rejected with ErrModelParserBusy
+	QueueSize int
+
+	// Maximum time a single upload may take to parse, 0 means no limit
+	Timeout time.Duration
+
+	// Maximum rate in bytes per second at which a single upload is read,
+	// 0 means no limit
+	Rate int64
+}
+
+// parserPool is a bounded pool of artifact parsers.
+// Artifacts are streamed through the parser, so memory used per upload
+// is bounded by the reader buffers regardless of the artifact size.
+type parserPool struct {
+	limits ParserLimits
+	slots  chan struct{}
+
+	active   int64
+	queued   int64
+	rejected int64
+	timedOut int64
+}
+
+func newParserPool(limits ParserLimits) *parserPool {
+	if limits.Workers <= 0 {
+		limits.Workers = runtime.NumCPU()
+	}
+	if limits.QueueSize < 0 {
+		limits.QueueSize = 0
+	}
+	return &parserPool{
+		limits: limits,
+		slots:  make(chan struct{}, limits.Workers),
+	}
+}
+
+// acquire waits for a free worker.
+// Returns function releasing the worker, ErrModelParserBusy if too many uploads
+// are already waiting or context error if the upload was cancelled while waiting.
+func (p *parserPool) acquire(ctx context.Context) (func(), error) {
+	select {
+	case p.slots <- struct{}{}:
+		return p.started(), nil
+	default:
+	}
+
+	if atomic.AddInt64(&p.queued, 1) > int64(p.limits.QueueSize) {
+		atomic.AddInt64(&p.queued, -1)
+		atomic.AddInt64(&p.rejected, 1)
+		return nil, controller.ErrModelParserBusy
+	}
+	defer atomic.AddInt64(&p.queued, -1)
+
+	select {
+	case p.slots <- struct{}{}:
+		return p.started(), nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (p *parserPool) started() func() {
+	atomic.AddInt64(&p.active, 1)
+	return func() {
+		atomic.AddInt64(&p.active, -1)
+		<-p.slots
+	}
+}
+
+// limit applies per upload limits to the context and the artifact reader.
+func (p *parserPool) limit(ctx context.Context,
+	r io.Reader) (context.Context, io.Reader, context.CancelFunc) {
+
+	cancel := context.CancelFunc(func() {})
+	if p.limits.Timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, p.limits.Timeout)
+	}
+
+	return ctx, &limitedReader{
+		ctx:    ctx,
+		reader: r,
+		rate:   p.limits.Rate,
+		start:  time.Now(),
+	}, cancel
+}
+
+// timeout records upload exceeding the parsing time limit.
+func (p *parserPool) timeout() {
+	atomic.AddInt64(&p.timedOut, 1)
+}
+
+func (p *parserPool) stats() *images.ParserStats {
+	return &images.ParserStats{
+		Workers:   p.limits.Workers,
+		QueueSize: p.limits.QueueSize,
+		Active:    int(atomic.LoadInt64(&p.active)),
+		Queued:    int(atomic.LoadInt64(&p.queued)),
+		Rejected:  atomic.LoadInt64(&p.rejected),
+		TimedOut:  atomic.LoadInt64(&p.timedOut),
+	}
+}
+
+// limitedReader stops reading once the context is done
+// and throttles reading to given rate.
+type limitedReader struct {
+	ctx    context.Context
+	reader io.Reader
+	rate   int64
+
+	start time.Time
+	read  int64
+}
+
+func (r *limitedReader) Read(b []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	if r.rate > 0 {
+		if len(b) > int(r.rate) {
+			b = b[:r.rate]
+		}
+		expected := time.Duration(float64(r.read) / float64(r.rate) * float64(time.Second))
+		if wait := expected - time.Since(r.start); wait > 0 {
+			select {
+			case <-time.After(wait):
+			case <-r.ctx.Done():
+				return 0, r.ctx.Err()
+			}
+		}
+	}
+
+	n, err := r.reader.Read(b)
+	r.read += int64(n)
+	return n, err
+}
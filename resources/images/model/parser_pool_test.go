@@ -0,0 +1,131 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mendersoftware/deployments/resources/images"
+	"github.com/mendersoftware/deployments/resources/images/controller"
+)
+
+func TestParserPoolAcquire(t *testing.T) {
+	pool := newParserPool(ParserLimits{Workers: 1, QueueSize: 1})
+
+	release, err := pool.acquire(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, &images.ParserStats{Workers: 1, QueueSize: 1, Active: 1},
+		pool.stats())
+
+	// second upload waits in the queue
+	acquired := make(chan func())
+	go func() {
+		r, err := pool.acquire(context.Background())
+		assert.NoError(t, err)
+		acquired <- r
+	}()
+	for pool.stats().Queued != 1 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// queue is full
+	_, err = pool.acquire(context.Background())
+	assert.Equal(t, controller.ErrModelParserBusy, err)
+	assert.Equal(t, int64(1), pool.stats().Rejected)
+
+	release()
+	release = <-acquired
+	assert.Equal(t, 1, pool.stats().Active)
+	assert.Equal(t, 0, pool.stats().Queued)
+
+	// waiting upload cancelled
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = pool.acquire(ctx)
+	assert.Equal(t, context.Canceled, err)
+	assert.Equal(t, 0, pool.stats().Queued)
+
+	release()
+	assert.Equal(t, 0, pool.stats().Active)
+}
+
+func TestParserPoolLimit(t *testing.T) {
+	data := bytes.Repeat([]byte("a"), 300)
+
+	// rate limited read
+	pool := newParserPool(ParserLimits{Rate: 1000})
+	_, r, cancel := pool.limit(context.Background(), bytes.NewReader(data))
+	start := time.Now()
+	read, err := ioutil.ReadAll(r)
+	cancel()
+	assert.NoError(t, err)
+	assert.Equal(t, data, read)
+	assert.True(t, time.Since(start) >= 200*time.Millisecond)
+
+	// read stops after the timeout
+	pool = newParserPool(ParserLimits{Timeout: 50 * time.Millisecond, Rate: 1000})
+	ctx, r, cancel := pool.limit(context.Background(), bytes.NewReader(data))
+	_, err = ioutil.ReadAll(r)
+	cancel()
+	assert.Equal(t, context.DeadlineExceeded, err)
+	assert.Equal(t, context.DeadlineExceeded, ctx.Err())
+}
+
+func TestCreateImageParserLimits(t *testing.T) {
+	fakeIS := new(FakeImageStorage)
+	fakeIS.isArtifactUnique = true
+	fakeFS := new(FakeFileStorage)
+
+	iModel := NewImagesModel(fakeFS, nil, fakeIS)
+
+	newMessage := func() *controller.MultipartUploadMsg {
+		upd, err := MakeRootfsImageArtifact(1, false)
+		assert.NoError(t, err)
+		return &controller.MultipartUploadMsg{
+			MetaConstructor: createValidImageMeta(),
+			ArtifactSize:    int64(upd.Len()),
+			ArtifactReader:  upd,
+		}
+	}
+
+	// all parsers busy, no queue
+	iModel.SetParserLimits(ParserLimits{Workers: 1})
+	release, err := iModel.parsers.acquire(context.Background())
+	assert.NoError(t, err)
+	_, err = iModel.CreateImage(context.Background(), newMessage())
+	assert.Equal(t, controller.ErrModelParserBusy, err)
+	release()
+
+	// parsing takes too long
+	iModel.SetParserLimits(ParserLimits{Timeout: time.Nanosecond})
+	_, err = iModel.CreateImage(context.Background(), newMessage())
+	assert.Equal(t, controller.ErrModelParsingTimeout, errors.Cause(err))
+
+	stats, err := iModel.ParserStats(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), stats.TimedOut)
+
+	// within limits
+	iModel.SetParserLimits(ParserLimits{Workers: 1, Timeout: time.Minute})
+	_, err = iModel.CreateImage(context.Background(), newMessage())
+	assert.NoError(t, err)
+}
@@ -28,6 +28,8 @@ var (
 	ErrSoftwareImagesStorageInvalidName         = errors.New("Invalid name")
 	ErrSoftwareImagesStorageInvalidDeviceType   = errors.New("Invalid device type")
 	ErrSoftwareImagesStorageInvalidImage        = errors.New("Invalid image")
+	ErrSoftwareImagesStorageInvalidFetch        = errors.New("Invalid artifact fetch")
+	ErrSoftwareImagesStorageInvalidNamingPolicy = errors.New("Invalid naming policy")
 )
 
 // SoftwareImagesStorage allow to store and manage image.SoftwareImages
@@ -40,4 +42,20 @@ type SoftwareImagesStorage interface {
 		deviceTypesCompatible []string) (bool, error)
 	Delete(ctx context.Context, id string) error
 	FindAll(ctx context.Context) ([]*images.SoftwareImage, error)
+	FindByProvides(ctx context.Context,
+		filter images.ProvidesFilter) ([]*images.SoftwareImage, error)
+	ImagesByNamePrefix(ctx context.Context,
+		prefix, deviceType string) ([]*images.SoftwareImage, error)
+	InsertFetch(ctx context.Context, fetch *images.Fetch) error
+	UpdateFetch(ctx context.Context, fetch *images.Fetch) error
+	FindFetchByID(ctx context.Context, id string) (*images.Fetch, error)
+	Trash(ctx context.Context, image *images.SoftwareImage) error
+	FindTrashed(ctx context.Context) ([]*images.SoftwareImage, error)
+	FindTrashedByID(ctx context.Context, id string) (*images.SoftwareImage, error)
+	DeleteTrashed(ctx context.Context, id string) error
+	IsFileShared(ctx context.Context, image *images.SoftwareImage) (bool, error)
+	UpdateLock(ctx context.Context, id string, lock *images.Lock) (bool, error)
+	FindArtifactDeviceTypes(ctx context.Context) ([]images.ArtifactDeviceTypes, error)
+	GetNamingPolicy(ctx context.Context) (*images.NamingPolicy, error)
+	SetNamingPolicy(ctx context.Context, policy *images.NamingPolicy) error
 }
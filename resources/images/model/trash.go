@@ -0,0 +1,120 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/deployments/resources/images"
+	"github.com/mendersoftware/deployments/resources/images/controller"
+)
+
+// ListTrashedImages lists deleted images kept in the trash.
+func (i *ImagesModel) ListTrashedImages(ctx context.Context) ([]*images.SoftwareImage, error) {
+	list, err := i.imagesStorage.FindTrashed(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "Searching for trashed images")
+	}
+
+	if list == nil {
+		return make([]*images.SoftwareImage, 0), nil
+	}
+
+	return list, nil
+}
+
+// RestoreImage moves image from the trash back to the images.
+// Fails if an artifact with the same name and device type was uploaded
+// after the image was deleted.
+func (i *ImagesModel) RestoreImage(ctx context.Context, imageID string) error {
+	image, err := i.imagesStorage.FindTrashedByID(ctx, imageID)
+	if err != nil {
+		return errors.Wrap(err, "Searching for trashed image")
+	}
+	if image == nil {
+		return controller.ErrImageMetaNotFound
+	}
+
+	unique, err := i.imagesStorage.IsArtifactUnique(ctx,
+		image.Name, image.DeviceTypesCompatible)
+	if err != nil {
+		return errors.Wrap(err, "Fail to check if artifact is unique")
+	}
+	if !unique {
+		return controller.ErrModelArtifactNotUnique
+	}
+
+	image.Trashed = nil
+	if err := i.imagesStorage.Insert(ctx, image); err != nil {
+		return errors.Wrap(err, "Restoring image metadata")
+	}
+
+	if err := i.imagesStorage.DeleteTrashed(ctx, imageID); err != nil {
+		return errors.Wrap(err, "Removing image from the trash")
+	}
+
+	return nil
+}
+
+// PurgeImage permanently deletes image in the trash along with its file.
+func (i *ImagesModel) PurgeImage(ctx context.Context, imageID string) error {
+	image, err := i.imagesStorage.FindTrashedByID(ctx, imageID)
+	if err != nil {
+		return errors.Wrap(err, "Searching for trashed image")
+	}
+	if image == nil {
+		return controller.ErrImageMetaNotFound
+	}
+
+	return i.purgeImage(ctx, image)
+}
+
+// PurgeTrash permanently deletes images moved to the trash before given time.
+// Returns number of purged images.
+func (i *ImagesModel) PurgeTrash(ctx context.Context, trashedBefore time.Time) (int, error) {
+	list, err := i.imagesStorage.FindTrashed(ctx)
+	if err != nil {
+		return 0, errors.Wrap(err, "Searching for trashed images")
+	}
+
+	purged := 0
+	for _, image := range list {
+		if image.Trashed != nil && image.Trashed.After(trashedBefore) {
+			continue
+		}
+
+		if err := i.purgeImage(ctx, image); err != nil {
+			return purged, errors.Wrapf(err, "Purging artifact %s", image.Id)
+		}
+		purged++
+	}
+
+	return purged, nil
+}
+
+func (i *ImagesModel) purgeImage(ctx context.Context, image *images.SoftwareImage) error {
+	if err := i.deleteFile(ctx, image); err != nil {
+		return err
+	}
+
+	if err := i.imagesStorage.DeleteTrashed(ctx, image.Id); err != nil {
+		return errors.Wrap(err, "Deleting image metadata")
+	}
+
+	return nil
+}
@@ -0,0 +1,221 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mendersoftware/deployments/resources/images"
+	"github.com/mendersoftware/deployments/resources/images/controller"
+)
+
+func newTrashedImage(id string, trashed time.Time) *images.SoftwareImage {
+	image := images.NewSoftwareImage(id, createValidImageMeta(), createValidImageMetaArtifact())
+	image.ObjectID = "sha256-" + id
+	image.Trashed = &trashed
+	return image
+}
+
+func TestDeleteImageToTrash(t *testing.T) {
+	image := images.NewSoftwareImage(validUUIDv4, createValidImageMeta(),
+		createValidImageMetaArtifact())
+
+	fakeIS := new(FakeImageStorage)
+	fakeIS.findByIdImage = image
+	fakeFS := new(FakeFileStorage)
+
+	iModel := NewImagesModel(fakeFS, new(FakeUseChecker), fakeIS)
+	iModel.SetTrashRetention(7 * 24 * time.Hour)
+
+	assert.NoError(t, iModel.DeleteImage(context.Background(), validUUIDv4))
+	assert.Equal(t, []*images.SoftwareImage{image}, fakeIS.trashed)
+	assert.NotNil(t, image.Trashed)
+	assert.Empty(t, fakeFS.deleted)
+
+	fakeIS.trashError = errors.New("db error")
+	assert.EqualError(t, iModel.DeleteImage(context.Background(), validUUIDv4),
+		"Moving image to the trash: db error")
+}
+
+func TestDeleteImageSharedFile(t *testing.T) {
+	image := images.NewSoftwareImage(validUUIDv4, createValidImageMeta(),
+		createValidImageMetaArtifact())
+
+	fakeIS := new(FakeImageStorage)
+	fakeIS.findByIdImage = image
+	fakeIS.fileShared = true
+	fakeFS := new(FakeFileStorage)
+
+	iModel := NewImagesModel(fakeFS, new(FakeUseChecker), fakeIS)
+
+	assert.NoError(t, iModel.DeleteImage(context.Background(), validUUIDv4))
+	assert.Empty(t, fakeFS.deleted)
+}
+
+func TestListTrashedImages(t *testing.T) {
+	fakeIS := new(FakeImageStorage)
+	iModel := NewImagesModel(new(FakeFileStorage), nil, fakeIS)
+
+	list, err := iModel.ListTrashedImages(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []*images.SoftwareImage{}, list)
+
+	fakeIS.findTrashedError = errors.New("db error")
+	_, err = iModel.ListTrashedImages(context.Background())
+	assert.EqualError(t, err, "Searching for trashed images: db error")
+}
+
+func TestRestoreImage(t *testing.T) {
+	testCases := map[string]struct {
+		id          string
+		notUnique   bool
+		insertError error
+		outputError string
+	}{
+		"ok": {
+			id: "1",
+		},
+		"not found": {
+			id:          "2",
+			outputError: controller.ErrImageMetaNotFound.Error(),
+		},
+		"name taken": {
+			id:          "1",
+			notUnique:   true,
+			outputError: controller.ErrModelArtifactNotUnique.Error(),
+		},
+		"insert error": {
+			id:          "1",
+			insertError: errors.New("db error"),
+			outputError: "Restoring image metadata: db error",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			image := newTrashedImage("1", time.Now())
+
+			fakeIS := new(FakeImageStorage)
+			fakeIS.trashed = []*images.SoftwareImage{image}
+			fakeIS.isArtifactUnique = !tc.notUnique
+			fakeIS.insertError = tc.insertError
+
+			iModel := NewImagesModel(new(FakeFileStorage), nil, fakeIS)
+
+			err := iModel.RestoreImage(context.Background(), tc.id)
+			if tc.outputError != "" {
+				assert.EqualError(t, err, tc.outputError)
+				assert.Len(t, fakeIS.trashed, 1)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, image, fakeIS.inserted)
+				assert.Nil(t, image.Trashed)
+				assert.Empty(t, fakeIS.trashed)
+			}
+		})
+	}
+}
+
+func TestPurgeImage(t *testing.T) {
+	fakeIS := new(FakeImageStorage)
+	fakeIS.trashed = []*images.SoftwareImage{newTrashedImage("1", time.Now())}
+	fakeFS := new(FakeFileStorage)
+	iModel := NewImagesModel(fakeFS, nil, fakeIS)
+
+	assert.EqualError(t, iModel.PurgeImage(context.Background(), "2"),
+		controller.ErrImageMetaNotFound.Error())
+
+	fakeFS.deleteError = errors.New("s3 error")
+	assert.EqualError(t, iModel.PurgeImage(context.Background(), "1"),
+		"Deleting image file: s3 error")
+	assert.Len(t, fakeIS.trashed, 1)
+
+	fakeFS.deleteError = nil
+	assert.NoError(t, iModel.PurgeImage(context.Background(), "1"))
+	assert.Equal(t, []string{"sha256-1"}, fakeFS.deleted)
+	assert.Empty(t, fakeIS.trashed)
+}
+
+func TestPurgeTrash(t *testing.T) {
+	now := time.Now()
+	trashedBefore := now.AddDate(0, 0, -7)
+	old := now.AddDate(0, 0, -10)
+
+	testCases := map[string]struct {
+		images        []*images.SoftwareImage
+		findError     error
+		fileShared    bool
+		deleteError   error
+		outputCount   int
+		outputDeleted []string
+		outputError   string
+	}{
+		"ok": {
+			images: []*images.SoftwareImage{
+				newTrashedImage("1", old),
+				// deleted recently
+				newTrashedImage("2", now),
+			},
+			outputCount:   1,
+			outputDeleted: []string{"sha256-1"},
+		},
+		"shared file": {
+			images: []*images.SoftwareImage{
+				newTrashedImage("1", old),
+			},
+			fileShared:  true,
+			outputCount: 1,
+		},
+		"find error": {
+			findError:   errors.New("db error"),
+			outputError: "Searching for trashed images: db error",
+		},
+		"delete error": {
+			images: []*images.SoftwareImage{
+				newTrashedImage("1", old),
+			},
+			deleteError: errors.New("s3 error"),
+			outputError: "Purging artifact 1: Deleting image file: s3 error",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			fakeIS := new(FakeImageStorage)
+			fakeIS.trashed = tc.images
+			fakeIS.findTrashedError = tc.findError
+			fakeIS.fileShared = tc.fileShared
+
+			fakeFS := new(FakeFileStorage)
+			fakeFS.deleteError = tc.deleteError
+
+			iModel := NewImagesModel(fakeFS, nil, fakeIS)
+
+			count, err := iModel.PurgeTrash(context.Background(), trashedBefore)
+			if tc.outputError != "" {
+				assert.EqualError(t, err, tc.outputError)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tc.outputCount, count)
+			assert.Equal(t, tc.outputDeleted, fakeFS.deleted)
+		})
+	}
+}
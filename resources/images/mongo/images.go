@@ -16,6 +16,7 @@ package mongo
 
 import (
 	"context"
+	"regexp"
 	"time"
 
 	"github.com/asaskevich/govalidator"
@@ -35,17 +36,30 @@ const (
 	StorageKeySoftwareImageDeviceTypes = "meta_artifact.device_types_compatible"
 	StorageKeySoftwareImageName        = "meta_artifact.name"
 	StorageKeySoftwareImageId          = "_id"
+	StorageKeySoftwareImageObjectID    = "object_id"
+	StorageKeySoftwareImageLocked      = "locked"
+
+	StorageKeySoftwareImageProvides       = "meta_artifact.provides"
+	StorageKeySoftwareImageProvideKey     = "meta_artifact.provides.key"
+	StorageKeySoftwareImageProvideValue   = "meta_artifact.provides.value"
+	StorageKeySoftwareImageClearsProvides = "meta_artifact.clears_provides"
 )
 
 // Indexes
 const (
 	IndexUniqeNameAndDeviceTypeStr = "uniqueNameAndDeviceTypeIndex"
+	IndexProvidesStr               = "providesIndex"
+	IndexClearsProvidesStr         = "clearsProvidesIndex"
 )
 
 // Database
 const (
-	DatabaseName     = "deployment_service"
-	CollectionImages = "images"
+	DatabaseName      = "deployment_service"
+	CollectionImages  = "images"
+	CollectionFetches = "artifact_fetches"
+	// Deleted images kept until purged, out of the way of the queries
+	// of the images collection
+	CollectionImagesTrash = "images_trash"
 )
 
 // SoftwareImagesStorage is a data layer for SoftwareImages based on MongoDB
@@ -73,8 +87,27 @@ func (i *SoftwareImagesStorage) ensureIndexing(ctx context.Context, session *mgo
 		Background: false,
 	}
 
-	return session.DB(store.DbFromContext(ctx, DatabaseName)).
-		C(CollectionImages).EnsureIndex(uniqueNameVersionIndex)
+	collection := session.DB(store.DbFromContext(ctx, DatabaseName)).
+		C(CollectionImages)
+	if err := collection.EnsureIndex(uniqueNameVersionIndex); err != nil {
+		return err
+	}
+
+	providesIndex := mgo.Index{
+		Key:        []string{StorageKeySoftwareImageProvideKey, StorageKeySoftwareImageProvideValue},
+		Name:       IndexProvidesStr,
+		Background: true,
+	}
+	if err := collection.EnsureIndex(providesIndex); err != nil {
+		return err
+	}
+
+	clearsProvidesIndex := mgo.Index{
+		Key:        []string{StorageKeySoftwareImageClearsProvides},
+		Name:       IndexClearsProvidesStr,
+		Background: true,
+	}
+	return collection.EnsureIndex(clearsProvidesIndex)
 }
 
 // Exists checks if object with ID exists
@@ -123,6 +156,35 @@ func (i *SoftwareImagesStorage) Update(ctx context.Context,
 	return true, nil
 }
 
+// UpdateLock sets lock of the image, removes it if lock is nil;
+// modification time of the image is kept.
+// Return false if not found
+func (i *SoftwareImagesStorage) UpdateLock(ctx context.Context,
+	id string, lock *images.Lock) (bool, error) {
+
+	if govalidator.IsNull(id) {
+		return false, model.ErrSoftwareImagesStorageInvalidID
+	}
+
+	session := i.session.Copy()
+	defer session.Close()
+
+	update := bson.M{"$unset": bson.M{StorageKeySoftwareImageLocked: ""}}
+	if lock != nil {
+		update = bson.M{"$set": bson.M{StorageKeySoftwareImageLocked: lock}}
+	}
+
+	if err := session.DB(store.DbFromContext(ctx, DatabaseName)).
+		C(CollectionImages).UpdateId(id, update); err != nil {
+		if err.Error() == mgo.ErrNotFound.Error() {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return true, nil
+}
+
 // ImageByNameAndDeviceType finds image with speficied application name and targed device type
 func (i *SoftwareImagesStorage) ImageByNameAndDeviceType(ctx context.Context,
 	name, deviceType string) (*images.SoftwareImage, error) {
@@ -218,6 +280,37 @@ func (i *SoftwareImagesStorage) ImagesByName(
 	return images, nil
 }
 
+// ImagesByNamePrefix lists images with names starting with the prefix,
+// compatible with the device type unless empty.
+func (i *SoftwareImagesStorage) ImagesByNamePrefix(ctx context.Context,
+	prefix, deviceType string) ([]*images.SoftwareImage, error) {
+
+	if govalidator.IsNull(prefix) {
+		return nil, model.ErrSoftwareImagesStorageInvalidName
+	}
+
+	// prefix match can use the name index
+	query := bson.M{
+		StorageKeySoftwareImageName: bson.M{
+			"$regex": "^" + regexp.QuoteMeta(prefix),
+		},
+	}
+	if deviceType != "" {
+		query[StorageKeySoftwareImageDeviceTypes] = deviceType
+	}
+
+	session := i.session.Copy()
+	defer session.Close()
+
+	var images []*images.SoftwareImage
+	if err := session.DB(store.DbFromContext(ctx, DatabaseName)).
+		C(CollectionImages).Find(query).All(&images); err != nil {
+		return nil, err
+	}
+
+	return images, nil
+}
+
 // Insert persists object
 func (i *SoftwareImagesStorage) Insert(ctx context.Context, image *images.SoftwareImage) error {
 
@@ -340,3 +433,241 @@ func (i *SoftwareImagesStorage) FindAll(ctx context.Context) ([]*images.Software
 
 	return images, nil
 }
+
+// FindByProvides lists images matching the provides filter.
+func (i *SoftwareImagesStorage) FindByProvides(ctx context.Context,
+	filter images.ProvidesFilter) ([]*images.SoftwareImage, error) {
+
+	query := bson.M{}
+	if filter.Provide.Key != "" {
+		query[StorageKeySoftwareImageProvides] = bson.M{
+			"$elemMatch": bson.M{
+				"key":   filter.Provide.Key,
+				"value": filter.Provide.Value,
+			},
+		}
+	}
+	if filter.ClearsProvides != "" {
+		query[StorageKeySoftwareImageClearsProvides] = filter.ClearsProvides
+	}
+
+	session := i.session.Copy()
+	defer session.Close()
+
+	var images []*images.SoftwareImage
+	if err := session.DB(store.DbFromContext(ctx, DatabaseName)).
+		C(CollectionImages).Find(query).All(&images); err != nil {
+		return nil, err
+	}
+
+	return images, nil
+}
+
+// FindArtifactDeviceTypes lists device types compatible with artifacts
+// of each name.
+func (i *SoftwareImagesStorage) FindArtifactDeviceTypes(ctx context.Context) (
+	[]images.ArtifactDeviceTypes, error) {
+
+	session := i.session.Copy()
+	defer session.Close()
+
+	pipe := []bson.M{
+		{
+			"$unwind": "$" + StorageKeySoftwareImageDeviceTypes,
+		},
+		{
+			"$group": bson.M{
+				"_id": "$" + StorageKeySoftwareImageName,
+				"device_types": bson.M{
+					"$addToSet": "$" + StorageKeySoftwareImageDeviceTypes,
+				},
+			},
+		},
+	}
+
+	var results []images.ArtifactDeviceTypes
+	if err := session.DB(store.DbFromContext(ctx, DatabaseName)).
+		C(CollectionImages).Pipe(&pipe).All(&results); err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// InsertFetch stores new artifact fetch
+func (i *SoftwareImagesStorage) InsertFetch(ctx context.Context, fetch *images.Fetch) error {
+
+	if fetch == nil || govalidator.IsNull(fetch.Id) {
+		return model.ErrSoftwareImagesStorageInvalidFetch
+	}
+
+	session := i.session.Copy()
+	defer session.Close()
+
+	return session.DB(store.DbFromContext(ctx, DatabaseName)).
+		C(CollectionFetches).Insert(fetch)
+}
+
+// UpdateFetch replaces stored artifact fetch, sets modification time
+func (i *SoftwareImagesStorage) UpdateFetch(ctx context.Context, fetch *images.Fetch) error {
+
+	if fetch == nil || govalidator.IsNull(fetch.Id) {
+		return model.ErrSoftwareImagesStorageInvalidFetch
+	}
+
+	session := i.session.Copy()
+	defer session.Close()
+
+	now := time.Now()
+	fetch.Modified = &now
+
+	return session.DB(store.DbFromContext(ctx, DatabaseName)).
+		C(CollectionFetches).UpdateId(fetch.Id, fetch)
+}
+
+// FindFetchByID search storage for artifact fetch with ID, returns nil if not found
+func (i *SoftwareImagesStorage) FindFetchByID(ctx context.Context,
+	id string) (*images.Fetch, error) {
+
+	if govalidator.IsNull(id) {
+		return nil, model.ErrSoftwareImagesStorageInvalidID
+	}
+
+	session := i.session.Copy()
+	defer session.Close()
+
+	var fetch *images.Fetch
+	if err := session.DB(store.DbFromContext(ctx, DatabaseName)).
+		C(CollectionFetches).FindId(id).One(&fetch); err != nil {
+		if err.Error() == mgo.ErrNotFound.Error() {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return fetch, nil
+}
+
+// Trash moves image to the trash
+func (i *SoftwareImagesStorage) Trash(ctx context.Context, image *images.SoftwareImage) error {
+
+	if image == nil || govalidator.IsNull(image.Id) {
+		return model.ErrSoftwareImagesStorageInvalidImage
+	}
+
+	session := i.session.Copy()
+	defer session.Close()
+
+	db := session.DB(store.DbFromContext(ctx, DatabaseName))
+
+	// upsert, so that trashing can be retried if removal fails
+	if _, err := db.C(CollectionImagesTrash).UpsertId(image.Id, image); err != nil {
+		return err
+	}
+
+	if err := db.C(CollectionImages).RemoveId(image.Id); err != nil {
+		if err.Error() == mgo.ErrNotFound.Error() {
+			return nil
+		}
+		return err
+	}
+
+	return nil
+}
+
+// FindTrashed lists images in the trash
+func (i *SoftwareImagesStorage) FindTrashed(ctx context.Context) ([]*images.SoftwareImage, error) {
+
+	session := i.session.Copy()
+	defer session.Close()
+
+	var images []*images.SoftwareImage
+	if err := session.DB(store.DbFromContext(ctx, DatabaseName)).
+		C(CollectionImagesTrash).Find(nil).Sort("trashed").All(&images); err != nil {
+		return nil, err
+	}
+
+	return images, nil
+}
+
+// FindTrashedByID search the trash for image with ID, returns nil if not found
+func (i *SoftwareImagesStorage) FindTrashedByID(ctx context.Context,
+	id string) (*images.SoftwareImage, error) {
+
+	if govalidator.IsNull(id) {
+		return nil, model.ErrSoftwareImagesStorageInvalidID
+	}
+
+	session := i.session.Copy()
+	defer session.Close()
+
+	var image *images.SoftwareImage
+	if err := session.DB(store.DbFromContext(ctx, DatabaseName)).
+		C(CollectionImagesTrash).FindId(id).One(&image); err != nil {
+		if err.Error() == mgo.ErrNotFound.Error() {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return image, nil
+}
+
+// DeleteTrashed removes image specified by ID from the trash
+// Noop on if not found.
+func (i *SoftwareImagesStorage) DeleteTrashed(ctx context.Context, id string) error {
+
+	if govalidator.IsNull(id) {
+		return model.ErrSoftwareImagesStorageInvalidID
+	}
+
+	session := i.session.Copy()
+	defer session.Close()
+
+	if err := session.DB(store.DbFromContext(ctx, DatabaseName)).
+		C(CollectionImagesTrash).RemoveId(id); err != nil {
+		if err.Error() == mgo.ErrNotFound.Error() {
+			return nil
+		}
+		return err
+	}
+
+	return nil
+}
+
+// IsFileShared checks if any other image, in the trash or not, is stored
+// in the same content addressed file as the image.
+func (i *SoftwareImagesStorage) IsFileShared(ctx context.Context,
+	image *images.SoftwareImage) (bool, error) {
+
+	if image == nil {
+		return false, model.ErrSoftwareImagesStorageInvalidImage
+	}
+
+	// files of images uploaded before files were keyed by content
+	// are never shared
+	if image.ObjectID == "" {
+		return false, nil
+	}
+
+	session := i.session.Copy()
+	defer session.Close()
+
+	db := session.DB(store.DbFromContext(ctx, DatabaseName))
+
+	query := bson.M{
+		StorageKeySoftwareImageObjectID: image.ObjectID,
+		StorageKeySoftwareImageId:       bson.M{"$ne": image.Id},
+	}
+	for _, collection := range []string{CollectionImages, CollectionImagesTrash} {
+		count, err := db.C(collection).Find(query).Count()
+		if err != nil {
+			return false, err
+		}
+		if count > 0 {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
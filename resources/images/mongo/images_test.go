@@ -16,9 +16,11 @@ package mongo_test
 
 import (
 	"context"
+	"sort"
 	"testing"
 
 	"github.com/mendersoftware/go-lib-micro/identity"
+	"github.com/satori/go.uuid"
 	"github.com/stretchr/testify/assert"
 
 	"github.com/mendersoftware/deployments/resources/images"
@@ -260,3 +262,383 @@ func TestIsArtifactUnique(t *testing.T) {
 	}
 
 }
+
+func TestSoftwareImagesStorageFetch(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping TestSoftwareImagesStorageFetch in short mode.")
+	}
+
+	db.Wipe()
+	session := db.Session()
+	defer session.Close()
+
+	ctx := identity.WithContext(context.Background(), &identity.Identity{
+		Tenant: "foo",
+	})
+	store := NewSoftwareImagesStorage(session)
+
+	assert.EqualError(t, store.InsertFetch(ctx, nil),
+		model.ErrSoftwareImagesStorageInvalidFetch.Error())
+
+	fetch := images.NewFetch("1", &images.FetchConstructor{
+		URI: "https://ci.example.com/app.mender",
+	})
+	assert.NoError(t, store.InsertFetch(ctx, fetch))
+
+	fetch.Status = images.FetchStatusDownloading
+	fetch.Size = 1024
+	fetch.Downloaded = 512
+	assert.NoError(t, store.UpdateFetch(ctx, fetch))
+
+	out, err := store.FindFetchByID(ctx, "1")
+	assert.NoError(t, err)
+	assert.NotNil(t, out)
+	assert.Equal(t, images.FetchStatusDownloading, out.Status)
+	assert.Equal(t, int64(1024), out.Size)
+	assert.Equal(t, int64(512), out.Downloaded)
+
+	// stored per tenant
+	out, err = store.FindFetchByID(context.Background(), "1")
+	assert.NoError(t, err)
+	assert.Nil(t, out)
+
+	out, err = store.FindFetchByID(ctx, "2")
+	assert.NoError(t, err)
+	assert.Nil(t, out)
+}
+
+func TestSoftwareImagesStorageTrash(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping TestSoftwareImagesStorageTrash in short mode.")
+	}
+
+	db.Wipe()
+	session := db.Session()
+	defer session.Close()
+
+	ctx := identity.WithContext(context.Background(), &identity.Identity{
+		Tenant: "foo",
+	})
+	store := NewSoftwareImagesStorage(session)
+
+	newImage := func(id, name, objectID string) *images.SoftwareImage {
+		image := images.NewSoftwareImage(id,
+			images.NewSoftwareImageMetaConstructor(),
+			&images.SoftwareImageMetaArtifactConstructor{
+				Name:                  name,
+				DeviceTypesCompatible: []string{"foo"},
+				Info:                  &images.ArtifactInfo{Format: "mender", Version: 2},
+			})
+		image.ObjectID = objectID
+		return image
+	}
+
+	deleted := newImage("d50eda0d-2cea-4de1-8d42-9cd3e7e8670d", "App1", "sha256-1")
+	kept := newImage("5ee8c7bc-9c3e-4ba8-a8c8-bdcbe2f6a7b4", "App2", "sha256-2")
+	assert.NoError(t, store.Insert(ctx, deleted))
+	assert.NoError(t, store.Insert(ctx, kept))
+
+	shared, err := store.IsFileShared(ctx, deleted)
+	assert.NoError(t, err)
+	assert.False(t, shared)
+
+	assert.NoError(t, store.Trash(ctx, deleted))
+
+	out, err := store.FindByID(ctx, deleted.Id)
+	assert.NoError(t, err)
+	assert.Nil(t, out)
+
+	unique, err := store.IsArtifactUnique(ctx, "App1", []string{"foo"})
+	assert.NoError(t, err)
+	assert.True(t, unique)
+
+	out, err = store.FindTrashedByID(ctx, deleted.Id)
+	assert.NoError(t, err)
+	assert.NotNil(t, out)
+	assert.Equal(t, "App1", out.Name)
+
+	list, err := store.FindTrashed(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, list, 1)
+
+	// the same content uploaded again shares the file
+	reuploaded := newImage("0c1fc2ad-b4d4-4a80-8e1c-e8b3e8fb8f11", "App1", "sha256-1")
+	assert.NoError(t, store.Insert(ctx, reuploaded))
+	shared, err = store.IsFileShared(ctx, deleted)
+	assert.NoError(t, err)
+	assert.True(t, shared)
+	shared, err = store.IsFileShared(ctx, reuploaded)
+	assert.NoError(t, err)
+	assert.True(t, shared)
+
+	assert.NoError(t, store.DeleteTrashed(ctx, deleted.Id))
+	out, err = store.FindTrashedByID(ctx, deleted.Id)
+	assert.NoError(t, err)
+	assert.Nil(t, out)
+
+	shared, err = store.IsFileShared(ctx, reuploaded)
+	assert.NoError(t, err)
+	assert.False(t, shared)
+}
+
+func TestSoftwareImagesStorageUpdateLock(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping TestSoftwareImagesStorageUpdateLock in short mode.")
+	}
+
+	db.Wipe()
+	session := db.Session()
+	defer session.Close()
+
+	ctx := context.Background()
+	store := NewSoftwareImagesStorage(session)
+
+	image := images.NewSoftwareImage("d50eda0d-2cea-4de1-8d42-9cd3e7e8670d",
+		images.NewSoftwareImageMetaConstructor(),
+		&images.SoftwareImageMetaArtifactConstructor{
+			Name:                  "App1",
+			DeviceTypesCompatible: []string{"foo"},
+			Info:                  &images.ArtifactInfo{Format: "mender", Version: 2},
+		})
+	assert.NoError(t, store.Insert(ctx, image))
+
+	found, err := store.UpdateLock(ctx, "5ee8c7bc-9c3e-4ba8-a8c8-bdcbe2f6a7b4",
+		&images.Lock{Subject: "user"})
+	assert.NoError(t, err)
+	assert.False(t, found)
+
+	found, err = store.UpdateLock(ctx, image.Id, &images.Lock{Subject: "user"})
+	assert.NoError(t, err)
+	assert.True(t, found)
+
+	out, err := store.FindByID(ctx, image.Id)
+	assert.NoError(t, err)
+	assert.NotNil(t, out.Locked)
+	assert.Equal(t, "user", out.Locked.Subject)
+	assert.Equal(t, image.Modified.Unix(), out.Modified.Unix())
+
+	found, err = store.UpdateLock(ctx, image.Id, nil)
+	assert.NoError(t, err)
+	assert.True(t, found)
+
+	out, err = store.FindByID(ctx, image.Id)
+	assert.NoError(t, err)
+	assert.Nil(t, out.Locked)
+}
+
+func TestSoftwareImagesStorageFindArtifactDeviceTypes(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping TestSoftwareImagesStorageFindArtifactDeviceTypes in short mode.")
+	}
+
+	db.Wipe()
+	session := db.Session()
+	defer session.Close()
+
+	ctx := context.Background()
+	store := NewSoftwareImagesStorage(session)
+
+	for id, typ := range map[string][]string{
+		"d50eda0d-2cea-4de1-8d42-9cd3e7e8670d": {"foo", "bar"},
+		"5ee8c7bc-9c3e-4ba8-a8c8-bdcbe2f6a7b4": {"baz"},
+	} {
+		image := images.NewSoftwareImage(id,
+			images.NewSoftwareImageMetaConstructor(),
+			&images.SoftwareImageMetaArtifactConstructor{
+				Name:                  "App1",
+				DeviceTypesCompatible: typ,
+				Info:                  &images.ArtifactInfo{Format: "mender", Version: 2},
+			})
+		assert.NoError(t, store.Insert(ctx, image))
+	}
+
+	out, err := store.FindArtifactDeviceTypes(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, out, 1)
+	assert.Equal(t, "App1", out[0].Name)
+	sort.Strings(out[0].DeviceTypes)
+	assert.Equal(t, []string{"bar", "baz", "foo"}, out[0].DeviceTypes)
+}
+
+func TestSoftwareImagesStorageFindByProvides(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping TestSoftwareImagesStorageFindByProvides in short mode.")
+	}
+
+	db.Wipe()
+	session := db.Session()
+	defer session.Close()
+
+	ctx := context.Background()
+	store := NewSoftwareImagesStorage(session)
+
+	image := images.NewSoftwareImage("d50eda0d-2cea-4de1-8d42-9cd3e7e8670d",
+		images.NewSoftwareImageMetaConstructor(),
+		&images.SoftwareImageMetaArtifactConstructor{
+			Name:                  "App1",
+			DeviceTypesCompatible: []string{"foo"},
+			Info:                  &images.ArtifactInfo{Format: "mender", Version: 2},
+			Provides: []images.ArtifactProvide{
+				{Key: "artifact_name", Value: "App1"},
+				{Key: "rootfs-image.version", Value: "1.0"},
+			},
+			ClearsProvides: []string{"rootfs-image.*"},
+		})
+	assert.NoError(t, store.Insert(ctx, image))
+
+	testCases := map[string]struct {
+		filter images.ProvidesFilter
+		found  bool
+	}{
+		"provide": {
+			filter: images.ProvidesFilter{
+				Provide: images.ArtifactProvide{Key: "rootfs-image.version", Value: "1.0"},
+			},
+			found: true,
+		},
+		"key and value of different provides": {
+			filter: images.ProvidesFilter{
+				Provide: images.ArtifactProvide{Key: "rootfs-image.version", Value: "App1"},
+			},
+		},
+		"clears provides": {
+			filter: images.ProvidesFilter{ClearsProvides: "rootfs-image.*"},
+			found:  true,
+		},
+		"both": {
+			filter: images.ProvidesFilter{
+				Provide:        images.ArtifactProvide{Key: "artifact_name", Value: "App1"},
+				ClearsProvides: "data-partition.*",
+			},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			out, err := store.FindByProvides(ctx, tc.filter)
+			assert.NoError(t, err)
+			if tc.found {
+				assert.Len(t, out, 1)
+				assert.Equal(t, image.Provides, out[0].Provides)
+			} else {
+				assert.Empty(t, out)
+			}
+		})
+	}
+}
+
+func TestSoftwareImagesStorageImagesByNamePrefix(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping TestSoftwareImagesStorageImagesByNamePrefix in short mode.")
+	}
+
+	db.Wipe()
+	session := db.Session()
+	defer session.Close()
+
+	ctx := context.Background()
+	store := NewSoftwareImagesStorage(session)
+
+	for i, artifact := range []struct {
+		name       string
+		deviceType string
+	}{
+		{"app-1.0.0", "foo"},
+		{"app-1.1.0", "bar"},
+		{"app.1.2.0", "foo"},
+		{"other-app-2.0.0", "foo"},
+	} {
+		image := images.NewSoftwareImage(uuid.NewV4().String(),
+			images.NewSoftwareImageMetaConstructor(),
+			&images.SoftwareImageMetaArtifactConstructor{
+				Name:                  artifact.name,
+				DeviceTypesCompatible: []string{artifact.deviceType},
+				Info:                  &images.ArtifactInfo{Format: "mender", Version: 2},
+			})
+		assert.NoError(t, store.Insert(ctx, image), i)
+	}
+
+	_, err := store.ImagesByNamePrefix(ctx, "", "foo")
+	assert.Equal(t, model.ErrSoftwareImagesStorageInvalidName, err)
+
+	testCases := map[string]struct {
+		prefix     string
+		deviceType string
+
+		names []string
+	}{
+		"prefix": {
+			prefix: "app-",
+			names:  []string{"app-1.0.0", "app-1.1.0"},
+		},
+		"prefix and device type": {
+			prefix:     "app-",
+			deviceType: "foo",
+			names:      []string{"app-1.0.0"},
+		},
+		"prefix is not a pattern": {
+			prefix: "app.",
+			names:  []string{"app.1.2.0"},
+		},
+		"no match": {
+			prefix:     "app-",
+			deviceType: "baz",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			found, err := store.ImagesByNamePrefix(ctx, tc.prefix, tc.deviceType)
+			assert.NoError(t, err)
+			names := []string{}
+			for _, image := range found {
+				names = append(names, image.Name)
+			}
+			if tc.names == nil {
+				tc.names = []string{}
+			}
+			sort.Strings(names)
+			assert.Equal(t, tc.names, names)
+		})
+	}
+}
+
+func TestSoftwareImagesStorageNamingPolicy(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping TestSoftwareImagesStorageNamingPolicy in short mode.")
+	}
+
+	db.Wipe()
+	session := db.Session()
+	defer session.Close()
+
+	ctx := identity.WithContext(context.Background(), &identity.Identity{
+		Tenant: "foo",
+	})
+	store := NewSoftwareImagesStorage(session)
+
+	assert.EqualError(t, store.SetNamingPolicy(ctx, nil),
+		model.ErrSoftwareImagesStorageInvalidNamingPolicy.Error())
+
+	out, err := store.GetNamingPolicy(ctx)
+	assert.NoError(t, err)
+	assert.Nil(t, out)
+
+	policy := &images.NamingPolicy{Pattern: `app-.*`, Semver: true}
+	assert.NoError(t, store.SetNamingPolicy(ctx, policy))
+	out, err = store.GetNamingPolicy(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, policy, out)
+
+	// replaced as a whole
+	policy = &images.NamingPolicy{Semver: true}
+	assert.NoError(t, store.SetNamingPolicy(ctx, policy))
+	out, err = store.GetNamingPolicy(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, policy, out)
+
+	// stored per tenant
+	out, err = store.GetNamingPolicy(context.Background())
+	assert.NoError(t, err)
+	assert.Nil(t, out)
+}
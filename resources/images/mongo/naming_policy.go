@@ -0,0 +1,68 @@
+// Copyright 2018 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package mongo
+
+import (
+	"context"
+
+	"github.com/globalsign/mgo"
+	"github.com/mendersoftware/go-lib-micro/store"
+
+	"github.com/mendersoftware/deployments/resources/images"
+	"github.com/mendersoftware/deployments/resources/images/model"
+)
+
+// Tenant settings, shared with the deployment settings
+const (
+	CollectionSettings = "settings"
+
+	SettingsIDArtifactNaming = "artifact_naming"
+)
+
+// GetNamingPolicy returns artifact naming policy of the tenant, nil if it
+// was never set.
+func (i *SoftwareImagesStorage) GetNamingPolicy(ctx context.Context) (
+	*images.NamingPolicy, error) {
+
+	session := i.session.Copy()
+	defer session.Close()
+
+	var policy images.NamingPolicy
+	if err := session.DB(store.DbFromContext(ctx, DatabaseName)).
+		C(CollectionSettings).FindId(SettingsIDArtifactNaming).One(&policy); err != nil {
+		if err == mgo.ErrNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &policy, nil
+}
+
+// SetNamingPolicy replaces artifact naming policy of the tenant.
+func (i *SoftwareImagesStorage) SetNamingPolicy(ctx context.Context,
+	policy *images.NamingPolicy) error {
+
+	if policy == nil {
+		return model.ErrSoftwareImagesStorageInvalidNamingPolicy
+	}
+
+	session := i.session.Copy()
+	defer session.Close()
+
+	_, err := session.DB(store.DbFromContext(ctx, DatabaseName)).
+		C(CollectionSettings).UpsertId(SettingsIDArtifactNaming, policy)
+	return err
+}
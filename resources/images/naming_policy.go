@@ -0,0 +1,98 @@
+// Copyright 2026 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package images
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/pkg/errors"
+)
+
+// Maximum length of the artifact name pattern
+const NamingPolicyPatternMaxLength = 1024
+
+// semverSuffix matches names ending with a semantic version.
+var semverSuffix = regexp.MustCompile(DefaultVersionPattern)
+
+// Errors
+var (
+	ErrNamingPolicyPatternTooLong = errors.New("Pattern is too long")
+)
+
+// NamingPolicy are tenant rules artifact names have to follow, enforced
+// on upload. Empty policy allows any name.
+type NamingPolicy struct {
+	// Regular expression the whole artifact name has to match
+	Pattern string `json:"pattern,omitempty" bson:"pattern,omitempty"`
+
+	// Artifact name has to end with a semantic version
+	Semver bool `json:"semver,omitempty" bson:"semver,omitempty"`
+}
+
+// Validate checks that the pattern is a valid regular expression.
+func (p *NamingPolicy) Validate() error {
+	if len(p.Pattern) > NamingPolicyPatternMaxLength {
+		return ErrNamingPolicyPatternTooLong
+	}
+	if _, err := p.pattern(); err != nil {
+		return errors.Wrap(err, "Invalid pattern")
+	}
+	return nil
+}
+
+// pattern compiles the pattern anchored at both ends, nil if not set.
+func (p *NamingPolicy) pattern() (*regexp.Regexp, error) {
+	if p.Pattern == "" {
+		return nil, nil
+	}
+	return regexp.Compile("^(?:" + p.Pattern + ")$")
+}
+
+// Check returns *NamingPolicyError if the artifact name violates the policy.
+func (p *NamingPolicy) Check(name string) error {
+	pattern, err := p.pattern()
+	if err != nil {
+		return errors.Wrap(err, "Invalid pattern")
+	}
+
+	switch {
+	case pattern != nil && !pattern.MatchString(name):
+		return &NamingPolicyError{
+			Name:   name,
+			Policy: *p,
+			Reason: fmt.Sprintf("name does not match pattern %s", p.Pattern),
+		}
+	case p.Semver && !semverSuffix.MatchString(name):
+		return &NamingPolicyError{
+			Name:   name,
+			Policy: *p,
+			Reason: "name does not end with a semantic version",
+		}
+	}
+
+	return nil
+}
+
+// NamingPolicyError is the violation of the naming policy by artifact name.
+type NamingPolicyError struct {
+	Name   string
+	Policy NamingPolicy
+	Reason string
+}
+
+func (e *NamingPolicyError) Error() string {
+	return fmt.Sprintf("Artifact name %s violates naming policy: %s", e.Name, e.Reason)
+}
@@ -0,0 +1,116 @@
+// Copyright 2026 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package images
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNamingPolicyValidate(t *testing.T) {
+	assert.NoError(t, (&NamingPolicy{}).Validate())
+	assert.NoError(t, (&NamingPolicy{Pattern: `app-[a-z]+`, Semver: true}).Validate())
+
+	assert.EqualError(t, (&NamingPolicy{Pattern: `app-[a-z`}).Validate(),
+		"Invalid pattern: error parsing regexp: missing closing ]: `[a-z)$`")
+	assert.Equal(t, ErrNamingPolicyPatternTooLong,
+		(&NamingPolicy{Pattern: strings.Repeat("a", 1025)}).Validate())
+}
+
+func TestNamingPolicyCheck(t *testing.T) {
+	testCases := map[string]struct {
+		policy NamingPolicy
+		name   string
+
+		reason string
+	}{
+		"empty policy": {
+			name: "anything goes",
+		},
+		"pattern": {
+			policy: NamingPolicy{Pattern: `app-[a-z]+`},
+			name:   "app-release",
+		},
+		"pattern, matched in full": {
+			policy: NamingPolicy{Pattern: `app-[a-z]+`},
+			name:   "my-app-release",
+
+			reason: "name does not match pattern app-[a-z]+",
+		},
+		"pattern alternatives, matched in full": {
+			policy: NamingPolicy{Pattern: `app|release`},
+			name:   "apprelease",
+
+			reason: "name does not match pattern app|release",
+		},
+		"semver": {
+			policy: NamingPolicy{Semver: true},
+			name:   "release-1.2.3",
+		},
+		"semver only": {
+			policy: NamingPolicy{Semver: true},
+			name:   "v2.0.0-rc.1+build.5",
+		},
+		"semver, missing patch": {
+			policy: NamingPolicy{Semver: true},
+			name:   "release-1.2",
+
+			reason: "name does not end with a semantic version",
+		},
+		"semver, leading zero": {
+			policy: NamingPolicy{Semver: true},
+			name:   "release-1.02.3",
+
+			reason: "name does not end with a semantic version",
+		},
+		"semver, part of other version": {
+			policy: NamingPolicy{Semver: true},
+			name:   "release-1.1.2.3",
+
+			reason: "name does not end with a semantic version",
+		},
+		"pattern and semver": {
+			policy: NamingPolicy{Pattern: `app-.*`, Semver: true},
+			name:   "app-1.0.0",
+		},
+		"pattern and semver, no version": {
+			policy: NamingPolicy{Pattern: `app-.*`, Semver: true},
+			name:   "app-latest",
+
+			reason: "name does not end with a semantic version",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			err := tc.policy.Check(tc.name)
+			if tc.reason == "" {
+				assert.NoError(t, err)
+				return
+			}
+			if assert.IsType(t, &NamingPolicyError{}, err) {
+				assert.Equal(t, &NamingPolicyError{
+					Name:   tc.name,
+					Policy: tc.policy,
+					Reason: tc.reason,
+				}, err)
+				assert.EqualError(t, err, "Artifact name "+tc.name+
+					" violates naming policy: "+tc.reason)
+			}
+		})
+	}
+}
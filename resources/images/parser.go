@@ -0,0 +1,36 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package images
+
+// ParserStats describes usage of the artifact parsers.
+type ParserStats struct {
+	// Number of artifacts which can be parsed concurrently
+	Workers int `json:"workers"`
+
+	// Maximum number of uploads waiting for a parser
+	QueueSize int `json:"queue_size"`
+
+	// Number of artifacts being parsed
+	Active int `json:"active"`
+
+	// Number of uploads waiting for a parser
+	Queued int `json:"queued"`
+
+	// Number of uploads rejected because the queue was full
+	Rejected int64 `json:"rejected"`
+
+	// Number of uploads which exceeded the parsing time limit
+	TimedOut int64 `json:"timed_out"`
+}
@@ -0,0 +1,71 @@
+// Copyright 2026 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package images
+
+import (
+	"errors"
+	"strings"
+)
+
+// Keys of the artifact list filters.
+const (
+	FilterProvides       = "provides"
+	FilterClearsProvides = "clears_provides"
+)
+
+var (
+	ErrInvalidProvidesFilter = errors.New("provides filter must be in key:value format")
+)
+
+// ArtifactProvide is a single key/value pair an artifact provides once
+// installed. Provide keys usually contain dots, which can not be used in
+// document field names, so provides are kept as a list of pairs.
+type ArtifactProvide struct {
+	Key   string `json:"key" bson:"key"`
+	Value string `json:"value" bson:"value"`
+}
+
+// ProvidesFilter selects artifacts by their provides.
+type ProvidesFilter struct {
+	// Provide the artifact must have, ignored if the key is empty
+	Provide ArtifactProvide
+
+	// Pattern the artifact must clear, ignored if empty
+	ClearsProvides string
+}
+
+// ParseProvidesFilter builds the provides filter from the list filters.
+// Returns nil if the filters do not select by provides.
+func ParseProvidesFilter(filters map[string]string) (*ProvidesFilter, error) {
+	provides := filters[FilterProvides]
+	clearsProvides := filters[FilterClearsProvides]
+	if provides == "" && clearsProvides == "" {
+		return nil, nil
+	}
+
+	filter := &ProvidesFilter{ClearsProvides: clearsProvides}
+	if provides != "" {
+		idx := strings.Index(provides, ":")
+		if idx < 1 {
+			return nil, ErrInvalidProvidesFilter
+		}
+		filter.Provide = ArtifactProvide{
+			Key:   provides[:idx],
+			Value: provides[idx+1:],
+		}
+	}
+
+	return filter, nil
+}
@@ -0,0 +1,81 @@
+// Copyright 2026 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package images
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseProvidesFilter(t *testing.T) {
+	testCases := map[string]struct {
+		filters map[string]string
+
+		filter *ProvidesFilter
+		err    error
+	}{
+		"no filters": {
+			filters: map[string]string{},
+		},
+		"provides": {
+			filters: map[string]string{
+				FilterProvides: "rootfs-image.version:release:1",
+			},
+			filter: &ProvidesFilter{
+				Provide: ArtifactProvide{
+					Key:   "rootfs-image.version",
+					Value: "release:1",
+				},
+			},
+		},
+		"clears provides": {
+			filters: map[string]string{
+				FilterClearsProvides: "rootfs-image.*",
+			},
+			filter: &ProvidesFilter{
+				ClearsProvides: "rootfs-image.*",
+			},
+		},
+		"empty value": {
+			filters: map[string]string{
+				FilterProvides: "data-partition.app:",
+			},
+			filter: &ProvidesFilter{
+				Provide: ArtifactProvide{Key: "data-partition.app"},
+			},
+		},
+		"missing separator": {
+			filters: map[string]string{
+				FilterProvides: "rootfs-image.version",
+			},
+			err: ErrInvalidProvidesFilter,
+		},
+		"missing key": {
+			filters: map[string]string{
+				FilterProvides: ":release-1",
+			},
+			err: ErrInvalidProvidesFilter,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			filter, err := ParseProvidesFilter(tc.filters)
+			assert.Equal(t, tc.err, err)
+			assert.Equal(t, tc.filter, filter)
+		})
+	}
+}
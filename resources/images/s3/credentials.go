@@ -0,0 +1,135 @@
+// Copyright 2018 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package s3
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/pkg/errors"
+)
+
+const (
+	// WebIdentityProviderName is the name of the web identity credentials
+	// provider.
+	WebIdentityProviderName = "WebIdentityRoleProvider"
+
+	// Temporary credentials are refreshed this long before they expire.
+	CredentialsExpiryWindow = time.Minute
+)
+
+// WebIdentityRoleAssumer exchanges web identity token, e.g. of a Kubernetes
+// service account, for temporary credentials of a role.
+// Satisfied by the STS client.
+type WebIdentityRoleAssumer interface {
+	AssumeRoleWithWebIdentity(input *sts.AssumeRoleWithWebIdentityInput) (
+		*sts.AssumeRoleWithWebIdentityOutput, error)
+}
+
+// WebIdentityRoleProvider retrieves temporary credentials of a role with
+// the web identity token read from a file, and keeps track of their
+// expiration time. The token file is read on each retrieval, as the token
+// is rotated by its issuer.
+type WebIdentityRoleProvider struct {
+	credentials.Expiry
+
+	Client          WebIdentityRoleAssumer
+	RoleARN         string
+	RoleSessionName string
+	TokenFile       string
+	Duration        time.Duration
+	ExpiryWindow    time.Duration
+}
+
+// Retrieve generates a new set of temporary credentials using STS.
+func (p *WebIdentityRoleProvider) Retrieve() (credentials.Value, error) {
+	token, err := ioutil.ReadFile(p.TokenFile)
+	if err != nil {
+		return credentials.Value{ProviderName: WebIdentityProviderName},
+			errors.Wrap(err, "failed to read web identity token")
+	}
+
+	sessionName := p.RoleSessionName
+	if sessionName == "" {
+		sessionName = fmt.Sprintf("%d", time.Now().UTC().UnixNano())
+	}
+
+	input := &sts.AssumeRoleWithWebIdentityInput{
+		RoleArn:          aws.String(p.RoleARN),
+		RoleSessionName:  aws.String(sessionName),
+		WebIdentityToken: aws.String(strings.TrimSpace(string(token))),
+	}
+	if p.Duration > 0 {
+		input.DurationSeconds = aws.Int64(int64(p.Duration / time.Second))
+	}
+
+	out, err := p.Client.AssumeRoleWithWebIdentity(input)
+	if err != nil {
+		return credentials.Value{ProviderName: WebIdentityProviderName},
+			errors.Wrap(err, "failed to assume role with web identity")
+	}
+
+	p.SetExpiration(*out.Credentials.Expiration, p.ExpiryWindow)
+
+	return credentials.Value{
+		AccessKeyID:     *out.Credentials.AccessKeyId,
+		SecretAccessKey: *out.Credentials.SecretAccessKey,
+		SessionToken:    *out.Credentials.SessionToken,
+		ProviderName:    WebIdentityProviderName,
+	}, nil
+}
+
+// NewWebIdentityCredentials creates credentials of the role assumed with
+// web identity token, e.g. of IAM roles for Kubernetes service accounts.
+func NewWebIdentityCredentials(region, roleARN, sessionName,
+	tokenFile string) *credentials.Credentials {
+
+	// the token is the only authentication of the request
+	sess := session.New(aws.NewConfig().
+		WithRegion(region).
+		WithCredentials(credentials.AnonymousCredentials))
+
+	return credentials.NewCredentials(&WebIdentityRoleProvider{
+		Client:          sts.New(sess),
+		RoleARN:         roleARN,
+		RoleSessionName: sessionName,
+		TokenFile:       tokenFile,
+		ExpiryWindow:    CredentialsExpiryWindow,
+	})
+}
+
+// NewAssumeRoleCredentials creates credentials of the role assumed with
+// the base credentials; default credential chain of the SDK if nil.
+func NewAssumeRoleCredentials(region string, base *credentials.Credentials,
+	roleARN, sessionName string) *credentials.Credentials {
+
+	config := aws.NewConfig().WithRegion(region)
+	if base != nil {
+		config = config.WithCredentials(base)
+	}
+
+	return stscreds.NewCredentials(session.New(config), roleARN,
+		func(p *stscreds.AssumeRoleProvider) {
+			p.RoleSessionName = sessionName
+			p.ExpiryWindow = CredentialsExpiryWindow
+		})
+}
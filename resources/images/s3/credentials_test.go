@@ -0,0 +1,147 @@
+// Copyright 2018 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package s3
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeWebIdentityAssumer struct {
+	inputs     []*sts.AssumeRoleWithWebIdentityInput
+	expiration time.Time
+	err        error
+}
+
+func (f *fakeWebIdentityAssumer) AssumeRoleWithWebIdentity(
+	input *sts.AssumeRoleWithWebIdentityInput) (*sts.AssumeRoleWithWebIdentityOutput, error) {
+
+	f.inputs = append(f.inputs, input)
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &sts.AssumeRoleWithWebIdentityOutput{
+		Credentials: &sts.Credentials{
+			AccessKeyId:     aws.String("key"),
+			SecretAccessKey: aws.String("secret"),
+			SessionToken:    aws.String("session"),
+			Expiration:      aws.Time(f.expiration),
+		},
+	}, nil
+}
+
+func TestWebIdentityRoleProvider(t *testing.T) {
+	dir, err := ioutil.TempDir("", "web-identity")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	tokenFile := filepath.Join(dir, "token")
+	assert.NoError(t, ioutil.WriteFile(tokenFile, []byte("token-1\n"), 0600))
+
+	assumer := &fakeWebIdentityAssumer{expiration: time.Now().Add(time.Hour)}
+	creds := credentials.NewCredentials(&WebIdentityRoleProvider{
+		Client:          assumer,
+		RoleARN:         "arn:aws:iam::123456789012:role/deployments",
+		RoleSessionName: "deployments",
+		TokenFile:       tokenFile,
+		ExpiryWindow:    CredentialsExpiryWindow,
+	})
+
+	value, err := creds.Get()
+	assert.NoError(t, err)
+	assert.Equal(t, credentials.Value{
+		AccessKeyID:     "key",
+		SecretAccessKey: "secret",
+		SessionToken:    "session",
+		ProviderName:    WebIdentityProviderName,
+	}, value)
+	assert.False(t, creds.IsExpired())
+	if assert.Len(t, assumer.inputs, 1) {
+		assert.Equal(t, "arn:aws:iam::123456789012:role/deployments",
+			*assumer.inputs[0].RoleArn)
+		assert.Equal(t, "deployments", *assumer.inputs[0].RoleSessionName)
+		assert.Equal(t, "token-1", *assumer.inputs[0].WebIdentityToken)
+		assert.Nil(t, assumer.inputs[0].DurationSeconds)
+	}
+
+	// credentials are cached until they expire
+	_, err = creds.Get()
+	assert.NoError(t, err)
+	assert.Len(t, assumer.inputs, 1)
+
+	// refreshed credentials are requested with the rotated token
+	assert.NoError(t, ioutil.WriteFile(tokenFile, []byte("token-2"), 0600))
+	creds.Expire()
+	_, err = creds.Get()
+	assert.NoError(t, err)
+	if assert.Len(t, assumer.inputs, 2) {
+		assert.Equal(t, "token-2", *assumer.inputs[1].WebIdentityToken)
+	}
+}
+
+func TestWebIdentityRoleProviderExpiryWindow(t *testing.T) {
+	dir, err := ioutil.TempDir("", "web-identity")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	tokenFile := filepath.Join(dir, "token")
+	assert.NoError(t, ioutil.WriteFile(tokenFile, []byte("token"), 0600))
+
+	provider := &WebIdentityRoleProvider{
+		Client: &fakeWebIdentityAssumer{
+			expiration: time.Now().Add(30 * time.Second),
+		},
+		RoleARN:      "arn:aws:iam::123456789012:role/deployments",
+		TokenFile:    tokenFile,
+		ExpiryWindow: CredentialsExpiryWindow,
+	}
+
+	_, err = provider.Retrieve()
+	assert.NoError(t, err)
+	assert.True(t, provider.IsExpired())
+}
+
+func TestWebIdentityRoleProviderErrors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "web-identity")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	tokenFile := filepath.Join(dir, "token")
+
+	provider := &WebIdentityRoleProvider{
+		Client:    &fakeWebIdentityAssumer{err: errors.New("access denied")},
+		RoleARN:   "arn:aws:iam::123456789012:role/deployments",
+		TokenFile: tokenFile,
+	}
+
+	value, err := provider.Retrieve()
+	assert.EqualError(t, err, "failed to read web identity token: open "+
+		tokenFile+": no such file or directory")
+	assert.Equal(t, WebIdentityProviderName, value.ProviderName)
+
+	assert.NoError(t, ioutil.WriteFile(tokenFile, []byte("token"), 0600))
+	_, err = provider.Retrieve()
+	assert.EqualError(t, err,
+		"failed to assume role with web identity: access denied")
+}
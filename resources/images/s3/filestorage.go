@@ -15,7 +15,10 @@
 package s3
 
 import (
+	"bytes"
 	"context"
+	"crypto/md5"
+	"encoding/base64"
 	"fmt"
 	"io"
 	"net/http"
@@ -37,9 +40,21 @@ import (
 )
 
 const (
-	ExpireMaxLimit                 = 7 * 24 * time.Hour
-	ExpireMinLimit                 = 1 * time.Minute
-	ErrCodeBucketAlreadyOwnedByYou = "BucketAlreadyOwnedByYou"
+	ExpireMaxLimit                  = 7 * 24 * time.Hour
+	ExpireMinLimit                  = 1 * time.Minute
+	ErrCodeBucketAlreadyOwnedByYou  = "BucketAlreadyOwnedByYou"
+	ErrCodeRestoreAlreadyInProgress = "RestoreAlreadyInProgress"
+
+	// Artifacts larger than this are uploaded using multipart upload
+	MultipartPartSize = 16 * 1024 * 1024
+	// Maximum number of parts of a multipart upload allowed by S3
+	MultipartMaxParts = 10000
+	// Attempts to upload a part before the upload is aborted
+	MultipartPartAttempts = 3
+
+	// Objects larger than this are copied using multipart upload
+	CopyMaxSize  = 5 * 1024 * 1024 * 1024
+	CopyPartSize = 1024 * 1024 * 1024
 )
 
 // SimpleStorageService - AWS S3 client.
@@ -49,20 +64,41 @@ type SimpleStorageService struct {
 	client      *s3.S3
 	bucket      string
 	tagArtifact bool
+	partSize    int64
+	sessions    UploadSessions
 }
 
-// NewSimpleStorageServiceStatic create new S3 client model.
-// AWS authentication keys are automatically reloaded from env variables.
-func NewSimpleStorageServiceStatic(bucket, key, secret, region, token, uri string, tag_artifact bool) (*SimpleStorageService, error) {
-	credentials := credentials.NewStaticCredentials(key, secret, token)
-	config := aws.NewConfig().WithCredentials(credentials).WithRegion(region)
+// Options of the S3 client.
+type Options struct {
+	Region string
+	// Endpoint of S3 compatible storage, e.g. MinIO; AWS if empty.
+	Endpoint string
+	// ForcePathStyle addresses buckets by path instead of host name,
+	// as required by most S3 compatible storages.
+	ForcePathStyle bool
+	// Credentials signing the requests; default credential chain of
+	// the SDK if nil, i.e. env variables, AWS profile file and ec2 iam role.
+	Credentials *credentials.Credentials
+	TagArtifact bool
+	// UploadSessions stores state of multipart uploads; kept in memory
+	// if nil.
+	UploadSessions UploadSessions
+}
+
+// NewSimpleStorageService create new S3 client model.
+// Credentials are refreshed automatically when they expire.
+func NewSimpleStorageService(bucket string, opts Options) (*SimpleStorageService, error) {
+	config := aws.NewConfig().WithRegion(opts.Region)
+	if opts.Credentials != nil {
+		config = config.WithCredentials(opts.Credentials)
+	}
 
-	if len(uri) > 0 {
-		sslDisabled := !strings.HasPrefix(uri, "https://")
-		config = config.WithDisableSSL(sslDisabled).WithEndpoint(uri)
+	if len(opts.Endpoint) > 0 {
+		sslDisabled := !strings.HasPrefix(opts.Endpoint, "https://")
+		config = config.WithDisableSSL(sslDisabled).WithEndpoint(opts.Endpoint)
 	}
 
-	config.S3ForcePathStyle = aws.Bool(true)
+	config.S3ForcePathStyle = aws.Bool(opts.ForcePathStyle)
 	sess := session.New(config)
 
 	client := s3.New(sess)
@@ -81,39 +117,37 @@ func NewSimpleStorageServiceStatic(bucket, key, secret, region, token, uri strin
 		}
 	}
 
+	sessions := opts.UploadSessions
+	if sessions == nil {
+		sessions = NewMemoryUploadSessions()
+	}
+
 	return &SimpleStorageService{
 		client:      client,
 		bucket:      bucket,
-		tagArtifact: tag_artifact,
+		tagArtifact: opts.TagArtifact,
+		partSize:    MultipartPartSize,
+		sessions:    sessions,
 	}, nil
 }
 
+// NewSimpleStorageServiceStatic create new S3 client model.
+// AWS authentication keys are automatically reloaded from env variables.
+func NewSimpleStorageServiceStatic(bucket, key, secret, region, token, uri string, tag_artifact bool) (*SimpleStorageService, error) {
+	return NewSimpleStorageService(bucket, Options{
+		Region:         region,
+		Endpoint:       uri,
+		ForcePathStyle: true,
+		Credentials:    credentials.NewStaticCredentials(key, secret, token),
+		TagArtifact:    tag_artifact,
+	})
+}
+
 // NewSimpleStorageServiceDefaults create new S3 client model.
 // Use default authentication provides which looks at env variables,
 // Aws profile file and ec2 iam role
 func NewSimpleStorageServiceDefaults(bucket, region string) (*SimpleStorageService, error) {
-
-	sess := session.New(aws.NewConfig().WithRegion(region))
-	client := s3.New(sess)
-
-	// minio requires explicit bucket creation
-	cparams := &s3.CreateBucketInput{
-		Bucket: aws.String(bucket), // Required
-	}
-
-	_, err := client.CreateBucket(cparams)
-	if err != nil {
-		if awsErr, ok := err.(awserr.Error); ok {
-			if awsErr.Code() != ErrCodeBucketAlreadyOwnedByYou {
-				return nil, err
-			}
-		}
-	}
-
-	return &SimpleStorageService{
-		client: client,
-		bucket: bucket,
-	}, nil
+	return NewSimpleStorageService(bucket, Options{Region: region})
 }
 
 func getArtifactByTenant(ctx context.Context, objectID string) string {
@@ -180,7 +214,11 @@ func (s *SimpleStorageService) Exists(ctx context.Context, objectID string) (boo
 }
 
 // UploadArtifact uploads given artifact into the file server (AWS S3 or minio)
-// using objectID as a key
+// using objectID as a key.
+// Artifact is streamed from the reader; artifacts larger than a single part
+// are sent using multipart upload, buffering one part in memory at a time.
+// Parts are verified by S3 against their MD5 checksum; failed parts are
+// uploaded again, up to MultipartPartAttempts times.
 func (s *SimpleStorageService) UploadArtifact(ctx context.Context,
 	objectID string, size int64, artifact io.Reader, contentType string) error {
 	objectID = getArtifactByTenant(ctx, objectID)
@@ -191,6 +229,47 @@ func (s *SimpleStorageService) UploadArtifact(ctx context.Context,
 		Key:    aws.String(objectID),
 	}
 
+	var err error
+	if size > s.partSize {
+		err = s.uploadMultipart(ctx, objectID, size, artifact, contentType)
+	} else {
+		err = s.uploadSingle(params, size, artifact, contentType)
+	}
+	if err != nil {
+		return err
+	}
+
+	s.tagTenant(ctx, objectID)
+
+	return nil
+}
+
+// tagTenant tags the object with ID of the tenant owning it, if enabled.
+func (s *SimpleStorageService) tagTenant(ctx context.Context, objectID string) {
+	if id := identity.FromContext(ctx); id != nil && len(id.Tenant) > 0 && s.tagArtifact {
+		input := &s3.PutObjectTaggingInput{
+			Bucket: aws.String(s.bucket),
+			Key:    aws.String(objectID),
+			Tagging: &s3.Tagging{
+				TagSet: []*s3.Tag{
+					{
+						Key:   aws.String("tenant_id"),
+						Value: aws.String(id.Tenant),
+					},
+				},
+			},
+		}
+		if _, err := s.client.PutObjectTagging(input); err != nil {
+			l := log.FromContext(ctx)
+			l.Warnf("failed to tag artifact : %s\n", objectID)
+		}
+	}
+}
+
+// uploadSingle sends the artifact with a single presigned PUT request.
+func (s *SimpleStorageService) uploadSingle(params *s3.PutObjectInput,
+	size int64, artifact io.Reader, contentType string) error {
+
 	// Ignore out object
 	r, _ := s.client.PutObjectRequest(params)
 
@@ -219,28 +298,313 @@ func (s *SimpleStorageService) UploadArtifact(ctx context.Context,
 			"Artifact upload failed with HTTP status %v", resp.Status)
 	}
 
-	if id := identity.FromContext(ctx); id != nil && len(id.Tenant) > 0 && s.tagArtifact {
-		input := &s3.PutObjectTaggingInput{
-			Bucket: params.Bucket,
-			Key:    params.Key,
-			Tagging: &s3.Tagging{
-				TagSet: []*s3.Tag{
-					{
-						Key:   aws.String("tenant_id"),
-						Value: aws.String(id.Tenant),
-					},
-				},
-			},
+	return nil
+}
+
+// uploadMultipart sends the artifact in parts using S3 multipart upload.
+func (s *SimpleStorageService) uploadMultipart(ctx context.Context,
+	objectID string, size int64, artifact io.Reader, contentType string) error {
+
+	// S3 allows at most MultipartMaxParts parts
+	partSize := s.partSize
+	if minSize := (size + MultipartMaxParts - 1) / MultipartMaxParts; partSize < minSize {
+		partSize = minSize
+	}
+
+	return s.multipart(ctx, objectID, contentType,
+		func(uploadID string) ([]*s3.CompletedPart, error) {
+			session := &UploadSession{
+				ObjectID: objectID,
+				UploadID: uploadID,
+				PartSize: partSize,
+			}
+			defer func() {
+				if err := s.sessions.Delete(ctx, uploadID); err != nil {
+					log.FromContext(ctx).Warnf(
+						"failed to remove session of upload of artifact %s: %s",
+						objectID, err.Error())
+				}
+			}()
+
+			return s.uploadParts(ctx, session, size, artifact)
+		})
+}
+
+// multipart runs multipart upload of the object with parts provided by
+// the given function. Incomplete upload is aborted on error.
+func (s *SimpleStorageService) multipart(ctx context.Context,
+	objectID string, contentType string,
+	sendParts func(uploadID string) ([]*s3.CompletedPart, error)) error {
+
+	upload, err := s.client.CreateMultipartUploadWithContext(ctx, &s3.CreateMultipartUploadInput{
+		Bucket:      aws.String(s.bucket),
+		Key:         aws.String(objectID),
+		ContentType: aws.String(contentType),
+	})
+	if err != nil {
+		return errors.Wrap(err, "Starting multipart upload")
+	}
+
+	parts, err := sendParts(*upload.UploadId)
+	if err == nil {
+		_, err = s.client.CompleteMultipartUploadWithContext(ctx, &s3.CompleteMultipartUploadInput{
+			Bucket:          aws.String(s.bucket),
+			Key:             aws.String(objectID),
+			UploadId:        upload.UploadId,
+			MultipartUpload: &s3.CompletedMultipartUpload{Parts: parts},
+		})
+		err = errors.Wrap(err, "Completing multipart upload")
+	}
+	if err != nil {
+		// the request context may already be done, abort regardless
+		if _, abortErr := s.client.AbortMultipartUpload(&s3.AbortMultipartUploadInput{
+			Bucket:   aws.String(s.bucket),
+			Key:      aws.String(objectID),
+			UploadId: upload.UploadId,
+		}); abortErr != nil {
+			log.FromContext(ctx).Warnf("failed to abort upload of artifact %s: %s",
+				objectID, abortErr.Error())
 		}
-		if _, err := s.client.PutObjectTagging(input); err != nil {
-			l := log.FromContext(r.Context())
-			l.Warnf("failed to tag artifact : %s\n", objectID)
+		return err
+	}
+
+	return nil
+}
+
+// uploadParts reads the artifact part by part and uploads the parts,
+// recording their state in the upload session.
+func (s *SimpleStorageService) uploadParts(ctx context.Context,
+	session *UploadSession, size int64,
+	artifact io.Reader) ([]*s3.CompletedPart, error) {
+
+	buf := make([]byte, session.PartSize)
+
+	for number := int64(1); size > 0; number++ {
+		n := session.PartSize
+		if size < n {
+			n = size
+		}
+		if _, err := io.ReadFull(artifact, buf[:n]); err != nil {
+			return nil, errors.Wrap(err, "Reading artifact")
+		}
+		size -= n
+
+		sum := md5.Sum(buf[:n])
+		part := &UploadPart{
+			Number: number,
+			Size:   n,
+			MD5:    base64.StdEncoding.EncodeToString(sum[:]),
+			State:  PartPending,
+		}
+		session.Parts = append(session.Parts, part)
+
+		if err := s.uploadPart(ctx, session, part, buf[:n]); err != nil {
+			return nil, err
+		}
+	}
+
+	return session.completed(), nil
+}
+
+// uploadPart uploads the part, again if it fails, until uploaded or out of
+// attempts.
+func (s *SimpleStorageService) uploadPart(ctx context.Context,
+	session *UploadSession, part *UploadPart, data []byte) error {
+
+	for {
+		if err := s.sessions.Save(ctx, session); err != nil {
+			return errors.Wrap(err, "Saving upload session")
+		}
+		if part.State == PartUploaded {
+			return nil
 		}
+
+		part.Attempts++
+		uploaded, err := s.client.UploadPartWithContext(ctx, &s3.UploadPartInput{
+			Bucket:        aws.String(s.bucket),
+			Key:           aws.String(session.ObjectID),
+			UploadId:      aws.String(session.UploadID),
+			PartNumber:    aws.Int64(part.Number),
+			ContentLength: aws.Int64(part.Size),
+			ContentMD5:    aws.String(part.MD5),
+			Body:          bytes.NewReader(data),
+		})
+		if err != nil {
+			part.State = PartFailed
+			if part.Attempts >= MultipartPartAttempts || ctx.Err() != nil {
+				if saveErr := s.sessions.Save(ctx, session); saveErr != nil {
+					log.FromContext(ctx).Warnf(
+						"failed to save session of upload of artifact %s: %s",
+						session.ObjectID, saveErr.Error())
+				}
+				return errors.Wrapf(err, "Uploading artifact part %d", part.Number)
+			}
+			log.FromContext(ctx).Warnf("failed to upload part %d of artifact %s, retrying: %s",
+				part.Number, session.ObjectID, err.Error())
+			continue
+		}
+
+		part.ETag = aws.StringValue(uploaded.ETag)
+		part.State = PartUploaded
+	}
+}
+
+// Move stores the object under objectID and removes the source object.
+func (s *SimpleStorageService) Move(ctx context.Context,
+	sourceID string, objectID string) error {
+	sourceID = getArtifactByTenant(ctx, sourceID)
+	objectID = getArtifactByTenant(ctx, objectID)
+
+	head, err := s.client.HeadObjectWithContext(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(sourceID),
+	})
+	if err != nil {
+		return errors.Wrap(err, "Searching for file")
+	}
+
+	// single request copy is limited in size
+	if size := aws.Int64Value(head.ContentLength); size > CopyMaxSize {
+		err = s.copyMultipart(ctx, sourceID, objectID, size,
+			aws.StringValue(head.ContentType))
+		if err == nil {
+			s.tagTenant(ctx, objectID)
+		}
+	} else {
+		_, err = s.client.CopyObjectWithContext(ctx, &s3.CopyObjectInput{
+			Bucket:     aws.String(s.bucket),
+			Key:        aws.String(objectID),
+			CopySource: aws.String(s.bucket + "/" + sourceID),
+		})
+	}
+	if err != nil {
+		return errors.Wrap(err, "Copying file")
+	}
+
+	_, err = s.client.DeleteObjectWithContext(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(sourceID),
+	})
+	if err != nil {
+		return errors.Wrap(err, "Removing file")
 	}
 
 	return nil
 }
 
+// copyMultipart copies the object in CopyPartSize ranges using multipart upload.
+func (s *SimpleStorageService) copyMultipart(ctx context.Context,
+	sourceID, objectID string, size int64, contentType string) error {
+
+	return s.multipart(ctx, objectID, contentType,
+		func(uploadID string) ([]*s3.CompletedPart, error) {
+			parts := []*s3.CompletedPart{}
+			for number, start := int64(1), int64(0); start < size; number++ {
+				end := start + CopyPartSize
+				if end > size {
+					end = size
+				}
+
+				part, err := s.client.UploadPartCopyWithContext(ctx, &s3.UploadPartCopyInput{
+					Bucket:          aws.String(s.bucket),
+					Key:             aws.String(objectID),
+					UploadId:        aws.String(uploadID),
+					PartNumber:      aws.Int64(number),
+					CopySource:      aws.String(s.bucket + "/" + sourceID),
+					CopySourceRange: aws.String(fmt.Sprintf("bytes=%d-%d", start, end-1)),
+				})
+				if err != nil {
+					return nil, errors.Wrapf(err, "Copying file part %d", number)
+				}
+
+				parts = append(parts, &s3.CompletedPart{
+					ETag:       part.CopyPartResult.ETag,
+					PartNumber: aws.Int64(number),
+				})
+				start = end
+			}
+			return parts, nil
+		})
+}
+
+// Archive moves the object to the given storage class (e.g. GLACIER)
+// by copying it in place.
+func (s *SimpleStorageService) Archive(ctx context.Context,
+	objectID string, storageClass string) error {
+	objectID = getArtifactByTenant(ctx, objectID)
+
+	params := &s3.CopyObjectInput{
+		// Required
+		Bucket:     aws.String(s.bucket),
+		Key:        aws.String(objectID),
+		CopySource: aws.String(s.bucket + "/" + objectID),
+
+		// Optional
+		StorageClass:      aws.String(storageClass),
+		MetadataDirective: aws.String(s3.MetadataDirectiveCopy),
+	}
+
+	if _, err := s.client.CopyObject(params); err != nil {
+		return errors.Wrap(err, "Changing file storage class")
+	}
+
+	return nil
+}
+
+// Restore requests temporary copy of the object stored in archive storage class.
+// Returns true if the object can be downloaded: it's not archived or
+// the restored copy is available already.
+func (s *SimpleStorageService) Restore(ctx context.Context,
+	objectID string, days int64) (bool, error) {
+	objectID = getArtifactByTenant(ctx, objectID)
+
+	head, err := s.client.HeadObject(&s3.HeadObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(objectID),
+	})
+	if err != nil {
+		return false, errors.Wrap(err, "Checking file storage class")
+	}
+
+	if !isArchiveStorageClass(head.StorageClass) {
+		return true, nil
+	}
+
+	// restore was requested already; either ongoing or completed
+	if head.Restore != nil {
+		return strings.Contains(*head.Restore, `ongoing-request="false"`), nil
+	}
+
+	_, err = s.client.RestoreObject(&s3.RestoreObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(objectID),
+		RestoreRequest: &s3.RestoreRequest{
+			Days: aws.Int64(days),
+		},
+	})
+	if err != nil {
+		if awsErr, ok := err.(awserr.Error); ok &&
+			awsErr.Code() == ErrCodeRestoreAlreadyInProgress {
+			return false, nil
+		}
+		return false, errors.Wrap(err, "Requesting file restore")
+	}
+
+	return false, nil
+}
+
+func isArchiveStorageClass(storageClass *string) bool {
+	if storageClass == nil {
+		return false
+	}
+
+	switch *storageClass {
+	case s3.ObjectStorageClassGlacier, "DEEP_ARCHIVE":
+		return true
+	}
+	return false
+}
+
 // PutRequest duration is limited to 7 days (AWS limitation)
 func (s *SimpleStorageService) PutRequest(ctx context.Context, objectID string,
 	duration time.Duration) (*images.Link, error) {
@@ -13,3 +13,269 @@
 //    limitations under the License.
 
 package s3
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws/client"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeS3 accepts single and multipart object uploads.
+// Parts are verified against their Content-MD5.
+type fakeS3 struct {
+	lock    sync.Mutex
+	objects map[string][]byte
+	parts   map[string][]byte
+	aborted bool
+	// part failing failTimes times, always if 0
+	failAt    string
+	failTimes int
+	failed    int
+	// part corrupted in transit once
+	corruptAt string
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	q := r.URL.Query()
+	switch {
+	case r.Method == http.MethodPut && r.URL.Path == "/bucket":
+		// bucket creation
+	case r.Method == http.MethodPost && q["uploads"] != nil:
+		fmt.Fprintf(w, `<InitiateMultipartUploadResult>`+
+			`<Bucket>bucket</Bucket><Key>%s</Key><UploadId>upload</UploadId>`+
+			`</InitiateMultipartUploadResult>`, r.URL.Path)
+	case r.Method == http.MethodPut && q.Get("partNumber") != "":
+		if q.Get("partNumber") == f.failAt && (f.failTimes == 0 || f.failed < f.failTimes) {
+			f.failed++
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		data, _ := ioutil.ReadAll(r.Body)
+		if q.Get("partNumber") == f.corruptAt {
+			f.corruptAt = ""
+			data[0]++
+		}
+		sum := md5.Sum(data)
+		if r.Header.Get("Content-MD5") != base64.StdEncoding.EncodeToString(sum[:]) {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `<Error><Code>BadDigest</Code>`+
+				`<Message>The Content-MD5 you specified did not match what we received.</Message>`+
+				`</Error>`)
+			return
+		}
+		f.parts[q.Get("partNumber")] = data
+		w.Header().Set("ETag", `"etag-`+q.Get("partNumber")+`"`)
+	case r.Method == http.MethodPost && q.Get("uploadId") != "":
+		var object []byte
+		for i := 1; i <= len(f.parts); i++ {
+			object = append(object, f.parts[fmt.Sprint(i)]...)
+		}
+		f.objects[r.URL.Path] = object
+		fmt.Fprintf(w, `<CompleteMultipartUploadResult>`+
+			`<Bucket>bucket</Bucket><Key>%s</Key><ETag>"etag"</ETag>`+
+			`</CompleteMultipartUploadResult>`, r.URL.Path)
+	case r.Method == http.MethodDelete && q.Get("uploadId") != "":
+		f.aborted = true
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodPut && r.Header.Get("X-Amz-Copy-Source") != "":
+		source := "/" + strings.TrimPrefix(r.Header.Get("X-Amz-Copy-Source"), "/")
+		data, ok := f.objects[source]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		f.objects[r.URL.Path] = data
+		fmt.Fprint(w, `<CopyObjectResult><ETag>"etag"</ETag></CopyObjectResult>`)
+	case r.Method == http.MethodPut:
+		data, _ := ioutil.ReadAll(r.Body)
+		f.objects[r.URL.Path] = data
+	case r.Method == http.MethodHead:
+		data, ok := f.objects[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Length", fmt.Sprint(len(data)))
+	case r.Method == http.MethodDelete:
+		delete(f.objects, r.URL.Path)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusNotImplemented)
+	}
+}
+
+func TestUploadArtifact(t *testing.T) {
+	data := bytes.Repeat([]byte("0123456789"), 10)
+
+	testCases := map[string]struct {
+		partSize  int64
+		failAt    string
+		failTimes int
+		corruptAt string
+
+		parts   int
+		aborted bool
+		err     bool
+	}{
+		"single request": {
+			partSize: 1000,
+		},
+		"multipart": {
+			partSize: 30,
+			parts:    4,
+		},
+		"multipart, part failed once": {
+			partSize:  30,
+			failAt:    "2",
+			failTimes: 1,
+			parts:     4,
+		},
+		"multipart, part corrupted": {
+			partSize:  30,
+			corruptAt: "3",
+			parts:     4,
+		},
+		"multipart, part failed": {
+			partSize: 30,
+			failAt:   "2",
+			parts:    1,
+			aborted:  true,
+			err:      true,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			fake := &fakeS3{
+				objects:   map[string][]byte{},
+				parts:     map[string][]byte{},
+				failAt:    tc.failAt,
+				failTimes: tc.failTimes,
+				corruptAt: tc.corruptAt,
+			}
+			server := httptest.NewServer(fake)
+			defer server.Close()
+
+			s, err := NewSimpleStorageServiceStatic("bucket", "key", "secret",
+				"us-east-1", "", server.URL, false)
+			assert.NoError(t, err)
+			s.client.Retryer = client.DefaultRetryer{NumMaxRetries: 0}
+			s.partSize = tc.partSize
+
+			err = s.UploadArtifact(context.Background(), "artifact",
+				int64(len(data)), bytes.NewReader(data), "application/vnd.mender-artifact")
+			if tc.err {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, data, fake.objects["/bucket/artifact"])
+			}
+			assert.Len(t, fake.parts, tc.parts)
+			assert.Equal(t, tc.aborted, fake.aborted)
+			if tc.failAt != "" && tc.failTimes == 0 {
+				assert.Equal(t, MultipartPartAttempts, fake.failed)
+			}
+		})
+	}
+}
+
+func TestMove(t *testing.T) {
+	fake := &fakeS3{
+		objects: map[string][]byte{"/bucket/upload": []byte("artifact")},
+		parts:   map[string][]byte{},
+	}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	s, err := NewSimpleStorageServiceStatic("bucket", "key", "secret",
+		"us-east-1", "", server.URL, false)
+	assert.NoError(t, err)
+	s.client.Retryer = client.DefaultRetryer{NumMaxRetries: 0}
+
+	err = s.Move(context.Background(), "upload", "sha256-abc")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string][]byte{"/bucket/sha256-abc": []byte("artifact")}, fake.objects)
+
+	err = s.Move(context.Background(), "upload", "sha256-abc")
+	assert.Error(t, err)
+}
+
+// recordingSessions keeps copies of all saved states of upload sessions.
+type recordingSessions struct {
+	*MemoryUploadSessions
+	saved []*UploadSession
+}
+
+func (r *recordingSessions) Save(ctx context.Context, session *UploadSession) error {
+	r.saved = append(r.saved, session.copy())
+	return r.MemoryUploadSessions.Save(ctx, session)
+}
+
+func TestUploadArtifactSessions(t *testing.T) {
+	data := bytes.Repeat([]byte("0123456789"), 10)
+
+	fake := &fakeS3{
+		objects:   map[string][]byte{},
+		parts:     map[string][]byte{},
+		failAt:    "2",
+		failTimes: 1,
+	}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	sessions := &recordingSessions{MemoryUploadSessions: NewMemoryUploadSessions()}
+	s, err := NewSimpleStorageServiceStatic("bucket", "key", "secret",
+		"us-east-1", "", server.URL, false)
+	assert.NoError(t, err)
+	s.client.Retryer = client.DefaultRetryer{NumMaxRetries: 0}
+	s.partSize = 60
+	s.sessions = sessions
+
+	err = s.UploadArtifact(context.Background(), "artifact",
+		int64(len(data)), bytes.NewReader(data), "application/vnd.mender-artifact")
+	assert.NoError(t, err)
+	assert.Equal(t, data, fake.objects["/bucket/artifact"])
+
+	// the failed part is uploaded again, the uploaded one is not
+	states := []PartState{}
+	for _, session := range sessions.saved {
+		assert.Equal(t, "upload", session.UploadID)
+		assert.Equal(t, "artifact", session.ObjectID)
+		last := session.Parts[len(session.Parts)-1]
+		states = append(states, last.State)
+	}
+	assert.Equal(t, []PartState{
+		PartPending, PartUploaded,
+		PartPending, PartFailed, PartUploaded,
+	}, states)
+
+	last := sessions.saved[len(sessions.saved)-1]
+	sum := md5.Sum(data[60:])
+	assert.Equal(t, &UploadPart{
+		Number:   2,
+		Size:     40,
+		MD5:      base64.StdEncoding.EncodeToString(sum[:]),
+		ETag:     `"etag-2"`,
+		State:    PartUploaded,
+		Attempts: 2,
+	}, last.Parts[1])
+	assert.Equal(t, 1, last.Parts[0].Attempts)
+
+	// session is removed once the upload is done
+	_, err = sessions.Get(context.Background(), "upload")
+	assert.Equal(t, ErrUploadSessionNotFound, err)
+}
@@ -0,0 +1,128 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package s3
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// Errors
+var (
+	ErrUploadSessionNotFound = errors.New("upload session not found")
+)
+
+// PartState is the upload state of a part of a multipart upload.
+type PartState string
+
+const (
+	PartPending  PartState = "pending"
+	PartUploaded PartState = "uploaded"
+	PartFailed   PartState = "failed"
+)
+
+// UploadPart is the state of a part of a multipart upload.
+type UploadPart struct {
+	Number int64
+	Size   int64
+	// base64 encoded MD5 of the part, sent as Content-MD5 for S3 to
+	// reject corrupted parts
+	MD5      string
+	ETag     string
+	State    PartState
+	Attempts int
+}
+
+// UploadSession is the state of a multipart upload of an object.
+type UploadSession struct {
+	ObjectID string
+	UploadID string
+	PartSize int64
+	Parts    []*UploadPart
+}
+
+func (s *UploadSession) copy() *UploadSession {
+	session := *s
+	session.Parts = make([]*UploadPart, len(s.Parts))
+	for i, part := range s.Parts {
+		p := *part
+		session.Parts[i] = &p
+	}
+	return &session
+}
+
+// completed lists the uploaded parts, as required to complete the upload.
+func (s *UploadSession) completed() []*s3.CompletedPart {
+	parts := []*s3.CompletedPart{}
+	for _, part := range s.Parts {
+		if part.State == PartUploaded {
+			parts = append(parts, &s3.CompletedPart{
+				ETag:       aws.String(part.ETag),
+				PartNumber: aws.Int64(part.Number),
+			})
+		}
+	}
+	return parts
+}
+
+// UploadSessions stores state of running multipart uploads.
+type UploadSessions interface {
+	Save(ctx context.Context, session *UploadSession) error
+	Get(ctx context.Context, uploadID string) (*UploadSession, error)
+	Delete(ctx context.Context, uploadID string) error
+}
+
+// MemoryUploadSessions keeps state of multipart uploads run by the process.
+type MemoryUploadSessions struct {
+	lock     sync.Mutex
+	sessions map[string]*UploadSession
+}
+
+func NewMemoryUploadSessions() *MemoryUploadSessions {
+	return &MemoryUploadSessions{
+		sessions: make(map[string]*UploadSession),
+	}
+}
+
+func (m *MemoryUploadSessions) Save(ctx context.Context, session *UploadSession) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	m.sessions[session.UploadID] = session.copy()
+	return nil
+}
+
+func (m *MemoryUploadSessions) Get(ctx context.Context, uploadID string) (*UploadSession, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	session, ok := m.sessions[uploadID]
+	if !ok {
+		return nil, ErrUploadSessionNotFound
+	}
+	return session.copy(), nil
+}
+
+func (m *MemoryUploadSessions) Delete(ctx context.Context, uploadID string) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	delete(m.sessions, uploadID)
+	return nil
+}
@@ -0,0 +1,222 @@
+// Copyright 2026 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package images
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultVersionPattern extracts semantic version, optionally prefixed
+// with "v", from the end of the artifact name, e.g. app-1.2.3 or
+// app-v2.0.0-rc.1+build.5.
+const DefaultVersionPattern = `(?:^|[^0-9A-Za-z.])v?(?P<version>` +
+	`(?:0|[1-9][0-9]*)\.(?:0|[1-9][0-9]*)\.(?:0|[1-9][0-9]*)` +
+	`(?:-[0-9A-Za-z-]+(?:\.[0-9A-Za-z-]+)*)?` +
+	`(?:\+[0-9A-Za-z-]+(?:\.[0-9A-Za-z-]+)*)?)$`
+
+// Name of the pattern group capturing the version
+const VersionPatternGroup = "version"
+
+// Errors
+var (
+	ErrVersionPatternNoGroup = errors.New(`pattern has no "version" group`)
+	ErrVersionInvalid        = errors.New("invalid version")
+)
+
+// Version is a semantic version. Versions with less than three numeric
+// parts, e.g. 1.2, are accepted, missing parts are 0.
+type Version struct {
+	Major      uint64
+	Minor      uint64
+	Patch      uint64
+	Prerelease []string
+}
+
+// ParseVersion parses version in MAJOR[.MINOR[.PATCH]][-PRERELEASE][+BUILD]
+// format; build metadata is dropped, as it does not affect precedence.
+func ParseVersion(s string) (*Version, error) {
+	if i := strings.Index(s, "+"); i != -1 {
+		s = s[:i]
+	}
+
+	v := &Version{}
+	if i := strings.Index(s, "-"); i != -1 {
+		v.Prerelease = strings.Split(s[i+1:], ".")
+		for _, id := range v.Prerelease {
+			if id == "" {
+				return nil, ErrVersionInvalid
+			}
+		}
+		s = s[:i]
+	}
+
+	parts := strings.Split(s, ".")
+	if len(parts) > 3 {
+		return nil, ErrVersionInvalid
+	}
+	numbers := []*uint64{&v.Major, &v.Minor, &v.Patch}
+	for i, part := range parts {
+		n, err := strconv.ParseUint(part, 10, 64)
+		if err != nil {
+			return nil, ErrVersionInvalid
+		}
+		*numbers[i] = n
+	}
+
+	return v, nil
+}
+
+// Compare returns -1, 0 or 1 if the version precedes, equals or follows
+// the other one, as defined by semantic versioning: numeric parts are
+// compared first, version with pre-release precedes the one without.
+func (v *Version) Compare(other *Version) int {
+	for _, pair := range [][2]uint64{
+		{v.Major, other.Major},
+		{v.Minor, other.Minor},
+		{v.Patch, other.Patch},
+	} {
+		if c := compareUint(pair[0], pair[1]); c != 0 {
+			return c
+		}
+	}
+
+	switch {
+	case len(v.Prerelease) == 0 && len(other.Prerelease) == 0:
+		return 0
+	case len(v.Prerelease) == 0:
+		return 1
+	case len(other.Prerelease) == 0:
+		return -1
+	}
+
+	for i := 0; i < len(v.Prerelease) && i < len(other.Prerelease); i++ {
+		if c := comparePrereleaseID(v.Prerelease[i], other.Prerelease[i]); c != 0 {
+			return c
+		}
+	}
+	return compareUint(uint64(len(v.Prerelease)), uint64(len(other.Prerelease)))
+}
+
+// comparePrereleaseID compares numeric identifiers numerically, others
+// lexically; numeric identifiers precede the others.
+func comparePrereleaseID(a, b string) int {
+	na, errA := strconv.ParseUint(a, 10, 64)
+	nb, errB := strconv.ParseUint(b, 10, 64)
+	switch {
+	case errA == nil && errB == nil:
+		return compareUint(na, nb)
+	case errA == nil:
+		return -1
+	case errB == nil:
+		return 1
+	}
+	return strings.Compare(a, b)
+}
+
+func compareUint(a, b uint64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// VersionParser extracts versions from artifact names with a pattern,
+// which has to capture the version in the "version" group.
+type VersionParser struct {
+	pattern *regexp.Regexp
+	group   int
+}
+
+// NewVersionParser compiles the pattern, DefaultVersionPattern if empty.
+func NewVersionParser(pattern string) (*VersionParser, error) {
+	if pattern == "" {
+		pattern = DefaultVersionPattern
+	}
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	return newVersionParser(re)
+}
+
+func newVersionParser(re *regexp.Regexp) (*VersionParser, error) {
+	for i, name := range re.SubexpNames() {
+		if name == VersionPatternGroup {
+			return &VersionParser{pattern: re, group: i}, nil
+		}
+	}
+	return nil, ErrVersionPatternNoGroup
+}
+
+// DefaultVersionParser returns parser of DefaultVersionPattern.
+func DefaultVersionParser() *VersionParser {
+	parser, _ := newVersionParser(regexp.MustCompile(DefaultVersionPattern))
+	return parser
+}
+
+// Parse returns version of the artifact name, false if the name has none.
+func (p *VersionParser) Parse(name string) (*Version, bool) {
+	match := p.pattern.FindStringSubmatch(name)
+	if match == nil {
+		return nil, false
+	}
+
+	version, err := ParseVersion(match[p.group])
+	if err != nil {
+		return nil, false
+	}
+	return version, true
+}
+
+// Latest returns the image with the highest version in its name, the most
+// recently modified one of images with the same version. Images without
+// version are skipped, nil is returned if none has one.
+func (p *VersionParser) Latest(images []*SoftwareImage) *SoftwareImage {
+	var latest *SoftwareImage
+	var latestVersion *Version
+
+	for _, image := range images {
+		version, ok := p.Parse(image.Name)
+		if !ok {
+			continue
+		}
+
+		if latest != nil {
+			c := version.Compare(latestVersion)
+			if c < 0 || c == 0 && !modifiedAfter(image, latest) {
+				continue
+			}
+		}
+		latest, latestVersion = image, version
+	}
+
+	return latest
+}
+
+func modifiedAfter(a, b *SoftwareImage) bool {
+	if a.Modified == nil || b.Modified == nil {
+		return a.Modified != nil
+	}
+	return a.Modified.After(*b.Modified)
+}
@@ -0,0 +1,164 @@
+// Copyright 2026 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package images
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseVersion(t *testing.T) {
+	testCases := map[string]*Version{
+		"1.2.3":             {Major: 1, Minor: 2, Patch: 3},
+		"1.2":               {Major: 1, Minor: 2},
+		"7":                 {Major: 7},
+		"1.0.0-rc.1":        {Major: 1, Prerelease: []string{"rc", "1"}},
+		"1.0.0-rc.1+build5": {Major: 1, Prerelease: []string{"rc", "1"}},
+		"2.0.0+build.5":     {Major: 2},
+		"1.2.3.4":           nil,
+		"1.x":               nil,
+		"1.0.0-rc..1":       nil,
+		"":                  nil,
+	}
+
+	for s, expected := range testCases {
+		t.Run(s, func(t *testing.T) {
+			v, err := ParseVersion(s)
+			if expected == nil {
+				assert.Equal(t, ErrVersionInvalid, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, expected, v)
+		})
+	}
+}
+
+func TestVersionCompare(t *testing.T) {
+	// ascending precedence, as in the semantic versioning specification
+	ordered := []string{
+		"1.0.0-alpha",
+		"1.0.0-alpha.1",
+		"1.0.0-alpha.beta",
+		"1.0.0-beta",
+		"1.0.0-beta.2",
+		"1.0.0-beta.11",
+		"1.0.0-rc.1",
+		"1.0.0",
+		"1.0.1",
+		"1.2.0",
+		"1.10.0",
+		"2.0.0",
+	}
+
+	for i := range ordered {
+		a, err := ParseVersion(ordered[i])
+		assert.NoError(t, err)
+		assert.Equal(t, 0, a.Compare(a), ordered[i])
+
+		for j := i + 1; j < len(ordered); j++ {
+			b, err := ParseVersion(ordered[j])
+			assert.NoError(t, err)
+			assert.Equal(t, -1, a.Compare(b), ordered[i]+" < "+ordered[j])
+			assert.Equal(t, 1, b.Compare(a), ordered[j]+" > "+ordered[i])
+		}
+	}
+
+	a, _ := ParseVersion("1.0.0+build.1")
+	b, _ := ParseVersion("1.0.0+build.2")
+	assert.Equal(t, 0, a.Compare(b))
+}
+
+func TestNewVersionParser(t *testing.T) {
+	parser, err := NewVersionParser("")
+	assert.NoError(t, err)
+	assert.Equal(t, DefaultVersionParser(), parser)
+
+	_, err = NewVersionParser(`release-([0-9.]+)`)
+	assert.Equal(t, ErrVersionPatternNoGroup, err)
+
+	_, err = NewVersionParser(`release-(?P<version>[0-9.]+`)
+	assert.EqualError(t, err, "error parsing regexp: missing closing ): `release-(?P<version>[0-9.]+`")
+}
+
+func TestVersionParserParse(t *testing.T) {
+	parser, _ := NewVersionParser("")
+
+	testCases := map[string]*Version{
+		"app-1.2.3":              {Major: 1, Minor: 2, Patch: 3},
+		"app-v2.0.0-rc.1+b.5":    {Major: 2, Prerelease: []string{"rc", "1"}},
+		"1.0.0":                  {Major: 1},
+		"app_1.0.0":              {Major: 1},
+		"app-1.2":                nil,
+		"app-1.02.3":             nil,
+		"app-1.1.2.3":            nil,
+		"app-1.2.3-linux-x86_64": nil,
+		"app":                    nil,
+	}
+	for name, expected := range testCases {
+		t.Run(name, func(t *testing.T) {
+			v, ok := parser.Parse(name)
+			assert.Equal(t, expected != nil, ok)
+			assert.Equal(t, expected, v)
+		})
+	}
+
+	// custom pattern
+	parser, err := NewVersionParser(`^release (?P<version>[0-9]+\.[0-9]+) `)
+	assert.NoError(t, err)
+	v, ok := parser.Parse("release 4.10 (stable)")
+	assert.True(t, ok)
+	assert.Equal(t, &Version{Major: 4, Minor: 10}, v)
+	_, ok = parser.Parse("release 4.x (stable)")
+	assert.False(t, ok)
+}
+
+func TestVersionParserLatest(t *testing.T) {
+	parser, _ := NewVersionParser("")
+
+	older := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	newer := older.Add(time.Hour)
+	image := func(id, name string, modified *time.Time) *SoftwareImage {
+		return &SoftwareImage{
+			Id: id,
+			SoftwareImageMetaArtifactConstructor: SoftwareImageMetaArtifactConstructor{
+				Name: name,
+			},
+			Modified: modified,
+		}
+	}
+
+	assert.Nil(t, parser.Latest(nil))
+	assert.Nil(t, parser.Latest([]*SoftwareImage{image("1", "app-latest", &newer)}))
+
+	latest := parser.Latest([]*SoftwareImage{
+		image("1", "app-1.9.0", &newer),
+		image("2", "app-1.10.0-rc.1", &newer),
+		image("3", "app-latest", &newer),
+		image("4", "app-1.10.0", &older),
+		image("5", "app-1.2.0", &newer),
+	})
+	assert.Equal(t, "4", latest.Id)
+
+	// the most recently modified one of the same version
+	latest = parser.Latest([]*SoftwareImage{
+		image("1", "app-1.0.0", &older),
+		image("2", "app-v1.0.0", &newer),
+		image("3", "app-1.0.0+rebuild", nil),
+	})
+	assert.Equal(t, "2", latest.Id)
+}
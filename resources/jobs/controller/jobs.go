@@ -0,0 +1,81 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package controller
+
+import (
+	"net/http"
+
+	"github.com/ant0ine/go-json-rest/rest"
+	"github.com/mendersoftware/go-lib-micro/requestlog"
+	"github.com/mendersoftware/go-lib-micro/rest_utils"
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/deployments/resources/jobs"
+)
+
+type JobsController struct {
+	view  RESTView
+	model JobsModel
+}
+
+func NewJobsController(model JobsModel, view RESTView) *JobsController {
+	return &JobsController{
+		model: model,
+		view:  view,
+	}
+}
+
+// ListJobs lists background jobs for inspection, optionally filtered
+// by type and status.
+func (c *JobsController) ListJobs(w rest.ResponseWriter, r *rest.Request) {
+	l := requestlog.GetRequestLogger(r)
+
+	query := jobs.Query{
+		Type:   r.URL.Query().Get("type"),
+		Status: r.URL.Query().Get("status"),
+	}
+	if query.Status != "" && !jobs.IsValidStatus(query.Status) {
+		c.view.RenderError(w, r,
+			errors.Errorf("unsupported status %s", query.Status),
+			http.StatusBadRequest, l)
+		return
+	}
+
+	page, perPage, err := rest_utils.ParsePagination(r)
+	if err != nil {
+		c.view.RenderError(w, r, err, http.StatusBadRequest, l)
+		return
+	}
+	query.Skip = int((page - 1) * perPage)
+	query.Limit = int(perPage + 1)
+
+	list, err := c.model.ListJobs(r.Context(), query)
+	if err != nil {
+		c.view.RenderInternalError(w, r, err, l)
+		return
+	}
+
+	hasNext := false
+	if uint64(len(list)) > perPage {
+		hasNext = true
+		list = list[:perPage]
+	}
+
+	for _, link := range rest_utils.MakePageLinkHdrs(r, page, perPage, hasNext) {
+		w.Header().Add("Link", link)
+	}
+
+	c.view.RenderCollection(w, r, list)
+}
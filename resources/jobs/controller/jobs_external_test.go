@@ -0,0 +1,145 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package controller_test
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/ant0ine/go-json-rest/rest"
+	"github.com/ant0ine/go-json-rest/rest/test"
+	"github.com/mendersoftware/go-lib-micro/requestid"
+	"github.com/mendersoftware/go-lib-micro/requestlog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/mendersoftware/deployments/resources/jobs"
+	. "github.com/mendersoftware/deployments/resources/jobs/controller"
+	"github.com/mendersoftware/deployments/resources/jobs/controller/mocks"
+	"github.com/mendersoftware/deployments/utils/restutil/view"
+)
+
+func contextMatcher() interface{} {
+	return mock.MatchedBy(func(_ context.Context) bool {
+		return true
+	})
+}
+
+func setUpRestTest(route string, handler rest.HandlerFunc) *rest.Api {
+	router, _ := rest.MakeRouter(rest.Get(route, handler))
+	api := rest.NewApi()
+	api.Use(
+		&requestlog.RequestLogMiddleware{
+			BaseLogger: &logrus.Logger{Out: ioutil.Discard},
+		},
+		&requestid.RequestIdMiddleware{},
+	)
+	api.SetApp(router)
+
+	return api
+}
+
+func TestListJobs(t *testing.T) {
+	created := time.Date(2018, 1, 2, 3, 4, 5, 0, time.UTC)
+	job := jobs.Job{
+		Id:          "1",
+		Type:        "foo",
+		Payload:     []byte(`{"id":"bar"}`),
+		Status:      jobs.StatusDead,
+		Attempts:    5,
+		MaxAttempts: 5,
+		NextRun:     created,
+		LastError:   "failed",
+		Created:     created,
+	}
+
+	testCases := map[string]struct {
+		url   string
+		query *jobs.Query
+		list  []jobs.Job
+		err   error
+
+		code  int
+		body  string
+		error string
+		link  bool
+	}{
+		"ok": {
+			url:   "/jobs?type=foo&status=dead",
+			query: &jobs.Query{Type: "foo", Status: jobs.StatusDead, Limit: 21},
+			list:  []jobs.Job{job},
+			code:  http.StatusOK,
+			body: `[{"id":"1","type":"foo","payload":{"id":"bar"},` +
+				`"status":"dead","attempts":5,"max_attempts":5,` +
+				`"next_run":"2018-01-02T03:04:05Z","last_error":"failed",` +
+				`"created":"2018-01-02T03:04:05Z"}]`,
+		},
+		"next page": {
+			url:   "/jobs?per_page=1",
+			query: &jobs.Query{Limit: 2},
+			list:  []jobs.Job{job, job},
+			code:  http.StatusOK,
+			link:  true,
+		},
+		"bad status": {
+			url:   "/jobs?status=foo",
+			code:  http.StatusBadRequest,
+			error: "unsupported status foo",
+		},
+		"bad pagination": {
+			url:  "/jobs?page=foo",
+			code: http.StatusBadRequest,
+		},
+		"model error": {
+			url:   "/jobs",
+			query: &jobs.Query{Limit: 21},
+			err:   errors.New("db failed"),
+			code:  http.StatusInternalServerError,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			model := &mocks.JobsModel{}
+			if tc.query != nil {
+				model.On("ListJobs", contextMatcher(), *tc.query).
+					Return(tc.list, tc.err)
+			}
+
+			controller := NewJobsController(model, new(view.RESTView))
+			api := setUpRestTest("/jobs", controller.ListJobs)
+
+			recorded := test.RunRequest(t, api.MakeHandler(),
+				test.MakeSimpleRequest("GET", "http://localhost"+tc.url, nil))
+			recorded.CodeIs(tc.code)
+			if tc.body != "" {
+				assert.JSONEq(t, tc.body, recorded.Recorder.Body.String())
+			}
+			if tc.error != "" {
+				assert.Contains(t, recorded.Recorder.Body.String(), tc.error)
+			}
+			if tc.link {
+				assert.Contains(t, recorded.Recorder.Header().Get("Link"), "next")
+			}
+
+			model.AssertExpectations(t)
+		})
+	}
+}
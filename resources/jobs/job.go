@@ -0,0 +1,104 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package jobs
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/satori/go.uuid"
+)
+
+// Job statuses
+const (
+	// waiting for a worker, either first run or retry
+	StatusQueued = "queued"
+	// claimed by a worker
+	StatusRunning = "running"
+	// handler succeeded
+	StatusDone = "done"
+	// all attempts failed, job is kept for inspection only
+	StatusDead = "dead"
+)
+
+var (
+	ValidStatuses = []string{StatusQueued, StatusRunning, StatusDone, StatusDead}
+)
+
+// Job is a unit of background work persisted in the job queue.
+type Job struct {
+	Id   string `json:"id" bson:"_id"`
+	Type string `json:"type" bson:"type"`
+
+	// Identity of the request which created the job, restored in the
+	// context passed to the handler
+	TenantID  string `json:"tenant_id,omitempty" bson:"tenant_id,omitempty"`
+	RequestID string `json:"request_id,omitempty" bson:"request_id,omitempty"`
+
+	// Handler specific arguments, JSON encoded
+	Payload json.RawMessage `json:"payload" bson:"payload"`
+
+	Status      string `json:"status" bson:"status"`
+	Attempts    int    `json:"attempts" bson:"attempts"`
+	MaxAttempts int    `json:"max_attempts" bson:"max_attempts"`
+
+	// Earliest time of the next attempt; for running jobs the time the
+	// worker lease expires and the job can be claimed again
+	NextRun time.Time `json:"next_run" bson:"next_run"`
+
+	// Error returned by the last failed attempt
+	LastError string `json:"last_error,omitempty" bson:"last_error,omitempty"`
+
+	Created  time.Time  `json:"created" bson:"created"`
+	Finished *time.Time `json:"finished,omitempty" bson:"finished,omitempty"`
+}
+
+// NewJob creates job of given type ready to be run immediately.
+func NewJob(jobType string, payload interface{}, maxAttempts int) (*Job, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+
+	return &Job{
+		Id:          uuid.NewV4().String(),
+		Type:        jobType,
+		Payload:     data,
+		Status:      StatusQueued,
+		MaxAttempts: maxAttempts,
+		NextRun:     now,
+		Created:     now,
+	}, nil
+}
+
+// IsValidStatus checks if status is one of the known job statuses.
+func IsValidStatus(status string) bool {
+	for _, s := range ValidStatuses {
+		if status == s {
+			return true
+		}
+	}
+	return false
+}
+
+// Query selects jobs listed for inspection.
+type Query struct {
+	Type   string
+	Status string
+	Limit  int
+	Skip   int
+}
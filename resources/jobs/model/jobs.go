@@ -0,0 +1,230 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/mendersoftware/go-lib-micro/identity"
+	"github.com/mendersoftware/go-lib-micro/log"
+	"github.com/mendersoftware/go-lib-micro/requestid"
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/deployments/resources/jobs"
+)
+
+// Defaults used for zero Config values
+const (
+	DefaultWorkers      = 2
+	DefaultMaxAttempts  = 5
+	DefaultPollInterval = time.Second
+	DefaultBackoff      = 10 * time.Second
+	DefaultLease        = 10 * time.Minute
+)
+
+var (
+	ErrJobHandlerMissing = errors.New("no handler registered for job type")
+)
+
+// Config tunes job processing.
+type Config struct {
+	// Number of jobs processed concurrently
+	Workers int
+	// Attempts before the job is moved to dead-letter
+	MaxAttempts int
+	// Delay between polls of the empty queue
+	PollInterval time.Duration
+	// Delay before the first retry, doubled on every following one
+	Backoff time.Duration
+	// Time after which job claimed by a crashed worker is run again
+	Lease time.Duration
+}
+
+// Handler runs the job, payload is JSON encoded argument passed to Enqueue.
+// Context carries tenant identity and request ID of the enqueuing request.
+// Returned error schedules a retry.
+type Handler func(ctx context.Context, payload json.RawMessage) error
+
+// JobsModel is a mongo backed background job queue with retries and
+// dead-letter, replacing work done in ad-hoc goroutines.
+type JobsModel struct {
+	storage JobsStorage
+	config  Config
+
+	lock     sync.RWMutex
+	handlers map[string]Handler
+}
+
+func NewJobsModel(storage JobsStorage, config Config) *JobsModel {
+	if config.Workers <= 0 {
+		config.Workers = DefaultWorkers
+	}
+	if config.MaxAttempts <= 0 {
+		config.MaxAttempts = DefaultMaxAttempts
+	}
+	if config.PollInterval <= 0 {
+		config.PollInterval = DefaultPollInterval
+	}
+	if config.Backoff <= 0 {
+		config.Backoff = DefaultBackoff
+	}
+	if config.Lease <= 0 {
+		config.Lease = DefaultLease
+	}
+
+	return &JobsModel{
+		storage:  storage,
+		config:   config,
+		handlers: map[string]Handler{},
+	}
+}
+
+// Register sets handler for jobs of given type.
+func (m *JobsModel) Register(jobType string,
+	handler func(ctx context.Context, payload json.RawMessage) error) {
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.handlers[jobType] = handler
+}
+
+func (m *JobsModel) handler(jobType string) Handler {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	return m.handlers[jobType]
+}
+
+// Enqueue stores job to be run by one of the workers.
+func (m *JobsModel) Enqueue(ctx context.Context, jobType string, payload interface{}) error {
+	job, err := jobs.NewJob(jobType, payload, m.config.MaxAttempts)
+	if err != nil {
+		return errors.Wrap(err, "serializing job payload")
+	}
+
+	if id := identity.FromContext(ctx); id != nil {
+		job.TenantID = id.Tenant
+	}
+	job.RequestID = requestid.FromContext(ctx)
+
+	if err := m.storage.InsertJob(ctx, job); err != nil {
+		return errors.Wrap(err, "storing job")
+	}
+	return nil
+}
+
+// ListJobs returns jobs matching the query, newest first.
+func (m *JobsModel) ListJobs(ctx context.Context, query jobs.Query) ([]jobs.Job, error) {
+	list, err := m.storage.FindJobs(ctx, query)
+	if err != nil {
+		return nil, errors.Wrap(err, "searching for jobs")
+	}
+	return list, nil
+}
+
+// Start launches workers processing the queue until ctx is done.
+func (m *JobsModel) Start(ctx context.Context) {
+	for i := 0; i < m.config.Workers; i++ {
+		go m.work(ctx)
+	}
+}
+
+func (m *JobsModel) work(ctx context.Context) {
+	l := log.FromContext(ctx)
+
+	for {
+		ran, err := m.RunNext(ctx)
+		if err != nil {
+			l.Errorf("running job: %s", err.Error())
+		}
+		if ran && err == nil {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(m.config.PollInterval):
+		}
+	}
+}
+
+// RunNext claims and runs single job, recording its result.
+// Returns false if the queue had no runnable job.
+func (m *JobsModel) RunNext(ctx context.Context) (bool, error) {
+	job, err := m.storage.ClaimJob(ctx, time.Now(), m.config.Lease)
+	if err != nil {
+		return false, errors.Wrap(err, "claiming job")
+	}
+	if job == nil {
+		return false, nil
+	}
+
+	m.finish(job, m.run(ctx, job))
+
+	if err := m.storage.UpdateJob(ctx, job); err != nil {
+		return true, errors.Wrapf(err, "saving job %s", job.Id)
+	}
+	return true, nil
+}
+
+func (m *JobsModel) run(ctx context.Context, job *jobs.Job) error {
+	handler := m.handler(job.Type)
+	if handler == nil {
+		return ErrJobHandlerMissing
+	}
+
+	if job.TenantID != "" {
+		ctx = identity.WithContext(ctx, &identity.Identity{Tenant: job.TenantID})
+	}
+	if job.RequestID != "" {
+		ctx = requestid.WithContext(ctx, job.RequestID)
+	}
+	ctx = log.WithContext(ctx, log.FromContext(ctx).F(log.Ctx{
+		"job_id":   job.Id,
+		"job_type": job.Type,
+	}))
+
+	return handler(ctx, job.Payload)
+}
+
+// finish updates job state after an attempt; failed jobs are retried with
+// exponential backoff until out of attempts, then moved to dead-letter.
+func (m *JobsModel) finish(job *jobs.Job, err error) {
+	now := time.Now()
+
+	if err == nil {
+		job.Status = jobs.StatusDone
+		job.Finished = &now
+		return
+	}
+
+	job.LastError = err.Error()
+	if job.Attempts >= job.MaxAttempts || err == ErrJobHandlerMissing {
+		job.Status = jobs.StatusDead
+		job.Finished = &now
+		return
+	}
+
+	backoff := m.config.Backoff
+	for i := 1; i < job.Attempts; i++ {
+		backoff *= 2
+	}
+
+	job.Status = jobs.StatusQueued
+	job.NextRun = now.Add(backoff)
+}
@@ -0,0 +1,31 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"context"
+	"time"
+
+	"github.com/mendersoftware/deployments/resources/jobs"
+)
+
+type JobsStorage interface {
+	InsertJob(ctx context.Context, job *jobs.Job) error
+	// ClaimJob atomically marks the oldest runnable job as running and
+	// leases it until now+lease. Returns nil if there is nothing to run.
+	ClaimJob(ctx context.Context, now time.Time, lease time.Duration) (*jobs.Job, error)
+	UpdateJob(ctx context.Context, job *jobs.Job) error
+	FindJobs(ctx context.Context, query jobs.Query) ([]jobs.Job, error)
+}
@@ -0,0 +1,185 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mendersoftware/go-lib-micro/identity"
+	"github.com/mendersoftware/go-lib-micro/requestid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/mendersoftware/deployments/resources/jobs"
+	"github.com/mendersoftware/deployments/resources/jobs/model/mocks"
+)
+
+func TestEnqueue(t *testing.T) {
+	storage := &mocks.JobsStorage{}
+	m := NewJobsModel(storage, Config{MaxAttempts: 3})
+
+	ctx := identity.WithContext(context.Background(), &identity.Identity{Tenant: "foo"})
+	ctx = requestid.WithContext(ctx, "req")
+
+	storage.On("InsertJob", ctx, mock.MatchedBy(func(job *jobs.Job) bool {
+		return job.Type == "bar" &&
+			job.TenantID == "foo" &&
+			job.RequestID == "req" &&
+			job.Status == jobs.StatusQueued &&
+			job.MaxAttempts == 3 &&
+			string(job.Payload) == `{"id":"baz"}`
+	})).Return(nil).Once()
+	assert.NoError(t, m.Enqueue(ctx, "bar", map[string]string{"id": "baz"}))
+
+	storage.On("InsertJob", ctx, mock.AnythingOfType("*jobs.Job")).
+		Return(errors.New("db failed")).Once()
+	assert.EqualError(t, m.Enqueue(ctx, "bar", nil), "storing job: db failed")
+
+	storage.AssertExpectations(t)
+}
+
+func TestRunNext(t *testing.T) {
+	handlerErr := errors.New("handler failed")
+
+	testCases := map[string]struct {
+		job        *jobs.Job
+		claimErr   error
+		handlerErr error
+		updateErr  error
+
+		ran       bool
+		err       string
+		status    string
+		lastError string
+		backoff   time.Duration
+	}{
+		"empty queue": {},
+		"claim error": {
+			claimErr: errors.New("db failed"),
+			err:      "claiming job: db failed",
+		},
+		"done": {
+			job:    &jobs.Job{Id: "1", Type: "foo", Attempts: 1, MaxAttempts: 3},
+			ran:    true,
+			status: jobs.StatusDone,
+		},
+		"retry": {
+			job:        &jobs.Job{Id: "1", Type: "foo", Attempts: 2, MaxAttempts: 3},
+			handlerErr: handlerErr,
+			ran:        true,
+			status:     jobs.StatusQueued,
+			lastError:  "handler failed",
+			backoff:    2 * time.Minute,
+		},
+		"dead": {
+			job:        &jobs.Job{Id: "1", Type: "foo", Attempts: 3, MaxAttempts: 3},
+			handlerErr: handlerErr,
+			ran:        true,
+			status:     jobs.StatusDead,
+			lastError:  "handler failed",
+		},
+		"no handler": {
+			job:       &jobs.Job{Id: "1", Type: "bar", Attempts: 1, MaxAttempts: 3},
+			ran:       true,
+			status:    jobs.StatusDead,
+			lastError: ErrJobHandlerMissing.Error(),
+		},
+		"update error": {
+			job:       &jobs.Job{Id: "1", Type: "foo", Attempts: 1, MaxAttempts: 3},
+			updateErr: errors.New("db failed"),
+			ran:       true,
+			err:       "saving job 1: db failed",
+			status:    jobs.StatusDone,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			storage := &mocks.JobsStorage{}
+			m := NewJobsModel(storage, Config{
+				Backoff: time.Minute,
+				Lease:   time.Hour,
+			})
+			m.Register("foo", func(ctx context.Context, payload json.RawMessage) error {
+				return tc.handlerErr
+			})
+
+			storage.On("ClaimJob", context.Background(),
+				mock.AnythingOfType("time.Time"), time.Hour).
+				Return(tc.job, tc.claimErr)
+			if tc.job != nil {
+				storage.On("UpdateJob", context.Background(), tc.job).
+					Return(tc.updateErr)
+			}
+
+			start := time.Now()
+			ran, err := m.RunNext(context.Background())
+			assert.Equal(t, tc.ran, ran)
+			if tc.err != "" {
+				assert.EqualError(t, err, tc.err)
+			} else {
+				assert.NoError(t, err)
+			}
+
+			if tc.job != nil {
+				assert.Equal(t, tc.status, tc.job.Status)
+				assert.Equal(t, tc.lastError, tc.job.LastError)
+				if tc.status == jobs.StatusQueued {
+					assert.Nil(t, tc.job.Finished)
+					assert.WithinDuration(t, start.Add(tc.backoff),
+						tc.job.NextRun, time.Second)
+				} else {
+					assert.NotNil(t, tc.job.Finished)
+				}
+			}
+			storage.AssertExpectations(t)
+		})
+	}
+}
+
+func TestRunNextContext(t *testing.T) {
+	storage := &mocks.JobsStorage{}
+	m := NewJobsModel(storage, Config{})
+
+	job := &jobs.Job{
+		Id:          "1",
+		Type:        "foo",
+		TenantID:    "tenant",
+		RequestID:   "req",
+		Payload:     json.RawMessage(`{"id":"bar"}`),
+		Attempts:    1,
+		MaxAttempts: 1,
+	}
+	storage.On("ClaimJob", context.Background(),
+		mock.AnythingOfType("time.Time"), DefaultLease).
+		Return(job, nil)
+	storage.On("UpdateJob", context.Background(), job).Return(nil)
+
+	m.Register("foo", func(ctx context.Context, payload json.RawMessage) error {
+		assert.Equal(t, &identity.Identity{Tenant: "tenant"}, identity.FromContext(ctx))
+		assert.Equal(t, "req", requestid.FromContext(ctx))
+		assert.JSONEq(t, `{"id":"bar"}`, string(payload))
+		return nil
+	})
+
+	ran, err := m.RunNext(context.Background())
+	assert.True(t, ran)
+	assert.NoError(t, err)
+	assert.Equal(t, jobs.StatusDone, job.Status)
+}
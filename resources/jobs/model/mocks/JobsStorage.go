@@ -0,0 +1,99 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package mocks
+
+import context "context"
+import jobs "github.com/mendersoftware/deployments/resources/jobs"
+import mock "github.com/stretchr/testify/mock"
+import time "time"
+
+// JobsStorage is an autogenerated mock type for the JobsStorage type
+type JobsStorage struct {
+	mock.Mock
+}
+
+// ClaimJob provides a mock function with given fields: ctx, now, lease
+func (_m *JobsStorage) ClaimJob(ctx context.Context, now time.Time, lease time.Duration) (*jobs.Job, error) {
+	ret := _m.Called(ctx, now, lease)
+
+	var r0 *jobs.Job
+	if rf, ok := ret.Get(0).(func(context.Context, time.Time, time.Duration) *jobs.Job); ok {
+		r0 = rf(ctx, now, lease)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*jobs.Job)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, time.Time, time.Duration) error); ok {
+		r1 = rf(ctx, now, lease)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// FindJobs provides a mock function with given fields: ctx, query
+func (_m *JobsStorage) FindJobs(ctx context.Context, query jobs.Query) ([]jobs.Job, error) {
+	ret := _m.Called(ctx, query)
+
+	var r0 []jobs.Job
+	if rf, ok := ret.Get(0).(func(context.Context, jobs.Query) []jobs.Job); ok {
+		r0 = rf(ctx, query)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]jobs.Job)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, jobs.Query) error); ok {
+		r1 = rf(ctx, query)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// InsertJob provides a mock function with given fields: ctx, job
+func (_m *JobsStorage) InsertJob(ctx context.Context, job *jobs.Job) error {
+	ret := _m.Called(ctx, job)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *jobs.Job) error); ok {
+		r0 = rf(ctx, job)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// UpdateJob provides a mock function with given fields: ctx, job
+func (_m *JobsStorage) UpdateJob(ctx context.Context, job *jobs.Job) error {
+	ret := _m.Called(ctx, job)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *jobs.Job) error); ok {
+		r0 = rf(ctx, job)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
@@ -0,0 +1,180 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package mongo
+
+import (
+	"context"
+	"time"
+
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+
+	"github.com/mendersoftware/deployments/resources/jobs"
+)
+
+// Database
+//
+// Jobs of all tenants share single queue in the main database, so that
+// workers do not need to know about tenant databases. Tenant is recorded
+// in the job itself.
+const (
+	DatabaseName   = "deployment_service"
+	CollectionJobs = "jobs"
+)
+
+// Keys
+const (
+	StorageKeyJobType     = "type"
+	StorageKeyJobStatus   = "status"
+	StorageKeyJobAttempts = "attempts"
+	StorageKeyJobNextRun  = "next_run"
+	StorageKeyJobCreated  = "created"
+	StorageKeyJobFinished = "finished"
+)
+
+// Indexes
+const (
+	IndexJobsClaimStr       = "jobsClaimIndex"
+	IndexJobsCreatedStr     = "jobsCreatedIndex"
+	IndexJobsExpireStr      = "jobsExpireIndex"
+	FinishedJobsExpireAfter = 7 * 24 * time.Hour
+)
+
+// JobsStorage is a data layer for the background job queue based on MongoDB
+// Implements model.JobsStorage
+type JobsStorage struct {
+	session *mgo.Session
+}
+
+func NewJobsStorage(session *mgo.Session) *JobsStorage {
+	return &JobsStorage{
+		session: session,
+	}
+}
+
+// Runnable jobs are looked up by status and next run time, listed newest
+// first; finished (done and dead) jobs are removed after a week.
+func (s *JobsStorage) ensureIndexing(session *mgo.Session) error {
+	for _, index := range []mgo.Index{
+		{
+			Key:        []string{StorageKeyJobStatus, StorageKeyJobNextRun},
+			Name:       IndexJobsClaimStr,
+			Background: true,
+		},
+		{
+			Key:        []string{"-" + StorageKeyJobCreated},
+			Name:       IndexJobsCreatedStr,
+			Background: true,
+		},
+		{
+			Key:         []string{StorageKeyJobFinished},
+			Name:        IndexJobsExpireStr,
+			ExpireAfter: FinishedJobsExpireAfter,
+			Background:  true,
+		},
+	} {
+		if err := session.DB(DatabaseName).C(CollectionJobs).
+			EnsureIndex(index); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *JobsStorage) InsertJob(ctx context.Context, job *jobs.Job) error {
+	session := s.session.Copy()
+	defer session.Close()
+
+	if err := s.ensureIndexing(session); err != nil {
+		return err
+	}
+
+	return session.DB(DatabaseName).C(CollectionJobs).Insert(job)
+}
+
+// ClaimJob picks queued job due to run, or running one with expired lease
+// (its worker has died), marks it as running and counts the attempt.
+func (s *JobsStorage) ClaimJob(ctx context.Context,
+	now time.Time, lease time.Duration) (*jobs.Job, error) {
+
+	session := s.session.Copy()
+	defer session.Close()
+
+	query := bson.M{
+		StorageKeyJobStatus: bson.M{
+			"$in": []string{jobs.StatusQueued, jobs.StatusRunning},
+		},
+		StorageKeyJobNextRun: bson.M{
+			"$lte": now,
+		},
+	}
+	change := mgo.Change{
+		Update: bson.M{
+			"$set": bson.M{
+				StorageKeyJobStatus:  jobs.StatusRunning,
+				StorageKeyJobNextRun: now.Add(lease),
+			},
+			"$inc": bson.M{
+				StorageKeyJobAttempts: 1,
+			},
+		},
+		ReturnNew: true,
+	}
+
+	var job jobs.Job
+	if _, err := session.DB(DatabaseName).C(CollectionJobs).
+		Find(query).Sort(StorageKeyJobNextRun).
+		Apply(change, &job); err != nil {
+		if err == mgo.ErrNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &job, nil
+}
+
+func (s *JobsStorage) UpdateJob(ctx context.Context, job *jobs.Job) error {
+	session := s.session.Copy()
+	defer session.Close()
+
+	return session.DB(DatabaseName).C(CollectionJobs).UpdateId(job.Id, job)
+}
+
+// FindJobs returns jobs matching the query, newest first.
+func (s *JobsStorage) FindJobs(ctx context.Context, match jobs.Query) ([]jobs.Job, error) {
+	session := s.session.Copy()
+	defer session.Close()
+
+	query := bson.M{}
+	if match.Type != "" {
+		query[StorageKeyJobType] = match.Type
+	}
+	if match.Status != "" {
+		query[StorageKeyJobStatus] = match.Status
+	}
+
+	var list []jobs.Job
+	err := session.DB(DatabaseName).C(CollectionJobs).
+		Find(query).Sort("-" + StorageKeyJobCreated).
+		Skip(match.Skip).Limit(match.Limit).
+		All(&list)
+	if err != nil {
+		return nil, err
+	}
+
+	return list, nil
+}
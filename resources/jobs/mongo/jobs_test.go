@@ -0,0 +1,94 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package mongo
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mendersoftware/deployments/resources/jobs"
+)
+
+func TestJobsQueue(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping TestJobsQueue in short mode.")
+	}
+
+	db.Wipe()
+	store := NewJobsStorage(db.Session())
+	ctx := context.Background()
+
+	now := time.Now().Round(time.Millisecond)
+
+	first, err := jobs.NewJob("foo", map[string]string{"a": "b"}, 3)
+	assert.NoError(t, err)
+	first.NextRun = now.Add(-time.Minute)
+	second, err := jobs.NewJob("bar", nil, 3)
+	assert.NoError(t, err)
+	second.NextRun = now.Add(-time.Second)
+	later, err := jobs.NewJob("foo", nil, 3)
+	assert.NoError(t, err)
+	later.NextRun = now.Add(time.Hour)
+
+	for _, job := range []*jobs.Job{first, second, later} {
+		assert.NoError(t, store.InsertJob(ctx, job))
+	}
+
+	// due jobs are claimed in order of next run
+	job, err := store.ClaimJob(ctx, now, time.Minute)
+	assert.NoError(t, err)
+	if assert.NotNil(t, job) {
+		assert.Equal(t, first.Id, job.Id)
+		assert.Equal(t, jobs.StatusRunning, job.Status)
+		assert.Equal(t, 1, job.Attempts)
+		assert.JSONEq(t, `{"a":"b"}`, string(job.Payload))
+	}
+
+	job, err = store.ClaimJob(ctx, now, time.Minute)
+	assert.NoError(t, err)
+	if assert.NotNil(t, job) {
+		assert.Equal(t, second.Id, job.Id)
+	}
+
+	// nothing is due until lease of the running jobs expires
+	job, err = store.ClaimJob(ctx, now, time.Minute)
+	assert.NoError(t, err)
+	assert.Nil(t, job)
+
+	job, err = store.ClaimJob(ctx, now.Add(2*time.Minute), time.Minute)
+	assert.NoError(t, err)
+	if assert.NotNil(t, job) {
+		assert.Equal(t, first.Id, job.Id)
+		assert.Equal(t, 2, job.Attempts)
+
+		job.Status = jobs.StatusDead
+		job.LastError = "failed"
+		assert.NoError(t, store.UpdateJob(ctx, job))
+	}
+
+	list, err := store.FindJobs(ctx, jobs.Query{Status: jobs.StatusDead})
+	assert.NoError(t, err)
+	if assert.Len(t, list, 1) {
+		assert.Equal(t, first.Id, list[0].Id)
+		assert.Equal(t, "failed", list[0].LastError)
+	}
+
+	list, err = store.FindJobs(ctx, jobs.Query{Type: "foo"})
+	assert.NoError(t, err)
+	assert.Len(t, list, 2)
+}
@@ -15,13 +15,16 @@
 package controller
 
 import (
+	"context"
 	"net/http"
 
 	"github.com/ant0ine/go-json-rest/rest"
+	"github.com/mendersoftware/go-lib-micro/identity"
 	"github.com/mendersoftware/go-lib-micro/requestlog"
 	"github.com/pkg/errors"
 
 	"github.com/mendersoftware/deployments/resources/limits"
+	"github.com/mendersoftware/deployments/utils/restutil"
 )
 
 var ()
@@ -41,9 +44,16 @@ func NewLimitsController(model LimitsModel, view RESTView) *LimitsController {
 type limitResponse struct {
 	Limit uint64 `json:"limit"`
 	Usage uint64 `json:"usage"`
+	Grace string `json:"grace,omitempty"`
 }
 
 func (s *LimitsController) GetLimit(w rest.ResponseWriter, r *rest.Request) {
+	s.getLimit(w, r, r.Context())
+}
+
+func (s *LimitsController) getLimit(w rest.ResponseWriter, r *rest.Request,
+	ctx context.Context) {
+
 	l := requestlog.GetRequestLogger(r)
 
 	name := r.PathParam("name")
@@ -55,7 +65,7 @@ func (s *LimitsController) GetLimit(w rest.ResponseWriter, r *rest.Request) {
 		return
 	}
 
-	limit, err := s.model.GetLimit(r.Context(), name)
+	limit, err := s.model.GetLimit(ctx, name)
 	if err != nil {
 		s.view.RenderInternalError(w, r, err, l)
 		return
@@ -64,5 +74,66 @@ func (s *LimitsController) GetLimit(w rest.ResponseWriter, r *rest.Request) {
 	s.view.RenderSuccessGet(w, limitResponse{
 		Limit: limit.Value,
 		Usage: 0, // TODO fill this when ready
+		Grace: limit.Grace,
+	})
+}
+
+type limitRequest struct {
+	Limit *uint64 `json:"limit"`
+	Grace string  `json:"grace"`
+}
+
+// GetTenantLimit returns the limit of the tenant given in the path.
+func (s *LimitsController) GetTenantLimit(w rest.ResponseWriter, r *rest.Request) {
+	ctx := identity.WithContext(r.Context(),
+		&identity.Identity{Tenant: r.PathParam("tenant")})
+	s.getLimit(w, r, ctx)
+}
+
+// SetTenantLimit sets the limit of the tenant given in the path.
+func (s *LimitsController) SetTenantLimit(w rest.ResponseWriter, r *rest.Request) {
+	l := requestlog.GetRequestLogger(r)
+
+	name := r.PathParam("name")
+
+	if !limits.IsValidLimit(name) {
+		s.view.RenderError(w, r,
+			errors.Errorf("unsupported limit %s", name),
+			http.StatusBadRequest, l)
+		return
+	}
+
+	var req limitRequest
+	if err := restutil.DecodeJSONPayload(r, &req); err != nil {
+		s.view.RenderError(w, r, errors.Wrap(err, "Validating request body"),
+			http.StatusBadRequest, l)
+		return
+	}
+	if req.Limit == nil {
+		s.view.RenderError(w, r, errors.New("Validating request body: missing limit"),
+			http.StatusBadRequest, l)
+		return
+	}
+	if !limits.IsValidGrace(name, req.Grace) {
+		s.view.RenderError(w, r,
+			errors.Errorf("Validating request body: unsupported grace %s of limit %s",
+				req.Grace, name),
+			http.StatusBadRequest, l)
+		return
+	}
+
+	ctx := identity.WithContext(r.Context(),
+		&identity.Identity{Tenant: r.PathParam("tenant")})
+
+	err := s.model.SetLimit(ctx, &limits.Limit{
+		Name:  name,
+		Value: *req.Limit,
+		Grace: req.Grace,
 	})
+	if err != nil {
+		s.view.RenderInternalError(w, r, err, l)
+		return
+	}
+
+	s.view.RenderSuccessPut(w)
 }
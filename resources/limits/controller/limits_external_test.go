@@ -25,6 +25,7 @@ import (
 	"github.com/Sirupsen/logrus"
 	"github.com/ant0ine/go-json-rest/rest"
 	"github.com/ant0ine/go-json-rest/rest/test"
+	"github.com/mendersoftware/go-lib-micro/identity"
 	"github.com/mendersoftware/go-lib-micro/requestid"
 	"github.com/mendersoftware/go-lib-micro/requestlog"
 	"github.com/stretchr/testify/assert"
@@ -78,6 +79,16 @@ func TestGetLimits(t *testing.T) {
 				Value: 200,
 			},
 		},
+		{
+			name: limits.LimitDevicesPerDeployment,
+			code: http.StatusOK,
+			body: `{"limit":100,"usage":0,"grace":"warn"}`,
+			limit: &limits.Limit{
+				Name:  limits.LimitDevicesPerDeployment,
+				Value: 100,
+				Grace: limits.GraceWarn,
+			},
+		},
 		{
 			name: "storage",
 			code: http.StatusInternalServerError,
@@ -115,3 +126,123 @@ func TestGetLimits(t *testing.T) {
 		})
 	}
 }
+
+func tenantMatcher(tenant string) interface{} {
+	return mock.MatchedBy(func(ctx context.Context) bool {
+		id := identity.FromContext(ctx)
+		return id != nil && id.Tenant == tenant
+	})
+}
+
+func TestGetTenantLimit(t *testing.T) {
+	limitsModel := &mocks.LimitsModel{}
+	controller := NewLimitsController(limitsModel, new(view.RESTView))
+
+	api := setUpRestTest("/api/internal/v1/deployments/tenants/:tenant/limits/:name",
+		rest.Get, controller.GetTenantLimit)
+
+	limitsModel.On("GetLimit", tenantMatcher("foo"), limits.LimitArtifacts).
+		Return(&limits.Limit{Name: limits.LimitArtifacts, Value: 10}, nil)
+
+	recorded := test.RunRequest(t, api.MakeHandler(),
+		test.MakeSimpleRequest("GET",
+			"http://localhost/api/internal/v1/deployments/tenants/foo/limits/artifacts",
+			nil))
+	recorded.CodeIs(http.StatusOK)
+	assert.JSONEq(t, `{"limit":10,"usage":0}`, recorded.Recorder.Body.String())
+	limitsModel.AssertExpectations(t)
+}
+
+func TestSetTenantLimit(t *testing.T) {
+
+	testCases := []struct {
+		name  string
+		body  interface{}
+		code  int
+		err   error
+		limit *limits.Limit
+	}{
+		{
+			name: limits.LimitDeploymentsPerDay,
+			body: map[string]interface{}{"limit": 5},
+			code: http.StatusNoContent,
+			limit: &limits.Limit{
+				Name:  limits.LimitDeploymentsPerDay,
+				Value: 5,
+			},
+		},
+		{
+			name: limits.LimitDevicesPerDeployment,
+			body: map[string]interface{}{"limit": 0},
+			code: http.StatusNoContent,
+			limit: &limits.Limit{
+				Name:  limits.LimitDevicesPerDeployment,
+				Value: 0,
+			},
+		},
+		{
+			name: limits.LimitDevicesPerDeployment,
+			body: map[string]interface{}{"limit": 100, "grace": limits.GraceWarn},
+			code: http.StatusNoContent,
+			limit: &limits.Limit{
+				Name:  limits.LimitDevicesPerDeployment,
+				Value: 100,
+				Grace: limits.GraceWarn,
+			},
+		},
+		{
+			name: limits.LimitArtifacts,
+			body: map[string]interface{}{"limit": 5, "grace": limits.GraceWarn},
+			code: http.StatusBadRequest,
+		},
+		{
+			name: limits.LimitArtifacts,
+			body: map[string]interface{}{"limit": 5},
+			code: http.StatusInternalServerError,
+			err:  errors.New("failed"),
+			limit: &limits.Limit{
+				Name:  limits.LimitArtifacts,
+				Value: 5,
+			},
+		},
+		{
+			name: limits.LimitArtifacts,
+			body: map[string]interface{}{},
+			code: http.StatusBadRequest,
+		},
+		{
+			name: limits.LimitArtifacts,
+			body: map[string]interface{}{"limit": -1},
+			code: http.StatusBadRequest,
+		},
+		{
+			name: "foobar",
+			body: map[string]interface{}{"limit": 5},
+			code: http.StatusBadRequest,
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			limitsModel := &mocks.LimitsModel{}
+			controller := NewLimitsController(limitsModel, new(view.RESTView))
+
+			api := setUpRestTest("/api/internal/v1/deployments/tenants/:tenant/limits/:name",
+				rest.Put, controller.SetTenantLimit)
+
+			if tc.limit != nil {
+				limitsModel.On("SetLimit", tenantMatcher("foo"), tc.limit).
+					Return(tc.err)
+			}
+
+			recorded := test.RunRequest(t, api.MakeHandler(),
+				test.MakeSimpleRequest("PUT",
+					"http://localhost/api/internal/v1/deployments/tenants/foo/limits/"+tc.name,
+					tc.body))
+			recorded.CodeIs(tc.code)
+			limitsModel.AssertExpectations(t)
+		})
+	}
+}
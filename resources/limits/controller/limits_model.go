@@ -25,4 +25,5 @@ var ()
 
 type LimitsModel interface {
 	GetLimit(ctx context.Context, name string) (*limits.Limit, error)
+	SetLimit(ctx context.Context, limit *limits.Limit) error
 }
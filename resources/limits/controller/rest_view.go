@@ -23,4 +23,5 @@ type RESTView interface {
 	RenderSuccessGet(w rest.ResponseWriter, object interface{})
 	RenderError(w rest.ResponseWriter, r *rest.Request, err error, status int, l *log.Logger)
 	RenderInternalError(w rest.ResponseWriter, r *rest.Request, err error, l *log.Logger)
+	RenderSuccessPut(w rest.ResponseWriter)
 }
@@ -14,23 +14,74 @@
 
 package limits
 
+import (
+	"fmt"
+)
+
+const (
+	LimitStorage              = "storage"
+	LimitArtifacts            = "artifacts"
+	LimitDeploymentsPerDay    = "deployments_per_day"
+	LimitDevicesPerDeployment = "devices_per_deployment"
+)
+
+// Grace behavior of exceeded limits
 const (
-	LimitStorage = "storage"
+	// Requests over the limit are rejected
+	GraceReject = "reject"
+	// Requests over the limit are allowed, recorded and logged
+	GraceWarn = "warn"
 )
 
 var (
-	ValidLimits = []string{LimitStorage}
+	ValidLimits = []string{
+		LimitStorage,
+		LimitArtifacts,
+		LimitDeploymentsPerDay,
+		LimitDevicesPerDeployment,
+	}
+
+	// Limits with configurable grace behavior
+	GraceLimits = []string{
+		LimitDevicesPerDeployment,
+	}
 )
 
 type Limit struct {
 	Name  string `bson:"_id"`
 	Value uint64 `bson:"value" json:"value"`
+
+	// Grace behavior when the limit is exceeded, reject if not set
+	Grace string `bson:"grace,omitempty" json:"grace,omitempty"`
+}
+
+// Warns returns true if requests over the limit are allowed.
+func (l Limit) Warns() bool {
+	return l.Grace == GraceWarn
 }
 
 func (l Limit) IsLess(what uint64) bool {
 	return what < l.Value
 }
 
+// Check returns ExceededError if usage is over the limit; 0 value
+// means no limit.
+func (l Limit) Check(usage uint64) error {
+	if l.Value == 0 || usage <= l.Value {
+		return nil
+	}
+	return &ExceededError{Limit: l}
+}
+
+// ExceededError reports request rejected by the limit.
+type ExceededError struct {
+	Limit Limit
+}
+
+func (e *ExceededError) Error() string {
+	return fmt.Sprintf("%s limit of %d exceeded", e.Limit.Name, e.Limit.Value)
+}
+
 func IsValidLimit(name string) bool {
 	for _, n := range ValidLimits {
 		if name == n {
@@ -39,3 +90,18 @@ func IsValidLimit(name string) bool {
 	}
 	return false
 }
+
+// IsValidGrace returns true if the grace behavior can be set for the limit.
+func IsValidGrace(name, grace string) bool {
+	switch grace {
+	case "", GraceReject:
+		return true
+	case GraceWarn:
+		for _, n := range GraceLimits {
+			if name == n {
+				return true
+			}
+		}
+	}
+	return false
+}
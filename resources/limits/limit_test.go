@@ -24,4 +24,30 @@ func TestValidLimit(t *testing.T) {
 	assert.False(t, IsValidLimit("foo"))
 	assert.False(t, IsValidLimit("bar"))
 	assert.True(t, IsValidLimit(LimitStorage))
+	assert.True(t, IsValidLimit(LimitArtifacts))
+	assert.True(t, IsValidLimit(LimitDeploymentsPerDay))
+	assert.True(t, IsValidLimit(LimitDevicesPerDeployment))
+}
+
+func TestLimitCheck(t *testing.T) {
+	limit := Limit{Name: LimitArtifacts, Value: 10}
+	assert.NoError(t, limit.Check(9))
+	assert.NoError(t, limit.Check(10))
+	assert.EqualError(t, limit.Check(11), "artifacts limit of 10 exceeded")
+	assert.IsType(t, &ExceededError{}, limit.Check(11))
+
+	// no limit
+	assert.NoError(t, Limit{Name: LimitArtifacts}.Check(1000))
+}
+
+func TestValidGrace(t *testing.T) {
+	assert.True(t, IsValidGrace(LimitDevicesPerDeployment, ""))
+	assert.True(t, IsValidGrace(LimitDevicesPerDeployment, GraceReject))
+	assert.True(t, IsValidGrace(LimitDevicesPerDeployment, GraceWarn))
+	assert.True(t, IsValidGrace(LimitArtifacts, GraceReject))
+	assert.False(t, IsValidGrace(LimitArtifacts, GraceWarn))
+	assert.False(t, IsValidGrace(LimitDevicesPerDeployment, "ignore"))
+
+	assert.True(t, Limit{Grace: GraceWarn}.Warns())
+	assert.False(t, Limit{}.Warns())
 }
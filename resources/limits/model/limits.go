@@ -45,3 +45,10 @@ func (lm *LimitsModel) GetLimit(ctx context.Context, name string) (*limits.Limit
 	}
 	return limit, nil
 }
+
+func (lm *LimitsModel) SetLimit(ctx context.Context, limit *limits.Limit) error {
+	if err := lm.storage.SetLimit(ctx, limit); err != nil {
+		return errors.Wrap(err, "failed to save limit in storage")
+	}
+	return nil
+}
@@ -27,4 +27,5 @@ var (
 
 type LimitsStorage interface {
 	GetLimit(ctx context.Context, name string) (*limits.Limit, error)
+	SetLimit(ctx context.Context, limit *limits.Limit) error
 }
@@ -93,3 +93,32 @@ func TestGetLimit(t *testing.T) {
 		})
 	}
 }
+
+func TestSetLimit(t *testing.T) {
+	limit := &limits.Limit{
+		Name:  limits.LimitArtifacts,
+		Value: 10,
+	}
+
+	ls := mocks.LimitsStorage{}
+	ls.On("SetLimit",
+		mock.MatchedBy(
+			func(_ context.Context) bool {
+				return true
+			}),
+		limit).Return(nil).Once()
+	ls.On("SetLimit",
+		mock.MatchedBy(
+			func(_ context.Context) bool {
+				return true
+			}),
+		limit).Return(errors.New("error")).Once()
+
+	lm := NewLimitsModel(&ls)
+
+	assert.NoError(t, lm.SetLimit(context.Background(), limit))
+	assert.EqualError(t, lm.SetLimit(context.Background(), limit),
+		"failed to save limit in storage: error")
+
+	ls.AssertExpectations(t)
+}
@@ -46,4 +46,18 @@ func (_m *LimitsStorage) GetLimit(ctx context.Context, name string) (*limits.Lim
 	return r0, r1
 }
 
+// SetLimit provides a mock function with given fields: ctx, limit
+func (_m *LimitsStorage) SetLimit(ctx context.Context, limit *limits.Limit) error {
+	ret := _m.Called(ctx, limit)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *limits.Limit) error); ok {
+		r0 = rf(ctx, limit)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 var _ model.LimitsStorage = (*LimitsStorage)(nil)
@@ -60,3 +60,14 @@ func (ls *LimitsStorage) GetLimit(ctx context.Context, name string) (*limits.Lim
 
 	return &limit, nil
 }
+
+// SetLimit creates or replaces the limit
+func (ls *LimitsStorage) SetLimit(ctx context.Context, limit *limits.Limit) error {
+
+	session := ls.session.Copy()
+	defer session.Close()
+
+	_, err := session.DB(store.DbFromContext(ctx, DatabaseName)).
+		C(CollectionLimits).UpsertId(limit.Name, limit)
+	return err
+}
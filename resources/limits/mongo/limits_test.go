@@ -91,3 +91,32 @@ func TestGetLimit(t *testing.T) {
 	assert.NoError(t, err)
 	assert.EqualValues(t, lim3OtherTenant, *lim)
 }
+
+func TestSetLimit(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping TestSetLimit in short mode.")
+	}
+
+	dbCtx := identity.WithContext(context.Background(), &identity.Identity{
+		Tenant: "foo",
+	})
+	db := getDb(dbCtx)
+	defer db.session.Close()
+
+	assert.NoError(t, db.SetLimit(dbCtx, &limits.Limit{
+		Name:  limits.LimitArtifacts,
+		Value: 10,
+	}))
+	assert.NoError(t, db.SetLimit(dbCtx, &limits.Limit{
+		Name:  limits.LimitArtifacts,
+		Value: 20,
+	}))
+
+	lim, err := db.GetLimit(dbCtx, limits.LimitArtifacts)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(20), lim.Value)
+
+	// set per tenant
+	_, err = db.GetLimit(context.Background(), limits.LimitArtifacts)
+	assert.EqualError(t, err, model.ErrLimitNotFound.Error())
+}
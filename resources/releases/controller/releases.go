@@ -52,5 +52,5 @@ func (c *ReleasesController) GetReleases(w rest.ResponseWriter, r *rest.Request)
 		return
 	}
 
-	c.view.RenderSuccessGet(w, releases)
+	c.view.RenderCollection(w, r, releases)
 }
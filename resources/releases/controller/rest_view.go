@@ -21,6 +21,7 @@ import (
 
 type RESTView interface {
 	RenderSuccessGet(w rest.ResponseWriter, object interface{})
+	RenderCollection(w rest.ResponseWriter, r *rest.Request, collection interface{})
 	RenderError(w rest.ResponseWriter, r *rest.Request, err error, status int, l *log.Logger)
 	RenderInternalError(w rest.ResponseWriter, r *rest.Request, err error, l *log.Logger)
 }
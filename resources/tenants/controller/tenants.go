@@ -21,13 +21,16 @@ import (
 	"net/http"
 
 	"github.com/ant0ine/go-json-rest/rest"
+	"github.com/mendersoftware/deployments/resources/deployments"
 	deploymentsController "github.com/mendersoftware/deployments/resources/deployments/controller"
 	deploymentsModel "github.com/mendersoftware/deployments/resources/deployments/model"
 	"github.com/pkg/errors"
 
 	imageController "github.com/mendersoftware/deployments/resources/images/controller"
+	"github.com/mendersoftware/deployments/resources/limits"
 
 	"github.com/mendersoftware/deployments/resources/tenants/model"
+	"github.com/mendersoftware/deployments/utils/metrics"
 	"github.com/mendersoftware/go-lib-micro/identity"
 	"github.com/mendersoftware/go-lib-micro/log"
 	"github.com/mendersoftware/go-lib-micro/rest_utils"
@@ -39,6 +42,9 @@ type Controller struct {
 	imageModel imageController.ImagesModel
 	imageCtrl  imageController.SoftwareImagesController
 	restView   imageController.RESTView
+
+	// limit of active deployments exposed in progress metrics
+	metricsDeploymentsMax int
 }
 
 func NewController(model model.Model, depsModel *deploymentsModel.DeploymentsModel,
@@ -54,6 +60,12 @@ func NewController(model model.Model, depsModel *deploymentsModel.DeploymentsMod
 	}
 }
 
+// SetMetricsDeploymentsMax limits the number of active deployments, across
+// all tenants, exposed in deployment progress metrics.
+func (c *Controller) SetMetricsDeploymentsMax(max int) {
+	c.metricsDeploymentsMax = max
+}
+
 func (c *Controller) ProvisionTenantsHandler(w rest.ResponseWriter, r *rest.Request) {
 	ctx := r.Context()
 	l := log.FromContext(ctx)
@@ -102,6 +114,168 @@ func (c *Controller) DeploymentsPerTenantHandler(w rest.ResponseWriter, r *rest.
 	}
 }
 
+// PurgeDeviceDataHandler removes data of the device from databases of
+// all tenants and reports the tenants which had any.
+func (c *Controller) PurgeDeviceDataHandler(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+	l := log.FromContext(ctx)
+
+	deviceID := r.PathParam("id")
+
+	tenants, err := c.model.ListTenants(ctx)
+	if err != nil {
+		rest_utils.RestErrWithLogInternal(w, r, l, err)
+		return
+	}
+
+	report := deployments.DeviceDataPurgeReport{
+		DeviceID: deviceID,
+		Tenants:  []deployments.DeviceDataPurge{},
+	}
+
+	// default database is used when multi-tenancy is off
+	for _, tenantID := range append([]string{""}, tenants...) {
+		tctx := ctx
+		if tenantID != "" {
+			tctx = identity.WithContext(ctx, &identity.Identity{Tenant: tenantID})
+		}
+
+		purge, err := c.depsModel.PurgeDeviceData(tctx, deviceID)
+		if err != nil {
+			rest_utils.RestErrWithLogInternal(w, r, l,
+				errors.Wrapf(err, "failed to purge data of tenant %s", tenantID))
+			return
+		}
+
+		if !purge.Empty() {
+			report.Tenants = append(report.Tenants, *purge)
+		}
+	}
+
+	l.Infof("purged data of device %s in %d tenant databases", deviceID, len(report.Tenants))
+	w.WriteJson(report)
+}
+
+// OverQuotaReportHandler lists deployments of all tenants which were
+// created over a tenant limit, allowed by its grace behavior.
+func (c *Controller) OverQuotaReportHandler(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+	l := log.FromContext(ctx)
+
+	query, err := deploymentsController.ParseLookupQuery(r.URL.Query())
+	if err != nil {
+		rest_utils.RestErrWithLog(w, r, l, err, http.StatusBadRequest)
+		return
+	}
+
+	tenants, err := c.model.ListTenants(ctx)
+	if err != nil {
+		rest_utils.RestErrWithLogInternal(w, r, l, err)
+		return
+	}
+
+	report := []deployments.OverQuotaDeployment{}
+	// default database is used when multi-tenancy is off
+	for _, tenantID := range append([]string{""}, tenants...) {
+		tctx := ctx
+		if tenantID != "" {
+			tctx = identity.WithContext(ctx, &identity.Identity{Tenant: tenantID})
+		}
+
+		list, err := c.depsModel.OverQuotaDeployments(tctx, query)
+		if err != nil {
+			rest_utils.RestErrWithLogInternal(w, r, l,
+				errors.Wrapf(err, "failed to get deployments of tenant %s", tenantID))
+			return
+		}
+		report = append(report, list...)
+	}
+
+	w.WriteJson(report)
+}
+
+// DeploymentsMetricsHandler exposes device counts and percent complete of
+// active deployments of all tenants in the OpenMetrics text format, so that
+// alerts can fire on stalled rollouts.
+func (c *Controller) DeploymentsMetricsHandler(w rest.ResponseWriter, r *rest.Request) {
+	ctx := r.Context()
+	l := log.FromContext(ctx)
+
+	tenants, err := c.model.ListTenants(ctx)
+	if err != nil {
+		rest_utils.RestErrWithLogInternal(w, r, l, err)
+		return
+	}
+
+	progress := []deployments.DeploymentProgress{}
+	// default database is used when multi-tenancy is off
+	for _, tenantID := range append([]string{""}, tenants...) {
+		limit := c.metricsDeploymentsMax - len(progress)
+		if limit <= 0 {
+			break
+		}
+
+		tctx := ctx
+		if tenantID != "" {
+			tctx = identity.WithContext(ctx, &identity.Identity{Tenant: tenantID})
+		}
+
+		p, err := c.depsModel.ActiveDeploymentsProgress(tctx, limit)
+		if err != nil {
+			rest_utils.RestErrWithLogInternal(w, r, l,
+				errors.Wrapf(err, "failed to get deployments of tenant %s", tenantID))
+			return
+		}
+		progress = append(progress, p...)
+	}
+
+	devices := metrics.Gauge{
+		Name: "deployments_deployment_devices",
+		Help: "Number of devices of an active deployment by state.",
+	}
+	complete := metrics.Gauge{
+		Name: "deployments_deployment_complete_percent",
+		Help: "Percentage of devices which finished an active deployment.",
+	}
+	for _, p := range progress {
+		labels := []metrics.Label{
+			{Name: "deployment_id", Value: p.DeploymentID},
+			{Name: "deployment_name", Value: p.Name},
+		}
+		if p.TenantID != "" {
+			labels = append([]metrics.Label{{Name: "tenant_id", Value: p.TenantID}},
+				labels...)
+		}
+
+		for _, count := range []struct {
+			state string
+			value int
+		}{
+			{"pending", p.Pending},
+			{"inprogress", p.InProgress},
+			{"failure", p.Failure},
+			{"success", p.Success},
+		} {
+			devices.Samples = append(devices.Samples, metrics.Sample{
+				Labels: append(labels[:len(labels):len(labels)],
+					metrics.Label{Name: "state", Value: count.state}),
+				Value: float64(count.value),
+			})
+		}
+		complete.Samples = append(complete.Samples, metrics.Sample{
+			Labels: labels,
+			Value:  p.PercentComplete(),
+		})
+	}
+
+	h, _ := w.(http.ResponseWriter)
+	h.Header().Set("Content-Type", metrics.OpenMetricsContentType)
+	h.WriteHeader(http.StatusOK)
+	if err := metrics.WriteOpenMetrics(h, devices, complete); err != nil {
+		l.Errorf("failed to write deployment metrics: %s", err)
+	}
+}
+
 func (c *Controller) NewImageForTenantHandler(w rest.ResponseWriter, r *rest.Request) {
 	l := log.FromContext(r.Context())
 
@@ -115,6 +289,15 @@ func (c *Controller) NewImageForTenantHandler(w rest.ResponseWriter, r *rest.Req
 	ident := &identity.Identity{Tenant: tenantID}
 	ctx := identity.WithContext(r.Context(), ident)
 
+	if err := c.imageCtrl.CheckArtifactsLimit(ctx); err != nil {
+		if _, ok := err.(*limits.ExceededError); ok {
+			c.restView.RenderError(w, r, err, http.StatusUnprocessableEntity, l)
+		} else {
+			c.restView.RenderInternalError(w, r, err, l)
+		}
+		return
+	}
+
 	// parse content type and params according to RFC 1521
 	_, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
 	if err != nil {
@@ -143,8 +326,12 @@ func (c *Controller) NewImageForTenantHandler(w rest.ResponseWriter, r *rest.Req
 		c.restView.RenderError(w, r, cause, http.StatusUnprocessableEntity, l)
 	case imageController.ErrModelMissingInputMetadata, imageController.ErrModelMissingInputArtifact,
 		imageController.ErrModelInvalidMetadata, imageController.ErrModelMultipartUploadMsgMalformed,
-		imageController.ErrModelArtifactFileTooLarge, imageController.ErrModelParsingArtifactFailed:
+		imageController.ErrModelArtifactFileTooLarge, imageController.ErrModelParsingArtifactFailed,
+		imageController.ErrModelParsingTimeout:
 		l.Error(err.Error())
 		c.restView.RenderError(w, r, cause, http.StatusBadRequest, l)
+	case imageController.ErrModelParserBusy:
+		l.Error(err.Error())
+		c.restView.RenderError(w, r, cause, http.StatusServiceUnavailable, l)
 	}
 }
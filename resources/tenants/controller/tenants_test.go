@@ -24,12 +24,16 @@ import (
 	"strconv"
 	//	"strconv"
 	"testing"
+	"time"
 
 	"github.com/Sirupsen/logrus"
 	"github.com/ant0ine/go-json-rest/rest"
 	"github.com/ant0ine/go-json-rest/rest/test"
+	"github.com/mendersoftware/deployments/resources/deployments"
 	deploymentsModel "github.com/mendersoftware/deployments/resources/deployments/model"
+	deploymentsMocks "github.com/mendersoftware/deployments/resources/deployments/model/mocks"
 	"github.com/mendersoftware/deployments/utils/restutil/view"
+	"github.com/mendersoftware/go-lib-micro/identity"
 	"github.com/mendersoftware/go-lib-micro/requestid"
 	"github.com/mendersoftware/go-lib-micro/requestlog"
 	mt "github.com/mendersoftware/go-lib-micro/testing"
@@ -39,6 +43,7 @@ import (
 
 	imageMock "github.com/mendersoftware/deployments/resources/images/controller/mocks"
 	"github.com/mendersoftware/deployments/resources/tenants/model/mocks"
+	"github.com/mendersoftware/deployments/utils/metrics"
 	h "github.com/mendersoftware/deployments/utils/testing"
 	"github.com/stretchr/testify/mock"
 )
@@ -119,6 +124,347 @@ func TestProvisionTenant(t *testing.T) {
 	}
 }
 
+func TestPurgeDeviceData(t *testing.T) {
+
+	testCases := map[string]struct {
+		tenants    []string
+		tenantsErr error
+		purgeErr   error
+
+		checker mt.ResponseChecker
+	}{
+		"ok": {
+			tenants: []string{"acme"},
+			checker: mt.NewJSONResponse(
+				http.StatusOK,
+				nil,
+				deployments.DeviceDataPurgeReport{
+					DeviceID: "foo",
+					Tenants: []deployments.DeviceDataPurge{
+						{
+							TenantID:          "acme",
+							DeviceDeployments: 2,
+							Logs:              1,
+						},
+					},
+				}),
+		},
+		"ok, no data": {
+			checker: mt.NewJSONResponse(
+				http.StatusOK,
+				nil,
+				deployments.DeviceDataPurgeReport{
+					DeviceID: "foo",
+					Tenants:  []deployments.DeviceDataPurge{},
+				}),
+		},
+		"error: tenants": {
+			tenantsErr: errors.New("failed to list tenants: connection failed"),
+			checker: mt.NewJSONResponse(
+				http.StatusInternalServerError,
+				nil,
+				restError("internal error")),
+		},
+		"error: purge": {
+			tenants:  []string{"acme"},
+			purgeErr: errors.New("connection failed"),
+			checker: mt.NewJSONResponse(
+				http.StatusInternalServerError,
+				nil,
+				restError("internal error")),
+		},
+	}
+
+	for name := range testCases {
+		tc := testCases[name]
+
+		t.Run(name, func(t *testing.T) {
+			m := &mocks.Model{}
+			m.On("ListTenants", contextMatcher()).Return(tc.tenants, tc.tenantsErr)
+
+			// data of the device is only found in tenant databases
+			tenantMatcher := mock.MatchedBy(func(ctx context.Context) bool {
+				return identity.FromContext(ctx) != nil
+			})
+			defaultMatcher := mock.MatchedBy(func(ctx context.Context) bool {
+				return identity.FromContext(ctx) == nil
+			})
+
+			devDeps := &deploymentsMocks.DeviceDeploymentStorage{}
+			devDeps.On("DecommissionDeviceDeployments", contextMatcher(), "foo").
+				Return(nil)
+			devDeps.On("FindAllDeploymentsForDeviceIDWithStatuses", contextMatcher(),
+				"foo", mock.AnythingOfType("[]string")).Return(nil, nil)
+			devDeps.On("AnonymizeDeviceDeployments", defaultMatcher,
+				"foo", mock.AnythingOfType("string")).Return(0, nil)
+			devDeps.On("AnonymizeDeviceDeployments", tenantMatcher,
+				"foo", mock.AnythingOfType("string")).Return(2, tc.purgeErr)
+
+			logs := &deploymentsMocks.DeviceDeploymentLogsStorage{}
+			logs.On("DeleteDeviceDeploymentLogs", defaultMatcher, "foo").Return(0, nil)
+			logs.On("DeleteDeviceDeploymentLogs", tenantMatcher, "foo").Return(1, nil)
+
+			deps := deploymentsModel.NewDeploymentModel(deploymentsModel.DeploymentsModelConfig{
+				DeviceDeploymentsStorage:    devDeps,
+				DeviceDeploymentLogsStorage: logs,
+			})
+
+			imageModelMock := &imageMock.ImagesModel{}
+			restView := new(view.RESTView)
+			imgCtrl := imageController.NewSoftwareImagesController(imageModelMock, restView)
+
+			c := NewController(m, deps, imageModelMock, imgCtrl, restView)
+
+			api := setUpRestTest("/api/internal/v1/deployments/devices/:id/data",
+				rest.Delete, c.PurgeDeviceDataHandler)
+
+			req := test.MakeSimpleRequest("DELETE",
+				"http://1.2.3.4/api/internal/v1/deployments/devices/foo/data", nil)
+			req.Header.Add(requestid.RequestIdHeader, "test")
+			recorded := test.RunRequest(t, api, req)
+			mt.CheckResponse(t, tc.checker, recorded)
+		})
+	}
+}
+
+func TestDeploymentsMetrics(t *testing.T) {
+
+	id := "a108ae14-bb4e-455f-9b40-2ef4bab97bb7"
+	defaultID := "d1804903-5caa-4a73-a3ae-0efcc3205405"
+	deploymentName := "foo"
+
+	testCases := map[string]struct {
+		tenants    []string
+		tenantsErr error
+		findErr    error
+		max        int
+
+		status int
+		body   string
+	}{
+		"ok": {
+			tenants: []string{"acme"},
+			max:     10,
+			status:  http.StatusOK,
+			body: `# TYPE deployments_deployment_devices gauge
+# HELP deployments_deployment_devices Number of devices of an active deployment by state.
+deployments_deployment_devices{deployment_id="d1804903-5caa-4a73-a3ae-0efcc3205405",deployment_name="foo",state="pending"} 1
+deployments_deployment_devices{deployment_id="d1804903-5caa-4a73-a3ae-0efcc3205405",deployment_name="foo",state="inprogress"} 0
+deployments_deployment_devices{deployment_id="d1804903-5caa-4a73-a3ae-0efcc3205405",deployment_name="foo",state="failure"} 0
+deployments_deployment_devices{deployment_id="d1804903-5caa-4a73-a3ae-0efcc3205405",deployment_name="foo",state="success"} 0
+deployments_deployment_devices{tenant_id="acme",deployment_id="a108ae14-bb4e-455f-9b40-2ef4bab97bb7",deployment_name="foo",state="pending"} 1
+deployments_deployment_devices{tenant_id="acme",deployment_id="a108ae14-bb4e-455f-9b40-2ef4bab97bb7",deployment_name="foo",state="inprogress"} 1
+deployments_deployment_devices{tenant_id="acme",deployment_id="a108ae14-bb4e-455f-9b40-2ef4bab97bb7",deployment_name="foo",state="failure"} 1
+deployments_deployment_devices{tenant_id="acme",deployment_id="a108ae14-bb4e-455f-9b40-2ef4bab97bb7",deployment_name="foo",state="success"} 1
+# TYPE deployments_deployment_complete_percent gauge
+# HELP deployments_deployment_complete_percent Percentage of devices which finished an active deployment.
+deployments_deployment_complete_percent{deployment_id="d1804903-5caa-4a73-a3ae-0efcc3205405",deployment_name="foo"} 0
+deployments_deployment_complete_percent{tenant_id="acme",deployment_id="a108ae14-bb4e-455f-9b40-2ef4bab97bb7",deployment_name="foo"} 50
+# EOF
+`,
+		},
+		"ok, limit reached": {
+			tenants: []string{"acme"},
+			max:     1,
+			status:  http.StatusOK,
+			body: `# TYPE deployments_deployment_devices gauge
+# HELP deployments_deployment_devices Number of devices of an active deployment by state.
+deployments_deployment_devices{deployment_id="d1804903-5caa-4a73-a3ae-0efcc3205405",deployment_name="foo",state="pending"} 1
+deployments_deployment_devices{deployment_id="d1804903-5caa-4a73-a3ae-0efcc3205405",deployment_name="foo",state="inprogress"} 0
+deployments_deployment_devices{deployment_id="d1804903-5caa-4a73-a3ae-0efcc3205405",deployment_name="foo",state="failure"} 0
+deployments_deployment_devices{deployment_id="d1804903-5caa-4a73-a3ae-0efcc3205405",deployment_name="foo",state="success"} 0
+# TYPE deployments_deployment_complete_percent gauge
+# HELP deployments_deployment_complete_percent Percentage of devices which finished an active deployment.
+deployments_deployment_complete_percent{deployment_id="d1804903-5caa-4a73-a3ae-0efcc3205405",deployment_name="foo"} 0
+# EOF
+`,
+		},
+		"error: tenants": {
+			tenantsErr: errors.New("failed to list tenants: connection failed"),
+			max:        10,
+			status:     http.StatusInternalServerError,
+			body:       `{"error":"internal error","request_id":"test"}`,
+		},
+		"error: deployments": {
+			tenants: []string{"acme"},
+			findErr: errors.New("connection failed"),
+			max:     10,
+			status:  http.StatusInternalServerError,
+			body:    `{"error":"internal error","request_id":"test"}`,
+		},
+	}
+
+	for name := range testCases {
+		tc := testCases[name]
+
+		t.Run(name, func(t *testing.T) {
+			m := &mocks.Model{}
+			m.On("ListTenants", contextMatcher()).Return(tc.tenants, tc.tenantsErr)
+
+			tenantMatcher := mock.MatchedBy(func(ctx context.Context) bool {
+				return identity.FromContext(ctx) != nil
+			})
+			defaultMatcher := mock.MatchedBy(func(ctx context.Context) bool {
+				return identity.FromContext(ctx) == nil
+			})
+			inProgressQuery := mock.MatchedBy(func(q deployments.Query) bool {
+				return q.Status == deployments.StatusQueryInProgress
+			})
+			pendingQuery := mock.MatchedBy(func(q deployments.Query) bool {
+				return q.Status == deployments.StatusQueryPending
+			})
+
+			deps := &deploymentsMocks.DeploymentsStorage{}
+			deps.On("Find", defaultMatcher, inProgressQuery).Return(nil, nil)
+			deps.On("Find", defaultMatcher, pendingQuery).Return(
+				[]*deployments.Deployment{{
+					Id:                    &defaultID,
+					DeploymentConstructor: &deployments.DeploymentConstructor{Name: &deploymentName},
+					Stats: map[string]int{
+						deployments.DeviceDeploymentStatusPending: 1,
+					},
+				}}, nil)
+			deps.On("Find", tenantMatcher, inProgressQuery).Return(
+				[]*deployments.Deployment{{
+					Id:                    &id,
+					DeploymentConstructor: &deployments.DeploymentConstructor{Name: &deploymentName},
+					Stats: map[string]int{
+						deployments.DeviceDeploymentStatusPending:    1,
+						deployments.DeviceDeploymentStatusInstalling: 1,
+						deployments.DeviceDeploymentStatusFailure:    1,
+						deployments.DeviceDeploymentStatusSuccess:    1,
+					},
+				}}, tc.findErr)
+			deps.On("Find", tenantMatcher, pendingQuery).Return(nil, nil)
+
+			depsModel := deploymentsModel.NewDeploymentModel(deploymentsModel.DeploymentsModelConfig{
+				DeploymentsStorage: deps,
+			})
+
+			imageModelMock := &imageMock.ImagesModel{}
+			restView := new(view.RESTView)
+			imgCtrl := imageController.NewSoftwareImagesController(imageModelMock, restView)
+
+			c := NewController(m, depsModel, imageModelMock, imgCtrl, restView)
+			c.SetMetricsDeploymentsMax(tc.max)
+
+			api := setUpRestTest("/api/internal/v1/deployments/metrics/deployments",
+				rest.Get, c.DeploymentsMetricsHandler)
+
+			req := test.MakeSimpleRequest("GET",
+				"http://1.2.3.4/api/internal/v1/deployments/metrics/deployments", nil)
+			req.Header.Add(requestid.RequestIdHeader, "test")
+			recorded := test.RunRequest(t, api, req)
+			recorded.CodeIs(tc.status)
+			assert.Equal(t, tc.body, recorded.Recorder.Body.String())
+			if tc.status == http.StatusOK {
+				recorded.HeaderIs("Content-Type", metrics.OpenMetricsContentType)
+			}
+		})
+	}
+}
+
+func TestOverQuotaReport(t *testing.T) {
+
+	id := "a108ae14-bb4e-455f-9b40-2ef4bab97bb7"
+	deploymentName := "foo"
+	created, _ := time.Parse(time.RFC3339, "2020-01-02T03:04:05Z")
+
+	testCases := map[string]struct {
+		query      string
+		tenants    []string
+		tenantsErr error
+		findErr    error
+
+		status int
+		body   string
+	}{
+		"ok": {
+			query:   "?created_after=1577934245",
+			tenants: []string{"acme"},
+			status:  http.StatusOK,
+			body: `[{"tenant_id":"acme","deployment_id":"a108ae14-bb4e-455f-9b40-2ef4bab97bb7",` +
+				`"name":"foo","created":"2020-01-02T03:04:05Z","quota":{` +
+				`"limit":"devices_per_deployment","value":1,"usage":2,"decision":"warn"}}]`,
+		},
+		"ok, empty": {
+			status: http.StatusOK,
+			body:   `[]`,
+		},
+		"error: query": {
+			query:  "?created_after=yesterday",
+			status: http.StatusBadRequest,
+			body: `{"error":"timestamp parsing failed created_after parameter: ` +
+				`invalid timestamp: yesterday","request_id":"test"}`,
+		},
+		"error: tenants": {
+			tenantsErr: errors.New("failed to list tenants: connection failed"),
+			status:     http.StatusInternalServerError,
+			body:       `{"error":"internal error","request_id":"test"}`,
+		},
+		"error: deployments": {
+			tenants: []string{"acme"},
+			findErr: errors.New("connection failed"),
+			status:  http.StatusInternalServerError,
+			body:    `{"error":"internal error","request_id":"test"}`,
+		},
+	}
+
+	for name := range testCases {
+		tc := testCases[name]
+
+		t.Run(name, func(t *testing.T) {
+			m := &mocks.Model{}
+			m.On("ListTenants", contextMatcher()).Return(tc.tenants, tc.tenantsErr)
+
+			tenantMatcher := mock.MatchedBy(func(ctx context.Context) bool {
+				return identity.FromContext(ctx) != nil
+			})
+			defaultMatcher := mock.MatchedBy(func(ctx context.Context) bool {
+				return identity.FromContext(ctx) == nil
+			})
+			overQuotaQuery := mock.MatchedBy(func(q deployments.Query) bool {
+				return q.OverQuota
+			})
+
+			deps := &deploymentsMocks.DeploymentsStorage{}
+			deps.On("Find", defaultMatcher, overQuotaQuery).Return(nil, nil)
+			deps.On("Find", tenantMatcher, overQuotaQuery).Return(
+				[]*deployments.Deployment{{
+					Id:                    &id,
+					DeploymentConstructor: &deployments.DeploymentConstructor{Name: &deploymentName},
+					Created:               &created,
+					OverQuota: &deployments.QuotaExceeded{
+						Limit:    "devices_per_deployment",
+						Value:    1,
+						Usage:    2,
+						Decision: "warn",
+					},
+				}}, tc.findErr)
+
+			depsModel := deploymentsModel.NewDeploymentModel(deploymentsModel.DeploymentsModelConfig{
+				DeploymentsStorage: deps,
+			})
+
+			imageModelMock := &imageMock.ImagesModel{}
+			restView := new(view.RESTView)
+			imgCtrl := imageController.NewSoftwareImagesController(imageModelMock, restView)
+
+			c := NewController(m, depsModel, imageModelMock, imgCtrl, restView)
+
+			api := setUpRestTest("/api/internal/v1/deployments/reports/over-quota",
+				rest.Get, c.OverQuotaReportHandler)
+
+			req := test.MakeSimpleRequest("GET",
+				"http://1.2.3.4/api/internal/v1/deployments/reports/over-quota"+tc.query, nil)
+			req.Header.Add(requestid.RequestIdHeader, "test")
+			recorded := test.RunRequest(t, api, req)
+			recorded.CodeIs(tc.status)
+			assert.JSONEq(t, tc.body, recorded.Recorder.Body.String())
+		})
+	}
+}
+
 func restError(status string) map[string]interface{} {
 	return map[string]interface{}{"error": status, "request_id": "test"}
 }
@@ -24,6 +24,7 @@ import (
 
 type Model interface {
 	ProvisionTenant(ctx context.Context, tenant_id string) error
+	ListTenants(ctx context.Context) ([]string, error)
 }
 
 type model struct {
@@ -43,3 +44,12 @@ func (m *model) ProvisionTenant(ctx context.Context, tenant_id string) error {
 
 	return nil
 }
+
+func (m *model) ListTenants(ctx context.Context) ([]string, error) {
+	tenants, err := m.store.ListTenants(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list tenants")
+	}
+
+	return tenants, nil
+}
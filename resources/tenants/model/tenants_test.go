@@ -70,3 +70,43 @@ func TestProvisionTenant(t *testing.T) {
 		})
 	}
 }
+
+func TestListTenants(t *testing.T) {
+	testCases := map[string]struct {
+		tenants  []string
+		storeErr error
+
+		err error
+	}{
+		"ok": {
+			tenants: []string{"foo", "bar"},
+		},
+		"error": {
+			storeErr: errors.New("connection failed"),
+			err:      errors.New("failed to list tenants: connection failed"),
+		},
+	}
+
+	for name := range testCases {
+		tc := testCases[name]
+
+		t.Run(name, func(t *testing.T) {
+			s := mstore.Store{}
+			s.On("ListTenants",
+				mock.MatchedBy(
+					func(_ context.Context) bool {
+						return true
+					})).Return(tc.tenants, tc.storeErr)
+
+			m := NewModel(&s)
+
+			tenants, err := m.ListTenants(context.Background())
+			if tc.err != nil {
+				assert.EqualError(t, err, tc.err.Error())
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tc.tenants, tenants)
+			}
+		})
+	}
+}
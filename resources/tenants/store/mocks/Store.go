@@ -21,6 +21,29 @@ type Store struct {
 	mock.Mock
 }
 
+// ListTenants provides a mock function with given fields: ctx
+func (_m *Store) ListTenants(ctx context.Context) ([]string, error) {
+	ret := _m.Called(ctx)
+
+	var r0 []string
+	if rf, ok := ret.Get(0).(func(context.Context) []string); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]string)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // ProvisionTenant provides a mock function with given fields: ctx, tenantId
 func (_m *Store) ProvisionTenant(ctx context.Context, tenantId string) error {
 	ret := _m.Called(ctx, tenantId)
@@ -17,6 +17,7 @@ import (
 	"context"
 
 	"github.com/globalsign/mgo"
+	"github.com/mendersoftware/go-lib-micro/mongo/migrate"
 
 	"github.com/mendersoftware/deployments/migrations"
 	mstore "github.com/mendersoftware/go-lib-micro/store"
@@ -24,6 +25,7 @@ import (
 
 type Store interface {
 	ProvisionTenant(ctx context.Context, tenantId string) error
+	ListTenants(ctx context.Context) ([]string, error)
 }
 
 type store struct {
@@ -44,3 +46,21 @@ func (ts *store) ProvisionTenant(ctx context.Context, tenantId string) error {
 
 	return migrations.MigrateSingle(ctx, dbname, migrations.DbVersion, session, true)
 }
+
+// ListTenants returns IDs of tenants with provisioned databases.
+func (ts *store) ListTenants(ctx context.Context) ([]string, error) {
+	session := ts.session.Copy()
+	defer session.Close()
+
+	dbs, err := migrate.GetTenantDbs(session, mstore.IsTenantDb(migrations.DbName))
+	if err != nil {
+		return nil, err
+	}
+
+	tenants := make([]string, 0, len(dbs))
+	for _, db := range dbs {
+		tenants = append(tenants, mstore.TenantFromDbName(db, migrations.DbName))
+	}
+
+	return tenants, nil
+}
@@ -0,0 +1,52 @@
+// Copyright 2018 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package mocks
+
+import context "context"
+import mock "github.com/stretchr/testify/mock"
+import usage "github.com/mendersoftware/deployments/resources/usage"
+
+// UsageModel is an autogenerated mock type for the UsageModel type
+type UsageModel struct {
+	mock.Mock
+}
+
+// ListUsage provides a mock function with given fields: ctx, query
+func (_m *UsageModel) ListUsage(ctx context.Context, query usage.Query) ([]usage.Usage, error) {
+	ret := _m.Called(ctx, query)
+
+	var r0 []usage.Usage
+	if rf, ok := ret.Get(0).(func(context.Context, usage.Query) []usage.Usage); ok {
+		r0 = rf(ctx, query)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]usage.Usage)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, usage.Query) error); ok {
+		r1 = rf(ctx, query)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// RecordCall provides a mock function with given fields: ctx
+func (_m *UsageModel) RecordCall(ctx context.Context) {
+	_m.Called(ctx)
+}
@@ -0,0 +1,91 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package controller
+
+import (
+	"net/http"
+
+	"github.com/ant0ine/go-json-rest/rest"
+	"github.com/mendersoftware/go-lib-micro/requestlog"
+	"github.com/mendersoftware/go-lib-micro/rest_utils"
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/deployments/resources/usage"
+)
+
+type UsageController struct {
+	view  RESTView
+	model UsageModel
+}
+
+func NewUsageController(model UsageModel, view RESTView) *UsageController {
+	return &UsageController{
+		model: model,
+		view:  view,
+	}
+}
+
+// ListUsage lists daily usage of tenants, optionally filtered by tenant
+// and range of days, and sorted by one of the usage counters.
+func (c *UsageController) ListUsage(w rest.ResponseWriter, r *rest.Request) {
+	l := requestlog.GetRequestLogger(r)
+
+	query := usage.Query{
+		TenantID: r.URL.Query().Get("tenant_id"),
+		From:     r.URL.Query().Get("from"),
+		To:       r.URL.Query().Get("to"),
+		Sort:     r.URL.Query().Get("sort"),
+	}
+	for _, day := range []string{query.From, query.To} {
+		if day != "" && !usage.IsValidDay(day) {
+			c.view.RenderError(w, r,
+				errors.Errorf("invalid day %s, expected YYYY-MM-DD", day),
+				http.StatusBadRequest, l)
+			return
+		}
+	}
+	if query.Sort != "" && !usage.IsValidSort(query.Sort) {
+		c.view.RenderError(w, r,
+			errors.Errorf("unsupported sort %s", query.Sort),
+			http.StatusBadRequest, l)
+		return
+	}
+
+	page, perPage, err := rest_utils.ParsePagination(r)
+	if err != nil {
+		c.view.RenderError(w, r, err, http.StatusBadRequest, l)
+		return
+	}
+	query.Skip = int((page - 1) * perPage)
+	query.Limit = int(perPage + 1)
+
+	list, err := c.model.ListUsage(r.Context(), query)
+	if err != nil {
+		c.view.RenderInternalError(w, r, err, l)
+		return
+	}
+
+	hasNext := false
+	if uint64(len(list)) > perPage {
+		hasNext = true
+		list = list[:perPage]
+	}
+
+	for _, link := range rest_utils.MakePageLinkHdrs(r, page, perPage, hasNext) {
+		w.Header().Add("Link", link)
+	}
+
+	c.view.RenderCollection(w, r, list)
+}
@@ -0,0 +1,174 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package controller_test
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/ant0ine/go-json-rest/rest"
+	"github.com/ant0ine/go-json-rest/rest/test"
+	"github.com/mendersoftware/go-lib-micro/identity"
+	"github.com/mendersoftware/go-lib-micro/requestid"
+	"github.com/mendersoftware/go-lib-micro/requestlog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/mendersoftware/deployments/resources/usage"
+	. "github.com/mendersoftware/deployments/resources/usage/controller"
+	"github.com/mendersoftware/deployments/resources/usage/controller/mocks"
+	"github.com/mendersoftware/deployments/utils/restutil/view"
+)
+
+func contextMatcher() interface{} {
+	return mock.MatchedBy(func(_ context.Context) bool {
+		return true
+	})
+}
+
+func setUpRestTest(route string, handler rest.HandlerFunc) *rest.Api {
+	router, _ := rest.MakeRouter(rest.Get(route, handler))
+	api := rest.NewApi()
+	api.Use(
+		&requestlog.RequestLogMiddleware{
+			BaseLogger: &logrus.Logger{Out: ioutil.Discard},
+		},
+		&requestid.RequestIdMiddleware{},
+	)
+	api.SetApp(router)
+
+	return api
+}
+
+func TestListUsage(t *testing.T) {
+	u := usage.Usage{
+		TenantID:    "foo",
+		Day:         "2019-03-01",
+		Calls:       10,
+		EgressBytes: 1024,
+	}
+
+	testCases := map[string]struct {
+		url   string
+		query *usage.Query
+		list  []usage.Usage
+		err   error
+
+		code  int
+		body  string
+		error string
+		link  bool
+	}{
+		"ok": {
+			url: "/usage?tenant_id=foo&from=2019-03-01&to=2019-03-31&sort=calls",
+			query: &usage.Query{
+				TenantID: "foo",
+				From:     "2019-03-01",
+				To:       "2019-03-31",
+				Sort:     usage.SortCalls,
+				Limit:    21,
+			},
+			list: []usage.Usage{u},
+			code: http.StatusOK,
+			body: `[{"tenant_id":"foo","day":"2019-03-01","calls":10,"egress_bytes":1024}]`,
+		},
+		"next page": {
+			url:   "/usage?per_page=1",
+			query: &usage.Query{Limit: 2},
+			list:  []usage.Usage{u, u},
+			code:  http.StatusOK,
+			link:  true,
+		},
+		"bad day": {
+			url:   "/usage?from=2019-3-1",
+			code:  http.StatusBadRequest,
+			error: "invalid day 2019-3-1, expected YYYY-MM-DD",
+		},
+		"bad sort": {
+			url:   "/usage?sort=tenant_id",
+			code:  http.StatusBadRequest,
+			error: "unsupported sort tenant_id",
+		},
+		"bad pagination": {
+			url:  "/usage?page=foo",
+			code: http.StatusBadRequest,
+		},
+		"model error": {
+			url:   "/usage",
+			query: &usage.Query{Limit: 21},
+			err:   errors.New("db failed"),
+			code:  http.StatusInternalServerError,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			model := &mocks.UsageModel{}
+			if tc.query != nil {
+				model.On("ListUsage", contextMatcher(), *tc.query).
+					Return(tc.list, tc.err)
+			}
+
+			controller := NewUsageController(model, new(view.RESTView))
+			api := setUpRestTest("/usage", controller.ListUsage)
+
+			recorded := test.RunRequest(t, api.MakeHandler(),
+				test.MakeSimpleRequest("GET", "http://localhost"+tc.url, nil))
+			recorded.CodeIs(tc.code)
+			if tc.body != "" {
+				assert.JSONEq(t, tc.body, recorded.Recorder.Body.String())
+			}
+			if tc.error != "" {
+				assert.Contains(t, recorded.Recorder.Body.String(), tc.error)
+			}
+			if tc.link {
+				assert.Contains(t, recorded.Recorder.Header().Get("Link"), "next")
+			}
+
+			model.AssertExpectations(t)
+		})
+	}
+}
+
+func TestUsageMiddleware(t *testing.T) {
+	model := &mocks.UsageModel{}
+	model.On("RecordCall", mock.MatchedBy(func(ctx context.Context) bool {
+		id := identity.FromContext(ctx)
+		return id != nil && id.Tenant == "foo"
+	})).Once()
+
+	router, _ := rest.MakeRouter(rest.Get("/r", func(w rest.ResponseWriter, r *rest.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	api := rest.NewApi()
+	api.Use(rest.MiddlewareSimple(func(h rest.HandlerFunc) rest.HandlerFunc {
+		return func(w rest.ResponseWriter, r *rest.Request) {
+			ctx := identity.WithContext(r.Context(), &identity.Identity{Tenant: "foo"})
+			r.Request = r.Request.WithContext(ctx)
+			h(w, r)
+		}
+	}), &UsageMiddleware{Model: model})
+	api.SetApp(router)
+
+	recorded := test.RunRequest(t, api.MakeHandler(),
+		test.MakeSimpleRequest("GET", "http://localhost/r", nil))
+	recorded.CodeIs(http.StatusNoContent)
+
+	model.AssertExpectations(t)
+}
@@ -0,0 +1,155 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/mendersoftware/go-lib-micro/identity"
+	"github.com/mendersoftware/go-lib-micro/log"
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/deployments/resources/usage"
+)
+
+type usageKey struct {
+	tenant string
+	day    string
+}
+
+// UsageModel accounts API calls and artifact egress of tenants.
+// Usage is aggregated in memory and flushed to the storage periodically,
+// so that requests are not slowed down by an extra write.
+type UsageModel struct {
+	storage UsageStorage
+
+	lock    sync.Mutex
+	pending map[usageKey]*usage.Usage
+	now     func() time.Time
+}
+
+func NewUsageModel(storage UsageStorage) *UsageModel {
+	return &UsageModel{
+		storage: storage,
+		pending: map[usageKey]*usage.Usage{},
+		now:     time.Now,
+	}
+}
+
+// RecordCall counts API call of the tenant of the request.
+// Calls without identity are not accounted.
+func (m *UsageModel) RecordCall(ctx context.Context) {
+	m.record(ctx, 1, 0)
+}
+
+// RecordEgress accounts artifact bytes served to the tenant of the request.
+func (m *UsageModel) RecordEgress(ctx context.Context, bytes int64) {
+	if bytes <= 0 {
+		return
+	}
+	m.record(ctx, 0, uint64(bytes))
+}
+
+func (m *UsageModel) record(ctx context.Context, calls, bytes uint64) {
+	id := identity.FromContext(ctx)
+	if id == nil {
+		return
+	}
+
+	key := usageKey{tenant: id.Tenant, day: usage.Day(m.now())}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	u, ok := m.pending[key]
+	if !ok {
+		u = &usage.Usage{TenantID: key.tenant, Day: key.day}
+		m.pending[key] = u
+	}
+	u.Calls += calls
+	u.EgressBytes += bytes
+}
+
+// Flush saves usage aggregated since the last flush. Usage which failed
+// to be saved is kept to be saved with the next flush.
+func (m *UsageModel) Flush(ctx context.Context) error {
+	m.lock.Lock()
+	pending := m.pending
+	m.pending = map[usageKey]*usage.Usage{}
+	m.lock.Unlock()
+
+	var failed error
+	for key, u := range pending {
+		if err := m.storage.IncrementUsage(ctx, u); err != nil {
+			failed = errors.Wrap(err, "saving usage")
+			m.restore(key, u)
+		}
+	}
+
+	return failed
+}
+
+func (m *UsageModel) restore(key usageKey, u *usage.Usage) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if p, ok := m.pending[key]; ok {
+		p.Calls += u.Calls
+		p.EgressBytes += u.EgressBytes
+	} else {
+		m.pending[key] = u
+	}
+}
+
+// Start flushes usage every interval until the context is done,
+// flushing the remaining usage on exit.
+func (m *UsageModel) Start(ctx context.Context, interval time.Duration) {
+	go func() {
+		l := log.FromContext(ctx)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				if err := m.Flush(context.Background()); err != nil {
+					l.Errorf("flushing usage: %s", err.Error())
+				}
+				return
+			case <-ticker.C:
+				if err := m.Flush(ctx); err != nil {
+					l.Errorf("flushing usage: %s", err.Error())
+				}
+			}
+		}
+	}()
+}
+
+// ListUsage returns saved usage matching the query; usage recorded
+// since the last flush is not included.
+func (m *UsageModel) ListUsage(ctx context.Context, query usage.Query) ([]usage.Usage, error) {
+	list, err := m.storage.FindUsage(ctx, query)
+	if err != nil {
+		return nil, errors.Wrap(err, "searching for usage")
+	}
+
+	if list == nil {
+		return make([]usage.Usage, 0), nil
+	}
+
+	return list, nil
+}
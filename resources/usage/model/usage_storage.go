@@ -0,0 +1,28 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"context"
+
+	"github.com/mendersoftware/deployments/resources/usage"
+)
+
+type UsageStorage interface {
+	// IncrementUsage adds calls and egress bytes to the usage of the
+	// tenant within the day, creating the record if needed.
+	IncrementUsage(ctx context.Context, u *usage.Usage) error
+	FindUsage(ctx context.Context, query usage.Query) ([]usage.Usage, error)
+}
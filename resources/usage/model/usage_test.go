@@ -0,0 +1,116 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mendersoftware/go-lib-micro/identity"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/mendersoftware/deployments/resources/usage"
+	"github.com/mendersoftware/deployments/resources/usage/model/mocks"
+)
+
+func TestRecordAndFlush(t *testing.T) {
+	storage := &mocks.UsageStorage{}
+	m := NewUsageModel(storage)
+
+	day := time.Date(2019, 3, 1, 12, 0, 0, 0, time.UTC)
+	m.now = func() time.Time { return day }
+
+	foo := identity.WithContext(context.Background(), &identity.Identity{Tenant: "foo"})
+	bar := identity.WithContext(context.Background(), &identity.Identity{Tenant: "bar"})
+
+	m.RecordCall(foo)
+	m.RecordCall(foo)
+	m.RecordEgress(foo, 1024)
+	m.RecordCall(bar)
+	m.RecordEgress(bar, 0)
+	// not accounted without identity
+	m.RecordCall(context.Background())
+
+	m.now = func() time.Time { return day.Add(24 * time.Hour) }
+	m.RecordCall(foo)
+
+	ctx := context.Background()
+	storage.On("IncrementUsage", ctx, &usage.Usage{
+		TenantID: "foo", Day: "2019-03-01", Calls: 2, EgressBytes: 1024,
+	}).Return(nil).Once()
+	storage.On("IncrementUsage", ctx, &usage.Usage{
+		TenantID: "bar", Day: "2019-03-01", Calls: 1,
+	}).Return(nil).Once()
+	storage.On("IncrementUsage", ctx, &usage.Usage{
+		TenantID: "foo", Day: "2019-03-02", Calls: 1,
+	}).Return(nil).Once()
+	assert.NoError(t, m.Flush(ctx))
+
+	// nothing left to flush
+	assert.NoError(t, m.Flush(ctx))
+
+	storage.AssertExpectations(t)
+}
+
+func TestFlushError(t *testing.T) {
+	storage := &mocks.UsageStorage{}
+	m := NewUsageModel(storage)
+
+	m.now = func() time.Time { return time.Date(2019, 3, 1, 12, 0, 0, 0, time.UTC) }
+	foo := identity.WithContext(context.Background(), &identity.Identity{Tenant: "foo"})
+	ctx := context.Background()
+
+	m.RecordCall(foo)
+	storage.On("IncrementUsage", ctx, mock.AnythingOfType("*usage.Usage")).
+		Return(errors.New("db failed")).Once()
+	assert.EqualError(t, m.Flush(ctx), "saving usage: db failed")
+
+	// failed usage is saved with the next flush
+	m.RecordCall(foo)
+	storage.On("IncrementUsage", ctx, &usage.Usage{
+		TenantID: "foo", Day: "2019-03-01", Calls: 2,
+	}).Return(nil).Once()
+	assert.NoError(t, m.Flush(ctx))
+
+	storage.AssertExpectations(t)
+}
+
+func TestListUsage(t *testing.T) {
+	storage := &mocks.UsageStorage{}
+	m := NewUsageModel(storage)
+	ctx := context.Background()
+
+	query := usage.Query{TenantID: "foo", From: "2019-03-01"}
+	list := []usage.Usage{{TenantID: "foo", Day: "2019-03-01", Calls: 1}}
+
+	storage.On("FindUsage", ctx, query).Return(list, nil).Once()
+	res, err := m.ListUsage(ctx, query)
+	assert.NoError(t, err)
+	assert.Equal(t, list, res)
+
+	storage.On("FindUsage", ctx, query).Return(nil, nil).Once()
+	res, err = m.ListUsage(ctx, query)
+	assert.NoError(t, err)
+	assert.Equal(t, []usage.Usage{}, res)
+
+	storage.On("FindUsage", ctx, query).Return(nil, errors.New("db failed")).Once()
+	_, err = m.ListUsage(ctx, query)
+	assert.EqualError(t, err, "searching for usage: db failed")
+
+	storage.AssertExpectations(t)
+}
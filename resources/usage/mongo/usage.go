@@ -0,0 +1,145 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package mongo
+
+import (
+	"context"
+
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+
+	"github.com/mendersoftware/deployments/resources/usage"
+)
+
+// Database
+//
+// Usage of all tenants is kept in the main database, so that operators
+// can compare tenants with a single query.
+const (
+	DatabaseName    = "deployment_service"
+	CollectionUsage = "usage"
+)
+
+// Keys
+const (
+	StorageKeyUsageTenant      = "tenant_id"
+	StorageKeyUsageDay         = "day"
+	StorageKeyUsageCalls       = "calls"
+	StorageKeyUsageEgressBytes = "egress_bytes"
+)
+
+// Indexes
+const (
+	IndexUsageTenantDayStr = "usageTenantDayIndex"
+	IndexUsageDayStr       = "usageDayIndex"
+)
+
+// UsageStorage is a data layer for tenant usage based on MongoDB
+// Implements model.UsageStorage
+type UsageStorage struct {
+	session *mgo.Session
+}
+
+func NewUsageStorage(session *mgo.Session) *UsageStorage {
+	return &UsageStorage{
+		session: session,
+	}
+}
+
+// Single record per tenant and day; usage of all tenants is looked up
+// by day.
+func (s *UsageStorage) ensureIndexing(session *mgo.Session) error {
+	for _, index := range []mgo.Index{
+		{
+			Key:        []string{StorageKeyUsageTenant, StorageKeyUsageDay},
+			Name:       IndexUsageTenantDayStr,
+			Unique:     true,
+			Background: true,
+		},
+		{
+			Key:        []string{StorageKeyUsageDay},
+			Name:       IndexUsageDayStr,
+			Background: true,
+		},
+	} {
+		if err := session.DB(DatabaseName).C(CollectionUsage).
+			EnsureIndex(index); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// IncrementUsage adds calls and egress bytes to the usage of the tenant
+// within the day.
+func (s *UsageStorage) IncrementUsage(ctx context.Context, u *usage.Usage) error {
+	session := s.session.Copy()
+	defer session.Close()
+
+	if err := s.ensureIndexing(session); err != nil {
+		return err
+	}
+
+	_, err := session.DB(DatabaseName).C(CollectionUsage).Upsert(
+		bson.M{
+			StorageKeyUsageTenant: u.TenantID,
+			StorageKeyUsageDay:    u.Day,
+		},
+		bson.M{
+			"$inc": bson.M{
+				StorageKeyUsageCalls:       int64(u.Calls),
+				StorageKeyUsageEgressBytes: int64(u.EgressBytes),
+			},
+		})
+	return err
+}
+
+// FindUsage returns usage matching the query.
+func (s *UsageStorage) FindUsage(ctx context.Context, match usage.Query) ([]usage.Usage, error) {
+	session := s.session.Copy()
+	defer session.Close()
+
+	query := bson.M{}
+	if match.TenantID != "" {
+		query[StorageKeyUsageTenant] = match.TenantID
+	}
+	days := bson.M{}
+	if match.From != "" {
+		days["$gte"] = match.From
+	}
+	if match.To != "" {
+		days["$lte"] = match.To
+	}
+	if len(days) > 0 {
+		query[StorageKeyUsageDay] = days
+	}
+
+	sort := []string{"-" + StorageKeyUsageDay, StorageKeyUsageTenant}
+	if match.Sort != "" {
+		sort = append([]string{"-" + match.Sort}, sort...)
+	}
+
+	var list []usage.Usage
+	err := session.DB(DatabaseName).C(CollectionUsage).
+		Find(query).Select(bson.M{"_id": 0}).Sort(sort...).
+		Skip(match.Skip).Limit(match.Limit).
+		All(&list)
+	if err != nil {
+		return nil, err
+	}
+
+	return list, nil
+}
@@ -0,0 +1,76 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package mongo
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mendersoftware/deployments/resources/usage"
+)
+
+func TestUsageStorage(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping TestUsageStorage in short mode.")
+	}
+
+	db.Wipe()
+	store := NewUsageStorage(db.Session())
+	ctx := context.Background()
+
+	for _, u := range []*usage.Usage{
+		{TenantID: "foo", Day: "2019-03-01", Calls: 10, EgressBytes: 100},
+		{TenantID: "foo", Day: "2019-03-01", Calls: 5},
+		{TenantID: "foo", Day: "2019-03-02", Calls: 1, EgressBytes: 1000},
+		{TenantID: "bar", Day: "2019-03-01", Calls: 20},
+	} {
+		assert.NoError(t, store.IncrementUsage(ctx, u))
+	}
+
+	// increments are summed up, newest days first
+	list, err := store.FindUsage(ctx, usage.Query{TenantID: "foo"})
+	assert.NoError(t, err)
+	assert.Equal(t, []usage.Usage{
+		{TenantID: "foo", Day: "2019-03-02", Calls: 1, EgressBytes: 1000},
+		{TenantID: "foo", Day: "2019-03-01", Calls: 15, EgressBytes: 100},
+	}, list)
+
+	// heaviest tenants of the day
+	list, err = store.FindUsage(ctx, usage.Query{
+		From: "2019-03-01",
+		To:   "2019-03-01",
+		Sort: usage.SortCalls,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []usage.Usage{
+		{TenantID: "bar", Day: "2019-03-01", Calls: 20},
+		{TenantID: "foo", Day: "2019-03-01", Calls: 15, EgressBytes: 100},
+	}, list)
+
+	list, err = store.FindUsage(ctx, usage.Query{
+		Sort:  usage.SortEgressBytes,
+		Limit: 1,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []usage.Usage{
+		{TenantID: "foo", Day: "2019-03-02", Calls: 1, EgressBytes: 1000},
+	}, list)
+
+	list, err = store.FindUsage(ctx, usage.Query{From: "2019-03-03"})
+	assert.NoError(t, err)
+	assert.Empty(t, list)
+}
@@ -0,0 +1,78 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package usage
+
+import (
+	"time"
+)
+
+// DayFormat is the layout of usage days, days are in UTC.
+const DayFormat = "2006-01-02"
+
+// Keys usage can be sorted by
+const (
+	SortCalls       = "calls"
+	SortEgressBytes = "egress_bytes"
+)
+
+var (
+	ValidSorts = []string{SortCalls, SortEgressBytes}
+)
+
+// Usage is the API usage of the tenant within single day.
+type Usage struct {
+	TenantID string `json:"tenant_id" bson:"tenant_id"`
+	Day      string `json:"day" bson:"day"`
+
+	// Number of API calls made with identity of the tenant
+	Calls uint64 `json:"calls" bson:"calls"`
+
+	// Size of artifacts download links were issued for; the downloads
+	// themselves are served by the file storage
+	EgressBytes uint64 `json:"egress_bytes" bson:"egress_bytes"`
+}
+
+// Day returns usage day of the time.
+func Day(t time.Time) string {
+	return t.UTC().Format(DayFormat)
+}
+
+// IsValidDay checks if day is formatted according to DayFormat.
+func IsValidDay(day string) bool {
+	_, err := time.Parse(DayFormat, day)
+	return err == nil
+}
+
+// IsValidSort checks if usage can be sorted by the key.
+func IsValidSort(key string) bool {
+	for _, s := range ValidSorts {
+		if key == s {
+			return true
+		}
+	}
+	return false
+}
+
+// Query selects usage records, empty fields match any value.
+type Query struct {
+	TenantID string
+	// First and last day included
+	From string
+	To   string
+	// Sort key, sorted descending; newest days first if empty
+	Sort  string
+	Limit int
+	Skip  int
+}
@@ -0,0 +1,42 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package usage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDay(t *testing.T) {
+	loc := time.FixedZone("UTC+2", 2*60*60)
+
+	assert.Equal(t, "2019-03-01", Day(time.Date(2019, 3, 1, 23, 59, 0, 0, time.UTC)))
+	assert.Equal(t, "2019-02-28", Day(time.Date(2019, 3, 1, 1, 0, 0, 0, loc)))
+}
+
+func TestIsValidDay(t *testing.T) {
+	assert.True(t, IsValidDay("2019-03-01"))
+	assert.False(t, IsValidDay("2019-02-30"))
+	assert.False(t, IsValidDay("2019-3-1"))
+	assert.False(t, IsValidDay(""))
+}
+
+func TestIsValidSort(t *testing.T) {
+	assert.True(t, IsValidSort(SortCalls))
+	assert.True(t, IsValidSort(SortEgressBytes))
+	assert.False(t, IsValidSort("tenant_id"))
+}
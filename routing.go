@@ -15,23 +15,44 @@
 package main
 
 import (
-	"crypto/tls"
+	"context"
 	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
 	"time"
 
 	"github.com/ant0ine/go-json-rest/rest"
+	"github.com/aws/aws-sdk-go/aws/credentials"
 	"github.com/globalsign/mgo"
 	"github.com/pkg/errors"
 
 	"github.com/mendersoftware/deployments/config"
+	"github.com/mendersoftware/deployments/integration"
+	"github.com/mendersoftware/deployments/jwt"
+	"github.com/mendersoftware/deployments/resources/apitokens"
+	apiTokensController "github.com/mendersoftware/deployments/resources/apitokens/controller"
+	apiTokensModel "github.com/mendersoftware/deployments/resources/apitokens/model"
+	apiTokensMongo "github.com/mendersoftware/deployments/resources/apitokens/mongo"
+	"github.com/mendersoftware/deployments/resources/deployments"
 	deploymentsController "github.com/mendersoftware/deployments/resources/deployments/controller"
+	"github.com/mendersoftware/deployments/resources/deployments/events"
 	deploymentsModel "github.com/mendersoftware/deployments/resources/deployments/model"
 	deploymentsMongo "github.com/mendersoftware/deployments/resources/deployments/mongo"
+	"github.com/mendersoftware/deployments/resources/deployments/policy"
+	"github.com/mendersoftware/deployments/resources/deployments/subscriber"
+	subscriberMongo "github.com/mendersoftware/deployments/resources/deployments/subscriber/mongo"
 	deploymentsView "github.com/mendersoftware/deployments/resources/deployments/view"
+	"github.com/mendersoftware/deployments/resources/images"
 	imagesController "github.com/mendersoftware/deployments/resources/images/controller"
+	"github.com/mendersoftware/deployments/resources/images/mirror"
 	imagesModel "github.com/mendersoftware/deployments/resources/images/model"
 	imagesMongo "github.com/mendersoftware/deployments/resources/images/mongo"
 	"github.com/mendersoftware/deployments/resources/images/s3"
+	jobsController "github.com/mendersoftware/deployments/resources/jobs/controller"
+	jobsModel "github.com/mendersoftware/deployments/resources/jobs/model"
+	jobsMongo "github.com/mendersoftware/deployments/resources/jobs/mongo"
 	limitsController "github.com/mendersoftware/deployments/resources/limits/controller"
 	limitsModel "github.com/mendersoftware/deployments/resources/limits/model"
 	limitsMongo "github.com/mendersoftware/deployments/resources/limits/mongo"
@@ -40,6 +61,10 @@ import (
 	tenantsController "github.com/mendersoftware/deployments/resources/tenants/controller"
 	tenantsModel "github.com/mendersoftware/deployments/resources/tenants/model"
 	tenantsStore "github.com/mendersoftware/deployments/resources/tenants/store"
+	usageController "github.com/mendersoftware/deployments/resources/usage/controller"
+	usageModel "github.com/mendersoftware/deployments/resources/usage/model"
+	usageMongo "github.com/mendersoftware/deployments/resources/usage/mongo"
+	"github.com/mendersoftware/deployments/utils/metrics"
 	"github.com/mendersoftware/deployments/utils/restutil"
 	"github.com/mendersoftware/deployments/utils/restutil/view"
 )
@@ -50,65 +75,290 @@ const (
 	ApiUrlDevices    = "/api/devices/v1/deployments"
 
 	ApiUrlManagementArtifacts = ApiUrlManagement + "/artifacts"
+	ApiUrlManagementStream    = ApiUrlManagement + "/deployments/stream"
+
+	// Proxied artifact downloads, authorized by the link signature instead
+	// of the device token
+	ApiUrlDevicesDownload = ApiUrlDevices + "/download"
 )
 
 func SetupS3(c config.ConfigReader) (imagesModel.FileStorage, error) {
 
 	bucket := c.GetString(SettingAwsS3Bucket)
+	uri := c.GetString(SettingAwsURI)
+
+	opts := s3.Options{
+		Region:         c.GetString(SettingAwsS3Region),
+		Endpoint:       uri,
+		ForcePathStyle: uri != "",
+		Credentials:    SetupAwsCredentials(c),
+		TagArtifact:    c.GetBool(SettingsAwsTagArtifact),
+	}
+	if c.IsSet(SettingAwsForcePathStyle) {
+		opts.ForcePathStyle = c.GetBool(SettingAwsForcePathStyle)
+	}
+
+	return s3.NewSimpleStorageService(bucket, opts)
+}
+
+// SetupAwsCredentials creates credentials of artifact storage: static keys,
+// if configured, or credentials of the role assumed with web identity token;
+// the default credential chain of the SDK otherwise. Role configured without
+// web identity token is assumed with these.
+// Role and token of IAM roles for service accounts are taken from
+// the environment, if not configured.
+// Nil is returned for the default credential chain.
+func SetupAwsCredentials(c config.ConfigReader) *credentials.Credentials {
+
 	region := c.GetString(SettingAwsS3Region)
+	roleARN := c.GetString(SettingAwsRoleARN)
+	sessionName := c.GetString(SettingAwsRoleSessionName)
+	tokenFile := c.GetString(SettingAwsRoleWebIdentityTokenFile)
 
+	var creds *credentials.Credentials
 	if c.IsSet(SettingsAwsAuth) || (c.IsSet(SettingAwsAuthKeyId) && c.IsSet(SettingAwsAuthSecret) && c.IsSet(SettingAwsURI)) {
-		return s3.NewSimpleStorageServiceStatic(
-			bucket,
+		creds = credentials.NewStaticCredentials(
 			c.GetString(SettingAwsAuthKeyId),
 			c.GetString(SettingAwsAuthSecret),
-			region,
 			c.GetString(SettingAwsAuthToken),
-			c.GetString(SettingAwsURI),
-			c.GetBool(SettingsAwsTagArtifact),
 		)
+	} else {
+		if roleARN == "" && tokenFile == "" {
+			roleARN = os.Getenv(EnvAwsRoleARN)
+			tokenFile = os.Getenv(EnvAwsWebIdentityTokenFile)
+		}
+		if roleARN != "" && tokenFile != "" {
+			return s3.NewWebIdentityCredentials(region, roleARN, sessionName, tokenFile)
+		}
+	}
+
+	if roleARN != "" {
+		return s3.NewAssumeRoleCredentials(region, creds, roleARN, sessionName)
 	}
 
-	return s3.NewSimpleStorageServiceDefaults(bucket, region)
+	return creds
 }
 
-func NewMongoSession(c config.ConfigReader) (*mgo.Session, error) {
+// SetupDownloadProxy creates device download proxy from configuration,
+// nil if the proxy is not configured.
+func SetupDownloadProxy(c config.ConfigReader) (*deploymentsModel.DownloadProxy, error) {
+	uri := c.GetString(SettingDownloadProxyURL)
+	if uri == "" {
+		return nil, nil
+	}
 
-	dialInfo, err := mgo.ParseURL(c.GetString(SettingMongo))
+	parsed, err := url.Parse(uri)
+	if err != nil || parsed.Scheme == "" || parsed.Host == "" {
+		return nil, errors.Errorf("%s: invalid URL: %s", SettingDownloadProxyURL, uri)
+	}
+
+	secret := c.GetString(SettingDownloadProxySecret)
+	if secret == "" {
+		return nil, errors.Errorf("%s: must not be empty", SettingDownloadProxySecret)
+	}
+
+	return &deploymentsModel.DownloadProxy{
+		URL:    uri,
+		Secret: []byte(secret),
+	}, nil
+}
+
+// SetupMirrors creates artifact download mirror rules from configuration.
+// Network rules take precedence over attribute rules.
+func SetupMirrors(c config.ConfigReader) ([]mirror.Rule, error) {
+	var rules []mirror.Rule
+
+	for _, raw := range c.GetStringSlice(SettingMirrorsNetworks) {
+		rule, err := mirror.ParseNetworkRule(raw)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, *rule)
+	}
+
+	for _, raw := range c.GetStringSlice(SettingMirrorsAttributes) {
+		rule, err := mirror.ParseAttributeRule(raw)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, *rule)
+	}
+
+	return rules, nil
+}
+
+// SetupInventory creates client of the inventory service reachable
+// through the configured gateway address.
+func SetupInventory(c config.ConfigReader) (*integration.MenderAPI, error) {
+	uri := c.GetString(SettingGateway)
+	if !strings.Contains(uri, "://") {
+		uri = "http://" + uri
+	}
+
+	return integration.NewMenderAPI(uri)
+}
+
+// SetupPolicy creates deployment policy hooks from configuration.
+// No hooks are returned if policy endpoint is not configured.
+func SetupPolicy(c config.ConfigReader) ([]deploymentsModel.PreCreateHook,
+	[]deploymentsModel.PreServeHook, error) {
+
+	uri := c.GetString(SettingPolicyURL)
+	if uri == "" {
+		return nil, nil, nil
+	}
+
+	timeout := time.Duration(c.GetInt(SettingPolicyTimeout)) * time.Second
+	endpoint, err := policy.NewHTTPPolicy(uri, &http.Client{Timeout: timeout})
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to open mgo session")
+		return nil, nil, err
 	}
 
-	// Set 10s timeout - same as set by Dial
-	dialInfo.Timeout = 10 * time.Second
+	return []deploymentsModel.PreCreateHook{endpoint},
+		[]deploymentsModel.PreServeHook{endpoint}, nil
+}
+
+// SetupEvents creates deployment event publisher from configuration,
+// delivering events through the job queue.
+// No publisher is returned if webhook is not configured.
+func SetupEvents(c config.ConfigReader,
+	jobs events.JobQueue) (deploymentsModel.EventPublisher, error) {
+
+	uri := c.GetString(SettingEventsWebhookURL)
+	if uri == "" {
+		return nil, nil
+	}
 
-	username := c.GetString(SettingDbUsername)
-	if username != "" {
-		dialInfo.Username = username
+	timeout := time.Duration(c.GetInt(SettingEventsTimeout)) * time.Second
+	webhook, err := events.NewWebhook(uri, c.GetString(SettingEventsBaseURL),
+		&http.Client{Timeout: timeout})
+	if err != nil {
+		return nil, err
 	}
 
-	passward := c.GetString(SettingDbPassword)
-	if passward != "" {
-		dialInfo.Password = passward
+	return events.NewQueue(jobs, webhook), nil
+}
+
+// SetupDeviceNotifier creates publisher of notifications to connected
+// devices, delivered to the bridge through the job queue.
+// No publisher is returned if the bridge is not configured.
+func SetupDeviceNotifier(c config.ConfigReader,
+	jobs events.JobQueue) (deploymentsModel.EventPublisher, error) {
+
+	uri := c.GetString(SettingDeviceNotifyURL)
+	if uri == "" {
+		return nil, nil
 	}
 
-	if c.GetBool(SettingDbSSL) {
-		dialInfo.DialServer = func(addr *mgo.ServerAddr) (net.Conn, error) {
+	timeout := time.Duration(c.GetInt(SettingEventsTimeout)) * time.Second
+	bridge, err := events.NewWebhook(uri, "", &http.Client{Timeout: timeout})
+	if err != nil {
+		return nil, errors.Wrap(err, "device notification bridge")
+	}
 
-			// Setup TLS
-			tlsConfig := &tls.Config{}
-			tlsConfig.InsecureSkipVerify = c.GetBool(SettingDbSSLSkipVerify)
+	return events.NewQueueWithJobType(jobs, events.JobTypeNotifyDevice, bridge), nil
+}
 
-			conn, err := tls.Dial("tcp", addr.String(), tlsConfig)
-			return conn, err
+// SetupFinalizeHooks registers hooks run when deployments reach a terminal
+// state, as configured.
+func SetupFinalizeHooks(c config.ConfigReader, model *deploymentsModel.DeploymentsModel,
+	eventPublisher deploymentsModel.EventPublisher, locker deploymentsModel.ArtifactLocker,
+	statsArchive deploymentsModel.StatsArchiveStorage) error {
+
+	for _, hook := range c.GetStringSlice(SettingFinalizeHooks) {
+		switch hook {
+		case FinalizeHookWebhook:
+			if eventPublisher == nil {
+				return errors.Errorf("%s hook requires %s", hook, SettingEventsWebhookURL)
+			}
+			model.RegisterFinalizeHook(hook,
+				deploymentsModel.NewEventFinalizeHook(eventPublisher))
+		case FinalizeHookWorkflow:
+			timeout := time.Duration(c.GetInt(SettingEventsTimeout)) * time.Second
+			workflow, err := events.NewWorkflow(c.GetString(SettingFinalizeWorkflowURL),
+				&http.Client{Timeout: timeout})
+			if err != nil {
+				return err
+			}
+			model.RegisterFinalizeHook(hook, workflow)
+		case FinalizeHookLockArtifacts:
+			model.RegisterFinalizeHook(hook,
+				deploymentsModel.NewArtifactLockFinalizeHook(locker))
+		case FinalizeHookStatsArchive:
+			model.RegisterFinalizeHook(hook,
+				deploymentsModel.NewStatsArchiveFinalizeHook(statsArchive))
+		default:
+			return errors.Errorf("unsupported finalization hook %s", hook)
 		}
 	}
 
+	return nil
+}
+
+// SetupSlowQueryLog creates log of slow storage calls, explained by
+// the database profiler. No log is returned if the threshold is not set.
+func SetupSlowQueryLog(c config.ConfigReader,
+	session *mgo.Session) *deploymentsModel.SlowQueryLog {
+
+	threshold := time.Duration(c.GetInt(SettingSlowQueriesThreshold)) * time.Millisecond
+	if threshold <= 0 {
+		return nil
+	}
+
+	var explainer deploymentsModel.QueryExplainer
+	sampleRate := c.GetFloat64(SettingSlowQueriesExplainSampleRate)
+	if sampleRate > 0 {
+		explainer = deploymentsMongo.NewProfiler(session, threshold)
+	}
+
+	return deploymentsModel.NewSlowQueryLog(threshold, sampleRate,
+		metrics.NewRecorder(), explainer)
+}
+
+// SetupDeviceEvents creates subscriber of device events from the message
+// bus, decommissioning devices through the job queue.
+// No subscriber is returned if message bus database is not configured.
+func SetupDeviceEvents(c config.ConfigReader, session *mgo.Session,
+	jobs subscriber.JobQueue, model subscriber.Decommissioner) *subscriber.Subscriber {
+
+	database := c.GetString(SettingDeviceEventsDatabase)
+	if database == "" {
+		return nil
+	}
+
+	source := subscriberMongo.NewSource(session, database,
+		c.GetString(SettingDeviceEventsConsumer))
+	return subscriber.NewSubscriber(source, jobs, model)
+}
+
+func NewMongoSession(c config.ConfigReader) (*mgo.Session, error) {
+	return newMongoSession(c, c.GetString(SettingMongo))
+}
+
+func newMongoSession(c config.ConfigReader, url string) (*mgo.Session, error) {
+
+	dialInfo, err := NewMongoDialInfo(c, net.DefaultResolver, url)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to open mgo session")
+	}
+
+	x509Cred, err := mongoX509Credential(c, dialInfo)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to open mgo session")
+	}
+
 	masterSession, err := mgo.DialWithInfo(dialInfo)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to open mgo session")
 	}
 
+	if x509Cred != nil {
+		if err := masterSession.Login(x509Cred); err != nil {
+			masterSession.Close()
+			return nil, errors.Wrap(err, "failed to open mgo session")
+		}
+	}
+
 	// Validate connection
 	if err := masterSession.Ping(); err != nil {
 		return nil, errors.Wrap(err, "failed to open mgo session")
@@ -123,6 +373,27 @@ func NewMongoSession(c config.ConfigReader) (*mgo.Session, error) {
 	return masterSession, nil
 }
 
+// NewMongoReplicaSession creates session reading from secondary members
+// of the replica set, with its own connection pool.
+// No session is returned if replica reads are not configured.
+func NewMongoReplicaSession(c config.ConfigReader) (*mgo.Session, error) {
+	url := c.GetString(SettingMongoReplicaURL)
+	if url == "" {
+		return nil, nil
+	}
+
+	session, err := newMongoSession(c, url)
+	if err != nil {
+		return nil, err
+	}
+	session.SetMode(mgo.SecondaryPreferred, true)
+	if limit := c.GetInt(SettingMongoReplicaPoolLimit); limit > 0 {
+		session.SetPoolLimit(limit)
+	}
+
+	return session, nil
+}
+
 // NewRouter defines all REST API routes.
 func NewRouter(c config.ConfigReader) (rest.App, error) {
 
@@ -136,33 +407,201 @@ func NewRouter(c config.ConfigReader) (rest.App, error) {
 	if err != nil {
 		return nil, err
 	}
-	deploymentsStorage := deploymentsMongo.NewDeploymentsStorage(dbSession)
-	deviceDeploymentsStorage := deploymentsMongo.NewDeviceDeploymentsStorage(dbSession)
+	mirrors, err := SetupMirrors(c)
+	if err != nil {
+		return nil, err
+	}
+	downloadProxy, err := SetupDownloadProxy(c)
+	if err != nil {
+		return nil, err
+	}
+	// mirrors are applied per request on top of the (cached) storage links
+	var linkRewriter deploymentsModel.LinkRewriter
+	if len(mirrors) > 0 {
+		linkRewriter = mirror.NewLinker(fileStorage, mirrors...)
+	}
+
+	inventory, err := SetupInventory(c)
+	if err != nil {
+		return nil, err
+	}
+
+	preCreateHooks, preServeHooks, err := SetupPolicy(c)
+	if err != nil {
+		return nil, err
+	}
+
+	jobsStorage := jobsMongo.NewJobsStorage(dbSession)
+	jobsModel := jobsModel.NewJobsModel(jobsStorage, jobsModel.Config{
+		Workers:     c.GetInt(SettingJobsWorkers),
+		MaxAttempts: c.GetInt(SettingJobsMaxAttempts),
+		Backoff:     time.Duration(c.GetInt(SettingJobsBackoff)) * time.Second,
+	})
+
+	// Usage accounting, optional
+	var usageRecorder *usageModel.UsageModel
+	var egress deploymentsModel.EgressRecorder
+	if c.GetInt(SettingUsageFlushInterval) > 0 {
+		usageRecorder = usageModel.NewUsageModel(usageMongo.NewUsageStorage(dbSession))
+		egress = usageRecorder
+	}
+
+	eventPublisher, err := SetupEvents(c, jobsModel)
+	if err != nil {
+		return nil, err
+	}
+
+	deviceNotifier, err := SetupDeviceNotifier(c, jobsModel)
+	if err != nil {
+		return nil, err
+	}
+
+	statusNames, err := deployments.NewStatusNames(c.GetStringMapString(SettingStatusNames))
+	if err != nil {
+		return nil, err
+	}
+
+	var deploymentsStorage deploymentsModel.DeploymentsStorage
+	var deviceDeploymentsStorage deploymentsModel.DeviceDeploymentStorage
+	deploymentsStorage = deploymentsMongo.NewDeploymentsStorage(dbSession)
+	deviceDeploymentsStorage = deploymentsMongo.NewDeviceDeploymentsStorage(dbSession)
+	slowQueries := SetupSlowQueryLog(c, dbSession)
+	if slowQueries != nil {
+		deploymentsStorage = deploymentsModel.NewSlowQueryDeploymentsStorage(
+			deploymentsStorage, slowQueries)
+		deviceDeploymentsStorage = deploymentsModel.NewSlowQueryDeviceDeploymentStorage(
+			deviceDeploymentsStorage, slowQueries)
+	}
+	if url := c.GetString(SettingDualWriteMongo); url != "" {
+		shadowSession, err := newMongoSession(c, url)
+		if err != nil {
+			return nil, errors.Wrap(err, "dual write")
+		}
+		deploymentsStorage = deploymentsModel.NewDualWriteDeploymentsStorage(
+			deploymentsStorage, deploymentsMongo.NewDeploymentsStorage(shadowSession))
+	}
 	deviceDeploymentLogsStorage := deploymentsMongo.NewDeviceDeploymentLogsStorage(dbSession)
+	freezePeriodsStorage := deploymentsMongo.NewFreezePeriodsStorage(dbSession)
+	deviceTypeAliasesStorage := deploymentsMongo.NewDeviceTypeAliasesStorage(dbSession)
+	installedArtifactsStorage := deploymentsMongo.NewInstalledArtifactsStorage(dbSession)
+	telemetryStorage := deploymentsMongo.NewTelemetryStorage(dbSession)
+	settingsStorage := deploymentsMongo.NewSettingsStorage(dbSession)
+	downloadsStorage := deploymentsMongo.NewDownloadsStorage(dbSession)
+	deploymentDevicesStorage := deploymentsMongo.NewDeploymentDevicesStorage(dbSession)
+	campaignsStorage := deploymentsMongo.NewCampaignsStorage(dbSession)
+	alertsStorage := deploymentsMongo.NewAlertsStorage(dbSession)
 	imagesStorage := imagesMongo.NewSoftwareImagesStorage(dbSession)
 	limitsStorage := limitsMongo.NewLimitsStorage(dbSession)
 	tenantsStorage := tenantsStore.NewStore(dbSession)
 	releasesStorage := releasesStore.NewStore(dbSession)
 
+	replicaSession, err := NewMongoReplicaSession(c)
+	if err != nil {
+		return nil, errors.Wrap(err, "read replica")
+	}
+	var replicaDeploymentsStorage deploymentsModel.DeploymentsStorage
+	var replicaDeviceDeploymentsStorage deploymentsModel.DeviceDeploymentStorage
+	if replicaSession != nil {
+		replicaDeploymentsStorage = deploymentsMongo.NewDeploymentsStorage(replicaSession)
+		replicaDeviceDeploymentsStorage = deploymentsMongo.NewDeviceDeploymentsStorage(replicaSession)
+	}
+
+	versionParser, err := images.NewVersionParser(c.GetString(SettingArtifactVersionPattern))
+	if err != nil {
+		return nil, errors.Wrap(err, SettingArtifactVersionPattern)
+	}
+
+	var downloadMetrics *deploymentsModel.DownloadMetrics
+	if c.GetBool(SettingMetricsDownloads) {
+		downloadMetrics = deploymentsModel.NewDownloadMetrics()
+	}
+
 	// Domain Models
+	limitsModel := limitsModel.NewLimitsModel(limitsStorage)
 	deploymentModel := deploymentsModel.NewDeploymentModel(deploymentsModel.DeploymentsModelConfig{
 		DeploymentsStorage:          deploymentsStorage,
 		DeviceDeploymentsStorage:    deviceDeploymentsStorage,
 		DeviceDeploymentLogsStorage: deviceDeploymentLogsStorage,
 		ImageLinker:                 fileStorage,
+		LinkRewriter:                linkRewriter,
+		DownloadProxy:               downloadProxy,
 		ArtifactGetter:              imagesStorage,
+		Inventory:                   inventory,
 		ImageContentType:            imagesModel.ArtifactContentType,
+		PreCreateHooks:              preCreateHooks,
+		PreServeHooks:               preServeHooks,
+		EventPublisher:              eventPublisher,
+		ArtifactRestorer:            fileStorage,
+		ArtifactRestoreDays:         int64(c.GetInt(SettingAwsArchiveRestoreDays)),
+		FreezePeriodsStorage:        freezePeriodsStorage,
+		DeviceTypeAliasesStorage:    deviceTypeAliasesStorage,
+		InstalledArtifactsStorage:   installedArtifactsStorage,
+		Limits:                      limitsModel,
+		TelemetryStorage:            telemetryStorage,
+		TelemetrySampleRate:         c.GetFloat64(SettingTelemetrySampleRate),
+		DownloadMetrics:             downloadMetrics,
+		SettingsStorage:             settingsStorage,
+		AlertsStorage:               alertsStorage,
+		DownloadsStorage:            downloadsStorage,
+		DeviceLogsSearch:            c.GetBool(SettingDeviceLogsSearch),
+		ArtifactLinkCacheTTL:        time.Duration(c.GetInt(SettingArtifactLinkCacheTTL)) * time.Second,
+		Jobs:                        jobsModel,
+		DeploymentDevicesStorage:    deploymentDevicesStorage,
+		CampaignsStorage:            campaignsStorage,
+		LazyDevicesThreshold:        c.GetInt(SettingLazyDevicesThreshold),
+		ScriptsAckTenants:           c.GetStringSlice(SettingScriptsAckTenants),
+		DeviceExternalIDAttribute:   c.GetString(SettingDeviceExternalID),
+		InventorySnapshot:           c.GetStringSlice(SettingDeviceInventorySnapshot),
+
+		ReplicaDeploymentsStorage:       replicaDeploymentsStorage,
+		ReplicaDeviceDeploymentsStorage: replicaDeviceDeploymentsStorage,
+		StatusBatchWindow: time.Duration(c.GetInt(SettingStatusBatchWindow)) *
+			time.Millisecond,
+		DeviceNotifier: deviceNotifier,
+		Egress:         egress,
+		MaxRetries:     c.GetInt(SettingDeviceDeploymentMaxRetries),
+		VersionParser:  versionParser,
 	})
 
+	parserLimits := imagesModel.ParserLimits{
+		Workers:   c.GetInt(SettingArtifactParserWorkers),
+		QueueSize: c.GetInt(SettingArtifactParserQueueSize),
+		Timeout:   time.Duration(c.GetInt(SettingArtifactParserTimeout)) * time.Second,
+		Rate:      int64(c.GetInt(SettingArtifactParserRate)),
+	}
+
+	deviceEvents := SetupDeviceEvents(c, dbSession, jobsModel, deploymentModel)
+
 	imagesModel := imagesModel.NewImagesModel(fileStorage, deploymentModel, imagesStorage)
-	limitsModel := limitsModel.NewLimitsModel(limitsStorage)
+	imagesModel.SetParserLimits(parserLimits)
+	imagesModel.SetTrashRetention(
+		time.Duration(c.GetInt(SettingArtifactTrashDays)) * 24 * time.Hour)
+	imagesModel.SetJobQueue(jobsModel)
+	imagesModel.SetVersionParser(versionParser)
+	if usageRecorder != nil {
+		imagesModel.SetEgressRecorder(usageRecorder)
+	}
+	if err := SetupFinalizeHooks(c, deploymentModel, eventPublisher, imagesModel,
+		deploymentsMongo.NewStatsArchiveStorage(dbSession)); err != nil {
+		return nil, errors.Wrap(err, "finalization hooks")
+	}
 	tenantsModel := tenantsModel.NewModel(tenantsStorage)
 
 	// Controllers
 	imagesController := imagesController.NewSoftwareImagesController(imagesModel,
 		new(view.RESTView))
-	deploymentsController := deploymentsController.NewDeploymentsController(deploymentModel,
-		new(deploymentsView.DeploymentsView))
+	imagesController.SetLimits(limitsModel)
+	modelMetrics := metrics.NewRecorder()
+	if top := c.GetInt(SettingMetricsTenantsTop); top > 0 {
+		modelMetrics = metrics.NewTenantRecorder(top,
+			c.GetInt(SettingMetricsTenantsMaxTracked))
+	}
+	deploymentsController := deploymentsController.NewDeploymentsController(
+		deploymentsModel.NewMetricsModel(deploymentModel, modelMetrics),
+		&deploymentsView.DeploymentsView{StatusNames: statusNames})
+	deploymentsController.SetStreamInterval(
+		time.Duration(c.GetInt(SettingDashboardStreamInterval)) * time.Second)
+	deploymentsController.SetLimits(limitsModel)
 	limitsController := limitsController.NewLimitsController(limitsModel,
 		new(view.RESTView))
 
@@ -171,25 +610,120 @@ func NewRouter(c config.ConfigReader) (rest.App, error) {
 		imagesModel,
 		imagesController,
 		new(view.RESTView))
+	tenantsController.SetMetricsDeploymentsMax(c.GetInt(SettingMetricsDeploymentsMax))
 
 	releasesController := releasesController.NewReleasesController(releasesStorage, new(view.RESTView))
+	jobsController := jobsController.NewJobsController(jobsModel, new(view.RESTView))
+	var usageCtrl *usageController.UsageController
+	if usageRecorder != nil {
+		usageCtrl = usageController.NewUsageController(usageRecorder, new(view.RESTView))
+	}
+	apiTokens := apiTokensModel.NewAPITokensModel(apiTokensMongo.NewAPITokensStorage(dbSession))
+	apiTokensCtrl := apiTokensController.NewAPITokensController(apiTokens, new(view.RESTView))
 
 	// Routing
-	imageRoutes := NewImagesResourceRoutes(imagesController)
-	deploymentsRoutes := NewDeploymentsResourceRoutes(deploymentsController)
+	imageRoutes := NewImagesResourceRoutes(imagesController,
+		c.GetString(SettingArtifactUnlockRole))
+	deploymentsRoutes := NewDeploymentsResourceRoutes(deploymentsController,
+		c.GetString(SettingFreezeOverrideRole))
 	limitsRoutes := NewLimitsResourceRoutes(limitsController)
 	tenantsRoutes := TenantRoutes(tenantsController)
 	releasesRoutes := ReleasesRoutes(releasesController)
+	var slowQueryMetrics *metrics.Recorder
+	if slowQueries != nil {
+		slowQueryMetrics = slowQueries.Recorder
+	}
+	metricsRoutes := MetricsRoutes(modelMetrics, slowQueryMetrics)
+	if c.GetInt(SettingMetricsTenantsTop) > 0 {
+		metricsRoutes = append(metricsRoutes, rest.Get(ApiUrlInternal+"/metrics/model/tenants",
+			modelMetrics.GetTenantSnapshot))
+	}
+	if c.GetInt(SettingMetricsDeploymentsMax) > 0 {
+		metricsRoutes = append(metricsRoutes, rest.Get(ApiUrlInternal+"/metrics/deployments",
+			tenantsController.DeploymentsMetricsHandler))
+	}
+	if downloadMetrics != nil {
+		metricsRoutes = append(metricsRoutes, rest.Get(ApiUrlInternal+"/metrics/downloads",
+			metrics.OpenMetricsHandler(downloadMetrics.Families()...)))
+	}
+	jobsRoutes := JobsRoutes(jobsController)
+	usageRoutes := UsageRoutes(usageCtrl)
+	apiTokensRoutes := APITokensRoutes(apiTokensCtrl)
+	bodyLogToggles := restutil.NewBodyLogToggles()
+	debugRoutes := DebugRoutes(bodyLogToggles)
 
 	routes := append(releasesRoutes, deploymentsRoutes...)
 	routes = append(routes, limitsRoutes...)
 	routes = append(routes, tenantsRoutes...)
 	routes = append(routes, imageRoutes...)
+	routes = append(routes, metricsRoutes...)
+	routes = append(routes, jobsRoutes...)
+	routes = append(routes, usageRoutes...)
+	routes = append(routes, apiTokensRoutes...)
+	routes = append(routes, debugRoutes...)
+
+	// all job handlers are registered
+	jobsModel.Start(context.Background())
+	if deviceEvents != nil {
+		go deviceEvents.Run(context.Background())
+	}
+	if interval := c.GetInt(SettingAlertsInterval); interval > 0 && eventPublisher != nil {
+		deploymentModel.StartAlerts(context.Background(),
+			time.Duration(interval)*time.Second, tenantsStorage)
+	}
+	if interval := c.GetInt(SettingPhasesInterval); interval > 0 {
+		deploymentModel.StartPhases(context.Background(),
+			time.Duration(interval)*time.Second, tenantsStorage)
+	}
 
-	return rest.MakeRouter(restutil.AutogenOptionsRoutes(restutil.NewOptionsHandler, routes...)...)
+	router, err := rest.MakeRouter(restutil.AutogenOptionsRoutes(restutil.NewOptionsHandler, routes...)...)
+	if err != nil {
+		return nil, err
+	}
+
+	// bodies of device requests are logged while toggled, after the
+	// identity of the request is known
+	bodyLogMiddleware := &restutil.BodyLogMiddleware{
+		Toggles: bodyLogToggles,
+		Prefix:  ApiUrlDevices,
+	}
+	handler := bodyLogMiddleware.MiddlewareFunc(router.AppFunc())
+	if usageRecorder != nil {
+		usageRecorder.Start(context.Background(),
+			time.Duration(c.GetInt(SettingUsageFlushInterval))*time.Second)
+
+		// calls are counted by the router, after the identity of the
+		// request is known
+		usageMiddleware := &usageController.UsageMiddleware{Model: usageRecorder}
+		handler = usageMiddleware.MiddlewareFunc(handler)
+	}
+
+	// identity of requests with API tokens is known only once the token
+	// is found, before anything else handled by the router
+	apiTokenMiddleware := &apiTokensController.APITokenMiddleware{
+		Model: apiTokens,
+		Rules: APITokenScopeRules,
+	}
+	return rest.AppSimple(apiTokenMiddleware.MiddlewareFunc(handler)), nil
+}
+
+// APITokenScopeRules lists requests API tokens of the scopes can be used
+// for; API tokens are rejected on other routes.
+var APITokenScopeRules = []apitokens.ScopeRule{
+	{
+		Method: http.MethodPost,
+		Path:   ApiUrlManagementArtifacts,
+		Scope:  apitokens.ScopeArtifactsUpload,
+	},
+	{
+		Method: http.MethodPost,
+		Path:   ApiUrlManagement + "/deployments",
+		Scope:  apitokens.ScopeDeploymentsCreate,
+	},
 }
 
-func NewImagesResourceRoutes(controller *imagesController.SoftwareImagesController) []*rest.Route {
+func NewImagesResourceRoutes(controller *imagesController.SoftwareImagesController,
+	unlockRole string) []*rest.Route {
 
 	if controller == nil {
 		return []*rest.Route{}
@@ -199,15 +733,36 @@ func NewImagesResourceRoutes(controller *imagesController.SoftwareImagesControll
 		rest.Post(ApiUrlManagementArtifacts, controller.NewImage),
 		rest.Get(ApiUrlManagementArtifacts, controller.ListImages),
 
+		rest.Post(ApiUrlManagement+"/artifacts/fetch", controller.FetchImage),
+		rest.Get(ApiUrlManagement+"/artifacts/fetch/:id", controller.GetFetch),
+
+		rest.Get(ApiUrlManagement+"/artifacts/compatibility", controller.GetCompatibility),
+		rest.Get(ApiUrlManagement+"/artifacts/latest", controller.GetLatestImage),
+
+		rest.Get(ApiUrlManagement+"/artifacts/naming-policy", controller.GetNamingPolicy),
+		rest.Put(ApiUrlManagement+"/artifacts/naming-policy", controller.PutNamingPolicy),
+
+		rest.Get(ApiUrlManagement+"/artifacts/trash", controller.ListTrashedImages),
+		rest.Post(ApiUrlManagement+"/artifacts/trash/:id/restore", controller.RestoreImage),
+		rest.Delete(ApiUrlManagement+"/artifacts/trash/:id", controller.PurgeImage),
+
 		rest.Get(ApiUrlManagement+"/artifacts/:id", controller.GetImage),
 		rest.Delete(ApiUrlManagement+"/artifacts/:id", controller.DeleteImage),
 		rest.Put(ApiUrlManagement+"/artifacts/:id", controller.EditImage),
 
+		rest.Put(ApiUrlManagement+"/artifacts/:id/lock", controller.LockImage),
+		rest.Delete(ApiUrlManagement+"/artifacts/:id/lock",
+			jwt.RequireRole(unlockRole, controller.UnlockImage)),
+
 		rest.Get(ApiUrlManagement+"/artifacts/:id/download", controller.DownloadLink),
+		rest.Get(ApiUrlManagement+"/artifacts/:id/contents", controller.GetImageContents),
+
+		rest.Get(ApiUrlInternal+"/artifacts/parser", controller.GetParserStats),
 	}
 }
 
-func NewDeploymentsResourceRoutes(controller *deploymentsController.DeploymentsController) []*rest.Route {
+func NewDeploymentsResourceRoutes(controller *deploymentsController.DeploymentsController,
+	overrideFreezeRole string) []*rest.Route {
 
 	if controller == nil {
 		return []*rest.Route{}
@@ -217,23 +772,88 @@ func NewDeploymentsResourceRoutes(controller *deploymentsController.DeploymentsC
 
 		// Deployments
 		rest.Post(ApiUrlManagement+"/deployments", controller.PostDeployment),
+		rest.Post(ApiUrlManagement+"/deployments/override-freeze",
+			jwt.RequireRole(overrideFreezeRole, controller.PostDeploymentOverrideFreeze)),
 		rest.Get(ApiUrlManagement+"/deployments", controller.LookupDeployment),
-		rest.Get(ApiUrlManagement+"/deployments/:id", controller.GetDeployment),
-		rest.Get(ApiUrlManagement+"/deployments/:id/statistics", controller.GetDeploymentStats),
-		rest.Put(ApiUrlManagement+"/deployments/:id/status", controller.AbortDeployment),
+		rest.Get(ApiUrlManagement+"/deployments/stats/summary", controller.GetStatsSummary),
+		rest.Get(ApiUrlManagement+"/deployments/devices",
+			controller.LookupDeviceDeployments),
+		rest.Get(ApiUrlManagement+"/deployments/downloads", controller.LookupDownloads),
+		rest.Get(ApiUrlManagementStream, controller.StreamDeployments),
+		rest.Get(ApiUrlManagement+"/deployments/:id",
+			controller.ResolveSlug(controller.GetDeployment)),
+		rest.Get(ApiUrlManagement+"/deployments/:id/statistics",
+			controller.ResolveSlug(controller.GetDeploymentStats)),
+		rest.Get(ApiUrlManagement+"/deployments/:id/statistics/groups",
+			controller.ResolveSlug(controller.GetDeploymentStatsByGroup)),
+		rest.Get(ApiUrlManagement+"/deployments/:id/statistics/failures",
+			controller.ResolveSlug(controller.GetDeploymentFailureStats)),
+		rest.Get(ApiUrlManagement+"/deployments/:id/statistics/durations",
+			controller.ResolveSlug(controller.GetDeploymentDurationStats)),
+		rest.Get(ApiUrlManagement+"/deployments/:id/statistics/telemetry",
+			controller.ResolveSlug(controller.GetDeploymentTelemetryStats)),
+		rest.Get(ApiUrlManagement+"/deployments/:id/statistics/phases",
+			controller.ResolveSlug(controller.GetDeploymentPhaseStats)),
+		rest.Put(ApiUrlManagement+"/deployments/:id/status",
+			controller.ResolveSlug(controller.AbortDeployment)),
+		rest.Post(ApiUrlManagement+"/deployments/:id/finalize",
+			controller.ResolveSlug(controller.ForceFinishDeployment)),
+		rest.Get(ApiUrlManagement+"/deployments/:id/devices/count",
+			controller.ResolveSlug(controller.GetDeviceDeploymentsCount)),
 		rest.Get(ApiUrlManagement+"/deployments/:id/devices",
-			controller.GetDeviceStatusesForDeployment),
+			controller.ResolveSlug(controller.GetDeviceStatusesForDeployment)),
 		rest.Get(ApiUrlManagement+"/deployments/:id/devices/:devid/log",
-			controller.GetDeploymentLogForDevice),
+			controller.ResolveSlug(controller.GetDeploymentLogForDevice)),
+		rest.Delete(ApiUrlManagement+"/deployments/:id/devices/:devid/link",
+			controller.ResolveSlug(controller.RevokeDeviceDeploymentLink)),
+		rest.Post(ApiUrlManagement+"/deployments/:id/devices/:devid/retry",
+			controller.ResolveSlug(controller.RetryDeviceDeployment)),
+		rest.Get(ApiUrlManagement+"/deployments/:id/logs/search",
+			controller.ResolveSlug(controller.SearchDeploymentLogs)),
 		rest.Delete(ApiUrlManagement+"/deployments/devices/:id",
 			controller.DecommissionDevice),
 
+		// Freeze periods
+		rest.Post(ApiUrlManagement+"/freeze-periods", controller.PostFreezePeriod),
+		rest.Get(ApiUrlManagement+"/freeze-periods", controller.GetFreezePeriods),
+		rest.Delete(ApiUrlManagement+"/freeze-periods/:id", controller.DeleteFreezePeriod),
+
+		// Device type aliases
+		rest.Get(ApiUrlManagement+"/device-types/aliases", controller.GetDeviceTypeAliases),
+		rest.Put(ApiUrlManagement+"/device-types/aliases/:alias", controller.PutDeviceTypeAlias),
+		rest.Delete(ApiUrlManagement+"/device-types/aliases/:alias",
+			controller.DeleteDeviceTypeAlias),
+
+		// Alerts
+		rest.Post(ApiUrlManagement+"/alerts", controller.PostAlert),
+		rest.Get(ApiUrlManagement+"/alerts", controller.GetAlerts),
+		rest.Delete(ApiUrlManagement+"/alerts/:id", controller.DeleteAlert),
+
+		// Tenant defaults of deployment parameters
+		rest.Get(ApiUrlManagement+"/settings", controller.GetDeploymentSettings),
+		rest.Put(ApiUrlManagement+"/settings", controller.PutDeploymentSettings),
+
+		// Campaigns
+		rest.Post(ApiUrlManagement+"/campaigns", controller.PostCampaign),
+		rest.Get(ApiUrlManagement+"/campaigns", controller.GetCampaigns),
+		rest.Get(ApiUrlManagement+"/campaigns/:id", controller.GetCampaign),
+		rest.Get(ApiUrlManagement+"/campaigns/:id/statistics", controller.GetCampaignStats),
+		rest.Put(ApiUrlManagement+"/campaigns/:id/status", controller.PutCampaignStatus),
+
 		// Devices
 		rest.Get(ApiUrlDevices+"/device/deployments/next", controller.GetDeploymentForDevice),
 		rest.Put(ApiUrlDevices+"/device/deployments/:id/status",
 			controller.PutDeploymentStatusForDevice),
 		rest.Put(ApiUrlDevices+"/device/deployments/:id/log",
 			controller.PutDeploymentLogForDevice),
+		rest.Get(ApiUrlDevicesDownload+"/:id/:devid",
+			controller.GetDeviceDeploymentDownload),
+
+		// Internal
+		rest.Get(ApiUrlInternal+"/devices/:id/deployments/last",
+			controller.GetLatestDeviceDeployment),
+		rest.Post(ApiUrlInternal+"/devices/deployments/active",
+			controller.GetActiveDeviceDeployments),
 	}
 }
 
@@ -246,6 +866,63 @@ func NewLimitsResourceRoutes(controller *limitsController.LimitsController) []*r
 	return []*rest.Route{
 		// limits
 		rest.Get(ApiUrlManagement+"/limits/:name", controller.GetLimit),
+		rest.Get(ApiUrlInternal+"/tenants/:tenant/limits/:name", controller.GetTenantLimit),
+		rest.Put(ApiUrlInternal+"/tenants/:tenant/limits/:name", controller.SetTenantLimit),
+	}
+}
+
+// MetricsRoutes exposes calls statistics of the domain models and,
+// if recorded, of slow storage calls.
+func MetricsRoutes(modelMetrics, slowQueryMetrics *metrics.Recorder) []*rest.Route {
+	routes := []*rest.Route{
+		rest.Get(ApiUrlInternal+"/metrics/model", modelMetrics.GetSnapshot),
+	}
+	if slowQueryMetrics != nil {
+		routes = append(routes, rest.Get(ApiUrlInternal+"/metrics/slow_queries",
+			slowQueryMetrics.GetSnapshot))
+	}
+	return routes
+}
+
+// JobsRoutes exposes background jobs for inspection.
+func JobsRoutes(controller *jobsController.JobsController) []*rest.Route {
+	if controller == nil {
+		return []*rest.Route{}
+	}
+
+	return []*rest.Route{
+		rest.Get(ApiUrlInternal+"/jobs", controller.ListJobs),
+	}
+}
+
+func APITokensRoutes(controller *apiTokensController.APITokensController) []*rest.Route {
+	if controller == nil {
+		return []*rest.Route{}
+	}
+
+	return []*rest.Route{
+		rest.Post(ApiUrlManagement+"/api-tokens", controller.CreateToken),
+		rest.Get(ApiUrlManagement+"/api-tokens", controller.ListTokens),
+		rest.Delete(ApiUrlManagement+"/api-tokens/:id", controller.RevokeToken),
+	}
+}
+
+func UsageRoutes(controller *usageController.UsageController) []*rest.Route {
+	if controller == nil {
+		return []*rest.Route{}
+	}
+
+	return []*rest.Route{
+		rest.Get(ApiUrlInternal+"/usage", controller.ListUsage),
+	}
+}
+
+// DebugRoutes toggle diagnostic logging of the service instance.
+func DebugRoutes(bodyLog *restutil.BodyLogToggles) []*rest.Route {
+	return []*rest.Route{
+		rest.Get(ApiUrlInternal+"/debug/body-log", bodyLog.ListToggles),
+		rest.Put(ApiUrlInternal+"/debug/body-log", bodyLog.SetToggle),
+		rest.Delete(ApiUrlInternal+"/debug/body-log", bodyLog.DeleteToggle),
 	}
 }
 
@@ -258,6 +935,8 @@ func TenantRoutes(controller *tenantsController.Controller) []*rest.Route {
 		rest.Post(ApiUrlInternal+"/tenants", controller.ProvisionTenantsHandler),
 		rest.Get(ApiUrlInternal+"/tenants/:tenant/deployments", controller.DeploymentsPerTenantHandler),
 		rest.Post(ApiUrlInternal+"/tenants/:tenant/artifacts", controller.NewImageForTenantHandler),
+		rest.Delete(ApiUrlInternal+"/devices/:id/data", controller.PurgeDeviceDataHandler),
+		rest.Get(ApiUrlInternal+"/reports/over-quota", controller.OverQuotaReportHandler),
 	}
 }
 
@@ -0,0 +1,86 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/ant0ine/go-json-rest/rest"
+	"github.com/ant0ine/go-json-rest/rest/test"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mendersoftware/deployments/jwt"
+	"github.com/mendersoftware/deployments/resources/deployments/controller"
+	"github.com/mendersoftware/deployments/resources/deployments/controller/mocks"
+	"github.com/mendersoftware/deployments/resources/deployments/view"
+)
+
+func TestDeploymentsRoutesOverrideFreeze(t *testing.T) {
+
+	t.Parallel()
+
+	token := func(roles ...string) string {
+		hdr, _ := json.Marshal(map[string]string{"alg": "RS256"})
+		payload, _ := json.Marshal(map[string]interface{}{
+			"sub":          "user",
+			jwt.RolesClaim: roles,
+		})
+		return base64.RawURLEncoding.EncodeToString(hdr) + "." +
+			base64.RawURLEncoding.EncodeToString(payload) + ".c2ln"
+	}
+
+	testCases := map[string]struct {
+		Authorization string
+		Code          int
+	}{
+		"has role": {
+			Authorization: "Bearer " + token("RBAC_ROLE_OBSERVER", "release-manager"),
+			// empty body is rejected by the controller
+			Code: http.StatusBadRequest,
+		},
+		"other roles": {
+			Authorization: "Bearer " + token("RBAC_ROLE_OBSERVER"),
+			Code:          http.StatusForbidden,
+		},
+		"missing header": {
+			Code: http.StatusUnauthorized,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			model := new(mocks.DeploymentsModel)
+			defer model.AssertExpectations(t)
+
+			c := controller.NewDeploymentsController(model, new(view.DeploymentsView))
+
+			api := rest.NewApi()
+			router, err := rest.MakeRouter(NewDeploymentsResourceRoutes(c, "release-manager")...)
+			assert.NoError(t, err)
+			api.SetApp(router)
+
+			req := test.MakeSimpleRequest(http.MethodPost,
+				"http://localhost"+ApiUrlManagement+"/deployments/override-freeze", nil)
+			if tc.Authorization != "" {
+				req.Header.Set("Authorization", tc.Authorization)
+			}
+			recorded := test.RunRequest(t, api.MakeHandler(), req)
+			recorded.CodeIs(tc.Code)
+		})
+	}
+}
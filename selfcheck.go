@@ -0,0 +1,402 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/pkg/errors"
+	"github.com/satori/go.uuid"
+
+	"github.com/mendersoftware/deployments/authz"
+	"github.com/mendersoftware/deployments/config"
+	"github.com/mendersoftware/deployments/resources/deployments"
+	"github.com/mendersoftware/deployments/resources/deployments/events"
+	"github.com/mendersoftware/deployments/resources/images"
+)
+
+// Prefix of the object written to the artifact storage by the storage probe.
+const selfCheckProbePrefix = "deployments-selfcheck-"
+
+// SelfCheck is a single named startup check.
+type SelfCheck struct {
+	Name  string
+	Check func(c config.ConfigReader) error
+}
+
+// SelfCheckResult is an outcome of a single startup check.
+type SelfCheckResult struct {
+	Name  string
+	Error error
+}
+
+// SelfChecks returns all startup checks: configuration consistency first,
+// followed by database connectivity and artifact storage access.
+func SelfChecks() []SelfCheck {
+	return []SelfCheck{
+		{Name: "config: aws auth", Check: ValidateAwsAuth},
+		{Name: "config: aws role", Check: ValidateAwsRole},
+		{Name: "config: https", Check: ValidateHttps},
+		{Name: "config: mirrors", Check: ValidateMirrors},
+		{Name: "config: mongo", Check: ValidateMongo},
+		{Name: "config: middleware", Check: checkMiddleware},
+		{Name: "config: jwt", Check: checkJWT},
+		{Name: "config: authz", Check: checkAuthz},
+		{Name: "config: timeouts", Check: checkTimeouts},
+		{Name: "config: policy", Check: checkPolicy},
+		{Name: "config: events", Check: checkEvents},
+		{Name: "config: download proxy", Check: checkDownloadProxy},
+		{Name: "config: jobs", Check: checkJobs},
+		{Name: "config: lazy devices", Check: checkLazyDevices},
+		{Name: "config: status names", Check: checkStatusNames},
+		{Name: "config: status batch", Check: checkStatusBatch},
+		{Name: "config: dashboard stream", Check: checkDashboardStream},
+		{Name: "config: device deployment partitions", Check: checkDevicePartitions},
+		{Name: "config: slow queries", Check: checkSlowQueries},
+		{Name: "config: artifact trash", Check: checkArtifactTrash},
+		{Name: "config: artifact unlock role", Check: checkArtifactUnlockRole},
+		{Name: "config: freeze override role", Check: checkFreezeOverrideRole},
+		{Name: "config: artifact version pattern", Check: checkArtifactVersionPattern},
+		{Name: "config: telemetry", Check: checkTelemetry},
+		{Name: "config: tenant metrics", Check: checkMetricsTenants},
+		{Name: "config: deployment metrics", Check: checkMetricsDeployments},
+		{Name: "config: alerts", Check: checkAlerts},
+		{Name: "config: phases", Check: checkPhases},
+		{Name: "config: finalization hooks", Check: checkFinalize},
+		{Name: "config: usage accounting", Check: checkUsage},
+		{Name: "mongo", Check: checkMongo},
+		{Name: "storage", Check: checkStorage},
+	}
+}
+
+// RunSelfChecks runs all checks, writes report line per check to out
+// and returns results in order of checks.
+func RunSelfChecks(c config.ConfigReader, out io.Writer,
+	checks []SelfCheck) []SelfCheckResult {
+
+	results := make([]SelfCheckResult, 0, len(checks))
+	for _, check := range checks {
+		err := check.Check(c)
+		if err != nil {
+			fmt.Fprintf(out, "[FAIL] %s: %v\n", check.Name, err)
+		} else {
+			fmt.Fprintf(out, "[ OK ] %s\n", check.Name)
+		}
+		results = append(results, SelfCheckResult{Name: check.Name, Error: err})
+	}
+
+	return results
+}
+
+// SelfChecksFailed returns number of failed checks.
+func SelfChecksFailed(results []SelfCheckResult) int {
+	failed := 0
+	for _, result := range results {
+		if result.Error != nil {
+			failed++
+		}
+	}
+	return failed
+}
+
+func checkMiddleware(c config.ConfigReader) error {
+	switch mwtype := c.GetString(SettingMiddleware); mwtype {
+	case EnvProd, EnvDev:
+		return nil
+	default:
+		return fmt.Errorf("%s: unsupported value '%s'", SettingMiddleware, mwtype)
+	}
+}
+
+func checkJWT(c config.ConfigReader) error {
+	if _, err := SetupJWTValidator(c); err != nil {
+		return err
+	}
+
+	for _, group := range c.GetStringSlice(SettingJWTRoutes) {
+		if group != JWTRoutesManagement && group != JWTRoutesDevices {
+			return fmt.Errorf("%s: unsupported route group '%s'",
+				SettingJWTRoutes, group)
+		}
+	}
+
+	return nil
+}
+
+func checkAuthz(c config.ConfigReader) error {
+	uri := c.GetString(SettingAuthzOPAURL)
+	if uri == "" {
+		return nil
+	}
+
+	_, err := authz.NewOPAClient(uri)
+	return err
+}
+
+func checkTimeouts(c config.ConfigReader) error {
+	_, err := SetupTimeouts(c)
+	return err
+}
+
+func checkPolicy(c config.ConfigReader) error {
+	_, _, err := SetupPolicy(c)
+	return err
+}
+
+func checkEvents(c config.ConfigReader) error {
+	uri := c.GetString(SettingEventsWebhookURL)
+	if uri == "" {
+		return nil
+	}
+
+	_, err := events.NewWebhook(uri, c.GetString(SettingEventsBaseURL), nil)
+	return err
+}
+
+func checkDownloadProxy(c config.ConfigReader) error {
+	_, err := SetupDownloadProxy(c)
+	return err
+}
+
+func checkJobs(c config.ConfigReader) error {
+	for _, key := range []string{SettingJobsWorkers, SettingJobsMaxAttempts} {
+		if c.GetInt(key) <= 0 {
+			return fmt.Errorf("%s: must be greater than 0", key)
+		}
+	}
+
+	if c.GetInt(SettingJobsBackoff) < 0 {
+		return fmt.Errorf("%s: must not be negative", SettingJobsBackoff)
+	}
+
+	return nil
+}
+
+func checkLazyDevices(c config.ConfigReader) error {
+	if c.GetInt(SettingLazyDevicesThreshold) < 0 {
+		return fmt.Errorf("%s: must not be negative", SettingLazyDevicesThreshold)
+	}
+
+	return nil
+}
+
+func checkStatusNames(c config.ConfigReader) error {
+	if _, err := deployments.NewStatusNames(c.GetStringMapString(SettingStatusNames)); err != nil {
+		return fmt.Errorf("%s: %s", SettingStatusNames, err)
+	}
+
+	return nil
+}
+
+func checkStatusBatch(c config.ConfigReader) error {
+	if c.GetInt(SettingStatusBatchWindow) < 0 {
+		return fmt.Errorf("%s: must not be negative", SettingStatusBatchWindow)
+	}
+
+	return nil
+}
+
+func checkDashboardStream(c config.ConfigReader) error {
+	if c.GetInt(SettingDashboardStreamInterval) <= 0 {
+		return fmt.Errorf("%s: must be positive", SettingDashboardStreamInterval)
+	}
+
+	return nil
+}
+
+func checkDevicePartitions(c config.ConfigReader) error {
+	if c.GetInt(SettingPartitionDeviceDeploymentsDays) <= 0 {
+		return fmt.Errorf("%s: must be positive", SettingPartitionDeviceDeploymentsDays)
+	}
+
+	return nil
+}
+
+func checkSlowQueries(c config.ConfigReader) error {
+	if c.GetInt(SettingSlowQueriesThreshold) < 0 {
+		return fmt.Errorf("%s: must not be negative", SettingSlowQueriesThreshold)
+	}
+
+	rate := c.GetFloat64(SettingSlowQueriesExplainSampleRate)
+	if rate < 0 || rate > 1 {
+		return fmt.Errorf("%s: must be between 0 and 1", SettingSlowQueriesExplainSampleRate)
+	}
+
+	return nil
+}
+
+func checkArtifactTrash(c config.ConfigReader) error {
+	if c.GetInt(SettingArtifactTrashDays) < 0 {
+		return fmt.Errorf("%s: must not be negative", SettingArtifactTrashDays)
+	}
+
+	return nil
+}
+
+func checkArtifactUnlockRole(c config.ConfigReader) error {
+	if c.GetString(SettingArtifactUnlockRole) == "" {
+		return fmt.Errorf("%s: must not be empty", SettingArtifactUnlockRole)
+	}
+
+	return nil
+}
+
+func checkFreezeOverrideRole(c config.ConfigReader) error {
+	if c.GetString(SettingFreezeOverrideRole) == "" {
+		return fmt.Errorf("%s: must not be empty", SettingFreezeOverrideRole)
+	}
+
+	return nil
+}
+
+func checkArtifactVersionPattern(c config.ConfigReader) error {
+	if _, err := images.NewVersionParser(
+		c.GetString(SettingArtifactVersionPattern)); err != nil {
+		return fmt.Errorf("%s: %s", SettingArtifactVersionPattern, err)
+	}
+
+	return nil
+}
+
+func checkTelemetry(c config.ConfigReader) error {
+	rate := c.GetFloat64(SettingTelemetrySampleRate)
+	if rate < 0 || rate > 1 {
+		return fmt.Errorf("%s: must be between 0 and 1", SettingTelemetrySampleRate)
+	}
+
+	return nil
+}
+
+func checkMetricsTenants(c config.ConfigReader) error {
+	top := c.GetInt(SettingMetricsTenantsTop)
+	if top < 0 {
+		return fmt.Errorf("%s: must not be negative", SettingMetricsTenantsTop)
+	}
+
+	if c.GetInt(SettingMetricsTenantsMaxTracked) < top {
+		return fmt.Errorf("%s: must not be less than %s",
+			SettingMetricsTenantsMaxTracked, SettingMetricsTenantsTop)
+	}
+
+	return nil
+}
+
+func checkMetricsDeployments(c config.ConfigReader) error {
+	if c.GetInt(SettingMetricsDeploymentsMax) < 0 {
+		return fmt.Errorf("%s: must not be negative", SettingMetricsDeploymentsMax)
+	}
+
+	return nil
+}
+
+func checkAlerts(c config.ConfigReader) error {
+	if c.GetInt(SettingAlertsInterval) < 0 {
+		return fmt.Errorf("%s: must not be negative", SettingAlertsInterval)
+	}
+
+	return nil
+}
+
+func checkPhases(c config.ConfigReader) error {
+	if c.GetInt(SettingPhasesInterval) < 0 {
+		return fmt.Errorf("%s: must not be negative", SettingPhasesInterval)
+	}
+
+	return nil
+}
+
+func checkFinalize(c config.ConfigReader) error {
+	for _, hook := range c.GetStringSlice(SettingFinalizeHooks) {
+		switch hook {
+		case FinalizeHookWebhook:
+			if c.GetString(SettingEventsWebhookURL) == "" {
+				return fmt.Errorf("%s: %s hook requires %s",
+					SettingFinalizeHooks, hook, SettingEventsWebhookURL)
+			}
+		case FinalizeHookWorkflow:
+			if _, err := events.NewWorkflow(c.GetString(SettingFinalizeWorkflowURL),
+				nil); err != nil {
+				return fmt.Errorf("%s: %v", SettingFinalizeWorkflowURL, err)
+			}
+		case FinalizeHookLockArtifacts, FinalizeHookStatsArchive:
+		default:
+			return fmt.Errorf("%s: unsupported hook '%s'", SettingFinalizeHooks, hook)
+		}
+	}
+
+	return nil
+}
+
+func checkUsage(c config.ConfigReader) error {
+	if c.GetInt(SettingUsageFlushInterval) < 0 {
+		return fmt.Errorf("%s: must not be negative", SettingUsageFlushInterval)
+	}
+
+	return nil
+}
+
+func checkMongo(c config.ConfigReader) error {
+	session, err := NewMongoSession(c)
+	if err != nil {
+		return err
+	}
+	session.Close()
+
+	replica, err := NewMongoReplicaSession(c)
+	if err != nil {
+		return errors.Wrap(err, SettingMongoReplicaURL)
+	}
+	if replica != nil {
+		replica.Close()
+	}
+
+	if url := c.GetString(SettingDualWriteMongo); url != "" {
+		session, err := newMongoSession(c, url)
+		if err != nil {
+			return errors.Wrap(err, SettingDualWriteMongo)
+		}
+		session.Close()
+	}
+
+	return nil
+}
+
+// checkStorage verifies that artifact storage is writable by uploading
+// and removing a small probe object.
+func checkStorage(c config.ConfigReader) error {
+	fileStorage, err := SetupS3(c)
+	if err != nil {
+		return errors.Wrap(err, "failed to set up file storage")
+	}
+
+	ctx := context.Background()
+	probe := []byte("deployments self-check")
+	objectId := selfCheckProbePrefix + uuid.NewV4().String()
+
+	err = fileStorage.UploadArtifact(ctx, objectId, int64(len(probe)),
+		bytes.NewReader(probe), "text/plain")
+	if err != nil {
+		return errors.Wrapf(err, "failed to write probe object %s", objectId)
+	}
+
+	if err := fileStorage.Delete(ctx, objectId); err != nil {
+		return errors.Wrapf(err, "failed to delete probe object %s", objectId)
+	}
+
+	return nil
+}
@@ -0,0 +1,388 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mendersoftware/deployments/config"
+)
+
+func TestRunSelfChecks(t *testing.T) {
+	checks := []SelfCheck{
+		{Name: "first", Check: func(c config.ConfigReader) error { return nil }},
+		{Name: "second", Check: func(c config.ConfigReader) error {
+			return errors.New("bucket not accessible")
+		}},
+		{Name: "third", Check: func(c config.ConfigReader) error { return nil }},
+	}
+
+	out := &bytes.Buffer{}
+	results := RunSelfChecks(viper.New(), out, checks)
+
+	assert.Len(t, results, 3)
+	assert.Equal(t, 1, SelfChecksFailed(results))
+	assert.Equal(t, "second", results[1].Name)
+	assert.EqualError(t, results[1].Error, "bucket not accessible")
+	assert.Equal(t,
+		"[ OK ] first\n"+
+			"[FAIL] second: bucket not accessible\n"+
+			"[ OK ] third\n",
+		out.String())
+}
+
+func TestSelfChecksConfig(t *testing.T) {
+	testCases := map[string]struct {
+		settings map[string]interface{}
+		check    func(c config.ConfigReader) error
+		err      string
+	}{
+		"aws role": {
+			settings: map[string]interface{}{
+				SettingAwsRoleARN: "arn:aws:iam::123456789012:role/deployments",
+			},
+			check: ValidateAwsRole,
+		},
+		"aws role web identity": {
+			settings: map[string]interface{}{
+				SettingAwsRoleARN:                  "arn:aws:iam::123456789012:role/deployments",
+				SettingAwsRoleWebIdentityTokenFile: "selfcheck_test.go",
+			},
+			check: ValidateAwsRole,
+		},
+		"aws role web identity without role": {
+			settings: map[string]interface{}{
+				SettingAwsRoleWebIdentityTokenFile: "selfcheck_test.go",
+			},
+			check: ValidateAwsRole,
+			err:   "Required option: 'aws.role.arn'",
+		},
+		"aws role web identity with static keys": {
+			settings: map[string]interface{}{
+				SettingAwsRoleARN:                  "arn:aws:iam::123456789012:role/deployments",
+				SettingAwsRoleWebIdentityTokenFile: "selfcheck_test.go",
+				SettingAwsAuthKeyId:                "key",
+				SettingAwsAuthSecret:               "secret",
+			},
+			check: ValidateAwsRole,
+			err:   "aws.role.web_identity_token_file: not allowed together with aws.auth",
+		},
+		"aws role web identity token not found": {
+			settings: map[string]interface{}{
+				SettingAwsRoleARN:                  "arn:aws:iam::123456789012:role/deployments",
+				SettingAwsRoleWebIdentityTokenFile: "missing-token",
+			},
+			check: ValidateAwsRole,
+			err:   "stat missing-token: no such file or directory",
+		},
+		"middleware ok": {
+			settings: map[string]interface{}{SettingMiddleware: EnvProd},
+			check:    checkMiddleware,
+		},
+		"middleware unsupported": {
+			settings: map[string]interface{}{SettingMiddleware: "staging"},
+			check:    checkMiddleware,
+			err:      "middleware: unsupported value 'staging'",
+		},
+		"jwt routes unsupported": {
+			settings: map[string]interface{}{
+				SettingJWTRoutes: []string{JWTRoutesDevices, "internal"},
+			},
+			check: checkJWT,
+			err:   "jwt.routes: unsupported route group 'internal'",
+		},
+		"authz invalid url": {
+			settings: map[string]interface{}{SettingAuthzOPAURL: "not a url"},
+			check:    checkAuthz,
+			err:      "invalid OPA uri",
+		},
+		"events not configured": {
+			settings: map[string]interface{}{},
+			check:    checkEvents,
+		},
+		"events invalid url": {
+			settings: map[string]interface{}{SettingEventsWebhookURL: "not a url"},
+			check:    checkEvents,
+			err:      "invalid webhook uri",
+		},
+		"jobs ok": {
+			settings: map[string]interface{}{
+				SettingJobsWorkers:     2,
+				SettingJobsMaxAttempts: 5,
+				SettingJobsBackoff:     0,
+			},
+			check: checkJobs,
+		},
+		"jobs no workers": {
+			settings: map[string]interface{}{
+				SettingJobsWorkers:     0,
+				SettingJobsMaxAttempts: 5,
+			},
+			check: checkJobs,
+			err:   "jobs.workers: must be greater than 0",
+		},
+		"jobs negative backoff": {
+			settings: map[string]interface{}{
+				SettingJobsWorkers:     2,
+				SettingJobsMaxAttempts: 5,
+				SettingJobsBackoff:     -1,
+			},
+			check: checkJobs,
+			err:   "jobs.backoff: must not be negative",
+		},
+		"lazy devices negative": {
+			settings: map[string]interface{}{SettingLazyDevicesThreshold: -1},
+			check:    checkLazyDevices,
+			err:      "lazy_devices_threshold: must not be negative",
+		},
+		"status names ok": {
+			settings: map[string]interface{}{
+				SettingStatusNames: map[string]string{"success": "installed"},
+			},
+			check: checkStatusNames,
+		},
+		"status names unknown status": {
+			settings: map[string]interface{}{
+				SettingStatusNames: map[string]string{"done": "installed"},
+			},
+			check: checkStatusNames,
+			err:   "status_names: unknown status done",
+		},
+		"status batch negative": {
+			settings: map[string]interface{}{SettingStatusBatchWindow: -100},
+			check:    checkStatusBatch,
+			err:      "status_batch_window: must not be negative",
+		},
+		"dashboard stream": {
+			settings: map[string]interface{}{SettingDashboardStreamInterval: 5},
+			check:    checkDashboardStream,
+		},
+		"dashboard stream zero": {
+			settings: map[string]interface{}{SettingDashboardStreamInterval: 0},
+			check:    checkDashboardStream,
+			err:      "dashboard_stream_interval: must be positive",
+		},
+		"device partitions negative": {
+			settings: map[string]interface{}{SettingPartitionDeviceDeploymentsDays: -1},
+			check:    checkDevicePartitions,
+			err:      "partition_device_deployments_days: must be positive",
+		},
+		"slow queries": {
+			settings: map[string]interface{}{
+				SettingSlowQueriesThreshold:         500,
+				SettingSlowQueriesExplainSampleRate: 0.5,
+			},
+			check: checkSlowQueries,
+		},
+		"slow queries sample rate": {
+			settings: map[string]interface{}{SettingSlowQueriesExplainSampleRate: 2},
+			check:    checkSlowQueries,
+			err:      "slow_queries.explain_sample_rate: must be between 0 and 1",
+		},
+		"artifact trash negative": {
+			settings: map[string]interface{}{SettingArtifactTrashDays: -1},
+			check:    checkArtifactTrash,
+			err:      "artifact_trash_days: must not be negative",
+		},
+		"artifact unlock role": {
+			settings: map[string]interface{}{SettingArtifactUnlockRole: "admin"},
+			check:    checkArtifactUnlockRole,
+		},
+		"artifact unlock role empty": {
+			settings: map[string]interface{}{SettingArtifactUnlockRole: ""},
+			check:    checkArtifactUnlockRole,
+			err:      "artifact_unlock_role: must not be empty",
+		},
+		"download proxy disabled": {
+			settings: map[string]interface{}{},
+			check:    checkDownloadProxy,
+		},
+		"download proxy": {
+			settings: map[string]interface{}{
+				SettingDownloadProxyURL:    "https://example.com/api/devices/v1/deployments",
+				SettingDownloadProxySecret: "secret",
+			},
+			check: checkDownloadProxy,
+		},
+		"download proxy without secret": {
+			settings: map[string]interface{}{
+				SettingDownloadProxyURL: "https://example.com/api/devices/v1/deployments",
+			},
+			check: checkDownloadProxy,
+			err:   "download_proxy.secret: must not be empty",
+		},
+		"download proxy invalid url": {
+			settings: map[string]interface{}{
+				SettingDownloadProxyURL:    "example.com",
+				SettingDownloadProxySecret: "secret",
+			},
+			check: checkDownloadProxy,
+			err:   "download_proxy.url: invalid URL: example.com",
+		},
+		"freeze override role": {
+			settings: map[string]interface{}{SettingFreezeOverrideRole: "admin"},
+			check:    checkFreezeOverrideRole,
+		},
+		"freeze override role empty": {
+			settings: map[string]interface{}{SettingFreezeOverrideRole: ""},
+			check:    checkFreezeOverrideRole,
+			err:      "freeze_override_role: must not be empty",
+		},
+		"artifact version pattern default": {
+			settings: map[string]interface{}{SettingArtifactVersionPattern: ""},
+			check:    checkArtifactVersionPattern,
+		},
+		"artifact version pattern": {
+			settings: map[string]interface{}{
+				SettingArtifactVersionPattern: `_r(?P<version>[0-9]+)$`,
+			},
+			check: checkArtifactVersionPattern,
+		},
+		"artifact version pattern without group": {
+			settings: map[string]interface{}{SettingArtifactVersionPattern: `[0-9]+$`},
+			check:    checkArtifactVersionPattern,
+			err:      `artifact_version_pattern: pattern has no "version" group`,
+		},
+		"telemetry sample rate": {
+			settings: map[string]interface{}{SettingTelemetrySampleRate: 1},
+			check:    checkTelemetry,
+		},
+		"telemetry sample rate negative": {
+			settings: map[string]interface{}{SettingTelemetrySampleRate: -0.5},
+			check:    checkTelemetry,
+			err:      "telemetry_sample_rate: must be between 0 and 1",
+		},
+		"artifact version pattern invalid": {
+			settings: map[string]interface{}{SettingArtifactVersionPattern: `(`},
+			check:    checkArtifactVersionPattern,
+			err: "artifact_version_pattern: error parsing regexp: " +
+				"missing closing ): `(`",
+		},
+		"tenant metrics": {
+			settings: map[string]interface{}{
+				SettingMetricsTenantsTop:        10,
+				SettingMetricsTenantsMaxTracked: 1000,
+			},
+			check: checkMetricsTenants,
+		},
+		"tenant metrics disabled": {
+			settings: map[string]interface{}{
+				SettingMetricsTenantsTop:        0,
+				SettingMetricsTenantsMaxTracked: 0,
+			},
+			check: checkMetricsTenants,
+		},
+		"tenant metrics too few tracked": {
+			settings: map[string]interface{}{
+				SettingMetricsTenantsTop:        10,
+				SettingMetricsTenantsMaxTracked: 5,
+			},
+			check: checkMetricsTenants,
+			err:   "metrics_tenants.max_tracked: must not be less than metrics_tenants.top",
+		},
+		"deployment metrics disabled": {
+			settings: map[string]interface{}{SettingMetricsDeploymentsMax: 0},
+			check:    checkMetricsDeployments,
+		},
+		"deployment metrics negative": {
+			settings: map[string]interface{}{SettingMetricsDeploymentsMax: -1},
+			check:    checkMetricsDeployments,
+			err:      "metrics_deployments_max: must not be negative",
+		},
+		"alerts disabled": {
+			settings: map[string]interface{}{SettingAlertsInterval: 0},
+			check:    checkAlerts,
+		},
+		"alerts interval negative": {
+			settings: map[string]interface{}{SettingAlertsInterval: -1},
+			check:    checkAlerts,
+			err:      "alerts_interval: must not be negative",
+		},
+		"phases activation disabled": {
+			settings: map[string]interface{}{SettingPhasesInterval: 0},
+			check:    checkPhases,
+		},
+		"phases interval negative": {
+			settings: map[string]interface{}{SettingPhasesInterval: -1},
+			check:    checkPhases,
+			err:      "phases_interval: must not be negative",
+		},
+		"finalize hooks": {
+			settings: map[string]interface{}{
+				SettingFinalizeHooks: []string{
+					FinalizeHookWebhook,
+					FinalizeHookWorkflow,
+					FinalizeHookLockArtifacts,
+					FinalizeHookStatsArchive,
+				},
+				SettingEventsWebhookURL:    "http://localhost/events",
+				SettingFinalizeWorkflowURL: "http://localhost/workflows/deployment_finished",
+			},
+			check: checkFinalize,
+		},
+		"finalize hooks unsupported": {
+			settings: map[string]interface{}{
+				SettingFinalizeHooks: []string{"email"},
+			},
+			check: checkFinalize,
+			err:   "finalize.hooks: unsupported hook 'email'",
+		},
+		"finalize webhook not configured": {
+			settings: map[string]interface{}{
+				SettingFinalizeHooks: []string{FinalizeHookWebhook},
+			},
+			check: checkFinalize,
+			err:   "finalize.hooks: webhook hook requires events.webhook_url",
+		},
+		"finalize workflow invalid url": {
+			settings: map[string]interface{}{
+				SettingFinalizeHooks:       []string{FinalizeHookWorkflow},
+				SettingFinalizeWorkflowURL: "not a url",
+			},
+			check: checkFinalize,
+			err:   "finalize.workflow_url: invalid workflow uri",
+		},
+		"usage accounting disabled": {
+			settings: map[string]interface{}{SettingUsageFlushInterval: 0},
+			check:    checkUsage,
+		},
+		"usage flush interval negative": {
+			settings: map[string]interface{}{SettingUsageFlushInterval: -1},
+			check:    checkUsage,
+			err:      "usage_flush_interval: must not be negative",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			c := viper.New()
+			for key, value := range tc.settings {
+				c.Set(key, value)
+			}
+
+			err := tc.check(c)
+			if tc.err != "" {
+				assert.EqualError(t, err, tc.err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
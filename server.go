@@ -29,7 +29,9 @@ func RunServer(c config.ConfigReader) error {
 	}
 
 	api := rest.NewApi()
-	SetupMiddleware(c, api)
+	if err := SetupMiddleware(c, api); err != nil {
+		return err
+	}
 	api.SetApp(router)
 
 	listen := c.GetString(SettingListen)
@@ -0,0 +1,280 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+//go:build acceptance
+// +build acceptance
+
+// Package acceptance exercises the deployments flows against a running
+// service, backed by its database and artifact storage:
+//
+//	go test -tags acceptance ./tests/acceptance -server http://localhost:8080
+//
+// Management requests carry unsigned user tokens unless -token is given,
+// devices always use unsigned tokens, so the tested instance must not verify
+// device tokens.
+package acceptance
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/mendersoftware/mender-artifact/awriter"
+	"github.com/mendersoftware/mender-artifact/handlers"
+	"github.com/pkg/errors"
+	"github.com/satori/go.uuid"
+
+	client "github.com/mendersoftware/deployments/client/deployments"
+	"github.com/mendersoftware/deployments/resources/deployments"
+)
+
+var (
+	server = flag.String("server", "http://localhost:8080",
+		"URL of the tested service or API gateway")
+	token = flag.String("token", "",
+		"management API token, unsigned user token is generated if not set")
+	tenant = flag.String("tenant", "",
+		"tenant of the generated tokens (optional)")
+	timeout = flag.Duration("timeout", 30*time.Second,
+		"timeout of a single test")
+)
+
+// Device API routes
+const (
+	uriNextDeployment   = "/api/devices/v1/deployments/device/deployments/next"
+	uriDeploymentStatus = "/api/devices/v1/deployments/device/deployments/%s/status"
+	uriDeploymentLog    = "/api/devices/v1/deployments/device/deployments/%s/log"
+)
+
+func TestMain(m *testing.M) {
+	flag.Parse()
+	os.Exit(m.Run())
+}
+
+func newContext() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), *timeout)
+}
+
+// newClient creates management and internal API client.
+func newClient(t *testing.T) *client.Client {
+	jwt := *token
+	if jwt == "" {
+		jwt = unsignedToken(map[string]interface{}{
+			"sub":         uuid.NewV4().String(),
+			"mender.user": true,
+		})
+	}
+
+	c, err := client.NewClient(*server, client.WithToken(jwt))
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	return c
+}
+
+func unsignedToken(claims map[string]interface{}) string {
+	if *tenant != "" {
+		claims["mender.tenant"] = *tenant
+	}
+
+	header, _ := json.Marshal(map[string]string{"alg": "none", "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+
+	return base64.RawStdEncoding.EncodeToString(header) + "." +
+		base64.RawStdEncoding.EncodeToString(payload) + ".acceptance"
+}
+
+// makeArtifact creates rootfs image artifact with unique name.
+func makeArtifact(t *testing.T, deviceType string) (string, []byte) {
+	update, err := ioutil.TempFile("", "acceptance-update")
+	if err != nil {
+		t.Fatalf("failed to create update file: %v", err)
+	}
+	defer os.Remove(update.Name())
+
+	if _, err := update.WriteString("acceptance update " + uuid.NewV4().String()); err != nil {
+		t.Fatalf("failed to write update file: %v", err)
+	}
+	update.Close()
+
+	name := "acceptance-" + uuid.NewV4().String()
+	artifact := &bytes.Buffer{}
+	updates := &awriter.Updates{U: []handlers.Composer{handlers.NewRootfsV2(update.Name())}}
+	err = awriter.NewWriter(artifact).WriteArtifact("mender", 2,
+		[]string{deviceType}, name, updates, nil)
+	if err != nil {
+		t.Fatalf("failed to write artifact: %v", err)
+	}
+
+	return name, artifact.Bytes()
+}
+
+// uploadArtifact uploads new artifact for the device type, returns its
+// name and function removing it.
+func uploadArtifact(t *testing.T, c *client.Client, deviceType string) (string, func()) {
+	ctx, cancel := newContext()
+	defer cancel()
+
+	name, artifact := makeArtifact(t, deviceType)
+	id, err := c.UploadArtifact(ctx, "acceptance test", int64(len(artifact)),
+		bytes.NewReader(artifact))
+	if err != nil {
+		t.Fatalf("failed to upload artifact: %v", err)
+	}
+
+	return name, func() {
+		ctx, cancel := newContext()
+		defer cancel()
+		if err := c.DeleteArtifact(ctx, id); err != nil {
+			t.Logf("failed to remove artifact %s: %v", id, err)
+		}
+	}
+}
+
+// device talks to the devices API on behalf of a single device.
+type device struct {
+	id         string
+	deviceType string
+	token      string
+}
+
+func newDevice(deviceType string) *device {
+	id := "acceptance-" + uuid.NewV4().String()
+	return &device{
+		id:         id,
+		deviceType: deviceType,
+		token: unsignedToken(map[string]interface{}{
+			"sub":           id,
+			"mender.device": true,
+		}),
+	}
+}
+
+// next returns pending deployment of the device, nil if there is none.
+func (d *device) next(ctx context.Context,
+	installed string) (*deployments.DeploymentInstructions, error) {
+
+	query := url.Values{}
+	query.Set("artifact_name", installed)
+	query.Set("device_type", d.deviceType)
+
+	rsp, err := d.request(ctx, http.MethodGet, uriNextDeployment+"?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	defer rsp.Body.Close()
+
+	switch rsp.StatusCode {
+	case http.StatusNoContent:
+		return nil, nil
+	case http.StatusOK:
+	default:
+		return nil, errors.Errorf("unexpected response: %s", rsp.Status)
+	}
+
+	var instructions deployments.DeploymentInstructions
+	if err := json.NewDecoder(rsp.Body).Decode(&instructions); err != nil {
+		return nil, err
+	}
+
+	return &instructions, nil
+}
+
+// report sends status of the deployment, returns response status code.
+func (d *device) report(ctx context.Context, id, status string) (int, error) {
+	report := map[string]string{"status": status}
+
+	rsp, err := d.request(ctx, http.MethodPut, fmt.Sprintf(uriDeploymentStatus, id), report)
+	if err != nil {
+		return 0, err
+	}
+	rsp.Body.Close()
+
+	return rsp.StatusCode, nil
+}
+
+// log uploads deployment log with single message.
+func (d *device) log(ctx context.Context, id, message string) (int, error) {
+	now := time.Now()
+	log := deployments.DeploymentLog{
+		Messages: []deployments.LogMessage{{
+			Timestamp: &now,
+			Level:     "error",
+			Message:   message,
+		}},
+	}
+
+	rsp, err := d.request(ctx, http.MethodPut, fmt.Sprintf(uriDeploymentLog, id), log)
+	if err != nil {
+		return 0, err
+	}
+	rsp.Body.Close()
+
+	return rsp.StatusCode, nil
+}
+
+// download fetches artifact from the link received in instructions.
+func (d *device) download(ctx context.Context, uri string) ([]byte, error) {
+	req, err := http.NewRequest(http.MethodGet, uri, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	rsp, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer rsp.Body.Close()
+
+	if rsp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("unexpected response: %s", rsp.Status)
+	}
+
+	return ioutil.ReadAll(rsp.Body)
+}
+
+func (d *device) request(ctx context.Context, method, path string,
+	body interface{}) (*http.Response, error) {
+
+	var payload io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		payload = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequest(method, *server+path, payload)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+d.token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	return http.DefaultClient.Do(req.WithContext(ctx))
+}
@@ -0,0 +1,277 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+//go:build acceptance
+// +build acceptance
+
+package acceptance
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mendersoftware/deployments/resources/deployments"
+)
+
+func newDeployment(name, artifactName string,
+	devices ...*device) *deployments.DeploymentConstructor {
+
+	ids := make([]string, 0, len(devices))
+	for _, dev := range devices {
+		ids = append(ids, dev.id)
+	}
+
+	return &deployments.DeploymentConstructor{
+		Name:         &name,
+		ArtifactName: &artifactName,
+		Devices:      ids,
+	}
+}
+
+// TestDeploymentFlow creates deployment for two devices, one of them
+// goes through the whole update while the other has the artifact
+// installed already.
+func TestDeploymentFlow(t *testing.T) {
+	ctx, cancel := newContext()
+	defer cancel()
+
+	c := newClient(t)
+	deviceType := "acceptance-flow"
+	artifactName, remove := uploadArtifact(t, c, deviceType)
+	defer remove()
+
+	updated, installed := newDevice(deviceType), newDevice(deviceType)
+	id, err := c.CreateDeployment(ctx,
+		newDeployment("acceptance flow", artifactName, updated, installed))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	stats, err := c.GetDeploymentStats(ctx, id)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, 2, stats[deployments.DeviceDeploymentStatusPending])
+
+	// device with the artifact installed has nothing to do
+	instructions, err := installed.next(ctx, artifactName)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Nil(t, instructions)
+
+	// other device goes through the update
+	instructions, err = updated.next(ctx, "acceptance-previous")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	if !assert.NotNil(t, instructions) {
+		t.FailNow()
+	}
+	assert.Equal(t, id, instructions.ID)
+	assert.Equal(t, artifactName, instructions.Artifact.ArtifactName)
+	assert.Contains(t, instructions.Artifact.DeviceTypesCompatible, deviceType)
+
+	for _, status := range []string{
+		deployments.DeviceDeploymentStatusDownloading,
+		deployments.DeviceDeploymentStatusInstalling,
+		deployments.DeviceDeploymentStatusRebooting,
+	} {
+		code, err := updated.report(ctx, id, status)
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		if !assert.Equal(t, http.StatusNoContent, code, status) {
+			t.FailNow()
+		}
+
+		if status == deployments.DeviceDeploymentStatusDownloading {
+			artifact, err := updated.download(ctx, instructions.Artifact.Source.Uri)
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			assert.NotEmpty(t, artifact)
+		}
+	}
+
+	deployment, err := c.GetDeployment(ctx, id)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, "inprogress", deployment.Status)
+
+	code, err := updated.report(ctx, id, deployments.DeviceDeploymentStatusSuccess)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	if !assert.Equal(t, http.StatusNoContent, code) {
+		t.FailNow()
+	}
+
+	stats, err = c.GetDeploymentStats(ctx, id)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, 1, stats[deployments.DeviceDeploymentStatusSuccess])
+	assert.Equal(t, 1, stats[deployments.DeviceDeploymentStatusAlreadyInst])
+	assert.Equal(t, 0, stats[deployments.DeviceDeploymentStatusPending])
+
+	deployment, err = c.GetDeployment(ctx, id)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, "finished", deployment.Status)
+	assert.NotNil(t, deployment.Finished)
+
+	// the device is up to date now
+	instructions, err = updated.next(ctx, artifactName)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Nil(t, instructions)
+}
+
+// TestDeploymentFailure reports failed update together with the log.
+func TestDeploymentFailure(t *testing.T) {
+	ctx, cancel := newContext()
+	defer cancel()
+
+	c := newClient(t)
+	deviceType := "acceptance-failure"
+	artifactName, remove := uploadArtifact(t, c, deviceType)
+	defer remove()
+
+	dev := newDevice(deviceType)
+	id, err := c.CreateDeployment(ctx, newDeployment("acceptance failure", artifactName, dev))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	instructions, err := dev.next(ctx, "acceptance-previous")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	if !assert.NotNil(t, instructions) {
+		t.FailNow()
+	}
+
+	code, err := dev.report(ctx, id, deployments.DeviceDeploymentStatusInstalling)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	if !assert.Equal(t, http.StatusNoContent, code) {
+		t.FailNow()
+	}
+
+	code, err = dev.log(ctx, id, "installation failed")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	if !assert.Equal(t, http.StatusNoContent, code) {
+		t.FailNow()
+	}
+
+	code, err = dev.report(ctx, id, deployments.DeviceDeploymentStatusFailure)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	if !assert.Equal(t, http.StatusNoContent, code) {
+		t.FailNow()
+	}
+
+	devices, err := c.ListDeploymentDevices(ctx, id)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	if !assert.Len(t, devices, 1) {
+		t.FailNow()
+	}
+	assert.Equal(t, deployments.DeviceDeploymentStatusFailure, *devices[0].Status)
+	assert.True(t, devices[0].IsLogAvailable)
+
+	deployment, err := c.GetDeployment(ctx, id)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, "finished", deployment.Status)
+}
+
+// TestDeploymentAbort aborts deployment while device is updating.
+func TestDeploymentAbort(t *testing.T) {
+	ctx, cancel := newContext()
+	defer cancel()
+
+	c := newClient(t)
+	deviceType := "acceptance-abort"
+	artifactName, remove := uploadArtifact(t, c, deviceType)
+	defer remove()
+
+	updating, pending := newDevice(deviceType), newDevice(deviceType)
+	id, err := c.CreateDeployment(ctx,
+		newDeployment("acceptance abort", artifactName, updating, pending))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	instructions, err := updating.next(ctx, "acceptance-previous")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	if !assert.NotNil(t, instructions) {
+		t.FailNow()
+	}
+
+	code, err := updating.report(ctx, id, deployments.DeviceDeploymentStatusDownloading)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	if !assert.Equal(t, http.StatusNoContent, code) {
+		t.FailNow()
+	}
+
+	if !assert.NoError(t, c.AbortDeployment(ctx, id, "acceptance test")) {
+		t.FailNow()
+	}
+
+	// updating device learns about the abort on next report
+	code, err = updating.report(ctx, id, deployments.DeviceDeploymentStatusInstalling)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, http.StatusConflict, code)
+
+	// pending device gets nothing
+	instructions, err = pending.next(ctx, "acceptance-previous")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Nil(t, instructions)
+
+	stats, err := c.GetDeploymentStats(ctx, id)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, 2, stats[deployments.DeviceDeploymentStatusAborted])
+
+	deployment, err := c.GetDeployment(ctx, id)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, "finished", deployment.Status)
+	if assert.NotNil(t, deployment.Abort) {
+		assert.Equal(t, "acceptance test", deployment.Abort.Reason)
+	}
+}
@@ -0,0 +1,121 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package metrics
+
+import (
+	"bufio"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Histogram counts observed values in buckets, separately for each
+// combination of label values. It is safe for concurrent use.
+type Histogram struct {
+	name       string
+	help       string
+	buckets    []float64
+	labelNames []string
+
+	mu     sync.Mutex
+	series map[string]*histogramSeries
+}
+
+type histogramSeries struct {
+	labels []Label
+	// counts per bucket, not cumulative; the last bucket is +Inf
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+// NewHistogram creates a histogram with the bucket upper bounds, the +Inf
+// bucket is implicit.
+func NewHistogram(name, help string, buckets []float64,
+	labelNames ...string) *Histogram {
+
+	sorted := append([]float64{}, buckets...)
+	sort.Float64s(sorted)
+	return &Histogram{
+		name:       name,
+		help:       help,
+		buckets:    sorted,
+		labelNames: labelNames,
+		series:     map[string]*histogramSeries{},
+	}
+}
+
+// Observe counts the value in the series of the label values, given in
+// order of the label names.
+func (h *Histogram) Observe(value float64, labelValues ...string) {
+	key := strings.Join(labelValues, "\xff")
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	s, ok := h.series[key]
+	if !ok {
+		s = &histogramSeries{
+			labels: make([]Label, len(h.labelNames)),
+			counts: make([]uint64, len(h.buckets)+1),
+		}
+		for i, name := range h.labelNames {
+			s.labels[i].Name = name
+			if i < len(labelValues) {
+				s.labels[i].Value = labelValues[i]
+			}
+		}
+		h.series[key] = s
+	}
+
+	// buckets are inclusive of the upper bound
+	s.counts[sort.SearchFloat64s(h.buckets, value)]++
+	s.count++
+	s.sum += value
+}
+
+func (h *Histogram) writeOpenMetrics(bw *bufio.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	writeMetadata(bw, h.name, "histogram", h.help)
+
+	keys := make([]string, 0, len(h.series))
+	for key := range h.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		s := h.series[key]
+		var cumulative uint64
+		for i, count := range s.counts {
+			cumulative += count
+			upTo := math.Inf(1)
+			if i < len(h.buckets) {
+				upTo = h.buckets[i]
+			}
+			labels := append(s.labels[:len(s.labels):len(s.labels)],
+				Label{Name: "le", Value: formatFloat(upTo)})
+			writeSample(bw, h.name+"_bucket", Sample{
+				Labels: labels,
+				Value:  float64(cumulative),
+			})
+		}
+		writeSample(bw, h.name+"_count", Sample{Labels: s.labels, Value: float64(s.count)})
+		writeSample(bw, h.name+"_sum", Sample{Labels: s.labels, Value: s.sum})
+	}
+}
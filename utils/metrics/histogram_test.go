@@ -0,0 +1,71 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package metrics
+
+import (
+	"bytes"
+	"net/http"
+	"testing"
+
+	"github.com/ant0ine/go-json-rest/rest"
+	"github.com/ant0ine/go-json-rest/rest/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHistogram(t *testing.T) {
+	h := NewHistogram("download_seconds", "Artifact download duration.",
+		[]float64{60, 10}, "size")
+	h.Observe(5, "small")
+	h.Observe(10, "small")
+	h.Observe(30, "small")
+	h.Observe(600, "large")
+
+	var b bytes.Buffer
+	assert.NoError(t, WriteOpenMetrics(&b, h, NewHistogram("empty", "", nil)))
+	assert.Equal(t, `# TYPE download_seconds histogram
+# HELP download_seconds Artifact download duration.
+download_seconds_bucket{size="large",le="10"} 0
+download_seconds_bucket{size="large",le="60"} 0
+download_seconds_bucket{size="large",le="+Inf"} 1
+download_seconds_count{size="large"} 1
+download_seconds_sum{size="large"} 600
+download_seconds_bucket{size="small",le="10"} 2
+download_seconds_bucket{size="small",le="60"} 3
+download_seconds_bucket{size="small",le="+Inf"} 3
+download_seconds_count{size="small"} 3
+download_seconds_sum{size="small"} 45
+# TYPE empty histogram
+# EOF
+`, b.String())
+}
+
+func TestOpenMetricsHandler(t *testing.T) {
+	h := NewHistogram("download_seconds", "", []float64{10})
+	h.Observe(1)
+
+	api := rest.NewApi()
+	api.SetApp(rest.AppSimple(OpenMetricsHandler(h)))
+	recorded := test.RunRequest(t, api.MakeHandler(),
+		test.MakeSimpleRequest(http.MethodGet, "http://1.2.3.4/metrics", nil))
+	recorded.CodeIs(http.StatusOK)
+	recorded.HeaderIs("Content-Type", OpenMetricsContentType)
+	recorded.BodyIs(`# TYPE download_seconds histogram
+download_seconds_bucket{le="10"} 1
+download_seconds_bucket{le="+Inf"} 1
+download_seconds_count 1
+download_seconds_sum 1
+# EOF
+`)
+}
@@ -0,0 +1,264 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package metrics
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ant0ine/go-json-rest/rest"
+)
+
+// Operation summarizes calls of a single operation.
+type Operation struct {
+	// Number of calls
+	Count int64 `json:"count"`
+
+	// Number of calls which returned an error
+	Errors int64 `json:"errors"`
+
+	// Ratio of failed calls
+	ErrorRate float64 `json:"error_rate"`
+
+	// Average and maximum call duration in milliseconds
+	LatencyAvg float64 `json:"latency_avg_ms"`
+	LatencyMax float64 `json:"latency_max_ms"`
+}
+
+type operation struct {
+	count   int64
+	errors  int64
+	total   time.Duration
+	longest time.Duration
+}
+
+func (op *operation) observe(took time.Duration, err error) {
+	op.count++
+	if err != nil {
+		op.errors++
+	}
+	op.total += took
+	if took > op.longest {
+		op.longest = took
+	}
+}
+
+func (op *operation) merge(other *operation) {
+	op.count += other.count
+	op.errors += other.errors
+	op.total += other.total
+	if other.longest > op.longest {
+		op.longest = other.longest
+	}
+}
+
+func (op *operation) summary() Operation {
+	return Operation{
+		Count:      op.count,
+		Errors:     op.errors,
+		ErrorRate:  float64(op.errors) / float64(op.count),
+		LatencyAvg: milliseconds(op.total / time.Duration(op.count)),
+		LatencyMax: milliseconds(op.longest),
+	}
+}
+
+// OtherTenants labels calls of the tenants not among the top tenants.
+const OtherTenants = "other"
+
+// tenantOperations are the calls of a single tenant.
+type tenantOperations struct {
+	calls      int64
+	operations map[string]*operation
+}
+
+func (t *tenantOperations) observe(name string, took time.Duration, err error) {
+	op, ok := t.operations[name]
+	if !ok {
+		op = &operation{}
+		t.operations[name] = op
+	}
+	t.calls++
+	op.observe(took, err)
+}
+
+func (t *tenantOperations) merge(other *tenantOperations) {
+	for name, op := range other.operations {
+		if _, ok := t.operations[name]; !ok {
+			t.operations[name] = &operation{}
+		}
+		t.operations[name].merge(op)
+	}
+	t.calls += other.calls
+}
+
+// Recorder collects call counts, latencies and errors per operation.
+type Recorder struct {
+	lock       sync.Mutex
+	operations map[string]*operation
+
+	// calls per tenant, recorded if topTenants is set
+	topTenants int
+	maxTenants int
+	tenants    map[string]*tenantOperations
+	other      *tenantOperations
+}
+
+func NewRecorder() *Recorder {
+	return &Recorder{
+		operations: map[string]*operation{},
+	}
+}
+
+// NewTenantRecorder creates recorder collecting calls per tenant as well.
+// Tenants are labeled in snapshots only if they are among topTenants with
+// the most calls, the rest are summed up as OtherTenants. At most
+// maxTenants are tracked; when a new tenant comes, the tracked tenant with
+// the least calls is summed up as OtherTenants, so the memory used stays
+// bounded while tenants with many calls get labeled.
+func NewTenantRecorder(topTenants, maxTenants int) *Recorder {
+	if maxTenants < topTenants {
+		maxTenants = topTenants
+	}
+	r := NewRecorder()
+	r.topTenants = topTenants
+	r.maxTenants = maxTenants
+	r.tenants = map[string]*tenantOperations{}
+	r.other = &tenantOperations{operations: map[string]*operation{}}
+	return r
+}
+
+// Observe records single call of the operation.
+func (r *Recorder) Observe(name string, took time.Duration, err error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.observe(name, took, err)
+}
+
+// ObserveTenant records single call of the operation by the tenant.
+// Calls without tenant are recorded as by Observe.
+func (r *Recorder) ObserveTenant(name, tenant string, took time.Duration, err error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.observe(name, took, err)
+	if r.topTenants <= 0 || tenant == "" {
+		return
+	}
+
+	t, ok := r.tenants[tenant]
+	if !ok {
+		if len(r.tenants) >= r.maxTenants {
+			r.evictTenant()
+		}
+		t = &tenantOperations{operations: map[string]*operation{}}
+		r.tenants[tenant] = t
+	}
+	t.observe(name, took, err)
+}
+
+func (r *Recorder) observe(name string, took time.Duration, err error) {
+	op, ok := r.operations[name]
+	if !ok {
+		op = &operation{}
+		r.operations[name] = op
+	}
+	op.observe(took, err)
+}
+
+// evictTenant sums up calls of the tracked tenant with the least calls
+// as other tenants.
+func (r *Recorder) evictTenant() {
+	var least string
+	for tenant, t := range r.tenants {
+		if least == "" || t.calls < r.tenants[least].calls {
+			least = tenant
+		}
+	}
+	r.other.merge(r.tenants[least])
+	delete(r.tenants, least)
+}
+
+// Snapshot returns summary of the operations recorded so far.
+func (r *Recorder) Snapshot() map[string]Operation {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	snapshot := make(map[string]Operation, len(r.operations))
+	for name, op := range r.operations {
+		snapshot[name] = op.summary()
+	}
+
+	return snapshot
+}
+
+// TenantSnapshot returns summary of the operations recorded so far per
+// tenant label: the top tenants and OtherTenants. Empty if tenants are
+// not recorded.
+func (r *Recorder) TenantSnapshot() map[string]map[string]Operation {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	ranked := make([]string, 0, len(r.tenants))
+	for tenant := range r.tenants {
+		ranked = append(ranked, tenant)
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		ci, cj := r.tenants[ranked[i]].calls, r.tenants[ranked[j]].calls
+		return ci > cj || (ci == cj && ranked[i] < ranked[j])
+	})
+
+	labeled := make(map[string]*tenantOperations)
+	other := &tenantOperations{operations: map[string]*operation{}}
+	if r.other != nil {
+		other.merge(r.other)
+	}
+	for i, tenant := range ranked {
+		if i < r.topTenants {
+			labeled[tenant] = r.tenants[tenant]
+		} else {
+			other.merge(r.tenants[tenant])
+		}
+	}
+	if other.calls > 0 {
+		labeled[OtherTenants] = other
+	}
+
+	snapshot := make(map[string]map[string]Operation, len(labeled))
+	for label, t := range labeled {
+		ops := make(map[string]Operation, len(t.operations))
+		for name, op := range t.operations {
+			ops[name] = op.summary()
+		}
+		snapshot[label] = ops
+	}
+
+	return snapshot
+}
+
+// GetSnapshot renders the snapshot of the operations.
+func (r *Recorder) GetSnapshot(w rest.ResponseWriter, req *rest.Request) {
+	w.WriteJson(r.Snapshot())
+}
+
+// GetTenantSnapshot renders the snapshot of the operations per tenant.
+func (r *Recorder) GetTenantSnapshot(w rest.ResponseWriter, req *rest.Request) {
+	w.WriteJson(r.TenantSnapshot())
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
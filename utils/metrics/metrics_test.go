@@ -0,0 +1,95 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package metrics
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecorder(t *testing.T) {
+	r := NewRecorder()
+	assert.Empty(t, r.Snapshot())
+
+	r.Observe("Get", 10*time.Millisecond, nil)
+	r.Observe("Get", 30*time.Millisecond, errors.New("failed"))
+	r.Observe("Get", 20*time.Millisecond, nil)
+	r.Observe("Delete", time.Millisecond, nil)
+
+	assert.Equal(t, map[string]Operation{
+		"Get": {
+			Count:      3,
+			Errors:     1,
+			ErrorRate:  1.0 / 3,
+			LatencyAvg: 20,
+			LatencyMax: 30,
+		},
+		"Delete": {
+			Count:      1,
+			LatencyAvg: 1,
+			LatencyMax: 1,
+		},
+	}, r.Snapshot())
+}
+
+func TestRecorderTenants(t *testing.T) {
+	r := NewTenantRecorder(2, 3)
+	assert.Empty(t, r.TenantSnapshot())
+
+	for i := 0; i < 3; i++ {
+		r.ObserveTenant("Get", "tenant-a", 10*time.Millisecond, nil)
+	}
+	r.ObserveTenant("Get", "tenant-b", 20*time.Millisecond, nil)
+	r.ObserveTenant("Delete", "tenant-b", time.Millisecond, nil)
+	r.ObserveTenant("Get", "tenant-c", 30*time.Millisecond, errors.New("failed"))
+	// evicts tenant-c with the least calls
+	r.ObserveTenant("Get", "tenant-d", 40*time.Millisecond, nil)
+	// not tracked per tenant
+	r.ObserveTenant("Get", "", 10*time.Millisecond, nil)
+
+	assert.Equal(t, map[string]map[string]Operation{
+		"tenant-a": {
+			"Get": {Count: 3, LatencyAvg: 10, LatencyMax: 10},
+		},
+		"tenant-b": {
+			"Get":    {Count: 1, LatencyAvg: 20, LatencyMax: 20},
+			"Delete": {Count: 1, LatencyAvg: 1, LatencyMax: 1},
+		},
+		OtherTenants: {
+			"Get": {
+				Count:      2,
+				Errors:     1,
+				ErrorRate:  0.5,
+				LatencyAvg: 35,
+				LatencyMax: 40,
+			},
+		},
+	}, r.TenantSnapshot())
+
+	snapshot := r.Snapshot()
+	assert.Equal(t, int64(7), snapshot["Get"].Count)
+	assert.Equal(t, int64(1), snapshot["Delete"].Count)
+}
+
+func TestRecorderTenantsDisabled(t *testing.T) {
+	r := NewRecorder()
+	r.ObserveTenant("Get", "tenant-a", 10*time.Millisecond, nil)
+
+	assert.Empty(t, r.TenantSnapshot())
+	assert.Equal(t, int64(1), r.Snapshot()["Get"].Count)
+}
@@ -0,0 +1,121 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/ant0ine/go-json-rest/rest"
+	"github.com/mendersoftware/go-lib-micro/log"
+)
+
+// OpenMetricsContentType is the content type of the OpenMetrics text
+// exposition format.
+const OpenMetricsContentType = "application/openmetrics-text; version=1.0.0; charset=utf-8"
+
+// Label is a single label of a sample; labels are written in order.
+type Label struct {
+	Name  string
+	Value string
+}
+
+// Sample is a single value of a metric.
+type Sample struct {
+	Labels []Label
+	Value  float64
+}
+
+// Gauge is a metric family of gauge samples.
+type Gauge struct {
+	Name    string
+	Help    string
+	Samples []Sample
+}
+
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// Family is a metric family written in the OpenMetrics text format.
+type Family interface {
+	writeOpenMetrics(bw *bufio.Writer)
+}
+
+// WriteOpenMetrics writes the metric families in the OpenMetrics text
+// exposition format, terminated by the EOF marker.
+func WriteOpenMetrics(w io.Writer, families ...Family) error {
+	bw := bufio.NewWriter(w)
+	for _, f := range families {
+		f.writeOpenMetrics(bw)
+	}
+	bw.WriteString("# EOF\n")
+	return bw.Flush()
+}
+
+// OpenMetricsHandler renders the current state of the metric families in
+// the OpenMetrics text format.
+func OpenMetricsHandler(families ...Family) rest.HandlerFunc {
+	return func(w rest.ResponseWriter, r *rest.Request) {
+		h, _ := w.(http.ResponseWriter)
+		h.Header().Set("Content-Type", OpenMetricsContentType)
+		h.WriteHeader(http.StatusOK)
+		if err := WriteOpenMetrics(h, families...); err != nil {
+			log.FromContext(r.Context()).Errorf("failed to write metrics: %s", err)
+		}
+	}
+}
+
+func (g Gauge) writeOpenMetrics(bw *bufio.Writer) {
+	writeMetadata(bw, g.Name, "gauge", g.Help)
+	for _, s := range g.Samples {
+		writeSample(bw, g.Name, s)
+	}
+}
+
+func writeMetadata(bw *bufio.Writer, name, typ, help string) {
+	fmt.Fprintf(bw, "# TYPE %s %s\n", name, typ)
+	if help != "" {
+		fmt.Fprintf(bw, "# HELP %s %s\n", name,
+			strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(help))
+	}
+}
+
+func writeSample(bw *bufio.Writer, name string, s Sample) {
+	bw.WriteString(name)
+	if len(s.Labels) > 0 {
+		bw.WriteByte('{')
+		for i, l := range s.Labels {
+			if i > 0 {
+				bw.WriteByte(',')
+			}
+			fmt.Fprintf(bw, `%s="%s"`, l.Name, labelValueEscaper.Replace(l.Value))
+		}
+		bw.WriteByte('}')
+	}
+	bw.WriteByte(' ')
+	bw.WriteString(formatFloat(s.Value))
+	bw.WriteByte('\n')
+}
+
+func formatFloat(v float64) string {
+	if math.IsInf(v, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
@@ -0,0 +1,63 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package metrics
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteOpenMetrics(t *testing.T) {
+	var b bytes.Buffer
+	err := WriteOpenMetrics(&b,
+		Gauge{
+			Name: "deployment_devices",
+			Help: "Number of devices of the deployment by state.",
+			Samples: []Sample{
+				{
+					Labels: []Label{
+						{Name: "deployment_name", Value: `new "release"\n`},
+						{Name: "state", Value: "pending"},
+					},
+					Value: 3,
+				},
+				{Labels: []Label{{Name: "state", Value: "success"}}, Value: 0},
+			},
+		},
+		Gauge{
+			Name:    "deployment_complete_percent",
+			Samples: []Sample{{Value: 37.5}},
+		},
+		Gauge{Name: "empty"},
+	)
+	assert.NoError(t, err)
+	assert.Equal(t, `# TYPE deployment_devices gauge
+# HELP deployment_devices Number of devices of the deployment by state.
+deployment_devices{deployment_name="new \"release\"\\n",state="pending"} 3
+deployment_devices{state="success"} 0
+# TYPE deployment_complete_percent gauge
+deployment_complete_percent 37.5
+# TYPE empty gauge
+# EOF
+`, b.String())
+}
+
+func TestWriteOpenMetricsEmpty(t *testing.T) {
+	var b bytes.Buffer
+	assert.NoError(t, WriteOpenMetrics(&b))
+	assert.Equal(t, "# EOF\n", b.String())
+}
@@ -0,0 +1,370 @@
+// Copyright 2018 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package restutil
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ant0ine/go-json-rest/rest"
+	"github.com/mendersoftware/go-lib-micro/identity"
+	"github.com/mendersoftware/go-lib-micro/log"
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/deployments/utils/restutil/view"
+)
+
+const (
+	// Body logging lasts this long if the toggle does not set duration.
+	DefaultBodyLogDuration = 15 * time.Minute
+	// Longest body logging allowed by a toggle.
+	MaxBodyLogDuration = 24 * time.Hour
+	// Bodies are logged up to this size.
+	DefaultBodyLogMaxSize = 64 * 1024
+
+	bodyLogRedacted = "REDACTED"
+)
+
+// Errors
+var (
+	ErrBodyLogNoSelector    = errors.New("tenant_id or route has to be set")
+	ErrBodyLogInvalidRoute  = errors.New("route has to be an absolute path prefix")
+	ErrBodyLogInvalidExpiry = errors.New("duration out of range")
+	ErrBodyLogNotFound      = errors.New("Body logging toggle not found")
+)
+
+// values of JSON fields with names containing any of these are not logged
+var bodyLogSensitiveFields = []string{
+	"authorization",
+	"credential",
+	"password",
+	"secret",
+	"signature",
+	"token",
+}
+
+// BodyLogToggle enables logging of request and response bodies of the
+// tenant (any if empty) on routes with the path prefix (any if empty)
+// until it expires.
+type BodyLogToggle struct {
+	TenantID string    `json:"tenant_id,omitempty"`
+	Route    string    `json:"route,omitempty"`
+	Expires  time.Time `json:"expires"`
+}
+
+func (t *BodyLogToggle) key() string {
+	return t.TenantID + " " + t.Route
+}
+
+func (t *BodyLogToggle) matches(tenantID, path string) bool {
+	return (t.TenantID == "" || t.TenantID == tenantID) &&
+		(t.Route == "" || strings.HasPrefix(path, t.Route))
+}
+
+// BodyLogToggles keeps body logging toggles of the service instance;
+// expired toggles are dropped when consulted.
+type BodyLogToggles struct {
+	mu      sync.Mutex
+	toggles map[string]BodyLogToggle
+	now     func() time.Time
+}
+
+func NewBodyLogToggles() *BodyLogToggles {
+	return &BodyLogToggles{
+		toggles: map[string]BodyLogToggle{},
+		now:     time.Now,
+	}
+}
+
+// Set enables body logging for the given duration, replacing the toggle
+// of the same tenant and route.
+func (b *BodyLogToggles) Set(tenantID, route string, duration time.Duration) (*BodyLogToggle, error) {
+	if tenantID == "" && route == "" {
+		return nil, ErrBodyLogNoSelector
+	}
+	if route != "" && !strings.HasPrefix(route, "/") {
+		return nil, ErrBodyLogInvalidRoute
+	}
+	if duration == 0 {
+		duration = DefaultBodyLogDuration
+	}
+	if duration < 0 || duration > MaxBodyLogDuration {
+		return nil, ErrBodyLogInvalidExpiry
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	toggle := BodyLogToggle{
+		TenantID: tenantID,
+		Route:    route,
+		Expires:  b.now().Add(duration).UTC(),
+	}
+	b.toggles[toggle.key()] = toggle
+	return &toggle, nil
+}
+
+// Delete disables body logging of the tenant and route, returns false if
+// not enabled.
+func (b *BodyLogToggles) Delete(tenantID, route string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.prune()
+	key := (&BodyLogToggle{TenantID: tenantID, Route: route}).key()
+	_, ok := b.toggles[key]
+	delete(b.toggles, key)
+	return ok
+}
+
+// List returns active toggles sorted by tenant and route.
+func (b *BodyLogToggles) List() []BodyLogToggle {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.prune()
+	list := make([]BodyLogToggle, 0, len(b.toggles))
+	for _, t := range b.toggles {
+		list = append(list, t)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].key() < list[j].key()
+	})
+	return list
+}
+
+// Enabled returns true if bodies of the tenant's requests of the path
+// are logged.
+func (b *BodyLogToggles) Enabled(tenantID, path string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if len(b.toggles) == 0 {
+		return false
+	}
+	b.prune()
+	for _, t := range b.toggles {
+		if t.matches(tenantID, path) {
+			return true
+		}
+	}
+	return false
+}
+
+func (b *BodyLogToggles) prune() {
+	now := b.now()
+	for key, t := range b.toggles {
+		if !now.Before(t.Expires) {
+			delete(b.toggles, key)
+		}
+	}
+}
+
+// ListToggles renders active body logging toggles.
+func (b *BodyLogToggles) ListToggles(w rest.ResponseWriter, r *rest.Request) {
+	w.WriteJson(b.List())
+}
+
+// SetToggle enables body logging of the tenant and route from the request
+// body, for duration in seconds.
+func (b *BodyLogToggles) SetToggle(w rest.ResponseWriter, r *rest.Request) {
+	l := log.FromContext(r.Context())
+
+	var req struct {
+		TenantID string `json:"tenant_id"`
+		Route    string `json:"route"`
+		Duration int64  `json:"duration"`
+	}
+	if err := DecodeJSONPayload(r, &req); err != nil {
+		new(view.RESTView).RenderError(w, r,
+			errors.Wrap(err, "Validating request body"), http.StatusBadRequest, l)
+		return
+	}
+
+	toggle, err := b.Set(req.TenantID, req.Route, time.Duration(req.Duration)*time.Second)
+	if err != nil {
+		new(view.RESTView).RenderError(w, r,
+			errors.Wrap(err, "Validating request body"), http.StatusBadRequest, l)
+		return
+	}
+
+	l.Infof("body logging enabled for tenant %q, route %q until %s",
+		toggle.TenantID, toggle.Route, toggle.Expires.Format(time.RFC3339))
+	w.WriteJson(toggle)
+}
+
+// DeleteToggle disables body logging of the tenant_id and route from the
+// query.
+func (b *BodyLogToggles) DeleteToggle(w rest.ResponseWriter, r *rest.Request) {
+	l := log.FromContext(r.Context())
+
+	q := r.URL.Query()
+	if !b.Delete(q.Get("tenant_id"), q.Get("route")) {
+		new(view.RESTView).RenderError(w, r, ErrBodyLogNotFound, http.StatusNotFound, l)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// BodyLogMiddleware logs sanitized request and response bodies of requests
+// with the path prefix, while enabled by a toggle.
+// Has to be used after the identity middleware.
+type BodyLogMiddleware struct {
+	Toggles *BodyLogToggles
+	Prefix  string
+	// bodies over the size are not logged, DefaultBodyLogMaxSize if 0
+	MaxSize int
+}
+
+// MiddlewareFunc makes BodyLogMiddleware implement the Middleware interface.
+func (mw *BodyLogMiddleware) MiddlewareFunc(h rest.HandlerFunc) rest.HandlerFunc {
+	return func(w rest.ResponseWriter, r *rest.Request) {
+		if !strings.HasPrefix(r.URL.Path, mw.Prefix) {
+			h(w, r)
+			return
+		}
+		var tenantID string
+		if id := identity.FromContext(r.Context()); id != nil {
+			tenantID = id.Tenant
+		}
+		if !mw.Toggles.Enabled(tenantID, r.URL.Path) {
+			h(w, r)
+			return
+		}
+
+		maxSize := mw.MaxSize
+		if maxSize <= 0 {
+			maxSize = DefaultBodyLogMaxSize
+		}
+
+		// read at most one byte over the limit, the handler gets the
+		// whole body anyway
+		var request []byte
+		if r.Body != nil {
+			request, _ = ioutil.ReadAll(io.LimitReader(r.Body, int64(maxSize)+1))
+			r.Body = struct {
+				io.Reader
+				io.Closer
+			}{io.MultiReader(bytes.NewReader(request), r.Body), r.Body}
+		}
+
+		bw := &bodyLogWriter{ResponseWriter: w, code: http.StatusOK, max: maxSize}
+		h(bw, r)
+
+		log.FromContext(r.Context()).Infof("body log: %s %s request: %s response %d: %s",
+			r.Method, r.URL.Path,
+			SanitizeBody(request, maxSize),
+			bw.code,
+			SanitizeBody(bw.body.Bytes(), maxSize))
+	}
+}
+
+// bodyLogWriter keeps a copy of the response body up to the size limit.
+type bodyLogWriter struct {
+	rest.ResponseWriter
+
+	body bytes.Buffer
+	code int
+	max  int
+}
+
+func (bw *bodyLogWriter) WriteJson(v interface{}) error {
+	b, err := bw.EncodeJson(v)
+	if err != nil {
+		return err
+	}
+	_, err = bw.Write(b)
+	return err
+}
+
+func (bw *bodyLogWriter) WriteHeader(code int) {
+	bw.code = code
+	bw.ResponseWriter.WriteHeader(code)
+}
+
+func (bw *bodyLogWriter) Write(b []byte) (int, error) {
+	if room := bw.max + 1 - bw.body.Len(); room > 0 {
+		if len(b) < room {
+			room = len(b)
+		}
+		bw.body.Write(b[:room])
+	}
+	if w, ok := bw.ResponseWriter.(http.ResponseWriter); ok {
+		return w.Write(b)
+	}
+	return len(b), nil
+}
+
+// SanitizeBody returns the JSON body with values of sensitive fields and
+// URL query strings, e.g. of presigned links, redacted. Bodies which are
+// not JSON or over the size limit are described only.
+func SanitizeBody(body []byte, maxSize int) string {
+	if len(body) == 0 {
+		return "<empty>"
+	}
+	if len(body) > maxSize {
+		return fmt.Sprintf("<over %d bytes>", maxSize)
+	}
+
+	var v interface{}
+	if err := json.Unmarshal(body, &v); err != nil {
+		return fmt.Sprintf("<%d bytes, not JSON>", len(body))
+	}
+	sanitized, _ := json.Marshal(sanitizeValue(v))
+	return string(sanitized)
+}
+
+func sanitizeValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for key, value := range v {
+			if isSensitiveField(key) {
+				v[key] = bodyLogRedacted
+			} else {
+				v[key] = sanitizeValue(value)
+			}
+		}
+	case []interface{}:
+		for i, value := range v {
+			v[i] = sanitizeValue(value)
+		}
+	case string:
+		if u, err := url.Parse(v); err == nil && u.Scheme != "" &&
+			u.Host != "" && u.RawQuery != "" {
+			u.RawQuery = bodyLogRedacted
+			return u.String()
+		}
+	}
+	return v
+}
+
+func isSensitiveField(name string) bool {
+	name = strings.ToLower(name)
+	for _, field := range bodyLogSensitiveFields {
+		if strings.Contains(name, field) {
+			return true
+		}
+	}
+	return false
+}
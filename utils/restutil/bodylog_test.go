@@ -0,0 +1,237 @@
+// Copyright 2018 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package restutil
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/ant0ine/go-json-rest/rest"
+	"github.com/ant0ine/go-json-rest/rest/test"
+	"github.com/mendersoftware/go-lib-micro/identity"
+	"github.com/mendersoftware/go-lib-micro/requestlog"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBodyLogToggles(t *testing.T) {
+
+	t.Parallel()
+
+	now := time.Date(2018, 5, 1, 10, 0, 0, 0, time.UTC)
+	toggles := NewBodyLogToggles()
+	toggles.now = func() time.Time { return now }
+
+	_, err := toggles.Set("", "", 0)
+	assert.EqualError(t, err, ErrBodyLogNoSelector.Error())
+	_, err = toggles.Set("", "api/devices", 0)
+	assert.EqualError(t, err, ErrBodyLogInvalidRoute.Error())
+	_, err = toggles.Set("tenant", "", 25*time.Hour)
+	assert.EqualError(t, err, ErrBodyLogInvalidExpiry.Error())
+	_, err = toggles.Set("tenant", "", -time.Second)
+	assert.EqualError(t, err, ErrBodyLogInvalidExpiry.Error())
+
+	toggle, err := toggles.Set("tenant", "", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, &BodyLogToggle{
+		TenantID: "tenant",
+		Expires:  now.Add(DefaultBodyLogDuration),
+	}, toggle)
+	_, err = toggles.Set("", "/api/devices/v1/deployments/device/deployments/1/", time.Hour)
+	assert.NoError(t, err)
+
+	assert.True(t, toggles.Enabled("tenant", "/api/devices/v1/deployments/device/deployments/next"))
+	assert.True(t, toggles.Enabled("other", "/api/devices/v1/deployments/device/deployments/1/log"))
+	assert.False(t, toggles.Enabled("other", "/api/devices/v1/deployments/device/deployments/next"))
+	assert.Len(t, toggles.List(), 2)
+
+	// the tenant toggle expires first
+	now = now.Add(DefaultBodyLogDuration)
+	assert.False(t, toggles.Enabled("tenant", "/api/devices/v1/deployments/device/deployments/next"))
+	assert.Equal(t, []BodyLogToggle{{
+		Route:   "/api/devices/v1/deployments/device/deployments/1/",
+		Expires: now.Add(time.Hour - DefaultBodyLogDuration),
+	}}, toggles.List())
+
+	assert.False(t, toggles.Delete("tenant", ""))
+	assert.True(t, toggles.Delete("", "/api/devices/v1/deployments/device/deployments/1/"))
+	assert.Empty(t, toggles.List())
+}
+
+func TestBodyLogTogglesHandlers(t *testing.T) {
+
+	t.Parallel()
+
+	toggles := NewBodyLogToggles()
+	router, err := rest.MakeRouter(
+		rest.Get("/r", toggles.ListToggles),
+		rest.Put("/r", toggles.SetToggle),
+		rest.Delete("/r", toggles.DeleteToggle),
+	)
+	assert.NoError(t, err)
+	api := rest.NewApi()
+	api.Use(&requestlog.RequestLogMiddleware{
+		BaseLogger: &logrus.Logger{Out: ioutil.Discard},
+	})
+	api.SetApp(router)
+	handler := api.MakeHandler()
+
+	recorded := test.RunRequest(t, handler,
+		test.MakeSimpleRequest(http.MethodPut, "http://1.2.3.4/r",
+			map[string]interface{}{"route": "devices"}))
+	recorded.CodeIs(http.StatusBadRequest)
+	recorded.BodyIs(`{"error":"Validating request body: ` +
+		ErrBodyLogInvalidRoute.Error() + `","request_id":""}`)
+
+	recorded = test.RunRequest(t, handler,
+		test.MakeSimpleRequest(http.MethodPut, "http://1.2.3.4/r",
+			map[string]interface{}{"tenant_id": "tenant", "duration": 60}))
+	recorded.CodeIs(http.StatusOK)
+	var toggle BodyLogToggle
+	assert.NoError(t, recorded.DecodeJsonPayload(&toggle))
+	assert.Equal(t, "tenant", toggle.TenantID)
+	assert.WithinDuration(t, time.Now().Add(time.Minute), toggle.Expires, 10*time.Second)
+
+	recorded = test.RunRequest(t, handler,
+		test.MakeSimpleRequest(http.MethodGet, "http://1.2.3.4/r", nil))
+	recorded.CodeIs(http.StatusOK)
+	var list []BodyLogToggle
+	assert.NoError(t, recorded.DecodeJsonPayload(&list))
+	assert.Equal(t, []BodyLogToggle{toggle}, list)
+
+	recorded = test.RunRequest(t, handler,
+		test.MakeSimpleRequest(http.MethodDelete, "http://1.2.3.4/r?tenant_id=tenant", nil))
+	recorded.CodeIs(http.StatusNoContent)
+
+	recorded = test.RunRequest(t, handler,
+		test.MakeSimpleRequest(http.MethodDelete, "http://1.2.3.4/r?tenant_id=tenant", nil))
+	recorded.CodeIs(http.StatusNotFound)
+}
+
+func TestBodyLogMiddleware(t *testing.T) {
+
+	t.Parallel()
+
+	testCases := map[string]struct {
+		Tenant  string
+		Path    string
+		Request string
+
+		Logged string
+	}{
+		"logged, sanitized": {
+			Tenant:  "tenant",
+			Path:    "/api/devices/v1/deployments/device/deployments/next",
+			Request: `{"device_type":"qemu","token":"t0k3n"}`,
+
+			Logged: `body log: POST /api/devices/v1/deployments/device/deployments/next ` +
+				`request: {"device_type":"qemu","token":"REDACTED"} ` +
+				`response 200: {"artifact":{"source":{"uri":"https://s3.example.com/a?REDACTED"}}}`,
+		},
+		"logged, not JSON": {
+			Tenant:  "tenant",
+			Path:    "/api/devices/v1/deployments/device/deployments/next",
+			Request: "garbage",
+
+			Logged: `request: <7 bytes, not JSON>`,
+		},
+		"logged, over size": {
+			Tenant:  "tenant",
+			Path:    "/api/devices/v1/deployments/device/deployments/next",
+			Request: `{"messages":"` + strings.Repeat("x", 200) + `"}`,
+
+			Logged: `request: <over 128 bytes> response 200: <over 128 bytes>`,
+		},
+		"other tenant": {
+			Tenant:  "other",
+			Path:    "/api/devices/v1/deployments/device/deployments/next",
+			Request: `{"device_type":"qemu"}`,
+		},
+		"not device API": {
+			Tenant:  "tenant",
+			Path:    "/api/management/v1/deployments/deployments",
+			Request: `{"device_type":"qemu"}`,
+		},
+	}
+
+	toggles := NewBodyLogToggles()
+	_, err := toggles.Set("tenant", "", time.Hour)
+	assert.NoError(t, err)
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			var out bytes.Buffer
+
+			api := rest.NewApi()
+			api.Use(&requestlog.RequestLogMiddleware{
+				BaseLogger: &logrus.Logger{
+					Out:       &out,
+					Formatter: &logrus.JSONFormatter{},
+					Level:     logrus.InfoLevel,
+				},
+			})
+			api.Use(rest.MiddlewareSimple(func(h rest.HandlerFunc) rest.HandlerFunc {
+				return func(w rest.ResponseWriter, r *rest.Request) {
+					r.Request = r.WithContext(identity.WithContext(r.Context(),
+						&identity.Identity{Tenant: tc.Tenant}))
+					h(w, r)
+				}
+			}))
+			api.Use(&BodyLogMiddleware{
+				Toggles: toggles,
+				Prefix:  "/api/devices/",
+				MaxSize: 128,
+			})
+			api.SetApp(rest.AppSimple(func(w rest.ResponseWriter, r *rest.Request) {
+				// the handler receives the whole body
+				body, err := ioutil.ReadAll(r.Body)
+				assert.NoError(t, err)
+				assert.Equal(t, tc.Request, string(body))
+
+				if len(body) > 128 {
+					w.WriteJson(map[string]string{"echo": string(body)})
+					return
+				}
+				w.WriteJson(map[string]interface{}{
+					"artifact": map[string]interface{}{
+						"source": map[string]string{
+							"uri": "https://s3.example.com/a?X-Amz-Signature=abc",
+						},
+					},
+				})
+			}))
+
+			req := test.MakeSimpleRequest(http.MethodPost, "http://1.2.3.4"+tc.Path, nil)
+			req.Body = ioutil.NopCloser(strings.NewReader(tc.Request))
+			recorded := test.RunRequest(t, api.MakeHandler(), req)
+			recorded.CodeIs(http.StatusOK)
+
+			if tc.Logged == "" {
+				assert.NotContains(t, out.String(), "body log")
+				return
+			}
+			var entry struct {
+				Msg string `json:"msg"`
+			}
+			assert.NoError(t, json.Unmarshal(out.Bytes(), &entry))
+			assert.Contains(t, entry.Msg, tc.Logged)
+		})
+	}
+}
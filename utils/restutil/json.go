@@ -0,0 +1,81 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package restutil
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"strconv"
+	"strings"
+
+	"github.com/ant0ine/go-json-rest/rest"
+)
+
+const envStrictJSON = "STRICT_JSON"
+
+// UnknownFieldError is returned when decoding strictly a request body with a
+// field not known to the decoded type.
+type UnknownFieldError struct {
+	Field string
+}
+
+func (e *UnknownFieldError) Error() string {
+	return "unknown field " + strconv.Quote(e.Field)
+}
+
+// StrictJSONMiddleware makes DecodeJSONPayload reject request bodies
+// containing unknown fields, e.g. misspelled ones, instead of ignoring them.
+type StrictJSONMiddleware struct{}
+
+// MiddlewareFunc makes StrictJSONMiddleware implement the Middleware interface.
+func (mw *StrictJSONMiddleware) MiddlewareFunc(h rest.HandlerFunc) rest.HandlerFunc {
+	return func(w rest.ResponseWriter, r *rest.Request) {
+		r.Env[envStrictJSON] = true
+		h(w, r)
+	}
+}
+
+// DecodeJSONPayload reads the request body and decodes it into v, same as
+// rest.Request.DecodeJsonPayload. If the request passed StrictJSONMiddleware,
+// fields unknown to v fail decoding with *UnknownFieldError.
+func DecodeJSONPayload(r *rest.Request, v interface{}) error {
+	strict, _ := r.Env[envStrictJSON].(bool)
+	if !strict {
+		return r.DecodeJsonPayload(v)
+	}
+
+	content, err := ioutil.ReadAll(r.Body)
+	r.Body.Close()
+	if err != nil {
+		return err
+	}
+	if len(content) == 0 {
+		return rest.ErrJsonPayloadEmpty
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(content))
+	dec.DisallowUnknownFields()
+	err = dec.Decode(v)
+	// encoding/json does not export the error of an unknown field
+	const unknownField = "json: unknown field "
+	if err != nil && strings.HasPrefix(err.Error(), unknownField) {
+		field, uerr := strconv.Unquote(strings.TrimPrefix(err.Error(), unknownField))
+		if uerr == nil {
+			return &UnknownFieldError{Field: field}
+		}
+	}
+	return err
+}
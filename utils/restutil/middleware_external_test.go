@@ -0,0 +1,323 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package restutil_test
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ant0ine/go-json-rest/rest"
+	"github.com/ant0ine/go-json-rest/rest/test"
+	"github.com/stretchr/testify/assert"
+
+	. "github.com/mendersoftware/deployments/utils/restutil"
+)
+
+func TestRecoverMiddleware(t *testing.T) {
+
+	t.Parallel()
+
+	api := rest.NewApi()
+	api.Use(&RecoverMiddleware{})
+	api.SetApp(rest.AppSimple(func(w rest.ResponseWriter, r *rest.Request) {
+		panic("boom")
+	}))
+
+	recorded := test.RunRequest(t, api.MakeHandler(),
+		test.MakeSimpleRequest(http.MethodGet, "http://1.2.3.4/r", nil))
+
+	recorded.CodeIs(http.StatusInternalServerError)
+	recorded.ContentTypeIsJson()
+	recorded.BodyIs(`{"error":"internal error","request_id":""}`)
+}
+
+func TestParseRouteTimeout(t *testing.T) {
+
+	t.Parallel()
+
+	route, err := ParseRouteTimeout("post:/api/artifacts=10m")
+	assert.NoError(t, err)
+	assert.Equal(t, &RouteTimeout{
+		Method:  http.MethodPost,
+		Prefix:  "/api/artifacts",
+		Timeout: 10 * time.Minute,
+	}, route)
+
+	route, err = ParseRouteTimeout("/api/devices=5s")
+	assert.NoError(t, err)
+	assert.Equal(t, &RouteTimeout{
+		Prefix:  "/api/devices",
+		Timeout: 5 * time.Second,
+	}, route)
+
+	for _, rule := range []string{"/api/devices", "/api/devices=5", "POST /api=5s"} {
+		_, err = ParseRouteTimeout(rule)
+		assert.Error(t, err, rule)
+	}
+}
+
+func TestTimeoutMiddleware(t *testing.T) {
+
+	t.Parallel()
+
+	testCases := map[string]struct {
+		Path  string
+		Sleep time.Duration
+		Panic bool
+
+		Code int
+		Body string
+	}{
+		"in time": {
+			Path: "/fast",
+			Code: http.StatusCreated,
+			Body: `{"status":"ok"}`,
+		},
+		"timed out": {
+			Path:  "/fast",
+			Sleep: time.Second,
+			Code:  http.StatusServiceUnavailable,
+			Body:  `{"error":"Request timed out","request_id":""}`,
+		},
+		"route override": {
+			Path:  "/slow",
+			Sleep: 100 * time.Millisecond,
+			Code:  http.StatusCreated,
+			Body:  `{"status":"ok"}`,
+		},
+		"no default": {
+			Path:  "/upload",
+			Sleep: 100 * time.Millisecond,
+			Code:  http.StatusCreated,
+			Body:  `{"status":"ok"}`,
+		},
+		"no default, path under": {
+			Path:  "/upload/fetch",
+			Sleep: time.Second,
+			Code:  http.StatusServiceUnavailable,
+			Body:  `{"error":"Request timed out","request_id":""}`,
+		},
+		"panic": {
+			Path:  "/fast",
+			Panic: true,
+			Code:  http.StatusInternalServerError,
+			Body:  `{"error":"internal error","request_id":""}`,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			api := rest.NewApi()
+			api.Use(&RecoverMiddleware{}, &TimeoutMiddleware{
+				Default: 50 * time.Millisecond,
+				Routes: []RouteTimeout{
+					{Prefix: "/slow", Timeout: time.Second},
+				},
+				NoDefault: []RouteTimeout{
+					{Prefix: "/upload", Exact: true},
+				},
+			})
+			api.SetApp(rest.AppSimple(func(w rest.ResponseWriter, r *rest.Request) {
+				if tc.Panic {
+					panic("boom")
+				}
+				select {
+				case <-time.After(tc.Sleep):
+				case <-r.Context().Done():
+				}
+				w.WriteHeader(http.StatusCreated)
+				w.WriteJson(map[string]string{"status": "ok"})
+			}))
+
+			recorded := test.RunRequest(t, api.MakeHandler(),
+				test.MakeSimpleRequest(http.MethodGet, "http://1.2.3.4"+tc.Path, nil))
+
+			recorded.CodeIs(tc.Code)
+			recorded.ContentTypeIsJson()
+			recorded.BodyIs(tc.Body)
+		})
+	}
+}
+
+func TestTimeoutMiddlewareRequestCopy(t *testing.T) {
+
+	t.Parallel()
+
+	var outer *http.Request
+	handled := make(chan struct{})
+	api := rest.NewApi()
+	api.Use(rest.MiddlewareSimple(func(h rest.HandlerFunc) rest.HandlerFunc {
+		return func(w rest.ResponseWriter, r *rest.Request) {
+			req := r.Request
+			h(w, r)
+			outer = r.Request
+			assert.Equal(t, req, outer)
+		}
+	}), &TimeoutMiddleware{
+		Default: 50 * time.Millisecond,
+	})
+	api.SetApp(rest.AppSimple(func(w rest.ResponseWriter, r *rest.Request) {
+		defer close(handled)
+		<-r.Context().Done()
+		// changes made by the handler still running are not seen by the
+		// middleware which timed it out
+		r.Request = r.WithContext(context.Background())
+		r.PathParams = map[string]string{"id": "1"}
+		w.WriteHeader(http.StatusCreated)
+	}))
+
+	recorded := test.RunRequest(t, api.MakeHandler(),
+		test.MakeSimpleRequest(http.MethodGet, "http://1.2.3.4/fast", nil))
+	<-handled
+
+	recorded.CodeIs(http.StatusServiceUnavailable)
+	recorded.BodyIs(`{"error":"Request timed out","request_id":""}`)
+	assert.NotNil(t, outer)
+	assert.NoError(t, outer.Context().Err())
+}
+
+func TestTimeoutMiddlewareStreaming(t *testing.T) {
+
+	t.Parallel()
+
+	testCases := map[string]struct {
+		Path   string
+		Accept string
+
+		Timeout time.Duration
+	}{
+		"streaming route": {
+			Path:    "/stream",
+			Timeout: 100 * time.Millisecond,
+		},
+		"streaming route, no timeout": {
+			Path: "/stream/forever",
+		},
+		"ndjson": {
+			Path:    "/fast",
+			Accept:  "application/x-ndjson",
+			Timeout: 50 * time.Millisecond,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			var flushed bool
+			var deadline bool
+			api := rest.NewApi()
+			api.Use(&RecoverMiddleware{}, &TimeoutMiddleware{
+				Default: 50 * time.Millisecond,
+				Streaming: []RouteTimeout{
+					{Prefix: "/stream/forever"},
+					{Prefix: "/stream", Timeout: 100 * time.Millisecond},
+				},
+			})
+			api.SetApp(rest.AppSimple(func(w rest.ResponseWriter, r *rest.Request) {
+				w.(http.ResponseWriter).Write([]byte("{}\n"))
+				if flusher, ok := w.(http.Flusher); ok {
+					flusher.Flush()
+					flushed = true
+				}
+				_, deadline = r.Context().Deadline()
+
+				select {
+				case <-r.Context().Done():
+				case <-time.After(time.Second):
+				}
+				w.(http.ResponseWriter).Write([]byte("{}\n"))
+			}))
+
+			req := test.MakeSimpleRequest(http.MethodGet, "http://1.2.3.4"+tc.Path, nil)
+			if tc.Accept != "" {
+				req.Header.Set("Accept", tc.Accept)
+			}
+			start := time.Now()
+			recorded := test.RunRequest(t, api.MakeHandler(), req)
+
+			// not buffered, the handler is stopped by the request context
+			recorded.CodeIs(http.StatusOK)
+			recorded.BodyIs("{}\n{}\n")
+			assert.True(t, flushed)
+			assert.Equal(t, tc.Timeout > 0, deadline)
+			if tc.Timeout > 0 {
+				assert.True(t, time.Since(start) < time.Second)
+			}
+		})
+	}
+}
+
+func TestStrictJSONMiddleware(t *testing.T) {
+
+	t.Parallel()
+
+	testCases := map[string]struct {
+		Strict bool
+		Body   string
+
+		Name  string
+		Error error
+	}{
+		"ok": {
+			Body: `{"name":"foo","other":"bar"}`,
+			Name: "foo",
+		},
+		"ok, strict": {
+			Strict: true,
+			Body:   `{"name":"foo"}`,
+			Name:   "foo",
+		},
+		"error, strict unknown field": {
+			Strict: true,
+			Body:   `{"name":"foo","other":"bar"}`,
+			Error:  &UnknownFieldError{Field: "other"},
+		},
+		"error, strict empty": {
+			Strict: true,
+			Error:  rest.ErrJsonPayloadEmpty,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			api := rest.NewApi()
+			if tc.Strict {
+				api.Use(&StrictJSONMiddleware{})
+			}
+			api.SetApp(rest.AppSimple(func(w rest.ResponseWriter, r *rest.Request) {
+				var value struct {
+					Name string `json:"name"`
+				}
+				err := DecodeJSONPayload(r, &value)
+				if tc.Error != nil {
+					assert.EqualError(t, err, tc.Error.Error())
+					assert.IsType(t, tc.Error, err)
+				} else {
+					assert.NoError(t, err)
+					assert.Equal(t, tc.Name, value.Name)
+				}
+				w.WriteHeader(http.StatusNoContent)
+			}))
+
+			req := test.MakeSimpleRequest(http.MethodPost, "http://1.2.3.4/r", nil)
+			req.Body = ioutil.NopCloser(strings.NewReader(tc.Body))
+			recorded := test.RunRequest(t, api.MakeHandler(), req)
+			recorded.CodeIs(http.StatusNoContent)
+		})
+	}
+}
@@ -0,0 +1,56 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package restutil
+
+import (
+	"fmt"
+	"runtime/debug"
+
+	"github.com/ant0ine/go-json-rest/rest"
+	"github.com/mendersoftware/go-lib-micro/log"
+
+	"github.com/mendersoftware/deployments/utils/restutil/view"
+)
+
+// handlerPanic carries panic raised in another goroutine along with
+// the stack trace of the goroutine.
+type handlerPanic struct {
+	value interface{}
+	stack []byte
+}
+
+// RecoverMiddleware recovers from panics in handlers, logs the panic
+// with the stack trace and responds with structured internal error.
+type RecoverMiddleware struct{}
+
+// MiddlewareFunc makes RecoverMiddleware implement the Middleware interface.
+func (mw *RecoverMiddleware) MiddlewareFunc(h rest.HandlerFunc) rest.HandlerFunc {
+	return func(w rest.ResponseWriter, r *rest.Request) {
+		defer func() {
+			if rec := recover(); rec != nil {
+				stack := debug.Stack()
+				if p, ok := rec.(*handlerPanic); ok {
+					rec, stack = p.value, p.stack
+				}
+
+				l := log.FromContext(r.Context()).F(log.Ctx{"stack": string(stack)})
+				new(view.RESTView).RenderInternalError(w, r,
+					fmt.Errorf("recovered from panic: %v", rec), l)
+			}
+		}()
+
+		h(w, r)
+	}
+}
@@ -0,0 +1,273 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package restutil
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ant0ine/go-json-rest/rest"
+	"github.com/mendersoftware/go-lib-micro/log"
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/deployments/utils/restutil/view"
+)
+
+// Errors
+var (
+	ErrRequestTimeout   = errors.New("Request timed out")
+	ErrInvalidRouteRule = errors.New("invalid route timeout rule")
+)
+
+// RouteTimeout sets timeout of requests with the given method
+// (any if empty) and path prefix; with Exact set, of the path only.
+type RouteTimeout struct {
+	Method  string
+	Prefix  string
+	Exact   bool
+	Timeout time.Duration
+}
+
+// ParseRouteTimeout parses rule in "[<METHOD>:]<path prefix>=<duration>" format,
+// e.g. "POST:/api/management/v1/deployments/artifacts=10m".
+func ParseRouteTimeout(rule string) (*RouteTimeout, error) {
+	parts := strings.SplitN(rule, "=", 2)
+	if len(parts) != 2 {
+		return nil, errors.Wrap(ErrInvalidRouteRule, rule)
+	}
+
+	timeout, err := time.ParseDuration(strings.TrimSpace(parts[1]))
+	if err != nil {
+		return nil, errors.Wrapf(ErrInvalidRouteRule, "%s: %s", rule, err.Error())
+	}
+
+	route := &RouteTimeout{Timeout: timeout}
+
+	route.Prefix = strings.TrimSpace(parts[0])
+	if !strings.HasPrefix(route.Prefix, "/") {
+		fields := strings.SplitN(route.Prefix, ":", 2)
+		if len(fields) != 2 || !strings.HasPrefix(fields[1], "/") {
+			return nil, errors.Wrap(ErrInvalidRouteRule, rule)
+		}
+		route.Method = strings.ToUpper(fields[0])
+		route.Prefix = fields[1]
+	}
+
+	return route, nil
+}
+
+func (rt *RouteTimeout) matches(r *rest.Request) bool {
+	if rt.Method != "" && rt.Method != r.Method {
+		return false
+	}
+	if rt.Exact {
+		return r.URL.Path == rt.Prefix
+	}
+	return strings.HasPrefix(r.URL.Path, rt.Prefix)
+}
+
+// TimeoutMiddleware limits handler execution time. The request context gets
+// the deadline set, and if the handler does not finish in time the client
+// receives 503 Service Unavailable; the handler output is discarded then.
+// The response is buffered until the handler finishes.
+// Routes are evaluated in order, first match wins; requests not matching
+// any route use Default timeout, unless exempt by NoDefault; zero timeout
+// disables the limit.
+//
+// Streamed responses are not buffered: those of Streaming routes, of
+// websocket upgrade requests and of requests accepting NDJSON. Handlers of
+// these are stopped only by cancellation of the request context once the
+// timeout passes. Timeout of Streaming routes is their own, Default does
+// not apply to them.
+type TimeoutMiddleware struct {
+	Default   time.Duration
+	Routes    []RouteTimeout
+	Streaming []RouteTimeout
+	NoDefault []RouteTimeout
+}
+
+func (mw *TimeoutMiddleware) timeout(r *rest.Request) time.Duration {
+	for _, route := range mw.Routes {
+		if route.matches(r) {
+			return route.Timeout
+		}
+	}
+	for _, route := range mw.NoDefault {
+		if route.matches(r) {
+			return 0
+		}
+	}
+	return mw.Default
+}
+
+// streaming returns the timeout of the request if its response is streamed.
+func (mw *TimeoutMiddleware) streaming(r *rest.Request) (time.Duration, bool) {
+	for _, route := range mw.Streaming {
+		if route.matches(r) {
+			return route.Timeout, true
+		}
+	}
+
+	if strings.EqualFold(r.Header.Get("Upgrade"), "websocket") ||
+		strings.Contains(r.Header.Get("Accept"), view.MediaTypeNDJSON) {
+		return mw.timeout(r), true
+	}
+
+	return 0, false
+}
+
+// MiddlewareFunc makes TimeoutMiddleware implement the Middleware interface.
+func (mw *TimeoutMiddleware) MiddlewareFunc(h rest.HandlerFunc) rest.HandlerFunc {
+	return func(w rest.ResponseWriter, r *rest.Request) {
+		if timeout, ok := mw.streaming(r); ok {
+			if timeout > 0 {
+				ctx, cancel := context.WithTimeout(r.Context(), timeout)
+				defer cancel()
+				r.Request = r.WithContext(ctx)
+			}
+			h(w, r)
+			return
+		}
+
+		timeout := mw.timeout(r)
+		if timeout <= 0 {
+			h(w, r)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		// the handler may still run once timed out, it gets its own copy
+		// of the request
+		params := make(map[string]string, len(r.PathParams))
+		for k, v := range r.PathParams {
+			params[k] = v
+		}
+		hr := &rest.Request{
+			Request:    r.WithContext(ctx),
+			PathParams: params,
+			Env:        r.Env,
+		}
+
+		tw := &timeoutWriter{
+			w:    w,
+			h:    make(http.Header),
+			code: http.StatusOK,
+		}
+
+		done := make(chan struct{})
+		panics := make(chan *handlerPanic, 1)
+		go func() {
+			defer func() {
+				if rec := recover(); rec != nil {
+					panics <- &handlerPanic{value: rec, stack: debug.Stack()}
+				}
+			}()
+			h(tw, hr)
+			close(done)
+		}()
+
+		select {
+		case p := <-panics:
+			// let the recovery middleware handle it
+			panic(p)
+		case <-done:
+			tw.flush()
+		case <-ctx.Done():
+			tw.mu.Lock()
+			defer tw.mu.Unlock()
+			tw.timedOut = true
+
+			new(view.RESTView).RenderError(w, r, ErrRequestTimeout,
+				http.StatusServiceUnavailable, log.FromContext(ctx))
+		}
+	}
+}
+
+// timeoutWriter buffers the handler response, so that it can be discarded
+// if the handler times out.
+type timeoutWriter struct {
+	w rest.ResponseWriter
+	h http.Header
+
+	mu          sync.Mutex
+	buf         bytes.Buffer
+	code        int
+	wroteHeader bool
+	timedOut    bool
+}
+
+func (tw *timeoutWriter) Header() http.Header {
+	return tw.h
+}
+
+func (tw *timeoutWriter) EncodeJson(v interface{}) ([]byte, error) {
+	return tw.w.EncodeJson(v)
+}
+
+func (tw *timeoutWriter) WriteJson(v interface{}) error {
+	b, err := tw.EncodeJson(v)
+	if err != nil {
+		return err
+	}
+	_, err = tw.Write(b)
+	return err
+}
+
+func (tw *timeoutWriter) WriteHeader(code int) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+
+	if tw.timedOut || tw.wroteHeader {
+		return
+	}
+	tw.code = code
+	tw.wroteHeader = true
+}
+
+func (tw *timeoutWriter) Write(b []byte) (int, error) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+
+	if tw.timedOut {
+		return 0, ErrRequestTimeout
+	}
+	tw.wroteHeader = true
+	return tw.buf.Write(b)
+}
+
+// flush copies the buffered response to the underlying writer.
+func (tw *timeoutWriter) flush() {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+
+	header := tw.w.Header()
+	for k, v := range tw.h {
+		header[k] = v
+	}
+	tw.w.WriteHeader(tw.code)
+
+	if tw.buf.Len() > 0 {
+		if w, ok := tw.w.(http.ResponseWriter); ok {
+			w.Write(tw.buf.Bytes())
+		}
+	}
+}
@@ -0,0 +1,201 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package view
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"mime"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ant0ine/go-json-rest/rest"
+)
+
+// Media types supported for collections
+const (
+	MediaTypeJSON   = "application/json"
+	MediaTypeNDJSON = "application/x-ndjson"
+	MediaTypeCSV    = "text/csv"
+)
+
+// RenderCollection renders list of objects in the format selected by
+// the Accept header of the request: JSON array (default), newline
+// delimited JSON streamed object by object, or CSV with a header row
+// made of the top level JSON field names.
+func (p *RESTView) RenderCollection(w rest.ResponseWriter, r *rest.Request,
+	collection interface{}) {
+
+	items := reflect.ValueOf(collection)
+	if items.Kind() != reflect.Slice {
+		p.RenderSuccessGet(w, collection)
+		return
+	}
+
+	switch NegotiateMediaType(r.Header.Get("Accept"),
+		MediaTypeJSON, MediaTypeNDJSON, MediaTypeCSV) {
+	case MediaTypeNDJSON:
+		renderNDJSON(w, items)
+	case MediaTypeCSV:
+		renderCSV(w, items)
+	default:
+		p.RenderSuccessGet(w, collection)
+	}
+}
+
+// NegotiateMediaType returns the supported media type preferred by
+// the Accept header value, the first supported type if none matches.
+func NegotiateMediaType(accept string, supported ...string) string {
+	type accepted struct {
+		mediaType string
+		q         float64
+	}
+
+	acceptedTypes := []accepted{}
+	for _, value := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(value))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+		if q > 0 {
+			acceptedTypes = append(acceptedTypes, accepted{mediaType, q})
+		}
+	}
+	sort.SliceStable(acceptedTypes, func(i, j int) bool {
+		return acceptedTypes[i].q > acceptedTypes[j].q
+	})
+
+	for _, a := range acceptedTypes {
+		for _, s := range supported {
+			if a.mediaType == s || a.mediaType == "*/*" ||
+				a.mediaType == strings.Split(s, "/")[0]+"/*" {
+				return s
+			}
+		}
+	}
+
+	return supported[0]
+}
+
+func renderNDJSON(w rest.ResponseWriter, items reflect.Value) {
+	h, _ := w.(http.ResponseWriter)
+	flusher, _ := w.(http.Flusher)
+
+	h.Header().Set("Content-Type", MediaTypeNDJSON)
+	h.WriteHeader(http.StatusOK)
+
+	for i := 0; i < items.Len(); i++ {
+		data, err := json.Marshal(items.Index(i).Interface())
+		if err != nil {
+			panic(err)
+		}
+		h.Write(append(data, '\n'))
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+}
+
+func renderCSV(w rest.ResponseWriter, items reflect.Value) {
+	columns := []string{}
+	known := map[string]bool{}
+	rows := make([]map[string]json.RawMessage, items.Len())
+
+	for i := range rows {
+		data, err := json.Marshal(items.Index(i).Interface())
+		if err != nil {
+			panic(err)
+		}
+		fields, row, err := decodeObject(data)
+		if err != nil {
+			panic(err)
+		}
+		for _, f := range fields {
+			if !known[f] {
+				known[f] = true
+				columns = append(columns, f)
+			}
+		}
+		rows[i] = row
+	}
+
+	h, _ := w.(http.ResponseWriter)
+	h.Header().Set("Content-Type", MediaTypeCSV)
+	h.WriteHeader(http.StatusOK)
+
+	writer := csv.NewWriter(h)
+	writer.Write(columns)
+	for _, row := range rows {
+		record := make([]string, len(columns))
+		for i, c := range columns {
+			record[i] = csvValue(row[c])
+		}
+		writer.Write(record)
+	}
+	writer.Flush()
+}
+
+// decodeObject returns field names of JSON object in the encoded order
+// and their raw values.
+func decodeObject(data []byte) ([]string, map[string]json.RawMessage, error) {
+	values := map[string]json.RawMessage{}
+	if err := json.Unmarshal(data, &values); err != nil {
+		// not an object, single column
+		return []string{"value"}, map[string]json.RawMessage{"value": data}, nil
+	}
+
+	fields := []string{}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	// opening brace
+	if _, err := dec.Token(); err != nil {
+		return nil, nil, err
+	}
+	for dec.More() {
+		key, err := dec.Token()
+		if err != nil {
+			return nil, nil, err
+		}
+		fields = append(fields, key.(string))
+		var skip json.RawMessage
+		if err := dec.Decode(&skip); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return fields, values, nil
+}
+
+// csvValue formats JSON value as CSV cell: strings unquoted, null empty,
+// nested objects and arrays as JSON.
+func csvValue(value json.RawMessage) string {
+	if len(value) == 0 || string(value) == "null" {
+		return ""
+	}
+	var s string
+	if err := json.Unmarshal(value, &s); err == nil {
+		return s
+	}
+	return string(value)
+}
@@ -0,0 +1,112 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package view_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/ant0ine/go-json-rest/rest"
+	"github.com/ant0ine/go-json-rest/rest/test"
+	"github.com/stretchr/testify/assert"
+
+	. "github.com/mendersoftware/deployments/utils/restutil/view"
+)
+
+func TestNegotiateMediaType(t *testing.T) {
+	supported := []string{MediaTypeJSON, MediaTypeNDJSON, MediaTypeCSV}
+
+	testCases := map[string]string{
+		"":                                       MediaTypeJSON,
+		"*/*":                                    MediaTypeJSON,
+		"text/html":                              MediaTypeJSON,
+		"text/csv":                               MediaTypeCSV,
+		"text/*":                                 MediaTypeCSV,
+		"application/x-ndjson":                   MediaTypeNDJSON,
+		"text/csv;q=0.5, application/x-ndjson":   MediaTypeNDJSON,
+		"text/csv;q=0.9, application/json;q=0.1": MediaTypeCSV,
+		"text/csv;q=0, */*":                      MediaTypeJSON,
+	}
+
+	for accept, mediaType := range testCases {
+		assert.Equal(t, mediaType, NegotiateMediaType(accept, supported...), accept)
+	}
+}
+
+func TestRenderCollection(t *testing.T) {
+	type item struct {
+		Name   string            `json:"name"`
+		Size   int               `json:"size"`
+		Tags   []string          `json:"tags,omitempty"`
+		Extra  map[string]string `json:"extra,omitempty"`
+		Parent *string           `json:"parent"`
+	}
+	parent := "root"
+	collection := []item{
+		{Name: "foo", Size: 1, Parent: &parent},
+		{Name: "bar, baz", Size: 2, Tags: []string{"a", "b"}},
+	}
+
+	testCases := map[string]struct {
+		accept      string
+		contentType string
+		body        string
+	}{
+		"json": {
+			contentType: MediaTypeJSON,
+			body: `[{"name":"foo","size":1,"parent":"root"},` +
+				`{"name":"bar, baz","size":2,"tags":["a","b"],"parent":null}]`,
+		},
+		"ndjson": {
+			accept:      MediaTypeNDJSON,
+			contentType: MediaTypeNDJSON,
+			body: `{"name":"foo","size":1,"parent":"root"}` + "\n" +
+				`{"name":"bar, baz","size":2,"tags":["a","b"],"parent":null}` + "\n",
+		},
+		"csv": {
+			accept:      MediaTypeCSV,
+			contentType: MediaTypeCSV,
+			body: "name,size,parent,tags\n" +
+				"foo,1,root,\n" +
+				`"bar, baz",2,,"[""a"",""b""]"` + "\n",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			router, err := rest.MakeRouter(rest.Get("/test", func(w rest.ResponseWriter, r *rest.Request) {
+				new(RESTView).RenderCollection(w, r, collection)
+			}))
+			assert.NoError(t, err)
+
+			api := rest.NewApi()
+			api.SetApp(router)
+
+			req := test.MakeSimpleRequest("GET", "http://localhost/test", nil)
+			if tc.accept != "" {
+				req.Header.Set("Accept", tc.accept)
+			}
+			recorded := test.RunRequest(t, api.MakeHandler(), req)
+
+			recorded.CodeIs(http.StatusOK)
+			if tc.contentType == MediaTypeJSON {
+				recorded.ContentTypeIsJson()
+			} else {
+				recorded.HeaderIs("Content-Type", tc.contentType)
+			}
+			recorded.BodyIs(tc.body)
+		})
+	}
+}
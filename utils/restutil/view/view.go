@@ -43,6 +43,14 @@ func (p *RESTView) RenderSuccessPost(w rest.ResponseWriter, r *rest.Request, id
 	w.WriteHeader(http.StatusCreated)
 }
 
+// RenderSuccessPostObject renders created response with details of the
+// created resource.
+func (p *RESTView) RenderSuccessPostObject(w rest.ResponseWriter, r *rest.Request,
+	id string, object interface{}) {
+	p.RenderSuccessPost(w, r, id)
+	w.WriteJson(object)
+}
+
 func (p *RESTView) RenderSuccessGet(w rest.ResponseWriter, object interface{}) {
 	w.WriteJson(object)
 }
@@ -52,6 +60,26 @@ func (p *RESTView) RenderError(w rest.ResponseWriter, r *rest.Request, err error
 	renderErrorWithMsg(w, r, status, err.Error())
 }
 
+// RenderErrorDetails renders error response extended with the details,
+// e.g. the violated rule, for clients to act on.
+func (p *RESTView) RenderErrorDetails(w rest.ResponseWriter, r *rest.Request, err error,
+	status int, details map[string]interface{}, l *log.Logger) {
+
+	l.Error(err.Error())
+
+	body := make(map[string]interface{}, len(details)+2)
+	for key, value := range details {
+		body[key] = value
+	}
+	body["error"] = err.Error()
+	body["request_id"] = requestid.GetReqId(r)
+
+	w.WriteHeader(status)
+	if writeErr := w.WriteJson(body); writeErr != nil {
+		panic(writeErr)
+	}
+}
+
 func (p *RESTView) RenderInternalError(w rest.ResponseWriter, r *rest.Request, err error, l *log.Logger) {
 	l.F(log.Ctx{}).Error(err.Error())
 	renderErrorWithMsg(w, r, http.StatusInternalServerError, "internal error")
@@ -15,6 +15,7 @@
 package view_test
 
 import (
+	"errors"
 	"net/http"
 	"testing"
 
@@ -127,3 +128,29 @@ func TestRenderErrorNotFound(t *testing.T) {
 	recorded.CodeIs(http.StatusNotFound)
 	recorded.BodyIs(`{"error":"Resource not found","request_id":""}`)
 }
+
+func TestRenderErrorDetails(t *testing.T) {
+
+	router, err := rest.MakeRouter(rest.Get("/test", func(w rest.ResponseWriter, r *rest.Request) {
+
+		l := log.New(log.Ctx{})
+		new(RESTView).RenderErrorDetails(w, r, errors.New("name not allowed"),
+			http.StatusUnprocessableEntity, map[string]interface{}{
+				"policy": map[string]string{"pattern": "app-.*"},
+				"error":  "overridden",
+			}, l)
+	}))
+
+	if err != nil {
+		assert.NoError(t, err)
+	}
+
+	api := rest.NewApi()
+	api.SetApp(router)
+
+	recorded := test.RunRequest(t, api.MakeHandler(),
+		test.MakeSimpleRequest("GET", "http://localhost/test", nil))
+
+	recorded.CodeIs(http.StatusUnprocessableEntity)
+	recorded.BodyIs(`{"error":"name not allowed","policy":{"pattern":"app-.*"},"request_id":""}`)
+}
@@ -0,0 +1,159 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package testing
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// WebSocketClient is a minimal WebSocket client for testing handlers
+// streaming JSON messages.
+type WebSocketClient struct {
+	Conn     net.Conn
+	Response *http.Response
+
+	r *bufio.Reader
+}
+
+// DialWebSocket connects to the WebSocket endpoint at the http:// URL
+// with the given extra request headers.
+func DialWebSocket(rawurl string, header http.Header) (*WebSocketClient, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := net.Dial("tcp", u.Host)
+	if err != nil {
+		return nil, err
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, rawurl, nil)
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+	if err := req.Write(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	r := bufio.NewReader(conn)
+	rsp, err := http.ReadResponse(r, req)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return &WebSocketClient{Conn: conn, Response: rsp, r: r}, nil
+}
+
+// WriteFrame sends a masked frame with the given first header byte.
+func (c *WebSocketClient) WriteFrame(b0 byte, payload []byte) error {
+	frame := []byte{b0}
+	if len(payload) < 126 {
+		frame = append(frame, 0x80|byte(len(payload)))
+	} else {
+		frame = append(frame, 0x80|126, 0, 0)
+		binary.BigEndian.PutUint16(frame[2:], uint16(len(payload)))
+	}
+
+	mask := []byte{1, 2, 3, 4}
+	frame = append(frame, mask...)
+	for i, b := range payload {
+		frame = append(frame, b^mask[i%4])
+	}
+
+	_, err := c.Conn.Write(frame)
+	return err
+}
+
+// WriteJSON sends v as a text message.
+func (c *WebSocketClient) WriteJSON(v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return c.WriteFrame(0x81, data)
+}
+
+// ReadFrame returns the first header byte and payload of the next frame,
+// waiting no longer than timeout.
+func (c *WebSocketClient) ReadFrame(timeout time.Duration) (byte, []byte, error) {
+	c.Conn.SetReadDeadline(time.Now().Add(timeout))
+
+	var header [2]byte
+	if _, err := io.ReadFull(c.r, header[:]); err != nil {
+		return 0, nil, err
+	}
+	if header[1]&0x80 != 0 {
+		return 0, nil, errors.New("server frame masked")
+	}
+
+	size := uint64(header[1])
+	switch size {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(c.r, ext[:]); err != nil {
+			return 0, nil, err
+		}
+		size = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(c.r, ext[:]); err != nil {
+			return 0, nil, err
+		}
+		size = binary.BigEndian.Uint64(ext[:])
+	}
+
+	payload := make([]byte, size)
+	if _, err := io.ReadFull(c.r, payload); err != nil {
+		return 0, nil, err
+	}
+	return header[0], payload, nil
+}
+
+// ReadJSON decodes the next text message into v, skipping pings.
+func (c *WebSocketClient) ReadJSON(v interface{}, timeout time.Duration) error {
+	for {
+		b0, payload, err := c.ReadFrame(timeout)
+		if err != nil {
+			return err
+		}
+		if b0 == 0x89 {
+			continue
+		}
+		if b0 != 0x81 {
+			return errors.New("unexpected frame")
+		}
+		return json.Unmarshal(payload, v)
+	}
+}
+
+// Close closes the connection.
+func (c *WebSocketClient) Close() error {
+	return c.Conn.Close()
+}
@@ -0,0 +1,132 @@
+// Copyright 2017 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package websocket_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	h "github.com/mendersoftware/deployments/utils/testing"
+	"github.com/mendersoftware/deployments/utils/websocket"
+)
+
+func TestAcceptKey(t *testing.T) {
+	// example of RFC 6455
+	assert.Equal(t, "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=",
+		websocket.AcceptKey("dGhlIHNhbXBsZSBub25jZQ=="))
+}
+
+func TestIsUpgrade(t *testing.T) {
+	testCases := map[string]struct {
+		method  string
+		header  map[string]string
+		upgrade bool
+	}{
+		"ok": {
+			method: http.MethodGet,
+			header: map[string]string{
+				"Connection": "keep-alive, Upgrade",
+				"Upgrade":    "websocket",
+			},
+			upgrade: true,
+		},
+		"no upgrade": {
+			method: http.MethodGet,
+			header: map[string]string{"Connection": "keep-alive"},
+		},
+		"post": {
+			method: http.MethodPost,
+			header: map[string]string{
+				"Connection": "Upgrade",
+				"Upgrade":    "websocket",
+			},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			r := httptest.NewRequest(tc.method, "/", nil)
+			for key, value := range tc.header {
+				r.Header.Set(key, value)
+			}
+			assert.Equal(t, tc.upgrade, websocket.IsUpgrade(r))
+		})
+	}
+}
+
+func TestConn(t *testing.T) {
+	received := make(chan []byte, 1)
+	srv := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			conn, err := websocket.Upgrade(w, r)
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			defer conn.Close()
+
+			message, err := conn.ReadMessage()
+			assert.NoError(t, err)
+			received <- message
+
+			assert.NoError(t, conn.WriteJSON(map[string]string{"echo": string(message)}))
+
+			_, err = conn.ReadMessage()
+			assert.Equal(t, io.EOF, err)
+		}))
+	defer srv.Close()
+
+	client, err := h.DialWebSocket(srv.URL, nil)
+	assert.NoError(t, err)
+	defer client.Close()
+
+	assert.Equal(t, http.StatusSwitchingProtocols, client.Response.StatusCode)
+	assert.Equal(t, "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=",
+		client.Response.Header.Get("Sec-WebSocket-Accept"))
+
+	// fragmented message with a ping in between
+	assert.NoError(t, client.WriteFrame(0x01, []byte("hello ")))
+	assert.NoError(t, client.WriteFrame(0x89, []byte("ping")))
+	assert.NoError(t, client.WriteFrame(0x80, []byte("world")))
+
+	b0, payload, err := client.ReadFrame(time.Second)
+	assert.NoError(t, err)
+	assert.Equal(t, byte(0x8a), b0)
+	assert.Equal(t, "ping", string(payload))
+
+	var echo map[string]string
+	assert.NoError(t, client.ReadJSON(&echo, time.Second))
+	assert.Equal(t, map[string]string{"echo": "hello world"}, echo)
+	assert.Equal(t, []byte("hello world"), <-received)
+
+	assert.NoError(t, client.WriteFrame(0x88, nil))
+	b0, _, err = client.ReadFrame(time.Second)
+	assert.NoError(t, err)
+	assert.Equal(t, byte(0x88), b0)
+}
+
+func TestUpgradeNotWebSocket(t *testing.T) {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+
+	conn, err := websocket.Upgrade(w, r)
+	assert.Nil(t, conn)
+	assert.Equal(t, websocket.ErrNotWebSocket, err)
+}